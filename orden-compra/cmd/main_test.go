@@ -0,0 +1,104 @@
+package main
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"medisupply/httpsecurity"
+	"orden-compra/internal/tenant"
+)
+
+func TestRoutesAreVersionedAndDocumented(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := setupRouter(nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, tenant.Policy{}, httpsecurity.CORSConfig{}, httpsecurity.HeadersConfig{}, log.New(io.Discard, "", 0))
+
+	routes := router.Routes()
+	for _, route := range routes {
+		if !unversionedPaths[route.Path] && !strings.HasPrefix(route.Path, apiPrefix+"/") {
+			t.Errorf("%s %s is outside %s", route.Method, route.Path, apiPrefix)
+		}
+	}
+	for path := range unversionedPaths {
+		if !hasRoute(routes, "GET", path) {
+			t.Errorf("GET %s is not served at the root", path)
+		}
+	}
+	if !hasRoute(routes, "GET", apiPrefix+"/purchase-orders/:id/pdf") {
+		t.Errorf("GET %s/purchase-orders/:id/pdf is not served", apiPrefix)
+	}
+
+	// Every documented operation names a registered route
+	documented := describeRoutes().Document(nil)["paths"].(map[string]interface{})
+	paths := describeRoutes().Document(routes)["paths"].(map[string]interface{})
+	for path, item := range documented {
+		for method := range item.(map[string]interface{}) {
+			if operations, ok := paths[path].(map[string]interface{}); !ok || operations[method] == nil {
+				t.Errorf("%s %s is documented but not served", strings.ToUpper(method), path)
+			}
+		}
+	}
+	if operation, ok := paths[apiPrefix+"/purchase-orders/{id}"].(map[string]interface{})["get"].(map[string]interface{}); !ok || operation["summary"] == nil {
+		t.Errorf("GET %s/purchase-orders/{id} is not documented", apiPrefix)
+	}
+}
+
+func TestLegacyPathsServeTheVersionedRoute(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	var middlewareRuns int
+	router.Use(func(c *gin.Context) {
+		middlewareRuns++
+		c.Next()
+	})
+	router.GET("/health", func(c *gin.Context) { c.String(200, "healthy") })
+	router.GET(apiPrefix+"/purchase-orders/:id", func(c *gin.Context) { c.String(200, c.Param("id")) })
+	handler := legacyPaths(router)
+
+	for _, tc := range []struct {
+		path       string
+		status     int
+		body       string
+		deprecated bool
+		link       string
+	}{
+		{apiPrefix + "/purchase-orders/po-1", 200, "po-1", false, ""},
+		{"/purchase-orders/po-1", 200, "po-1", true, "</api/v1/purchase-orders/po-1>; rel=\"successor-version\""},
+		{"/health", 200, "healthy", false, ""},
+		{"/unknown", 404, "", true, "</api/v1/unknown>; rel=\"successor-version\""},
+	} {
+		t.Run(tc.path, func(t *testing.T) {
+			middlewareRuns = 0
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, tc.path, nil))
+
+			if recorder.Code != tc.status || (tc.body != "" && recorder.Body.String() != tc.body) {
+				t.Fatalf("status %d with %q, want %d with %q", recorder.Code, recorder.Body.String(), tc.status, tc.body)
+			}
+			if deprecated := recorder.Header().Get("Deprecation") == "true"; deprecated != tc.deprecated {
+				t.Fatalf("Deprecation header %q", recorder.Header().Get("Deprecation"))
+			}
+			if link := recorder.Header().Get("Link"); link != tc.link {
+				t.Fatalf("Link %q, want %q", link, tc.link)
+			}
+			if tc.status == 200 && middlewareRuns != 1 {
+				t.Fatalf("middleware ran %d times", middlewareRuns)
+			}
+		})
+	}
+}
+
+// hasRoute reports whether a route is registered with the method and Gin path
+func hasRoute(routes gin.RoutesInfo, method, path string) bool {
+	for _, route := range routes {
+		if route.Method == method && route.Path == path {
+			return true
+		}
+	}
+	return false
+}
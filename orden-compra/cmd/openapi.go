@@ -0,0 +1,1141 @@
+package main
+
+import (
+	"time"
+
+	"orden-compra/internal/attachments"
+	"orden-compra/internal/auth"
+	"orden-compra/internal/cqrs"
+	"orden-compra/internal/flow"
+	"orden-compra/internal/handlers"
+	"orden-compra/internal/limiter"
+	"orden-compra/internal/models"
+	"orden-compra/internal/openapi"
+	"orden-compra/internal/search"
+	"orden-compra/internal/slo"
+)
+
+// errorResponse is the body returned by every failing REST endpoint
+var errorResponse = openapi.Fields{"success": false, "error": ""}
+
+// purchaseOrderStats documents the statistics computed by the read model
+var purchaseOrderStats = openapi.Fields{
+	"total_orders":     0,
+	"pending_orders":   0,
+	"completed_orders": 0,
+	"overdue_orders":   0,
+	"by_status":        map[string]int{},
+	"by_urgency":       map[string]int{},
+	"by_supplier":      map[string]int{},
+	"open_by_urgency":  map[string]int{},
+	"received_orders":  0,
+	"quality_failures": 0,
+	// Seconds from creation to receipt
+	"average_receipt_latency": 0.0,
+	"quality_failure_rate":    0.0,
+}
+
+// idempotent documents the Idempotency-Key header of a command route
+func idempotent(operation openapi.Operation) openapi.Operation {
+	operation.Description += " Send an Idempotency-Key header to retry safely: a retry with the same key and body gets the first response, marked Idempotent-Replayed, without running the command again."
+
+	// Routes may share their responses
+	responses := make(map[int]openapi.Response, len(operation.Responses)+2)
+	for status, response := range operation.Responses {
+		responses[status] = response
+	}
+	conflict := responses[409]
+	if conflict.Description == "" {
+		conflict = openapi.Response{Description: "A request with the same Idempotency-Key is in progress", Body: errorResponse}
+	} else {
+		conflict.Description += ", or a request with the same Idempotency-Key is in progress"
+	}
+	responses[409] = conflict
+	responses[422] = openapi.Response{Description: "The Idempotency-Key was used with a different request", Body: errorResponse}
+	operation.Responses = responses
+	return operation
+}
+
+// tagged documents the ETag of a read route
+func tagged(operation openapi.Operation) openapi.Operation {
+	operation.Description += " Responses carry an ETag; send it in If-None-Match to get 304 Not Modified while the response is unchanged."
+	responses := make(map[int]openapi.Response, len(operation.Responses)+1)
+	for status, response := range operation.Responses {
+		responses[status] = response
+	}
+	responses[304] = openapi.Response{Description: "Unchanged since the ETag in If-None-Match"}
+	operation.Responses = responses
+	return operation
+}
+
+// conditionalCommand documents the If-Match header of a command on a purchase order
+func conditionalCommand(operation openapi.Operation) openapi.Operation {
+	operation.Description += " Send the order's ETag in If-Match to apply the command only if the order has not changed since."
+	responses := make(map[int]openapi.Response, len(operation.Responses)+1)
+	for status, response := range operation.Responses {
+		responses[status] = response
+	}
+	responses[412] = openapi.Response{Description: "The order changed since the ETag in If-Match", Body: errorResponse}
+	operation.Responses = responses
+	return operation
+}
+
+// describeRoutes documents the HTTP API for the generated OpenAPI document.
+// Routes registered in setupRouter without a description still appear in it.
+func describeRoutes() *openapi.Registry {
+	docs := openapi.NewRegistry(
+		"Orden Compra API",
+		"1.0.0",
+		"Purchase order commands, read model queries and outbound webhooks of the MediSupply orden-compra service.",
+	)
+	docs.SecuritySchemes = map[string]interface{}{
+		"apiKey":     map[string]interface{}{"type": "apiKey", "in": "header", "name": auth.APIKeyHeader},
+		"bearerAuth": map[string]interface{}{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+	}
+
+	// Models exchanged with integrations outside the REST endpoints
+	docs.Model("PurchaseOrder", models.PurchaseOrder{})
+	docs.Model("EventSourcingEvent", models.EventSourcingEvent{})
+	docs.Model("Supplier", models.Supplier{})
+	docs.Model("PurchaseOrderStats", purchaseOrderStats)
+
+	docs.Describe("GET", "/health", openapi.Operation{
+		Summary: "Check service health",
+		Tags:    []string{"health"},
+		Public:  true,
+		Responses: map[int]openapi.Response{
+			200: {Description: "Service is healthy", Body: openapi.Fields{"status": "", "timestamp": int64(0), "checks": map[string]string{}}},
+			503: {Description: "A dependency is unavailable", Body: openapi.Fields{"status": "", "timestamp": int64(0), "checks": map[string]string{}, "error": ""}},
+		},
+	})
+
+	docs.Describe("GET", "/ready", openapi.Operation{
+		Summary:     "Check service readiness",
+		Description: "As /health, and also unhealthy while a RabbitMQ consumer is down; paused consumers count as ready. Each consumer is listed in checks as consumer:<name>.",
+		Tags:        []string{"health"},
+		Public:      true,
+		Responses: map[int]openapi.Response{
+			200: {Description: "Service is ready", Body: openapi.Fields{"status": "", "timestamp": int64(0), "checks": map[string]string{}}},
+			503: {Description: "A dependency is unavailable or a consumer is down", Body: openapi.Fields{"status": "", "timestamp": int64(0), "checks": map[string]string{}, "error": ""}},
+		},
+	})
+
+	docs.Describe("GET", "/metrics", openapi.Operation{
+		Summary: "Service metrics",
+		Tags:    []string{"health"},
+		Public:  true,
+		Responses: map[int]openapi.Response{
+			200: {Body: openapi.Fields{"message": "", "timestamp": int64(0)}},
+		},
+	})
+
+	docs.Describe("GET", "/", openapi.Operation{
+		Summary: "Service information",
+		Tags:    []string{"health"},
+		Responses: map[int]openapi.Response{
+			200: {Body: openapi.Fields{"service": "", "version": "", "status": "", "timestamp": int64(0)}},
+		},
+	})
+
+	docs.Describe("POST", apiPrefix+"/reorders", idempotent(openapi.Operation{
+		Summary:     "Schedule a re-order",
+		Description: "Publishes a StockBajo event for the caller's tenant onto the consumed queue, to be processed after delay (a duration such as \"30m\") or at process_at. Without either it is processed at once. Send X-Correlation-ID to correlate the resulting purchase order. With X-Dry-Run: true the event is not published: the supplier, quantity and price of the order it would create now are returned, and nothing is stored.",
+		Tags:        []string{"reorders"},
+		Request:     scheduleReorderRequest{},
+		Responses: map[int]openapi.Response{
+			200: {Description: "Dry run: the order that would be created, or the open order a duplicate would be folded into", Body: openapi.Fields{
+				"success":                true,
+				"dry_run":                true,
+				"event":                  models.StockLowEvent{},
+				"purchase_order":         models.PurchaseOrder{},
+				"requires_approval":      false,
+				"awaiting_consolidation": false,
+				"reorder":                openapi.Fields{"calculated_quantity": 0, "strategy": "", "source": ""},
+				"correlation_id":         (*string)(nil),
+			}},
+			202: {Description: "Re-order scheduled", Body: openapi.Fields{"success": true, "event": models.StockLowEvent{}, "process_at": time.Time{}}},
+			400: {Description: "Invalid event, or delay above the maximum", Body: errorResponse},
+			403: {Description: "Requires the buyer role", Body: errorResponse},
+			500: {Body: errorResponse},
+		},
+	}))
+
+	docs.Describe("POST", apiPrefix+"/purchase-orders/bulk-status", idempotent(openapi.Operation{
+		Summary:     "Update the status of many purchase orders",
+		Description: "Moves up to 100 orders to sent, received or completed, one at a time. A failed order does not stop the others; each result reports its outcome, with an error_code of not_found, invalid_transition, conflict, forbidden or internal. All updates share one correlation ID; send X-Correlation-ID to choose it.",
+		Tags:        []string{"purchase-orders"},
+		Request:     bulkStatusRequest{},
+		Responses: map[int]openapi.Response{
+			200: {Description: "Every order updated", Body: openapi.Fields{
+				"success":        true,
+				"status":         "",
+				"requested":      0,
+				"succeeded":      0,
+				"failed":         0,
+				"results":        []handlers.BulkStatusResult{},
+				"correlation_id": (*string)(nil),
+			}},
+			207: {Description: "Some orders failed; the body is as for 200 and each result tells which"},
+			400: {Description: "Invalid status, or no orders or too many", Body: errorResponse},
+			403: {Description: "Requires the buyer role", Body: errorResponse},
+			500: {Body: errorResponse},
+		},
+	}))
+
+	docs.Describe("POST", apiPrefix+"/purchase-orders/:id/cancel", conditionalCommand(idempotent(openapi.Operation{
+		Summary:     "Cancel a purchase order",
+		Description: "Cancels an order that has not been received yet and publishes a PurchaseOrderCancelled event. Send X-Correlation-ID to correlate the emitted events.",
+		Tags:        []string{"purchase-orders"},
+		Request:     cancelPurchaseOrderRequest{},
+		Responses: map[int]openapi.Response{
+			200: {Description: "Purchase order cancelled", Body: openapi.Fields{
+				"success":            true,
+				"purchase_order_id":  "",
+				"status":             "",
+				"cancellation_event": models.PurchaseOrderCancelledEvent{},
+				"correlation_id":     (*string)(nil),
+			}},
+			400: {Description: "Missing reason", Body: errorResponse},
+			403: {Description: "Requires the buyer role", Body: errorResponse},
+			404: {Description: "Purchase order not found", Body: errorResponse},
+			409: {Description: "Purchase order can no longer be cancelled, or was modified concurrently", Body: errorResponse},
+			500: {Body: errorResponse},
+		},
+	})))
+
+	docs.Describe("POST", apiPrefix+"/purchase-orders/:id/asn", conditionalCommand(idempotent(openapi.Operation{
+		Summary:     "Attach an advance shipment notice",
+		Description: "Records the supplier's expected delivery date, carrier, tracking number and lots on the order, moves its expected_date and forwards the notice to Proveedor so the reception is expected. A later notice replaces the earlier one.",
+		Tags:        []string{"purchase-orders"},
+		Request:     models.AdvanceShipmentNotice{},
+		Responses: map[int]openapi.Response{
+			200: {Description: "Notice attached", Body: openapi.Fields{
+				"success":           true,
+				"purchase_order_id": "",
+				"expected_date":     time.Time{},
+				"asn":               models.AdvanceShipmentNotice{},
+				"asn_event":         models.AdvanceShipmentNoticeEvent{},
+				"correlation_id":    (*string)(nil),
+			}},
+			400: {Description: "Invalid notice or supplier", Body: errorResponse},
+			403: {Description: "Requires the buyer role", Body: errorResponse},
+			404: {Description: "Purchase order not found", Body: errorResponse},
+			409: {Description: "Purchase order is not awaiting a shipment, or was modified concurrently", Body: errorResponse},
+			500: {Body: errorResponse},
+		},
+	})))
+
+	docs.Describe("POST", apiPrefix+"/purchase-orders/:id/acknowledgement", conditionalCommand(idempotent(openapi.Operation{
+		Summary:     "Record a supplier acknowledgement",
+		Description: "Records whether the supplier accepted the order. An accepted order moves to sent and a promised date becomes its expected_date unless an ASN already set one; a rejected order is cancelled and Proveedor is notified.",
+		Tags:        []string{"purchase-orders"},
+		Request:     models.OrderAcknowledgement{},
+		Responses: map[int]openapi.Response{
+			200: {Description: "Acknowledgement recorded", Body: openapi.Fields{
+				"success":            true,
+				"purchase_order_id":  "",
+				"status":             "",
+				"previous_status":    "",
+				"acknowledgement":    models.OrderAcknowledgement{},
+				"cancellation_event": (*models.PurchaseOrderCancelledEvent)(nil),
+				"correlation_id":     (*string)(nil),
+			}},
+			400: {Description: "Invalid acknowledgement", Body: errorResponse},
+			403: {Description: "Requires the buyer role", Body: errorResponse},
+			404: {Description: "Purchase order not found", Body: errorResponse},
+			409: {Description: "Purchase order is not awaiting an acknowledgement, or was modified concurrently", Body: errorResponse},
+			500: {Body: errorResponse},
+		},
+	})))
+
+	docs.Describe("POST", apiPrefix+"/purchase-orders/:id/comments", idempotent(openapi.Operation{
+		Summary:     "Comment on a purchase order",
+		Description: "Adds a note to the order in any status. The author is the caller. A PurchaseOrderCommentAdded event is recorded, so the comment shows in the order's timeline.",
+		Tags:        []string{"purchase-orders"},
+		Request:     models.OrderComment{},
+		Responses: map[int]openapi.Response{
+			201: {Description: "Comment added", Body: openapi.Fields{
+				"success":           true,
+				"purchase_order_id": "",
+				"comment":           models.OrderComment{},
+				"version":           0,
+				"correlation_id":    (*string)(nil),
+			}},
+			400: {Description: "Missing or too long text", Body: errorResponse},
+			403: {Description: "Requires the buyer role", Body: errorResponse},
+			404: {Description: "Purchase order not found", Body: errorResponse},
+			409: {Description: "Purchase order kept being modified concurrently", Body: errorResponse},
+			500: {Body: errorResponse},
+		},
+	}))
+
+	docs.Describe("POST", apiPrefix+"/purchase-orders/:id/attachments", idempotent(openapi.Operation{
+		Summary:     "Attach a file to a purchase order",
+		Description: "Records the file's metadata with the order and returns a presigned S3 URL to PUT the file to before upload.expires_at, with the declared content_type and size as Content-Type and Content-Length. The uploader is the caller. A PurchaseOrderAttachmentAdded event is recorded, so the attachment shows in the order's timeline.",
+		Tags:        []string{"purchase-orders"},
+		Request:     models.OrderAttachment{},
+		Responses: map[int]openapi.Response{
+			201: {Description: "Attachment recorded, upload pending", Body: openapi.Fields{
+				"success":           true,
+				"purchase_order_id": "",
+				"attachment":        models.OrderAttachment{},
+				"upload":            attachments.PresignedURL{},
+				"version":           0,
+				"correlation_id":    (*string)(nil),
+			}},
+			400: {Description: "Invalid file name, content type or size, or the order has too many attachments", Body: errorResponse},
+			403: {Description: "Requires the buyer role", Body: errorResponse},
+			404: {Description: "Purchase order not found", Body: errorResponse},
+			409: {Description: "Purchase order kept being modified concurrently", Body: errorResponse},
+			503: {Description: "No attachments bucket is configured", Body: errorResponse},
+			500: {Body: errorResponse},
+		},
+	}))
+
+	docs.Describe("GET", apiPrefix+"/purchase-orders/:id/attachments", tagged(openapi.Operation{
+		Summary:     "List the comments and attachments of a purchase order",
+		Description: "Returns the order's comments and attachments, oldest first. Each attachment has a presigned download URL when an attachments bucket is configured.",
+		Tags:        []string{"purchase-orders"},
+		Responses: map[int]openapi.Response{
+			200: {Body: openapi.Fields{
+				"success":           true,
+				"purchase_order_id": "",
+				"comments":          []models.OrderComment{},
+				"attachments": []openapi.Fields{{
+					"attachment": models.OrderAttachment{},
+					"download":   attachments.PresignedURL{},
+				}},
+			}},
+			403: {Description: "Requires the viewer role", Body: errorResponse},
+			404: {Description: "Purchase order not found", Body: errorResponse},
+			500: {Body: errorResponse},
+		},
+	}))
+
+	docs.Describe("POST", apiPrefix+"/purchase-orders/:id/invoices", idempotent(openapi.Operation{
+		Summary:     "Attach a supplier invoice to a purchase order",
+		Description: "Records an invoice of the order's supplier and matches the order's invoices against the quantity and unit price ordered and the quantity received. Line amounts left out are computed. A PurchaseOrderInvoiced event is recorded; when the match fails an InvoiceMismatchDetected event is recorded and published for finance. Invoices also arrive as FacturaRecibida events.",
+		Tags:        []string{"purchase-orders"},
+		Request:     models.SupplierInvoice{},
+		Responses: map[int]openapi.Response{
+			201: {Description: "Invoice attached and matched", Body: openapi.Fields{
+				"success":           true,
+				"purchase_order_id": "",
+				"invoice":           models.SupplierInvoice{},
+				"invoice_match":     models.InvoiceMatch{},
+				"mismatch_event":    models.InvoiceMismatchDetectedEvent{},
+				"correlation_id":    (*string)(nil),
+			}},
+			400: {Description: "Invalid invoice, another supplier's invoice, or the order has too many invoices", Body: errorResponse},
+			403: {Description: "Requires the buyer role", Body: errorResponse},
+			404: {Description: "Purchase order not found", Body: errorResponse},
+			409: {Description: "The order was never released, the invoice number is already attached, or the order was modified concurrently", Body: errorResponse},
+			500: {Body: errorResponse},
+		},
+	}))
+
+	docs.Describe("GET", apiPrefix+"/purchase-orders/:id/invoice-match", tagged(openapi.Operation{
+		Summary:     "Three-way match of a purchase order",
+		Description: "Returns the order's invoices and their match against the order and its receipt, computed with the current tolerances. The status is pending until the order is invoiced, awaiting_receipt while the invoices match the order but nothing was received, and matched or mismatch after that; each discrepancy names the invoice and what differs.",
+		Tags:        []string{"purchase-orders"},
+		Responses: map[int]openapi.Response{
+			200: {Body: openapi.Fields{
+				"success":           true,
+				"purchase_order_id": "",
+				"supplier_id":       "",
+				"status":            "",
+				"pricing":           models.OrderPricing{},
+				"receipt":           models.InventoryReceipt{},
+				"invoices":          []models.SupplierInvoice{},
+				"invoice_match":     models.InvoiceMatch{},
+			}},
+			403: {Description: "Requires the viewer role", Body: errorResponse},
+			404: {Description: "Purchase order not found", Body: errorResponse},
+			500: {Body: errorResponse},
+		},
+	}))
+
+	docs.Describe("POST", apiPrefix+"/purchase-orders/:id/dispatch", idempotent(openapi.Operation{
+		Summary:     "Dispatch a purchase order to its supplier",
+		Description: "Sends a released order to its supplier again through every configured channel, email and EDI 850, for instance after its earlier dispatch failed. Sending runs in the background; its outcome is recorded in the order's dispatch field.",
+		Tags:        []string{"purchase-orders"},
+		Responses: map[int]openapi.Response{
+			202: {Description: "Dispatch started", Body: openapi.Fields{
+				"success":           true,
+				"purchase_order_id": "",
+				"status":            models.DispatchPending,
+				"channels":          []string{},
+			}},
+			403: {Description: "Requires the buyer role", Body: errorResponse},
+			404: {Description: "Purchase order not found", Body: errorResponse},
+			409: {Description: "Purchase order is not released to its supplier", Body: errorResponse},
+			503: {Description: "No dispatch channel is configured", Body: errorResponse},
+			500: {Body: errorResponse},
+		},
+	}))
+
+	docs.Describe("POST", apiPrefix+"/purchase-orders/:id/erp-export", idempotent(openapi.Operation{
+		Summary:     "Export a completed purchase order to the ERP",
+		Description: "Delivers the order's purchase order document, and its goods receipt when it has one, to the ERP again, for instance for an order completed before ERP export was enabled. Delivery runs in the background; follow it on /erp/deliveries.",
+		Tags:        []string{"purchase-orders"},
+		Responses: map[int]openapi.Response{
+			202: {Description: "Export started", Body: openapi.Fields{
+				"success":           true,
+				"purchase_order_id": "",
+				"deliveries":        []models.ERPDelivery{},
+			}},
+			403: {Description: "Requires the buyer role", Body: errorResponse},
+			404: {Description: "Purchase order not found", Body: errorResponse},
+			409: {Description: "Purchase order is not completed", Body: errorResponse},
+			503: {Description: "ERP export is not configured", Body: errorResponse},
+			500: {Body: errorResponse},
+		},
+	}))
+
+	approvalResponses := map[int]openapi.Response{
+		200: {Body: openapi.Fields{
+			"success":           true,
+			"purchase_order_id": "",
+			"status":            "",
+			"purchase_order":    models.PurchaseOrder{},
+		}},
+		400: {Description: "Missing approver", Body: errorResponse},
+		403: {Description: "Requires the approver role", Body: errorResponse},
+		404: {Description: "Purchase order not found", Body: errorResponse},
+		409: {Description: "Purchase order is not pending approval, or was modified concurrently", Body: errorResponse},
+		500: {Body: errorResponse},
+	}
+
+	docs.Describe("POST", apiPrefix+"/purchase-orders/:id/approve", conditionalCommand(idempotent(openapi.Operation{
+		Summary:     "Approve a purchase order",
+		Description: "Approves an order pending approval and releases its RecepcionProveedor event.",
+		Tags:        []string{"purchase-orders"},
+		Request:     approvalDecisionRequest{},
+		Responses:   approvalResponses,
+	})))
+
+	docs.Describe("POST", apiPrefix+"/purchase-orders/:id/reject", conditionalCommand(idempotent(openapi.Operation{
+		Summary:     "Reject a purchase order",
+		Description: "Rejects an order pending approval.",
+		Tags:        []string{"purchase-orders"},
+		Request:     approvalDecisionRequest{},
+		Responses:   approvalResponses,
+	})))
+
+	docs.Describe("GET", apiPrefix+"/purchase-orders/:id", tagged(openapi.Operation{
+		Summary:     "Get a purchase order",
+		Description: "Returns the order as stored in the read model. The ETag changes with every write to the order; send it in If-Match on order commands to apply them only to this version.",
+		Tags:        []string{"purchase-orders"},
+		Responses: map[int]openapi.Response{
+			200: {Body: openapi.Fields{"success": true, "purchase_order": models.PurchaseOrder{}}},
+			403: {Description: "Requires the viewer role", Body: errorResponse},
+			404: {Description: "Purchase order not found", Body: errorResponse},
+			500: {Body: errorResponse},
+		},
+	}))
+
+	docs.Describe("GET", apiPrefix+"/purchase-orders/:id/pdf", tagged(openapi.Operation{
+		Summary:     "Render a purchase order as PDF",
+		Description: "Returns a printable application/pdf purchase order with the supplier details from the catalog, the line items, totals and a Code 128 barcode of the order number, for suppliers that require paper or PDF copies.",
+		Tags:        []string{"purchase-orders"},
+		Responses: map[int]openapi.Response{
+			200: {Description: "PDF document"},
+			403: {Description: "Requires the viewer role", Body: errorResponse},
+			404: {Description: "Purchase order not found", Body: errorResponse},
+			500: {Body: errorResponse},
+		},
+	}))
+
+	docs.Describe("GET", apiPrefix+"/purchase-orders/:id/status-history", tagged(openapi.Operation{
+		Summary:     "List the status changes of a purchase order",
+		Description: "Derives every status change, oldest first, from the order's events. Events stored before the previous status was recorded fall back to the status of the preceding event; events archived to S3 are not included until restored.",
+		Tags:        []string{"purchase-orders"},
+		Responses: map[int]openapi.Response{
+			200: {Body: openapi.Fields{"success": true, "purchase_order_id": "", "status": "", "history": []cqrs.StatusHistoryEntry{}, "count": 0}},
+			403: {Description: "Requires the viewer role", Body: errorResponse},
+			404: {Description: "Purchase order not found", Body: errorResponse},
+			500: {Body: errorResponse},
+		},
+	}))
+
+	docs.Describe("GET", apiPrefix+"/purchase-orders/:id/timeline", tagged(openapi.Operation{
+		Summary:     "Get the timeline of a purchase order",
+		Description: "Merges the order's events, the reception events Proveedor logged for it and its audit entries into one timeline, oldest first, for support and compliance reviews. Each entry has a summary in plain words; audit entries carry the actor and outcome. When Proveedor or the audit log cannot be read the timeline is returned without them and they are listed in unavailable. Events archived to S3 are not included until restored.",
+		Tags:        []string{"purchase-orders"},
+		Responses: map[int]openapi.Response{
+			200: {Body: openapi.Fields{"success": true, "timeline": flow.OrderTimeline{Timeline: []flow.Entry{{}}}}},
+			403: {Description: "Requires the viewer role", Body: errorResponse},
+			404: {Description: "The order has no event or audit entry", Body: errorResponse},
+			500: {Body: errorResponse},
+		},
+	}))
+
+	docs.Describe("GET", apiPrefix+"/purchase-orders/:id/rehydrated", tagged(openapi.Operation{
+		Summary:     "Rebuild a purchase order from its events",
+		Description: "Returns the order as recorded by its latest snapshot and the events stored after it, regardless of the read model. The aggregate snapshotter snapshots orders every SNAPSHOT_EVERY events, so events_replayed stays bounded for long-lived orders.",
+		Tags:        []string{"purchase-orders"},
+		Responses: map[int]openapi.Response{
+			200: {Body: openapi.Fields{"success": true, "rehydration": models.Rehydration{}}},
+			403: {Description: "Requires the viewer role", Body: errorResponse},
+			404: {Description: "No snapshot or event of the purchase order", Body: errorResponse},
+			500: {Body: errorResponse},
+		},
+	}))
+
+	docs.Describe("GET", apiPrefix+"/events", openapi.Operation{
+		Summary:     "Tail the order event stream",
+		Description: "Returns the stored events after since, oldest first (by timestamp, then ID), upcast to their current schema. Pass next_cursor as since to read the next page; it is unchanged when there are no new events. The stream stays EVENT_STREAM_LAG behind the present so events stored late by other replicas are not skipped. With wait, a request finding no events holds until some arrive or the wait (at most EVENT_STREAM_MAX_WAIT) is over. Events restored from the archive are not streamed.",
+		Tags:        []string{"events"},
+		Query: map[string]string{
+			"since": "RFC 3339 timestamp, or a next_cursor returned by an earlier page; omitted, the stream starts at the oldest stored event",
+			"limit": "Events per page, 1 to 1000 (default 100)",
+			"wait":  "Long-poll duration such as 20s",
+		},
+		Responses: map[int]openapi.Response{
+			200: {Body: openapi.Fields{"success": true, "events": []models.EventSourcingEvent{}, "count": 0, "next_cursor": "", "has_more": false, "until": ""}},
+			400: {Description: "Invalid since, limit or wait", Body: errorResponse},
+			403: {Description: "Requires the viewer role", Body: errorResponse},
+			500: {Body: errorResponse},
+		},
+	})
+
+	docs.Describe("GET", apiPrefix+"/sagas/:correlation_id", tagged(openapi.Operation{
+		Summary:     "Get the replenishment saga of a correlation chain",
+		Description: "Returns the steps the flow went through from the StockBajo event to the inventory reception, its current deadline and any compensation taken when the supplier did not acknowledge in time. Sagas are projected from stored events and may lag them by one coordinator interval.",
+		Tags:        []string{"sagas"},
+		Responses: map[int]openapi.Response{
+			200: {Body: openapi.Fields{"success": true, "saga": models.Saga{}}},
+			403: {Description: "Requires the viewer role", Body: errorResponse},
+			404: {Description: "Saga not found", Body: errorResponse},
+			500: {Body: errorResponse},
+		},
+	}))
+
+	docs.Describe("GET", apiPrefix+"/flows/:correlation_id", tagged(openapi.Operation{
+		Summary:     "Trace the flow of a correlation chain across services",
+		Description: "Assembles the StockBajo event, the orden-compra events of every purchase order created in the chain and the receptions and inventory events Proveedor logged for them into one timeline, oldest first. The StockBajo entry is inferred from the order that referenced it. When Proveedor cannot be reached the timeline is returned without its events and it is listed in unavailable.",
+		Tags:        []string{"flows"},
+		Responses: map[int]openapi.Response{
+			200: {Body: openapi.Fields{"success": true, "trace": flow.Trace{Timeline: []flow.Entry{{}}}}},
+			403: {Description: "Requires the viewer role", Body: errorResponse},
+			404: {Description: "No event belongs to the correlation chain", Body: errorResponse},
+			500: {Body: errorResponse},
+		},
+	}))
+
+	docs.Describe("GET", apiPrefix+"/exports/purchase-orders", openapi.Operation{
+		Summary:     "Export purchase orders",
+		Description: "Streams every purchase order matching the filters as CSV or XLSX for finance reconciliation, reading all pages of the read model. Since rows are streamed, failures after the first row are reported in the X-Export-Status trailer together with X-Export-Rows.",
+		Tags:        []string{"exports"},
+		Query: map[string]string{
+			"format":         "csv (default) or xlsx",
+			"product_id":     "Product ID",
+			"supplier_id":    "Supplier ID",
+			"status":         "Purchase order status",
+			"urgency_level":  "Urgency level",
+			"created_after":  "Earliest creation timestamp (RFC 3339)",
+			"created_before": "Latest creation timestamp (RFC 3339), now by default",
+		},
+		Responses: map[int]openapi.Response{
+			200: {Description: "CSV or XLSX file"},
+			400: {Description: "Invalid format or timestamp", Body: errorResponse},
+			403: {Description: "Requires the viewer role", Body: errorResponse},
+		},
+	})
+
+	docs.Describe("GET", apiPrefix+"/purchase-orders/search", tagged(openapi.Operation{
+		Summary:     "Search purchase orders",
+		Description: "Matches the query against order IDs, batch numbers from advance shipment notices, product names and supplier names, tolerating typos. The index follows the event store, so changes show up after the next sync.",
+		Tags:        []string{"purchase-orders"},
+		Query: map[string]string{
+			"q":     "Free-text query",
+			"limit": "Maximum results, 1 to 100, 20 by default",
+		},
+		Responses: map[int]openapi.Response{
+			200: {Body: openapi.Fields{"success": true, "purchase_orders": []search.Document{}, "count": 0, "total": 0}},
+			400: {Description: "Missing query or invalid limit", Body: errorResponse},
+			403: {Description: "Requires the viewer role", Body: errorResponse},
+			500: {Body: errorResponse},
+			503: {Description: "Search is not configured", Body: errorResponse},
+		},
+	}))
+
+	docs.Describe("GET", apiPrefix+"/purchase-orders/stats/timeseries", tagged(openapi.Operation{
+		Summary:     "Count orders created, completed and overdue per period",
+		Description: "Buckets the event store by UTC day or by week starting on Monday. Every period of the range is returned, including empty ones; an order is counted as completed once, when it is first received or completed, and as overdue when the overdue check reports it.",
+		Tags:        []string{"purchase-orders"},
+		Query: map[string]string{
+			"interval": "day (default) or week",
+			"from":     "Start of the range (RFC 3339), 30 days ago by default",
+			"to":       "End of the range (RFC 3339), now by default",
+		},
+		Responses: map[int]openapi.Response{
+			200: {Body: openapi.Fields{"success": true, "interval": "", "periods": []models.StatsPeriod{}, "count": 0}},
+			400: {Description: "Invalid interval or range, or a range over 366 periods", Body: errorResponse},
+			403: {Description: "Requires the viewer role", Body: errorResponse},
+			500: {Body: errorResponse},
+		},
+	}))
+
+	docs.Describe("GET", apiPrefix+"/purchase-orders/stats/slo", tagged(openapi.Operation{
+		Summary:     "Summarize pipeline stage latency against the release objectives",
+		Description: "Covers the orders whose StockBajo event was received within the range, by urgency level. Stages report the time from the previous stage as nearest-rank percentiles: order_persisted from event_received, reception_published from order_persisted and inventory_received from reception_published. Release compares the time from the event being received to the reception being published with the urgency's objective; orders not released yet, such as those awaiting approval, are counted as unreleased and not judged. An urgency meets its objective when the share released within it reaches the target.",
+		Tags:        []string{"purchase-orders"},
+		Query: map[string]string{
+			"from": "Start of the range (RFC 3339), 30 days ago by default",
+			"to":   "End of the range (RFC 3339), now by default",
+		},
+		Responses: map[int]openapi.Response{
+			200: {Body: openapi.Fields{"success": true, "from": "", "to": "", "target": 0.0, "objectives_seconds": map[string]float64{}, "met": true, "urgency_levels": map[string]slo.UrgencySummary{}}},
+			403: {Description: "Requires the viewer role", Body: errorResponse},
+			500: {Body: errorResponse},
+		},
+	}))
+
+	docs.Describe("POST", apiPrefix+"/graphql", openapi.Operation{
+		Summary:     "Query the read model with GraphQL",
+		Description: "Supports the purchaseOrder, purchaseOrders, orderEvents and stats queries. Results use the PurchaseOrder, EventSourcingEvent and PurchaseOrderStats models in camelCase.",
+		Tags:        []string{"graphql"},
+		Request:     graphqlRequest{},
+		Responses: map[int]openapi.Response{
+			200: {Description: "GraphQL result, including field errors", Body: openapi.Fields{"data": map[string]interface{}{}, "errors": []map[string]string{}}},
+			400: {Description: "Missing query", Body: openapi.Fields{"errors": []map[string]string{}}},
+		},
+	})
+
+	docs.Describe("POST", apiPrefix+"/webhooks/subscriptions", openapi.Operation{
+		Summary:     "Subscribe to order lifecycle events",
+		Description: "Deliveries are signed with HMAC-SHA256 in X-Webhook-Signature. A secret is generated when none is given and is only returned here.",
+		Tags:        []string{"webhooks"},
+		Request:     webhookSubscriptionRequest{},
+		Responses: map[int]openapi.Response{
+			201: {Description: "Subscription created", Body: openapi.Fields{"success": true, "subscription": models.WebhookSubscription{}}},
+			400: {Description: "Invalid URL or event type", Body: errorResponse},
+			500: {Body: errorResponse},
+		},
+	})
+
+	docs.Describe("GET", apiPrefix+"/webhooks/subscriptions", openapi.Operation{
+		Summary: "List webhook subscriptions",
+		Tags:    []string{"webhooks"},
+		Responses: map[int]openapi.Response{
+			200: {Body: openapi.Fields{"success": true, "subscriptions": []models.WebhookSubscription{}, "count": 0}},
+			500: {Body: errorResponse},
+		},
+	})
+
+	docs.Describe("GET", apiPrefix+"/webhooks/subscriptions/:id", openapi.Operation{
+		Summary: "Get a webhook subscription",
+		Tags:    []string{"webhooks"},
+		Responses: map[int]openapi.Response{
+			200: {Body: openapi.Fields{"success": true, "subscription": models.WebhookSubscription{}}},
+			404: {Description: "Subscription not found", Body: errorResponse},
+			500: {Body: errorResponse},
+		},
+	})
+
+	docs.Describe("DELETE", apiPrefix+"/webhooks/subscriptions/:id", openapi.Operation{
+		Summary: "Delete a webhook subscription",
+		Tags:    []string{"webhooks"},
+		Responses: map[int]openapi.Response{
+			200: {Body: openapi.Fields{"success": true, "subscription_id": ""}},
+			404: {Description: "Subscription not found", Body: errorResponse},
+			500: {Body: errorResponse},
+		},
+	})
+
+	docs.Describe("GET", apiPrefix+"/webhooks/subscriptions/:id/deliveries", openapi.Operation{
+		Summary: "List the delivery attempts of a subscription",
+		Tags:    []string{"webhooks"},
+		Responses: map[int]openapi.Response{
+			200: {Body: openapi.Fields{"success": true, "deliveries": []models.WebhookDelivery{}, "count": 0}},
+			404: {Description: "Subscription not found", Body: errorResponse},
+			500: {Body: errorResponse},
+		},
+	})
+
+	docs.Describe("GET", apiPrefix+"/admin/limits", openapi.Operation{
+		Summary: "Show dependency concurrency limits",
+		Tags:    []string{"admin"},
+		Responses: map[int]openapi.Response{
+			200: {Body: openapi.Fields{"success": true, "limiters": []limiter.Stats{}}},
+		},
+	})
+
+	docs.Describe("PUT", apiPrefix+"/admin/limits/:dependency", openapi.Operation{
+		Summary:     "Update a dependency concurrency limit",
+		Description: "queue_timeout is a Go duration such as 500ms or 2s.",
+		Tags:        []string{"admin"},
+		Request:     limitUpdateRequest{},
+		Responses: map[int]openapi.Response{
+			200: {Body: openapi.Fields{"success": true, "limiter": limiter.Stats{}}},
+			400: {Description: "Unknown dependency or invalid limits", Body: errorResponse},
+		},
+	})
+
+	docs.Describe("GET", apiPrefix+"/admin/log-level", openapi.Operation{
+		Summary: "Show the log level",
+		Tags:    []string{"admin"},
+		Responses: map[int]openapi.Response{
+			200: {Body: openapi.Fields{"success": true, "level": "info"}},
+		},
+	})
+
+	docs.Describe("PUT", apiPrefix+"/admin/log-level", openapi.Operation{
+		Summary:     "Change the log level",
+		Description: "level is debug, info, warn or error. The change lasts until the service restarts; LOG_LEVEL sets the initial level.",
+		Tags:        []string{"admin"},
+		Request:     logLevelRequest{},
+		Responses: map[int]openapi.Response{
+			200: {Body: openapi.Fields{"success": true, "level": "debug"}},
+			400: {Description: "Unknown level", Body: errorResponse},
+		},
+	})
+
+	docs.Describe("GET", apiPrefix+"/auth/me", openapi.Operation{
+		Summary: "Show the authenticated caller and its effective roles",
+		Tags:    []string{"auth"},
+		Responses: map[int]openapi.Response{
+			200: {Body: openapi.Fields{"success": true, "authenticated": true, "subject": "", "method": "", "roles": []string{}}},
+			500: {Body: errorResponse},
+		},
+	})
+
+	docs.Describe("POST", apiPrefix+"/suppliers/import", openapi.Operation{
+		Summary:     "Import suppliers into the supplier catalog",
+		Description: "Upserts up to 1000 suppliers of the caller's tenant, with their product mappings and lead times. Send JSON, or a CSV (Content-Type text/csv) of up to 5 MiB whose header names the columns id, name, email, phone, address, is_active, lead_time_days, critical_lead_time_days and products; products lists product_id|supplier_sku|lead_time_days entries separated by semicolons. Each row is validated and imported on its own; an existing supplier keeps its creation time and metadata keys the row does not set. Orders still awaiting delivery from the imported suppliers then take their catalog names, each recording a PurchaseOrderSupplierUpdated event; orders_refresh counts them, and importing a supplier again retries its orders that failed.",
+		Tags:        []string{"suppliers"},
+		Request:     importSuppliersRequest{},
+		Responses: map[int]openapi.Response{
+			200: {Description: "Every row imported", Body: openapi.Fields{
+				"success":        true,
+				"rows":           0,
+				"created":        0,
+				"updated":        0,
+				"failed":         0,
+				"results":        []handlers.SupplierImportResult{},
+				"orders_refresh": openapi.Fields{"refreshed": 0, "failed": 0},
+			}},
+			207: {Description: "Some rows failed; the body is as for 200 and each result tells which"},
+			400: {Description: "Unreadable file, or no rows or too many", Body: errorResponse},
+			403: {Description: "Requires the admin role", Body: errorResponse},
+			413: {Description: "Import too large", Body: errorResponse},
+			500: {Body: errorResponse},
+		},
+	})
+
+	docs.Describe("GET", apiPrefix+"/suppliers/:id", tagged(openapi.Operation{
+		Summary: "Get a supplier of the catalog",
+		Tags:    []string{"suppliers"},
+		Responses: map[int]openapi.Response{
+			200: {Body: openapi.Fields{"success": true, "supplier": models.Supplier{}}},
+			404: {Description: "Supplier not in the catalog", Body: errorResponse},
+			500: {Body: errorResponse},
+		},
+	}))
+
+	docs.Describe("GET", apiPrefix+"/suppliers/:id/contacts", tagged(openapi.Operation{
+		Summary: "List the contacts of a supplier",
+		Tags:    []string{"suppliers"},
+		Responses: map[int]openapi.Response{
+			200: {Body: openapi.Fields{"success": true, "supplier_id": "", "contacts": []models.SupplierContact{}, "count": 0}},
+			404: {Description: "Supplier not in the catalog", Body: errorResponse},
+			500: {Body: errorResponse},
+		},
+	}))
+
+	docs.Describe("POST", apiPrefix+"/suppliers/:id/contacts", openapi.Operation{
+		Summary:     "Add a contact to a supplier",
+		Description: "Roles: purchasing, quality, emergency. A contact needs an email address or a phone number; only contacts with an email address are notified. The contact ID is assigned.",
+		Tags:        []string{"suppliers"},
+		Request:     models.SupplierContact{},
+		Responses: map[int]openapi.Response{
+			201: {Body: openapi.Fields{"success": true, "supplier_id": "", "contact": models.SupplierContact{}}},
+			400: {Description: "Invalid contact", Body: errorResponse},
+			403: {Description: "Requires the buyer role", Body: errorResponse},
+			404: {Description: "Supplier not in the catalog", Body: errorResponse},
+			409: {Description: "Supplier modified concurrently; retry", Body: errorResponse},
+			500: {Body: errorResponse},
+		},
+	})
+
+	docs.Describe("PUT", apiPrefix+"/suppliers/:id/contacts/:contact_id", openapi.Operation{
+		Summary: "Replace a contact of a supplier",
+		Tags:    []string{"suppliers"},
+		Request: models.SupplierContact{},
+		Responses: map[int]openapi.Response{
+			200: {Body: openapi.Fields{"success": true, "supplier_id": "", "contact": models.SupplierContact{}}},
+			400: {Description: "Invalid contact", Body: errorResponse},
+			403: {Description: "Requires the buyer role", Body: errorResponse},
+			404: {Description: "Supplier or contact not found", Body: errorResponse},
+			409: {Description: "Supplier modified concurrently; retry", Body: errorResponse},
+			500: {Body: errorResponse},
+		},
+	})
+
+	docs.Describe("DELETE", apiPrefix+"/suppliers/:id/contacts/:contact_id", openapi.Operation{
+		Summary: "Remove a contact from a supplier",
+		Tags:    []string{"suppliers"},
+		Responses: map[int]openapi.Response{
+			200: {Body: openapi.Fields{"success": true, "supplier_id": "", "contact_id": ""}},
+			403: {Description: "Requires the buyer role", Body: errorResponse},
+			404: {Description: "Supplier or contact not found", Body: errorResponse},
+			409: {Description: "Supplier modified concurrently; retry", Body: errorResponse},
+			500: {Body: errorResponse},
+		},
+	})
+
+	docs.Describe("GET", apiPrefix+"/suppliers/:id/escalation-rules", tagged(openapi.Operation{
+		Summary:     "Get the escalation rules of a supplier",
+		Description: "Returns the supplier's rules and the defaults used when none of them match an event.",
+		Tags:        []string{"suppliers"},
+		Responses: map[int]openapi.Response{
+			200: {Body: openapi.Fields{"success": true, "supplier_id": "", "escalation_rules": []models.EscalationRule{}, "defaults": []models.EscalationRule{}}},
+			404: {Description: "Supplier not in the catalog", Body: errorResponse},
+			500: {Body: errorResponse},
+		},
+	}))
+
+	docs.Describe("PUT", apiPrefix+"/suppliers/:id/escalation-rules", openapi.Operation{
+		Summary:     "Replace the escalation rules of a supplier",
+		Description: "Each rule names the contact roles notified of an event (empty for every event) at the given urgency levels (empty for every level). The first matching rule applies, then the first matching default; without a contact of its roles the supplier's own email address is used.",
+		Tags:        []string{"suppliers"},
+		Request:     escalationRulesRequest{},
+		Responses: map[int]openapi.Response{
+			200: {Body: openapi.Fields{"success": true, "supplier_id": "", "escalation_rules": []models.EscalationRule{}}},
+			400: {Description: "Unknown event, urgency level or role", Body: errorResponse},
+			403: {Description: "Requires the buyer role", Body: errorResponse},
+			404: {Description: "Supplier not in the catalog", Body: errorResponse},
+			409: {Description: "Supplier modified concurrently; retry", Body: errorResponse},
+			500: {Body: errorResponse},
+		},
+	})
+
+	docs.Describe("POST", apiPrefix+"/standing-orders", idempotent(openapi.Operation{
+		Summary:     "Create a standing order",
+		Description: "Orders quantity of a product from a supplier every interval_days, starting at start_at (default now) until end_at or until total_quantity has been released. Each release is a purchase order whose blanket_order_id is the standing order's ID; releases are not held for approval. The supplier name defaults to the supplier catalog's.",
+		Tags:        []string{"standing-orders"},
+		Request:     standingOrderRequest{},
+		Responses: map[int]openapi.Response{
+			201: {Body: openapi.Fields{"success": true, "standing_order": models.StandingOrder{}}},
+			400: {Description: "Invalid standing order", Body: errorResponse},
+			403: {Description: "Requires the approver role", Body: errorResponse},
+			500: {Body: errorResponse},
+		},
+	}))
+
+	docs.Describe("GET", apiPrefix+"/standing-orders", tagged(openapi.Operation{
+		Summary:     "List standing orders",
+		Description: "Filter with the product_id, supplier_id and status (active, paused, completed, cancelled) query parameters.",
+		Tags:        []string{"standing-orders"},
+		Responses: map[int]openapi.Response{
+			200: {Body: openapi.Fields{"success": true, "standing_orders": []models.StandingOrder{}, "count": 0}},
+			500: {Body: errorResponse},
+		},
+	}))
+
+	docs.Describe("GET", apiPrefix+"/standing-orders/:id", tagged(openapi.Operation{
+		Summary: "Get a standing order",
+		Tags:    []string{"standing-orders"},
+		Responses: map[int]openapi.Response{
+			200: {Body: openapi.Fields{"success": true, "standing_order": models.StandingOrder{}}},
+			404: {Description: "Standing order not found", Body: errorResponse},
+			500: {Body: errorResponse},
+		},
+	}))
+
+	docs.Describe("GET", apiPrefix+"/standing-orders/:id/releases", tagged(openapi.Operation{
+		Summary:     "List the releases of a standing order",
+		Description: "Returns the purchase orders whose blanket_order_id is the standing order's ID.",
+		Tags:        []string{"standing-orders"},
+		Responses: map[int]openapi.Response{
+			200: {Body: openapi.Fields{"success": true, "blanket_order_id": "", "purchase_orders": []models.PurchaseOrder{}, "count": 0}},
+			404: {Description: "Standing order not found", Body: errorResponse},
+			500: {Body: errorResponse},
+		},
+	}))
+
+	docs.Describe("POST", apiPrefix+"/standing-orders/:id/pause", openapi.Operation{
+		Summary: "Pause an active standing order",
+		Tags:    []string{"standing-orders"},
+		Responses: map[int]openapi.Response{
+			200: {Body: openapi.Fields{"success": true, "standing_order": models.StandingOrder{}}},
+			403: {Description: "Requires the buyer role", Body: errorResponse},
+			404: {Description: "Standing order not found", Body: errorResponse},
+			409: {Description: "Standing order not active, or modified concurrently", Body: errorResponse},
+			500: {Body: errorResponse},
+		},
+	})
+
+	docs.Describe("POST", apiPrefix+"/standing-orders/:id/resume", openapi.Operation{
+		Summary:     "Resume a paused standing order",
+		Description: "Releases that fell due while the standing order was paused are skipped; the next release is the first scheduled one from now.",
+		Tags:        []string{"standing-orders"},
+		Responses: map[int]openapi.Response{
+			200: {Body: openapi.Fields{"success": true, "standing_order": models.StandingOrder{}}},
+			403: {Description: "Requires the approver role", Body: errorResponse},
+			404: {Description: "Standing order not found", Body: errorResponse},
+			409: {Description: "Standing order not paused, or modified concurrently", Body: errorResponse},
+			500: {Body: errorResponse},
+		},
+	})
+
+	docs.Describe("POST", apiPrefix+"/standing-orders/:id/cancel", openapi.Operation{
+		Summary:     "Cancel a standing order",
+		Description: "Stops further releases; purchase orders already released are not affected.",
+		Tags:        []string{"standing-orders"},
+		Responses: map[int]openapi.Response{
+			200: {Body: openapi.Fields{"success": true, "standing_order": models.StandingOrder{}}},
+			403: {Description: "Requires the buyer role", Body: errorResponse},
+			404: {Description: "Standing order not found", Body: errorResponse},
+			409: {Description: "Standing order already completed or cancelled, or modified concurrently", Body: errorResponse},
+			500: {Body: errorResponse},
+		},
+	})
+
+	docs.Describe("GET", apiPrefix+"/reorder-suggestions", tagged(openapi.Operation{
+		Summary:     "Suggest reorder points and quantities",
+		Description: "Analyzes the StockBajo events, order quantities and lead times within FORECAST_WINDOW and suggests a reorder point and order quantity for every product that ran low in it. Products with fewer than FORECAST_MIN_EVENTS events are listed with sufficient false and no figures.",
+		Tags:        []string{"reorders"},
+		Query: map[string]string{
+			"product_id": "Only suggest for this product",
+		},
+		Responses: map[int]openapi.Response{
+			200: {Body: openapi.Fields{"success": true, "suggestions": []models.ReorderSuggestion{}, "count": 0, "events": 0, "since": ""}},
+			500: {Body: errorResponse},
+		},
+	}))
+
+	docs.Describe("GET", apiPrefix+"/admin/access-policies", openapi.Operation{
+		Summary: "List role overrides",
+		Tags:    []string{"admin"},
+		Responses: map[int]openapi.Response{
+			200: {Body: openapi.Fields{"success": true, "policies": []models.AccessPolicy{}, "count": 0}},
+			403: {Description: "Requires the admin role", Body: errorResponse},
+			500: {Body: errorResponse},
+		},
+	})
+
+	docs.Describe("GET", apiPrefix+"/admin/access-policies/:subject", openapi.Operation{
+		Summary: "Get the role override of a subject",
+		Tags:    []string{"admin"},
+		Responses: map[int]openapi.Response{
+			200: {Body: openapi.Fields{"success": true, "policy": models.AccessPolicy{}}},
+			403: {Description: "Requires the admin role", Body: errorResponse},
+			404: {Description: "Subject has no override", Body: errorResponse},
+			500: {Body: errorResponse},
+		},
+	})
+
+	docs.Describe("PUT", apiPrefix+"/admin/access-policies/:subject", openapi.Operation{
+		Summary:     "Override the roles of a subject",
+		Description: "The subject is a JWT sub claim or an API key client name. The override replaces the roles carried by its credentials. Roles: viewer, buyer, approver, admin.",
+		Tags:        []string{"admin"},
+		Request:     accessPolicyRequest{},
+		Responses: map[int]openapi.Response{
+			200: {Body: openapi.Fields{"success": true, "policy": models.AccessPolicy{}}},
+			400: {Description: "Unknown role", Body: errorResponse},
+			403: {Description: "Requires the admin role", Body: errorResponse},
+			500: {Body: errorResponse},
+		},
+	})
+
+	docs.Describe("DELETE", apiPrefix+"/admin/access-policies/:subject", openapi.Operation{
+		Summary: "Remove the role override of a subject",
+		Tags:    []string{"admin"},
+		Responses: map[int]openapi.Response{
+			200: {Body: openapi.Fields{"success": true, "subject": ""}},
+			403: {Description: "Requires the admin role", Body: errorResponse},
+			404: {Description: "Subject has no override", Body: errorResponse},
+			500: {Body: errorResponse},
+		},
+	})
+
+	docs.Describe("POST", apiPrefix+"/admin/stats/recompute", openapi.Operation{
+		Summary:     "Rebuild the purchase order statistics",
+		Description: "Statistics are kept as counters per tenant and creation day that the commands update as orders change. Recounting every order repairs counters left behind by failed updates; callers in a tenant only rebuild that tenant's counters.",
+		Tags:        []string{"admin"},
+		Responses: map[int]openapi.Response{
+			200: {Body: openapi.Fields{"success": true, "orders": 0, "buckets": 0, "removed": 0}},
+			403: {Description: "Requires the admin role", Body: errorResponse},
+			500: {Body: errorResponse},
+		},
+	})
+
+	docs.Describe("POST", apiPrefix+"/admin/events/restore", openapi.Operation{
+		Summary:     "Restore archived events",
+		Description: "Copies the events archived to S3 between from and to (RFC 3339, at most 92 days apart) back into the event store so they can be replayed. Restored events carry restored_at, are not archived again and are removed by DynamoDB TTL after ARCHIVE_RESTORE_TTL; callers in a tenant only restore that tenant's events.",
+		Tags:        []string{"admin"},
+		Request:     restoreEventsRequest{},
+		Responses: map[int]openapi.Response{
+			200: {Body: openapi.Fields{"success": true, "restored": 0, "objects": 0, "expires_at": ""}},
+			400: {Description: "Invalid range", Body: errorResponse},
+			403: {Description: "Requires the admin role", Body: errorResponse},
+			500: {Body: errorResponse},
+			503: {Description: "Event archival is not configured", Body: errorResponse},
+		},
+	})
+
+	docs.Describe("GET", apiPrefix+"/admin/failed-events", openapi.Operation{
+		Summary:     "List messages rejected to the dead letter queue",
+		Description: "Returns the rejected messages recorded in orden-compra-dead-letters, newest first, with their rejection reason, validation errors, payload and headers. Filter with status (failed or replayed), reason, queue, from and to (RFC 3339) and limit (default 100); callers in a tenant only see that tenant's messages.",
+		Tags:        []string{"admin"},
+		Responses: map[int]openapi.Response{
+			200: {Body: openapi.Fields{"success": true, "failed_events": []models.DeadLetterRecord{}, "count": 0}},
+			400: {Description: "Invalid filter", Body: errorResponse},
+			403: {Description: "Requires the admin role", Body: errorResponse},
+			500: {Body: errorResponse},
+		},
+	})
+
+	docs.Describe("GET", apiPrefix+"/admin/failed-events/:id", openapi.Operation{
+		Summary: "Get a message rejected to the dead letter queue",
+		Tags:    []string{"admin"},
+		Responses: map[int]openapi.Response{
+			200: {Body: openapi.Fields{"success": true, "failed_event": models.DeadLetterRecord{}}},
+			403: {Description: "Requires the admin role", Body: errorResponse},
+			404: {Body: errorResponse},
+			500: {Body: errorResponse},
+		},
+	})
+
+	failedEventsResult := openapi.Fields{
+		"success":   true,
+		"requested": 0,
+		"succeeded": 0,
+		"failed":    0,
+		"results":   []handlers.DeadLetterResult{},
+	}
+
+	docs.Describe("POST", apiPrefix+"/admin/failed-events/replay", openapi.Operation{
+		Summary:     "Replay messages rejected to the dead letter queue",
+		Description: "Publishes up to 100 failed messages again onto the queue they were consumed from, with their original payload and headers, so they go through validation and the commands again with fresh retry attempts. Replayed records are kept, marked replayed; a message that fails again is recorded as a new one. Each result reports its outcome, with an error_code of not_found, not_replayable or internal. Replays are audited.",
+		Tags:        []string{"admin"},
+		Request:     failedEventsRequest{},
+		Responses: map[int]openapi.Response{
+			200: {Description: "Every message replayed", Body: failedEventsResult},
+			400: {Description: "No IDs or too many", Body: errorResponse},
+			403: {Description: "Requires the admin role", Body: errorResponse},
+		},
+	})
+
+	docs.Describe("POST", apiPrefix+"/admin/failed-events/discard", openapi.Operation{
+		Summary:     "Discard messages rejected to the dead letter queue",
+		Description: "Deletes up to 100 records. The audit log keeps each one as it was before being discarded. Each result reports its outcome, with an error_code of not_found or internal.",
+		Tags:        []string{"admin"},
+		Request:     failedEventsRequest{},
+		Responses: map[int]openapi.Response{
+			200: {Description: "Every record discarded", Body: failedEventsResult},
+			400: {Description: "No IDs or too many", Body: errorResponse},
+			403: {Description: "Requires the admin role", Body: errorResponse},
+		},
+	})
+
+	ediResult := openapi.Fields{
+		"success":        true,
+		"sender_id":      "",
+		"control_number": "",
+		"results": []openapi.Fields{{
+			"transaction_set":   "",
+			"control_number":    "",
+			"purchase_order_id": "",
+			"success":           true,
+			"error":             "",
+		}},
+	}
+
+	docs.Describe("POST", apiPrefix+"/edi/inbound", openapi.Operation{
+		Summary:     "Ingest an inbound EDI interchange",
+		Description: "Takes a raw X12 interchange (Content-Type application/edi-x12) of up to 5 MiB. 855 acknowledgements are applied as on /purchase-orders/{id}/acknowledgement and each order of an 856 ship notice as on /purchase-orders/{id}/asn. Every transaction set is applied even when another fails.",
+		Tags:        []string{"edi"},
+		Responses: map[int]openapi.Response{
+			200: {Description: "Every transaction set applied", Body: ediResult},
+			400: {Description: "Malformed interchange", Body: errorResponse},
+			403: {Description: "Requires the buyer role", Body: errorResponse},
+			413: {Description: "Interchange too large", Body: errorResponse},
+			422: {Description: "Some transaction sets failed; see results", Body: ediResult},
+			500: {Body: errorResponse},
+		},
+	})
+
+	docs.Describe("GET", apiPrefix+"/erp/deliveries", openapi.Operation{
+		Summary:     "List ERP deliveries",
+		Description: "Returns the deliveries of purchase order and goods receipt documents to the ERP, most recently updated first, with their status (pending, delivered or failed), attempts, replays and last error. Completed orders are exported once by the scheduled exporter; failed deliveries stay failed until replayed.",
+		Tags:        []string{"erp"},
+		Query: map[string]string{
+			"purchase_order_id": "Purchase order ID",
+			"document_type":     "purchase_order or goods_receipt",
+			"status":            "pending, delivered or failed",
+			"limit":             "Maximum deliveries, 100 by default",
+		},
+		Responses: map[int]openapi.Response{
+			200: {Body: openapi.Fields{"success": true, "deliveries": []models.ERPDelivery{}, "count": 0}},
+			400: {Description: "Invalid limit", Body: errorResponse},
+			403: {Description: "Requires the viewer role", Body: errorResponse},
+			500: {Body: errorResponse},
+		},
+	})
+
+	docs.Describe("GET", apiPrefix+"/erp/deliveries/:id", openapi.Operation{
+		Summary: "Get an ERP delivery",
+		Tags:    []string{"erp"},
+		Responses: map[int]openapi.Response{
+			200: {Body: openapi.Fields{"success": true, "delivery": models.ERPDelivery{}}},
+			403: {Description: "Requires the viewer role", Body: errorResponse},
+			404: {Body: errorResponse},
+			500: {Body: errorResponse},
+		},
+	})
+
+	docs.Describe("POST", apiPrefix+"/erp/deliveries/:id/replay", idempotent(openapi.Operation{
+		Summary:     "Replay an ERP delivery",
+		Description: "Renders the document again from the current order and delivers it under the same document number and file name, replacing a file uploaded earlier. Delivery runs in the background. Replays are audited.",
+		Tags:        []string{"erp"},
+		Responses: map[int]openapi.Response{
+			202: {Description: "Replay started", Body: openapi.Fields{"success": true, "delivery": models.ERPDelivery{}}},
+			403: {Description: "Requires the buyer role", Body: errorResponse},
+			404: {Description: "Delivery or purchase order not found", Body: errorResponse},
+			503: {Description: "ERP export is not configured", Body: errorResponse},
+			500: {Body: errorResponse},
+		},
+	}))
+
+	docs.Describe("GET", apiPrefix+"/audit", openapi.Operation{
+		Summary:     "Query the audit log",
+		Description: "Every state-changing operation with its actor, origin and the resource state before and after it, newest first.",
+		Tags:        []string{"admin"},
+		Query: map[string]string{
+			"resource_type": "Resource type, e.g. purchase_order",
+			"resource_id":   "Resource ID",
+			"actor_id":      "JWT subject, API key client, message publisher or job name",
+			"action":        "Action, e.g. purchase_order.cancelled",
+			"from":          "Earliest timestamp (RFC 3339)",
+			"to":            "Latest timestamp (RFC 3339)",
+			"limit":         "Maximum entries, 100 by default",
+		},
+		Responses: map[int]openapi.Response{
+			200: {Body: openapi.Fields{"success": true, "entries": []models.AuditEntry{}, "count": 0}},
+			400: {Description: "Invalid limit or timestamp", Body: errorResponse},
+			403: {Description: "Requires the admin role", Body: errorResponse},
+			500: {Body: errorResponse},
+		},
+	})
+
+	docs.Describe("GET", apiPrefix+"/openapi.json", openapi.Operation{
+		Summary: "This OpenAPI document",
+		Tags:    []string{"docs"},
+		Responses: map[int]openapi.Response{
+			200: {Body: openapi.Fields{"openapi": "", "info": map[string]interface{}{}, "paths": map[string]interface{}{}}},
+		},
+	})
+
+	docs.Describe("GET", apiPrefix+"/docs", openapi.Operation{
+		Summary: "Swagger UI for this API",
+		Tags:    []string{"docs"},
+		Responses: map[int]openapi.Response{
+			200: {Description: "HTML page"},
+		},
+	})
+
+	return docs
+}
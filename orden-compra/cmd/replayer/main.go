@@ -0,0 +1,240 @@
+// Command replayer republishes events to RabbitMQ at a steady rate, for
+// backfills and load tests. It reads JSON Lines files, the events archived
+// to S3 or the events stored in orden-compra-events within a time range:
+//
+//	go run ./cmd/replayer -source file -file stock-bajo.jsonl -exchange stock-bajo-exchange -routing-key stock.bajo -rate 20
+//	go run ./cmd/replayer -source store -from 2024-03-01T00:00:00Z -to 2024-03-02T00:00:00Z -event-types PurchaseOrderCreated -exchange backfill -routing-key purchase.order
+//
+// Every message is new, with its own message ID, and tagged as a replay: its
+// correlation-id is replay-<run ID>, so the whole run traces as one chain,
+// its causation-id is the original event ID, and the replay, replay-run-id
+// and replayed-correlation-id headers tell consumers it is not live traffic.
+// With -dry-run StockBajo events only compute the orders they would create.
+//
+// Connections are configured like the service: RABBITMQ_URL and its
+// credentials, DYNAMODB_ENDPOINT and DYNAMODB_REGION, and ARCHIVE_BUCKET,
+// ARCHIVE_PREFIX, ARCHIVE_REGION and ARCHIVE_ENDPOINT.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/s3"
+
+	"medisupply/env"
+	"medisupply/queue"
+
+	"orden-compra/internal/archive"
+)
+
+// config holds the command line flags
+type config struct {
+	Source     string
+	File       string
+	From       string
+	To         string
+	EventTypes string
+	Exchange   string
+	RoutingKey string
+	Rate       float64
+	Repeat     int
+	Limit      int
+	PageSize   int
+	RunID      string
+	DryRun     bool
+}
+
+func main() {
+	var cfg config
+	flag.StringVar(&cfg.Source, "source", sourceFile, "where to read events: file, archive or store")
+	flag.StringVar(&cfg.File, "file", "-", "JSON Lines file of the file source, gzipped if it ends in .gz; - reads standard input")
+	flag.StringVar(&cfg.From, "from", "", "start of the range of the archive and store sources, RFC 3339")
+	flag.StringVar(&cfg.To, "to", "", "end of the range of the archive and store sources, RFC 3339; defaults to now")
+	flag.StringVar(&cfg.EventTypes, "event-types", "", "comma-separated event types to replay; all when empty")
+	flag.StringVar(&cfg.Exchange, "exchange", "", "exchange to publish to")
+	flag.StringVar(&cfg.RoutingKey, "routing-key", "", "routing key to publish with")
+	flag.Float64Var(&cfg.Rate, "rate", 10, "messages per second; 0 publishes as fast as the broker confirms")
+	flag.IntVar(&cfg.Repeat, "repeat", 1, "times to replay the events, for load tests")
+	flag.IntVar(&cfg.Limit, "limit", 0, "stop after publishing this many messages; 0 for no limit")
+	flag.IntVar(&cfg.PageSize, "page-size", 500, "events read per page from the store source")
+	flag.StringVar(&cfg.RunID, "run-id", "", "ID of the run in the replay headers; defaults to the start time")
+	flag.BoolVar(&cfg.DryRun, "dry-run", false, "have orden-compra compute the orders of StockBajo events without creating them")
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := run(ctx, cfg); err != nil {
+		log.Fatalf("replayer: %v", err)
+	}
+}
+
+// run opens the source and the broker and replays the events
+func run(ctx context.Context, cfg config) error {
+	if cfg.Exchange == "" {
+		return errors.New("-exchange is required")
+	}
+	if cfg.Repeat < 1 {
+		return errors.New("-repeat must be at least 1")
+	}
+	if cfg.RunID == "" {
+		cfg.RunID = time.Now().UTC().Format("20060102T150405Z")
+	}
+	open, err := sourceOpener(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	hostname, _ := os.Hostname()
+	connection, err := queue.Dial(queue.ConnectionConfig{
+		URL:            env.String("RABBITMQ_URL", "amqp://rabbitmq-service:5672/"),
+		Username:       env.String("RABBITMQ_USERNAME", ""),
+		Password:       env.String("RABBITMQ_PASSWORD", ""),
+		ExternalAuth:   env.String("RABBITMQ_EXTERNAL_AUTH", "false") == "true",
+		CAFile:         env.String("RABBITMQ_CA_FILE", ""),
+		CertFile:       env.String("RABBITMQ_CERT_FILE", ""),
+		KeyFile:        env.String("RABBITMQ_KEY_FILE", ""),
+		ServerName:     env.String("RABBITMQ_SERVER_NAME", ""),
+		ConnectionName: "orden-compra-replayer@" + hostname,
+	})
+	if err != nil {
+		return err
+	}
+	defer connection.Close()
+
+	p, err := newPublisher(connection, cfg.Exchange, cfg.RoutingKey, cfg.RunID, cfg.Rate, cfg.DryRun)
+	if err != nil {
+		return err
+	}
+	defer p.Close()
+
+	eventTypes := make(map[string]bool)
+	for _, eventType := range strings.Split(cfg.EventTypes, ",") {
+		if eventType = strings.TrimSpace(eventType); eventType != "" {
+			eventTypes[eventType] = true
+		}
+	}
+
+	logf("replaying %s events to %s/%s as run %s, correlation_id %s", cfg.Source, cfg.Exchange, cfg.RoutingKey, cfg.RunID, p.correlationID())
+	start := time.Now()
+	published, skipped := 0, 0
+	for pass := 1; pass <= cfg.Repeat; pass++ {
+		src, err := open()
+		if err != nil {
+			return err
+		}
+		for cfg.Limit == 0 || published < cfg.Limit {
+			r, err := src.Next(ctx)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				src.Close()
+				return err
+			}
+			if len(eventTypes) > 0 && !eventTypes[r.EventType] {
+				skipped++
+				continue
+			}
+
+			if err := p.Publish(ctx, r); err != nil {
+				src.Close()
+				return err
+			}
+			published++
+			if published%1000 == 0 {
+				logf("published %d messages (pass %d of %d)", published, pass, cfg.Repeat)
+			}
+		}
+		src.Close()
+	}
+	if err := p.Flush(ctx); err != nil {
+		return err
+	}
+
+	elapsed := time.Since(start)
+	logf("published %d messages, skipped %d, in %v (%.1f/s)", published, skipped, elapsed.Round(time.Millisecond), float64(published)/elapsed.Seconds())
+	if p.nacked > 0 {
+		return fmt.Errorf("%w: %d of %d", ErrNacked, p.nacked, published)
+	}
+	return nil
+}
+
+// sourceOpener returns a function opening the configured source, called
+// once per pass
+func sourceOpener(ctx context.Context, cfg config) (func() (source, error), error) {
+	if cfg.Source == sourceFile {
+		if cfg.File == "-" && cfg.Repeat > 1 {
+			return nil, errors.New("standard input cannot be repeated; give -file")
+		}
+		return func() (source, error) { return newFileSource(cfg.File) }, nil
+	}
+
+	if cfg.From == "" {
+		return nil, fmt.Errorf("-from is required with the %s source", cfg.Source)
+	}
+	from, err := time.Parse(time.RFC3339Nano, cfg.From)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -from: %w", err)
+	}
+	to := time.Now().UTC()
+	if cfg.To != "" {
+		if to, err = time.Parse(time.RFC3339Nano, cfg.To); err != nil {
+			return nil, fmt.Errorf("invalid -to: %w", err)
+		}
+	}
+
+	dynamoDBRegion := env.String("DYNAMODB_REGION", "us-east-1")
+	switch cfg.Source {
+	case sourceArchive:
+		awsConfig := &aws.Config{Region: aws.String(env.String("ARCHIVE_REGION", dynamoDBRegion))}
+		if endpoint := env.String("ARCHIVE_ENDPOINT", ""); endpoint != "" {
+			awsConfig.Endpoint = aws.String(endpoint)
+			awsConfig.S3ForcePathStyle = aws.Bool(true)
+		}
+		sess, err := session.NewSession(awsConfig)
+		if err != nil {
+			return nil, err
+		}
+		archiveConfig := archive.Config{
+			Bucket: env.String("ARCHIVE_BUCKET", ""),
+			Prefix: env.String("ARCHIVE_PREFIX", "orden-compra/events"),
+		}
+		if archiveConfig.Bucket == "" {
+			return nil, archive.ErrNotConfigured
+		}
+		archiver := archive.NewArchiver(nil, s3.New(sess), archiveConfig, log.Default())
+		return func() (source, error) { return newArchiveSource(ctx, archiver, from, to) }, nil
+
+	case sourceStore:
+		sess, err := session.NewSession(&aws.Config{
+			Endpoint:    aws.String(env.String("DYNAMODB_ENDPOINT", "http://dynamodb-local:8000")),
+			Region:      aws.String(dynamoDBRegion),
+			Credentials: credentials.NewStaticCredentials("dummy", "dummy", ""),
+		})
+		if err != nil {
+			return nil, err
+		}
+		dynamoDB := dynamodb.New(sess)
+		return func() (source, error) { return newStoreSource(dynamoDB, from, to, cfg.PageSize), nil }, nil
+	}
+	return nil, fmt.Errorf("unknown -source %q: use file, archive or store", cfg.Source)
+}
+
+// logf prints a progress line of the run
+func logf(format string, args ...interface{}) {
+	log.Printf("replayer: "+format, args...)
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"orden-compra/internal/archive"
+)
+
+func TestRunValidatesTheConfig(t *testing.T) {
+	t.Setenv("ARCHIVE_BUCKET", "")
+	for _, tc := range []struct {
+		name string
+		cfg  config
+		want string
+	}{
+		{"no exchange", config{Source: sourceFile, File: "-", Repeat: 1}, "-exchange is required"},
+		{"no repeat", config{Source: sourceFile, File: "-", Exchange: "backfill"}, "-repeat must be at least 1"},
+		{"repeated standard input", config{Source: sourceFile, File: "-", Exchange: "backfill", Repeat: 2}, "standard input cannot be repeated"},
+		{"no range", config{Source: sourceStore, Exchange: "backfill", Repeat: 1}, "-from is required"},
+		{"invalid start", config{Source: sourceStore, From: "yesterday", Exchange: "backfill", Repeat: 1}, "invalid -from"},
+		{"invalid end", config{Source: sourceStore, From: "2024-03-01T00:00:00Z", To: "today", Exchange: "backfill", Repeat: 1}, "invalid -to"},
+		{"unknown source", config{Source: "queue", From: "2024-03-01T00:00:00Z", Exchange: "backfill", Repeat: 1}, `unknown -source "queue"`},
+		{"no bucket", config{Source: sourceArchive, From: "2024-03-01T00:00:00Z", Exchange: "backfill", Repeat: 1}, archive.ErrNotConfigured.Error()},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			// Every config is refused before connecting to the broker
+			err := run(context.Background(), tc.cfg)
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("run returned %v, want %q", err, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rabbitmq/amqp091-go"
+
+	"orden-compra/internal/handlers"
+	"orden-compra/internal/tenant"
+)
+
+// Headers that tag a replayed message
+const (
+	// replayHeader marks every replayed message
+	replayHeader = "replay"
+	// replayRunHeader names the run that replayed the message
+	replayRunHeader = "replay-run-id"
+	// replayedCorrelationHeader keeps the correlation ID the event was
+	// stored with, since the message carries the run's
+	replayedCorrelationHeader = "replayed-correlation-id"
+)
+
+// confirmWindow bounds the messages published before waiting for their
+// confirms, so a broker that stops confirming stops the replay
+const confirmWindow = 256
+
+// ErrNacked is returned when the broker refused replayed messages
+var ErrNacked = errors.New("broker nacked replayed messages")
+
+// publisher republishes events to an exchange at a steady rate
+type publisher struct {
+	channel    *amqp091.Channel
+	exchange   string
+	routingKey string
+	runID      string
+	dryRun     bool
+	interval   time.Duration
+
+	next    time.Time
+	pending []*amqp091.DeferredConfirmation
+	nacked  int
+}
+
+// newPublisher opens a channel in confirm mode; rate is in messages per
+// second and 0 publishes as fast as the broker confirms
+func newPublisher(connection *amqp091.Connection, exchange, routingKey, runID string, rate float64, dryRun bool) (*publisher, error) {
+	channel, err := connection.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open channel: %w", err)
+	}
+	if err := channel.Confirm(false); err != nil {
+		channel.Close()
+		return nil, fmt.Errorf("failed to enable publisher confirms: %w", err)
+	}
+
+	p := &publisher{
+		channel:    channel,
+		exchange:   exchange,
+		routingKey: routingKey,
+		runID:      runID,
+		dryRun:     dryRun,
+	}
+	if rate > 0 {
+		p.interval = time.Duration(float64(time.Second) / rate)
+	}
+	return p, nil
+}
+
+// correlationID is the correlation ID of every message of the run, so what
+// the replay caused can be traced as one chain with GET /flows
+func (p *publisher) correlationID() string {
+	return "replay-" + p.runID
+}
+
+// Publish waits for the record's turn and publishes it as a new message
+// caused by the original event
+func (p *publisher) Publish(ctx context.Context, r *record) error {
+	if p.interval > 0 {
+		now := time.Now()
+		if p.next.IsZero() || p.next.Before(now) {
+			p.next = now
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Until(p.next)):
+		}
+		p.next = p.next.Add(p.interval)
+	}
+
+	confirmation, err := p.channel.PublishWithDeferredConfirmWithContext(ctx, p.exchange, p.routingKey, false, false, amqp091.Publishing{
+		ContentType:  "application/json",
+		DeliveryMode: amqp091.Persistent,
+		MessageId:    uuid.New().String(),
+		AppId:        "orden-compra-replayer",
+		Timestamp:    time.Now(),
+		Headers:      p.headers(r),
+		Body:         r.Body,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to publish event %s: %w", r.ID, err)
+	}
+
+	p.pending = append(p.pending, confirmation)
+	if len(p.pending) >= confirmWindow {
+		return p.Flush(ctx)
+	}
+	return nil
+}
+
+// headers tags the message of a record as a replay of the run
+func (p *publisher) headers(r *record) amqp091.Table {
+	headers := amqp091.Table{
+		"correlation-id": p.correlationID(),
+		replayHeader:     "true",
+		replayRunHeader:  p.runID,
+	}
+	if r.ID != "" {
+		headers["causation-id"] = r.ID
+	}
+	if r.CorrelationID != "" {
+		headers[replayedCorrelationHeader] = r.CorrelationID
+	}
+	if r.EventType != "" {
+		headers["event-type"] = r.EventType
+	}
+	if r.TenantID != "" {
+		headers[tenant.MessageHeader] = r.TenantID
+	}
+	if p.dryRun {
+		headers[handlers.DryRunHeader] = "true"
+	}
+	return headers
+}
+
+// Flush waits for the confirms of the messages published so far
+func (p *publisher) Flush(ctx context.Context) error {
+	for _, confirmation := range p.pending {
+		acked, err := confirmation.WaitContext(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to wait for publisher confirm: %w", err)
+		}
+		if !acked {
+			p.nacked++
+		}
+	}
+	p.pending = p.pending[:0]
+	return nil
+}
+
+// Close closes the channel
+func (p *publisher) Close() error {
+	return p.channel.Close()
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/rabbitmq/amqp091-go"
+
+	"orden-compra/internal/handlers"
+	"orden-compra/internal/tenant"
+)
+
+func TestReplayHeaders(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		record record
+		dryRun bool
+		want   amqp091.Table
+	}{
+		{
+			"stored event",
+			record{ID: "event-1", EventType: "PurchaseOrderCreated", TenantID: "tenant-1", CorrelationID: "correlation-1"},
+			false,
+			amqp091.Table{
+				"correlation-id":          "replay-run-1",
+				replayHeader:              "true",
+				replayRunHeader:           "run-1",
+				"causation-id":            "event-1",
+				replayedCorrelationHeader: "correlation-1",
+				"event-type":              "PurchaseOrderCreated",
+				tenant.MessageHeader:      "tenant-1",
+			},
+		},
+		{
+			"dry run of a bare event",
+			record{},
+			true,
+			amqp091.Table{
+				"correlation-id":      "replay-run-1",
+				replayHeader:          "true",
+				replayRunHeader:       "run-1",
+				handlers.DryRunHeader: "true",
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p := &publisher{runID: "run-1", dryRun: tc.dryRun}
+			if got := p.headers(&tc.record); !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("headers %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
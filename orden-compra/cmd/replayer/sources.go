@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/sirupsen/logrus"
+
+	"orden-compra/internal/archive"
+	"orden-compra/internal/cqrs"
+	"orden-compra/internal/models"
+)
+
+// Sources the replayer reads from
+const (
+	sourceFile    = "file"
+	sourceArchive = "archive"
+	sourceStore   = "store"
+)
+
+// record is an event to republish, with the envelope fields its headers are
+// taken from
+type record struct {
+	Body          []byte
+	ID            string
+	EventType     string
+	TenantID      string
+	CorrelationID string
+}
+
+// newRecord reads the envelope fields of an event's JSON body. Both stored
+// events and the events the services exchange carry id, event_type and
+// tenant_id; only stored events have a correlation_id.
+func newRecord(body []byte) (*record, error) {
+	var envelope struct {
+		ID            string  `json:"id"`
+		EventType     string  `json:"event_type"`
+		TenantID      string  `json:"tenant_id"`
+		CorrelationID *string `json:"correlation_id"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return nil, fmt.Errorf("invalid event: %w", err)
+	}
+
+	r := &record{
+		Body:      body,
+		ID:        envelope.ID,
+		EventType: envelope.EventType,
+		TenantID:  envelope.TenantID,
+	}
+	if envelope.CorrelationID != nil {
+		r.CorrelationID = *envelope.CorrelationID
+	}
+	return r, nil
+}
+
+// source yields the events to republish in order, and io.EOF after the last
+type source interface {
+	Next(ctx context.Context) (*record, error)
+	Close() error
+}
+
+// fileSource reads JSON Lines, gzipped when the name ends in .gz, as
+// written by the archive or exported from a queue; "-" is standard input
+type fileSource struct {
+	file    io.Closer
+	scanner *bufio.Scanner
+	name    string
+	line    int
+}
+
+// newFileSource opens a JSON Lines file
+func newFileSource(name string) (*fileSource, error) {
+	var (
+		file   io.ReadCloser = os.Stdin
+		reader io.Reader     = os.Stdin
+	)
+	if name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s: %w", name, err)
+		}
+		file, reader = f, f
+	}
+	if strings.HasSuffix(name, ".gz") {
+		zr, err := gzip.NewReader(reader)
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to decompress %s: %w", name, err)
+		}
+		reader = zr
+	}
+
+	scanner := bufio.NewScanner(reader)
+	// DynamoDB items are at most 400 KB; JSON adds some overhead
+	scanner.Buffer(make([]byte, 64*1024), 2<<20)
+	return &fileSource{file: file, scanner: scanner, name: name}, nil
+}
+
+// Next returns the event of the next non-blank line
+func (s *fileSource) Next(ctx context.Context) (*record, error) {
+	for s.scanner.Scan() {
+		s.line++
+		line := strings.TrimSpace(s.scanner.Text())
+		if line == "" {
+			continue
+		}
+		r, err := newRecord([]byte(line))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", s.name, s.line, err)
+		}
+		return r, nil
+	}
+	if err := s.scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", s.name, err)
+	}
+	return nil, io.EOF
+}
+
+// Close closes the file
+func (s *fileSource) Close() error {
+	return s.file.Close()
+}
+
+// archiveSource reads the events archived to S3 within a range
+type archiveSource struct {
+	events []map[string]interface{}
+}
+
+// newArchiveSource reads the range from the archive up front; the archive
+// stores one object per event day, so the range bounds what is held
+func newArchiveSource(ctx context.Context, archiver *archive.Archiver, from, to time.Time) (*archiveSource, error) {
+	events, objects, err := archiver.Read(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+	logf("read %d archived events from %d objects", len(events), objects)
+	return &archiveSource{events: events}, nil
+}
+
+// Next returns the next archived event
+func (s *archiveSource) Next(ctx context.Context) (*record, error) {
+	if len(s.events) == 0 {
+		return nil, io.EOF
+	}
+	event := s.events[0]
+	s.events = s.events[1:]
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode archived event %v: %w", event["id"], err)
+	}
+	return newRecord(body)
+}
+
+// Close releases nothing
+func (s *archiveSource) Close() error {
+	return nil
+}
+
+// storeSource pages through the event store in timestamp order, the way
+// GET /events does, upcasting each event to its current schema
+type storeSource struct {
+	dynamoDB dynamodbiface.DynamoDBAPI
+	logger   *logrus.Logger
+	cursor   cqrs.EventCursor
+	until    time.Time
+	pageSize int
+	page     []*models.EventSourcingEvent
+	done     bool
+}
+
+// newStoreSource reads the events stored after from and up to to
+func newStoreSource(dynamoDB dynamodbiface.DynamoDBAPI, from, to time.Time, pageSize int) *storeSource {
+	logger := logrus.New()
+	logger.SetLevel(logrus.WarnLevel)
+	return &storeSource{
+		dynamoDB: dynamoDB,
+		logger:   logger,
+		cursor:   cqrs.EventCursor{Timestamp: from.UTC()},
+		until:    to,
+		pageSize: pageSize,
+	}
+}
+
+// Next returns the next stored event, reading a page when the last is used up
+func (s *storeSource) Next(ctx context.Context) (*record, error) {
+	for len(s.page) == 0 {
+		if s.done {
+			return nil, io.EOF
+		}
+		if err := s.readPage(ctx); err != nil {
+			return nil, err
+		}
+	}
+	event := s.page[0]
+	s.page = s.page[1:]
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode stored event %s: %w", event.ID, err)
+	}
+	return newRecord(body)
+}
+
+// readPage reads the events after the cursor
+func (s *storeSource) readPage(ctx context.Context) error {
+	result, err := cqrs.NewListEventsQuery(s.cursor, s.until, s.pageSize, s.dynamoDB, s.logger).Execute(ctx)
+	if err != nil {
+		return err
+	}
+	s.page, _ = result["events"].([]*models.EventSourcingEvent)
+
+	hasMore, _ := result["has_more"].(bool)
+	if !hasMore {
+		s.done = true
+	}
+	if len(s.page) == 0 {
+		// The page stopped at an event this build cannot upcast
+		if hasMore {
+			return fmt.Errorf("stopped at an event after %s whose schema this build does not know", s.cursor.Timestamp.Format(time.RFC3339Nano))
+		}
+		return nil
+	}
+	last := s.page[len(s.page)-1]
+	s.cursor = cqrs.EventCursor{Timestamp: last.Timestamp.UTC(), EventID: last.ID}
+	return nil
+}
+
+// Close releases nothing
+func (s *storeSource) Close() error {
+	return nil
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+
+	"orden-compra/internal/memory"
+	"orden-compra/internal/models"
+)
+
+func TestNewRecord(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		body  string
+		want  record
+		error bool
+	}{
+		{"stored event", `{"id":"event-1","event_type":"PurchaseOrderCreated","tenant_id":"tenant-1","correlation_id":"correlation-1"}`, record{ID: "event-1", EventType: "PurchaseOrderCreated", TenantID: "tenant-1", CorrelationID: "correlation-1"}, false},
+		{"exchanged event", `{"id":"stock-low-1","event_type":"StockBajo"}`, record{ID: "stock-low-1", EventType: "StockBajo"}, false},
+		{"null correlation", `{"id":"event-1","correlation_id":null}`, record{ID: "event-1"}, false},
+		{"not JSON", `{"id":`, record{}, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := newRecord([]byte(tc.body))
+			if tc.error {
+				if err == nil {
+					t.Fatalf("newRecord returned %+v", r)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("newRecord: %v", err)
+			}
+			if string(r.Body) != tc.body || r.ID != tc.want.ID || r.EventType != tc.want.EventType || r.TenantID != tc.want.TenantID || r.CorrelationID != tc.want.CorrelationID {
+				t.Fatalf("record %+v, want %+v", r, tc.want)
+			}
+		})
+	}
+}
+
+// readAll returns the IDs of every record of a source
+func readAll(t *testing.T, src source) []string {
+	t.Helper()
+	defer src.Close()
+	var ids []string
+	for {
+		r, err := src.Next(context.Background())
+		if err == io.EOF {
+			return ids
+		}
+		if err != nil {
+			t.Fatalf("next: %v", err)
+		}
+		ids = append(ids, r.ID)
+	}
+}
+
+func TestFileSource(t *testing.T) {
+	lines := "{\"id\":\"event-1\"}\n\n  \n{\"id\":\"event-2\"}\n"
+	dir := t.TempDir()
+
+	plain := filepath.Join(dir, "events.jsonl")
+	if err := os.WriteFile(plain, []byte(lines), 0o600); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	gzipped := filepath.Join(dir, "events.jsonl.gz")
+	f, err := os.Create(gzipped)
+	if err != nil {
+		t.Fatalf("create file: %v", err)
+	}
+	zw := gzip.NewWriter(f)
+	zw.Write([]byte(lines))
+	zw.Close()
+	f.Close()
+
+	for _, name := range []string{plain, gzipped} {
+		t.Run(filepath.Base(name), func(t *testing.T) {
+			src, err := newFileSource(name)
+			if err != nil {
+				t.Fatalf("open: %v", err)
+			}
+			if ids := readAll(t, src); strings.Join(ids, ",") != "event-1,event-2" {
+				t.Fatalf("read %v, want event-1 and event-2 skipping blank lines", ids)
+			}
+		})
+	}
+
+	// An invalid line is reported with its position
+	invalid := filepath.Join(dir, "invalid.jsonl")
+	if err := os.WriteFile(invalid, []byte("{\"id\":\"event-1\"}\nnot json\n"), 0o600); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	src, err := newFileSource(invalid)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer src.Close()
+	if _, err := src.Next(context.Background()); err != nil {
+		t.Fatalf("first line: %v", err)
+	}
+	if _, err := src.Next(context.Background()); err == nil || !strings.Contains(err.Error(), invalid+":2:") {
+		t.Fatalf("invalid line returned %v", err)
+	}
+
+	if _, err := newFileSource(filepath.Join(dir, "missing.jsonl")); err == nil {
+		t.Fatal("opened a missing file")
+	}
+}
+
+func TestStoreSourcePagesThroughTheRange(t *testing.T) {
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	var want []string
+	for i := 0; i < 6; i++ {
+		event := models.NewEventSourcingEvent("po-1", "PurchaseOrderCreated", map[string]interface{}{"quantity": i}, nil, nil, from.Add(time.Duration(i+1)*time.Minute))
+		item, err := dynamodbattribute.MarshalMap(event)
+		if err != nil {
+			t.Fatalf("marshal event: %v", err)
+		}
+		if _, err := dynamoDB.PutItemWithContext(context.Background(), &dynamodb.PutItemInput{TableName: aws.String("orden-compra-events"), Item: item}); err != nil {
+			t.Fatalf("put event: %v", err)
+		}
+		// The last event is after the end of the range
+		if i < 5 {
+			want = append(want, event.ID)
+		}
+	}
+
+	ids := readAll(t, newStoreSource(dynamoDB, from, from.Add(5*time.Minute), 2))
+	if strings.Join(ids, ",") != strings.Join(want, ",") {
+		t.Fatalf("read %v, want %v", ids, want)
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"math"
+	"time"
+
+	"orden-compra/internal/models"
+)
+
+// product is a catalog product, with the stock thresholds its orders are
+// sized from
+type product struct {
+	ID           string
+	Name         string
+	MinimumStock int
+}
+
+// products are the products the seeded suppliers carry
+var products = []product{
+	{ID: "PROD-INSULIN-100", Name: "Insulina glargina 100 UI/ml", MinimumStock: 120},
+	{ID: "PROD-GLOVES-M", Name: "Guantes de nitrilo talla M (caja x100)", MinimumStock: 400},
+	{ID: "PROD-SYRINGE-5", Name: "Jeringa desechable 5 ml", MinimumStock: 1000},
+	{ID: "PROD-SALINE-500", Name: "Solución salina 0,9% 500 ml", MinimumStock: 600},
+	{ID: "PROD-AMOX-500", Name: "Amoxicilina 500 mg (caja x30)", MinimumStock: 250},
+	{ID: "PROD-MASK-N95", Name: "Respirador N95", MinimumStock: 800},
+	{ID: "PROD-GAUZE-10", Name: "Gasa estéril 10x10 cm (paquete x10)", MinimumStock: 500},
+	{ID: "PROD-CATHETER-20G", Name: "Catéter intravenoso 20G", MinimumStock: 300},
+	{ID: "PROD-PARACET-500", Name: "Acetaminofén 500 mg (caja x100)", MinimumStock: 350},
+	{ID: "PROD-HEPARIN-5K", Name: "Heparina sódica 5.000 UI/ml", MinimumStock: 90},
+}
+
+// locations are the warehouses orders are delivered to
+var locations = []string{"BOG-CENTRAL", "MED-NORTE", "CAL-SUR", "BAQ-COSTA"}
+
+// supplierProduct is a product a supplier carries, on a contract when
+// UnitPrice is set
+type supplierProduct struct {
+	ProductID    string
+	SKU          string
+	LeadTimeDays int
+	MOQ          int
+	UnitPrice    float64
+}
+
+// supplier is a seeded supplier. The first is supplier-001, the supplier
+// orden-compra orders StockBajo products from, so live events find it listed.
+type supplier struct {
+	ID           string
+	Name         string
+	Email        string
+	Phone        string
+	Address      string
+	LeadTimeDays int
+	CriticalDays int
+	Products     []supplierProduct
+}
+
+// suppliers is the seeded supplier catalog
+var suppliers = []supplier{
+	{
+		ID: "supplier-001", Name: "MediPharm Colombia", Email: "pedidos@medipharm.example", Phone: "+57 601 555 0101",
+		Address: "Cra. 7 # 71-21, Bogotá", LeadTimeDays: 5, CriticalDays: 2,
+		Products: []supplierProduct{
+			{ProductID: "PROD-INSULIN-100", SKU: "MP-INS-100", LeadTimeDays: 3, MOQ: 50, UnitPrice: 38.5},
+			{ProductID: "PROD-AMOX-500", SKU: "MP-AMX-500", MOQ: 100, UnitPrice: 4.2},
+			{ProductID: "PROD-PARACET-500", SKU: "MP-ACT-500", MOQ: 100, UnitPrice: 2.9},
+			{ProductID: "PROD-HEPARIN-5K", SKU: "MP-HEP-5K", LeadTimeDays: 4, MOQ: 20, UnitPrice: 12.75},
+		},
+	},
+	{
+		ID: "supplier-002", Name: "Suministros Quirúrgicos Andinos", Email: "ventas@squirurgicos.example", Phone: "+57 604 555 0202",
+		Address: "Cl. 10 # 43D-30, Medellín", LeadTimeDays: 7, CriticalDays: 3,
+		Products: []supplierProduct{
+			{ProductID: "PROD-GLOVES-M", SKU: "SQA-GN-M", MOQ: 200, UnitPrice: 6.1},
+			{ProductID: "PROD-GAUZE-10", SKU: "SQA-GZ-10", MOQ: 250, UnitPrice: 1.35},
+			{ProductID: "PROD-CATHETER-20G", SKU: "SQA-CT-20", LeadTimeDays: 10},
+			{ProductID: "PROD-MASK-N95", SKU: "SQA-N95"},
+		},
+	},
+	{
+		ID: "supplier-003", Name: "Laboratorios del Pacífico", Email: "compras@labpacifico.example", Phone: "+57 602 555 0303",
+		Address: "Av. 6N # 28N-102, Cali", LeadTimeDays: 10, CriticalDays: 4,
+		Products: []supplierProduct{
+			{ProductID: "PROD-SALINE-500", SKU: "LP-SS-500", MOQ: 300, UnitPrice: 1.8},
+			{ProductID: "PROD-AMOX-500", SKU: "LP-AMX-500", LeadTimeDays: 8},
+			{ProductID: "PROD-HEPARIN-5K", SKU: "LP-HEP-5K"},
+		},
+	},
+	{
+		ID: "supplier-004", Name: "Distribuidora Médica Caribe", Email: "pedidos@dmcaribe.example", Phone: "+57 605 555 0404",
+		Address: "Cra. 54 # 72-80, Barranquilla", LeadTimeDays: 6, CriticalDays: 2,
+		Products: []supplierProduct{
+			{ProductID: "PROD-SYRINGE-5", SKU: "DMC-JR-5", MOQ: 500, UnitPrice: 0.22},
+			{ProductID: "PROD-MASK-N95", SKU: "DMC-N95", MOQ: 400, UnitPrice: 1.1},
+			{ProductID: "PROD-GLOVES-M", SKU: "DMC-GN-M"},
+			{ProductID: "PROD-PARACET-500", SKU: "DMC-ACT-500", LeadTimeDays: 4},
+		},
+	},
+	{
+		ID: "supplier-005", Name: "BioInsumos Hospitalarios", Email: "servicio@bioinsumos.example", Phone: "+57 601 555 0505",
+		Address: "Cl. 26 # 69-76, Bogotá", LeadTimeDays: 14, CriticalDays: 5,
+		Products: []supplierProduct{
+			{ProductID: "PROD-CATHETER-20G", SKU: "BIH-CAT-20G", MOQ: 100, UnitPrice: 0.95},
+			{ProductID: "PROD-SALINE-500", SKU: "BIH-SS-500"},
+			{ProductID: "PROD-SYRINGE-5", SKU: "BIH-JR-5", LeadTimeDays: 9},
+			{ProductID: "PROD-GAUZE-10", SKU: "BIH-GZ-10"},
+		},
+	},
+}
+
+// Supplier returns the supplier's catalog entry, with contracts in force from
+// validFrom for a year
+func (s supplier) Supplier(tenantID string, validFrom time.Time) *models.Supplier {
+	validTo := validFrom.AddDate(1, 0, 0)
+	entry := &models.Supplier{
+		ID:                   s.ID,
+		TenantID:             tenantID,
+		Name:                 s.Name,
+		Email:                s.Email,
+		Phone:                s.Phone,
+		Address:              s.Address,
+		IsActive:             true,
+		LeadTimeDays:         s.LeadTimeDays,
+		CriticalLeadTimeDays: s.CriticalDays,
+		Metadata:             map[string]interface{}{"seeded": true},
+	}
+	for _, p := range s.Products {
+		mapping := models.SupplierProduct{
+			ProductID:    p.ProductID,
+			SupplierSKU:  p.SKU,
+			LeadTimeDays: p.LeadTimeDays,
+		}
+		if p.UnitPrice > 0 {
+			mapping.Contract = &models.SupplierContract{
+				ContractID:           "CT-" + p.SKU,
+				MinimumOrderQuantity: p.MOQ,
+				Currency:             "USD",
+				UnitPrice:            p.UnitPrice,
+				PriceBreaks: []models.PriceBreak{
+					{MinQuantity: p.MOQ * 5, UnitPrice: round(p.UnitPrice * 0.92)},
+					{MinQuantity: p.MOQ * 10, UnitPrice: round(p.UnitPrice * 0.85)},
+				},
+				ValidFrom: &validFrom,
+				ValidTo:   &validTo,
+			}
+		}
+		entry.Products = append(entry.Products, mapping)
+	}
+	return entry
+}
+
+// productByID returns the catalog product with the ID
+func productByID(id string) product {
+	for _, p := range products {
+		if p.ID == id {
+			return p
+		}
+	}
+	return product{ID: id, Name: id, MinimumStock: 100}
+}
+
+// round rounds a price to cents
+func round(price float64) float64 {
+	return math.Round(price*100) / 100
+}
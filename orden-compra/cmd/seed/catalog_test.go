@@ -0,0 +1,53 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCatalogIsConsistent(t *testing.T) {
+	if suppliers[0].ID != "supplier-001" {
+		t.Fatalf("first supplier is %s, want supplier-001 which StockBajo orders use", suppliers[0].ID)
+	}
+	known := make(map[string]bool)
+	for _, p := range products {
+		known[p.ID] = true
+	}
+	for _, s := range suppliers {
+		for _, p := range s.Products {
+			if !known[p.ProductID] {
+				t.Errorf("%s carries %s, which is not in the catalog", s.ID, p.ProductID)
+			}
+		}
+	}
+}
+
+func TestSupplierEntry(t *testing.T) {
+	validFrom := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	entry := suppliers[0].Supplier("tenant-1", validFrom)
+	if entry.ID != "supplier-001" || entry.TenantID != "tenant-1" || !entry.IsActive || len(entry.Products) != len(suppliers[0].Products) {
+		t.Fatalf("supplier %+v", entry)
+	}
+
+	contract := entry.Products[0].Contract
+	if contract == nil || contract.UnitPrice != 38.5 || contract.MinimumOrderQuantity != 50 || !contract.ValidTo.Equal(validFrom.AddDate(1, 0, 0)) {
+		t.Fatalf("contract %+v", contract)
+	}
+	if len(contract.PriceBreaks) != 2 || contract.PriceBreaks[0].MinQuantity != 250 || contract.PriceBreaks[0].UnitPrice != 35.42 || contract.PriceBreaks[1].UnitPrice != 32.73 {
+		t.Fatalf("price breaks %+v", contract.PriceBreaks)
+	}
+
+	// Products without a price are carried without a contract
+	if mapping := suppliers[1].Supplier("", validFrom).Products[2]; mapping.Contract != nil || mapping.LeadTimeDays != 10 {
+		t.Fatalf("mapping %+v", mapping)
+	}
+}
+
+func TestProductByID(t *testing.T) {
+	if p := productByID("PROD-GLOVES-M"); p.MinimumStock != 400 {
+		t.Fatalf("product %+v", p)
+	}
+	if p := productByID("PROD-UNKNOWN"); p.Name != "PROD-UNKNOWN" || p.MinimumStock != 100 {
+		t.Fatalf("unknown product %+v", p)
+	}
+}
@@ -0,0 +1,191 @@
+// Command seed fills a local DynamoDB with demo data, so development and demo
+// environments do not start empty: a catalog of medical suppliers with their
+// product mappings and contracts, and purchase orders spread over the past
+// days, each taken as far through its lifecycle as it would have got by now.
+// Older orders were mostly sent and received, with the receipts Proveedor
+// reports once a reception passes inspection; recent ones are still pending,
+// awaiting approval or on their way.
+//
+//	DYNAMODB_ENDPOINT=http://localhost:8000 go run ./cmd/seed -orders 500 -days 120
+//
+// Orders are created and moved by the service's own commands on a clock set
+// back in time, so they carry the same events, SLAs, pricing and receipts as
+// live ones. The same -seed gives the same mix of products, quantities and
+// outcomes; IDs are new on every run, so running it again adds more orders.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"os"
+	"os/signal"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+
+	"medisupply/clock"
+	"medisupply/env"
+
+	"orden-compra/internal/cqrs"
+	"orden-compra/internal/memory"
+	"orden-compra/internal/models"
+)
+
+// config holds the command line flags
+type config struct {
+	Orders       int
+	Days         int
+	Suppliers    int
+	Seed         int64
+	TenantID     string
+	CreateTables bool
+	Verbose      bool
+}
+
+func main() {
+	var cfg config
+	flag.IntVar(&cfg.Orders, "orders", 200, "purchase orders to create")
+	flag.IntVar(&cfg.Days, "days", 90, "days back the orders are spread over")
+	flag.IntVar(&cfg.Suppliers, "suppliers", len(suppliers), fmt.Sprintf("suppliers to list, at most %d", len(suppliers)))
+	flag.Int64Var(&cfg.Seed, "seed", 1, "seed of the random choices, for a repeatable mix")
+	flag.StringVar(&cfg.TenantID, "tenant", "", "tenant the data belongs to; none when empty")
+	flag.BoolVar(&cfg.CreateTables, "create-tables", true, "create the orden-compra tables that do not exist yet")
+	flag.BoolVar(&cfg.Verbose, "verbose", false, "print the log of every command run")
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := run(ctx, cfg); err != nil {
+		log.Fatalf("seed: %v", err)
+	}
+}
+
+// run lists the suppliers and creates the orders, oldest first
+func run(ctx context.Context, cfg config) error {
+	if cfg.Orders < 0 {
+		return errors.New("-orders must not be negative")
+	}
+	if cfg.Days < 1 {
+		return errors.New("-days must be at least 1")
+	}
+	if cfg.Suppliers < 1 {
+		return errors.New("-suppliers must be at least 1")
+	}
+
+	endpoint := env.String("DYNAMODB_ENDPOINT", "http://dynamodb-local:8000")
+	sess, err := session.NewSession(&aws.Config{
+		Endpoint:    aws.String(endpoint),
+		Region:      aws.String(env.String("DYNAMODB_REGION", "us-east-1")),
+		Credentials: credentials.NewStaticCredentials("dummy", "dummy", ""),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create DynamoDB session: %w", err)
+	}
+	client := dynamodb.New(sess)
+	if cfg.CreateTables {
+		if err := createTables(ctx, client); err != nil {
+			return err
+		}
+	}
+
+	logger := log.New(io.Discard, "", 0)
+	if cfg.Verbose {
+		logger = log.New(os.Stderr, "seed: ", log.LstdFlags)
+	}
+	now := time.Now().UTC()
+	fake := clock.NewFake(now)
+
+	// Count the orders in the statistics the way the service does
+	stats := cqrs.StatsInCommands
+	if env.String("DYNAMODB_STREAMS_ENABLED", "false") == "true" {
+		stats = cqrs.StatsFromStream
+	}
+
+	s := &seeder{
+		dynamoDB: client,
+		stats:    stats,
+		logger:   logger,
+		random:   rand.New(rand.NewSource(cfg.Seed)),
+		clock:    fake,
+		now:      now,
+		tenantID: cfg.TenantID,
+	}
+	start := now.AddDate(0, 0, -cfg.Days)
+	logf("seeding %s with %d suppliers and %d purchase orders since %s", endpoint, cfg.Suppliers, cfg.Orders, start.Format("2006-01-02"))
+	if err := s.seedSuppliers(ctx, cfg.Suppliers, start); err != nil {
+		return err
+	}
+
+	// Spread the orders over the days, stopping a day short of now so even
+	// the newest has had time to be approved
+	createdAt := make([]time.Time, cfg.Orders)
+	span := now.Add(-24 * time.Hour).Sub(start)
+	for i := range createdAt {
+		createdAt[i] = start.Add(time.Duration(s.random.Int63n(int64(span))))
+	}
+	sort.Slice(createdAt, func(i, j int) bool { return createdAt[i].Before(createdAt[j]) })
+
+	statuses := make(map[string]int)
+	for i, at := range createdAt {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		status, err := s.seedOrder(ctx, at)
+		if err != nil {
+			return fmt.Errorf("failed to seed purchase order %d of %d: %w", i+1, cfg.Orders, err)
+		}
+		statuses[status]++
+		if (i+1)%100 == 0 {
+			logf("created %d purchase orders", i+1)
+		}
+	}
+
+	logf("created %d purchase orders: %d pending, %d pending approval, %d approved, %d sent, %d received short, %d completed with receipts, %d cancelled",
+		cfg.Orders, statuses[models.StatusPending], statuses[models.StatusPendingApproval], statuses[models.StatusApproved],
+		statuses[models.StatusSent], statuses[models.StatusReceived], statuses[models.StatusCompleted], statuses[models.StatusCancelled])
+	return nil
+}
+
+// createTables creates the orden-compra tables DynamoDB Local does not have
+// yet, keyed as the service expects
+func createTables(ctx context.Context, client *dynamodb.DynamoDB) error {
+	for name, key := range memory.Tables {
+		attributes := []*dynamodb.AttributeDefinition{{AttributeName: aws.String(key.Hash), AttributeType: aws.String(dynamodb.ScalarAttributeTypeS)}}
+		schema := []*dynamodb.KeySchemaElement{{AttributeName: aws.String(key.Hash), KeyType: aws.String(dynamodb.KeyTypeHash)}}
+		if key.Range != "" {
+			attributes = append(attributes, &dynamodb.AttributeDefinition{AttributeName: aws.String(key.Range), AttributeType: aws.String(dynamodb.ScalarAttributeTypeS)})
+			schema = append(schema, &dynamodb.KeySchemaElement{AttributeName: aws.String(key.Range), KeyType: aws.String(dynamodb.KeyTypeRange)})
+		}
+
+		_, err := client.CreateTableWithContext(ctx, &dynamodb.CreateTableInput{
+			TableName:            aws.String(name),
+			AttributeDefinitions: attributes,
+			KeySchema:            schema,
+			BillingMode:          aws.String(dynamodb.BillingModePayPerRequest),
+		})
+		var aerr awserr.Error
+		if errors.As(err, &aerr) && aerr.Code() == dynamodb.ErrCodeResourceInUseException {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to create table %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// logf prints a progress line of the run
+func logf(format string, args ...interface{}) {
+	log.Printf("seed: "+format, args...)
+}
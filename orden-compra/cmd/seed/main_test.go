@@ -0,0 +1,24 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRunValidatesTheConfig(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		cfg  config
+		want string
+	}{
+		{"negative orders", config{Orders: -1, Days: 1, Suppliers: 1}, "-orders must not be negative"},
+		{"no days", config{Orders: 1, Suppliers: 1}, "-days must be at least 1"},
+		{"no suppliers", config{Orders: 1, Days: 1}, "-suppliers must be at least 1"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := run(context.Background(), tc.cfg); err == nil || err.Error() != tc.want {
+				t.Fatalf("run returned %v, want %q", err, tc.want)
+			}
+		})
+	}
+}
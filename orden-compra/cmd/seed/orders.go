@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/google/uuid"
+
+	"medisupply/clock"
+
+	"orden-compra/internal/cqrs"
+	"orden-compra/internal/models"
+)
+
+// seedActor approves and cancels seeded orders
+const seedActor = "seed"
+
+// Fates of seeded orders
+const (
+	fateOpen      = "open"
+	fateSent      = "sent"
+	fateReceived  = "received"
+	fateCancelled = "cancelled"
+)
+
+// seeder creates backdated purchase orders and takes them through their
+// lifecycle by running the service's commands on a fake clock, so the
+// stored orders and events look like the ones live traffic leaves behind
+type seeder struct {
+	dynamoDB  dynamodbiface.DynamoDBAPI
+	stats     cqrs.StatsProjection
+	logger    *log.Logger
+	random    *rand.Rand
+	clock     *clock.Fake
+	now       time.Time
+	tenantID  string
+	suppliers []*models.Supplier
+}
+
+// seedSuppliers stores the supplier catalog, with contracts in force since
+// from
+func (s *seeder) seedSuppliers(ctx context.Context, count int, from time.Time) error {
+	if count > len(suppliers) {
+		count = len(suppliers)
+	}
+	s.clock.Set(from)
+	for _, entry := range suppliers[:count] {
+		supplier := entry.Supplier(s.tenantID, from)
+		upsert := cqrs.NewUpsertSupplierCommand(supplier, s.dynamoDB, s.logger)
+		upsert.Clock = s.clock
+		if _, err := upsert.Execute(ctx); err != nil {
+			return fmt.Errorf("failed to store supplier %s: %w", supplier.ID, err)
+		}
+		s.suppliers = append(s.suppliers, supplier)
+	}
+	return nil
+}
+
+// seedOrder creates a purchase order at at and moves it through what would
+// have happened to it by now, returning the status it was left in
+func (s *seeder) seedOrder(ctx context.Context, at time.Time) (string, error) {
+	s.clock.Set(at)
+	supplier := s.suppliers[s.random.Intn(len(s.suppliers))]
+	mapping := supplier.Products[s.random.Intn(len(supplier.Products))]
+	item := productByID(mapping.ProductID)
+	urgency := s.urgency()
+
+	// Order back up to twice the minimum stock, as the default strategy does
+	currentStock := s.random.Intn(item.MinimumStock)
+	quantity := 2*item.MinimumStock - currentStock
+
+	purchaseOrder := models.NewPurchaseOrder(item.ID, item.Name, supplier.ID, supplier.Name, locations[s.random.Intn(len(locations))], urgency, quantity, at)
+	purchaseOrder.TenantID = s.tenantID
+	correlationID := "seed-" + uuid.New().String()
+	purchaseOrder.Metadata["correlation_id"] = correlationID
+	purchaseOrder.Metadata["seeded"] = true
+	purchaseOrder.Metadata[models.MetadataReorderStrategy] = "min_max"
+
+	leadTimeDays, slaSource := models.DefaultLeadTimePolicy().LeadTimeDays(supplier, item.ID, urgency)
+	purchaseOrder.ApplySLA(purchaseOrder.CreatedAt, leadTimeDays, slaSource)
+	if contract := mapping.Contract; contract != nil && contract.InForce(at) {
+		purchaseOrder.Quantity = contract.OrderQuantity(purchaseOrder.Quantity)
+		purchaseOrder.Pricing, _ = contract.Price(purchaseOrder.Quantity)
+	}
+
+	// Critical orders wait for approval, as with APPROVAL_REQUIRED_FOR_CRITICAL
+	if urgency == "critical" {
+		purchaseOrder.Status = models.StatusPendingApproval
+		purchaseOrder.Metadata[models.MetadataApprovalReason] = "critical urgency"
+	}
+
+	create := cqrs.NewCreatePurchaseOrderCommand(purchaseOrder, s.dynamoDB, s.logger, &correlationID, nil)
+	create.Clock = s.clock
+	create.Stats = s.stats
+	if _, err := create.Execute(ctx); err != nil {
+		return "", err
+	}
+	status := purchaseOrder.Status
+
+	fate := s.fate(purchaseOrder)
+	if status == models.StatusPendingApproval && fate != fateCancelled {
+		if fate == fateOpen || !s.advance(at.Add(time.Duration(1+s.random.Intn(6))*time.Hour)) {
+			return status, nil
+		}
+		approve := cqrs.NewApprovePurchaseOrderCommand(purchaseOrder.ID, seedActor, "Critical stock, approved", s.dynamoDB, s.logger, &correlationID, nil)
+		approve.Clock = s.clock
+		approve.Stats = s.stats
+		if _, err := approve.Execute(ctx); err != nil {
+			return "", err
+		}
+		status = models.StatusApproved
+	}
+
+	switch fate {
+	case fateCancelled:
+		if !s.advance(at.Add(time.Duration(2+s.random.Intn(48)) * time.Hour)) {
+			return status, nil
+		}
+		cancel := cqrs.NewCancelPurchaseOrderCommand(purchaseOrder.ID, "Stock covered by a transfer between warehouses", s.dynamoDB, s.logger, &correlationID, nil)
+		cancel.Clock = s.clock
+		cancel.Stats = s.stats
+		if _, err := cancel.Execute(ctx); err != nil {
+			return "", err
+		}
+		return models.StatusCancelled, nil
+
+	case fateSent, fateReceived:
+		sentAt := at.Add(time.Duration(8+s.random.Intn(16)) * time.Hour)
+		if !s.advance(sentAt) {
+			return status, nil
+		}
+		send := cqrs.NewUpdatePurchaseOrderStatusCommand(purchaseOrder.ID, models.StatusSent, s.dynamoDB, s.logger, &correlationID, nil)
+		send.Clock = s.clock
+		send.Stats = s.stats
+		if _, err := send.Execute(ctx); err != nil {
+			return "", err
+		}
+		if fate == fateSent {
+			return models.StatusSent, nil
+		}
+
+		// Deliveries land a day early to three days late
+		receivedAt := purchaseOrder.ExpectedDate.Add(time.Duration(s.random.Intn(5)-1) * 24 * time.Hour).Add(time.Duration(s.random.Intn(10)) * time.Hour)
+		if earliest := sentAt.Add(12 * time.Hour); receivedAt.Before(earliest) {
+			receivedAt = earliest
+		}
+		if !s.advance(receivedAt) {
+			return models.StatusSent, nil
+		}
+		receive := cqrs.NewReceiveInventoryCommand(s.receipt(purchaseOrder, receivedAt), s.dynamoDB, s.logger, &correlationID, nil)
+		receive.Clock = s.clock
+		receive.Stats = s.stats
+		result, err := receive.Execute(ctx)
+		if err != nil {
+			return "", err
+		}
+		// A short delivery leaves the order received rather than completed
+		status, _ := result["status"].(string)
+		return status, nil
+	}
+	return status, nil
+}
+
+// fate decides what has happened to an order by now: most orders whose
+// delivery date has passed were received, a few were cancelled or are still
+// on their way (and overdue); recent ones are open or sent
+func (s *seeder) fate(purchaseOrder *models.PurchaseOrder) string {
+	roll := s.random.Intn(100)
+	if purchaseOrder.ExpectedDate.Add(72 * time.Hour).Before(s.now) {
+		switch {
+		case roll < 80:
+			return fateReceived
+		case roll < 90:
+			return fateCancelled
+		default:
+			return fateSent
+		}
+	}
+	switch {
+	case roll < 45:
+		return fateOpen
+	case roll < 95:
+		return fateSent
+	default:
+		return fateCancelled
+	}
+}
+
+// advance moves the clock to at, unless at is still to come
+func (s *seeder) advance(at time.Time) bool {
+	if at.After(s.now) {
+		return false
+	}
+	s.clock.Set(at)
+	return true
+}
+
+// urgency picks an urgency level, most orders being routine
+func (s *seeder) urgency() string {
+	switch roll := s.random.Intn(100); {
+	case roll < 40:
+		return "low"
+	case roll < 75:
+		return "medium"
+	case roll < 92:
+		return "high"
+	default:
+		return "critical"
+	}
+}
+
+// receipt builds the InventarioRecibido event Proveedor sends once the
+// order's reception passes inspection; one delivery in ten is short and a
+// few are over
+func (s *seeder) receipt(purchaseOrder *models.PurchaseOrder, receivedAt time.Time) *models.InventoryReceivedEvent {
+	quantity := purchaseOrder.Quantity
+	switch roll := s.random.Intn(100); {
+	case roll < 10:
+		quantity -= 1 + s.random.Intn(quantity/10+1)
+	case roll < 13:
+		quantity += 1 + s.random.Intn(quantity/20+1)
+	}
+
+	expiryDate := receivedAt.AddDate(1+s.random.Intn(2), s.random.Intn(12), 0)
+	event := &models.InventoryReceivedEvent{
+		ID:              uuid.New().String(),
+		TenantID:        purchaseOrder.TenantID,
+		Timestamp:       receivedAt,
+		EventType:       models.SupplierEventType,
+		PurchaseOrderID: purchaseOrder.ID,
+		ProductID:       purchaseOrder.ProductID,
+		ProductName:     purchaseOrder.ProductName,
+		Quantity:        quantity,
+		SupplierID:      purchaseOrder.SupplierID,
+		SupplierName:    purchaseOrder.SupplierName,
+		Location:        purchaseOrder.Location,
+		Status:          "received",
+		ReceivedAt:      receivedAt,
+		QualityCheck:    "passed",
+		BatchNumber:     fmt.Sprintf("L%s-%04d", receivedAt.Format("0601"), s.random.Intn(10000)),
+		ExpiryDate:      &expiryDate,
+		Metadata:        map[string]interface{}{"seeded": true},
+	}
+	// Cold chain products are logged with their arrival temperature
+	if purchaseOrder.ProductID == "PROD-INSULIN-100" || purchaseOrder.ProductID == "PROD-HEPARIN-5K" {
+		temperature := 2 + float64(s.random.Intn(60))/10
+		event.Temperature = &temperature
+	}
+	return event
+}
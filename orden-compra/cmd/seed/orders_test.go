@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+
+	"medisupply/clock"
+	"orden-compra/internal/cqrs"
+	"orden-compra/internal/memory"
+	"orden-compra/internal/models"
+)
+
+func newTestSeeder(now time.Time) (*seeder, *memory.DynamoDB) {
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	return &seeder{
+		dynamoDB: dynamoDB,
+		stats:    cqrs.StatsInCommands,
+		logger:   log.New(io.Discard, "", 0),
+		random:   rand.New(rand.NewSource(1)),
+		clock:    clock.NewFake(now),
+		now:      now,
+		tenantID: "tenant-1",
+	}, dynamoDB
+}
+
+func TestSeedSuppliers(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	s, dynamoDB := newTestSeeder(now)
+
+	// The count is capped at the catalog
+	if err := s.seedSuppliers(context.Background(), len(suppliers)+3, now.AddDate(0, 0, -30)); err != nil {
+		t.Fatalf("seed suppliers: %v", err)
+	}
+	if stored := dynamoDB.Items("orden-compra-suppliers"); len(stored) != len(suppliers) || len(s.suppliers) != len(suppliers) {
+		t.Fatalf("stored %d suppliers, want %d", len(stored), len(suppliers))
+	}
+}
+
+func TestSeedOrdersReachTheirFate(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	s, dynamoDB := newTestSeeder(now)
+	start := now.AddDate(0, 0, -60)
+	if err := s.seedSuppliers(context.Background(), 2, start); err != nil {
+		t.Fatalf("seed suppliers: %v", err)
+	}
+
+	statuses := make(map[string]int)
+	for i := 0; i < 40; i++ {
+		status, err := s.seedOrder(context.Background(), start.Add(time.Duration(i)*24*time.Hour))
+		if err != nil {
+			t.Fatalf("seed order %d: %v", i, err)
+		}
+		statuses[status]++
+	}
+	if statuses[models.StatusCompleted] == 0 || statuses[models.StatusPending]+statuses[models.StatusPendingApproval]+statuses[models.StatusSent] == 0 {
+		t.Fatalf("statuses %v, want old orders received and recent ones open", statuses)
+	}
+
+	items := dynamoDB.Items("orden-compra-read")
+	if len(items) != 40 {
+		t.Fatalf("stored %d orders, want 40", len(items))
+	}
+	stored := make(map[string]int)
+	for _, item := range items {
+		var order models.PurchaseOrder
+		if err := dynamodbattribute.UnmarshalMap(item, &order); err != nil {
+			t.Fatalf("unmarshal order: %v", err)
+		}
+		stored[order.Status]++
+		if order.TenantID != "tenant-1" || order.Metadata["seeded"] != true || order.UpdatedAt.After(now) {
+			t.Fatalf("order %+v", order)
+		}
+		if order.SupplierID != "supplier-001" && order.SupplierID != "supplier-002" {
+			t.Fatalf("order of %s, which was not seeded", order.SupplierID)
+		}
+	}
+	for status, count := range statuses {
+		if stored[status] != count {
+			t.Fatalf("stored statuses %v, seeding reported %v", stored, statuses)
+		}
+	}
+}
+
+func TestAdvanceStopsAtNow(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	s, _ := newTestSeeder(now)
+	if !s.advance(now.Add(-time.Hour)) || !s.clock.Now().Equal(now.Add(-time.Hour)) {
+		t.Fatalf("clock at %v, want an hour ago", s.clock.Now())
+	}
+	if s.advance(now.Add(time.Hour)) || !s.clock.Now().Equal(now.Add(-time.Hour)) {
+		t.Fatalf("clock moved to %v, past now", s.clock.Now())
+	}
+}
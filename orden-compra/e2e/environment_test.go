@@ -0,0 +1,176 @@
+//go:build integration
+
+package e2e
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/testcontainers/testcontainers-go"
+	tcdynamodb "github.com/testcontainers/testcontainers-go/modules/dynamodb"
+	"github.com/testcontainers/testcontainers-go/modules/rabbitmq"
+
+	"orden-compra/internal/memory"
+)
+
+// Images of the dependencies started for the flow
+const (
+	rabbitMQImage = "rabbitmq:3-management"
+	dynamoDBImage = "amazon/dynamodb-local:2.5.2"
+)
+
+// environment is RabbitMQ, DynamoDB Local and both services, started for one test
+type environment struct {
+	RabbitMQURL string
+	DynamoDB    *dynamodb.DynamoDB
+	OrdenCompra *service
+	Proveedor   *service
+}
+
+// startEnvironment builds the services, starts the containers, creates the
+// orden-compra tables and runs proveedor then orden-compra against them.
+// Everything is removed when the test ends; the services' and containers'
+// logs are reported when it failed.
+func startEnvironment(t *testing.T) *environment {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), startupTimeout)
+	defer cancel()
+
+	dir := t.TempDir()
+	proveedorDir, err := filepath.Abs("../../proveedor")
+	if err != nil {
+		t.Fatalf("proveedor source directory: %v", err)
+	}
+	ordenCompraBinary := buildService(t, ctx, "orden-compra", "..", dir)
+	proveedorBinary := buildService(t, ctx, "proveedor", proveedorDir, dir)
+
+	rabbitMQContainer, err := rabbitmq.Run(ctx, rabbitMQImage)
+	testcontainers.CleanupContainer(t, rabbitMQContainer)
+	if err != nil {
+		t.Fatalf("failed to start RabbitMQ: %v", err)
+	}
+	dynamoDBContainer, err := tcdynamodb.Run(ctx, dynamoDBImage, tcdynamodb.WithSharedDB())
+	testcontainers.CleanupContainer(t, dynamoDBContainer)
+	if err != nil {
+		t.Fatalf("failed to start DynamoDB Local: %v", err)
+	}
+
+	env := &environment{}
+	var services []*service
+	// Registered after the containers, so it runs before they are removed
+	t.Cleanup(func() {
+		if !t.Failed() {
+			return
+		}
+		for _, s := range services {
+			t.Logf("----- %s log -----\n%s", s.Name, s.Logs())
+		}
+		t.Logf("----- %s log -----\n%s", rabbitMQImage, containerLogs(rabbitMQContainer))
+		t.Logf("----- %s log -----\n%s", dynamoDBImage, containerLogs(dynamoDBContainer))
+	})
+
+	if env.RabbitMQURL, err = rabbitMQContainer.AmqpURL(ctx); err != nil {
+		t.Fatalf("RabbitMQ address: %v", err)
+	}
+	hostPort, err := dynamoDBContainer.ConnectionString(ctx)
+	if err != nil {
+		t.Fatalf("DynamoDB Local address: %v", err)
+	}
+	dynamoDBEndpoint := "http://" + hostPort
+	env.DynamoDB = newDynamoDB(t, dynamoDBEndpoint)
+	createTables(t, ctx, env.DynamoDB)
+
+	// Proveedor starts first so its queue is bound before orden-compra publishes
+	env.Proveedor = startService(t, "proveedor", proveedorBinary, dir, map[string]string{
+		"RABBITMQ_URL":         env.RabbitMQURL,
+		"OTEL_TRACES_EXPORTER": "none",
+	})
+	services = append(services, env.Proveedor)
+	env.Proveedor.WaitHealthy(t)
+
+	env.OrdenCompra = startService(t, "orden-compra", ordenCompraBinary, dir, map[string]string{
+		"GRPC_PORT":                      strconv.Itoa(freePort(t)),
+		"RABBITMQ_URL":                   env.RabbitMQURL,
+		"DYNAMODB_ENDPOINT":              dynamoDBEndpoint,
+		"DYNAMODB_REGION":                "us-east-1",
+		"PROVEEDOR_URL":                  env.Proveedor.URL,
+		"AUTH_ENABLED":                   "false",
+		"APPROVAL_REQUIRED_FOR_CRITICAL": "false",
+		"OTEL_TRACES_EXPORTER":           "none",
+	})
+	services = append(services, env.OrdenCompra)
+	env.OrdenCompra.WaitHealthy(t)
+
+	return env
+}
+
+// newDynamoDB creates a client of DynamoDB Local
+func newDynamoDB(t *testing.T, endpoint string) *dynamodb.DynamoDB {
+	t.Helper()
+	sess, err := session.NewSession(&aws.Config{
+		Endpoint:    aws.String(endpoint),
+		Region:      aws.String("us-east-1"),
+		Credentials: credentials.NewStaticCredentials("dummy", "dummy", ""),
+	})
+	if err != nil {
+		t.Fatalf("failed to create DynamoDB session: %v", err)
+	}
+	return dynamodb.New(sess)
+}
+
+// createTables creates every orden-compra table in DynamoDB Local
+func createTables(t *testing.T, ctx context.Context, client *dynamodb.DynamoDB) {
+	t.Helper()
+	for name, key := range memory.Tables {
+		attributes := []*dynamodb.AttributeDefinition{{AttributeName: aws.String(key.Hash), AttributeType: aws.String(dynamodb.ScalarAttributeTypeS)}}
+		schema := []*dynamodb.KeySchemaElement{{AttributeName: aws.String(key.Hash), KeyType: aws.String(dynamodb.KeyTypeHash)}}
+		if key.Range != "" {
+			attributes = append(attributes, &dynamodb.AttributeDefinition{AttributeName: aws.String(key.Range), AttributeType: aws.String(dynamodb.ScalarAttributeTypeS)})
+			schema = append(schema, &dynamodb.KeySchemaElement{AttributeName: aws.String(key.Range), KeyType: aws.String(dynamodb.KeyTypeRange)})
+		}
+
+		_, err := client.CreateTableWithContext(ctx, &dynamodb.CreateTableInput{
+			TableName:            aws.String(name),
+			AttributeDefinitions: attributes,
+			KeySchema:            schema,
+			BillingMode:          aws.String(dynamodb.BillingModePayPerRequest),
+		})
+		var aerr awserr.Error
+		if errors.As(err, &aerr) && aerr.Code() == dynamodb.ErrCodeResourceInUseException {
+			continue
+		}
+		if err != nil {
+			t.Fatalf("failed to create table %s: %v", name, err)
+		}
+	}
+}
+
+// containerLogs returns the end of what a container wrote, for failure reports
+func containerLogs(c testcontainers.Container) string {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	reader, err := c.Logs(ctx)
+	if err != nil {
+		return "failed to get logs: " + err.Error()
+	}
+	defer reader.Close()
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		return "failed to read logs: " + err.Error()
+	}
+	const tail = 8 << 10
+	if len(body) > tail {
+		body = body[len(body)-tail:]
+	}
+	return string(body)
+}
@@ -0,0 +1,395 @@
+//go:build integration
+
+// Package e2e runs the main business flow end to end. It starts throwaway
+// RabbitMQ and DynamoDB Local containers with testcontainers, runs
+// orden-compra and proveedor against them and publishes a StockBajo event
+// built from the golden fixture. It needs a docker daemon and go on the
+// PATH, and runs only with the integration build tag:
+//
+//	cd orden-compra && go test -tags integration ./e2e
+package e2e
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/google/uuid"
+	"github.com/rabbitmq/amqp091-go"
+
+	"medisupply/fixtures"
+	"orden-compra/internal/models"
+	"orden-compra/internal/tenant"
+)
+
+const (
+	// startupTimeout bounds building the services and starting everything
+	startupTimeout = 5 * time.Minute
+	// flowTimeout bounds each link of the flow
+	flowTimeout = time.Minute
+)
+
+// Event types the flow is expected to go through
+const (
+	purchaseOrderCreatedType = "PurchaseOrderCreated"
+	inventoryReceivedType    = "InventarioRecibido"
+)
+
+// TestStockBajoFlow follows a StockBajo event through both services: the
+// purchase order is persisted with its creation event, the reception is
+// published to proveedor, which records it, and passing its inspection has
+// proveedor log InventarioRecibido
+func TestStockBajoFlow(t *testing.T) {
+	env := startEnvironment(t)
+	receptions := watchReceptions(t, env.RabbitMQURL)
+
+	event := newStockLow(t)
+	publishStockLow(t, env.RabbitMQURL, event)
+
+	purchaseOrder := waitForPurchaseOrder(t, env.DynamoDB, event)
+	if purchaseOrder.Status == models.StatusPendingApproval {
+		t.Fatalf("purchase order %s is awaiting approval, so no reception is published", purchaseOrder.ID)
+	}
+	waitForEvent(t, env.DynamoDB, purchaseOrder.ID, purchaseOrderCreatedType)
+	waitForReception(t, receptions, purchaseOrder.ID)
+
+	proveedor := &client{URL: env.Proveedor.URL, TenantID: event.TenantID, HTTP: &http.Client{Timeout: 5 * time.Second}}
+	recepcionID := proveedor.waitForRecepcion(t, purchaseOrder.ID)
+	proveedor.inspect(t, recepcionID)
+	proveedor.waitForEvent(t, purchaseOrder.ID, inventoryReceivedType)
+}
+
+// stockLow is the StockBajo event the test publishes
+type stockLow struct {
+	EventID       string
+	CorrelationID string
+	TenantID      string
+	ProductID     string
+	Body          []byte
+}
+
+// newStockLow builds a StockBajo event from the current golden fixture, with
+// IDs and a product of its own
+func newStockLow(t *testing.T) *stockLow {
+	t.Helper()
+	fixture, err := fixtures.Latest(fixtures.StockBajo)
+	if err != nil {
+		t.Fatalf("fixtures: %v", err)
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(fixture.Body, &fields); err != nil {
+		t.Fatalf("invalid %s fixture: %v", fixture.Name(), err)
+	}
+
+	event := &stockLow{
+		EventID:       uuid.New().String(),
+		CorrelationID: uuid.New().String(),
+		ProductID:     "E2E-" + uuid.New().String()[:8],
+	}
+	event.TenantID, _ = fields["tenant_id"].(string)
+	fields["id"] = event.EventID
+	fields["product_id"] = event.ProductID
+	fields["timestamp"] = time.Now().UTC().Format(time.RFC3339)
+
+	if event.Body, err = json.Marshal(fields); err != nil {
+		t.Fatalf("failed to encode stock low event: %v", err)
+	}
+	return event
+}
+
+// publishStockLow publishes the event to orden-compra's StockBajo exchange the
+// way inventario does, and waits for the broker to confirm it
+func publishStockLow(t *testing.T, rabbitMQURL string, event *stockLow) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), flowTimeout)
+	defer cancel()
+
+	connection, err := amqp091.Dial(rabbitMQURL)
+	if err != nil {
+		t.Fatalf("failed to connect to RabbitMQ: %v", err)
+	}
+	defer connection.Close()
+	channel, err := connection.Channel()
+	if err != nil {
+		t.Fatalf("failed to open channel: %v", err)
+	}
+	defer channel.Close()
+
+	if err := channel.Confirm(false); err != nil {
+		t.Fatalf("failed to enable publisher confirms: %v", err)
+	}
+	confirmation, err := channel.PublishWithDeferredConfirmWithContext(ctx, "stock-bajo-exchange", "stock.bajo", true, false, amqp091.Publishing{
+		ContentType:  "application/json",
+		DeliveryMode: amqp091.Persistent,
+		MessageId:    event.EventID,
+		AppId:        "medisupply-e2e",
+		Timestamp:    time.Now(),
+		Headers: amqp091.Table{
+			"correlation-id":     event.CorrelationID,
+			"event-type":         fixtures.StockBajo,
+			tenant.MessageHeader: event.TenantID,
+		},
+		Body: event.Body,
+	})
+	if err != nil {
+		t.Fatalf("failed to publish stock low event: %v", err)
+	}
+	acked, err := confirmation.WaitContext(ctx)
+	if err != nil {
+		t.Fatalf("failed to wait for publisher confirm: %v", err)
+	}
+	if !acked {
+		t.Fatalf("broker nacked the stock low event")
+	}
+}
+
+// watchReceptions binds a queue of the test's own to the reception exchange,
+// so it sees every RecepcionProveedor orden-compra publishes
+func watchReceptions(t *testing.T, rabbitMQURL string) <-chan amqp091.Delivery {
+	t.Helper()
+	connection, err := amqp091.Dial(rabbitMQURL)
+	if err != nil {
+		t.Fatalf("failed to connect to RabbitMQ: %v", err)
+	}
+	t.Cleanup(func() { connection.Close() })
+	channel, err := connection.Channel()
+	if err != nil {
+		t.Fatalf("failed to open channel: %v", err)
+	}
+
+	queue, err := channel.QueueDeclare("", false, true, true, false, nil)
+	if err != nil {
+		t.Fatalf("failed to declare reception queue: %v", err)
+	}
+	if err := channel.QueueBind(queue.Name, "recepcion.proveedor", "recepcion-proveedor-exchange", false, nil); err != nil {
+		t.Fatalf("failed to bind reception queue: %v", err)
+	}
+	deliveries, err := channel.Consume(queue.Name, "", true, true, false, false, nil)
+	if err != nil {
+		t.Fatalf("failed to consume receptions: %v", err)
+	}
+	return deliveries
+}
+
+// waitForPurchaseOrder waits until orden-compra persisted the one purchase
+// order the StockBajo event asked for
+func waitForPurchaseOrder(t *testing.T, client *dynamodb.DynamoDB, event *stockLow) *models.PurchaseOrder {
+	t.Helper()
+	var purchaseOrder models.PurchaseOrder
+	eventually(t, flowTimeout, "purchase order persisted", func(ctx context.Context) error {
+		items, err := scan(ctx, client, &dynamodb.ScanInput{
+			TableName:                 aws.String("orden-compra-read"),
+			FilterExpression:          aws.String("product_id = :product"),
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":product": {S: aws.String(event.ProductID)}},
+		})
+		if err != nil {
+			return err
+		}
+		if len(items) != 1 {
+			return fmt.Errorf("%d purchase orders for product %s, want one", len(items), event.ProductID)
+		}
+		return dynamodbattribute.UnmarshalMap(items[0], &purchaseOrder)
+	})
+	if purchaseOrder.Quantity <= 0 {
+		t.Fatalf("purchase order %s orders %d units", purchaseOrder.ID, purchaseOrder.Quantity)
+	}
+	return &purchaseOrder
+}
+
+// waitForEvent waits until orden-compra stored an event of a type for the order
+func waitForEvent(t *testing.T, client *dynamodb.DynamoDB, purchaseOrderID, eventType string) {
+	t.Helper()
+	eventually(t, flowTimeout, eventType+" event stored", func(ctx context.Context) error {
+		items, err := scan(ctx, client, &dynamodb.ScanInput{
+			TableName:        aws.String("orden-compra-events"),
+			FilterExpression: aws.String("aggregate_id = :id AND event_type = :type"),
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+				":id":   {S: aws.String(purchaseOrderID)},
+				":type": {S: aws.String(eventType)},
+			},
+		})
+		if err != nil {
+			return err
+		}
+		if len(items) == 0 {
+			return fmt.Errorf("no %s event for purchase order %s", eventType, purchaseOrderID)
+		}
+		return nil
+	})
+}
+
+// waitForReception waits for the RecepcionProveedor of the order on the
+// test's reception queue
+func waitForReception(t *testing.T, deliveries <-chan amqp091.Delivery, purchaseOrderID string) {
+	t.Helper()
+	timeout := time.After(flowTimeout)
+	for {
+		select {
+		case delivery, ok := <-deliveries:
+			if !ok {
+				t.Fatalf("reception queue closed before the reception of %s was published", purchaseOrderID)
+			}
+			var event models.RecepcionProveedorEvent
+			if err := json.Unmarshal(delivery.Body, &event); err != nil {
+				t.Fatalf("undecodable reception %s: %v", delivery.MessageId, err)
+			}
+			if event.PurchaseOrderID == purchaseOrderID {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("reception published: no RecepcionProveedor for purchase order %s within %s", purchaseOrderID, flowTimeout)
+		}
+	}
+}
+
+// scan reads every item of a scan
+func scan(ctx context.Context, client *dynamodb.DynamoDB, input *dynamodb.ScanInput) ([]map[string]*dynamodb.AttributeValue, error) {
+	var items []map[string]*dynamodb.AttributeValue
+	err := client.ScanPagesWithContext(ctx, input, func(page *dynamodb.ScanOutput, last bool) bool {
+		items = append(items, page.Items...)
+		return true
+	})
+	return items, err
+}
+
+// client calls proveedor's HTTP API as the event's tenant
+type client struct {
+	URL      string
+	TenantID string
+	HTTP     *http.Client
+}
+
+// waitForRecepcion waits until proveedor recorded the one reception of the
+// order and returns its ID
+func (c *client) waitForRecepcion(t *testing.T, purchaseOrderID string) string {
+	t.Helper()
+	var recepcionID string
+	eventually(t, flowTimeout, "reception processed by proveedor", func(ctx context.Context) error {
+		var result struct {
+			Receipt struct {
+				Recepciones []struct {
+					ID string `json:"id"`
+				} `json:"recepciones"`
+			} `json:"receipt"`
+		}
+		if err := c.get(ctx, "/purchase-orders/"+url.PathEscape(purchaseOrderID)+"/receipt", &result); err != nil {
+			return err
+		}
+		if len(result.Receipt.Recepciones) != 1 {
+			return fmt.Errorf("purchase order %s has %d receptions, want one", purchaseOrderID, len(result.Receipt.Recepciones))
+		}
+		recepcionID = result.Receipt.Recepciones[0].ID
+		return nil
+	})
+	return recepcionID
+}
+
+// inspect passes the reception's quality inspection
+func (c *client) inspect(t *testing.T, recepcionID string) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), flowTimeout)
+	defer cancel()
+
+	inspection := map[string]string{"result": "passed", "inspector_id": "medisupply-e2e", "notes": "end-to-end run"}
+	if err := c.post(ctx, "/recepciones/"+url.PathEscape(recepcionID)+"/inspections", inspection); err != nil {
+		t.Fatalf("failed to inspect recepcion %s: %v", recepcionID, err)
+	}
+}
+
+// waitForEvent waits until proveedor logged an event of a type for the order
+func (c *client) waitForEvent(t *testing.T, purchaseOrderID, eventType string) {
+	t.Helper()
+	eventually(t, flowTimeout, eventType+" logged by proveedor", func(ctx context.Context) error {
+		var result struct {
+			Events []struct {
+				EventType string `json:"event_type"`
+			} `json:"events"`
+		}
+		if err := c.get(ctx, "/purchase-orders/"+url.PathEscape(purchaseOrderID)+"/events", &result); err != nil {
+			return err
+		}
+		for _, event := range result.Events {
+			if event.EventType == eventType {
+				return nil
+			}
+		}
+		return fmt.Errorf("proveedor logged no %s event for purchase order %s", eventType, purchaseOrderID)
+	})
+}
+
+// get decodes the JSON response of a GET request
+func (c *client) get(ctx context.Context, path string, result interface{}) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, c.URL+path, nil)
+	if err != nil {
+		return err
+	}
+	return c.do(request, result)
+}
+
+// post sends a JSON body and discards the response
+func (c *client) post(ctx context.Context, path string, body interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL+path, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	return c.do(request, nil)
+}
+
+// do sends a request as the event's tenant and fails on any status but 200
+func (c *client) do(request *http.Request, result interface{}) error {
+	request.Header.Set(tenant.Header, c.TenantID)
+	response, err := c.HTTP.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(response.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("failed to read response of %s %s: %w", request.Method, request.URL.Path, err)
+	}
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s %s returned status %d: %s", request.Method, request.URL.Path, response.StatusCode, body)
+	}
+	if result == nil {
+		return nil
+	}
+	if err := json.Unmarshal(body, result); err != nil {
+		return fmt.Errorf("failed to decode response of %s %s: %w", request.Method, request.URL.Path, err)
+	}
+	return nil
+}
+
+// eventually calls check until it succeeds, failing the test with what did
+// not happen and the last error once timeout passes
+func eventually(t *testing.T, timeout time.Duration, what string, check func(context.Context) error) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var last error
+	for {
+		if last = check(ctx); last == nil {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			t.Fatalf("%s: not within %s: %v", what, timeout, last)
+		case <-time.After(time.Second):
+		}
+	}
+}
@@ -0,0 +1,145 @@
+//go:build integration
+
+package e2e
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// service is a service binary running on the host for one test
+type service struct {
+	Name    string
+	URL     string
+	cmd     *exec.Cmd
+	logPath string
+	exited  chan error
+}
+
+// buildService compiles a service's ./cmd into dir, so the process started
+// is the service itself rather than go run, and stopping it stops the service
+func buildService(t *testing.T, ctx context.Context, name, sourceDir, dir string) string {
+	t.Helper()
+	binary := filepath.Join(dir, name)
+	cmd := exec.CommandContext(ctx, "go", "build", "-o", binary, "./cmd")
+	cmd.Dir = sourceDir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("failed to build %s: %v\n%s", name, err, out)
+	}
+	return binary
+}
+
+// startService runs a service binary with the given settings on top of the
+// test environment, writing its output to a log file in dir. The service is
+// stopped when the test ends.
+func startService(t *testing.T, name, binary, dir string, settings map[string]string) *service {
+	t.Helper()
+	port := freePort(t)
+	settings["SERVICE_PORT"] = strconv.Itoa(port)
+
+	logPath := filepath.Join(dir, name+".log")
+	logFile, err := os.Create(logPath)
+	if err != nil {
+		t.Fatalf("failed to create %s log: %v", name, err)
+	}
+
+	cmd := exec.Command(binary)
+	cmd.Dir = dir
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	cmd.Env = os.Environ()
+	for key, value := range settings {
+		cmd.Env = append(cmd.Env, key+"="+value)
+	}
+	if err := cmd.Start(); err != nil {
+		logFile.Close()
+		t.Fatalf("failed to start %s: %v", name, err)
+	}
+
+	s := &service{
+		Name:    name,
+		URL:     "http://127.0.0.1:" + strconv.Itoa(port),
+		cmd:     cmd,
+		logPath: logPath,
+		exited:  make(chan error, 1),
+	}
+	go func() {
+		s.exited <- cmd.Wait()
+		logFile.Close()
+	}()
+	t.Cleanup(s.Stop)
+	return s
+}
+
+// WaitHealthy waits until the service answers its health check
+func (s *service) WaitHealthy(t *testing.T) {
+	t.Helper()
+	client := &http.Client{Timeout: 2 * time.Second}
+	eventually(t, startupTimeout, s.Name+" health", func(ctx context.Context) error {
+		select {
+		case err := <-s.exited:
+			s.exited <- err
+			return fmt.Errorf("%s exited: %v", s.Name, err)
+		default:
+		}
+
+		request, err := http.NewRequestWithContext(ctx, http.MethodGet, s.URL+"/health", nil)
+		if err != nil {
+			return err
+		}
+		response, err := client.Do(request)
+		if err != nil {
+			return err
+		}
+		response.Body.Close()
+		if response.StatusCode != http.StatusOK {
+			return fmt.Errorf("health check returned status %d", response.StatusCode)
+		}
+		return nil
+	})
+}
+
+// Stop interrupts the service and kills it if it does not shut down in time
+func (s *service) Stop() {
+	if err := s.cmd.Process.Signal(os.Interrupt); err != nil {
+		s.cmd.Process.Kill()
+	}
+	select {
+	case err := <-s.exited:
+		s.exited <- err
+	case <-time.After(15 * time.Second):
+		s.cmd.Process.Kill()
+	}
+}
+
+// Logs returns the end of what the service wrote, for failure reports
+func (s *service) Logs() string {
+	body, err := os.ReadFile(s.logPath)
+	if err != nil {
+		return fmt.Sprintf("failed to read log: %v", err)
+	}
+	const tail = 8 << 10
+	if len(body) > tail {
+		body = body[len(body)-tail:]
+	}
+	return string(body)
+}
+
+// freePort asks the kernel for a port nothing listens on
+func freePort(t *testing.T) int {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to find a free port: %v", err)
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port
+}
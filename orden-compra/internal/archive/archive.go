@@ -0,0 +1,379 @@
+// Package archive moves events past a retention age out of the event store
+// into S3 as gzipped JSON Lines, one object per event day and run, and
+// restores archived events to the event store for replay.
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+
+	"orden-compra/internal/models"
+	"orden-compra/internal/tenant"
+)
+
+const eventsTable = "orden-compra-events"
+
+// RestoredAtAttribute marks restored events, which the archiver skips and
+// DynamoDB TTL deletes once their expires_at has passed
+const RestoredAtAttribute = "restored_at"
+
+// dayLayout names the per-day object prefixes, e.g. dt=2026-01-31
+const dayLayout = "2006-01-02"
+
+// maxRestoreDays bounds the range of a single restore
+const maxRestoreDays = 92
+
+// batchWriteSize is the maximum number of requests in a DynamoDB batch write
+const batchWriteSize = 25
+
+var (
+	// ErrNotConfigured is returned when no archive bucket is configured
+	ErrNotConfigured = errors.New("event archive is not configured")
+	// ErrInvalidRange is returned for restore ranges that are empty or too long
+	ErrInvalidRange = errors.New("invalid restore range")
+)
+
+// Config represents the settings of the event archive
+type Config struct {
+	Bucket     string
+	Prefix     string
+	MaxAge     time.Duration
+	Interval   time.Duration
+	RestoreTTL time.Duration
+}
+
+// Archiver archives old events to S3 and restores them. A nil archiver means
+// archival is disabled; Restore returns ErrNotConfigured.
+type Archiver struct {
+	DynamoDB dynamodbiface.DynamoDBAPI
+	S3       s3iface.S3API
+	Config   Config
+	Logger   *log.Logger
+}
+
+// NewArchiver creates a new archiver
+func NewArchiver(dynamoDB dynamodbiface.DynamoDBAPI, s3Client s3iface.S3API, config Config, logger *log.Logger) *Archiver {
+	config.Prefix = strings.Trim(config.Prefix, "/")
+	return &Archiver{
+		DynamoDB: dynamoDB,
+		S3:       s3Client,
+		Config:   config,
+		Logger:   logger,
+	}
+}
+
+// Start archives old events every interval until the context is cancelled
+func (a *Archiver) Start(ctx context.Context) {
+	a.Logger.Printf("Starting event archiver - bucket: %s, max age: %v, interval: %v", a.Config.Bucket, a.Config.MaxAge, a.Config.Interval)
+
+	ticker := time.NewTicker(a.Config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			a.Logger.Println("Event archiver stopped")
+			return
+		case <-ticker.C:
+			if _, err := a.RunOnce(ctx); err != nil {
+				a.Logger.Printf("Event archival failed: %v", err)
+			}
+		}
+	}
+}
+
+// dayPrefix returns the object prefix of an event day
+func (a *Archiver) dayPrefix(day string) string {
+	return a.Config.Prefix + "/dt=" + day + "/"
+}
+
+// RunOnce archives and deletes every event older than the maximum age. Each
+// scan page is written to S3 before its events are deleted, so a failed run
+// leaves events in place and the next run archives them again; restores
+// tolerate the duplicates. It must run without a tenant in the context.
+func (a *Archiver) RunOnce(ctx context.Context) (map[string]interface{}, error) {
+	cutoff := time.Now().UTC().Add(-a.Config.MaxAge)
+	runID := time.Now().UTC().Format("20060102T150405Z")
+
+	scanInput := &dynamodb.ScanInput{
+		TableName:                aws.String(eventsTable),
+		FilterExpression:         aws.String("#timestamp < :cutoff AND attribute_not_exists(#restored)"),
+		ExpressionAttributeNames: map[string]*string{"#timestamp": aws.String("timestamp"), "#restored": aws.String(RestoredAtAttribute)},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":cutoff": {S: aws.String(cutoff.Format(time.RFC3339Nano))},
+		},
+	}
+
+	archived, objects := 0, 0
+	for page := 0; ; page++ {
+		result, err := a.DynamoDB.ScanWithContext(ctx, scanInput)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan events: %w", err)
+		}
+
+		if len(result.Items) > 0 {
+			written, err := a.archivePage(ctx, runID, page, result.Items)
+			if err != nil {
+				return nil, err
+			}
+			if err := a.deleteEvents(ctx, result.Items); err != nil {
+				return nil, err
+			}
+			archived += len(result.Items)
+			objects += written
+		}
+
+		if len(result.LastEvaluatedKey) == 0 {
+			break
+		}
+		scanInput.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+
+	if archived > 0 {
+		a.Logger.Printf("Events archived - events: %d, objects: %d, older than: %s", archived, objects, cutoff.Format(time.RFC3339))
+	}
+
+	return map[string]interface{}{
+		"success":  true,
+		"archived": archived,
+		"objects":  objects,
+		"cutoff":   cutoff,
+	}, nil
+}
+
+// archivePage writes the events of a scan page to one object per event day
+// and returns the number of objects written
+func (a *Archiver) archivePage(ctx context.Context, runID string, page int, items []map[string]*dynamodb.AttributeValue) (int, error) {
+	days := make(map[string][]map[string]*dynamodb.AttributeValue)
+	for _, item := range items {
+		day := "unknown"
+		if value, ok := item["timestamp"]; ok && value.S != nil && len(*value.S) >= len(dayLayout) {
+			day = (*value.S)[:len(dayLayout)]
+		}
+		days[day] = append(days[day], item)
+	}
+
+	for day, events := range days {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		encoder := json.NewEncoder(zw)
+		for _, item := range events {
+			var event map[string]interface{}
+			if err := dynamodbattribute.UnmarshalMap(item, &event); err != nil {
+				return 0, fmt.Errorf("failed to unmarshal event: %w", err)
+			}
+			if err := encoder.Encode(event); err != nil {
+				return 0, fmt.Errorf("failed to encode event: %w", err)
+			}
+		}
+		if err := zw.Close(); err != nil {
+			return 0, fmt.Errorf("failed to compress events: %w", err)
+		}
+
+		key := fmt.Sprintf("%s%s-%05d.jsonl.gz", a.dayPrefix(day), runID, page)
+		_, err := a.S3.PutObjectWithContext(ctx, &s3.PutObjectInput{
+			Bucket:          aws.String(a.Config.Bucket),
+			Key:             aws.String(key),
+			Body:            bytes.NewReader(buf.Bytes()),
+			ContentType:     aws.String("application/x-ndjson"),
+			ContentEncoding: aws.String("gzip"),
+		})
+		if err != nil {
+			return 0, fmt.Errorf("failed to upload %s: %w", key, err)
+		}
+	}
+	return len(days), nil
+}
+
+// deleteEvents deletes archived events in batches, retrying unprocessed ones
+func (a *Archiver) deleteEvents(ctx context.Context, items []map[string]*dynamodb.AttributeValue) error {
+	requests := make([]*dynamodb.WriteRequest, 0, len(items))
+	for _, item := range items {
+		requests = append(requests, &dynamodb.WriteRequest{
+			DeleteRequest: &dynamodb.DeleteRequest{
+				Key: map[string]*dynamodb.AttributeValue{
+					"id":        item["id"],
+					"timestamp": item["timestamp"],
+				},
+			},
+		})
+	}
+
+	for len(requests) > 0 {
+		n := batchWriteSize
+		if len(requests) < n {
+			n = len(requests)
+		}
+		batch := requests[:n]
+		requests = requests[n:]
+
+		for attempt := 0; len(batch) > 0; attempt++ {
+			if attempt > 0 {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(time.Duration(attempt) * 100 * time.Millisecond):
+				}
+			}
+			if attempt == 5 {
+				return fmt.Errorf("failed to delete %d archived events: unprocessed after %d attempts", len(batch), attempt)
+			}
+
+			result, err := a.DynamoDB.BatchWriteItemWithContext(ctx, &dynamodb.BatchWriteItemInput{
+				RequestItems: map[string][]*dynamodb.WriteRequest{eventsTable: batch},
+			})
+			if err != nil {
+				return fmt.Errorf("failed to delete archived events: %w", err)
+			}
+			batch = result.UnprocessedItems[eventsTable]
+		}
+	}
+	return nil
+}
+
+// Restore puts the archived events of the range back into the event store,
+// marked as restored so they are not archived again and expiring after the
+// restore TTL. In a tenant context only that tenant's events are restored.
+func (a *Archiver) Restore(ctx context.Context, from, to time.Time) (map[string]interface{}, error) {
+	if a == nil {
+		return nil, ErrNotConfigured
+	}
+
+	events, objects, err := a.Read(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	restoredAt := time.Now().UTC()
+	expiresAt := strconv.FormatInt(restoredAt.Add(a.Config.RestoreTTL).Unix(), 10)
+
+	for _, event := range events {
+		item, err := dynamodbattribute.MarshalMap(event)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal event %v: %w", event["id"], err)
+		}
+		item[RestoredAtAttribute] = &dynamodb.AttributeValue{S: aws.String(restoredAt.Format(time.RFC3339))}
+		item[models.ExpiresAtAttribute] = &dynamodb.AttributeValue{N: aws.String(expiresAt)}
+
+		if _, err := a.DynamoDB.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+			TableName: aws.String(eventsTable),
+			Item:      item,
+		}); err != nil {
+			return nil, fmt.Errorf("failed to restore event: %w", err)
+		}
+	}
+
+	a.Logger.Printf("Events restored - events: %d, objects: %d, from: %s, to: %s", len(events), objects, from.Format(time.RFC3339), to.Format(time.RFC3339))
+
+	return map[string]interface{}{
+		"success":    true,
+		"restored":   len(events),
+		"objects":    objects,
+		"expires_at": restoredAt.Add(a.Config.RestoreTTL),
+	}, nil
+}
+
+// Read returns the archived events stored between from and to, in the order
+// they were archived, and the number of objects read. In a tenant context
+// only that tenant's events are returned. Events archived by a failed run
+// and again by the next one are returned twice.
+func (a *Archiver) Read(ctx context.Context, from, to time.Time) ([]map[string]interface{}, int, error) {
+	if a == nil {
+		return nil, 0, ErrNotConfigured
+	}
+	if to.Before(from) {
+		return nil, 0, fmt.Errorf("%w: range ends before it starts", ErrInvalidRange)
+	}
+	if to.Sub(from) > maxRestoreDays*24*time.Hour {
+		return nil, 0, fmt.Errorf("%w: range spans more than %d days", ErrInvalidRange, maxRestoreDays)
+	}
+
+	tenantID, scoped := tenant.FromContext(ctx)
+
+	var keys []string
+	for day := from.UTC().Truncate(24 * time.Hour); !day.After(to); day = day.AddDate(0, 0, 1) {
+		err := a.S3.ListObjectsV2PagesWithContext(ctx, &s3.ListObjectsV2Input{
+			Bucket: aws.String(a.Config.Bucket),
+			Prefix: aws.String(a.dayPrefix(day.Format(dayLayout))),
+		}, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+			for _, object := range page.Contents {
+				keys = append(keys, aws.StringValue(object.Key))
+			}
+			return true
+		})
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to list archived events: %w", err)
+		}
+	}
+	sort.Strings(keys)
+
+	var events []map[string]interface{}
+	for _, key := range keys {
+		objectEvents, err := a.readObject(ctx, key)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		for _, event := range objectEvents {
+			timestamp, _ := time.Parse(time.RFC3339Nano, fmt.Sprint(event["timestamp"]))
+			if timestamp.Before(from) || timestamp.After(to) {
+				continue
+			}
+			if scoped && fmt.Sprint(event[tenant.Attribute]) != tenantID {
+				continue
+			}
+			events = append(events, event)
+		}
+	}
+	return events, len(keys), nil
+}
+
+// readObject downloads and decodes an archive object
+func (a *Archiver) readObject(ctx context.Context, key string) ([]map[string]interface{}, error) {
+	result, err := a.S3.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(a.Config.Bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", key, err)
+	}
+	defer result.Body.Close()
+
+	zr, err := gzip.NewReader(result.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress %s: %w", key, err)
+	}
+
+	var events []map[string]interface{}
+	scanner := bufio.NewScanner(zr)
+	// DynamoDB items are at most 400 KB; JSON adds some overhead
+	scanner.Buffer(make([]byte, 64*1024), 2<<20)
+	for scanner.Scan() {
+		var event map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return nil, fmt.Errorf("failed to decode event in %s: %w", key, err)
+		}
+		events = append(events, event)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", key, err)
+	}
+	return events, nil
+}
@@ -0,0 +1,165 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+
+	"orden-compra/internal/memory"
+	"orden-compra/internal/models"
+	"orden-compra/internal/tenant"
+)
+
+// bucket is an in-memory S3 bucket supporting the calls the archiver makes
+type bucket struct {
+	s3iface.S3API
+	objects map[string][]byte
+}
+
+func (b *bucket) PutObjectWithContext(ctx aws.Context, input *s3.PutObjectInput, opts ...request.Option) (*s3.PutObjectOutput, error) {
+	body, err := io.ReadAll(input.Body)
+	if err != nil {
+		return nil, err
+	}
+	b.objects[aws.StringValue(input.Key)] = body
+	return &s3.PutObjectOutput{}, nil
+}
+
+func (b *bucket) GetObjectWithContext(ctx aws.Context, input *s3.GetObjectInput, opts ...request.Option) (*s3.GetObjectOutput, error) {
+	body, ok := b.objects[aws.StringValue(input.Key)]
+	if !ok {
+		return nil, errors.New("no such key")
+	}
+	return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(body))}, nil
+}
+
+func (b *bucket) ListObjectsV2PagesWithContext(ctx aws.Context, input *s3.ListObjectsV2Input, fn func(*s3.ListObjectsV2Output, bool) bool, opts ...request.Option) error {
+	var keys []string
+	for key := range b.objects {
+		if strings.HasPrefix(key, aws.StringValue(input.Prefix)) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	page := &s3.ListObjectsV2Output{}
+	for _, key := range keys {
+		page.Contents = append(page.Contents, &s3.Object{Key: aws.String(key)})
+	}
+	fn(page, true)
+	return nil
+}
+
+func putEvent(t *testing.T, dynamoDB *memory.DynamoDB, aggregateID, tenantID string, at time.Time) *models.EventSourcingEvent {
+	t.Helper()
+	event := models.NewEventSourcingEvent(aggregateID, "PurchaseOrderCreated", map[string]interface{}{"quantity": 10}, nil, nil, at)
+	event.TenantID = tenantID
+	item, err := dynamodbattribute.MarshalMap(event)
+	if err != nil {
+		t.Fatalf("marshal event: %v", err)
+	}
+	if _, err := dynamoDB.PutItemWithContext(context.Background(), &dynamodb.PutItemInput{TableName: aws.String(eventsTable), Item: item}); err != nil {
+		t.Fatalf("put event: %v", err)
+	}
+	return event
+}
+
+func newTestArchiver() (*Archiver, *memory.DynamoDB, *bucket) {
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	objects := &bucket{objects: map[string][]byte{}}
+	archiver := NewArchiver(dynamoDB, objects, Config{Bucket: "events", Prefix: "/orden-compra/", MaxAge: 90 * 24 * time.Hour, RestoreTTL: 24 * time.Hour}, log.New(io.Discard, "", 0))
+	return archiver, dynamoDB, objects
+}
+
+func TestArchiveAndRestore(t *testing.T) {
+	ctx := context.Background()
+	archiver, dynamoDB, objects := newTestArchiver()
+	old := time.Now().UTC().AddDate(0, 0, -120).Truncate(24 * time.Hour).Add(12 * time.Hour)
+
+	putEvent(t, dynamoDB, "po-1", "tenant-1", old)
+	putEvent(t, dynamoDB, "po-2", "tenant-2", old.Add(time.Hour))
+	putEvent(t, dynamoDB, "po-1", "tenant-1", old.AddDate(0, 0, 1))
+	recent := putEvent(t, dynamoDB, "po-3", "tenant-1", time.Now().UTC())
+
+	result, err := archiver.RunOnce(ctx)
+	if err != nil || result["archived"] != 3 || result["objects"] != 2 {
+		t.Fatalf("archived %v, error %v", result, err)
+	}
+	// One object per event day under the trimmed prefix
+	for key := range objects.objects {
+		if !strings.HasPrefix(key, "orden-compra/dt=") || !strings.HasSuffix(key, ".jsonl.gz") {
+			t.Fatalf("object key %q", key)
+		}
+	}
+	if events := dynamoDB.Items(eventsTable); len(events) != 1 || aws.StringValue(events[0]["id"].S) != recent.ID {
+		t.Fatalf("event store kept %v, want only the recent event", events)
+	}
+
+	// A tenant only reads back its own events
+	events, read, err := archiver.Read(tenant.NewContext(ctx, "tenant-1"), old.Add(-time.Hour), old.AddDate(0, 0, 2))
+	if err != nil || read != 2 || len(events) != 2 {
+		t.Fatalf("read %d events from %d objects, error %v", len(events), read, err)
+	}
+	for _, event := range events {
+		if event["tenant_id"] != "tenant-1" {
+			t.Fatalf("read an event of %v", event["tenant_id"])
+		}
+	}
+
+	result, err = archiver.Restore(ctx, old.Add(-time.Hour), old.Add(2*time.Hour))
+	if err != nil || result["restored"] != 2 {
+		t.Fatalf("restored %v, error %v", result, err)
+	}
+	restored := 0
+	for _, event := range dynamoDB.Items(eventsTable) {
+		if event[RestoredAtAttribute] != nil {
+			restored++
+			if event[models.ExpiresAtAttribute] == nil || event["event_data"] == nil {
+				t.Fatalf("restored event %v", event)
+			}
+		}
+	}
+	if restored != 2 {
+		t.Fatalf("event store holds %d restored events, want 2", restored)
+	}
+
+	// Restored events are not archived again
+	if result, err := archiver.RunOnce(ctx); err != nil || result["archived"] != 0 {
+		t.Fatalf("archived %v, error %v", result, err)
+	}
+}
+
+func TestRestoreRejectsInvalidRanges(t *testing.T) {
+	archiver, _, _ := newTestArchiver()
+	now := time.Now()
+	for _, tc := range []struct {
+		name     string
+		from, to time.Time
+	}{
+		{"ends before it starts", now, now.Add(-time.Hour)},
+		{"too long", now.AddDate(0, 0, -maxRestoreDays-1), now},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := archiver.Restore(context.Background(), tc.from, tc.to); !errors.Is(err, ErrInvalidRange) {
+				t.Fatalf("Restore returned %v", err)
+			}
+		})
+	}
+
+	var disabled *Archiver
+	if _, err := disabled.Restore(context.Background(), now.Add(-time.Hour), now); !errors.Is(err, ErrNotConfigured) {
+		t.Fatalf("disabled archiver returned %v", err)
+	}
+}
@@ -0,0 +1,109 @@
+// Package attachments hands out presigned S3 URLs for the files buyers
+// attach to purchase orders, so the files go straight between the client
+// and the bucket and never through the service.
+package attachments
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+
+	"orden-compra/internal/models"
+)
+
+// ErrNotConfigured is returned when no attachments bucket is configured
+var ErrNotConfigured = errors.New("purchase order attachments are not configured")
+
+// Config represents the settings of the attachment store
+type Config struct {
+	Bucket string
+	Prefix string
+	// URLTTL is how long a presigned upload or download URL stays valid
+	URLTTL time.Duration
+}
+
+// Store presigns uploads and downloads of purchase order attachments. A nil
+// store means attachments are disabled; its methods return ErrNotConfigured.
+type Store struct {
+	S3     s3iface.S3API
+	Config Config
+}
+
+// NewStore creates a new attachment store
+func NewStore(s3Client s3iface.S3API, config Config) *Store {
+	config.Prefix = strings.Trim(config.Prefix, "/")
+	return &Store{
+		S3:     s3Client,
+		Config: config,
+	}
+}
+
+// PresignedURL is a URL a client uses to upload or download one attachment
+type PresignedURL struct {
+	URL       string    `json:"url"`
+	Method    string    `json:"method"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Key returns the object key of an attachment of a purchase order, e.g.
+// orden-compra/attachments/<tenant>/<purchase order>/<attachment>/<file name>
+func (s *Store) Key(tenantID, purchaseOrderID string, attachment *models.OrderAttachment) string {
+	if tenantID == "" {
+		tenantID = "default"
+	}
+	key := fmt.Sprintf("%s/%s/%s/%s", tenantID, purchaseOrderID, attachment.ID, attachment.FileName)
+	if s.Config.Prefix != "" {
+		key = s.Config.Prefix + "/" + key
+	}
+	return key
+}
+
+// PresignUpload returns a URL the client PUTs the attachment's file to. The
+// URL is signed for the declared content type and length, so the upload must
+// send the same Content-Type and Content-Length.
+func (s *Store) PresignUpload(attachment *models.OrderAttachment) (*PresignedURL, error) {
+	if s == nil {
+		return nil, ErrNotConfigured
+	}
+
+	req, _ := s.S3.PutObjectRequest(&s3.PutObjectInput{
+		Bucket:        aws.String(s.Config.Bucket),
+		Key:           aws.String(attachment.Key),
+		ContentType:   aws.String(attachment.ContentType),
+		ContentLength: aws.Int64(attachment.Size),
+	})
+	return s.presign(req.Presign, "PUT")
+}
+
+// PresignDownload returns a URL the client GETs the attachment's file from
+func (s *Store) PresignDownload(attachment *models.OrderAttachment) (*PresignedURL, error) {
+	if s == nil {
+		return nil, ErrNotConfigured
+	}
+
+	req, _ := s.S3.GetObjectRequest(&s3.GetObjectInput{
+		Bucket:                     aws.String(s.Config.Bucket),
+		Key:                        aws.String(attachment.Key),
+		ResponseContentDisposition: aws.String(fmt.Sprintf("attachment; filename=%q", attachment.FileName)),
+	})
+	return s.presign(req.Presign, "GET")
+}
+
+// presign signs a request for the configured URL TTL
+func (s *Store) presign(sign func(time.Duration) (string, error), method string) (*PresignedURL, error) {
+	expiresAt := time.Now().UTC().Add(s.Config.URLTTL)
+	url, err := sign(s.Config.URLTTL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to presign %s: %w", method, err)
+	}
+	return &PresignedURL{
+		URL:       url,
+		Method:    method,
+		ExpiresAt: expiresAt,
+	}, nil
+}
@@ -0,0 +1,77 @@
+package attachments
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+
+	"orden-compra/internal/models"
+)
+
+func newTestStore(t *testing.T, prefix string) *Store {
+	t.Helper()
+	sess, err := session.NewSession(&aws.Config{
+		Region:           aws.String("us-east-1"),
+		Endpoint:         aws.String("http://s3.local"),
+		S3ForcePathStyle: aws.Bool(true),
+		Credentials:      credentials.NewStaticCredentials("key", "secret", ""),
+	})
+	if err != nil {
+		t.Fatalf("session: %v", err)
+	}
+	return NewStore(s3.New(sess), Config{Bucket: "attachments", Prefix: prefix, URLTTL: 15 * time.Minute})
+}
+
+func TestKey(t *testing.T) {
+	attachment := &models.OrderAttachment{ID: "attachment-1", FileName: "invoice.pdf"}
+	for _, tc := range []struct {
+		prefix, tenantID, want string
+	}{
+		{"/orden-compra/attachments/", "tenant-1", "orden-compra/attachments/tenant-1/po-1/attachment-1/invoice.pdf"},
+		{"", "", "default/po-1/attachment-1/invoice.pdf"},
+	} {
+		if key := newTestStore(t, tc.prefix).Key(tc.tenantID, "po-1", attachment); key != tc.want {
+			t.Errorf("Key() = %q, want %q", key, tc.want)
+		}
+	}
+}
+
+func TestPresign(t *testing.T) {
+	store := newTestStore(t, "")
+	attachment := &models.OrderAttachment{FileName: "invoice.pdf", ContentType: "application/pdf", Size: 1024, Key: "default/po-1/attachment-1/invoice.pdf"}
+
+	upload, err := store.PresignUpload(attachment)
+	if err != nil || upload.Method != "PUT" || !strings.HasPrefix(upload.URL, "http://s3.local/attachments/default/po-1/attachment-1/invoice.pdf?") {
+		t.Fatalf("upload %+v, error %v", upload, err)
+	}
+	if until := time.Until(upload.ExpiresAt); until <= 14*time.Minute || until > 15*time.Minute {
+		t.Fatalf("upload expires in %v, want the URL TTL", until)
+	}
+	// The declared content type is signed
+	if query, _ := url.Parse(upload.URL); !strings.Contains(query.Query().Get("X-Amz-SignedHeaders"), "content-type") {
+		t.Fatalf("upload URL %s does not sign the content type", upload.URL)
+	}
+
+	download, err := store.PresignDownload(attachment)
+	if err != nil || download.Method != "GET" {
+		t.Fatalf("download %+v, error %v", download, err)
+	}
+	if query, _ := url.Parse(download.URL); query.Query().Get("response-content-disposition") != `attachment; filename="invoice.pdf"` {
+		t.Fatalf("download URL %s", download.URL)
+	}
+
+	var disabled *Store
+	if _, err := disabled.PresignUpload(attachment); !errors.Is(err, ErrNotConfigured) {
+		t.Fatalf("disabled store returned %v", err)
+	}
+	if _, err := disabled.PresignDownload(attachment); !errors.Is(err, ErrNotConfigured) {
+		t.Fatalf("disabled store returned %v", err)
+	}
+}
@@ -0,0 +1,72 @@
+package audit
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+
+	"medisupply/correlation"
+	"orden-compra/internal/auth"
+	"orden-compra/internal/models"
+)
+
+// Origin describes who or what triggered the operations in a context
+type Origin struct {
+	ActorID       string
+	ActorType     string
+	Source        string
+	IPAddress     string
+	MessageID     string
+	RequestID     string
+	CorrelationID string
+}
+
+type originKey struct{}
+
+// WithOrigin returns a copy of ctx carrying the origin
+func WithOrigin(ctx context.Context, origin Origin) context.Context {
+	return context.WithValue(ctx, originKey{}, origin)
+}
+
+// OriginFrom returns the origin carried by ctx. Operations without one are
+// attributed to the service itself.
+func OriginFrom(ctx context.Context) Origin {
+	if origin, ok := ctx.Value(originKey{}).(Origin); ok {
+		return origin
+	}
+	return Origin{ActorID: "orden-compra", ActorType: models.AuditActorSystem, Source: "internal"}
+}
+
+// SystemOrigin attributes operations to a scheduled job of the service
+func SystemOrigin(job string) Origin {
+	return Origin{ActorID: job, ActorType: models.AuditActorSystem, Source: "scheduler"}
+}
+
+// Middleware records the origin of HTTP requests: the authenticated
+// principal, client IP and request and correlation IDs. It runs after
+// authentication and the correlation middleware.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		origin := Origin{
+			ActorID:   "anonymous",
+			ActorType: models.AuditActorAnonymous,
+			Source:    "http",
+			IPAddress: c.ClientIP(),
+		}
+		if ids, ok := correlation.FromContext(c.Request.Context()); ok {
+			origin.RequestID = ids.RequestID
+			origin.CorrelationID = ids.CorrelationID
+		}
+
+		if principal, ok := auth.FromContext(c.Request.Context()); ok {
+			origin.ActorID = principal.Subject
+			origin.ActorType = models.AuditActorUser
+			if principal.Method == auth.MethodAPIKey {
+				origin.ActorType = models.AuditActorAPIClient
+			}
+		}
+
+		c.Request = c.Request.WithContext(WithOrigin(c.Request.Context(), origin))
+		c.Next()
+	}
+}
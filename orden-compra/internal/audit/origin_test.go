@@ -0,0 +1,60 @@
+package audit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"medisupply/correlation"
+	"orden-compra/internal/auth"
+	"orden-compra/internal/models"
+)
+
+func TestMiddlewareRecordsTheOrigin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	for _, tc := range []struct {
+		name      string
+		principal *auth.Principal
+		actorID   string
+		actorType string
+	}{
+		{"anonymous", nil, "anonymous", models.AuditActorAnonymous},
+		{"user", &auth.Principal{Subject: "user-1", Method: auth.MethodJWT}, "user-1", models.AuditActorUser},
+		{"api client", &auth.Principal{Subject: "client-1", Method: auth.MethodAPIKey}, "client-1", models.AuditActorAPIClient},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var origin Origin
+			router := gin.New()
+			router.Use(func(c *gin.Context) {
+				ctx := correlation.NewContext(c.Request.Context(), correlation.IDs{RequestID: "request-1", CorrelationID: "correlation-1"})
+				if tc.principal != nil {
+					ctx = auth.NewContext(ctx, tc.principal)
+				}
+				c.Request = c.Request.WithContext(ctx)
+			}, Middleware())
+			router.GET("/", func(c *gin.Context) { origin = OriginFrom(c.Request.Context()) })
+
+			request := httptest.NewRequest(http.MethodGet, "/", nil)
+			request.RemoteAddr = "10.0.0.1:1234"
+			router.ServeHTTP(httptest.NewRecorder(), request)
+
+			want := Origin{ActorID: tc.actorID, ActorType: tc.actorType, Source: "http", IPAddress: "10.0.0.1", RequestID: "request-1", CorrelationID: "correlation-1"}
+			if origin != want {
+				t.Fatalf("origin %+v, want %+v", origin, want)
+			}
+		})
+	}
+}
+
+func TestOriginFrom(t *testing.T) {
+	if origin := OriginFrom(context.Background()); origin.ActorType != models.AuditActorSystem || origin.Source != "internal" {
+		t.Fatalf("default origin %+v", origin)
+	}
+	if origin := OriginFrom(WithOrigin(context.Background(), SystemOrigin("overdue-check"))); origin.ActorID != "overdue-check" || origin.Source != "scheduler" {
+		t.Fatalf("system origin %+v", origin)
+	}
+}
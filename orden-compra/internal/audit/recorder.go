@@ -0,0 +1,107 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+
+	"orden-compra/internal/models"
+	"orden-compra/internal/tenant"
+)
+
+// Recorder writes audit entries for state-changing operations. A nil
+// Recorder records nothing.
+type Recorder struct {
+	Store    *Store
+	DynamoDB dynamodbiface.DynamoDBAPI
+	Logger   *log.Logger
+}
+
+// NewRecorder creates a recorder. dynamoDB is used to snapshot purchase orders.
+func NewRecorder(store *Store, dynamoDB dynamodbiface.DynamoDBAPI, logger *log.Logger) *Recorder {
+	return &Recorder{
+		Store:    store,
+		DynamoDB: dynamoDB,
+		Logger:   logger,
+	}
+}
+
+// Record appends an entry attributed to the origin in ctx. before and after
+// are the resource state around the operation and may be nil; opErr marks
+// the operation as failed. Failures to write are logged, never returned, so
+// auditing does not change the outcome of the operation.
+func (r *Recorder) Record(ctx context.Context, action, resourceType, resourceID string, before, after interface{}, opErr error) {
+	if r == nil {
+		return
+	}
+
+	origin := OriginFrom(ctx)
+	entry := models.NewAuditEntry(action, resourceType, resourceID, time.Now())
+	entry.ActorID = origin.ActorID
+	entry.ActorType = origin.ActorType
+	entry.Source = origin.Source
+	entry.IPAddress = origin.IPAddress
+	entry.MessageID = origin.MessageID
+	entry.RequestID = origin.RequestID
+	entry.CorrelationID = origin.CorrelationID
+	entry.TenantID, _ = tenant.FromContext(ctx)
+	entry.Before = snapshot(before)
+	entry.After = snapshot(after)
+	if opErr != nil {
+		entry.Outcome = models.AuditOutcomeFailure
+		entry.Error = opErr.Error()
+	}
+
+	// Keep recording when the request that triggered the operation is cancelled
+	if err := r.Store.Append(context.WithoutCancel(ctx), entry); err != nil {
+		r.Logger.Printf("AUDIT WRITE FAILED - action: %s, resource: %s/%s, actor: %s, error: %v", action, resourceType, resourceID, origin.ActorID, err)
+	}
+}
+
+// PurchaseOrder snapshots a purchase order from the read model, returning nil
+// when it cannot be read
+func (r *Recorder) PurchaseOrder(ctx context.Context, purchaseOrderID string) *models.PurchaseOrder {
+	if r == nil {
+		return nil
+	}
+
+	result, err := r.DynamoDB.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String("orden-compra-read"),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {S: aws.String(purchaseOrderID)},
+		},
+	})
+	if err != nil || result.Item == nil {
+		return nil
+	}
+
+	var purchaseOrder models.PurchaseOrder
+	if err := dynamodbattribute.UnmarshalMap(result.Item, &purchaseOrder); err != nil {
+		return nil
+	}
+	return &purchaseOrder
+}
+
+// snapshot converts a resource into its JSON object form
+func snapshot(v interface{}) map[string]interface{} {
+	if v == nil {
+		return nil
+	}
+
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return map[string]interface{}{"snapshot_error": err.Error()}
+	}
+
+	var object map[string]interface{}
+	if err := json.Unmarshal(raw, &object); err != nil {
+		return map[string]interface{}{"value": string(raw)}
+	}
+	return object
+}
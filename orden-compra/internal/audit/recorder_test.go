@@ -0,0 +1,95 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"testing"
+
+	"orden-compra/internal/memory"
+	"orden-compra/internal/models"
+	"orden-compra/internal/tenant"
+)
+
+func newRecorder() (*Recorder, *Store) {
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	store := NewStore(dynamoDB)
+	return NewRecorder(store, dynamoDB, log.New(io.Discard, "", 0)), store
+}
+
+// onlyEntry returns the single audit entry in the store
+func onlyEntry(t *testing.T, store *Store) *models.AuditEntry {
+	t.Helper()
+	entries, err := store.Query(context.Background(), Filter{})
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("audit log holds %d entries, error %v, want 1", len(entries), err)
+	}
+	return entries[0]
+}
+
+func TestRecordAttributesTheEntryToTheOrigin(t *testing.T) {
+	recorder, store := newRecorder()
+	ctx := WithOrigin(tenant.NewContext(context.Background(), "tenant-1"), Origin{
+		ActorID:       "client-1",
+		ActorType:     models.AuditActorAPIClient,
+		Source:        "http",
+		IPAddress:     "10.0.0.1",
+		RequestID:     "request-1",
+		CorrelationID: "correlation-1",
+	})
+
+	before := models.NewPurchaseOrder("product-1", "Gloves", "supplier-1", "Acme", "warehouse-1", "HIGH", 10, testTime)
+	after := *before
+	after.Status = models.StatusCancelled
+	recorder.Record(ctx, models.AuditPurchaseOrderCancelled, models.AuditResourcePurchaseOrder, before.ID, before, &after, nil)
+
+	entry := onlyEntry(t, store)
+	if entry.ActorID != "client-1" || entry.ActorType != models.AuditActorAPIClient || entry.IPAddress != "10.0.0.1" || entry.RequestID != "request-1" || entry.CorrelationID != "correlation-1" || entry.TenantID != "tenant-1" {
+		t.Fatalf("entry %+v", entry)
+	}
+	if entry.Action != models.AuditPurchaseOrderCancelled || entry.ResourceID != before.ID || entry.Outcome != models.AuditOutcomeSuccess {
+		t.Fatalf("entry %+v", entry)
+	}
+	if entry.Before["status"] != before.Status || entry.After["status"] != models.StatusCancelled {
+		t.Fatalf("snapshots before %v after %v", entry.Before["status"], entry.After["status"])
+	}
+}
+
+func TestRecordFailedOperations(t *testing.T) {
+	recorder, store := newRecorder()
+
+	// Operations without an origin are attributed to the service, and a
+	// cancelled request still records its entry
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	recorder.Record(ctx, models.AuditLogLevelUpdated, models.AuditResourceLogLevel, "root", nil, nil, errors.New("invalid level"))
+
+	entry := onlyEntry(t, store)
+	if entry.ActorID != "orden-compra" || entry.ActorType != models.AuditActorSystem || entry.Outcome != models.AuditOutcomeFailure || entry.Error != "invalid level" || entry.Before != nil || entry.After != nil {
+		t.Fatalf("entry %+v", entry)
+	}
+
+	// A nil recorder records nothing
+	var disabled *Recorder
+	disabled.Record(context.Background(), models.AuditLogLevelUpdated, models.AuditResourceLogLevel, "root", nil, nil, nil)
+	if disabled.PurchaseOrder(context.Background(), "po-1") != nil {
+		t.Fatal("nil recorder returned a snapshot")
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	if got := snapshot(nil); got != nil {
+		t.Fatalf("snapshot of nil %v", got)
+	}
+	if got := snapshot(map[string]int{"quantity": 10}); got["quantity"] != float64(10) {
+		t.Fatalf("snapshot %v", got)
+	}
+	// Values that are not JSON objects are kept as their encoding
+	if got := snapshot([]string{"a"}); got["value"] != `["a"]` {
+		t.Fatalf("snapshot %v", got)
+	}
+	if got := snapshot(make(chan int)); got["snapshot_error"] == nil {
+		t.Fatalf("snapshot %v", got)
+	}
+}
@@ -0,0 +1,132 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+
+	"orden-compra/internal/models"
+)
+
+// Store appends audit entries to DynamoDB. It offers no update or delete.
+type Store struct {
+	DynamoDB  dynamodbiface.DynamoDBAPI
+	TableName string
+}
+
+// NewStore creates a store on the default audit table
+func NewStore(dynamoDB dynamodbiface.DynamoDBAPI) *Store {
+	return &Store{
+		DynamoDB:  dynamoDB,
+		TableName: "orden-compra-audit-log",
+	}
+}
+
+// Append writes a new entry; an existing entry is never overwritten
+func (s *Store) Append(ctx context.Context, entry *models.AuditEntry) error {
+	item, err := dynamodbattribute.MarshalMap(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit entry: %w", err)
+	}
+
+	_, err = s.DynamoDB.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(s.TableName),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(id)"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put audit entry: %w", err)
+	}
+
+	return nil
+}
+
+// Filter selects audit entries; empty fields match everything
+type Filter struct {
+	ResourceType string
+	ResourceID   string
+	ActorID      string
+	Action       string
+	From         *time.Time
+	To           *time.Time
+	Limit        int
+}
+
+// Query returns the entries matching the filter, newest first
+func (s *Store) Query(ctx context.Context, filter Filter) ([]*models.AuditEntry, error) {
+	scanInput := &dynamodb.ScanInput{
+		TableName: aws.String(s.TableName),
+	}
+
+	var filterExpressions []string
+	expressionAttributeNames := make(map[string]*string)
+	expressionAttributeValues := make(map[string]*dynamodb.AttributeValue)
+
+	addEquals := func(attribute, value string) {
+		if value == "" {
+			return
+		}
+		filterExpressions = append(filterExpressions, fmt.Sprintf("#%s = :%s", attribute, attribute))
+		expressionAttributeNames["#"+attribute] = aws.String(attribute)
+		expressionAttributeValues[":"+attribute] = &dynamodb.AttributeValue{S: aws.String(value)}
+	}
+	addEquals("resource_type", filter.ResourceType)
+	addEquals("resource_id", filter.ResourceID)
+	addEquals("actor_id", filter.ActorID)
+	addEquals("action", filter.Action)
+
+	if filter.From != nil || filter.To != nil {
+		expressionAttributeNames["#timestamp"] = aws.String("timestamp")
+	}
+	if filter.From != nil {
+		filterExpressions = append(filterExpressions, "#timestamp >= :from")
+		expressionAttributeValues[":from"] = &dynamodb.AttributeValue{S: aws.String(filter.From.UTC().Format(time.RFC3339Nano))}
+	}
+	if filter.To != nil {
+		filterExpressions = append(filterExpressions, "#timestamp <= :to")
+		expressionAttributeValues[":to"] = &dynamodb.AttributeValue{S: aws.String(filter.To.UTC().Format(time.RFC3339Nano))}
+	}
+
+	if len(filterExpressions) > 0 {
+		scanInput.FilterExpression = aws.String(strings.Join(filterExpressions, " AND "))
+		scanInput.ExpressionAttributeNames = expressionAttributeNames
+		scanInput.ExpressionAttributeValues = expressionAttributeValues
+	}
+
+	entries := make([]*models.AuditEntry, 0)
+	for {
+		result, err := s.DynamoDB.ScanWithContext(ctx, scanInput)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan audit log: %w", err)
+		}
+
+		for _, item := range result.Items {
+			var entry models.AuditEntry
+			if err := dynamodbattribute.UnmarshalMap(item, &entry); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal audit entry: %w", err)
+			}
+			entries = append(entries, &entry)
+		}
+
+		if len(result.LastEvaluatedKey) == 0 {
+			break
+		}
+		scanInput.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Timestamp.After(entries[j].Timestamp)
+	})
+	if filter.Limit > 0 && len(entries) > filter.Limit {
+		entries = entries[:filter.Limit]
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,79 @@
+package audit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"orden-compra/internal/memory"
+	"orden-compra/internal/models"
+)
+
+var testTime = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func TestAppendNeverOverwrites(t *testing.T) {
+	store := NewStore(memory.NewDynamoDB(memory.Tables))
+	entry := models.NewAuditEntry(models.AuditPurchaseOrderApproved, models.AuditResourcePurchaseOrder, "po-1", testTime)
+	if err := store.Append(context.Background(), entry); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+
+	entry.Action = models.AuditPurchaseOrderRejected
+	if err := store.Append(context.Background(), entry); err == nil {
+		t.Fatal("appended an entry with an existing ID")
+	}
+	if got := onlyEntry(t, store); got.Action != models.AuditPurchaseOrderApproved {
+		t.Fatalf("entry rewritten to %s", got.Action)
+	}
+}
+
+func TestQueryFilters(t *testing.T) {
+	store := NewStore(memory.NewDynamoDB(memory.Tables))
+	for i, entry := range []*models.AuditEntry{
+		models.NewAuditEntry(models.AuditPurchaseOrderCreated, models.AuditResourcePurchaseOrder, "po-1", testTime),
+		models.NewAuditEntry(models.AuditPurchaseOrderApproved, models.AuditResourcePurchaseOrder, "po-1", testTime.Add(time.Hour)),
+		models.NewAuditEntry(models.AuditPurchaseOrderCreated, models.AuditResourcePurchaseOrder, "po-2", testTime.Add(2*time.Hour)),
+		models.NewAuditEntry(models.AuditAccessPolicyUpdated, models.AuditResourceAccessPolicy, "client-1", testTime.Add(3*time.Hour)),
+	} {
+		entry.ActorID = "buyer-1"
+		if i == 3 {
+			entry.ActorID = "admin-1"
+		}
+		if err := store.Append(context.Background(), entry); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+
+	from, to := testTime.Add(30*time.Minute), testTime.Add(150*time.Minute)
+	for _, tc := range []struct {
+		name   string
+		filter Filter
+		want   []string
+	}{
+		{"everything newest first", Filter{}, []string{"client-1", "po-2", "po-1", "po-1"}},
+		{"resource", Filter{ResourceType: models.AuditResourcePurchaseOrder, ResourceID: "po-1"}, []string{"po-1", "po-1"}},
+		{"actor", Filter{ActorID: "admin-1"}, []string{"client-1"}},
+		{"action", Filter{Action: models.AuditPurchaseOrderCreated}, []string{"po-2", "po-1"}},
+		{"time range", Filter{From: &from, To: &to}, []string{"po-2", "po-1"}},
+		{"limit", Filter{Limit: 1}, []string{"client-1"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			entries, err := store.Query(context.Background(), tc.filter)
+			if err != nil {
+				t.Fatalf("query: %v", err)
+			}
+			var got []string
+			for _, entry := range entries {
+				got = append(got, entry.ResourceID)
+			}
+			if len(got) != len(tc.want) {
+				t.Fatalf("resources %v, want %v", got, tc.want)
+			}
+			for i := range got {
+				if got[i] != tc.want[i] {
+					t.Fatalf("resources %v, want %v", got, tc.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+)
+
+// Authentication methods recorded on a Principal
+const (
+	MethodAPIKey = "api_key"
+	MethodJWT    = "jwt"
+)
+
+var (
+	// ErrMissingCredentials is returned when a request carries neither an API key nor a bearer token
+	ErrMissingCredentials = errors.New("missing credentials")
+	// ErrInvalidCredentials is returned when the presented credentials are rejected
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	Subject string                 `json:"subject"`
+	Method  string                 `json:"method"`
+	Roles   []string               `json:"roles"`
+	Tenant  string                 `json:"tenant,omitempty"`
+	Claims  map[string]interface{} `json:"claims,omitempty"`
+}
+
+type principalKey struct{}
+
+// NewContext returns a copy of ctx carrying the principal
+func NewContext(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// FromContext returns the principal carried by ctx, if any
+func FromContext(ctx context.Context) (*Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(*Principal)
+	return principal, ok && principal != nil
+}
+
+// APIKey identifies the machine client owning a static key
+type APIKey struct {
+	Client string
+	Roles  []string
+	Tenant string
+}
+
+// ParseAPIKeys parses "client:key", "client:key:role|role" or
+// "client:key:role|role:tenant" entries into a map from key to client
+func ParseAPIKeys(entries []string) (map[string]APIKey, error) {
+	keys := make(map[string]APIKey, len(entries))
+	for _, entry := range entries {
+		parts := strings.SplitN(entry, ":", 4)
+		if len(parts) < 2 || strings.TrimSpace(parts[0]) == "" || strings.TrimSpace(parts[1]) == "" {
+			return nil, fmt.Errorf("invalid API key entry %q, expected client:key[:roles[:tenant]]", entry)
+		}
+
+		apiKey := APIKey{Client: strings.TrimSpace(parts[0])}
+		if len(parts) >= 3 {
+			for _, role := range strings.Split(parts[2], "|") {
+				if role = strings.TrimSpace(role); role != "" {
+					apiKey.Roles = append(apiKey.Roles, role)
+				}
+			}
+		}
+		if len(parts) == 4 {
+			apiKey.Tenant = strings.TrimSpace(parts[3])
+		}
+		keys[strings.TrimSpace(parts[1])] = apiKey
+	}
+	return keys, nil
+}
+
+// Authenticator verifies static API keys for machine clients and JWT bearer
+// tokens for users
+type Authenticator struct {
+	Verifier *JWTVerifier
+	Logger   *log.Logger
+
+	mu sync.RWMutex
+	// apiKeys maps the SHA-256 of each key to its client so lookups
+	// compare fixed-length digests in constant time
+	apiKeys map[[sha256.Size]byte]APIKey
+}
+
+// NewAuthenticator creates an authenticator. apiKeys maps each key to the
+// client it identifies; verifier may be nil when JWTs are not accepted.
+func NewAuthenticator(apiKeys map[string]APIKey, verifier *JWTVerifier, logger *log.Logger) *Authenticator {
+	authenticator := &Authenticator{
+		Verifier: verifier,
+		Logger:   logger,
+	}
+	authenticator.SetAPIKeys(apiKeys)
+	return authenticator
+}
+
+// SetAPIKeys replaces the accepted API keys, such as after a rotation
+func (a *Authenticator) SetAPIKeys(apiKeys map[string]APIKey) {
+	hashed := make(map[[sha256.Size]byte]APIKey, len(apiKeys))
+	for key, client := range apiKeys {
+		hashed[sha256.Sum256([]byte(key))] = client
+	}
+
+	a.mu.Lock()
+	a.apiKeys = hashed
+	a.mu.Unlock()
+}
+
+// Authenticate resolves the principal from an Authorization header value and
+// an API key. A bearer token takes precedence when both are present.
+func (a *Authenticator) Authenticate(ctx context.Context, authorization, apiKey string) (*Principal, error) {
+	if token, ok := bearerToken(authorization); ok {
+		if a.Verifier == nil {
+			return nil, fmt.Errorf("%w: bearer tokens are not accepted", ErrInvalidCredentials)
+		}
+		claims, err := a.Verifier.Verify(ctx, token)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+		}
+		subject, _ := claims["sub"].(string)
+		return &Principal{Subject: subject, Method: MethodJWT, Roles: a.Verifier.Roles(claims), Tenant: a.Verifier.Tenant(claims), Claims: claims}, nil
+	}
+
+	if apiKey != "" {
+		if client, ok := a.lookupAPIKey(apiKey); ok {
+			return &Principal{Subject: client.Client, Method: MethodAPIKey, Roles: client.Roles, Tenant: client.Tenant}, nil
+		}
+		return nil, fmt.Errorf("%w: unknown API key", ErrInvalidCredentials)
+	}
+
+	return nil, ErrMissingCredentials
+}
+
+// lookupAPIKey finds the client owning an API key
+func (a *Authenticator) lookupAPIKey(apiKey string) (APIKey, bool) {
+	digest := sha256.Sum256([]byte(apiKey))
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	var found APIKey
+	ok := false
+	for candidate, client := range a.apiKeys {
+		if subtle.ConstantTimeCompare(candidate[:], digest[:]) == 1 {
+			found, ok = client, true
+		}
+	}
+	return found, ok
+}
+
+// bearerToken extracts the token of a "Bearer <token>" header value
+func bearerToken(authorization string) (string, bool) {
+	scheme, token, ok := strings.Cut(strings.TrimSpace(authorization), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseAPIKeys(t *testing.T) {
+	keys, err := ParseAPIKeys([]string{
+		"erp:key-1",
+		"scheduler:key-2:buyer|approver",
+		" portal : key-3 : viewer : tenant-1 ",
+	})
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	want := map[string]APIKey{
+		"key-1": {Client: "erp"},
+		"key-2": {Client: "scheduler", Roles: []string{"buyer", "approver"}},
+		"key-3": {Client: "portal", Roles: []string{"viewer"}, Tenant: "tenant-1"},
+	}
+	if !reflect.DeepEqual(keys, want) {
+		t.Fatalf("keys %+v, want %+v", keys, want)
+	}
+
+	for _, entry := range []string{"erp", ":key-1", "erp: "} {
+		if _, err := ParseAPIKeys([]string{entry}); err == nil {
+			t.Fatalf("parsed invalid entry %q", entry)
+		}
+	}
+}
+
+func TestAuthenticateAPIKeys(t *testing.T) {
+	authenticator := NewAuthenticator(map[string]APIKey{"key-1": {Client: "portal", Roles: []string{"viewer"}, Tenant: "tenant-1"}}, nil, nil)
+
+	principal, err := authenticator.Authenticate(context.Background(), "", "key-1")
+	if err != nil || principal.Subject != "portal" || principal.Method != MethodAPIKey || principal.Tenant != "tenant-1" {
+		t.Fatalf("principal %+v, error %v", principal, err)
+	}
+
+	for _, tc := range []struct {
+		name          string
+		authorization string
+		apiKey        string
+		err           error
+	}{
+		{"unknown key", "", "key-2", ErrInvalidCredentials},
+		{"no credentials", "", "", ErrMissingCredentials},
+		{"bearer token without a verifier", "Bearer token", "key-1", ErrInvalidCredentials},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := authenticator.Authenticate(context.Background(), tc.authorization, tc.apiKey); !errors.Is(err, tc.err) {
+				t.Fatalf("Authenticate returned %v, want %v", err, tc.err)
+			}
+		})
+	}
+
+	// Rotated keys replace the old ones
+	authenticator.SetAPIKeys(map[string]APIKey{"key-2": {Client: "portal"}})
+	if _, err := authenticator.Authenticate(context.Background(), "", "key-1"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("rotated key returned %v", err)
+	}
+}
+
+func TestTenantClaim(t *testing.T) {
+	claims := map[string]interface{}{
+		"tenant_id": "tenant-1",
+		"org":       map[string]interface{}{"id": "tenant-2"},
+	}
+	if tenantID := (&JWTVerifier{}).Tenant(claims); tenantID != "tenant-1" {
+		t.Fatalf("default claim tenant %q", tenantID)
+	}
+	if tenantID := (&JWTVerifier{Config: JWTConfig{TenantClaim: "org.id"}}).Tenant(claims); tenantID != "tenant-2" {
+		t.Fatalf("nested claim tenant %q", tenantID)
+	}
+	if tenantID := (&JWTVerifier{Config: JWTConfig{TenantClaim: "missing"}}).Tenant(claims); tenantID != "" {
+		t.Fatalf("missing claim tenant %q", tenantID)
+	}
+}
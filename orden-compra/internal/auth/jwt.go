@@ -0,0 +1,453 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// minRefetchInterval bounds how often an unknown key ID triggers a JWKS fetch
+const minRefetchInterval = 30 * time.Second
+
+// JWTConfig configures bearer token verification
+type JWTConfig struct {
+	// Issuer must match the iss claim when set
+	Issuer string
+	// Audience must be listed in the aud claim when set
+	Audience string
+	// JWKSURL serves the issuer's signing keys
+	JWKSURL string
+	// RefreshInterval is how long fetched keys are trusted before refetching
+	RefreshInterval time.Duration
+	// Leeway tolerates clock skew on exp and nbf
+	Leeway time.Duration
+	// RolesClaim is the claim holding the caller's roles; dots select nested
+	// claims such as realm_access.roles
+	RolesClaim string
+	// TenantClaim is the claim holding the caller's tenant, with the same
+	// dotted syntax as RolesClaim
+	TenantClaim string
+}
+
+// JWTVerifier verifies RS256/384/512 and ES256/384 tokens against a JWKS
+type JWTVerifier struct {
+	Config JWTConfig
+	Client *http.Client
+	Logger *log.Logger
+
+	// mu guards the cached keys; it is never held while fetching the JWKS
+	mu        sync.RWMutex
+	keys      map[string]signingKey
+	fetchedAt time.Time
+	// fetches lets concurrent callers share one JWKS request
+	fetches singleflight.Group
+}
+
+// signingKey is a key of the JWKS with the algorithm it is published for
+type signingKey struct {
+	public crypto.PublicKey
+	// algorithm is the JWK alg, empty when the issuer does not restrict it
+	algorithm string
+}
+
+// NewJWTVerifier creates a verifier. Keys are fetched lazily on first use.
+func NewJWTVerifier(config JWTConfig, logger *log.Logger) (*JWTVerifier, error) {
+	if config.JWKSURL == "" {
+		return nil, errors.New("JWKS URL is required")
+	}
+	if config.RefreshInterval <= 0 {
+		config.RefreshInterval = 10 * time.Minute
+	}
+
+	return &JWTVerifier{
+		Config: config,
+		Client: &http.Client{Timeout: 10 * time.Second},
+		Logger: logger,
+	}, nil
+}
+
+// jwtHeader is the JOSE header of a token
+type jwtHeader struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+}
+
+// Verify checks the token signature and registered claims and returns its claims
+func (v *JWTVerifier) Verify(ctx context.Context, token string) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("malformed token header: %w", err)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("malformed token signature: %w", err)
+	}
+
+	key, err := v.key(ctx, header.KeyID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := key.verify(header.Algorithm, []byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return nil, err
+	}
+
+	var claims map[string]interface{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("malformed token claims: %w", err)
+	}
+
+	if err := v.validateClaims(claims, time.Now()); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+// Roles extracts the roles from verified claims. The claim may be a list or a
+// space-separated string.
+func (v *JWTVerifier) Roles(claims map[string]interface{}) []string {
+	path := v.Config.RolesClaim
+	if path == "" {
+		path = "roles"
+	}
+
+	switch roles := claimValue(claims, path).(type) {
+	case string:
+		return strings.Fields(roles)
+	case []interface{}:
+		result := make([]string, 0, len(roles))
+		for _, role := range roles {
+			if s, ok := role.(string); ok {
+				result = append(result, s)
+			}
+		}
+		return result
+	default:
+		return nil
+	}
+}
+
+// Tenant extracts the tenant from verified claims, or "" when the token has none
+func (v *JWTVerifier) Tenant(claims map[string]interface{}) string {
+	path := v.Config.TenantClaim
+	if path == "" {
+		path = "tenant_id"
+	}
+
+	tenantID, _ := claimValue(claims, path).(string)
+	return tenantID
+}
+
+// claimValue follows a dotted claim path, returning nil when it is absent
+func claimValue(claims map[string]interface{}, path string) interface{} {
+	var value interface{} = claims
+	for _, name := range strings.Split(path, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = object[name]
+	}
+	return value
+}
+
+// validateClaims checks exp, nbf, iss and aud
+func (v *JWTVerifier) validateClaims(claims map[string]interface{}, now time.Time) error {
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return errors.New("token has no expiry")
+	}
+	if now.After(time.Unix(int64(exp), 0).Add(v.Config.Leeway)) {
+		return errors.New("token expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(v.Config.Leeway).Before(time.Unix(int64(nbf), 0)) {
+		return errors.New("token not valid yet")
+	}
+
+	if v.Config.Issuer != "" {
+		if iss, _ := claims["iss"].(string); iss != v.Config.Issuer {
+			return fmt.Errorf("unexpected issuer %q", iss)
+		}
+	}
+
+	if v.Config.Audience != "" {
+		found := false
+		switch aud := claims["aud"].(type) {
+		case string:
+			found = aud == v.Config.Audience
+		case []interface{}:
+			for _, entry := range aud {
+				if entry == v.Config.Audience {
+					found = true
+					break
+				}
+			}
+		}
+		if !found {
+			return errors.New("token not issued for this audience")
+		}
+	}
+
+	return nil
+}
+
+// key returns the signing key with the given ID, refreshing the JWKS when the
+// cache is stale or the key is unknown
+func (v *JWTVerifier) key(ctx context.Context, keyID string) (signingKey, error) {
+	v.mu.RLock()
+	age := time.Since(v.fetchedAt)
+	key, known := v.lookup(keyID)
+	fetched := v.keys != nil
+	v.mu.RUnlock()
+
+	if known && age < v.Config.RefreshInterval {
+		return key, nil
+	}
+	if fetched && age < v.Config.RefreshInterval && age < minRefetchInterval {
+		return signingKey{}, fmt.Errorf("unknown signing key %q", keyID)
+	}
+
+	if err := v.refresh(ctx); err != nil {
+		if known {
+			// Keep serving the cached key while the issuer is unreachable
+			v.Logger.Printf("Failed to refresh JWKS, using cached keys: %v", err)
+			return key, nil
+		}
+		return signingKey{}, err
+	}
+
+	v.mu.RLock()
+	key, known = v.lookup(keyID)
+	v.mu.RUnlock()
+	if !known {
+		return signingKey{}, fmt.Errorf("unknown signing key %q", keyID)
+	}
+	return key, nil
+}
+
+// refresh fetches the JWKS into the cache. Concurrent callers share one
+// request, which is not cancelled when one of them gives up waiting.
+func (v *JWTVerifier) refresh(ctx context.Context) error {
+	done := v.fetches.DoChan("jwks", func() (interface{}, error) {
+		keys, err := v.fetchKeys(context.WithoutCancel(ctx))
+		if err != nil {
+			return nil, err
+		}
+		v.mu.Lock()
+		v.keys = keys
+		v.fetchedAt = time.Now()
+		v.mu.Unlock()
+		return nil, nil
+	})
+
+	select {
+	case result := <-done:
+		return result.Err
+	case <-ctx.Done():
+		return fmt.Errorf("failed to fetch JWKS: %w", ctx.Err())
+	}
+}
+
+// lookup finds a cached key. Tokens without a key ID are accepted when the
+// JWKS holds a single key. The caller holds mu.
+func (v *JWTVerifier) lookup(keyID string) (signingKey, bool) {
+	if keyID == "" && len(v.keys) == 1 {
+		for _, key := range v.keys {
+			return key, true
+		}
+	}
+	key, ok := v.keys[keyID]
+	return key, ok
+}
+
+// jwk is one JSON Web Key
+type jwk struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	N         string `json:"n"`
+	E         string `json:"e"`
+	Curve     string `json:"crv"`
+	X         string `json:"x"`
+	Y         string `json:"y"`
+}
+
+// fetchKeys downloads the JWKS and parses its RSA and EC signing keys
+func (v *JWTVerifier) fetchKeys(ctx context.Context) (map[string]signingKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.Config.JWKSURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build JWKS request: %w", err)
+	}
+
+	resp, err := v.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch JWKS: status %d", resp.StatusCode)
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("failed to decode JWKS: %w", err)
+	}
+
+	keys := make(map[string]signingKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			v.Logger.Printf("Skipping JWKS key %q: %v", k.KeyID, err)
+			continue
+		}
+		keys[k.KeyID] = signingKey{public: key, algorithm: k.Algorithm}
+	}
+
+	v.Logger.Printf("JWKS refreshed - keys: %d", len(keys))
+	return keys, nil
+}
+
+// publicKey converts a JWK into an RSA or ECDSA public key
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.KeyType {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid modulus: %w", err)
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil || !e.IsInt64() {
+			return nil, errors.New("invalid exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Curve {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Curve)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("invalid x coordinate: %w", err)
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("invalid y coordinate: %w", err)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.KeyType)
+	}
+}
+
+// verify checks a JWS signature. The algorithm must be the one the key is
+// published for, and name the key's type and, for EC keys, its curve.
+func (k signingKey) verify(algorithm string, signed, signature []byte) error {
+	if k.algorithm != "" && algorithm != k.algorithm {
+		return fmt.Errorf("algorithm %s does not match the key's %s", algorithm, k.algorithm)
+	}
+
+	var hash crypto.Hash
+	switch algorithm {
+	case "RS256", "ES256":
+		hash = crypto.SHA256
+	case "RS384", "ES384":
+		hash = crypto.SHA384
+	case "RS512":
+		hash = crypto.SHA512
+	default:
+		return fmt.Errorf("unsupported signing algorithm %q", algorithm)
+	}
+
+	h := hash.New()
+	h.Write(signed)
+	digest := h.Sum(nil)
+
+	switch pub := k.public.(type) {
+	case *rsa.PublicKey:
+		if !strings.HasPrefix(algorithm, "RS") {
+			return fmt.Errorf("algorithm %s does not match an RSA key", algorithm)
+		}
+		if err := rsa.VerifyPKCS1v15(pub, hash, digest, signature); err != nil {
+			return errors.New("invalid token signature")
+		}
+	case *ecdsa.PublicKey:
+		if curveAlgorithms[pub.Curve] != algorithm {
+			return fmt.Errorf("algorithm %s does not match an EC %s key", algorithm, pub.Curve.Params().Name)
+		}
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return errors.New("invalid token signature")
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(pub, digest, r, s) {
+			return errors.New("invalid token signature")
+		}
+	default:
+		return errors.New("unsupported key")
+	}
+
+	return nil
+}
+
+// curveAlgorithms is the one algorithm each supported EC curve signs with
+var curveAlgorithms = map[elliptic.Curve]string{
+	elliptic.P256(): "ES256",
+	elliptic.P384(): "ES384",
+}
+
+// decodeSegment decodes a base64url JSON token segment
+func decodeSegment(segment string, v interface{}) error {
+	raw, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, v)
+}
+
+// decodeBigInt decodes a base64url big-endian integer
+func decodeBigInt(value string) (*big.Int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) == 0 {
+		return nil, errors.New("empty value")
+	}
+	return new(big.Int).SetBytes(raw), nil
+}
@@ -0,0 +1,219 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// issuer serves a JWKS and signs tokens with its keys
+type issuer struct {
+	rsa     *rsa.PrivateKey
+	p256    *ecdsa.PrivateKey
+	p384    *ecdsa.PrivateKey
+	fetches atomic.Int32
+	// release, when set, holds JWKS requests until it is closed
+	release chan struct{}
+	server  *httptest.Server
+}
+
+func newIssuer(t *testing.T) *issuer {
+	t.Helper()
+	i := &issuer{}
+	var err error
+	if i.rsa, err = rsa.GenerateKey(rand.Reader, 2048); err != nil {
+		t.Fatalf("generate RSA key: %v", err)
+	}
+	if i.p256, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+		t.Fatalf("generate P-256 key: %v", err)
+	}
+	if i.p384, err = ecdsa.GenerateKey(elliptic.P384(), rand.Reader); err != nil {
+		t.Fatalf("generate P-384 key: %v", err)
+	}
+
+	encode := func(n *big.Int) string { return base64.RawURLEncoding.EncodeToString(n.Bytes()) }
+	jwks := map[string]interface{}{"keys": []map[string]string{
+		{"kty": "RSA", "kid": "rsa", "use": "sig", "alg": "RS256", "n": encode(i.rsa.N), "e": encode(big.NewInt(int64(i.rsa.E)))},
+		{"kty": "RSA", "kid": "rsa-any", "n": encode(i.rsa.N), "e": encode(big.NewInt(int64(i.rsa.E)))},
+		{"kty": "EC", "kid": "p256", "crv": "P-256", "x": encode(i.p256.X), "y": encode(i.p256.Y)},
+		{"kty": "EC", "kid": "p384", "crv": "P-384", "x": encode(i.p384.X), "y": encode(i.p384.Y)},
+	}}
+	i.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		i.fetches.Add(1)
+		if i.release != nil {
+			<-i.release
+		}
+		json.NewEncoder(w).Encode(jwks)
+	}))
+	t.Cleanup(i.server.Close)
+	return i
+}
+
+// sign builds a token with the given header algorithm and key ID, signed by
+// the issuer key signer
+func (i *issuer) sign(t *testing.T, algorithm, signer, keyID string, claims map[string]interface{}) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": algorithm, "kid": keyID, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	hash := map[string]crypto.Hash{
+		"RS256": crypto.SHA256, "RS384": crypto.SHA384, "RS512": crypto.SHA512,
+		"ES256": crypto.SHA256, "ES384": crypto.SHA384,
+	}[algorithm]
+	h := hash.New()
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+
+	var signature []byte
+	var err error
+	switch signer {
+	case "rsa":
+		signature, err = rsa.SignPKCS1v15(rand.Reader, i.rsa, hash, digest)
+	default:
+		key := i.p256
+		if signer == "p384" {
+			key = i.p384
+		}
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, key, digest)
+		size := (key.Curve.Params().BitSize + 7) / 8
+		signature = make([]byte, 2*size)
+		r.FillBytes(signature[:size])
+		s.FillBytes(signature[size:])
+	}
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func (i *issuer) verifier(t *testing.T) *JWTVerifier {
+	t.Helper()
+	verifier, err := NewJWTVerifier(JWTConfig{
+		Issuer:   "https://issuer.example.com",
+		Audience: "orden-compra",
+		JWKSURL:  i.server.URL,
+	}, log.New(io.Discard, "", 0))
+	if err != nil {
+		t.Fatalf("new verifier: %v", err)
+	}
+	return verifier
+}
+
+func validClaims() map[string]interface{} {
+	return map[string]interface{}{
+		"iss":   "https://issuer.example.com",
+		"aud":   []string{"orden-compra", "proveedor"},
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"sub":   "buyer-1",
+		"roles": "buyer",
+	}
+}
+
+func TestVerify(t *testing.T) {
+	i := newIssuer(t)
+	verifier := i.verifier(t)
+
+	expired := validClaims()
+	expired["exp"] = time.Now().Add(-time.Hour).Unix()
+	otherAudience := validClaims()
+	otherAudience["aud"] = "inventario"
+
+	for _, tc := range []struct {
+		name      string
+		algorithm string
+		signer    string
+		keyID     string
+		claims    map[string]interface{}
+		// wantErr is part of the error expected, empty for a valid token
+		wantErr string
+	}{
+		{"RS256", "RS256", "rsa", "rsa", validClaims(), ""},
+		{"ES256", "ES256", "p256", "p256", validClaims(), ""},
+		{"ES384", "ES384", "p384", "p384", validClaims(), ""},
+		{"expired", "RS256", "rsa", "rsa", expired, "token expired"},
+		{"wrong audience", "ES256", "p256", "p256", otherAudience, "audience"},
+		{"algorithm other than the JWK's", "RS512", "rsa", "rsa", validClaims(), "does not match the key's RS256"},
+		{"RS512 on an RSA key of any algorithm", "RS512", "rsa", "rsa-any", validClaims(), ""},
+		{"EC algorithm on an RSA key", "ES256", "rsa", "rsa-any", validClaims(), "does not match an RSA key"},
+		{"ES384 on a P-256 key", "ES384", "p256", "p256", validClaims(), "does not match an EC P-256 key"},
+		{"ES256 on a P-384 key", "ES256", "p384", "p384", validClaims(), "does not match an EC P-384 key"},
+		{"unknown key ID", "ES256", "p256", "retired", validClaims(), `unknown signing key "retired"`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			token := i.sign(t, tc.algorithm, tc.signer, tc.keyID, tc.claims)
+			claims, err := verifier.Verify(context.Background(), token)
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Verify: %v", err)
+				}
+				if claims["sub"] != "buyer-1" || verifier.Roles(claims)[0] != "buyer" {
+					t.Fatalf("claims %v", claims)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("Verify returned %v, want an error containing %q", err, tc.wantErr)
+			}
+		})
+	}
+
+	// An unknown key ID refetches at most once per minRefetchInterval
+	if fetches := i.fetches.Load(); fetches != 1 {
+		t.Fatalf("JWKS fetched %d times, want once", fetches)
+	}
+}
+
+func TestVerifyFetchesTheKeysOnceForConcurrentCallers(t *testing.T) {
+	i := newIssuer(t)
+	i.release = make(chan struct{})
+	verifier := i.verifier(t)
+	token := i.sign(t, "ES256", "p256", "p256", validClaims())
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for n := 0; n < 10; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := verifier.Verify(context.Background(), token)
+			errs <- err
+		}()
+	}
+
+	// A caller giving up leaves the shared fetch running for the others
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := verifier.Verify(ctx, token); !errors.Is(err, context.Canceled) {
+		t.Fatalf("cancelled Verify returned %v", err)
+	}
+
+	close(i.release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("Verify: %v", err)
+		}
+	}
+	if fetches := i.fetches.Load(); fetches != 1 {
+		t.Fatalf("JWKS fetched %d times, want once", fetches)
+	}
+}
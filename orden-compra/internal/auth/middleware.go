@@ -0,0 +1,44 @@
+package auth
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ContextKey is the Gin context key holding the authenticated *Principal
+const ContextKey = "principal"
+
+// APIKeyHeader carries the static API key of machine clients
+const APIKeyHeader = "X-API-Key"
+
+// Middleware rejects requests without valid credentials, except for the given
+// public route paths such as /health. The principal is stored in the Gin
+// context and in the request context for the handlers.
+func (a *Authenticator) Middleware(publicPaths []string) gin.HandlerFunc {
+	public := make(map[string]bool, len(publicPaths))
+	for _, path := range publicPaths {
+		public[path] = true
+	}
+
+	return func(c *gin.Context) {
+		if public[c.FullPath()] {
+			c.Next()
+			return
+		}
+
+		principal, err := a.Authenticate(c.Request.Context(), c.GetHeader("Authorization"), c.GetHeader(APIKeyHeader))
+		if err != nil {
+			if !errors.Is(err, ErrMissingCredentials) {
+				a.Logger.Printf("Authentication failed - path: %s, client_ip: %s, error: %v", c.Request.URL.Path, c.ClientIP(), err)
+			}
+			c.Header("WWW-Authenticate", `Bearer realm="orden-compra"`)
+			c.AbortWithStatusJSON(401, gin.H{"success": false, "error": err.Error()})
+			return
+		}
+
+		c.Set(ContextKey, principal)
+		c.Request = c.Request.WithContext(NewContext(c.Request.Context(), principal))
+		c.Next()
+	}
+}
@@ -0,0 +1,126 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+
+	"orden-compra/internal/models"
+)
+
+// ErrPolicyNotFound is returned when a subject has no access policy
+var ErrPolicyNotFound = errors.New("access policy not found")
+
+// ErrInvalidPolicy is returned when an access policy is malformed
+var ErrInvalidPolicy = errors.New("invalid access policy")
+
+// PolicyStore persists access policy overrides in DynamoDB
+type PolicyStore struct {
+	DynamoDB  dynamodbiface.DynamoDBAPI
+	TableName string
+}
+
+// NewPolicyStore creates a store on the default access policy table
+func NewPolicyStore(dynamoDB dynamodbiface.DynamoDBAPI) *PolicyStore {
+	return &PolicyStore{
+		DynamoDB:  dynamoDB,
+		TableName: "orden-compra-access-policies",
+	}
+}
+
+// Save creates or replaces the policy of a subject
+func (s *PolicyStore) Save(ctx context.Context, policy *models.AccessPolicy) error {
+	item, err := dynamodbattribute.MarshalMap(policy)
+	if err != nil {
+		return fmt.Errorf("failed to marshal access policy: %w", err)
+	}
+
+	_, err = s.DynamoDB.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(s.TableName),
+		Item:      item,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put access policy: %w", err)
+	}
+
+	return nil
+}
+
+// Get returns the policy of a subject
+func (s *PolicyStore) Get(ctx context.Context, subject string) (*models.AccessPolicy, error) {
+	result, err := s.DynamoDB.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(s.TableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"subject": {S: aws.String(subject)},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get access policy: %w", err)
+	}
+	if result.Item == nil {
+		return nil, ErrPolicyNotFound
+	}
+
+	var policy models.AccessPolicy
+	if err := dynamodbattribute.UnmarshalMap(result.Item, &policy); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal access policy: %w", err)
+	}
+
+	return &policy, nil
+}
+
+// List returns every policy
+func (s *PolicyStore) List(ctx context.Context) ([]*models.AccessPolicy, error) {
+	scanInput := &dynamodb.ScanInput{
+		TableName: aws.String(s.TableName),
+	}
+
+	policies := make([]*models.AccessPolicy, 0)
+	for {
+		result, err := s.DynamoDB.ScanWithContext(ctx, scanInput)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan access policies: %w", err)
+		}
+
+		for _, item := range result.Items {
+			var policy models.AccessPolicy
+			if err := dynamodbattribute.UnmarshalMap(item, &policy); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal access policy: %w", err)
+			}
+			policies = append(policies, &policy)
+		}
+
+		if len(result.LastEvaluatedKey) == 0 {
+			break
+		}
+		scanInput.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+
+	return policies, nil
+}
+
+// Delete removes the policy of a subject, restoring the roles from its token
+func (s *PolicyStore) Delete(ctx context.Context, subject string) error {
+	_, err := s.DynamoDB.DeleteItemWithContext(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(s.TableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"subject": {S: aws.String(subject)},
+		},
+		ConditionExpression: aws.String("attribute_exists(subject)"),
+	})
+	if err != nil {
+		var awsErr awserr.Error
+		if errors.As(err, &awsErr) && awsErr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return ErrPolicyNotFound
+		}
+		return fmt.Errorf("failed to delete access policy: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,45 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"orden-compra/internal/memory"
+	"orden-compra/internal/models"
+)
+
+func TestPolicyStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewPolicyStore(memory.NewDynamoDB(memory.Tables))
+
+	if _, err := store.Get(ctx, "client-1"); !errors.Is(err, ErrPolicyNotFound) {
+		t.Fatalf("get before saving returned %v", err)
+	}
+
+	for _, subject := range []string{"client-1", "client-2"} {
+		if err := store.Save(ctx, models.NewAccessPolicy(subject, []string{models.RoleBuyer}, "admin-1", time.Now())); err != nil {
+			t.Fatalf("save policy: %v", err)
+		}
+	}
+	// Saving again replaces the roles
+	if err := store.Save(ctx, models.NewAccessPolicy("client-1", []string{models.RoleAdmin}, "admin-2", time.Now())); err != nil {
+		t.Fatalf("save policy: %v", err)
+	}
+
+	policy, err := store.Get(ctx, "client-1")
+	if err != nil || len(policy.Roles) != 1 || policy.Roles[0] != models.RoleAdmin || policy.UpdatedBy != "admin-2" {
+		t.Fatalf("policy %+v, error %v", policy, err)
+	}
+	if policies, err := store.List(ctx); err != nil || len(policies) != 2 {
+		t.Fatalf("listed %d policies, error %v", len(policies), err)
+	}
+
+	if err := store.Delete(ctx, "client-1"); err != nil {
+		t.Fatalf("delete policy: %v", err)
+	}
+	if err := store.Delete(ctx, "client-1"); !errors.Is(err, ErrPolicyNotFound) {
+		t.Fatalf("deleting twice returned %v", err)
+	}
+}
@@ -0,0 +1,154 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"orden-compra/internal/models"
+)
+
+// Permission is an action guarded by RBAC
+type Permission string
+
+// Permissions checked by the API
+const (
+	PermissionQuery         Permission = "query"
+	PermissionManageOrders  Permission = "orders:manage"
+	PermissionApproveOrders Permission = "orders:approve"
+	PermissionAdmin         Permission = "admin"
+)
+
+// rolePermissions grants permissions to roles. Admins hold every permission.
+var rolePermissions = map[string][]Permission{
+	models.RoleViewer:   {PermissionQuery},
+	models.RoleBuyer:    {PermissionQuery, PermissionManageOrders},
+	models.RoleApprover: {PermissionQuery, PermissionApproveOrders},
+	models.RoleAdmin:    {PermissionQuery, PermissionManageOrders, PermissionApproveOrders, PermissionAdmin},
+}
+
+// ErrForbidden is returned when the caller lacks a permission
+var ErrForbidden = errors.New("forbidden")
+
+// policyCacheTTL bounds how long policy overrides are cached per subject
+const policyCacheTTL = 30 * time.Second
+
+// cachedPolicy is a policy lookup result; policy is nil when the subject has none
+type cachedPolicy struct {
+	policy    *models.AccessPolicy
+	fetchedAt time.Time
+}
+
+// Authorizer checks permissions from the caller's roles. Roles come from the
+// credentials unless the policy table overrides them for the subject.
+type Authorizer struct {
+	Store  *PolicyStore
+	Logger *log.Logger
+
+	mu    sync.Mutex
+	cache map[string]cachedPolicy
+}
+
+// NewAuthorizer creates an authorizer
+func NewAuthorizer(store *PolicyStore, logger *log.Logger) *Authorizer {
+	return &Authorizer{
+		Store:  store,
+		Logger: logger,
+		cache:  make(map[string]cachedPolicy),
+	}
+}
+
+// Roles returns the effective roles of a principal
+func (a *Authorizer) Roles(ctx context.Context, principal *Principal) ([]string, error) {
+	policy, err := a.policy(ctx, principal.Subject)
+	if err != nil {
+		return nil, err
+	}
+	if policy != nil {
+		return policy.Roles, nil
+	}
+	return principal.Roles, nil
+}
+
+// Authorize checks that the principal in ctx holds the permission
+func (a *Authorizer) Authorize(ctx context.Context, permission Permission) error {
+	principal, ok := FromContext(ctx)
+	if !ok {
+		return fmt.Errorf("%w: no authenticated principal", ErrForbidden)
+	}
+
+	roles, err := a.Roles(ctx, principal)
+	if err != nil {
+		return err
+	}
+
+	for _, role := range roles {
+		for _, granted := range rolePermissions[role] {
+			if granted == permission {
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("%w: %s requires the %s permission", ErrForbidden, principal.Subject, permission)
+}
+
+// Require returns a middleware rejecting callers without the permission. A nil
+// authorizer allows every request, as when authentication is disabled.
+func (a *Authorizer) Require(permission Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if a == nil {
+			c.Next()
+			return
+		}
+
+		if err := a.Authorize(c.Request.Context(), permission); err != nil {
+			status := 403
+			if !errors.Is(err, ErrForbidden) {
+				status = 500
+			}
+			a.Logger.Printf("Authorization failed - path: %s, permission: %s, error: %v", c.Request.URL.Path, permission, err)
+			c.AbortWithStatusJSON(status, gin.H{"success": false, "error": err.Error()})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// Invalidate drops the cached policy of a subject after it changes
+func (a *Authorizer) Invalidate(subject string) {
+	if a == nil {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.cache, subject)
+}
+
+// policy returns the override of a subject, or nil when it has none
+func (a *Authorizer) policy(ctx context.Context, subject string) (*models.AccessPolicy, error) {
+	a.mu.Lock()
+	cached, ok := a.cache[subject]
+	a.mu.Unlock()
+	if ok && time.Since(cached.fetchedAt) < policyCacheTTL {
+		return cached.policy, nil
+	}
+
+	policy, err := a.Store.Get(ctx, subject)
+	if err != nil && !errors.Is(err, ErrPolicyNotFound) {
+		return nil, fmt.Errorf("failed to load access policy: %w", err)
+	}
+
+	a.mu.Lock()
+	a.cache[subject] = cachedPolicy{policy: policy, fetchedAt: time.Now()}
+	a.mu.Unlock()
+
+	return policy, nil
+}
@@ -0,0 +1,131 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/gin-gonic/gin"
+
+	"orden-compra/internal/memory"
+	"orden-compra/internal/models"
+)
+
+// unavailableDynamoDB fails every policy lookup
+type unavailableDynamoDB struct {
+	dynamodbiface.DynamoDBAPI
+}
+
+func (unavailableDynamoDB) GetItemWithContext(aws.Context, *dynamodb.GetItemInput, ...request.Option) (*dynamodb.GetItemOutput, error) {
+	return nil, errors.New("dynamodb unavailable")
+}
+
+func newAuthorizer() (*Authorizer, *PolicyStore) {
+	store := NewPolicyStore(memory.NewDynamoDB(memory.Tables))
+	return NewAuthorizer(store, log.New(io.Discard, "", 0)), store
+}
+
+func principalContext(subject string, roles ...string) context.Context {
+	return NewContext(context.Background(), &Principal{Subject: subject, Method: "api_key", Roles: roles})
+}
+
+func TestAuthorizeFollowsTheRolePermissions(t *testing.T) {
+	authorizer, _ := newAuthorizer()
+
+	for _, tc := range []struct {
+		role    string
+		granted []Permission
+	}{
+		{models.RoleViewer, []Permission{PermissionQuery}},
+		{models.RoleBuyer, []Permission{PermissionQuery, PermissionManageOrders}},
+		{models.RoleApprover, []Permission{PermissionQuery, PermissionApproveOrders}},
+		{models.RoleAdmin, []Permission{PermissionQuery, PermissionManageOrders, PermissionApproveOrders, PermissionAdmin}},
+		{"unknown", nil},
+	} {
+		t.Run(tc.role, func(t *testing.T) {
+			ctx := principalContext("client-"+tc.role, tc.role)
+			for _, permission := range []Permission{PermissionQuery, PermissionManageOrders, PermissionApproveOrders, PermissionAdmin} {
+				want := false
+				for _, granted := range tc.granted {
+					want = want || granted == permission
+				}
+				if err := authorizer.Authorize(ctx, permission); (err == nil) != want || (err != nil && !errors.Is(err, ErrForbidden)) {
+					t.Fatalf("%s: Authorize returned %v, want granted %v", permission, err, want)
+				}
+			}
+		})
+	}
+
+	// Callers without a principal are never authorized
+	if err := authorizer.Authorize(context.Background(), PermissionQuery); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("anonymous caller: Authorize returned %v", err)
+	}
+}
+
+func TestPolicyOverridesTheTokenRoles(t *testing.T) {
+	authorizer, store := newAuthorizer()
+	ctx := principalContext("client-1", models.RoleViewer)
+
+	if err := store.Save(ctx, models.NewAccessPolicy("client-1", []string{models.RoleApprover}, "admin-1", time.Now())); err != nil {
+		t.Fatalf("save policy: %v", err)
+	}
+	if err := authorizer.Authorize(ctx, PermissionApproveOrders); err != nil {
+		t.Fatalf("override was not applied: %v", err)
+	}
+
+	// The cached override applies until it is invalidated
+	if err := store.Delete(ctx, "client-1"); err != nil {
+		t.Fatalf("delete policy: %v", err)
+	}
+	if err := authorizer.Authorize(ctx, PermissionApproveOrders); err != nil {
+		t.Fatalf("cached override was dropped before invalidating: %v", err)
+	}
+	authorizer.Invalidate("client-1")
+	if err := authorizer.Authorize(ctx, PermissionApproveOrders); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("token roles did not apply again: %v", err)
+	}
+	if roles, err := authorizer.Roles(ctx, &Principal{Subject: "client-1", Roles: []string{models.RoleViewer}}); err != nil || len(roles) != 1 || roles[0] != models.RoleViewer {
+		t.Fatalf("roles %v, error %v", roles, err)
+	}
+}
+
+func TestRequire(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	authorizer, _ := newAuthorizer()
+	unavailable := NewAuthorizer(NewPolicyStore(unavailableDynamoDB{}), log.New(io.Discard, "", 0))
+
+	for _, tc := range []struct {
+		name       string
+		authorizer *Authorizer
+		roles      []string
+		want       int
+	}{
+		{"granted", authorizer, []string{models.RoleBuyer}, http.StatusOK},
+		{"missing the permission", authorizer, []string{models.RoleViewer}, http.StatusForbidden},
+		{"policy lookup failure", unavailable, []string{models.RoleBuyer}, http.StatusInternalServerError},
+		{"authorization disabled", nil, nil, http.StatusOK},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			router := gin.New()
+			router.Use(func(c *gin.Context) {
+				c.Request = c.Request.WithContext(principalContext("client-1", tc.roles...))
+			})
+			router.POST("/orders", tc.authorizer.Require(PermissionManageOrders), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/orders", nil))
+			if recorder.Code != tc.want {
+				t.Fatalf("status %d, want %d: %s", recorder.Code, tc.want, recorder.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,409 @@
+// Package batch groups the unconditional item writes of concurrent callers
+// into BatchWriteItem calls, so bursts of StockBajo events cost a request
+// per batch of events stored rather than one per event. Callers still wait
+// until their own item is written and get its own error, so a message is
+// only acknowledged once what it wrote is stored.
+package batch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// maxBatchSize is the most items DynamoDB takes in one BatchWriteItem call
+const maxBatchSize = 25
+
+var (
+	// ErrInvalidConfig is returned for unusable batching settings
+	ErrInvalidConfig = errors.New("invalid batch write configuration")
+	// ErrUnprocessed is returned for items DynamoDB left unprocessed on
+	// every attempt, as when the table stays throttled
+	ErrUnprocessed = errors.New("item left unprocessed by batch write")
+)
+
+// Config represents the batching settings
+type Config struct {
+	Enabled bool
+	// Size is the number of items that flushes a batch at once, at most 25
+	Size int
+	// Interval is how long the first item of a batch waits for others
+	Interval time.Duration
+	// MaxAttempts bounds the writes of items DynamoDB leaves unprocessed
+	MaxAttempts int
+}
+
+// Validate checks that the batching settings are usable
+func (c Config) Validate() error {
+	if c.Size < 1 || c.Size > maxBatchSize {
+		return fmt.Errorf("%w: size must be between 1 and %d", ErrInvalidConfig, maxBatchSize)
+	}
+	if c.Interval <= 0 {
+		return fmt.Errorf("%w: interval must be positive", ErrInvalidConfig)
+	}
+	if c.MaxAttempts < 1 {
+		return fmt.Errorf("%w: max attempts must be at least 1", ErrInvalidConfig)
+	}
+	return nil
+}
+
+// write is an item waiting to be written and the caller waiting for it
+type write struct {
+	table  string
+	key    string
+	item   map[string]*dynamodb.AttributeValue
+	result chan error
+
+	// attempts counts the batches the item was sent in, and due is when an
+	// item DynamoDB left unprocessed is sent again
+	attempts int
+	due      time.Time
+}
+
+// BatchedDynamoDB wraps a DynamoDB client so PutItem calls to the tables
+// listed in Tables are written in batches. Puts with a condition or asking
+// for the old item cannot be batched and go through as they are.
+type BatchedDynamoDB struct {
+	dynamodbiface.DynamoDBAPI
+	Config Config
+	// Tables maps the batched tables to their key attributes, which tell
+	// two writes of the same item apart; those never share a batch, as
+	// DynamoDB refuses them and their order would be lost
+	Tables map[string][]string
+	Logger *log.Logger
+
+	mu     sync.RWMutex
+	closed bool
+	writes chan *write
+	done   chan struct{}
+
+	batchSize   metric.Int64Histogram
+	unprocessed metric.Int64Counter
+}
+
+// NewBatchedDynamoDB creates a BatchedDynamoDB and starts batching
+func NewBatchedDynamoDB(client dynamodbiface.DynamoDBAPI, config Config, tables map[string][]string, logger *log.Logger) *BatchedDynamoDB {
+	meter := otel.Meter("orden-compra/batch")
+	batchSize, _ := meter.Int64Histogram(
+		"dynamodb_batch_write_items",
+		metric.WithDescription("Items written per BatchWriteItem call"),
+		metric.WithExplicitBucketBoundaries(1, 2, 5, 10, 15, 20, 25),
+	)
+	unprocessed, _ := meter.Int64Counter(
+		"dynamodb_batch_write_unprocessed_total",
+		metric.WithDescription("Items DynamoDB left unprocessed in a BatchWriteItem call, written again or failed"),
+	)
+
+	d := &BatchedDynamoDB{
+		DynamoDBAPI: client,
+		Config:      config,
+		Tables:      tables,
+		Logger:      logger,
+		writes:      make(chan *write, maxBatchSize),
+		done:        make(chan struct{}),
+		batchSize:   batchSize,
+		unprocessed: unprocessed,
+	}
+	go d.run()
+	return d
+}
+
+// PutItemWithContext queues an item for the next batch and waits until it
+// is written, or puts it at once when it cannot be batched. When ctx ends
+// first the item may still be written.
+func (d *BatchedDynamoDB) PutItemWithContext(ctx aws.Context, input *dynamodb.PutItemInput, opts ...request.Option) (*dynamodb.PutItemOutput, error) {
+	key, ok := d.key(input)
+	if !ok {
+		return d.DynamoDBAPI.PutItemWithContext(ctx, input, opts...)
+	}
+
+	w := &write{table: aws.StringValue(input.TableName), key: key, item: input.Item, result: make(chan error, 1)}
+	d.mu.RLock()
+	if d.closed {
+		d.mu.RUnlock()
+		return d.DynamoDBAPI.PutItemWithContext(ctx, input, opts...)
+	}
+	select {
+	case d.writes <- w:
+		d.mu.RUnlock()
+	case <-ctx.Done():
+		d.mu.RUnlock()
+		return nil, ctx.Err()
+	}
+
+	select {
+	case err := <-w.result:
+		if err != nil {
+			return nil, err
+		}
+		return &dynamodb.PutItemOutput{}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Close writes the queued items and stops batching; later puts go through
+// one by one
+func (d *BatchedDynamoDB) Close() {
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		close(d.writes)
+	}
+	d.mu.Unlock()
+	<-d.done
+}
+
+// key returns the key identifying the item of a batchable put
+func (d *BatchedDynamoDB) key(input *dynamodb.PutItemInput) (string, bool) {
+	attributes, ok := d.Tables[aws.StringValue(input.TableName)]
+	if !ok || input.ConditionExpression != nil || input.Expected != nil {
+		return "", false
+	}
+	if returnValues := aws.StringValue(input.ReturnValues); returnValues != "" && returnValues != dynamodb.ReturnValueNone {
+		return "", false
+	}
+
+	var key strings.Builder
+	key.WriteString(aws.StringValue(input.TableName))
+	for _, attribute := range attributes {
+		value, ok := input.Item[attribute]
+		if !ok {
+			// Let DynamoDB report the missing key
+			return "", false
+		}
+		key.WriteString("/" + value.String())
+	}
+	return key.String(), true
+}
+
+// run collects writes into batches, flushing one once it is full or its
+// first item has waited the interval. An item DynamoDB leaves unprocessed is
+// sent again after a growing backoff without holding up the other writes;
+// later writes of the same item wait for it, so writes of the same item are
+// stored in the order they were made. After Close it returns once the items
+// waiting to be sent again are written.
+func (d *BatchedDynamoDB) run() {
+	defer close(d.done)
+
+	b := &batcher{
+		d:        d,
+		keys:     make(map[string]bool),
+		retrying: make(map[string]bool),
+		heldKeys: make(map[string]int),
+	}
+	writes := d.writes
+	for writes != nil || len(b.retries) > 0 {
+		select {
+		case w, ok := <-writes:
+			if !ok {
+				writes = nil
+				b.flush()
+				continue
+			}
+			b.add(w)
+		case <-b.timeout:
+			b.timer, b.timeout = nil, nil
+			b.flush()
+		case <-b.retryTimeout:
+			b.retryTimer, b.retryTimeout = nil, nil
+			b.retryDue()
+		}
+	}
+}
+
+// batcher is the state of the run goroutine: the batch being collected, the
+// items waiting to be sent again, and the later writes held behind them
+type batcher struct {
+	d *BatchedDynamoDB
+
+	batch   []*write
+	keys    map[string]bool
+	timer   *time.Timer
+	timeout <-chan time.Time
+
+	retries      []*write
+	retrying     map[string]bool
+	retryTimer   *time.Timer
+	retryTimeout <-chan time.Time
+
+	held     []*write
+	heldKeys map[string]int
+}
+
+// add puts a write in the batch, flushing the batch first when it already
+// holds the same item, or holds the write while an earlier write of the
+// item waits to be sent again
+func (b *batcher) add(w *write) {
+	if b.keys[w.key] {
+		b.flush()
+	}
+	if b.retrying[w.key] || b.heldKeys[w.key] > 0 {
+		b.held = append(b.held, w)
+		b.heldKeys[w.key]++
+		return
+	}
+
+	b.batch = append(b.batch, w)
+	b.keys[w.key] = true
+	if len(b.batch) >= b.d.Config.Size {
+		b.flush()
+	} else if b.timer == nil {
+		b.timer = time.NewTimer(b.d.Config.Interval)
+		b.timeout = b.timer.C
+	}
+}
+
+// flush writes the batch and schedules the items left unprocessed
+func (b *batcher) flush() {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer, b.timeout = nil, nil
+	}
+	batch := b.batch
+	b.batch = nil
+	b.keys = make(map[string]bool)
+
+	for _, w := range b.d.flush(batch) {
+		w.due = time.Now().Add(b.d.backoff(w.attempts))
+		b.retries = append(b.retries, w)
+		b.retrying[w.key] = true
+	}
+	b.schedule()
+}
+
+// retryDue sends again the items whose backoff has passed, together with
+// the writes held behind them
+func (b *batcher) retryDue() {
+	now := time.Now()
+	var due, later []*write
+	for _, w := range b.retries {
+		if w.due.After(now) {
+			later = append(later, w)
+			continue
+		}
+		due = append(due, w)
+		delete(b.retrying, w.key)
+	}
+	b.retries = later
+
+	// The held writes go after the earlier writes of their items, and back
+	// to being held behind those not yet due
+	held := b.held
+	b.held, b.heldKeys = nil, make(map[string]int)
+	for _, w := range append(due, held...) {
+		b.add(w)
+	}
+	b.flush()
+}
+
+// schedule sets the retry timer for the earliest item waiting to be sent again
+func (b *batcher) schedule() {
+	if b.retryTimer != nil {
+		b.retryTimer.Stop()
+		b.retryTimer, b.retryTimeout = nil, nil
+	}
+	if len(b.retries) == 0 {
+		return
+	}
+	next := b.retries[0].due
+	for _, w := range b.retries[1:] {
+		if w.due.Before(next) {
+			next = w.due
+		}
+	}
+	b.retryTimer = time.NewTimer(time.Until(next))
+	b.retryTimeout = b.retryTimer.C
+}
+
+// backoff is how long an item left unprocessed in its given attempt waits
+// before it is sent again, doubling from the interval up to about a second
+func (d *BatchedDynamoDB) backoff(attempts int) time.Duration {
+	backoff := d.Config.Interval
+	for i := 1; i < attempts && backoff < time.Second; i++ {
+		backoff *= 2
+	}
+	return backoff
+}
+
+// flush writes a batch in one BatchWriteItem call and tells each caller how
+// its item fared. It returns the items DynamoDB left unprocessed that have
+// attempts left; those that have none fail with ErrUnprocessed. When the
+// call itself fails, as for an item DynamoDB refuses, the items are put one
+// by one so each caller gets its own error.
+func (d *BatchedDynamoDB) flush(batch []*write) []*write {
+	if len(batch) == 0 {
+		return nil
+	}
+	ctx := context.Background()
+
+	requestItems := make(map[string][]*dynamodb.WriteRequest)
+	for _, w := range batch {
+		w.attempts++
+		requestItems[w.table] = append(requestItems[w.table], &dynamodb.WriteRequest{PutRequest: &dynamodb.PutRequest{Item: w.item}})
+	}
+	d.batchSize.Record(ctx, int64(len(batch)))
+
+	output, err := d.DynamoDBAPI.BatchWriteItemWithContext(ctx, &dynamodb.BatchWriteItemInput{RequestItems: requestItems})
+	if err != nil {
+		d.Logger.Printf("Batch write of %d items failed, putting them one by one: %v", len(batch), err)
+		d.putEach(ctx, batch)
+		return nil
+	}
+
+	unprocessed := make(map[string]bool)
+	for table, requests := range output.UnprocessedItems {
+		for _, r := range requests {
+			if r.PutRequest == nil {
+				continue
+			}
+			if key, ok := d.key(&dynamodb.PutItemInput{TableName: aws.String(table), Item: r.PutRequest.Item}); ok {
+				unprocessed[key] = true
+			}
+		}
+	}
+	var retry, failed []*write
+	for _, w := range batch {
+		switch {
+		case !unprocessed[w.key]:
+			w.result <- nil
+		case w.attempts >= d.Config.MaxAttempts:
+			failed = append(failed, w)
+		default:
+			retry = append(retry, w)
+		}
+	}
+	if n := len(retry) + len(failed); n > 0 {
+		d.unprocessed.Add(ctx, int64(n))
+	}
+
+	if len(failed) > 0 {
+		d.Logger.Printf("Batch write left %d items unprocessed after %d attempts", len(failed), d.Config.MaxAttempts)
+		for _, w := range failed {
+			w.result <- fmt.Errorf("%w: %s after %d attempts", ErrUnprocessed, w.key, w.attempts)
+		}
+	}
+	return retry
+}
+
+// putEach puts the items of a failed batch one by one
+func (d *BatchedDynamoDB) putEach(ctx context.Context, writes []*write) {
+	for _, w := range writes {
+		_, err := d.DynamoDBAPI.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+			TableName: aws.String(w.table),
+			Item:      w.item,
+		})
+		w.result <- err
+	}
+}
@@ -0,0 +1,344 @@
+package batch
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+var errThrottled = errors.New("provisioned throughput exceeded")
+
+// fakeDynamoDB records the writes it gets as "id:version". leave reports
+// whether a batch leaves a write unprocessed on its given attempt, and
+// batchErr and putErrs fail batch calls and single puts.
+type fakeDynamoDB struct {
+	dynamodbiface.DynamoDBAPI
+
+	mu       sync.Mutex
+	batches  [][]string
+	puts     []string
+	written  []string
+	attempts map[string]int
+	leave    func(write string, attempt int) bool
+	batchErr error
+	putErrs  map[string]error
+}
+
+func newFakeDynamoDB() *fakeDynamoDB {
+	return &fakeDynamoDB{attempts: make(map[string]int), putErrs: make(map[string]error)}
+}
+
+func name(item map[string]*dynamodb.AttributeValue) string {
+	return aws.StringValue(item["id"].S) + ":" + aws.StringValue(item["version"].N)
+}
+
+func (f *fakeDynamoDB) BatchWriteItemWithContext(ctx aws.Context, input *dynamodb.BatchWriteItemInput, opts ...request.Option) (*dynamodb.BatchWriteItemOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var batch []string
+	unprocessed := make(map[string][]*dynamodb.WriteRequest)
+	for table, requests := range input.RequestItems {
+		for _, r := range requests {
+			write := name(r.PutRequest.Item)
+			batch = append(batch, write)
+			f.attempts[write]++
+			if f.leave != nil && f.leave(write, f.attempts[write]) {
+				unprocessed[table] = append(unprocessed[table], r)
+				continue
+			}
+			if f.batchErr == nil {
+				f.written = append(f.written, write)
+			}
+		}
+	}
+	f.batches = append(f.batches, batch)
+	if f.batchErr != nil {
+		return nil, f.batchErr
+	}
+	return &dynamodb.BatchWriteItemOutput{UnprocessedItems: unprocessed}, nil
+}
+
+func (f *fakeDynamoDB) PutItemWithContext(ctx aws.Context, input *dynamodb.PutItemInput, opts ...request.Option) (*dynamodb.PutItemOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	write := name(input.Item)
+	f.puts = append(f.puts, write)
+	if err := f.putErrs[write]; err != nil {
+		return nil, err
+	}
+	f.written = append(f.written, write)
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+// calls returns the batch calls made and the items written so far
+func (f *fakeDynamoDB) calls() ([][]string, []string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([][]string(nil), f.batches...), append([]string(nil), f.written...)
+}
+
+func newBatched(t *testing.T, client *fakeDynamoDB, config Config) *BatchedDynamoDB {
+	t.Helper()
+	if err := config.Validate(); err != nil {
+		t.Fatalf("config: %v", err)
+	}
+	d := NewBatchedDynamoDB(client, config, map[string][]string{"orden-compra-events": {"id"}}, log.New(io.Discard, "", 0))
+	t.Cleanup(d.Close)
+	return d
+}
+
+func put(id, version string) *dynamodb.PutItemInput {
+	return &dynamodb.PutItemInput{
+		TableName: aws.String("orden-compra-events"),
+		Item: map[string]*dynamodb.AttributeValue{
+			"id":      {S: aws.String(id)},
+			"version": {N: aws.String(version)},
+		},
+	}
+}
+
+// putAll puts the items concurrently and returns each caller's error by item
+func putAll(d *BatchedDynamoDB, inputs ...*dynamodb.PutItemInput) map[string]error {
+	var mu sync.Mutex
+	errs := make(map[string]error)
+	var wg sync.WaitGroup
+	for _, input := range inputs {
+		wg.Add(1)
+		go func(input *dynamodb.PutItemInput) {
+			defer wg.Done()
+			_, err := d.PutItemWithContext(context.Background(), input)
+			mu.Lock()
+			errs[name(input.Item)] = err
+			mu.Unlock()
+		}(input)
+	}
+	wg.Wait()
+	return errs
+}
+
+func TestConcurrentPutsShareABatch(t *testing.T) {
+	client := newFakeDynamoDB()
+	d := newBatched(t, client, Config{Size: 3, Interval: time.Hour, MaxAttempts: 1})
+
+	// A full batch is written at once, without waiting the interval
+	errs := putAll(d, put("e1", "1"), put("e2", "1"), put("e3", "1"))
+	for write, err := range errs {
+		if err != nil {
+			t.Fatalf("put %s: %v", write, err)
+		}
+	}
+	if batches, written := client.calls(); len(batches) != 1 || len(batches[0]) != 3 || len(written) != 3 {
+		t.Fatalf("batches %v wrote %v, want one batch of 3", batches, written)
+	}
+}
+
+func TestAPartialBatchIsWrittenAfterTheInterval(t *testing.T) {
+	client := newFakeDynamoDB()
+	d := newBatched(t, client, Config{Size: 25, Interval: 20 * time.Millisecond, MaxAttempts: 1})
+
+	start := time.Now()
+	if _, err := d.PutItemWithContext(context.Background(), put("e1", "1")); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	if waited := time.Since(start); waited < 20*time.Millisecond {
+		t.Fatalf("written after %v, before the interval", waited)
+	}
+}
+
+func TestWritesOfTheSameItemAreStoredInOrder(t *testing.T) {
+	client := newFakeDynamoDB()
+	d := newBatched(t, client, Config{Size: 25, Interval: 10 * time.Millisecond, MaxAttempts: 1})
+
+	results := make(chan error, 3)
+	for _, version := range []string{"1", "2", "3"} {
+		go func(version string) {
+			_, err := d.PutItemWithContext(context.Background(), put("e1", version))
+			results <- err
+		}(version)
+		// Queue them in order
+		time.Sleep(time.Millisecond)
+	}
+	for i := 0; i < 3; i++ {
+		if err := <-results; err != nil {
+			t.Fatalf("put: %v", err)
+		}
+	}
+	batches, written := client.calls()
+	if len(batches) != 3 || written[0] != "e1:1" || written[1] != "e1:2" || written[2] != "e1:3" {
+		t.Fatalf("batches %v wrote %v, want each version in its own batch in order", batches, written)
+	}
+}
+
+func TestUnprocessedItemsAreWrittenAgain(t *testing.T) {
+	client := newFakeDynamoDB()
+	client.leave = func(write string, attempt int) bool { return write == "e2:1" && attempt < 3 }
+	d := newBatched(t, client, Config{Size: 3, Interval: time.Millisecond, MaxAttempts: 3})
+
+	errs := putAll(d, put("e1", "1"), put("e2", "1"), put("e3", "1"))
+	for write, err := range errs {
+		if err != nil {
+			t.Fatalf("put %s: %v", write, err)
+		}
+	}
+	batches, written := client.calls()
+	if len(batches) != 3 || len(batches[1]) != 1 || batches[1][0] != "e2:1" || len(written) != 3 {
+		t.Fatalf("batches %v wrote %v, want e2 sent alone twice more", batches, written)
+	}
+}
+
+func TestItemsUnprocessedOnEveryAttemptFailAlone(t *testing.T) {
+	client := newFakeDynamoDB()
+	client.leave = func(write string, attempt int) bool { return write == "e2:1" }
+	d := newBatched(t, client, Config{Size: 2, Interval: time.Millisecond, MaxAttempts: 2})
+
+	errs := putAll(d, put("e1", "1"), put("e2", "1"))
+	if errs["e1:1"] != nil {
+		t.Fatalf("put e1: %v", errs["e1:1"])
+	}
+	if !errors.Is(errs["e2:1"], ErrUnprocessed) {
+		t.Fatalf("put e2 returned %v, want ErrUnprocessed", errs["e2:1"])
+	}
+	if batches, _ := client.calls(); len(batches) != 2 {
+		t.Fatalf("batches %v, want max attempts 2", batches)
+	}
+}
+
+func TestAFailedBatchIsPutOneByOne(t *testing.T) {
+	client := newFakeDynamoDB()
+	client.batchErr = errors.New("ValidationException: item size exceeded")
+	client.putErrs["e2:1"] = errThrottled
+	d := newBatched(t, client, Config{Size: 3, Interval: time.Millisecond, MaxAttempts: 3})
+
+	errs := putAll(d, put("e1", "1"), put("e2", "1"), put("e3", "1"))
+	if errs["e1:1"] != nil || errs["e3:1"] != nil || !errors.Is(errs["e2:1"], errThrottled) {
+		t.Fatalf("errors %v, want only e2 to fail with its own error", errs)
+	}
+	if _, written := client.calls(); len(written) != 2 {
+		t.Fatalf("wrote %v, want e1 and e3", written)
+	}
+}
+
+func TestAnItemWaitingToBeSentAgainDoesNotHoldUpOtherWrites(t *testing.T) {
+	client := newFakeDynamoDB()
+	client.leave = func(write string, attempt int) bool { return write == "e1:1" && attempt == 1 }
+	d := newBatched(t, client, Config{Size: 1, Interval: 200 * time.Millisecond, MaxAttempts: 2})
+
+	first := make(chan error, 1)
+	go func() {
+		_, err := d.PutItemWithContext(context.Background(), put("e1", "1"))
+		first <- err
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for batches, _ := client.calls(); len(batches) == 0; batches, _ = client.calls() {
+		if time.Now().After(deadline) {
+			t.Fatal("e1 was never sent")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// Another item goes through while e1 backs off
+	start := time.Now()
+	if _, err := d.PutItemWithContext(context.Background(), put("e2", "1")); err != nil {
+		t.Fatalf("put e2: %v", err)
+	}
+	if waited := time.Since(start); waited >= 200*time.Millisecond {
+		t.Fatalf("e2 waited %v behind the backoff of e1", waited)
+	}
+
+	// A later write of e1 waits for the earlier one
+	if _, err := d.PutItemWithContext(context.Background(), put("e1", "2")); err != nil {
+		t.Fatalf("put e1 again: %v", err)
+	}
+	if err := <-first; err != nil {
+		t.Fatalf("put e1: %v", err)
+	}
+	if _, written := client.calls(); len(written) != 3 || written[0] != "e2:1" || written[1] != "e1:1" || written[2] != "e1:2" {
+		t.Fatalf("wrote %v, want e2 then both versions of e1 in order", written)
+	}
+}
+
+func TestPutsThatCannotBeBatchedGoThroughAtOnce(t *testing.T) {
+	client := newFakeDynamoDB()
+	d := newBatched(t, client, Config{Size: 25, Interval: time.Hour, MaxAttempts: 1})
+
+	conditional := put("e1", "1")
+	conditional.ConditionExpression = aws.String("attribute_not_exists(id)")
+	other := put("p1", "1")
+	other.TableName = aws.String("orden-compra-read")
+	for _, input := range []*dynamodb.PutItemInput{conditional, other} {
+		if _, err := d.PutItemWithContext(context.Background(), input); err != nil {
+			t.Fatalf("put: %v", err)
+		}
+	}
+	if batches, written := client.calls(); len(batches) != 0 || len(written) != 2 {
+		t.Fatalf("batches %v wrote %v, want two single puts", batches, written)
+	}
+}
+
+func TestCloseWaitsForTheItemsToBeSentAgain(t *testing.T) {
+	client := newFakeDynamoDB()
+	client.leave = func(write string, attempt int) bool { return write == "e2:1" && attempt == 1 }
+	d := newBatched(t, client, Config{Size: 2, Interval: 50 * time.Millisecond, MaxAttempts: 2})
+
+	results := make(chan error, 2)
+	for _, id := range []string{"e1", "e2"} {
+		go func(id string) {
+			_, err := d.PutItemWithContext(context.Background(), put(id, "1"))
+			results <- err
+		}(id)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for batches, _ := client.calls(); len(batches) == 0; batches, _ = client.calls() {
+		if time.Now().After(deadline) {
+			t.Fatal("the batch was never sent")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// e2 backs off; Close returns once it is written
+	d.Close()
+	if batches, written := client.calls(); len(batches) != 2 || len(written) != 2 {
+		t.Fatalf("batches %v wrote %v on close, want e2 sent again", batches, written)
+	}
+	for i := 0; i < 2; i++ {
+		if err := <-results; err != nil {
+			t.Fatalf("put: %v", err)
+		}
+	}
+
+	// Later puts go through one by one
+	if _, err := d.PutItemWithContext(context.Background(), put("e3", "1")); err != nil {
+		t.Fatalf("put after close: %v", err)
+	}
+	if _, written := client.calls(); len(written) != 3 || len(client.puts) != 1 {
+		t.Fatalf("wrote %v with %d single puts after close", written, len(client.puts))
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	for _, tc := range []struct {
+		config Config
+		valid  bool
+	}{
+		{Config{Size: 25, Interval: time.Millisecond, MaxAttempts: 1}, true},
+		{Config{Size: 0, Interval: time.Millisecond, MaxAttempts: 1}, false},
+		{Config{Size: 26, Interval: time.Millisecond, MaxAttempts: 1}, false},
+		{Config{Size: 25, Interval: 0, MaxAttempts: 1}, false},
+		{Config{Size: 25, Interval: time.Millisecond, MaxAttempts: 0}, false},
+	} {
+		if err := tc.config.Validate(); (err == nil) != tc.valid || (err != nil && !errors.Is(err, ErrInvalidConfig)) {
+			t.Errorf("Validate(%+v) returned %v", tc.config, err)
+		}
+	}
+}
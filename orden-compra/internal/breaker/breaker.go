@@ -0,0 +1,357 @@
+// Package breaker implements circuit breakers around downstream
+// dependencies. After repeated failures a breaker opens and calls fail at
+// once, instead of each waiting out a timeout against a degraded dependency.
+// Once the open timeout passes a few probe calls are let through, and the
+// breaker closes again when they succeed.
+package breaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"medisupply/clock"
+)
+
+// ErrOpen is returned for calls refused by an open breaker
+var ErrOpen = errors.New("circuit breaker open")
+
+// State is the state of a breaker
+type State string
+
+const (
+	// Closed lets every call through
+	Closed State = "closed"
+	// Open refuses every call until the open timeout passes
+	Open State = "open"
+	// HalfOpen lets a limited number of probe calls through
+	HalfOpen State = "half_open"
+)
+
+// OpenError is returned for a refused call; it matches ErrOpen
+type OpenError struct {
+	Dependency string
+	// RetryAfter is how long until the breaker lets probe calls through
+	RetryAfter time.Duration
+}
+
+func (e *OpenError) Error() string {
+	return fmt.Sprintf("%s: %v, retry after %v", e.Dependency, ErrOpen, e.RetryAfter.Round(time.Millisecond))
+}
+
+// Is makes errors.Is(err, ErrOpen) hold
+func (e *OpenError) Is(target error) bool {
+	return target == ErrOpen
+}
+
+// Config represents the settings of a breaker
+type Config struct {
+	// FailureThreshold is the number of consecutive failures that opens the
+	// breaker; 0 disables it
+	FailureThreshold int `json:"failure_threshold"`
+	// OpenTimeout is how long the breaker stays open before probing
+	OpenTimeout time.Duration `json:"open_timeout"`
+	// HalfOpenRequests is the number of probe calls let through at once
+	// while half open; they must all succeed to close the breaker
+	HalfOpenRequests int `json:"half_open_requests"`
+}
+
+// Validate checks that the breaker settings are usable
+func (c Config) Validate() error {
+	if c.FailureThreshold < 0 {
+		return fmt.Errorf("failure_threshold must not be negative")
+	}
+	if c.FailureThreshold > 0 && c.OpenTimeout <= 0 {
+		return fmt.Errorf("open_timeout must be positive")
+	}
+	if c.FailureThreshold > 0 && c.HalfOpenRequests < 1 {
+		return fmt.Errorf("half_open_requests must be at least 1")
+	}
+	return nil
+}
+
+// Stats represents a point-in-time view of a breaker
+type Stats struct {
+	Name     string `json:"name"`
+	State    State  `json:"state"`
+	Failures int    `json:"consecutive_failures"`
+	Rejected int64  `json:"rejected"`
+}
+
+// Breaker guards calls to a single dependency
+type Breaker struct {
+	name      string
+	mu        sync.Mutex
+	config    Config
+	state     State
+	failures  int
+	openedAt  time.Time
+	probes    int
+	successes int
+	rejected  int64
+	metrics   *breakerMetrics
+	clock     clock.Clock
+}
+
+// Allow reports whether a call may go ahead. A permitted call must be
+// followed by Record with its outcome.
+func (b *Breaker) Allow(ctx context.Context) error {
+	if b == nil {
+		return nil
+	}
+
+	b.mu.Lock()
+	if b.config.FailureThreshold == 0 {
+		b.mu.Unlock()
+		return nil
+	}
+	if b.state == Open {
+		remaining := b.config.OpenTimeout - b.clock.Now().Sub(b.openedAt)
+		if remaining > 0 {
+			b.rejected++
+			b.mu.Unlock()
+			b.metrics.recordRejected(ctx, b.name)
+			return &OpenError{Dependency: b.name, RetryAfter: remaining}
+		}
+		b.transitionLocked(ctx, HalfOpen)
+	}
+	if b.state == HalfOpen {
+		if b.probes >= b.config.HalfOpenRequests {
+			b.rejected++
+			b.mu.Unlock()
+			b.metrics.recordRejected(ctx, b.name)
+			return &OpenError{Dependency: b.name, RetryAfter: b.config.OpenTimeout}
+		}
+		b.probes++
+	}
+	b.mu.Unlock()
+	return nil
+}
+
+// Record reports the outcome of a call permitted by Allow
+func (b *Breaker) Record(ctx context.Context, success bool) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.config.FailureThreshold == 0 {
+		return
+	}
+	switch b.state {
+	case Closed:
+		if success {
+			b.failures = 0
+			return
+		}
+		b.failures++
+		if b.failures >= b.config.FailureThreshold {
+			b.transitionLocked(ctx, Open)
+		}
+	case HalfOpen:
+		if !success {
+			b.failures++
+			b.transitionLocked(ctx, Open)
+			return
+		}
+		b.successes++
+		if b.successes >= b.config.HalfOpenRequests {
+			b.transitionLocked(ctx, Closed)
+		}
+	}
+}
+
+// Do runs fn unless the breaker is open. isFailure decides which errors
+// count against the dependency; the others, such as a conditional check
+// failing, show the dependency is up.
+func (b *Breaker) Do(ctx context.Context, fn func() error, isFailure func(error) bool) error {
+	if err := b.Allow(ctx); err != nil {
+		return err
+	}
+	err := fn()
+	if err != nil && (errors.Is(err, context.Canceled) || errors.Is(ctx.Err(), context.Canceled)) {
+		// The caller gave up; that says nothing about the dependency
+		b.release()
+		return err
+	}
+	b.Record(ctx, err == nil || !isFailure(err))
+	return err
+}
+
+// release returns the probe slot of a call that reported no outcome
+func (b *Breaker) release() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == HalfOpen && b.probes > 0 {
+		b.probes--
+	}
+}
+
+// Stats returns the current breaker state
+func (b *Breaker) Stats() Stats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	state := b.state
+	if state == Open && b.clock.Now().Sub(b.openedAt) >= b.config.OpenTimeout {
+		// The next call probes
+		state = HalfOpen
+	}
+	return Stats{
+		Name:     b.name,
+		State:    state,
+		Failures: b.failures,
+		Rejected: b.rejected,
+	}
+}
+
+// transitionLocked moves the breaker to a new state
+func (b *Breaker) transitionLocked(ctx context.Context, state State) {
+	if b.state == state {
+		return
+	}
+	b.metrics.recordTransition(ctx, b.name, b.state, state)
+	b.state = state
+	b.probes = 0
+	b.successes = 0
+	switch state {
+	case Open:
+		b.openedAt = b.clock.Now()
+	case Closed:
+		b.failures = 0
+	}
+}
+
+// Registry holds one breaker per downstream dependency
+type Registry struct {
+	// Clock times the open timeout of the breakers registered after it is
+	// set
+	Clock clock.Clock
+
+	mu       sync.RWMutex
+	breakers map[string]*Breaker
+	metrics  *breakerMetrics
+}
+
+// NewRegistry creates a new breaker registry and registers its state metrics
+func NewRegistry() *Registry {
+	r := &Registry{
+		Clock:    clock.System,
+		breakers: make(map[string]*Breaker),
+	}
+	r.metrics = newBreakerMetrics(r)
+	return r
+}
+
+// Register creates a breaker for the named dependency
+func (r *Registry) Register(name string, config Config) (*Breaker, error) {
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid circuit breaker config for %s: %w", name, err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.breakers[name]; exists {
+		return nil, fmt.Errorf("circuit breaker %s already registered", name)
+	}
+
+	b := &Breaker{
+		name:    name,
+		config:  config,
+		state:   Closed,
+		metrics: r.metrics,
+		clock:   r.Clock,
+	}
+	r.breakers[name] = b
+	return b, nil
+}
+
+// Stats returns the state of every registered breaker ordered by name
+func (r *Registry) Stats() []Stats {
+	r.mu.RLock()
+	breakers := make([]*Breaker, 0, len(r.breakers))
+	for _, b := range r.breakers {
+		breakers = append(breakers, b)
+	}
+	r.mu.RUnlock()
+
+	stats := make([]Stats, 0, len(breakers))
+	for _, b := range breakers {
+		stats = append(stats, b.Stats())
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Name < stats[j].Name })
+	return stats
+}
+
+// breakerMetrics holds the OpenTelemetry instruments shared by all breakers
+type breakerMetrics struct {
+	transitions metric.Int64Counter
+	rejected    metric.Int64Counter
+}
+
+// stateValues encode breaker states in the state gauge
+var stateValues = map[State]int64{Closed: 0, HalfOpen: 1, Open: 2}
+
+// newBreakerMetrics creates the breaker instruments; failures leave metrics disabled
+func newBreakerMetrics(registry *Registry) *breakerMetrics {
+	meter := otel.Meter("orden-compra/breaker")
+	m := &breakerMetrics{}
+
+	m.transitions, _ = meter.Int64Counter(
+		"circuit_breaker_transitions_total",
+		metric.WithDescription("Circuit breaker state changes per dependency"),
+	)
+	m.rejected, _ = meter.Int64Counter(
+		"circuit_breaker_rejected_total",
+		metric.WithDescription("Calls refused because a dependency's circuit breaker was open"),
+	)
+
+	state, err := meter.Int64ObservableGauge(
+		"circuit_breaker_state",
+		metric.WithDescription("Circuit breaker state per dependency: 0 closed, 1 half open, 2 open"),
+	)
+	if err == nil {
+		_, _ = meter.RegisterCallback(func(ctx context.Context, o metric.Observer) error {
+			for _, s := range registry.Stats() {
+				o.ObserveInt64(state, stateValues[s.State], metric.WithAttributes(attribute.String("dependency", s.Name)))
+			}
+			return nil
+		}, state)
+	}
+
+	return m
+}
+
+// recordTransition records a state change
+func (m *breakerMetrics) recordTransition(ctx context.Context, name string, from, to State) {
+	if m == nil || m.transitions == nil {
+		return
+	}
+	m.transitions.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("dependency", name),
+		attribute.String("from", string(from)),
+		attribute.String("to", string(to)),
+	))
+}
+
+// recordRejected records a refused call
+func (m *breakerMetrics) recordRejected(ctx context.Context, name string) {
+	if m == nil || m.rejected == nil {
+		return
+	}
+	m.rejected.Add(ctx, 1, metric.WithAttributes(attribute.String("dependency", name)))
+}
@@ -0,0 +1,240 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"medisupply/clock"
+)
+
+var errUnavailable = errors.New("service unavailable")
+
+func newBreaker(t *testing.T, config Config) (*Breaker, *clock.Fake) {
+	t.Helper()
+	registry := NewRegistry()
+	fake := clock.NewFake(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+	registry.Clock = fake
+	b, err := registry.Register("dynamodb", config)
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	return b, fake
+}
+
+func TestBreakerStates(t *testing.T) {
+	config := Config{FailureThreshold: 3, OpenTimeout: 30 * time.Second, HalfOpenRequests: 2}
+
+	// Steps: ok and fail make a call with that outcome, probe takes a
+	// half-open slot without reporting, reject expects the call refused,
+	// and wait advances the clock by the open timeout
+	for _, tc := range []struct {
+		name     string
+		config   Config
+		steps    []string
+		want     State
+		failures int
+		rejected int64
+	}{
+		{"closed below the threshold", config, []string{"fail", "fail"}, Closed, 2, 0},
+		{"a success resets the failures", config, []string{"fail", "fail", "ok", "fail", "fail"}, Closed, 2, 0},
+		{"opens at the threshold", config, []string{"fail", "fail", "fail", "reject", "reject"}, Open, 3, 2},
+		{"half open once the timeout passes", config, []string{"fail", "fail", "fail", "wait"}, HalfOpen, 3, 0},
+		{"closes when the probes succeed", config, []string{"fail", "fail", "fail", "wait", "ok", "ok", "ok"}, Closed, 0, 0},
+		{"stays half open until every probe succeeds", config, []string{"fail", "fail", "fail", "wait", "ok"}, HalfOpen, 3, 0},
+		{"a failed probe opens it again", config, []string{"fail", "fail", "fail", "wait", "ok", "fail", "reject"}, Open, 4, 1},
+		{"limits the probes in flight", config, []string{"fail", "fail", "fail", "wait", "probe", "probe", "reject"}, HalfOpen, 3, 1},
+		{"closed again opens at the threshold", config, []string{"fail", "fail", "fail", "wait", "ok", "ok", "fail", "fail", "fail", "reject"}, Open, 3, 1},
+		{"threshold 0 never opens", Config{}, []string{"fail", "fail", "fail", "fail", "ok"}, Closed, 0, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			b, fake := newBreaker(t, tc.config)
+			ctx := context.Background()
+			for i, step := range tc.steps {
+				switch step {
+				case "ok", "fail":
+					if err := b.Allow(ctx); err != nil {
+						t.Fatalf("step %d %s: refused: %v", i, step, err)
+					}
+					b.Record(ctx, step == "ok")
+				case "probe":
+					if err := b.Allow(ctx); err != nil {
+						t.Fatalf("step %d probe: refused: %v", i, err)
+					}
+				case "reject":
+					var openErr *OpenError
+					if err := b.Allow(ctx); !errors.As(err, &openErr) || !errors.Is(err, ErrOpen) {
+						t.Fatalf("step %d reject: Allow returned %v", i, err)
+					}
+					if openErr.Dependency != "dynamodb" || openErr.RetryAfter <= 0 || openErr.RetryAfter > tc.config.OpenTimeout {
+						t.Fatalf("step %d reject: %+v", i, openErr)
+					}
+				case "wait":
+					fake.Advance(tc.config.OpenTimeout)
+				}
+			}
+			if stats := b.Stats(); stats.State != tc.want || stats.Failures != tc.failures || stats.Rejected != tc.rejected {
+				t.Fatalf("stats %+v, want %s with %d failures and %d rejected", stats, tc.want, tc.failures, tc.rejected)
+			}
+		})
+	}
+}
+
+func TestOpenBreakerRetryAfterFollowsTheClock(t *testing.T) {
+	b, fake := newBreaker(t, Config{FailureThreshold: 1, OpenTimeout: 30 * time.Second, HalfOpenRequests: 1})
+	b.Allow(context.Background())
+	b.Record(context.Background(), false)
+
+	fake.Advance(20 * time.Second)
+	var openErr *OpenError
+	if err := b.Allow(context.Background()); !errors.As(err, &openErr) || openErr.RetryAfter != 10*time.Second {
+		t.Fatalf("Allow returned %v, want a retry after 10s", err)
+	}
+	fake.Advance(10 * time.Second)
+	if err := b.Allow(context.Background()); err != nil {
+		t.Fatalf("probe refused once the timeout passed: %v", err)
+	}
+}
+
+func TestDo(t *testing.T) {
+	b, fake := newBreaker(t, Config{FailureThreshold: 2, OpenTimeout: time.Minute, HalfOpenRequests: 1})
+	ctx := context.Background()
+	isFailure := func(err error) bool { return errors.Is(err, errUnavailable) }
+
+	// Errors that show the dependency is up do not count
+	conditionFailed := errors.New("conditional check failed")
+	for i := 0; i < 3; i++ {
+		if err := b.Do(ctx, func() error { return conditionFailed }, isFailure); err != conditionFailed {
+			t.Fatalf("Do returned %v", err)
+		}
+	}
+	if stats := b.Stats(); stats.State != Closed || stats.Failures != 0 {
+		t.Fatalf("stats %+v after errors that are not failures", stats)
+	}
+
+	for i := 0; i < 2; i++ {
+		b.Do(ctx, func() error { return errUnavailable }, isFailure)
+	}
+	calls := 0
+	if err := b.Do(ctx, func() error { calls++; return nil }, isFailure); !errors.Is(err, ErrOpen) || calls != 0 {
+		t.Fatalf("open breaker returned %v after %d calls", err, calls)
+	}
+
+	// A probe whose caller gave up frees its slot without an outcome
+	fake.Advance(time.Minute)
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := b.Do(cancelled, func() error { return context.Canceled }, isFailure); !errors.Is(err, context.Canceled) {
+		t.Fatalf("cancelled probe returned %v", err)
+	}
+	if stats := b.Stats(); stats.State != HalfOpen {
+		t.Fatalf("state %s after a cancelled probe, want half_open", stats.State)
+	}
+	if err := b.Do(ctx, func() error { return nil }, isFailure); err != nil {
+		t.Fatalf("probe after the cancelled one: %v", err)
+	}
+	if stats := b.Stats(); stats.State != Closed {
+		t.Fatalf("state %s after a successful probe, want closed", stats.State)
+	}
+}
+
+func TestBreakerCountsConcurrentFailures(t *testing.T) {
+	b, fake := newBreaker(t, Config{FailureThreshold: 200, OpenTimeout: time.Minute, HalfOpenRequests: 5})
+	ctx := context.Background()
+	isFailure := func(error) bool { return true }
+
+	// 199 concurrent failures leave it closed, each counted once
+	var wg sync.WaitGroup
+	for i := 0; i < 199; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b.Do(ctx, func() error { return errUnavailable }, isFailure)
+		}()
+	}
+	wg.Wait()
+	if stats := b.Stats(); stats.State != Closed || stats.Failures != 199 {
+		t.Fatalf("stats %+v after 199 concurrent failures", stats)
+	}
+
+	// Concurrent calls at the threshold open it; the calls after it are
+	// refused without running, and outcomes reported once it is open do
+	// not count
+	var ran, refused atomic.Int32
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := b.Do(ctx, func() error {
+				ran.Add(1)
+				return errUnavailable
+			}, isFailure)
+			if errors.Is(err, ErrOpen) {
+				refused.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	stats := b.Stats()
+	if stats.State != Open || int(ran.Load()+refused.Load()) != 50 || stats.Rejected != int64(refused.Load()) || stats.Failures < 200 || stats.Failures > 199+int(ran.Load()) {
+		t.Fatalf("stats %+v with %d calls run and %d refused", stats, ran.Load(), refused.Load())
+	}
+
+	// Half open, only HalfOpenRequests concurrent probes go through
+	fake.Advance(time.Minute)
+	release := make(chan struct{})
+	var probes, refusedProbes atomic.Int32
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := b.Do(ctx, func() error {
+				probes.Add(1)
+				<-release
+				return nil
+			}, isFailure)
+			if errors.Is(err, ErrOpen) {
+				refusedProbes.Add(1)
+			}
+		}()
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for probes.Load()+refusedProbes.Load() < 20 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+	if probes.Load() != 5 || refusedProbes.Load() != 15 {
+		t.Fatalf("%d probes ran and %d were refused, want half_open_requests 5", probes.Load(), refusedProbes.Load())
+	}
+	if stats := b.Stats(); stats.State != Closed {
+		t.Fatalf("state %s after the probes succeeded, want closed", stats.State)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	registry := NewRegistry()
+	if _, err := registry.Register("rabbitmq", Config{FailureThreshold: 5, OpenTimeout: 0, HalfOpenRequests: 1}); err == nil {
+		t.Fatal("registered a breaker without open timeout")
+	}
+	if _, err := registry.Register("rabbitmq", Config{FailureThreshold: 5, OpenTimeout: time.Second, HalfOpenRequests: 1}); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	if _, err := registry.Register("rabbitmq", Config{}); err == nil {
+		t.Fatal("registered rabbitmq twice")
+	}
+	registry.Register("dynamodb", Config{})
+	if stats := registry.Stats(); len(stats) != 2 || stats[0].Name != "dynamodb" || stats[1].Name != "rabbitmq" || stats[1].State != Closed {
+		t.Fatalf("stats %+v", stats)
+	}
+
+	// A nil breaker lets every call through
+	var b *Breaker
+	if err := b.Allow(context.Background()); err != nil {
+		t.Fatalf("nil breaker refused a call: %v", err)
+	}
+	b.Record(context.Background(), false)
+}
@@ -0,0 +1,135 @@
+package breaker
+
+import (
+	"errors"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// GuardedDynamoDB wraps a DynamoDB client so data-plane calls go through
+// the DynamoDB breaker
+type GuardedDynamoDB struct {
+	dynamodbiface.DynamoDBAPI
+	Breaker *Breaker
+}
+
+// NewGuardedDynamoDB creates a new GuardedDynamoDB
+func NewGuardedDynamoDB(client dynamodbiface.DynamoDBAPI, breaker *Breaker) *GuardedDynamoDB {
+	return &GuardedDynamoDB{
+		DynamoDBAPI: client,
+		Breaker:     breaker,
+	}
+}
+
+// IsDynamoDBFailure reports whether an error shows DynamoDB degraded:
+// server errors, throttling and failed requests. Client errors such as a
+// conditional check failing are answers from a healthy service.
+func IsDynamoDBFailure(err error) bool {
+	var requestFailure awserr.RequestFailure
+	if errors.As(err, &requestFailure) {
+		switch requestFailure.Code() {
+		case dynamodb.ErrCodeProvisionedThroughputExceededException, dynamodb.ErrCodeRequestLimitExceeded, "ThrottlingException":
+			return true
+		}
+		return requestFailure.StatusCode() >= 500
+	}
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) && awsErr.Code() == request.InvalidParameterErrCode {
+		return false
+	}
+	return true
+}
+
+// GetItemWithContext gets an item unless the breaker is open
+func (d *GuardedDynamoDB) GetItemWithContext(ctx aws.Context, input *dynamodb.GetItemInput, opts ...request.Option) (*dynamodb.GetItemOutput, error) {
+	var output *dynamodb.GetItemOutput
+	err := d.Breaker.Do(ctx, func() (err error) {
+		output, err = d.DynamoDBAPI.GetItemWithContext(ctx, input, opts...)
+		return err
+	}, IsDynamoDBFailure)
+	return output, err
+}
+
+// PutItemWithContext puts an item unless the breaker is open
+func (d *GuardedDynamoDB) PutItemWithContext(ctx aws.Context, input *dynamodb.PutItemInput, opts ...request.Option) (*dynamodb.PutItemOutput, error) {
+	var output *dynamodb.PutItemOutput
+	err := d.Breaker.Do(ctx, func() (err error) {
+		output, err = d.DynamoDBAPI.PutItemWithContext(ctx, input, opts...)
+		return err
+	}, IsDynamoDBFailure)
+	return output, err
+}
+
+// UpdateItemWithContext updates an item unless the breaker is open
+func (d *GuardedDynamoDB) UpdateItemWithContext(ctx aws.Context, input *dynamodb.UpdateItemInput, opts ...request.Option) (*dynamodb.UpdateItemOutput, error) {
+	var output *dynamodb.UpdateItemOutput
+	err := d.Breaker.Do(ctx, func() (err error) {
+		output, err = d.DynamoDBAPI.UpdateItemWithContext(ctx, input, opts...)
+		return err
+	}, IsDynamoDBFailure)
+	return output, err
+}
+
+// DeleteItemWithContext deletes an item unless the breaker is open
+func (d *GuardedDynamoDB) DeleteItemWithContext(ctx aws.Context, input *dynamodb.DeleteItemInput, opts ...request.Option) (*dynamodb.DeleteItemOutput, error) {
+	var output *dynamodb.DeleteItemOutput
+	err := d.Breaker.Do(ctx, func() (err error) {
+		output, err = d.DynamoDBAPI.DeleteItemWithContext(ctx, input, opts...)
+		return err
+	}, IsDynamoDBFailure)
+	return output, err
+}
+
+// QueryWithContext runs a query unless the breaker is open
+func (d *GuardedDynamoDB) QueryWithContext(ctx aws.Context, input *dynamodb.QueryInput, opts ...request.Option) (*dynamodb.QueryOutput, error) {
+	var output *dynamodb.QueryOutput
+	err := d.Breaker.Do(ctx, func() (err error) {
+		output, err = d.DynamoDBAPI.QueryWithContext(ctx, input, opts...)
+		return err
+	}, IsDynamoDBFailure)
+	return output, err
+}
+
+// ScanWithContext runs a scan unless the breaker is open
+func (d *GuardedDynamoDB) ScanWithContext(ctx aws.Context, input *dynamodb.ScanInput, opts ...request.Option) (*dynamodb.ScanOutput, error) {
+	var output *dynamodb.ScanOutput
+	err := d.Breaker.Do(ctx, func() (err error) {
+		output, err = d.DynamoDBAPI.ScanWithContext(ctx, input, opts...)
+		return err
+	}, IsDynamoDBFailure)
+	return output, err
+}
+
+// BatchWriteItemWithContext writes a batch unless the breaker is open
+func (d *GuardedDynamoDB) BatchWriteItemWithContext(ctx aws.Context, input *dynamodb.BatchWriteItemInput, opts ...request.Option) (*dynamodb.BatchWriteItemOutput, error) {
+	var output *dynamodb.BatchWriteItemOutput
+	err := d.Breaker.Do(ctx, func() (err error) {
+		output, err = d.DynamoDBAPI.BatchWriteItemWithContext(ctx, input, opts...)
+		return err
+	}, IsDynamoDBFailure)
+	return output, err
+}
+
+// BatchGetItemWithContext reads a batch unless the breaker is open
+func (d *GuardedDynamoDB) BatchGetItemWithContext(ctx aws.Context, input *dynamodb.BatchGetItemInput, opts ...request.Option) (*dynamodb.BatchGetItemOutput, error) {
+	var output *dynamodb.BatchGetItemOutput
+	err := d.Breaker.Do(ctx, func() (err error) {
+		output, err = d.DynamoDBAPI.BatchGetItemWithContext(ctx, input, opts...)
+		return err
+	}, IsDynamoDBFailure)
+	return output, err
+}
+
+// TransactWriteItemsWithContext runs a transaction unless the breaker is open
+func (d *GuardedDynamoDB) TransactWriteItemsWithContext(ctx aws.Context, input *dynamodb.TransactWriteItemsInput, opts ...request.Option) (*dynamodb.TransactWriteItemsOutput, error) {
+	var output *dynamodb.TransactWriteItemsOutput
+	err := d.Breaker.Do(ctx, func() (err error) {
+		output, err = d.DynamoDBAPI.TransactWriteItemsWithContext(ctx, input, opts...)
+		return err
+	}, IsDynamoDBFailure)
+	return output, err
+}
@@ -0,0 +1,142 @@
+// Package cache keeps recently read DynamoDB items in memory so hot reads,
+// such as dashboards polling the same orders, do not each cost a DynamoDB read.
+package cache
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Config represents the settings of the cache
+type Config struct {
+	Enabled  bool
+	Capacity int
+	TTL      time.Duration
+}
+
+// Validate checks that the cache settings are usable
+func (c Config) Validate() error {
+	if c.Capacity < 1 {
+		return fmt.Errorf("capacity must be at least 1")
+	}
+	if c.TTL <= 0 {
+		return fmt.Errorf("ttl must be positive")
+	}
+	return nil
+}
+
+// entry is a cached value and when it stops being served
+type entry struct {
+	key       string
+	value     interface{}
+	expiresAt time.Time
+}
+
+// LRU is a size-bounded cache whose entries expire after a TTL. Once full,
+// the least recently used entry is evicted.
+type LRU struct {
+	mu       sync.Mutex
+	capacity int
+	ttl      time.Duration
+	entries  map[string]*list.Element
+	order    list.List
+	requests metric.Int64Counter
+}
+
+// NewLRU creates a new LRU cache
+func NewLRU(config Config) (*LRU, error) {
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid cache config: %w", err)
+	}
+
+	c := &LRU{
+		capacity: config.Capacity,
+		ttl:      config.TTL,
+		entries:  make(map[string]*list.Element, config.Capacity),
+	}
+	c.requests, _ = otel.Meter("orden-compra/cache").Int64Counter(
+		"cache_requests_total",
+		metric.WithDescription("Cache lookups by result"),
+	)
+	return c, nil
+}
+
+// Get returns the value cached under key, if present and not expired
+func (c *LRU) Get(ctx context.Context, key string) (interface{}, bool) {
+	c.mu.Lock()
+	element, ok := c.entries[key]
+	if ok && time.Now().After(element.Value.(*entry).expiresAt) {
+		c.remove(element)
+		ok = false
+	}
+	var value interface{}
+	if ok {
+		c.order.MoveToFront(element)
+		value = element.Value.(*entry).value
+	}
+	c.mu.Unlock()
+
+	c.record(ctx, ok)
+	return value, ok
+}
+
+// Set caches value under key for the TTL
+func (c *LRU) Set(key string, value interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expiresAt := time.Now().Add(c.ttl)
+	if element, ok := c.entries[key]; ok {
+		element.Value.(*entry).value = value
+		element.Value.(*entry).expiresAt = expiresAt
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&entry{key: key, value: value, expiresAt: expiresAt})
+	if c.order.Len() > c.capacity {
+		c.remove(c.order.Back())
+	}
+}
+
+// Delete removes key from the cache
+func (c *LRU) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		c.remove(element)
+	}
+}
+
+// Len returns the number of cached entries, expired ones included
+func (c *LRU) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// remove drops an entry; the caller holds the lock
+func (c *LRU) remove(element *list.Element) {
+	c.order.Remove(element)
+	delete(c.entries, element.Value.(*entry).key)
+}
+
+// record counts a lookup as a hit or a miss
+func (c *LRU) record(ctx context.Context, hit bool) {
+	if c.requests == nil {
+		return
+	}
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	c.requests.Add(ctx, 1, metric.WithAttributes(attribute.String("result", result)))
+}
@@ -0,0 +1,69 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func newLRU(t *testing.T, capacity int, ttl time.Duration) *LRU {
+	t.Helper()
+	c, err := NewLRU(Config{Enabled: true, Capacity: capacity, TTL: ttl})
+	if err != nil {
+		t.Fatalf("NewLRU: %v", err)
+	}
+	return c
+}
+
+func TestConfigValidate(t *testing.T) {
+	for _, config := range []Config{{Capacity: 0, TTL: time.Second}, {Capacity: 1, TTL: 0}} {
+		if _, err := NewLRU(config); err == nil {
+			t.Fatalf("accepted %+v", config)
+		}
+	}
+}
+
+func TestLRUEvictsTheLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	c := newLRU(t, 2, time.Minute)
+	c.Set("a", 1)
+	c.Set("b", 2)
+
+	// Reading a makes b the least recently used
+	if value, ok := c.Get(ctx, "a"); !ok || value != 1 {
+		t.Fatalf("a = %v, %v", value, ok)
+	}
+	c.Set("c", 3)
+	if _, ok := c.Get(ctx, "b"); ok {
+		t.Fatal("b was not evicted")
+	}
+	if c.Len() != 2 {
+		t.Fatalf("%d entries, want 2", c.Len())
+	}
+
+	// Setting a cached key replaces its value without evicting
+	c.Set("a", 10)
+	if value, ok := c.Get(ctx, "a"); !ok || value != 10 {
+		t.Fatalf("a = %v, %v", value, ok)
+	}
+	if _, ok := c.Get(ctx, "c"); !ok {
+		t.Fatal("c was evicted by an update")
+	}
+
+	c.Delete("a")
+	if _, ok := c.Get(ctx, "a"); ok {
+		t.Fatal("a was not deleted")
+	}
+}
+
+func TestLRUEntriesExpire(t *testing.T) {
+	c := newLRU(t, 2, 10*time.Millisecond)
+	c.Set("a", 1)
+	time.Sleep(20 * time.Millisecond)
+	if _, ok := c.Get(context.Background(), "a"); ok {
+		t.Fatal("served an expired entry")
+	}
+	if c.Len() != 0 {
+		t.Fatalf("%d entries after expiry, want the expired one dropped", c.Len())
+	}
+}
@@ -0,0 +1,148 @@
+package cache
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+type staleKey struct{}
+
+// AllowStale returns a copy of ctx whose item reads may be served from the
+// cache. Only reads that return data to callers should opt in: commands
+// that read an item to write it back must see the stored version, since
+// writes made by other replicas are only noticed once entries expire.
+func AllowStale(ctx context.Context) context.Context {
+	return context.WithValue(ctx, staleKey{}, true)
+}
+
+// StaleAllowed reports whether reads made with ctx may be served from the cache
+func StaleAllowed(ctx context.Context) bool {
+	allowed, _ := ctx.Value(staleKey{}).(bool)
+	return allowed
+}
+
+// CachedDynamoDB wraps a DynamoDB client so GetItem calls that allow stale
+// reads are served from the cache, for the tables listed in Tables. Writes
+// through the client evict the items they touch, so this replica sees its
+// own writes immediately and other replicas' writes within the TTL.
+type CachedDynamoDB struct {
+	dynamodbiface.DynamoDBAPI
+	Cache *LRU
+	// Tables maps the cached tables to their hash key
+	Tables map[string]string
+}
+
+// NewCachedDynamoDB creates a new CachedDynamoDB
+func NewCachedDynamoDB(client dynamodbiface.DynamoDBAPI, cache *LRU, tables map[string]string) *CachedDynamoDB {
+	return &CachedDynamoDB{
+		DynamoDBAPI: client,
+		Cache:       cache,
+		Tables:      tables,
+	}
+}
+
+// key returns the cache key of an item or item key, or "" when the table is
+// not cached
+func (d *CachedDynamoDB) key(table *string, item map[string]*dynamodb.AttributeValue) string {
+	hashKey, ok := d.Tables[aws.StringValue(table)]
+	if !ok {
+		return ""
+	}
+	value, ok := item[hashKey]
+	if !ok || value.S == nil {
+		return ""
+	}
+	return aws.StringValue(table) + "/" + *value.S
+}
+
+// evict drops the cached copy of an item
+func (d *CachedDynamoDB) evict(table *string, item map[string]*dynamodb.AttributeValue) {
+	if key := d.key(table, item); key != "" {
+		d.Cache.Delete(key)
+	}
+}
+
+// GetItemWithContext serves whole-item reads that allow stale data from the
+// cache. Missing items are not cached so new orders show up at once.
+func (d *CachedDynamoDB) GetItemWithContext(ctx aws.Context, input *dynamodb.GetItemInput, opts ...request.Option) (*dynamodb.GetItemOutput, error) {
+	key := d.key(input.TableName, input.Key)
+	if key == "" || !StaleAllowed(ctx) || aws.BoolValue(input.ConsistentRead) || input.ProjectionExpression != nil {
+		return d.DynamoDBAPI.GetItemWithContext(ctx, input, opts...)
+	}
+
+	if cached, ok := d.Cache.Get(ctx, key); ok {
+		return &dynamodb.GetItemOutput{Item: copyItem(cached.(map[string]*dynamodb.AttributeValue))}, nil
+	}
+
+	output, err := d.DynamoDBAPI.GetItemWithContext(ctx, input, opts...)
+	if err == nil && output.Item != nil {
+		d.Cache.Set(key, copyItem(output.Item))
+	}
+	return output, err
+}
+
+// PutItemWithContext puts an item and evicts its cached copy
+func (d *CachedDynamoDB) PutItemWithContext(ctx aws.Context, input *dynamodb.PutItemInput, opts ...request.Option) (*dynamodb.PutItemOutput, error) {
+	defer d.evict(input.TableName, input.Item)
+	return d.DynamoDBAPI.PutItemWithContext(ctx, input, opts...)
+}
+
+// UpdateItemWithContext updates an item and evicts its cached copy
+func (d *CachedDynamoDB) UpdateItemWithContext(ctx aws.Context, input *dynamodb.UpdateItemInput, opts ...request.Option) (*dynamodb.UpdateItemOutput, error) {
+	defer d.evict(input.TableName, input.Key)
+	return d.DynamoDBAPI.UpdateItemWithContext(ctx, input, opts...)
+}
+
+// DeleteItemWithContext deletes an item and evicts its cached copy
+func (d *CachedDynamoDB) DeleteItemWithContext(ctx aws.Context, input *dynamodb.DeleteItemInput, opts ...request.Option) (*dynamodb.DeleteItemOutput, error) {
+	defer d.evict(input.TableName, input.Key)
+	return d.DynamoDBAPI.DeleteItemWithContext(ctx, input, opts...)
+}
+
+// BatchWriteItemWithContext writes items and evicts their cached copies
+func (d *CachedDynamoDB) BatchWriteItemWithContext(ctx aws.Context, input *dynamodb.BatchWriteItemInput, opts ...request.Option) (*dynamodb.BatchWriteItemOutput, error) {
+	defer func() {
+		for table, requests := range input.RequestItems {
+			for _, r := range requests {
+				switch {
+				case r.PutRequest != nil:
+					d.evict(aws.String(table), r.PutRequest.Item)
+				case r.DeleteRequest != nil:
+					d.evict(aws.String(table), r.DeleteRequest.Key)
+				}
+			}
+		}
+	}()
+	return d.DynamoDBAPI.BatchWriteItemWithContext(ctx, input, opts...)
+}
+
+// TransactWriteItemsWithContext writes items and evicts their cached copies
+func (d *CachedDynamoDB) TransactWriteItemsWithContext(ctx aws.Context, input *dynamodb.TransactWriteItemsInput, opts ...request.Option) (*dynamodb.TransactWriteItemsOutput, error) {
+	defer func() {
+		for _, item := range input.TransactItems {
+			switch {
+			case item.Put != nil:
+				d.evict(item.Put.TableName, item.Put.Item)
+			case item.Update != nil:
+				d.evict(item.Update.TableName, item.Update.Key)
+			case item.Delete != nil:
+				d.evict(item.Delete.TableName, item.Delete.Key)
+			}
+		}
+	}()
+	return d.DynamoDBAPI.TransactWriteItemsWithContext(ctx, input, opts...)
+}
+
+// copyItem copies the attribute map so callers, such as the tenant wrapper
+// attributing legacy items, cannot change the cached item
+func copyItem(item map[string]*dynamodb.AttributeValue) map[string]*dynamodb.AttributeValue {
+	copied := make(map[string]*dynamodb.AttributeValue, len(item))
+	for name, value := range item {
+		copied[name] = value
+	}
+	return copied
+}
@@ -0,0 +1,128 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+
+	"orden-compra/internal/memory"
+)
+
+// countingDynamoDB counts the item reads that reach the tables
+type countingDynamoDB struct {
+	*memory.DynamoDB
+	reads int
+}
+
+func (d *countingDynamoDB) GetItemWithContext(ctx aws.Context, input *dynamodb.GetItemInput, opts ...request.Option) (*dynamodb.GetItemOutput, error) {
+	d.reads++
+	return d.DynamoDB.GetItemWithContext(ctx, input, opts...)
+}
+
+func newCachedDynamoDB(t *testing.T) (*CachedDynamoDB, *countingDynamoDB) {
+	t.Helper()
+	backend := &countingDynamoDB{DynamoDB: memory.NewDynamoDB(memory.Tables)}
+	return NewCachedDynamoDB(backend, newLRU(t, 10, time.Minute), map[string]string{"orden-compra-read": "id"}), backend
+}
+
+func getOrder(t *testing.T, ctx context.Context, d *CachedDynamoDB, id string) map[string]*dynamodb.AttributeValue {
+	t.Helper()
+	output, err := d.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String("orden-compra-read"),
+		Key:       map[string]*dynamodb.AttributeValue{"id": {S: aws.String(id)}},
+	})
+	if err != nil {
+		t.Fatalf("get %s: %v", id, err)
+	}
+	return output.Item
+}
+
+func putOrder(t *testing.T, d *CachedDynamoDB, id, status string) {
+	t.Helper()
+	_, err := d.PutItemWithContext(context.Background(), &dynamodb.PutItemInput{
+		TableName: aws.String("orden-compra-read"),
+		Item:      map[string]*dynamodb.AttributeValue{"id": {S: aws.String(id)}, "status": {S: aws.String(status)}},
+	})
+	if err != nil {
+		t.Fatalf("put %s: %v", id, err)
+	}
+}
+
+func TestCachedDynamoDBServesStaleReadsFromTheCache(t *testing.T) {
+	d, backend := newCachedDynamoDB(t)
+	putOrder(t, d, "po-1", "pending")
+	stale := AllowStale(context.Background())
+
+	getOrder(t, stale, d, "po-1")
+	item := getOrder(t, stale, d, "po-1")
+	if backend.reads != 1 || aws.StringValue(item["status"].S) != "pending" {
+		t.Fatalf("%d reads, item %v", backend.reads, item)
+	}
+
+	// Callers cannot change the cached item
+	item["status"] = &dynamodb.AttributeValue{S: aws.String("changed")}
+	if item := getOrder(t, stale, d, "po-1"); aws.StringValue(item["status"].S) != "pending" {
+		t.Fatalf("cached item changed to %v", item)
+	}
+
+	// Writes through the client evict the cached copy
+	putOrder(t, d, "po-1", "approved")
+	if item := getOrder(t, stale, d, "po-1"); backend.reads != 2 || aws.StringValue(item["status"].S) != "approved" {
+		t.Fatalf("%d reads, item %v after a write", backend.reads, item)
+	}
+}
+
+func TestCachedDynamoDBBypassesTheCache(t *testing.T) {
+	d, backend := newCachedDynamoDB(t)
+	putOrder(t, d, "po-1", "pending")
+	stale := AllowStale(context.Background())
+	getOrder(t, stale, d, "po-1")
+	backend.reads = 0
+
+	// Reads that do not allow stale data always reach the table
+	getOrder(t, context.Background(), d, "po-1")
+	_, err := d.GetItemWithContext(stale, &dynamodb.GetItemInput{
+		TableName:      aws.String("orden-compra-read"),
+		Key:            map[string]*dynamodb.AttributeValue{"id": {S: aws.String("po-1")}},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil || backend.reads != 2 {
+		t.Fatalf("%d reads, error %v, want both to reach the table", backend.reads, err)
+	}
+
+	// Missing items are not cached, so new orders show up at once
+	if item := getOrder(t, stale, d, "po-2"); item != nil {
+		t.Fatalf("item %v for a missing order", item)
+	}
+	putItem := &dynamodb.PutItemInput{TableName: aws.String("orden-compra-read"), Item: map[string]*dynamodb.AttributeValue{"id": {S: aws.String("po-2")}}}
+	if _, err := backend.PutItemWithContext(context.Background(), putItem); err != nil {
+		t.Fatalf("put po-2: %v", err)
+	}
+	if item := getOrder(t, stale, d, "po-2"); item == nil {
+		t.Fatal("missing order was cached")
+	}
+}
+
+func TestCachedDynamoDBEvictsTransactionWrites(t *testing.T) {
+	d, backend := newCachedDynamoDB(t)
+	putOrder(t, d, "po-1", "pending")
+	stale := AllowStale(context.Background())
+	getOrder(t, stale, d, "po-1")
+
+	_, err := d.TransactWriteItemsWithContext(context.Background(), &dynamodb.TransactWriteItemsInput{
+		TransactItems: []*dynamodb.TransactWriteItem{{Put: &dynamodb.Put{
+			TableName: aws.String("orden-compra-read"),
+			Item:      map[string]*dynamodb.AttributeValue{"id": {S: aws.String("po-1")}, "status": {S: aws.String("sent")}},
+		}}},
+	})
+	if err != nil {
+		t.Fatalf("transact: %v", err)
+	}
+	if item := getOrder(t, stale, d, "po-1"); backend.reads != 2 || aws.StringValue(item["status"].S) != "sent" {
+		t.Fatalf("%d reads, item %v after a transaction", backend.reads, item)
+	}
+}
@@ -0,0 +1,225 @@
+package codec
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+
+	wire "medisupply/codec"
+	"medisupply/codec/eventsv1"
+	"orden-compra/internal/models"
+)
+
+// Supported message content types
+const (
+	ContentTypeJSON     = "application/json"
+	ContentTypeProtobuf = "application/x-protobuf"
+)
+
+// protobufAliases lists other content types commonly used for protobuf payloads
+var protobufAliases = map[string]bool{
+	ContentTypeProtobuf:                 true,
+	"application/protobuf":              true,
+	"application/vnd.google.protobuf":   true,
+	"application/octet-stream+protobuf": true,
+}
+
+// Normalize maps a content type header onto one of the supported content types.
+// An empty content type is treated as JSON for backwards compatibility.
+func Normalize(contentType string) (string, error) {
+	if strings.TrimSpace(contentType) == "" {
+		return ContentTypeJSON, nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", fmt.Errorf("invalid content type %q: %w", contentType, err)
+	}
+
+	switch {
+	case mediaType == ContentTypeJSON:
+		return ContentTypeJSON, nil
+	case protobufAliases[mediaType]:
+		return ContentTypeProtobuf, nil
+	default:
+		return "", fmt.Errorf("unsupported content type %q", contentType)
+	}
+}
+
+// DecodeStockLowEvent decodes a StockBajo payload in the given content type
+func DecodeStockLowEvent(contentType string, body []byte) (*models.StockLowEvent, error) {
+	normalized, err := Normalize(contentType)
+	if err != nil {
+		return nil, err
+	}
+
+	var event models.StockLowEvent
+	if normalized == ContentTypeProtobuf {
+		if err := unmarshalStockLowEvent(body, &event); err != nil {
+			return nil, fmt.Errorf("failed to decode protobuf StockBajo: %w", err)
+		}
+		return &event, nil
+	}
+
+	if err := json.Unmarshal(body, &event); err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+// DecodeInventoryReceivedEvent decodes an InventarioRecibido payload in the given content type
+func DecodeInventoryReceivedEvent(contentType string, body []byte) (*models.InventoryReceivedEvent, error) {
+	normalized, err := Normalize(contentType)
+	if err != nil {
+		return nil, err
+	}
+
+	var event models.InventoryReceivedEvent
+	if normalized == ContentTypeProtobuf {
+		if err := unmarshalInventoryReceivedEvent(body, &event); err != nil {
+			return nil, fmt.Errorf("failed to decode protobuf InventarioRecibido: %w", err)
+		}
+		return &event, nil
+	}
+
+	if err := json.Unmarshal(body, &event); err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+// DecodeInvoiceReceivedEvent decodes a FacturaRecibida payload in the given
+// content type. FacturaRecibida has no protobuf schema, so only JSON is accepted.
+func DecodeInvoiceReceivedEvent(contentType string, body []byte) (*models.InvoiceReceivedEvent, error) {
+	normalized, err := Normalize(contentType)
+	if err != nil {
+		return nil, err
+	}
+	if normalized != ContentTypeJSON {
+		return nil, fmt.Errorf("unsupported content type %q for FacturaRecibida", contentType)
+	}
+
+	var event models.InvoiceReceivedEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+// EncodeOptions controls how egress events are encoded
+type EncodeOptions struct {
+	// LegacyFieldNames adds the Spanish field aliases to JSON events so older
+	// consumers keep working during the deprecation window
+	LegacyFieldNames bool
+}
+
+// EncodeRecepcionProveedorEvent encodes a RecepcionProveedor event in the given content type
+func EncodeRecepcionProveedorEvent(contentType string, event *models.RecepcionProveedorEvent, options EncodeOptions) ([]byte, string, error) {
+	normalized, err := Normalize(contentType)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if normalized == ContentTypeProtobuf {
+		body, err := marshalRecepcionProveedorEvent(event)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to encode protobuf RecepcionProveedor: %w", err)
+		}
+		return body, ContentTypeProtobuf, nil
+	}
+
+	var body []byte
+	if options.LegacyFieldNames {
+		body, err = event.MarshalLegacyJSON()
+	} else {
+		body, err = json.Marshal(event)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return body, ContentTypeJSON, nil
+}
+
+// unmarshalStockLowEvent decodes the medisupply.events.v1.StockBajo message
+func unmarshalStockLowEvent(b []byte, event *models.StockLowEvent) error {
+	var message eventsv1.StockBajo
+	if err := proto.Unmarshal(b, &message); err != nil {
+		return err
+	}
+	metadata, err := wire.DecodeMetadata(message.Metadata)
+	if err != nil {
+		return err
+	}
+
+	*event = models.StockLowEvent{
+		ID:           message.Id,
+		Timestamp:    wire.Time(message.Timestamp),
+		EventType:    models.EventType(message.EventType),
+		ProductID:    message.ProductId,
+		ProductName:  message.ProductName,
+		CurrentStock: int(message.CurrentStock),
+		MinimumStock: int(message.MinimumStock),
+		Location:     message.Location,
+		UrgencyLevel: message.UrgencyLevel,
+		Metadata:     metadata,
+	}
+	return nil
+}
+
+// unmarshalInventoryReceivedEvent decodes the medisupply.events.v1.InventarioRecibido message
+func unmarshalInventoryReceivedEvent(b []byte, event *models.InventoryReceivedEvent) error {
+	var message eventsv1.InventarioRecibido
+	if err := proto.Unmarshal(b, &message); err != nil {
+		return err
+	}
+	metadata, err := wire.DecodeMetadata(message.Metadata)
+	if err != nil {
+		return err
+	}
+
+	*event = models.InventoryReceivedEvent{
+		ID:              message.Id,
+		Timestamp:       wire.Time(message.Timestamp),
+		EventType:       models.EventType(message.EventType),
+		PurchaseOrderID: message.PurchaseOrderId,
+		ProductID:       message.ProductId,
+		ProductName:     message.ProductName,
+		Quantity:        int(message.Quantity),
+		SupplierID:      message.SupplierId,
+		SupplierName:    message.SupplierName,
+		Location:        message.Location,
+		Status:          message.Status,
+		ReceivedAt:      wire.Time(message.ReceivedAt),
+		QualityCheck:    message.QualityCheck,
+		Temperature:     message.Temperature,
+		BatchNumber:     message.BatchNumber,
+		ExpiryDate:      wire.OptionalTime(message.ExpiryDate),
+		Metadata:        metadata,
+	}
+	return nil
+}
+
+// marshalRecepcionProveedorEvent encodes the medisupply.events.v1.RecepcionProveedor message
+func marshalRecepcionProveedorEvent(event *models.RecepcionProveedorEvent) ([]byte, error) {
+	metadata, err := wire.EncodeMetadata(event.Metadata)
+	if err != nil {
+		return nil, err
+	}
+	return proto.Marshal(&eventsv1.RecepcionProveedor{
+		Id:              event.ID,
+		Timestamp:       wire.Timestamp(event.Timestamp),
+		EventType:       string(event.EventType),
+		PurchaseOrderId: event.PurchaseOrderID,
+		ProductId:       event.ProductID,
+		ProductName:     event.ProductName,
+		Quantity:        int64(event.Quantity),
+		SupplierId:      event.SupplierID,
+		SupplierName:    event.SupplierName,
+		Location:        event.Location,
+		Status:          event.Status,
+		Metadata:        metadata,
+	})
+}
@@ -0,0 +1,68 @@
+package codec
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"orden-compra/internal/models"
+)
+
+func TestEncodeRecepcionProveedorEventLegacyFieldNames(t *testing.T) {
+	event := models.NewRecepcionProveedorEvent("po-1", "product-1", "Gloves", "supplier-1", "Acme", "warehouse-1", models.StatusSent, 10, time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+
+	// The option belongs to each call, so encoders with different options
+	// can run side by side
+	for _, tc := range []struct {
+		name   string
+		legacy bool
+	}{
+		{"legacy", true},
+		{"canonical", false},
+	} {
+		legacy := tc.legacy
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			for i := 0; i < 100; i++ {
+				body, contentType, err := EncodeRecepcionProveedorEvent(ContentTypeJSON, event, EncodeOptions{LegacyFieldNames: legacy})
+				if err != nil {
+					t.Fatalf("encode: %v", err)
+				}
+				if contentType != ContentTypeJSON {
+					t.Fatalf("content type = %q, want %q", contentType, ContentTypeJSON)
+				}
+
+				var fields map[string]interface{}
+				if err := json.Unmarshal(body, &fields); err != nil {
+					t.Fatalf("decode: %v", err)
+				}
+				if _, ok := fields["producto_id"]; ok != legacy {
+					t.Fatalf("producto_id present = %v, want %v", ok, legacy)
+				}
+				if fields["product_id"] != "product-1" {
+					t.Fatalf("product_id = %v, want product-1", fields["product_id"])
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeInvoiceReceivedEvent(t *testing.T) {
+	body := []byte(`{"id":"event-1","event_type":"FacturaRecibida","purchase_order_id":"po-1","invoice_number":"INV-1","issued_at":"2024-03-01T12:00:00Z","lines":[{"quantity":10,"unit_price":2}]}`)
+
+	event, err := DecodeInvoiceReceivedEvent(ContentTypeJSON, body)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if event.PurchaseOrderID != "po-1" || event.InvoiceNumber != "INV-1" || len(event.Lines) != 1 || event.Lines[0].UnitPrice != 2 {
+		t.Fatalf("event %+v", event)
+	}
+
+	// FacturaRecibida has no protobuf schema
+	if _, err := DecodeInvoiceReceivedEvent(ContentTypeProtobuf, body); err == nil {
+		t.Fatal("decoded FacturaRecibida from protobuf")
+	}
+	if _, err := DecodeInvoiceReceivedEvent(ContentTypeJSON, []byte(`{"id":`)); err == nil {
+		t.Fatal("decoded a malformed payload")
+	}
+}
@@ -0,0 +1,132 @@
+package codec
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"medisupply/codec/eventsv1"
+	"medisupply/fixtures"
+	"orden-compra/internal/models"
+)
+
+// consume checks that every version of an event type decodes, as JSON and
+// as protobuf, and that no field of the fixture is lost on the way; the
+// current version must be read in full from JSON, so a field the producer
+// added that this model lacks is reported. newMessage returns an empty
+// message of the event's protobuf schema.
+func consume(t *testing.T, eventType string, newMessage func() proto.Message, decode func(contentType string, body []byte) (interface{}, error)) {
+	all, err := fixtures.All(eventType)
+	if err != nil {
+		t.Fatalf("fixtures: %v", err)
+	}
+
+	check := func(t *testing.T, contentType string, body, want []byte, exact bool) {
+		event, err := decode(contentType, body)
+		if err != nil {
+			t.Fatalf("failed to decode: %v", err)
+		}
+		encoded, err := json.Marshal(event)
+		if err != nil {
+			t.Fatalf("failed to encode: %v", err)
+		}
+		if err := fixtures.Match(want, encoded, exact); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}
+
+	for i, fixture := range all {
+		current := i == len(all)-1
+		t.Run(fixture.Name()+"/json", func(t *testing.T) {
+			check(t, ContentTypeJSON, fixture.Body, fixture.Body, current)
+		})
+		t.Run(fixture.Name()+"/protobuf", func(t *testing.T) {
+			protobuf, err := fixture.Protobuf(newMessage())
+			if err != nil {
+				t.Fatalf("fixtures: %v", err)
+			}
+			check(t, ContentTypeProtobuf, protobuf.Body, protobuf.Fields, false)
+		})
+	}
+}
+
+func TestStockBajoContract(t *testing.T) {
+	consume(t, fixtures.StockBajo, func() proto.Message { return &eventsv1.StockBajo{} }, func(contentType string, body []byte) (interface{}, error) {
+		event, err := DecodeStockLowEvent(contentType, body)
+		if err != nil {
+			return nil, err
+		}
+		return event, event.Validate(time.Now())
+	})
+}
+
+func TestInventarioRecibidoContract(t *testing.T) {
+	consume(t, fixtures.InventarioRecibido, func() proto.Message { return &eventsv1.InventarioRecibido{} }, func(contentType string, body []byte) (interface{}, error) {
+		event, err := DecodeInventoryReceivedEvent(contentType, body)
+		if err != nil {
+			return nil, err
+		}
+		return event, event.Validate()
+	})
+}
+
+// TestRecepcionProveedorContract encodes RecepcionProveedor as sent with and
+// without the Spanish aliases, which must match v2 and v3 exactly. The
+// aliases exist only in JSON, so protobuf is always sent as v3.
+func TestRecepcionProveedorContract(t *testing.T) {
+	for _, version := range []struct {
+		number int
+		legacy bool
+	}{
+		{2, true},
+		{3, false},
+	} {
+		fixture, err := fixtures.Load(fixtures.RecepcionProveedor, version.number)
+		if err != nil {
+			t.Fatalf("fixtures: %v", err)
+		}
+		t.Run(fixture.Name()+"/json", func(t *testing.T) {
+			var event models.RecepcionProveedorEvent
+			if err := json.Unmarshal(fixture.Body, &event); err != nil {
+				t.Fatalf("failed to decode: %v", err)
+			}
+			body, _, err := EncodeRecepcionProveedorEvent(ContentTypeJSON, &event, EncodeOptions{LegacyFieldNames: version.legacy})
+			if err != nil {
+				t.Fatalf("failed to encode: %v", err)
+			}
+			if err := fixtures.Match(fixture.Body, body, true); err != nil {
+				t.Fatalf("%v", err)
+			}
+		})
+	}
+
+	fixture, err := fixtures.Latest(fixtures.RecepcionProveedor)
+	if err != nil {
+		t.Fatalf("fixtures: %v", err)
+	}
+	t.Run(fixture.Name()+"/protobuf", func(t *testing.T) {
+		want, err := fixture.Protobuf(&eventsv1.RecepcionProveedor{})
+		if err != nil {
+			t.Fatalf("fixtures: %v", err)
+		}
+		var event models.RecepcionProveedorEvent
+		if err := json.Unmarshal(fixture.Body, &event); err != nil {
+			t.Fatalf("failed to decode: %v", err)
+		}
+		for _, legacy := range []bool{false, true} {
+			body, _, err := EncodeRecepcionProveedorEvent(ContentTypeProtobuf, &event, EncodeOptions{LegacyFieldNames: legacy})
+			if err != nil {
+				t.Fatalf("failed to encode: %v", err)
+			}
+			var got eventsv1.RecepcionProveedor
+			if err := proto.Unmarshal(body, &got); err != nil {
+				t.Fatalf("failed to decode the encoded event: %v", err)
+			}
+			if !proto.Equal(&got, want.Message) {
+				t.Fatalf("encoded %v, want %v", &got, want.Message)
+			}
+		}
+	})
+}
@@ -0,0 +1,162 @@
+package codec
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"medisupply/codec/eventsv1"
+	"orden-compra/internal/models"
+)
+
+var wireTime = time.Date(2024, 3, 1, 12, 0, 0, 500, time.UTC)
+
+func TestDecodeStockLowEventFromGeneratedMessage(t *testing.T) {
+	body, err := proto.Marshal(&eventsv1.StockBajo{
+		Id:           "event-1",
+		Timestamp:    timestamppb.New(wireTime),
+		EventType:    "StockBajo",
+		ProductId:    "product-1",
+		ProductName:  "Gloves",
+		CurrentStock: 3,
+		MinimumStock: 10,
+		Location:     "warehouse-1",
+		UrgencyLevel: "high",
+		Metadata:     []byte(`{"correlation_id":"correlation-1"}`),
+	})
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+
+	event, err := DecodeStockLowEvent(ContentTypeProtobuf, body)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	want := &models.StockLowEvent{
+		ID:           "event-1",
+		Timestamp:    wireTime,
+		EventType:    "StockBajo",
+		ProductID:    "product-1",
+		ProductName:  "Gloves",
+		CurrentStock: 3,
+		MinimumStock: 10,
+		Location:     "warehouse-1",
+		UrgencyLevel: "high",
+		Metadata:     map[string]interface{}{"correlation_id": "correlation-1"},
+	}
+	if !reflect.DeepEqual(event, want) {
+		t.Fatalf("decoded %+v, want %+v", event, want)
+	}
+}
+
+func TestDecodeInventoryReceivedEventFromGeneratedMessage(t *testing.T) {
+	temperature := 4.5
+	expiry := wireTime.AddDate(1, 0, 0)
+	body, err := proto.Marshal(&eventsv1.InventarioRecibido{
+		Id:              "event-1",
+		Timestamp:       timestamppb.New(wireTime),
+		EventType:       "InventarioRecibido",
+		PurchaseOrderId: "po-1",
+		ProductId:       "product-1",
+		ProductName:     "Gloves",
+		Quantity:        10,
+		SupplierId:      "supplier-1",
+		SupplierName:    "Acme",
+		Location:        "warehouse-1",
+		Status:          "received",
+		ReceivedAt:      timestamppb.New(wireTime),
+		QualityCheck:    "passed",
+		Temperature:     &temperature,
+		BatchNumber:     "LOT-1",
+		ExpiryDate:      timestamppb.New(expiry),
+	})
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+
+	event, err := DecodeInventoryReceivedEvent(ContentTypeProtobuf, body)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	want := &models.InventoryReceivedEvent{
+		ID:              "event-1",
+		Timestamp:       wireTime,
+		EventType:       "InventarioRecibido",
+		PurchaseOrderID: "po-1",
+		ProductID:       "product-1",
+		ProductName:     "Gloves",
+		Quantity:        10,
+		SupplierID:      "supplier-1",
+		SupplierName:    "Acme",
+		Location:        "warehouse-1",
+		Status:          "received",
+		ReceivedAt:      wireTime,
+		QualityCheck:    "passed",
+		Temperature:     &temperature,
+		BatchNumber:     "LOT-1",
+		ExpiryDate:      &expiry,
+		Metadata:        map[string]interface{}{},
+	}
+	if !reflect.DeepEqual(event, want) {
+		t.Fatalf("decoded %+v, want %+v", event, want)
+	}
+
+	// Unset optional fields stay unset
+	body, _ = proto.Marshal(&eventsv1.InventarioRecibido{Id: "event-2"})
+	event, err = DecodeInventoryReceivedEvent(ContentTypeProtobuf, body)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if event.Temperature != nil || event.ExpiryDate != nil {
+		t.Fatalf("unset temperature %v or expiry date %v decoded", event.Temperature, event.ExpiryDate)
+	}
+}
+
+func TestEncodeRecepcionProveedorEventDecodesAsGeneratedMessage(t *testing.T) {
+	event := models.NewRecepcionProveedorEvent("po-1", "product-1", "Gloves", "supplier-1", "Acme", "warehouse-1", models.StatusSent, 10, wireTime)
+	event.Metadata = map[string]interface{}{"correlation_id": "correlation-1"}
+
+	body, contentType, err := EncodeRecepcionProveedorEvent(ContentTypeProtobuf, event, EncodeOptions{})
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	if contentType != ContentTypeProtobuf {
+		t.Fatalf("content type %q", contentType)
+	}
+
+	var message eventsv1.RecepcionProveedor
+	if err := proto.Unmarshal(body, &message); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	want := &eventsv1.RecepcionProveedor{
+		Id:              event.ID,
+		Timestamp:       timestamppb.New(wireTime),
+		EventType:       string(event.EventType),
+		PurchaseOrderId: "po-1",
+		ProductId:       "product-1",
+		ProductName:     "Gloves",
+		Quantity:        10,
+		SupplierId:      "supplier-1",
+		SupplierName:    "Acme",
+		Location:        "warehouse-1",
+		Status:          models.StatusSent,
+		Metadata:        []byte(`{"correlation_id":"correlation-1"}`),
+	}
+	if !proto.Equal(&message, want) {
+		t.Fatalf("decoded %v, want %v", &message, want)
+	}
+}
+
+func TestDecodeRejectsMalformedProtobuf(t *testing.T) {
+	// A string field that is not UTF-8
+	if _, err := DecodeStockLowEvent(ContentTypeProtobuf, []byte{0x0a, 0x01, 0xff}); err == nil {
+		t.Fatal("decoded a string that is not UTF-8")
+	}
+	// A truncated length-delimited field
+	if _, err := DecodeInventoryReceivedEvent(ContentTypeProtobuf, []byte{0x0a, 0x05, 'a'}); err == nil {
+		t.Fatal("decoded a truncated message")
+	}
+}
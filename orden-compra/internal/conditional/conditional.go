@@ -0,0 +1,123 @@
+// Package conditional implements HTTP conditional requests: entity tags on
+// read responses, so polling clients get 304 Not Modified instead of a body
+// they already have, and If-Match checks on commands.
+package conditional
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HTTP headers of conditional requests
+const (
+	ETagHeader        = "ETag"
+	IfMatchHeader     = "If-Match"
+	IfNoneMatchHeader = "If-None-Match"
+)
+
+// Matches reports whether an If-Match or If-None-Match header value lists
+// the entity tag. "*" matches any. If-Match compares strongly, so weak tags
+// never match; If-None-Match compares weakly.
+func Matches(header, etag string, weak bool) bool {
+	if strings.TrimSpace(header) == "*" {
+		return true
+	}
+	if !weak && strings.HasPrefix(etag, "W/") {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if weak {
+			if strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		} else if candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// bufferedWriter holds the response back until its entity tag is known
+type bufferedWriter struct {
+	gin.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *bufferedWriter) WriteHeader(code int) {
+	w.status = code
+}
+
+func (w *bufferedWriter) WriteHeaderNow() {}
+
+func (w *bufferedWriter) Write(data []byte) (int, error) {
+	return w.body.Write(data)
+}
+
+func (w *bufferedWriter) WriteString(s string) (int, error) {
+	return w.body.WriteString(s)
+}
+
+func (w *bufferedWriter) Status() int {
+	if w.status == 0 {
+		return 200
+	}
+	return w.status
+}
+
+func (w *bufferedWriter) Size() int {
+	return w.body.Len()
+}
+
+func (w *bufferedWriter) Written() bool {
+	return w.status != 0 || w.body.Len() > 0
+}
+
+// Middleware tags successful responses of a read route and answers 304 Not
+// Modified when the request's If-None-Match lists the tag. A handler may set
+// its own ETag, such as one derived from a version; otherwise the tag is a
+// weak one hashed from the body. Responses are buffered, so it is meant for
+// routes with bounded JSON bodies, not streamed exports.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method != "GET" && c.Request.Method != "HEAD" {
+			c.Next()
+			return
+		}
+
+		writer := c.Writer
+		buffered := &bufferedWriter{ResponseWriter: writer}
+		c.Writer = buffered
+		c.Next()
+		c.Writer = writer
+
+		status := buffered.Status()
+		if status == 200 {
+			etag := writer.Header().Get(ETagHeader)
+			if etag == "" {
+				sum := sha256.Sum256(buffered.body.Bytes())
+				etag = `W/"` + hex.EncodeToString(sum[:16]) + `"`
+				writer.Header().Set(ETagHeader, etag)
+			}
+			if header := c.GetHeader(IfNoneMatchHeader); header != "" && Matches(header, etag, true) {
+				writer.Header().Del("Content-Type")
+				writer.Header().Del("Content-Length")
+				writer.WriteHeader(304)
+				writer.WriteHeaderNow()
+				return
+			}
+		}
+
+		writer.WriteHeader(status)
+		if buffered.body.Len() == 0 {
+			writer.WriteHeaderNow()
+			return
+		}
+		writer.Write(buffered.body.Bytes())
+	}
+}
@@ -0,0 +1,87 @@
+package conditional
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMatches(t *testing.T) {
+	for _, tc := range []struct {
+		header string
+		etag   string
+		weak   bool
+		want   bool
+	}{
+		{`*`, `"1-a"`, false, true},
+		{`"1-a"`, `"1-a"`, false, true},
+		{`"0-b", "1-a"`, `"1-a"`, false, true},
+		{`"1-b"`, `"1-a"`, false, false},
+		{`W/"1-a"`, `"1-a"`, false, false},
+		{`"1-a"`, `W/"1-a"`, false, false},
+		{`W/"1-a"`, `"1-a"`, true, true},
+		{`"1-a"`, `W/"1-a"`, true, true},
+		{`W/"1-b"`, `W/"1-a"`, true, false},
+	} {
+		if got := Matches(tc.header, tc.etag, tc.weak); got != tc.want {
+			t.Errorf("Matches(%q, %q, weak %t) = %t, want %t", tc.header, tc.etag, tc.weak, got, tc.want)
+		}
+	}
+}
+
+func newRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Middleware())
+	router.GET("/hashed", func(c *gin.Context) { c.JSON(200, gin.H{"id": "po-1"}) })
+	router.GET("/versioned", func(c *gin.Context) {
+		c.Header(ETagHeader, `"3-x"`)
+		c.JSON(200, gin.H{"id": "po-1"})
+	})
+	router.GET("/missing", func(c *gin.Context) { c.JSON(404, gin.H{"error": "not found"}) })
+	return router
+}
+
+func get(router *gin.Engine, path, ifNoneMatch string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodGet, path, nil)
+	if ifNoneMatch != "" {
+		request.Header.Set(IfNoneMatchHeader, ifNoneMatch)
+	}
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestMiddlewareTagsResponses(t *testing.T) {
+	router := newRouter()
+
+	first := get(router, "/hashed", "")
+	etag := first.Header().Get(ETagHeader)
+	if first.Code != 200 || first.Body.String() != `{"id":"po-1"}` || len(etag) < 4 || etag[:3] != `W/"` {
+		t.Fatalf("answered %d with %s and ETag %q, want the body under a weak tag", first.Code, first.Body, etag)
+	}
+	if again := get(router, "/hashed", ""); again.Header().Get(ETagHeader) != etag {
+		t.Fatalf("the same body was tagged %q, then %q", etag, again.Header().Get(ETagHeader))
+	}
+
+	// A client that has the body gets no body again
+	notModified := get(router, "/hashed", etag)
+	if notModified.Code != 304 || notModified.Body.Len() != 0 || notModified.Header().Get(ETagHeader) != etag {
+		t.Fatalf("conditional request answered %d with %q", notModified.Code, notModified.Body)
+	}
+	if changed := get(router, "/hashed", `W/"other"`); changed.Code != 200 || changed.Body.Len() == 0 {
+		t.Fatalf("request with another tag answered %d", changed.Code)
+	}
+
+	// A handler's own tag is kept and compared weakly
+	if versioned := get(router, "/versioned", `W/"3-x"`); versioned.Code != 304 || versioned.Header().Get(ETagHeader) != `"3-x"` {
+		t.Fatalf("versioned route answered %d with ETag %q", versioned.Code, versioned.Header().Get(ETagHeader))
+	}
+
+	// Errors are passed through untagged
+	if missing := get(router, "/missing", "*"); missing.Code != 404 || missing.Header().Get(ETagHeader) != "" || missing.Body.String() != `{"error":"not found"}` {
+		t.Fatalf("missing route answered %d with %s and ETag %q", missing.Code, missing.Body, missing.Header().Get(ETagHeader))
+	}
+}
@@ -0,0 +1,164 @@
+package cqrs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+
+	"medisupply/clock"
+	"orden-compra/internal/models"
+)
+
+// ErrAcknowledgementNotAllowed is returned when an acknowledgement arrives for an order that is not awaiting one
+var ErrAcknowledgementNotAllowed = errors.New("purchase order is not awaiting an acknowledgement")
+
+// AcknowledgePurchaseOrderCommand records a supplier's acknowledgement of a purchase order
+type AcknowledgePurchaseOrderCommand struct {
+	PurchaseOrderID string
+	Acknowledgement *models.OrderAcknowledgement
+	DynamoDB        dynamodbiface.DynamoDBAPI
+	Logger          *log.Logger
+	Clock           clock.Clock
+	Stats           StatsProjection
+	CorrelationID   *string
+	CausationID     *string
+}
+
+// NewAcknowledgePurchaseOrderCommand creates a new AcknowledgePurchaseOrderCommand
+func NewAcknowledgePurchaseOrderCommand(purchaseOrderID string, acknowledgement *models.OrderAcknowledgement, dynamoDB dynamodbiface.DynamoDBAPI, logger *log.Logger, correlationID, causationID *string) *AcknowledgePurchaseOrderCommand {
+	return &AcknowledgePurchaseOrderCommand{
+		PurchaseOrderID: purchaseOrderID,
+		Acknowledgement: acknowledgement,
+		DynamoDB:        dynamoDB,
+		Logger:          logger,
+		Clock:           clock.System,
+		CorrelationID:   correlationID,
+		CausationID:     causationID,
+	}
+}
+
+// Execute attaches the acknowledgement to the order. An accepting supplier
+// moves the order to sent and its promised date becomes the expected date; a
+// rejection is only recorded, cancelling the order is up to the caller.
+func (c *AcknowledgePurchaseOrderCommand) Execute(ctx context.Context) (map[string]interface{}, error) {
+	c.Logger.Printf("Acknowledging purchase order - purchase_order_id: %s, correlation_id: %v", c.PurchaseOrderID, c.CorrelationID)
+
+	if c.Acknowledgement == nil {
+		return nil, models.ValidationErrors{{Field: "body", Message: "acknowledgement is required"}}
+	}
+	if err := c.Acknowledgement.Validate(); err != nil {
+		return nil, err
+	}
+
+	statusCommand := &UpdatePurchaseOrderStatusCommand{
+		PurchaseOrderID: c.PurchaseOrderID,
+		Status:          models.StatusSent,
+		DynamoDB:        c.DynamoDB,
+		Logger:          c.Logger,
+		Clock:           c.Clock,
+		Stats:           c.Stats,
+		CorrelationID:   c.CorrelationID,
+		CausationID:     c.CausationID,
+	}
+
+	purchaseOrder, err := statusCommand.getPurchaseOrder(ctx)
+	if err != nil {
+		c.Logger.Printf("Failed to get purchase order: %v", err)
+		return nil, fmt.Errorf("failed to get purchase order: %w", err)
+	}
+
+	// Orders awaiting a shipment are the ones released to the supplier
+	if !shipmentNoticeStatuses[purchaseOrder.Status] {
+		return nil, fmt.Errorf("%w: status is %s", ErrAcknowledgementNotAllowed, purchaseOrder.Status)
+	}
+
+	acknowledgement := *c.Acknowledgement
+	acknowledgement.ReceivedAt = c.Clock.Now().UTC()
+	if acknowledgement.PromisedDate != nil {
+		promisedDate := acknowledgement.PromisedDate.UTC()
+		acknowledgement.PromisedDate = &promisedDate
+	}
+
+	previousStatus := purchaseOrder.Status
+	purchaseOrder.Acknowledgement = &acknowledgement
+	purchaseOrder.UpdatedAt = acknowledgement.ReceivedAt
+
+	if acknowledgement.Status != models.AcknowledgementRejected {
+		if purchaseOrder.Status != models.StatusSent {
+			if err := purchaseOrder.UpdateStatus(models.StatusSent, c.Clock.Now()); err != nil {
+				return nil, fmt.Errorf("failed to acknowledge purchase order: %w", err)
+			}
+		}
+		// The supplier's promise replaces our lead time estimate until an ASN arrives
+		if acknowledgement.PromisedDate != nil && purchaseOrder.ASN == nil {
+			expectedDate := *acknowledgement.PromisedDate
+			purchaseOrder.ExpectedDate = &expectedDate
+			if expectedDate.After(acknowledgement.ReceivedAt) {
+				delete(purchaseOrder.Metadata, models.MetadataOverdueDetectedAt)
+			}
+		}
+	}
+
+	if err := statusCommand.storePurchaseOrder(ctx, purchaseOrder); err != nil {
+		c.Logger.Printf("Failed to store purchase order: %v", err)
+		return nil, fmt.Errorf("failed to store purchase order: %w", err)
+	}
+
+	if err := c.storeEventSourcingEvent(ctx, purchaseOrder, previousStatus); err != nil {
+		c.Logger.Printf("Failed to store event sourcing event: %v", err)
+		return nil, fmt.Errorf("failed to store event sourcing event: %w", err)
+	}
+
+	c.Logger.Printf("Purchase order acknowledged - purchase_order_id: %s, acknowledgement: %s, status: %s",
+		c.PurchaseOrderID, acknowledgement.Status, purchaseOrder.Status)
+
+	return map[string]interface{}{
+		"success":           true,
+		"purchase_order_id": c.PurchaseOrderID,
+		"status":            purchaseOrder.Status,
+		"previous_status":   previousStatus,
+		"acknowledgement":   &acknowledgement,
+		"correlation_id":    c.CorrelationID,
+	}, nil
+}
+
+// storeEventSourcingEvent stores the PurchaseOrderAcknowledged event sourcing event
+func (c *AcknowledgePurchaseOrderCommand) storeEventSourcingEvent(ctx context.Context, purchaseOrder *models.PurchaseOrder, previousStatus string) error {
+	eventData := map[string]interface{}{
+		"purchase_order":  purchaseOrder,
+		"acknowledgement": purchaseOrder.Acknowledgement,
+		"previous_status": previousStatus,
+	}
+
+	event := models.NewEventSourcingEvent(
+		purchaseOrder.ID,
+		models.PurchaseOrderAcknowledgedEventType,
+		eventData,
+		c.CorrelationID,
+		c.CausationID,
+		c.Clock.Now(),
+	)
+	event.TenantID = purchaseOrder.TenantID
+
+	item, err := dynamodbattribute.MarshalMap(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event sourcing event: %w", err)
+	}
+
+	_, err = c.DynamoDB.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String("orden-compra-events"),
+		Item:      item,
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to put event sourcing event: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,270 @@
+package cqrs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+
+	"medisupply/clock"
+	"orden-compra/internal/models"
+)
+
+// ApprovePurchaseOrderCommand approves a purchase order waiting for approval
+type ApprovePurchaseOrderCommand struct {
+	PurchaseOrderID string
+	Approver        string
+	Comment         string
+	DynamoDB        dynamodbiface.DynamoDBAPI
+	Logger          *log.Logger
+	Clock           clock.Clock
+	Stats           StatsProjection
+	CorrelationID   *string
+	CausationID     *string
+}
+
+// NewApprovePurchaseOrderCommand creates a new ApprovePurchaseOrderCommand
+func NewApprovePurchaseOrderCommand(purchaseOrderID, approver, comment string, dynamoDB dynamodbiface.DynamoDBAPI, logger *log.Logger, correlationID, causationID *string) *ApprovePurchaseOrderCommand {
+	return &ApprovePurchaseOrderCommand{
+		PurchaseOrderID: purchaseOrderID,
+		Approver:        approver,
+		Comment:         comment,
+		DynamoDB:        dynamoDB,
+		Logger:          logger,
+		Clock:           clock.System,
+		CorrelationID:   correlationID,
+		CausationID:     causationID,
+	}
+}
+
+// Execute approves the purchase order and returns the reception event to publish
+func (c *ApprovePurchaseOrderCommand) Execute(ctx context.Context) (map[string]interface{}, error) {
+	c.Logger.Printf("Approving purchase order - purchase_order_id: %s, approver: %s, correlation_id: %v", c.PurchaseOrderID, c.Approver, c.CorrelationID)
+
+	decision := &approvalDecision{
+		PurchaseOrderID: c.PurchaseOrderID,
+		Actor:           c.Approver,
+		Comment:         c.Comment,
+		Status:          models.StatusApproved,
+		ActorKey:        models.MetadataApprovedBy,
+		TimeKey:         models.MetadataApprovedAt,
+		EventType:       "PurchaseOrderApproved",
+		DynamoDB:        c.DynamoDB,
+		Logger:          c.Logger,
+		Clock:           c.Clock,
+		Stats:           c.Stats,
+		CorrelationID:   c.CorrelationID,
+		CausationID:     c.CausationID,
+	}
+
+	purchaseOrder, err := decision.apply(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Approved orders are now released to Proveedor
+	receptionEvent := newReceptionEvent(purchaseOrder, c.CorrelationID, c.CausationID, c.Clock.Now())
+	receptionEvent.Metadata[models.MetadataApprovedBy] = c.Approver
+
+	c.Logger.Printf("Purchase order approved - purchase_order_id: %s, approver: %s", c.PurchaseOrderID, c.Approver)
+
+	return map[string]interface{}{
+		"success":           true,
+		"purchase_order_id": c.PurchaseOrderID,
+		"status":            purchaseOrder.Status,
+		"purchase_order":    purchaseOrder,
+		"reception_event":   receptionEvent,
+		"correlation_id":    c.CorrelationID,
+	}, nil
+}
+
+// RejectPurchaseOrderCommand rejects a purchase order waiting for approval
+type RejectPurchaseOrderCommand struct {
+	PurchaseOrderID string
+	Approver        string
+	Comment         string
+	DynamoDB        dynamodbiface.DynamoDBAPI
+	Logger          *log.Logger
+	Clock           clock.Clock
+	Stats           StatsProjection
+	CorrelationID   *string
+	CausationID     *string
+}
+
+// NewRejectPurchaseOrderCommand creates a new RejectPurchaseOrderCommand
+func NewRejectPurchaseOrderCommand(purchaseOrderID, approver, comment string, dynamoDB dynamodbiface.DynamoDBAPI, logger *log.Logger, correlationID, causationID *string) *RejectPurchaseOrderCommand {
+	return &RejectPurchaseOrderCommand{
+		PurchaseOrderID: purchaseOrderID,
+		Approver:        approver,
+		Comment:         comment,
+		DynamoDB:        dynamoDB,
+		Logger:          logger,
+		Clock:           clock.System,
+		CorrelationID:   correlationID,
+		CausationID:     causationID,
+	}
+}
+
+// Execute rejects the purchase order
+func (c *RejectPurchaseOrderCommand) Execute(ctx context.Context) (map[string]interface{}, error) {
+	c.Logger.Printf("Rejecting purchase order - purchase_order_id: %s, approver: %s, correlation_id: %v", c.PurchaseOrderID, c.Approver, c.CorrelationID)
+
+	decision := &approvalDecision{
+		PurchaseOrderID: c.PurchaseOrderID,
+		Actor:           c.Approver,
+		Comment:         c.Comment,
+		Status:          models.StatusRejected,
+		ActorKey:        models.MetadataRejectedBy,
+		TimeKey:         models.MetadataRejectedAt,
+		EventType:       "PurchaseOrderRejected",
+		DynamoDB:        c.DynamoDB,
+		Logger:          c.Logger,
+		Clock:           c.Clock,
+		Stats:           c.Stats,
+		CorrelationID:   c.CorrelationID,
+		CausationID:     c.CausationID,
+	}
+
+	purchaseOrder, err := decision.apply(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c.Logger.Printf("Purchase order rejected - purchase_order_id: %s, approver: %s", c.PurchaseOrderID, c.Approver)
+
+	return map[string]interface{}{
+		"success":           true,
+		"purchase_order_id": c.PurchaseOrderID,
+		"status":            purchaseOrder.Status,
+		"purchase_order":    purchaseOrder,
+		"correlation_id":    c.CorrelationID,
+	}, nil
+}
+
+// approvalDecision applies an approve or reject decision to a purchase order
+type approvalDecision struct {
+	PurchaseOrderID string
+	Actor           string
+	Comment         string
+	Status          string
+	ActorKey        string
+	TimeKey         string
+	EventType       string
+	DynamoDB        dynamodbiface.DynamoDBAPI
+	Logger          *log.Logger
+	Clock           clock.Clock
+	Stats           StatsProjection
+	CorrelationID   *string
+	CausationID     *string
+}
+
+// apply moves the order out of pending_approval and records who decided
+func (d *approvalDecision) apply(ctx context.Context) (*models.PurchaseOrder, error) {
+	if d.Actor == "" {
+		return nil, fmt.Errorf("approver is required")
+	}
+
+	statusCommand := &UpdatePurchaseOrderStatusCommand{
+		PurchaseOrderID: d.PurchaseOrderID,
+		Status:          d.Status,
+		DynamoDB:        d.DynamoDB,
+		Logger:          d.Logger,
+		Clock:           d.Clock,
+		Stats:           d.Stats,
+		CorrelationID:   d.CorrelationID,
+		CausationID:     d.CausationID,
+	}
+
+	purchaseOrder, err := statusCommand.getPurchaseOrder(ctx)
+	if err != nil {
+		d.Logger.Printf("Failed to get purchase order: %v", err)
+		return nil, fmt.Errorf("failed to get purchase order: %w", err)
+	}
+
+	// Only orders waiting for approval can be decided
+	if purchaseOrder.Status != models.StatusPendingApproval {
+		err := &models.StatusTransitionError{
+			From:    purchaseOrder.Status,
+			To:      d.Status,
+			Allowed: models.AllowedTransitions(purchaseOrder.Status),
+		}
+		if storeErr := statusCommand.storeRejectedEvent(ctx, purchaseOrder, err); storeErr != nil {
+			d.Logger.Printf("Failed to store status rejected event: %v", storeErr)
+		}
+		return nil, fmt.Errorf("purchase order is not awaiting approval: %w", err)
+	}
+
+	if err := purchaseOrder.UpdateStatus(d.Status, d.Clock.Now()); err != nil {
+		return nil, fmt.Errorf("failed to update purchase order status: %w", err)
+	}
+
+	// The supplier's lead time only starts once the order is released
+	if d.Status == models.StatusApproved {
+		if leadTimeDays, ok := purchaseOrder.SLALeadTimeDays(); ok {
+			source, _ := purchaseOrder.Metadata[models.MetadataSLASource].(string)
+			purchaseOrder.ApplySLA(purchaseOrder.UpdatedAt, leadTimeDays, source)
+		}
+	}
+
+	if purchaseOrder.Metadata == nil {
+		purchaseOrder.Metadata = make(map[string]interface{})
+	}
+	purchaseOrder.Metadata[d.ActorKey] = d.Actor
+	purchaseOrder.Metadata[d.TimeKey] = purchaseOrder.UpdatedAt.Format(time.RFC3339)
+	if d.Comment != "" {
+		purchaseOrder.Metadata[models.MetadataApprovalComment] = d.Comment
+	}
+
+	if err := statusCommand.storePurchaseOrder(ctx, purchaseOrder); err != nil {
+		d.Logger.Printf("Failed to store purchase order: %v", err)
+		return nil, fmt.Errorf("failed to store purchase order: %w", err)
+	}
+
+	if err := d.storeEventSourcingEvent(ctx, purchaseOrder); err != nil {
+		d.Logger.Printf("Failed to store event sourcing event: %v", err)
+		return nil, fmt.Errorf("failed to store event sourcing event: %w", err)
+	}
+
+	return purchaseOrder, nil
+}
+
+// storeEventSourcingEvent stores the approval decision event
+func (d *approvalDecision) storeEventSourcingEvent(ctx context.Context, purchaseOrder *models.PurchaseOrder) error {
+	eventData := map[string]interface{}{
+		"purchase_order":  purchaseOrder,
+		"previous_status": models.StatusPendingApproval,
+		"decided_by":      d.Actor,
+		"comment":         d.Comment,
+	}
+
+	event := models.NewEventSourcingEvent(
+		purchaseOrder.ID,
+		d.EventType,
+		eventData,
+		d.CorrelationID,
+		d.CausationID,
+		d.Clock.Now(),
+	)
+	event.TenantID = purchaseOrder.TenantID
+
+	item, err := dynamodbattribute.MarshalMap(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event sourcing event: %w", err)
+	}
+
+	_, err = d.DynamoDB.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String("orden-compra-events"),
+		Item:      item,
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to put event sourcing event: %w", err)
+	}
+
+	return nil
+}
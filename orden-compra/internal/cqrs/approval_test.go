@@ -0,0 +1,84 @@
+package cqrs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"medisupply/clock"
+	"orden-compra/internal/memory"
+	"orden-compra/internal/models"
+)
+
+func TestStockLowAboveTheThresholdWaitsForApproval(t *testing.T) {
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	fake := clock.NewFake(statsDay)
+	policy := models.PurchaseOrderPolicy{Approval: models.ApprovalPolicy{QuantityThreshold: 1}}
+
+	result, err := newStockLowCommand(dynamoDB, fake, policy).Execute(context.Background())
+	if err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	stored := getPurchaseOrder(t, dynamoDB, result["purchase_order_id"].(string))
+	if stored.Status != models.StatusPendingApproval || stored.Metadata[models.MetadataApprovalReason] != "quantity above approval threshold" {
+		t.Fatalf("stored order %+v, want pending approval above the threshold", stored)
+	}
+	if result["reception_event"] != nil {
+		t.Fatalf("an order awaiting approval was released to Proveedor: %v", result["reception_event"])
+	}
+}
+
+func TestRejectPurchaseOrder(t *testing.T) {
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	fake := clock.NewFake(statsDay)
+	created := createPurchaseOrder(t, dynamoDB, fake, models.StatusPendingApproval)
+
+	// The approver is required
+	reject := NewRejectPurchaseOrderCommand(created.ID, "", "over budget", dynamoDB, discardLogger, nil, nil)
+	reject.Clock = fake
+	if _, err := reject.Execute(context.Background()); err == nil {
+		t.Fatal("rejected without an approver")
+	}
+
+	fake.Advance(time.Hour)
+	reject = NewRejectPurchaseOrderCommand(created.ID, "bob", "over budget", dynamoDB, discardLogger, nil, nil)
+	reject.Clock = fake
+	result, err := reject.Execute(context.Background())
+	if err != nil {
+		t.Fatalf("reject: %v", err)
+	}
+	if result["reception_event"] != nil {
+		t.Fatalf("a rejected order was released: %v", result["reception_event"])
+	}
+
+	stored := getPurchaseOrder(t, dynamoDB, created.ID)
+	if stored.Status != models.StatusRejected || stored.Metadata[models.MetadataRejectedBy] != "bob" || stored.Metadata[models.MetadataApprovalComment] != "over budget" {
+		t.Fatalf("stored order %+v, want rejected by bob", stored)
+	}
+	if rejectedAt := stored.Metadata[models.MetadataRejectedAt]; rejectedAt != fake.Now().Format(time.RFC3339) {
+		t.Fatalf("rejected at %v, want %s", rejectedAt, fake.Now().Format(time.RFC3339))
+	}
+	if times := storedEventTimes(t, dynamoDB, "PurchaseOrderRejected"); len(times) != 1 || !times[0].Equal(fake.Now()) {
+		t.Fatalf("PurchaseOrderRejected events at %v, want one at %s", times, fake.Now())
+	}
+}
+
+func TestOnlyOrdersAwaitingApprovalCanBeDecided(t *testing.T) {
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	fake := clock.NewFake(statsDay)
+	created := createPurchaseOrder(t, dynamoDB, fake, models.StatusPending)
+
+	approve := NewApprovePurchaseOrderCommand(created.ID, "alice", "", dynamoDB, discardLogger, nil, nil)
+	approve.Clock = fake
+	var transition *models.StatusTransitionError
+	if _, err := approve.Execute(context.Background()); !errors.As(err, &transition) || transition.From != models.StatusPending {
+		t.Fatalf("approving a pending order returned %v, want a StatusTransitionError", err)
+	}
+	if stored := getPurchaseOrder(t, dynamoDB, created.ID); stored.Status != models.StatusPending || stored.Metadata[models.MetadataApprovedBy] != nil {
+		t.Fatalf("stored order %+v after a refused approval", stored)
+	}
+	if times := storedEventTimes(t, dynamoDB, "PurchaseOrderStatusRejected"); len(times) != 1 {
+		t.Fatalf("recorded %d refused transitions, want 1", len(times))
+	}
+}
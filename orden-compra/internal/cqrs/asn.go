@@ -0,0 +1,168 @@
+package cqrs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/google/uuid"
+
+	"medisupply/clock"
+	"orden-compra/internal/models"
+)
+
+// ErrShipmentNoticeNotAllowed is returned when an ASN arrives for an order that is no longer expecting a shipment
+var ErrShipmentNoticeNotAllowed = errors.New("purchase order is not awaiting a shipment")
+
+// shipmentNoticeStatuses lists the statuses of orders released to the supplier and not yet received
+var shipmentNoticeStatuses = map[string]bool{
+	models.StatusPending:  true,
+	models.StatusApproved: true,
+	models.StatusSent:     true,
+}
+
+// AttachAdvanceShipmentNoticeCommand attaches a supplier's advance shipment notice to a purchase order
+type AttachAdvanceShipmentNoticeCommand struct {
+	PurchaseOrderID string
+	Notice          *models.AdvanceShipmentNotice
+	DynamoDB        dynamodbiface.DynamoDBAPI
+	Logger          *log.Logger
+	Clock           clock.Clock
+	Stats           StatsProjection
+	CorrelationID   *string
+	CausationID     *string
+}
+
+// NewAttachAdvanceShipmentNoticeCommand creates a new AttachAdvanceShipmentNoticeCommand
+func NewAttachAdvanceShipmentNoticeCommand(purchaseOrderID string, notice *models.AdvanceShipmentNotice, dynamoDB dynamodbiface.DynamoDBAPI, logger *log.Logger, correlationID, causationID *string) *AttachAdvanceShipmentNoticeCommand {
+	return &AttachAdvanceShipmentNoticeCommand{
+		PurchaseOrderID: purchaseOrderID,
+		Notice:          notice,
+		DynamoDB:        dynamoDB,
+		Logger:          logger,
+		Clock:           clock.System,
+		CorrelationID:   correlationID,
+		CausationID:     causationID,
+	}
+}
+
+// Execute attaches the notice, moves the order's expected date to the announced
+// delivery date and returns the ASN event to publish to Proveedor. A later
+// notice for the same order replaces the earlier one.
+func (c *AttachAdvanceShipmentNoticeCommand) Execute(ctx context.Context) (map[string]interface{}, error) {
+	c.Logger.Printf("Attaching advance shipment notice - purchase_order_id: %s, correlation_id: %v", c.PurchaseOrderID, c.CorrelationID)
+
+	if c.Notice == nil {
+		return nil, models.ValidationErrors{{Field: "body", Message: "advance shipment notice is required"}}
+	}
+	if err := c.Notice.Validate(); err != nil {
+		return nil, err
+	}
+
+	statusCommand := &UpdatePurchaseOrderStatusCommand{
+		PurchaseOrderID: c.PurchaseOrderID,
+		DynamoDB:        c.DynamoDB,
+		Logger:          c.Logger,
+		Clock:           c.Clock,
+		Stats:           c.Stats,
+		CorrelationID:   c.CorrelationID,
+		CausationID:     c.CausationID,
+	}
+
+	purchaseOrder, err := statusCommand.getPurchaseOrder(ctx)
+	if err != nil {
+		c.Logger.Printf("Failed to get purchase order: %v", err)
+		return nil, fmt.Errorf("failed to get purchase order: %w", err)
+	}
+
+	if !shipmentNoticeStatuses[purchaseOrder.Status] {
+		return nil, fmt.Errorf("%w: status is %s", ErrShipmentNoticeNotAllowed, purchaseOrder.Status)
+	}
+	if c.Notice.SupplierID != "" && c.Notice.SupplierID != purchaseOrder.SupplierID {
+		return nil, models.ValidationErrors{{Field: "supplier_id", Message: fmt.Sprintf("must be the order's supplier %s", purchaseOrder.SupplierID)}}
+	}
+
+	notice := *c.Notice
+	notice.ID = uuid.New().String()
+	notice.SupplierID = purchaseOrder.SupplierID
+	notice.ExpectedDeliveryDate = notice.ExpectedDeliveryDate.UTC()
+	notice.ReceivedAt = c.Clock.Now().UTC()
+
+	previousExpectedDate := purchaseOrder.ExpectedDate
+	expectedDate := notice.ExpectedDeliveryDate
+	purchaseOrder.ASN = &notice
+	purchaseOrder.ExpectedDate = &expectedDate
+	purchaseOrder.UpdatedAt = notice.ReceivedAt
+
+	// A rescheduled delivery may become overdue again
+	if expectedDate.After(notice.ReceivedAt) {
+		delete(purchaseOrder.Metadata, models.MetadataOverdueDetectedAt)
+	}
+
+	if err := statusCommand.storePurchaseOrder(ctx, purchaseOrder); err != nil {
+		c.Logger.Printf("Failed to store purchase order: %v", err)
+		return nil, fmt.Errorf("failed to store purchase order: %w", err)
+	}
+
+	if err := c.storeEventSourcingEvent(ctx, purchaseOrder, previousExpectedDate); err != nil {
+		c.Logger.Printf("Failed to store event sourcing event: %v", err)
+		return nil, fmt.Errorf("failed to store event sourcing event: %w", err)
+	}
+
+	asnEvent := models.NewAdvanceShipmentNoticeEvent(purchaseOrder, &notice, c.Clock.Now())
+	asnEvent.Metadata["correlation_id"] = c.CorrelationID
+	asnEvent.Metadata["causation_id"] = c.CausationID
+
+	c.Logger.Printf("Advance shipment notice attached - purchase_order_id: %s, asn_id: %s, carrier: %s, expected_date: %s",
+		c.PurchaseOrderID, notice.ID, notice.Carrier, expectedDate.Format(time.RFC3339))
+
+	return map[string]interface{}{
+		"success":           true,
+		"purchase_order_id": c.PurchaseOrderID,
+		"expected_date":     expectedDate,
+		"asn":               &notice,
+		"asn_event":         asnEvent,
+		"correlation_id":    c.CorrelationID,
+	}, nil
+}
+
+// storeEventSourcingEvent stores the PurchaseOrderShipmentNotified event sourcing event
+func (c *AttachAdvanceShipmentNoticeCommand) storeEventSourcingEvent(ctx context.Context, purchaseOrder *models.PurchaseOrder, previousExpectedDate *time.Time) error {
+	eventData := map[string]interface{}{
+		"purchase_order":         purchaseOrder,
+		"asn":                    purchaseOrder.ASN,
+		"previous_expected_date": previousExpectedDate,
+	}
+
+	event := models.NewEventSourcingEvent(
+		purchaseOrder.ID,
+		models.PurchaseOrderShipmentNotifiedEventType,
+		eventData,
+		c.CorrelationID,
+		c.CausationID,
+		c.Clock.Now(),
+	)
+	event.TenantID = purchaseOrder.TenantID
+
+	item, err := dynamodbattribute.MarshalMap(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event sourcing event: %w", err)
+	}
+
+	_, err = c.DynamoDB.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String("orden-compra-events"),
+		Item:      item,
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to put event sourcing event: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,107 @@
+package cqrs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"medisupply/clock"
+	"orden-compra/internal/memory"
+	"orden-compra/internal/models"
+)
+
+func attachShipmentNotice(dynamoDB *memory.DynamoDB, fake *clock.Fake, id string, notice *models.AdvanceShipmentNotice) (map[string]interface{}, error) {
+	correlationID := "correlation-1"
+	command := NewAttachAdvanceShipmentNoticeCommand(id, notice, dynamoDB, discardLogger, &correlationID, nil)
+	command.Clock = fake
+	return command.Execute(context.Background())
+}
+
+func TestAttachAdvanceShipmentNotice(t *testing.T) {
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	fake := clock.NewFake(statsDay)
+	created := createPurchaseOrder(t, dynamoDB, fake, models.StatusSent)
+
+	fake.Advance(time.Hour)
+	expected := statsDay.Add(72 * time.Hour)
+	result, err := attachShipmentNotice(dynamoDB, fake, created.ID, &models.AdvanceShipmentNotice{
+		ExpectedDeliveryDate: expected,
+		Carrier:              "DHL",
+		TrackingNumber:       "TRACK-1",
+		Lots:                 []models.ShipmentLot{{BatchNumber: "LOT-1", Quantity: 10}},
+	})
+	if err != nil {
+		t.Fatalf("attach: %v", err)
+	}
+
+	event := result["asn_event"].(*models.AdvanceShipmentNoticeEvent)
+	if event.PurchaseOrderID != created.ID || event.Carrier != "DHL" || !event.ExpectedDeliveryDate.Equal(expected) || event.SupplierID != "supplier-1" {
+		t.Fatalf("asn event %+v", event)
+	}
+	if correlationID, _ := event.Metadata["correlation_id"].(*string); correlationID == nil || *correlationID != "correlation-1" {
+		t.Fatalf("asn event metadata %v", event.Metadata)
+	}
+
+	shipped := getPurchaseOrder(t, dynamoDB, created.ID)
+	if shipped.ASN == nil || shipped.ASN.ID != event.ASNID || shipped.ASN.SupplierID != "supplier-1" || !shipped.ASN.ReceivedAt.Equal(fake.Now()) {
+		t.Fatalf("order ASN %+v", shipped.ASN)
+	}
+	if shipped.ExpectedDate == nil || !shipped.ExpectedDate.Equal(expected) {
+		t.Fatalf("expected date %v, want %s", shipped.ExpectedDate, expected)
+	}
+	if times := storedEventTimes(t, dynamoDB, models.PurchaseOrderShipmentNotifiedEventType); len(times) != 1 || !times[0].Equal(fake.Now()) {
+		t.Fatalf("shipment notified events at %v", times)
+	}
+}
+
+func TestAttachAdvanceShipmentNoticeReschedulesAnOverdueOrder(t *testing.T) {
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	fake := clock.NewFake(statsDay)
+
+	purchaseOrder := models.NewPurchaseOrder("product-1", "Gloves", "supplier-1", "Acme", "warehouse-1", "HIGH", 10, fake.Now())
+	purchaseOrder.Status = models.StatusSent
+	purchaseOrder.Version = 1
+	purchaseOrder.Metadata[models.MetadataOverdueDetectedAt] = statsDay.Format(time.RFC3339)
+	putItem(t, dynamoDB, "orden-compra-read", purchaseOrder)
+
+	notice := &models.AdvanceShipmentNotice{ExpectedDeliveryDate: statsDay.Add(24 * time.Hour), Carrier: "DHL", TrackingNumber: "TRACK-1"}
+	if _, err := attachShipmentNotice(dynamoDB, fake, purchaseOrder.ID, notice); err != nil {
+		t.Fatalf("attach: %v", err)
+	}
+	if rescheduled := getPurchaseOrder(t, dynamoDB, purchaseOrder.ID); rescheduled.Metadata[models.MetadataOverdueDetectedAt] != nil {
+		t.Fatalf("rescheduled order is still marked overdue: %v", rescheduled.Metadata)
+	}
+}
+
+func TestAttachAdvanceShipmentNoticeRejections(t *testing.T) {
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	fake := clock.NewFake(statsDay)
+	notice := func() *models.AdvanceShipmentNotice {
+		return &models.AdvanceShipmentNotice{ExpectedDeliveryDate: statsDay.Add(24 * time.Hour), Carrier: "DHL", TrackingNumber: "TRACK-1"}
+	}
+
+	completed := createPurchaseOrder(t, dynamoDB, fake, models.StatusCompleted)
+	if _, err := attachShipmentNotice(dynamoDB, fake, completed.ID, notice()); !errors.Is(err, ErrShipmentNoticeNotAllowed) {
+		t.Fatalf("attaching to a completed order returned %v", err)
+	}
+
+	sent := createPurchaseOrder(t, dynamoDB, fake, models.StatusSent)
+	otherSupplier := notice()
+	otherSupplier.SupplierID = "supplier-2"
+	invalid := notice()
+	invalid.Carrier = ""
+	for name, notice := range map[string]*models.AdvanceShipmentNotice{"no notice": nil, "another supplier": otherSupplier, "invalid notice": invalid} {
+		var errs models.ValidationErrors
+		if _, err := attachShipmentNotice(dynamoDB, fake, sent.ID, notice); !errors.As(err, &errs) {
+			t.Fatalf("%s: attach returned %v, want validation errors", name, err)
+		}
+	}
+	if order := getPurchaseOrder(t, dynamoDB, sent.ID); order.ASN != nil {
+		t.Fatalf("rejected notices attached %+v", order.ASN)
+	}
+
+	if _, err := attachShipmentNotice(dynamoDB, fake, "missing", notice()); err == nil {
+		t.Fatal("attached a notice to an unknown order")
+	}
+}
@@ -0,0 +1,255 @@
+package cqrs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/google/uuid"
+
+	"medisupply/clock"
+	"orden-compra/internal/models"
+)
+
+// maxAnnotationAttempts bounds the writes of a comment or attachment to an
+// order that keeps being modified concurrently
+const maxAnnotationAttempts = 3
+
+// AddPurchaseOrderCommentCommand adds a buyer's comment to a purchase order
+type AddPurchaseOrderCommentCommand struct {
+	PurchaseOrderID string
+	Comment         *models.OrderComment
+	DynamoDB        dynamodbiface.DynamoDBAPI
+	Logger          *log.Logger
+	Clock           clock.Clock
+	Stats           StatsProjection
+	CorrelationID   *string
+	CausationID     *string
+}
+
+// NewAddPurchaseOrderCommentCommand creates a new AddPurchaseOrderCommentCommand
+func NewAddPurchaseOrderCommentCommand(purchaseOrderID string, comment *models.OrderComment, dynamoDB dynamodbiface.DynamoDBAPI, logger *log.Logger, correlationID, causationID *string) *AddPurchaseOrderCommentCommand {
+	return &AddPurchaseOrderCommentCommand{
+		PurchaseOrderID: purchaseOrderID,
+		Comment:         comment,
+		DynamoDB:        dynamoDB,
+		Logger:          logger,
+		Clock:           clock.System,
+		CorrelationID:   correlationID,
+		CausationID:     causationID,
+	}
+}
+
+// Execute appends the comment to the order, whatever its status, and records
+// a PurchaseOrderCommentAdded event
+func (c *AddPurchaseOrderCommentCommand) Execute(ctx context.Context) (map[string]interface{}, error) {
+	c.Logger.Printf("Adding comment to purchase order - purchase_order_id: %s, correlation_id: %v", c.PurchaseOrderID, c.CorrelationID)
+
+	if c.Comment == nil {
+		return nil, models.ValidationErrors{{Field: "body", Message: "comment is required"}}
+	}
+	if err := c.Comment.Validate(); err != nil {
+		return nil, err
+	}
+
+	comment := *c.Comment
+	comment.ID = uuid.New().String()
+	comment.CreatedAt = c.Clock.Now().UTC()
+
+	annotation := &orderAnnotation{
+		PurchaseOrderID: c.PurchaseOrderID,
+		EventType:       models.PurchaseOrderCommentAddedEventType,
+		EventKey:        "comment",
+		Value:           &comment,
+		DynamoDB:        c.DynamoDB,
+		Logger:          c.Logger,
+		Clock:           c.Clock,
+		Stats:           c.Stats,
+		CorrelationID:   c.CorrelationID,
+		CausationID:     c.CausationID,
+	}
+	purchaseOrder, err := annotation.apply(ctx, func(purchaseOrder *models.PurchaseOrder) error {
+		purchaseOrder.Comments = append(purchaseOrder.Comments, comment)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	c.Logger.Printf("Comment added to purchase order - purchase_order_id: %s, comment_id: %s, author: %s", c.PurchaseOrderID, comment.ID, comment.Author)
+
+	return map[string]interface{}{
+		"success":           true,
+		"purchase_order_id": c.PurchaseOrderID,
+		"comment":           &comment,
+		"version":           purchaseOrder.Version,
+		"correlation_id":    c.CorrelationID,
+	}, nil
+}
+
+// AddPurchaseOrderAttachmentCommand records the metadata of a file attached
+// to a purchase order. The caller gives the attachment its ID and storage
+// key; the file itself is uploaded separately.
+type AddPurchaseOrderAttachmentCommand struct {
+	PurchaseOrderID string
+	Attachment      *models.OrderAttachment
+	DynamoDB        dynamodbiface.DynamoDBAPI
+	Logger          *log.Logger
+	Clock           clock.Clock
+	Stats           StatsProjection
+	CorrelationID   *string
+	CausationID     *string
+}
+
+// NewAddPurchaseOrderAttachmentCommand creates a new AddPurchaseOrderAttachmentCommand
+func NewAddPurchaseOrderAttachmentCommand(purchaseOrderID string, attachment *models.OrderAttachment, dynamoDB dynamodbiface.DynamoDBAPI, logger *log.Logger, correlationID, causationID *string) *AddPurchaseOrderAttachmentCommand {
+	return &AddPurchaseOrderAttachmentCommand{
+		PurchaseOrderID: purchaseOrderID,
+		Attachment:      attachment,
+		DynamoDB:        dynamoDB,
+		Logger:          logger,
+		Clock:           clock.System,
+		CorrelationID:   correlationID,
+		CausationID:     causationID,
+	}
+}
+
+// Execute appends the attachment to the order, up to models.MaxOrderAttachments
+// per order, and records a PurchaseOrderAttachmentAdded event
+func (c *AddPurchaseOrderAttachmentCommand) Execute(ctx context.Context) (map[string]interface{}, error) {
+	c.Logger.Printf("Adding attachment to purchase order - purchase_order_id: %s, correlation_id: %v", c.PurchaseOrderID, c.CorrelationID)
+
+	if c.Attachment == nil {
+		return nil, models.ValidationErrors{{Field: "body", Message: "attachment is required"}}
+	}
+	if err := c.Attachment.Validate(); err != nil {
+		return nil, err
+	}
+	if c.Attachment.ID == "" || c.Attachment.Key == "" {
+		return nil, fmt.Errorf("attachment has no ID or storage key")
+	}
+
+	attachment := *c.Attachment
+	attachment.CreatedAt = c.Clock.Now().UTC()
+
+	annotation := &orderAnnotation{
+		PurchaseOrderID: c.PurchaseOrderID,
+		EventType:       models.PurchaseOrderAttachmentAddedEventType,
+		EventKey:        "attachment",
+		Value:           &attachment,
+		DynamoDB:        c.DynamoDB,
+		Logger:          c.Logger,
+		Clock:           c.Clock,
+		Stats:           c.Stats,
+		CorrelationID:   c.CorrelationID,
+		CausationID:     c.CausationID,
+	}
+	purchaseOrder, err := annotation.apply(ctx, func(purchaseOrder *models.PurchaseOrder) error {
+		if len(purchaseOrder.Attachments) >= models.MaxOrderAttachments {
+			return models.ValidationErrors{{Field: "attachments", Message: fmt.Sprintf("a purchase order holds at most %d attachments", models.MaxOrderAttachments)}}
+		}
+		purchaseOrder.Attachments = append(purchaseOrder.Attachments, attachment)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	c.Logger.Printf("Attachment added to purchase order - purchase_order_id: %s, attachment_id: %s, file_name: %s, uploaded_by: %s",
+		c.PurchaseOrderID, attachment.ID, attachment.FileName, attachment.UploadedBy)
+
+	return map[string]interface{}{
+		"success":           true,
+		"purchase_order_id": c.PurchaseOrderID,
+		"attachment":        &attachment,
+		"version":           purchaseOrder.Version,
+		"correlation_id":    c.CorrelationID,
+	}, nil
+}
+
+// orderAnnotation adds a comment or attachment to a purchase order
+type orderAnnotation struct {
+	PurchaseOrderID string
+	EventType       string
+	// EventKey names Value in the event data
+	EventKey      string
+	Value         interface{}
+	DynamoDB      dynamodbiface.DynamoDBAPI
+	Logger        *log.Logger
+	Clock         clock.Clock
+	Stats         StatsProjection
+	CorrelationID *string
+	CausationID   *string
+}
+
+// apply reads the order, lets add modify it and stores it with the event,
+// reading it again when it is modified concurrently
+func (a *orderAnnotation) apply(ctx context.Context, add func(purchaseOrder *models.PurchaseOrder) error) (*models.PurchaseOrder, error) {
+	for attempt := 1; ; attempt++ {
+		purchaseOrder, err := FindPurchaseOrder(ctx, a.DynamoDB, a.PurchaseOrderID, true)
+		if err != nil {
+			a.Logger.Printf("Failed to get purchase order: %v", err)
+			return nil, fmt.Errorf("failed to get purchase order: %w", err)
+		}
+
+		if err := add(purchaseOrder); err != nil {
+			return nil, err
+		}
+		purchaseOrder.UpdatedAt = a.Clock.Now().UTC()
+
+		err = putPurchaseOrder(ctx, a.DynamoDB, a.Stats, purchaseOrder, a.Clock.Now())
+		var conflict *ConflictError
+		if errors.As(err, &conflict) && attempt < maxAnnotationAttempts {
+			continue
+		}
+		if err != nil {
+			a.Logger.Printf("Failed to store purchase order: %v", err)
+			return nil, fmt.Errorf("failed to store purchase order: %w", err)
+		}
+
+		if err := a.storeEventSourcingEvent(ctx, purchaseOrder); err != nil {
+			a.Logger.Printf("Failed to store event sourcing event: %v", err)
+			return nil, fmt.Errorf("failed to store event sourcing event: %w", err)
+		}
+		return purchaseOrder, nil
+	}
+}
+
+// storeEventSourcingEvent stores the comment or attachment event
+func (a *orderAnnotation) storeEventSourcingEvent(ctx context.Context, purchaseOrder *models.PurchaseOrder) error {
+	eventData := map[string]interface{}{
+		"purchase_order": purchaseOrder,
+		a.EventKey:       a.Value,
+	}
+
+	event := models.NewEventSourcingEvent(
+		purchaseOrder.ID,
+		a.EventType,
+		eventData,
+		a.CorrelationID,
+		a.CausationID,
+		a.Clock.Now(),
+	)
+	event.TenantID = purchaseOrder.TenantID
+
+	item, err := dynamodbattribute.MarshalMap(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event sourcing event: %w", err)
+	}
+
+	_, err = a.DynamoDB.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String("orden-compra-events"),
+		Item:      item,
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to put event sourcing event: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,95 @@
+package cqrs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"medisupply/clock"
+	"orden-compra/internal/memory"
+	"orden-compra/internal/models"
+)
+
+func TestAddPurchaseOrderComment(t *testing.T) {
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	fake := clock.NewFake(statsDay)
+	created := createPurchaseOrder(t, dynamoDB, fake, models.StatusCompleted)
+
+	// Comments are added whatever the order's status
+	fake.Advance(1)
+	command := NewAddPurchaseOrderCommentCommand(created.ID, &models.OrderComment{ID: "chosen", Text: "Delivered to the back door", Author: "jdoe"}, dynamoDB, discardLogger, nil, nil)
+	command.Clock = fake
+	result, err := command.Execute(context.Background())
+	if err != nil {
+		t.Fatalf("add comment: %v", err)
+	}
+	comment := result["comment"].(*models.OrderComment)
+	if comment.ID == "" || comment.ID == "chosen" || !comment.CreatedAt.Equal(fake.Now()) {
+		t.Fatalf("comment %+v, want a new ID created now", comment)
+	}
+
+	stored := getPurchaseOrder(t, dynamoDB, created.ID)
+	if len(stored.Comments) != 1 || stored.Comments[0].Text != "Delivered to the back door" || stored.Comments[0].Author != "jdoe" || !stored.UpdatedAt.Equal(fake.Now()) {
+		t.Fatalf("stored order %+v", stored)
+	}
+	if times := storedEventTimes(t, dynamoDB, models.PurchaseOrderCommentAddedEventType); len(times) != 1 {
+		t.Fatalf("recorded %d comment events, want 1", len(times))
+	}
+
+	var validationErrors models.ValidationErrors
+	for _, comment := range []*models.OrderComment{nil, {Text: " "}} {
+		if _, err := NewAddPurchaseOrderCommentCommand(created.ID, comment, dynamoDB, discardLogger, nil, nil).Execute(context.Background()); !errors.As(err, &validationErrors) {
+			t.Fatalf("comment %+v returned %v, want validation errors", comment, err)
+		}
+	}
+	if _, err := NewAddPurchaseOrderCommentCommand("po-9", &models.OrderComment{Text: "note"}, dynamoDB, discardLogger, nil, nil).Execute(context.Background()); !errors.Is(err, ErrPurchaseOrderNotFound) {
+		t.Fatalf("unknown order returned %v", err)
+	}
+}
+
+func TestAddPurchaseOrderAttachment(t *testing.T) {
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	fake := clock.NewFake(statsDay)
+	created := createPurchaseOrder(t, dynamoDB, fake, models.StatusPending)
+
+	attach := func(id string) (map[string]interface{}, error) {
+		attachment := &models.OrderAttachment{ID: id, Key: "default/" + id, FileName: "invoice.pdf", ContentType: "application/pdf", Size: 1024}
+		command := NewAddPurchaseOrderAttachmentCommand(created.ID, attachment, dynamoDB, discardLogger, nil, nil)
+		command.Clock = fake
+		return command.Execute(context.Background())
+	}
+
+	result, err := attach("attachment-1")
+	if err != nil {
+		t.Fatalf("add attachment: %v", err)
+	}
+	if attachment := result["attachment"].(*models.OrderAttachment); attachment.ID != "attachment-1" || !attachment.CreatedAt.Equal(fake.Now()) {
+		t.Fatalf("attachment %+v", attachment)
+	}
+	if stored := getPurchaseOrder(t, dynamoDB, created.ID); len(stored.Attachments) != 1 || stored.Attachments[0].Key != "default/attachment-1" {
+		t.Fatalf("stored attachments %+v", stored.Attachments)
+	}
+	if times := storedEventTimes(t, dynamoDB, models.PurchaseOrderAttachmentAddedEventType); len(times) != 1 {
+		t.Fatalf("recorded %d attachment events, want 1", len(times))
+	}
+
+	// The caller gives the attachment its ID and key
+	if _, err := attach(""); err == nil {
+		t.Fatal("attachment without an ID or key was stored")
+	}
+
+	// An order holds a bounded number of attachments
+	for i := 2; i <= models.MaxOrderAttachments; i++ {
+		if _, err := attach(fmt.Sprintf("attachment-%d", i)); err != nil {
+			t.Fatalf("attachment %d: %v", i, err)
+		}
+	}
+	var validationErrors models.ValidationErrors
+	if _, err := attach("one-too-many"); !errors.As(err, &validationErrors) || validationErrors[0].Field != "attachments" {
+		t.Fatalf("attachment over the limit returned %v", err)
+	}
+	if stored := getPurchaseOrder(t, dynamoDB, created.ID); len(stored.Attachments) != models.MaxOrderAttachments {
+		t.Fatalf("order holds %d attachments, want %d", len(stored.Attachments), models.MaxOrderAttachments)
+	}
+}
@@ -0,0 +1,88 @@
+package cqrs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"medisupply/clock"
+	"orden-compra/internal/memory"
+	"orden-compra/internal/models"
+)
+
+func cancelPurchaseOrder(dynamoDB *memory.DynamoDB, fake *clock.Fake, id, reason string) (map[string]interface{}, error) {
+	correlationID := "correlation-1"
+	command := NewCancelPurchaseOrderCommand(id, reason, dynamoDB, discardLogger, &correlationID, nil)
+	command.Clock = fake
+	return command.Execute(context.Background())
+}
+
+func TestCancelPurchaseOrder(t *testing.T) {
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	fake := clock.NewFake(statsDay)
+	created := createPurchaseOrder(t, dynamoDB, fake, models.StatusSent)
+
+	fake.Advance(time.Hour)
+	result, err := cancelPurchaseOrder(dynamoDB, fake, created.ID, "supplier out of stock")
+	if err != nil {
+		t.Fatalf("cancel: %v", err)
+	}
+
+	event := result["cancellation_event"].(*models.PurchaseOrderCancelledEvent)
+	if event.PurchaseOrderID != created.ID || event.PreviousStatus != models.StatusSent || event.Reason != "supplier out of stock" || event.Type != models.ReceptionCancelledType {
+		t.Fatalf("cancellation event %+v", event)
+	}
+	if correlationID, _ := event.Metadata["correlation_id"].(*string); correlationID == nil || *correlationID != "correlation-1" {
+		t.Fatalf("cancellation event metadata %v", event.Metadata)
+	}
+
+	cancelled := getPurchaseOrder(t, dynamoDB, created.ID)
+	if cancelled.Status != models.StatusCancelled || cancelled.Metadata["cancellation_reason"] != "supplier out of stock" || cancelled.Metadata["cancelled_at"] != fake.Now().Format(time.RFC3339) {
+		t.Fatalf("cancelled order %+v", cancelled)
+	}
+	if times := storedEventTimes(t, dynamoDB, "PurchaseOrderCancelled"); len(times) != 1 || !times[0].Equal(fake.Now()) {
+		t.Fatalf("cancelled events at %v", times)
+	}
+}
+
+func TestCancelPurchaseOrderRejections(t *testing.T) {
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	fake := clock.NewFake(statsDay)
+
+	completed := createPurchaseOrder(t, dynamoDB, fake, models.StatusCompleted)
+	if _, err := cancelPurchaseOrder(dynamoDB, fake, completed.ID, "too late"); err == nil {
+		t.Fatal("cancelled a completed order")
+	}
+	if status := getPurchaseOrder(t, dynamoDB, completed.ID).Status; status != models.StatusCompleted {
+		t.Fatalf("rejected cancellation left status %s", status)
+	}
+	if times := storedEventTimes(t, dynamoDB, "PurchaseOrderStatusRejected"); len(times) != 1 {
+		t.Fatalf("stored %d rejection events, want 1", len(times))
+	}
+
+	pending := createPurchaseOrder(t, dynamoDB, fake, models.StatusPending)
+	if _, err := cancelPurchaseOrder(dynamoDB, fake, pending.ID, ""); err == nil {
+		t.Fatal("cancelled without a reason")
+	}
+	if _, err := cancelPurchaseOrder(dynamoDB, fake, "missing", "no longer needed"); err == nil {
+		t.Fatal("cancelled an unknown order")
+	}
+}
+
+func TestCancelPurchaseOrderWithoutMetadata(t *testing.T) {
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	fake := clock.NewFake(statsDay)
+
+	// Orders stored without metadata decode with a nil map
+	purchaseOrder := models.NewPurchaseOrder("product-1", "Gloves", "supplier-1", "Acme", "warehouse-1", "HIGH", 10, fake.Now())
+	purchaseOrder.Metadata = nil
+	purchaseOrder.Version = 1
+	putItem(t, dynamoDB, "orden-compra-read", purchaseOrder)
+
+	if _, err := cancelPurchaseOrder(dynamoDB, fake, purchaseOrder.ID, "no longer needed"); err != nil {
+		t.Fatalf("cancel: %v", err)
+	}
+	if cancelled := getPurchaseOrder(t, dynamoDB, purchaseOrder.ID); cancelled.Metadata["cancellation_reason"] != "no longer needed" {
+		t.Fatalf("cancelled order metadata %v", cancelled.Metadata)
+	}
+}
@@ -2,17 +2,25 @@ package cqrs
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
 	"log"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
 
+	"medisupply/clock"
 	"orden-compra/internal/models"
+	"orden-compra/internal/outbox"
 )
 
+// ErrPurchaseOrderNotFound is returned when a purchase order does not exist
+var ErrPurchaseOrderNotFound = errors.New("purchase order not found")
+
 // Command represents a command in the CQRS pattern
 type Command interface {
 	Execute(ctx context.Context) (map[string]interface{}, error)
@@ -21,18 +29,26 @@ type Command interface {
 // ProcessStockLowCommand processes stock low events and creates purchase orders
 type ProcessStockLowCommand struct {
 	Event         *models.StockLowEvent
-	DynamoDB      *dynamodb.DynamoDB
+	Policy        models.PurchaseOrderPolicy
+	DynamoDB      dynamodbiface.DynamoDBAPI
 	Logger        *log.Logger
+	Clock         clock.Clock
+	Stats         StatsProjection
 	CorrelationID *string
 	CausationID   *string
+
+	// reorder records how the order quantity was calculated
+	reorder reorderDecision
 }
 
 // NewProcessStockLowCommand creates a new ProcessStockLowCommand
-func NewProcessStockLowCommand(event *models.StockLowEvent, dynamoDB *dynamodb.DynamoDB, logger *log.Logger, correlationID, causationID *string) *ProcessStockLowCommand {
+func NewProcessStockLowCommand(event *models.StockLowEvent, policy models.PurchaseOrderPolicy, dynamoDB dynamodbiface.DynamoDBAPI, logger *log.Logger, correlationID, causationID *string) *ProcessStockLowCommand {
 	return &ProcessStockLowCommand{
 		Event:         event,
+		Policy:        policy,
 		DynamoDB:      dynamoDB,
 		Logger:        logger,
+		Clock:         clock.System,
 		CorrelationID: correlationID,
 		CausationID:   causationID,
 	}
@@ -43,7 +59,32 @@ func (c *ProcessStockLowCommand) Execute(ctx context.Context) (map[string]interf
 	c.Logger.Printf("Processing stock low event - event_id: %s, product_id: %s, urgency: %s, correlation_id: %v", c.Event.ID, c.Event.ProductID, c.Event.UrgencyLevel, c.CorrelationID)
 
 	// Calculate quantity to order
-	quantity := c.Event.CalculateQuantity()
+	reorder, err := c.calculateQuantity(ctx)
+	if err != nil {
+		c.Logger.Printf("Failed to calculate order quantity: %v", err)
+		return nil, fmt.Errorf("failed to calculate order quantity: %w", err)
+	}
+	c.reorder = reorder
+	quantity := reorder.Quantity
+
+	// Fold repeated StockBajo events into an existing open order
+	if c.Policy.Duplicates != "" && c.Policy.Duplicates != models.DuplicatePolicyCreate {
+		existing, err := c.findOpenPurchaseOrder(ctx)
+		if err != nil {
+			c.Logger.Printf("Failed to look up open purchase orders: %v", err)
+			return nil, fmt.Errorf("failed to look up open purchase orders: %w", err)
+		}
+		if existing != nil {
+			handleDuplicate := c.handleDuplicate
+			if c.Policy.DryRun {
+				handleDuplicate = c.simulateDuplicate
+			}
+			result, handled, err := handleDuplicate(ctx, existing, quantity)
+			if err != nil || handled {
+				return result, err
+			}
+		}
+	}
 
 	// Get supplier information
 	supplierID := c.Event.GetSupplierID()
@@ -58,26 +99,122 @@ func (c *ProcessStockLowCommand) Execute(ctx context.Context) (map[string]interf
 		c.Event.Location,
 		c.Event.UrgencyLevel,
 		quantity,
+		c.Clock.Now(),
 	)
 
+	purchaseOrder.TenantID = c.Event.TenantID
+
 	// Add correlation information
 	purchaseOrder.Metadata["correlation_id"] = c.CorrelationID
 	purchaseOrder.Metadata["causation_id"] = c.CausationID
 	purchaseOrder.Metadata["stock_low_event_id"] = c.Event.ID
+	purchaseOrder.Metadata[models.MetadataReorderStrategy] = reorder.Strategy
+	purchaseOrder.Metadata[models.MetadataReorderSource] = reorder.Source
 
-	// Store purchase order in read model
-	if err := c.storePurchaseOrder(ctx, purchaseOrder); err != nil {
+	// Promise a delivery date from the supplier's lead time
+	supplier, err := c.getSupplier(ctx, supplierID)
+	if err != nil {
+		c.Logger.Printf("Failed to get supplier %s, using default lead time: %v", supplierID, err)
+	}
+	leadTimePolicy := c.Policy.LeadTimes
+	if leadTimePolicy == (models.LeadTimePolicy{}) {
+		leadTimePolicy = models.DefaultLeadTimePolicy()
+	}
+	leadTimeDays, slaSource := leadTimePolicy.LeadTimeDays(supplier, purchaseOrder.ProductID, purchaseOrder.UrgencyLevel)
+	purchaseOrder.ApplySLA(purchaseOrder.CreatedAt, leadTimeDays, slaSource)
+
+	// Order on the terms of the supplier's contract for the product
+	if err := applyContract(purchaseOrder, supplier, purchaseOrder.CreatedAt); err != nil {
+		c.Logger.Printf("Refusing to order - product_id: %s, supplier_id: %s: %v", purchaseOrder.ProductID, supplierID, err)
+		return nil, err
+	}
+	if adjustedFrom, ok := purchaseOrder.Metadata[models.MetadataMOQAdjustedFrom]; ok {
+		c.Logger.Printf("Order quantity rounded up to minimum order quantity - product_id: %s, supplier_id: %s, quantity: %v -> %d", purchaseOrder.ProductID, supplierID, adjustedFrom, purchaseOrder.Quantity)
+	}
+
+	// High-value and critical orders wait for a manual approval
+	requiresApproval, approvalReason := c.Policy.Approval.RequiresApproval(purchaseOrder)
+	if requiresApproval {
+		purchaseOrder.Status = models.StatusPendingApproval
+		purchaseOrder.Metadata[models.MetadataApprovalReason] = approvalReason
+	}
+
+	// In consolidation mode routine orders wait for the next consolidation run
+	awaitingConsolidation := c.Policy.Consolidate && !requiresApproval && purchaseOrder.UrgencyLevel != "critical"
+	if awaitingConsolidation {
+		purchaseOrder.Metadata[models.MetadataAwaitingConsolidation] = true
+	}
+
+	// A dry run stops here, with the order as it would have been created
+	if c.Policy.DryRun {
+		return c.simulatedOrder(purchaseOrder, requiresApproval, awaitingConsolidation), nil
+	}
+
+	// Tell MovimientoInventario the product is on order, however the order is
+	// released; only orders released at once go to Proveedor now
+	orderPlacedEvent := c.newOrderPlacedEvent(ctx, purchaseOrder)
+	var receptionEvent *models.RecepcionProveedorEvent
+	if !requiresApproval && !awaitingConsolidation {
+		receptionEvent = newReceptionEvent(purchaseOrder, c.CorrelationID, c.CausationID, c.Clock.Now())
+		receptionEvent.Metadata["stock_low_event_id"] = c.Event.ID
+	}
+
+	// Store purchase order in read model, with the events in the outbox
+	messages, err := c.storePurchaseOrder(ctx, purchaseOrder, receptionEvent, orderPlacedEvent)
+	if err != nil {
 		c.Logger.Printf("Failed to store purchase order: %v", err)
 		return nil, fmt.Errorf("failed to store purchase order: %w", err)
 	}
 
 	// Store event sourcing event
-	if err := c.storeEventSourcingEvent(ctx, purchaseOrder); err != nil {
+	if err := c.storeEventSourcingEvent(ctx, purchaseOrder, "PurchaseOrderCreated"); err != nil {
 		c.Logger.Printf("Failed to store event sourcing event: %v", err)
 		return nil, fmt.Errorf("failed to store event sourcing event: %w", err)
 	}
 
-	// Create reception event
+	if requiresApproval {
+		c.Logger.Printf("Purchase order awaiting approval - purchase_order_id: %s, product_id: %s, quantity: %d, reason: %s", purchaseOrder.ID, purchaseOrder.ProductID, purchaseOrder.Quantity, approvalReason)
+
+		return map[string]interface{}{
+			"success":            true,
+			"purchase_order_id":  purchaseOrder.ID,
+			"purchase_order":     purchaseOrder,
+			"requires_approval":  true,
+			"order_placed_event": orderPlacedEvent,
+			"outbox_messages":    messages,
+			"correlation_id":     c.CorrelationID,
+		}, nil
+	}
+
+	if awaitingConsolidation {
+		c.Logger.Printf("Purchase order awaiting consolidation - purchase_order_id: %s, product_id: %s, supplier_id: %s", purchaseOrder.ID, purchaseOrder.ProductID, purchaseOrder.SupplierID)
+
+		return map[string]interface{}{
+			"success":                true,
+			"purchase_order_id":      purchaseOrder.ID,
+			"purchase_order":         purchaseOrder,
+			"awaiting_consolidation": true,
+			"order_placed_event":     orderPlacedEvent,
+			"outbox_messages":        messages,
+			"correlation_id":         c.CorrelationID,
+		}, nil
+	}
+
+	c.Logger.Printf("Purchase order created successfully - purchase_order_id: %s, product_id: %s, quantity: %d, supplier_id: %s", purchaseOrder.ID, purchaseOrder.ProductID, purchaseOrder.Quantity, purchaseOrder.SupplierID)
+
+	return map[string]interface{}{
+		"success":            true,
+		"purchase_order_id":  purchaseOrder.ID,
+		"purchase_order":     purchaseOrder,
+		"reception_event":    receptionEvent,
+		"order_placed_event": orderPlacedEvent,
+		"outbox_messages":    messages,
+		"correlation_id":     c.CorrelationID,
+	}, nil
+}
+
+// newReceptionEvent creates the RecepcionProveedor event sent to Proveedor for a purchase order
+func newReceptionEvent(purchaseOrder *models.PurchaseOrder, correlationID, causationID *string, now time.Time) *models.RecepcionProveedorEvent {
 	receptionEvent := models.NewRecepcionProveedorEvent(
 		purchaseOrder.ID,
 		purchaseOrder.ProductID,
@@ -87,61 +224,76 @@ func (c *ProcessStockLowCommand) Execute(ctx context.Context) (map[string]interf
 		purchaseOrder.Location,
 		"pending",
 		purchaseOrder.Quantity,
+		now,
 	)
+	receptionEvent.TenantID = purchaseOrder.TenantID
 
 	// Add correlation information
-	receptionEvent.Metadata["correlation_id"] = c.CorrelationID
-	receptionEvent.Metadata["causation_id"] = c.CausationID
+	receptionEvent.Metadata["correlation_id"] = correlationID
+	receptionEvent.Metadata["causation_id"] = causationID
 	receptionEvent.Metadata["purchase_order_id"] = purchaseOrder.ID
-	receptionEvent.Metadata["stock_low_event_id"] = c.Event.ID
-
-	c.Logger.Printf("Purchase order created successfully - purchase_order_id: %s, product_id: %s, quantity: %d, supplier_id: %s", purchaseOrder.ID, purchaseOrder.ProductID, purchaseOrder.Quantity, purchaseOrder.SupplierID)
+	receptionEvent.Metadata["urgency_level"] = purchaseOrder.UrgencyLevel
 
-	return map[string]interface{}{
-		"success":           true,
-		"purchase_order_id": purchaseOrder.ID,
-		"reception_event":   receptionEvent,
-		"correlation_id":    c.CorrelationID,
-	}, nil
+	return receptionEvent
 }
 
-// storePurchaseOrder stores the purchase order in the read model
-func (c *ProcessStockLowCommand) storePurchaseOrder(ctx context.Context, purchaseOrder *models.PurchaseOrder) error {
-	item, err := dynamodbattribute.MarshalMap(purchaseOrder)
-	if err != nil {
-		return fmt.Errorf("failed to marshal purchase order: %w", err)
+// storePurchaseOrder stores the purchase order in the read model and, in the
+// same transaction, the outbox messages publishing the events that are not
+// nil. It returns the messages for the caller to deliver.
+func (c *ProcessStockLowCommand) storePurchaseOrder(ctx context.Context, purchaseOrder *models.PurchaseOrder, receptionEvent *models.RecepcionProveedorEvent, orderPlacedEvent *models.OrderPlacedForProductEvent) ([]*outbox.Message, error) {
+	now := c.Clock.Now()
+	var messages []*outbox.Message
+	if receptionEvent != nil {
+		message, err := outbox.NewMessage(string(models.PurchaseOrderEventType), receptionEvent.ID, receptionEvent.TenantID, receptionEvent, now)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, message)
 	}
-
-	_, err = c.DynamoDB.PutItemWithContext(ctx, &dynamodb.PutItemInput{
-		TableName: aws.String("orden-compra-read"),
-		Item:      item,
-	})
-
-	if err != nil {
-		return fmt.Errorf("failed to put item: %w", err)
+	if orderPlacedEvent != nil {
+		message, err := outbox.NewMessage(string(models.OrderPlacedForProductEventType), orderPlacedEvent.ID, orderPlacedEvent.TenantID, orderPlacedEvent, now)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, message)
 	}
 
-	return nil
+	items := make([]*dynamodb.TransactWriteItem, 0, len(messages))
+	for _, message := range messages {
+		item, err := message.Put()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	if err := putPurchaseOrder(ctx, c.DynamoDB, c.Stats, purchaseOrder, now, items...); err != nil {
+		return nil, err
+	}
+	return messages, nil
 }
 
 // storeEventSourcingEvent stores the event sourcing event
-func (c *ProcessStockLowCommand) storeEventSourcingEvent(ctx context.Context, purchaseOrder *models.PurchaseOrder) error {
+func (c *ProcessStockLowCommand) storeEventSourcingEvent(ctx context.Context, purchaseOrder *models.PurchaseOrder, eventType string) error {
 	eventData := map[string]interface{}{
 		"purchase_order": purchaseOrder,
 		"stock_low_event": map[string]interface{}{
-			"id":            c.Event.ID,
-			"product_id":    c.Event.ProductID,
-			"urgency_level": c.Event.UrgencyLevel,
+			"id":                  c.Event.ID,
+			"product_id":          c.Event.ProductID,
+			"urgency_level":       c.Event.UrgencyLevel,
+			"calculated_quantity": c.reorder.Quantity,
+			"reorder_strategy":    c.reorder.Strategy,
 		},
 	}
 
 	event := models.NewEventSourcingEvent(
 		purchaseOrder.ID,
-		"PurchaseOrderCreated",
+		eventType,
 		eventData,
 		c.CorrelationID,
 		c.CausationID,
+		c.Clock.Now(),
 	)
+	event.TenantID = purchaseOrder.TenantID
 
 	item, err := dynamodbattribute.MarshalMap(event)
 	if err != nil {
@@ -163,18 +315,21 @@ func (c *ProcessStockLowCommand) storeEventSourcingEvent(ctx context.Context, pu
 // CreatePurchaseOrderCommand creates a new purchase order
 type CreatePurchaseOrderCommand struct {
 	PurchaseOrder *models.PurchaseOrder
-	DynamoDB      *dynamodb.DynamoDB
+	DynamoDB      dynamodbiface.DynamoDBAPI
 	Logger        *log.Logger
+	Clock         clock.Clock
+	Stats         StatsProjection
 	CorrelationID *string
 	CausationID   *string
 }
 
 // NewCreatePurchaseOrderCommand creates a new CreatePurchaseOrderCommand
-func NewCreatePurchaseOrderCommand(purchaseOrder *models.PurchaseOrder, dynamoDB *dynamodb.DynamoDB, logger *log.Logger, correlationID, causationID *string) *CreatePurchaseOrderCommand {
+func NewCreatePurchaseOrderCommand(purchaseOrder *models.PurchaseOrder, dynamoDB dynamodbiface.DynamoDBAPI, logger *log.Logger, correlationID, causationID *string) *CreatePurchaseOrderCommand {
 	return &CreatePurchaseOrderCommand{
 		PurchaseOrder: purchaseOrder,
 		DynamoDB:      dynamoDB,
 		Logger:        logger,
+		Clock:         clock.System,
 		CorrelationID: correlationID,
 		CausationID:   causationID,
 	}
@@ -207,21 +362,7 @@ func (c *CreatePurchaseOrderCommand) Execute(ctx context.Context) (map[string]in
 
 // storePurchaseOrder stores the purchase order in the read model
 func (c *CreatePurchaseOrderCommand) storePurchaseOrder(ctx context.Context, purchaseOrder *models.PurchaseOrder) error {
-	item, err := dynamodbattribute.MarshalMap(purchaseOrder)
-	if err != nil {
-		return fmt.Errorf("failed to marshal purchase order: %w", err)
-	}
-
-	_, err = c.DynamoDB.PutItemWithContext(ctx, &dynamodb.PutItemInput{
-		TableName: aws.String("orden-compra-read"),
-		Item:      item,
-	})
-
-	if err != nil {
-		return fmt.Errorf("failed to put item: %w", err)
-	}
-
-	return nil
+	return putPurchaseOrder(ctx, c.DynamoDB, c.Stats, purchaseOrder, c.Clock.Now())
 }
 
 // storeEventSourcingEvent stores the event sourcing event
@@ -236,7 +377,9 @@ func (c *CreatePurchaseOrderCommand) storeEventSourcingEvent(ctx context.Context
 		eventData,
 		c.CorrelationID,
 		c.CausationID,
+		c.Clock.Now(),
 	)
+	event.TenantID = purchaseOrder.TenantID
 
 	item, err := dynamodbattribute.MarshalMap(event)
 	if err != nil {
@@ -259,19 +402,22 @@ func (c *CreatePurchaseOrderCommand) storeEventSourcingEvent(ctx context.Context
 type UpdatePurchaseOrderStatusCommand struct {
 	PurchaseOrderID string
 	Status          string
-	DynamoDB        *dynamodb.DynamoDB
+	DynamoDB        dynamodbiface.DynamoDBAPI
 	Logger          *log.Logger
+	Clock           clock.Clock
+	Stats           StatsProjection
 	CorrelationID   *string
 	CausationID     *string
 }
 
 // NewUpdatePurchaseOrderStatusCommand creates a new UpdatePurchaseOrderStatusCommand
-func NewUpdatePurchaseOrderStatusCommand(purchaseOrderID, status string, dynamoDB *dynamodb.DynamoDB, logger *log.Logger, correlationID, causationID *string) *UpdatePurchaseOrderStatusCommand {
+func NewUpdatePurchaseOrderStatusCommand(purchaseOrderID, status string, dynamoDB dynamodbiface.DynamoDBAPI, logger *log.Logger, correlationID, causationID *string) *UpdatePurchaseOrderStatusCommand {
 	return &UpdatePurchaseOrderStatusCommand{
 		PurchaseOrderID: purchaseOrderID,
 		Status:          status,
 		DynamoDB:        dynamoDB,
 		Logger:          logger,
+		Clock:           clock.System,
 		CorrelationID:   correlationID,
 		CausationID:     causationID,
 	}
@@ -288,8 +434,15 @@ func (c *UpdatePurchaseOrderStatusCommand) Execute(ctx context.Context) (map[str
 		return nil, fmt.Errorf("failed to get purchase order: %w", err)
 	}
 
-	// Update status
-	purchaseOrder.UpdateStatus(c.Status)
+	// Update status, recording a rejection event for illegal transitions
+	previousStatus := purchaseOrder.Status
+	if err := purchaseOrder.UpdateStatus(c.Status, c.Clock.Now()); err != nil {
+		c.Logger.Printf("Purchase order status change rejected - purchase_order_id: %s, from: %s, to: %s, error: %v", c.PurchaseOrderID, previousStatus, c.Status, err)
+		if storeErr := c.storeRejectedEvent(ctx, purchaseOrder, err); storeErr != nil {
+			c.Logger.Printf("Failed to store status rejected event: %v", storeErr)
+		}
+		return nil, fmt.Errorf("failed to update purchase order status: %w", err)
+	}
 
 	// Store updated purchase order
 	if err := c.storePurchaseOrder(ctx, purchaseOrder); err != nil {
@@ -298,7 +451,7 @@ func (c *UpdatePurchaseOrderStatusCommand) Execute(ctx context.Context) (map[str
 	}
 
 	// Store event sourcing event
-	if err := c.storeEventSourcingEvent(ctx, purchaseOrder); err != nil {
+	if err := c.storeEventSourcingEvent(ctx, purchaseOrder, previousStatus); err != nil {
 		c.Logger.Printf("Failed to store event sourcing event: %v", err)
 		return nil, fmt.Errorf("failed to store event sourcing event: %w", err)
 	}
@@ -329,7 +482,7 @@ func (c *UpdatePurchaseOrderStatusCommand) getPurchaseOrder(ctx context.Context)
 	}
 
 	if result.Item == nil {
-		return nil, fmt.Errorf("purchase order not found")
+		return nil, ErrPurchaseOrderNotFound
 	}
 
 	var purchaseOrder models.PurchaseOrder
@@ -343,40 +496,237 @@ func (c *UpdatePurchaseOrderStatusCommand) getPurchaseOrder(ctx context.Context)
 
 // storePurchaseOrder stores the purchase order in the read model
 func (c *UpdatePurchaseOrderStatusCommand) storePurchaseOrder(ctx context.Context, purchaseOrder *models.PurchaseOrder) error {
-	item, err := dynamodbattribute.MarshalMap(purchaseOrder)
+	return putPurchaseOrder(ctx, c.DynamoDB, c.Stats, purchaseOrder, c.Clock.Now())
+}
+
+// storeEventSourcingEvent stores the event sourcing event
+func (c *UpdatePurchaseOrderStatusCommand) storeEventSourcingEvent(ctx context.Context, purchaseOrder *models.PurchaseOrder, previousStatus string) error {
+	eventData := map[string]interface{}{
+		"purchase_order": purchaseOrder,
+		"status_change": map[string]interface{}{
+			"old_status": previousStatus,
+			"new_status": c.Status,
+		},
+	}
+
+	event := models.NewEventSourcingEvent(
+		purchaseOrder.ID,
+		"PurchaseOrderStatusUpdated",
+		eventData,
+		c.CorrelationID,
+		c.CausationID,
+		c.Clock.Now(),
+	)
+	event.TenantID = purchaseOrder.TenantID
+
+	item, err := dynamodbattribute.MarshalMap(event)
 	if err != nil {
-		return fmt.Errorf("failed to marshal purchase order: %w", err)
+		return fmt.Errorf("failed to marshal event sourcing event: %w", err)
 	}
 
 	_, err = c.DynamoDB.PutItemWithContext(ctx, &dynamodb.PutItemInput{
-		TableName: aws.String("orden-compra-read"),
+		TableName: aws.String("orden-compra-events"),
 		Item:      item,
 	})
 
 	if err != nil {
-		return fmt.Errorf("failed to put item: %w", err)
+		return fmt.Errorf("failed to put event sourcing event: %w", err)
 	}
 
 	return nil
 }
 
-// storeEventSourcingEvent stores the event sourcing event
-func (c *UpdatePurchaseOrderStatusCommand) storeEventSourcingEvent(ctx context.Context, purchaseOrder *models.PurchaseOrder) error {
+// storeRejectedEvent stores a PurchaseOrderStatusRejected event sourcing event
+func (c *UpdatePurchaseOrderStatusCommand) storeRejectedEvent(ctx context.Context, purchaseOrder *models.PurchaseOrder, reason error) error {
 	eventData := map[string]interface{}{
-		"purchase_order": purchaseOrder,
-		"status_change": map[string]interface{}{
-			"old_status": "unknown", // In a real implementation, we'd track the previous status
-			"new_status": c.Status,
-		},
+		"purchase_order_id": purchaseOrder.ID,
+		"current_status":    purchaseOrder.Status,
+		"requested_status":  c.Status,
+		"allowed_statuses":  models.AllowedTransitions(purchaseOrder.Status),
+		"reason":            reason.Error(),
 	}
 
 	event := models.NewEventSourcingEvent(
 		purchaseOrder.ID,
-		"PurchaseOrderStatusUpdated",
+		"PurchaseOrderStatusRejected",
+		eventData,
+		c.CorrelationID,
+		c.CausationID,
+		c.Clock.Now(),
+	)
+	event.TenantID = purchaseOrder.TenantID
+
+	item, err := dynamodbattribute.MarshalMap(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event sourcing event: %w", err)
+	}
+
+	_, err = c.DynamoDB.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String("orden-compra-events"),
+		Item:      item,
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to put event sourcing event: %w", err)
+	}
+
+	return nil
+}
+
+// RecordDeadLetterCommand persists a rejected incoming message for debugging
+type RecordDeadLetterCommand struct {
+	Record   *models.DeadLetterRecord
+	DynamoDB dynamodbiface.DynamoDBAPI
+	Logger   *log.Logger
+	Clock    clock.Clock
+}
+
+// NewRecordDeadLetterCommand creates a new RecordDeadLetterCommand
+func NewRecordDeadLetterCommand(record *models.DeadLetterRecord, dynamoDB dynamodbiface.DynamoDBAPI, logger *log.Logger) *RecordDeadLetterCommand {
+	return &RecordDeadLetterCommand{
+		Record:   record,
+		DynamoDB: dynamoDB,
+		Logger:   logger,
+		Clock:    clock.System,
+	}
+}
+
+// Execute stores the dead letter record
+func (c *RecordDeadLetterCommand) Execute(ctx context.Context) (map[string]interface{}, error) {
+	c.Logger.Printf("Recording dead letter - dead_letter_id: %s, message_id: %s, reason: %s, errors: %d", c.Record.ID, c.Record.MessageID, c.Record.Reason, len(c.Record.Errors))
+
+	item, err := dynamodbattribute.MarshalMap(c.Record)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal dead letter record: %w", err)
+	}
+
+	_, err = c.DynamoDB.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String("orden-compra-dead-letters"),
+		Item:      item,
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to put dead letter record: %w", err)
+	}
+
+	return map[string]interface{}{
+		"success":        true,
+		"dead_letter_id": c.Record.ID,
+	}, nil
+}
+
+// CancelPurchaseOrderCommand cancels a purchase order
+type CancelPurchaseOrderCommand struct {
+	PurchaseOrderID string
+	Reason          string
+	DynamoDB        dynamodbiface.DynamoDBAPI
+	Logger          *log.Logger
+	Clock           clock.Clock
+	Stats           StatsProjection
+	CorrelationID   *string
+	CausationID     *string
+}
+
+// NewCancelPurchaseOrderCommand creates a new CancelPurchaseOrderCommand
+func NewCancelPurchaseOrderCommand(purchaseOrderID, reason string, dynamoDB dynamodbiface.DynamoDBAPI, logger *log.Logger, correlationID, causationID *string) *CancelPurchaseOrderCommand {
+	return &CancelPurchaseOrderCommand{
+		PurchaseOrderID: purchaseOrderID,
+		Reason:          reason,
+		DynamoDB:        dynamoDB,
+		Logger:          logger,
+		Clock:           clock.System,
+		CorrelationID:   correlationID,
+		CausationID:     causationID,
+	}
+}
+
+// Execute cancels the purchase order and returns the compensating event for Proveedor
+func (c *CancelPurchaseOrderCommand) Execute(ctx context.Context) (map[string]interface{}, error) {
+	c.Logger.Printf("Cancelling purchase order - purchase_order_id: %s, reason: %s, correlation_id: %v", c.PurchaseOrderID, c.Reason, c.CorrelationID)
+
+	if c.Reason == "" {
+		return nil, fmt.Errorf("cancellation reason is required")
+	}
+
+	// Get current purchase order
+	statusCommand := &UpdatePurchaseOrderStatusCommand{
+		PurchaseOrderID: c.PurchaseOrderID,
+		Status:          models.StatusCancelled,
+		DynamoDB:        c.DynamoDB,
+		Logger:          c.Logger,
+		Clock:           c.Clock,
+		Stats:           c.Stats,
+		CorrelationID:   c.CorrelationID,
+		CausationID:     c.CausationID,
+	}
+
+	purchaseOrder, err := statusCommand.getPurchaseOrder(ctx)
+	if err != nil {
+		c.Logger.Printf("Failed to get purchase order: %v", err)
+		return nil, fmt.Errorf("failed to get purchase order: %w", err)
+	}
+
+	// Move to cancelled, recording a rejection event for illegal transitions
+	previousStatus := purchaseOrder.Status
+	if err := purchaseOrder.UpdateStatus(models.StatusCancelled, c.Clock.Now()); err != nil {
+		c.Logger.Printf("Purchase order cancellation rejected - purchase_order_id: %s, status: %s, error: %v", c.PurchaseOrderID, previousStatus, err)
+		if storeErr := statusCommand.storeRejectedEvent(ctx, purchaseOrder, err); storeErr != nil {
+			c.Logger.Printf("Failed to store status rejected event: %v", storeErr)
+		}
+		return nil, fmt.Errorf("failed to cancel purchase order: %w", err)
+	}
+
+	if purchaseOrder.Metadata == nil {
+		purchaseOrder.Metadata = make(map[string]interface{})
+	}
+	purchaseOrder.Metadata["cancellation_reason"] = c.Reason
+	purchaseOrder.Metadata["cancelled_at"] = purchaseOrder.UpdatedAt.Format(time.RFC3339)
+
+	// Store updated purchase order
+	if err := statusCommand.storePurchaseOrder(ctx, purchaseOrder); err != nil {
+		c.Logger.Printf("Failed to store cancelled purchase order: %v", err)
+		return nil, fmt.Errorf("failed to store cancelled purchase order: %w", err)
+	}
+
+	// Store event sourcing event
+	if err := c.storeEventSourcingEvent(ctx, purchaseOrder, previousStatus); err != nil {
+		c.Logger.Printf("Failed to store event sourcing event: %v", err)
+		return nil, fmt.Errorf("failed to store event sourcing event: %w", err)
+	}
+
+	// Create cancellation event for Proveedor
+	cancellationEvent := models.NewPurchaseOrderCancelledEvent(purchaseOrder, previousStatus, c.Reason, c.Clock.Now())
+	cancellationEvent.Metadata["correlation_id"] = c.CorrelationID
+	cancellationEvent.Metadata["causation_id"] = c.CausationID
+
+	c.Logger.Printf("Purchase order cancelled successfully - purchase_order_id: %s, previous_status: %s", c.PurchaseOrderID, previousStatus)
+
+	return map[string]interface{}{
+		"success":            true,
+		"purchase_order_id":  c.PurchaseOrderID,
+		"status":             models.StatusCancelled,
+		"cancellation_event": cancellationEvent,
+		"correlation_id":     c.CorrelationID,
+	}, nil
+}
+
+// storeEventSourcingEvent stores the PurchaseOrderCancelled event sourcing event
+func (c *CancelPurchaseOrderCommand) storeEventSourcingEvent(ctx context.Context, purchaseOrder *models.PurchaseOrder, previousStatus string) error {
+	eventData := map[string]interface{}{
+		"purchase_order":  purchaseOrder,
+		"previous_status": previousStatus,
+		"reason":          c.Reason,
+	}
+
+	event := models.NewEventSourcingEvent(
+		purchaseOrder.ID,
+		"PurchaseOrderCancelled",
 		eventData,
 		c.CorrelationID,
 		c.CausationID,
+		c.Clock.Now(),
 	)
+	event.TenantID = purchaseOrder.TenantID
 
 	item, err := dynamodbattribute.MarshalMap(event)
 	if err != nil {
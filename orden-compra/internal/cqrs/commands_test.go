@@ -0,0 +1,231 @@
+package cqrs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/sirupsen/logrus"
+
+	"medisupply/clock"
+	"orden-compra/internal/memory"
+	"orden-compra/internal/models"
+)
+
+var (
+	discardLogger = log.New(io.Discard, "", 0)
+	discardLogrus = &logrus.Logger{Out: io.Discard, Formatter: new(logrus.TextFormatter), Level: logrus.PanicLevel}
+)
+
+// createPurchaseOrder stores a new order for product-1 at the fake's time
+func createPurchaseOrder(t *testing.T, dynamoDB *memory.DynamoDB, fake *clock.Fake, status string) *models.PurchaseOrder {
+	t.Helper()
+	purchaseOrder := models.NewPurchaseOrder("product-1", "Gloves", "supplier-1", "Acme", "warehouse-1", "HIGH", 10, fake.Now())
+	purchaseOrder.Status = status
+
+	command := NewCreatePurchaseOrderCommand(purchaseOrder, dynamoDB, discardLogger, nil, nil)
+	command.Clock = fake
+	if _, err := command.Execute(context.Background()); err != nil {
+		t.Fatalf("create purchase order: %v", err)
+	}
+	return purchaseOrder
+}
+
+// getPurchaseOrder reads an order back through GetPurchaseOrderQuery
+func getPurchaseOrder(t *testing.T, dynamoDB *memory.DynamoDB, id string) models.PurchaseOrder {
+	t.Helper()
+	result, err := NewGetPurchaseOrderQuery(id, dynamoDB, discardLogrus).Execute(context.Background())
+	if err != nil {
+		t.Fatalf("get purchase order: %v", err)
+	}
+	purchaseOrder, ok := result["purchase_order"].(models.PurchaseOrder)
+	if !ok {
+		t.Fatalf("purchase order %s not found: %v", id, result)
+	}
+	return purchaseOrder
+}
+
+// putItem stores v in a table as it would be marshalled by the commands
+func putItem(t *testing.T, dynamoDB *memory.DynamoDB, tableName string, v interface{}) {
+	t.Helper()
+	item, err := dynamodbattribute.MarshalMap(v)
+	if err != nil {
+		t.Fatalf("marshal %T: %v", v, err)
+	}
+	if _, err := dynamoDB.PutItemWithContext(context.Background(), &dynamodb.PutItemInput{TableName: aws.String(tableName), Item: item}); err != nil {
+		t.Fatalf("put %T: %v", v, err)
+	}
+}
+
+// storedEventTimes returns the timestamps of the stored events of a type
+func storedEventTimes(t *testing.T, dynamoDB *memory.DynamoDB, eventType string) []time.Time {
+	t.Helper()
+	var times []time.Time
+	for _, item := range dynamoDB.Items("orden-compra-events") {
+		if aws.StringValue(item["event_type"].S) != eventType {
+			continue
+		}
+		timestamp, err := time.Parse(time.RFC3339Nano, aws.StringValue(item["timestamp"].S))
+		if err != nil {
+			t.Fatalf("timestamp of %s event: %v", eventType, err)
+		}
+		times = append(times, timestamp)
+	}
+	return times
+}
+
+func TestCreatePurchaseOrderUsesTheCommandsClock(t *testing.T) {
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	fake := clock.NewFake(statsDay)
+
+	created := createPurchaseOrder(t, dynamoDB, fake, models.StatusPending)
+
+	stored := getPurchaseOrder(t, dynamoDB, created.ID)
+	if stored.Status != models.StatusPending || stored.Version != 1 || !stored.CreatedAt.Equal(statsDay) {
+		t.Fatalf("stored order %+v, want pending at version 1 created at %s", stored, statsDay)
+	}
+	times := storedEventTimes(t, dynamoDB, "PurchaseOrderCreated")
+	if len(times) != 1 || !times[0].Equal(statsDay) {
+		t.Fatalf("PurchaseOrderCreated events at %v, want one at %s", times, statsDay)
+	}
+	if got := statsCounter(t, dynamoDB, statsDay.Format(models.StatsDayLayout), models.StatsTotal); got != 1 {
+		t.Fatalf("stats bucket of %s counts %d orders, want 1", statsDay.Format(models.StatsDayLayout), got)
+	}
+}
+
+func TestUpdatePurchaseOrderStatus(t *testing.T) {
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	fake := clock.NewFake(statsDay)
+	created := createPurchaseOrder(t, dynamoDB, fake, models.StatusPending)
+
+	fake.Advance(2 * time.Hour)
+	update := NewUpdatePurchaseOrderStatusCommand(created.ID, models.StatusSent, dynamoDB, discardLogger, nil, nil)
+	update.Clock = fake
+	if _, err := update.Execute(context.Background()); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	stored := getPurchaseOrder(t, dynamoDB, created.ID)
+	if stored.Status != models.StatusSent || !stored.UpdatedAt.Equal(fake.Now()) || stored.Version != 2 {
+		t.Fatalf("stored order %+v, want sent at version 2 updated at %s", stored, fake.Now())
+	}
+
+	// An illegal transition is refused and recorded
+	update = NewUpdatePurchaseOrderStatusCommand(created.ID, models.StatusPending, dynamoDB, discardLogger, nil, nil)
+	update.Clock = fake
+	var transition *models.StatusTransitionError
+	if _, err := update.Execute(context.Background()); !errors.As(err, &transition) {
+		t.Fatalf("sent -> pending returned %v, want a StatusTransitionError", err)
+	}
+	if stored := getPurchaseOrder(t, dynamoDB, created.ID); stored.Status != models.StatusSent {
+		t.Fatalf("status %s after a refused transition, want sent", stored.Status)
+	}
+	if times := storedEventTimes(t, dynamoDB, "PurchaseOrderStatusRejected"); len(times) != 1 || !times[0].Equal(fake.Now()) {
+		t.Fatalf("PurchaseOrderStatusRejected events at %v, want one at %s", times, fake.Now())
+	}
+
+	update = NewUpdatePurchaseOrderStatusCommand("po-missing", models.StatusSent, dynamoDB, discardLogger, nil, nil)
+	update.Clock = fake
+	if _, err := update.Execute(context.Background()); !errors.Is(err, ErrPurchaseOrderNotFound) {
+		t.Fatalf("update of a missing order returned %v, want ErrPurchaseOrderNotFound", err)
+	}
+}
+
+func TestApprovePurchaseOrderReleasesIt(t *testing.T) {
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	fake := clock.NewFake(statsDay)
+	created := createPurchaseOrder(t, dynamoDB, fake, models.StatusPendingApproval)
+
+	fake.Advance(30 * time.Minute)
+	approve := NewApprovePurchaseOrderCommand(created.ID, "alice", "ok", dynamoDB, discardLogger, nil, nil)
+	approve.Clock = fake
+	result, err := approve.Execute(context.Background())
+	if err != nil {
+		t.Fatalf("approve: %v", err)
+	}
+
+	stored := getPurchaseOrder(t, dynamoDB, created.ID)
+	if stored.Status != models.StatusApproved || stored.Metadata[models.MetadataApprovedBy] != "alice" {
+		t.Fatalf("stored order %+v, want approved by alice", stored)
+	}
+	if approvedAt := stored.Metadata[models.MetadataApprovedAt]; approvedAt != fake.Now().Format(time.RFC3339) {
+		t.Fatalf("approved at %v, want %s", approvedAt, fake.Now().Format(time.RFC3339))
+	}
+	receptionEvent := result["reception_event"].(*models.RecepcionProveedorEvent)
+	if receptionEvent.PurchaseOrderID != created.ID || !receptionEvent.Timestamp.Equal(fake.Now()) {
+		t.Fatalf("reception event %+v, want one for %s at %s", receptionEvent, created.ID, fake.Now())
+	}
+
+	// A decided order cannot be approved again
+	approve = NewApprovePurchaseOrderCommand(created.ID, "bob", "", dynamoDB, discardLogger, nil, nil)
+	approve.Clock = fake
+	if _, err := approve.Execute(context.Background()); err == nil {
+		t.Fatalf("second approval succeeded")
+	}
+}
+
+func TestDetectOverduePurchaseOrdersFollowsTheClock(t *testing.T) {
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	fake := clock.NewFake(statsDay)
+	created := createPurchaseOrder(t, dynamoDB, fake, models.StatusSent)
+
+	detect := func() int {
+		t.Helper()
+		command := NewDetectOverduePurchaseOrdersCommand(dynamoDB, discardLogger, nil, nil)
+		command.Clock = fake
+		result, err := command.Execute(context.Background())
+		if err != nil {
+			t.Fatalf("detect overdue orders: %v", err)
+		}
+		return result["count"].(int)
+	}
+	overdue := func() int {
+		t.Helper()
+		query := NewGetOverduePurchaseOrdersQuery(dynamoDB, discardLogrus)
+		query.Clock = fake
+		result, err := query.Execute(context.Background())
+		if err != nil {
+			t.Fatalf("get overdue orders: %v", err)
+		}
+		return result["count"].(int)
+	}
+
+	if count := detect(); count != 0 {
+		t.Fatalf("detected %d overdue orders before the expected date", count)
+	}
+	if count := overdue(); count != 0 {
+		t.Fatalf("query returned %d overdue orders before the expected date", count)
+	}
+
+	fake.Set(created.ExpectedDate.Add(time.Minute))
+	if count := detect(); count != 1 {
+		t.Fatalf("detected %d overdue orders past the expected date, want 1", count)
+	}
+	if count := detect(); count != 0 {
+		t.Fatalf("detected an overdue order %d more times", count)
+	}
+	if count := overdue(); count != 1 {
+		t.Fatalf("query returned %d overdue orders, want 1", count)
+	}
+
+	stored := getPurchaseOrder(t, dynamoDB, created.ID)
+	if detectedAt := stored.Metadata[models.MetadataOverdueDetectedAt]; detectedAt != fake.Now().Format(time.RFC3339) {
+		t.Fatalf("overdue detected at %v, want %s", detectedAt, fake.Now().Format(time.RFC3339))
+	}
+	if times := storedEventTimes(t, dynamoDB, models.PurchaseOrderOverdueEventType); len(times) != 1 || !times[0].Equal(fake.Now()) {
+		t.Fatalf("PurchaseOrderOverdue events at %v, want one at %s", times, fake.Now())
+	}
+}
+
+func TestReceptionEventCarriesTheOrdersUrgency(t *testing.T) {
+	purchaseOrder := models.NewPurchaseOrder("product-1", "Gloves", "supplier-1", "Acme", "warehouse-1", "critical", 10, statsDay)
+	event := newReceptionEvent(purchaseOrder, nil, nil, statsDay)
+	if event.Metadata["urgency_level"] != "critical" || event.Metadata["purchase_order_id"] != purchaseOrder.ID {
+		t.Fatalf("reception metadata %v, want the order's urgency", event.Metadata)
+	}
+}
@@ -0,0 +1,28 @@
+package cqrs
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrConflict is returned when a purchase order changed between being read
+// and written back
+var ErrConflict = errors.New("purchase order was modified concurrently")
+
+// ConflictError describes a read-model write that lost to a concurrent one.
+// Callers should read the order again and reapply their change.
+type ConflictError struct {
+	PurchaseOrderID string
+	// Version is the version the writer read
+	Version int
+}
+
+// Error implements the error interface
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("purchase order %s was modified concurrently (read version %d)", e.PurchaseOrderID, e.Version)
+}
+
+// Unwrap allows errors.Is checks against ErrConflict
+func (e *ConflictError) Unwrap() error {
+	return ErrConflict
+}
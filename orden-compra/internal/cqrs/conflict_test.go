@@ -0,0 +1,67 @@
+package cqrs
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+
+	"medisupply/clock"
+	"orden-compra/internal/memory"
+	"orden-compra/internal/models"
+)
+
+func TestStaleWriteLosesToACommand(t *testing.T) {
+	ctx := context.Background()
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	fake := clock.NewFake(statsDay)
+	created := createPurchaseOrder(t, dynamoDB, fake, models.StatusPending)
+
+	// A writer reads the order, then a command updates it first
+	stale := getPurchaseOrder(t, dynamoDB, created.ID)
+	update := NewUpdatePurchaseOrderStatusCommand(created.ID, models.StatusSent, dynamoDB, discardLogger, nil, nil)
+	update.Clock = fake
+	if _, err := update.Execute(ctx); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	stale.Status = models.StatusCancelled
+	err := putPurchaseOrder(ctx, dynamoDB, StatsInCommands, &stale, fake.Now())
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) || conflict.PurchaseOrderID != created.ID || conflict.Version != 1 {
+		t.Fatalf("stale write returned %v, want a conflict at read version 1", err)
+	}
+	if want := "purchase order " + created.ID + " was modified concurrently (read version 1)"; err.Error() != want {
+		t.Fatalf("error %q, want %q", err.Error(), want)
+	}
+	if stored := getPurchaseOrder(t, dynamoDB, created.ID); stored.Status != models.StatusSent || stored.Version != 2 {
+		t.Fatalf("stored order %s at version %d, want the command's sent at version 2", stored.Status, stored.Version)
+	}
+}
+
+func TestUnversionedOrderIsWrittenAtVersionOne(t *testing.T) {
+	ctx := context.Background()
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	purchaseOrder := newStatsOrder(models.StatusPending)
+
+	// An order stored before orders were versioned has no version attribute
+	putItem(t, dynamoDB, "orden-compra-read", purchaseOrder)
+	if _, err := dynamoDB.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+		TableName:                aws.String("orden-compra-read"),
+		Key:                      map[string]*dynamodb.AttributeValue{"id": {S: aws.String(purchaseOrder.ID)}},
+		UpdateExpression:         aws.String("REMOVE #version"),
+		ExpressionAttributeNames: map[string]*string{"#version": aws.String("version")},
+	}); err != nil {
+		t.Fatalf("remove version: %v", err)
+	}
+
+	purchaseOrder.Status = models.StatusApproved
+	if err := putPurchaseOrder(ctx, dynamoDB, StatsInCommands, purchaseOrder, statsDay); err != nil {
+		t.Fatalf("put unversioned order: %v", err)
+	}
+	if stored := getPurchaseOrder(t, dynamoDB, purchaseOrder.ID); stored.Status != models.StatusApproved || stored.Version != 1 {
+		t.Fatalf("stored order %s at version %d, want approved at version 1", stored.Status, stored.Version)
+	}
+}
@@ -0,0 +1,233 @@
+package cqrs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+
+	"medisupply/clock"
+	"orden-compra/internal/models"
+)
+
+// ConsolidatePurchaseOrdersCommand batches the purchase orders held back for
+// consolidation into one consolidated order per supplier
+type ConsolidatePurchaseOrdersCommand struct {
+	Cutoff        time.Time
+	DynamoDB      dynamodbiface.DynamoDBAPI
+	Logger        *log.Logger
+	Clock         clock.Clock
+	Stats         StatsProjection
+	CorrelationID *string
+	CausationID   *string
+}
+
+// NewConsolidatePurchaseOrdersCommand creates a new ConsolidatePurchaseOrdersCommand
+func NewConsolidatePurchaseOrdersCommand(cutoff time.Time, dynamoDB dynamodbiface.DynamoDBAPI, logger *log.Logger, correlationID, causationID *string) *ConsolidatePurchaseOrdersCommand {
+	return &ConsolidatePurchaseOrdersCommand{
+		Cutoff:        cutoff,
+		DynamoDB:      dynamoDB,
+		Logger:        logger,
+		Clock:         clock.System,
+		CorrelationID: correlationID,
+		CausationID:   causationID,
+	}
+}
+
+// Execute consolidates every order awaiting consolidation created before the cutoff
+// and returns the reception events to publish for the consolidated lines
+func (c *ConsolidatePurchaseOrdersCommand) Execute(ctx context.Context) (map[string]interface{}, error) {
+	c.Logger.Printf("Consolidating purchase orders - cutoff: %s", c.Cutoff.Format(time.RFC3339))
+
+	orders, err := c.getAwaitingOrders(ctx)
+	if err != nil {
+		c.Logger.Printf("Failed to get purchase orders awaiting consolidation: %v", err)
+		return nil, fmt.Errorf("failed to get purchase orders awaiting consolidation: %w", err)
+	}
+
+	// Group orders by tenant and supplier; orders of different tenants are never merged
+	bySupplier := make(map[supplierKey][]*models.PurchaseOrder)
+	for _, po := range orders {
+		key := supplierKey{TenantID: po.TenantID, SupplierID: po.SupplierID}
+		bySupplier[key] = append(bySupplier[key], po)
+	}
+
+	keys := make([]supplierKey, 0, len(bySupplier))
+	for key := range bySupplier {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].TenantID != keys[j].TenantID {
+			return keys[i].TenantID < keys[j].TenantID
+		}
+		return keys[i].SupplierID < keys[j].SupplierID
+	})
+
+	var consolidatedOrders []*models.ConsolidatedPurchaseOrder
+	var receptionEvents []*models.RecepcionProveedorEvent
+
+	for _, key := range keys {
+		supplierID := key.SupplierID
+		group := bySupplier[key]
+		sort.Slice(group, func(i, j int) bool { return group[i].CreatedAt.Before(group[j].CreatedAt) })
+
+		consolidated := models.NewConsolidatedPurchaseOrder(
+			supplierID,
+			group[0].SupplierName,
+			group[0].CreatedAt,
+			c.Cutoff,
+			group,
+			c.Clock.Now(),
+		)
+		consolidated.Metadata["correlation_id"] = c.CorrelationID
+		consolidated.Metadata["causation_id"] = c.CausationID
+
+		if err := c.storeConsolidatedOrder(ctx, consolidated); err != nil {
+			c.Logger.Printf("Failed to store consolidated order: %v", err)
+			return nil, fmt.Errorf("failed to store consolidated order: %w", err)
+		}
+
+		if err := c.storeEventSourcingEvent(ctx, consolidated); err != nil {
+			c.Logger.Printf("Failed to store event sourcing event: %v", err)
+			return nil, fmt.Errorf("failed to store event sourcing event: %w", err)
+		}
+
+		for _, po := range group {
+			delete(po.Metadata, models.MetadataAwaitingConsolidation)
+			po.Metadata[models.MetadataConsolidatedOrderID] = consolidated.ID
+			po.UpdatedAt = c.Clock.Now().UTC()
+
+			if err := c.storePurchaseOrder(ctx, po); err != nil {
+				c.Logger.Printf("Failed to store purchase order: %v", err)
+				return nil, fmt.Errorf("failed to store purchase order: %w", err)
+			}
+
+			receptionEvent := newReceptionEvent(po, c.CorrelationID, c.CausationID, c.Clock.Now())
+			receptionEvent.Metadata[models.MetadataConsolidatedOrderID] = consolidated.ID
+			receptionEvents = append(receptionEvents, receptionEvent)
+		}
+
+		c.Logger.Printf("Consolidated purchase order created - consolidated_order_id: %s, supplier_id: %s, lines: %d, total_quantity: %d", consolidated.ID, supplierID, len(consolidated.Lines), consolidated.TotalQuantity())
+		consolidatedOrders = append(consolidatedOrders, consolidated)
+	}
+
+	return map[string]interface{}{
+		"success":             true,
+		"consolidated_orders": consolidatedOrders,
+		"reception_events":    receptionEvents,
+		"count":               len(consolidatedOrders),
+	}, nil
+}
+
+// supplierKey groups the orders consolidated together
+type supplierKey struct {
+	TenantID   string
+	SupplierID string
+}
+
+// getAwaitingOrders scans the read model for pending orders held for consolidation
+func (c *ConsolidatePurchaseOrdersCommand) getAwaitingOrders(ctx context.Context) ([]*models.PurchaseOrder, error) {
+	scanInput := &dynamodb.ScanInput{
+		TableName:        aws.String("orden-compra-read"),
+		FilterExpression: aws.String("#status = :status AND metadata.#awaiting = :awaiting"),
+		ExpressionAttributeNames: map[string]*string{
+			"#status":   aws.String("status"),
+			"#awaiting": aws.String(models.MetadataAwaitingConsolidation),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":status":   {S: aws.String(models.StatusPending)},
+			":awaiting": {BOOL: aws.Bool(true)},
+		},
+	}
+
+	var orders []*models.PurchaseOrder
+	for {
+		result, err := c.DynamoDB.ScanWithContext(ctx, scanInput)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan: %w", err)
+		}
+
+		for _, item := range result.Items {
+			var purchaseOrder models.PurchaseOrder
+			if err := dynamodbattribute.UnmarshalMap(item, &purchaseOrder); err != nil {
+				c.Logger.Printf("Failed to unmarshal purchase order: %v", err)
+				continue
+			}
+			if !purchaseOrder.IsAwaitingConsolidation() || purchaseOrder.CreatedAt.After(c.Cutoff) {
+				continue
+			}
+			orders = append(orders, &purchaseOrder)
+		}
+
+		if len(result.LastEvaluatedKey) == 0 {
+			break
+		}
+		scanInput.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+
+	return orders, nil
+}
+
+// storeConsolidatedOrder stores the consolidated order aggregate
+func (c *ConsolidatePurchaseOrdersCommand) storeConsolidatedOrder(ctx context.Context, consolidated *models.ConsolidatedPurchaseOrder) error {
+	item, err := dynamodbattribute.MarshalMap(consolidated)
+	if err != nil {
+		return fmt.Errorf("failed to marshal consolidated order: %w", err)
+	}
+
+	_, err = c.DynamoDB.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String("orden-compra-consolidated"),
+		Item:      item,
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to put item: %w", err)
+	}
+
+	return nil
+}
+
+// storePurchaseOrder stores the purchase order in the read model
+func (c *ConsolidatePurchaseOrdersCommand) storePurchaseOrder(ctx context.Context, purchaseOrder *models.PurchaseOrder) error {
+	return putPurchaseOrder(ctx, c.DynamoDB, c.Stats, purchaseOrder, c.Clock.Now())
+}
+
+// storeEventSourcingEvent stores the consolidated order created event
+func (c *ConsolidatePurchaseOrdersCommand) storeEventSourcingEvent(ctx context.Context, consolidated *models.ConsolidatedPurchaseOrder) error {
+	eventData := map[string]interface{}{
+		"consolidated_order": consolidated,
+		"total_quantity":     consolidated.TotalQuantity(),
+	}
+
+	event := models.NewEventSourcingEvent(
+		consolidated.ID,
+		models.ConsolidatedPurchaseOrderCreatedType,
+		eventData,
+		c.CorrelationID,
+		c.CausationID,
+		c.Clock.Now(),
+	)
+	event.TenantID = consolidated.TenantID
+
+	item, err := dynamodbattribute.MarshalMap(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event sourcing event: %w", err)
+	}
+
+	_, err = c.DynamoDB.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String("orden-compra-events"),
+		Item:      item,
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to put event sourcing event: %w", err)
+	}
+
+	return nil
+}
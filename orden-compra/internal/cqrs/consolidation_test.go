@@ -0,0 +1,141 @@
+package cqrs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"medisupply/clock"
+	"orden-compra/internal/memory"
+	"orden-compra/internal/models"
+)
+
+// heldOrder stores a pending order for product from supplier, held for the
+// next consolidation run, at the fake's time
+func heldOrder(t *testing.T, dynamoDB *memory.DynamoDB, fake *clock.Fake, productID, supplierID string, quantity int) *models.PurchaseOrder {
+	t.Helper()
+	purchaseOrder := models.NewPurchaseOrder(productID, productID, supplierID, supplierID, "warehouse-1", "low", quantity, fake.Now())
+	purchaseOrder.Metadata[models.MetadataAwaitingConsolidation] = true
+
+	command := NewCreatePurchaseOrderCommand(purchaseOrder, dynamoDB, discardLogger, nil, nil)
+	command.Clock = fake
+	if _, err := command.Execute(context.Background()); err != nil {
+		t.Fatalf("create purchase order: %v", err)
+	}
+	return purchaseOrder
+}
+
+func TestStockLowHoldsRoutineOrdersForConsolidation(t *testing.T) {
+	for _, tc := range []struct {
+		urgency  string
+		released bool
+	}{
+		{"low", false},
+		{"high", false},
+		{"critical", true},
+	} {
+		t.Run(tc.urgency, func(t *testing.T) {
+			dynamoDB := memory.NewDynamoDB(memory.Tables)
+			command := newStockLowCommand(dynamoDB, clock.NewFake(statsDay), models.PurchaseOrderPolicy{Consolidate: true})
+			command.Event.UrgencyLevel = tc.urgency
+			result, err := command.Execute(context.Background())
+			if err != nil {
+				t.Fatalf("execute: %v", err)
+			}
+			if released := result["reception_event"] != nil; released != tc.released {
+				t.Fatalf("released %t, want %t", released, tc.released)
+			}
+			if order := getPurchaseOrder(t, dynamoDB, result["purchase_order_id"].(string)); order.IsAwaitingConsolidation() == tc.released {
+				t.Fatalf("order awaiting consolidation: %t", order.IsAwaitingConsolidation())
+			}
+		})
+	}
+}
+
+func TestConsolidateGroupsHeldOrdersBySupplier(t *testing.T) {
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	fake := clock.NewFake(statsDay)
+
+	gloves := heldOrder(t, dynamoDB, fake, "product-1", "supplier-1", 10)
+	fake.Advance(time.Minute)
+	syringes := heldOrder(t, dynamoDB, fake, "product-3", "supplier-2", 30)
+	masks := heldOrder(t, dynamoDB, fake, "product-2", "supplier-1", 20)
+
+	// Orders created after the cutoff wait for the next run
+	cutoff := fake.Now()
+	fake.Advance(time.Minute)
+	late := heldOrder(t, dynamoDB, fake, "product-5", "supplier-1", 5)
+
+	fake.Advance(time.Hour)
+	consolidate := NewConsolidatePurchaseOrdersCommand(cutoff, dynamoDB, discardLogger, nil, nil)
+	consolidate.Clock = fake
+	result, err := consolidate.Execute(context.Background())
+	if err != nil {
+		t.Fatalf("consolidate: %v", err)
+	}
+
+	consolidated := result["consolidated_orders"].([]*models.ConsolidatedPurchaseOrder)
+	if len(consolidated) != 2 || consolidated[0].SupplierID != "supplier-1" || consolidated[1].SupplierID != "supplier-2" {
+		t.Fatalf("consolidated orders %+v, want one per supplier", consolidated)
+	}
+	first := consolidated[0]
+	if len(first.Lines) != 2 || first.Lines[0].PurchaseOrderID != gloves.ID || first.Lines[1].PurchaseOrderID != masks.ID {
+		t.Fatalf("supplier-1 lines %+v, want the gloves and then the masks", first.Lines)
+	}
+	if first.TotalQuantity() != 30 || !first.WindowStart.Equal(gloves.CreatedAt) || !first.WindowEnd.Equal(cutoff) || !first.CreatedAt.Equal(fake.Now()) {
+		t.Fatalf("consolidated order %+v", first)
+	}
+	if consolidated[1].Lines[0].PurchaseOrderID != syringes.ID {
+		t.Fatalf("supplier-2 lines %+v, want the syringes", consolidated[1].Lines)
+	}
+	if stored := dynamoDB.Items("orden-compra-consolidated"); len(stored) != 2 {
+		t.Fatalf("stored %d consolidated orders, want 2", len(stored))
+	}
+	if times := storedEventTimes(t, dynamoDB, models.ConsolidatedPurchaseOrderCreatedType); len(times) != 2 {
+		t.Fatalf("stored %d ConsolidatedPurchaseOrderCreated events, want 2", len(times))
+	}
+
+	// Each held order is released with a reception event naming its batch
+	receptions := result["reception_events"].([]*models.RecepcionProveedorEvent)
+	if len(receptions) != 3 || receptions[0].PurchaseOrderID != gloves.ID || receptions[0].Metadata[models.MetadataConsolidatedOrderID] != first.ID {
+		t.Fatalf("reception events %+v, want one per held order", receptions)
+	}
+	order := getPurchaseOrder(t, dynamoDB, gloves.ID)
+	if order.IsAwaitingConsolidation() || order.Metadata[models.MetadataConsolidatedOrderID] != first.ID {
+		t.Fatalf("consolidated order metadata %v", order.Metadata)
+	}
+	if order := getPurchaseOrder(t, dynamoDB, late.ID); !order.IsAwaitingConsolidation() {
+		t.Fatal("an order created after the cutoff was consolidated")
+	}
+
+	// A second run has nothing left before the cutoff
+	result, err = consolidate.Execute(context.Background())
+	if err != nil || result["count"] != 0 {
+		t.Fatalf("second run returned %v, %v; want nothing consolidated", result, err)
+	}
+}
+
+func TestConsolidateKeepsTenantsApart(t *testing.T) {
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	fake := clock.NewFake(statsDay)
+
+	for i, tenantID := range []string{"tenant-1", "tenant-2"} {
+		command := newStockLowCommand(dynamoDB, fake, models.PurchaseOrderPolicy{Consolidate: true})
+		command.Event.ID = "stock-low-" + tenantID
+		command.Event.TenantID = tenantID
+		if _, err := command.Execute(context.Background()); err != nil {
+			t.Fatalf("stock low %d: %v", i, err)
+		}
+	}
+
+	consolidate := NewConsolidatePurchaseOrdersCommand(fake.Now(), dynamoDB, discardLogger, nil, nil)
+	consolidate.Clock = fake
+	result, err := consolidate.Execute(context.Background())
+	if err != nil {
+		t.Fatalf("consolidate: %v", err)
+	}
+	consolidated := result["consolidated_orders"].([]*models.ConsolidatedPurchaseOrder)
+	if len(consolidated) != 2 || consolidated[0].TenantID != "tenant-1" || consolidated[1].TenantID != "tenant-2" {
+		t.Fatalf("consolidated orders %+v, want one per tenant", consolidated)
+	}
+}
@@ -0,0 +1,69 @@
+package cqrs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"orden-compra/internal/models"
+)
+
+// ErrContractExpired is returned when the supplier's contract for a product
+// has ended, so the product may not be ordered from it
+var ErrContractExpired = errors.New("supplier contract expired")
+
+// applyContract applies the supplier's contract for the order's product:
+// the quantity is rounded up to the minimum order quantity and the order is
+// priced. Products without a contract, or whose contract has not started,
+// are ordered as they are. The supplier may be nil when it is not listed.
+func applyContract(purchaseOrder *models.PurchaseOrder, supplier *models.Supplier, at time.Time) error {
+	if supplier == nil {
+		return nil
+	}
+	product := supplier.Product(purchaseOrder.ProductID)
+	if product == nil || product.Contract == nil {
+		return nil
+	}
+
+	contract := product.Contract
+	if contract.Expired(at) {
+		return fmt.Errorf("%w: supplier %s, product %s, contract %s ended %s",
+			ErrContractExpired, supplier.ID, purchaseOrder.ProductID, contract.ContractID, contract.ValidTo.Format(time.RFC3339))
+	}
+	if !contract.InForce(at) {
+		return nil
+	}
+
+	if quantity := contract.OrderQuantity(purchaseOrder.Quantity); quantity != purchaseOrder.Quantity {
+		purchaseOrder.Metadata[models.MetadataMOQAdjustedFrom] = purchaseOrder.Quantity
+		purchaseOrder.Quantity = quantity
+	}
+	if pricing, ok := contract.Price(purchaseOrder.Quantity); ok {
+		purchaseOrder.Pricing = pricing
+	}
+	return nil
+}
+
+// reprice prices a topped-up order at the contract price of its new
+// quantity, which may reach another price break. When the contract cannot
+// be read or no longer applies the order keeps its unit price.
+func (c *ProcessStockLowCommand) reprice(ctx context.Context, purchaseOrder *models.PurchaseOrder) {
+	purchaseOrder.Pricing = purchaseOrder.Pricing.ForQuantity(purchaseOrder.Quantity)
+
+	supplier, err := c.getSupplier(ctx, purchaseOrder.SupplierID)
+	if err != nil {
+		c.Logger.Printf("Failed to get supplier %s, keeping unit price: %v", purchaseOrder.SupplierID, err)
+		return
+	}
+	if supplier == nil {
+		return
+	}
+	product := supplier.Product(purchaseOrder.ProductID)
+	if product == nil || product.Contract == nil || !product.Contract.InForce(c.Clock.Now().UTC()) {
+		return
+	}
+	if repriced, ok := product.Contract.Price(purchaseOrder.Quantity); ok {
+		purchaseOrder.Pricing = repriced
+	}
+}
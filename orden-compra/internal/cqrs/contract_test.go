@@ -0,0 +1,104 @@
+package cqrs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"medisupply/clock"
+	"orden-compra/internal/memory"
+	"orden-compra/internal/models"
+)
+
+// putContract lists the stock low supplier with a contract for product-1
+func putContract(t *testing.T, dynamoDB *memory.DynamoDB, contract *models.SupplierContract) {
+	t.Helper()
+	putItem(t, dynamoDB, "orden-compra-suppliers", &models.Supplier{
+		ID:       "supplier-001",
+		Name:     "Acme",
+		IsActive: true,
+		Products: []models.SupplierProduct{{ProductID: "product-1", Contract: contract}},
+	})
+}
+
+func TestStockLowOrdersOnTheContractTerms(t *testing.T) {
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	fake := clock.NewFake(statsDay)
+	putContract(t, dynamoDB, &models.SupplierContract{
+		ContractID:           "contract-1",
+		MinimumOrderQuantity: 500,
+		Currency:             "USD",
+		UnitPrice:            2,
+		PriceBreaks:          []models.PriceBreak{{MinQuantity: 1000, UnitPrice: 1}, {MinQuantity: 500, UnitPrice: 1.5}},
+	})
+
+	result, err := newStockLowCommand(dynamoDB, fake, models.PurchaseOrderPolicy{}).Execute(context.Background())
+	if err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	stored := getPurchaseOrder(t, dynamoDB, result["purchase_order_id"].(string))
+	if stored.Quantity != 500 || stored.Metadata[models.MetadataMOQAdjustedFrom] == nil {
+		t.Fatalf("order of %d adjusted from %v, want 500", stored.Quantity, stored.Metadata[models.MetadataMOQAdjustedFrom])
+	}
+	if pricing := stored.Pricing; pricing == nil || *pricing != (models.OrderPricing{ContractID: "contract-1", Currency: "USD", UnitPrice: 1.5, TotalAmount: 750}) {
+		t.Fatalf("pricing %+v", stored.Pricing)
+	}
+}
+
+func TestStockLowContractValidity(t *testing.T) {
+	before, after := statsDay.Add(-time.Hour), statsDay.Add(time.Hour)
+	for _, tc := range []struct {
+		name     string
+		contract *models.SupplierContract
+		priced   bool
+		expired  bool
+	}{
+		{"in force", &models.SupplierContract{UnitPrice: 2, ValidFrom: &before, ValidTo: &after}, true, false},
+		{"not started", &models.SupplierContract{UnitPrice: 2, ValidFrom: &after}, false, false},
+		{"expired", &models.SupplierContract{UnitPrice: 2, ValidTo: &before}, false, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dynamoDB := memory.NewDynamoDB(memory.Tables)
+			putContract(t, dynamoDB, tc.contract)
+
+			result, err := newStockLowCommand(dynamoDB, clock.NewFake(statsDay), models.PurchaseOrderPolicy{}).Execute(context.Background())
+			if tc.expired {
+				if !errors.Is(err, ErrContractExpired) || len(dynamoDB.Items("orden-compra-read")) != 0 {
+					t.Fatalf("expired contract returned %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("execute: %v", err)
+			}
+			if stored := getPurchaseOrder(t, dynamoDB, result["purchase_order_id"].(string)); (stored.Pricing != nil) != tc.priced {
+				t.Fatalf("pricing %+v", stored.Pricing)
+			}
+		})
+	}
+}
+
+func TestTopUpIsRepricedAtTheContractPrice(t *testing.T) {
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	fake := clock.NewFake(statsDay)
+	putContract(t, dynamoDB, &models.SupplierContract{UnitPrice: 2})
+	first, err := newStockLowCommand(dynamoDB, fake, models.PurchaseOrderPolicy{Duplicates: models.DuplicatePolicyTopUp}).Execute(context.Background())
+	if err != nil {
+		t.Fatalf("first event: %v", err)
+	}
+
+	// The doubled quantity reaches a price break agreed since
+	quantity := first["purchase_order"].(*models.PurchaseOrder).Quantity
+	putContract(t, dynamoDB, &models.SupplierContract{UnitPrice: 2, PriceBreaks: []models.PriceBreak{{MinQuantity: quantity + 1, UnitPrice: 1}}})
+	command := newStockLowCommand(dynamoDB, fake, models.PurchaseOrderPolicy{Duplicates: models.DuplicatePolicyTopUp})
+	command.Event.ID = "stock-low-2"
+	if _, err := command.Execute(context.Background()); err != nil {
+		t.Fatalf("second event: %v", err)
+	}
+
+	stored := getPurchaseOrder(t, dynamoDB, first["purchase_order_id"].(string))
+	if stored.Quantity != 2*quantity || stored.Pricing == nil || stored.Pricing.UnitPrice != 1 || stored.Pricing.TotalAmount != float64(2*quantity) {
+		t.Fatalf("topped-up order of %d priced %+v", stored.Quantity, stored.Pricing)
+	}
+}
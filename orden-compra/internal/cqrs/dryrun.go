@@ -0,0 +1,58 @@
+package cqrs
+
+import (
+	"context"
+
+	"orden-compra/internal/models"
+)
+
+// simulatedOrder reports the purchase order a dry run would have created,
+// with how its quantity was calculated and how it would have been released
+func (c *ProcessStockLowCommand) simulatedOrder(purchaseOrder *models.PurchaseOrder, requiresApproval, awaitingConsolidation bool) map[string]interface{} {
+	c.Logger.Printf("Dry run: purchase order not created - product_id: %s, supplier_id: %s, quantity: %d, strategy: %s, requires_approval: %v, awaiting_consolidation: %v",
+		purchaseOrder.ProductID, purchaseOrder.SupplierID, purchaseOrder.Quantity, c.reorder.Strategy, requiresApproval, awaitingConsolidation)
+
+	return map[string]interface{}{
+		"success":                true,
+		"dry_run":                true,
+		"purchase_order":         purchaseOrder,
+		"requires_approval":      requiresApproval,
+		"awaiting_consolidation": awaitingConsolidation,
+		"reorder": map[string]interface{}{
+			"calculated_quantity": c.reorder.Quantity,
+			"strategy":            c.reorder.Strategy,
+			"source":              c.reorder.Source,
+		},
+		"correlation_id": c.CorrelationID,
+	}
+}
+
+// simulateDuplicate reports how the duplicate policy would have applied to
+// an existing open order, without changing it. It reports false when a new
+// purchase order would be created instead.
+func (c *ProcessStockLowCommand) simulateDuplicate(ctx context.Context, existing *models.PurchaseOrder, quantity int) (map[string]interface{}, bool, error) {
+	switch c.Policy.Duplicates {
+	case models.DuplicatePolicySkip, models.DuplicatePolicyAttach:
+	case models.DuplicatePolicyTopUp:
+		if c.topUpRequiresApproval(existing, quantity) {
+			return nil, false, nil
+		}
+	default:
+		return nil, false, nil
+	}
+
+	c.Logger.Printf("Dry run: stock low event handled as a duplicate - event_id: %s, purchase_order_id: %s, policy: %s", c.Event.ID, existing.ID, c.Policy.Duplicates)
+
+	result := map[string]interface{}{
+		"success":           true,
+		"dry_run":           true,
+		"purchase_order_id": existing.ID,
+		"duplicate":         true,
+		"duplicate_policy":  string(c.Policy.Duplicates),
+		"correlation_id":    c.CorrelationID,
+	}
+	if c.Policy.Duplicates == models.DuplicatePolicyTopUp {
+		result["added_quantity"] = quantity
+	}
+	return result, true, nil
+}
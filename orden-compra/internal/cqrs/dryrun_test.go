@@ -0,0 +1,78 @@
+package cqrs
+
+import (
+	"context"
+	"testing"
+
+	"medisupply/clock"
+	"orden-compra/internal/memory"
+	"orden-compra/internal/models"
+)
+
+func TestDryRunStoresNothing(t *testing.T) {
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	fake := clock.NewFake(statsDay)
+
+	result, err := newStockLowCommand(dynamoDB, fake, models.PurchaseOrderPolicy{DryRun: true}).Execute(context.Background())
+	if err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+
+	order, ok := result["purchase_order"].(*models.PurchaseOrder)
+	if result["dry_run"] != true || !ok || order.ProductID != "product-1" || order.Quantity <= 0 {
+		t.Fatalf("dry run returned %v", result)
+	}
+	reorder, _ := result["reorder"].(map[string]interface{})
+	if reorder["calculated_quantity"] != order.Quantity || reorder["strategy"] == "" {
+		t.Fatalf("reorder details %v, want the calculated quantity %d", reorder, order.Quantity)
+	}
+	for table := range memory.Tables {
+		if items := dynamoDB.Items(table); len(items) != 0 {
+			t.Fatalf("dry run stored %d items in %s", len(items), table)
+		}
+	}
+}
+
+func TestDryRunOfADuplicateLeavesTheOrderAlone(t *testing.T) {
+	for _, tc := range []struct {
+		policy models.DuplicatePolicy
+		added  bool
+	}{
+		{models.DuplicatePolicySkip, false},
+		{models.DuplicatePolicyAttach, false},
+		{models.DuplicatePolicyTopUp, true},
+	} {
+		t.Run(string(tc.policy), func(t *testing.T) {
+			dynamoDB := memory.NewDynamoDB(memory.Tables)
+			fake := clock.NewFake(statsDay)
+			first, err := newStockLowCommand(dynamoDB, fake, models.PurchaseOrderPolicy{}).Execute(context.Background())
+			if err != nil {
+				t.Fatalf("first event: %v", err)
+			}
+			id := first["purchase_order_id"].(string)
+			quantity := first["purchase_order"].(*models.PurchaseOrder).Quantity
+			events := len(dynamoDB.Items("orden-compra-events"))
+
+			command := newStockLowCommand(dynamoDB, fake, models.PurchaseOrderPolicy{Duplicates: tc.policy, DryRun: true})
+			command.Event.ID = "stock-low-2"
+			result, err := command.Execute(context.Background())
+			if err != nil {
+				t.Fatalf("dry run: %v", err)
+			}
+
+			if result["dry_run"] != true || result["duplicate"] != true || result["purchase_order_id"] != id || result["duplicate_policy"] != string(tc.policy) {
+				t.Fatalf("dry run returned %v, want %s as a duplicate", result, id)
+			}
+			if _, added := result["added_quantity"]; added != tc.added {
+				t.Fatalf("dry run returned %v, added quantity reported: %v", result, added)
+			}
+			order := getPurchaseOrder(t, dynamoDB, id)
+			if order.Quantity != quantity || order.Metadata[models.MetadataAttachedStockLowEvents] != nil {
+				t.Fatalf("dry run changed the order to %+v", order)
+			}
+			if stored := len(dynamoDB.Items("orden-compra-events")); stored != events {
+				t.Fatalf("dry run stored %d events", stored-events)
+			}
+		})
+	}
+}
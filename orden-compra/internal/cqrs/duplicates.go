@@ -0,0 +1,163 @@
+package cqrs
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+
+	"orden-compra/internal/models"
+)
+
+// findOpenPurchaseOrder returns the most recent open purchase order for the
+// event's product and location, or nil if there is none
+func (c *ProcessStockLowCommand) findOpenPurchaseOrder(ctx context.Context) (*models.PurchaseOrder, error) {
+	expressionAttributeValues := map[string]*dynamodb.AttributeValue{
+		":product_id": {S: aws.String(c.Event.ProductID)},
+		":location":   {S: aws.String(c.Event.Location)},
+	}
+
+	var statusPlaceholders []string
+	for i, status := range models.OpenStatuses() {
+		placeholder := fmt.Sprintf(":status%d", i)
+		statusPlaceholders = append(statusPlaceholders, placeholder)
+		expressionAttributeValues[placeholder] = &dynamodb.AttributeValue{S: aws.String(status)}
+	}
+
+	scanInput := &dynamodb.ScanInput{
+		TableName:                 aws.String("orden-compra-read"),
+		FilterExpression:          aws.String(fmt.Sprintf("product_id = :product_id AND #location = :location AND #status IN (%s)", strings.Join(statusPlaceholders, ", "))),
+		ExpressionAttributeNames:  map[string]*string{"#status": aws.String("status"), "#location": aws.String("location")},
+		ExpressionAttributeValues: expressionAttributeValues,
+	}
+
+	var latest *models.PurchaseOrder
+	for {
+		result, err := c.DynamoDB.ScanWithContext(ctx, scanInput)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan: %w", err)
+		}
+
+		for _, item := range result.Items {
+			var purchaseOrder models.PurchaseOrder
+			if err := dynamodbattribute.UnmarshalMap(item, &purchaseOrder); err != nil {
+				c.Logger.Printf("Failed to unmarshal purchase order: %v", err)
+				continue
+			}
+			if !purchaseOrder.IsOpen() {
+				continue
+			}
+			if latest == nil || purchaseOrder.CreatedAt.After(latest.CreatedAt) {
+				po := purchaseOrder
+				latest = &po
+			}
+		}
+
+		if len(result.LastEvaluatedKey) == 0 {
+			break
+		}
+		scanInput.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+
+	if latest != nil && latest.Metadata == nil {
+		latest.Metadata = make(map[string]interface{})
+	}
+
+	return latest, nil
+}
+
+// handleDuplicate applies the duplicate policy to an existing open order. It
+// reports false when a new purchase order should be created instead.
+func (c *ProcessStockLowCommand) handleDuplicate(ctx context.Context, existing *models.PurchaseOrder, quantity int) (map[string]interface{}, bool, error) {
+	result := map[string]interface{}{
+		"success":           true,
+		"purchase_order_id": existing.ID,
+		"duplicate":         true,
+		"duplicate_policy":  string(c.Policy.Duplicates),
+		"correlation_id":    c.CorrelationID,
+	}
+
+	switch c.Policy.Duplicates {
+	case models.DuplicatePolicySkip:
+		if err := c.storeEventSourcingEvent(ctx, existing, "StockLowEventSuppressed"); err != nil {
+			c.Logger.Printf("Failed to store event sourcing event: %v", err)
+			return nil, true, fmt.Errorf("failed to store event sourcing event: %w", err)
+		}
+
+		c.Logger.Printf("Stock low event suppressed - event_id: %s, existing purchase_order_id: %s", c.Event.ID, existing.ID)
+		return result, true, nil
+
+	case models.DuplicatePolicyAttach:
+		existing.AttachStockLowEvent(c.Event.ID, c.Clock.Now())
+
+		if _, err := c.storePurchaseOrder(ctx, existing, nil, nil); err != nil {
+			c.Logger.Printf("Failed to store purchase order: %v", err)
+			return nil, true, fmt.Errorf("failed to store purchase order: %w", err)
+		}
+		if err := c.storeEventSourcingEvent(ctx, existing, "StockLowEventAttached"); err != nil {
+			c.Logger.Printf("Failed to store event sourcing event: %v", err)
+			return nil, true, fmt.Errorf("failed to store event sourcing event: %w", err)
+		}
+
+		c.Logger.Printf("Stock low event attached - event_id: %s, purchase_order_id: %s", c.Event.ID, existing.ID)
+		return result, true, nil
+
+	case models.DuplicatePolicyTopUp:
+		// A top-up must not sneak an order past approval: if the larger order
+		// would need approval it has not already asked for, create a new order
+		if c.topUpRequiresApproval(existing, quantity) {
+			c.Logger.Printf("Top-up would require approval, creating a new purchase order - purchase_order_id: %s", existing.ID)
+			return nil, false, nil
+		}
+
+		existing.TopUp(quantity, c.Clock.Now())
+		existing.AttachStockLowEvent(c.Event.ID, c.Clock.Now())
+		if existing.Pricing != nil {
+			c.reprice(ctx, existing)
+		}
+
+		// Orders already released to Proveedor get a reception for the added quantity;
+		// orders awaiting approval or consolidation are released in full later
+		var receptionEvent *models.RecepcionProveedorEvent
+		if existing.Status != models.StatusPendingApproval && !existing.IsAwaitingConsolidation() {
+			receptionEvent = newReceptionEvent(existing, c.CorrelationID, c.CausationID, c.Clock.Now())
+			receptionEvent.Quantity = quantity
+			receptionEvent.Metadata["stock_low_event_id"] = c.Event.ID
+			receptionEvent.Metadata["top_up"] = true
+			result["reception_event"] = receptionEvent
+		}
+		orderPlacedEvent := c.newOrderPlacedEvent(ctx, existing)
+		result["order_placed_event"] = orderPlacedEvent
+
+		messages, err := c.storePurchaseOrder(ctx, existing, receptionEvent, orderPlacedEvent)
+		if err != nil {
+			c.Logger.Printf("Failed to store purchase order: %v", err)
+			return nil, true, fmt.Errorf("failed to store purchase order: %w", err)
+		}
+		result["outbox_messages"] = messages
+		if err := c.storeEventSourcingEvent(ctx, existing, "PurchaseOrderToppedUp"); err != nil {
+			c.Logger.Printf("Failed to store event sourcing event: %v", err)
+			return nil, true, fmt.Errorf("failed to store event sourcing event: %w", err)
+		}
+
+		c.Logger.Printf("Purchase order topped up - purchase_order_id: %s, added_quantity: %d, quantity: %d", existing.ID, quantity, existing.Quantity)
+		return result, true, nil
+	}
+
+	return nil, false, nil
+}
+
+// topUpRequiresApproval reports whether topping up an order that has not
+// asked for approval would make it need one
+func (c *ProcessStockLowCommand) topUpRequiresApproval(existing *models.PurchaseOrder, quantity int) bool {
+	if existing.Status == models.StatusPendingApproval {
+		return false
+	}
+	toppedUp := *existing
+	toppedUp.Quantity += quantity
+	requiresApproval, _ := c.Policy.Approval.RequiresApproval(&toppedUp)
+	return requiresApproval
+}
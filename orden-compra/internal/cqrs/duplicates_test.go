@@ -0,0 +1,134 @@
+package cqrs
+
+import (
+	"context"
+	"testing"
+
+	"medisupply/clock"
+	"orden-compra/internal/memory"
+	"orden-compra/internal/models"
+)
+
+// placeTwice runs two StockBajo events for the same product and location
+// under policy and returns the results
+func placeTwice(t *testing.T, dynamoDB *memory.DynamoDB, fake *clock.Fake, policy models.PurchaseOrderPolicy) (first, second map[string]interface{}) {
+	t.Helper()
+	first, err := newStockLowCommand(dynamoDB, fake, policy).Execute(context.Background())
+	if err != nil {
+		t.Fatalf("first event: %v", err)
+	}
+	command := newStockLowCommand(dynamoDB, fake, policy)
+	command.Event.ID = "stock-low-2"
+	second, err = command.Execute(context.Background())
+	if err != nil {
+		t.Fatalf("second event: %v", err)
+	}
+	return first, second
+}
+
+func TestDuplicatePolicies(t *testing.T) {
+	for _, tc := range []struct {
+		policy       models.DuplicatePolicy
+		orders       int
+		storedEvent  string
+		attached     bool
+		quantityRate int
+	}{
+		{models.DuplicatePolicyCreate, 2, "PurchaseOrderCreated", false, 1},
+		{models.DuplicatePolicySkip, 1, "StockLowEventSuppressed", false, 1},
+		{models.DuplicatePolicyAttach, 1, "StockLowEventAttached", true, 1},
+		{models.DuplicatePolicyTopUp, 1, "PurchaseOrderToppedUp", true, 2},
+	} {
+		t.Run(string(tc.policy), func(t *testing.T) {
+			dynamoDB := memory.NewDynamoDB(memory.Tables)
+			fake := clock.NewFake(statsDay)
+			first, second := placeTwice(t, dynamoDB, fake, models.PurchaseOrderPolicy{Duplicates: tc.policy})
+
+			if orders := dynamoDB.Items("orden-compra-read"); len(orders) != tc.orders {
+				t.Fatalf("stored %d orders, want %d", len(orders), tc.orders)
+			}
+			if times := storedEventTimes(t, dynamoDB, tc.storedEvent); len(times) == 0 {
+				t.Fatalf("no %s event stored", tc.storedEvent)
+			}
+			if tc.orders == 2 {
+				return
+			}
+
+			if second["duplicate"] != true || second["purchase_order_id"] != first["purchase_order_id"] {
+				t.Fatalf("second event returned %v, want the first order as a duplicate", second)
+			}
+			order := getPurchaseOrder(t, dynamoDB, first["purchase_order_id"].(string))
+			firstQuantity := first["purchase_order"].(*models.PurchaseOrder).Quantity
+			if order.Quantity != tc.quantityRate*firstQuantity {
+				t.Fatalf("order quantity %d, want %d", order.Quantity, tc.quantityRate*firstQuantity)
+			}
+			attached, _ := order.Metadata[models.MetadataAttachedStockLowEvents].([]interface{})
+			if tc.attached != (len(attached) == 1 && attached[0] == "stock-low-2") {
+				t.Fatalf("attached events %v", order.Metadata[models.MetadataAttachedStockLowEvents])
+			}
+		})
+	}
+}
+
+func TestTopUpOfAReleasedOrderSendsTheAddedQuantity(t *testing.T) {
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	fake := clock.NewFake(statsDay)
+	first, second := placeTwice(t, dynamoDB, fake, models.PurchaseOrderPolicy{Duplicates: models.DuplicatePolicyTopUp})
+
+	added := first["purchase_order"].(*models.PurchaseOrder).Quantity
+	reception, ok := second["reception_event"].(*models.RecepcionProveedorEvent)
+	if !ok || reception.Quantity != added || reception.Metadata["top_up"] != true || reception.Metadata["stock_low_event_id"] != "stock-low-2" {
+		t.Fatalf("top-up reception event %+v, want one for the %d added", second["reception_event"], added)
+	}
+}
+
+func TestTopUpThatWouldNeedApprovalCreatesANewOrder(t *testing.T) {
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	fake := clock.NewFake(statsDay)
+
+	// The first order is just under the threshold; both together are over it
+	first, err := newStockLowCommand(dynamoDB, fake, models.PurchaseOrderPolicy{}).Execute(context.Background())
+	if err != nil {
+		t.Fatalf("first event: %v", err)
+	}
+	quantity := first["purchase_order"].(*models.PurchaseOrder).Quantity
+	policy := models.PurchaseOrderPolicy{Duplicates: models.DuplicatePolicyTopUp, Approval: models.ApprovalPolicy{QuantityThreshold: quantity}}
+	command := newStockLowCommand(dynamoDB, fake, policy)
+	command.Event.ID = "stock-low-2"
+	second, err := command.Execute(context.Background())
+	if err != nil {
+		t.Fatalf("second event: %v", err)
+	}
+
+	if second["duplicate"] == true || second["purchase_order_id"] == first["purchase_order_id"] {
+		t.Fatalf("second event returned %v, want a new order", second)
+	}
+	if stored := getPurchaseOrder(t, dynamoDB, first["purchase_order_id"].(string)); stored.Quantity != quantity {
+		t.Fatalf("first order quantity %d, want it left at %d", stored.Quantity, quantity)
+	}
+}
+
+func TestOrdersSentToTheSupplierAreNotDuplicates(t *testing.T) {
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	fake := clock.NewFake(statsDay)
+	policy := models.PurchaseOrderPolicy{Duplicates: models.DuplicatePolicySkip}
+
+	first, err := newStockLowCommand(dynamoDB, fake, policy).Execute(context.Background())
+	if err != nil {
+		t.Fatalf("first event: %v", err)
+	}
+	update := NewUpdatePurchaseOrderStatusCommand(first["purchase_order_id"].(string), models.StatusSent, dynamoDB, discardLogger, nil, nil)
+	update.Clock = fake
+	if _, err := update.Execute(context.Background()); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	command := newStockLowCommand(dynamoDB, fake, policy)
+	command.Event.ID = "stock-low-2"
+	if _, err := command.Execute(context.Background()); err != nil {
+		t.Fatalf("second event: %v", err)
+	}
+	if orders := dynamoDB.Items("orden-compra-read"); len(orders) != 2 {
+		t.Fatalf("stored %d orders, want a new one next to the sent order", len(orders))
+	}
+}
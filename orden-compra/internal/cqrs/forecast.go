@@ -0,0 +1,257 @@
+package cqrs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/sirupsen/logrus"
+
+	"medisupply/clock"
+	"orden-compra/internal/models"
+	"orden-compra/internal/tenant"
+)
+
+// GetReorderSuggestionsQuery analyzes the events stored within the forecast
+// window and suggests a reorder point and order quantity for each product
+// that ran low in it
+type GetReorderSuggestionsQuery struct {
+	ProductID *string
+	Policy    models.ForecastPolicy
+	DynamoDB  dynamodbiface.DynamoDBAPI
+	Logger    *logrus.Logger
+	Clock     clock.Clock
+}
+
+// NewGetReorderSuggestionsQuery creates a new GetReorderSuggestionsQuery
+func NewGetReorderSuggestionsQuery(policy models.ForecastPolicy, dynamoDB dynamodbiface.DynamoDBAPI, logger *logrus.Logger) *GetReorderSuggestionsQuery {
+	return &GetReorderSuggestionsQuery{
+		Policy:   policy,
+		DynamoDB: dynamoDB,
+		Logger:   logger,
+		Clock:    clock.System,
+	}
+}
+
+// WithProductID sets the product ID filter
+func (q *GetReorderSuggestionsQuery) WithProductID(productID string) *GetReorderSuggestionsQuery {
+	q.ProductID = &productID
+	return q
+}
+
+// Execute replays the events in the window. Every event recording a
+// StockBajo event counts towards the product's frequency; the quantity it
+// ordered or topped up counts towards its demand. Lead times come from the
+// latest snapshot of each order.
+func (q *GetReorderSuggestionsQuery) Execute(ctx context.Context) (map[string]interface{}, error) {
+	q.Logger.Debug("Computing reorder suggestions")
+
+	now := q.Clock.Now().UTC()
+	filterExpression := "#timestamp > :since AND attribute_exists(event_data.purchase_order)"
+	expressionAttributeValues := map[string]*dynamodb.AttributeValue{
+		":since": {S: aws.String(now.Add(-q.Policy.Window).Format(time.RFC3339Nano))},
+	}
+	if q.ProductID != nil {
+		filterExpression += " AND event_data.purchase_order.product_id = :product_id"
+		expressionAttributeValues[":product_id"] = &dynamodb.AttributeValue{S: q.ProductID}
+	}
+	scanInput := &dynamodb.ScanInput{
+		TableName:                 aws.String("orden-compra-events"),
+		FilterExpression:          aws.String(filterExpression),
+		ExpressionAttributeNames:  map[string]*string{"#timestamp": aws.String("timestamp")},
+		ExpressionAttributeValues: expressionAttributeValues,
+	}
+
+	histories := make(map[string]*models.DemandHistory)
+	latest := make(map[string]*orderSnapshot)
+	events := 0
+	for {
+		result, err := q.DynamoDB.ScanWithContext(ctx, scanInput)
+		if err != nil {
+			q.Logger.WithError(err).Error("Failed to scan events")
+			return nil, fmt.Errorf("failed to scan events: %w", err)
+		}
+
+		for _, item := range result.Items {
+			snapshot, err := newOrderSnapshot(item)
+			if err != nil {
+				q.Logger.WithError(err).Warn("Failed to read order snapshot, skipped")
+				continue
+			}
+			events++
+
+			// The event's tenant, which is attributed to the default tenant when
+			// the event predates multi-tenancy
+			purchaseOrder := snapshot.PurchaseOrder
+			if owner := item[tenant.Attribute]; owner != nil && owner.S != nil {
+				purchaseOrder.TenantID = *owner.S
+			}
+			key := purchaseOrder.TenantID + "\x00" + purchaseOrder.ProductID
+			history, ok := histories[key]
+			if !ok {
+				history = models.NewDemandHistory(purchaseOrder.TenantID, purchaseOrder.ProductID)
+				histories[key] = history
+			}
+			if history.ProductName == "" {
+				history.ProductName = purchaseOrder.ProductName
+			}
+
+			if stockLowEvent := item["event_data"].M["stock_low_event"]; stockLowEvent != nil {
+				history.AddStockLowEvent(snapshot.Timestamp, orderedQuantity(snapshot, stockLowEvent))
+			}
+
+			if previous, ok := latest[purchaseOrder.ID]; !ok || snapshot.newerThan(previous) {
+				latest[purchaseOrder.ID] = snapshot
+			}
+		}
+
+		if len(result.LastEvaluatedKey) == 0 {
+			break
+		}
+		scanInput.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+
+	for _, snapshot := range latest {
+		purchaseOrder := snapshot.PurchaseOrder
+		if history, ok := histories[purchaseOrder.TenantID+"\x00"+purchaseOrder.ProductID]; ok {
+			history.AddLeadTime(purchaseOrder)
+		}
+	}
+
+	suggestions := make([]*models.ReorderSuggestion, 0, len(histories))
+	for _, history := range histories {
+		suggestion := history.Suggest(q.Policy, now)
+		// Products that never ran low in the window have nothing to suggest
+		if suggestion.StockLowEvents == 0 {
+			continue
+		}
+		suggestions = append(suggestions, suggestion)
+	}
+	sort.Slice(suggestions, func(i, j int) bool {
+		if suggestions[i].TenantID != suggestions[j].TenantID {
+			return suggestions[i].TenantID < suggestions[j].TenantID
+		}
+		return suggestions[i].ProductID < suggestions[j].ProductID
+	})
+
+	return map[string]interface{}{
+		"success":     true,
+		"suggestions": suggestions,
+		"count":       len(suggestions),
+		"events":      events,
+		"since":       now.Add(-q.Policy.Window),
+	}, nil
+}
+
+// orderedQuantity returns the quantity a StockBajo event added to an order:
+// the order's quantity when it created the order, the calculated quantity
+// when it topped one up, and nothing when it was suppressed or attached
+func orderedQuantity(snapshot *orderSnapshot, stockLowEvent *dynamodb.AttributeValue) int {
+	switch snapshot.EventType {
+	case "PurchaseOrderCreated":
+		return snapshot.PurchaseOrder.Quantity
+	case "PurchaseOrderToppedUp":
+		if calculated := stockLowEvent.M["calculated_quantity"]; calculated != nil && calculated.N != nil {
+			quantity, _ := strconv.Atoi(*calculated.N)
+			return quantity
+		}
+	}
+	return 0
+}
+
+// ApplyReorderSuggestionCommand makes a reorder suggestion the product's
+// reorder policy override, so its orders are placed for the suggested
+// quantity. An override set by hand is never replaced.
+type ApplyReorderSuggestionCommand struct {
+	Suggestion *models.ReorderSuggestion
+	DynamoDB   dynamodbiface.DynamoDBAPI
+	Logger     *log.Logger
+	Clock      clock.Clock
+}
+
+// NewApplyReorderSuggestionCommand creates a new ApplyReorderSuggestionCommand
+func NewApplyReorderSuggestionCommand(suggestion *models.ReorderSuggestion, dynamoDB dynamodbiface.DynamoDBAPI, logger *log.Logger) *ApplyReorderSuggestionCommand {
+	return &ApplyReorderSuggestionCommand{
+		Suggestion: suggestion,
+		DynamoDB:   dynamoDB,
+		Logger:     logger,
+		Clock:      clock.System,
+	}
+}
+
+// Execute writes the fixed_quantity override of the suggestion. It reports
+// applied false when the history was insufficient, the override already
+// orders the suggested quantity or the product has an override set by hand.
+func (c *ApplyReorderSuggestionCommand) Execute(ctx context.Context) (map[string]interface{}, error) {
+	suggestion := c.Suggestion
+	result := map[string]interface{}{
+		"success":    true,
+		"applied":    false,
+		"product_id": suggestion.ProductID,
+	}
+	if !suggestion.Sufficient {
+		result["reason"] = "insufficient history"
+		return result, nil
+	}
+
+	override := &models.ReorderPolicyOverride{
+		ProductID:     suggestion.ProductID,
+		TenantID:      suggestion.TenantID,
+		Strategy:      models.ReorderFixedQuantity,
+		FixedQuantity: suggestion.SuggestedQuantity,
+		Source:        models.ReorderSourceForecast,
+	}
+	item, err := dynamodbattribute.MarshalMap(override)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal reorder policy: %w", err)
+	}
+
+	output, err := c.DynamoDB.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName:                aws.String("orden-compra-reorder-policies"),
+		Item:                     item,
+		ConditionExpression:      aws.String("attribute_not_exists(product_id) OR (#source = :forecast AND (attribute_not_exists(fixed_quantity) OR fixed_quantity <> :quantity))"),
+		ExpressionAttributeNames: map[string]*string{"#source": aws.String("source")},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":forecast": {S: aws.String(models.ReorderSourceForecast)},
+			":quantity": {N: aws.String(strconv.Itoa(suggestion.SuggestedQuantity))},
+		},
+		ReturnValues: aws.String(dynamodb.ReturnValueAllOld),
+	})
+	if err != nil {
+		var awsErr awserr.Error
+		if errors.As(err, &awsErr) && awsErr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			result["reason"] = "override set by hand or unchanged"
+			return result, nil
+		}
+		return nil, fmt.Errorf("failed to put reorder policy: %w", err)
+	}
+
+	var previous *models.ReorderPolicyOverride
+	if len(output.Attributes) > 0 {
+		previous = &models.ReorderPolicyOverride{}
+		if err := dynamodbattribute.UnmarshalMap(output.Attributes, previous); err != nil {
+			c.Logger.Printf("Failed to unmarshal previous reorder policy of product %s: %v", suggestion.ProductID, err)
+			previous = nil
+		}
+		if previous != nil {
+			// The stored key may carry the tenant prefix
+			previous.ProductID = suggestion.ProductID
+		}
+	}
+
+	c.Logger.Printf("Reorder suggestion applied - product_id: %s, fixed_quantity: %d, reorder_point: %d", suggestion.ProductID, suggestion.SuggestedQuantity, suggestion.ReorderPoint)
+
+	result["applied"] = true
+	result["override"] = override
+	result["previous"] = previous
+	return result, nil
+}
@@ -0,0 +1,97 @@
+package cqrs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"medisupply/clock"
+	"orden-compra/internal/memory"
+	"orden-compra/internal/models"
+)
+
+// suggestReorders places an order of 80 every 5 days for 10 days and
+// returns the suggestion for product-1 at the end
+func suggestReorders(t *testing.T, dynamoDB *memory.DynamoDB, fake *clock.Fake) *models.ReorderSuggestion {
+	t.Helper()
+	for i, id := range []string{"stock-low-1", "stock-low-2", "stock-low-3"} {
+		if i > 0 {
+			fake.Advance(5 * 24 * time.Hour)
+		}
+		command := newStockLowCommand(dynamoDB, fake, models.PurchaseOrderPolicy{Duplicates: models.DuplicatePolicyCreate})
+		command.Event.ID = id
+		command.Event.Timestamp = fake.Now()
+		if _, err := command.Execute(context.Background()); err != nil {
+			t.Fatalf("stock low %s: %v", id, err)
+		}
+	}
+
+	query := NewGetReorderSuggestionsQuery(models.DefaultForecastPolicy(), dynamoDB, discardLogrus).WithProductID("product-1")
+	query.Clock = fake
+	result, err := query.Execute(context.Background())
+	if err != nil {
+		t.Fatalf("suggest: %v", err)
+	}
+	suggestions := result["suggestions"].([]*models.ReorderSuggestion)
+	if len(suggestions) != 1 {
+		t.Fatalf("suggestions %v, want one for product-1", suggestions)
+	}
+	return suggestions[0]
+}
+
+func TestReorderSuggestionsFromTheEventHistory(t *testing.T) {
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	suggestion := suggestReorders(t, dynamoDB, clock.NewFake(statsDay))
+
+	// 240 ordered over 10 days, with the lead time promised to the orders
+	if !suggestion.Sufficient || suggestion.StockLowEvents != 3 || suggestion.OrderedQuantity != 240 || suggestion.DailyDemand != 24 || suggestion.SuggestedQuantity != 720 {
+		t.Fatalf("suggestion %+v", suggestion)
+	}
+	if suggestion.LeadTimeSource != models.LeadTimeSourcePromised || suggestion.LeadTimeDays != 7 || suggestion.ReorderPoint <= 7*24 {
+		t.Fatalf("lead time %g from %s, reorder point %d", suggestion.LeadTimeDays, suggestion.LeadTimeSource, suggestion.ReorderPoint)
+	}
+}
+
+func TestApplyReorderSuggestion(t *testing.T) {
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	fake := clock.NewFake(statsDay)
+	suggestion := suggestReorders(t, dynamoDB, fake)
+	apply := func(suggestion *models.ReorderSuggestion) map[string]interface{} {
+		t.Helper()
+		result, err := NewApplyReorderSuggestionCommand(suggestion, dynamoDB, discardLogger).Execute(context.Background())
+		if err != nil {
+			t.Fatalf("apply: %v", err)
+		}
+		return result
+	}
+
+	if result := apply(suggestion); result["applied"] != true || result["previous"] != (*models.ReorderPolicyOverride)(nil) {
+		t.Fatalf("apply returned %v", result)
+	}
+	if result := apply(suggestion); result["applied"] != false {
+		t.Fatalf("applying the same suggestion again returned %v", result)
+	}
+	if result := apply(&models.ReorderSuggestion{ProductID: "product-1"}); result["applied"] != false || result["reason"] != "insufficient history" {
+		t.Fatalf("applying an insufficient suggestion returned %v", result)
+	}
+
+	// Orders are placed for the suggested quantity
+	command := newStockLowCommand(dynamoDB, fake, models.PurchaseOrderPolicy{ProductOverrides: true, Duplicates: models.DuplicatePolicyCreate})
+	command.Event.ID = "stock-low-4"
+	result, err := command.Execute(context.Background())
+	if err != nil {
+		t.Fatalf("stock low: %v", err)
+	}
+	order := getPurchaseOrder(t, dynamoDB, result["purchase_order_id"].(string))
+	if order.Quantity != 720 || order.Metadata[models.MetadataReorderSource] != models.ReorderSourceForecast {
+		t.Fatalf("ordered %d from %v, want 720 from the forecast", order.Quantity, order.Metadata[models.MetadataReorderSource])
+	}
+
+	// An override set by hand is never replaced
+	putItem(t, dynamoDB, "orden-compra-reorder-policies", &models.ReorderPolicyOverride{ProductID: "product-1", Strategy: models.ReorderFixedQuantity, FixedQuantity: 50})
+	changed := *suggestion
+	changed.SuggestedQuantity = 900
+	if result := apply(&changed); result["applied"] != false {
+		t.Fatalf("apply over a hand override returned %v", result)
+	}
+}
@@ -0,0 +1,157 @@
+package cqrs
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/sirupsen/logrus"
+)
+
+// unknownStatus is the old_status of status updates recorded before the
+// previous status was tracked
+const unknownStatus = "unknown"
+
+// historyEvent holds the event attributes the status history reads
+type historyEvent struct {
+	ID            string    `dynamodbav:"id"`
+	EventType     string    `dynamodbav:"event_type"`
+	Timestamp     time.Time `dynamodbav:"timestamp"`
+	CorrelationID *string   `dynamodbav:"correlation_id"`
+	EventData     struct {
+		PurchaseOrder struct {
+			Status string `dynamodbav:"status"`
+		} `dynamodbav:"purchase_order"`
+		PreviousStatus string `dynamodbav:"previous_status"`
+		StatusChange   struct {
+			OldStatus string `dynamodbav:"old_status"`
+		} `dynamodbav:"status_change"`
+	} `dynamodbav:"event_data"`
+}
+
+// previousStatus returns the status the event recorded the order moving
+// from, or "" when it does not say
+func (e *historyEvent) previousStatus() string {
+	if e.EventData.PreviousStatus != "" {
+		return e.EventData.PreviousStatus
+	}
+	if old := e.EventData.StatusChange.OldStatus; old != unknownStatus {
+		return old
+	}
+	return ""
+}
+
+// StatusHistoryEntry is one status change of a purchase order
+type StatusHistoryEntry struct {
+	From          string    `json:"from,omitempty"`
+	To            string    `json:"to"`
+	EventID       string    `json:"event_id"`
+	EventType     string    `json:"event_type"`
+	ChangedAt     time.Time `json:"changed_at"`
+	CorrelationID *string   `json:"correlation_id,omitempty"`
+}
+
+// GetPurchaseOrderStatusHistoryQuery derives the status changes of a
+// purchase order from its events: every event snapshots the order, so a
+// change is recorded wherever the snapshot's status differs from the one
+// before. Events that name the previous status take precedence, which keeps
+// the history right when earlier events were archived.
+type GetPurchaseOrderStatusHistoryQuery struct {
+	PurchaseOrderID string
+	DynamoDB        dynamodbiface.DynamoDBAPI
+	Logger          *logrus.Logger
+}
+
+// NewGetPurchaseOrderStatusHistoryQuery creates a new GetPurchaseOrderStatusHistoryQuery
+func NewGetPurchaseOrderStatusHistoryQuery(purchaseOrderID string, dynamoDB dynamodbiface.DynamoDBAPI, logger *logrus.Logger) *GetPurchaseOrderStatusHistoryQuery {
+	return &GetPurchaseOrderStatusHistoryQuery{
+		PurchaseOrderID: purchaseOrderID,
+		DynamoDB:        dynamoDB,
+		Logger:          logger,
+	}
+}
+
+// Execute returns the status changes of the order, oldest first
+func (q *GetPurchaseOrderStatusHistoryQuery) Execute(ctx context.Context) (map[string]interface{}, error) {
+	q.Logger.WithFields(logrus.Fields{
+		"purchase_order_id": q.PurchaseOrderID,
+	}).Debug("Getting purchase order status history")
+
+	scanInput := &dynamodb.ScanInput{
+		TableName:            aws.String("orden-compra-events"),
+		FilterExpression:     aws.String("aggregate_id = :aggregate_id"),
+		ProjectionExpression: aws.String("id, event_type, #timestamp, correlation_id, event_data.purchase_order.#status, event_data.previous_status, event_data.status_change"),
+		ExpressionAttributeNames: map[string]*string{
+			"#timestamp": aws.String("timestamp"),
+			"#status":    aws.String("status"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":aggregate_id": {S: aws.String(q.PurchaseOrderID)},
+		},
+	}
+
+	var events []historyEvent
+	for {
+		result, err := q.DynamoDB.ScanWithContext(ctx, scanInput)
+		if err != nil {
+			q.Logger.WithError(err).Error("Failed to scan purchase order events")
+			return nil, fmt.Errorf("failed to scan: %w", err)
+		}
+
+		for _, item := range result.Items {
+			var event historyEvent
+			if err := dynamodbattribute.UnmarshalMap(item, &event); err != nil {
+				q.Logger.WithError(err).Error("Failed to unmarshal event")
+				continue
+			}
+			events = append(events, event)
+		}
+
+		if len(result.LastEvaluatedKey) == 0 {
+			break
+		}
+		scanInput.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+
+	if len(events) == 0 {
+		return nil, ErrPurchaseOrderNotFound
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Timestamp.Before(events[j].Timestamp)
+	})
+
+	history := make([]StatusHistoryEntry, 0)
+	current := ""
+	for _, event := range events {
+		status := event.EventData.PurchaseOrder.Status
+		if status == "" || status == current {
+			continue
+		}
+		from := event.previousStatus()
+		if from == "" {
+			from = current
+		}
+		history = append(history, StatusHistoryEntry{
+			From:          from,
+			To:            status,
+			EventID:       event.ID,
+			EventType:     event.EventType,
+			ChangedAt:     event.Timestamp,
+			CorrelationID: event.CorrelationID,
+		})
+		current = status
+	}
+
+	return map[string]interface{}{
+		"success":           true,
+		"purchase_order_id": q.PurchaseOrderID,
+		"status":            current,
+		"history":           history,
+		"count":             len(history),
+	}, nil
+}
@@ -0,0 +1,84 @@
+package cqrs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"medisupply/clock"
+	"orden-compra/internal/memory"
+	"orden-compra/internal/models"
+)
+
+// statusHistory runs the status history query of an order
+func statusHistory(t *testing.T, dynamoDB *memory.DynamoDB, id string) []StatusHistoryEntry {
+	t.Helper()
+	result, err := NewGetPurchaseOrderStatusHistoryQuery(id, dynamoDB, discardLogrus).Execute(context.Background())
+	if err != nil {
+		t.Fatalf("status history: %v", err)
+	}
+	return result["history"].([]StatusHistoryEntry)
+}
+
+func TestStatusHistoryFollowsTheCommands(t *testing.T) {
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	fake := clock.NewFake(statsDay)
+	created := createPurchaseOrder(t, dynamoDB, fake, models.StatusPending)
+	for _, status := range []string{models.StatusSent, models.StatusReceived} {
+		fake.Advance(time.Hour)
+		update := NewUpdatePurchaseOrderStatusCommand(created.ID, status, dynamoDB, discardLogger, nil, nil)
+		update.Clock = fake
+		if _, err := update.Execute(context.Background()); err != nil {
+			t.Fatalf("update to %s: %v", status, err)
+		}
+	}
+
+	history := statusHistory(t, dynamoDB, created.ID)
+	want := []StatusHistoryEntry{
+		{To: models.StatusPending, EventType: "PurchaseOrderCreated", ChangedAt: statsDay},
+		{From: models.StatusPending, To: models.StatusSent, EventType: "PurchaseOrderStatusUpdated", ChangedAt: statsDay.Add(time.Hour)},
+		{From: models.StatusSent, To: models.StatusReceived, EventType: "PurchaseOrderStatusUpdated", ChangedAt: statsDay.Add(2 * time.Hour)},
+	}
+	if len(history) != len(want) {
+		t.Fatalf("history %+v, want %d changes", history, len(want))
+	}
+	for i, entry := range history {
+		if entry.From != want[i].From || entry.To != want[i].To || entry.EventType != want[i].EventType || !entry.ChangedAt.Equal(want[i].ChangedAt) || entry.EventID == "" {
+			t.Fatalf("change %d is %+v, want %+v", i, entry, want[i])
+		}
+	}
+}
+
+func TestStatusHistoryOfOlderEvents(t *testing.T) {
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	snapshot := func(status string) map[string]interface{} {
+		return map[string]interface{}{"purchase_order": map[string]interface{}{"status": status}}
+	}
+
+	// The creation event was archived, so the first change names its
+	// previous status; a later one predates tracking it
+	approved := snapshot(models.StatusApproved)
+	approved["previous_status"] = models.StatusPendingApproval
+	sent := snapshot(models.StatusSent)
+	sent["status_change"] = map[string]interface{}{"old_status": unknownStatus, "new_status": models.StatusSent}
+	putItem(t, dynamoDB, "orden-compra-events", models.NewEventSourcingEvent("po-1", "PurchaseOrderApproved", approved, nil, nil, statsDay))
+	putItem(t, dynamoDB, "orden-compra-events", models.NewEventSourcingEvent("po-1", "PurchaseOrderStatusUpdated", sent, nil, nil, statsDay.Add(time.Hour)))
+	// An event that leaves the status alone is not a change
+	putItem(t, dynamoDB, "orden-compra-events", models.NewEventSourcingEvent("po-1", "PurchaseOrderAcknowledged", snapshot(models.StatusSent), nil, nil, statsDay.Add(2*time.Hour)))
+
+	history := statusHistory(t, dynamoDB, "po-1")
+	if len(history) != 2 {
+		t.Fatalf("history %+v, want 2 changes", history)
+	}
+	if history[0].From != models.StatusPendingApproval || history[0].To != models.StatusApproved {
+		t.Fatalf("first change %s -> %s, want the recorded previous status", history[0].From, history[0].To)
+	}
+	if history[1].From != models.StatusApproved || history[1].To != models.StatusSent {
+		t.Fatalf("second change %s -> %s, want the status before it", history[1].From, history[1].To)
+	}
+
+	if _, err := NewGetPurchaseOrderStatusHistoryQuery("po-2", dynamoDB, discardLogrus).Execute(context.Background()); !errors.Is(err, ErrPurchaseOrderNotFound) {
+		t.Fatalf("history of an order without events returned %v", err)
+	}
+}
@@ -0,0 +1,234 @@
+package cqrs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/google/uuid"
+
+	"medisupply/clock"
+	"orden-compra/internal/models"
+)
+
+var (
+	// ErrInvoiceNotAllowed is returned when an invoice arrives for an order that was never released
+	ErrInvoiceNotAllowed = errors.New("purchase order cannot be invoiced")
+	// ErrDuplicateInvoice is returned when an invoice number is already attached to the order
+	ErrDuplicateInvoice = errors.New("invoice is already attached to the purchase order")
+)
+
+// invoiceStatuses lists the statuses of orders released to the supplier,
+// which the supplier may invoice before or after delivery
+var invoiceStatuses = map[string]bool{
+	models.StatusPending:   true,
+	models.StatusApproved:  true,
+	models.StatusSent:      true,
+	models.StatusReceived:  true,
+	models.StatusCompleted: true,
+}
+
+// AttachInvoiceCommand attaches a supplier invoice to a purchase order and
+// matches the order's invoices against the order and its receipt
+type AttachInvoiceCommand struct {
+	PurchaseOrderID string
+	Invoice         *models.SupplierInvoice
+	Policy          models.InvoiceMatchPolicy
+	DynamoDB        dynamodbiface.DynamoDBAPI
+	Logger          *log.Logger
+	Clock           clock.Clock
+	Stats           StatsProjection
+	CorrelationID   *string
+	CausationID     *string
+}
+
+// NewAttachInvoiceCommand creates a new AttachInvoiceCommand
+func NewAttachInvoiceCommand(purchaseOrderID string, invoice *models.SupplierInvoice, policy models.InvoiceMatchPolicy, dynamoDB dynamodbiface.DynamoDBAPI, logger *log.Logger, correlationID, causationID *string) *AttachInvoiceCommand {
+	return &AttachInvoiceCommand{
+		PurchaseOrderID: purchaseOrderID,
+		Invoice:         invoice,
+		Policy:          policy,
+		DynamoDB:        dynamoDB,
+		Logger:          logger,
+		Clock:           clock.System,
+		CorrelationID:   correlationID,
+		CausationID:     causationID,
+	}
+}
+
+// Execute attaches the invoice, up to models.MaxOrderInvoices per order, and
+// records a PurchaseOrderInvoiced event with the new match. A failed match
+// also records an InvoiceMismatchDetected event, which is returned to
+// publish. A redelivered FacturaRecibida event finds its invoice already
+// attached and changes nothing; another invoice with the same number is
+// rejected with ErrDuplicateInvoice.
+func (c *AttachInvoiceCommand) Execute(ctx context.Context) (map[string]interface{}, error) {
+	c.Logger.Printf("Attaching invoice - purchase_order_id: %s, correlation_id: %v", c.PurchaseOrderID, c.CorrelationID)
+
+	if c.Invoice == nil {
+		return nil, models.ValidationErrors{{Field: "body", Message: "invoice is required"}}
+	}
+	if err := c.Invoice.Validate(); err != nil {
+		return nil, err
+	}
+
+	purchaseOrder, err := FindPurchaseOrder(ctx, c.DynamoDB, c.PurchaseOrderID, true)
+	if err != nil {
+		c.Logger.Printf("Failed to get purchase order: %v", err)
+		return nil, fmt.Errorf("failed to get purchase order: %w", err)
+	}
+
+	for _, attached := range purchaseOrder.Invoices {
+		if c.Invoice.EventID != "" && attached.EventID == c.Invoice.EventID {
+			c.Logger.Printf("Invoice already attached - purchase_order_id: %s, event_id: %s", purchaseOrder.ID, attached.EventID)
+			return map[string]interface{}{
+				"success":           true,
+				"duplicate":         true,
+				"purchase_order_id": purchaseOrder.ID,
+				"invoice":           &attached,
+				"invoice_match":     purchaseOrder.InvoiceMatch,
+				"correlation_id":    c.CorrelationID,
+			}, nil
+		}
+		if attached.InvoiceNumber == c.Invoice.InvoiceNumber {
+			return nil, fmt.Errorf("%w: %s", ErrDuplicateInvoice, attached.InvoiceNumber)
+		}
+	}
+
+	if !invoiceStatuses[purchaseOrder.Status] {
+		return nil, fmt.Errorf("%w: status is %s", ErrInvoiceNotAllowed, purchaseOrder.Status)
+	}
+	if c.Invoice.SupplierID != "" && c.Invoice.SupplierID != purchaseOrder.SupplierID {
+		return nil, models.ValidationErrors{{Field: "supplier_id", Message: fmt.Sprintf("must be the order's supplier %s", purchaseOrder.SupplierID)}}
+	}
+	if len(purchaseOrder.Invoices) >= models.MaxOrderInvoices {
+		return nil, models.ValidationErrors{{Field: "invoices", Message: fmt.Sprintf("a purchase order holds at most %d invoices", models.MaxOrderInvoices)}}
+	}
+
+	invoice := *c.Invoice
+	invoice.Lines = append([]models.InvoiceLine(nil), c.Invoice.Lines...)
+	invoice.ID = uuid.New().String()
+	invoice.SupplierID = purchaseOrder.SupplierID
+	invoice.IssuedAt = invoice.IssuedAt.UTC()
+	invoice.TotalAmount = invoice.Total()
+	invoice.ReceivedAt = c.Clock.Now().UTC()
+
+	purchaseOrder.Invoices = append(purchaseOrder.Invoices, invoice)
+	purchaseOrder.InvoiceMatch = models.MatchInvoices(purchaseOrder, c.Policy, c.Clock.Now())
+	purchaseOrder.UpdatedAt = invoice.ReceivedAt
+
+	if err := putPurchaseOrder(ctx, c.DynamoDB, c.Stats, purchaseOrder, c.Clock.Now()); err != nil {
+		c.Logger.Printf("Failed to store purchase order: %v", err)
+		return nil, fmt.Errorf("failed to store purchase order: %w", err)
+	}
+
+	if err := c.storeEventSourcingEvent(ctx, purchaseOrder, &invoice); err != nil {
+		c.Logger.Printf("Failed to store event sourcing event: %v", err)
+		return nil, fmt.Errorf("failed to store event sourcing event: %w", err)
+	}
+
+	match := purchaseOrder.InvoiceMatch
+	c.Logger.Printf("Invoice attached - purchase_order_id: %s, invoice_id: %s, invoice_number: %s, total_amount: %.2f, match_status: %s",
+		purchaseOrder.ID, invoice.ID, invoice.InvoiceNumber, invoice.TotalAmount, match.Status)
+
+	result := map[string]interface{}{
+		"success":           true,
+		"purchase_order_id": purchaseOrder.ID,
+		"invoice":           &invoice,
+		"invoice_match":     match,
+		"purchase_order":    purchaseOrder,
+		"correlation_id":    c.CorrelationID,
+	}
+
+	if match.Status == models.InvoiceMatchMismatch {
+		mismatchEvent, err := storeInvoiceMismatch(ctx, c.DynamoDB, c.Logger, purchaseOrder, c.CorrelationID, c.CausationID, c.Clock.Now())
+		if err != nil {
+			return nil, err
+		}
+		result["mismatch_event"] = mismatchEvent
+	}
+
+	return result, nil
+}
+
+// storeEventSourcingEvent stores the PurchaseOrderInvoiced event sourcing event
+func (c *AttachInvoiceCommand) storeEventSourcingEvent(ctx context.Context, purchaseOrder *models.PurchaseOrder, invoice *models.SupplierInvoice) error {
+	eventData := map[string]interface{}{
+		"purchase_order": purchaseOrder,
+		"invoice":        invoice,
+		"invoice_match":  purchaseOrder.InvoiceMatch,
+	}
+
+	event := models.NewEventSourcingEvent(
+		purchaseOrder.ID,
+		models.PurchaseOrderInvoicedEventType,
+		eventData,
+		c.CorrelationID,
+		c.CausationID,
+		c.Clock.Now(),
+	)
+	event.TenantID = purchaseOrder.TenantID
+
+	item, err := dynamodbattribute.MarshalMap(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event sourcing event: %w", err)
+	}
+
+	_, err = c.DynamoDB.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String("orden-compra-events"),
+		Item:      item,
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to put event sourcing event: %w", err)
+	}
+
+	return nil
+}
+
+// storeInvoiceMismatch records the InvoiceMismatchDetected event sourcing
+// event of an order whose invoice match failed and returns the event to
+// publish to finance
+func storeInvoiceMismatch(ctx context.Context, dynamoDB dynamodbiface.DynamoDBAPI, logger *log.Logger, purchaseOrder *models.PurchaseOrder, correlationID, causationID *string, now time.Time) (*models.InvoiceMismatchDetectedEvent, error) {
+	match := purchaseOrder.InvoiceMatch
+
+	event := models.NewEventSourcingEvent(
+		purchaseOrder.ID,
+		string(models.InvoiceMismatchDetectedEventType),
+		map[string]interface{}{
+			"purchase_order": purchaseOrder,
+			"invoice_match":  match,
+		},
+		correlationID,
+		causationID,
+		now,
+	)
+	event.TenantID = purchaseOrder.TenantID
+
+	item, err := dynamodbattribute.MarshalMap(event)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal event sourcing event: %w", err)
+	}
+	_, err = dynamoDB.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String("orden-compra-events"),
+		Item:      item,
+	})
+	if err != nil {
+		logger.Printf("Failed to store event sourcing event: %v", err)
+		return nil, fmt.Errorf("failed to put event sourcing event: %w", err)
+	}
+
+	logger.Printf("WARN: Invoices do not match purchase order - purchase_order_id: %s, invoiced_quantity: %d, ordered_quantity: %d, discrepancies: %d",
+		purchaseOrder.ID, match.InvoicedQuantity, match.OrderedQuantity, len(match.Discrepancies))
+
+	mismatchEvent := models.NewInvoiceMismatchDetectedEvent(purchaseOrder, now)
+	mismatchEvent.Metadata["correlation_id"] = correlationID
+	mismatchEvent.Metadata["causation_id"] = causationID
+	return mismatchEvent, nil
+}
@@ -0,0 +1,196 @@
+package cqrs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"medisupply/clock"
+	"orden-compra/internal/memory"
+	"orden-compra/internal/models"
+)
+
+// putPricedOrder stores po-1, 10 units priced at 2 USD, in the given status
+func putPricedOrder(t *testing.T, dynamoDB *memory.DynamoDB, status string) *models.PurchaseOrder {
+	t.Helper()
+	purchaseOrder := newStatsOrder(status)
+	purchaseOrder.Pricing = &models.OrderPricing{Currency: "USD", UnitPrice: 2, TotalAmount: 20}
+	if err := putPurchaseOrder(context.Background(), dynamoDB, StatsInCommands, purchaseOrder, statsDay); err != nil {
+		t.Fatalf("store order: %v", err)
+	}
+	return purchaseOrder
+}
+
+// newInvoice returns an invoice of quantity units at unitPrice
+func newInvoice(number string, quantity int, unitPrice float64) *models.SupplierInvoice {
+	return &models.SupplierInvoice{
+		InvoiceNumber: number,
+		Currency:      "USD",
+		IssuedAt:      statsDay,
+		Lines:         []models.InvoiceLine{{Quantity: quantity, UnitPrice: unitPrice}},
+	}
+}
+
+func attachInvoice(dynamoDB *memory.DynamoDB, fake *clock.Fake, id string, invoice *models.SupplierInvoice) (map[string]interface{}, error) {
+	command := NewAttachInvoiceCommand(id, invoice, models.DefaultInvoiceMatchPolicy(), dynamoDB, discardLogger, nil, nil)
+	command.Clock = fake
+	return command.Execute(context.Background())
+}
+
+func TestAttachInvoice(t *testing.T) {
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	fake := clock.NewFake(statsDay)
+	putPricedOrder(t, dynamoDB, models.StatusSent)
+
+	fake.Advance(time.Hour)
+	invoice := newInvoice("INV-1", 10, 2)
+	invoice.EventID = "event-1"
+	result, err := attachInvoice(dynamoDB, fake, "po-1", invoice)
+	if err != nil {
+		t.Fatalf("attach: %v", err)
+	}
+	attached := result["invoice"].(*models.SupplierInvoice)
+	if attached.ID == "" || attached.SupplierID != "supplier-1" || attached.TotalAmount != 20 || !attached.ReceivedAt.Equal(fake.Now()) {
+		t.Fatalf("attached invoice %+v", attached)
+	}
+	if match := result["invoice_match"].(*models.InvoiceMatch); match.Status != models.InvoiceMatchAwaitingReceipt {
+		t.Fatalf("match %+v, want %s before delivery", match, models.InvoiceMatchAwaitingReceipt)
+	}
+	if _, ok := result["mismatch_event"]; ok {
+		t.Fatal("matching invoice produced a mismatch event")
+	}
+
+	stored := getPurchaseOrder(t, dynamoDB, "po-1")
+	if len(stored.Invoices) != 1 || stored.Invoices[0].ID != attached.ID || stored.InvoiceMatch == nil || stored.InvoiceMatch.InvoicedAmount != 20 {
+		t.Fatalf("stored invoices %+v, match %+v", stored.Invoices, stored.InvoiceMatch)
+	}
+	if times := storedEventTimes(t, dynamoDB, models.PurchaseOrderInvoicedEventType); len(times) != 1 || !times[0].Equal(fake.Now()) {
+		t.Fatalf("invoiced events at %v", times)
+	}
+
+	// A redelivered FacturaRecibida event changes nothing
+	result, err = attachInvoice(dynamoDB, fake, "po-1", invoice)
+	if err != nil || result["duplicate"] != true {
+		t.Fatalf("redelivered invoice: %v, error %v", result, err)
+	}
+	// Another invoice with the same number is rejected
+	if _, err := attachInvoice(dynamoDB, fake, "po-1", newInvoice("INV-1", 1, 2)); !errors.Is(err, ErrDuplicateInvoice) {
+		t.Fatalf("same invoice number returned %v, want ErrDuplicateInvoice", err)
+	}
+	if stored := getPurchaseOrder(t, dynamoDB, "po-1"); len(stored.Invoices) != 1 {
+		t.Fatalf("order holds %d invoices, want 1", len(stored.Invoices))
+	}
+}
+
+func TestAttachInvoiceThatDoesNotMatch(t *testing.T) {
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	fake := clock.NewFake(statsDay)
+	putPricedOrder(t, dynamoDB, models.StatusSent)
+
+	correlationID := "correlation-1"
+	command := NewAttachInvoiceCommand("po-1", newInvoice("INV-1", 10, 2.5), models.DefaultInvoiceMatchPolicy(), dynamoDB, discardLogger, &correlationID, nil)
+	command.Clock = fake
+	result, err := command.Execute(context.Background())
+	if err != nil {
+		t.Fatalf("attach: %v", err)
+	}
+
+	event, ok := result["mismatch_event"].(*models.InvoiceMismatchDetectedEvent)
+	if !ok || event.PurchaseOrderID != "po-1" || event.Match.Status != models.InvoiceMatchMismatch || event.Match.Discrepancies[0].Kind != models.DiscrepancyUnitPrice {
+		t.Fatalf("mismatch event %+v", result["mismatch_event"])
+	}
+	if id, _ := event.Metadata["correlation_id"].(*string); id == nil || *id != "correlation-1" {
+		t.Fatalf("mismatch event metadata %v", event.Metadata)
+	}
+	if times := storedEventTimes(t, dynamoDB, string(models.InvoiceMismatchDetectedEventType)); len(times) != 1 {
+		t.Fatalf("stored %d mismatch events, want 1", len(times))
+	}
+}
+
+func TestReceiptRematchesTheInvoices(t *testing.T) {
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	fake := clock.NewFake(statsDay)
+	putPricedOrder(t, dynamoDB, models.StatusSent)
+
+	if _, err := attachInvoice(dynamoDB, fake, "po-1", newInvoice("INV-1", 10, 2)); err != nil {
+		t.Fatalf("attach: %v", err)
+	}
+
+	// Only 8 of the 10 invoiced units arrive
+	result := receiveInventory(t, dynamoDB, "event-1", 8)
+	purchaseOrder := result["purchase_order"].(*models.PurchaseOrder)
+	match := purchaseOrder.InvoiceMatch
+	if match.Status != models.InvoiceMatchMismatch || match.ReceivedQuantity == nil || *match.ReceivedQuantity != 8 || match.Discrepancies[0].Kind != models.DiscrepancyOverReceived {
+		t.Fatalf("match after the receipt %+v", match)
+	}
+	if _, ok := result["mismatch_event"].(*models.InvoiceMismatchDetectedEvent); !ok {
+		t.Fatal("short receipt of an invoiced order produced no mismatch event")
+	}
+
+	// The rest of the delivery settles the match
+	result = receiveInventory(t, dynamoDB, "event-2", 2)
+	if match := result["purchase_order"].(*models.PurchaseOrder).InvoiceMatch; match.Status != models.InvoiceMatchMatched {
+		t.Fatalf("match once every unit arrived %+v", match)
+	}
+	if _, ok := result["mismatch_event"]; ok {
+		t.Fatal("matched receipt produced a mismatch event")
+	}
+}
+
+func TestAttachInvoiceRejections(t *testing.T) {
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	fake := clock.NewFake(statsDay)
+	putPricedOrder(t, dynamoDB, models.StatusSent)
+
+	full := newStatsOrder(models.StatusSent)
+	full.ID = "po-full"
+	for i := 0; i < models.MaxOrderInvoices; i++ {
+		full.Invoices = append(full.Invoices, *newInvoice(fmt.Sprintf("INV-%d", i), 1, 2))
+	}
+	if err := putPurchaseOrder(context.Background(), dynamoDB, StatsInCommands, full, statsDay); err != nil {
+		t.Fatalf("store order: %v", err)
+	}
+	cancelled := newStatsOrder(models.StatusCancelled)
+	cancelled.ID = "po-cancelled"
+	if err := putPurchaseOrder(context.Background(), dynamoDB, StatsInCommands, cancelled, statsDay); err != nil {
+		t.Fatalf("store order: %v", err)
+	}
+
+	otherSupplier := newInvoice("INV-1", 10, 2)
+	otherSupplier.SupplierID = "supplier-2"
+
+	for _, tc := range []struct {
+		name       string
+		id         string
+		invoice    *models.SupplierInvoice
+		validation string
+		err        error
+	}{
+		{"no invoice", "po-1", nil, "body", nil},
+		{"invalid invoice", "po-1", &models.SupplierInvoice{IssuedAt: statsDay, Lines: []models.InvoiceLine{{Quantity: 1}}}, "invoice_number", nil},
+		{"unknown order", "po-unknown", newInvoice("INV-1", 10, 2), "", ErrPurchaseOrderNotFound},
+		{"cancelled order", "po-cancelled", newInvoice("INV-1", 10, 2), "", ErrInvoiceNotAllowed},
+		{"another supplier", "po-1", otherSupplier, "supplier_id", nil},
+		{"too many invoices", "po-full", newInvoice("INV-new", 1, 2), "invoices", nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := attachInvoice(dynamoDB, fake, tc.id, tc.invoice)
+			if tc.err != nil {
+				if !errors.Is(err, tc.err) {
+					t.Fatalf("error %v, want %v", err, tc.err)
+				}
+				return
+			}
+			var errs models.ValidationErrors
+			if !errors.As(err, &errs) || errs[0].Field != tc.validation {
+				t.Fatalf("error %v, want a validation error on %s", err, tc.validation)
+			}
+		})
+	}
+
+	if times := storedEventTimes(t, dynamoDB, models.PurchaseOrderInvoicedEventType); len(times) != 0 {
+		t.Fatalf("rejected invoices stored %d events", len(times))
+	}
+}
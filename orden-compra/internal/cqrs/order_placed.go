@@ -0,0 +1,83 @@
+package cqrs
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+
+	"orden-compra/internal/models"
+)
+
+// newOrderPlacedEvent creates the OrderPlacedForProduct event of an order the
+// StockBajo event placed or topped up. It returns nil when the quantity on
+// order cannot be read, since the order itself is already stored.
+func (c *ProcessStockLowCommand) newOrderPlacedEvent(ctx context.Context, purchaseOrder *models.PurchaseOrder) *models.OrderPlacedForProductEvent {
+	quantityOnOrder, err := c.quantityOnOrder(ctx, purchaseOrder)
+	if err != nil {
+		c.Logger.Printf("Failed to get quantity on order, OrderPlacedForProduct not produced - purchase_order_id: %s, error: %v", purchaseOrder.ID, err)
+		return nil
+	}
+
+	event := models.NewOrderPlacedForProductEvent(purchaseOrder, c.Event.ID, quantityOnOrder, c.Clock.Now())
+	event.Metadata["correlation_id"] = c.CorrelationID
+	event.Metadata["causation_id"] = c.CausationID
+	return event
+}
+
+// quantityOnOrder sums the quantity on order for the purchase order's tenant,
+// product and location. The order is counted as given rather than as read,
+// since the scan may not see its latest write yet.
+func (c *ProcessStockLowCommand) quantityOnOrder(ctx context.Context, purchaseOrder *models.PurchaseOrder) (int, error) {
+	expressionAttributeValues := map[string]*dynamodb.AttributeValue{
+		":product_id": {S: aws.String(purchaseOrder.ProductID)},
+		":location":   {S: aws.String(purchaseOrder.Location)},
+	}
+
+	var statusPlaceholders []string
+	for i, status := range models.OnOrderStatuses() {
+		placeholder := fmt.Sprintf(":status%d", i)
+		statusPlaceholders = append(statusPlaceholders, placeholder)
+		expressionAttributeValues[placeholder] = &dynamodb.AttributeValue{S: aws.String(status)}
+	}
+
+	scanInput := &dynamodb.ScanInput{
+		TableName:                 aws.String("orden-compra-read"),
+		FilterExpression:          aws.String(fmt.Sprintf("product_id = :product_id AND #location = :location AND #status IN (%s)", strings.Join(statusPlaceholders, ", "))),
+		ExpressionAttributeNames:  map[string]*string{"#status": aws.String("status"), "#location": aws.String("location")},
+		ExpressionAttributeValues: expressionAttributeValues,
+	}
+
+	quantity := 0
+	if purchaseOrder.IsOnOrder() {
+		quantity = purchaseOrder.Quantity
+	}
+	for {
+		result, err := c.DynamoDB.ScanWithContext(ctx, scanInput)
+		if err != nil {
+			return 0, fmt.Errorf("failed to scan: %w", err)
+		}
+
+		for _, item := range result.Items {
+			var other models.PurchaseOrder
+			if err := dynamodbattribute.UnmarshalMap(item, &other); err != nil {
+				c.Logger.Printf("Failed to unmarshal purchase order: %v", err)
+				continue
+			}
+			if other.ID == purchaseOrder.ID || other.TenantID != purchaseOrder.TenantID || !other.IsOnOrder() {
+				continue
+			}
+			quantity += other.Quantity
+		}
+
+		if len(result.LastEvaluatedKey) == 0 {
+			break
+		}
+		scanInput.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+
+	return quantity, nil
+}
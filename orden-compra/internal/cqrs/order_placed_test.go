@@ -0,0 +1,54 @@
+package cqrs
+
+import (
+	"context"
+	"testing"
+
+	"medisupply/clock"
+	"orden-compra/internal/memory"
+	"orden-compra/internal/models"
+)
+
+func TestOrderPlacedCountsTheQuantityOnOrder(t *testing.T) {
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	fake := clock.NewFake(statsDay)
+
+	// Only orders of the product and location still on their way count
+	for _, other := range []struct {
+		location, status, tenantID string
+		quantity                   int
+	}{
+		{"warehouse-1", models.StatusSent, "", 7},
+		{"warehouse-1", models.StatusReceived, "", 100},
+		{"warehouse-2", models.StatusSent, "", 100},
+		{"warehouse-1", models.StatusSent, "tenant-2", 100},
+	} {
+		purchaseOrder := models.NewPurchaseOrder("product-1", "Gloves", "supplier-2", "Other", other.location, "HIGH", other.quantity, fake.Now())
+		purchaseOrder.Status = other.status
+		purchaseOrder.TenantID = other.tenantID
+		putItem(t, dynamoDB, "orden-compra-read", purchaseOrder)
+	}
+
+	command := newStockLowCommand(dynamoDB, fake, models.PurchaseOrderPolicy{})
+	correlationID := "correlation-1"
+	command.CorrelationID = &correlationID
+	result, err := command.Execute(context.Background())
+	if err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+
+	event, ok := result["order_placed_event"].(*models.OrderPlacedForProductEvent)
+	if !ok || event == nil {
+		t.Fatalf("result %v has no OrderPlacedForProduct event", result)
+	}
+	stored := getPurchaseOrder(t, dynamoDB, event.PurchaseOrderID)
+	if event.StockLowEventID != "stock-low-1" || event.ProductID != "product-1" || event.Location != "warehouse-1" || event.Quantity != stored.Quantity || event.Status != stored.Status {
+		t.Fatalf("event %+v for order %+v", event, stored)
+	}
+	if want := stored.Quantity + 7; event.QuantityOnOrder != want {
+		t.Fatalf("quantity on order %d, want %d", event.QuantityOnOrder, want)
+	}
+	if !event.Timestamp.Equal(fake.Now()) || event.Metadata["correlation_id"] != &correlationID {
+		t.Fatalf("event at %v with metadata %v", event.Timestamp, event.Metadata)
+	}
+}
@@ -0,0 +1,90 @@
+package cqrs
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+
+	"medisupply/clock"
+	"orden-compra/internal/memory"
+	"orden-compra/internal/models"
+	"orden-compra/internal/outbox"
+)
+
+func newStockLowCommand(dynamoDB dynamodbiface.DynamoDBAPI, fake *clock.Fake, policy models.PurchaseOrderPolicy) *ProcessStockLowCommand {
+	event := &models.StockLowEvent{
+		ID:           "stock-low-1",
+		Timestamp:    fake.Now(),
+		EventType:    models.StockLowEventType,
+		ProductID:    "product-1",
+		ProductName:  "Gloves",
+		CurrentStock: 5,
+		MinimumStock: 20,
+		Location:     "warehouse-1",
+		UrgencyLevel: "high",
+		Metadata:     map[string]interface{}{"supplier_id": "supplier-1"},
+	}
+	command := NewProcessStockLowCommand(event, policy, dynamoDB, discardLogger, nil, nil)
+	command.Clock = fake
+	return command
+}
+
+func TestStockLowStoresItsEventsWithTheOrder(t *testing.T) {
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	fake := clock.NewFake(statsDay)
+
+	result, err := newStockLowCommand(dynamoDB, fake, models.PurchaseOrderPolicy{}).Execute(context.Background())
+	if err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+
+	messages, _ := result["outbox_messages"].([]*outbox.Message)
+	if len(messages) != 2 || messages[0].Type != string(models.PurchaseOrderEventType) || messages[1].Type != string(models.OrderPlacedForProductEventType) {
+		t.Fatalf("command returned outbox messages %v, want the reception and order placed events", messages)
+	}
+	var reception models.RecepcionProveedorEvent
+	if err := messages[0].Decode(&reception); err != nil {
+		t.Fatalf("decode reception event: %v", err)
+	}
+	if reception.PurchaseOrderID != result["purchase_order_id"] {
+		t.Fatalf("reception event is for order %s, want %v", reception.PurchaseOrderID, result["purchase_order_id"])
+	}
+	if stored := dynamoDB.Items(outbox.TableName); len(stored) != 2 {
+		t.Fatalf("outbox holds %d messages, want 2", len(stored))
+	}
+}
+
+func TestStockLowWithAFailedTransactionLeavesNoEvents(t *testing.T) {
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	fake := clock.NewFake(statsDay)
+
+	failing := &failingTransactions{DynamoDB: dynamoDB, failures: 1}
+	if _, err := newStockLowCommand(failing, fake, models.PurchaseOrderPolicy{}).Execute(context.Background()); err == nil {
+		t.Fatal("command succeeded through a failed transaction")
+	}
+	if orders := dynamoDB.Items("orden-compra-read"); len(orders) != 0 {
+		t.Fatalf("stored %d orders, want none", len(orders))
+	}
+	if messages := dynamoDB.Items(outbox.TableName); len(messages) != 0 {
+		t.Fatalf("outbox holds %d messages without their order", len(messages))
+	}
+}
+
+func TestStockLowAwaitingApprovalOnlyAnnouncesTheOrder(t *testing.T) {
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	fake := clock.NewFake(statsDay)
+	policy := models.PurchaseOrderPolicy{Approval: models.ApprovalPolicy{QuantityThreshold: 1}}
+
+	result, err := newStockLowCommand(dynamoDB, fake, policy).Execute(context.Background())
+	if err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	if result["requires_approval"] != true {
+		t.Fatalf("order does not require approval: %v", result)
+	}
+	messages, _ := result["outbox_messages"].([]*outbox.Message)
+	if len(messages) != 1 || messages[0].Type != string(models.OrderPlacedForProductEventType) {
+		t.Fatalf("command returned outbox messages %v, want only the order placed event", messages)
+	}
+}
@@ -0,0 +1,140 @@
+package cqrs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+
+	"medisupply/clock"
+	"orden-compra/internal/models"
+)
+
+// DetectOverduePurchaseOrdersCommand finds orders past their expected date
+// and records each one as overdue exactly once
+type DetectOverduePurchaseOrdersCommand struct {
+	DynamoDB      dynamodbiface.DynamoDBAPI
+	Logger        *log.Logger
+	Clock         clock.Clock
+	Stats         StatsProjection
+	CorrelationID *string
+	CausationID   *string
+}
+
+// NewDetectOverduePurchaseOrdersCommand creates a new DetectOverduePurchaseOrdersCommand
+func NewDetectOverduePurchaseOrdersCommand(dynamoDB dynamodbiface.DynamoDBAPI, logger *log.Logger, correlationID, causationID *string) *DetectOverduePurchaseOrdersCommand {
+	return &DetectOverduePurchaseOrdersCommand{
+		DynamoDB:      dynamoDB,
+		Logger:        logger,
+		Clock:         clock.System,
+		CorrelationID: correlationID,
+		CausationID:   causationID,
+	}
+}
+
+// Execute marks newly overdue purchase orders and returns them
+func (c *DetectOverduePurchaseOrdersCommand) Execute(ctx context.Context) (map[string]interface{}, error) {
+	now := c.Clock.Now().UTC()
+	scanInput := &dynamodb.ScanInput{
+		TableName:        aws.String("orden-compra-read"),
+		FilterExpression: aws.String("attribute_exists(expected_date) AND expected_date < :now AND attribute_not_exists(metadata.#detected)"),
+		ExpressionAttributeNames: map[string]*string{
+			"#detected": aws.String(models.MetadataOverdueDetectedAt),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":now": {S: aws.String(now.Format(time.RFC3339))},
+		},
+	}
+
+	var overdueOrders []*models.PurchaseOrder
+	for {
+		result, err := c.DynamoDB.ScanWithContext(ctx, scanInput)
+		if err != nil {
+			c.Logger.Printf("Failed to scan purchase orders: %v", err)
+			return nil, fmt.Errorf("failed to scan: %w", err)
+		}
+
+		for _, item := range result.Items {
+			var purchaseOrder models.PurchaseOrder
+			if err := dynamodbattribute.UnmarshalMap(item, &purchaseOrder); err != nil {
+				c.Logger.Printf("Failed to unmarshal purchase order: %v", err)
+				continue
+			}
+			if purchaseOrder.Metadata == nil {
+				purchaseOrder.Metadata = make(map[string]interface{})
+			}
+
+			// The scan filter compares timestamps as strings; IsOverdue is authoritative
+			if !purchaseOrder.IsOverdue(now) || purchaseOrder.Metadata[models.MetadataOverdueDetectedAt] != nil {
+				continue
+			}
+
+			if err := c.markOverdue(ctx, &purchaseOrder); err != nil {
+				c.Logger.Printf("Failed to mark purchase order %s overdue: %v", purchaseOrder.ID, err)
+				continue
+			}
+			overdueOrders = append(overdueOrders, &purchaseOrder)
+		}
+
+		if len(result.LastEvaluatedKey) == 0 {
+			break
+		}
+		scanInput.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+
+	if len(overdueOrders) > 0 {
+		c.Logger.Printf("Detected overdue purchase orders - count: %d", len(overdueOrders))
+	}
+
+	return map[string]interface{}{
+		"success":         true,
+		"purchase_orders": overdueOrders,
+		"count":           len(overdueOrders),
+	}, nil
+}
+
+// markOverdue records the detection on the order and in the event store
+func (c *DetectOverduePurchaseOrdersCommand) markOverdue(ctx context.Context, purchaseOrder *models.PurchaseOrder) error {
+	detectedAt := c.Clock.Now().UTC()
+	purchaseOrder.Metadata[models.MetadataOverdueDetectedAt] = detectedAt.Format(time.RFC3339)
+
+	if err := putPurchaseOrder(ctx, c.DynamoDB, c.Stats, purchaseOrder, c.Clock.Now()); err != nil {
+		return err
+	}
+
+	eventData := map[string]interface{}{
+		"purchase_order": purchaseOrder,
+		"expected_date":  purchaseOrder.ExpectedDate,
+		"detected_at":    detectedAt,
+	}
+
+	event := models.NewEventSourcingEvent(
+		purchaseOrder.ID,
+		models.PurchaseOrderOverdueEventType,
+		eventData,
+		c.CorrelationID,
+		c.CausationID,
+		c.Clock.Now(),
+	)
+	event.TenantID = purchaseOrder.TenantID
+
+	eventItem, err := dynamodbattribute.MarshalMap(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event sourcing event: %w", err)
+	}
+
+	_, err = c.DynamoDB.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String("orden-compra-events"),
+		Item:      eventItem,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put event sourcing event: %w", err)
+	}
+
+	return nil
+}
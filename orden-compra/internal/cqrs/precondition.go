@@ -0,0 +1,58 @@
+package cqrs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+
+	"orden-compra/internal/models"
+)
+
+// ErrPreconditionFailed is returned when a conditional command finds the
+// purchase order changed since the version the caller saw
+var ErrPreconditionFailed = errors.New("purchase order does not match the expected version")
+
+type expectedVersionKey struct{}
+
+// WithExpectedVersion returns a copy of ctx in which commands only write a
+// purchase order that is still at the given version, the one a conditional
+// request was checked against
+func WithExpectedVersion(ctx context.Context, version int) context.Context {
+	return context.WithValue(ctx, expectedVersionKey{}, version)
+}
+
+// expectedVersion returns the version ctx holds commands to, if any
+func expectedVersion(ctx context.Context) (int, bool) {
+	version, ok := ctx.Value(expectedVersionKey{}).(int)
+	return version, ok
+}
+
+// FindPurchaseOrder retrieves a purchase order from the read model,
+// returning ErrPurchaseOrderNotFound if there is none. A consistent read
+// bypasses the cache.
+func FindPurchaseOrder(ctx context.Context, dynamoDB dynamodbiface.DynamoDBAPI, purchaseOrderID string, consistent bool) (*models.PurchaseOrder, error) {
+	result, err := dynamoDB.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String("orden-compra-read"),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {S: aws.String(purchaseOrderID)},
+		},
+		ConsistentRead: aws.Bool(consistent),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get item: %w", err)
+	}
+	if result.Item == nil {
+		return nil, fmt.Errorf("%w: %s", ErrPurchaseOrderNotFound, purchaseOrderID)
+	}
+
+	var purchaseOrder models.PurchaseOrder
+	if err := dynamodbattribute.UnmarshalMap(result.Item, &purchaseOrder); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal purchase order: %w", err)
+	}
+	return &purchaseOrder, nil
+}
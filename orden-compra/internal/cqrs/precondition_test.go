@@ -0,0 +1,47 @@
+package cqrs
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"medisupply/clock"
+	"orden-compra/internal/memory"
+	"orden-compra/internal/models"
+)
+
+func TestCommandsHoldToTheExpectedVersion(t *testing.T) {
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	fake := clock.NewFake(statsDay)
+	created := createPurchaseOrder(t, dynamoDB, fake, models.StatusPending)
+	stored := getPurchaseOrder(t, dynamoDB, created.ID)
+
+	// A command checked against an older version is not written
+	update := NewUpdatePurchaseOrderStatusCommand(created.ID, models.StatusSent, dynamoDB, discardLogger, nil, nil)
+	update.Clock = fake
+	if _, err := update.Execute(WithExpectedVersion(context.Background(), stored.Version-1)); !errors.Is(err, ErrPreconditionFailed) {
+		t.Fatalf("update at another version returned %v, want ErrPreconditionFailed", err)
+	}
+	if unchanged := getPurchaseOrder(t, dynamoDB, created.ID); unchanged.Status != models.StatusPending || unchanged.Version != stored.Version {
+		t.Fatalf("order %s at version %d after a failed precondition", unchanged.Status, unchanged.Version)
+	}
+
+	if _, err := update.Execute(WithExpectedVersion(context.Background(), stored.Version)); err != nil {
+		t.Fatalf("update at the expected version: %v", err)
+	}
+	if updated := getPurchaseOrder(t, dynamoDB, created.ID); updated.Status != models.StatusSent || updated.Version != stored.Version+1 {
+		t.Fatalf("order %s at version %d, want sent at version %d", updated.Status, updated.Version, stored.Version+1)
+	}
+}
+
+func TestFindPurchaseOrder(t *testing.T) {
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	created := createPurchaseOrder(t, dynamoDB, clock.NewFake(statsDay), models.StatusPending)
+
+	if found, err := FindPurchaseOrder(context.Background(), dynamoDB, created.ID, true); err != nil || found.ID != created.ID || found.Status != models.StatusPending {
+		t.Fatalf("found %+v, error %v", found, err)
+	}
+	if _, err := FindPurchaseOrder(context.Background(), dynamoDB, "po-missing", false); !errors.Is(err, ErrPurchaseOrderNotFound) {
+		t.Fatalf("finding a missing order returned %v", err)
+	}
+}
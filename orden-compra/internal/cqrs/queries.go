@@ -3,14 +3,19 @@ package cqrs
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
 	"github.com/sirupsen/logrus"
 
+	"medisupply/clock"
+	"orden-compra/internal/cache"
 	"orden-compra/internal/models"
+	"orden-compra/internal/tenant"
 )
 
 // Query represents a query in the CQRS pattern
@@ -21,12 +26,12 @@ type Query interface {
 // GetPurchaseOrderQuery retrieves a single purchase order by ID
 type GetPurchaseOrderQuery struct {
 	PurchaseOrderID string
-	DynamoDB        *dynamodb.DynamoDB
+	DynamoDB        dynamodbiface.DynamoDBAPI
 	Logger          *logrus.Logger
 }
 
 // NewGetPurchaseOrderQuery creates a new GetPurchaseOrderQuery
-func NewGetPurchaseOrderQuery(purchaseOrderID string, dynamoDB *dynamodb.DynamoDB, logger *logrus.Logger) *GetPurchaseOrderQuery {
+func NewGetPurchaseOrderQuery(purchaseOrderID string, dynamoDB dynamodbiface.DynamoDBAPI, logger *logrus.Logger) *GetPurchaseOrderQuery {
 	return &GetPurchaseOrderQuery{
 		PurchaseOrderID: purchaseOrderID,
 		DynamoDB:        dynamoDB,
@@ -40,7 +45,7 @@ func (q *GetPurchaseOrderQuery) Execute(ctx context.Context) (map[string]interfa
 		"purchase_order_id": q.PurchaseOrderID,
 	}).Debug("Getting purchase order")
 
-	result, err := q.DynamoDB.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+	result, err := q.DynamoDB.GetItemWithContext(cache.AllowStale(ctx), &dynamodb.GetItemInput{
 		TableName: aws.String("orden-compra-read"),
 		Key: map[string]*dynamodb.AttributeValue{
 			"id": {
@@ -80,15 +85,18 @@ type ListPurchaseOrdersQuery struct {
 	SupplierID   *string
 	Status       *string
 	UrgencyLevel *string
-	StartDate    *time.Time
-	EndDate      *time.Time
-	Limit        int64
-	DynamoDB     *dynamodb.DynamoDB
-	Logger       *logrus.Logger
+	// BlanketOrderID selects the releases of a standing order
+	BlanketOrderID *string
+	StartDate      *time.Time
+	EndDate        *time.Time
+	Limit          int64
+	ScanSegments   int
+	DynamoDB       dynamodbiface.DynamoDBAPI
+	Logger         *logrus.Logger
 }
 
 // NewListPurchaseOrdersQuery creates a new ListPurchaseOrdersQuery
-func NewListPurchaseOrdersQuery(dynamoDB *dynamodb.DynamoDB, logger *logrus.Logger) *ListPurchaseOrdersQuery {
+func NewListPurchaseOrdersQuery(dynamoDB dynamodbiface.DynamoDBAPI, logger *logrus.Logger) *ListPurchaseOrdersQuery {
 	return &ListPurchaseOrdersQuery{
 		DynamoDB: dynamoDB,
 		Logger:   logger,
@@ -102,6 +110,12 @@ func (q *ListPurchaseOrdersQuery) WithProductID(productID string) *ListPurchaseO
 	return q
 }
 
+// WithScanSegments sets the number of parallel segments Each scans in
+func (q *ListPurchaseOrdersQuery) WithScanSegments(segments int) *ListPurchaseOrdersQuery {
+	q.ScanSegments = segments
+	return q
+}
+
 // WithSupplierID sets the supplier ID filter
 func (q *ListPurchaseOrdersQuery) WithSupplierID(supplierID string) *ListPurchaseOrdersQuery {
 	q.SupplierID = &supplierID
@@ -120,6 +134,12 @@ func (q *ListPurchaseOrdersQuery) WithUrgencyLevel(urgencyLevel string) *ListPur
 	return q
 }
 
+// WithBlanketOrderID sets the blanket order ID filter
+func (q *ListPurchaseOrdersQuery) WithBlanketOrderID(blanketOrderID string) *ListPurchaseOrdersQuery {
+	q.BlanketOrderID = &blanketOrderID
+	return q
+}
+
 // WithDateRange sets the date range filter
 func (q *ListPurchaseOrdersQuery) WithDateRange(startDate, endDate time.Time) *ListPurchaseOrdersQuery {
 	q.StartDate = &startDate
@@ -137,6 +157,54 @@ func (q *ListPurchaseOrdersQuery) WithLimit(limit int64) *ListPurchaseOrdersQuer
 func (q *ListPurchaseOrdersQuery) Execute(ctx context.Context) (map[string]interface{}, error) {
 	q.Logger.Debug("Listing purchase orders")
 
+	result, err := q.DynamoDB.ScanWithContext(ctx, q.scanInput())
+	if err != nil {
+		q.Logger.WithError(err).Error("Failed to scan purchase orders")
+		return nil, fmt.Errorf("failed to scan: %w", err)
+	}
+
+	var purchaseOrders []models.PurchaseOrder
+	for _, item := range result.Items {
+		var purchaseOrder models.PurchaseOrder
+		err := dynamodbattribute.UnmarshalMap(item, &purchaseOrder)
+		if err != nil {
+			q.Logger.WithError(err).Error("Failed to unmarshal purchase order")
+			continue
+		}
+		purchaseOrders = append(purchaseOrders, purchaseOrder)
+	}
+
+	return map[string]interface{}{
+		"success":         true,
+		"purchase_orders": purchaseOrders,
+		"count":           len(purchaseOrders),
+	}, nil
+}
+
+// Each calls fn for every matching purchase order, following the scan's
+// pagination until the whole table is covered, in ScanSegments parallel
+// segments and so in no particular order when there are several. Limit only bounds the items read
+// per page. Iteration stops at the first error fn returns.
+func (q *ListPurchaseOrdersQuery) Each(ctx context.Context, fn func(*models.PurchaseOrder) error) error {
+	q.Logger.Debug("Iterating purchase orders")
+
+	return scanAll(ctx, q.DynamoDB, q.ScanSegments, q.scanInput(), func(items []map[string]*dynamodb.AttributeValue) error {
+		for _, item := range items {
+			var purchaseOrder models.PurchaseOrder
+			if err := dynamodbattribute.UnmarshalMap(item, &purchaseOrder); err != nil {
+				q.Logger.WithError(err).Error("Failed to unmarshal purchase order")
+				continue
+			}
+			if err := fn(&purchaseOrder); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// scanInput builds the filtered scan of the read model
+func (q *ListPurchaseOrdersQuery) scanInput() *dynamodb.ScanInput {
 	// Build scan parameters
 	scanInput := &dynamodb.ScanInput{
 		TableName: aws.String("orden-compra-read"),
@@ -177,6 +245,13 @@ func (q *ListPurchaseOrdersQuery) Execute(ctx context.Context) (map[string]inter
 		}
 	}
 
+	if q.BlanketOrderID != nil {
+		filterExpressions = append(filterExpressions, "blanket_order_id = :blanket_order_id")
+		expressionAttributeValues[":blanket_order_id"] = &dynamodb.AttributeValue{
+			S: q.BlanketOrderID,
+		}
+	}
+
 	if q.StartDate != nil {
 		filterExpressions = append(filterExpressions, "created_at >= :start_date")
 		expressionAttributeValues[":start_date"] = &dynamodb.AttributeValue{
@@ -206,28 +281,7 @@ func (q *ListPurchaseOrdersQuery) Execute(ctx context.Context) (map[string]inter
 		scanInput.ExpressionAttributeValues = expressionAttributeValues
 	}
 
-	result, err := q.DynamoDB.ScanWithContext(ctx, scanInput)
-	if err != nil {
-		q.Logger.WithError(err).Error("Failed to scan purchase orders")
-		return nil, fmt.Errorf("failed to scan: %w", err)
-	}
-
-	var purchaseOrders []models.PurchaseOrder
-	for _, item := range result.Items {
-		var purchaseOrder models.PurchaseOrder
-		err := dynamodbattribute.UnmarshalMap(item, &purchaseOrder)
-		if err != nil {
-			q.Logger.WithError(err).Error("Failed to unmarshal purchase order")
-			continue
-		}
-		purchaseOrders = append(purchaseOrders, purchaseOrder)
-	}
-
-	return map[string]interface{}{
-		"success":         true,
-		"purchase_orders": purchaseOrders,
-		"count":           len(purchaseOrders),
-	}, nil
+	return scanInput
 }
 
 // GetPurchaseOrderEventsQuery retrieves events for a purchase order
@@ -237,12 +291,12 @@ type GetPurchaseOrderEventsQuery struct {
 	StartDate       *time.Time
 	EndDate         *time.Time
 	Limit           int64
-	DynamoDB        *dynamodb.DynamoDB
+	DynamoDB        dynamodbiface.DynamoDBAPI
 	Logger          *logrus.Logger
 }
 
 // NewGetPurchaseOrderEventsQuery creates a new GetPurchaseOrderEventsQuery
-func NewGetPurchaseOrderEventsQuery(purchaseOrderID string, dynamoDB *dynamodb.DynamoDB, logger *logrus.Logger) *GetPurchaseOrderEventsQuery {
+func NewGetPurchaseOrderEventsQuery(purchaseOrderID string, dynamoDB dynamodbiface.DynamoDBAPI, logger *logrus.Logger) *GetPurchaseOrderEventsQuery {
 	return &GetPurchaseOrderEventsQuery{
 		PurchaseOrderID: purchaseOrderID,
 		DynamoDB:        dynamoDB,
@@ -345,6 +399,10 @@ func (q *GetPurchaseOrderEventsQuery) Execute(ctx context.Context) (map[string]i
 			q.Logger.WithError(err).Error("Failed to unmarshal event")
 			continue
 		}
+		if err := models.EventSchemas.Upcast(&event); err != nil {
+			q.Logger.WithError(err).Error("Failed to upcast event")
+			continue
+		}
 		events = append(events, event)
 	}
 
@@ -358,15 +416,17 @@ func (q *GetPurchaseOrderEventsQuery) Execute(ctx context.Context) (map[string]i
 // GetOverduePurchaseOrdersQuery retrieves overdue purchase orders
 type GetOverduePurchaseOrdersQuery struct {
 	Limit    int64
-	DynamoDB *dynamodb.DynamoDB
+	DynamoDB dynamodbiface.DynamoDBAPI
 	Logger   *logrus.Logger
+	Clock    clock.Clock
 }
 
 // NewGetOverduePurchaseOrdersQuery creates a new GetOverduePurchaseOrdersQuery
-func NewGetOverduePurchaseOrdersQuery(dynamoDB *dynamodb.DynamoDB, logger *logrus.Logger) *GetOverduePurchaseOrdersQuery {
+func NewGetOverduePurchaseOrdersQuery(dynamoDB dynamodbiface.DynamoDBAPI, logger *logrus.Logger) *GetOverduePurchaseOrdersQuery {
 	return &GetOverduePurchaseOrdersQuery{
 		DynamoDB: dynamoDB,
 		Logger:   logger,
+		Clock:    clock.System,
 		Limit:    100,
 	}
 }
@@ -404,7 +464,7 @@ func (q *GetOverduePurchaseOrdersQuery) Execute(ctx context.Context) (map[string
 		}
 
 		// Check if order is overdue
-		if purchaseOrder.IsOverdue() {
+		if purchaseOrder.IsOverdue(q.Clock.Now()) {
 			overdueOrders = append(overdueOrders, purchaseOrder)
 		}
 	}
@@ -418,14 +478,15 @@ func (q *GetOverduePurchaseOrdersQuery) Execute(ctx context.Context) (map[string
 
 // GetPurchaseOrderStatsQuery retrieves purchase order statistics
 type GetPurchaseOrderStatsQuery struct {
-	StartDate *time.Time
-	EndDate   *time.Time
-	DynamoDB  *dynamodb.DynamoDB
-	Logger    *logrus.Logger
+	StartDate    *time.Time
+	EndDate      *time.Time
+	ScanSegments int
+	DynamoDB     dynamodbiface.DynamoDBAPI
+	Logger       *logrus.Logger
 }
 
 // NewGetPurchaseOrderStatsQuery creates a new GetPurchaseOrderStatsQuery
-func NewGetPurchaseOrderStatsQuery(dynamoDB *dynamodb.DynamoDB, logger *logrus.Logger) *GetPurchaseOrderStatsQuery {
+func NewGetPurchaseOrderStatsQuery(dynamoDB dynamodbiface.DynamoDBAPI, logger *logrus.Logger) *GetPurchaseOrderStatsQuery {
 	return &GetPurchaseOrderStatsQuery{
 		DynamoDB: dynamoDB,
 		Logger:   logger,
@@ -439,85 +500,73 @@ func (q *GetPurchaseOrderStatsQuery) WithDateRange(startDate, endDate time.Time)
 	return q
 }
 
-// Execute retrieves purchase order statistics
+// WithScanSegments sets the number of parallel segments the projection is
+// scanned in
+func (q *GetPurchaseOrderStatsQuery) WithScanSegments(segments int) *GetPurchaseOrderStatsQuery {
+	q.ScanSegments = segments
+	return q
+}
+
+// Execute retrieves purchase order statistics from the statistics
+// projection. Date ranges are applied per creation day.
 func (q *GetPurchaseOrderStatsQuery) Execute(ctx context.Context) (map[string]interface{}, error) {
 	q.Logger.Debug("Getting purchase order statistics")
 
-	// Get all purchase orders
 	scanInput := &dynamodb.ScanInput{
-		TableName: aws.String("orden-compra-read"),
+		TableName: aws.String(statsTable),
 	}
 
-	result, err := q.DynamoDB.ScanWithContext(ctx, scanInput)
-	if err != nil {
-		q.Logger.WithError(err).Error("Failed to scan purchase orders")
-		return nil, fmt.Errorf("failed to scan: %w", err)
-	}
-
-	stats := map[string]interface{}{
-		"total_orders":     0,
-		"pending_orders":   0,
-		"completed_orders": 0,
-		"overdue_orders":   0,
-		"by_status":        make(map[string]int),
-		"by_urgency":       make(map[string]int),
-		"by_supplier":      make(map[string]int),
-	}
-
-	for _, item := range result.Items {
-		var purchaseOrder models.PurchaseOrder
-		err := dynamodbattribute.UnmarshalMap(item, &purchaseOrder)
-		if err != nil {
-			q.Logger.WithError(err).Error("Failed to unmarshal purchase order")
-			continue
-		}
+	// Add filter expressions
+	var filterExpressions []string
+	expressionAttributeNames := make(map[string]*string)
+	expressionAttributeValues := make(map[string]*dynamodb.AttributeValue)
 
-		// Apply date filter if specified
-		if q.StartDate != nil && purchaseOrder.CreatedAt.Before(*q.StartDate) {
-			continue
+	if tenantID, ok := tenant.FromContext(ctx); ok {
+		filterExpressions = append(filterExpressions, "#tenant = :tenant")
+		expressionAttributeNames["#tenant"] = aws.String(tenant.Attribute)
+		expressionAttributeValues[":tenant"] = &dynamodb.AttributeValue{
+			S: aws.String(tenantID),
 		}
-		if q.EndDate != nil && purchaseOrder.CreatedAt.After(*q.EndDate) {
-			continue
-		}
-
-		// Update statistics
-		stats["total_orders"] = stats["total_orders"].(int) + 1
+	}
 
-		// Count by status
-		if statusCount, ok := stats["by_status"].(map[string]int)[purchaseOrder.Status]; ok {
-			stats["by_status"].(map[string]int)[purchaseOrder.Status] = statusCount + 1
-		} else {
-			stats["by_status"].(map[string]int)[purchaseOrder.Status] = 1
+	if q.StartDate != nil {
+		filterExpressions = append(filterExpressions, "#day >= :start_day")
+		expressionAttributeNames["#day"] = aws.String("day")
+		expressionAttributeValues[":start_day"] = &dynamodb.AttributeValue{
+			S: aws.String(q.StartDate.UTC().Format(models.StatsDayLayout)),
 		}
+	}
 
-		// Count by urgency
-		if urgencyCount, ok := stats["by_urgency"].(map[string]int)[purchaseOrder.UrgencyLevel]; ok {
-			stats["by_urgency"].(map[string]int)[purchaseOrder.UrgencyLevel] = urgencyCount + 1
-		} else {
-			stats["by_urgency"].(map[string]int)[purchaseOrder.UrgencyLevel] = 1
+	if q.EndDate != nil {
+		filterExpressions = append(filterExpressions, "#day <= :end_day")
+		expressionAttributeNames["#day"] = aws.String("day")
+		expressionAttributeValues[":end_day"] = &dynamodb.AttributeValue{
+			S: aws.String(q.EndDate.UTC().Format(models.StatsDayLayout)),
 		}
+	}
 
-		// Count by supplier
-		if supplierCount, ok := stats["by_supplier"].(map[string]int)[purchaseOrder.SupplierID]; ok {
-			stats["by_supplier"].(map[string]int)[purchaseOrder.SupplierID] = supplierCount + 1
-		} else {
-			stats["by_supplier"].(map[string]int)[purchaseOrder.SupplierID] = 1
-		}
+	if len(filterExpressions) > 0 {
+		scanInput.FilterExpression = aws.String(strings.Join(filterExpressions, " AND "))
+		scanInput.ExpressionAttributeNames = expressionAttributeNames
+		scanInput.ExpressionAttributeValues = expressionAttributeValues
+	}
 
-		// Count specific statuses
-		if purchaseOrder.Status == "pending" {
-			stats["pending_orders"] = stats["pending_orders"].(int) + 1
-		}
-		if purchaseOrder.IsCompleted() {
-			stats["completed_orders"] = stats["completed_orders"].(int) + 1
-		}
-		if purchaseOrder.IsOverdue() {
-			stats["overdue_orders"] = stats["overdue_orders"].(int) + 1
+	counters := make(map[string]int)
+	err := scanAll(ctx, q.DynamoDB, q.ScanSegments, scanInput, func(items []map[string]*dynamodb.AttributeValue) error {
+		for _, item := range items {
+			for counter, count := range statsCountersOf(item) {
+				counters[counter] += count
+			}
 		}
+		return nil
+	})
+	if err != nil {
+		q.Logger.WithError(err).Error("Failed to scan purchase order statistics")
+		return nil, err
 	}
 
 	return map[string]interface{}{
 		"success": true,
-		"stats":   stats,
+		"stats":   models.SummarizeStats(counters),
 	}, nil
 }
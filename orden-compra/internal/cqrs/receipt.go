@@ -0,0 +1,218 @@
+package cqrs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+
+	"medisupply/clock"
+	"orden-compra/internal/models"
+)
+
+// ErrReceiptNotAllowed is returned when inventory arrives for an order that cannot be completed
+var ErrReceiptNotAllowed = errors.New("purchase order cannot receive inventory")
+
+// receiptStatuses lists the statuses of orders whose inventory may arrive.
+// Orders still pending or approved were released without being marked sent.
+var receiptStatuses = map[string]bool{
+	models.StatusPending:  true,
+	models.StatusApproved: true,
+	models.StatusSent:     true,
+	models.StatusReceived: true,
+}
+
+// ReceiveInventoryCommand completes a purchase order from the InventarioRecibido
+// event of its reception. InvoiceMatch is the policy the order's invoices are
+// matched with again once it is received.
+type ReceiveInventoryCommand struct {
+	Event         *models.InventoryReceivedEvent
+	InvoiceMatch  models.InvoiceMatchPolicy
+	DynamoDB      dynamodbiface.DynamoDBAPI
+	Logger        *log.Logger
+	Clock         clock.Clock
+	Stats         StatsProjection
+	CorrelationID *string
+	CausationID   *string
+}
+
+// NewReceiveInventoryCommand creates a new ReceiveInventoryCommand
+func NewReceiveInventoryCommand(event *models.InventoryReceivedEvent, dynamoDB dynamodbiface.DynamoDBAPI, logger *log.Logger, correlationID, causationID *string) *ReceiveInventoryCommand {
+	return &ReceiveInventoryCommand{
+		Event:         event,
+		DynamoDB:      dynamoDB,
+		Logger:        logger,
+		Clock:         clock.System,
+		CorrelationID: correlationID,
+		CausationID:   causationID,
+	}
+}
+
+// Execute adds the delivery to the order's receipt, reconciling the quantity
+// received so far with the ordered quantity, and moves the order to
+// received. Once at least the ordered quantity arrived the order is
+// completed; until then it stays received, recording a
+// PurchaseOrderPartiallyReceived event for each delivery. The actual date is
+// when Proveedor received the goods. An order invoiced before delivery is
+// matched again, recording an InvoiceMismatchDetected event when the
+// invoices bill more than was received. A redelivered event finds its
+// delivery already counted and changes nothing.
+func (c *ReceiveInventoryCommand) Execute(ctx context.Context) (map[string]interface{}, error) {
+	c.Logger.Printf("Receiving inventory - purchase_order_id: %s, event_id: %s, correlation_id: %v", c.Event.PurchaseOrderID, c.Event.ID, c.CorrelationID)
+
+	if err := c.Event.Validate(); err != nil {
+		return nil, err
+	}
+
+	statusCommand := &UpdatePurchaseOrderStatusCommand{
+		PurchaseOrderID: c.Event.PurchaseOrderID,
+		Status:          models.StatusCompleted,
+		DynamoDB:        c.DynamoDB,
+		Logger:          c.Logger,
+		Clock:           c.Clock,
+		Stats:           c.Stats,
+		CorrelationID:   c.CorrelationID,
+		CausationID:     c.CausationID,
+	}
+
+	purchaseOrder, err := statusCommand.getPurchaseOrder(ctx)
+	if err != nil {
+		c.Logger.Printf("Failed to get purchase order: %v", err)
+		return nil, fmt.Errorf("failed to get purchase order: %w", err)
+	}
+
+	if purchaseOrder.Receipt != nil && purchaseOrder.Receipt.Counts(c.Event.ID) {
+		c.Logger.Printf("Inventory receipt already recorded - purchase_order_id: %s, event_id: %s", purchaseOrder.ID, c.Event.ID)
+		return map[string]interface{}{
+			"success":           true,
+			"duplicate":         true,
+			"purchase_order_id": purchaseOrder.ID,
+			"status":            purchaseOrder.Status,
+			"correlation_id":    c.CorrelationID,
+		}, nil
+	}
+	if !receiptStatuses[purchaseOrder.Status] {
+		return nil, fmt.Errorf("%w: status is %s", ErrReceiptNotAllowed, purchaseOrder.Status)
+	}
+
+	receipt := models.NewInventoryReceipt(c.Event, purchaseOrder.Quantity, purchaseOrder.Receipt, c.Clock.Now())
+	statuses := []string{models.StatusSent, models.StatusReceived}
+	if receipt.Complete() {
+		statuses = append(statuses, models.StatusCompleted)
+	}
+
+	previousStatus := purchaseOrder.Status
+	for _, status := range statuses {
+		if models.ValidateTransition(purchaseOrder.Status, status) != nil {
+			continue
+		}
+		if err := purchaseOrder.UpdateStatus(status, c.Clock.Now()); err != nil {
+			return nil, fmt.Errorf("failed to complete purchase order: %w", err)
+		}
+	}
+
+	actualDate := receipt.ReceivedAt
+	purchaseOrder.Receipt = receipt
+	purchaseOrder.ActualDate = &actualDate
+	delete(purchaseOrder.Metadata, models.MetadataOverdueDetectedAt)
+	if len(purchaseOrder.Invoices) > 0 {
+		purchaseOrder.InvoiceMatch = models.MatchInvoices(purchaseOrder, c.InvoiceMatch, c.Clock.Now())
+	}
+
+	if err := statusCommand.storePurchaseOrder(ctx, purchaseOrder); err != nil {
+		c.Logger.Printf("Failed to store purchase order: %v", err)
+		return nil, fmt.Errorf("failed to store purchase order: %w", err)
+	}
+
+	if err := c.storeEventSourcingEvent(ctx, purchaseOrder, previousStatus); err != nil {
+		c.Logger.Printf("Failed to store event sourcing event: %v", err)
+		return nil, fmt.Errorf("failed to store event sourcing event: %w", err)
+	}
+
+	result := map[string]interface{}{
+		"success":           true,
+		"purchase_order_id": purchaseOrder.ID,
+		"status":            purchaseOrder.Status,
+		"previous_status":   previousStatus,
+		"receipt":           receipt,
+		"purchase_order":    purchaseOrder,
+		"correlation_id":    c.CorrelationID,
+	}
+
+	if receipt.Complete() {
+		if receipt.Reconciliation == models.ReconciliationOver {
+			c.Logger.Printf("WARN: Received more than ordered - purchase_order_id: %s, ordered: %d, received: %d, variance: %d",
+				purchaseOrder.ID, receipt.OrderedQuantity, receipt.ReceivedQuantity, receipt.Variance)
+		}
+		c.Logger.Printf("Purchase order completed - purchase_order_id: %s, previous_status: %s, reconciliation: %s", purchaseOrder.ID, previousStatus, receipt.Reconciliation)
+
+		completedEvent := models.NewPurchaseOrderCompletedEvent(purchaseOrder, previousStatus, c.Clock.Now())
+		completedEvent.Metadata["correlation_id"] = c.CorrelationID
+		completedEvent.Metadata["causation_id"] = c.CausationID
+		completedEvent.Metadata["inventory_received_event_id"] = c.Event.ID
+		result["completed_event"] = completedEvent
+	} else {
+		c.Logger.Printf("WARN: Purchase order partially received - purchase_order_id: %s, ordered: %d, received: %d, outstanding: %d",
+			purchaseOrder.ID, receipt.OrderedQuantity, receipt.ReceivedQuantity, -receipt.Variance)
+	}
+
+	if purchaseOrder.InvoiceMatch != nil && purchaseOrder.InvoiceMatch.Status == models.InvoiceMatchMismatch {
+		mismatchEvent, err := storeInvoiceMismatch(ctx, c.DynamoDB, c.Logger, purchaseOrder, c.CorrelationID, c.CausationID, c.Clock.Now())
+		if err != nil {
+			return nil, err
+		}
+		result["mismatch_event"] = mismatchEvent
+	}
+
+	return result, nil
+}
+
+// storeEventSourcingEvent stores the PurchaseOrderCompleted event sourcing
+// event, or PurchaseOrderPartiallyReceived while units are outstanding
+func (c *ReceiveInventoryCommand) storeEventSourcingEvent(ctx context.Context, purchaseOrder *models.PurchaseOrder, previousStatus string) error {
+	eventData := map[string]interface{}{
+		"purchase_order": purchaseOrder,
+		"receipt":        purchaseOrder.Receipt,
+		"status_change": map[string]interface{}{
+			"old_status": previousStatus,
+			"new_status": purchaseOrder.Status,
+		},
+		"inventory_received_event_id": c.Event.ID,
+	}
+
+	eventType := models.PurchaseOrderCompletedEventType
+	if !purchaseOrder.Receipt.Complete() {
+		eventType = models.PurchaseOrderPartiallyReceivedEventType
+	}
+
+	event := models.NewEventSourcingEvent(
+		purchaseOrder.ID,
+		string(eventType),
+		eventData,
+		c.CorrelationID,
+		c.CausationID,
+		c.Clock.Now(),
+	)
+	event.TenantID = purchaseOrder.TenantID
+
+	item, err := dynamodbattribute.MarshalMap(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event sourcing event: %w", err)
+	}
+
+	_, err = c.DynamoDB.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String("orden-compra-events"),
+		Item:      item,
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to put event sourcing event: %w", err)
+	}
+
+	return nil
+}
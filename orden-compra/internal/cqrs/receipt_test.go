@@ -0,0 +1,108 @@
+package cqrs
+
+import (
+	"context"
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+
+	"orden-compra/internal/memory"
+	"orden-compra/internal/models"
+)
+
+// receiveInventory handles the InventarioRecibido event of a delivery of quantity units
+func receiveInventory(t *testing.T, dynamoDB *memory.DynamoDB, eventID string, quantity int) map[string]interface{} {
+	t.Helper()
+	event := &models.InventoryReceivedEvent{
+		ID:              eventID,
+		EventType:       models.SupplierEventType,
+		PurchaseOrderID: "po-1",
+		ProductID:       "product-1",
+		Quantity:        quantity,
+		ReceivedAt:      time.Date(2026, 3, 4, 8, 30, 0, 0, time.UTC),
+		QualityCheck:    models.QualityCheckPassed,
+	}
+	result, err := NewReceiveInventoryCommand(event, dynamoDB, log.New(io.Discard, "", 0), nil, nil).Execute(context.Background())
+	if err != nil {
+		t.Fatalf("receive %s: %v", eventID, err)
+	}
+	return result
+}
+
+// storedEventTypes returns the types of the events stored for the order
+func storedEventTypes(dynamoDB *memory.DynamoDB) []string {
+	var types []string
+	for _, item := range dynamoDB.Items("orden-compra-events") {
+		types = append(types, aws.StringValue(item["event_type"].S))
+	}
+	return types
+}
+
+func TestShortReceiptLeavesOrderReceived(t *testing.T) {
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	if err := putPurchaseOrder(context.Background(), dynamoDB, StatsInCommands, newStatsOrder(models.StatusSent), statsDay); err != nil {
+		t.Fatalf("store order: %v", err)
+	}
+
+	result := receiveInventory(t, dynamoDB, "event-1", 4)
+	purchaseOrder := result["purchase_order"].(*models.PurchaseOrder)
+	if purchaseOrder.Status != models.StatusReceived {
+		t.Fatalf("status %s after 4 of 10 units, want %s", purchaseOrder.Status, models.StatusReceived)
+	}
+	if _, ok := result["completed_event"]; ok {
+		t.Fatalf("short receipt produced a completion event")
+	}
+	receipt := purchaseOrder.Receipt
+	if receipt.ReceivedQuantity != 4 || receipt.Variance != -6 || receipt.Reconciliation != models.ReconciliationShort {
+		t.Fatalf("receipt %+v, want 4 received, variance -6, short", receipt)
+	}
+	if types := storedEventTypes(dynamoDB); len(types) != 1 || types[0] != string(models.PurchaseOrderPartiallyReceivedEventType) {
+		t.Fatalf("stored events %v, want one PurchaseOrderPartiallyReceived", types)
+	}
+
+	// A redelivery is not counted twice
+	if result := receiveInventory(t, dynamoDB, "event-1", 4); result["duplicate"] != true {
+		t.Fatalf("redelivered event counted again: %v", result)
+	}
+
+	result = receiveInventory(t, dynamoDB, "event-2", 6)
+	purchaseOrder = result["purchase_order"].(*models.PurchaseOrder)
+	if purchaseOrder.Status != models.StatusCompleted {
+		t.Fatalf("status %s once all 10 units arrived, want %s", purchaseOrder.Status, models.StatusCompleted)
+	}
+	if _, ok := result["completed_event"].(*models.PurchaseOrderCompletedEvent); !ok {
+		t.Fatalf("completing delivery produced no completion event")
+	}
+	receipt = purchaseOrder.Receipt
+	if receipt.ReceivedQuantity != 10 || receipt.DeliveredQuantity != 6 || receipt.Reconciliation != models.ReconciliationMatched {
+		t.Fatalf("receipt %+v, want 10 received of which 6 last, matched", receipt)
+	}
+}
+
+func TestFullReceiptCompletesOrder(t *testing.T) {
+	tests := []struct {
+		name           string
+		quantity       int
+		reconciliation string
+	}{
+		{name: "exact", quantity: 10, reconciliation: models.ReconciliationMatched},
+		{name: "over", quantity: 12, reconciliation: models.ReconciliationOver},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dynamoDB := memory.NewDynamoDB(memory.Tables)
+			if err := putPurchaseOrder(context.Background(), dynamoDB, StatsInCommands, newStatsOrder(models.StatusSent), statsDay); err != nil {
+				t.Fatalf("store order: %v", err)
+			}
+
+			purchaseOrder := receiveInventory(t, dynamoDB, "event-1", tt.quantity)["purchase_order"].(*models.PurchaseOrder)
+			if purchaseOrder.Status != models.StatusCompleted || purchaseOrder.Receipt.Reconciliation != tt.reconciliation {
+				t.Fatalf("status %s reconciliation %s, want completed %s", purchaseOrder.Status, purchaseOrder.Receipt.Reconciliation, tt.reconciliation)
+			}
+		})
+	}
+}
@@ -0,0 +1,324 @@
+package cqrs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+
+	"medisupply/clock"
+	"orden-compra/internal/models"
+	"orden-compra/internal/redact"
+)
+
+// orderSnapshot is the latest state of an order recorded in its events
+type orderSnapshot struct {
+	EventID       string
+	EventType     string
+	Timestamp     time.Time
+	PurchaseOrder *models.PurchaseOrder
+	Item          map[string]*dynamodb.AttributeValue
+}
+
+// newerThan reports whether the snapshot records a later state than other:
+// the higher version, or the later event when either is unversioned
+func (s *orderSnapshot) newerThan(other *orderSnapshot) bool {
+	if s.PurchaseOrder.Version > 0 && other.PurchaseOrder.Version > 0 && s.PurchaseOrder.Version != other.PurchaseOrder.Version {
+		return s.PurchaseOrder.Version > other.PurchaseOrder.Version
+	}
+	return s.Timestamp.After(other.Timestamp)
+}
+
+// ReconcileReadModelCommand replays the events stored since a point in time
+// and compares the order snapshot of each order's latest event with its read
+// model item. Orders missing from the read model or behind their events are
+// reported and, with Repair, restored from the snapshot.
+type ReconcileReadModelCommand struct {
+	Since    time.Time
+	Repair   bool
+	DynamoDB dynamodbiface.DynamoDBAPI
+	Logger   *log.Logger
+	Clock    clock.Clock
+	Stats    StatsProjection
+}
+
+// NewReconcileReadModelCommand creates a new ReconcileReadModelCommand
+func NewReconcileReadModelCommand(since time.Time, repair bool, dynamoDB dynamodbiface.DynamoDBAPI, logger *log.Logger) *ReconcileReadModelCommand {
+	return &ReconcileReadModelCommand{
+		Since:    since,
+		Repair:   repair,
+		DynamoDB: dynamoDB,
+		Logger:   logger,
+		Clock:    clock.System,
+	}
+}
+
+// Execute reconciles the orders with events since c.Since. A read model item
+// ahead of the events is not drift: it was written by a command whose event
+// is stored after the scan.
+func (c *ReconcileReadModelCommand) Execute(ctx context.Context) (map[string]interface{}, error) {
+	snapshots, events, err := c.latestSnapshots(ctx)
+	if err != nil {
+		c.Logger.Printf("Failed to replay events: %v", err)
+		return nil, err
+	}
+
+	drifts := make([]*models.ReadModelDrift, 0)
+	counts := make(map[string]int, len(models.DriftKinds()))
+	for _, kind := range models.DriftKinds() {
+		counts[kind] = 0
+	}
+	repaired := 0
+
+	for id, snapshot := range snapshots {
+		current, err := c.readItem(ctx, id)
+		if err != nil {
+			c.Logger.Printf("Failed to get purchase order %s, not reconciled: %v", id, err)
+			continue
+		}
+
+		drift, err := c.compare(snapshot, current)
+		if err != nil {
+			c.Logger.Printf("Failed to compare purchase order %s, not reconciled: %v", id, err)
+			continue
+		}
+		if drift == nil {
+			continue
+		}
+		counts[drift.Kind]++
+
+		if c.Repair {
+			if err := c.restore(ctx, snapshot, current); err != nil {
+				drift.RepairError = err.Error()
+				c.Logger.Printf("Failed to repair purchase order %s: %v", id, err)
+			} else {
+				drift.Repaired = true
+				repaired++
+			}
+		}
+
+		c.Logger.Printf("WARN: Read model drift - purchase_order_id: %s, kind: %s, read_status: %s, event_status: %s, read_version: %d, event_version: %d, repaired: %v",
+			drift.PurchaseOrderID, drift.Kind, drift.ReadStatus, drift.EventStatus, drift.ReadVersion, drift.EventVersion, drift.Repaired)
+		drifts = append(drifts, drift)
+	}
+
+	c.Logger.Printf("Read model reconciled - since: %s, events: %d, purchase_orders: %d, drift: %d, repaired: %d",
+		c.Since.Format(time.RFC3339), events, len(snapshots), len(drifts), repaired)
+
+	return map[string]interface{}{
+		"success":         true,
+		"since":           c.Since,
+		"events_replayed": events,
+		"orders_checked":  len(snapshots),
+		"drift":           drifts,
+		"drift_counts":    counts,
+		"repaired":        repaired,
+	}, nil
+}
+
+// latestSnapshots returns the latest order snapshot of every order with
+// events since c.Since, and the number of events replayed. Restored events
+// replay old history and are skipped.
+func (c *ReconcileReadModelCommand) latestSnapshots(ctx context.Context) (map[string]*orderSnapshot, int, error) {
+	scanInput := &dynamodb.ScanInput{
+		TableName:        aws.String("orden-compra-events"),
+		FilterExpression: aws.String("#timestamp > :since AND attribute_exists(event_data.purchase_order) AND attribute_not_exists(restored_at)"),
+		ExpressionAttributeNames: map[string]*string{
+			"#timestamp": aws.String("timestamp"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":since": {S: aws.String(c.Since.UTC().Format(time.RFC3339Nano))},
+		},
+	}
+
+	snapshots := make(map[string]*orderSnapshot)
+	events := 0
+	for {
+		result, err := c.DynamoDB.ScanWithContext(ctx, scanInput)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan events: %w", err)
+		}
+
+		for _, item := range result.Items {
+			snapshot, err := newOrderSnapshot(item)
+			if err != nil {
+				c.Logger.Printf("Failed to read order snapshot, skipped: %v", err)
+				continue
+			}
+			events++
+
+			id := snapshot.PurchaseOrder.ID
+			if latest, ok := snapshots[id]; !ok || snapshot.newerThan(latest) {
+				snapshots[id] = snapshot
+			}
+		}
+
+		if len(result.LastEvaluatedKey) == 0 {
+			break
+		}
+		scanInput.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+
+	return snapshots, events, nil
+}
+
+// newOrderSnapshot reads the order snapshot of an event item
+func newOrderSnapshot(item map[string]*dynamodb.AttributeValue) (*orderSnapshot, error) {
+	var event struct {
+		ID          string    `dynamodbav:"id"`
+		AggregateID string    `dynamodbav:"aggregate_id"`
+		EventType   string    `dynamodbav:"event_type"`
+		Timestamp   time.Time `dynamodbav:"timestamp"`
+	}
+	if err := dynamodbattribute.UnmarshalMap(item, &event); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal event: %w", err)
+	}
+
+	data := item["event_data"]
+	if data == nil || data.M["purchase_order"] == nil || data.M["purchase_order"].M == nil {
+		return nil, fmt.Errorf("event %s has no order snapshot", event.ID)
+	}
+	snapshotItem := data.M["purchase_order"].M
+
+	var purchaseOrder models.PurchaseOrder
+	if err := dynamodbattribute.UnmarshalMap(snapshotItem, &purchaseOrder); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal order snapshot of event %s: %w", event.ID, err)
+	}
+	if purchaseOrder.ID == "" {
+		purchaseOrder.ID = event.AggregateID
+	}
+
+	return &orderSnapshot{
+		EventID:       event.ID,
+		EventType:     event.EventType,
+		Timestamp:     event.Timestamp,
+		PurchaseOrder: &purchaseOrder,
+		Item:          snapshotItem,
+	}, nil
+}
+
+// readItem returns the read model item of an order, or nil if it is missing
+func (c *ReconcileReadModelCommand) readItem(ctx context.Context, purchaseOrderID string) (map[string]*dynamodb.AttributeValue, error) {
+	result, err := c.DynamoDB.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String("orden-compra-read"),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {S: aws.String(purchaseOrderID)},
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get item: %w", err)
+	}
+	return result.Item, nil
+}
+
+// compare returns the drift of the read model item from the snapshot, or nil
+// if the item is up to date
+func (c *ReconcileReadModelCommand) compare(snapshot *orderSnapshot, current map[string]*dynamodb.AttributeValue) (*models.ReadModelDrift, error) {
+	drift := &models.ReadModelDrift{
+		PurchaseOrderID: snapshot.PurchaseOrder.ID,
+		TenantID:        snapshot.PurchaseOrder.TenantID,
+		EventID:         snapshot.EventID,
+		EventType:       snapshot.EventType,
+		EventStatus:     snapshot.PurchaseOrder.Status,
+		EventVersion:    snapshot.PurchaseOrder.Version,
+	}
+	if current == nil {
+		drift.Kind = models.DriftMissing
+		return drift, nil
+	}
+
+	var purchaseOrder models.PurchaseOrder
+	if err := dynamodbattribute.UnmarshalMap(current, &purchaseOrder); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal purchase order: %w", err)
+	}
+	drift.ReadStatus = purchaseOrder.Status
+	drift.ReadVersion = purchaseOrder.Version
+
+	behind := false
+	if snapshot.PurchaseOrder.Version > 0 && purchaseOrder.Version > 0 {
+		behind = purchaseOrder.Version < snapshot.PurchaseOrder.Version
+	} else {
+		behind = purchaseOrder.UpdatedAt.Before(snapshot.PurchaseOrder.UpdatedAt)
+	}
+	if !behind {
+		return nil, nil
+	}
+
+	drift.Kind = models.DriftStale
+	if purchaseOrder.Status != snapshot.PurchaseOrder.Status {
+		drift.Kind = models.DriftStaleStatus
+	}
+	return drift, nil
+}
+
+// restore writes the snapshot over the read model item it was compared with,
+// keeping the snapshot's version so later runs find the item up to date.
+// Snapshot attributes masked by the event data redactor keep the item's
+// value; orders restored when missing keep the mask. The put fails if the
+// item changed since it was read, leaving the order to the next run.
+func (c *ReconcileReadModelCommand) restore(ctx context.Context, snapshot *orderSnapshot, current map[string]*dynamodb.AttributeValue) error {
+	item := make(map[string]*dynamodb.AttributeValue, len(snapshot.Item))
+	for name, value := range snapshot.Item {
+		if value.S != nil && *value.S == redact.Mask && current[name] != nil {
+			value = current[name]
+		}
+		item[name] = value
+	}
+
+	var restored models.PurchaseOrder
+	if err := dynamodbattribute.UnmarshalMap(item, &restored); err != nil {
+		return fmt.Errorf("failed to unmarshal restored purchase order %s: %w", snapshot.PurchaseOrder.ID, err)
+	}
+
+	input := &dynamodb.Put{
+		TableName:           aws.String("orden-compra-read"),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(id)"),
+	}
+	var previous *models.PurchaseOrder
+	if current != nil {
+		previous = &models.PurchaseOrder{}
+		if err := dynamodbattribute.UnmarshalMap(current, previous); err != nil {
+			return fmt.Errorf("failed to unmarshal purchase order: %w", err)
+		}
+
+		// Orders stored before they were versioned are matched on their update time
+		input.ConditionExpression = aws.String("#version = :version")
+		input.ExpressionAttributeNames = map[string]*string{"#version": aws.String("version")}
+		input.ExpressionAttributeValues = map[string]*dynamodb.AttributeValue{
+			":version": {N: aws.String(strconv.Itoa(previous.Version))},
+		}
+		if previous.Version == 0 {
+			if current["updated_at"] == nil {
+				return fmt.Errorf("purchase order %s has neither version nor update time", previous.ID)
+			}
+			input.ConditionExpression = aws.String("updated_at = :updated_at")
+			input.ExpressionAttributeNames = nil
+			input.ExpressionAttributeValues = map[string]*dynamodb.AttributeValue{
+				":updated_at": current["updated_at"],
+			}
+		}
+	}
+
+	// The event stream consumer already counted the version of the snapshot
+	// with StatsFromStream; otherwise its counts move in the same transaction
+	if err := writeWithStats(ctx, c.DynamoDB, c.Stats, input, previous, &restored, c.Clock.Now()); err != nil {
+		if errors.Is(err, errPutConditionFailed) {
+			readVersion := 0
+			if previous != nil {
+				readVersion = previous.Version
+			}
+			return &ConflictError{PurchaseOrderID: snapshot.PurchaseOrder.ID, Version: readVersion}
+		}
+		return err
+	}
+	return nil
+}
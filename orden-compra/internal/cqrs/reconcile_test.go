@@ -0,0 +1,111 @@
+package cqrs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+
+	"medisupply/clock"
+	"orden-compra/internal/memory"
+	"orden-compra/internal/models"
+)
+
+func reconcile(t *testing.T, dynamoDB *memory.DynamoDB, fake *clock.Fake, repair bool) map[string]interface{} {
+	t.Helper()
+	command := NewReconcileReadModelCommand(statsDay.Add(-time.Hour), repair, dynamoDB, discardLogger)
+	command.Clock = fake
+	result, err := command.Execute(context.Background())
+	if err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	return result
+}
+
+func TestReconcileRepairsTheReadModelFromTheEvents(t *testing.T) {
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	fake := clock.NewFake(statsDay)
+	intact := createPurchaseOrder(t, dynamoDB, fake, models.StatusPending)
+	missing := createPurchaseOrder(t, dynamoDB, fake, models.StatusPending)
+	stale := createPurchaseOrder(t, dynamoDB, fake, models.StatusPending)
+
+	// One item is lost and another misses the update of its status
+	if _, err := dynamoDB.DeleteItemWithContext(context.Background(), &dynamodb.DeleteItemInput{
+		TableName: aws.String("orden-compra-read"),
+		Key:       map[string]*dynamodb.AttributeValue{"id": {S: aws.String(missing.ID)}},
+	}); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	before := getPurchaseOrder(t, dynamoDB, stale.ID)
+	fake.Advance(time.Minute)
+	update := NewUpdatePurchaseOrderStatusCommand(stale.ID, models.StatusSent, dynamoDB, discardLogger, nil, nil)
+	update.Clock = fake
+	if _, err := update.Execute(context.Background()); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	putItem(t, dynamoDB, "orden-compra-read", before)
+
+	// Drift is only reported without repair
+	result := reconcile(t, dynamoDB, fake, false)
+	counts := result["drift_counts"].(map[string]int)
+	if result["orders_checked"] != 3 || counts[models.DriftMissing] != 1 || counts[models.DriftStaleStatus] != 1 || counts[models.DriftStale] != 0 || result["repaired"] != 0 {
+		t.Fatalf("result %v", result)
+	}
+	for _, drift := range result["drift"].([]*models.ReadModelDrift) {
+		switch drift.PurchaseOrderID {
+		case missing.ID:
+			if drift.Kind != models.DriftMissing || drift.EventStatus != models.StatusPending || drift.Repaired {
+				t.Fatalf("missing order drift %+v", drift)
+			}
+		case stale.ID:
+			if drift.Kind != models.DriftStaleStatus || drift.ReadStatus != models.StatusPending || drift.EventStatus != models.StatusSent || drift.ReadVersion != 1 || drift.EventVersion != 2 {
+				t.Fatalf("stale order drift %+v", drift)
+			}
+		default:
+			t.Fatalf("drift reported for %s", drift.PurchaseOrderID)
+		}
+	}
+	if stored := getPurchaseOrder(t, dynamoDB, stale.ID); stored.Status != models.StatusPending {
+		t.Fatalf("reporting changed the order to %s", stored.Status)
+	}
+
+	result = reconcile(t, dynamoDB, fake, true)
+	if result["repaired"] != 2 {
+		t.Fatalf("repaired %v orders, want 2", result["repaired"])
+	}
+	if stored := getPurchaseOrder(t, dynamoDB, missing.ID); stored.Status != models.StatusPending || stored.Version != 1 {
+		t.Fatalf("restored order %+v", stored)
+	}
+	if stored := getPurchaseOrder(t, dynamoDB, stale.ID); stored.Status != models.StatusSent || stored.Version != 2 {
+		t.Fatalf("repaired order %+v, want sent at version 2", stored)
+	}
+	if stored := getPurchaseOrder(t, dynamoDB, intact.ID); stored.Version != 1 {
+		t.Fatalf("intact order rewritten at version %d", stored.Version)
+	}
+
+	// Repaired orders are up to date on the next run
+	if result := reconcile(t, dynamoDB, fake, true); len(result["drift"].([]*models.ReadModelDrift)) != 0 {
+		t.Fatalf("drift %v after repairing", result["drift"])
+	}
+}
+
+func TestReconcileLeavesItemsAheadOfTheEvents(t *testing.T) {
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	fake := clock.NewFake(statsDay)
+	created := createPurchaseOrder(t, dynamoDB, fake, models.StatusPending)
+
+	// A command wrote the item but its event is not stored yet
+	ahead := getPurchaseOrder(t, dynamoDB, created.ID)
+	ahead.Status = models.StatusSent
+	ahead.Version = 2
+	putItem(t, dynamoDB, "orden-compra-read", ahead)
+
+	if result := reconcile(t, dynamoDB, fake, true); len(result["drift"].([]*models.ReadModelDrift)) != 0 {
+		t.Fatalf("drift %v for an item ahead of its events", result["drift"])
+	}
+	if stored := getPurchaseOrder(t, dynamoDB, created.ID); stored.Status != models.StatusSent {
+		t.Fatalf("order reverted to %s", stored.Status)
+	}
+}
@@ -0,0 +1,82 @@
+package cqrs
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+
+	"orden-compra/internal/models"
+)
+
+// reorderDecision is the quantity chosen for a StockBajo event and how it was chosen
+type reorderDecision struct {
+	Quantity int
+	Strategy string
+	Source   string
+}
+
+// calculateQuantity applies the product's reorder policy override, if any, or
+// the configured default strategy
+func (c *ProcessStockLowCommand) calculateQuantity(ctx context.Context) (reorderDecision, error) {
+	strategy := c.Policy.Reorder
+	if strategy == nil {
+		strategy = models.DefaultReorderStrategy()
+	}
+	source := models.ReorderSourceDefault
+
+	if c.Policy.ProductOverrides {
+		override, err := c.getReorderPolicyOverride(ctx)
+		if err != nil {
+			return reorderDecision{}, err
+		}
+		if override != nil {
+			overrideStrategy, err := override.BuildStrategy()
+			if err != nil {
+				// A broken table entry must not stop replenishment
+				c.Logger.Printf("Ignoring invalid reorder policy override for product %s: %v", c.Event.ProductID, err)
+			} else {
+				strategy = overrideStrategy
+				source = models.ReorderSourceProductOverride
+				if override.Source == models.ReorderSourceForecast {
+					source = models.ReorderSourceForecast
+				}
+			}
+		}
+	}
+
+	return reorderDecision{
+		Quantity: strategy.CalculateQuantity(c.Event),
+		Strategy: strategy.Name(),
+		Source:   source,
+	}, nil
+}
+
+// getReorderPolicyOverride retrieves the product's entry from the reorder policy table
+func (c *ProcessStockLowCommand) getReorderPolicyOverride(ctx context.Context) (*models.ReorderPolicyOverride, error) {
+	result, err := c.DynamoDB.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String("orden-compra-reorder-policies"),
+		Key: map[string]*dynamodb.AttributeValue{
+			"product_id": {
+				S: aws.String(c.Event.ProductID),
+			},
+		},
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reorder policy: %w", err)
+	}
+
+	if result.Item == nil {
+		return nil, nil
+	}
+
+	var override models.ReorderPolicyOverride
+	if err := dynamodbattribute.UnmarshalMap(result.Item, &override); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal reorder policy: %w", err)
+	}
+
+	return &override, nil
+}
@@ -0,0 +1,45 @@
+package cqrs
+
+import (
+	"context"
+	"testing"
+
+	"medisupply/clock"
+	"orden-compra/internal/memory"
+	"orden-compra/internal/models"
+)
+
+func TestStockLowQuantityFollowsTheReorderPolicy(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		policy   models.PurchaseOrderPolicy
+		override *models.ReorderPolicyOverride
+		quantity int
+		strategy string
+		source   string
+	}{
+		// The event is for 20 minimum, 5 in stock, at high urgency
+		{"default strategy", models.PurchaseOrderPolicy{}, nil, 80, models.ReorderFixedMultiplier, models.ReorderSourceDefault},
+		{"configured strategy", models.PurchaseOrderPolicy{Reorder: &models.FixedQuantityStrategy{Quantity: 30}}, nil, 30, models.ReorderFixedQuantity, models.ReorderSourceDefault},
+		{"product override", models.PurchaseOrderPolicy{ProductOverrides: true}, &models.ReorderPolicyOverride{ProductID: "product-1", Strategy: models.ReorderUpToMax, MaxStockFactor: 5}, 95, models.ReorderUpToMax, models.ReorderSourceProductOverride},
+		{"overrides disabled", models.PurchaseOrderPolicy{}, &models.ReorderPolicyOverride{ProductID: "product-1", Strategy: models.ReorderFixedQuantity, FixedQuantity: 7}, 80, models.ReorderFixedMultiplier, models.ReorderSourceDefault},
+		{"override of another product", models.PurchaseOrderPolicy{ProductOverrides: true}, &models.ReorderPolicyOverride{ProductID: "product-2", Strategy: models.ReorderFixedQuantity, FixedQuantity: 7}, 80, models.ReorderFixedMultiplier, models.ReorderSourceDefault},
+		{"invalid override is ignored", models.PurchaseOrderPolicy{ProductOverrides: true}, &models.ReorderPolicyOverride{ProductID: "product-1", Strategy: models.ReorderFixedQuantity}, 80, models.ReorderFixedMultiplier, models.ReorderSourceDefault},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dynamoDB := memory.NewDynamoDB(memory.Tables)
+			if tc.override != nil {
+				putItem(t, dynamoDB, "orden-compra-reorder-policies", tc.override)
+			}
+
+			result, err := newStockLowCommand(dynamoDB, clock.NewFake(statsDay), tc.policy).Execute(context.Background())
+			if err != nil {
+				t.Fatalf("execute: %v", err)
+			}
+			order := getPurchaseOrder(t, dynamoDB, result["purchase_order_id"].(string))
+			if order.Quantity != tc.quantity || order.Metadata[models.MetadataReorderStrategy] != tc.strategy || order.Metadata[models.MetadataReorderSource] != tc.source {
+				t.Fatalf("ordered %d with %v from %v, want %d with %s from %s", order.Quantity, order.Metadata[models.MetadataReorderStrategy], order.Metadata[models.MetadataReorderSource], tc.quantity, tc.strategy, tc.source)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package cqrs
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// scanAll reads every page of a scan split into segments read in parallel;
+// a single segment, or none, reads it in one sequential scan.
+// fn is called with the items of one page at a time, so it needs no locking
+// of its own, but pages of different segments arrive in no particular
+// order. The first error of a segment or of fn stops the others.
+func scanAll(ctx context.Context, dynamoDB dynamodbiface.DynamoDBAPI, segments int, input *dynamodb.ScanInput, fn func(items []map[string]*dynamodb.AttributeValue) error) error {
+	if segments <= 1 {
+		return scanSegment(ctx, dynamoDB, input, fn)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		firstErr error
+	)
+	merge := func(items []map[string]*dynamodb.AttributeValue) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if firstErr != nil {
+			return firstErr
+		}
+		return fn(items)
+	}
+	for segment := 0; segment < segments; segment++ {
+		segmentInput := *input
+		segmentInput.Segment = aws.Int64(int64(segment))
+		segmentInput.TotalSegments = aws.Int64(int64(segments))
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := scanSegment(ctx, dynamoDB, &segmentInput, merge); err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+					cancel()
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return firstErr
+}
+
+// scanSegment reads every page of one scan or scan segment
+func scanSegment(ctx context.Context, dynamoDB dynamodbiface.DynamoDBAPI, input *dynamodb.ScanInput, fn func(items []map[string]*dynamodb.AttributeValue) error) error {
+	scanInput := *input
+	for {
+		result, err := dynamoDB.ScanWithContext(ctx, &scanInput)
+		if err != nil {
+			return fmt.Errorf("failed to scan: %w", err)
+		}
+		if err := fn(result.Items); err != nil {
+			return err
+		}
+
+		if len(result.LastEvaluatedKey) == 0 {
+			return nil
+		}
+		scanInput.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+}
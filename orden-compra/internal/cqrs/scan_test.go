@@ -0,0 +1,85 @@
+package cqrs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+
+	"orden-compra/internal/memory"
+	"orden-compra/internal/models"
+)
+
+// putOrders stores count pending orders in the read model
+func putOrders(t *testing.T, dynamoDB *memory.DynamoDB, count int) {
+	t.Helper()
+	for i := 0; i < count; i++ {
+		purchaseOrder := newStatsOrder(models.StatusPending)
+		purchaseOrder.ID = fmt.Sprintf("po-%02d", i)
+		item, err := dynamodbattribute.MarshalMap(purchaseOrder)
+		if err != nil {
+			t.Fatalf("marshal order: %v", err)
+		}
+		if _, err := dynamoDB.PutItemWithContext(context.Background(), &dynamodb.PutItemInput{TableName: aws.String("orden-compra-read"), Item: item}); err != nil {
+			t.Fatalf("put order: %v", err)
+		}
+	}
+}
+
+func TestListPurchaseOrdersEachReadsEveryOrderOnce(t *testing.T) {
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	const orders = 25
+	putOrders(t, dynamoDB, orders)
+
+	for _, segments := range []int{0, 1, 4, 7} {
+		segments := segments
+		t.Run(fmt.Sprintf("%d segments", segments), func(t *testing.T) {
+			t.Parallel()
+			seen := make(map[string]int)
+			err := NewListPurchaseOrdersQuery(dynamoDB, discardLogrus).
+				WithLimit(3).
+				WithScanSegments(segments).
+				Each(context.Background(), func(purchaseOrder *models.PurchaseOrder) error {
+					seen[purchaseOrder.ID]++
+					return nil
+				})
+			if err != nil {
+				t.Fatalf("each: %v", err)
+			}
+			if len(seen) != orders {
+				t.Fatalf("read %d orders, want %d", len(seen), orders)
+			}
+			for id, count := range seen {
+				if count != 1 {
+					t.Fatalf("read %s %d times", id, count)
+				}
+			}
+		})
+	}
+}
+
+func TestScanAllStopsAtTheFirstError(t *testing.T) {
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	putOrders(t, dynamoDB, 10)
+
+	// Each segment stops at its first failed page, so the orders are not all read
+	stop := errors.New("stop")
+	calls := 0
+	err := NewListPurchaseOrdersQuery(dynamoDB, discardLogrus).
+		WithLimit(1).
+		WithScanSegments(4).
+		Each(context.Background(), func(*models.PurchaseOrder) error {
+			calls++
+			return stop
+		})
+	if !errors.Is(err, stop) {
+		t.Fatalf("each returned %v, want the callback's error", err)
+	}
+	if calls > 4 {
+		t.Fatalf("callback called %d times, want at most once per segment", calls)
+	}
+}
@@ -0,0 +1,180 @@
+package cqrs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/sirupsen/logrus"
+
+	"orden-compra/internal/models"
+	"orden-compra/internal/tenant"
+)
+
+// FindAggregateSnapshot returns the latest snapshot of a purchase order, or
+// nil if none has been taken
+func FindAggregateSnapshot(ctx context.Context, dynamoDB dynamodbiface.DynamoDBAPI, purchaseOrderID string) (*models.AggregateSnapshot, error) {
+	result, err := dynamoDB.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String("orden-compra-snapshots"),
+		Key: map[string]*dynamodb.AttributeValue{
+			"aggregate_id": {S: aws.String(purchaseOrderID)},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get snapshot: %w", err)
+	}
+	if result.Item == nil {
+		return nil, nil
+	}
+
+	var snapshot models.AggregateSnapshot
+	if err := dynamodbattribute.UnmarshalMap(result.Item, &snapshot); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal snapshot: %w", err)
+	}
+	return &snapshot, nil
+}
+
+// SaveAggregateSnapshot stores a snapshot unless a snapshot of a later event
+// is already stored. It reports whether the snapshot was written.
+func SaveAggregateSnapshot(ctx context.Context, dynamoDB dynamodbiface.DynamoDBAPI, snapshot *models.AggregateSnapshot) (bool, error) {
+	item, err := dynamodbattribute.MarshalMap(snapshot)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal snapshot: %w", err)
+	}
+
+	_, err = dynamoDB.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String("orden-compra-snapshots"),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(aggregate_id) OR event_timestamp < :event_timestamp"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":event_timestamp": item["event_timestamp"],
+		},
+	})
+	if err != nil {
+		var awsErr awserr.Error
+		if errors.As(err, &awsErr) && awsErr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to put snapshot: %w", err)
+	}
+	return true, nil
+}
+
+// RehydratePurchaseOrder rebuilds a purchase order from its latest snapshot
+// and the events stored after it. Every event records the whole order, so
+// the state is that of the newest one; without a snapshot every event of the
+// order is read.
+func RehydratePurchaseOrder(ctx context.Context, dynamoDB dynamodbiface.DynamoDBAPI, purchaseOrderID string) (*models.Rehydration, error) {
+	snapshot, err := FindAggregateSnapshot(ctx, dynamoDB, purchaseOrderID)
+	if err != nil {
+		return nil, err
+	}
+
+	filterExpression := "aggregate_id = :aggregate_id AND attribute_exists(event_data.purchase_order)"
+	expressionAttributeValues := map[string]*dynamodb.AttributeValue{
+		":aggregate_id": {S: aws.String(purchaseOrderID)},
+	}
+	var latest *orderSnapshot
+	if snapshot != nil {
+		filterExpression += " AND #timestamp > :since"
+		expressionAttributeValues[":since"] = &dynamodb.AttributeValue{S: aws.String(snapshot.EventTimestamp.UTC().Format(time.RFC3339Nano))}
+		latest = &orderSnapshot{
+			EventID:       snapshot.EventID,
+			Timestamp:     snapshot.EventTimestamp,
+			PurchaseOrder: snapshot.PurchaseOrder,
+		}
+	}
+	scanInput := &dynamodb.ScanInput{
+		TableName:                 aws.String("orden-compra-events"),
+		FilterExpression:          aws.String(filterExpression),
+		ExpressionAttributeValues: expressionAttributeValues,
+	}
+	if snapshot != nil {
+		scanInput.ExpressionAttributeNames = map[string]*string{"#timestamp": aws.String("timestamp")}
+	}
+
+	replayed := 0
+	for {
+		result, err := dynamoDB.ScanWithContext(ctx, scanInput)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan events: %w", err)
+		}
+
+		for _, item := range result.Items {
+			event, err := newOrderSnapshot(item)
+			if err != nil {
+				continue
+			}
+			replayed++
+
+			// The event's tenant, which is attributed to the default tenant when
+			// the event predates multi-tenancy
+			if owner := item[tenant.Attribute]; owner != nil && owner.S != nil {
+				event.PurchaseOrder.TenantID = *owner.S
+			}
+			if latest == nil || event.newerThan(latest) {
+				latest = event
+			}
+		}
+
+		if len(result.LastEvaluatedKey) == 0 {
+			break
+		}
+		scanInput.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+
+	if latest == nil {
+		return nil, fmt.Errorf("%w: %s", ErrPurchaseOrderNotFound, purchaseOrderID)
+	}
+
+	return &models.Rehydration{
+		PurchaseOrder:      latest.PurchaseOrder,
+		Snapshot:           snapshot,
+		EventsReplayed:     replayed,
+		LastEventID:        latest.EventID,
+		LastEventTimestamp: latest.Timestamp,
+	}, nil
+}
+
+// RehydratePurchaseOrderQuery returns a purchase order as rebuilt from its
+// snapshot and events rather than as stored in the read model
+type RehydratePurchaseOrderQuery struct {
+	PurchaseOrderID string
+	DynamoDB        dynamodbiface.DynamoDBAPI
+	Logger          *logrus.Logger
+}
+
+// NewRehydratePurchaseOrderQuery creates a new RehydratePurchaseOrderQuery
+func NewRehydratePurchaseOrderQuery(purchaseOrderID string, dynamoDB dynamodbiface.DynamoDBAPI, logger *logrus.Logger) *RehydratePurchaseOrderQuery {
+	return &RehydratePurchaseOrderQuery{
+		PurchaseOrderID: purchaseOrderID,
+		DynamoDB:        dynamoDB,
+		Logger:          logger,
+	}
+}
+
+// Execute executes the query
+func (q *RehydratePurchaseOrderQuery) Execute(ctx context.Context) (map[string]interface{}, error) {
+	q.Logger.WithFields(logrus.Fields{
+		"purchase_order_id": q.PurchaseOrderID,
+	}).Debug("Rehydrating purchase order")
+
+	rehydration, err := RehydratePurchaseOrder(ctx, q.DynamoDB, q.PurchaseOrderID)
+	if err != nil {
+		if !errors.Is(err, ErrPurchaseOrderNotFound) {
+			q.Logger.WithError(err).Error("Failed to rehydrate purchase order")
+		}
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"success":     true,
+		"rehydration": rehydration,
+	}, nil
+}
@@ -0,0 +1,72 @@
+package cqrs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"medisupply/clock"
+	"orden-compra/internal/memory"
+	"orden-compra/internal/models"
+)
+
+func updateStatus(t *testing.T, dynamoDB *memory.DynamoDB, fake *clock.Fake, id, status string) {
+	t.Helper()
+	fake.Advance(time.Hour)
+	update := NewUpdatePurchaseOrderStatusCommand(id, status, dynamoDB, discardLogger, nil, nil)
+	update.Clock = fake
+	if _, err := update.Execute(context.Background()); err != nil {
+		t.Fatalf("update to %s: %v", status, err)
+	}
+}
+
+func TestRehydrateFromTheLatestSnapshot(t *testing.T) {
+	ctx := context.Background()
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	fake := clock.NewFake(statsDay)
+	created := createPurchaseOrder(t, dynamoDB, fake, models.StatusPending)
+	updateStatus(t, dynamoDB, fake, created.ID, models.StatusSent)
+
+	// Without a snapshot every event is replayed
+	rehydration, err := RehydratePurchaseOrder(ctx, dynamoDB, created.ID)
+	if err != nil || rehydration.Snapshot != nil || rehydration.EventsReplayed != 2 || rehydration.PurchaseOrder.Status != models.StatusSent || rehydration.PurchaseOrder.Version != 2 {
+		t.Fatalf("rehydration %+v, error %v", rehydration, err)
+	}
+	first := rehydration.NewSnapshot(fake.Now())
+	if saved, err := SaveAggregateSnapshot(ctx, dynamoDB, first); err != nil || !saved {
+		t.Fatalf("save snapshot: %v, %v", saved, err)
+	}
+
+	// With one only the events after it are
+	updateStatus(t, dynamoDB, fake, created.ID, models.StatusReceived)
+	rehydration, err = RehydratePurchaseOrder(ctx, dynamoDB, created.ID)
+	if err != nil || rehydration.Snapshot == nil || rehydration.EventsReplayed != 1 || rehydration.PurchaseOrder.Status != models.StatusReceived || !rehydration.LastEventTimestamp.Equal(fake.Now()) {
+		t.Fatalf("rehydration %+v, error %v", rehydration, err)
+	}
+	second := rehydration.NewSnapshot(fake.Now())
+	if second.EventsApplied != 3 || second.Version != 3 {
+		t.Fatalf("snapshot %+v, want 3 events applied at version 3", second)
+	}
+	if saved, err := SaveAggregateSnapshot(ctx, dynamoDB, second); err != nil || !saved {
+		t.Fatalf("save snapshot: %v, %v", saved, err)
+	}
+
+	// An older snapshot does not replace a newer one
+	if saved, err := SaveAggregateSnapshot(ctx, dynamoDB, first); err != nil || saved {
+		t.Fatalf("saving an older snapshot returned %v, %v", saved, err)
+	}
+	if latest, err := FindAggregateSnapshot(ctx, dynamoDB, created.ID); err != nil || latest.EventID != second.EventID {
+		t.Fatalf("latest snapshot %+v, error %v", latest, err)
+	}
+
+	// Nothing after the latest snapshot leaves its state
+	rehydration, err = RehydratePurchaseOrder(ctx, dynamoDB, created.ID)
+	if err != nil || rehydration.EventsReplayed != 0 || rehydration.PurchaseOrder.Status != models.StatusReceived {
+		t.Fatalf("rehydration %+v, error %v", rehydration, err)
+	}
+
+	if _, err := RehydratePurchaseOrder(ctx, dynamoDB, "missing"); !errors.Is(err, ErrPurchaseOrderNotFound) {
+		t.Fatalf("rehydrating a missing order returned %v", err)
+	}
+}
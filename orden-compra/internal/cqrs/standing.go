@@ -0,0 +1,328 @@
+package cqrs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+
+	"medisupply/clock"
+	"orden-compra/internal/cache"
+	"orden-compra/internal/models"
+)
+
+var (
+	// ErrStandingOrderNotFound is returned when no standing order exists for an ID
+	ErrStandingOrderNotFound = errors.New("standing order not found")
+	// ErrStandingOrderConflict is returned when a standing order changed
+	// between being read and written back
+	ErrStandingOrderConflict = errors.New("standing order was modified concurrently")
+)
+
+// StandingOrderFilter narrows a standing order listing; empty fields match
+// every standing order
+type StandingOrderFilter struct {
+	ProductID  string
+	SupplierID string
+	Status     string
+}
+
+// FindStandingOrder retrieves a standing order by its blanket order ID
+func FindStandingOrder(ctx context.Context, dynamoDB dynamodbiface.DynamoDBAPI, id string) (*models.StandingOrder, error) {
+	result, err := dynamoDB.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String("orden-compra-standing-orders"),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {S: aws.String(id)},
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get standing order: %w", err)
+	}
+	if result.Item == nil {
+		return nil, fmt.Errorf("%w: %s", ErrStandingOrderNotFound, id)
+	}
+
+	var standingOrder models.StandingOrder
+	if err := dynamodbattribute.UnmarshalMap(result.Item, &standingOrder); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal standing order: %w", err)
+	}
+	return &standingOrder, nil
+}
+
+// SaveStandingOrder stores a standing order, conditional on the version it
+// was read at so concurrent changes are not lost. The version is incremented.
+func SaveStandingOrder(ctx context.Context, dynamoDB dynamodbiface.DynamoDBAPI, standingOrder *models.StandingOrder) error {
+	readVersion := standingOrder.Version
+	standingOrder.Version = readVersion + 1
+	item, err := dynamodbattribute.MarshalMap(standingOrder)
+	if err != nil {
+		standingOrder.Version = readVersion
+		return fmt.Errorf("failed to marshal standing order: %w", err)
+	}
+
+	input := &dynamodb.PutItemInput{
+		TableName:                aws.String("orden-compra-standing-orders"),
+		Item:                     item,
+		ConditionExpression:      aws.String("attribute_not_exists(#version)"),
+		ExpressionAttributeNames: map[string]*string{"#version": aws.String("version")},
+	}
+	if readVersion > 0 {
+		input.ConditionExpression = aws.String("#version = :version")
+		input.ExpressionAttributeValues = map[string]*dynamodb.AttributeValue{
+			":version": {N: aws.String(strconv.Itoa(readVersion))},
+		}
+	}
+
+	if _, err := dynamoDB.PutItemWithContext(ctx, input); err != nil {
+		standingOrder.Version = readVersion
+		var awsErr awserr.Error
+		if errors.As(err, &awsErr) && awsErr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return fmt.Errorf("%w: %s", ErrStandingOrderConflict, standingOrder.ID)
+		}
+		return fmt.Errorf("failed to put standing order: %w", err)
+	}
+	return nil
+}
+
+// ListStandingOrders returns the standing orders matching the filter
+func ListStandingOrders(ctx context.Context, dynamoDB dynamodbiface.DynamoDBAPI, filter StandingOrderFilter) ([]*models.StandingOrder, error) {
+	scanInput := &dynamodb.ScanInput{
+		TableName: aws.String("orden-compra-standing-orders"),
+	}
+
+	var filterExpression string
+	expressionAttributeNames := make(map[string]*string)
+	expressionAttributeValues := make(map[string]*dynamodb.AttributeValue)
+	and := func(expression string) {
+		if filterExpression != "" {
+			filterExpression += " AND "
+		}
+		filterExpression += expression
+	}
+	if filter.ProductID != "" {
+		and("product_id = :product_id")
+		expressionAttributeValues[":product_id"] = &dynamodb.AttributeValue{S: aws.String(filter.ProductID)}
+	}
+	if filter.SupplierID != "" {
+		and("supplier_id = :supplier_id")
+		expressionAttributeValues[":supplier_id"] = &dynamodb.AttributeValue{S: aws.String(filter.SupplierID)}
+	}
+	if filter.Status != "" {
+		and("#status = :status")
+		expressionAttributeNames["#status"] = aws.String("status")
+		expressionAttributeValues[":status"] = &dynamodb.AttributeValue{S: aws.String(filter.Status)}
+	}
+	if filterExpression != "" {
+		scanInput.FilterExpression = aws.String(filterExpression)
+		scanInput.ExpressionAttributeValues = expressionAttributeValues
+	}
+	if len(expressionAttributeNames) > 0 {
+		scanInput.ExpressionAttributeNames = expressionAttributeNames
+	}
+
+	return scanStandingOrders(ctx, dynamoDB, scanInput)
+}
+
+// ListDueStandingOrders returns the active standing orders of every tenant
+// whose next release is at or before now
+func ListDueStandingOrders(ctx context.Context, dynamoDB dynamodbiface.DynamoDBAPI, now time.Time) ([]*models.StandingOrder, error) {
+	return scanStandingOrders(ctx, dynamoDB, &dynamodb.ScanInput{
+		TableName:                aws.String("orden-compra-standing-orders"),
+		FilterExpression:         aws.String("#status = :active AND next_release_at <= :now"),
+		ExpressionAttributeNames: map[string]*string{"#status": aws.String("status")},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":active": {S: aws.String(models.StandingOrderActive)},
+			":now":    {S: aws.String(now.UTC().Format(time.RFC3339Nano))},
+		},
+	})
+}
+
+// scanStandingOrders follows a scan of the standing order table to its end
+func scanStandingOrders(ctx context.Context, dynamoDB dynamodbiface.DynamoDBAPI, scanInput *dynamodb.ScanInput) ([]*models.StandingOrder, error) {
+	standingOrders := make([]*models.StandingOrder, 0)
+	for {
+		result, err := dynamoDB.ScanWithContext(ctx, scanInput)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan standing orders: %w", err)
+		}
+
+		for _, item := range result.Items {
+			var standingOrder models.StandingOrder
+			if err := dynamodbattribute.UnmarshalMap(item, &standingOrder); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal standing order: %w", err)
+			}
+			standingOrders = append(standingOrders, &standingOrder)
+		}
+
+		if len(result.LastEvaluatedKey) == 0 {
+			break
+		}
+		scanInput.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+	return standingOrders, nil
+}
+
+// ReleaseStandingOrderCommand creates the purchase order of a standing
+// order's next release and schedules the release after it
+type ReleaseStandingOrderCommand struct {
+	StandingOrder *models.StandingOrder
+	Policy        models.PurchaseOrderPolicy
+	DynamoDB      dynamodbiface.DynamoDBAPI
+	Logger        *log.Logger
+	Clock         clock.Clock
+	Stats         StatsProjection
+	CorrelationID *string
+	CausationID   *string
+}
+
+// NewReleaseStandingOrderCommand creates a new ReleaseStandingOrderCommand
+func NewReleaseStandingOrderCommand(standingOrder *models.StandingOrder, policy models.PurchaseOrderPolicy, dynamoDB dynamodbiface.DynamoDBAPI, logger *log.Logger, correlationID, causationID *string) *ReleaseStandingOrderCommand {
+	return &ReleaseStandingOrderCommand{
+		StandingOrder: standingOrder,
+		Policy:        policy,
+		DynamoDB:      dynamoDB,
+		Logger:        logger,
+		Clock:         clock.System,
+		CorrelationID: correlationID,
+		CausationID:   causationID,
+	}
+}
+
+// Execute releases the standing order. The standing order was approved when
+// it was defined, so its releases are neither held for approval nor for
+// consolidation; they go to the supplier straight away. A release whose
+// purchase order already exists, because an earlier run stored it but not
+// the standing order, is recorded without creating the order again.
+func (c *ReleaseStandingOrderCommand) Execute(ctx context.Context) (map[string]interface{}, error) {
+	standingOrder := c.StandingOrder
+	now := c.Clock.Now().UTC()
+	if !standingOrder.Due(now) {
+		return map[string]interface{}{
+			"success":  false,
+			"released": false,
+			"reason":   "standing order is not due",
+		}, nil
+	}
+
+	release := standingOrder.Releases + 1
+	purchaseOrder := models.NewPurchaseOrder(
+		standingOrder.ProductID,
+		standingOrder.ProductName,
+		standingOrder.SupplierID,
+		standingOrder.SupplierName,
+		standingOrder.Location,
+		standingOrder.UrgencyLevel,
+		standingOrder.ReleaseQuantity(),
+		now,
+	)
+	purchaseOrder.ID = standingOrder.ReleasePurchaseOrderID(release)
+	purchaseOrder.TenantID = standingOrder.TenantID
+	purchaseOrder.BlanketOrderID = standingOrder.ID
+
+	purchaseOrder.Metadata["correlation_id"] = c.CorrelationID
+	purchaseOrder.Metadata["causation_id"] = c.CausationID
+	purchaseOrder.Metadata["standing_order_release"] = release
+
+	// Promise a delivery date from the supplier's lead time
+	supplier, err := FindSupplier(cache.AllowStale(ctx), c.DynamoDB, standingOrder.SupplierID, false)
+	if err != nil {
+		c.Logger.Printf("Failed to get supplier %s, using default lead time: %v", standingOrder.SupplierID, err)
+	}
+	leadTimePolicy := c.Policy.LeadTimes
+	if leadTimePolicy == (models.LeadTimePolicy{}) {
+		leadTimePolicy = models.DefaultLeadTimePolicy()
+	}
+	leadTimeDays, slaSource := leadTimePolicy.LeadTimeDays(supplier, purchaseOrder.ProductID, purchaseOrder.UrgencyLevel)
+	purchaseOrder.ApplySLA(purchaseOrder.CreatedAt, leadTimeDays, slaSource)
+
+	if err := applyContract(purchaseOrder, supplier, purchaseOrder.CreatedAt); err != nil {
+		c.Logger.Printf("Refusing to release standing order - standing_order_id: %s, product_id: %s: %v", standingOrder.ID, purchaseOrder.ProductID, err)
+		return nil, err
+	}
+
+	created := true
+	if err := putPurchaseOrder(ctx, c.DynamoDB, c.Stats, purchaseOrder, now); err != nil {
+		var conflict *ConflictError
+		if !errors.As(err, &conflict) || conflict.Version != 0 {
+			c.Logger.Printf("Failed to store purchase order: %v", err)
+			return nil, fmt.Errorf("failed to store purchase order: %w", err)
+		}
+		c.Logger.Printf("Standing order release already stored - standing_order_id: %s, release: %d, purchase_order_id: %s", standingOrder.ID, release, purchaseOrder.ID)
+		created = false
+	}
+
+	if created {
+		if err := c.storeEventSourcingEvent(ctx, purchaseOrder, release); err != nil {
+			c.Logger.Printf("Failed to store event sourcing event: %v", err)
+			return nil, fmt.Errorf("failed to store event sourcing event: %w", err)
+		}
+	}
+
+	standingOrder.RecordRelease(now, purchaseOrder.ID, purchaseOrder.Quantity)
+	if err := SaveStandingOrder(ctx, c.DynamoDB, standingOrder); err != nil {
+		c.Logger.Printf("Failed to store standing order %s after release %d: %v", standingOrder.ID, release, err)
+		return nil, err
+	}
+
+	c.Logger.Printf("Standing order released - standing_order_id: %s, release: %d, purchase_order_id: %s, quantity: %d, next_release_at: %v", standingOrder.ID, release, purchaseOrder.ID, purchaseOrder.Quantity, standingOrder.NextReleaseAt)
+
+	result := map[string]interface{}{
+		"success":           true,
+		"released":          true,
+		"release":           release,
+		"purchase_order_id": purchaseOrder.ID,
+		"standing_order":    standingOrder,
+		"correlation_id":    c.CorrelationID,
+	}
+	if created {
+		result["purchase_order"] = purchaseOrder
+		result["reception_event"] = newReceptionEvent(purchaseOrder, c.CorrelationID, c.CausationID, c.Clock.Now())
+	}
+	return result, nil
+}
+
+// storeEventSourcingEvent stores the PurchaseOrderCreated event of a release
+func (c *ReleaseStandingOrderCommand) storeEventSourcingEvent(ctx context.Context, purchaseOrder *models.PurchaseOrder, release int) error {
+	eventData := map[string]interface{}{
+		"purchase_order": purchaseOrder,
+		"standing_order": map[string]interface{}{
+			"id":      c.StandingOrder.ID,
+			"release": release,
+		},
+	}
+
+	event := models.NewEventSourcingEvent(
+		purchaseOrder.ID,
+		"PurchaseOrderCreated",
+		eventData,
+		c.CorrelationID,
+		c.CausationID,
+		c.Clock.Now(),
+	)
+	event.TenantID = purchaseOrder.TenantID
+
+	item, err := dynamodbattribute.MarshalMap(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event sourcing event: %w", err)
+	}
+
+	_, err = c.DynamoDB.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String("orden-compra-events"),
+		Item:      item,
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to put event sourcing event: %w", err)
+	}
+
+	return nil
+}
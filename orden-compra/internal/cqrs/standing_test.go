@@ -0,0 +1,133 @@
+package cqrs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"medisupply/clock"
+	"orden-compra/internal/memory"
+	"orden-compra/internal/models"
+)
+
+// saveStandingOrder stores a weekly standing order starting at the fake's time
+func saveStandingOrder(t *testing.T, dynamoDB *memory.DynamoDB, fake *clock.Fake) *models.StandingOrder {
+	t.Helper()
+	standingOrder := models.NewStandingOrder("product-1", "Gloves", "supplier-1", "Acme", "warehouse-1", "medium", 100, 7, fake.Now(), fake.Now())
+	standingOrder.TenantID = "tenant-1"
+	if err := SaveStandingOrder(context.Background(), dynamoDB, standingOrder); err != nil {
+		t.Fatalf("save standing order: %v", err)
+	}
+	return standingOrder
+}
+
+func releaseStandingOrder(dynamoDB *memory.DynamoDB, fake *clock.Fake, standingOrder *models.StandingOrder) (map[string]interface{}, error) {
+	command := NewReleaseStandingOrderCommand(standingOrder, models.PurchaseOrderPolicy{}, dynamoDB, discardLogger, nil, nil)
+	command.Clock = fake
+	return command.Execute(context.Background())
+}
+
+func TestReleaseStandingOrder(t *testing.T) {
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	fake := clock.NewFake(statsDay)
+	standingOrder := saveStandingOrder(t, dynamoDB, fake)
+
+	result, err := releaseStandingOrder(dynamoDB, fake, standingOrder)
+	if err != nil || result["released"] != true || result["release"] != 1 {
+		t.Fatalf("release returned %v, error %v", result, err)
+	}
+	stored := getPurchaseOrder(t, dynamoDB, result["purchase_order_id"].(string))
+	if stored.BlanketOrderID != standingOrder.ID || stored.TenantID != "tenant-1" || stored.Quantity != 100 || stored.Status != models.StatusPending || stored.ExpectedDate == nil {
+		t.Fatalf("released order %+v", stored)
+	}
+	if _, ok := result["reception_event"].(*models.RecepcionProveedorEvent); !ok {
+		t.Fatalf("release returned no reception event: %v", result)
+	}
+	if times := storedEventTimes(t, dynamoDB, "PurchaseOrderCreated"); len(times) != 1 {
+		t.Fatalf("stored %d PurchaseOrderCreated events, want 1", len(times))
+	}
+
+	saved, err := FindStandingOrder(context.Background(), dynamoDB, standingOrder.ID)
+	if err != nil || saved.Releases != 1 || saved.LastPurchaseOrderID != stored.ID || saved.Version != 2 || !saved.NextReleaseAt.Equal(statsDay.AddDate(0, 0, 7)) {
+		t.Fatalf("standing order %+v, error %v", saved, err)
+	}
+
+	// Nothing is released until the next interval
+	if result, err := releaseStandingOrder(dynamoDB, fake, saved); err != nil || result["released"] != false {
+		t.Fatalf("early release returned %v, error %v", result, err)
+	}
+}
+
+func TestRetriedReleaseDoesNotCreateTheOrderAgain(t *testing.T) {
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	fake := clock.NewFake(statsDay)
+	standingOrder := saveStandingOrder(t, dynamoDB, fake)
+	stale := *standingOrder
+	if _, err := releaseStandingOrder(dynamoDB, fake, standingOrder); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+
+	// A run that read the standing order before the release stored it loses
+	result, err := releaseStandingOrder(dynamoDB, fake, &stale)
+	if !errors.Is(err, ErrStandingOrderConflict) {
+		t.Fatalf("stale release returned %v, %v, want ErrStandingOrderConflict", result, err)
+	}
+	if orders := dynamoDB.Items("orden-compra-read"); len(orders) != 1 {
+		t.Fatalf("stored %d orders, want 1", len(orders))
+	}
+
+	// An order stored by a run that failed to save the standing order is
+	// recorded as the release without being created again
+	dynamoDB = memory.NewDynamoDB(memory.Tables)
+	standingOrder = saveStandingOrder(t, dynamoDB, fake)
+	first := *standingOrder
+	if _, err := releaseStandingOrder(dynamoDB, fake, &first); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	putItem(t, dynamoDB, "orden-compra-standing-orders", standingOrder)
+	result, err = releaseStandingOrder(dynamoDB, fake, standingOrder)
+	if err != nil || result["released"] != true || result["purchase_order"] != nil {
+		t.Fatalf("retried release returned %v, error %v", result, err)
+	}
+	if times := storedEventTimes(t, dynamoDB, "PurchaseOrderCreated"); len(times) != 1 {
+		t.Fatalf("stored %d PurchaseOrderCreated events, want 1", len(times))
+	}
+}
+
+func TestListStandingOrders(t *testing.T) {
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	fake := clock.NewFake(statsDay)
+	due := saveStandingOrder(t, dynamoDB, fake)
+	later := models.NewStandingOrder("product-2", "Masks", "supplier-2", "Globex", "warehouse-1", "low", 10, 1, statsDay.Add(time.Hour), statsDay)
+	paused := models.NewStandingOrder("product-1", "Gloves", "supplier-2", "Globex", "warehouse-1", "low", 10, 1, statsDay, statsDay)
+	paused.Status = models.StandingOrderPaused
+	for _, standingOrder := range []*models.StandingOrder{later, paused} {
+		if err := SaveStandingOrder(context.Background(), dynamoDB, standingOrder); err != nil {
+			t.Fatalf("save standing order: %v", err)
+		}
+	}
+
+	dueOrders, err := ListDueStandingOrders(context.Background(), dynamoDB, fake.Now())
+	if err != nil || len(dueOrders) != 1 || dueOrders[0].ID != due.ID {
+		t.Fatalf("due standing orders %v, error %v, want only %s", dueOrders, err, due.ID)
+	}
+
+	for _, tc := range []struct {
+		filter StandingOrderFilter
+		count  int
+	}{
+		{StandingOrderFilter{}, 3},
+		{StandingOrderFilter{ProductID: "product-1"}, 2},
+		{StandingOrderFilter{ProductID: "product-1", SupplierID: "supplier-2"}, 1},
+		{StandingOrderFilter{Status: models.StandingOrderActive}, 2},
+	} {
+		if standingOrders, err := ListStandingOrders(context.Background(), dynamoDB, tc.filter); err != nil || len(standingOrders) != tc.count {
+			t.Errorf("filter %+v listed %d standing orders, error %v, want %d", tc.filter, len(standingOrders), err, tc.count)
+		}
+	}
+
+	if _, err := FindStandingOrder(context.Background(), dynamoDB, "missing"); !errors.Is(err, ErrStandingOrderNotFound) {
+		t.Fatalf("missing standing order returned %v", err)
+	}
+}
@@ -0,0 +1,408 @@
+package cqrs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+
+	"medisupply/clock"
+	"orden-compra/internal/models"
+	"orden-compra/internal/tenant"
+)
+
+// statsTable holds the statistics projection: one item per tenant and
+// creation day whose numeric attributes are the models.Stats* counters. It
+// is not tenant-isolated because counters are updated with ADD on items that
+// may not exist yet; items carry tenant_id for the stats query to filter on.
+const statsTable = "orden-compra-stats"
+
+// statsKeyAttributes are the non-counter attributes of projection items
+var statsKeyAttributes = map[string]bool{"id": true, tenant.Attribute: true, "day": true, "updated_at": true}
+
+// statsBucket identifies the projection item an order is counted in
+type statsBucket struct {
+	TenantID string
+	Day      string
+}
+
+// id returns the projection item key of the bucket
+func (b statsBucket) id() string {
+	if b.TenantID == "" {
+		return b.Day
+	}
+	return b.TenantID + "#" + b.Day
+}
+
+// statsBucketOf returns the bucket of the order, falling back to the tenant
+// of ctx for orders stored before they carried a tenant
+func statsBucketOf(ctx context.Context, purchaseOrder *models.PurchaseOrder) statsBucket {
+	tenantID := purchaseOrder.TenantID
+	if tenantID == "" {
+		tenantID, _ = tenant.FromContext(ctx)
+	}
+	return statsBucket{TenantID: tenantID, Day: purchaseOrder.CreatedAt.UTC().Format(models.StatsDayLayout)}
+}
+
+// StatsProjection selects what moves an order's counts in the statistics
+// projection when the order is written
+type StatsProjection int
+
+const (
+	// StatsInCommands moves the counts in the transaction writing the order
+	StatsInCommands StatsProjection = iota
+	// StatsFromStream leaves the counts to ProjectStats, which the event
+	// stream consumer runs on every stored order version
+	StatsFromStream
+)
+
+// errPutConditionFailed is returned by writeWithStats when the put's
+// condition failed
+var errPutConditionFailed = errors.New("put condition failed")
+
+// putPurchaseOrder writes the order to the read model and moves its counts in
+// the statistics projection from the stored version to the new one, in one
+// transaction so the projection never misses a write. With StatsFromStream
+// the projection is left to the event stream consumer.
+//
+// The put only succeeds if the stored order still has the version the order
+// was read at, and increments purchaseOrder.Version; otherwise it returns a
+// *ConflictError. Version 0 stands for new orders and orders stored before
+// they were versioned. When ctx expects a version, an order read at another
+// version is not written and ErrPreconditionFailed is returned. The orders
+// are counted as they stand at now, and the extra items are written in the
+// same transaction.
+func putPurchaseOrder(ctx context.Context, dynamoDB dynamodbiface.DynamoDBAPI, stats StatsProjection, purchaseOrder *models.PurchaseOrder, now time.Time, extra ...*dynamodb.TransactWriteItem) error {
+	readVersion := purchaseOrder.Version
+	if expected, ok := expectedVersion(ctx); ok && readVersion != expected {
+		return fmt.Errorf("%w: %s is at version %d, expected %d", ErrPreconditionFailed, purchaseOrder.ID, readVersion, expected)
+	}
+
+	// The stored version is the one the projection counts; the put's
+	// condition fails if it changes before the transaction
+	previous, err := storedPurchaseOrder(ctx, dynamoDB, purchaseOrder.ID)
+	if err != nil {
+		return err
+	}
+	storedVersion := 0
+	if previous != nil {
+		storedVersion = previous.Version
+	}
+	if storedVersion != readVersion {
+		return &ConflictError{PurchaseOrderID: purchaseOrder.ID, Version: readVersion}
+	}
+
+	purchaseOrder.Version = readVersion + 1
+	item, err := dynamodbattribute.MarshalMap(purchaseOrder)
+	if err != nil {
+		purchaseOrder.Version = readVersion
+		return fmt.Errorf("failed to marshal purchase order: %w", err)
+	}
+
+	put := &dynamodb.Put{
+		TableName:                aws.String("orden-compra-read"),
+		Item:                     item,
+		ConditionExpression:      aws.String("attribute_not_exists(#version)"),
+		ExpressionAttributeNames: map[string]*string{"#version": aws.String("version")},
+	}
+	if readVersion > 0 {
+		put.ConditionExpression = aws.String("#version = :version")
+		put.ExpressionAttributeValues = map[string]*dynamodb.AttributeValue{
+			":version": {N: aws.String(strconv.Itoa(readVersion))},
+		}
+	}
+
+	if err := writeWithStats(ctx, dynamoDB, stats, put, previous, purchaseOrder, now, extra...); err != nil {
+		purchaseOrder.Version = readVersion
+		if errors.Is(err, errPutConditionFailed) {
+			return &ConflictError{PurchaseOrderID: purchaseOrder.ID, Version: readVersion}
+		}
+		return err
+	}
+	return nil
+}
+
+// storedPurchaseOrder reads the read model version of an order, or nil if
+// it is not stored
+func storedPurchaseOrder(ctx context.Context, dynamoDB dynamodbiface.DynamoDBAPI, purchaseOrderID string) (*models.PurchaseOrder, error) {
+	result, err := dynamoDB.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String("orden-compra-read"),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {S: aws.String(purchaseOrderID)},
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get item: %w", err)
+	}
+	if len(result.Item) == 0 {
+		return nil, nil
+	}
+
+	var purchaseOrder models.PurchaseOrder
+	if err := dynamodbattribute.UnmarshalMap(result.Item, &purchaseOrder); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal purchase order: %w", err)
+	}
+	return &purchaseOrder, nil
+}
+
+// writeWithStats puts an order version to the read model and, with
+// StatsInCommands, applies the difference between the counters of the previous
+// and current version to the statistics projection in the same transaction;
+// previous is nil for new orders. The extra items, such as outbox messages,
+// are written in the same transaction. It returns errPutConditionFailed when
+// the put's condition failed.
+func writeWithStats(ctx context.Context, dynamoDB dynamodbiface.DynamoDBAPI, stats StatsProjection, put *dynamodb.Put, previous, current *models.PurchaseOrder, now time.Time, extra ...*dynamodb.TransactWriteItem) error {
+	items := []*dynamodb.TransactWriteItem{{Put: put}}
+	if stats == StatsInCommands {
+		for bucket, counters := range statsDeltas(ctx, previous, current, now) {
+			if update := statsUpdate(bucket, counters, now); update != nil {
+				items = append(items, &dynamodb.TransactWriteItem{Update: update})
+			}
+		}
+	}
+	items = append(items, extra...)
+
+	_, err := dynamoDB.TransactWriteItemsWithContext(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: items})
+	if err != nil {
+		var cancelled *dynamodb.TransactionCanceledException
+		if errors.As(err, &cancelled) && len(cancelled.CancellationReasons) > 0 &&
+			aws.StringValue(cancelled.CancellationReasons[0].Code) == "ConditionalCheckFailed" {
+			return errPutConditionFailed
+		}
+		return fmt.Errorf("failed to write purchase order %s: %w", current.ID, err)
+	}
+	return nil
+}
+
+// statsDeltas returns the difference between the counters of the previous
+// and current version of an order at now by bucket; previous is nil for new
+// orders
+func statsDeltas(ctx context.Context, previous, current *models.PurchaseOrder, now time.Time) map[statsBucket]map[string]int {
+	deltas := make(map[statsBucket]map[string]int, 2)
+	add := func(purchaseOrder *models.PurchaseOrder, sign int) {
+		bucket := statsBucketOf(ctx, purchaseOrder)
+		if deltas[bucket] == nil {
+			deltas[bucket] = make(map[string]int)
+		}
+		for counter, count := range purchaseOrder.StatsCounters(now) {
+			deltas[bucket][counter] += sign * count
+		}
+	}
+	if previous != nil {
+		add(previous, -1)
+	}
+	add(current, 1)
+	return deltas
+}
+
+// statsUpdate returns the update adding the non-zero deltas to the bucket's
+// counters at now, creating the bucket on first use, or nil when all are
+// zero
+func statsUpdate(bucket statsBucket, counters map[string]int, now time.Time) *dynamodb.Update {
+	names := map[string]*string{"#day": aws.String("day")}
+	values := map[string]*dynamodb.AttributeValue{
+		":day":        {S: aws.String(bucket.Day)},
+		":updated_at": {S: aws.String(now.UTC().Format(time.RFC3339))},
+	}
+
+	add := ""
+	for counter, delta := range counters {
+		if delta == 0 {
+			continue
+		}
+		i := strconv.Itoa(len(names))
+		names["#c"+i] = aws.String(counter)
+		values[":c"+i] = &dynamodb.AttributeValue{N: aws.String(strconv.Itoa(delta))}
+		if add != "" {
+			add += ", "
+		}
+		add += "#c" + i + " :c" + i
+	}
+	if add == "" {
+		return nil
+	}
+
+	set := "SET #day = :day, updated_at = :updated_at"
+	if bucket.TenantID != "" {
+		names["#tenant"] = aws.String(tenant.Attribute)
+		values[":tenant"] = &dynamodb.AttributeValue{S: aws.String(bucket.TenantID)}
+		set += ", #tenant = :tenant"
+	}
+
+	return &dynamodb.Update{
+		TableName: aws.String(statsTable),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {S: aws.String(bucket.id())},
+		},
+		UpdateExpression:          aws.String(set + " ADD " + add),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+	}
+}
+
+// statsCountersOf returns the counters of a projection item
+func statsCountersOf(item map[string]*dynamodb.AttributeValue) map[string]int {
+	counters := make(map[string]int, len(item))
+	for name, value := range item {
+		if statsKeyAttributes[name] || value.N == nil {
+			continue
+		}
+		count, err := strconv.Atoi(aws.StringValue(value.N))
+		if err != nil {
+			continue
+		}
+		counters[name] = count
+	}
+	return counters
+}
+
+// RecomputeStatsCommand rebuilds the statistics projection from the read
+// model, repairing counts that drifted from it. In a tenant
+// context only that tenant's buckets are rebuilt.
+type RecomputeStatsCommand struct {
+	ScanSegments int
+	DynamoDB     dynamodbiface.DynamoDBAPI
+	Logger       *log.Logger
+	Clock        clock.Clock
+	Stats        StatsProjection
+}
+
+// NewRecomputeStatsCommand creates a new RecomputeStatsCommand
+func NewRecomputeStatsCommand(dynamoDB dynamodbiface.DynamoDBAPI, logger *log.Logger) *RecomputeStatsCommand {
+	return &RecomputeStatsCommand{
+		DynamoDB: dynamoDB,
+		Logger:   logger,
+		Clock:    clock.System,
+	}
+}
+
+// Execute counts every purchase order, overwrites the buckets and deletes
+// buckets no order is counted in anymore
+func (c *RecomputeStatsCommand) Execute(ctx context.Context) (map[string]interface{}, error) {
+	now := c.Clock.Now().UTC()
+	buckets := make(map[statsBucket]map[string]int)
+	orders := 0
+
+	scanInput := &dynamodb.ScanInput{TableName: aws.String("orden-compra-read")}
+	err := scanAll(ctx, c.DynamoDB, c.ScanSegments, scanInput, func(items []map[string]*dynamodb.AttributeValue) error {
+		for _, item := range items {
+			var purchaseOrder models.PurchaseOrder
+			if err := dynamodbattribute.UnmarshalMap(item, &purchaseOrder); err != nil {
+				c.Logger.Printf("Failed to unmarshal purchase order: %v", err)
+				continue
+			}
+
+			bucket := statsBucketOf(ctx, &purchaseOrder)
+			if buckets[bucket] == nil {
+				buckets[bucket] = make(map[string]int)
+			}
+			for counter, count := range purchaseOrder.StatsCounters(now) {
+				buckets[bucket][counter] += count
+			}
+			orders++
+
+			// The stream consumer applies later versions on top of the recount
+			if c.Stats == StatsFromStream && purchaseOrder.Version > 0 {
+				if err := putStatsContribution(ctx, c.DynamoDB, &purchaseOrder, now); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.Logger.Printf("Failed to count purchase orders: %v", err)
+		return nil, err
+	}
+
+	current := make(map[string]bool, len(buckets))
+	for bucket, counters := range buckets {
+		item := map[string]*dynamodb.AttributeValue{
+			"id":         {S: aws.String(bucket.id())},
+			"day":        {S: aws.String(bucket.Day)},
+			"updated_at": {S: aws.String(now.Format(time.RFC3339))},
+		}
+		if bucket.TenantID != "" {
+			item[tenant.Attribute] = &dynamodb.AttributeValue{S: aws.String(bucket.TenantID)}
+		}
+		for counter, count := range counters {
+			item[counter] = &dynamodb.AttributeValue{N: aws.String(strconv.Itoa(count))}
+		}
+
+		if _, err := c.DynamoDB.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+			TableName: aws.String(statsTable),
+			Item:      item,
+		}); err != nil {
+			return nil, fmt.Errorf("failed to put statistics bucket %s: %w", bucket.id(), err)
+		}
+		current[bucket.id()] = true
+	}
+
+	removed, err := c.removeStaleBuckets(ctx, current)
+	if err != nil {
+		return nil, err
+	}
+
+	c.Logger.Printf("Statistics recomputed - orders: %d, buckets: %d, removed: %d", orders, len(buckets), removed)
+
+	return map[string]interface{}{
+		"success": true,
+		"orders":  orders,
+		"buckets": len(buckets),
+		"removed": removed,
+	}, nil
+}
+
+// removeStaleBuckets deletes the buckets in scope that are not current
+func (c *RecomputeStatsCommand) removeStaleBuckets(ctx context.Context, current map[string]bool) (int, error) {
+	scanInput := &dynamodb.ScanInput{
+		TableName:            aws.String(statsTable),
+		ProjectionExpression: aws.String("id"),
+	}
+	if tenantID, ok := tenant.FromContext(ctx); ok {
+		scanInput.FilterExpression = aws.String("#tenant = :tenant")
+		scanInput.ExpressionAttributeNames = map[string]*string{"#tenant": aws.String(tenant.Attribute)}
+		scanInput.ExpressionAttributeValues = map[string]*dynamodb.AttributeValue{
+			":tenant": {S: aws.String(tenantID)},
+		}
+		scanInput.ProjectionExpression = aws.String("id, #tenant")
+	}
+
+	removed := 0
+	for {
+		result, err := c.DynamoDB.ScanWithContext(ctx, scanInput)
+		if err != nil {
+			return removed, fmt.Errorf("failed to scan statistics: %w", err)
+		}
+
+		for _, item := range result.Items {
+			id := aws.StringValue(item["id"].S)
+			if current[id] {
+				continue
+			}
+			if _, err := c.DynamoDB.DeleteItemWithContext(ctx, &dynamodb.DeleteItemInput{
+				TableName: aws.String(statsTable),
+				Key: map[string]*dynamodb.AttributeValue{
+					"id": {S: aws.String(id)},
+				},
+			}); err != nil {
+				return removed, fmt.Errorf("failed to delete statistics bucket %s: %w", id, err)
+			}
+			removed++
+		}
+
+		if len(result.LastEvaluatedKey) == 0 {
+			break
+		}
+		scanInput.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+	return removed, nil
+}
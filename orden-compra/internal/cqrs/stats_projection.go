@@ -0,0 +1,189 @@
+package cqrs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+
+	"orden-compra/internal/models"
+)
+
+// statsContributionsTable records what each order is counted as in the
+// statistics projection when the projection is driven by the event stream
+const statsContributionsTable = "orden-compra-stats-contributions"
+
+// statsContribution is the version of an order the projection counts, and
+// the bucket and counters it is counted with
+type statsContribution struct {
+	ID        string         `dynamodbav:"id"`
+	TenantID  string         `dynamodbav:"tenant_id,omitempty"`
+	Day       string         `dynamodbav:"day"`
+	Version   int            `dynamodbav:"version"`
+	Counters  map[string]int `dynamodbav:"counters"`
+	UpdatedAt time.Time      `dynamodbav:"updated_at"`
+}
+
+// newStatsContribution returns the contribution of an order version at now
+func newStatsContribution(ctx context.Context, purchaseOrder *models.PurchaseOrder, now time.Time) *statsContribution {
+	bucket := statsBucketOf(ctx, purchaseOrder)
+	return &statsContribution{
+		ID:        purchaseOrder.ID,
+		TenantID:  bucket.TenantID,
+		Day:       bucket.Day,
+		Version:   purchaseOrder.Version,
+		Counters:  purchaseOrder.StatsCounters(now),
+		UpdatedAt: now.UTC(),
+	}
+}
+
+// ProjectStats moves the counts of the orders recorded in the events from
+// the version the projection counts to the one recorded, for commands run
+// with StatsFromStream. Stream records of one order may arrive in any order
+// and more than once, so a version is only applied when it is newer than the
+// counted one. The orders are counted as they stand at now.
+func ProjectStats(ctx context.Context, dynamoDB dynamodbiface.DynamoDBAPI, events []*models.EventSourcingEvent, now time.Time) error {
+	for _, event := range events {
+		data, ok := event.EventData["purchase_order"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		item, err := dynamodbattribute.MarshalMap(data)
+		if err != nil {
+			return fmt.Errorf("failed to marshal order snapshot of event %s: %w", event.ID, err)
+		}
+		var purchaseOrder models.PurchaseOrder
+		if err := dynamodbattribute.UnmarshalMap(item, &purchaseOrder); err != nil {
+			return fmt.Errorf("failed to unmarshal order snapshot of event %s: %w", event.ID, err)
+		}
+		// Orders stored before they were versioned cannot be ordered; the
+		// projection catches up with them on the next recount
+		if purchaseOrder.ID == "" || purchaseOrder.Version == 0 {
+			continue
+		}
+		if purchaseOrder.TenantID == "" {
+			purchaseOrder.TenantID = event.TenantID
+		}
+
+		if err := projectOrderStats(ctx, dynamoDB, &purchaseOrder, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// maxProjectAttempts bounds how often an order version is applied again
+// when another consumer moved the order's contribution in between
+const maxProjectAttempts = 5
+
+// projectOrderStats counts an order version in place of the version counted
+// so far, unless a newer version is already counted. The contribution and
+// the buckets are written in one transaction conditioned on the contribution
+// read, so a retried or concurrent record never moves the counts twice.
+func projectOrderStats(ctx context.Context, dynamoDB dynamodbiface.DynamoDBAPI, purchaseOrder *models.PurchaseOrder, now time.Time) error {
+	contribution := newStatsContribution(ctx, purchaseOrder, now)
+	item, err := dynamodbattribute.MarshalMap(contribution)
+	if err != nil {
+		return fmt.Errorf("failed to marshal statistics contribution: %w", err)
+	}
+
+	for attempt := 1; ; attempt++ {
+		previous, err := storedStatsContribution(ctx, dynamoDB, purchaseOrder.ID)
+		if err != nil {
+			return err
+		}
+		if previous != nil && previous.Version >= contribution.Version {
+			return nil
+		}
+
+		put := &dynamodb.Put{
+			TableName:           aws.String(statsContributionsTable),
+			Item:                item,
+			ConditionExpression: aws.String("attribute_not_exists(id)"),
+		}
+		deltas := map[statsBucket]map[string]int{
+			{TenantID: contribution.TenantID, Day: contribution.Day}: contribution.Counters,
+		}
+		if previous != nil {
+			put.ConditionExpression = aws.String("#version = :version")
+			put.ExpressionAttributeNames = map[string]*string{"#version": aws.String("version")}
+			put.ExpressionAttributeValues = map[string]*dynamodb.AttributeValue{
+				":version": {N: aws.String(strconv.Itoa(previous.Version))},
+			}
+
+			bucket := statsBucket{TenantID: previous.TenantID, Day: previous.Day}
+			if deltas[bucket] == nil {
+				deltas[bucket] = make(map[string]int)
+			}
+			for counter, count := range previous.Counters {
+				deltas[bucket][counter] -= count
+			}
+		}
+
+		items := []*dynamodb.TransactWriteItem{{Put: put}}
+		for bucket, counters := range deltas {
+			if update := statsUpdate(bucket, counters, now); update != nil {
+				items = append(items, &dynamodb.TransactWriteItem{Update: update})
+			}
+		}
+
+		_, err = dynamoDB.TransactWriteItemsWithContext(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: items})
+		if err == nil {
+			return nil
+		}
+		var cancelled *dynamodb.TransactionCanceledException
+		if !errors.As(err, &cancelled) || len(cancelled.CancellationReasons) == 0 ||
+			aws.StringValue(cancelled.CancellationReasons[0].Code) != "ConditionalCheckFailed" {
+			return fmt.Errorf("failed to project statistics of %s: %w", purchaseOrder.ID, err)
+		}
+		if attempt == maxProjectAttempts {
+			return fmt.Errorf("failed to project statistics of %s: contribution changed %d times", purchaseOrder.ID, attempt)
+		}
+	}
+}
+
+// storedStatsContribution reads the contribution the projection counts for
+// an order, or nil if the order is not counted yet
+func storedStatsContribution(ctx context.Context, dynamoDB dynamodbiface.DynamoDBAPI, purchaseOrderID string) (*statsContribution, error) {
+	result, err := dynamoDB.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(statsContributionsTable),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {S: aws.String(purchaseOrderID)},
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get statistics contribution of %s: %w", purchaseOrderID, err)
+	}
+	if len(result.Item) == 0 {
+		return nil, nil
+	}
+
+	var contribution statsContribution
+	if err := dynamodbattribute.UnmarshalMap(result.Item, &contribution); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal statistics contribution of %s: %w", purchaseOrderID, err)
+	}
+	return &contribution, nil
+}
+
+// putStatsContribution records an order as counted at its version, for
+// RecomputeStatsCommand which counts every order itself
+func putStatsContribution(ctx context.Context, dynamoDB dynamodbiface.DynamoDBAPI, purchaseOrder *models.PurchaseOrder, now time.Time) error {
+	item, err := dynamodbattribute.MarshalMap(newStatsContribution(ctx, purchaseOrder, now))
+	if err != nil {
+		return fmt.Errorf("failed to marshal statistics contribution: %w", err)
+	}
+	if _, err := dynamoDB.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(statsContributionsTable),
+		Item:      item,
+	}); err != nil {
+		return fmt.Errorf("failed to put statistics contribution of %s: %w", purchaseOrder.ID, err)
+	}
+	return nil
+}
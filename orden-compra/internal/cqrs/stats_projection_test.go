@@ -0,0 +1,140 @@
+package cqrs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+
+	"medisupply/clock"
+	"orden-compra/internal/memory"
+	"orden-compra/internal/models"
+)
+
+// failingTransactions fails the next transactions before they reach the fake
+type failingTransactions struct {
+	*memory.DynamoDB
+	failures int
+}
+
+func (d *failingTransactions) TransactWriteItemsWithContext(ctx aws.Context, input *dynamodb.TransactWriteItemsInput, opts ...request.Option) (*dynamodb.TransactWriteItemsOutput, error) {
+	if d.failures > 0 {
+		d.failures--
+		return nil, errors.New("throttled")
+	}
+	return d.DynamoDB.TransactWriteItemsWithContext(ctx, input, opts...)
+}
+
+// runStatsLifecycle creates two pending orders and sends the second, with
+// commands counting the statistics as stats selects
+func runStatsLifecycle(t *testing.T, dynamoDB *memory.DynamoDB, fake *clock.Fake, stats StatsProjection) {
+	t.Helper()
+	var created []*models.PurchaseOrder
+	for _, productID := range []string{"product-1", "product-2"} {
+		purchaseOrder := models.NewPurchaseOrder(productID, "Gloves", "supplier-1", "Acme", "warehouse-1", "HIGH", 10, fake.Now())
+		create := NewCreatePurchaseOrderCommand(purchaseOrder, dynamoDB, discardLogger, nil, nil)
+		create.Clock = fake
+		create.Stats = stats
+		if _, err := create.Execute(context.Background()); err != nil {
+			t.Fatalf("create purchase order: %v", err)
+		}
+		created = append(created, purchaseOrder)
+	}
+
+	fake.Advance(time.Hour)
+	send := NewUpdatePurchaseOrderStatusCommand(created[1].ID, models.StatusSent, dynamoDB, discardLogger, nil, nil)
+	send.Clock = fake
+	send.Stats = stats
+	if _, err := send.Execute(context.Background()); err != nil {
+		t.Fatalf("send purchase order: %v", err)
+	}
+}
+
+// storedEvents reads the event store as the stream consumer receives it
+func storedEvents(t *testing.T, dynamoDB *memory.DynamoDB) []*models.EventSourcingEvent {
+	t.Helper()
+	var events []*models.EventSourcingEvent
+	for _, item := range dynamoDB.Items("orden-compra-events") {
+		var event models.EventSourcingEvent
+		if err := dynamodbattribute.UnmarshalMap(item, &event); err != nil {
+			t.Fatalf("unmarshal event: %v", err)
+		}
+		events = append(events, &event)
+	}
+	return events
+}
+
+// checkStatsCounters fails unless the bucket of statsDay holds the counters
+// of one pending and one sent order
+func checkStatsCounters(t *testing.T, dynamoDB *memory.DynamoDB) {
+	t.Helper()
+	bucket := statsDay.Format(models.StatsDayLayout)
+	counters := map[string]int{
+		models.StatsTotal: 2,
+		models.StatsStatusPrefix + models.StatusPending: 1,
+		models.StatsStatusPrefix + models.StatusSent:    1,
+		models.StatsUrgencyPrefix + "HIGH":              2,
+	}
+	for counter, want := range counters {
+		if got := statsCounter(t, dynamoDB, bucket, counter); got != want {
+			t.Errorf("%s = %d, want %d", counter, got, want)
+		}
+	}
+}
+
+func TestStatsCountEachOrderOnce(t *testing.T) {
+	t.Run("in commands", func(t *testing.T) {
+		dynamoDB := memory.NewDynamoDB(memory.Tables)
+		runStatsLifecycle(t, dynamoDB, clock.NewFake(statsDay), StatsInCommands)
+
+		checkStatsCounters(t, dynamoDB)
+		if contributions := dynamoDB.Items(statsContributionsTable); len(contributions) != 0 {
+			t.Fatalf("commands recorded %d stream contributions", len(contributions))
+		}
+	})
+
+	t.Run("from stream", func(t *testing.T) {
+		dynamoDB := memory.NewDynamoDB(memory.Tables)
+		fake := clock.NewFake(statsDay)
+		runStatsLifecycle(t, dynamoDB, fake, StatsFromStream)
+		if buckets := dynamoDB.Items(statsTable); len(buckets) != 0 {
+			t.Fatalf("commands counted the orders in %d buckets, want them left to the stream", len(buckets))
+		}
+
+		// Records arrive more than once and out of order
+		events := storedEvents(t, dynamoDB)
+		reversed := make([]*models.EventSourcingEvent, len(events))
+		for i, event := range events {
+			reversed[len(events)-1-i] = event
+		}
+		for _, batch := range [][]*models.EventSourcingEvent{events, reversed, events} {
+			if err := ProjectStats(context.Background(), dynamoDB, batch, fake.Now()); err != nil {
+				t.Fatalf("project statistics: %v", err)
+			}
+		}
+		checkStatsCounters(t, dynamoDB)
+	})
+}
+
+func TestProjectStatsRetryCountsOnce(t *testing.T) {
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	fake := clock.NewFake(statsDay)
+	runStatsLifecycle(t, dynamoDB, fake, StatsFromStream)
+	events := storedEvents(t, dynamoDB)
+
+	// A failed write leaves neither the contribution nor the buckets behind,
+	// so the redelivered batch is counted in full exactly once
+	failing := &failingTransactions{DynamoDB: dynamoDB, failures: 1}
+	if err := ProjectStats(context.Background(), failing, events, fake.Now()); err == nil {
+		t.Fatal("projection succeeded through a failed transaction")
+	}
+	if err := ProjectStats(context.Background(), failing, events, fake.Now()); err != nil {
+		t.Fatalf("project redelivered batch: %v", err)
+	}
+	checkStatsCounters(t, dynamoDB)
+}
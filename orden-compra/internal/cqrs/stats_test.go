@@ -0,0 +1,154 @@
+package cqrs
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+
+	"orden-compra/internal/memory"
+	"orden-compra/internal/models"
+	"orden-compra/internal/tenant"
+)
+
+var statsDay = time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+
+func newStatsOrder(status string) *models.PurchaseOrder {
+	return &models.PurchaseOrder{
+		ID:           "po-1",
+		ProductID:    "product-1",
+		SupplierID:   "supplier-1",
+		Quantity:     10,
+		Status:       status,
+		UrgencyLevel: "HIGH",
+		CreatedAt:    statsDay,
+		UpdatedAt:    statsDay,
+	}
+}
+
+// statsCounter reads one counter of a projection bucket
+func statsCounter(t *testing.T, dynamoDB *memory.DynamoDB, bucket, counter string) int {
+	t.Helper()
+	for _, item := range dynamoDB.Items(statsTable) {
+		if aws.StringValue(item["id"].S) != bucket {
+			continue
+		}
+		if item[counter] == nil {
+			return 0
+		}
+		count, err := strconv.Atoi(aws.StringValue(item[counter].N))
+		if err != nil {
+			t.Fatalf("counter %s of %s: %v", counter, bucket, err)
+		}
+		return count
+	}
+	return 0
+}
+
+func TestPutPurchaseOrderMovesStatsWithTheOrder(t *testing.T) {
+	ctx := context.Background()
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	bucket := statsDay.Format(models.StatsDayLayout)
+
+	purchaseOrder := newStatsOrder(models.StatusPending)
+	if err := putPurchaseOrder(ctx, dynamoDB, StatsInCommands, purchaseOrder, statsDay); err != nil {
+		t.Fatalf("put new order: %v", err)
+	}
+	if purchaseOrder.Version != 1 {
+		t.Fatalf("version %d after the first put, want 1", purchaseOrder.Version)
+	}
+
+	purchaseOrder.Status = models.StatusApproved
+	if err := putPurchaseOrder(ctx, dynamoDB, StatsInCommands, purchaseOrder, statsDay); err != nil {
+		t.Fatalf("put approved order: %v", err)
+	}
+
+	counters := map[string]int{
+		models.StatsTotal: 1,
+		models.StatsStatusPrefix + models.StatusPending:  0,
+		models.StatsStatusPrefix + models.StatusApproved: 1,
+		models.StatsUrgencyPrefix + "HIGH":               1,
+	}
+	for counter, want := range counters {
+		if got := statsCounter(t, dynamoDB, bucket, counter); got != want {
+			t.Errorf("%s = %d, want %d", counter, got, want)
+		}
+	}
+}
+
+func TestPutPurchaseOrderConflictLeavesStatsUnchanged(t *testing.T) {
+	ctx := context.Background()
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	bucket := statsDay.Format(models.StatsDayLayout)
+
+	if err := putPurchaseOrder(ctx, dynamoDB, StatsInCommands, newStatsOrder(models.StatusPending), statsDay); err != nil {
+		t.Fatalf("put new order: %v", err)
+	}
+
+	// A second writer read the order before the first put
+	stale := newStatsOrder(models.StatusApproved)
+	err := putPurchaseOrder(ctx, dynamoDB, StatsInCommands, stale, statsDay)
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) || !errors.Is(err, ErrConflict) {
+		t.Fatalf("stale put error %v, want a ConflictError", err)
+	}
+	if stale.Version != 0 {
+		t.Fatalf("stale order left at version %d, want its read version 0", stale.Version)
+	}
+
+	if got := statsCounter(t, dynamoDB, bucket, models.StatsStatusPrefix+models.StatusApproved); got != 0 {
+		t.Fatalf("rejected write counted %d approved orders", got)
+	}
+	if got := statsCounter(t, dynamoDB, bucket, models.StatsTotal); got != 1 {
+		t.Fatalf("total %d after a rejected write, want 1", got)
+	}
+}
+
+func TestPutPurchaseOrderReturnsTransactionErrors(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := writeWithStats(ctx, memory.NewDynamoDB(memory.Tables), StatsInCommands, &dynamodb.Put{
+		TableName: aws.String("orden-compra-read"),
+		Item:      map[string]*dynamodb.AttributeValue{"id": {S: aws.String("po-1")}},
+	}, nil, newStatsOrder(models.StatusPending), statsDay)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("write error %v, want the transaction's error", err)
+	}
+}
+
+func TestPutPurchaseOrderInTenantContext(t *testing.T) {
+	client := memory.NewDynamoDB(memory.Tables)
+	dynamoDB := tenant.NewIsolatedDynamoDB(client, tenant.Policy{DefaultTenant: "default"}, []string{"orden-compra-read"}, nil)
+	ctx := tenant.NewContext(context.Background(), "tenant-a")
+
+	purchaseOrder := newStatsOrder(models.StatusPending)
+	if err := putPurchaseOrder(ctx, dynamoDB, StatsInCommands, purchaseOrder, statsDay); err != nil {
+		t.Fatalf("put in tenant context: %v", err)
+	}
+	purchaseOrder.Status = models.StatusApproved
+	if err := putPurchaseOrder(ctx, dynamoDB, StatsInCommands, purchaseOrder, statsDay); err != nil {
+		t.Fatalf("second put in tenant context: %v", err)
+	}
+
+	items := client.Items("orden-compra-read")
+	if len(items) != 1 || aws.StringValue(items[0][tenant.Attribute].S) != "tenant-a" {
+		t.Fatalf("read model %v, want one order stamped with tenant-a", items)
+	}
+	bucket := "tenant-a#" + statsDay.Format(models.StatsDayLayout)
+	if got := statsCounter(t, client, bucket, models.StatsStatusPrefix+models.StatusApproved); got != 1 {
+		t.Fatalf("tenant bucket counts %d approved orders, want 1", got)
+	}
+
+	// Another tenant cannot overwrite the order through its ID
+	other := newStatsOrder(models.StatusSent)
+	other.Version = purchaseOrder.Version
+	err := putPurchaseOrder(tenant.NewContext(context.Background(), "tenant-b"), dynamoDB, StatsInCommands, other, statsDay)
+	if !errors.Is(err, ErrConflict) {
+		t.Fatalf("put by another tenant error %v, want a conflict", err)
+	}
+}
@@ -0,0 +1,182 @@
+package cqrs
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/sirupsen/logrus"
+
+	"orden-compra/internal/models"
+)
+
+// ErrInvalidCursor is returned when an event stream position is neither a
+// timestamp nor a cursor returned by the stream
+var ErrInvalidCursor = errors.New("invalid event stream cursor")
+
+// EventCursor is a position in the event stream. Events are ordered by
+// timestamp, then ID; the cursor is after every event at or before it.
+type EventCursor struct {
+	Timestamp time.Time
+	EventID   string
+}
+
+// ParseEventCursor reads a position given as an RFC 3339 timestamp, which is
+// before every event at that time, or as a cursor returned by the stream
+func ParseEventCursor(value string) (EventCursor, error) {
+	if timestamp, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return EventCursor{Timestamp: timestamp.UTC()}, nil
+	}
+
+	decoded, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return EventCursor{}, fmt.Errorf("%w: %s", ErrInvalidCursor, value)
+	}
+	timestamp, eventID, ok := strings.Cut(string(decoded), "|")
+	if !ok || eventID == "" {
+		return EventCursor{}, fmt.Errorf("%w: %s", ErrInvalidCursor, value)
+	}
+	parsed, err := time.Parse(time.RFC3339Nano, timestamp)
+	if err != nil {
+		return EventCursor{}, fmt.Errorf("%w: %s", ErrInvalidCursor, value)
+	}
+	return EventCursor{Timestamp: parsed.UTC(), EventID: eventID}, nil
+}
+
+// String encodes the cursor as an opaque URL-safe token
+func (c EventCursor) String() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(c.Timestamp.UTC().Format(time.RFC3339Nano) + "|" + c.EventID))
+}
+
+// before reports whether the cursor precedes the event
+func (c EventCursor) before(event *models.EventSourcingEvent) bool {
+	if !event.Timestamp.Equal(c.Timestamp) {
+		return event.Timestamp.After(c.Timestamp)
+	}
+	return event.ID > c.EventID
+}
+
+// ListEventsQuery reads a page of the event stream: the events after a
+// cursor, in order, up to a point in time that events stored late by other
+// replicas are expected to have reached
+type ListEventsQuery struct {
+	After    EventCursor
+	Until    time.Time
+	Limit    int
+	DynamoDB dynamodbiface.DynamoDBAPI
+	Logger   *logrus.Logger
+}
+
+// NewListEventsQuery creates a new ListEventsQuery
+func NewListEventsQuery(after EventCursor, until time.Time, limit int, dynamoDB dynamodbiface.DynamoDBAPI, logger *logrus.Logger) *ListEventsQuery {
+	return &ListEventsQuery{
+		After:    after,
+		Until:    until,
+		Limit:    limit,
+		DynamoDB: dynamoDB,
+		Logger:   logger,
+	}
+}
+
+// Execute returns up to Limit events after the cursor, upcast to their
+// current schema, with the cursor to continue from. next_cursor is the
+// request's own cursor when there are no new events, so it can be polled.
+func (q *ListEventsQuery) Execute(ctx context.Context) (map[string]interface{}, error) {
+	q.Logger.WithFields(logrus.Fields{
+		"after": q.After.String(),
+		"until": q.Until,
+		"limit": q.Limit,
+	}).Debug("Listing events")
+
+	// Stored timestamps have a varying number of fractional digits, which do
+	// not compare as strings; the filter takes the whole second and the
+	// events are compared exactly below
+	scanInput := &dynamodb.ScanInput{
+		TableName:        aws.String("orden-compra-events"),
+		FilterExpression: aws.String("#timestamp >= :since AND attribute_not_exists(restored_at)"),
+		ExpressionAttributeNames: map[string]*string{
+			"#timestamp": aws.String("timestamp"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":since": {S: aws.String(q.After.Timestamp.UTC().Format("2006-01-02T15:04:05"))},
+		},
+	}
+
+	// Only the earliest Limit+1 events are kept, the extra one telling
+	// whether there is more to read
+	var events []*models.EventSourcingEvent
+	for {
+		result, err := q.DynamoDB.ScanWithContext(ctx, scanInput)
+		if err != nil {
+			q.Logger.WithError(err).Error("Failed to scan events")
+			return nil, fmt.Errorf("failed to scan events: %w", err)
+		}
+
+		for _, item := range result.Items {
+			var event models.EventSourcingEvent
+			if err := dynamodbattribute.UnmarshalMap(item, &event); err != nil {
+				q.Logger.WithError(err).Error("Failed to unmarshal event")
+				continue
+			}
+			if !q.After.before(&event) || event.Timestamp.After(q.Until) {
+				continue
+			}
+			events = append(events, &event)
+		}
+		if len(events) > q.Limit {
+			sortEvents(events)
+			events = events[:q.Limit+1]
+		}
+
+		if len(result.LastEvaluatedKey) == 0 {
+			break
+		}
+		scanInput.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+	sortEvents(events)
+
+	hasMore := len(events) > q.Limit
+	if hasMore {
+		events = events[:q.Limit]
+	}
+
+	next := q.After
+	for i, event := range events {
+		if err := models.EventSchemas.Upcast(event); err != nil {
+			// The page ends before the event rather than skipping it, so
+			// readers resume there once a build that knows its schema serves them
+			q.Logger.WithError(err).Error("Failed to upcast event")
+			events = events[:i]
+			hasMore = true
+			break
+		}
+		next = EventCursor{Timestamp: event.Timestamp.UTC(), EventID: event.ID}
+	}
+
+	return map[string]interface{}{
+		"success":     true,
+		"events":      events,
+		"count":       len(events),
+		"next_cursor": next.String(),
+		"has_more":    hasMore,
+		"until":       q.Until,
+	}, nil
+}
+
+// sortEvents orders events by timestamp, then ID
+func sortEvents(events []*models.EventSourcingEvent) {
+	sort.Slice(events, func(i, j int) bool {
+		if !events[i].Timestamp.Equal(events[j].Timestamp) {
+			return events[i].Timestamp.Before(events[j].Timestamp)
+		}
+		return events[i].ID < events[j].ID
+	})
+}
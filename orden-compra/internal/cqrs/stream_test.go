@@ -0,0 +1,73 @@
+package cqrs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"orden-compra/internal/memory"
+	"orden-compra/internal/models"
+)
+
+func TestParseEventCursor(t *testing.T) {
+	cursor := EventCursor{Timestamp: statsDay.Add(1500 * time.Millisecond), EventID: "event-1"}
+	if parsed, err := ParseEventCursor(cursor.String()); err != nil || !parsed.Timestamp.Equal(cursor.Timestamp) || parsed.EventID != "event-1" {
+		t.Fatalf("cursor %s parsed as %+v, error %v", cursor, parsed, err)
+	}
+	if parsed, err := ParseEventCursor("2024-03-04T10:00:00-05:00"); err != nil || !parsed.Timestamp.Equal(time.Date(2024, 3, 4, 15, 0, 0, 0, time.UTC)) || parsed.EventID != "" {
+		t.Fatalf("timestamp parsed as %+v, error %v", parsed, err)
+	}
+	for _, value := range []string{"yesterday", "bm8gc2VwYXJhdG9y", "bm90IGEgdGltZXxldmVudC0x", "MjAyNC0wMy0wNFQxMDowMDowMFp8"} {
+		if _, err := ParseEventCursor(value); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("parsing %q returned %v, want ErrInvalidCursor", value, err)
+		}
+	}
+}
+
+func TestListEventsPagesThroughTheStream(t *testing.T) {
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	// Two events share a timestamp and the last is past until
+	for _, at := range []time.Time{statsDay, statsDay.Add(time.Second), statsDay.Add(time.Second), statsDay.Add(90 * time.Millisecond), statsDay.Add(time.Hour)} {
+		putEvent(t, dynamoDB, "po-1", "PurchaseOrderCreated", "", at)
+	}
+	until := statsDay.Add(time.Minute)
+
+	var streamed []*models.EventSourcingEvent
+	after := EventCursor{Timestamp: statsDay.Add(-time.Second)}
+	for page := 0; ; page++ {
+		result, err := NewListEventsQuery(after, until, 2, dynamoDB, discardLogrus).Execute(context.Background())
+		if err != nil {
+			t.Fatalf("page %d: %v", page, err)
+		}
+		events := result["events"].([]*models.EventSourcingEvent)
+		streamed = append(streamed, events...)
+		next, err := ParseEventCursor(result["next_cursor"].(string))
+		if err != nil {
+			t.Fatalf("next cursor of page %d: %v", page, err)
+		}
+		after = next
+		if result["has_more"] != true {
+			break
+		}
+		if page > 3 {
+			t.Fatal("the stream does not end")
+		}
+	}
+
+	if len(streamed) != 4 {
+		t.Fatalf("streamed %d events, want the 4 before until", len(streamed))
+	}
+	for i := 1; i < len(streamed); i++ {
+		previous := EventCursor{Timestamp: streamed[i-1].Timestamp, EventID: streamed[i-1].ID}
+		if !previous.before(streamed[i]) {
+			t.Fatalf("event %d at %s is streamed after %s", i, streamed[i].Timestamp, streamed[i-1].Timestamp)
+		}
+	}
+
+	// A reader at the end polls with its own cursor
+	result, err := NewListEventsQuery(after, until, 2, dynamoDB, discardLogrus).Execute(context.Background())
+	if err != nil || result["count"] != 0 || result["next_cursor"] != after.String() || result["has_more"] != false {
+		t.Fatalf("poll at the end returned %v, error %v", result, err)
+	}
+}
@@ -0,0 +1,199 @@
+package cqrs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+
+	"medisupply/clock"
+	"orden-compra/internal/models"
+)
+
+// maxSupplierRefreshAttempts bounds the writes of an order that keeps being
+// modified concurrently while its supplier name is refreshed
+const maxSupplierRefreshAttempts = 3
+
+// RefreshSupplierNamesCommand copies the catalog names of suppliers onto the
+// orders still awaiting delivery from them, which carry the name their
+// supplier had when they were created. Received, cancelled and rejected
+// orders keep the name they were placed under.
+type RefreshSupplierNamesCommand struct {
+	// Names maps supplier IDs to their current names
+	Names        map[string]string
+	ScanSegments int
+	DynamoDB     dynamodbiface.DynamoDBAPI
+	Logger       *log.Logger
+	Clock        clock.Clock
+	Stats        StatsProjection
+}
+
+// NewRefreshSupplierNamesCommand creates a new RefreshSupplierNamesCommand
+func NewRefreshSupplierNamesCommand(names map[string]string, dynamoDB dynamodbiface.DynamoDBAPI, logger *log.Logger) *RefreshSupplierNamesCommand {
+	return &RefreshSupplierNamesCommand{
+		Names:    names,
+		DynamoDB: dynamoDB,
+		Logger:   logger,
+		Clock:    clock.System,
+	}
+}
+
+// Execute scans the read model once for the orders of all the suppliers and
+// stores those named differently with the current name, recording a
+// PurchaseOrderSupplierUpdated event. An order that fails is logged and
+// counted and does not stop the others; running the command again retries it.
+func (c *RefreshSupplierNamesCommand) Execute(ctx context.Context) (map[string]interface{}, error) {
+	if len(c.Names) == 0 {
+		return map[string]interface{}{"success": true, "refreshed": 0, "failed": 0}, nil
+	}
+
+	scanInput := &dynamodb.ScanInput{
+		TableName:                aws.String("orden-compra-read"),
+		FilterExpression:         aws.String("#status IN (:pending, :pending_approval, :approved, :sent)"),
+		ExpressionAttributeNames: map[string]*string{"#status": aws.String("status")},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":pending":          {S: aws.String(models.StatusPending)},
+			":pending_approval": {S: aws.String(models.StatusPendingApproval)},
+			":approved":         {S: aws.String(models.StatusApproved)},
+			":sent":             {S: aws.String(models.StatusSent)},
+		},
+	}
+
+	var stale []string
+	err := scanAll(ctx, c.DynamoDB, c.ScanSegments, scanInput, func(items []map[string]*dynamodb.AttributeValue) error {
+		for _, item := range items {
+			var purchaseOrder models.PurchaseOrder
+			if err := dynamodbattribute.UnmarshalMap(item, &purchaseOrder); err != nil {
+				c.Logger.Printf("Failed to unmarshal purchase order: %v", err)
+				continue
+			}
+			if name, ok := c.Names[purchaseOrder.SupplierID]; ok && purchaseOrder.SupplierName != name {
+				stale = append(stale, purchaseOrder.ID)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.Logger.Printf("Failed to scan purchase orders: %v", err)
+		return nil, err
+	}
+
+	refreshed, failed := 0, 0
+	for _, purchaseOrderID := range stale {
+		updated, err := c.refresh(ctx, purchaseOrderID)
+		switch {
+		case err != nil:
+			c.Logger.Printf("Failed to refresh supplier name - purchase_order_id: %s, error: %v", purchaseOrderID, err)
+			failed++
+		case updated:
+			refreshed++
+		}
+	}
+
+	c.Logger.Printf("Supplier names refreshed - suppliers: %d, orders: %d, failed: %d", len(c.Names), refreshed, failed)
+
+	return map[string]interface{}{
+		"success":   failed == 0,
+		"refreshed": refreshed,
+		"failed":    failed,
+	}, nil
+}
+
+// refresh reads an order and stores it with its supplier's new name, reading
+// it again when it is modified concurrently. It reports false when the order
+// no longer needs refreshing.
+func (c *RefreshSupplierNamesCommand) refresh(ctx context.Context, purchaseOrderID string) (bool, error) {
+	for attempt := 1; ; attempt++ {
+		purchaseOrder, err := c.getPurchaseOrder(ctx, purchaseOrderID)
+		if err != nil {
+			return false, err
+		}
+		name, ok := c.Names[purchaseOrder.SupplierID]
+		if !ok || purchaseOrder.SupplierName == name || !purchaseOrder.AwaitingDelivery() {
+			return false, nil
+		}
+
+		previousName := purchaseOrder.SupplierName
+		purchaseOrder.SupplierName = name
+		purchaseOrder.UpdatedAt = c.Clock.Now().UTC()
+
+		err = putPurchaseOrder(ctx, c.DynamoDB, c.Stats, purchaseOrder, c.Clock.Now())
+		var conflict *ConflictError
+		if errors.As(err, &conflict) && attempt < maxSupplierRefreshAttempts {
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+
+		if err := c.storeEventSourcingEvent(ctx, purchaseOrder, previousName); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+}
+
+// getPurchaseOrder reads the stored version of an order
+func (c *RefreshSupplierNamesCommand) getPurchaseOrder(ctx context.Context, purchaseOrderID string) (*models.PurchaseOrder, error) {
+	result, err := c.DynamoDB.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String("orden-compra-read"),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {S: aws.String(purchaseOrderID)},
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get item: %w", err)
+	}
+	if result.Item == nil {
+		return nil, ErrPurchaseOrderNotFound
+	}
+
+	var purchaseOrder models.PurchaseOrder
+	if err := dynamodbattribute.UnmarshalMap(result.Item, &purchaseOrder); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal purchase order: %w", err)
+	}
+	return &purchaseOrder, nil
+}
+
+// storeEventSourcingEvent stores the PurchaseOrderSupplierUpdated event sourcing event
+func (c *RefreshSupplierNamesCommand) storeEventSourcingEvent(ctx context.Context, purchaseOrder *models.PurchaseOrder, previousName string) error {
+	eventData := map[string]interface{}{
+		"purchase_order": purchaseOrder,
+		"supplier_change": map[string]interface{}{
+			"supplier_id": purchaseOrder.SupplierID,
+			"old_name":    previousName,
+			"new_name":    purchaseOrder.SupplierName,
+		},
+	}
+
+	event := models.NewEventSourcingEvent(
+		purchaseOrder.ID,
+		models.PurchaseOrderSupplierUpdatedEventType,
+		eventData,
+		nil,
+		nil,
+		c.Clock.Now(),
+	)
+	event.TenantID = purchaseOrder.TenantID
+
+	item, err := dynamodbattribute.MarshalMap(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event sourcing event: %w", err)
+	}
+
+	_, err = c.DynamoDB.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String("orden-compra-events"),
+		Item:      item,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put event sourcing event: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,62 @@
+package cqrs
+
+import (
+	"context"
+	"testing"
+
+	"medisupply/clock"
+	"orden-compra/internal/memory"
+	"orden-compra/internal/models"
+)
+
+func TestRefreshSupplierNames(t *testing.T) {
+	ctx := context.Background()
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	fake := clock.NewFake(statsDay)
+
+	pending := createPurchaseOrder(t, dynamoDB, fake, models.StatusPending)
+	sent := createPurchaseOrder(t, dynamoDB, fake, models.StatusSent)
+	cancelled := createPurchaseOrder(t, dynamoDB, fake, models.StatusCancelled)
+	other := models.NewPurchaseOrder("product-1", "Gloves", "supplier-2", "Globex", "warehouse-1", "HIGH", 10, fake.Now())
+	create := NewCreatePurchaseOrderCommand(other, dynamoDB, discardLogger, nil, nil)
+	create.Clock = fake
+	if _, err := create.Execute(ctx); err != nil {
+		t.Fatalf("create purchase order: %v", err)
+	}
+
+	fake.Advance(1)
+	command := NewRefreshSupplierNamesCommand(map[string]string{"supplier-1": "Acme Corp", "supplier-3": "Initech"}, dynamoDB, discardLogger)
+	command.Clock = fake
+	result, err := command.Execute(ctx)
+	if err != nil || result["success"] != true || result["refreshed"] != 2 || result["failed"] != 0 {
+		t.Fatalf("refresh returned %v, error %v, want the two orders awaiting delivery", result, err)
+	}
+
+	for _, tc := range []struct {
+		id   string
+		name string
+	}{
+		{pending.ID, "Acme Corp"},
+		{sent.ID, "Acme Corp"},
+		{cancelled.ID, "Acme"},
+		{other.ID, "Globex"},
+	} {
+		if stored := getPurchaseOrder(t, dynamoDB, tc.id); stored.SupplierName != tc.name {
+			t.Fatalf("%s order of %s named %q, want %q", stored.Status, stored.SupplierID, stored.SupplierName, tc.name)
+		}
+	}
+	if stored := getPurchaseOrder(t, dynamoDB, pending.ID); !stored.UpdatedAt.Equal(fake.Now()) {
+		t.Fatalf("refreshed order updated at %s, want %s", stored.UpdatedAt, fake.Now())
+	}
+	if times := storedEventTimes(t, dynamoDB, models.PurchaseOrderSupplierUpdatedEventType); len(times) != 2 {
+		t.Fatalf("recorded %d supplier updates, want 2", len(times))
+	}
+
+	// Orders already carrying the names are left alone
+	if result, err := command.Execute(ctx); err != nil || result["refreshed"] != 0 {
+		t.Fatalf("second refresh returned %v, error %v", result, err)
+	}
+	if result, err := NewRefreshSupplierNamesCommand(nil, dynamoDB, discardLogger).Execute(ctx); err != nil || result["refreshed"] != 0 {
+		t.Fatalf("refresh without names returned %v, error %v", result, err)
+	}
+}
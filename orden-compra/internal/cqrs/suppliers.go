@@ -0,0 +1,162 @@
+package cqrs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+
+	"medisupply/clock"
+	"orden-compra/internal/cache"
+	"orden-compra/internal/models"
+)
+
+var (
+	// ErrSupplierNotFound is returned when a supplier is not in the catalog
+	ErrSupplierNotFound = errors.New("supplier not found")
+	// ErrSupplierConflict is returned when a supplier changed between being
+	// read and written back
+	ErrSupplierConflict = errors.New("supplier was modified concurrently")
+)
+
+// getSupplier retrieves a supplier from the supplier catalog, returning nil if it is not listed
+func (c *ProcessStockLowCommand) getSupplier(ctx context.Context, supplierID string) (*models.Supplier, error) {
+	// Supplier details change rarely; a copy up to the cache TTL old will do
+	return FindSupplier(cache.AllowStale(ctx), c.DynamoDB, supplierID, false)
+}
+
+// FindSupplier retrieves a supplier from the supplier catalog, returning nil
+// if it is not listed. A consistent read bypasses the cache.
+func FindSupplier(ctx context.Context, dynamoDB dynamodbiface.DynamoDBAPI, supplierID string, consistent bool) (*models.Supplier, error) {
+	supplier, _, err := findSupplierItem(ctx, dynamoDB, supplierID, consistent)
+	return supplier, err
+}
+
+// findSupplierItem retrieves a supplier together with its stored item
+func findSupplierItem(ctx context.Context, dynamoDB dynamodbiface.DynamoDBAPI, supplierID string, consistent bool) (*models.Supplier, map[string]*dynamodb.AttributeValue, error) {
+	result, err := dynamoDB.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String("orden-compra-suppliers"),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {
+				S: aws.String(supplierID),
+			},
+		},
+		ConsistentRead: aws.Bool(consistent),
+	})
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get supplier: %w", err)
+	}
+
+	if result.Item == nil {
+		return nil, nil, nil
+	}
+
+	var supplier models.Supplier
+	if err := dynamodbattribute.UnmarshalMap(result.Item, &supplier); err != nil {
+		return nil, nil, fmt.Errorf("failed to unmarshal supplier: %w", err)
+	}
+
+	return &supplier, result.Item, nil
+}
+
+// UpsertSupplierCommand adds a supplier to the supplier catalog or replaces
+// its entry
+type UpsertSupplierCommand struct {
+	Supplier *models.Supplier
+	DynamoDB dynamodbiface.DynamoDBAPI
+	Logger   *log.Logger
+	Clock    clock.Clock
+}
+
+// NewUpsertSupplierCommand creates a new UpsertSupplierCommand
+func NewUpsertSupplierCommand(supplier *models.Supplier, dynamoDB dynamodbiface.DynamoDBAPI, logger *log.Logger) *UpsertSupplierCommand {
+	return &UpsertSupplierCommand{
+		Supplier: supplier,
+		DynamoDB: dynamoDB,
+		Logger:   logger,
+		Clock:    clock.System,
+	}
+}
+
+// Execute validates and stores the supplier. An existing entry keeps its
+// creation time, the metadata keys the supplier does not set, such as its
+// dispatch settings, and its contacts and escalation rules unless the
+// supplier sets them (to an empty list to remove them); everything else is
+// replaced.
+func (c *UpsertSupplierCommand) Execute(ctx context.Context) (map[string]interface{}, error) {
+	if err := c.Supplier.Validate(); err != nil {
+		return nil, err
+	}
+
+	// Read past the cache so the creation time kept is the stored one
+	previous, previousItem, err := findSupplierItem(ctx, c.DynamoDB, c.Supplier.ID, true)
+	if err != nil {
+		return nil, err
+	}
+
+	now := c.Clock.Now().UTC()
+	supplier := *c.Supplier
+	supplier.CreatedAt = now
+	supplier.UpdatedAt = now
+	supplier.Metadata = make(map[string]interface{}, len(c.Supplier.Metadata))
+	if previous != nil {
+		supplier.CreatedAt = previous.CreatedAt
+		if supplier.Contacts == nil {
+			supplier.Contacts = previous.Contacts
+		}
+		if supplier.EscalationRules == nil {
+			supplier.EscalationRules = previous.EscalationRules
+		}
+		for key, value := range previous.Metadata {
+			supplier.Metadata[key] = value
+		}
+	}
+	for key, value := range c.Supplier.Metadata {
+		supplier.Metadata[key] = value
+	}
+
+	item, err := dynamodbattribute.MarshalMap(&supplier)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal supplier: %w", err)
+	}
+
+	// Conditional on the entry read, so concurrent edits are not lost
+	input := &dynamodb.PutItemInput{
+		TableName:           aws.String("orden-compra-suppliers"),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(id)"),
+	}
+	if updatedAt, ok := previousItem["updated_at"]; ok {
+		input.ConditionExpression = aws.String("updated_at = :updated_at")
+		input.ExpressionAttributeValues = map[string]*dynamodb.AttributeValue{
+			":updated_at": updatedAt,
+		}
+	} else if previous != nil {
+		input.ConditionExpression = aws.String("attribute_not_exists(updated_at)")
+	}
+
+	_, err = c.DynamoDB.PutItemWithContext(ctx, input)
+	if err != nil {
+		var awsErr awserr.Error
+		if errors.As(err, &awsErr) && awsErr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return nil, fmt.Errorf("%w: %s", ErrSupplierConflict, supplier.ID)
+		}
+		return nil, fmt.Errorf("failed to put supplier: %w", err)
+	}
+
+	c.Logger.Printf("Supplier stored - supplier_id: %s, created: %t, products: %d", supplier.ID, previous == nil, len(supplier.Products))
+
+	return map[string]interface{}{
+		"success":  true,
+		"created":  previous == nil,
+		"supplier": &supplier,
+		"previous": previous,
+	}, nil
+}
@@ -0,0 +1,68 @@
+package cqrs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"medisupply/clock"
+	"orden-compra/internal/memory"
+	"orden-compra/internal/models"
+)
+
+func TestStockLowExpectsDeliveryAfterTheSupplierLeadTime(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		supplier *models.Supplier
+		days     int
+		source   string
+	}{
+		{"not in the catalog", nil, 7, models.SLASourceDefault},
+		{"supplier lead time", &models.Supplier{ID: "supplier-001", Name: "Acme", LeadTimeDays: 3}, 3, models.SLASourceSupplierCatalog},
+		{"product lead time", &models.Supplier{ID: "supplier-001", Name: "Acme", LeadTimeDays: 3, Products: []models.SupplierProduct{{ProductID: "product-1", LeadTimeDays: 12}}}, 12, models.SLASourceSupplierCatalog},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dynamoDB := memory.NewDynamoDB(memory.Tables)
+			if tc.supplier != nil {
+				putItem(t, dynamoDB, "orden-compra-suppliers", tc.supplier)
+			}
+			fake := clock.NewFake(statsDay)
+
+			result, err := newStockLowCommand(dynamoDB, fake, models.PurchaseOrderPolicy{}).Execute(context.Background())
+			if err != nil {
+				t.Fatalf("execute: %v", err)
+			}
+			order := getPurchaseOrder(t, dynamoDB, result["purchase_order_id"].(string))
+			if want := statsDay.AddDate(0, 0, tc.days); order.ExpectedDate == nil || !order.ExpectedDate.Equal(want) {
+				t.Fatalf("expected date %v, want %s", order.ExpectedDate, want)
+			}
+			if days, _ := order.SLALeadTimeDays(); days != tc.days || order.Metadata[models.MetadataSLASource] != tc.source {
+				t.Fatalf("SLA of %d days from %v, want %d from %s", days, order.Metadata[models.MetadataSLASource], tc.days, tc.source)
+			}
+		})
+	}
+}
+
+func TestApprovalRestartsTheLeadTime(t *testing.T) {
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	putItem(t, dynamoDB, "orden-compra-suppliers", &models.Supplier{ID: "supplier-001", Name: "Acme", LeadTimeDays: 3})
+	fake := clock.NewFake(statsDay)
+	policy := models.PurchaseOrderPolicy{Approval: models.ApprovalPolicy{QuantityThreshold: 1}}
+
+	result, err := newStockLowCommand(dynamoDB, fake, policy).Execute(context.Background())
+	if err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+
+	// The supplier's clock starts when the order is released to it
+	fake.Advance(48 * time.Hour)
+	approve := NewApprovePurchaseOrderCommand(result["purchase_order_id"].(string), "alice", "", dynamoDB, discardLogger, nil, nil)
+	approve.Clock = fake
+	if _, err := approve.Execute(context.Background()); err != nil {
+		t.Fatalf("approve: %v", err)
+	}
+	order := getPurchaseOrder(t, dynamoDB, result["purchase_order_id"].(string))
+	if want := fake.Now().AddDate(0, 0, 3); order.ExpectedDate == nil || !order.ExpectedDate.Equal(want) {
+		t.Fatalf("expected date %v, want %s", order.ExpectedDate, want)
+	}
+}
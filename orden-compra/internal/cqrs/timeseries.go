@@ -0,0 +1,176 @@
+package cqrs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/sirupsen/logrus"
+
+	"orden-compra/internal/models"
+)
+
+// Time series intervals
+const (
+	IntervalDay  = "day"
+	IntervalWeek = "week"
+)
+
+// maxTimeSeriesPeriods bounds the number of buckets a time series returns
+const maxTimeSeriesPeriods = 366
+
+// ErrInvalidTimeSeries is returned for unknown intervals and unusable ranges
+var ErrInvalidTimeSeries = errors.New("invalid time series")
+
+// timeSeriesEvent holds the event attributes the time series reads
+type timeSeriesEvent struct {
+	AggregateID string    `dynamodbav:"aggregate_id"`
+	EventType   string    `dynamodbav:"event_type"`
+	Timestamp   time.Time `dynamodbav:"timestamp"`
+	EventData   struct {
+		StatusChange struct {
+			NewStatus string `dynamodbav:"new_status"`
+		} `dynamodbav:"status_change"`
+	} `dynamodbav:"event_data"`
+}
+
+// GetPurchaseOrderTimeSeriesQuery counts the purchase orders created,
+// completed and reported overdue per day or week, from the event store.
+// Periods are UTC days, and weeks starting on Monday.
+type GetPurchaseOrderTimeSeriesQuery struct {
+	StartDate    time.Time
+	EndDate      time.Time
+	Interval     string
+	ScanSegments int
+	DynamoDB     dynamodbiface.DynamoDBAPI
+	Logger       *logrus.Logger
+}
+
+// NewGetPurchaseOrderTimeSeriesQuery creates a new GetPurchaseOrderTimeSeriesQuery
+func NewGetPurchaseOrderTimeSeriesQuery(startDate, endDate time.Time, interval string, dynamoDB dynamodbiface.DynamoDBAPI, logger *logrus.Logger) *GetPurchaseOrderTimeSeriesQuery {
+	return &GetPurchaseOrderTimeSeriesQuery{
+		StartDate: startDate,
+		EndDate:   endDate,
+		Interval:  interval,
+		DynamoDB:  dynamoDB,
+		Logger:    logger,
+	}
+}
+
+// WithScanSegments sets the number of parallel segments the event store is
+// scanned in
+func (q *GetPurchaseOrderTimeSeriesQuery) WithScanSegments(segments int) *GetPurchaseOrderTimeSeriesQuery {
+	q.ScanSegments = segments
+	return q
+}
+
+// periodStart returns the start of the period t falls in
+func (q *GetPurchaseOrderTimeSeriesQuery) periodStart(t time.Time) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	if q.Interval == IntervalWeek {
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	}
+	return day
+}
+
+// nextPeriod returns the start of the period after the one starting at start
+func (q *GetPurchaseOrderTimeSeriesQuery) nextPeriod(start time.Time) time.Time {
+	if q.Interval == IntervalWeek {
+		return start.AddDate(0, 0, 7)
+	}
+	return start.AddDate(0, 0, 1)
+}
+
+// Execute returns one entry per period of the range, including empty ones
+func (q *GetPurchaseOrderTimeSeriesQuery) Execute(ctx context.Context) (map[string]interface{}, error) {
+	if q.Interval != IntervalDay && q.Interval != IntervalWeek {
+		return nil, fmt.Errorf("%w: interval must be %s or %s", ErrInvalidTimeSeries, IntervalDay, IntervalWeek)
+	}
+	if q.EndDate.Before(q.StartDate) {
+		return nil, fmt.Errorf("%w: range ends before it starts", ErrInvalidTimeSeries)
+	}
+
+	var periods []*models.StatsPeriod
+	index := make(map[string]*models.StatsPeriod)
+	for start := q.periodStart(q.StartDate); !start.After(q.EndDate); start = q.nextPeriod(start) {
+		if len(periods) == maxTimeSeriesPeriods {
+			return nil, fmt.Errorf("%w: range spans more than %d periods", ErrInvalidTimeSeries, maxTimeSeriesPeriods)
+		}
+		period := &models.StatsPeriod{Start: start.Format(models.StatsDayLayout)}
+		periods = append(periods, period)
+		index[period.Start] = period
+	}
+
+	q.Logger.WithFields(logrus.Fields{
+		"start_date": q.StartDate,
+		"end_date":   q.EndDate,
+		"interval":   q.Interval,
+	}).Debug("Getting purchase order time series")
+
+	scanInput := &dynamodb.ScanInput{
+		TableName:            aws.String("orden-compra-events"),
+		FilterExpression:     aws.String("event_type IN (:created, :status_updated, :completed, :overdue) AND #timestamp BETWEEN :start_date AND :end_date"),
+		ProjectionExpression: aws.String("aggregate_id, event_type, #timestamp, event_data.status_change"),
+		ExpressionAttributeNames: map[string]*string{
+			"#timestamp": aws.String("timestamp"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":created":        {S: aws.String("PurchaseOrderCreated")},
+			":status_updated": {S: aws.String("PurchaseOrderStatusUpdated")},
+			":completed":      {S: aws.String(string(models.PurchaseOrderCompletedEventType))},
+			":overdue":        {S: aws.String(models.PurchaseOrderOverdueEventType)},
+			":start_date":     {S: aws.String(q.StartDate.UTC().Format(time.RFC3339Nano))},
+			":end_date":       {S: aws.String(q.EndDate.UTC().Format(time.RFC3339Nano))},
+		},
+	}
+
+	// An order moving from received to completed is only counted once
+	completed := make(map[string]bool)
+	err := scanAll(ctx, q.DynamoDB, q.ScanSegments, scanInput, func(items []map[string]*dynamodb.AttributeValue) error {
+		for _, item := range items {
+			var event timeSeriesEvent
+			if err := dynamodbattribute.UnmarshalMap(item, &event); err != nil {
+				q.Logger.WithError(err).Error("Failed to unmarshal event")
+				continue
+			}
+
+			period := index[q.periodStart(event.Timestamp).Format(models.StatsDayLayout)]
+			if period == nil {
+				continue
+			}
+
+			switch event.EventType {
+			case "PurchaseOrderCreated":
+				period.Created++
+			case models.PurchaseOrderOverdueEventType:
+				period.Overdue++
+			case "PurchaseOrderStatusUpdated", string(models.PurchaseOrderCompletedEventType):
+				switch event.EventData.StatusChange.NewStatus {
+				case models.StatusReceived, models.StatusCompleted:
+					if !completed[event.AggregateID] {
+						completed[event.AggregateID] = true
+						period.Completed++
+					}
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		q.Logger.WithError(err).Error("Failed to scan purchase order events")
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"success":  true,
+		"interval": q.Interval,
+		"periods":  periods,
+		"count":    len(periods),
+	}, nil
+}
@@ -0,0 +1,91 @@
+package cqrs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"orden-compra/internal/memory"
+	"orden-compra/internal/models"
+)
+
+// putEvent stores an event of an order at a time
+func putEvent(t *testing.T, dynamoDB *memory.DynamoDB, aggregateID, eventType, newStatus string, at time.Time) {
+	t.Helper()
+	data := map[string]interface{}{}
+	if newStatus != "" {
+		data["status_change"] = map[string]interface{}{"new_status": newStatus}
+	}
+	putItem(t, dynamoDB, "orden-compra-events", models.NewEventSourcingEvent(aggregateID, eventType, data, nil, nil, at))
+}
+
+func timeSeries(t *testing.T, dynamoDB *memory.DynamoDB, start, end time.Time, interval string) []*models.StatsPeriod {
+	t.Helper()
+	result, err := NewGetPurchaseOrderTimeSeriesQuery(start, end, interval, dynamoDB, discardLogrus).Execute(context.Background())
+	if err != nil {
+		t.Fatalf("time series: %v", err)
+	}
+	return result["periods"].([]*models.StatsPeriod)
+}
+
+func TestPurchaseOrderTimeSeries(t *testing.T) {
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	// statsDay is a Monday
+	tuesday := statsDay.AddDate(0, 0, 1)
+	putEvent(t, dynamoDB, "po-1", "PurchaseOrderCreated", "", statsDay)
+	putEvent(t, dynamoDB, "po-2", "PurchaseOrderCreated", "", statsDay.Add(time.Hour))
+	putEvent(t, dynamoDB, "po-3", "PurchaseOrderCreated", "", tuesday)
+	// po-1 is received and then completed, and only counted once
+	putEvent(t, dynamoDB, "po-1", "PurchaseOrderStatusUpdated", models.StatusReceived, tuesday)
+	putEvent(t, dynamoDB, "po-1", string(models.PurchaseOrderCompletedEventType), models.StatusCompleted, tuesday.Add(time.Hour))
+	putEvent(t, dynamoDB, "po-2", "PurchaseOrderStatusUpdated", models.StatusApproved, tuesday)
+	putEvent(t, dynamoDB, "po-2", models.PurchaseOrderOverdueEventType, "", statsDay.AddDate(0, 0, 3))
+	// Outside the range
+	putEvent(t, dynamoDB, "po-4", "PurchaseOrderCreated", "", statsDay.AddDate(0, 0, -1))
+
+	days := timeSeries(t, dynamoDB, statsDay, statsDay.AddDate(0, 0, 3).Add(time.Hour), IntervalDay)
+	want := []models.StatsPeriod{
+		{Start: "2026-03-02", Created: 2},
+		{Start: "2026-03-03", Created: 1, Completed: 1},
+		{Start: "2026-03-04"},
+		{Start: "2026-03-05", Overdue: 1},
+	}
+	if len(days) != len(want) {
+		t.Fatalf("%d daily periods, want %d", len(days), len(want))
+	}
+	for i, period := range days {
+		if *period != want[i] {
+			t.Fatalf("period %d is %+v, want %+v", i, *period, want[i])
+		}
+	}
+
+	// Weeks start on Monday, even when the range starts midweek
+	weeks := timeSeries(t, dynamoDB, tuesday, statsDay.AddDate(0, 0, 7), IntervalWeek)
+	if len(weeks) != 2 || weeks[0].Start != "2026-03-02" || weeks[1].Start != "2026-03-09" {
+		t.Fatalf("weeks %+v %+v", weeks[0], weeks[len(weeks)-1])
+	}
+	if weeks[0].Created != 1 || weeks[0].Completed != 1 || weeks[0].Overdue != 1 {
+		t.Fatalf("first week %+v", *weeks[0])
+	}
+}
+
+func TestPurchaseOrderTimeSeriesRejectsInvalidRanges(t *testing.T) {
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	for _, tc := range []struct {
+		name       string
+		start, end time.Time
+		interval   string
+	}{
+		{"unknown interval", statsDay, statsDay, "month"},
+		{"ends before it starts", statsDay, statsDay.Add(-time.Hour), IntervalDay},
+		{"too many periods", statsDay, statsDay.AddDate(2, 0, 0), IntervalDay},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NewGetPurchaseOrderTimeSeriesQuery(tc.start, tc.end, tc.interval, dynamoDB, discardLogrus).Execute(context.Background())
+			if !errors.Is(err, ErrInvalidTimeSeries) {
+				t.Fatalf("returned %v, want ErrInvalidTimeSeries", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,207 @@
+// Package deadletter reads the rejected messages recorded in the dead letter
+// table so operators can inspect, replay or discard them.
+package deadletter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+
+	"orden-compra/internal/models"
+)
+
+// ErrDeadLetterNotFound is returned when a dead letter record does not exist
+var ErrDeadLetterNotFound = errors.New("dead letter not found")
+
+// ErrNotReplayable is returned for records that were already replayed, or
+// that predate replay and do not name the queue they were consumed from
+var ErrNotReplayable = errors.New("dead letter cannot be replayed")
+
+// Store reads and resolves dead letter records in DynamoDB
+type Store struct {
+	DynamoDB  dynamodbiface.DynamoDBAPI
+	TableName string
+}
+
+// NewStore creates a store on the default dead letter table
+func NewStore(dynamoDB dynamodbiface.DynamoDBAPI) *Store {
+	return &Store{
+		DynamoDB:  dynamoDB,
+		TableName: "orden-compra-dead-letters",
+	}
+}
+
+// Filter narrows the records returned by Query; empty fields match everything
+type Filter struct {
+	Status string
+	Reason string
+	Queue  string
+	From   *time.Time
+	To     *time.Time
+	Limit  int
+}
+
+// Query returns the records matching the filter, newest first
+func (s *Store) Query(ctx context.Context, filter Filter) ([]*models.DeadLetterRecord, error) {
+	scanInput := &dynamodb.ScanInput{
+		TableName: aws.String(s.TableName),
+	}
+
+	var filterExpressions []string
+	expressionAttributeNames := make(map[string]*string)
+	expressionAttributeValues := make(map[string]*dynamodb.AttributeValue)
+
+	addEquals := func(attribute, value string) {
+		if value == "" {
+			return
+		}
+		filterExpressions = append(filterExpressions, fmt.Sprintf("#%s = :%s", attribute, attribute))
+		expressionAttributeNames["#"+attribute] = aws.String(attribute)
+		expressionAttributeValues[":"+attribute] = &dynamodb.AttributeValue{S: aws.String(value)}
+	}
+	addEquals("reason", filter.Reason)
+	addEquals("queue", filter.Queue)
+
+	// Records written before replay have no status and are still failed
+	switch filter.Status {
+	case "":
+	case models.DeadLetterFailed:
+		filterExpressions = append(filterExpressions, "(attribute_not_exists(#status) OR #status = :status)")
+		expressionAttributeNames["#status"] = aws.String("status")
+		expressionAttributeValues[":status"] = &dynamodb.AttributeValue{S: aws.String(filter.Status)}
+	default:
+		addEquals("status", filter.Status)
+	}
+
+	if filter.From != nil || filter.To != nil {
+		expressionAttributeNames["#received_at"] = aws.String("received_at")
+	}
+	if filter.From != nil {
+		filterExpressions = append(filterExpressions, "#received_at >= :from")
+		expressionAttributeValues[":from"] = &dynamodb.AttributeValue{S: aws.String(filter.From.UTC().Format(time.RFC3339Nano))}
+	}
+	if filter.To != nil {
+		filterExpressions = append(filterExpressions, "#received_at <= :to")
+		expressionAttributeValues[":to"] = &dynamodb.AttributeValue{S: aws.String(filter.To.UTC().Format(time.RFC3339Nano))}
+	}
+
+	if len(filterExpressions) > 0 {
+		scanInput.FilterExpression = aws.String(strings.Join(filterExpressions, " AND "))
+		scanInput.ExpressionAttributeNames = expressionAttributeNames
+		scanInput.ExpressionAttributeValues = expressionAttributeValues
+	}
+
+	records := make([]*models.DeadLetterRecord, 0)
+	for {
+		result, err := s.DynamoDB.ScanWithContext(ctx, scanInput)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan dead letters: %w", err)
+		}
+
+		for _, item := range result.Items {
+			var record models.DeadLetterRecord
+			if err := dynamodbattribute.UnmarshalMap(item, &record); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal dead letter record: %w", err)
+			}
+			record.Normalize()
+			records = append(records, &record)
+		}
+
+		if len(result.LastEvaluatedKey) == 0 {
+			break
+		}
+		scanInput.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].ReceivedAt.After(records[j].ReceivedAt)
+	})
+	if filter.Limit > 0 && len(records) > filter.Limit {
+		records = records[:filter.Limit]
+	}
+
+	return records, nil
+}
+
+// Get returns a record by ID
+func (s *Store) Get(ctx context.Context, id string) (*models.DeadLetterRecord, error) {
+	result, err := s.DynamoDB.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(s.TableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {S: aws.String(id)},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dead letter record: %w", err)
+	}
+	if result.Item == nil {
+		return nil, ErrDeadLetterNotFound
+	}
+
+	var record models.DeadLetterRecord
+	if err := dynamodbattribute.UnmarshalMap(result.Item, &record); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal dead letter record: %w", err)
+	}
+	record.Normalize()
+
+	return &record, nil
+}
+
+// MarkReplayed records that a failed record was published again. The
+// record is kept until its retention expires so the replay can be traced.
+func (s *Store) MarkReplayed(ctx context.Context, id string, replayedAt time.Time) error {
+	_, err := s.DynamoDB.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(s.TableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {S: aws.String(id)},
+		},
+		UpdateExpression:    aws.String("SET #status = :replayed, replayed_at = :replayed_at"),
+		ConditionExpression: aws.String("attribute_exists(id) AND (attribute_not_exists(#status) OR #status = :failed)"),
+		ExpressionAttributeNames: map[string]*string{
+			"#status": aws.String("status"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":replayed":    {S: aws.String(models.DeadLetterReplayed)},
+			":failed":      {S: aws.String(models.DeadLetterFailed)},
+			":replayed_at": {S: aws.String(replayedAt.UTC().Format(time.RFC3339Nano))},
+		},
+	})
+	if err != nil {
+		var awsErr awserr.Error
+		if errors.As(err, &awsErr) && awsErr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return ErrNotReplayable
+		}
+		return fmt.Errorf("failed to update dead letter record: %w", err)
+	}
+
+	return nil
+}
+
+// Delete removes a record
+func (s *Store) Delete(ctx context.Context, id string) error {
+	_, err := s.DynamoDB.DeleteItemWithContext(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(s.TableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {S: aws.String(id)},
+		},
+		ConditionExpression: aws.String("attribute_exists(id)"),
+	})
+	if err != nil {
+		var awsErr awserr.Error
+		if errors.As(err, &awsErr) && awsErr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return ErrDeadLetterNotFound
+		}
+		return fmt.Errorf("failed to delete dead letter record: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,117 @@
+package deadletter
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+
+	"orden-compra/internal/memory"
+	"orden-compra/internal/models"
+)
+
+var receivedAt = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// putRecord stores a dead letter received the given time after receivedAt
+func putRecord(t *testing.T, store *Store, id, queue, reason, status string, after time.Duration) {
+	t.Helper()
+	record := models.NewDeadLetterRecord("message-"+id, queue, "stock.bajo", "application/json", reason, nil, []byte("{}"), nil, "", receivedAt.Add(after))
+	record.ID = id
+	record.Status = status
+	item, err := dynamodbattribute.MarshalMap(record)
+	if err != nil {
+		t.Fatalf("marshal record: %v", err)
+	}
+	if status == "" {
+		// Records written before replay have no status
+		delete(item, "status")
+	}
+	if _, err := store.DynamoDB.PutItemWithContext(context.Background(), &dynamodb.PutItemInput{TableName: aws.String(store.TableName), Item: item}); err != nil {
+		t.Fatalf("put record: %v", err)
+	}
+}
+
+func ids(records []*models.DeadLetterRecord) []string {
+	ids := make([]string, 0, len(records))
+	for _, record := range records {
+		ids = append(ids, record.ID)
+	}
+	return ids
+}
+
+func TestQuery(t *testing.T) {
+	store := NewStore(memory.NewDynamoDB(memory.Tables))
+	putRecord(t, store, "dl-1", "stock-bajo-queue", "validation_failed", "", 0)
+	putRecord(t, store, "dl-2", "stock-bajo-queue", "malformed_payload", models.DeadLetterFailed, time.Hour)
+	putRecord(t, store, "dl-3", "recepcion-queue", "validation_failed", models.DeadLetterReplayed, 2*time.Hour)
+
+	from, to := receivedAt.Add(30*time.Minute), receivedAt.Add(90*time.Minute)
+	for _, tc := range []struct {
+		name   string
+		filter Filter
+		want   []string
+	}{
+		{"everything, newest first", Filter{}, []string{"dl-3", "dl-2", "dl-1"}},
+		{"failed includes records without a status", Filter{Status: models.DeadLetterFailed}, []string{"dl-2", "dl-1"}},
+		{"replayed", Filter{Status: models.DeadLetterReplayed}, []string{"dl-3"}},
+		{"reason", Filter{Reason: "validation_failed"}, []string{"dl-3", "dl-1"}},
+		{"queue", Filter{Queue: "stock-bajo-queue"}, []string{"dl-2", "dl-1"}},
+		{"received between", Filter{From: &from, To: &to}, []string{"dl-2"}},
+		{"limit", Filter{Limit: 1}, []string{"dl-3"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			records, err := store.Query(context.Background(), tc.filter)
+			if err != nil {
+				t.Fatalf("query: %v", err)
+			}
+			if got := ids(records); !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("records %v, want %v", got, tc.want)
+			}
+			for _, record := range records {
+				if record.Status == "" {
+					t.Fatalf("record %s has no status", record.ID)
+				}
+			}
+		})
+	}
+}
+
+func TestReplayAndDelete(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore(memory.NewDynamoDB(memory.Tables))
+	putRecord(t, store, "dl-1", "stock-bajo-queue", "validation_failed", "", 0)
+
+	if record, err := store.Get(ctx, "dl-1"); err != nil || record.Status != models.DeadLetterFailed {
+		t.Fatalf("record %+v, error %v", record, err)
+	}
+	replayedAt := receivedAt.Add(time.Hour)
+	if err := store.MarkReplayed(ctx, "dl-1", replayedAt); err != nil {
+		t.Fatalf("mark replayed: %v", err)
+	}
+	record, err := store.Get(ctx, "dl-1")
+	if err != nil || record.Status != models.DeadLetterReplayed || record.ReplayedAt == nil || !record.ReplayedAt.Equal(replayedAt) {
+		t.Fatalf("record %+v, error %v", record, err)
+	}
+	// A record is replayed once
+	if err := store.MarkReplayed(ctx, "dl-1", replayedAt); !errors.Is(err, ErrNotReplayable) {
+		t.Fatalf("replaying twice returned %v", err)
+	}
+	if err := store.MarkReplayed(ctx, "dl-missing", replayedAt); !errors.Is(err, ErrNotReplayable) {
+		t.Fatalf("replaying a missing record returned %v", err)
+	}
+
+	if err := store.Delete(ctx, "dl-1"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := store.Get(ctx, "dl-1"); !errors.Is(err, ErrDeadLetterNotFound) {
+		t.Fatalf("get after delete returned %v", err)
+	}
+	if err := store.Delete(ctx, "dl-1"); !errors.Is(err, ErrDeadLetterNotFound) {
+		t.Fatalf("deleting twice returned %v", err)
+	}
+}
@@ -0,0 +1,154 @@
+// Package deadline bounds how long DynamoDB calls may take. Calls get the
+// deadline configured for their operation unless the caller's context ends
+// sooner, so work started from a context without a deadline, such as a
+// consumed message, cannot hang on a slow request.
+package deadline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// ErrInvalidConfig is returned for unusable timeout settings
+var ErrInvalidConfig = errors.New("invalid timeout configuration")
+
+// Config represents the per-operation timeouts. A timeout covers the SDK's
+// retries of the call as well.
+type Config struct {
+	// Default applies to operations without their own timeout; 0 leaves
+	// them without one
+	Default time.Duration
+	// Operations maps DynamoDB operation names, such as Scan or
+	// TransactWriteItems, to their timeout
+	Operations map[string]time.Duration
+}
+
+// ParseOperations parses "Operation=duration" entries, such as
+// "Scan=30s", into per-operation timeouts
+func ParseOperations(entries []string) (map[string]time.Duration, error) {
+	operations := make(map[string]time.Duration, len(entries))
+	for _, entry := range entries {
+		operation, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("%w: %q is not Operation=duration", ErrInvalidConfig, entry)
+		}
+		timeout, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil || timeout < 0 {
+			return nil, fmt.Errorf("%w: invalid timeout for %s: %q", ErrInvalidConfig, operation, value)
+		}
+		operations[strings.TrimSpace(operation)] = timeout
+	}
+	return operations, nil
+}
+
+// Timeout returns the timeout of an operation
+func (c Config) Timeout(operation string) time.Duration {
+	if timeout, ok := c.Operations[operation]; ok {
+		return timeout
+	}
+	return c.Default
+}
+
+// TimedDynamoDB wraps a DynamoDB client so data-plane calls run under their
+// operation's timeout
+type TimedDynamoDB struct {
+	dynamodbiface.DynamoDBAPI
+	Config Config
+}
+
+// NewTimedDynamoDB creates a new TimedDynamoDB
+func NewTimedDynamoDB(client dynamodbiface.DynamoDBAPI, config Config) *TimedDynamoDB {
+	return &TimedDynamoDB{
+		DynamoDBAPI: client,
+		Config:      config,
+	}
+}
+
+// withTimeout derives the context a call runs under
+func (d *TimedDynamoDB) withTimeout(ctx aws.Context, operation string) (context.Context, context.CancelFunc) {
+	timeout := d.Config.Timeout(operation)
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// GetItemWithContext gets an item within the GetItem timeout
+func (d *TimedDynamoDB) GetItemWithContext(ctx aws.Context, input *dynamodb.GetItemInput, opts ...request.Option) (*dynamodb.GetItemOutput, error) {
+	ctx, cancel := d.withTimeout(ctx, "GetItem")
+	defer cancel()
+
+	return d.DynamoDBAPI.GetItemWithContext(ctx, input, opts...)
+}
+
+// PutItemWithContext puts an item within the PutItem timeout
+func (d *TimedDynamoDB) PutItemWithContext(ctx aws.Context, input *dynamodb.PutItemInput, opts ...request.Option) (*dynamodb.PutItemOutput, error) {
+	ctx, cancel := d.withTimeout(ctx, "PutItem")
+	defer cancel()
+
+	return d.DynamoDBAPI.PutItemWithContext(ctx, input, opts...)
+}
+
+// UpdateItemWithContext updates an item within the UpdateItem timeout
+func (d *TimedDynamoDB) UpdateItemWithContext(ctx aws.Context, input *dynamodb.UpdateItemInput, opts ...request.Option) (*dynamodb.UpdateItemOutput, error) {
+	ctx, cancel := d.withTimeout(ctx, "UpdateItem")
+	defer cancel()
+
+	return d.DynamoDBAPI.UpdateItemWithContext(ctx, input, opts...)
+}
+
+// DeleteItemWithContext deletes an item within the DeleteItem timeout
+func (d *TimedDynamoDB) DeleteItemWithContext(ctx aws.Context, input *dynamodb.DeleteItemInput, opts ...request.Option) (*dynamodb.DeleteItemOutput, error) {
+	ctx, cancel := d.withTimeout(ctx, "DeleteItem")
+	defer cancel()
+
+	return d.DynamoDBAPI.DeleteItemWithContext(ctx, input, opts...)
+}
+
+// QueryWithContext runs a query within the Query timeout
+func (d *TimedDynamoDB) QueryWithContext(ctx aws.Context, input *dynamodb.QueryInput, opts ...request.Option) (*dynamodb.QueryOutput, error) {
+	ctx, cancel := d.withTimeout(ctx, "Query")
+	defer cancel()
+
+	return d.DynamoDBAPI.QueryWithContext(ctx, input, opts...)
+}
+
+// ScanWithContext runs a scan within the Scan timeout
+func (d *TimedDynamoDB) ScanWithContext(ctx aws.Context, input *dynamodb.ScanInput, opts ...request.Option) (*dynamodb.ScanOutput, error) {
+	ctx, cancel := d.withTimeout(ctx, "Scan")
+	defer cancel()
+
+	return d.DynamoDBAPI.ScanWithContext(ctx, input, opts...)
+}
+
+// BatchWriteItemWithContext writes a batch within the BatchWriteItem timeout
+func (d *TimedDynamoDB) BatchWriteItemWithContext(ctx aws.Context, input *dynamodb.BatchWriteItemInput, opts ...request.Option) (*dynamodb.BatchWriteItemOutput, error) {
+	ctx, cancel := d.withTimeout(ctx, "BatchWriteItem")
+	defer cancel()
+
+	return d.DynamoDBAPI.BatchWriteItemWithContext(ctx, input, opts...)
+}
+
+// BatchGetItemWithContext reads a batch within the BatchGetItem timeout
+func (d *TimedDynamoDB) BatchGetItemWithContext(ctx aws.Context, input *dynamodb.BatchGetItemInput, opts ...request.Option) (*dynamodb.BatchGetItemOutput, error) {
+	ctx, cancel := d.withTimeout(ctx, "BatchGetItem")
+	defer cancel()
+
+	return d.DynamoDBAPI.BatchGetItemWithContext(ctx, input, opts...)
+}
+
+// TransactWriteItemsWithContext runs a transaction within the TransactWriteItems timeout
+func (d *TimedDynamoDB) TransactWriteItemsWithContext(ctx aws.Context, input *dynamodb.TransactWriteItemsInput, opts ...request.Option) (*dynamodb.TransactWriteItemsOutput, error) {
+	ctx, cancel := d.withTimeout(ctx, "TransactWriteItems")
+	defer cancel()
+
+	return d.DynamoDBAPI.TransactWriteItemsWithContext(ctx, input, opts...)
+}
@@ -0,0 +1,93 @@
+package deadline
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// deadlineDynamoDB records how long each call had left before its deadline
+type deadlineDynamoDB struct {
+	dynamodbiface.DynamoDBAPI
+	remaining map[string]time.Duration
+}
+
+func (d *deadlineDynamoDB) record(ctx aws.Context, operation string) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		d.remaining[operation] = 0
+		return
+	}
+	d.remaining[operation] = time.Until(deadline)
+}
+
+func (d *deadlineDynamoDB) GetItemWithContext(ctx aws.Context, input *dynamodb.GetItemInput, opts ...request.Option) (*dynamodb.GetItemOutput, error) {
+	d.record(ctx, "GetItem")
+	return &dynamodb.GetItemOutput{}, nil
+}
+
+func (d *deadlineDynamoDB) ScanWithContext(ctx aws.Context, input *dynamodb.ScanInput, opts ...request.Option) (*dynamodb.ScanOutput, error) {
+	d.record(ctx, "Scan")
+	return &dynamodb.ScanOutput{}, nil
+}
+
+func (d *deadlineDynamoDB) TransactWriteItemsWithContext(ctx aws.Context, input *dynamodb.TransactWriteItemsInput, opts ...request.Option) (*dynamodb.TransactWriteItemsOutput, error) {
+	d.record(ctx, "TransactWriteItems")
+	return &dynamodb.TransactWriteItemsOutput{}, nil
+}
+
+func TestParseOperations(t *testing.T) {
+	operations, err := ParseOperations([]string{"Scan=1m", " Query = 10s "})
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if want := map[string]time.Duration{"Scan": time.Minute, "Query": 10 * time.Second}; !reflect.DeepEqual(operations, want) {
+		t.Fatalf("operations %v, want %v", operations, want)
+	}
+
+	for _, entry := range []string{"Scan", "Scan=soon", "Scan=-1s"} {
+		if _, err := ParseOperations([]string{entry}); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("parsing %q returned %v, want ErrInvalidConfig", entry, err)
+		}
+	}
+}
+
+func TestTimedDynamoDBAppliesTheOperationsTimeout(t *testing.T) {
+	client := &deadlineDynamoDB{remaining: make(map[string]time.Duration)}
+	db := NewTimedDynamoDB(client, Config{Default: 5 * time.Second, Operations: map[string]time.Duration{"Scan": 30 * time.Second, "TransactWriteItems": 0}})
+	ctx := context.Background()
+
+	db.GetItemWithContext(ctx, &dynamodb.GetItemInput{})
+	db.ScanWithContext(ctx, &dynamodb.ScanInput{})
+	db.TransactWriteItemsWithContext(ctx, &dynamodb.TransactWriteItemsInput{})
+
+	for _, tc := range []struct {
+		operation string
+		timeout   time.Duration
+	}{
+		{"GetItem", 5 * time.Second},
+		{"Scan", 30 * time.Second},
+		// A zero timeout leaves the operation without one
+		{"TransactWriteItems", 0},
+	} {
+		remaining := client.remaining[tc.operation]
+		if remaining > tc.timeout || remaining < tc.timeout-time.Second {
+			t.Errorf("%s had %s left, want its %s timeout", tc.operation, remaining, tc.timeout)
+		}
+	}
+
+	// A caller's earlier deadline is kept
+	short, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	db.ScanWithContext(short, &dynamodb.ScanInput{})
+	if remaining := client.remaining["Scan"]; remaining > time.Second {
+		t.Fatalf("scan had %s left, want the caller's deadline", remaining)
+	}
+}
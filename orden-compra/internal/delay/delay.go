@@ -0,0 +1,190 @@
+// Package delay publishes RabbitMQ messages that reach their queue after a
+// delay, so retries and scheduled work need no external scheduler. It uses
+// the rabbitmq_delayed_message_exchange plugin when the broker has it, and
+// otherwise one queue per delay whose expired messages are dead-lettered to
+// the destination queue.
+package delay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/rabbitmq/amqp091-go"
+
+	"medisupply/messaging"
+)
+
+// ErrInvalidDelay is returned for negative delays and delays above the maximum
+var ErrInvalidDelay = errors.New("invalid delay")
+
+// Mode selects how delayed messages are held until they are due
+type Mode string
+
+const (
+	// ModeTTL holds messages in per-delay queues with a message TTL and the
+	// destination as dead-letter target; it works on any broker
+	ModeTTL Mode = "ttl"
+	// ModePlugin holds messages in an x-delayed-message exchange
+	ModePlugin Mode = "plugin"
+)
+
+// ParseMode parses a delay mode
+func ParseMode(value string) (Mode, error) {
+	switch mode := Mode(value); mode {
+	case ModeTTL, ModePlugin:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown delay mode %q, expected %s or %s", value, ModeTTL, ModePlugin)
+}
+
+// Config represents the delayed publishing settings
+type Config struct {
+	Mode Mode
+	// Exchange is the x-delayed-message exchange of plugin mode
+	Exchange string
+	// Granularity rounds delays up in TTL mode, bounding the number of delay queues
+	Granularity time.Duration
+	// MaxDelay is the longest delay accepted
+	MaxDelay time.Duration
+}
+
+// Publisher publishes messages to queues after a delay
+type Publisher struct {
+	Channel  *amqp091.Channel
+	Confirms messaging.Sender
+	Config   Config
+	Logger   *log.Logger
+
+	mu    sync.Mutex
+	bound map[string]bool
+}
+
+// NewPublisher creates a publisher on its own channel, publishing in confirm
+// mode. In plugin mode it declares the delayed-message exchange, which fails
+// when the broker lacks the plugin.
+func NewPublisher(connection *amqp091.Connection, config Config, confirms messaging.PublisherConfig, logger *log.Logger) (*Publisher, error) {
+	channel, err := connection.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open delay channel: %w", err)
+	}
+
+	confirmed, err := messaging.NewPublisher(channel, confirms, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.Mode == ModePlugin {
+		err = channel.ExchangeDeclare(
+			config.Exchange,     // name
+			"x-delayed-message", // type
+			true,                // durable
+			false,               // auto-deleted
+			false,               // internal
+			false,               // no-wait
+			amqp091.Table{"x-delayed-type": "direct"}, // arguments
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to declare delayed message exchange: %w", err)
+		}
+	}
+
+	return &Publisher{
+		Channel:  channel,
+		Confirms: confirmed,
+		Config:   config,
+		Logger:   logger,
+		bound:    make(map[string]bool),
+	}, nil
+}
+
+// Publish delivers msg to queue once delay has passed; a zero delay
+// delivers it at once
+func (p *Publisher) Publish(ctx context.Context, queue string, delay time.Duration, msg amqp091.Publishing) error {
+	if delay < 0 || delay > p.Config.MaxDelay {
+		return fmt.Errorf("%w: %v must be between 0 and %v", ErrInvalidDelay, delay, p.Config.MaxDelay)
+	}
+	if delay == 0 {
+		return p.publish(ctx, "", queue, msg)
+	}
+
+	if p.Config.Mode == ModePlugin {
+		if err := p.bind(queue); err != nil {
+			return err
+		}
+		headers := make(amqp091.Table, len(msg.Headers)+1)
+		for key, value := range msg.Headers {
+			headers[key] = value
+		}
+		headers["x-delay"] = delay.Milliseconds()
+		msg.Headers = headers
+		return p.publish(ctx, p.Config.Exchange, queue, msg)
+	}
+
+	delayQueue, err := p.declareDelayQueue(queue, delay)
+	if err != nil {
+		return err
+	}
+	return p.publish(ctx, "", delayQueue, msg)
+}
+
+// bind routes the delayed-message exchange to a queue under the queue's name
+func (p *Publisher) bind(queue string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.bound[queue] {
+		return nil
+	}
+	if err := p.Channel.QueueBind(queue, queue, p.Config.Exchange, false, nil); err != nil {
+		return fmt.Errorf("failed to bind %s to delayed message exchange: %w", queue, err)
+	}
+	p.bound[queue] = true
+	return nil
+}
+
+// declareDelayQueue declares the queue holding messages for queue during
+// delay, rounded up to the granularity, and returns its name. It is declared
+// on every publish because publishing alone does not keep x-expires from
+// deleting it; it expires an hour after its messages once unused.
+func (p *Publisher) declareDelayQueue(queue string, delay time.Duration) (string, error) {
+	if p.Config.Granularity > 0 && delay%p.Config.Granularity != 0 {
+		delay = delay.Truncate(p.Config.Granularity) + p.Config.Granularity
+	}
+
+	name := fmt.Sprintf("%s.delay.%dms", queue, delay.Milliseconds())
+	_, err := p.Channel.QueueDeclare(
+		name,  // name
+		true,  // durable
+		false, // delete when unused
+		false, // exclusive
+		false, // no-wait
+		amqp091.Table{
+			"x-message-ttl":             delay.Milliseconds(),
+			"x-dead-letter-exchange":    "",
+			"x-dead-letter-routing-key": queue,
+			"x-expires":                 (delay + time.Hour).Milliseconds(),
+		}, // arguments
+	)
+	if err != nil {
+		return "", fmt.Errorf("failed to declare delay queue %s: %w", name, err)
+	}
+	return name, nil
+}
+
+// publish publishes a persistent message and waits for its confirmation
+func (p *Publisher) publish(ctx context.Context, exchange, routingKey string, msg amqp091.Publishing) error {
+	msg.DeliveryMode = amqp091.Persistent
+	if err := p.Confirms.Publish(ctx, exchange, routingKey, msg); err != nil {
+		return fmt.Errorf("failed to publish delayed message: %w", err)
+	}
+	return nil
+}
+
+// Close closes the publisher's channel
+func (p *Publisher) Close() error {
+	return p.Channel.Close()
+}
@@ -0,0 +1,111 @@
+package delay
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/rabbitmq/amqp091-go"
+)
+
+// published is one message a test sender got
+type published struct {
+	exchange   string
+	routingKey string
+	msg        amqp091.Publishing
+}
+
+// recordingSender records published messages
+type recordingSender struct {
+	published []published
+}
+
+func (s *recordingSender) Publish(ctx context.Context, exchange, routingKey string, msg amqp091.Publishing) error {
+	s.published = append(s.published, published{exchange, routingKey, msg})
+	return nil
+}
+
+// newTestPublisher returns a publisher whose queues are already bound, so it
+// needs no channel
+func newTestPublisher(mode Mode, queues ...string) (*Publisher, *recordingSender) {
+	sender := &recordingSender{}
+	publisher := &Publisher{
+		Confirms: sender,
+		Config:   Config{Mode: mode, Exchange: "orden-compra.delayed", Granularity: time.Second, MaxDelay: time.Hour},
+		Logger:   log.New(io.Discard, "", 0),
+		bound:    make(map[string]bool),
+	}
+	for _, queue := range queues {
+		publisher.bound[queue] = true
+	}
+	return publisher, sender
+}
+
+func TestParseMode(t *testing.T) {
+	for _, value := range []string{"ttl", "plugin"} {
+		if mode, err := ParseMode(value); err != nil || string(mode) != value {
+			t.Fatalf("ParseMode(%q) = %q, %v", value, mode, err)
+		}
+	}
+	if _, err := ParseMode("cron"); err == nil {
+		t.Fatal("ParseMode accepted an unknown mode")
+	}
+}
+
+func TestPublishDelaysThroughThePlugin(t *testing.T) {
+	publisher, sender := newTestPublisher(ModePlugin, "orden-compra.stock-bajo")
+	headers := amqp091.Table{"correlation-id": "correlation-1"}
+
+	err := publisher.Publish(context.Background(), "orden-compra.stock-bajo", 90*time.Second, amqp091.Publishing{MessageId: "message-1", Headers: headers})
+	if err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	got := sender.published[0]
+	if got.exchange != "orden-compra.delayed" || got.routingKey != "orden-compra.stock-bajo" || got.msg.DeliveryMode != amqp091.Persistent {
+		t.Fatalf("published %+v", got)
+	}
+	if got.msg.Headers["x-delay"] != int64(90000) || got.msg.Headers["correlation-id"] != "correlation-1" {
+		t.Fatalf("headers %v", got.msg.Headers)
+	}
+	// The caller's headers are left alone
+	if _, ok := headers["x-delay"]; ok {
+		t.Fatal("x-delay added to the caller's headers")
+	}
+}
+
+func TestPublishWithoutADelay(t *testing.T) {
+	for _, mode := range []Mode{ModeTTL, ModePlugin} {
+		publisher, sender := newTestPublisher(mode)
+		if err := publisher.Publish(context.Background(), "orden-compra.stock-bajo", 0, amqp091.Publishing{MessageId: "message-1"}); err != nil {
+			t.Fatalf("%s Publish: %v", mode, err)
+		}
+		// Due messages go straight to the queue through the default exchange
+		if got := sender.published[0]; got.exchange != "" || got.routingKey != "orden-compra.stock-bajo" || got.msg.Headers != nil {
+			t.Fatalf("%s published %+v", mode, got)
+		}
+	}
+}
+
+func TestPublishRejectsInvalidDelays(t *testing.T) {
+	publisher, sender := newTestPublisher(ModePlugin, "orden-compra.stock-bajo")
+	for _, wait := range []time.Duration{-time.Second, 2 * time.Hour} {
+		if err := publisher.Publish(context.Background(), "orden-compra.stock-bajo", wait, amqp091.Publishing{}); !errors.Is(err, ErrInvalidDelay) {
+			t.Fatalf("delay %v returned %v", wait, err)
+		}
+	}
+	if len(sender.published) != 0 {
+		t.Fatalf("published %d messages with invalid delays", len(sender.published))
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 5, InitialDelay: time.Second, MaxDelay: 5 * time.Second}
+	for attempt, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 4: 5 * time.Second, 10: 5 * time.Second} {
+		if got := policy.Delay(attempt); got != want {
+			t.Errorf("delay of attempt %d is %v, want %v", attempt, got, want)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package delay
+
+import "time"
+
+// RetryAttemptHeader counts how many times a message has been retried
+const RetryAttemptHeader = "x-retry-attempt"
+
+// RetryPolicy spaces the retries of messages that failed to process
+type RetryPolicy struct {
+	// MaxAttempts is the number of retries before a message is dead-lettered
+	MaxAttempts  int
+	InitialDelay time.Duration
+	MaxDelay     time.Duration
+}
+
+// Delay returns the wait before a retry, doubling from InitialDelay up to MaxDelay
+func (p RetryPolicy) Delay(attempt int) time.Duration {
+	delay := p.InitialDelay
+	for i := 1; i < attempt && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, p.MaxDelay)
+}
@@ -0,0 +1,191 @@
+package dispatch
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"orden-compra/internal/edi"
+	"orden-compra/internal/models"
+)
+
+// startSMTP serves just enough SMTP for net/smtp.SendMail and returns its
+// address and the message data it receives
+func startSMTP(t *testing.T) (string, <-chan string) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	received := make(chan string, 10)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				reader := bufio.NewReader(conn)
+				reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+				reply("220 localhost ready")
+				for {
+					line, err := reader.ReadString('\n')
+					if err != nil {
+						return
+					}
+					switch command := strings.ToUpper(strings.TrimSpace(line)); {
+					case command == "DATA":
+						reply("354 end with .")
+						var data strings.Builder
+						for {
+							line, err := reader.ReadString('\n')
+							if err != nil || line == ".\r\n" {
+								break
+							}
+							data.WriteString(line)
+						}
+						received <- data.String()
+						reply("250 OK")
+					case command == "QUIT":
+						reply("221 bye")
+						return
+					default:
+						reply("250 localhost")
+					}
+				}
+			}()
+		}
+	}()
+	return listener.Addr().String(), received
+}
+
+func testOrder(supplier *models.Supplier) *Order {
+	return &Order{
+		PurchaseOrder: &models.PurchaseOrder{ID: "po-1", ProductID: "product-1", ProductName: "Gloves", SupplierID: "supplier-1", SupplierName: "Acme", Location: "warehouse-1", Quantity: 10, UrgencyLevel: "HIGH"},
+		Supplier:      supplier,
+	}
+}
+
+func TestEmailChannelSendsTheRenderedOrder(t *testing.T) {
+	addr, received := startSMTP(t)
+	channel, err := NewEmailChannel(addr, "Purchasing <purchasing@medisupply.example>", "", "", "")
+	if err != nil {
+		t.Fatalf("NewEmailChannel: %v", err)
+	}
+
+	reference, err := channel.Send(context.Background(), testOrder(&models.Supplier{Name: "Acme Medical", Email: "orders@acme.example"}))
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if reference != "<po-1@medisupply.example>" {
+		t.Fatalf("reference %q", reference)
+	}
+
+	select {
+	case data := <-received:
+		for _, want := range []string{"To: orders@acme.example", "Subject: Purchase order po-1", "Message-ID: <po-1@medisupply.example>", "Dear Acme Medical,", "Quantity: 10"} {
+			if !strings.Contains(data, want) {
+				t.Fatalf("email lacks %q:\n%s", want, data)
+			}
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no email received")
+	}
+}
+
+func TestEmailChannelSkipsSuppliersWithoutAnAddress(t *testing.T) {
+	channel, err := NewEmailChannel("127.0.0.1:1", "purchasing@medisupply.example", "", "", "")
+	if err != nil {
+		t.Fatalf("NewEmailChannel: %v", err)
+	}
+	for _, supplier := range []*models.Supplier{nil, {Name: "Acme"}} {
+		if _, err := channel.Send(context.Background(), testOrder(supplier)); !errors.Is(err, ErrSkipped) {
+			t.Fatalf("sending to %+v returned %v, want ErrSkipped", supplier, err)
+		}
+	}
+}
+
+func TestEmailTemplatesAreOverriddenFromTheDirectory(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "email.subject.tmpl"), []byte("PO {{.PurchaseOrder.ID}} for {{.Supplier.Name}}"), 0o644); err != nil {
+		t.Fatalf("write template: %v", err)
+	}
+	channel, err := NewEmailChannel("localhost:25", "purchasing@medisupply.example", "", "", dir)
+	if err != nil {
+		t.Fatalf("NewEmailChannel: %v", err)
+	}
+
+	var subject, body strings.Builder
+	order := testOrder(&models.Supplier{Name: "Acme Medical"})
+	if err := channel.Subject.Execute(&subject, order); err != nil || subject.String() != "PO po-1 for Acme Medical" {
+		t.Fatalf("subject %q, error %v", subject.String(), err)
+	}
+	// The body without a file keeps the built-in template
+	if err := channel.Body.Execute(&body, order); err != nil || !strings.HasPrefix(body.String(), "Dear Acme Medical,") {
+		t.Fatalf("body %q, error %v", body.String(), err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "email.body.tmpl"), []byte("{{.Unclosed"), 0o644); err != nil {
+		t.Fatalf("write template: %v", err)
+	}
+	if _, err := NewEmailChannel("localhost:25", "purchasing@medisupply.example", "", "", dir); err == nil {
+		t.Fatal("accepted an invalid body template")
+	}
+}
+
+func TestEDIChannelPostsThe850(t *testing.T) {
+	var document []byte
+	var contentType string
+	status := http.StatusAccepted
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		document, _ = io.ReadAll(r.Body)
+		contentType = r.Header.Get("Content-Type")
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(gateway.Close)
+	channel := NewEDIChannel(gateway.URL, "MEDISUPPLY", true)
+
+	// Only suppliers with an interchange ID receive EDI
+	if _, err := channel.Send(context.Background(), testOrder(&models.Supplier{Name: "Acme"})); !errors.Is(err, ErrSkipped) {
+		t.Fatalf("sending to a supplier without %s returned %v", MetadataEDIID, err)
+	}
+
+	supplier := &models.Supplier{Name: "Acme", Metadata: map[string]interface{}{MetadataEDIID: "ACME"}}
+	reference, err := channel.Send(context.Background(), testOrder(supplier))
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if contentType != "application/edi-x12" {
+		t.Fatalf("content type %q", contentType)
+	}
+	parsed, err := edi.Parse(document)
+	if err != nil {
+		t.Fatalf("parse posted document: %v", err)
+	}
+	if parsed.ReceiverID != "ACME" || parsed.ControlNumber != reference || !parsed.Test || parsed.Sets[0].ID != "850" {
+		t.Fatalf("posted %+v with reference %s", parsed, reference)
+	}
+
+	// Control numbers are not reused
+	next, err := channel.Send(context.Background(), testOrder(supplier))
+	if err != nil || next == reference {
+		t.Fatalf("second reference %q, error %v", next, err)
+	}
+
+	status = http.StatusBadGateway
+	if _, err := channel.Send(context.Background(), testOrder(supplier)); err == nil || errors.Is(err, ErrSkipped) {
+		t.Fatalf("a refused document returned %v", err)
+	}
+}
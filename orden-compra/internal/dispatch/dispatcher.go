@@ -0,0 +1,211 @@
+package dispatch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"orden-compra/internal/models"
+)
+
+// Order is a purchase order together with the supplier it is sent to
+type Order struct {
+	PurchaseOrder *models.PurchaseOrder
+	// Supplier is nil when the supplier is not in the catalog
+	Supplier *models.Supplier
+}
+
+var (
+	// ErrNotConfigured is returned when no dispatch channel is configured
+	ErrNotConfigured = errors.New("no dispatch channel is configured")
+	// ErrNotDispatchable is returned for orders not released to their supplier
+	ErrNotDispatchable = errors.New("purchase order is not released to its supplier")
+)
+
+// dispatchableStatuses lists the statuses of orders released to the supplier
+var dispatchableStatuses = map[string]bool{
+	models.StatusPending:  true,
+	models.StatusApproved: true,
+	models.StatusSent:     true,
+}
+
+// ErrSkipped is returned by channels the supplier cannot be reached through,
+// such as email for suppliers without an address. Skipped sends are not retried.
+var ErrSkipped = errors.New("supplier not reachable through channel")
+
+// Channel sends purchase orders to suppliers
+type Channel interface {
+	Name() string
+	// Send sends the order and returns a reference to the sent document
+	Send(ctx context.Context, order *Order) (string, error)
+}
+
+// RetryPolicy controls how failed sends are retried. The backoff doubles
+// after every attempt.
+type RetryPolicy struct {
+	MaxAttempts int
+	Backoff     time.Duration
+}
+
+// Dispatcher sends released purchase orders to their suppliers through every
+// configured channel and records the outcome on the order
+type Dispatcher struct {
+	Store   *Store
+	Retry   RetryPolicy
+	Timeout time.Duration
+	Logger  *log.Logger
+
+	channels []Channel
+	wg       sync.WaitGroup
+}
+
+// NewDispatcher creates a dispatcher without channels
+func NewDispatcher(store *Store, retry RetryPolicy, timeout time.Duration, logger *log.Logger) *Dispatcher {
+	if retry.MaxAttempts < 1 {
+		retry.MaxAttempts = 1
+	}
+	return &Dispatcher{
+		Store:   store,
+		Retry:   retry,
+		Timeout: timeout,
+		Logger:  logger,
+	}
+}
+
+// AddChannel sends every dispatched order through the channel
+func (d *Dispatcher) AddChannel(channel Channel) {
+	d.channels = append(d.channels, channel)
+}
+
+// Channels returns the names of the configured channels
+func (d *Dispatcher) Channels() []string {
+	if d == nil {
+		return nil
+	}
+	names := make([]string, 0, len(d.channels))
+	for _, channel := range d.channels {
+		names = append(names, channel.Name())
+	}
+	return names
+}
+
+// Dispatch sends the purchase order to its supplier in the background, so
+// callers never wait on mail servers or EDI gateways. The tenant in ctx is
+// kept. A nil dispatcher or one without channels is a no-op.
+func (d *Dispatcher) Dispatch(ctx context.Context, purchaseOrderID string) {
+	if d == nil || len(d.channels) == 0 {
+		return
+	}
+
+	ctx = context.WithoutCancel(ctx)
+
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		d.dispatch(ctx, purchaseOrderID)
+	}()
+}
+
+// Redispatch sends an order again, for instance after its dispatch failed.
+// Unlike Dispatch it checks up front that the order can be sent.
+func (d *Dispatcher) Redispatch(ctx context.Context, purchaseOrderID string) error {
+	if d == nil || len(d.channels) == 0 {
+		return ErrNotConfigured
+	}
+
+	order, err := d.Store.GetOrder(ctx, purchaseOrderID)
+	if err != nil {
+		return err
+	}
+	if !dispatchableStatuses[order.PurchaseOrder.Status] {
+		return fmt.Errorf("%w: status is %s", ErrNotDispatchable, order.PurchaseOrder.Status)
+	}
+
+	d.Dispatch(ctx, purchaseOrderID)
+	return nil
+}
+
+// Wait blocks until in-flight dispatches finish
+func (d *Dispatcher) Wait() {
+	if d == nil {
+		return
+	}
+	d.wg.Wait()
+}
+
+// dispatch sends the order through each channel in turn, recording the
+// dispatch as pending first and with its outcome once every channel is done
+func (d *Dispatcher) dispatch(ctx context.Context, purchaseOrderID string) {
+	loadCtx, cancel := context.WithTimeout(ctx, d.Timeout)
+	order, err := d.Store.GetOrder(loadCtx, purchaseOrderID)
+	cancel()
+	if err != nil {
+		d.Logger.Printf("Failed to load purchase order %s for dispatch: %v", purchaseOrderID, err)
+		return
+	}
+
+	status := models.NewDispatchStatus(d.Channels(), time.Now())
+	d.record(ctx, order.PurchaseOrder, status)
+
+	for i, channel := range d.channels {
+		status.Channels[i] = d.send(ctx, channel, order)
+	}
+
+	status.Summarize(time.Now())
+	d.record(ctx, order.PurchaseOrder, status)
+
+	d.Logger.Printf("Purchase order dispatch finished - purchase_order_id: %s, supplier_id: %s, status: %s",
+		purchaseOrderID, order.PurchaseOrder.SupplierID, status.Status)
+}
+
+// send sends the order through one channel, retrying with exponential backoff
+func (d *Dispatcher) send(ctx context.Context, channel Channel, order *Order) models.ChannelDispatch {
+	result := models.ChannelDispatch{Channel: channel.Name(), Status: models.DispatchFailed}
+
+	backoff := d.Retry.Backoff
+	for attempt := 1; attempt <= d.Retry.MaxAttempts; attempt++ {
+		sendCtx, cancel := context.WithTimeout(ctx, d.Timeout)
+		reference, err := channel.Send(sendCtx, order)
+		cancel()
+
+		result.Attempts = attempt
+		if errors.Is(err, ErrSkipped) {
+			result.Status = models.DispatchSkipped
+			result.LastError = err.Error()
+			return result
+		}
+		if err == nil {
+			sentAt := time.Now().UTC()
+			result.Status = models.DispatchSent
+			result.Reference = reference
+			result.LastError = ""
+			result.SentAt = &sentAt
+			return result
+		}
+
+		result.LastError = err.Error()
+		d.Logger.Printf("Purchase order dispatch failed - channel: %s, purchase_order_id: %s, attempt: %d/%d, error: %v",
+			channel.Name(), order.PurchaseOrder.ID, attempt, d.Retry.MaxAttempts, err)
+
+		if attempt < d.Retry.MaxAttempts {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+
+	d.Logger.Printf("Giving up on purchase order dispatch - channel: %s, purchase_order_id: %s", channel.Name(), order.PurchaseOrder.ID)
+	return result
+}
+
+// record stores the dispatch status; tracking failures never block sending
+func (d *Dispatcher) record(ctx context.Context, purchaseOrder *models.PurchaseOrder, status *models.DispatchStatus) {
+	ctx, cancel := context.WithTimeout(ctx, d.Timeout)
+	defer cancel()
+
+	if err := d.Store.SaveStatus(ctx, purchaseOrder, status); err != nil {
+		d.Logger.Printf("Failed to record dispatch status of purchase order %s: %v", purchaseOrder.ID, err)
+	}
+}
@@ -0,0 +1,185 @@
+package dispatch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+
+	"orden-compra/internal/memory"
+	"orden-compra/internal/models"
+)
+
+// scriptedChannel answers each send with the next error, repeating the last,
+// and records the orders it was asked to send
+type scriptedChannel struct {
+	name string
+	errs []error
+
+	mu    sync.Mutex
+	sends int
+}
+
+func (c *scriptedChannel) Name() string {
+	return c.name
+}
+
+func (c *scriptedChannel) Send(ctx context.Context, order *Order) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sends++
+	err := c.errs[len(c.errs)-1]
+	if c.sends <= len(c.errs) {
+		err = c.errs[c.sends-1]
+	}
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s-%s", c.name, order.PurchaseOrder.ID), nil
+}
+
+// storeOrder puts a purchase order with the given status into the store's tables
+func storeOrder(t *testing.T, dynamoDB *memory.DynamoDB, status string) *models.PurchaseOrder {
+	t.Helper()
+	purchaseOrder := models.NewPurchaseOrder("product-1", "Gloves", "supplier-1", "Acme", "warehouse-1", "HIGH", 10, time.Now())
+	purchaseOrder.Status = status
+	putItem(t, dynamoDB, "orden-compra-read", purchaseOrder)
+	return purchaseOrder
+}
+
+func newTestDispatcher(dynamoDB *memory.DynamoDB, channels ...Channel) *Dispatcher {
+	d := NewDispatcher(NewStore(dynamoDB), RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond}, time.Second, log.New(io.Discard, "", 0))
+	for _, channel := range channels {
+		d.AddChannel(channel)
+	}
+	return d
+}
+
+// storedDispatch returns the dispatch status recorded on an order
+func storedDispatch(t *testing.T, store *Store, id string) *models.DispatchStatus {
+	t.Helper()
+	order, err := store.GetOrder(context.Background(), id)
+	if err != nil {
+		t.Fatalf("get order: %v", err)
+	}
+	if order.PurchaseOrder.Dispatch == nil {
+		t.Fatalf("no dispatch recorded on %s", id)
+	}
+	return order.PurchaseOrder.Dispatch
+}
+
+func TestDispatchRecordsEachChannel(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		channels []*scriptedChannel
+		status   string
+		event    string
+		results  []models.ChannelDispatch
+	}{
+		{
+			name:     "sent after a retry",
+			channels: []*scriptedChannel{{name: "email", errs: []error{errors.New("mail server down"), nil}}},
+			status:   models.DispatchSent,
+			event:    models.PurchaseOrderDispatchedEventType,
+			results:  []models.ChannelDispatch{{Channel: "email", Status: models.DispatchSent, Attempts: 2}},
+		},
+		{
+			name:     "skipped channels are not retried",
+			channels: []*scriptedChannel{{name: "email", errs: []error{ErrSkipped}}, {name: "edi", errs: []error{nil}}},
+			status:   models.DispatchSent,
+			event:    models.PurchaseOrderDispatchedEventType,
+			results:  []models.ChannelDispatch{{Channel: "email", Status: models.DispatchSkipped, Attempts: 1}, {Channel: "edi", Status: models.DispatchSent, Attempts: 1}},
+		},
+		{
+			name:     "gives up after the last attempt",
+			channels: []*scriptedChannel{{name: "edi", errs: []error{errors.New("gateway down")}}},
+			status:   models.DispatchFailed,
+			event:    models.PurchaseOrderDispatchFailedEventType,
+			results:  []models.ChannelDispatch{{Channel: "edi", Status: models.DispatchFailed, Attempts: 3}},
+		},
+		{
+			name:     "every channel skipped",
+			channels: []*scriptedChannel{{name: "email", errs: []error{ErrSkipped}}},
+			status:   models.DispatchFailed,
+			event:    models.PurchaseOrderDispatchFailedEventType,
+			results:  []models.ChannelDispatch{{Channel: "email", Status: models.DispatchSkipped, Attempts: 1}},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dynamoDB := memory.NewDynamoDB(memory.Tables)
+			purchaseOrder := storeOrder(t, dynamoDB, models.StatusSent)
+			channels := make([]Channel, 0, len(tc.channels))
+			for _, channel := range tc.channels {
+				channels = append(channels, channel)
+			}
+			d := newTestDispatcher(dynamoDB, channels...)
+
+			d.Dispatch(context.Background(), purchaseOrder.ID)
+			d.Wait()
+
+			status := storedDispatch(t, d.Store, purchaseOrder.ID)
+			if status.Status != tc.status || len(status.Channels) != len(tc.results) {
+				t.Fatalf("dispatch %+v, want %s", status, tc.status)
+			}
+			for i, want := range tc.results {
+				got := status.Channels[i]
+				if got.Channel != want.Channel || got.Status != want.Status || got.Attempts != want.Attempts {
+					t.Fatalf("channel %d is %+v, want %+v", i, got, want)
+				}
+				if sent := got.Status == models.DispatchSent; sent != (got.Reference != "" && got.SentAt != nil && got.LastError == "") {
+					t.Fatalf("channel %d is %+v", i, got)
+				}
+			}
+
+			events := dynamoDB.Items("orden-compra-events")
+			if len(events) != 1 || aws.StringValue(events[0]["event_type"].S) != tc.event {
+				t.Fatalf("events %v, want one %s", events, tc.event)
+			}
+		})
+	}
+}
+
+func TestRedispatch(t *testing.T) {
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	sent := storeOrder(t, dynamoDB, models.StatusSent)
+	completed := storeOrder(t, dynamoDB, models.StatusCompleted)
+
+	if err := newTestDispatcher(dynamoDB).Redispatch(context.Background(), sent.ID); !errors.Is(err, ErrNotConfigured) {
+		t.Fatalf("redispatch without channels returned %v", err)
+	}
+
+	channel := &scriptedChannel{name: "email", errs: []error{nil}}
+	d := newTestDispatcher(dynamoDB, channel)
+	if err := d.Redispatch(context.Background(), completed.ID); !errors.Is(err, ErrNotDispatchable) {
+		t.Fatalf("redispatch of a completed order returned %v", err)
+	}
+	if err := d.Redispatch(context.Background(), "missing"); !errors.Is(err, ErrPurchaseOrderNotFound) {
+		t.Fatalf("redispatch of an unknown order returned %v", err)
+	}
+
+	if err := d.Redispatch(context.Background(), sent.ID); err != nil {
+		t.Fatalf("redispatch: %v", err)
+	}
+	d.Wait()
+	if channel.sends != 1 || storedDispatch(t, d.Store, sent.ID).Status != models.DispatchSent {
+		t.Fatalf("sent %d times", channel.sends)
+	}
+}
+
+func TestNilDispatcherIsANoOp(t *testing.T) {
+	var d *Dispatcher
+	d.Dispatch(context.Background(), "po-1")
+	d.Wait()
+	if channels := d.Channels(); channels != nil {
+		t.Fatalf("channels %v", channels)
+	}
+	if err := d.Redispatch(context.Background(), "po-1"); !errors.Is(err, ErrNotConfigured) {
+		t.Fatalf("redispatch returned %v", err)
+	}
+}
@@ -0,0 +1,86 @@
+package dispatch
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"orden-compra/internal/edi"
+)
+
+// MetadataEDIID is the supplier metadata key holding its EDI interchange ID.
+// Only suppliers with one receive EDI documents.
+const MetadataEDIID = "edi_id"
+
+// EDIChannel sends purchase orders as X12 850 documents to an EDI gateway,
+// such as a VAN or AS2 bridge, which routes them by the ISA receiver ID
+type EDIChannel struct {
+	Endpoint string
+	SenderID string
+	Test     bool
+	Client   *http.Client
+
+	// controlNumber numbers interchanges; it starts from the clock so
+	// restarts do not reuse recent control numbers
+	controlNumber atomic.Int64
+}
+
+// NewEDIChannel creates an EDI channel posting to the gateway endpoint
+func NewEDIChannel(endpoint, senderID string, test bool) *EDIChannel {
+	c := &EDIChannel{
+		Endpoint: endpoint,
+		SenderID: senderID,
+		Test:     test,
+		Client:   &http.Client{},
+	}
+	c.controlNumber.Store(time.Now().Unix() % 1000000000)
+	return c
+}
+
+// Name returns the channel name
+func (c *EDIChannel) Name() string {
+	return "edi"
+}
+
+// Send renders the 850 and posts it to the gateway. The interchange control
+// number is returned as the reference.
+func (c *EDIChannel) Send(ctx context.Context, order *Order) (string, error) {
+	receiverID := ""
+	if order.Supplier != nil {
+		receiverID, _ = order.Supplier.Metadata[MetadataEDIID].(string)
+	}
+	if receiverID == "" {
+		return "", fmt.Errorf("%w: supplier %s has no %s", ErrSkipped, order.PurchaseOrder.SupplierID, MetadataEDIID)
+	}
+
+	controlNumber := int(c.controlNumber.Add(1) % 1000000000)
+	document := edi.Encode(edi.Interchange{
+		SenderID:      c.SenderID,
+		ReceiverID:    receiverID,
+		ControlNumber: controlNumber,
+		Test:          c.Test,
+	}, "PO", "850", edi.PurchaseOrder850(order.PurchaseOrder, order.Supplier))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.Endpoint, bytes.NewReader(document))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/edi-x12")
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to post EDI document: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", fmt.Errorf("unexpected status %d from EDI gateway", resp.StatusCode)
+	}
+
+	return fmt.Sprintf("%09d", controlNumber), nil
+}
@@ -0,0 +1,149 @@
+package dispatch
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// defaultEmailSubject is the built-in subject of purchase order emails
+const defaultEmailSubject = `Purchase order {{.PurchaseOrder.ID}}`
+
+// defaultEmailBody is the built-in body of purchase order emails
+const defaultEmailBody = `Dear {{if .Supplier}}{{.Supplier.Name}}{{else}}{{.PurchaseOrder.SupplierName}}{{end}},
+
+Please supply the following purchase order.
+
+Purchase order: {{.PurchaseOrder.ID}}
+Product: {{.PurchaseOrder.ProductName}} ({{.PurchaseOrder.ProductID}})
+Quantity: {{.PurchaseOrder.Quantity}}
+Deliver to: {{.PurchaseOrder.Location}}
+{{- with .PurchaseOrder.ExpectedDate}}
+Requested delivery date: {{.Format "2006-01-02"}}
+{{- end}}
+Urgency: {{.PurchaseOrder.UrgencyLevel}}
+
+Please quote the purchase order number on the advance shipment notice and
+the delivery documents.
+`
+
+// EmailChannel emails purchase orders to the supplier's catalog address
+type EmailChannel struct {
+	Addr    string
+	From    string
+	Auth    smtp.Auth
+	Subject *template.Template
+	Body    *template.Template
+}
+
+// NewEmailChannel creates an email channel rendering the built-in templates,
+// replaced by <templateDir>/email.subject.tmpl or email.body.tmpl when those
+// files exist. Authentication is skipped when the username is empty.
+func NewEmailChannel(addr, from, username, password, templateDir string) (*EmailChannel, error) {
+	subject, err := loadTemplate("subject", templateDir, "email.subject.tmpl", defaultEmailSubject)
+	if err != nil {
+		return nil, err
+	}
+	body, err := loadTemplate("body", templateDir, "email.body.tmpl", defaultEmailBody)
+	if err != nil {
+		return nil, err
+	}
+
+	var auth smtp.Auth
+	if username != "" {
+		host := addr
+		if i := strings.LastIndex(addr, ":"); i >= 0 {
+			host = addr[:i]
+		}
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return &EmailChannel{
+		Addr:    addr,
+		From:    from,
+		Auth:    auth,
+		Subject: subject,
+		Body:    body,
+	}, nil
+}
+
+// loadTemplate parses the template file in dir, or text when there is none
+func loadTemplate(name, dir, file, text string) (*template.Template, error) {
+	if dir != "" {
+		content, err := os.ReadFile(filepath.Join(dir, file))
+		if err == nil {
+			text = string(content)
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("failed to read template %s: %w", file, err)
+		}
+	}
+
+	parsed, err := template.New(name).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s template: %w", name, err)
+	}
+	return parsed, nil
+}
+
+// Name returns the channel name
+func (c *EmailChannel) Name() string {
+	return "email"
+}
+
+// Send renders the order and mails it to the supplier
+func (c *EmailChannel) Send(ctx context.Context, order *Order) (string, error) {
+	if order.Supplier == nil || order.Supplier.Email == "" {
+		return "", fmt.Errorf("%w: supplier %s has no email address", ErrSkipped, order.PurchaseOrder.SupplierID)
+	}
+
+	var subject, body bytes.Buffer
+	if err := c.Subject.Execute(&subject, order); err != nil {
+		return "", fmt.Errorf("failed to render subject: %w", err)
+	}
+	if err := c.Body.Execute(&body, order); err != nil {
+		return "", fmt.Errorf("failed to render body: %w", err)
+	}
+
+	messageID := fmt.Sprintf("<%s@%s>", order.PurchaseOrder.ID, c.domain())
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", c.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", order.Supplier.Email)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", strings.TrimSpace(subject.String()))
+	fmt.Fprintf(&msg, "Message-ID: %s\r\n", messageID)
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	msg.Write(body.Bytes())
+
+	// net/smtp has no context support; run it aside so a hung server cannot outlive the deadline
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(c.Addr, c.Auth, c.From, []string{order.Supplier.Email}, msg.Bytes())
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return "", fmt.Errorf("failed to send email: %w", err)
+		}
+		return messageID, nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// domain returns the domain of the sender address for message IDs
+func (c *EmailChannel) domain() string {
+	from := strings.TrimSuffix(c.From, ">")
+	if i := strings.LastIndex(from, "@"); i >= 0 {
+		return from[i+1:]
+	}
+	return "localhost"
+}
@@ -0,0 +1,160 @@
+package dispatch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+
+	"orden-compra/internal/cache"
+	"orden-compra/internal/models"
+)
+
+// ErrPurchaseOrderNotFound is returned when the order to dispatch does not exist
+var ErrPurchaseOrderNotFound = errors.New("purchase order not found")
+
+// Store reads the orders to dispatch and records their dispatch status in DynamoDB
+type Store struct {
+	DynamoDB       dynamodbiface.DynamoDBAPI
+	OrdersTable    string
+	SuppliersTable string
+	EventsTable    string
+}
+
+// NewStore creates a store on the default purchase order tables
+func NewStore(dynamoDB dynamodbiface.DynamoDBAPI) *Store {
+	return &Store{
+		DynamoDB:       dynamoDB,
+		OrdersTable:    "orden-compra-read",
+		SuppliersTable: "orden-compra-suppliers",
+		EventsTable:    "orden-compra-events",
+	}
+}
+
+// GetOrder returns a purchase order together with its supplier. The supplier
+// is nil when it is not in the catalog.
+func (s *Store) GetOrder(ctx context.Context, purchaseOrderID string) (*Order, error) {
+	result, err := s.DynamoDB.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(s.OrdersTable),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {S: aws.String(purchaseOrderID)},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get purchase order: %w", err)
+	}
+	if result.Item == nil {
+		return nil, ErrPurchaseOrderNotFound
+	}
+
+	var purchaseOrder models.PurchaseOrder
+	if err := dynamodbattribute.UnmarshalMap(result.Item, &purchaseOrder); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal purchase order: %w", err)
+	}
+
+	supplier, err := s.getSupplier(ctx, purchaseOrder.SupplierID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Order{PurchaseOrder: &purchaseOrder, Supplier: supplier}, nil
+}
+
+// getSupplier returns a supplier from the catalog, or nil if it is not listed
+func (s *Store) getSupplier(ctx context.Context, supplierID string) (*models.Supplier, error) {
+	result, err := s.DynamoDB.GetItemWithContext(cache.AllowStale(ctx), &dynamodb.GetItemInput{
+		TableName: aws.String(s.SuppliersTable),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {S: aws.String(supplierID)},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get supplier: %w", err)
+	}
+	if result.Item == nil {
+		return nil, nil
+	}
+
+	var supplier models.Supplier
+	if err := dynamodbattribute.UnmarshalMap(result.Item, &supplier); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal supplier: %w", err)
+	}
+
+	return &supplier, nil
+}
+
+// SaveStatus records the dispatch status on the order without touching its
+// other attributes, and stores a dispatch event once the dispatch finished
+func (s *Store) SaveStatus(ctx context.Context, purchaseOrder *models.PurchaseOrder, status *models.DispatchStatus) error {
+	value, err := dynamodbattribute.Marshal(status)
+	if err != nil {
+		return fmt.Errorf("failed to marshal dispatch status: %w", err)
+	}
+
+	_, err = s.DynamoDB.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(s.OrdersTable),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {S: aws.String(purchaseOrder.ID)},
+		},
+		// The version moves so writers holding an older copy of the order
+		// conflict instead of dropping the dispatch status
+		UpdateExpression:    aws.String("SET #dispatch = :dispatch, updated_at = :updated_at ADD #version :one"),
+		ConditionExpression: aws.String("attribute_exists(id)"),
+		ExpressionAttributeNames: map[string]*string{
+			"#dispatch": aws.String("dispatch"),
+			"#version":  aws.String("version"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":dispatch":   value,
+			":updated_at": {S: aws.String(status.UpdatedAt.Format(time.RFC3339Nano))},
+			":one":        {N: aws.String("1")},
+		},
+	})
+	if err != nil {
+		var awsErr awserr.Error
+		if errors.As(err, &awsErr) && awsErr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return ErrPurchaseOrderNotFound
+		}
+		return fmt.Errorf("failed to update dispatch status: %w", err)
+	}
+
+	var eventType string
+	switch status.Status {
+	case models.DispatchSent:
+		eventType = models.PurchaseOrderDispatchedEventType
+	case models.DispatchFailed:
+		eventType = models.PurchaseOrderDispatchFailedEventType
+	default:
+		return nil
+	}
+
+	eventData := map[string]interface{}{
+		"purchase_order_id": purchaseOrder.ID,
+		"supplier_id":       purchaseOrder.SupplierID,
+		"dispatch":          status,
+	}
+
+	event := models.NewEventSourcingEvent(purchaseOrder.ID, eventType, eventData, nil, nil, time.Now())
+	event.TenantID = purchaseOrder.TenantID
+
+	item, err := dynamodbattribute.MarshalMap(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event sourcing event: %w", err)
+	}
+
+	_, err = s.DynamoDB.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(s.EventsTable),
+		Item:      item,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put event sourcing event: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,84 @@
+package dispatch
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+
+	"orden-compra/internal/memory"
+	"orden-compra/internal/models"
+)
+
+func putItem(t *testing.T, dynamoDB *memory.DynamoDB, tableName string, v interface{}) {
+	t.Helper()
+	item, err := dynamodbattribute.MarshalMap(v)
+	if err != nil {
+		t.Fatalf("marshal %T: %v", v, err)
+	}
+	if _, err := dynamoDB.PutItemWithContext(context.Background(), &dynamodb.PutItemInput{TableName: aws.String(tableName), Item: item}); err != nil {
+		t.Fatalf("put %T: %v", v, err)
+	}
+}
+
+func TestStoreGetOrder(t *testing.T) {
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	store := NewStore(dynamoDB)
+	purchaseOrder := storeOrder(t, dynamoDB, models.StatusSent)
+
+	// Suppliers missing from the catalog are left out
+	order, err := store.GetOrder(context.Background(), purchaseOrder.ID)
+	if err != nil || order.PurchaseOrder.ID != purchaseOrder.ID || order.Supplier != nil {
+		t.Fatalf("order %+v, error %v", order, err)
+	}
+
+	putItem(t, dynamoDB, "orden-compra-suppliers", &models.Supplier{ID: "supplier-1", Name: "Acme", Email: "orders@acme.example"})
+	if order, err := store.GetOrder(context.Background(), purchaseOrder.ID); err != nil || order.Supplier == nil || order.Supplier.Email != "orders@acme.example" {
+		t.Fatalf("order %+v, error %v", order, err)
+	}
+
+	if _, err := store.GetOrder(context.Background(), "missing"); !errors.Is(err, ErrPurchaseOrderNotFound) {
+		t.Fatalf("get missing returned %v", err)
+	}
+}
+
+func TestStoreSaveStatus(t *testing.T) {
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	store := NewStore(dynamoDB)
+	purchaseOrder := storeOrder(t, dynamoDB, models.StatusSent)
+	now := time.Now()
+
+	// A pending dispatch is recorded without an event
+	status := models.NewDispatchStatus([]string{"email"}, now)
+	if err := store.SaveStatus(context.Background(), purchaseOrder, status); err != nil {
+		t.Fatalf("save pending status: %v", err)
+	}
+	if events := dynamoDB.Items("orden-compra-events"); len(events) != 0 {
+		t.Fatalf("stored %d events for a pending dispatch", len(events))
+	}
+
+	status.Channels[0].Status = models.DispatchSent
+	status.Summarize(now)
+	if err := store.SaveStatus(context.Background(), purchaseOrder, status); err != nil {
+		t.Fatalf("save sent status: %v", err)
+	}
+	order, _ := store.GetOrder(context.Background(), purchaseOrder.ID)
+	if order.PurchaseOrder.Dispatch == nil || order.PurchaseOrder.Dispatch.Status != models.DispatchSent {
+		t.Fatalf("dispatch %+v", order.PurchaseOrder.Dispatch)
+	}
+	// Every update moves the version so stale writers conflict
+	if order.PurchaseOrder.Version != purchaseOrder.Version+2 || order.PurchaseOrder.Status != models.StatusSent {
+		t.Fatalf("order at version %d with status %s", order.PurchaseOrder.Version, order.PurchaseOrder.Status)
+	}
+	if events := dynamoDB.Items("orden-compra-events"); len(events) != 1 || aws.StringValue(events[0]["event_type"].S) != models.PurchaseOrderDispatchedEventType {
+		t.Fatalf("events %v", events)
+	}
+
+	if err := store.SaveStatus(context.Background(), &models.PurchaseOrder{ID: "missing"}, status); !errors.Is(err, ErrPurchaseOrderNotFound) {
+		t.Fatalf("saving the status of an unknown order returned %v", err)
+	}
+}
@@ -0,0 +1,144 @@
+package edi
+
+import (
+	"fmt"
+	"strconv"
+
+	"orden-compra/internal/models"
+)
+
+// ShipNotice856 is the advance shipment notice read from an X12 856 for one
+// purchase order. A shipment covering several orders yields one per order.
+type ShipNotice856 struct {
+	PurchaseOrderID string
+	Notice          models.AdvanceShipmentNotice
+}
+
+// ParseShipNotice856 reads an X12 856 ship notice. The carrier, tracking
+// number and delivery date come from the shipment level and apply to every
+// order; lots are read from item level LIN LT qualifiers or REF*LT with
+// SN1 quantities and DTM*036 expiry dates.
+func ParseShipNotice856(set TransactionSet) ([]ShipNotice856, error) {
+	if set.ID != "856" {
+		return nil, fmt.Errorf("%w: transaction set %s is not an 856", ErrInvalidDocument, set.ID)
+	}
+
+	var (
+		shipment models.AdvanceShipmentNotice
+		notices  []*ShipNotice856
+		order    *ShipNotice856
+		lot      *models.ShipmentLot
+		level    string
+		shipDate string
+	)
+
+	// closeLot adds the current item's lot to the current order
+	closeLot := func() {
+		if lot != nil && order != nil && lot.BatchNumber != "" {
+			order.Notice.Lots = append(order.Notice.Lots, *lot)
+		}
+		lot = nil
+	}
+
+	for _, segment := range set.Segments {
+		switch segment[0] {
+		case "HL":
+			closeLot()
+			level = segment.Element(3)
+			switch level {
+			case "O":
+				order = &ShipNotice856{}
+				notices = append(notices, order)
+			case "I":
+				lot = &models.ShipmentLot{}
+			}
+		case "PRF":
+			if order != nil {
+				order.PurchaseOrderID = segment.Element(1)
+			}
+		case "TD5":
+			// TD505 is the carrier name; fall back to the SCAC code in TD503
+			if carrier := segment.Element(5); carrier != "" {
+				shipment.Carrier = carrier
+			} else if shipment.Carrier == "" {
+				shipment.Carrier = segment.Element(3)
+			}
+		case "REF":
+			switch segment.Element(1) {
+			case "CN", "BM", "2I":
+				// Carrier tracking, bill of lading or tracking number
+				if shipment.TrackingNumber == "" || segment.Element(1) == "2I" {
+					shipment.TrackingNumber = segment.Element(2)
+				}
+			case "LT":
+				if lot != nil {
+					lot.BatchNumber = segment.Element(2)
+				}
+			}
+		case "LIN":
+			if lot != nil {
+				// LIN carries qualifier/value pairs from LIN02 on
+				for i := 2; i+1 < len(segment); i += 2 {
+					if segment.Element(i) == "LT" {
+						lot.BatchNumber = segment.Element(i + 1)
+					}
+				}
+			}
+		case "SN1":
+			if lot != nil {
+				quantity, err := strconv.ParseFloat(segment.Element(2), 64)
+				if err != nil {
+					return nil, fmt.Errorf("%w: invalid SN1 quantity %q", ErrInvalidDocument, segment.Element(2))
+				}
+				lot.Quantity += int(quantity)
+			}
+		case "DTM":
+			switch segment.Element(1) {
+			case "017", "067":
+				// Estimated delivery
+				date, err := parseDate(segment.Element(2))
+				if err != nil {
+					return nil, fmt.Errorf("%w: %v", ErrInvalidDocument, err)
+				}
+				shipment.ExpectedDeliveryDate = *date
+			case "011":
+				shipDate = segment.Element(2)
+			case "036":
+				if lot != nil {
+					expiry, err := parseDate(segment.Element(2))
+					if err != nil {
+						return nil, fmt.Errorf("%w: %v", ErrInvalidDocument, err)
+					}
+					lot.ExpiryDate = expiry
+				}
+			}
+		}
+	}
+	closeLot()
+
+	// Without an estimate the shipment is expected on its ship date
+	if shipment.ExpectedDeliveryDate.IsZero() && shipDate != "" {
+		date, err := parseDate(shipDate)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidDocument, err)
+		}
+		shipment.ExpectedDeliveryDate = *date
+	}
+
+	if len(notices) == 0 {
+		return nil, fmt.Errorf("%w: 856 has no order level", ErrInvalidDocument)
+	}
+
+	results := make([]ShipNotice856, 0, len(notices))
+	for _, notice := range notices {
+		if notice.PurchaseOrderID == "" {
+			return nil, fmt.Errorf("%w: 856 order level has no PRF purchase order number", ErrInvalidDocument)
+		}
+		notice.Notice.ExpectedDeliveryDate = shipment.ExpectedDeliveryDate
+		notice.Notice.Carrier = shipment.Carrier
+		notice.Notice.TrackingNumber = shipment.TrackingNumber
+		results = append(results, *notice)
+	}
+
+	return results, nil
+}
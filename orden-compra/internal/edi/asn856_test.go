@@ -0,0 +1,87 @@
+package edi
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseShipNotice856(t *testing.T) {
+	document, err := Parse(readFixture(t, "856_two_orders.edi"))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	notices, err := ParseShipNotice856(document.Sets[0])
+	if err != nil {
+		t.Fatalf("ParseShipNotice856: %v", err)
+	}
+	if len(notices) != 2 || notices[0].PurchaseOrderID != "po-1" || notices[1].PurchaseOrderID != "po-2" {
+		t.Fatalf("notices %+v, want po-1 and po-2", notices)
+	}
+
+	// The shipment level applies to both orders
+	expected := time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)
+	for _, notice := range notices {
+		if notice.Notice.Carrier != "UPS Ground" || notice.Notice.TrackingNumber != "1Z999" || !notice.Notice.ExpectedDeliveryDate.Equal(expected) {
+			t.Fatalf("notice %+v", notice.Notice)
+		}
+	}
+
+	lots := notices[0].Notice.Lots
+	if len(lots) != 2 || lots[0].BatchNumber != "LOT-A" || lots[0].Quantity != 60 || lots[1].BatchNumber != "LOT-B" || lots[1].Quantity != 40 {
+		t.Fatalf("po-1 lots %+v", lots)
+	}
+	if lots[0].ExpiryDate == nil || !lots[0].ExpiryDate.Equal(time.Date(2026, 1, 31, 0, 0, 0, 0, time.UTC)) || lots[1].ExpiryDate != nil {
+		t.Fatalf("po-1 lot expiries %v and %v", lots[0].ExpiryDate, lots[1].ExpiryDate)
+	}
+	if lots := notices[1].Notice.Lots; len(lots) != 1 || lots[0].BatchNumber != "LOT-C" || lots[0].Quantity != 12 {
+		t.Fatalf("po-2 lots %+v", lots)
+	}
+}
+
+func TestShipNotice856DefaultsToTheShipDate(t *testing.T) {
+	notices, err := ParseShipNotice856(TransactionSet{ID: "856", Segments: []Segment{
+		{"HL", "1", "", "S"},
+		{"TD5", "", "2", "FDEG"},
+		{"REF", "BM", "BOL-1"},
+		{"DTM", "011", "20240302"},
+		{"HL", "2", "1", "O"},
+		{"PRF", "po-1"},
+		// An item without a lot adds none
+		{"HL", "3", "2", "I"},
+		{"SN1", "1", "5", "EA"},
+	}})
+	if err != nil {
+		t.Fatalf("ParseShipNotice856: %v", err)
+	}
+	notice := notices[0].Notice
+	if notice.Carrier != "FDEG" || notice.TrackingNumber != "BOL-1" || !notice.ExpectedDeliveryDate.Equal(time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC)) || len(notice.Lots) != 0 {
+		t.Fatalf("notice %+v", notice)
+	}
+}
+
+func TestParseShipNotice856RejectsMalformedSets(t *testing.T) {
+	order := []Segment{{"HL", "1", "", "S"}, {"HL", "2", "1", "O"}, {"PRF", "po-1"}, {"HL", "3", "2", "I"}}
+	for _, tc := range []struct {
+		name     string
+		id       string
+		segments []Segment
+		want     string
+	}{
+		{"not an 856", "855", order, "is not an 856"},
+		{"no order level", "856", []Segment{{"HL", "1", "", "S"}, {"TD5", "", "2", "UPSN"}}, "no order level"},
+		{"order without PRF", "856", []Segment{{"HL", "1", "", "S"}, {"HL", "2", "1", "O"}}, "no PRF"},
+		{"truncated PRF", "856", []Segment{{"HL", "1", "", "S"}, {"HL", "2", "1", "O"}, {"PRF"}}, "no PRF"},
+		{"truncated SN1", "856", append(order, Segment{"SN1", "1"}), "invalid SN1 quantity"},
+		{"invalid expiry", "856", append(order, Segment{"DTM", "036", "2026"}), "invalid date"},
+		{"invalid delivery date", "856", append(order, Segment{"DTM", "017", "20240231"}), "invalid date"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			notices, err := ParseShipNotice856(TransactionSet{ID: tc.id, Segments: tc.segments})
+			if !errors.Is(err, ErrInvalidDocument) || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("ParseShipNotice856 = %+v, %v; want an error containing %q", notices, err, tc.want)
+			}
+		})
+	}
+}
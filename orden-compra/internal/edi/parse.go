@@ -0,0 +1,134 @@
+package edi
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidDocument is returned for input that is not a well-formed X12 interchange
+var ErrInvalidDocument = errors.New("invalid X12 document")
+
+// isaLength is the fixed length of the ISA segment up to its terminator
+const isaLength = 106
+
+// TransactionSet is one ST...SE transaction set of a received interchange
+type TransactionSet struct {
+	// ID is the transaction set identifier, e.g. 855 or 856
+	ID            string
+	ControlNumber string
+	// Segments holds the segments between ST and SE
+	Segments []Segment
+}
+
+// Document is a parsed inbound interchange
+type Document struct {
+	SenderID      string
+	ReceiverID    string
+	ControlNumber string
+	Test          bool
+	Sets          []TransactionSet
+}
+
+// Parse splits an X12 interchange into its transaction sets. The delimiters
+// are read from the ISA segment, so partners need not use the ones this
+// service writes. Multiple functional groups are supported.
+func Parse(data []byte) (*Document, error) {
+	text := strings.TrimLeft(string(data), " \t\r\n\ufeff")
+	if len(text) < isaLength || !strings.HasPrefix(text, "ISA") {
+		return nil, fmt.Errorf("%w: missing ISA segment", ErrInvalidDocument)
+	}
+
+	elementSeparator := string(text[3])
+	segmentTerminator := string(text[isaLength-1])
+
+	var segments []Segment
+	for _, raw := range strings.Split(text, segmentTerminator) {
+		raw = strings.Trim(raw, " \t\r\n")
+		if raw == "" {
+			continue
+		}
+		segments = append(segments, Segment(strings.Split(raw, elementSeparator)))
+	}
+
+	isa := segments[0]
+	if len(isa) < 17 {
+		return nil, fmt.Errorf("%w: ISA has %d elements, want 17", ErrInvalidDocument, len(isa)-1)
+	}
+	document := &Document{
+		SenderID:      strings.TrimSpace(isa[6]),
+		ReceiverID:    strings.TrimSpace(isa[8]),
+		ControlNumber: isa[13],
+		Test:          isa[15] == "T",
+	}
+
+	var current *TransactionSet
+	var ended bool
+	for _, segment := range segments[1:] {
+		switch segment[0] {
+		case "GS", "GE":
+		case "IEA":
+			if segment.Element(2) != document.ControlNumber {
+				return nil, fmt.Errorf("%w: IEA control number %s does not match ISA %s", ErrInvalidDocument, segment.Element(2), document.ControlNumber)
+			}
+			ended = true
+		case "ST":
+			if current != nil {
+				return nil, fmt.Errorf("%w: transaction set %s has no SE", ErrInvalidDocument, current.ControlNumber)
+			}
+			current = &TransactionSet{ID: segment.Element(1), ControlNumber: segment.Element(2)}
+		case "SE":
+			if current == nil {
+				return nil, fmt.Errorf("%w: SE without ST", ErrInvalidDocument)
+			}
+			// SE01 counts every segment of the set, including ST and SE
+			if count, err := strconv.Atoi(segment.Element(1)); err != nil || count != len(current.Segments)+2 {
+				return nil, fmt.Errorf("%w: transaction set %s has %d segments, SE says %s", ErrInvalidDocument, current.ControlNumber, len(current.Segments)+2, segment.Element(1))
+			}
+			document.Sets = append(document.Sets, *current)
+			current = nil
+		default:
+			if current == nil {
+				return nil, fmt.Errorf("%w: segment %s outside a transaction set", ErrInvalidDocument, segment[0])
+			}
+			current.Segments = append(current.Segments, segment)
+		}
+	}
+
+	if current != nil {
+		return nil, fmt.Errorf("%w: transaction set %s has no SE", ErrInvalidDocument, current.ControlNumber)
+	}
+	// A file cut short after a complete set still lacks its IEA
+	if !ended {
+		return nil, fmt.Errorf("%w: interchange has no IEA", ErrInvalidDocument)
+	}
+	if len(document.Sets) == 0 {
+		return nil, fmt.Errorf("%w: no transaction sets", ErrInvalidDocument)
+	}
+
+	return document, nil
+}
+
+// Element returns the element at position i (1-based as in X12 notation), or
+// an empty string if the segment is shorter
+func (s Segment) Element(i int) string {
+	if i <= 0 || i >= len(s) {
+		return ""
+	}
+	return strings.TrimSpace(s[i])
+}
+
+// parseDate parses an X12 CCYYMMDD date, or YYMMDD for older partners
+func parseDate(value string) (*time.Time, error) {
+	layout := "20060102"
+	if len(value) == 6 {
+		layout = "060102"
+	}
+	parsed, err := time.Parse(layout, value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid date %q", value)
+	}
+	return &parsed, nil
+}
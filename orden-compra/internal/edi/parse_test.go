@@ -0,0 +1,135 @@
+package edi
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"orden-compra/internal/models"
+)
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+	return data
+}
+
+func TestParseReadsTheEnvelope(t *testing.T) {
+	for _, tc := range []struct {
+		fixture  string
+		set      string
+		control  string
+		segments int
+		test     bool
+	}{
+		{"855_changed.edi", "855", "000000001", 6, false},
+		// Delimiters |, ^ and : with CRLF line breaks, read from its ISA
+		{"856_two_orders.edi", "856", "000000007", 23, true},
+	} {
+		t.Run(tc.fixture, func(t *testing.T) {
+			document, err := Parse(readFixture(t, tc.fixture))
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if document.SenderID != "SUPPLIER1" || document.ReceiverID != "MEDISUPPLY" || document.ControlNumber != tc.control || document.Test != tc.test {
+				t.Fatalf("envelope %+v", document)
+			}
+			if len(document.Sets) != 1 || document.Sets[0].ID != tc.set || len(document.Sets[0].Segments) != tc.segments {
+				t.Fatalf("sets %+v, want one %s with %d segments", document.Sets, tc.set, tc.segments)
+			}
+		})
+	}
+}
+
+func TestParseReadsWhatEncodeWrites(t *testing.T) {
+	expected := time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+	order := &models.PurchaseOrder{
+		ID:           "po-1",
+		ProductID:    "product-1",
+		ProductName:  "Guantes ~ nitrilo",
+		SupplierID:   "supplier-1",
+		Quantity:     100,
+		Location:     "bodega-norte",
+		ExpectedDate: &expected,
+		CreatedAt:    time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+	}
+	segments := PurchaseOrder850(order, nil)
+	data := Encode(Interchange{SenderID: "MEDISUPPLY", ReceiverID: "SUPPLIER1", ControlNumber: 42, Date: order.CreatedAt}, "PO", "850", segments)
+
+	document, err := Parse(data)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if document.ControlNumber != "000000042" || len(document.Sets) != 1 || len(document.Sets[0].Segments) != len(segments) {
+		t.Fatalf("parsed %+v", document)
+	}
+	// A delimiter in the data is written as a space
+	for _, segment := range document.Sets[0].Segments {
+		if segment[0] == "PID" && segment.Element(5) != "Guantes   nitrilo" {
+			t.Fatalf("PID05 %q", segment.Element(5))
+		}
+	}
+}
+
+func TestParseRejectsMalformedInterchanges(t *testing.T) {
+	valid := string(readFixture(t, "855_changed.edi"))
+	for _, tc := range []struct {
+		name string
+		data string
+		want string
+	}{
+		{"empty", "", "missing ISA"},
+		{"truncated ISA", valid[:80], "missing ISA"},
+		{"not X12", strings.Repeat("<xml/>", 20), "missing ISA"},
+		{"cut in the middle of a segment", string(readFixture(t, "855_truncated.edi")), "transaction set 0001 has no SE"},
+		{"cut after the set", valid[:strings.Index(valid, "GE*")], "interchange has no IEA"},
+		{"unknown segment outside a set", string(readFixture(t, "855_unknown_outside_set.edi")), "segment ZZZ outside a transaction set"},
+		{"wrong segment count", strings.Replace(valid, "SE*8*", "SE*7*", 1), "SE says 7"},
+		{"SE without ST", strings.Replace(valid, "ST*855*0001~", "", 1), "segment BAK outside a transaction set"},
+		{"ST inside a set", strings.Replace(valid, "CTT*1~", "ST*855*0002~", 1), "transaction set 0001 has no SE"},
+		{"IEA control number", strings.Replace(valid, "IEA*1*000000001", "IEA*1*000000002", 1), "does not match ISA"},
+		{"no sets", valid[:106] + "\nIEA*1*000000001~", "no transaction sets"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			document, err := Parse([]byte(tc.data))
+			if !errors.Is(err, ErrInvalidDocument) || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("Parse = %+v, %v; want an error containing %q", document, err, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseKeepsUnknownSegmentsInASet(t *testing.T) {
+	document, err := Parse(readFixture(t, "855_unknown_segment.edi"))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	segments := document.Sets[0].Segments
+	if len(segments) != 7 || segments[3][0] != "ZZZ" || segments[3].Element(2) != "value" || segments[3].Element(3) != "" {
+		t.Fatalf("segments %v, want ZZZ kept in place", segments)
+	}
+
+	// The 855 reader skips it
+	acknowledgement, err := ParseAcknowledgement855(document.Sets[0])
+	if err != nil {
+		t.Fatalf("ParseAcknowledgement855: %v", err)
+	}
+	if acknowledgement.Acknowledgement.AcknowledgedQuantity != 100 {
+		t.Fatalf("acknowledged %d, want 100", acknowledgement.Acknowledgement.AcknowledgedQuantity)
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	for value, want := range map[string]string{"20240315": "2024-03-15", "240315": "2024-03-15", "20240230": "", "2024-03-15": "", "": ""} {
+		date, err := parseDate(value)
+		if (err == nil) != (want != "") || (date != nil && date.Format("2006-01-02") != want) {
+			t.Errorf("parseDate(%q) = %v, %v; want %q", value, date, err, want)
+		}
+	}
+}
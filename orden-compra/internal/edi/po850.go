@@ -0,0 +1,38 @@
+package edi
+
+import (
+	"strconv"
+
+	"orden-compra/internal/models"
+)
+
+// PurchaseOrder850 returns the segments of an X12 850 purchase order between
+// ST and SE. The buyer's product ID is sent as the BP part number.
+func PurchaseOrder850(purchaseOrder *models.PurchaseOrder, supplier *models.Supplier) []Segment {
+	supplierName := purchaseOrder.SupplierName
+	if supplier != nil && supplier.Name != "" {
+		supplierName = supplier.Name
+	}
+
+	segments := []Segment{
+		// Original stand-alone order
+		{"BEG", "00", "SA", purchaseOrder.ID, "", purchaseOrder.CreatedAt.UTC().Format("20060102")},
+	}
+	if purchaseOrder.ExpectedDate != nil {
+		// Delivery requested
+		segments = append(segments, Segment{"DTM", "002", purchaseOrder.ExpectedDate.UTC().Format("20060102")})
+	}
+	if purchaseOrder.UrgencyLevel != "" {
+		segments = append(segments, Segment{"MSG", "URGENCY " + purchaseOrder.UrgencyLevel})
+	}
+
+	segments = append(segments,
+		Segment{"N1", "ST", purchaseOrder.Location},
+		Segment{"N1", "SE", supplierName, "92", purchaseOrder.SupplierID},
+		Segment{"PO1", "1", strconv.Itoa(purchaseOrder.Quantity), "EA", "", "", "BP", purchaseOrder.ProductID},
+		Segment{"PID", "F", "", "", "", purchaseOrder.ProductName},
+		Segment{"CTT", "1", strconv.Itoa(purchaseOrder.Quantity)},
+	)
+
+	return segments
+}
@@ -0,0 +1,77 @@
+package edi
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"orden-compra/internal/models"
+)
+
+func TestEncodePurchaseOrder850(t *testing.T) {
+	expected := time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)
+	purchaseOrder := &models.PurchaseOrder{
+		ID:           "po-1",
+		ProductID:    "product-1",
+		ProductName:  "Gloves*nitrile~",
+		SupplierID:   "supplier-1",
+		SupplierName: "Acme",
+		Location:     "warehouse-1",
+		Quantity:     120,
+		UrgencyLevel: "HIGH",
+		ExpectedDate: &expected,
+		CreatedAt:    time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC),
+	}
+	interchange := Interchange{SenderID: "MEDISUPPLY", ReceiverID: "SUPPLIER1", ControlNumber: 42, Test: true, Date: time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)}
+	data := Encode(interchange, "PO", "850", PurchaseOrder850(purchaseOrder, &models.Supplier{Name: "Acme Medical"}))
+
+	// What this service writes reads back through its own parser
+	document, err := Parse(data)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if document.SenderID != "MEDISUPPLY" || document.ReceiverID != "SUPPLIER1" || document.ControlNumber != "000000042" || !document.Test {
+		t.Fatalf("envelope %+v", document)
+	}
+	if len(document.Sets) != 1 || document.Sets[0].ID != "850" {
+		t.Fatalf("transaction sets %+v, want one 850", document.Sets)
+	}
+
+	segments := map[string]Segment{}
+	for _, segment := range document.Sets[0].Segments {
+		key := segment[0]
+		if key == "N1" {
+			key += segment.Element(1)
+		}
+		segments[key] = segment
+	}
+	for key, want := range map[string]string{
+		"BEG":  "BEG*00*SA*po-1**20240301",
+		"DTM":  "DTM*002*20240315",
+		"MSG":  "MSG*URGENCY HIGH",
+		"N1ST": "N1*ST*warehouse-1",
+		"N1SE": "N1*SE*Acme Medical*92*supplier-1",
+		"PO1":  "PO1*1*120*EA***BP*product-1",
+		"PID":  "PID*F****Gloves nitrile",
+		"CTT":  "CTT*1*120",
+	} {
+		if got := strings.Join(segments[key], ElementSeparator); got != want {
+			t.Errorf("%s segment %q, want %q", key, got, want)
+		}
+	}
+	if !strings.Contains(string(data), "SE*10*0001~") {
+		t.Fatalf("SE does not count the 10 segments of the set:\n%s", data)
+	}
+}
+
+func TestPurchaseOrder850LeavesOutMissingFields(t *testing.T) {
+	segments := PurchaseOrder850(&models.PurchaseOrder{ID: "po-1", SupplierName: "Acme"}, nil)
+	for _, segment := range segments {
+		if segment[0] == "DTM" || segment[0] == "MSG" {
+			t.Fatalf("wrote %v for an order without it", segment)
+		}
+		if segment[0] == "N1" && segment[1] == "SE" && segment[2] != "Acme" {
+			t.Fatalf("supplier named %q, want the order's supplier name", segment[2])
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package edi
+
+import (
+	"fmt"
+	"strconv"
+
+	"orden-compra/internal/models"
+)
+
+// OrderAcknowledgement855 is a supplier acknowledgement read from an X12 855
+type OrderAcknowledgement855 struct {
+	PurchaseOrderID string
+	Acknowledgement models.OrderAcknowledgement
+}
+
+// ParseAcknowledgement855 reads an X12 855 purchase order acknowledgement.
+// BAK02 gives the overall answer; line ACK segments refine it, and rejected
+// lines reject the order since this service orders one product per order.
+func ParseAcknowledgement855(set TransactionSet) (*OrderAcknowledgement855, error) {
+	if set.ID != "855" {
+		return nil, fmt.Errorf("%w: transaction set %s is not an 855", ErrInvalidDocument, set.ID)
+	}
+
+	result := &OrderAcknowledgement855{}
+	var deliveryDate, shipDate string
+
+	for _, segment := range set.Segments {
+		switch segment[0] {
+		case "BAK":
+			result.PurchaseOrderID = segment.Element(3)
+			result.Acknowledgement.Reference = segment.Element(8)
+			switch segment.Element(2) {
+			case "AC":
+				result.Acknowledgement.Status = models.AcknowledgementChanged
+			case "AD", "AK", "AT":
+				result.Acknowledgement.Status = models.AcknowledgementAccepted
+			case "RD", "RJ":
+				result.Acknowledgement.Status = models.AcknowledgementRejected
+			default:
+				return nil, fmt.Errorf("%w: unsupported BAK02 acknowledgement type %q", ErrInvalidDocument, segment.Element(2))
+			}
+		case "ACK":
+			switch segment.Element(1) {
+			case "IR", "R1", "R2", "R3", "R4":
+				result.Acknowledgement.Status = models.AcknowledgementRejected
+			case "IA":
+			default:
+				// Backorders, quantity, price and date changes
+				if result.Acknowledgement.Status == models.AcknowledgementAccepted {
+					result.Acknowledgement.Status = models.AcknowledgementChanged
+				}
+			}
+			if quantity := segment.Element(2); quantity != "" {
+				parsed, err := strconv.ParseFloat(quantity, 64)
+				if err != nil {
+					return nil, fmt.Errorf("%w: invalid ACK02 quantity %q", ErrInvalidDocument, quantity)
+				}
+				result.Acknowledgement.AcknowledgedQuantity += int(parsed)
+			}
+			switch segment.Element(4) {
+			case "067":
+				deliveryDate = segment.Element(5)
+			case "068":
+				shipDate = segment.Element(5)
+			}
+		case "DTM":
+			switch segment.Element(1) {
+			case "067":
+				deliveryDate = segment.Element(2)
+			case "068":
+				shipDate = segment.Element(2)
+			}
+		case "MSG":
+			result.Acknowledgement.Note = segment.Element(1)
+		}
+	}
+
+	if result.PurchaseOrderID == "" || result.Acknowledgement.Status == "" {
+		return nil, fmt.Errorf("%w: 855 has no BAK segment", ErrInvalidDocument)
+	}
+
+	// A promised delivery date wins over a ship date
+	if date := deliveryDate; date != "" || shipDate != "" {
+		if date == "" {
+			date = shipDate
+		}
+		promisedDate, err := parseDate(date)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidDocument, err)
+		}
+		result.Acknowledgement.PromisedDate = promisedDate
+	}
+
+	return result, nil
+}
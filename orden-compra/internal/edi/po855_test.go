@@ -0,0 +1,83 @@
+package edi
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"orden-compra/internal/models"
+)
+
+func TestParseAcknowledgement855(t *testing.T) {
+	document, err := Parse(readFixture(t, "855_changed.edi"))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	result, err := ParseAcknowledgement855(document.Sets[0])
+	if err != nil {
+		t.Fatalf("ParseAcknowledgement855: %v", err)
+	}
+	acknowledgement := result.Acknowledgement
+	if result.PurchaseOrderID != "po-1" || acknowledgement.Status != models.AcknowledgementChanged || acknowledgement.Reference != "SO-778" {
+		t.Fatalf("acknowledgement %+v for %s", acknowledgement, result.PurchaseOrderID)
+	}
+	if acknowledgement.AcknowledgedQuantity != 100 || acknowledgement.Note != "20 units backordered" {
+		t.Fatalf("acknowledgement %+v", acknowledgement)
+	}
+	if want := time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC); acknowledgement.PromisedDate == nil || !acknowledgement.PromisedDate.Equal(want) {
+		t.Fatalf("promised %v, want %v", acknowledgement.PromisedDate, want)
+	}
+}
+
+func TestAcknowledgement855Status(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		segments []Segment
+		status   string
+		promised string
+	}{
+		{"accepted", []Segment{{"BAK", "00", "AT", "po-1"}, {"ACK", "IA", "10"}}, models.AcknowledgementAccepted, ""},
+		{"line changes make it changed", []Segment{{"BAK", "00", "AD", "po-1"}, {"ACK", "IB", "10"}}, models.AcknowledgementChanged, ""},
+		{"a rejected line rejects it", []Segment{{"BAK", "00", "AC", "po-1"}, {"ACK", "IR"}}, models.AcknowledgementRejected, ""},
+		{"rejected", []Segment{{"BAK", "00", "RJ", "po-1"}}, models.AcknowledgementRejected, ""},
+		{"ship date without delivery date", []Segment{{"BAK", "00", "AT", "po-1"}, {"DTM", "068", "240320"}}, models.AcknowledgementAccepted, "2024-03-20"},
+		{"delivery date wins", []Segment{{"BAK", "00", "AT", "po-1"}, {"DTM", "068", "20240320"}, {"DTM", "067", "20240322"}}, models.AcknowledgementAccepted, "2024-03-22"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := ParseAcknowledgement855(TransactionSet{ID: "855", Segments: tc.segments})
+			if err != nil {
+				t.Fatalf("ParseAcknowledgement855: %v", err)
+			}
+			promised := ""
+			if result.Acknowledgement.PromisedDate != nil {
+				promised = result.Acknowledgement.PromisedDate.Format("2006-01-02")
+			}
+			if result.Acknowledgement.Status != tc.status || promised != tc.promised {
+				t.Fatalf("status %s promised %q, want %s %q", result.Acknowledgement.Status, promised, tc.status, tc.promised)
+			}
+		})
+	}
+}
+
+func TestParseAcknowledgement855RejectsMalformedSets(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		set  TransactionSet
+		want string
+	}{
+		{"not an 855", TransactionSet{ID: "856"}, "is not an 855"},
+		{"no BAK", TransactionSet{ID: "855", Segments: []Segment{{"ACK", "IA", "10"}}}, "no BAK segment"},
+		{"truncated BAK", TransactionSet{ID: "855", Segments: []Segment{{"BAK", "00"}}}, "unsupported BAK02"},
+		{"unknown acknowledgement type", TransactionSet{ID: "855", Segments: []Segment{{"BAK", "00", "ZZ", "po-1"}}}, "unsupported BAK02"},
+		{"invalid quantity", TransactionSet{ID: "855", Segments: []Segment{{"BAK", "00", "AT", "po-1"}, {"ACK", "IA", "ten"}}}, "invalid ACK02"},
+		{"invalid date", TransactionSet{ID: "855", Segments: []Segment{{"BAK", "00", "AT", "po-1"}, {"DTM", "067", "20241340"}}}, "invalid date"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := ParseAcknowledgement855(tc.set)
+			if !errors.Is(err, ErrInvalidDocument) || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("ParseAcknowledgement855 = %+v, %v; want an error containing %q", result, err, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package edi
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// X12 delimiters used on every document this service writes
+const (
+	ElementSeparator   = "*"
+	SegmentTerminator  = "~"
+	ComponentSeparator = ">"
+)
+
+// Version is the X12 version of the documents this service writes
+const Version = "004010"
+
+// Segment is one X12 segment: its ID followed by its elements
+type Segment []string
+
+// Interchange identifies the trading partners and control number of an X12
+// interchange
+type Interchange struct {
+	SenderQualifier   string
+	SenderID          string
+	ReceiverQualifier string
+	ReceiverID        string
+	ControlNumber     int
+	// Test marks the interchange as test data (ISA15 T)
+	Test bool
+	Date time.Time
+}
+
+// Encode wraps one transaction set in ISA/GS/ST envelopes. functionalID is the
+// GS01 functional identifier, e.g. PO for 850 purchase orders.
+func Encode(interchange Interchange, functionalID, transactionSet string, segments []Segment) []byte {
+	date := interchange.Date.UTC()
+	if date.IsZero() {
+		date = time.Now().UTC()
+	}
+	control := fmt.Sprintf("%09d", interchange.ControlNumber%1000000000)
+	usage := "P"
+	if interchange.Test {
+		usage = "T"
+	}
+
+	var b strings.Builder
+	write := func(segment Segment) {
+		for i, element := range segment {
+			if i > 0 {
+				b.WriteString(ElementSeparator)
+			}
+			b.WriteString(element)
+		}
+		b.WriteString(SegmentTerminator)
+		b.WriteString("\n")
+	}
+
+	// ISA elements are fixed width and written verbatim
+	write(Segment{
+		"ISA", "00", pad("", 10), "00", pad("", 10),
+		pad(qualifier(interchange.SenderQualifier), 2), pad(clean(interchange.SenderID), 15),
+		pad(qualifier(interchange.ReceiverQualifier), 2), pad(clean(interchange.ReceiverID), 15),
+		date.Format("060102"), date.Format("1504"), "U", "00401", control, "0", usage, ComponentSeparator,
+	})
+	write(Segment{
+		"GS", functionalID, clean(interchange.SenderID), clean(interchange.ReceiverID),
+		date.Format("20060102"), date.Format("1504"), strconv.Itoa(interchange.ControlNumber % 1000000000), "X", Version,
+	})
+
+	write(Segment{"ST", transactionSet, "0001"})
+	for _, segment := range segments {
+		cleaned := make(Segment, len(segment))
+		for i, element := range segment {
+			cleaned[i] = clean(element)
+		}
+		write(cleaned)
+	}
+	// SE counts every segment of the transaction set, including ST and SE
+	write(Segment{"SE", strconv.Itoa(len(segments) + 2), "0001"})
+
+	write(Segment{"GE", "1", strconv.Itoa(interchange.ControlNumber % 1000000000)})
+	write(Segment{"IEA", "1", control})
+
+	return []byte(b.String())
+}
+
+// qualifier defaults interchange ID qualifiers to ZZ, mutually defined
+func qualifier(value string) string {
+	if value == "" {
+		return "ZZ"
+	}
+	return value
+}
+
+// clean removes delimiters and line breaks from an element value
+func clean(value string) string {
+	return strings.Map(func(r rune) rune {
+		switch string(r) {
+		case ElementSeparator, SegmentTerminator, ComponentSeparator, "\r", "\n":
+			return ' '
+		}
+		return r
+	}, strings.TrimSpace(value))
+}
+
+// pad right-pads or truncates a fixed-width ISA element
+func pad(value string, width int) string {
+	if len(value) > width {
+		return value[:width]
+	}
+	return value + strings.Repeat(" ", width-len(value))
+}
@@ -0,0 +1,67 @@
+// Package erp exports completed purchase orders and their goods receipts to
+// the ERP, such as SAP, as IDoc XML or mapped JSON documents delivered over
+// HTTP or SFTP. Every delivery is tracked so failed ones can be replayed.
+package erp
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"orden-compra/internal/models"
+)
+
+var (
+	// ErrNotConfigured is returned when no ERP target is configured
+	ErrNotConfigured = errors.New("ERP export is not configured")
+	// ErrDeliveryNotFound is returned when an ERP delivery does not exist
+	ErrDeliveryNotFound = errors.New("ERP delivery not found")
+	// ErrNotExportable is returned for orders that are not completed
+	ErrNotExportable = errors.New("purchase order is not completed")
+	// ErrInvalidMapping is returned for a malformed JSON mapping
+	ErrInvalidMapping = errors.New("invalid ERP JSON mapping")
+)
+
+// Document is a rendered document ready to deliver
+type Document struct {
+	Delivery    *models.ERPDelivery
+	ContentType string
+	Body        []byte
+}
+
+// Format renders the documents of a purchase order
+type Format interface {
+	Name() string
+	// Extension is the file name extension of rendered documents, e.g. "xml"
+	Extension() string
+	ContentType() string
+	Render(delivery *models.ERPDelivery, purchaseOrder *models.PurchaseOrder) ([]byte, error)
+}
+
+// Transport delivers rendered documents to the ERP
+type Transport interface {
+	Name() string
+	// Deliver delivers the document and returns a reference to it on the
+	// receiving side, such as the remote path or the location of the
+	// created resource, when there is one
+	Deliver(ctx context.Context, document *Document) (string, error)
+}
+
+// RetryPolicy controls how failed deliveries are retried. The backoff doubles
+// after every attempt.
+type RetryPolicy struct {
+	MaxAttempts int
+	Backoff     time.Duration
+}
+
+// Config represents the ERP export settings
+type Config struct {
+	// FilePrefix prefixes the file name of every document
+	FilePrefix string
+	Interval   time.Duration
+	// Lag keeps the exporter this far behind the present, so completions
+	// stored late by other replicas are not skipped
+	Lag     time.Duration
+	Retry   RetryPolicy
+	Timeout time.Duration
+}
@@ -0,0 +1,415 @@
+package erp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+
+	"orden-compra/internal/cqrs"
+	"orden-compra/internal/models"
+)
+
+const (
+	checkpointsTable = "orden-compra-checkpoints"
+	checkpointID     = "erp-export"
+)
+
+// Exporter exports completed purchase orders to the ERP. Its scheduled run
+// picks up the orders completed since its checkpoint and exports each one
+// once; deliveries that fail are kept as failed until they are replayed. A
+// nil exporter means ERP export is disabled.
+type Exporter struct {
+	DynamoDB  dynamodbiface.DynamoDBAPI
+	Store     *Store
+	Format    Format
+	Transport Transport
+	Config    Config
+	Logger    *log.Logger
+
+	wg sync.WaitGroup
+}
+
+// NewExporter creates a new ERP exporter
+func NewExporter(dynamoDB dynamodbiface.DynamoDBAPI, store *Store, format Format, transport Transport, config Config, logger *log.Logger) *Exporter {
+	if config.Retry.MaxAttempts < 1 {
+		config.Retry.MaxAttempts = 1
+	}
+	return &Exporter{
+		DynamoDB:  dynamoDB,
+		Store:     store,
+		Format:    format,
+		Transport: transport,
+		Config:    config,
+		Logger:    logger,
+	}
+}
+
+// Start exports completed orders every interval until the context is cancelled
+func (e *Exporter) Start(ctx context.Context) {
+	e.Logger.Printf("Starting ERP exporter - format: %s, transport: %s, interval: %v", e.Format.Name(), e.Transport.Name(), e.Config.Interval)
+
+	ticker := time.NewTicker(e.Config.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := e.RunOnce(ctx); err != nil {
+			e.Logger.Printf("ERP export failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			e.Logger.Println("ERP exporter stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce exports the orders completed between the checkpoint and the lag
+// and moves the checkpoint forward. It returns the number of documents
+// delivered. The first run only sets the checkpoint, so orders completed
+// before the exporter was enabled are exported on request only.
+func (e *Exporter) RunOnce(ctx context.Context) (int, error) {
+	checkpoint, err := e.checkpoint(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	until := time.Now().UTC().Add(-e.Config.Lag)
+	if checkpoint.IsZero() {
+		return 0, e.saveCheckpoint(ctx, until)
+	}
+	if !until.After(checkpoint) {
+		return 0, nil
+	}
+
+	purchaseOrderIDs, err := e.completed(ctx, checkpoint, until)
+	if err != nil {
+		return 0, err
+	}
+
+	// An order failing to load leaves the checkpoint in place, so the next
+	// run tries the window again; orders already exported are skipped
+	delivered := 0
+	for _, purchaseOrderID := range purchaseOrderIDs {
+		purchaseOrder, err := cqrs.FindPurchaseOrder(ctx, e.DynamoDB, purchaseOrderID, false)
+		if errors.Is(err, cqrs.ErrPurchaseOrderNotFound) {
+			e.Logger.Printf("Completed purchase order not found, not exported - purchase_order_id: %s", purchaseOrderID)
+			continue
+		}
+		if err != nil {
+			return delivered, err
+		}
+		if purchaseOrder.Status != models.StatusCompleted {
+			continue
+		}
+
+		deliveries, err := e.prepare(ctx, purchaseOrder, false)
+		if err != nil {
+			return delivered, err
+		}
+		for _, delivery := range deliveries {
+			e.record(ctx, delivery)
+			if e.deliver(ctx, delivery, purchaseOrder) {
+				delivered++
+			}
+		}
+	}
+
+	if err := e.saveCheckpoint(ctx, until); err != nil {
+		return delivered, err
+	}
+
+	if len(purchaseOrderIDs) > 0 {
+		e.Logger.Printf("Completed purchase orders exported to ERP - orders: %d, delivered: %d, checkpoint: %s", len(purchaseOrderIDs), delivered, until.Format(time.RFC3339))
+	}
+	return delivered, nil
+}
+
+// ExportPurchaseOrder exports every document of a completed order again, for
+// instance one completed before export was enabled. Delivery runs in the
+// background; the returned deliveries are pending.
+func (e *Exporter) ExportPurchaseOrder(ctx context.Context, purchaseOrderID string) ([]*models.ERPDelivery, error) {
+	if e == nil {
+		return nil, ErrNotConfigured
+	}
+
+	purchaseOrder, err := cqrs.FindPurchaseOrder(ctx, e.DynamoDB, purchaseOrderID, false)
+	if err != nil {
+		return nil, err
+	}
+	if purchaseOrder.Status != models.StatusCompleted {
+		return nil, fmt.Errorf("%w: status is %s", ErrNotExportable, purchaseOrder.Status)
+	}
+
+	deliveries, err := e.prepare(ctx, purchaseOrder, true)
+	if err != nil {
+		return nil, err
+	}
+	pending := make([]*models.ERPDelivery, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		if err := e.Store.Save(ctx, delivery); err != nil {
+			return nil, err
+		}
+		copied := *delivery
+		pending = append(pending, &copied)
+	}
+
+	e.background(ctx, deliveries, purchaseOrder)
+	return pending, nil
+}
+
+// Replay delivers a document again, rendered from the current order.
+// Delivery runs in the background; the returned delivery is pending.
+func (e *Exporter) Replay(ctx context.Context, id string) (*models.ERPDelivery, error) {
+	if e == nil {
+		return nil, ErrNotConfigured
+	}
+
+	delivery, err := e.Store.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	purchaseOrder, err := cqrs.FindPurchaseOrder(ctx, e.DynamoDB, delivery.PurchaseOrderID, false)
+	if err != nil {
+		return nil, err
+	}
+
+	e.reset(delivery)
+	delivery.Replays++
+	if err := e.Store.Save(ctx, delivery); err != nil {
+		return nil, err
+	}
+	pending := *delivery
+
+	e.background(ctx, []*models.ERPDelivery{delivery}, purchaseOrder)
+	return &pending, nil
+}
+
+// Wait blocks until in-flight deliveries finish
+func (e *Exporter) Wait() {
+	if e == nil {
+		return
+	}
+	e.wg.Wait()
+}
+
+// prepare returns the pending deliveries of the documents of an order. Unless
+// forced, documents that already have a delivery are left out.
+func (e *Exporter) prepare(ctx context.Context, purchaseOrder *models.PurchaseOrder, force bool) ([]*models.ERPDelivery, error) {
+	var deliveries []*models.ERPDelivery
+	for _, documentType := range models.ERPDocumentTypes {
+		if documentType == models.ERPDocumentGoodsReceipt && purchaseOrder.Receipt == nil {
+			continue
+		}
+
+		delivery, err := e.Store.Get(ctx, models.ERPDeliveryID(purchaseOrder.ID, documentType))
+		switch {
+		case errors.Is(err, ErrDeliveryNotFound):
+			delivery = models.NewERPDelivery(purchaseOrder, documentType, time.Now())
+		case err != nil:
+			return nil, err
+		case !force:
+			continue
+		default:
+			delivery.Replays++
+		}
+
+		e.reset(delivery)
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, nil
+}
+
+// reset makes a delivery pending with the current format and transport
+func (e *Exporter) reset(delivery *models.ERPDelivery) {
+	delivery.Format = e.Format.Name()
+	delivery.Transport = e.Transport.Name()
+	delivery.FileName = fmt.Sprintf("%s%s_%s.%s", e.Config.FilePrefix, delivery.DocumentType, delivery.PurchaseOrderID, e.Format.Extension())
+	delivery.Status = models.ERPDeliveryPending
+	delivery.Attempts = 0
+	delivery.UpdatedAt = time.Now().UTC()
+}
+
+// background delivers documents without holding up the caller. The tenant
+// in ctx is kept.
+func (e *Exporter) background(ctx context.Context, deliveries []*models.ERPDelivery, purchaseOrder *models.PurchaseOrder) {
+	ctx = context.WithoutCancel(ctx)
+
+	e.wg.Add(1)
+	go func() {
+		defer e.wg.Done()
+		for _, delivery := range deliveries {
+			e.deliver(ctx, delivery, purchaseOrder)
+		}
+	}()
+}
+
+// deliver renders and delivers one document, retrying with exponential
+// backoff and recording the outcome of every attempt. It reports whether
+// the document was delivered.
+func (e *Exporter) deliver(ctx context.Context, delivery *models.ERPDelivery, purchaseOrder *models.PurchaseOrder) bool {
+	body, err := e.Format.Render(delivery, purchaseOrder)
+	if err != nil {
+		delivery.Status = models.ERPDeliveryFailed
+		delivery.LastError = err.Error()
+		delivery.UpdatedAt = time.Now().UTC()
+		e.record(ctx, delivery)
+		e.Logger.Printf("Failed to render ERP document - delivery_id: %s, error: %v", delivery.ID, err)
+		return false
+	}
+	document := &Document{Delivery: delivery, ContentType: e.Format.ContentType(), Body: body}
+
+	backoff := e.Config.Retry.Backoff
+	for attempt := 1; attempt <= e.Config.Retry.MaxAttempts; attempt++ {
+		deliverCtx, cancel := context.WithTimeout(ctx, e.Config.Timeout)
+		reference, err := e.Transport.Deliver(deliverCtx, document)
+		cancel()
+
+		delivery.Attempts = attempt
+		delivery.UpdatedAt = time.Now().UTC()
+		if err == nil {
+			deliveredAt := delivery.UpdatedAt
+			delivery.Status = models.ERPDeliveryDelivered
+			delivery.Reference = reference
+			delivery.LastError = ""
+			delivery.DeliveredAt = &deliveredAt
+			e.record(ctx, delivery)
+			e.Logger.Printf("ERP document delivered - delivery_id: %s, document_number: %s, transport: %s, reference: %s", delivery.ID, delivery.DocumentNumber, delivery.Transport, reference)
+			return true
+		}
+
+		delivery.LastError = err.Error()
+		e.Logger.Printf("ERP delivery failed - delivery_id: %s, transport: %s, attempt: %d/%d, error: %v",
+			delivery.ID, delivery.Transport, attempt, e.Config.Retry.MaxAttempts, err)
+
+		if attempt < e.Config.Retry.MaxAttempts {
+			e.record(ctx, delivery)
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+
+	delivery.Status = models.ERPDeliveryFailed
+	e.record(ctx, delivery)
+	e.Logger.Printf("Giving up on ERP delivery - delivery_id: %s, purchase_order_id: %s", delivery.ID, delivery.PurchaseOrderID)
+	return false
+}
+
+// record stores the delivery status; tracking failures never block delivery
+func (e *Exporter) record(ctx context.Context, delivery *models.ERPDelivery) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	if err := e.Store.Save(ctx, delivery); err != nil {
+		e.Logger.Printf("Failed to record ERP delivery %s: %v", delivery.ID, err)
+	}
+}
+
+// completed returns the IDs of the orders completed after since and up to
+// until, either on receipt or by a status update, oldest first
+func (e *Exporter) completed(ctx context.Context, since, until time.Time) ([]string, error) {
+	scanInput := &dynamodb.ScanInput{
+		TableName:            aws.String("orden-compra-events"),
+		FilterExpression:     aws.String("event_type IN (:completed, :updated) AND event_data.status_change.new_status = :status AND #timestamp > :since AND #timestamp <= :until"),
+		ProjectionExpression: aws.String("aggregate_id, #timestamp"),
+		ExpressionAttributeNames: map[string]*string{
+			"#timestamp": aws.String("timestamp"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":completed": {S: aws.String(string(models.PurchaseOrderCompletedEventType))},
+			":updated":   {S: aws.String("PurchaseOrderStatusUpdated")},
+			":status":    {S: aws.String(models.StatusCompleted)},
+			":since":     {S: aws.String(since.Format(time.RFC3339Nano))},
+			":until":     {S: aws.String(until.Format(time.RFC3339Nano))},
+		},
+	}
+
+	type completion struct {
+		AggregateID string    `dynamodbav:"aggregate_id"`
+		Timestamp   time.Time `dynamodbav:"timestamp"`
+	}
+	var completions []completion
+	for {
+		result, err := e.DynamoDB.ScanWithContext(ctx, scanInput)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan events: %w", err)
+		}
+
+		for _, item := range result.Items {
+			var event completion
+			if err := dynamodbattribute.UnmarshalMap(item, &event); err != nil {
+				e.Logger.Printf("Failed to unmarshal event, not exported: %v", err)
+				continue
+			}
+			completions = append(completions, event)
+		}
+
+		if len(result.LastEvaluatedKey) == 0 {
+			break
+		}
+		scanInput.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+
+	sort.SliceStable(completions, func(i, j int) bool {
+		return completions[i].Timestamp.Before(completions[j].Timestamp)
+	})
+	seen := make(map[string]bool, len(completions))
+	purchaseOrderIDs := make([]string, 0, len(completions))
+	for _, event := range completions {
+		if !seen[event.AggregateID] {
+			seen[event.AggregateID] = true
+			purchaseOrderIDs = append(purchaseOrderIDs, event.AggregateID)
+		}
+	}
+	return purchaseOrderIDs, nil
+}
+
+// checkpoint returns the timestamp orders were last exported up to, or the
+// zero time before the first run
+func (e *Exporter) checkpoint(ctx context.Context) (time.Time, error) {
+	result, err := e.DynamoDB.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(checkpointsTable),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {S: aws.String(checkpointID)},
+		},
+	})
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get ERP export checkpoint: %w", err)
+	}
+	if result.Item == nil {
+		return time.Time{}, nil
+	}
+
+	var item struct {
+		Timestamp time.Time `dynamodbav:"timestamp"`
+	}
+	if err := dynamodbattribute.UnmarshalMap(result.Item, &item); err != nil {
+		return time.Time{}, fmt.Errorf("failed to unmarshal ERP export checkpoint: %w", err)
+	}
+	return item.Timestamp, nil
+}
+
+// saveCheckpoint records the timestamp orders were exported up to
+func (e *Exporter) saveCheckpoint(ctx context.Context, timestamp time.Time) error {
+	_, err := e.DynamoDB.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(checkpointsTable),
+		Item: map[string]*dynamodb.AttributeValue{
+			"id":        {S: aws.String(checkpointID)},
+			"timestamp": {S: aws.String(timestamp.Format(time.RFC3339Nano))},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save ERP export checkpoint: %w", err)
+	}
+	return nil
+}
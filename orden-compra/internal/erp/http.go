@@ -0,0 +1,70 @@
+package erp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Headers sent with every document posted over HTTP
+const (
+	HeaderDocumentType   = "X-ERP-Document-Type"
+	HeaderDocumentNumber = "X-ERP-Document-Number"
+	HeaderFileName       = "X-ERP-File-Name"
+	HeaderPurchaseOrder  = "X-ERP-Purchase-Order"
+)
+
+// HTTPTransport posts documents to an ERP endpoint, such as an SAP PI/PO or
+// Integration Suite adapter
+type HTTPTransport struct {
+	URL string
+	// Authorization is sent as the Authorization header when set
+	Authorization string
+	Client        *http.Client
+}
+
+// NewHTTPTransport creates a transport posting to url
+func NewHTTPTransport(url, authorization string) *HTTPTransport {
+	return &HTTPTransport{
+		URL:           url,
+		Authorization: authorization,
+		Client:        &http.Client{},
+	}
+}
+
+// Name returns the transport name
+func (t *HTTPTransport) Name() string {
+	return "http"
+}
+
+// Deliver posts the document and treats any non-2xx response as a failure.
+// The Location of the created resource is returned as the reference.
+func (t *HTTPTransport) Deliver(ctx context.Context, document *Document) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.URL, bytes.NewReader(document.Body))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", document.ContentType)
+	req.Header.Set(HeaderDocumentType, document.Delivery.DocumentType)
+	req.Header.Set(HeaderDocumentNumber, document.Delivery.DocumentNumber)
+	req.Header.Set(HeaderFileName, document.Delivery.FileName)
+	req.Header.Set(HeaderPurchaseOrder, document.Delivery.PurchaseOrderID)
+	if t.Authorization != "" {
+		req.Header.Set("Authorization", t.Authorization)
+	}
+
+	resp, err := t.Client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to post document: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", fmt.Errorf("unexpected status %d from ERP endpoint", resp.StatusCode)
+	}
+
+	return resp.Header.Get("Location"), nil
+}
@@ -0,0 +1,313 @@
+package erp
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"time"
+
+	"orden-compra/internal/models"
+)
+
+// IDoc types and message types of the exported documents
+const (
+	idocTypeOrders       = "ORDERS05"
+	messageTypeOrders    = "ORDERS"
+	idocTypeGoodsReceipt = "WMMBID02"
+	messageTypeGoodsMove = "WMMBXY"
+)
+
+// IDocPartners identifies the sending and receiving systems in the IDoc
+// control record
+type IDocPartners struct {
+	// Client is the SAP client the IDocs are posted in, e.g. 100
+	Client          string
+	SenderPort      string
+	SenderPartner   string
+	ReceiverPort    string
+	ReceiverPartner string
+}
+
+// IDocFormat renders purchase orders as ORDERS05 and goods receipts as
+// WMMBID02 IDocs in the XML form SAP's IDoc XML inbound port reads. Each
+// order has a single item, position 10.
+type IDocFormat struct {
+	Partners IDocPartners
+}
+
+// NewIDocFormat creates a new IDoc format
+func NewIDocFormat(partners IDocPartners) *IDocFormat {
+	return &IDocFormat{Partners: partners}
+}
+
+// Name returns the format name
+func (f *IDocFormat) Name() string {
+	return "idoc"
+}
+
+// Extension returns the file name extension of IDoc documents
+func (f *IDocFormat) Extension() string {
+	return "xml"
+}
+
+// ContentType returns the content type of IDoc documents
+func (f *IDocFormat) ContentType() string {
+	return "application/xml"
+}
+
+// controlRecord is the EDI_DC40 control record heading every IDoc
+type controlRecord struct {
+	Segment string `xml:"SEGMENT,attr"`
+	TabNam  string `xml:"TABNAM"`
+	Mandt   string `xml:"MANDT,omitempty"`
+	DocNum  string `xml:"DOCNUM"`
+	Direct  string `xml:"DIRECT"`
+	IDocTyp string `xml:"IDOCTYP"`
+	MesTyp  string `xml:"MESTYP"`
+	SndPor  string `xml:"SNDPOR"`
+	SndPrt  string `xml:"SNDPRT"`
+	SndPrn  string `xml:"SNDPRN"`
+	RcvPor  string `xml:"RCVPOR"`
+	RcvPrt  string `xml:"RCVPRT"`
+	RcvPrn  string `xml:"RCVPRN"`
+	CreDat  string `xml:"CREDAT"`
+	CreTim  string `xml:"CRETIM"`
+}
+
+// ordersIDoc is an ORDERS05 purchase order
+type ordersIDoc struct {
+	XMLName xml.Name `xml:"ORDERS05"`
+	IDoc    struct {
+		Begin   string           `xml:"BEGIN,attr"`
+		Control controlRecord    `xml:"EDI_DC40"`
+		Header  ordersHeader     `xml:"E1EDK01"`
+		Dates   []ordersDate     `xml:"E1EDK03"`
+		Partner ordersPartner    `xml:"E1EDKA1"`
+		Ref     ordersReference  `xml:"E1EDK02"`
+		Item    ordersItem       `xml:"E1EDP01"`
+		Summary *ordersSummation `xml:"E1EDS01,omitempty"`
+	} `xml:"IDOC"`
+}
+
+type ordersHeader struct {
+	Segment string `xml:"SEGMENT,attr"`
+	Curcy   string `xml:"CURCY,omitempty"`
+	Belnr   string `xml:"BELNR"`
+}
+
+type ordersDate struct {
+	Segment string `xml:"SEGMENT,attr"`
+	Iddat   string `xml:"IDDAT"`
+	Datum   string `xml:"DATUM"`
+}
+
+type ordersPartner struct {
+	Segment string `xml:"SEGMENT,attr"`
+	Parvw   string `xml:"PARVW"`
+	Partn   string `xml:"PARTN"`
+	Name1   string `xml:"NAME1,omitempty"`
+}
+
+type ordersReference struct {
+	Segment string `xml:"SEGMENT,attr"`
+	Qualf   string `xml:"QUALF"`
+	Belnr   string `xml:"BELNR"`
+}
+
+type ordersItem struct {
+	Segment string            `xml:"SEGMENT,attr"`
+	Posex   string            `xml:"POSEX"`
+	Menge   string            `xml:"MENGE"`
+	Menee   string            `xml:"MENEE"`
+	Vprei   string            `xml:"VPREI,omitempty"`
+	Netwr   string            `xml:"NETWR,omitempty"`
+	Werks   string            `xml:"WERKS,omitempty"`
+	Object  ordersItemObject  `xml:"E1EDP19"`
+	Dates   []ordersItemDates `xml:"E1EDP20,omitempty"`
+}
+
+type ordersItemObject struct {
+	Segment string `xml:"SEGMENT,attr"`
+	Qualf   string `xml:"QUALF"`
+	Idtnr   string `xml:"IDTNR"`
+	Ktext   string `xml:"KTEXT,omitempty"`
+}
+
+type ordersItemDates struct {
+	Segment string `xml:"SEGMENT,attr"`
+	Wmeng   string `xml:"WMENG"`
+	Edatu   string `xml:"EDATU"`
+}
+
+type ordersSummation struct {
+	Segment string `xml:"SEGMENT,attr"`
+	Sumid   string `xml:"SUMID"`
+	Summe   string `xml:"SUMME"`
+	Sunit   string `xml:"SUNIT,omitempty"`
+}
+
+// goodsReceiptIDoc is a WMMBID02 goods movement
+type goodsReceiptIDoc struct {
+	XMLName xml.Name `xml:"WMMBID02"`
+	IDoc    struct {
+		Begin   string            `xml:"BEGIN,attr"`
+		Control controlRecord     `xml:"EDI_DC40"`
+		Header  goodsReceiptHead  `xml:"E1MBXYH"`
+		Item    goodsReceiptEntry `xml:"E1MBXYI"`
+	} `xml:"IDOC"`
+}
+
+type goodsReceiptHead struct {
+	Segment string `xml:"SEGMENT,attr"`
+	Bldat   string `xml:"BLDAT"`
+	Budat   string `xml:"BUDAT"`
+	Xblnr   string `xml:"XBLNR,omitempty"`
+	Bktxt   string `xml:"BKTXT,omitempty"`
+	Tcode   string `xml:"TCODE"`
+}
+
+type goodsReceiptEntry struct {
+	Segment string `xml:"SEGMENT,attr"`
+	Matnr   string `xml:"MATNR"`
+	Werks   string `xml:"WERKS,omitempty"`
+	Bwart   string `xml:"BWART"`
+	Erfmg   string `xml:"ERFMG"`
+	Erfme   string `xml:"ERFME"`
+	Ebeln   string `xml:"EBELN"`
+	Ebelp   string `xml:"EBELP"`
+	Lifnr   string `xml:"LIFNR,omitempty"`
+	Charg   string `xml:"CHARG,omitempty"`
+	Vfdat   string `xml:"VFDAT,omitempty"`
+	Kzbew   string `xml:"KZBEW"`
+}
+
+// Render renders the document of the delivery
+func (f *IDocFormat) Render(delivery *models.ERPDelivery, purchaseOrder *models.PurchaseOrder) ([]byte, error) {
+	var document interface{}
+	switch delivery.DocumentType {
+	case models.ERPDocumentPurchaseOrder:
+		document = f.orders(delivery, purchaseOrder)
+	case models.ERPDocumentGoodsReceipt:
+		if purchaseOrder.Receipt == nil {
+			return nil, fmt.Errorf("purchase order %s has no receipt", purchaseOrder.ID)
+		}
+		document = f.goodsReceipt(delivery, purchaseOrder)
+	default:
+		return nil, fmt.Errorf("unknown document type %s", delivery.DocumentType)
+	}
+
+	body, err := xml.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal IDoc: %w", err)
+	}
+	return append([]byte(xml.Header), body...), nil
+}
+
+// control returns the control record of an outbound IDoc
+func (f *IDocFormat) control(delivery *models.ERPDelivery, idocType, messageType string) controlRecord {
+	now := time.Now().UTC()
+	return controlRecord{
+		Segment: "1",
+		TabNam:  "EDI_DC40",
+		Mandt:   f.Partners.Client,
+		DocNum:  delivery.DocumentNumber,
+		// Inbound to the ERP
+		Direct:  "2",
+		IDocTyp: idocType,
+		MesTyp:  messageType,
+		SndPor:  f.Partners.SenderPort,
+		SndPrt:  "LS",
+		SndPrn:  f.Partners.SenderPartner,
+		RcvPor:  f.Partners.ReceiverPort,
+		RcvPrt:  "LS",
+		RcvPrn:  f.Partners.ReceiverPartner,
+		CreDat:  now.Format("20060102"),
+		CreTim:  now.Format("150405"),
+	}
+}
+
+// orders builds the ORDERS05 IDoc of a purchase order
+func (f *IDocFormat) orders(delivery *models.ERPDelivery, purchaseOrder *models.PurchaseOrder) *ordersIDoc {
+	idoc := &ordersIDoc{}
+	idoc.IDoc.Begin = "1"
+	idoc.IDoc.Control = f.control(delivery, idocTypeOrders, messageTypeOrders)
+	idoc.IDoc.Header = ordersHeader{Segment: "1", Belnr: purchaseOrder.ID}
+	// Document date
+	idoc.IDoc.Dates = []ordersDate{{Segment: "1", Iddat: "012", Datum: idocDate(purchaseOrder.CreatedAt)}}
+	// Vendor
+	idoc.IDoc.Partner = ordersPartner{Segment: "1", Parvw: "LF", Partn: purchaseOrder.SupplierID, Name1: purchaseOrder.SupplierName}
+	// Customer purchase order number
+	idoc.IDoc.Ref = ordersReference{Segment: "1", Qualf: "001", Belnr: purchaseOrder.ID}
+	idoc.IDoc.Item = ordersItem{
+		Segment: "1",
+		Posex:   "000010",
+		Menge:   strconv.Itoa(purchaseOrder.Quantity),
+		Menee:   "EA",
+		Werks:   purchaseOrder.Location,
+		// Buyer's material number
+		Object: ordersItemObject{Segment: "1", Qualf: "001", Idtnr: purchaseOrder.ProductID, Ktext: purchaseOrder.ProductName},
+	}
+
+	if purchaseOrder.ExpectedDate != nil {
+		// Requested delivery date
+		idoc.IDoc.Dates = append(idoc.IDoc.Dates, ordersDate{Segment: "1", Iddat: "002", Datum: idocDate(*purchaseOrder.ExpectedDate)})
+		idoc.IDoc.Item.Dates = []ordersItemDates{{Segment: "1", Wmeng: strconv.Itoa(purchaseOrder.Quantity), Edatu: idocDate(*purchaseOrder.ExpectedDate)}}
+	}
+
+	if pricing := purchaseOrder.Pricing; pricing != nil {
+		idoc.IDoc.Header.Curcy = pricing.Currency
+		idoc.IDoc.Item.Vprei = idocAmount(pricing.UnitPrice)
+		idoc.IDoc.Item.Netwr = idocAmount(pricing.TotalAmount)
+		// Total net value of the order
+		idoc.IDoc.Summary = &ordersSummation{Segment: "1", Sumid: "002", Summe: idocAmount(pricing.TotalAmount), Sunit: pricing.Currency}
+	}
+
+	return idoc
+}
+
+// goodsReceipt builds the WMMBID02 IDoc posting the receipt of a purchase
+// order as a goods receipt for purchase order, movement type 101
+func (f *IDocFormat) goodsReceipt(delivery *models.ERPDelivery, purchaseOrder *models.PurchaseOrder) *goodsReceiptIDoc {
+	receipt := purchaseOrder.Receipt
+
+	idoc := &goodsReceiptIDoc{}
+	idoc.IDoc.Begin = "1"
+	idoc.IDoc.Control = f.control(delivery, idocTypeGoodsReceipt, messageTypeGoodsMove)
+	idoc.IDoc.Header = goodsReceiptHead{
+		Segment: "1",
+		Bldat:   idocDate(receipt.ReceivedAt),
+		Budat:   idocDate(receipt.ReceivedAt),
+		Xblnr:   receipt.EventID,
+		Bktxt:   receipt.Reconciliation,
+		Tcode:   "MB01",
+	}
+	idoc.IDoc.Item = goodsReceiptEntry{
+		Segment: "1",
+		Matnr:   purchaseOrder.ProductID,
+		Werks:   purchaseOrder.Location,
+		Bwart:   "101",
+		Erfmg:   strconv.Itoa(receipt.ReceivedQuantity),
+		Erfme:   "EA",
+		Ebeln:   purchaseOrder.ID,
+		Ebelp:   "00010",
+		Lifnr:   purchaseOrder.SupplierID,
+		Charg:   receipt.BatchNumber,
+		// Goods movement for purchase order
+		Kzbew: "B",
+	}
+	if receipt.ExpiryDate != nil {
+		idoc.IDoc.Item.Vfdat = idocDate(*receipt.ExpiryDate)
+	}
+
+	return idoc
+}
+
+// idocDate formats a date as IDocs do
+func idocDate(t time.Time) string {
+	return t.UTC().Format("20060102")
+}
+
+// idocAmount formats an amount with two decimals
+func idocAmount(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', 2, 64)
+}
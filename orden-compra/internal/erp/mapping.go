@@ -0,0 +1,163 @@
+package erp
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"orden-compra/internal/models"
+)
+
+// Mapping maps the fields of an exported JSON document to the purchase order
+// field each is read from, as a dotted path into the order's JSON, e.g.
+// "receipt.batch_number". A source starting with "=" is a constant, so
+// "=101" always writes "101". Fields whose source is missing are null.
+type Mapping map[string]string
+
+// DefaultMappings are the mappings of each document type used unless
+// configured otherwise
+var DefaultMappings = map[string]Mapping{
+	models.ERPDocumentPurchaseOrder: {
+		"document_type": "=purchase_order",
+		"po_number":     "id",
+		"vendor":        "supplier_id",
+		"vendor_name":   "supplier_name",
+		"material":      "product_id",
+		"description":   "product_name",
+		"quantity":      "quantity",
+		"unit":          "=EA",
+		"plant":         "location",
+		"currency":      "pricing.currency",
+		"net_price":     "pricing.unit_price",
+		"net_value":     "pricing.total_amount",
+		"document_date": "created_at",
+		"delivery_date": "expected_date",
+	},
+	models.ERPDocumentGoodsReceipt: {
+		"document_type":    "=goods_receipt",
+		"po_number":        "id",
+		"movement_type":    "=101",
+		"vendor":           "supplier_id",
+		"material":         "product_id",
+		"plant":            "location",
+		"quantity":         "receipt.received_quantity",
+		"ordered_quantity": "receipt.ordered_quantity",
+		"unit":             "=EA",
+		"batch":            "receipt.batch_number",
+		"expiry_date":      "receipt.expiry_date",
+		"posting_date":     "receipt.received_at",
+		"reference":        "receipt.event_id",
+	},
+}
+
+// ParseMappings reads the mappings of a JSON object keyed by document type,
+// e.g. {"goods_receipt": {"menge": "receipt.received_quantity"}}. A document
+// type it leaves out keeps its default mapping.
+func ParseMappings(data string) (map[string]Mapping, error) {
+	mappings := make(map[string]Mapping, len(DefaultMappings))
+	for documentType, mapping := range DefaultMappings {
+		mappings[documentType] = mapping
+	}
+	if strings.TrimSpace(data) == "" {
+		return mappings, nil
+	}
+
+	var configured map[string]Mapping
+	if err := json.Unmarshal([]byte(data), &configured); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidMapping, err)
+	}
+	for documentType, mapping := range configured {
+		if _, ok := DefaultMappings[documentType]; !ok {
+			return nil, fmt.Errorf("%w: unknown document type %q", ErrInvalidMapping, documentType)
+		}
+		if len(mapping) == 0 {
+			return nil, fmt.Errorf("%w: %s maps no fields", ErrInvalidMapping, documentType)
+		}
+		for field, source := range mapping {
+			if strings.TrimSpace(field) == "" || strings.TrimSpace(source) == "" {
+				return nil, fmt.Errorf("%w: %s has an empty field or source", ErrInvalidMapping, documentType)
+			}
+		}
+		mappings[documentType] = mapping
+	}
+	return mappings, nil
+}
+
+// JSONFormat renders the documents of a purchase order as flat JSON objects
+// laid out by a mapping per document type, for ERPs or middleware that take
+// JSON rather than IDocs
+type JSONFormat struct {
+	Mappings map[string]Mapping
+}
+
+// NewJSONFormat creates a new JSON format
+func NewJSONFormat(mappings map[string]Mapping) *JSONFormat {
+	return &JSONFormat{Mappings: mappings}
+}
+
+// Name returns the format name
+func (f *JSONFormat) Name() string {
+	return "json"
+}
+
+// Extension returns the file name extension of JSON documents
+func (f *JSONFormat) Extension() string {
+	return "json"
+}
+
+// ContentType returns the content type of JSON documents
+func (f *JSONFormat) ContentType() string {
+	return "application/json"
+}
+
+// Render renders the document of the delivery
+func (f *JSONFormat) Render(delivery *models.ERPDelivery, purchaseOrder *models.PurchaseOrder) ([]byte, error) {
+	mapping, ok := f.Mappings[delivery.DocumentType]
+	if !ok {
+		return nil, fmt.Errorf("unknown document type %s", delivery.DocumentType)
+	}
+	if delivery.DocumentType == models.ERPDocumentGoodsReceipt && purchaseOrder.Receipt == nil {
+		return nil, fmt.Errorf("purchase order %s has no receipt", purchaseOrder.ID)
+	}
+
+	// Paths are resolved against the order as the API returns it
+	encoded, err := json.Marshal(purchaseOrder)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal purchase order: %w", err)
+	}
+	var source map[string]interface{}
+	if err := json.Unmarshal(encoded, &source); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal purchase order: %w", err)
+	}
+
+	document := make(map[string]interface{}, len(mapping)+1)
+	for field, path := range mapping {
+		if constant, ok := strings.CutPrefix(path, "="); ok {
+			document[field] = constant
+			continue
+		}
+		document[field] = lookup(source, path)
+	}
+	if _, ok := document["document_number"]; !ok {
+		document["document_number"] = delivery.DocumentNumber
+	}
+
+	body, err := json.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal document: %w", err)
+	}
+	return body, nil
+}
+
+// lookup returns the value at a dotted path, or nil when any part is missing
+func lookup(source map[string]interface{}, path string) interface{} {
+	var value interface{} = source
+	for _, key := range strings.Split(path, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = object[key]
+	}
+	return value
+}
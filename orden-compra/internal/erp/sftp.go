@@ -0,0 +1,158 @@
+package erp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"path"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// posixRename is the OpenSSH extension replacing an existing file on rename
+const posixRename = "posix-rename@openssh.com"
+
+// SFTPConfig represents the settings of the SFTP transport
+type SFTPConfig struct {
+	// Addr is the host:port of the SFTP server
+	Addr     string
+	User     string
+	Password string
+	// PrivateKey is a PEM private key, used instead of or with the password
+	PrivateKey string
+	// HostKey is the server's public key in authorized_keys format, checked
+	// on every connection
+	HostKey string
+	// Directory is the remote directory documents are uploaded to
+	Directory string
+}
+
+// SFTPTransport uploads each document as a file to a directory the ERP
+// polls, such as an SAP file port. The file is written under a temporary
+// name and renamed once complete, so the ERP never reads a partial file.
+type SFTPTransport struct {
+	Config       SFTPConfig
+	clientConfig *ssh.ClientConfig
+}
+
+// NewSFTPTransport creates an SFTP transport, checking its keys up front
+func NewSFTPTransport(config SFTPConfig) (*SFTPTransport, error) {
+	if config.HostKey == "" {
+		return nil, errors.New("SFTP host key is required")
+	}
+	hostKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(config.HostKey))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse SFTP host key: %w", err)
+	}
+
+	var methods []ssh.AuthMethod
+	if config.PrivateKey != "" {
+		signer, err := ssh.ParsePrivateKey([]byte(config.PrivateKey))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse SFTP private key: %w", err)
+		}
+		methods = append(methods, ssh.PublicKeys(signer))
+	}
+	if config.Password != "" {
+		methods = append(methods, ssh.Password(config.Password))
+	}
+	if len(methods) == 0 {
+		return nil, errors.New("SFTP password or private key is required")
+	}
+
+	return &SFTPTransport{
+		Config: config,
+		clientConfig: &ssh.ClientConfig{
+			User:            config.User,
+			Auth:            methods,
+			HostKeyCallback: ssh.FixedHostKey(hostKey),
+		},
+	}, nil
+}
+
+// Name returns the transport name
+func (t *SFTPTransport) Name() string {
+	return "sftp"
+}
+
+// Deliver uploads the document over a new connection and returns its remote
+// path. An earlier upload of the same file is replaced.
+func (t *SFTPTransport) Deliver(ctx context.Context, document *Document) (string, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", t.Config.Addr)
+	if err != nil {
+		return "", fmt.Errorf("failed to connect to SFTP server: %w", err)
+	}
+	defer conn.Close()
+
+	// The deadline of ctx bounds the whole session, not just the dial
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	sshConn, channels, requests, err := ssh.NewClientConn(conn, t.Config.Addr, t.clientConfig)
+	if err != nil {
+		return "", fmt.Errorf("failed to open SSH connection: %w", err)
+	}
+	client := ssh.NewClient(sshConn, channels, requests)
+	defer client.Close()
+
+	sftpClient, err := sftp.NewClient(client)
+	if err != nil {
+		return "", fmt.Errorf("failed to start SFTP subsystem: %w", err)
+	}
+	defer sftpClient.Close()
+
+	remotePath := path.Join(t.Config.Directory, document.Delivery.FileName)
+	tempPath := path.Join(t.Config.Directory, "."+document.Delivery.FileName+".part")
+	if err := upload(sftpClient, tempPath, document.Body); err != nil {
+		return "", err
+	}
+	if err := replace(sftpClient, tempPath, remotePath); err != nil {
+		return "", err
+	}
+
+	return remotePath, nil
+}
+
+// upload writes data to a new or truncated file
+func upload(client *sftp.Client, remotePath string, data []byte) error {
+	file, err := client.OpenFile(remotePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", remotePath, err)
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write %s: %w", remotePath, err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to close %s: %w", remotePath, err)
+	}
+	return nil
+}
+
+// replace renames from to to, replacing to when it exists. Servers without
+// the posix-rename extension refuse to rename onto an existing file, so it
+// is removed first.
+func replace(client *sftp.Client, from, to string) error {
+	if _, ok := client.HasExtension(posixRename); ok {
+		if err := client.PosixRename(from, to); err != nil {
+			return fmt.Errorf("failed to rename %s to %s: %w", from, to, err)
+		}
+		return nil
+	}
+
+	if err := client.Remove(to); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to remove %s: %w", to, err)
+	}
+	if err := client.Rename(from, to); err != nil {
+		return fmt.Errorf("failed to rename %s to %s: %w", from, to, err)
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+package erp
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+
+	"orden-compra/internal/models"
+)
+
+// newSigner generates an SSH key and returns it as a signer and in PEM
+func newSigner(t *testing.T) (ssh.Signer, string) {
+	t.Helper()
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	block, err := ssh.MarshalPrivateKey(private, "")
+	if err != nil {
+		t.Fatalf("marshal key: %v", err)
+	}
+	signer, err := ssh.NewSignerFromKey(private)
+	if err != nil {
+		t.Fatalf("signer: %v", err)
+	}
+	return signer, string(pem.EncodeToMemory(block))
+}
+
+// startSFTP serves SFTP over the local file system to the user "erp" with
+// password "secret" or clientKey, and returns its address and host key in
+// authorized_keys format
+func startSFTP(t *testing.T, clientKey ssh.PublicKey) (string, string) {
+	t.Helper()
+	hostKey, _ := newSigner(t)
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if conn.User() == "erp" && string(password) == "secret" {
+				return nil, nil
+			}
+			return nil, ssh.ErrNoAuth
+		},
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if conn.User() == "erp" && clientKey != nil && string(key.Marshal()) == string(clientKey.Marshal()) {
+				return nil, nil
+			}
+			return nil, ssh.ErrNoAuth
+		},
+	}
+	config.AddHostKey(hostKey)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveSFTP(conn, config)
+		}
+	}()
+	return listener.Addr().String(), string(ssh.MarshalAuthorizedKey(hostKey.PublicKey()))
+}
+
+// serveSFTP runs the SFTP subsystem on the sessions of an SSH connection
+func serveSFTP(conn net.Conn, config *ssh.ServerConfig) {
+	defer conn.Close()
+	_, channels, requests, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(requests)
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "session only")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			for request := range requests {
+				ok := request.Type == "subsystem" && string(request.Payload[4:]) == "sftp"
+				request.Reply(ok, nil)
+				if ok {
+					if server, err := sftp.NewServer(channel); err == nil {
+						server.Serve()
+					}
+					channel.Close()
+				}
+			}
+		}()
+	}
+}
+
+func newDocument(fileName, body string) *Document {
+	return &Document{Delivery: &models.ERPDelivery{FileName: fileName}, Body: []byte(body)}
+}
+
+func TestSFTPTransportUploadsAndReplacesTheFile(t *testing.T) {
+	signer, privateKey := newSigner(t)
+	addr, hostKey := startSFTP(t, signer.PublicKey())
+	dir := t.TempDir()
+
+	for name, config := range map[string]SFTPConfig{
+		"password":    {Addr: addr, User: "erp", Password: "secret", HostKey: hostKey, Directory: dir},
+		"private key": {Addr: addr, User: "erp", PrivateKey: privateKey, HostKey: hostKey, Directory: dir},
+	} {
+		t.Run(name, func(t *testing.T) {
+			transport, err := NewSFTPTransport(config)
+			if err != nil {
+				t.Fatalf("new transport: %v", err)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			// A second upload of the same file replaces the first
+			for _, body := range []string{"<ORDERS05>first</ORDERS05>", strings.Repeat("<E1EDP01/>", 10000)} {
+				remotePath, err := transport.Deliver(ctx, newDocument("po-1.xml", body))
+				if err != nil {
+					t.Fatalf("Deliver: %v", err)
+				}
+				if remotePath != filepath.Join(dir, "po-1.xml") {
+					t.Fatalf("delivered to %s", remotePath)
+				}
+				written, err := os.ReadFile(remotePath)
+				if err != nil || string(written) != body {
+					t.Fatalf("remote file holds %d bytes, error %v; want %d bytes", len(written), err, len(body))
+				}
+			}
+
+			// The temporary file was renamed
+			entries, _ := os.ReadDir(dir)
+			if len(entries) != 1 {
+				t.Fatalf("directory holds %d files, want only po-1.xml", len(entries))
+			}
+		})
+	}
+}
+
+func TestSFTPTransportRefusesAnUnknownHostKey(t *testing.T) {
+	addr, _ := startSFTP(t, nil)
+	otherKey, _ := newSigner(t)
+	dir := t.TempDir()
+
+	transport, err := NewSFTPTransport(SFTPConfig{
+		Addr:      addr,
+		User:      "erp",
+		Password:  "secret",
+		HostKey:   string(ssh.MarshalAuthorizedKey(otherKey.PublicKey())),
+		Directory: dir,
+	})
+	if err != nil {
+		t.Fatalf("new transport: %v", err)
+	}
+	if _, err := transport.Deliver(context.Background(), newDocument("po-1.xml", "body")); err == nil || !strings.Contains(err.Error(), "host key mismatch") {
+		t.Fatalf("Deliver returned %v, want a host key mismatch", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Fatalf("directory holds %d files after a refused connection", len(entries))
+	}
+}
+
+func TestSFTPTransportReportsAFailedLogin(t *testing.T) {
+	addr, hostKey := startSFTP(t, nil)
+	transport, err := NewSFTPTransport(SFTPConfig{Addr: addr, User: "erp", Password: "wrong", HostKey: hostKey, Directory: t.TempDir()})
+	if err != nil {
+		t.Fatalf("new transport: %v", err)
+	}
+	if _, err := transport.Deliver(context.Background(), newDocument("po-1.xml", "body")); err == nil || !strings.Contains(err.Error(), "unable to authenticate") {
+		t.Fatalf("Deliver returned %v, want an authentication failure", err)
+	}
+}
+
+func TestNewSFTPTransportRequiresAHostKeyAndCredentials(t *testing.T) {
+	_, hostKey := startSFTP(t, nil)
+	if _, err := NewSFTPTransport(SFTPConfig{Addr: "erp:22", User: "erp", Password: "secret"}); err == nil {
+		t.Fatal("accepted a config without host key")
+	}
+	if _, err := NewSFTPTransport(SFTPConfig{Addr: "erp:22", User: "erp", HostKey: hostKey}); err == nil {
+		t.Fatal("accepted a config without password or private key")
+	}
+}
@@ -0,0 +1,137 @@
+package erp
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+
+	"orden-compra/internal/models"
+)
+
+// Filter selects ERP deliveries; empty fields match every delivery
+type Filter struct {
+	PurchaseOrderID string
+	DocumentType    string
+	Status          string
+	Limit           int
+}
+
+// Store persists ERP deliveries in DynamoDB
+type Store struct {
+	DynamoDB  dynamodbiface.DynamoDBAPI
+	TableName string
+}
+
+// NewStore creates a store on the default ERP deliveries table
+func NewStore(dynamoDB dynamodbiface.DynamoDBAPI) *Store {
+	return &Store{
+		DynamoDB:  dynamoDB,
+		TableName: "orden-compra-erp-deliveries",
+	}
+}
+
+// Save creates or replaces a delivery
+func (s *Store) Save(ctx context.Context, delivery *models.ERPDelivery) error {
+	item, err := dynamodbattribute.MarshalMap(delivery)
+	if err != nil {
+		return fmt.Errorf("failed to marshal ERP delivery: %w", err)
+	}
+
+	_, err = s.DynamoDB.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(s.TableName),
+		Item:      item,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put ERP delivery: %w", err)
+	}
+
+	return nil
+}
+
+// Get returns a delivery by ID
+func (s *Store) Get(ctx context.Context, id string) (*models.ERPDelivery, error) {
+	result, err := s.DynamoDB.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(s.TableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {S: aws.String(id)},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ERP delivery: %w", err)
+	}
+	if result.Item == nil {
+		return nil, ErrDeliveryNotFound
+	}
+
+	var delivery models.ERPDelivery
+	if err := dynamodbattribute.UnmarshalMap(result.Item, &delivery); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal ERP delivery: %w", err)
+	}
+
+	return &delivery, nil
+}
+
+// Query returns the deliveries matching the filter, most recently updated first
+func (s *Store) Query(ctx context.Context, filter Filter) ([]*models.ERPDelivery, error) {
+	scanInput := &dynamodb.ScanInput{
+		TableName: aws.String(s.TableName),
+	}
+
+	var filterExpressions []string
+	expressionAttributeNames := make(map[string]*string)
+	expressionAttributeValues := make(map[string]*dynamodb.AttributeValue)
+
+	addEquals := func(attribute, value string) {
+		if value == "" {
+			return
+		}
+		filterExpressions = append(filterExpressions, fmt.Sprintf("#%s = :%s", attribute, attribute))
+		expressionAttributeNames["#"+attribute] = aws.String(attribute)
+		expressionAttributeValues[":"+attribute] = &dynamodb.AttributeValue{S: aws.String(value)}
+	}
+	addEquals("purchase_order_id", filter.PurchaseOrderID)
+	addEquals("document_type", filter.DocumentType)
+	addEquals("status", filter.Status)
+
+	if len(filterExpressions) > 0 {
+		scanInput.FilterExpression = aws.String(strings.Join(filterExpressions, " AND "))
+		scanInput.ExpressionAttributeNames = expressionAttributeNames
+		scanInput.ExpressionAttributeValues = expressionAttributeValues
+	}
+
+	deliveries := make([]*models.ERPDelivery, 0)
+	for {
+		result, err := s.DynamoDB.ScanWithContext(ctx, scanInput)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan ERP deliveries: %w", err)
+		}
+
+		for _, item := range result.Items {
+			var delivery models.ERPDelivery
+			if err := dynamodbattribute.UnmarshalMap(item, &delivery); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal ERP delivery: %w", err)
+			}
+			deliveries = append(deliveries, &delivery)
+		}
+
+		if len(result.LastEvaluatedKey) == 0 {
+			break
+		}
+		scanInput.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+
+	sort.Slice(deliveries, func(i, j int) bool {
+		return deliveries[i].UpdatedAt.After(deliveries[j].UpdatedAt)
+	})
+	if filter.Limit > 0 && len(deliveries) > filter.Limit {
+		deliveries = deliveries[:filter.Limit]
+	}
+
+	return deliveries, nil
+}
@@ -0,0 +1,312 @@
+// Package eventbridge mirrors purchase order events from the event store to
+// an Amazon EventBridge bus, so AWS-native teams can subscribe to them with
+// EventBridge rules instead of consuming from RabbitMQ.
+package eventbridge
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/aws/aws-sdk-go/service/eventbridge"
+	"github.com/aws/aws-sdk-go/service/eventbridge/eventbridgeiface"
+
+	"orden-compra/internal/models"
+)
+
+// Source is the source of every mirrored event
+const Source = "medisupply.orden-compra"
+
+// Detail types of the mirrored events, named after the stored events
+const (
+	DetailTypePurchaseOrderCreated       = "PurchaseOrderCreated"
+	DetailTypePurchaseOrderStatusUpdated = "PurchaseOrderStatusUpdated"
+	DetailTypePurchaseOrderCompleted     = string(models.PurchaseOrderCompletedEventType)
+)
+
+const (
+	checkpointsTable = "orden-compra-checkpoints"
+	checkpointID     = "eventbridge-mirror"
+	// putEventsSize is the maximum number of entries in a PutEvents request
+	putEventsSize = 10
+)
+
+// Config represents the EventBridge mirror settings
+type Config struct {
+	// EventBus is the name or ARN of the bus events are put on
+	EventBus string
+	Interval time.Duration
+	// Lag keeps the mirror this far behind the present, so events stored
+	// late by other replicas are not skipped
+	Lag time.Duration
+}
+
+// Detail is the detail of a mirrored event
+type Detail struct {
+	EventID         string                 `json:"event_id"`
+	TenantID        string                 `json:"tenant_id,omitempty"`
+	PurchaseOrderID string                 `json:"purchase_order_id"`
+	Timestamp       time.Time              `json:"timestamp"`
+	CorrelationID   *string                `json:"correlation_id,omitempty"`
+	CausationID     *string                `json:"causation_id,omitempty"`
+	PurchaseOrder   map[string]interface{} `json:"purchase_order"`
+	// StatusChange is set on PurchaseOrderStatusUpdated and PurchaseOrderCompleted
+	StatusChange *StatusChange `json:"status_change,omitempty"`
+}
+
+// StatusChange is the status transition of a status update or completion
+type StatusChange struct {
+	OldStatus string `json:"old_status"`
+	NewStatus string `json:"new_status"`
+}
+
+// Mirror puts the purchase order events stored since its checkpoint on an
+// EventBridge bus. A nil mirror means mirroring is disabled.
+type Mirror struct {
+	DynamoDB    dynamodbiface.DynamoDBAPI
+	EventBridge eventbridgeiface.EventBridgeAPI
+	Config      Config
+	Logger      *log.Logger
+}
+
+// NewMirror creates a new EventBridge mirror
+func NewMirror(dynamoDB dynamodbiface.DynamoDBAPI, eventBridge eventbridgeiface.EventBridgeAPI, config Config, logger *log.Logger) *Mirror {
+	return &Mirror{
+		DynamoDB:    dynamoDB,
+		EventBridge: eventBridge,
+		Config:      config,
+		Logger:      logger,
+	}
+}
+
+// Start mirrors events every interval until the context is cancelled
+func (m *Mirror) Start(ctx context.Context) {
+	m.Logger.Printf("Starting EventBridge mirror - bus: %s, interval: %v", m.Config.EventBus, m.Config.Interval)
+
+	ticker := time.NewTicker(m.Config.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := m.RunOnce(ctx); err != nil {
+			m.Logger.Printf("EventBridge mirror failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			m.Logger.Println("EventBridge mirror stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce puts the events stored between the checkpoint and the lag on the
+// bus and moves the checkpoint forward. It returns the number of events put.
+// The first run only sets the checkpoint, so mirroring starts with the events
+// stored after it rather than replaying the whole event store.
+func (m *Mirror) RunOnce(ctx context.Context) (int, error) {
+	checkpoint, err := m.checkpoint(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	until := time.Now().UTC().Add(-m.Config.Lag)
+	if checkpoint.IsZero() {
+		return 0, m.saveCheckpoint(ctx, until)
+	}
+	if !until.After(checkpoint) {
+		return 0, nil
+	}
+
+	events, err := m.events(ctx, checkpoint, until)
+	if err != nil {
+		return 0, err
+	}
+
+	// A failed batch leaves the checkpoint in place, so the next run puts the
+	// window again and subscribers may see an event twice
+	for start := 0; start < len(events); start += putEventsSize {
+		end := min(start+putEventsSize, len(events))
+		if err := m.put(ctx, events[start:end]); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := m.saveCheckpoint(ctx, until); err != nil {
+		return 0, err
+	}
+
+	if len(events) > 0 {
+		m.Logger.Printf("Events mirrored to EventBridge - events: %d, checkpoint: %s", len(events), until.Format(time.RFC3339))
+	}
+	return len(events), nil
+}
+
+// events returns the purchase order events stored after since and up to
+// until, oldest first
+func (m *Mirror) events(ctx context.Context, since, until time.Time) ([]*models.EventSourcingEvent, error) {
+	scanInput := &dynamodb.ScanInput{
+		TableName:        aws.String("orden-compra-events"),
+		FilterExpression: aws.String("event_type IN (:created, :updated, :completed) AND #timestamp > :since AND #timestamp <= :until"),
+		ExpressionAttributeNames: map[string]*string{
+			"#timestamp": aws.String("timestamp"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":created":   {S: aws.String(DetailTypePurchaseOrderCreated)},
+			":updated":   {S: aws.String(DetailTypePurchaseOrderStatusUpdated)},
+			":completed": {S: aws.String(DetailTypePurchaseOrderCompleted)},
+			":since":     {S: aws.String(since.Format(time.RFC3339Nano))},
+			":until":     {S: aws.String(until.Format(time.RFC3339Nano))},
+		},
+	}
+
+	var events []*models.EventSourcingEvent
+	for {
+		result, err := m.DynamoDB.ScanWithContext(ctx, scanInput)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan events: %w", err)
+		}
+
+		for _, item := range result.Items {
+			var event models.EventSourcingEvent
+			if err := dynamodbattribute.UnmarshalMap(item, &event); err != nil {
+				m.Logger.Printf("Failed to unmarshal event, not mirrored: %v", err)
+				continue
+			}
+			if err := models.EventSchemas.Upcast(&event); err != nil {
+				m.Logger.Printf("Failed to upcast event, not mirrored: %v", err)
+				continue
+			}
+			events = append(events, &event)
+		}
+
+		if len(result.LastEvaluatedKey) == 0 {
+			break
+		}
+		scanInput.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Timestamp.Before(events[j].Timestamp)
+	})
+	return events, nil
+}
+
+// Project puts the mirrored events among those delivered by the event
+// stream consumer on the bus, in place of the scheduled mirror
+func (m *Mirror) Project(ctx context.Context, events []*models.EventSourcingEvent) error {
+	mirrored := make([]*models.EventSourcingEvent, 0, len(events))
+	for _, event := range events {
+		switch event.EventType {
+		case DetailTypePurchaseOrderCreated, DetailTypePurchaseOrderStatusUpdated, DetailTypePurchaseOrderCompleted:
+			mirrored = append(mirrored, event)
+		}
+	}
+
+	for start := 0; start < len(mirrored); start += putEventsSize {
+		end := min(start+putEventsSize, len(mirrored))
+		if err := m.put(ctx, mirrored[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// put puts a batch of events on the bus
+func (m *Mirror) put(ctx context.Context, events []*models.EventSourcingEvent) error {
+	entries := make([]*eventbridge.PutEventsRequestEntry, 0, len(events))
+	for _, event := range events {
+		detail, err := json.Marshal(NewDetail(event))
+		if err != nil {
+			return fmt.Errorf("failed to marshal detail of event %s: %w", event.ID, err)
+		}
+		entries = append(entries, &eventbridge.PutEventsRequestEntry{
+			EventBusName: aws.String(m.Config.EventBus),
+			Source:       aws.String(Source),
+			DetailType:   aws.String(event.EventType),
+			Detail:       aws.String(string(detail)),
+			Time:         aws.Time(event.Timestamp),
+		})
+	}
+
+	result, err := m.EventBridge.PutEventsWithContext(ctx, &eventbridge.PutEventsInput{Entries: entries})
+	if err != nil {
+		return fmt.Errorf("failed to put events: %w", err)
+	}
+	if failed := aws.Int64Value(result.FailedEntryCount); failed > 0 {
+		for i, entry := range result.Entries {
+			if entry.ErrorCode != nil {
+				return fmt.Errorf("failed to put %d of %d events, first event %s: %s: %s", failed, len(entries), events[i].ID, aws.StringValue(entry.ErrorCode), aws.StringValue(entry.ErrorMessage))
+			}
+		}
+		return fmt.Errorf("failed to put %d of %d events", failed, len(entries))
+	}
+	return nil
+}
+
+// NewDetail builds the detail of a stored purchase order event
+func NewDetail(event *models.EventSourcingEvent) *Detail {
+	detail := &Detail{
+		EventID:         event.ID,
+		TenantID:        event.TenantID,
+		PurchaseOrderID: event.AggregateID,
+		Timestamp:       event.Timestamp,
+		CorrelationID:   event.CorrelationID,
+		CausationID:     event.CausationID,
+	}
+	detail.PurchaseOrder, _ = event.EventData["purchase_order"].(map[string]interface{})
+
+	if change, ok := event.EventData["status_change"].(map[string]interface{}); ok {
+		detail.StatusChange = &StatusChange{}
+		detail.StatusChange.OldStatus, _ = change["old_status"].(string)
+		detail.StatusChange.NewStatus, _ = change["new_status"].(string)
+	}
+	return detail
+}
+
+// checkpoint returns the timestamp events were last mirrored up to, or the
+// zero time before the first run
+func (m *Mirror) checkpoint(ctx context.Context) (time.Time, error) {
+	result, err := m.DynamoDB.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(checkpointsTable),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {S: aws.String(checkpointID)},
+		},
+	})
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get eventbridge checkpoint: %w", err)
+	}
+	if result.Item == nil {
+		return time.Time{}, nil
+	}
+
+	var item struct {
+		Timestamp time.Time `dynamodbav:"timestamp"`
+	}
+	if err := dynamodbattribute.UnmarshalMap(result.Item, &item); err != nil {
+		return time.Time{}, fmt.Errorf("failed to unmarshal eventbridge checkpoint: %w", err)
+	}
+	return item.Timestamp, nil
+}
+
+// saveCheckpoint records the timestamp events were mirrored up to
+func (m *Mirror) saveCheckpoint(ctx context.Context, timestamp time.Time) error {
+	_, err := m.DynamoDB.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(checkpointsTable),
+		Item: map[string]*dynamodb.AttributeValue{
+			"id":        {S: aws.String(checkpointID)},
+			"timestamp": {S: aws.String(timestamp.Format(time.RFC3339Nano))},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save eventbridge checkpoint: %w", err)
+	}
+	return nil
+}
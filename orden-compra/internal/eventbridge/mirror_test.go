@@ -0,0 +1,172 @@
+package eventbridge
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/eventbridge"
+	"github.com/aws/aws-sdk-go/service/eventbridge/eventbridgeiface"
+
+	"orden-compra/internal/memory"
+	"orden-compra/internal/models"
+)
+
+// bus records the entries put on it; entries whose event ID is in reject fail
+type bus struct {
+	eventbridgeiface.EventBridgeAPI
+	requests [][]*eventbridge.PutEventsRequestEntry
+	reject   string
+}
+
+func (b *bus) PutEventsWithContext(ctx aws.Context, input *eventbridge.PutEventsInput, opts ...request.Option) (*eventbridge.PutEventsOutput, error) {
+	b.requests = append(b.requests, input.Entries)
+	output := &eventbridge.PutEventsOutput{FailedEntryCount: aws.Int64(0)}
+	for _, entry := range input.Entries {
+		result := &eventbridge.PutEventsResultEntry{EventId: aws.String("eb-1")}
+		if b.reject != "" && strings.Contains(aws.StringValue(entry.Detail), b.reject) {
+			result = &eventbridge.PutEventsResultEntry{ErrorCode: aws.String("InternalFailure"), ErrorMessage: aws.String("try again")}
+			*output.FailedEntryCount++
+		}
+		output.Entries = append(output.Entries, result)
+	}
+	return output, nil
+}
+
+// entries returns every entry put, in order
+func (b *bus) entries() []*eventbridge.PutEventsRequestEntry {
+	var entries []*eventbridge.PutEventsRequestEntry
+	for _, request := range b.requests {
+		entries = append(entries, request...)
+	}
+	return entries
+}
+
+func putEvent(t *testing.T, dynamoDB *memory.DynamoDB, event *models.EventSourcingEvent) {
+	t.Helper()
+	item, err := dynamodbattribute.MarshalMap(event)
+	if err != nil {
+		t.Fatalf("marshal event: %v", err)
+	}
+	if _, err := dynamoDB.PutItemWithContext(context.Background(), &dynamodb.PutItemInput{TableName: aws.String("orden-compra-events"), Item: item}); err != nil {
+		t.Fatalf("put event: %v", err)
+	}
+}
+
+func statusUpdated(aggregateID, oldStatus, newStatus string, at time.Time) *models.EventSourcingEvent {
+	return models.NewEventSourcingEvent(aggregateID, DetailTypePurchaseOrderStatusUpdated, map[string]interface{}{
+		"purchase_order": map[string]interface{}{"id": aggregateID, "status": newStatus},
+		"status_change":  map[string]interface{}{"old_status": oldStatus, "new_status": newStatus},
+	}, nil, nil, at)
+}
+
+func newTestMirror() (*Mirror, *memory.DynamoDB, *bus) {
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	eventBus := &bus{}
+	return NewMirror(dynamoDB, eventBus, Config{EventBus: "medisupply", Lag: time.Second}, log.New(io.Discard, "", 0)), dynamoDB, eventBus
+}
+
+func TestMirrorPutsEventsSinceTheCheckpoint(t *testing.T) {
+	ctx := context.Background()
+	mirror, dynamoDB, eventBus := newTestMirror()
+
+	// The first run starts mirroring from now rather than replaying history
+	putEvent(t, dynamoDB, statusUpdated("po-old", models.StatusPending, models.StatusSent, time.Now().UTC().Add(-time.Hour)))
+	if put, err := mirror.RunOnce(ctx); err != nil || put != 0 || len(eventBus.requests) != 0 {
+		t.Fatalf("first run put %d events, error %v", put, err)
+	}
+	checkpoint, err := mirror.checkpoint(ctx)
+	if err != nil || checkpoint.IsZero() {
+		t.Fatalf("checkpoint %v, error %v", checkpoint, err)
+	}
+
+	// Twelve mirrored events take two requests; other types are not mirrored
+	for i := 0; i < 12; i++ {
+		putEvent(t, dynamoDB, statusUpdated(fmt.Sprintf("po-%02d", i), models.StatusPending, models.StatusSent, checkpoint.Add(time.Duration(i+1)*time.Millisecond)))
+	}
+	putEvent(t, dynamoDB, models.NewEventSourcingEvent("po-00", "PurchaseOrderApproved", nil, nil, nil, checkpoint.Add(time.Millisecond)))
+	// The events were stored less than the lag ago
+	mirror.Config.Lag = 0
+
+	put, err := mirror.RunOnce(ctx)
+	if err != nil || put != 12 || len(eventBus.requests) != 2 || len(eventBus.requests[0]) != putEventsSize {
+		t.Fatalf("put %d events in %d requests, error %v", put, len(eventBus.requests), err)
+	}
+	entry := eventBus.entries()[0]
+	if aws.StringValue(entry.EventBusName) != "medisupply" || aws.StringValue(entry.Source) != Source || aws.StringValue(entry.DetailType) != DetailTypePurchaseOrderStatusUpdated {
+		t.Fatalf("entry %v", entry)
+	}
+	var detail Detail
+	if err := json.Unmarshal([]byte(aws.StringValue(entry.Detail)), &detail); err != nil || detail.PurchaseOrderID != "po-00" || detail.StatusChange == nil || detail.StatusChange.NewStatus != models.StatusSent {
+		t.Fatalf("detail %s, error %v", aws.StringValue(entry.Detail), err)
+	}
+
+	if put, err := mirror.RunOnce(ctx); err != nil || put != 0 {
+		t.Fatalf("run without new events put %d, error %v", put, err)
+	}
+}
+
+func TestMirrorKeepsTheCheckpointWhenAPutFails(t *testing.T) {
+	ctx := context.Background()
+	mirror, dynamoDB, eventBus := newTestMirror()
+	start := time.Now().UTC().Add(-time.Minute)
+	if err := mirror.saveCheckpoint(ctx, start); err != nil {
+		t.Fatalf("save checkpoint: %v", err)
+	}
+	putEvent(t, dynamoDB, statusUpdated("po-1", models.StatusPending, models.StatusSent, start.Add(time.Second)))
+
+	eventBus.reject = "po-1"
+	if _, err := mirror.RunOnce(ctx); err == nil || !strings.Contains(err.Error(), "InternalFailure") {
+		t.Fatalf("RunOnce error %v, want the failed entry", err)
+	}
+	if checkpoint, _ := mirror.checkpoint(ctx); !checkpoint.Equal(start) {
+		t.Fatalf("checkpoint moved to %v after a failed put", checkpoint)
+	}
+
+	// The next run puts the event again
+	eventBus.reject = ""
+	if put, err := mirror.RunOnce(ctx); err != nil || put != 1 {
+		t.Fatalf("retry put %d events, error %v", put, err)
+	}
+}
+
+func TestProjectPutsOnlyMirroredEvents(t *testing.T) {
+	mirror, _, eventBus := newTestMirror()
+	events := []*models.EventSourcingEvent{
+		models.NewEventSourcingEvent("po-1", DetailTypePurchaseOrderCreated, map[string]interface{}{"purchase_order": map[string]interface{}{"id": "po-1"}}, nil, nil, time.Now()),
+		models.NewEventSourcingEvent("po-1", "PurchaseOrderApproved", nil, nil, nil, time.Now()),
+		statusUpdated("po-1", models.StatusSent, models.StatusCompleted, time.Now()),
+	}
+	if err := mirror.Project(context.Background(), events); err != nil {
+		t.Fatalf("Project: %v", err)
+	}
+	entries := eventBus.entries()
+	if len(entries) != 2 || aws.StringValue(entries[0].DetailType) != DetailTypePurchaseOrderCreated {
+		t.Fatalf("put %v, want the creation and the status update", entries)
+	}
+}
+
+func TestNewDetail(t *testing.T) {
+	correlationID := "correlation-1"
+	event := models.NewEventSourcingEvent("po-1", DetailTypePurchaseOrderCreated, map[string]interface{}{
+		"purchase_order": map[string]interface{}{"id": "po-1", "status": models.StatusPending},
+	}, &correlationID, nil, time.Now())
+	event.TenantID = "tenant-1"
+
+	detail := NewDetail(event)
+	if detail.EventID != event.ID || detail.TenantID != "tenant-1" || detail.PurchaseOrderID != "po-1" || *detail.CorrelationID != correlationID {
+		t.Fatalf("detail %+v", detail)
+	}
+	if detail.PurchaseOrder["status"] != models.StatusPending || detail.StatusChange != nil {
+		t.Fatalf("detail order %v with status change %v", detail.PurchaseOrder, detail.StatusChange)
+	}
+}
@@ -0,0 +1,74 @@
+package flow
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"medisupply/correlation"
+)
+
+// ProveedorClient reads the event log Proveedor keeps per purchase order. A
+// nil client means Proveedor is not traced.
+type ProveedorClient struct {
+	URL  string
+	HTTP *http.Client
+}
+
+// NewProveedorClient creates a client, or returns nil when no URL is configured
+func NewProveedorClient(baseURL string, timeout time.Duration) *ProveedorClient {
+	if baseURL == "" {
+		return nil
+	}
+	return &ProveedorClient{
+		URL:  strings.TrimRight(baseURL, "/"),
+		HTTP: &http.Client{Timeout: timeout},
+	}
+}
+
+// proveedorEvent is an event of Proveedor's log as its API returns it
+type proveedorEvent struct {
+	ID            string                 `json:"id"`
+	AggregateID   string                 `json:"aggregate_id"`
+	EventType     string                 `json:"event_type"`
+	EventData     map[string]interface{} `json:"event_data"`
+	Timestamp     time.Time              `json:"timestamp"`
+	CorrelationID *string                `json:"correlation_id"`
+	CausationID   *string                `json:"causation_id"`
+}
+
+// Events returns the events Proveedor consumed and produced for a purchase order
+func (c *ProveedorClient) Events(ctx context.Context, purchaseOrderID string) ([]proveedorEvent, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, c.URL+"/purchase-orders/"+url.PathEscape(purchaseOrderID)+"/events", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create proveedor request: %w", err)
+	}
+	if ids, ok := correlation.FromContext(ctx); ok {
+		request.Header.Set(correlation.RequestIDHeader, ids.RequestID)
+		request.Header.Set(correlation.CorrelationIDHeader, ids.CorrelationID)
+	}
+
+	response, err := c.HTTP.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get proveedor events: %w", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+		return nil, fmt.Errorf("failed to get proveedor events of purchase order %s: status %d: %s", purchaseOrderID, response.StatusCode, body)
+	}
+
+	var result struct {
+		Events []proveedorEvent `json:"events"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode proveedor events: %w", err)
+	}
+	return result.Events, nil
+}
@@ -0,0 +1,364 @@
+package flow
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+
+	"orden-compra/internal/audit"
+	"orden-compra/internal/models"
+)
+
+// Sources of order timeline entries
+const (
+	SourceEvent = "event"
+	SourceAudit = "audit"
+)
+
+// OrderTimeline is the history of one purchase order for support and
+// compliance reviews, oldest entry first
+type OrderTimeline struct {
+	PurchaseOrderID string  `json:"purchase_order_id"`
+	Timeline        []Entry `json:"timeline"`
+	// Unavailable lists the sources that could not be read, so the timeline
+	// may be missing entries
+	Unavailable []string `json:"unavailable,omitempty"`
+}
+
+// timelineEvent holds an orden-compra event of the order with its data, which
+// the summaries are written from
+type timelineEvent struct {
+	ID            string                 `dynamodbav:"id"`
+	EventType     string                 `dynamodbav:"event_type"`
+	Timestamp     time.Time              `dynamodbav:"timestamp"`
+	CorrelationID *string                `dynamodbav:"correlation_id"`
+	CausationID   *string                `dynamodbav:"causation_id"`
+	EventData     map[string]interface{} `dynamodbav:"event_data"`
+}
+
+// Timeline merges the orden-compra events of a purchase order, the events
+// Proveedor logged for its reception and the audit entries of the order into
+// one timeline, each entry with a summary. Proveedor and the audit log only
+// add to the order's own events: when either cannot be read the timeline is
+// returned without it and it is listed in Unavailable.
+func (t *Tracer) Timeline(ctx context.Context, purchaseOrderID string) (*OrderTimeline, error) {
+	events, err := t.purchaseOrderEvents(ctx, purchaseOrderID)
+	if err != nil {
+		return nil, err
+	}
+
+	timeline := &OrderTimeline{
+		PurchaseOrderID: purchaseOrderID,
+		Timeline:        make([]Entry, 0, len(events)),
+	}
+
+	status := ""
+	for _, event := range events {
+		entry := Entry{
+			Service:         ServiceOrdenCompra,
+			Source:          SourceEvent,
+			EventID:         event.ID,
+			EventType:       event.EventType,
+			PurchaseOrderID: purchaseOrderID,
+			Timestamp:       event.Timestamp,
+			CorrelationID:   event.CorrelationID,
+			CausationID:     event.CausationID,
+			Status:          dataString(event.EventData, "purchase_order", "status"),
+		}
+		entry.Summary = orderEventSummary(event, status)
+		if entry.Status != "" {
+			status = entry.Status
+		}
+		timeline.Timeline = append(timeline.Timeline, entry)
+	}
+
+	if t.Proveedor != nil {
+		proveedorEvents, err := t.Proveedor.Events(ctx, purchaseOrderID)
+		if err != nil {
+			t.Logger.Printf("Failed to read proveedor events of purchase order %s: %v", purchaseOrderID, err)
+			timeline.Unavailable = append(timeline.Unavailable, ServiceProveedor)
+		}
+		for _, event := range proveedorEvents {
+			timeline.Timeline = append(timeline.Timeline, Entry{
+				Service:         ServiceProveedor,
+				Source:          SourceEvent,
+				EventID:         event.ID,
+				EventType:       event.EventType,
+				PurchaseOrderID: purchaseOrderID,
+				Timestamp:       event.Timestamp,
+				CorrelationID:   event.CorrelationID,
+				CausationID:     event.CausationID,
+				Data:            event.EventData,
+				Summary:         proveedorEventSummary(event.EventType),
+			})
+		}
+	} else {
+		timeline.Unavailable = append(timeline.Unavailable, ServiceProveedor)
+	}
+
+	auditEntries := 0
+	if t.Audit != nil {
+		entries, err := t.Audit.Query(ctx, audit.Filter{ResourceType: models.AuditResourcePurchaseOrder, ResourceID: purchaseOrderID})
+		if err != nil {
+			t.Logger.Printf("Failed to read audit entries of purchase order %s: %v", purchaseOrderID, err)
+			timeline.Unavailable = append(timeline.Unavailable, SourceAudit)
+		}
+		for _, entry := range entries {
+			timelineEntry := Entry{
+				Service:         ServiceOrdenCompra,
+				Source:          SourceAudit,
+				EventID:         entry.ID,
+				EventType:       entry.Action,
+				PurchaseOrderID: purchaseOrderID,
+				Timestamp:       entry.Timestamp,
+				Actor:           strings.TrimSpace(entry.ActorType + " " + entry.ActorID),
+				Outcome:         entry.Outcome,
+				Summary:         auditEntrySummary(entry),
+			}
+			if entry.CorrelationID != "" {
+				timelineEntry.CorrelationID = aws.String(entry.CorrelationID)
+			}
+			timeline.Timeline = append(timeline.Timeline, timelineEntry)
+		}
+		auditEntries = len(entries)
+	}
+
+	if len(events) == 0 && auditEntries == 0 {
+		return nil, fmt.Errorf("%w: no event or audit entry of purchase order %s", ErrFlowNotFound, purchaseOrderID)
+	}
+
+	sort.SliceStable(timeline.Timeline, func(i, j int) bool {
+		return timeline.Timeline[i].Timestamp.Before(timeline.Timeline[j].Timestamp)
+	})
+	return timeline, nil
+}
+
+// purchaseOrderEvents returns the orden-compra events of a purchase order,
+// oldest first
+func (t *Tracer) purchaseOrderEvents(ctx context.Context, purchaseOrderID string) ([]timelineEvent, error) {
+	scanInput := &dynamodb.ScanInput{
+		TableName:        aws.String("orden-compra-events"),
+		FilterExpression: aws.String("aggregate_id = :aggregate_id"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":aggregate_id": {S: aws.String(purchaseOrderID)},
+		},
+	}
+
+	var events []timelineEvent
+	for {
+		result, err := t.DynamoDB.ScanWithContext(ctx, scanInput)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan events: %w", err)
+		}
+
+		for _, item := range result.Items {
+			var event timelineEvent
+			if err := dynamodbattribute.UnmarshalMap(item, &event); err != nil {
+				t.Logger.Printf("Failed to unmarshal event: %v", err)
+				continue
+			}
+			events = append(events, event)
+		}
+
+		if len(result.LastEvaluatedKey) == 0 {
+			break
+		}
+		scanInput.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Timestamp.Before(events[j].Timestamp)
+	})
+	return events, nil
+}
+
+// orderEventSummary describes an orden-compra event; previousStatus is the
+// status of the order before it, or "" for the first event
+func orderEventSummary(event timelineEvent, previousStatus string) string {
+	data := event.EventData
+	status := dataString(data, "purchase_order", "status")
+
+	switch event.EventType {
+	case "PurchaseOrderCreated":
+		summary := fmt.Sprintf("Purchase order created for %s units of %s from %s",
+			dataNumber(data, "purchase_order", "quantity"), dataString(data, "purchase_order", "product_id"), supplierOf(data))
+		if urgency := dataString(data, "stock_low_event", "urgency_level"); urgency != "" {
+			summary += fmt.Sprintf(" after a %s StockBajo event", urgency)
+		}
+		return summary + withStatus(status)
+	case "PurchaseOrderToppedUp":
+		return fmt.Sprintf("Quantity raised to %s units by another StockBajo event", dataNumber(data, "purchase_order", "quantity"))
+	case "PurchaseOrderStatusRejected":
+		return fmt.Sprintf("Status change from %s to %s refused: %s",
+			dataString(data, "current_status"), dataString(data, "requested_status"), dataString(data, "reason"))
+	case "PurchaseOrderApproved":
+		return "Approved" + byActor(data, "decided_by") + withComment(data)
+	case "PurchaseOrderRejected":
+		return "Rejected" + byActor(data, "decided_by") + withComment(data)
+	case string(models.PurchaseOrderCancelledEventType):
+		summary := "Cancelled"
+		if reason := dataString(data, "reason"); reason != "" {
+			summary += ": " + reason
+		}
+		return summary
+	case models.PurchaseOrderAcknowledgedEventType:
+		return fmt.Sprintf("Supplier acknowledgement received (%s)%s", dataString(data, "acknowledgement", "status"), withStatus(status))
+	case models.PurchaseOrderShipmentNotifiedEventType:
+		summary := "Advance shipment notice received"
+		if carrier, tracking := dataString(data, "asn", "carrier"), dataString(data, "asn", "tracking_number"); carrier != "" || tracking != "" {
+			summary += fmt.Sprintf(" (carrier %s, tracking %s)", carrier, tracking)
+		}
+		return summary
+	case models.PurchaseOrderOverdueEventType:
+		return fmt.Sprintf("Reported overdue, expected by %s", dataString(data, "expected_date"))
+	case models.PurchaseOrderDispatchedEventType:
+		return "Sent to the supplier"
+	case models.PurchaseOrderDispatchFailedEventType:
+		return "Sending to the supplier failed"
+	case string(models.PurchaseOrderPartiallyReceivedEventType):
+		return fmt.Sprintf("Partial delivery: %s of %s units received so far%s",
+			dataNumber(data, "receipt", "received_quantity"), dataNumber(data, "purchase_order", "quantity"), withStatus(status))
+	case string(models.PurchaseOrderCompletedEventType):
+		return fmt.Sprintf("Inventory received: %s of %s units%s",
+			dataNumber(data, "receipt", "received_quantity"), dataNumber(data, "purchase_order", "quantity"), withStatus(status))
+	case models.PurchaseOrderCommentAddedEventType:
+		return "Comment added" + byActor(data, "comment", "author") + ": " + dataString(data, "comment", "text")
+	case models.PurchaseOrderAttachmentAddedEventType:
+		return fmt.Sprintf("File %s attached%s", dataString(data, "attachment", "file_name"), byActor(data, "attachment", "uploaded_by"))
+	case models.PurchaseOrderInvoicedEventType:
+		return fmt.Sprintf("Invoice %s received for %s, match %s",
+			dataString(data, "invoice", "invoice_number"), dataNumber(data, "invoice", "total_amount"), dataString(data, "invoice_match", "status"))
+	case string(models.InvoiceMismatchDetectedEventType):
+		discrepancies, _ := dataValue(data, "invoice_match", "discrepancies").([]interface{})
+		return fmt.Sprintf("Invoices do not match the order: %d discrepancies", len(discrepancies))
+	case models.PurchaseOrderSupplierUpdatedEventType:
+		return fmt.Sprintf("Supplier renamed from %s to %s", dataString(data, "supplier_change", "old_name"), dataString(data, "supplier_change", "new_name"))
+	}
+
+	if status != "" && previousStatus != "" && status != previousStatus {
+		return fmt.Sprintf("Status changed from %s to %s", previousStatus, status)
+	}
+	return humanize(event.EventType) + withStatus(status)
+}
+
+// proveedorEventSummary describes an event of Proveedor's log
+func proveedorEventSummary(eventType string) string {
+	switch eventType {
+	case string(models.PurchaseOrderEventType):
+		return "Proveedor received the purchase order for reception"
+	case string(models.SupplierEventType):
+		return "Proveedor recorded the inventory received"
+	case string(models.AdvanceShipmentNoticeEventType):
+		return "Proveedor recorded the supplier's advance shipment notice"
+	case "QualityCheckFailed":
+		return "Proveedor reported a failed quality check"
+	case "QuantityDiscrepancy":
+		return "Proveedor reported a quantity discrepancy"
+	case "DevolucionProveedor":
+		return "Proveedor returned goods to the supplier"
+	case "DamageReported":
+		return "Proveedor reported damaged goods"
+	}
+	return "Proveedor recorded " + humanize(eventType)
+}
+
+// auditEntrySummary describes an audit entry, such as "Purchase order
+// cancelled by user jdoe"
+func auditEntrySummary(entry *models.AuditEntry) string {
+	action := entry.Action
+	if action == "" {
+		action = "audited operation"
+	}
+	if i := strings.Index(action, "."); i >= 0 {
+		action = strings.ReplaceAll(action[:i], "_", " ") + " " + strings.ReplaceAll(action[i+1:], "_", " ")
+	}
+	summary := strings.ToUpper(action[:1]) + action[1:]
+	if actor := strings.TrimSpace(entry.ActorType + " " + entry.ActorID); actor != "" {
+		summary += " by " + actor
+	}
+	if entry.Outcome == models.AuditOutcomeFailure {
+		summary += " failed"
+		if entry.Error != "" {
+			summary += ": " + entry.Error
+		}
+	}
+	return summary
+}
+
+// humanize turns an event type such as PurchaseOrderToppedUp into "purchase
+// order topped up"
+func humanize(eventType string) string {
+	var words strings.Builder
+	for i, r := range eventType {
+		if i > 0 && r >= 'A' && r <= 'Z' {
+			words.WriteByte(' ')
+		}
+		words.WriteRune(r)
+	}
+	return strings.ToLower(words.String())
+}
+
+// dataValue returns the value at a path of nested event data, or nil
+func dataValue(data map[string]interface{}, path ...string) interface{} {
+	var value interface{} = data
+	for _, key := range path {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = object[key]
+	}
+	return value
+}
+
+// dataString returns the string at a path of nested event data, or ""
+func dataString(data map[string]interface{}, path ...string) string {
+	value, _ := dataValue(data, path...).(string)
+	return value
+}
+
+// dataNumber formats the number at a path of nested event data, or "?"
+func dataNumber(data map[string]interface{}, path ...string) string {
+	if value, ok := dataValue(data, path...).(float64); ok {
+		return fmt.Sprintf("%g", value)
+	}
+	return "?"
+}
+
+// supplierOf names the supplier of the order an event snapshots
+func supplierOf(data map[string]interface{}) string {
+	if name := dataString(data, "purchase_order", "supplier_name"); name != "" {
+		return name
+	}
+	return dataString(data, "purchase_order", "supplier_id")
+}
+
+// withStatus appends the status an event left the order in
+func withStatus(status string) string {
+	if status == "" {
+		return ""
+	}
+	return ", status " + status
+}
+
+// byActor appends who made a decision or change, when the event records it
+func byActor(data map[string]interface{}, path ...string) string {
+	if actor := dataString(data, path...); actor != "" {
+		return " by " + actor
+	}
+	return ""
+}
+
+// withComment appends the comment of a decision
+func withComment(data map[string]interface{}) string {
+	if comment := dataString(data, "comment"); comment != "" {
+		return ": " + comment
+	}
+	return ""
+}
@@ -0,0 +1,115 @@
+package flow
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"testing"
+	"time"
+
+	"orden-compra/internal/audit"
+	"orden-compra/internal/memory"
+	"orden-compra/internal/models"
+)
+
+func TestTimelineMergesEventsReceptionsAndAuditEntries(t *testing.T) {
+	ctx := context.Background()
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	chain, request := "chain-1", "request-2"
+	putOrderEvent(t, dynamoDB, "po-1", "PurchaseOrderCreated", models.StatusPending, &chain, flowStart)
+	putOrderEvent(t, dynamoDB, "po-1", "PurchaseOrderStatusUpdated", models.StatusSent, &request, flowStart.Add(time.Hour))
+	putOrderEvent(t, dynamoDB, "po-2", "PurchaseOrderCreated", models.StatusPending, &chain, flowStart)
+
+	auditStore := audit.NewStore(dynamoDB)
+	cancelled := models.NewAuditEntry(models.AuditPurchaseOrderCancelled, models.AuditResourcePurchaseOrder, "po-1", flowStart.Add(4*time.Hour))
+	cancelled.ActorType, cancelled.ActorID, cancelled.CorrelationID = "user", "jdoe", "request-3"
+	cancelled.Outcome, cancelled.Error = models.AuditOutcomeFailure, "already received"
+	if err := auditStore.Append(ctx, cancelled); err != nil {
+		t.Fatalf("append audit entry: %v", err)
+	}
+
+	proveedor, _ := startProveedor(t, http.StatusOK)
+	timeline, err := NewTracer(dynamoDB, proveedor, auditStore, log.New(io.Discard, "", 0)).Timeline(ctx, "po-1")
+	if err != nil {
+		t.Fatalf("Timeline: %v", err)
+	}
+	if timeline.PurchaseOrderID != "po-1" || len(timeline.Unavailable) != 0 {
+		t.Fatalf("timeline of %s, unavailable %v", timeline.PurchaseOrderID, timeline.Unavailable)
+	}
+
+	want := []struct {
+		service, source, eventType, summary string
+	}{
+		{ServiceOrdenCompra, SourceEvent, "PurchaseOrderCreated", ""},
+		{ServiceOrdenCompra, SourceEvent, "PurchaseOrderStatusUpdated", "Status changed from pending to sent"},
+		{ServiceProveedor, SourceEvent, "InventarioRecibido", "Proveedor recorded the inventory received"},
+		{ServiceOrdenCompra, SourceAudit, models.AuditPurchaseOrderCancelled, "Purchase order cancelled by user jdoe failed: already received"},
+	}
+	if len(timeline.Timeline) != len(want) {
+		t.Fatalf("timeline %+v, want %d entries", timeline.Timeline, len(want))
+	}
+	for i, entry := range timeline.Timeline {
+		w := want[i]
+		if entry.Service != w.service || entry.Source != w.source || entry.EventType != w.eventType || (w.summary != "" && entry.Summary != w.summary) {
+			t.Fatalf("entry %d is %s %s %s %q, want %s %s %s %q", i, entry.Service, entry.Source, entry.EventType, entry.Summary, w.service, w.source, w.eventType, w.summary)
+		}
+	}
+	if created := timeline.Timeline[0]; created.Summary == "" || created.Status != models.StatusPending {
+		t.Fatalf("created entry %+v", created)
+	}
+	if last := timeline.Timeline[3]; last.Actor != "user jdoe" || last.Outcome != models.AuditOutcomeFailure || last.CorrelationID == nil || *last.CorrelationID != "request-3" {
+		t.Fatalf("audit entry %+v", last)
+	}
+}
+
+func TestTimelineWithoutSources(t *testing.T) {
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	chain := "chain-1"
+	putOrderEvent(t, dynamoDB, "po-1", "PurchaseOrderCreated", models.StatusPending, &chain, flowStart)
+	failing, _ := startProveedor(t, http.StatusBadGateway)
+
+	// The order's own events are returned without Proveedor and the audit log
+	timeline, err := NewTracer(dynamoDB, failing, nil, log.New(io.Discard, "", 0)).Timeline(context.Background(), "po-1")
+	if err != nil || len(timeline.Timeline) != 1 || len(timeline.Unavailable) != 1 || timeline.Unavailable[0] != ServiceProveedor {
+		t.Fatalf("timeline %+v, error %v, want the order's events only", timeline, err)
+	}
+
+	tracer := NewTracer(dynamoDB, nil, audit.NewStore(dynamoDB), log.New(io.Discard, "", 0))
+	if _, err := tracer.Timeline(context.Background(), "po-9"); !errors.Is(err, ErrFlowNotFound) {
+		t.Fatalf("unknown order returned %v", err)
+	}
+}
+
+func TestHumanize(t *testing.T) {
+	for eventType, want := range map[string]string{
+		"PurchaseOrderToppedUp": "purchase order topped up",
+		"InventarioRecibido":    "inventario recibido",
+		"":                      "",
+	} {
+		if got := humanize(eventType); got != want {
+			t.Errorf("humanize(%q) = %q, want %q", eventType, got, want)
+		}
+	}
+}
+
+func TestAnnotationSummaries(t *testing.T) {
+	for _, tc := range []struct {
+		event timelineEvent
+		want  string
+	}{
+		{
+			timelineEvent{EventType: models.PurchaseOrderCommentAddedEventType, EventData: map[string]interface{}{"comment": map[string]interface{}{"text": "Call first", "author": "jdoe"}}},
+			"Comment added by jdoe: Call first",
+		},
+		{
+			timelineEvent{EventType: models.PurchaseOrderAttachmentAddedEventType, EventData: map[string]interface{}{"attachment": map[string]interface{}{"file_name": "quote.pdf"}}},
+			"File quote.pdf attached",
+		},
+	} {
+		if got := orderEventSummary(tc.event, models.StatusPending); got != tc.want {
+			t.Errorf("summary %q, want %q", got, tc.want)
+		}
+	}
+}
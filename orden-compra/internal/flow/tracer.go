@@ -0,0 +1,240 @@
+// Package flow traces the replenishment flow of a correlation chain across
+// services: the StockBajo event, the purchase orders orden-compra created
+// for it, and the receptions and inventory Proveedor recorded for them.
+package flow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+
+	"orden-compra/internal/audit"
+)
+
+// ErrFlowNotFound is returned when no stored event belongs to a correlation chain
+var ErrFlowNotFound = errors.New("flow not found")
+
+// Services appearing in a timeline
+const (
+	ServiceMovimientoInventario = "movimiento-inventario"
+	ServiceOrdenCompra          = "orden-compra"
+	ServiceProveedor            = "proveedor"
+)
+
+// stockLowEventType is the type of the event that starts the flow
+const stockLowEventType = "StockBajo"
+
+// Entry is one event of a flow's timeline. In order timelines Source tells
+// events from audit entries.
+type Entry struct {
+	Service         string    `json:"service"`
+	Source          string    `json:"source,omitempty"`
+	EventID         string    `json:"event_id"`
+	EventType       string    `json:"event_type"`
+	PurchaseOrderID string    `json:"purchase_order_id,omitempty"`
+	Timestamp       time.Time `json:"timestamp"`
+	CorrelationID   *string   `json:"correlation_id,omitempty"`
+	CausationID     *string   `json:"causation_id,omitempty"`
+	// Status is the purchase order status after an orden-compra event
+	Status string `json:"status,omitempty"`
+	// Data holds the details Proveedor logged with its events
+	Data map[string]interface{} `json:"data,omitempty"`
+	// Inferred entries are not stored by any service; they are placed right
+	// before the first event that referenced them
+	Inferred bool `json:"inferred,omitempty"`
+	// Summary describes the entry in plain words, in order timelines
+	Summary string `json:"summary,omitempty"`
+	// Actor and Outcome are set on audit entries of order timelines, whose
+	// EventType is the audited action
+	Actor   string `json:"actor,omitempty"`
+	Outcome string `json:"outcome,omitempty"`
+}
+
+// Trace is the timeline of a correlation chain, oldest event first
+type Trace struct {
+	CorrelationID    string   `json:"correlation_id"`
+	PurchaseOrderIDs []string `json:"purchase_order_ids"`
+	Timeline         []Entry  `json:"timeline"`
+	// Unavailable lists the services whose events could not be read, so the
+	// timeline may be missing steps
+	Unavailable []string `json:"unavailable,omitempty"`
+}
+
+// orderEvent holds the attributes of an orden-compra event the trace reads
+type orderEvent struct {
+	ID            string    `dynamodbav:"id"`
+	AggregateID   string    `dynamodbav:"aggregate_id"`
+	EventType     string    `dynamodbav:"event_type"`
+	Timestamp     time.Time `dynamodbav:"timestamp"`
+	CorrelationID *string   `dynamodbav:"correlation_id"`
+	CausationID   *string   `dynamodbav:"causation_id"`
+	EventData     struct {
+		PurchaseOrder struct {
+			Status   string `dynamodbav:"status"`
+			Metadata struct {
+				StockLowEventID string `dynamodbav:"stock_low_event_id"`
+			} `dynamodbav:"metadata"`
+		} `dynamodbav:"purchase_order"`
+	} `dynamodbav:"event_data"`
+}
+
+// Tracer assembles flow timelines from orden-compra's event store and
+// Proveedor's event log, and order timelines also from the audit log
+type Tracer struct {
+	DynamoDB  dynamodbiface.DynamoDBAPI
+	Proveedor *ProveedorClient
+	Audit     *audit.Store
+	Logger    *log.Logger
+}
+
+// NewTracer creates a tracer; proveedor is nil when Proveedor is not traced
+func NewTracer(dynamoDB dynamodbiface.DynamoDBAPI, proveedor *ProveedorClient, auditStore *audit.Store, logger *log.Logger) *Tracer {
+	return &Tracer{
+		DynamoDB:  dynamoDB,
+		Proveedor: proveedor,
+		Audit:     auditStore,
+		Logger:    logger,
+	}
+}
+
+// Trace returns the timeline of a correlation chain. Orden-compra events
+// belong to the chain when they carry its correlation ID or snapshot an order
+// created in it, which covers later commands with their own correlation.
+// Proveedor's events are read for every order found; when Proveedor cannot
+// be reached the timeline is returned without them.
+func (t *Tracer) Trace(ctx context.Context, correlationID string) (*Trace, error) {
+	events, err := t.orderEvents(ctx, correlationID)
+	if err != nil {
+		return nil, err
+	}
+	if len(events) == 0 {
+		return nil, ErrFlowNotFound
+	}
+
+	trace := &Trace{
+		CorrelationID:    correlationID,
+		PurchaseOrderIDs: make([]string, 0),
+		Timeline:         make([]Entry, 0, len(events)),
+	}
+
+	purchaseOrders := make(map[string]bool)
+	stockLowEvents := make(map[string]bool)
+	for _, event := range events {
+		trace.Timeline = append(trace.Timeline, Entry{
+			Service:         ServiceOrdenCompra,
+			EventID:         event.ID,
+			EventType:       event.EventType,
+			PurchaseOrderID: event.AggregateID,
+			Timestamp:       event.Timestamp,
+			CorrelationID:   event.CorrelationID,
+			CausationID:     event.CausationID,
+			Status:          event.EventData.PurchaseOrder.Status,
+		})
+
+		if !purchaseOrders[event.AggregateID] {
+			purchaseOrders[event.AggregateID] = true
+			trace.PurchaseOrderIDs = append(trace.PurchaseOrderIDs, event.AggregateID)
+		}
+
+		if stockLowEventID := event.EventData.PurchaseOrder.Metadata.StockLowEventID; stockLowEventID != "" && !stockLowEvents[stockLowEventID] {
+			stockLowEvents[stockLowEventID] = true
+			trace.Timeline = append(trace.Timeline, Entry{
+				Service:         ServiceMovimientoInventario,
+				EventID:         stockLowEventID,
+				EventType:       stockLowEventType,
+				PurchaseOrderID: event.AggregateID,
+				Timestamp:       event.Timestamp,
+				CorrelationID:   aws.String(correlationID),
+				Inferred:        true,
+			})
+		}
+	}
+	sort.Strings(trace.PurchaseOrderIDs)
+
+	if t.Proveedor != nil {
+		for _, purchaseOrderID := range trace.PurchaseOrderIDs {
+			proveedorEvents, err := t.Proveedor.Events(ctx, purchaseOrderID)
+			if err != nil {
+				t.Logger.Printf("Failed to trace proveedor events of purchase order %s: %v", purchaseOrderID, err)
+				trace.Unavailable = []string{ServiceProveedor}
+				break
+			}
+
+			for _, event := range proveedorEvents {
+				trace.Timeline = append(trace.Timeline, Entry{
+					Service:         ServiceProveedor,
+					EventID:         event.ID,
+					EventType:       event.EventType,
+					PurchaseOrderID: event.AggregateID,
+					Timestamp:       event.Timestamp,
+					CorrelationID:   event.CorrelationID,
+					CausationID:     event.CausationID,
+					Data:            event.EventData,
+				})
+			}
+		}
+	} else {
+		trace.Unavailable = []string{ServiceProveedor}
+	}
+
+	sort.SliceStable(trace.Timeline, func(i, j int) bool {
+		a, b := trace.Timeline[i], trace.Timeline[j]
+		if !a.Timestamp.Equal(b.Timestamp) {
+			return a.Timestamp.Before(b.Timestamp)
+		}
+		return a.Inferred && !b.Inferred
+	})
+
+	return trace, nil
+}
+
+// orderEvents returns the orden-compra events of a correlation chain
+func (t *Tracer) orderEvents(ctx context.Context, correlationID string) ([]orderEvent, error) {
+	scanInput := &dynamodb.ScanInput{
+		TableName:            aws.String("orden-compra-events"),
+		FilterExpression:     aws.String("correlation_id = :correlation_id OR event_data.purchase_order.metadata.correlation_id = :correlation_id"),
+		ProjectionExpression: aws.String("id, aggregate_id, event_type, #timestamp, correlation_id, causation_id, event_data.purchase_order.#status, event_data.purchase_order.metadata.stock_low_event_id"),
+		ExpressionAttributeNames: map[string]*string{
+			"#timestamp": aws.String("timestamp"),
+			"#status":    aws.String("status"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":correlation_id": {S: aws.String(correlationID)},
+		},
+	}
+
+	var events []orderEvent
+	for {
+		result, err := t.DynamoDB.ScanWithContext(ctx, scanInput)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan events: %w", err)
+		}
+
+		for _, item := range result.Items {
+			var event orderEvent
+			if err := dynamodbattribute.UnmarshalMap(item, &event); err != nil {
+				t.Logger.Printf("Failed to unmarshal event: %v", err)
+				continue
+			}
+			events = append(events, event)
+		}
+
+		if len(result.LastEvaluatedKey) == 0 {
+			break
+		}
+		scanInput.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Timestamp.Before(events[j].Timestamp)
+	})
+	return events, nil
+}
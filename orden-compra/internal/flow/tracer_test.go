@@ -0,0 +1,136 @@
+package flow
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+
+	"medisupply/correlation"
+	"orden-compra/internal/memory"
+	"orden-compra/internal/models"
+)
+
+var flowStart = time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+
+// putOrderEvent stores an event of an order created in the chain-1 chain
+func putOrderEvent(t *testing.T, dynamoDB *memory.DynamoDB, purchaseOrderID, eventType, status string, correlationID *string, at time.Time) {
+	t.Helper()
+	purchaseOrder := map[string]interface{}{
+		"id":       purchaseOrderID,
+		"status":   status,
+		"metadata": map[string]interface{}{"correlation_id": "chain-1", "stock_low_event_id": "stock-low-1"},
+	}
+	event := models.NewEventSourcingEvent(purchaseOrderID, eventType, map[string]interface{}{"purchase_order": purchaseOrder}, correlationID, nil, at)
+	item, err := dynamodbattribute.MarshalMap(event)
+	if err != nil {
+		t.Fatalf("marshal event: %v", err)
+	}
+	if _, err := dynamoDB.PutItemWithContext(context.Background(), &dynamodb.PutItemInput{TableName: aws.String("orden-compra-events"), Item: item}); err != nil {
+		t.Fatalf("put event: %v", err)
+	}
+}
+
+// startProveedor serves the event log of po-1, or fails with status
+func startProveedor(t *testing.T, status int) (*ProveedorClient, *http.Header) {
+	t.Helper()
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Clone()
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			return
+		}
+		events := []map[string]interface{}{}
+		if r.URL.Path == "/purchase-orders/po-1/events" {
+			events = append(events, map[string]interface{}{
+				"id":             "proveedor-event-1",
+				"aggregate_id":   "po-1",
+				"event_type":     "InventarioRecibido",
+				"event_data":     map[string]interface{}{"cantidad": 10},
+				"timestamp":      flowStart.Add(3 * time.Hour),
+				"correlation_id": "chain-1",
+			})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"events": events})
+	}))
+	t.Cleanup(server.Close)
+	return NewProveedorClient(server.URL+"/", time.Second), &header
+}
+
+func TestTraceFollowsTheChainAcrossServices(t *testing.T) {
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	chain, approval := "chain-1", "request-2"
+	putOrderEvent(t, dynamoDB, "po-1", "PurchaseOrderCreated", models.StatusPending, &chain, flowStart)
+	// A later command in its own chain still snapshots the order of this one
+	putOrderEvent(t, dynamoDB, "po-1", "PurchaseOrderStatusUpdated", models.StatusSent, &approval, flowStart.Add(time.Hour))
+	// So does an order created in the chain, such as a split of the first
+	putOrderEvent(t, dynamoDB, "po-9", "PurchaseOrderCreated", models.StatusPending, &approval, flowStart)
+
+	proveedor, header := startProveedor(t, http.StatusOK)
+	tracer := NewTracer(dynamoDB, proveedor, nil, log.New(io.Discard, "", 0))
+	ctx := correlation.NewContext(context.Background(), correlation.IDs{RequestID: "request-1", CorrelationID: "chain-1"})
+	trace, err := tracer.Trace(ctx, "chain-1")
+	if err != nil {
+		t.Fatalf("Trace: %v", err)
+	}
+
+	if len(trace.PurchaseOrderIDs) != 2 || len(trace.Unavailable) != 0 {
+		t.Fatalf("trace of %v, unavailable %v", trace.PurchaseOrderIDs, trace.Unavailable)
+	}
+	want := []struct {
+		service, eventType string
+		inferred           bool
+	}{
+		{ServiceMovimientoInventario, stockLowEventType, true},
+		{ServiceOrdenCompra, "PurchaseOrderCreated", false},
+		{ServiceOrdenCompra, "PurchaseOrderCreated", false},
+		{ServiceOrdenCompra, "PurchaseOrderStatusUpdated", false},
+		{ServiceProveedor, "InventarioRecibido", false},
+	}
+	if len(trace.Timeline) != len(want) {
+		t.Fatalf("timeline %+v, want %d entries", trace.Timeline, len(want))
+	}
+	for i, entry := range trace.Timeline {
+		if entry.Service != want[i].service || entry.EventType != want[i].eventType || entry.Inferred != want[i].inferred {
+			t.Fatalf("entry %d is %s %s, want %s %s", i, entry.Service, entry.EventType, want[i].service, want[i].eventType)
+		}
+	}
+	if last := trace.Timeline[4]; last.PurchaseOrderID != "po-1" || last.Data["cantidad"] != float64(10) {
+		t.Fatalf("proveedor entry %+v", last)
+	}
+	if header.Get(correlation.CorrelationIDHeader) != "chain-1" || header.Get(correlation.RequestIDHeader) != "request-1" {
+		t.Fatalf("proveedor request headers %v", *header)
+	}
+}
+
+func TestTraceWithoutProveedor(t *testing.T) {
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	chain := "chain-1"
+	putOrderEvent(t, dynamoDB, "po-1", "PurchaseOrderCreated", models.StatusPending, &chain, flowStart)
+	failing, _ := startProveedor(t, http.StatusBadGateway)
+
+	for name, proveedor := range map[string]*ProveedorClient{"unreachable": failing, "not traced": NewProveedorClient("", time.Second)} {
+		t.Run(name, func(t *testing.T) {
+			tracer := NewTracer(dynamoDB, proveedor, nil, log.New(io.Discard, "", 0))
+			trace, err := tracer.Trace(context.Background(), "chain-1")
+			if err != nil || len(trace.Timeline) != 2 || len(trace.Unavailable) != 1 || trace.Unavailable[0] != ServiceProveedor {
+				t.Fatalf("trace %+v, error %v, want orden-compra's events only", trace, err)
+			}
+		})
+	}
+
+	tracer := NewTracer(dynamoDB, nil, nil, log.New(io.Discard, "", 0))
+	if _, err := tracer.Trace(context.Background(), "chain-2"); !errors.Is(err, ErrFlowNotFound) {
+		t.Fatalf("unknown chain returned %v", err)
+	}
+}
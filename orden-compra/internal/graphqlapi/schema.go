@@ -0,0 +1,378 @@
+package graphqlapi
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/sirupsen/logrus"
+
+	"orden-compra/internal/cqrs"
+	"orden-compra/internal/models"
+)
+
+// Service executes GraphQL queries against the purchase order read model
+type Service struct {
+	Schema       graphql.Schema
+	DynamoDB     dynamodbiface.DynamoDBAPI
+	ScanSegments int
+	Logger       *logrus.Logger
+}
+
+// NewService builds the schema and returns a service ready to execute queries
+func NewService(dynamoDB dynamodbiface.DynamoDBAPI, logger *logrus.Logger) (*Service, error) {
+	s := &Service{
+		DynamoDB: dynamoDB,
+		Logger:   logger,
+	}
+
+	schema, err := s.buildSchema()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build GraphQL schema: %w", err)
+	}
+	s.Schema = schema
+
+	return s, nil
+}
+
+// Execute runs a GraphQL request. Errors are reported in the result, as the
+// GraphQL spec requires.
+func (s *Service) Execute(ctx context.Context, query, operationName string, variables map[string]interface{}) *graphql.Result {
+	return graphql.Do(graphql.Params{
+		Schema:         s.Schema,
+		RequestString:  query,
+		OperationName:  operationName,
+		VariableValues: variables,
+		Context:        ctx,
+	})
+}
+
+// jsonScalar passes free-form objects such as metadata through unchanged
+var jsonScalar = graphql.NewScalar(graphql.ScalarConfig{
+	Name:        "JSON",
+	Description: "Free-form JSON value",
+	Serialize: func(value interface{}) interface{} {
+		return value
+	},
+	ParseValue: func(value interface{}) interface{} {
+		return value
+	},
+	ParseLiteral: func(valueAST ast.Value) interface{} {
+		return valueAST.GetValue()
+	},
+})
+
+// purchaseOrderField resolves a PurchaseOrder field from the model
+func purchaseOrderField(typ graphql.Output, get func(po *models.PurchaseOrder) interface{}) *graphql.Field {
+	return &graphql.Field{
+		Type: typ,
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			po, ok := p.Source.(*models.PurchaseOrder)
+			if !ok {
+				return nil, nil
+			}
+			return get(po), nil
+		},
+	}
+}
+
+// orderEventField resolves an OrderEvent field from the model
+func orderEventField(typ graphql.Output, get func(event *models.EventSourcingEvent) interface{}) *graphql.Field {
+	return &graphql.Field{
+		Type: typ,
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			event, ok := p.Source.(*models.EventSourcingEvent)
+			if !ok {
+				return nil, nil
+			}
+			return get(event), nil
+		},
+	}
+}
+
+// buildSchema declares the GraphQL types and wires them to the CQRS queries
+func (s *Service) buildSchema() (graphql.Schema, error) {
+	orderEventType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "OrderEvent",
+		Fields: graphql.Fields{
+			"id":            orderEventField(graphql.NewNonNull(graphql.ID), func(e *models.EventSourcingEvent) interface{} { return e.ID }),
+			"aggregateId":   orderEventField(graphql.NewNonNull(graphql.ID), func(e *models.EventSourcingEvent) interface{} { return e.AggregateID }),
+			"eventType":     orderEventField(graphql.NewNonNull(graphql.String), func(e *models.EventSourcingEvent) interface{} { return e.EventType }),
+			"eventData":     orderEventField(jsonScalar, func(e *models.EventSourcingEvent) interface{} { return e.EventData }),
+			"timestamp":     orderEventField(graphql.DateTime, func(e *models.EventSourcingEvent) interface{} { return e.Timestamp }),
+			"version":       orderEventField(graphql.Int, func(e *models.EventSourcingEvent) interface{} { return e.Version }),
+			"schemaVersion": orderEventField(graphql.Int, func(e *models.EventSourcingEvent) interface{} { return e.SchemaVersion }),
+			"correlationId": orderEventField(graphql.String, func(e *models.EventSourcingEvent) interface{} {
+				if e.CorrelationID == nil {
+					return nil
+				}
+				return *e.CorrelationID
+			}),
+			"causationId": orderEventField(graphql.String, func(e *models.EventSourcingEvent) interface{} {
+				if e.CausationID == nil {
+					return nil
+				}
+				return *e.CausationID
+			}),
+		},
+	})
+
+	orderEventsArgs := graphql.FieldConfigArgument{
+		"eventType": &graphql.ArgumentConfig{Type: graphql.String},
+		"limit":     &graphql.ArgumentConfig{Type: graphql.Int},
+	}
+
+	purchaseOrderType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "PurchaseOrder",
+		Fields: graphql.Fields{
+			"id":             purchaseOrderField(graphql.NewNonNull(graphql.ID), func(po *models.PurchaseOrder) interface{} { return po.ID }),
+			"productId":      purchaseOrderField(graphql.String, func(po *models.PurchaseOrder) interface{} { return po.ProductID }),
+			"productName":    purchaseOrderField(graphql.String, func(po *models.PurchaseOrder) interface{} { return po.ProductName }),
+			"quantity":       purchaseOrderField(graphql.Int, func(po *models.PurchaseOrder) interface{} { return po.Quantity }),
+			"supplierId":     purchaseOrderField(graphql.String, func(po *models.PurchaseOrder) interface{} { return po.SupplierID }),
+			"supplierName":   purchaseOrderField(graphql.String, func(po *models.PurchaseOrder) interface{} { return po.SupplierName }),
+			"location":       purchaseOrderField(graphql.String, func(po *models.PurchaseOrder) interface{} { return po.Location }),
+			"status":         purchaseOrderField(graphql.String, func(po *models.PurchaseOrder) interface{} { return po.Status }),
+			"urgencyLevel":   purchaseOrderField(graphql.String, func(po *models.PurchaseOrder) interface{} { return po.UrgencyLevel }),
+			"blanketOrderId": purchaseOrderField(graphql.String, func(po *models.PurchaseOrder) interface{} { return po.BlanketOrderID }),
+			"createdAt":      purchaseOrderField(graphql.DateTime, func(po *models.PurchaseOrder) interface{} { return po.CreatedAt }),
+			"updatedAt":      purchaseOrderField(graphql.DateTime, func(po *models.PurchaseOrder) interface{} { return po.UpdatedAt }),
+			"expectedDate":   purchaseOrderField(graphql.DateTime, func(po *models.PurchaseOrder) interface{} { return po.ExpectedDate }),
+			"actualDate":     purchaseOrderField(graphql.DateTime, func(po *models.PurchaseOrder) interface{} { return po.ActualDate }),
+			"isOverdue":      purchaseOrderField(graphql.Boolean, func(po *models.PurchaseOrder) interface{} { return po.IsOverdue(time.Now()) }),
+			"metadata":       purchaseOrderField(jsonScalar, func(po *models.PurchaseOrder) interface{} { return po.Metadata }),
+			"events": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(orderEventType))),
+				Description: "Event-sourcing history of the purchase order",
+				Args:        orderEventsArgs,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					po, ok := p.Source.(*models.PurchaseOrder)
+					if !ok {
+						return nil, nil
+					}
+					return s.resolveOrderEvents(p.Context, po.ID, p.Args)
+				},
+			},
+		},
+	})
+
+	countBucketType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "CountBucket",
+		Fields: graphql.Fields{
+			"key":   &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"count": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		},
+	})
+
+	statsType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "PurchaseOrderStats",
+		Fields: graphql.Fields{
+			"totalOrders":     &graphql.Field{Type: graphql.Int, Resolve: statsField("total_orders")},
+			"pendingOrders":   &graphql.Field{Type: graphql.Int, Resolve: statsField("pending_orders")},
+			"completedOrders": &graphql.Field{Type: graphql.Int, Resolve: statsField("completed_orders")},
+			"overdueOrders":   &graphql.Field{Type: graphql.Int, Resolve: statsField("overdue_orders")},
+			"byStatus":        &graphql.Field{Type: graphql.NewList(countBucketType), Resolve: statsBuckets("by_status")},
+			"byUrgency":       &graphql.Field{Type: graphql.NewList(countBucketType), Resolve: statsBuckets("by_urgency")},
+			"bySupplier":      &graphql.Field{Type: graphql.NewList(countBucketType), Resolve: statsBuckets("by_supplier")},
+
+			// Business figures; the latency is in seconds
+			"openByUrgency":         &graphql.Field{Type: graphql.NewList(countBucketType), Resolve: statsBuckets("open_by_urgency")},
+			"receivedOrders":        &graphql.Field{Type: graphql.Int, Resolve: statsField("received_orders")},
+			"qualityFailures":       &graphql.Field{Type: graphql.Int, Resolve: statsField("quality_failures")},
+			"averageReceiptLatency": &graphql.Field{Type: graphql.Float, Resolve: statsField("average_receipt_latency")},
+			"qualityFailureRate":    &graphql.Field{Type: graphql.Float, Resolve: statsField("quality_failure_rate")},
+		},
+	})
+
+	filterType := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "PurchaseOrderFilter",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"productId":      &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"supplierId":     &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"status":         &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"urgencyLevel":   &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"blanketOrderId": &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"createdAfter":   &graphql.InputObjectFieldConfig{Type: graphql.DateTime},
+			"createdBefore":  &graphql.InputObjectFieldConfig{Type: graphql.DateTime},
+		},
+	})
+
+	queryType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"purchaseOrder": &graphql.Field{
+				Type: purchaseOrderType,
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+				},
+				Resolve: s.resolvePurchaseOrder,
+			},
+			"purchaseOrders": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(purchaseOrderType))),
+				Args: graphql.FieldConfigArgument{
+					"filter": &graphql.ArgumentConfig{Type: filterType},
+					"limit":  &graphql.ArgumentConfig{Type: graphql.Int},
+				},
+				Resolve: s.resolvePurchaseOrders,
+			},
+			"orderEvents": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(orderEventType))),
+				Args: graphql.FieldConfigArgument{
+					"purchaseOrderId": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+					"eventType":       &graphql.ArgumentConfig{Type: graphql.String},
+					"limit":           &graphql.ArgumentConfig{Type: graphql.Int},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return s.resolveOrderEvents(p.Context, p.Args["purchaseOrderId"].(string), p.Args)
+				},
+			},
+			"stats": &graphql.Field{
+				Type: statsType,
+				Args: graphql.FieldConfigArgument{
+					"createdAfter":  &graphql.ArgumentConfig{Type: graphql.DateTime},
+					"createdBefore": &graphql.ArgumentConfig{Type: graphql.DateTime},
+				},
+				Resolve: s.resolveStats,
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{
+		Query: queryType,
+	})
+}
+
+// resolvePurchaseOrder returns one purchase order, or null when it does not exist
+func (s *Service) resolvePurchaseOrder(p graphql.ResolveParams) (interface{}, error) {
+	result, err := cqrs.NewGetPurchaseOrderQuery(p.Args["id"].(string), s.DynamoDB, s.Logger).Execute(p.Context)
+	if err != nil {
+		return nil, err
+	}
+
+	purchaseOrder, ok := result["purchase_order"].(models.PurchaseOrder)
+	if !ok {
+		return nil, nil
+	}
+	return &purchaseOrder, nil
+}
+
+// resolvePurchaseOrders lists purchase orders matching the filter
+func (s *Service) resolvePurchaseOrders(p graphql.ResolveParams) (interface{}, error) {
+	query := cqrs.NewListPurchaseOrdersQuery(s.DynamoDB, s.Logger)
+	if limit, ok := p.Args["limit"].(int); ok && limit > 0 {
+		query.WithLimit(int64(limit))
+	}
+
+	if filter, ok := p.Args["filter"].(map[string]interface{}); ok {
+		if v, ok := filter["productId"].(string); ok {
+			query.WithProductID(v)
+		}
+		if v, ok := filter["supplierId"].(string); ok {
+			query.WithSupplierID(v)
+		}
+		if v, ok := filter["status"].(string); ok {
+			query.WithStatus(v)
+		}
+		if v, ok := filter["urgencyLevel"].(string); ok {
+			query.WithUrgencyLevel(v)
+		}
+		if v, ok := filter["blanketOrderId"].(string); ok {
+			query.WithBlanketOrderID(v)
+		}
+		if start, end, ok := dateRange(filter); ok {
+			query.WithDateRange(start, end)
+		}
+	}
+
+	result, err := query.Execute(p.Context)
+	if err != nil {
+		return nil, err
+	}
+
+	purchaseOrders, _ := result["purchase_orders"].([]models.PurchaseOrder)
+	items := make([]*models.PurchaseOrder, len(purchaseOrders))
+	for i := range purchaseOrders {
+		items[i] = &purchaseOrders[i]
+	}
+	return items, nil
+}
+
+// resolveOrderEvents returns the events of a purchase order
+func (s *Service) resolveOrderEvents(ctx context.Context, purchaseOrderID string, args map[string]interface{}) (interface{}, error) {
+	query := cqrs.NewGetPurchaseOrderEventsQuery(purchaseOrderID, s.DynamoDB, s.Logger)
+	if v, ok := args["eventType"].(string); ok {
+		query.WithEventType(v)
+	}
+	if limit, ok := args["limit"].(int); ok && limit > 0 {
+		query.WithLimit(int64(limit))
+	}
+
+	result, err := query.Execute(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	events, _ := result["events"].([]models.EventSourcingEvent)
+	items := make([]*models.EventSourcingEvent, len(events))
+	for i := range events {
+		items[i] = &events[i]
+	}
+	return items, nil
+}
+
+// resolveStats returns purchase order statistics
+func (s *Service) resolveStats(p graphql.ResolveParams) (interface{}, error) {
+	query := cqrs.NewGetPurchaseOrderStatsQuery(s.DynamoDB, s.Logger).WithScanSegments(s.ScanSegments)
+	if start, end, ok := dateRange(p.Args); ok {
+		query.WithDateRange(start, end)
+	}
+
+	result, err := query.Execute(p.Context)
+	if err != nil {
+		return nil, err
+	}
+	return result["stats"], nil
+}
+
+// dateRange reads the createdAfter/createdBefore pair; an open bound extends
+// to the beginning of time or now
+func dateRange(args map[string]interface{}) (time.Time, time.Time, bool) {
+	start, hasStart := args["createdAfter"].(time.Time)
+	end, hasEnd := args["createdBefore"].(time.Time)
+	if !hasStart && !hasEnd {
+		return time.Time{}, time.Time{}, false
+	}
+	if !hasEnd {
+		end = time.Now().UTC()
+	}
+	return start, end, true
+}
+
+// statsField resolves a counter of the stats query result
+func statsField(key string) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		stats, _ := p.Source.(map[string]interface{})
+		return stats[key], nil
+	}
+}
+
+// statsBuckets resolves a breakdown of the stats query result as key/count pairs
+func statsBuckets(key string) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		stats, _ := p.Source.(map[string]interface{})
+		counts, _ := stats[key].(map[string]int)
+
+		buckets := make([]map[string]interface{}, 0, len(counts))
+		for k, count := range counts {
+			buckets = append(buckets, map[string]interface{}{"key": k, "count": count})
+		}
+		sort.Slice(buckets, func(i, j int) bool {
+			return buckets[i]["key"].(string) < buckets[j]["key"].(string)
+		})
+		return buckets, nil
+	}
+}
@@ -0,0 +1,151 @@
+package graphqlapi
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"orden-compra/internal/cqrs"
+	"orden-compra/internal/memory"
+	"orden-compra/internal/models"
+)
+
+// newService returns a service over an in-memory read model holding the orders
+func newService(t *testing.T, purchaseOrders ...*models.PurchaseOrder) *Service {
+	t.Helper()
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	correlationID := "correlation-1"
+	for _, purchaseOrder := range purchaseOrders {
+		command := cqrs.NewCreatePurchaseOrderCommand(purchaseOrder, dynamoDB, log.New(io.Discard, "", 0), &correlationID, nil)
+		if _, err := command.Execute(context.Background()); err != nil {
+			t.Fatalf("create purchase order: %v", err)
+		}
+	}
+
+	s, err := NewService(dynamoDB, &logrus.Logger{Out: io.Discard, Formatter: new(logrus.TextFormatter), Level: logrus.PanicLevel})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	return s
+}
+
+// execute runs a query that must succeed and decodes its data into v
+func execute(t *testing.T, s *Service, query string, variables map[string]interface{}, v interface{}) {
+	t.Helper()
+	result := s.Execute(context.Background(), query, "", variables)
+	if result.HasErrors() {
+		t.Fatalf("query returned errors: %v", result.Errors)
+	}
+	data, err := json.Marshal(result.Data)
+	if err != nil {
+		t.Fatalf("marshal data: %v", err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		t.Fatalf("unmarshal %s: %v", data, err)
+	}
+}
+
+func TestPurchaseOrderQuery(t *testing.T) {
+	purchaseOrder := models.NewPurchaseOrder("product-1", "Gloves", "supplier-1", "Acme", "warehouse-1", "HIGH", 10, time.Now())
+	s := newService(t, purchaseOrder)
+
+	var data struct {
+		PurchaseOrder *struct {
+			ID        string `json:"id"`
+			Quantity  int    `json:"quantity"`
+			Status    string `json:"status"`
+			IsOverdue bool   `json:"isOverdue"`
+			Events    []struct {
+				EventType     string `json:"eventType"`
+				CorrelationID string `json:"correlationId"`
+			} `json:"events"`
+		} `json:"purchaseOrder"`
+	}
+	query := `query($id: ID!) { purchaseOrder(id: $id) { id quantity status isOverdue events { eventType correlationId } } }`
+	execute(t, s, query, map[string]interface{}{"id": purchaseOrder.ID}, &data)
+
+	got := data.PurchaseOrder
+	if got == nil || got.ID != purchaseOrder.ID || got.Quantity != 10 || got.Status != purchaseOrder.Status || got.IsOverdue {
+		t.Fatalf("purchase order %+v", got)
+	}
+	if len(got.Events) != 1 || got.Events[0].EventType != "PurchaseOrderCreated" || got.Events[0].CorrelationID != "correlation-1" {
+		t.Fatalf("events %+v", got.Events)
+	}
+
+	// An unknown order resolves to null rather than an error
+	data.PurchaseOrder = nil
+	execute(t, s, query, map[string]interface{}{"id": "missing"}, &data)
+	if data.PurchaseOrder != nil {
+		t.Fatalf("unknown order resolved to %+v", data.PurchaseOrder)
+	}
+}
+
+func TestPurchaseOrdersQueryFilters(t *testing.T) {
+	s := newService(t,
+		models.NewPurchaseOrder("product-1", "Gloves", "supplier-1", "Acme", "warehouse-1", "HIGH", 10, time.Now()),
+		models.NewPurchaseOrder("product-2", "Masks", "supplier-1", "Acme", "warehouse-1", "LOW", 20, time.Now()),
+		models.NewPurchaseOrder("product-3", "Syringes", "supplier-2", "Medico", "warehouse-1", "HIGH", 30, time.Now()),
+	)
+
+	for _, tc := range []struct {
+		name   string
+		filter map[string]interface{}
+		want   int
+	}{
+		{"no filter", nil, 3},
+		{"supplier", map[string]interface{}{"supplierId": "supplier-1"}, 2},
+		{"supplier and urgency", map[string]interface{}{"supplierId": "supplier-1", "urgencyLevel": "HIGH"}, 1},
+		{"product", map[string]interface{}{"productId": "product-3"}, 1},
+		{"created before they were", map[string]interface{}{"createdBefore": "2000-01-01T00:00:00Z"}, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var data struct {
+				PurchaseOrders []struct {
+					ID string `json:"id"`
+				} `json:"purchaseOrders"`
+			}
+			execute(t, s, `query($filter: PurchaseOrderFilter) { purchaseOrders(filter: $filter) { id } }`, map[string]interface{}{"filter": tc.filter}, &data)
+			if len(data.PurchaseOrders) != tc.want {
+				t.Fatalf("listed %d orders, want %d", len(data.PurchaseOrders), tc.want)
+			}
+		})
+	}
+}
+
+func TestStatsQuery(t *testing.T) {
+	s := newService(t,
+		models.NewPurchaseOrder("product-1", "Gloves", "supplier-1", "Acme", "warehouse-1", "HIGH", 10, time.Now()),
+		models.NewPurchaseOrder("product-2", "Masks", "supplier-2", "Medico", "warehouse-1", "HIGH", 20, time.Now()),
+	)
+
+	var data struct {
+		Stats struct {
+			TotalOrders int `json:"totalOrders"`
+			BySupplier  []struct {
+				Key   string `json:"key"`
+				Count int    `json:"count"`
+			} `json:"bySupplier"`
+		} `json:"stats"`
+	}
+	execute(t, s, `{ stats { totalOrders bySupplier { key count } } }`, nil, &data)
+
+	stats := data.Stats
+	if stats.TotalOrders != 2 || len(stats.BySupplier) != 2 || stats.BySupplier[0].Key != "supplier-1" || stats.BySupplier[1].Count != 1 {
+		t.Fatalf("stats %+v", stats)
+	}
+}
+
+func TestInvalidQueryReportsErrors(t *testing.T) {
+	s := newService(t)
+	if result := s.Execute(context.Background(), `{ purchaseOrder { id } }`, "", nil); !result.HasErrors() {
+		t.Fatal("query without the required id returned no errors")
+	}
+	if result := s.Execute(context.Background(), `{ purchaseOrders { unknownField } }`, "", nil); !result.HasErrors() {
+		t.Fatal("query for an unknown field returned no errors")
+	}
+}
@@ -0,0 +1,64 @@
+package grpcapi
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"medisupply/api/ordencomprav1"
+	wire "medisupply/codec"
+	"orden-compra/internal/models"
+)
+
+// purchaseOrder converts a purchase order to its medisupply.ordencompra.v1 message
+func purchaseOrder(po *models.PurchaseOrder) (*ordencomprav1.PurchaseOrder, error) {
+	metadata, err := wire.EncodeMetadata(po.Metadata)
+	if err != nil {
+		return nil, err
+	}
+	return &ordencomprav1.PurchaseOrder{
+		Id:           po.ID,
+		ProductId:    po.ProductID,
+		ProductName:  po.ProductName,
+		Quantity:     int64(po.Quantity),
+		SupplierId:   po.SupplierID,
+		SupplierName: po.SupplierName,
+		Location:     po.Location,
+		Status:       po.Status,
+		UrgencyLevel: po.UrgencyLevel,
+		CreatedAt:    wire.Timestamp(po.CreatedAt),
+		UpdatedAt:    wire.Timestamp(po.UpdatedAt),
+		ExpectedDate: wire.OptionalTimestamp(po.ExpectedDate),
+		ActualDate:   wire.OptionalTimestamp(po.ActualDate),
+		Metadata:     metadata,
+	}, nil
+}
+
+// orderEvent converts a purchase order event to its medisupply.ordencompra.v1 message
+func orderEvent(event *models.EventSourcingEvent) (*ordencomprav1.OrderEvent, error) {
+	var eventData []byte
+	if len(event.EventData) > 0 {
+		raw, err := json.Marshal(event.EventData)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode event data: %w", err)
+		}
+		eventData = raw
+	}
+	return &ordencomprav1.OrderEvent{
+		Id:            event.ID,
+		AggregateId:   event.AggregateID,
+		EventType:     event.EventType,
+		EventData:     eventData,
+		Timestamp:     wire.Timestamp(event.Timestamp),
+		Version:       int64(event.Version),
+		CorrelationId: stringValue(event.CorrelationID),
+		CausationId:   stringValue(event.CausationID),
+	}, nil
+}
+
+// stringValue returns the string s points to, empty when nil
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,270 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"medisupply/api/ordencomprav1"
+	wire "medisupply/codec"
+	"orden-compra/internal/auth"
+	"orden-compra/internal/cqrs"
+	"orden-compra/internal/models"
+	"orden-compra/internal/tenant"
+)
+
+// Server answers purchase order queries over gRPC from the read model
+type Server struct {
+	DynamoDB       dynamodbiface.DynamoDBAPI
+	DefaultTimeout time.Duration
+	Authenticator  *auth.Authenticator
+	Authorizer     *auth.Authorizer
+	Tenancy        tenant.Policy
+	Logger         *logrus.Logger
+
+	grpcServer *grpc.Server
+	ordencomprav1.UnimplementedPurchaseOrderQueryServiceServer
+}
+
+// NewServer creates a query server. Calls without a client deadline are
+// bounded by defaultTimeout. A nil authenticator accepts every call.
+func NewServer(dynamoDB dynamodbiface.DynamoDBAPI, defaultTimeout time.Duration, authenticator *auth.Authenticator, authorizer *auth.Authorizer, tenancy tenant.Policy, logger *logrus.Logger) *Server {
+	s := &Server{
+		DynamoDB:       dynamoDB,
+		DefaultTimeout: defaultTimeout,
+		Authenticator:  authenticator,
+		Authorizer:     authorizer,
+		Tenancy:        tenancy,
+		Logger:         logger,
+	}
+
+	s.grpcServer = grpc.NewServer(
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(s.deadlineInterceptor, s.loggingInterceptor, s.authInterceptor, s.tenantInterceptor),
+	)
+	ordencomprav1.RegisterPurchaseOrderQueryServiceServer(s.grpcServer, s)
+
+	return s
+}
+
+// Serve accepts connections on the address until Stop is called
+func (s *Server) Serve(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	s.Logger.WithField("addr", addr).Info("Starting gRPC server")
+	return s.serve(listener)
+}
+
+// serve accepts connections on listener until Stop is called
+func (s *Server) serve(listener net.Listener) error {
+	return s.grpcServer.Serve(listener)
+}
+
+// Stop finishes in-flight calls and stops the server
+func (s *Server) Stop() {
+	s.grpcServer.GracefulStop()
+}
+
+// GetPurchaseOrder returns one purchase order
+func (s *Server) GetPurchaseOrder(ctx context.Context, req *ordencomprav1.GetPurchaseOrderRequest) (*ordencomprav1.GetPurchaseOrderResponse, error) {
+	if req.GetId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+
+	result, err := cqrs.NewGetPurchaseOrderQuery(req.GetId(), s.DynamoDB, s.Logger).Execute(ctx)
+	if err != nil {
+		return nil, queryError(ctx, err)
+	}
+
+	found, ok := result["purchase_order"].(models.PurchaseOrder)
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "purchase order %s not found", req.GetId())
+	}
+
+	message, err := purchaseOrder(&found)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &ordencomprav1.GetPurchaseOrderResponse{PurchaseOrder: message}, nil
+}
+
+// ListPurchaseOrders lists purchase orders matching the request filters
+func (s *Server) ListPurchaseOrders(ctx context.Context, req *ordencomprav1.ListPurchaseOrdersRequest) (*ordencomprav1.ListPurchaseOrdersResponse, error) {
+	query := cqrs.NewListPurchaseOrdersQuery(s.DynamoDB, s.Logger)
+	if req.GetProductId() != "" {
+		query.WithProductID(req.GetProductId())
+	}
+	if req.GetSupplierId() != "" {
+		query.WithSupplierID(req.GetSupplierId())
+	}
+	if req.GetStatus() != "" {
+		query.WithStatus(req.GetStatus())
+	}
+	if req.GetUrgencyLevel() != "" {
+		query.WithUrgencyLevel(req.GetUrgencyLevel())
+	}
+	if req.GetStartDate() != nil && req.GetEndDate() != nil {
+		query.WithDateRange(wire.Time(req.GetStartDate()), wire.Time(req.GetEndDate()))
+	}
+	if req.GetLimit() > 0 {
+		query.WithLimit(req.GetLimit())
+	}
+
+	result, err := query.Execute(ctx)
+	if err != nil {
+		return nil, queryError(ctx, err)
+	}
+
+	found, _ := result["purchase_orders"].([]models.PurchaseOrder)
+	response := &ordencomprav1.ListPurchaseOrdersResponse{}
+	for i := range found {
+		message, err := purchaseOrder(&found[i])
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		response.PurchaseOrders = append(response.PurchaseOrders, message)
+	}
+	return response, nil
+}
+
+// GetOrderEvents returns the events of a purchase order
+func (s *Server) GetOrderEvents(ctx context.Context, req *ordencomprav1.GetOrderEventsRequest) (*ordencomprav1.GetOrderEventsResponse, error) {
+	if req.GetPurchaseOrderId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "purchase_order_id is required")
+	}
+
+	query := cqrs.NewGetPurchaseOrderEventsQuery(req.GetPurchaseOrderId(), s.DynamoDB, s.Logger)
+	if req.GetEventType() != "" {
+		query.WithEventType(req.GetEventType())
+	}
+	if req.GetLimit() > 0 {
+		query.WithLimit(req.GetLimit())
+	}
+
+	result, err := query.Execute(ctx)
+	if err != nil {
+		return nil, queryError(ctx, err)
+	}
+
+	found, _ := result["events"].([]models.EventSourcingEvent)
+	response := &ordencomprav1.GetOrderEventsResponse{}
+	for i := range found {
+		message, err := orderEvent(&found[i])
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		response.Events = append(response.Events, message)
+	}
+	return response, nil
+}
+
+// deadlineInterceptor bounds calls without a client deadline. Client deadlines
+// already travel in the context down to DynamoDB.
+func (s *Server) deadlineInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if _, ok := ctx.Deadline(); !ok && s.DefaultTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.DefaultTimeout)
+		defer cancel()
+	}
+	return handler(ctx, req)
+}
+
+// loggingInterceptor logs every call with its outcome
+func (s *Server) loggingInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+
+	entry := s.Logger.WithFields(logrus.Fields{
+		"method":   info.FullMethod,
+		"code":     status.Code(err).String(),
+		"duration": time.Since(start),
+	})
+	if err != nil {
+		entry.WithError(err).Warn("gRPC call failed")
+	} else {
+		entry.Debug("gRPC call handled")
+	}
+
+	return resp, err
+}
+
+// authInterceptor authenticates calls with the same API keys and bearer tokens
+// as the HTTP API, read from the authorization and x-api-key metadata, and
+// requires the query permission
+func (s *Server) authInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if s.Authenticator == nil {
+		return handler(ctx, req)
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	principal, err := s.Authenticator.Authenticate(ctx, firstValue(md, "authorization"), firstValue(md, "x-api-key"))
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	ctx = auth.NewContext(ctx, principal)
+	if s.Authorizer != nil {
+		if err := s.Authorizer.Authorize(ctx, auth.PermissionQuery); err != nil {
+			if errors.Is(err, auth.ErrForbidden) {
+				return nil, status.Error(codes.PermissionDenied, err.Error())
+			}
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+	}
+
+	return handler(ctx, req)
+}
+
+// tenantInterceptor scopes calls to the caller's tenant the same way as the
+// HTTP API, reading the requested tenant from the x-tenant-id metadata
+func (s *Server) tenantInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	var credentialTenant string
+	principal, authenticated := auth.FromContext(ctx)
+	if authenticated {
+		credentialTenant = principal.Tenant
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	tenantID, err := s.Tenancy.ForCaller(credentialTenant, firstValue(md, "x-tenant-id"), authenticated)
+	if err != nil {
+		if errors.Is(err, tenant.ErrInvalidTenant) {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	return handler(tenant.NewContext(ctx, tenantID), req)
+}
+
+// firstValue returns the first value of a metadata key
+func firstValue(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// queryError maps a query failure onto a gRPC status
+func queryError(ctx context.Context, err error) error {
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	case errors.Is(ctx.Err(), context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
@@ -0,0 +1,164 @@
+package grpcapi
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"medisupply/api/ordencomprav1"
+	"orden-compra/internal/memory"
+	"orden-compra/internal/models"
+	"orden-compra/internal/tenant"
+)
+
+var createdAt = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// put stores an item in a table of the memory DynamoDB
+func put(t *testing.T, dynamoDB *memory.DynamoDB, table string, v interface{}) {
+	t.Helper()
+	item, err := dynamodbattribute.MarshalMap(v)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if _, err := dynamoDB.PutItemWithContext(context.Background(), &dynamodb.PutItemInput{TableName: aws.String(table), Item: item}); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+}
+
+// newClient serves the query API over an in-memory connection and returns
+// a client generated from the .proto file
+func newClient(t *testing.T, dynamoDB *memory.DynamoDB) ordencomprav1.PurchaseOrderQueryServiceClient {
+	t.Helper()
+	logger := &logrus.Logger{Out: io.Discard, Formatter: new(logrus.TextFormatter), Level: logrus.PanicLevel}
+	server := NewServer(dynamoDB, time.Second, nil, nil, tenant.Policy{DefaultTenant: "default"}, logger)
+
+	listener := bufconn.Listen(1 << 20)
+	go server.serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return ordencomprav1.NewPurchaseOrderQueryServiceClient(conn)
+}
+
+func newOrder(id, supplierID string) models.PurchaseOrder {
+	expected := createdAt.Add(72 * time.Hour)
+	return models.PurchaseOrder{
+		ID:           id,
+		ProductID:    "product-1",
+		ProductName:  "Gloves",
+		Quantity:     10,
+		SupplierID:   supplierID,
+		SupplierName: "Acme",
+		Location:     "warehouse-1",
+		Status:       models.StatusPending,
+		UrgencyLevel: "high",
+		CreatedAt:    createdAt,
+		UpdatedAt:    createdAt,
+		ExpectedDate: &expected,
+		Metadata:     map[string]interface{}{"top_up": true},
+	}
+}
+
+func TestGetPurchaseOrder(t *testing.T) {
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	put(t, dynamoDB, "orden-compra-read", newOrder("po-1", "supplier-1"))
+	client := newClient(t, dynamoDB)
+
+	response, err := client.GetPurchaseOrder(context.Background(), &ordencomprav1.GetPurchaseOrderRequest{Id: "po-1"})
+	if err != nil {
+		t.Fatalf("GetPurchaseOrder: %v", err)
+	}
+	got := response.GetPurchaseOrder()
+	if got.GetId() != "po-1" || got.GetQuantity() != 10 || got.GetSupplierId() != "supplier-1" || got.GetStatus() != models.StatusPending {
+		t.Fatalf("returned %v", got)
+	}
+	if !got.GetCreatedAt().AsTime().Equal(createdAt) || !got.GetExpectedDate().AsTime().Equal(createdAt.Add(72*time.Hour)) || got.GetActualDate() != nil {
+		t.Fatalf("returned dates created %v, expected %v, actual %v", got.GetCreatedAt(), got.GetExpectedDate(), got.GetActualDate())
+	}
+	var metadata map[string]interface{}
+	if err := json.Unmarshal(got.GetMetadata(), &metadata); err != nil || metadata["top_up"] != true {
+		t.Fatalf("returned metadata %s, error %v", got.GetMetadata(), err)
+	}
+
+	_, err = client.GetPurchaseOrder(context.Background(), &ordencomprav1.GetPurchaseOrderRequest{Id: "po-2"})
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("missing order returned %v, want NOT_FOUND", err)
+	}
+	_, err = client.GetPurchaseOrder(context.Background(), &ordencomprav1.GetPurchaseOrderRequest{})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("request without id returned %v, want INVALID_ARGUMENT", err)
+	}
+}
+
+func TestListPurchaseOrders(t *testing.T) {
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	put(t, dynamoDB, "orden-compra-read", newOrder("po-1", "supplier-1"))
+	put(t, dynamoDB, "orden-compra-read", newOrder("po-2", "supplier-2"))
+	client := newClient(t, dynamoDB)
+
+	response, err := client.ListPurchaseOrders(context.Background(), &ordencomprav1.ListPurchaseOrdersRequest{
+		SupplierId: "supplier-2",
+		StartDate:  timestamppb.New(createdAt.Add(-time.Hour)),
+		EndDate:    timestamppb.New(createdAt.Add(time.Hour)),
+	})
+	if err != nil {
+		t.Fatalf("ListPurchaseOrders: %v", err)
+	}
+	if orders := response.GetPurchaseOrders(); len(orders) != 1 || orders[0].GetId() != "po-2" {
+		t.Fatalf("returned %v, want po-2", orders)
+	}
+}
+
+func TestGetOrderEvents(t *testing.T) {
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	correlationID := "correlation-1"
+	created := models.NewEventSourcingEvent("po-1", "PurchaseOrderCreated", map[string]interface{}{"quantity": 10}, &correlationID, nil, createdAt)
+	created.Version = 1
+	put(t, dynamoDB, "orden-compra-events", created)
+	put(t, dynamoDB, "orden-compra-events", models.NewEventSourcingEvent("po-2", "PurchaseOrderCreated", nil, nil, nil, createdAt))
+	client := newClient(t, dynamoDB)
+
+	response, err := client.GetOrderEvents(context.Background(), &ordencomprav1.GetOrderEventsRequest{PurchaseOrderId: "po-1"})
+	if err != nil {
+		t.Fatalf("GetOrderEvents: %v", err)
+	}
+	events := response.GetEvents()
+	if len(events) != 1 {
+		t.Fatalf("returned %d events, want the one of po-1", len(events))
+	}
+	got := events[0]
+	if got.GetId() != created.ID || got.GetEventType() != "PurchaseOrderCreated" || got.GetVersion() != 1 || got.GetCorrelationId() != correlationID || got.GetCausationId() != "" {
+		t.Fatalf("returned %v", got)
+	}
+	if string(got.GetEventData()) != `{"quantity":10}` || !got.GetTimestamp().AsTime().Equal(createdAt) {
+		t.Fatalf("returned event data %s at %v", got.GetEventData(), got.GetTimestamp())
+	}
+
+	_, err = client.GetOrderEvents(context.Background(), &ordencomprav1.GetOrderEventsRequest{})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("request without purchase_order_id returned %v, want INVALID_ARGUMENT", err)
+	}
+}
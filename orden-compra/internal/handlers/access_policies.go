@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"orden-compra/internal/audit"
+	"orden-compra/internal/auth"
+	"orden-compra/internal/models"
+)
+
+// AccessPolicyHandler manages the role overrides of API callers
+type AccessPolicyHandler struct {
+	Store      *auth.PolicyStore
+	Authorizer *auth.Authorizer
+	Audit      *audit.Recorder
+	Logger     *log.Logger
+}
+
+// NewAccessPolicyHandler creates a new access policy handler
+func NewAccessPolicyHandler(store *auth.PolicyStore, authorizer *auth.Authorizer, auditRecorder *audit.Recorder, logger *log.Logger) *AccessPolicyHandler {
+	return &AccessPolicyHandler{
+		Store:      store,
+		Authorizer: authorizer,
+		Audit:      auditRecorder,
+		Logger:     logger,
+	}
+}
+
+// WhoAmI returns the authenticated caller and its effective roles
+func (h *AccessPolicyHandler) WhoAmI(ctx context.Context) (map[string]interface{}, error) {
+	principal, ok := auth.FromContext(ctx)
+	if !ok {
+		return map[string]interface{}{
+			"success":       true,
+			"authenticated": false,
+		}, nil
+	}
+
+	roles, err := h.Authorizer.Roles(ctx, principal)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"success":       true,
+		"authenticated": true,
+		"subject":       principal.Subject,
+		"method":        principal.Method,
+		"roles":         roles,
+	}, nil
+}
+
+// SetPolicy replaces the roles of a subject
+func (h *AccessPolicyHandler) SetPolicy(ctx context.Context, subject string, roles []string) (map[string]interface{}, error) {
+	for _, role := range roles {
+		if !models.IsValidRole(role) {
+			return nil, fmt.Errorf("%w: unknown role %q", auth.ErrInvalidPolicy, role)
+		}
+	}
+
+	var updatedBy string
+	if principal, ok := auth.FromContext(ctx); ok {
+		updatedBy = principal.Subject
+	}
+
+	before, err := h.Store.Get(ctx, subject)
+	if err != nil && !errors.Is(err, auth.ErrPolicyNotFound) {
+		return nil, err
+	}
+
+	policy := models.NewAccessPolicy(subject, roles, updatedBy, time.Now())
+	if err := h.Store.Save(ctx, policy); err != nil {
+		return nil, err
+	}
+	h.Authorizer.Invalidate(subject)
+	h.Audit.Record(ctx, models.AuditAccessPolicyUpdated, models.AuditResourceAccessPolicy, subject, before, policy, nil)
+
+	h.Logger.Printf("Access policy updated - subject: %s, roles: %v, updated_by: %s", subject, roles, updatedBy)
+
+	return map[string]interface{}{
+		"success": true,
+		"policy":  policy,
+	}, nil
+}
+
+// ListPolicies returns every role override
+func (h *AccessPolicyHandler) ListPolicies(ctx context.Context) (map[string]interface{}, error) {
+	policies, err := h.Store.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"success":  true,
+		"policies": policies,
+		"count":    len(policies),
+	}, nil
+}
+
+// GetPolicy returns the role override of a subject
+func (h *AccessPolicyHandler) GetPolicy(ctx context.Context, subject string) (map[string]interface{}, error) {
+	policy, err := h.Store.Get(ctx, subject)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"success": true,
+		"policy":  policy,
+	}, nil
+}
+
+// DeletePolicy removes the role override of a subject so its token roles apply again
+func (h *AccessPolicyHandler) DeletePolicy(ctx context.Context, subject string) (map[string]interface{}, error) {
+	before, err := h.Store.Get(ctx, subject)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := h.Store.Delete(ctx, subject); err != nil {
+		return nil, err
+	}
+	h.Authorizer.Invalidate(subject)
+	h.Audit.Record(ctx, models.AuditAccessPolicyDeleted, models.AuditResourceAccessPolicy, subject, before, nil, nil)
+
+	h.Logger.Printf("Access policy deleted - subject: %s", subject)
+
+	return map[string]interface{}{
+		"success": true,
+		"subject": subject,
+	}, nil
+}
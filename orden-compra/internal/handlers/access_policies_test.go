@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"testing"
+
+	"orden-compra/internal/auth"
+	"orden-compra/internal/memory"
+	"orden-compra/internal/models"
+)
+
+func TestSetPolicyChangesTheEffectiveRoles(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	store := auth.NewPolicyStore(memory.NewDynamoDB(memory.Tables))
+	h := NewAccessPolicyHandler(store, auth.NewAuthorizer(store, logger), nil, logger)
+
+	admin := auth.NewContext(context.Background(), &auth.Principal{Subject: "admin-1", Roles: []string{models.RoleAdmin}})
+	client := auth.NewContext(context.Background(), &auth.Principal{Subject: "client-1", Roles: []string{models.RoleViewer}})
+
+	if _, err := h.SetPolicy(admin, "client-1", []string{"superuser"}); !errors.Is(err, auth.ErrInvalidPolicy) {
+		t.Fatalf("unknown role returned %v, want ErrInvalidPolicy", err)
+	}
+
+	// Look the roles up once so the authorizer caches the token roles
+	if result, err := h.WhoAmI(client); err != nil || result["roles"].([]string)[0] != models.RoleViewer {
+		t.Fatalf("whoami %v, error %v", result, err)
+	}
+
+	result, err := h.SetPolicy(admin, "client-1", []string{models.RoleBuyer})
+	if err != nil || result["policy"].(*models.AccessPolicy).UpdatedBy != "admin-1" {
+		t.Fatalf("set policy %v, error %v", result, err)
+	}
+	if result, err := h.WhoAmI(client); err != nil || result["roles"].([]string)[0] != models.RoleBuyer {
+		t.Fatalf("whoami after the override %v, error %v", result, err)
+	}
+
+	if _, err := h.DeletePolicy(admin, "client-1"); err != nil {
+		t.Fatalf("delete policy: %v", err)
+	}
+	if result, err := h.WhoAmI(client); err != nil || result["roles"].([]string)[0] != models.RoleViewer {
+		t.Fatalf("whoami after deleting the override %v, error %v", result, err)
+	}
+
+	if result, err := h.WhoAmI(context.Background()); err != nil || result["authenticated"] != false {
+		t.Fatalf("anonymous whoami %v, error %v", result, err)
+	}
+}
@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"orden-compra/internal/archive"
+	"orden-compra/internal/audit"
+	"orden-compra/internal/models"
+)
+
+// ArchiveHandler restores events archived to S3 back into the event store
+type ArchiveHandler struct {
+	Archiver *archive.Archiver
+	Audit    *audit.Recorder
+	Logger   *log.Logger
+}
+
+// NewArchiveHandler creates a new archive handler; archiver is nil when archival is disabled
+func NewArchiveHandler(archiver *archive.Archiver, auditRecorder *audit.Recorder, logger *log.Logger) *ArchiveHandler {
+	return &ArchiveHandler{
+		Archiver: archiver,
+		Audit:    auditRecorder,
+		Logger:   logger,
+	}
+}
+
+// RestoreEvents restores the archived events between from and to so they
+// can be replayed. Restored events expire again after the restore TTL.
+func (h *ArchiveHandler) RestoreEvents(ctx context.Context, from, to time.Time) (map[string]interface{}, error) {
+	result, err := h.Archiver.Restore(ctx, from, to)
+	h.Audit.Record(ctx, models.AuditEventsRestored, models.AuditResourceEventArchive, from.Format(time.RFC3339)+"/"+to.Format(time.RFC3339), nil, result, err)
+	if err != nil {
+		h.Logger.Printf("Failed to restore archived events: %v", err)
+		return nil, err
+	}
+	return result, nil
+}
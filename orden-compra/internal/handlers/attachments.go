@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"context"
+	"log"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/google/uuid"
+
+	"medisupply/correlation"
+	"orden-compra/internal/attachments"
+	"orden-compra/internal/audit"
+	"orden-compra/internal/auth"
+	"orden-compra/internal/cqrs"
+	"orden-compra/internal/models"
+	"orden-compra/internal/tenant"
+)
+
+// AttachmentHandler handles the comments and file attachments buyers add to
+// purchase orders
+type AttachmentHandler struct {
+	DynamoDB dynamodbiface.DynamoDBAPI
+	Stats    cqrs.StatsProjection
+	Store    *attachments.Store
+	Audit    *audit.Recorder
+	Logger   *log.Logger
+}
+
+// NewAttachmentHandler creates a new attachment handler; store is nil when
+// file attachments are disabled, which leaves comments working
+func NewAttachmentHandler(dynamoDB dynamodbiface.DynamoDBAPI, store *attachments.Store, auditRecorder *audit.Recorder, logger *log.Logger) *AttachmentHandler {
+	return &AttachmentHandler{
+		DynamoDB: dynamoDB,
+		Store:    store,
+		Audit:    auditRecorder,
+		Logger:   logger,
+	}
+}
+
+// AddComment adds a comment to a purchase order on behalf of the caller
+func (h *AttachmentHandler) AddComment(ctx context.Context, purchaseOrderID string, comment *models.OrderComment) (map[string]interface{}, error) {
+	// The author is the caller, whatever the request says
+	comment.Author = ""
+	if principal, ok := auth.FromContext(ctx); ok {
+		comment.Author = principal.Subject
+	}
+
+	command := cqrs.NewAddPurchaseOrderCommentCommand(
+		purchaseOrderID,
+		comment,
+		h.DynamoDB,
+		h.Logger,
+		correlation.CorrelationID(ctx),
+		correlation.CausationID(ctx),
+	)
+	command.Stats = h.Stats
+
+	result, err := command.Execute(ctx)
+	h.Audit.Record(ctx, models.AuditPurchaseOrderCommented, models.AuditResourcePurchaseOrder, purchaseOrderID, nil, result["comment"], err)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// AddAttachment records a file attached to a purchase order on behalf of
+// the caller and returns the presigned URL to upload the file to. The
+// metadata is stored with the order before the upload happens.
+func (h *AttachmentHandler) AddAttachment(ctx context.Context, purchaseOrderID string, attachment *models.OrderAttachment) (map[string]interface{}, error) {
+	if h.Store == nil {
+		return nil, attachments.ErrNotConfigured
+	}
+
+	// The ID, key and uploader are the service's, whatever the request says
+	tenantID, _ := tenant.FromContext(ctx)
+	attachment.ID = uuid.New().String()
+	attachment.Key = h.Store.Key(tenantID, purchaseOrderID, attachment)
+	attachment.UploadedBy = ""
+	if principal, ok := auth.FromContext(ctx); ok {
+		attachment.UploadedBy = principal.Subject
+	}
+
+	command := cqrs.NewAddPurchaseOrderAttachmentCommand(
+		purchaseOrderID,
+		attachment,
+		h.DynamoDB,
+		h.Logger,
+		correlation.CorrelationID(ctx),
+		correlation.CausationID(ctx),
+	)
+	command.Stats = h.Stats
+
+	result, err := command.Execute(ctx)
+	h.Audit.Record(ctx, models.AuditPurchaseOrderAttached, models.AuditResourcePurchaseOrder, purchaseOrderID, nil, result["attachment"], err)
+	if err != nil {
+		return nil, err
+	}
+
+	upload, err := h.Store.PresignUpload(result["attachment"].(*models.OrderAttachment))
+	if err != nil {
+		h.Logger.Printf("Failed to presign attachment upload - purchase_order_id: %s, error: %v", purchaseOrderID, err)
+		return nil, err
+	}
+	result["upload"] = upload
+
+	return result, nil
+}
+
+// ListAttachments returns the comments and attachments of a purchase order,
+// each attachment with a presigned URL to download its file when
+// attachments are configured
+func (h *AttachmentHandler) ListAttachments(ctx context.Context, purchaseOrderID string) (map[string]interface{}, error) {
+	purchaseOrder, err := cqrs.FindPurchaseOrder(ctx, h.DynamoDB, purchaseOrderID, false)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]map[string]interface{}, 0, len(purchaseOrder.Attachments))
+	for i := range purchaseOrder.Attachments {
+		attachment := &purchaseOrder.Attachments[i]
+		file := map[string]interface{}{"attachment": attachment}
+		if h.Store != nil {
+			download, err := h.Store.PresignDownload(attachment)
+			if err != nil {
+				h.Logger.Printf("Failed to presign attachment download - purchase_order_id: %s, attachment_id: %s, error: %v", purchaseOrderID, attachment.ID, err)
+				return nil, err
+			}
+			file["download"] = download
+		}
+		files = append(files, file)
+	}
+
+	comments := purchaseOrder.Comments
+	if comments == nil {
+		comments = []models.OrderComment{}
+	}
+
+	return map[string]interface{}{
+		"success":           true,
+		"purchase_order_id": purchaseOrderID,
+		"comments":          comments,
+		"attachments":       files,
+	}, nil
+}
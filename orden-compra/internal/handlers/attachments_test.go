@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+
+	"orden-compra/internal/attachments"
+	"orden-compra/internal/audit"
+	"orden-compra/internal/auth"
+	"orden-compra/internal/cqrs"
+	"orden-compra/internal/memory"
+	"orden-compra/internal/models"
+	"orden-compra/internal/tenant"
+)
+
+// newAttachmentHandler returns a handler on an order po-1, presigning
+// against a local endpoint unless disabled
+func newAttachmentHandler(t *testing.T, disabled bool) (*AttachmentHandler, *audit.Store, string) {
+	t.Helper()
+	logger := log.New(io.Discard, "", 0)
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	order := models.NewPurchaseOrder("product-1", "Gloves", "supplier-1", "Acme", "warehouse-1", "high", 10, time.Now())
+	if _, err := cqrs.NewCreatePurchaseOrderCommand(order, dynamoDB, logger, nil, nil).Execute(context.Background()); err != nil {
+		t.Fatalf("create purchase order: %v", err)
+	}
+
+	var store *attachments.Store
+	if !disabled {
+		sess, err := session.NewSession(&aws.Config{
+			Region:           aws.String("us-east-1"),
+			Endpoint:         aws.String("http://s3.local"),
+			S3ForcePathStyle: aws.Bool(true),
+			Credentials:      credentials.NewStaticCredentials("key", "secret", ""),
+		})
+		if err != nil {
+			t.Fatalf("session: %v", err)
+		}
+		store = attachments.NewStore(s3.New(sess), attachments.Config{Bucket: "attachments", Prefix: "orden-compra/attachments", URLTTL: time.Minute})
+	}
+	auditStore := audit.NewStore(dynamoDB)
+	return NewAttachmentHandler(dynamoDB, store, audit.NewRecorder(auditStore, dynamoDB, logger), logger), auditStore, order.ID
+}
+
+func TestAddCommentAndAttachment(t *testing.T) {
+	h, auditStore, id := newAttachmentHandler(t, false)
+	ctx := tenant.NewContext(auth.NewContext(context.Background(), &auth.Principal{Subject: "jdoe"}), "tenant-1")
+
+	// The author and uploader are the caller, whatever the request says
+	if _, err := h.AddComment(ctx, id, &models.OrderComment{Text: "Call before delivering", Author: "someone else"}); err != nil {
+		t.Fatalf("add comment: %v", err)
+	}
+	result, err := h.AddAttachment(ctx, id, &models.OrderAttachment{ID: "chosen", Key: "elsewhere", UploadedBy: "someone else", FileName: "quote.pdf", ContentType: "application/pdf", Size: 2048})
+	if err != nil {
+		t.Fatalf("add attachment: %v", err)
+	}
+	attachment := result["attachment"].(*models.OrderAttachment)
+	if attachment.ID == "chosen" || attachment.Key != "orden-compra/attachments/tenant-1/"+id+"/"+attachment.ID+"/quote.pdf" || attachment.UploadedBy != "jdoe" {
+		t.Fatalf("attachment %+v", attachment)
+	}
+	if upload := result["upload"].(*attachments.PresignedURL); upload.Method != "PUT" || !strings.Contains(upload.URL, attachment.Key) {
+		t.Fatalf("upload %+v", upload)
+	}
+
+	listed, err := h.ListAttachments(context.Background(), id)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	comments := listed["comments"].([]models.OrderComment)
+	files := listed["attachments"].([]map[string]interface{})
+	if len(comments) != 1 || comments[0].Author != "jdoe" || len(files) != 1 || files[0]["download"].(*attachments.PresignedURL).Method != "GET" {
+		t.Fatalf("listed comments %+v and attachments %+v", comments, files)
+	}
+
+	for _, action := range []string{models.AuditPurchaseOrderCommented, models.AuditPurchaseOrderAttached} {
+		if entries, err := auditStore.Query(context.Background(), audit.Filter{ResourceID: id, Action: action}); err != nil || len(entries) != 1 {
+			t.Fatalf("audited %d %s entries, error %v", len(entries), action, err)
+		}
+	}
+}
+
+func TestAttachmentsDisabled(t *testing.T) {
+	h, _, id := newAttachmentHandler(t, true)
+
+	// Comments still work and orders list without download URLs
+	if _, err := h.AddComment(context.Background(), id, &models.OrderComment{Text: "note"}); err != nil {
+		t.Fatalf("add comment: %v", err)
+	}
+	if _, err := h.AddAttachment(context.Background(), id, &models.OrderAttachment{FileName: "quote.pdf", ContentType: "application/pdf", Size: 1}); !errors.Is(err, attachments.ErrNotConfigured) {
+		t.Fatalf("add attachment returned %v", err)
+	}
+	listed, err := h.ListAttachments(context.Background(), id)
+	if err != nil || len(listed["comments"].([]models.OrderComment)) != 1 || len(listed["attachments"].([]map[string]interface{})) != 0 {
+		t.Fatalf("listed %v, error %v", listed, err)
+	}
+	if _, err := h.ListAttachments(context.Background(), "po-9"); !errors.Is(err, cqrs.ErrPurchaseOrderNotFound) {
+		t.Fatalf("unknown order returned %v", err)
+	}
+}
@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"context"
+	"log"
+
+	"orden-compra/internal/audit"
+)
+
+// AuditHandler serves the audit log of state-changing operations
+type AuditHandler struct {
+	Store  *audit.Store
+	Logger *log.Logger
+}
+
+// NewAuditHandler creates a new audit handler
+func NewAuditHandler(store *audit.Store, logger *log.Logger) *AuditHandler {
+	return &AuditHandler{
+		Store:  store,
+		Logger: logger,
+	}
+}
+
+// QueryAuditLog returns the audit entries matching the filter, newest first
+func (h *AuditHandler) QueryAuditLog(ctx context.Context, filter audit.Filter) (map[string]interface{}, error) {
+	entries, err := h.Store.Query(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"success": true,
+		"entries": entries,
+		"count":   len(entries),
+	}, nil
+}
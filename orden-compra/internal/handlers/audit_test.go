@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"orden-compra/internal/audit"
+	"orden-compra/internal/cqrs"
+	"orden-compra/internal/memory"
+	"orden-compra/internal/models"
+)
+
+func TestCancelPurchaseOrderIsAudited(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	store := audit.NewStore(dynamoDB)
+	h := NewPurchaseOrderHandler(dynamoDB, newPublishingHandler(&recordingSender{}), nil, nil, audit.NewRecorder(store, dynamoDB, logger), logger)
+	auditLog := NewAuditHandler(store, logger)
+
+	purchaseOrder := models.NewPurchaseOrder("product-1", "Gloves", "supplier-1", "Acme", "warehouse-1", "HIGH", 10, time.Now())
+	purchaseOrder.Metadata["source"] = "stock_low_event"
+	if _, err := cqrs.NewCreatePurchaseOrderCommand(purchaseOrder, dynamoDB, logger, nil, nil).Execute(context.Background()); err != nil {
+		t.Fatalf("create purchase order: %v", err)
+	}
+
+	ctx := audit.WithOrigin(context.Background(), audit.Origin{ActorID: "buyer-1", ActorType: models.AuditActorUser, Source: "http"})
+	if _, err := h.CancelPurchaseOrder(ctx, purchaseOrder.ID, "no longer needed"); err != nil {
+		t.Fatalf("cancel purchase order: %v", err)
+	}
+	// A second cancellation fails and is audited as such
+	if _, err := h.CancelPurchaseOrder(ctx, purchaseOrder.ID, "no longer needed"); err == nil {
+		t.Fatal("cancelled a cancelled order")
+	}
+
+	result, err := auditLog.QueryAuditLog(context.Background(), audit.Filter{ResourceID: purchaseOrder.ID, Action: models.AuditPurchaseOrderCancelled})
+	if err != nil || result["count"] != 2 {
+		t.Fatalf("audit log %v, error %v, want both cancellations", result, err)
+	}
+	var succeeded, failed *models.AuditEntry
+	for _, entry := range result["entries"].([]*models.AuditEntry) {
+		if entry.Outcome == models.AuditOutcomeSuccess {
+			succeeded = entry
+		} else {
+			failed = entry
+		}
+	}
+	if succeeded == nil || succeeded.ActorID != "buyer-1" || succeeded.Before["status"] != purchaseOrder.Status || succeeded.After["status"] != models.StatusCancelled {
+		t.Fatalf("successful cancellation %+v", succeeded)
+	}
+	if failed == nil || failed.Error == "" {
+		t.Fatalf("failed cancellation %+v", failed)
+	}
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"medisupply/correlation"
+	"orden-compra/internal/cqrs"
+	"orden-compra/internal/models"
+	"orden-compra/internal/tenant"
+)
+
+// maxBulkStatusOrders bounds the orders of a single bulk status update
+const maxBulkStatusOrders = 100
+
+// bulkStatuses are the statuses orders can be moved to in bulk. Approval,
+// rejection and cancellation notify others and have their own commands.
+var bulkStatuses = map[string]bool{
+	models.StatusSent:      true,
+	models.StatusReceived:  true,
+	models.StatusCompleted: true,
+}
+
+// BulkStatusResult is the outcome of one order of a bulk status update
+type BulkStatusResult struct {
+	PurchaseOrderID string `json:"purchase_order_id"`
+	Success         bool   `json:"success"`
+	Status          string `json:"status,omitempty"`
+	PreviousStatus  string `json:"previous_status,omitempty"`
+	Error           string `json:"error,omitempty"`
+	ErrorCode       string `json:"error_code,omitempty"`
+}
+
+// BulkUpdateStatus moves each order to status, reporting the outcome of each
+// one. A failed update does not stop the others, and all of them share the
+// correlation ID of ctx. Duplicate IDs are updated once.
+func (h *PurchaseOrderHandler) BulkUpdateStatus(ctx context.Context, purchaseOrderIDs []string, status string) (map[string]interface{}, error) {
+	var validationErrors models.ValidationErrors
+	if !bulkStatuses[status] {
+		validationErrors = append(validationErrors, models.ValidationError{Field: "status", Message: fmt.Sprintf("must be one of %s, %s or %s, got %q", models.StatusSent, models.StatusReceived, models.StatusCompleted, status)})
+	}
+
+	seen := make(map[string]bool, len(purchaseOrderIDs))
+	ids := make([]string, 0, len(purchaseOrderIDs))
+	for _, id := range purchaseOrderIDs {
+		id = strings.TrimSpace(id)
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+	switch {
+	case len(ids) == 0:
+		validationErrors = append(validationErrors, models.ValidationError{Field: "purchase_order_ids", Message: "is required"})
+	case len(ids) > maxBulkStatusOrders:
+		validationErrors = append(validationErrors, models.ValidationError{Field: "purchase_order_ids", Message: fmt.Sprintf("must not list more than %d orders, got %d", maxBulkStatusOrders, len(ids))})
+	}
+	if len(validationErrors) > 0 {
+		return nil, validationErrors
+	}
+
+	h.Logger.Printf("Bulk status update - orders: %d, status: %s, correlation_id: %v", len(ids), status, correlation.CorrelationID(ctx))
+
+	results := make([]BulkStatusResult, 0, len(ids))
+	succeeded := 0
+	for _, id := range ids {
+		before := h.Audit.PurchaseOrder(ctx, id)
+
+		command := cqrs.NewUpdatePurchaseOrderStatusCommand(
+			id,
+			status,
+			h.DynamoDB,
+			h.Logger,
+			correlation.CorrelationID(ctx),
+			correlation.CausationID(ctx),
+		)
+		command.Stats = h.Stats
+
+		_, err := command.Execute(ctx)
+		after := h.Audit.PurchaseOrder(ctx, id)
+		h.Audit.Record(ctx, models.AuditPurchaseOrderStatusUpdated, models.AuditResourcePurchaseOrder, id, before, after, err)
+		if err != nil {
+			results = append(results, BulkStatusResult{
+				PurchaseOrderID: id,
+				Error:           err.Error(),
+				ErrorCode:       bulkErrorCode(err),
+			})
+			continue
+		}
+		succeeded++
+
+		result := BulkStatusResult{PurchaseOrderID: id, Success: true, Status: status}
+		if before != nil {
+			result.PreviousStatus = before.Status
+		}
+		results = append(results, result)
+
+		if status == models.StatusCompleted && after != nil {
+			h.Webhooks.Dispatch(ctx, models.WebhookPurchaseOrderCompleted, after)
+		}
+	}
+
+	h.Logger.Printf("Bulk status update finished - status: %s, succeeded: %d, failed: %d", status, succeeded, len(ids)-succeeded)
+
+	return map[string]interface{}{
+		"success":        succeeded == len(ids),
+		"status":         status,
+		"requested":      len(ids),
+		"succeeded":      succeeded,
+		"failed":         len(ids) - succeeded,
+		"results":        results,
+		"correlation_id": correlation.CorrelationID(ctx),
+	}, nil
+}
+
+// bulkErrorCode classifies the failure of one order of a bulk update
+func bulkErrorCode(err error) string {
+	switch {
+	case errors.Is(err, cqrs.ErrPurchaseOrderNotFound):
+		return "not_found"
+	case errors.Is(err, models.ErrInvalidStatusTransition):
+		return "invalid_transition"
+	case errors.Is(err, cqrs.ErrConflict):
+		return "conflict"
+	case errors.Is(err, tenant.ErrCrossTenantWrite):
+		return "forbidden"
+	default:
+		return "internal"
+	}
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+
+	"medisupply/correlation"
+	"orden-compra/internal/audit"
+	"orden-compra/internal/cqrs"
+	"orden-compra/internal/memory"
+	"orden-compra/internal/models"
+)
+
+func TestBulkUpdateStatusReportsEachOrder(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	store := audit.NewStore(dynamoDB)
+	h := NewPurchaseOrderHandler(dynamoDB, newPublishingHandler(&recordingSender{}), nil, nil, audit.NewRecorder(store, dynamoDB, logger), logger)
+
+	ids := make([]string, 0, 2)
+	for _, status := range []string{models.StatusApproved, models.StatusCompleted} {
+		purchaseOrder := models.NewPurchaseOrder("product-1", "Gloves", "supplier-1", "Acme", "warehouse-1", "HIGH", 10, time.Now())
+		purchaseOrder.Status = status
+		if _, err := cqrs.NewCreatePurchaseOrderCommand(purchaseOrder, dynamoDB, logger, nil, nil).Execute(context.Background()); err != nil {
+			t.Fatalf("create purchase order: %v", err)
+		}
+		ids = append(ids, purchaseOrder.ID)
+	}
+
+	// The duplicate and blank IDs are dropped; the failures do not stop the rest
+	ctx := correlation.NewContext(context.Background(), correlation.IDs{RequestID: "request-1", CorrelationID: "correlation-1"})
+	result, err := h.BulkUpdateStatus(ctx, []string{ids[0], ids[1], "missing", ids[0], " "}, models.StatusSent)
+	if err != nil {
+		t.Fatalf("bulk update: %v", err)
+	}
+	if result["success"] != false || result["requested"] != 3 || result["succeeded"] != 1 || result["failed"] != 2 {
+		t.Fatalf("result %v", result)
+	}
+	results := result["results"].([]BulkStatusResult)
+	for i, want := range []BulkStatusResult{
+		{PurchaseOrderID: ids[0], Success: true, Status: models.StatusSent, PreviousStatus: models.StatusApproved},
+		{PurchaseOrderID: ids[1], ErrorCode: "invalid_transition"},
+		{PurchaseOrderID: "missing", ErrorCode: "not_found"},
+	} {
+		got := results[i]
+		got.Error = ""
+		if got != want {
+			t.Fatalf("result %d is %+v, want %+v", i, results[i], want)
+		}
+	}
+
+	// Every update shares the correlation of the request and is audited
+	for _, event := range dynamoDB.Items("orden-compra-events") {
+		if aws.StringValue(event["event_type"].S) == "PurchaseOrderStatusUpdated" && aws.StringValue(event["correlation_id"].S) != "correlation-1" {
+			t.Fatalf("status update correlated as %q", aws.StringValue(event["correlation_id"].S))
+		}
+	}
+	entries, err := store.Query(context.Background(), audit.Filter{Action: models.AuditPurchaseOrderStatusUpdated})
+	if err != nil || len(entries) != 3 {
+		t.Fatalf("audited %d updates, error %v, want 3", len(entries), err)
+	}
+}
+
+func TestBulkUpdateStatusValidatesTheRequest(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	h := NewPurchaseOrderHandler(memory.NewDynamoDB(memory.Tables), nil, nil, nil, nil, logger)
+
+	tooMany := make([]string, maxBulkStatusOrders+1)
+	for i := range tooMany {
+		tooMany[i] = strings.Repeat("x", i+1)
+	}
+	for _, tc := range []struct {
+		name   string
+		ids    []string
+		status string
+		fields []string
+	}{
+		{"status with its own command", []string{"po-1"}, models.StatusCancelled, []string{"status"}},
+		{"no orders", []string{"", " "}, models.StatusSent, []string{"purchase_order_ids"}},
+		{"too many orders", tooMany, models.StatusSent, []string{"purchase_order_ids"}},
+		{"both", nil, models.StatusApproved, []string{"status", "purchase_order_ids"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := h.BulkUpdateStatus(context.Background(), tc.ids, tc.status)
+			var validationErrors models.ValidationErrors
+			if !errors.As(err, &validationErrors) || len(validationErrors) != len(tc.fields) {
+				t.Fatalf("error %v, want validation errors on %v", err, tc.fields)
+			}
+			for i, field := range tc.fields {
+				if validationErrors[i].Field != field {
+					t.Fatalf("validation errors %v, want %v", validationErrors, tc.fields)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"orden-compra/internal/cqrs"
+	"orden-compra/internal/models"
+)
+
+// BusinessMetricsConfig represents the business metrics settings
+type BusinessMetricsConfig struct {
+	Interval time.Duration
+	// Window bounds the creation days of the orders the receipt latency and
+	// quality failure rate are computed over, so they follow recent orders
+	Window time.Duration
+}
+
+// businessSnapshot holds the figures of the last refresh
+type businessSnapshot struct {
+	OpenByUrgency         map[string]int
+	Overdue               int
+	AverageReceiptLatency float64
+	QualityFailureRate    float64
+	ReceivedOrders        int
+	RefreshedAt           time.Time
+}
+
+// BusinessMetrics periodically reads the statistics projection and exports
+// business gauges for dashboards and alerts. It runs on the leader only, so
+// the gauges are exported once however many replicas run.
+type BusinessMetrics struct {
+	DynamoDB     dynamodbiface.DynamoDBAPI
+	ScanSegments int
+	Config       BusinessMetricsConfig
+	QueryLogger  *logrus.Logger
+	Logger       *log.Logger
+
+	mu   sync.Mutex
+	last *businessSnapshot
+}
+
+// NewBusinessMetrics creates the business metrics and registers their gauges
+func NewBusinessMetrics(dynamoDB dynamodbiface.DynamoDBAPI, config BusinessMetricsConfig, queryLogger *logrus.Logger, logger *log.Logger) *BusinessMetrics {
+	m := &BusinessMetrics{
+		DynamoDB:    dynamoDB,
+		Config:      config,
+		QueryLogger: queryLogger,
+		Logger:      logger,
+	}
+
+	meter := otel.Meter("orden-compra/business")
+	open, err1 := meter.Int64ObservableGauge(
+		"purchase_orders_open",
+		metric.WithDescription("Purchase orders not yet received, cancelled or rejected, by urgency"),
+	)
+	overdue, err2 := meter.Int64ObservableGauge(
+		"purchase_orders_overdue",
+		metric.WithDescription("Purchase orders reported overdue and still not received"),
+	)
+	latency, err3 := meter.Float64ObservableGauge(
+		"purchase_order_receipt_latency_average_seconds",
+		metric.WithDescription("Average time from creating a purchase order to receiving its inventory, over the orders created within the window"),
+		metric.WithUnit("s"),
+	)
+	qualityFailureRate, err4 := meter.Float64ObservableGauge(
+		"purchase_order_quality_failure_ratio",
+		metric.WithDescription("Share of the received orders created within the window whose receipt failed quality checks"),
+	)
+	received, err5 := meter.Int64ObservableGauge(
+		"purchase_orders_received_window",
+		metric.WithDescription("Received orders created within the window, the base of the latency and quality failure ratio"),
+	)
+	refreshed, err6 := meter.Float64ObservableGauge(
+		"business_metrics_last_refresh_timestamp_seconds",
+		metric.WithDescription("When the business metrics were last read from the statistics projection"),
+		metric.WithUnit("s"),
+	)
+	for _, err := range []error{err1, err2, err3, err4, err5, err6} {
+		if err != nil {
+			return m
+		}
+	}
+
+	_, _ = meter.RegisterCallback(func(ctx context.Context, o metric.Observer) error {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if m.last == nil {
+			return nil
+		}
+		for urgency, count := range m.last.OpenByUrgency {
+			o.ObserveInt64(open, int64(count), metric.WithAttributes(attribute.String("urgency", urgency)))
+		}
+		o.ObserveInt64(overdue, int64(m.last.Overdue))
+		o.ObserveFloat64(latency, m.last.AverageReceiptLatency)
+		o.ObserveFloat64(qualityFailureRate, m.last.QualityFailureRate)
+		o.ObserveInt64(received, int64(m.last.ReceivedOrders))
+		o.ObserveFloat64(refreshed, float64(m.last.RefreshedAt.UnixNano())/1e9)
+		return nil
+	}, open, overdue, latency, qualityFailureRate, received, refreshed)
+
+	return m
+}
+
+// Start refreshes the metrics now and every interval until the context is
+// cancelled; once it is, the gauges are no longer exported, so the replica
+// taking over as leader is the only one reporting them
+func (m *BusinessMetrics) Start(ctx context.Context) {
+	m.Logger.Printf("Starting business metrics - interval: %v, window: %v", m.Config.Interval, m.Config.Window)
+	defer func() {
+		m.mu.Lock()
+		m.last = nil
+		m.mu.Unlock()
+	}()
+
+	if err := m.Refresh(ctx); err != nil {
+		m.Logger.Printf("Business metrics refresh failed: %v", err)
+	}
+
+	ticker := time.NewTicker(m.Config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			m.Logger.Println("Business metrics stopped")
+			return
+		case <-ticker.C:
+			if err := m.Refresh(ctx); err != nil {
+				m.Logger.Printf("Business metrics refresh failed: %v", err)
+			}
+		}
+	}
+}
+
+// Refresh reads the statistics projection across tenants: the open and
+// overdue orders of all time, and the receipts of the orders created within
+// the window. On failure the previous figures are kept, and the refresh
+// timestamp shows how old they are.
+func (m *BusinessMetrics) Refresh(ctx context.Context) error {
+	all, err := cqrs.NewGetPurchaseOrderStatsQuery(m.DynamoDB, m.QueryLogger).WithScanSegments(m.ScanSegments).Execute(ctx)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	recent, err := cqrs.NewGetPurchaseOrderStatsQuery(m.DynamoDB, m.QueryLogger).WithScanSegments(m.ScanSegments).WithDateRange(now.Add(-m.Config.Window), now).Execute(ctx)
+	if err != nil {
+		return err
+	}
+
+	allStats, _ := all["stats"].(map[string]interface{})
+	recentStats, _ := recent["stats"].(map[string]interface{})
+	snapshot := &businessSnapshot{RefreshedAt: now, OpenByUrgency: make(map[string]int)}
+	// Every level is reported, so alerts see zero rather than no series
+	for urgency := range models.ValidUrgencyLevels {
+		snapshot.OpenByUrgency[urgency] = 0
+	}
+	openByUrgency, _ := allStats["open_by_urgency"].(map[string]int)
+	for urgency, count := range openByUrgency {
+		snapshot.OpenByUrgency[urgency] = count
+	}
+	snapshot.Overdue, _ = allStats["overdue_orders"].(int)
+	snapshot.AverageReceiptLatency, _ = recentStats["average_receipt_latency"].(float64)
+	snapshot.QualityFailureRate, _ = recentStats["quality_failure_rate"].(float64)
+	snapshot.ReceivedOrders, _ = recentStats["received_orders"].(int)
+
+	m.mu.Lock()
+	m.last = snapshot
+	m.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"medisupply/clock"
+	"orden-compra/internal/cqrs"
+	"orden-compra/internal/memory"
+	"orden-compra/internal/models"
+)
+
+func TestBusinessMetricsRefresh(t *testing.T) {
+	ctx := context.Background()
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	logger := log.New(io.Discard, "", 0)
+	created := time.Now().UTC().Add(-48 * time.Hour)
+	fake := clock.NewFake(created)
+
+	var orders []*models.PurchaseOrder
+	for _, urgency := range []string{"high", "high", "low"} {
+		purchaseOrder := models.NewPurchaseOrder("product-1", "Gloves", "supplier-1", "Acme", "warehouse-1", urgency, 10, created)
+		purchaseOrder.Status = models.StatusSent
+		create := cqrs.NewCreatePurchaseOrderCommand(purchaseOrder, dynamoDB, logger, nil, nil)
+		create.Clock = fake
+		if _, err := create.Execute(ctx); err != nil {
+			t.Fatalf("create purchase order: %v", err)
+		}
+		orders = append(orders, purchaseOrder)
+	}
+
+	// The low urgency order arrives a day later and fails its quality check
+	receivedAt := created.Add(24 * time.Hour)
+	fake.Set(receivedAt)
+	receive := cqrs.NewReceiveInventoryCommand(&models.InventoryReceivedEvent{
+		ID:              "received-1",
+		Timestamp:       receivedAt,
+		EventType:       models.SupplierEventType,
+		PurchaseOrderID: orders[2].ID,
+		ProductID:       "product-1",
+		ProductName:     "Gloves",
+		Quantity:        10,
+		SupplierID:      "supplier-1",
+		Location:        "warehouse-1",
+		Status:          "received",
+		ReceivedAt:      receivedAt,
+		QualityCheck:    "failed",
+	}, dynamoDB, logger, nil, nil)
+	receive.Clock = fake
+	if _, err := receive.Execute(ctx); err != nil {
+		t.Fatalf("receive inventory: %v", err)
+	}
+
+	quiet := &logrus.Logger{Out: io.Discard, Formatter: new(logrus.TextFormatter), Level: logrus.PanicLevel}
+	m := NewBusinessMetrics(dynamoDB, BusinessMetricsConfig{Interval: time.Minute, Window: 7 * 24 * time.Hour}, quiet, logger)
+	if err := m.Refresh(ctx); err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+
+	snapshot := m.last
+	if snapshot.OpenByUrgency["high"] != 2 || snapshot.OpenByUrgency["low"] != 0 || snapshot.OpenByUrgency["critical"] != 0 {
+		t.Fatalf("open by urgency %v, want two high and every level reported", snapshot.OpenByUrgency)
+	}
+	if snapshot.ReceivedOrders != 1 || snapshot.AverageReceiptLatency != (24*time.Hour).Seconds() || snapshot.QualityFailureRate != 1 {
+		t.Fatalf("snapshot %+v, want one receipt a day after its order that failed quality checks", snapshot)
+	}
+}
+
+func TestBusinessMetricsStopReporting(t *testing.T) {
+	m := NewBusinessMetrics(memory.NewDynamoDB(memory.Tables), BusinessMetricsConfig{Interval: time.Hour, Window: time.Hour},
+		&logrus.Logger{Out: io.Discard, Formatter: new(logrus.TextFormatter), Level: logrus.PanicLevel}, log.New(io.Discard, "", 0))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		m.Start(ctx)
+		close(done)
+	}()
+
+	// Start refreshes at once, then drops the figures when it stops
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		m.mu.Lock()
+		refreshed := m.last != nil
+		m.mu.Unlock()
+		if refreshed {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("business metrics were not refreshed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	<-done
+	if m.last != nil {
+		t.Fatalf("figures %+v kept after stopping", m.last)
+	}
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+
+	"orden-compra/internal/audit"
+	"orden-compra/internal/cqrs"
+	"orden-compra/internal/models"
+	"orden-compra/internal/tenant"
+)
+
+// ConsolidationScheduler periodically batches held-back purchase orders into
+// consolidated orders and releases them to Proveedor
+type ConsolidationScheduler struct {
+	DynamoDB  dynamodbiface.DynamoDBAPI
+	Stats     cqrs.StatsProjection
+	Publisher *RabbitMQHandler
+	Audit     *audit.Recorder
+	Window    time.Duration
+	Logger    *log.Logger
+}
+
+// NewConsolidationScheduler creates a new consolidation scheduler
+func NewConsolidationScheduler(dynamoDB dynamodbiface.DynamoDBAPI, publisher *RabbitMQHandler, auditRecorder *audit.Recorder, window time.Duration, logger *log.Logger) *ConsolidationScheduler {
+	return &ConsolidationScheduler{
+		DynamoDB:  dynamoDB,
+		Publisher: publisher,
+		Audit:     auditRecorder,
+		Window:    window,
+		Logger:    logger,
+	}
+}
+
+// Start runs a consolidation at the end of every window until the context is cancelled
+func (s *ConsolidationScheduler) Start(ctx context.Context) {
+	s.Logger.Printf("Starting consolidation scheduler - window: %v", s.Window)
+
+	ticker := time.NewTicker(s.Window)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			s.Logger.Println("Consolidation scheduler stopped")
+			return
+		case <-ticker.C:
+			if _, err := s.RunOnce(ctx); err != nil {
+				s.Logger.Printf("Consolidation run failed: %v", err)
+			}
+		}
+	}
+}
+
+// RunOnce consolidates the orders waiting right now and publishes their reception events
+func (s *ConsolidationScheduler) RunOnce(ctx context.Context) (map[string]interface{}, error) {
+	ctx = audit.WithOrigin(ctx, audit.SystemOrigin("consolidation-scheduler"))
+	command := cqrs.NewConsolidatePurchaseOrdersCommand(
+		time.Now().UTC(),
+		s.DynamoDB,
+		s.Logger,
+		nil,
+		nil,
+	)
+	command.Stats = s.Stats
+
+	result, err := command.Execute(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	consolidatedOrders, _ := result["consolidated_orders"].([]*models.ConsolidatedPurchaseOrder)
+	for _, consolidated := range consolidatedOrders {
+		s.Audit.Record(tenant.NewContext(ctx, consolidated.TenantID), models.AuditPurchaseOrdersConsolidated, models.AuditResourceConsolidatedPurchaseOrder, consolidated.ID, nil, consolidated, nil)
+	}
+
+	receptionEvents, _ := result["reception_events"].([]*models.RecepcionProveedorEvent)
+
+	failed := 0
+	for _, receptionEvent := range receptionEvents {
+		if err := s.Publisher.produceReceptionEvent(tenant.NewContext(ctx, receptionEvent.TenantID), receptionEvent); err != nil {
+			s.Logger.Printf("Failed to produce reception event for consolidated order %v: %v", receptionEvent.Metadata[models.MetadataConsolidatedOrderID], err)
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return result, fmt.Errorf("failed to publish %d of %d consolidated reception events", failed, len(receptionEvents))
+	}
+
+	return result, nil
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"context"
+	"log"
+
+	"orden-compra/internal/audit"
+	"orden-compra/internal/intake"
+	"orden-compra/internal/models"
+)
+
+// ConsumersHandler exposes the RabbitMQ consumers for throttling intake at
+// runtime. Changes apply to this replica until it restarts.
+type ConsumersHandler struct {
+	Registry *intake.Registry
+	Audit    *audit.Recorder
+	Logger   *log.Logger
+}
+
+// NewConsumersHandler creates a new consumers handler
+func NewConsumersHandler(registry *intake.Registry, auditRecorder *audit.Recorder, logger *log.Logger) *ConsumersHandler {
+	return &ConsumersHandler{
+		Registry: registry,
+		Audit:    auditRecorder,
+		Logger:   logger,
+	}
+}
+
+// GetConsumers returns the current state of every consumer
+func (h *ConsumersHandler) GetConsumers() map[string]interface{} {
+	return map[string]interface{}{
+		"success":   true,
+		"consumers": h.Registry.Stats(),
+	}
+}
+
+// UpdateConsumer changes the prefetch count and in-flight limit of a consumer
+func (h *ConsumersHandler) UpdateConsumer(ctx context.Context, name string, config intake.Config) (map[string]interface{}, error) {
+	consumer, err := h.Registry.Get(name)
+	if err != nil {
+		return nil, err
+	}
+
+	before := consumer.Stats()
+	if err := consumer.Configure(config); err != nil {
+		return nil, err
+	}
+	h.Audit.Record(ctx, models.AuditConsumerUpdated, models.AuditResourceConsumer, name, before, consumer.Stats(), nil)
+
+	h.Logger.Printf("Consumer intake updated - consumer: %s, prefetch: %d, max_in_flight: %d", name, config.Prefetch, config.MaxInFlight)
+
+	return map[string]interface{}{
+		"success":  true,
+		"consumer": consumer.Stats(),
+	}, nil
+}
+
+// PauseConsumer stops a consumer from taking messages in
+func (h *ConsumersHandler) PauseConsumer(ctx context.Context, name, reason string) (map[string]interface{}, error) {
+	consumer, err := h.Registry.Get(name)
+	if err != nil {
+		return nil, err
+	}
+
+	before := consumer.Stats()
+	if err := consumer.Pause(reason); err != nil {
+		return nil, err
+	}
+	if !before.Paused {
+		h.Audit.Record(ctx, models.AuditConsumerPaused, models.AuditResourceConsumer, name, before, consumer.Stats(), nil)
+	}
+
+	return map[string]interface{}{
+		"success":  true,
+		"consumer": consumer.Stats(),
+	}, nil
+}
+
+// ResumeConsumer lets a paused consumer take messages in again
+func (h *ConsumersHandler) ResumeConsumer(ctx context.Context, name string) (map[string]interface{}, error) {
+	consumer, err := h.Registry.Get(name)
+	if err != nil {
+		return nil, err
+	}
+
+	before := consumer.Stats()
+	if err := consumer.Resume(); err != nil {
+		return nil, err
+	}
+	if before.Paused {
+		h.Audit.Record(ctx, models.AuditConsumerResumed, models.AuditResourceConsumer, name, before, consumer.Stats(), nil)
+	}
+
+	return map[string]interface{}{
+		"success":  true,
+		"consumer": consumer.Stats(),
+	}, nil
+}
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"orden-compra/internal/audit"
+	"orden-compra/internal/deadletter"
+	"orden-compra/internal/models"
+	"orden-compra/internal/tenant"
+)
+
+// maxDeadLetterBatch bounds the records of a single replay or discard
+const maxDeadLetterBatch = 100
+
+// DeadLetterResult is the outcome of replaying or discarding one record
+type DeadLetterResult struct {
+	ID        string `json:"id"`
+	Success   bool   `json:"success"`
+	Error     string `json:"error,omitempty"`
+	ErrorCode string `json:"error_code,omitempty"`
+}
+
+// DeadLetterHandler lets operators inspect the messages rejected to the dead
+// letter queue and replay or discard them
+type DeadLetterHandler struct {
+	Store    *deadletter.Store
+	Messages *RabbitMQHandler
+	Audit    *audit.Recorder
+	Logger   *log.Logger
+}
+
+// NewDeadLetterHandler creates a new dead letter handler
+func NewDeadLetterHandler(store *deadletter.Store, messages *RabbitMQHandler, auditRecorder *audit.Recorder, logger *log.Logger) *DeadLetterHandler {
+	return &DeadLetterHandler{
+		Store:    store,
+		Messages: messages,
+		Audit:    auditRecorder,
+		Logger:   logger,
+	}
+}
+
+// ListDeadLetters returns the records matching the filter with their
+// rejection reason and errors, newest first
+func (h *DeadLetterHandler) ListDeadLetters(ctx context.Context, filter deadletter.Filter) (map[string]interface{}, error) {
+	records, err := h.Store.Query(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"success":       true,
+		"failed_events": records,
+		"count":         len(records),
+	}, nil
+}
+
+// GetDeadLetter returns a record with its payload and headers
+func (h *DeadLetterHandler) GetDeadLetter(ctx context.Context, id string) (map[string]interface{}, error) {
+	record, err := h.Store.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"success":      true,
+		"failed_event": record,
+	}, nil
+}
+
+// ReplayDeadLetters publishes each failed record again onto the queue it was
+// consumed from. A message that fails again is recorded as a new dead letter.
+func (h *DeadLetterHandler) ReplayDeadLetters(ctx context.Context, ids []string) (map[string]interface{}, error) {
+	return h.resolve(ctx, ids, "replayed", h.replay)
+}
+
+// DiscardDeadLetters deletes each record; the audit log keeps its last state
+func (h *DeadLetterHandler) DiscardDeadLetters(ctx context.Context, ids []string) (map[string]interface{}, error) {
+	return h.resolve(ctx, ids, "discarded", h.discard)
+}
+
+// replay publishes a failed record again and marks it replayed
+func (h *DeadLetterHandler) replay(ctx context.Context, id string) error {
+	before, err := h.Store.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if before.Status != models.DeadLetterFailed {
+		return fmt.Errorf("%w: already %s", deadletter.ErrNotReplayable, before.Status)
+	}
+	if before.Queue == "" {
+		return fmt.Errorf("%w: the queue it was consumed from was not recorded", deadletter.ErrNotReplayable)
+	}
+
+	err = h.Messages.ReplayDeadLetter(ctx, before)
+	if err == nil {
+		err = h.Store.MarkReplayed(ctx, id, time.Now())
+	}
+
+	var after *models.DeadLetterRecord
+	if err == nil {
+		after, _ = h.Store.Get(ctx, id)
+	}
+	h.Audit.Record(ctx, models.AuditDeadLetterReplayed, models.AuditResourceDeadLetter, id, before, after, err)
+	return err
+}
+
+// discard deletes a record
+func (h *DeadLetterHandler) discard(ctx context.Context, id string) error {
+	before, err := h.Store.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	err = h.Store.Delete(ctx, id)
+	h.Audit.Record(ctx, models.AuditDeadLetterDiscarded, models.AuditResourceDeadLetter, id, before, nil, err)
+	return err
+}
+
+// resolve applies an operation to each record, reporting the outcome of each
+// one. A failed record does not stop the others. Duplicate IDs are resolved once.
+func (h *DeadLetterHandler) resolve(ctx context.Context, ids []string, outcome string, operation func(context.Context, string) error) (map[string]interface{}, error) {
+	seen := make(map[string]bool, len(ids))
+	unique := make([]string, 0, len(ids))
+	for _, id := range ids {
+		id = strings.TrimSpace(id)
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		unique = append(unique, id)
+	}
+	switch {
+	case len(unique) == 0:
+		return nil, models.ValidationErrors{{Field: "ids", Message: "is required"}}
+	case len(unique) > maxDeadLetterBatch:
+		return nil, models.ValidationErrors{{Field: "ids", Message: fmt.Sprintf("must not list more than %d records, got %d", maxDeadLetterBatch, len(unique))}}
+	}
+
+	results := make([]DeadLetterResult, 0, len(unique))
+	succeeded := 0
+	for _, id := range unique {
+		if err := operation(ctx, id); err != nil {
+			results = append(results, DeadLetterResult{
+				ID:        id,
+				Error:     err.Error(),
+				ErrorCode: deadLetterErrorCode(err),
+			})
+			continue
+		}
+		succeeded++
+		results = append(results, DeadLetterResult{ID: id, Success: true})
+	}
+
+	h.Logger.Printf("Dead letters %s - succeeded: %d, failed: %d", outcome, succeeded, len(unique)-succeeded)
+
+	return map[string]interface{}{
+		"success":   succeeded == len(unique),
+		"requested": len(unique),
+		"succeeded": succeeded,
+		"failed":    len(unique) - succeeded,
+		"results":   results,
+	}, nil
+}
+
+// deadLetterErrorCode classifies the failure of one record of a replay or discard
+func deadLetterErrorCode(err error) string {
+	switch {
+	case errors.Is(err, deadletter.ErrDeadLetterNotFound):
+		return "not_found"
+	case errors.Is(err, deadletter.ErrNotReplayable):
+		return "not_replayable"
+	case errors.Is(err, tenant.ErrCrossTenantWrite):
+		return "forbidden"
+	default:
+		return "internal"
+	}
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+
+	"orden-compra/internal/audit"
+	"orden-compra/internal/deadletter"
+	"orden-compra/internal/delay"
+	"orden-compra/internal/memory"
+	"orden-compra/internal/models"
+)
+
+func newDeadLetterHandler(t *testing.T, sender *recordingSender, records ...*models.DeadLetterRecord) (*DeadLetterHandler, *audit.Store) {
+	t.Helper()
+	logger := log.New(io.Discard, "", 0)
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	for _, record := range records {
+		item, err := dynamodbattribute.MarshalMap(record)
+		if err != nil {
+			t.Fatalf("marshal record: %v", err)
+		}
+		if _, err := dynamoDB.PutItemWithContext(context.Background(), &dynamodb.PutItemInput{TableName: aws.String("orden-compra-dead-letters"), Item: item}); err != nil {
+			t.Fatalf("put record: %v", err)
+		}
+	}
+	auditStore := audit.NewStore(dynamoDB)
+	return NewDeadLetterHandler(deadletter.NewStore(dynamoDB), newPublishingHandler(sender), audit.NewRecorder(auditStore, dynamoDB, logger), logger), auditStore
+}
+
+func deadLetter(id, queue, status string) *models.DeadLetterRecord {
+	headers := map[string]interface{}{"correlation-id": "correlation-1", delay.RetryAttemptHeader: "3"}
+	record := models.NewDeadLetterRecord("message-"+id, queue, "stock.bajo", "application/json", "validation_failed", nil, []byte(`{"id":"stock-low-1"}`), headers, "correlation-1", time.Now())
+	record.ID = id
+	record.Status = status
+	return record
+}
+
+func TestReplayDeadLetters(t *testing.T) {
+	sender := &recordingSender{}
+	h, auditStore := newDeadLetterHandler(t, sender,
+		deadLetter("dl-1", "stock-bajo-queue", models.DeadLetterFailed),
+		deadLetter("dl-2", "stock-bajo-queue", models.DeadLetterReplayed),
+		deadLetter("dl-3", "", models.DeadLetterFailed),
+	)
+
+	result, err := h.ReplayDeadLetters(context.Background(), []string{"dl-1", " dl-1 ", "dl-2", "dl-3", "dl-missing", ""})
+	if err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	if result["success"] != false || result["requested"] != 4 || result["succeeded"] != 1 || result["failed"] != 3 {
+		t.Fatalf("result %v", result)
+	}
+	codes := make(map[string]string)
+	for _, r := range result["results"].([]DeadLetterResult) {
+		codes[r.ID] = r.ErrorCode
+	}
+	if codes["dl-1"] != "" || codes["dl-2"] != "not_replayable" || codes["dl-3"] != "not_replayable" || codes["dl-missing"] != "not_found" {
+		t.Fatalf("error codes %v", codes)
+	}
+
+	// The message goes back to the queue it was consumed from, with a fresh retry count
+	if len(sender.published) != 1 {
+		t.Fatalf("published %d messages, want 1", len(sender.published))
+	}
+	published := sender.published[0]
+	if published.exchange != "" || published.routingKey != "stock-bajo-queue" || string(published.msg.Body) != `{"id":"stock-low-1"}` || published.msg.MessageId != "message-dl-1" || published.msg.ContentType != "application/json" {
+		t.Fatalf("published %s/%s %+v", published.exchange, published.routingKey, published.msg)
+	}
+	headers := published.msg.Headers
+	if headers[ReplayedDeadLetterHeader] != "dl-1" || headers["correlation-id"] != "correlation-1" || headers[delay.RetryAttemptHeader] != nil {
+		t.Fatalf("headers %v", headers)
+	}
+
+	replayed, err := h.GetDeadLetter(context.Background(), "dl-1")
+	if record := replayed["failed_event"].(*models.DeadLetterRecord); err != nil || record.Status != models.DeadLetterReplayed {
+		t.Fatalf("record %+v, error %v", record, err)
+	}
+	entries, err := auditStore.Query(context.Background(), audit.Filter{ResourceID: "dl-1", Action: models.AuditDeadLetterReplayed})
+	if err != nil || len(entries) != 1 || entries[0].Outcome == models.AuditOutcomeFailure {
+		t.Fatalf("audit entries %+v, error %v", entries, err)
+	}
+}
+
+func TestReplayDeadLetterThatFailsToPublishStaysFailed(t *testing.T) {
+	h, _ := newDeadLetterHandler(t, &recordingSender{err: errors.New("broker unavailable")}, deadLetter("dl-1", "stock-bajo-queue", models.DeadLetterFailed))
+
+	result, err := h.ReplayDeadLetters(context.Background(), []string{"dl-1"})
+	if err != nil || result["succeeded"] != 0 {
+		t.Fatalf("result %v, error %v", result, err)
+	}
+	if r := result["results"].([]DeadLetterResult)[0]; r.ErrorCode != "internal" {
+		t.Fatalf("result %+v, want an internal error", r)
+	}
+	failed, _ := h.GetDeadLetter(context.Background(), "dl-1")
+	if record := failed["failed_event"].(*models.DeadLetterRecord); record.Status != models.DeadLetterFailed {
+		t.Fatalf("record is %s after a failed replay", record.Status)
+	}
+}
+
+func TestDiscardDeadLetters(t *testing.T) {
+	h, auditStore := newDeadLetterHandler(t, &recordingSender{}, deadLetter("dl-1", "stock-bajo-queue", models.DeadLetterFailed))
+
+	result, err := h.DiscardDeadLetters(context.Background(), []string{"dl-1", "dl-missing"})
+	if err != nil || result["succeeded"] != 1 || result["failed"] != 1 {
+		t.Fatalf("result %v, error %v", result, err)
+	}
+	if listed, err := h.ListDeadLetters(context.Background(), deadletter.Filter{}); err != nil || listed["count"] != 0 {
+		t.Fatalf("listed %v after discarding, error %v", listed, err)
+	}
+	// The audit log keeps the discarded record
+	entries, err := auditStore.Query(context.Background(), audit.Filter{ResourceID: "dl-1", Action: models.AuditDeadLetterDiscarded})
+	if err != nil || len(entries) != 1 || entries[0].Before == nil {
+		t.Fatalf("audit entries %+v, error %v", entries, err)
+	}
+}
+
+func TestResolveDeadLettersValidatesTheIDs(t *testing.T) {
+	h, _ := newDeadLetterHandler(t, &recordingSender{})
+	tooMany := make([]string, maxDeadLetterBatch+1)
+	for i := range tooMany {
+		tooMany[i] = "dl-" + strings.Repeat("x", i+1)
+	}
+	for _, ids := range [][]string{nil, {" ", ""}, tooMany} {
+		var errs models.ValidationErrors
+		if _, err := h.DiscardDeadLetters(context.Background(), ids); !errors.As(err, &errs) || errs[0].Field != "ids" {
+			t.Fatalf("discarding %d IDs returned %v", len(ids), err)
+		}
+	}
+}
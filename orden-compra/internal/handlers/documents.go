@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"context"
+	"log"
+
+	"orden-compra/internal/dispatch"
+	"orden-compra/internal/pdf"
+)
+
+// DocumentHandler renders printable purchase order documents
+type DocumentHandler struct {
+	Store  *dispatch.Store
+	Logger *log.Logger
+}
+
+// NewDocumentHandler creates a new document handler
+func NewDocumentHandler(store *dispatch.Store, logger *log.Logger) *DocumentHandler {
+	return &DocumentHandler{
+		Store:  store,
+		Logger: logger,
+	}
+}
+
+// PurchaseOrderPDF renders the purchase order with its supplier details as a PDF
+func (h *DocumentHandler) PurchaseOrderPDF(ctx context.Context, purchaseOrderID string) ([]byte, error) {
+	order, err := h.Store.GetOrder(ctx, purchaseOrderID)
+	if err != nil {
+		return nil, err
+	}
+
+	h.Logger.Printf("Rendering purchase order PDF - purchase_order_id: %s", purchaseOrderID)
+	return pdf.PurchaseOrder(order.PurchaseOrder, order.Supplier), nil
+}
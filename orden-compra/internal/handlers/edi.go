@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"orden-compra/internal/edi"
+)
+
+// EDIHandler ingests X12 documents received from suppliers: 855 purchase
+// order acknowledgements and 856 ship notices
+type EDIHandler struct {
+	Orders *PurchaseOrderHandler
+	Logger *log.Logger
+}
+
+// NewEDIHandler creates a new EDI handler
+func NewEDIHandler(orders *PurchaseOrderHandler, logger *log.Logger) *EDIHandler {
+	return &EDIHandler{
+		Orders: orders,
+		Logger: logger,
+	}
+}
+
+// IngestDocument parses an interchange and applies each transaction set to its
+// purchase order. A failing transaction set does not stop the others; the
+// result lists the outcome of each and success is false if any failed. Only
+// malformed interchanges are returned as errors.
+func (h *EDIHandler) IngestDocument(ctx context.Context, data []byte) (map[string]interface{}, error) {
+	document, err := edi.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	h.Logger.Printf("Ingesting EDI interchange - sender_id: %s, control_number: %s, transaction_sets: %d",
+		document.SenderID, document.ControlNumber, len(document.Sets))
+
+	results := make([]map[string]interface{}, 0, len(document.Sets))
+	success := true
+	record := func(set edi.TransactionSet, purchaseOrderID string, err error) {
+		result := map[string]interface{}{
+			"transaction_set":   set.ID,
+			"control_number":    set.ControlNumber,
+			"purchase_order_id": purchaseOrderID,
+			"success":           err == nil,
+		}
+		if err != nil {
+			success = false
+			result["error"] = err.Error()
+			h.Logger.Printf("Failed to apply EDI %s %s - purchase_order_id: %s, error: %v", set.ID, set.ControlNumber, purchaseOrderID, err)
+		}
+		results = append(results, result)
+	}
+
+	for _, set := range document.Sets {
+		switch set.ID {
+		case "855":
+			acknowledgement, err := edi.ParseAcknowledgement855(set)
+			if err != nil {
+				record(set, "", err)
+				continue
+			}
+			_, err = h.Orders.AcknowledgePurchaseOrder(ctx, acknowledgement.PurchaseOrderID, &acknowledgement.Acknowledgement)
+			record(set, acknowledgement.PurchaseOrderID, err)
+		case "856":
+			notices, err := edi.ParseShipNotice856(set)
+			if err != nil {
+				record(set, "", err)
+				continue
+			}
+			for _, notice := range notices {
+				_, err := h.Orders.AttachShipmentNotice(ctx, notice.PurchaseOrderID, &notice.Notice)
+				record(set, notice.PurchaseOrderID, err)
+			}
+		default:
+			record(set, "", fmt.Errorf("unsupported transaction set %s", set.ID))
+		}
+	}
+
+	return map[string]interface{}{
+		"success":        success,
+		"sender_id":      document.SenderID,
+		"control_number": document.ControlNumber,
+		"results":        results,
+	}, nil
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"context"
+	"log"
+
+	"orden-compra/internal/audit"
+	"orden-compra/internal/erp"
+	"orden-compra/internal/models"
+)
+
+// ERPHandler tracks the export of completed purchase orders to the ERP and
+// replays failed deliveries
+type ERPHandler struct {
+	Store    *erp.Store
+	Exporter *erp.Exporter
+	Audit    *audit.Recorder
+	Logger   *log.Logger
+}
+
+// NewERPHandler creates a new ERP handler. exporter is nil when ERP export
+// is disabled; earlier deliveries can still be listed.
+func NewERPHandler(store *erp.Store, exporter *erp.Exporter, auditRecorder *audit.Recorder, logger *log.Logger) *ERPHandler {
+	return &ERPHandler{
+		Store:    store,
+		Exporter: exporter,
+		Audit:    auditRecorder,
+		Logger:   logger,
+	}
+}
+
+// ListDeliveries returns the deliveries matching the filter, most recently
+// updated first
+func (h *ERPHandler) ListDeliveries(ctx context.Context, filter erp.Filter) (map[string]interface{}, error) {
+	deliveries, err := h.Store.Query(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"success":    true,
+		"deliveries": deliveries,
+		"count":      len(deliveries),
+	}, nil
+}
+
+// GetDelivery returns a delivery
+func (h *ERPHandler) GetDelivery(ctx context.Context, id string) (map[string]interface{}, error) {
+	delivery, err := h.Store.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"success":  true,
+		"delivery": delivery,
+	}, nil
+}
+
+// ReplayDelivery delivers a document to the ERP again in the background
+func (h *ERPHandler) ReplayDelivery(ctx context.Context, id string) (map[string]interface{}, error) {
+	before, _ := h.Store.Get(ctx, id)
+
+	delivery, err := h.Exporter.Replay(ctx, id)
+	h.Audit.Record(ctx, models.AuditERPDeliveryReplayed, models.AuditResourceERPDelivery, id, before, delivery, err)
+	if err != nil {
+		h.Logger.Printf("Failed to replay ERP delivery %s: %v", id, err)
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"success":  true,
+		"delivery": delivery,
+	}, nil
+}
+
+// ExportPurchaseOrder exports every document of a completed purchase order
+// to the ERP again in the background
+func (h *ERPHandler) ExportPurchaseOrder(ctx context.Context, purchaseOrderID string) (map[string]interface{}, error) {
+	deliveries, err := h.Exporter.ExportPurchaseOrder(ctx, purchaseOrderID)
+	h.Audit.Record(ctx, models.AuditPurchaseOrderERPExported, models.AuditResourcePurchaseOrder, purchaseOrderID, nil, deliveries, err)
+	if err != nil {
+		h.Logger.Printf("Failed to export purchase order %s to ERP: %v", purchaseOrderID, err)
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"success":           true,
+		"purchase_order_id": purchaseOrderID,
+		"deliveries":        deliveries,
+	}, nil
+}
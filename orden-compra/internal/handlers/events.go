@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/sirupsen/logrus"
+
+	"orden-compra/internal/cqrs"
+)
+
+// EventStreamConfig represents the event stream API settings
+type EventStreamConfig struct {
+	// Lag keeps the stream this far behind the present, so events stored
+	// late by other replicas are not skipped by readers already past them
+	Lag time.Duration
+	// PollInterval is how often a long poll reads the event store again
+	PollInterval time.Duration
+	// MaxWait caps how long a long poll may wait for events
+	MaxWait time.Duration
+}
+
+// EventStreamHandler serves the order event stream to readers that tail it
+// with a cursor
+type EventStreamHandler struct {
+	DynamoDB    dynamodbiface.DynamoDBAPI
+	Config      EventStreamConfig
+	QueryLogger *logrus.Logger
+	Logger      *log.Logger
+}
+
+// NewEventStreamHandler creates a new event stream handler
+func NewEventStreamHandler(dynamoDB dynamodbiface.DynamoDBAPI, config EventStreamConfig, queryLogger *logrus.Logger, logger *log.Logger) *EventStreamHandler {
+	return &EventStreamHandler{
+		DynamoDB:    dynamoDB,
+		Config:      config,
+		QueryLogger: queryLogger,
+		Logger:      logger,
+	}
+}
+
+// ListEvents returns up to limit events after the cursor. With a wait, a
+// request that finds no events reads again every poll interval until events
+// arrive, the wait (capped at MaxWait) is over or the caller goes away.
+func (h *EventStreamHandler) ListEvents(ctx context.Context, since string, limit int, wait time.Duration) (map[string]interface{}, error) {
+	after := cqrs.EventCursor{}
+	if since != "" {
+		parsed, err := cqrs.ParseEventCursor(since)
+		if err != nil {
+			return nil, err
+		}
+		after = parsed
+	}
+	if wait > h.Config.MaxWait {
+		wait = h.Config.MaxWait
+	}
+	deadline := time.Now().Add(wait)
+
+	for {
+		until := time.Now().UTC().Add(-h.Config.Lag)
+		result, err := cqrs.NewListEventsQuery(after, until, limit, h.DynamoDB, h.QueryLogger).Execute(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if count, _ := result["count"].(int); count > 0 || !time.Now().Add(h.Config.PollInterval).Before(deadline) {
+			return result, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return result, nil
+		case <-time.After(h.Config.PollInterval):
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/sirupsen/logrus"
+
+	"orden-compra/internal/cqrs"
+	"orden-compra/internal/memory"
+	"orden-compra/internal/models"
+)
+
+func newEventStreamHandler(t *testing.T, events ...time.Time) *EventStreamHandler {
+	t.Helper()
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	for _, at := range events {
+		item, err := dynamodbattribute.MarshalMap(models.NewEventSourcingEvent("po-1", "PurchaseOrderCreated", nil, nil, nil, at))
+		if err != nil {
+			t.Fatalf("marshal event: %v", err)
+		}
+		if _, err := dynamoDB.PutItemWithContext(context.Background(), &dynamodb.PutItemInput{TableName: aws.String("orden-compra-events"), Item: item}); err != nil {
+			t.Fatalf("put event: %v", err)
+		}
+	}
+	queryLogger := &logrus.Logger{Out: io.Discard, Formatter: new(logrus.TextFormatter), Level: logrus.PanicLevel}
+	config := EventStreamConfig{Lag: time.Second, PollInterval: 5 * time.Millisecond, MaxWait: 50 * time.Millisecond}
+	return NewEventStreamHandler(dynamoDB, config, queryLogger, log.New(io.Discard, "", 0))
+}
+
+func TestListEventsReturnsEventsPastTheLag(t *testing.T) {
+	now := time.Now().UTC()
+	h := newEventStreamHandler(t, now.Add(-time.Minute), now.Add(time.Minute))
+
+	// Events already stored are returned without waiting
+	start := time.Now()
+	result, err := h.ListEvents(context.Background(), "", 10, time.Hour)
+	if err != nil || result["count"] != 1 {
+		t.Fatalf("listed %v, error %v, want the event behind the lag", result, err)
+	}
+	if elapsed := time.Since(start); elapsed > h.Config.MaxWait {
+		t.Fatalf("listing stored events took %s", elapsed)
+	}
+}
+
+func TestListEventsLongPollsUpToMaxWait(t *testing.T) {
+	h := newEventStreamHandler(t)
+
+	start := time.Now()
+	result, err := h.ListEvents(context.Background(), "", 10, time.Hour)
+	if err != nil || result["count"] != 0 {
+		t.Fatalf("listed %v, error %v, want no events", result, err)
+	}
+	// The wait is capped at MaxWait
+	if elapsed := time.Since(start); elapsed < h.Config.MaxWait-h.Config.PollInterval || elapsed > time.Second {
+		t.Fatalf("long poll took %s, want about %s", elapsed, h.Config.MaxWait)
+	}
+
+	// The caller going away ends the poll
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	h.Config.MaxWait = time.Minute
+	if _, err := h.ListEvents(ctx, "", 10, time.Minute); err != nil {
+		t.Fatalf("poll ended by the caller returned %v", err)
+	}
+}
+
+func TestListEventsRejectsAnInvalidCursor(t *testing.T) {
+	h := newEventStreamHandler(t)
+	if _, err := h.ListEvents(context.Background(), "yesterday", 10, 0); !errors.Is(err, cqrs.ErrInvalidCursor) {
+		t.Fatalf("listing since an invalid cursor returned %v", err)
+	}
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/sirupsen/logrus"
+
+	"medisupply/export"
+	"orden-compra/internal/cqrs"
+	"orden-compra/internal/models"
+)
+
+// exportPageSize is the number of items read per scan page during exports
+const exportPageSize = 1000
+
+// purchaseOrderExportColumns is the header row of purchase order exports
+var purchaseOrderExportColumns = []string{
+	"id", "product_id", "product_name", "quantity", "supplier_id", "supplier_name", "location",
+	"status", "urgency_level", "created_at", "updated_at", "expected_date", "actual_date",
+	"acknowledgement_status", "dispatch_status", "carrier", "tracking_number",
+}
+
+// ExportHandler exports the read model for finance reconciliation
+type ExportHandler struct {
+	DynamoDB     dynamodbiface.DynamoDBAPI
+	ScanSegments int
+	QueryLogger  *logrus.Logger
+	Logger       *log.Logger
+}
+
+// NewExportHandler creates a new export handler
+func NewExportHandler(dynamoDB dynamodbiface.DynamoDBAPI, queryLogger *logrus.Logger, logger *log.Logger) *ExportHandler {
+	return &ExportHandler{
+		DynamoDB:    dynamoDB,
+		QueryLogger: queryLogger,
+		Logger:      logger,
+	}
+}
+
+// PurchaseOrdersQuery returns the list query to add export filters to
+func (h *ExportHandler) PurchaseOrdersQuery() *cqrs.ListPurchaseOrdersQuery {
+	return cqrs.NewListPurchaseOrdersQuery(h.DynamoDB, h.QueryLogger).WithLimit(exportPageSize).WithScanSegments(h.ScanSegments)
+}
+
+// ExportPurchaseOrders writes a header and one row per purchase order matching
+// the query, reading every page of the scan. It returns the number of orders
+// written; w is not closed.
+func (h *ExportHandler) ExportPurchaseOrders(ctx context.Context, query *cqrs.ListPurchaseOrdersQuery, w export.Writer) (int, error) {
+	if err := w.Write(purchaseOrderExportColumns); err != nil {
+		return 0, err
+	}
+
+	rows := 0
+	err := query.Each(ctx, func(purchaseOrder *models.PurchaseOrder) error {
+		rows++
+		return w.Write(purchaseOrderExportRow(purchaseOrder))
+	})
+	if err != nil {
+		h.Logger.Printf("Purchase order export failed after %d rows: %v", rows, err)
+		return rows, err
+	}
+
+	h.Logger.Printf("Purchase orders exported - rows: %d", rows)
+	return rows, nil
+}
+
+// purchaseOrderExportRow flattens a purchase order into the export columns
+func purchaseOrderExportRow(purchaseOrder *models.PurchaseOrder) []string {
+	var acknowledgementStatus, dispatchStatus, carrier, trackingNumber string
+	if purchaseOrder.Acknowledgement != nil {
+		acknowledgementStatus = purchaseOrder.Acknowledgement.Status
+	}
+	if purchaseOrder.Dispatch != nil {
+		dispatchStatus = purchaseOrder.Dispatch.Status
+	}
+	if purchaseOrder.ASN != nil {
+		carrier = purchaseOrder.ASN.Carrier
+		trackingNumber = purchaseOrder.ASN.TrackingNumber
+	}
+
+	return []string{
+		purchaseOrder.ID,
+		purchaseOrder.ProductID,
+		purchaseOrder.ProductName,
+		strconv.Itoa(purchaseOrder.Quantity),
+		purchaseOrder.SupplierID,
+		purchaseOrder.SupplierName,
+		purchaseOrder.Location,
+		purchaseOrder.Status,
+		purchaseOrder.UrgencyLevel,
+		formatExportTime(&purchaseOrder.CreatedAt),
+		formatExportTime(&purchaseOrder.UpdatedAt),
+		formatExportTime(purchaseOrder.ExpectedDate),
+		formatExportTime(purchaseOrder.ActualDate),
+		acknowledgementStatus,
+		dispatchStatus,
+		carrier,
+		trackingNumber,
+	}
+}
+
+// formatExportTime formats an optional timestamp as RFC 3339 in UTC
+func formatExportTime(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"medisupply/export"
+	"orden-compra/internal/cqrs"
+	"orden-compra/internal/memory"
+	"orden-compra/internal/models"
+)
+
+// failingWriter fails every write after the first rows
+type failingWriter struct {
+	rows int
+}
+
+func (w *failingWriter) Write(row []string) error {
+	if w.rows == 2 {
+		return errors.New("client went away")
+	}
+	w.rows++
+	return nil
+}
+
+func (w *failingWriter) Close() error {
+	return nil
+}
+
+func newExportHandler(t *testing.T, statuses ...string) *ExportHandler {
+	t.Helper()
+	logger := log.New(io.Discard, "", 0)
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	for _, status := range statuses {
+		purchaseOrder := models.NewPurchaseOrder("product-1", "Gloves", "supplier-1", "Acme", "warehouse-1", "HIGH", 10, time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+		purchaseOrder.Status = status
+		if _, err := cqrs.NewCreatePurchaseOrderCommand(purchaseOrder, dynamoDB, logger, nil, nil).Execute(context.Background()); err != nil {
+			t.Fatalf("create purchase order: %v", err)
+		}
+	}
+	return NewExportHandler(dynamoDB, &logrus.Logger{Out: io.Discard, Formatter: new(logrus.TextFormatter), Level: logrus.PanicLevel}, logger)
+}
+
+func TestExportPurchaseOrdersReadsEveryPage(t *testing.T) {
+	h := newExportHandler(t, models.StatusPending, models.StatusSent, models.StatusSent, models.StatusPending, models.StatusSent)
+
+	for _, tc := range []struct {
+		name  string
+		query *cqrs.ListPurchaseOrdersQuery
+		rows  int
+	}{
+		// Pages of two cover the five orders
+		{"everything", h.PurchaseOrdersQuery().WithLimit(2), 5},
+		{"filtered", h.PurchaseOrdersQuery().WithLimit(2).WithStatus(models.StatusSent), 3},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w, _ := export.NewWriter(&buf, export.CSV, "")
+			rows, err := h.ExportPurchaseOrders(context.Background(), tc.query, w)
+			if err != nil || rows != tc.rows {
+				t.Fatalf("exported %d rows, error %v, want %d", rows, err, tc.rows)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatalf("close: %v", err)
+			}
+
+			records, err := csv.NewReader(&buf).ReadAll()
+			if err != nil || len(records) != tc.rows+1 {
+				t.Fatalf("read %d records, error %v, want a header and %d rows", len(records), err, tc.rows)
+			}
+			if len(records[0]) != len(purchaseOrderExportColumns) || records[0][0] != "id" {
+				t.Fatalf("header %v", records[0])
+			}
+			if row := records[1]; row[3] != "10" || row[9] != "2024-03-01T12:00:00Z" || row[12] != "" {
+				t.Fatalf("row %v", row)
+			}
+		})
+	}
+}
+
+func TestExportPurchaseOrdersStopsAtTheFirstWriteError(t *testing.T) {
+	h := newExportHandler(t, models.StatusPending, models.StatusPending, models.StatusPending)
+
+	rows, err := h.ExportPurchaseOrders(context.Background(), h.PurchaseOrdersQuery(), &failingWriter{})
+	if err == nil || rows != 2 {
+		t.Fatalf("exported %d rows, error %v, want the failure on the second order", rows, err)
+	}
+}
+
+func TestPurchaseOrderExportRow(t *testing.T) {
+	purchaseOrder := &models.PurchaseOrder{
+		ID:              "po-1",
+		Quantity:        10,
+		Acknowledgement: &models.OrderAcknowledgement{Status: models.AcknowledgementAccepted},
+		Dispatch:        &models.DispatchStatus{Status: models.DispatchSent},
+		ASN:             &models.AdvanceShipmentNotice{Carrier: "DHL", TrackingNumber: "TRACK-1"},
+	}
+	row := purchaseOrderExportRow(purchaseOrder)
+	if len(row) != len(purchaseOrderExportColumns) {
+		t.Fatalf("row has %d cells for %d columns", len(row), len(purchaseOrderExportColumns))
+	}
+	if row[9] != "" || row[13] != models.AcknowledgementAccepted || row[14] != models.DispatchSent || row[15] != "DHL" || row[16] != "TRACK-1" {
+		t.Fatalf("row %v", row)
+	}
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"context"
+	"log"
+
+	"orden-compra/internal/flow"
+)
+
+// FlowHandler serves end-to-end timelines of correlation chains for support
+type FlowHandler struct {
+	Tracer *flow.Tracer
+	Logger *log.Logger
+}
+
+// NewFlowHandler creates a new flow handler
+func NewFlowHandler(tracer *flow.Tracer, logger *log.Logger) *FlowHandler {
+	return &FlowHandler{
+		Tracer: tracer,
+		Logger: logger,
+	}
+}
+
+// TraceFlow returns the timeline of a correlation chain across orden-compra
+// and Proveedor
+func (h *FlowHandler) TraceFlow(ctx context.Context, correlationID string) (map[string]interface{}, error) {
+	trace, err := h.Tracer.Trace(ctx, correlationID)
+	if err != nil {
+		h.Logger.Printf("Failed to trace flow - correlation_id: %s: %v", correlationID, err)
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"success": true,
+		"trace":   trace,
+	}, nil
+}
+
+// PurchaseOrderTimeline returns the history of a purchase order: its events,
+// Proveedor's reception events and its audit entries, oldest first
+func (h *FlowHandler) PurchaseOrderTimeline(ctx context.Context, purchaseOrderID string) (map[string]interface{}, error) {
+	timeline, err := h.Tracer.Timeline(ctx, purchaseOrderID)
+	if err != nil {
+		h.Logger.Printf("Failed to get purchase order timeline - purchase_order_id: %s: %v", purchaseOrderID, err)
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"success":  true,
+		"timeline": timeline,
+	}, nil
+}
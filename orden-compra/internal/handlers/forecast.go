@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/sirupsen/logrus"
+
+	"orden-compra/internal/audit"
+	"orden-compra/internal/cqrs"
+	"orden-compra/internal/models"
+	"orden-compra/internal/tenant"
+)
+
+// ForecastHandler suggests reorder points and quantities from the event history
+type ForecastHandler struct {
+	DynamoDB    dynamodbiface.DynamoDBAPI
+	Policy      models.ForecastPolicy
+	QueryLogger *logrus.Logger
+	Logger      *log.Logger
+}
+
+// NewForecastHandler creates a new forecast handler
+func NewForecastHandler(dynamoDB dynamodbiface.DynamoDBAPI, policy models.ForecastPolicy, queryLogger *logrus.Logger, logger *log.Logger) *ForecastHandler {
+	return &ForecastHandler{
+		DynamoDB:    dynamoDB,
+		Policy:      policy,
+		QueryLogger: queryLogger,
+		Logger:      logger,
+	}
+}
+
+// GetReorderSuggestions returns the suggestions of the caller's tenant,
+// optionally for one product
+func (h *ForecastHandler) GetReorderSuggestions(ctx context.Context, productID string) (map[string]interface{}, error) {
+	query := cqrs.NewGetReorderSuggestionsQuery(h.Policy, h.DynamoDB, h.QueryLogger)
+	if productID != "" {
+		query.WithProductID(productID)
+	}
+	return query.Execute(ctx)
+}
+
+// ReorderSuggestionApplier periodically turns the reorder suggestions of
+// every tenant into reorder policy overrides
+type ReorderSuggestionApplier struct {
+	DynamoDB    dynamodbiface.DynamoDBAPI
+	Audit       *audit.Recorder
+	Policy      models.ForecastPolicy
+	Interval    time.Duration
+	QueryLogger *logrus.Logger
+	Logger      *log.Logger
+}
+
+// NewReorderSuggestionApplier creates a new reorder suggestion applier
+func NewReorderSuggestionApplier(dynamoDB dynamodbiface.DynamoDBAPI, auditRecorder *audit.Recorder, policy models.ForecastPolicy, interval time.Duration, queryLogger *logrus.Logger, logger *log.Logger) *ReorderSuggestionApplier {
+	return &ReorderSuggestionApplier{
+		DynamoDB:    dynamoDB,
+		Audit:       auditRecorder,
+		Policy:      policy,
+		Interval:    interval,
+		QueryLogger: queryLogger,
+		Logger:      logger,
+	}
+}
+
+// Start applies the suggestions every interval until the context is cancelled
+func (a *ReorderSuggestionApplier) Start(ctx context.Context) {
+	a.Logger.Printf("Starting reorder suggestion applier - interval: %v, window: %v", a.Interval, a.Policy.Window)
+
+	ticker := time.NewTicker(a.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			a.Logger.Println("Reorder suggestion applier stopped")
+			return
+		case <-ticker.C:
+			if _, err := a.RunOnce(ctx); err != nil {
+				a.Logger.Printf("Applying reorder suggestions failed: %v", err)
+			}
+		}
+	}
+}
+
+// RunOnce computes the suggestions of every tenant and applies those with
+// enough history. Overrides set by hand are left alone.
+func (a *ReorderSuggestionApplier) RunOnce(ctx context.Context) (map[string]interface{}, error) {
+	ctx = audit.WithOrigin(ctx, audit.SystemOrigin("reorder-suggestion-applier"))
+
+	result, err := cqrs.NewGetReorderSuggestionsQuery(a.Policy, a.DynamoDB, a.QueryLogger).Execute(ctx)
+	if err != nil {
+		return nil, err
+	}
+	suggestions, _ := result["suggestions"].([]*models.ReorderSuggestion)
+
+	applied := 0
+	failed := 0
+	for _, suggestion := range suggestions {
+		if !suggestion.Sufficient {
+			continue
+		}
+
+		// The applier works across tenants; each override is written and audited under its own
+		tenantCtx := tenant.NewContext(ctx, suggestion.TenantID)
+		applyResult, err := cqrs.NewApplyReorderSuggestionCommand(suggestion, a.DynamoDB, a.Logger).Execute(tenantCtx)
+		if err != nil {
+			a.Logger.Printf("Failed to apply reorder suggestion for product %s: %v", suggestion.ProductID, err)
+			failed++
+			continue
+		}
+		if applyResult["applied"] != true {
+			continue
+		}
+		applied++
+
+		var before interface{}
+		if previous, ok := applyResult["previous"].(*models.ReorderPolicyOverride); ok && previous != nil {
+			before = previous
+		}
+		a.Audit.Record(tenantCtx, models.AuditReorderSuggestionApplied, models.AuditResourceReorderPolicy, suggestion.ProductID, before, applyResult["override"], nil)
+	}
+
+	a.Logger.Printf("Reorder suggestions applied - suggestions: %d, applied: %d, failed: %d", len(suggestions), applied, failed)
+
+	summary := map[string]interface{}{
+		"success":     failed == 0,
+		"suggestions": len(suggestions),
+		"applied":     applied,
+		"failed":      failed,
+	}
+	if failed > 0 {
+		return summary, fmt.Errorf("failed to apply %d reorder suggestions", failed)
+	}
+	return summary, nil
+}
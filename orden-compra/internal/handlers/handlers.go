@@ -3,38 +3,155 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"runtime/debug"
+	"strconv"
 	"time"
 
 	"log"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
 	"github.com/rabbitmq/amqp091-go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 
+	"medisupply/correlation"
+	"medisupply/errortracking"
+	"medisupply/messaging"
+	"medisupply/observability"
+	"medisupply/queue"
+	"orden-compra/internal/audit"
+	"orden-compra/internal/breaker"
+	"orden-compra/internal/codec"
 	"orden-compra/internal/cqrs"
+	"orden-compra/internal/delay"
+	"orden-compra/internal/dispatch"
+	"orden-compra/internal/intake"
+	"orden-compra/internal/limiter"
 	"orden-compra/internal/models"
+	"orden-compra/internal/notify"
+	"orden-compra/internal/outbox"
+	"orden-compra/internal/slo"
+	"orden-compra/internal/tenant"
+	"orden-compra/internal/webhooks"
+)
+
+// OutputExchange is the topic exchange of the events OrdenCompra announces to
+// other services, as opposed to the commands it sends Proveedor
+const OutputExchange = "orden-compra-exchange"
+
+// ReceptionExchange is the topic exchange of the commands OrdenCompra sends Proveedor
+const ReceptionExchange = "recepcion-proveedor-exchange"
+
+// Routing keys of the commands published on ReceptionExchange
+const (
+	ReceptionRoutingKey      = "recepcion.proveedor"
+	CancellationRoutingKey   = "recepcion.proveedor.cancelled"
+	ShipmentNoticeRoutingKey = "recepcion.proveedor.asn"
+)
+
+// Routing keys of the events published on OutputExchange
+const (
+	OrderPlacedRoutingKey     = "orden.compra.placed"
+	CompletionRoutingKey      = "orden.compra.completed"
+	InvoiceMismatchRoutingKey = "orden.compra.invoice.mismatch"
+)
+
+// DryRunHeader has a StockBajo message compute its order without storing or
+// publishing it, e.g. when historical events are replayed to tune strategies
+const DryRunHeader = "dry-run"
+
+// Names of the consumers in the intake registry
+const (
+	StockLowConsumerName          = "stock-low"
+	InventoryReceivedConsumerName = "inventory-received"
+	InvoiceReceivedConsumerName   = "invoice-received"
 )
 
 // RabbitMQHandler handles RabbitMQ message consumption and production
 type RabbitMQHandler struct {
-	Connection   *amqp091.Connection
-	Channel      *amqp091.Channel
-	QueueName    string
-	ExchangeName string
-	RoutingKey   string
-	DynamoDB     *dynamodb.DynamoDB
-	Logger       *log.Logger
-	Running      bool
+	Connection         *amqp091.Connection
+	Channel            *amqp091.Channel
+	Publisher          messaging.Sender
+	QueueName          string
+	ExchangeName       string
+	RoutingKey         string
+	DeadLetterExchange string
+	DeadLetterQueue    string
+	OutputContentType  string
+	Encoding           codec.EncodeOptions
+	Priorities         models.MessagePriorityPolicy
+	OrderPolicy        models.PurchaseOrderPolicy
+	Tenancy            tenant.Policy
+	DynamoDB           dynamodbiface.DynamoDBAPI
+	Stats              cqrs.StatsProjection
+	PublishLimiter     *limiter.Limiter
+	Notifier           *notify.Notifier
+	Webhooks           *webhooks.Dispatcher
+	Audit              *audit.Recorder
+	Dispatcher         *dispatch.Dispatcher
+	Delayed            *delay.Publisher
+	Retry              delay.RetryPolicy
+	SLO                *slo.Recorder
+	Errors             *errortracking.Reporter
+	Outbox             *outbox.Relay
+	Consumer           *intake.Consumer
+	Logger             *log.Logger
+	Running            bool
+}
+
+// RabbitMQHandlerOptions holds the queues the handler consumes and publishes
+// on and the collaborators it hands orders to
+type RabbitMQHandlerOptions struct {
+	QueueName         string
+	ExchangeName      string
+	RoutingKey        string
+	OutputContentType string
+	Encoding          codec.EncodeOptions
+	Queue             queue.Config
+	DeadLetterQueue   queue.Config
+	Priorities        models.MessagePriorityPolicy
+	OrderPolicy       models.PurchaseOrderPolicy
+	Tenancy           tenant.Policy
+	Stats             cqrs.StatsProjection
+	Confirms          messaging.PublisherConfig
+	Retry             delay.RetryPolicy
+	PublishLimiter    *limiter.Limiter
+	Notifier          *notify.Notifier
+	Webhooks          *webhooks.Dispatcher
+	Audit             *audit.Recorder
+	Dispatcher        *dispatch.Dispatcher
+	Delayed           *delay.Publisher
+	SLO               *slo.Recorder
+	Errors            *errortracking.Reporter
+	// Outbox configures the relay publishing the events stock alerts leave
+	// in the outbox
+	Outbox outbox.Config
 }
 
 // NewRabbitMQHandler creates a new RabbitMQ handler
-func NewRabbitMQHandler(connection *amqp091.Connection, queueName, exchangeName, routingKey string, dynamoDB *dynamodb.DynamoDB, logger *log.Logger) (*RabbitMQHandler, error) {
+func NewRabbitMQHandler(connection *amqp091.Connection, dynamoDB dynamodbiface.DynamoDBAPI, options RabbitMQHandlerOptions, logger *log.Logger) (*RabbitMQHandler, error) {
+	outputContentType, err := codec.Normalize(options.OutputContentType)
+	if err != nil {
+		return nil, fmt.Errorf("invalid output content type: %w", err)
+	}
+	queueName, exchangeName, routingKey := options.QueueName, options.ExchangeName, options.RoutingKey
+	queueConfig, deadLetterQueueConfig := options.Queue, options.DeadLetterQueue
+
 	channel, err := connection.Channel()
 	if err != nil {
 		return nil, fmt.Errorf("failed to open channel: %w", err)
 	}
 
+	// Publish in confirm mode so broker-side failures surface
+	publisher, err := messaging.NewPublisher(channel, options.Confirms, logger)
+	if err != nil {
+		return nil, err
+	}
+
 	// Declare exchange
 	err = channel.ExchangeDeclare(
 		exchangeName, // name
@@ -49,14 +166,32 @@ func NewRabbitMQHandler(connection *amqp091.Connection, queueName, exchangeName,
 		return nil, fmt.Errorf("failed to declare exchange: %w", err)
 	}
 
-	// Declare queue
-	queue, err := channel.QueueDeclare(
-		queueName, // name
-		true,      // durable
-		false,     // delete when unused
-		false,     // exclusive
-		false,     // no-wait
-		nil,       // arguments
+	// Declare the exchange of the events OrdenCompra announces to other services
+	err = channel.ExchangeDeclare(
+		OutputExchange, // name
+		"topic",        // type
+		true,           // durable
+		false,          // auto-deleted
+		false,          // internal
+		false,          // no-wait
+		nil,            // arguments
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to declare output exchange: %w", err)
+	}
+
+	// Declare queue; with priorities, critical events overtake queued routine ones
+	queueConfig.MaxPriority = options.Priorities.MaxPriority
+	if err := queueConfig.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid queue %s: %w", queueName, err)
+	}
+	consumedQueue, err := channel.QueueDeclare(
+		queueName,               // name
+		true,                    // durable
+		false,                   // delete when unused
+		false,                   // exclusive
+		false,                   // no-wait
+		queueConfig.Arguments(), // arguments
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to declare queue: %w", err)
@@ -64,73 +199,108 @@ func NewRabbitMQHandler(connection *amqp091.Connection, queueName, exchangeName,
 
 	// Bind queue to exchange
 	err = channel.QueueBind(
-		queue.Name,   // queue name
-		routingKey,   // routing key
-		exchangeName, // exchange
-		false,        // no-wait
-		nil,          // arguments
+		consumedQueue.Name, // queue name
+		routingKey,         // routing key
+		exchangeName,       // exchange
+		false,              // no-wait
+		nil,                // arguments
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to bind queue: %w", err)
 	}
 
-	return &RabbitMQHandler{
-		Connection:   connection,
-		Channel:      channel,
-		QueueName:    queue.Name,
-		ExchangeName: exchangeName,
-		RoutingKey:   routingKey,
-		DynamoDB:     dynamoDB,
-		Logger:       logger,
-		Running:      false,
-	}, nil
-}
+	// Declare dead letter exchange and queue for rejected events
+	deadLetterExchange := exchangeName + ".dlx"
+	deadLetterQueue := DeadLetterQueueName(queueName)
 
-// StartConsuming starts consuming messages from RabbitMQ
-func (h *RabbitMQHandler) StartConsuming() error {
-	h.Running = true
-	h.Logger.Printf("Starting RabbitMQ consumer - queue: %s, exchange: %s, routing_key: %s", h.QueueName, h.ExchangeName, h.RoutingKey)
+	err = channel.ExchangeDeclare(
+		deadLetterExchange, // name
+		"fanout",           // type
+		true,               // durable
+		false,              // auto-deleted
+		false,              // internal
+		false,              // no-wait
+		nil,                // arguments
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to declare dead letter exchange: %w", err)
+	}
 
-	// Set QoS
-	err := h.Channel.Qos(
-		1,     // prefetch count
-		0,     // prefetch size
-		false, // global
+	if err := deadLetterQueueConfig.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid queue %s: %w", deadLetterQueue, err)
+	}
+	_, err = channel.QueueDeclare(
+		deadLetterQueue,                   // name
+		true,                              // durable
+		false,                             // delete when unused
+		false,                             // exclusive
+		false,                             // no-wait
+		deadLetterQueueConfig.Arguments(), // arguments
 	)
 	if err != nil {
-		return fmt.Errorf("failed to set QoS: %w", err)
+		return nil, fmt.Errorf("failed to declare dead letter queue: %w", err)
 	}
 
-	// Start consuming
-	msgs, err := h.Channel.Consume(
-		h.QueueName, // queue
-		"",          // consumer
-		false,       // auto-ack
-		false,       // exclusive
-		false,       // no-local
-		false,       // no-wait
-		nil,         // args
+	err = channel.QueueBind(
+		deadLetterQueue,    // queue name
+		"",                 // routing key
+		deadLetterExchange, // exchange
+		false,              // no-wait
+		nil,                // arguments
 	)
 	if err != nil {
-		return fmt.Errorf("failed to register consumer: %w", err)
+		return nil, fmt.Errorf("failed to bind dead letter queue: %w", err)
 	}
 
-	// Process messages
-	go func() {
-		for msg := range msgs {
-			if !h.Running {
-				break
-			}
-			h.processMessage(msg)
-		}
-	}()
+	h := &RabbitMQHandler{
+		Connection:         connection,
+		Channel:            channel,
+		Publisher:          publisher,
+		QueueName:          consumedQueue.Name,
+		ExchangeName:       exchangeName,
+		RoutingKey:         routingKey,
+		DeadLetterExchange: deadLetterExchange,
+		DeadLetterQueue:    deadLetterQueue,
+		OutputContentType:  outputContentType,
+		Encoding:           options.Encoding,
+		Priorities:         options.Priorities,
+		OrderPolicy:        options.OrderPolicy,
+		Tenancy:            options.Tenancy,
+		DynamoDB:           dynamoDB,
+		Stats:              options.Stats,
+		PublishLimiter:     options.PublishLimiter,
+		Notifier:           options.Notifier,
+		Webhooks:           options.Webhooks,
+		Audit:              options.Audit,
+		Dispatcher:         options.Dispatcher,
+		Delayed:            options.Delayed,
+		Retry:              options.Retry,
+		SLO:                options.SLO,
+		Errors:             options.Errors,
+		Logger:             logger,
+		Running:            false,
+	}
+	h.Outbox = outbox.NewRelay(dynamoDB, h.publishOutboxMessage, options.Outbox, logger)
+	return h, nil
+}
 
-	return nil
+// StartConsuming starts consuming messages from RabbitMQ
+func (h *RabbitMQHandler) StartConsuming(consumers *intake.Registry, config intake.Config) error {
+	h.Running = true
+	h.Logger.Printf("Starting RabbitMQ consumer - queue: %s, exchange: %s, routing_key: %s, prefetch: %d, max_in_flight: %d", h.QueueName, h.ExchangeName, h.RoutingKey, config.Prefetch, config.MaxInFlight)
+
+	consumer, err := consumers.Register(StockLowConsumerName, h.Channel, h.QueueName, config, h.processMessage, stockLowKey, h.Logger)
+	if err != nil {
+		return err
+	}
+	h.Consumer = consumer
+	return consumer.Start()
 }
 
-// StopConsuming stops consuming messages
+// StopConsuming stops consuming messages once those being processed finish
 func (h *RabbitMQHandler) StopConsuming() {
 	h.Running = false
+	h.Consumer.Stop()
 	if h.Channel != nil {
 		h.Channel.Close()
 	}
@@ -140,38 +310,130 @@ func (h *RabbitMQHandler) StopConsuming() {
 	h.Logger.Println("RabbitMQ consumer stopped")
 }
 
-// processMessage processes a single RabbitMQ message
-func (h *RabbitMQHandler) processMessage(msg amqp091.Delivery) {
-	startTime := time.Now()
-	ctx := context.Background()
+// DeadLetterQueueName returns the name of the queue rejected events of a
+// queue are dead-lettered to
+func DeadLetterQueueName(queueName string) string {
+	return queueName + ".dlq"
+}
 
-	// Extract correlation information from headers
-	correlationID := extractHeader(msg.Headers, "correlation-id")
-	causationID := extractHeader(msg.Headers, "causation-id")
+// stockLowKey keys stock alerts by tenant and product, so alerts of a
+// product are processed in order and each sees the order the previous one
+// placed; alerts that do not decode are rejected in any order. It hands
+// the decoded alert, or the decoding error, on to processMessage.
+func stockLowKey(msg amqp091.Delivery) (string, interface{}) {
+	contentType := msg.ContentType
+	if contentType == "" {
+		contentType = extractHeader(msg.Headers, "content-type")
+	}
+	event, err := codec.DecodeStockLowEvent(contentType, msg.Body)
+	if err != nil {
+		return "", err
+	}
+	tenantID := extractHeader(msg.Headers, tenant.MessageHeader)
+	if tenantID == "" {
+		tenantID = event.TenantID
+	}
+	return tenantID + "/" + event.ProductID, event
+}
+
+// processMessage processes a single RabbitMQ message, decoding it unless
+// stockLowKey did
+func (h *RabbitMQHandler) processMessage(msg amqp091.Delivery, decoded interface{}) {
+	startTime := time.Now()
+	ctx := messageContext(msg, h.Logger)
+	ctx, span := startMessageSpan(ctx, h.QueueName, msg)
+	defer span.End()
+	ctx = errortracking.WithTags(ctx, map[string]string{"queue": h.QueueName, "message_id": msg.MessageId})
+	// A message that panics is dead-lettered rather than redelivered to
+	// panic again; ctx carries the tags added below by then
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			h.rejectPanicked(ctx, h.QueueName, msg, recovered)
+		}
+	}()
+	messaging.RecordAge(ctx, h.QueueName, msg.Timestamp)
 
-	// Set correlation context
-	// TODO: Implement correlation tracking
-	_ = correlationID
-	_ = causationID
+	// Parse message according to its content type
+	contentType := msg.ContentType
+	if contentType == "" {
+		contentType = extractHeader(msg.Headers, "content-type")
+	}
 
-	h.Logger.Printf("Processing message - routing_key: %s, correlation_id: %s, causation_id: %s, message_id: %s", msg.RoutingKey, correlationID, causationID, msg.MessageId)
+	if _, err := codec.Normalize(contentType); err != nil {
+		h.Logger.Printf("Unsupported message content type: %v", err)
+		h.deadLetter(ctx, h.QueueName, msg, "unsupported_content_type", models.ValidationErrors{
+			{Field: "content_type", Message: err.Error()},
+		})
+		return
+	}
 
-	// Parse message
-	var stockLowEvent models.StockLowEvent
-	err := json.Unmarshal(msg.Body, &stockLowEvent)
+	var stockLowEvent *models.StockLowEvent
+	var err error
+	switch decoded := decoded.(type) {
+	case *models.StockLowEvent:
+		stockLowEvent = decoded
+	case error:
+		err = decoded
+	default:
+		stockLowEvent, err = codec.DecodeStockLowEvent(contentType, msg.Body)
+	}
 	if err != nil {
 		h.Logger.Printf("Failed to parse message: %v", err)
 		// TODO: Record metrics
-		msg.Nack(false, false) // Reject message
+		h.deadLetter(ctx, h.QueueName, msg, "malformed_payload", models.ValidationErrors{
+			{Field: "body", Message: err.Error()},
+		})
+		return
+	}
+
+	// Scope the message to its tenant; the header takes precedence over the payload
+	tenantID := extractHeader(msg.Headers, tenant.MessageHeader)
+	if tenantID == "" {
+		tenantID = stockLowEvent.TenantID
+	}
+	tenantID, err = h.Tenancy.Resolve(tenantID)
+	if err != nil {
+		h.Logger.Printf("Rejected stock low event without a valid tenant - message_id: %s, error: %v", msg.MessageId, err)
+		h.deadLetter(ctx, h.QueueName, msg, "invalid_tenant", models.ValidationErrors{
+			{Field: "tenant_id", Message: err.Error()},
+		})
+		return
+	}
+	stockLowEvent.TenantID = tenantID
+	ctx = tenant.NewContext(ctx, tenantID)
+	ctx = errortracking.WithTags(ctx, map[string]string{"tenant_id": tenantID, "event_id": stockLowEvent.ID, "product_id": stockLowEvent.ProductID})
+
+	// Validate message
+	if err := stockLowEvent.Validate(time.Now()); err != nil {
+		h.Logger.Printf("Invalid stock low event - message_id: %s, error: %v", msg.MessageId, err)
+		var validationErrors models.ValidationErrors
+		if !errors.As(err, &validationErrors) {
+			validationErrors = models.ValidationErrors{{Field: "body", Message: err.Error()}}
+		}
+		h.deadLetter(ctx, h.QueueName, msg, "validation_failed", validationErrors)
 		return
 	}
 
 	// Process the stock low event
-	result, err := h.processStockLowEvent(ctx, &stockLowEvent)
+	dryRun := h.OrderPolicy.DryRun || extractHeader(msg.Headers, DryRunHeader) == "true"
+	result, err := h.processStockLowEvent(ctx, stockLowEvent, dryRun)
+	if errors.Is(err, cqrs.ErrContractExpired) {
+		// Retrying cannot help until the contract is renewed
+		h.Logger.Printf("Stock low event refused - message_id: %s, dry_run: %v, error: %v", msg.MessageId, dryRun, err)
+		if dryRun {
+			// The refusal is the outcome of the dry run; there is nothing to keep
+			msg.Ack(false)
+			return
+		}
+		h.deadLetter(ctx, h.QueueName, msg, "contract_expired", models.ValidationErrors{
+			{Field: "supplier_id", Message: err.Error()},
+		})
+		return
+	}
 	if err != nil {
 		h.Logger.Printf("Failed to process stock low event: %v", err)
 		// TODO: Record metrics
-		msg.Nack(false, true) // Reject and requeue
+		h.retry(ctx, h.QueueName, msg, err)
 		return
 	}
 
@@ -179,90 +441,601 @@ func (h *RabbitMQHandler) processMessage(msg amqp091.Delivery) {
 	processingTime := time.Since(startTime)
 	// TODO: Record metrics
 	_ = processingTime
-	_ = result
-
-	// Produce output event if needed
-	if result["success"].(bool) && result["reception_event"] != nil {
-		receptionEvent := result["reception_event"].(*models.RecepcionProveedorEvent)
-		err = h.produceReceptionEvent(ctx, receptionEvent)
-		if err != nil {
-			h.Logger.Printf("Failed to produce reception event: %v", err)
-			// TODO: Record metrics
+
+	// Time the new order from the event reaching us; dry runs and events
+	// folded into an existing order start no pipeline
+	if purchaseOrder, ok := result["purchase_order"].(*models.PurchaseOrder); ok && !dryRun {
+		h.SLO.Record(ctx, purchaseOrder.ID, purchaseOrder.UrgencyLevel, map[string]time.Time{
+			slo.StageEventReceived:  startTime.UTC(),
+			slo.StageOrderPersisted: time.Now().UTC(),
+		})
+	}
+
+	// Publish the events the command stored in the outbox with the order.
+	// Events that fail stay there for the relay, so the message is
+	// acknowledged either way.
+	if messages, _ := result["outbox_messages"].([]*outbox.Message); len(messages) > 0 {
+		if err := h.Outbox.Deliver(ctx, messages); err != nil {
+			h.Logger.Printf("Failed to produce events, left in the outbox for the relay: %v", err)
 		}
 	}
 
 	// Acknowledge message
 	msg.Ack(false)
 
-	h.Logger.Printf("Message processed successfully - event_id: %s, product_id: %s, processing_time: %v, success: %v", stockLowEvent.ID, stockLowEvent.ProductID, processingTime, result["success"])
+	h.Logger.Printf("Message processed successfully - event_id: %s, tenant_id: %s, product_id: %s, processing_time: %v, success: %v, dry_run: %v", stockLowEvent.ID, tenantID, stockLowEvent.ProductID, processingTime, result["success"], dryRun)
 }
 
-// processStockLowEvent processes a stock low event and creates a purchase order
-func (h *RabbitMQHandler) processStockLowEvent(ctx context.Context, event *models.StockLowEvent) (map[string]interface{}, error) {
+// processStockLowEvent processes a stock low event and creates a purchase
+// order; a dry run only returns the order it would have created
+func (h *RabbitMQHandler) processStockLowEvent(ctx context.Context, event *models.StockLowEvent, dryRun bool) (map[string]interface{}, error) {
+	policy := h.OrderPolicy
+	policy.DryRun = dryRun
+
 	// Create and execute command
 	command := cqrs.NewProcessStockLowCommand(
 		event,
+		policy,
 		h.DynamoDB,
 		h.Logger,
-		nil, // TODO: correlation ID
-		nil, // TODO: causation ID
+		correlation.CorrelationID(ctx),
+		correlation.CausationID(ctx),
 	)
+	command.Stats = h.Stats
 
 	result, err := command.Execute(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute command: %w", err)
 	}
 
-	// Record purchase order created
-	if result["success"].(bool) {
-		receptionEvent := result["reception_event"].(*models.RecepcionProveedorEvent)
+	// Nothing was stored, so there is nothing to audit or notify
+	if policy.DryRun {
+		return result, nil
+	}
+
+	if purchaseOrder, ok := result["purchase_order"].(*models.PurchaseOrder); ok {
+		h.Audit.Record(ctx, models.AuditPurchaseOrderCreated, models.AuditResourcePurchaseOrder, purchaseOrder.ID, nil, purchaseOrder, nil)
+	} else if purchaseOrderID, ok := result["purchase_order_id"].(string); ok {
+		h.Audit.Record(ctx, models.AuditPurchaseOrderDuplicate, models.AuditResourcePurchaseOrder, purchaseOrderID, nil, h.Audit.PurchaseOrder(ctx, purchaseOrderID), nil)
+	}
+
+	// Record purchase order created; orders awaiting approval have no reception event yet
+	if receptionEvent, ok := result["reception_event"].(*models.RecepcionProveedorEvent); ok && result["success"].(bool) {
 		// TODO: Record metrics
 		_ = receptionEvent
 	}
 
+	// Duplicate events fold into an existing order and carry no new purchase order
+	if purchaseOrder, ok := result["purchase_order"].(*models.PurchaseOrder); ok {
+		h.Notifier.Notify(notify.NewNotification(notify.EventPurchaseOrderCreated, purchaseOrder.ID, purchaseOrderNotificationData(purchaseOrder)))
+		h.Webhooks.Dispatch(ctx, models.WebhookPurchaseOrderCreated, purchaseOrder)
+	}
+
 	return result, nil
 }
 
+// outgoingEvent is an encoded event and the envelope fields publish copies
+// onto its message
+type outgoingEvent struct {
+	ID          string
+	Type        string
+	TenantID    string
+	Timestamp   time.Time
+	Metadata    map[string]interface{}
+	ContentType string
+	Body        []byte
+	Priority    uint8
+}
+
+// publish sends an event with its correlation, tenant and type headers once a
+// publish slot is free
+func (h *RabbitMQHandler) publish(ctx context.Context, exchange, routingKey string, event outgoingEvent) error {
+	headers := make(amqp091.Table)
+	setCorrelationHeaders(headers, event.Metadata)
+	setTenantHeader(headers, event.TenantID)
+	headers["event-type"] = event.Type
+	headers["content-type"] = event.ContentType
+
+	// Wait for a publish slot
+	if h.PublishLimiter != nil {
+		if err := h.PublishLimiter.Acquire(ctx); err != nil {
+			return fmt.Errorf("failed to acquire publish slot: %w", err)
+		}
+		defer h.PublishLimiter.Release()
+	}
+
+	err := h.Publisher.Publish(ctx, exchange, routingKey, amqp091.Publishing{
+		ContentType:  event.ContentType,
+		Body:         event.Body,
+		Headers:      headers,
+		MessageId:    event.ID,
+		Timestamp:    event.Timestamp,
+		DeliveryMode: amqp091.Persistent,
+		Priority:     event.Priority,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to publish message: %w", err)
+	}
+	return nil
+}
+
+// publishJSON publishes event encoded as JSON in the given envelope
+func (h *RabbitMQHandler) publishJSON(ctx context.Context, exchange, routingKey string, envelope outgoingEvent, event interface{}) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+	envelope.ContentType = codec.ContentTypeJSON
+	envelope.Body = body
+	return h.publish(ctx, exchange, routingKey, envelope)
+}
+
 // produceReceptionEvent produces a reception event to the output exchange
 func (h *RabbitMQHandler) produceReceptionEvent(ctx context.Context, event *models.RecepcionProveedorEvent) error {
-	// Marshal event to JSON
+	// Encode event in the configured content type
+	body, contentType, err := codec.EncodeRecepcionProveedorEvent(h.OutputContentType, event, h.Encoding)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+	urgencyLevel, _ := event.Metadata["urgency_level"].(string)
+
+	err = h.publish(ctx, ReceptionExchange, ReceptionRoutingKey, outgoingEvent{
+		ID:          event.ID,
+		Type:        string(models.PurchaseOrderEventType),
+		TenantID:    event.TenantID,
+		Timestamp:   event.Timestamp,
+		Metadata:    event.Metadata,
+		ContentType: contentType,
+		Body:        body,
+		Priority:    h.Priorities.Priority(urgencyLevel),
+	})
+	if err != nil {
+		return err
+	}
+
+	h.Logger.Printf("Reception event produced - event_id: %s, product_id: %s, supplier_id: %s, urgency_level: %s, routing_key: %s", event.ID, event.ProductID, event.SupplierID, urgencyLevel, ReceptionRoutingKey)
+
+	h.Webhooks.Dispatch(ctx, models.WebhookReceptionRequested, event)
+
+	// Send newly released orders to the supplier; top-ups only add a reception
+	if topUp, _ := event.Metadata["top_up"].(bool); !topUp {
+		h.Dispatcher.Dispatch(ctx, event.PurchaseOrderID)
+		h.SLO.Record(ctx, event.PurchaseOrderID, urgencyLevel, map[string]time.Time{
+			slo.StageReceptionPublished: time.Now().UTC(),
+		})
+	}
+
+	return nil
+}
+
+// publishOutboxMessage publishes an event a stock alert stored in the outbox
+func (h *RabbitMQHandler) publishOutboxMessage(ctx context.Context, message *outbox.Message) error {
+	if message.TenantID != "" {
+		ctx = tenant.NewContext(ctx, message.TenantID)
+	}
+	switch models.EventType(message.Type) {
+	case models.PurchaseOrderEventType:
+		var event models.RecepcionProveedorEvent
+		if err := message.Decode(&event); err != nil {
+			return err
+		}
+		return h.produceReceptionEvent(ctx, &event)
+	case models.OrderPlacedForProductEventType:
+		var event models.OrderPlacedForProductEvent
+		if err := message.Decode(&event); err != nil {
+			return err
+		}
+		return h.PublishOrderPlacedEvent(ctx, &event)
+	default:
+		return fmt.Errorf("unknown outbox message type %q", message.Type)
+	}
+}
+
+// PublishCancellationEvent publishes a purchase order cancellation so Proveedor can stop the reception
+func (h *RabbitMQHandler) PublishCancellationEvent(ctx context.Context, event *models.PurchaseOrderCancelledEvent) error {
+	err := h.publishJSON(ctx, ReceptionExchange, CancellationRoutingKey, outgoingEvent{
+		ID:        event.ID,
+		Type:      string(models.PurchaseOrderCancelledEventType),
+		TenantID:  event.TenantID,
+		Timestamp: event.Timestamp,
+		Metadata:  event.Metadata,
+	}, event)
+	if err != nil {
+		return err
+	}
+
+	h.Logger.Printf("Cancellation event produced - event_id: %s, purchase_order_id: %s, routing_key: %s", event.ID, event.PurchaseOrderID, CancellationRoutingKey)
+
+	return nil
+}
+
+// PublishOrderPlacedEvent tells MovimientoInventario how much of a product is on order
+func (h *RabbitMQHandler) PublishOrderPlacedEvent(ctx context.Context, event *models.OrderPlacedForProductEvent) error {
+	err := h.publishJSON(ctx, OutputExchange, OrderPlacedRoutingKey, outgoingEvent{
+		ID:        event.ID,
+		Type:      string(models.OrderPlacedForProductEventType),
+		TenantID:  event.TenantID,
+		Timestamp: event.Timestamp,
+		Metadata:  event.Metadata,
+	}, event)
+	if err != nil {
+		return err
+	}
+
+	h.Logger.Printf("Order placed event produced - event_id: %s, product_id: %s, location: %s, quantity_on_order: %d, routing_key: %s", event.ID, event.ProductID, event.Location, event.QuantityOnOrder, OrderPlacedRoutingKey)
+
+	return nil
+}
+
+// PublishCompletionEvent announces a purchase order completed on receiving its inventory
+func (h *RabbitMQHandler) PublishCompletionEvent(ctx context.Context, event *models.PurchaseOrderCompletedEvent) error {
+	err := h.publishJSON(ctx, OutputExchange, CompletionRoutingKey, outgoingEvent{
+		ID:        event.ID,
+		Type:      string(models.PurchaseOrderCompletedEventType),
+		TenantID:  event.TenantID,
+		Timestamp: event.Timestamp,
+		Metadata:  event.Metadata,
+	}, event)
+	if err != nil {
+		return err
+	}
+
+	h.Logger.Printf("Completion event produced - event_id: %s, purchase_order_id: %s, routing_key: %s", event.ID, event.PurchaseOrderID, CompletionRoutingKey)
+
+	return nil
+}
+
+// PublishShipmentNoticeEvent tells Proveedor what a supplier announced it shipped
+func (h *RabbitMQHandler) PublishShipmentNoticeEvent(ctx context.Context, event *models.AdvanceShipmentNoticeEvent) error {
+	err := h.publishJSON(ctx, ReceptionExchange, ShipmentNoticeRoutingKey, outgoingEvent{
+		ID:        event.ID,
+		Type:      string(models.AdvanceShipmentNoticeEventType),
+		TenantID:  event.TenantID,
+		Timestamp: event.Timestamp,
+		Metadata:  event.Metadata,
+	}, event)
+	if err != nil {
+		return err
+	}
+
+	h.Logger.Printf("Shipment notice event produced - event_id: %s, purchase_order_id: %s, routing_key: %s", event.ID, event.PurchaseOrderID, ShipmentNoticeRoutingKey)
+
+	return nil
+}
+
+// PublishInvoiceMismatchEvent tells finance that a purchase order's invoices
+// do not match what was ordered and received
+func (h *RabbitMQHandler) PublishInvoiceMismatchEvent(ctx context.Context, event *models.InvoiceMismatchDetectedEvent) error {
+	err := h.publishJSON(ctx, OutputExchange, InvoiceMismatchRoutingKey, outgoingEvent{
+		ID:        event.ID,
+		Type:      string(models.InvoiceMismatchDetectedEventType),
+		TenantID:  event.TenantID,
+		Timestamp: event.Timestamp,
+		Metadata:  event.Metadata,
+	}, event)
+	if err != nil {
+		return err
+	}
+
+	h.Logger.Printf("Invoice mismatch event produced - event_id: %s, purchase_order_id: %s, routing_key: %s", event.ID, event.PurchaseOrderID, InvoiceMismatchRoutingKey)
+
+	return nil
+}
+
+// announceInvoiceMismatch publishes the InvoiceMismatchDetected event a
+// command returned, if any, and notifies webhook subscribers. The mismatch is
+// recorded with the order, so a failed publish is only logged.
+func (h *RabbitMQHandler) announceInvoiceMismatch(ctx context.Context, result map[string]interface{}) {
+	mismatchEvent, ok := result["mismatch_event"].(*models.InvoiceMismatchDetectedEvent)
+	if !ok {
+		return
+	}
+	if err := h.PublishInvoiceMismatchEvent(ctx, mismatchEvent); err != nil {
+		h.Logger.Printf("Failed to produce invoice mismatch event: %v", err)
+	}
+	h.Webhooks.Dispatch(ctx, models.WebhookInvoiceMismatch, mismatchEvent)
+}
+
+// retry schedules another attempt of a message that failed to process on
+// queueName, backing off exponentially, and dead-letters it once the attempts
+// are exhausted. Without retries configured the message is requeued at once.
+func (h *RabbitMQHandler) retry(ctx context.Context, queueName string, msg amqp091.Delivery, cause error) {
+	// A dependency behind an open circuit breaker is not the message's
+	// fault: consumption pauses until the breaker probes again and the
+	// message is requeued without using up an attempt
+	var open *breaker.OpenError
+	if errors.As(cause, &open) {
+		h.Logger.Printf("Dependency unavailable, pausing consumption - message_id: %s, dependency: %s, retry_after: %v", msg.MessageId, open.Dependency, open.RetryAfter)
+		select {
+		case <-time.After(open.RetryAfter):
+		case <-ctx.Done():
+		}
+		msg.Nack(false, true) // Reject and requeue
+		return
+	}
+
+	attempt := 1
+	if previous, ok := msg.Headers[delay.RetryAttemptHeader].(int32); ok {
+		attempt = int(previous) + 1
+	}
+	h.Errors.CaptureError(ctx, cause, map[string]string{"queue": queueName, "attempt": strconv.Itoa(attempt)})
+
+	if h.Retry.MaxAttempts == 0 {
+		msg.Nack(false, true) // Reject and requeue
+		return
+	}
+	if attempt > h.Retry.MaxAttempts {
+		h.deadLetter(ctx, queueName, msg, "retries_exhausted", models.ValidationErrors{
+			{Field: "processing", Message: cause.Error()},
+		})
+		return
+	}
+
+	headers := make(amqp091.Table, len(msg.Headers)+1)
+	for key, value := range msg.Headers {
+		headers[key] = value
+	}
+	headers[delay.RetryAttemptHeader] = int32(attempt)
+
+	// Keep the original publisher for auditing, since retries arrive through the default exchange
+	appID := msg.AppId
+	if appID == "" {
+		appID = msg.Exchange
+	}
+
+	wait := h.Retry.Delay(attempt)
+	err := h.Delayed.Publish(ctx, queueName, wait, amqp091.Publishing{
+		ContentType: msg.ContentType,
+		Body:        msg.Body,
+		Headers:     headers,
+		MessageId:   msg.MessageId,
+		AppId:       appID,
+		Timestamp:   msg.Timestamp,
+		Priority:    msg.Priority,
+	})
+	if err != nil {
+		h.Logger.Printf("Failed to schedule retry, requeueing - message_id: %s, error: %v", msg.MessageId, err)
+		msg.Nack(false, true) // Reject and requeue
+		return
+	}
+
+	msg.Ack(false)
+
+	h.Logger.Printf("Message scheduled for retry - message_id: %s, attempt: %d/%d, delay: %v", msg.MessageId, attempt, h.Retry.MaxAttempts, wait)
+}
+
+// ScheduleStockLowEvent publishes a StockBajo event onto the consumed queue
+// once wait has passed, so a re-order is processed as if the event had
+// arrived then
+func (h *RabbitMQHandler) ScheduleStockLowEvent(ctx context.Context, event *models.StockLowEvent, wait time.Duration) error {
 	body, err := json.Marshal(event)
 	if err != nil {
 		return fmt.Errorf("failed to marshal event: %w", err)
 	}
 
-	// Prepare headers
 	headers := make(amqp091.Table)
-	// TODO: Add correlation headers
-	headers["event-type"] = "RecepcionProveedor"
+	if ids, ok := correlation.FromContext(ctx); ok {
+		headers["correlation-id"] = ids.CorrelationID
+		headers["causation-id"] = ids.RequestID
+	}
+	setTenantHeader(headers, event.TenantID)
+	headers["event-type"] = string(models.StockLowEventType)
 	headers["content-type"] = "application/json"
 
-	// Publish message
-	err = h.Channel.PublishWithContext(
+	err = h.Delayed.Publish(ctx, h.QueueName, wait, amqp091.Publishing{
+		ContentType: "application/json",
+		Body:        body,
+		Headers:     headers,
+		MessageId:   event.ID,
+		AppId:       "orden-compra",
+		Timestamp:   event.Timestamp,
+		Priority:    h.Priorities.Priority(event.UrgencyLevel),
+	})
+	if err != nil {
+		return err
+	}
+
+	h.Logger.Printf("Stock low event scheduled - event_id: %s, product_id: %s, delay: %v, queue: %s", event.ID, event.ProductID, wait, h.QueueName)
+	return nil
+}
+
+// deadLetter persists a message rejected from queueName and forwards it to
+// the dead letter queue
+func (h *RabbitMQHandler) deadLetter(ctx context.Context, queueName string, msg amqp091.Delivery, reason string, validationErrors models.ValidationErrors) {
+	headers := make(map[string]interface{}, len(msg.Headers))
+	for key, value := range msg.Headers {
+		headers[key] = fmt.Sprint(value)
+	}
+
+	record := models.NewDeadLetterRecord(
+		msg.MessageId,
+		queueName,
+		msg.RoutingKey,
+		msg.ContentType,
+		reason,
+		validationErrors,
+		msg.Body,
+		headers,
+		extractHeader(msg.Headers, "correlation-id"),
+		time.Now(),
+	)
+
+	// Persist the rejection for debugging; the message still goes to the DLQ if this fails
+	command := cqrs.NewRecordDeadLetterCommand(record, h.DynamoDB, h.Logger)
+	if _, err := command.Execute(ctx); err != nil {
+		h.Logger.Printf("Failed to record dead letter: %v", err)
+	}
+
+	// Rejections of one kind from one queue are one issue, whatever the message
+	h.Errors.CaptureMessage(ctx, errortracking.LevelWarning, fmt.Sprintf("Message rejected to dead letter queue: %s: %s", reason, validationErrors.Error()), map[string]string{
+		"queue":          queueName,
+		"reason":         reason,
+		"message_id":     msg.MessageId,
+		"dead_letter_id": record.ID,
+		"correlation_id": extractHeader(msg.Headers, "correlation-id"),
+	}, "dead-letter", queueName, reason)
+
+	errorsJSON, err := json.Marshal(validationErrors)
+	if err != nil {
+		errorsJSON = []byte(validationErrors.Error())
+	}
+
+	dlqHeaders := make(amqp091.Table, len(msg.Headers)+3)
+	for key, value := range msg.Headers {
+		dlqHeaders[key] = value
+	}
+	dlqHeaders["x-rejection-reason"] = reason
+	dlqHeaders["x-validation-errors"] = string(errorsJSON)
+	dlqHeaders["x-dead-letter-id"] = record.ID
+
+	err = h.Publisher.Publish(
 		ctx,
-		"recepcion-proveedor-exchange", // exchange
-		"recepcion.proveedor",          // routing key
-		false,                          // mandatory
-		false,                          // immediate
+		h.DeadLetterExchange, // exchange
+		msg.RoutingKey,       // routing key
 		amqp091.Publishing{
-			ContentType:  "application/json",
-			Body:         body,
-			Headers:      headers,
-			MessageId:    event.ID,
-			Timestamp:    event.Timestamp,
+			ContentType:  msg.ContentType,
+			Body:         msg.Body,
+			Headers:      dlqHeaders,
+			MessageId:    msg.MessageId,
+			Timestamp:    time.Now().UTC(),
 			DeliveryMode: amqp091.Persistent,
 		},
 	)
+	if err != nil {
+		h.Logger.Printf("Failed to publish to dead letter queue: %v", err)
+		msg.Nack(false, false) // Reject message
+		return
+	}
+
+	msg.Ack(false)
+
+	h.Logger.Printf("Message rejected to dead letter queue - message_id: %s, dead_letter_id: %s, reason: %s, queue: %s", msg.MessageId, record.ID, reason, h.DeadLetterQueue)
+}
+
+// rejectPanicked reports the panic a message caused, with its stack, and
+// forwards the message to the dead letter queue
+func (h *RabbitMQHandler) rejectPanicked(ctx context.Context, queueName string, msg amqp091.Delivery, recovered interface{}) {
+	h.Logger.Printf("Recovered from panic processing message - message_id: %s, queue: %s, panic: %v\n%s", msg.MessageId, queueName, recovered, debug.Stack())
+	h.Errors.CapturePanic(ctx, recovered, nil)
+	h.deadLetter(ctx, queueName, msg, "panic", models.ValidationErrors{
+		{Field: "body", Message: fmt.Sprintf("processing panicked: %v", recovered)},
+	})
+}
+
+// ReplayedDeadLetterHeader names the dead letter record a replayed message
+// was published from
+const ReplayedDeadLetterHeader = "x-replayed-dead-letter-id"
+
+// ReplayDeadLetter publishes a rejected message again onto the queue it was
+// consumed from, so it goes through validation and the commands as if it had
+// just arrived. Its retry attempts start over.
+func (h *RabbitMQHandler) ReplayDeadLetter(ctx context.Context, record *models.DeadLetterRecord) error {
+	headers := make(amqp091.Table, len(record.Headers)+1)
+	for key, value := range record.Headers {
+		if key == delay.RetryAttemptHeader {
+			continue
+		}
+		headers[key] = fmt.Sprint(value)
+	}
+	headers[ReplayedDeadLetterHeader] = record.ID
+
+	contentType := record.ContentType
+	if contentType == "" {
+		contentType = extractHeader(headers, "content-type")
+	}
 
+	// Wait for a publish slot
+	if h.PublishLimiter != nil {
+		if err := h.PublishLimiter.Acquire(ctx); err != nil {
+			return fmt.Errorf("failed to acquire publish slot: %w", err)
+		}
+		defer h.PublishLimiter.Release()
+	}
+
+	err := h.Publisher.Publish(
+		ctx,
+		"",           // default exchange
+		record.Queue, // routing key
+		amqp091.Publishing{
+			ContentType:  contentType,
+			Body:         []byte(record.Payload),
+			Headers:      headers,
+			MessageId:    record.MessageID,
+			AppId:        "orden-compra",
+			Timestamp:    time.Now().UTC(),
+			DeliveryMode: amqp091.Persistent,
+		},
+	)
 	if err != nil {
 		return fmt.Errorf("failed to publish message: %w", err)
 	}
 
-	h.Logger.Printf("Reception event produced - event_id: %s, product_id: %s, supplier_id: %s, routing_key: recepcion.proveedor", event.ID, event.ProductID, event.SupplierID)
+	h.Logger.Printf("Dead letter replayed - dead_letter_id: %s, message_id: %s, queue: %s", record.ID, record.MessageID, record.Queue)
 
 	return nil
 }
 
+// messageContext returns the context of processing a consumed message,
+// carrying its correlation IDs and audit origin. The message causes the
+// commands it triggers; a message without a correlation ID starts a new
+// chain rooted at itself.
+func messageContext(msg amqp091.Delivery, logger *log.Logger) context.Context {
+	correlationID := extractHeader(msg.Headers, "correlation-id")
+	causationID := extractHeader(msg.Headers, "causation-id")
+
+	messageID := correlation.Sanitize(msg.MessageId)
+	if correlationID == "" {
+		correlationID = messageID
+	}
+	ctx := correlation.NewContext(context.Background(), correlation.IDs{RequestID: messageID, CorrelationID: correlationID})
+
+	logger.Printf("Processing message - routing_key: %s, correlation_id: %s, causation_id: %s, message_id: %s", msg.RoutingKey, correlationID, causationID, msg.MessageId)
+
+	// Attribute the resulting operations to the publishing application
+	publisher := msg.AppId
+	if publisher == "" {
+		publisher = msg.Exchange
+	}
+	return audit.WithOrigin(ctx, audit.Origin{
+		ActorID:       publisher,
+		ActorType:     models.AuditActorMessage,
+		Source:        "rabbitmq:" + msg.Exchange + "/" + msg.RoutingKey,
+		MessageID:     msg.MessageId,
+		CorrelationID: correlationID,
+	})
+}
+
+// startMessageSpan starts the span of processing a delivery; a traceparent
+// header continues the publisher's trace and a debug header has it sampled
+func startMessageSpan(ctx context.Context, queueName string, msg amqp091.Delivery) (context.Context, trace.Span) {
+	return observability.StartConsumerSpan(ctx, queueName+" process", messaging.Headers(msg.Headers),
+		attribute.String("messaging.system", "rabbitmq"),
+		attribute.String("messaging.destination.name", msg.Exchange),
+		attribute.String("messaging.rabbitmq.destination.routing_key", msg.RoutingKey),
+		attribute.String("messaging.message.id", msg.MessageId),
+	)
+}
+
+// setCorrelationHeaders copies the correlation and causation IDs recorded in
+// an event's metadata into the message headers
+func setCorrelationHeaders(headers amqp091.Table, metadata map[string]interface{}) {
+	for metadataKey, header := range map[string]string{
+		correlation.CorrelationIDKey: "correlation-id",
+		correlation.CausationIDKey:   "causation-id",
+	} {
+		if id := correlation.FromMetadata(metadata, metadataKey); id != nil {
+			headers[header] = *id
+		}
+	}
+}
+
+// setTenantHeader tells consumers which tenant an outgoing event belongs to
+func setTenantHeader(headers amqp091.Table, tenantID string) {
+	if tenantID != "" {
+		headers[tenant.MessageHeader] = tenantID
+	}
+}
+
 // extractHeader extracts a header value from AMQP headers
 func extractHeader(headers amqp091.Table, key string) string {
 	if headers == nil {
@@ -278,15 +1051,17 @@ func extractHeader(headers amqp091.Table, key string) string {
 
 // HealthCheckHandler handles health check requests
 type HealthCheckHandler struct {
-	DynamoDB *dynamodb.DynamoDB
-	Logger   *log.Logger
+	DynamoDB  dynamodbiface.DynamoDBAPI
+	Consumers *intake.Registry
+	Logger    *log.Logger
 }
 
 // NewHealthCheckHandler creates a new health check handler
-func NewHealthCheckHandler(dynamoDB *dynamodb.DynamoDB, logger *log.Logger) *HealthCheckHandler {
+func NewHealthCheckHandler(dynamoDB dynamodbiface.DynamoDBAPI, consumers *intake.Registry, logger *log.Logger) *HealthCheckHandler {
 	return &HealthCheckHandler{
-		DynamoDB: dynamoDB,
-		Logger:   logger,
+		DynamoDB:  dynamoDB,
+		Consumers: consumers,
+		Logger:    logger,
 	}
 }
 
@@ -313,3 +1088,24 @@ func (h *HealthCheckHandler) CheckHealth(ctx context.Context) map[string]interfa
 
 	return health
 }
+
+// CheckReadiness checks the service health and that every consumer is
+// alive, so a replica whose consumers went down stops being reported ready
+// while it consumes nothing
+func (h *HealthCheckHandler) CheckReadiness(ctx context.Context) map[string]interface{} {
+	readiness := h.CheckHealth(ctx)
+	checks := readiness["checks"].(map[string]string)
+	for _, consumer := range h.Consumers.Stats() {
+		switch {
+		case !consumer.Alive:
+			h.Logger.Printf("Readiness check failed - consumer %s: %s", consumer.Name, consumer.Failure)
+			readiness["status"] = "unhealthy"
+			checks["consumer:"+consumer.Name] = "down"
+		case consumer.Paused:
+			checks["consumer:"+consumer.Name] = "paused"
+		default:
+			checks["consumer:"+consumer.Name] = "ok"
+		}
+	}
+	return readiness
+}
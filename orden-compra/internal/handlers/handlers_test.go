@@ -0,0 +1,317 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/rabbitmq/amqp091-go"
+
+	"medisupply/observability"
+	"orden-compra/internal/memory"
+	"orden-compra/internal/models"
+	"orden-compra/internal/outbox"
+	"orden-compra/internal/tenant"
+)
+
+func TestStockLowKey(t *testing.T) {
+	msg := stockLowDelivery(t, &acknowledger{})
+	key, decoded := stockLowKey(msg)
+	if key != "/product-1" {
+		t.Fatalf("key %q, want /product-1", key)
+	}
+	if event, ok := decoded.(*models.StockLowEvent); !ok || event.ID != "stock-low-1" {
+		t.Fatalf("decoded %#v, want the stock low event", decoded)
+	}
+
+	// The tenant header takes precedence over the payload
+	msg.Headers = amqp091.Table{tenant.MessageHeader: "tenant-2"}
+	if key, _ := stockLowKey(msg); key != "tenant-2/product-1" {
+		t.Fatalf("key %q, want tenant-2/product-1", key)
+	}
+
+	// An alert that does not decode has no key and carries its error
+	msg.Body = []byte(`{"product_id":`)
+	key, decoded = stockLowKey(msg)
+	if _, ok := decoded.(error); key != "" || !ok {
+		t.Fatalf("malformed alert keyed %q with %#v, want no key and an error", key, decoded)
+	}
+}
+
+func TestProcessMessageUsesTheEventTheKeyDecoded(t *testing.T) {
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	h := newPublishingHandler(&recordingSender{})
+	h.DynamoDB = dynamoDB
+	h.Tenancy = tenant.Policy{DefaultTenant: "tenant-1"}
+	h.Outbox = outbox.NewRelay(dynamoDB, h.publishOutboxMessage, outbox.Config{}, h.Logger)
+
+	// The body is not decoded again, so a body that no longer decodes
+	// still places the order of the alert decoded before
+	ack := &acknowledger{}
+	msg := stockLowDelivery(t, ack)
+	_, decoded := stockLowKey(msg)
+	msg.Body = []byte("not json")
+	h.processMessage(msg, decoded)
+
+	if !ack.acked || ack.nacked || ack.rejected {
+		t.Fatalf("delivery settled as %+v, want acked", *ack)
+	}
+	if orders := dynamoDB.Items("orden-compra-read"); len(orders) != 1 {
+		t.Fatalf("stored %d orders, want 1", len(orders))
+	}
+
+	// A decoding error is dead-lettered as a malformed payload
+	msg = stockLowDelivery(t, &acknowledger{})
+	msg.Body = []byte(`{"product_id":`)
+	_, decoded = stockLowKey(msg)
+	h.processMessage(msg, decoded)
+
+	if records := dynamoDB.Items("orden-compra-dead-letters"); len(records) != 1 || aws.StringValue(records[0]["reason"].S) != "malformed_payload" {
+		t.Fatalf("dead letters %v, want one malformed_payload", records)
+	}
+}
+
+func TestInvalidStockLowEventIsDeadLettered(t *testing.T) {
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	sender := &recordingSender{}
+	h := newPublishingHandler(sender)
+	h.DynamoDB = dynamoDB
+	h.QueueName = "orden-compra.stock-bajo"
+	h.DeadLetterExchange = "orden-compra.dlx"
+	h.Tenancy = tenant.Policy{DefaultTenant: "tenant-1"}
+
+	ack := &acknowledger{}
+	msg := stockLowDelivery(t, ack)
+	msg.RoutingKey = "stock.bajo"
+	msg.Headers = amqp091.Table{"correlation-id": "correlation-1"}
+	msg.Body = []byte(`{"id":"stock-low-1","event_type":"StockBajo","product_id":"product-1","location":"warehouse-1","current_stock":-1,"urgency_level":"urgent","timestamp":"2024-03-01T12:00:00Z"}`)
+	h.processMessage(msg, nil)
+
+	// No order is placed; the message is settled once it reaches the DLQ
+	if !ack.acked || ack.nacked || ack.rejected {
+		t.Fatalf("delivery settled as %+v, want acked", *ack)
+	}
+	if orders := dynamoDB.Items("orden-compra-read"); len(orders) != 0 {
+		t.Fatalf("stored %d orders for an invalid event", len(orders))
+	}
+
+	records := dynamoDB.Items("orden-compra-dead-letters")
+	if len(records) != 1 || aws.StringValue(records[0]["reason"].S) != "validation_failed" || aws.StringValue(records[0]["queue"].S) != h.QueueName {
+		t.Fatalf("dead letters %v, want one validation_failed from %s", records, h.QueueName)
+	}
+	if len(sender.published) != 1 {
+		t.Fatalf("published %d messages, want the rejected one", len(sender.published))
+	}
+	rejected := sender.published[0]
+	if rejected.exchange != h.DeadLetterExchange || rejected.routingKey != "stock.bajo" || string(rejected.msg.Body) != string(msg.Body) {
+		t.Fatalf("published %s/%s %s", rejected.exchange, rejected.routingKey, rejected.msg.Body)
+	}
+	headers := rejected.msg.Headers
+	if headers["x-rejection-reason"] != "validation_failed" || headers["x-dead-letter-id"] != aws.StringValue(records[0]["id"].S) || headers["correlation-id"] != "correlation-1" {
+		t.Fatalf("dead letter headers %v", headers)
+	}
+	var errs models.ValidationErrors
+	if err := json.Unmarshal([]byte(headers["x-validation-errors"].(string)), &errs); err != nil || len(errs) != 2 || errs[0].Field != "current_stock" || errs[1].Field != "urgency_level" {
+		t.Fatalf("x-validation-errors %v, error %v", headers["x-validation-errors"], err)
+	}
+}
+
+func TestStockLowOfAnExpiredContractIsDeadLettered(t *testing.T) {
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	ended := time.Now().Add(-24 * time.Hour)
+	item, err := dynamodbattribute.MarshalMap(&models.Supplier{
+		ID:       "supplier-001",
+		Name:     "Acme",
+		Products: []models.SupplierProduct{{ProductID: "product-1", Contract: &models.SupplierContract{ContractID: "contract-1", ValidTo: &ended}}},
+	})
+	if err != nil {
+		t.Fatalf("marshal supplier: %v", err)
+	}
+	if _, err := dynamoDB.PutItemWithContext(context.Background(), &dynamodb.PutItemInput{TableName: aws.String("orden-compra-suppliers"), Item: item}); err != nil {
+		t.Fatalf("put supplier: %v", err)
+	}
+	h := newPublishingHandler(&recordingSender{})
+	h.DynamoDB = dynamoDB
+	h.Tenancy = tenant.Policy{DefaultTenant: "tenant-1"}
+
+	// Retrying cannot help, so the alert is settled on the DLQ
+	ack := &acknowledger{}
+	h.processMessage(stockLowDelivery(t, ack), nil)
+	if !ack.acked || ack.nacked || ack.rejected {
+		t.Fatalf("delivery settled as %+v, want acked", *ack)
+	}
+	if orders := dynamoDB.Items("orden-compra-read"); len(orders) != 0 {
+		t.Fatalf("stored %d orders under an expired contract", len(orders))
+	}
+	if records := dynamoDB.Items("orden-compra-dead-letters"); len(records) != 1 || aws.StringValue(records[0]["reason"].S) != "contract_expired" {
+		t.Fatalf("dead letters %v, want one contract_expired", records)
+	}
+}
+
+func TestDryRunMessageStoresAndPublishesNothing(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		expired bool
+	}{
+		{"order", false},
+		{"expired contract", true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dynamoDB := memory.NewDynamoDB(memory.Tables)
+			if tc.expired {
+				ended := time.Now().Add(-24 * time.Hour)
+				item, err := dynamodbattribute.MarshalMap(&models.Supplier{
+					ID:       "supplier-001",
+					Name:     "Acme",
+					Products: []models.SupplierProduct{{ProductID: "product-1", Contract: &models.SupplierContract{ContractID: "contract-1", ValidTo: &ended}}},
+				})
+				if err != nil {
+					t.Fatalf("marshal supplier: %v", err)
+				}
+				if _, err := dynamoDB.PutItemWithContext(context.Background(), &dynamodb.PutItemInput{TableName: aws.String("orden-compra-suppliers"), Item: item}); err != nil {
+					t.Fatalf("put supplier: %v", err)
+				}
+			}
+			sender := &recordingSender{}
+			h := newPublishingHandler(sender)
+			h.DynamoDB = dynamoDB
+			h.Tenancy = tenant.Policy{DefaultTenant: "tenant-1"}
+			h.Outbox = outbox.NewRelay(dynamoDB, h.publishOutboxMessage, outbox.Config{}, h.Logger)
+
+			// The outcome of a dry run, even a refusal, settles the message
+			ack := &acknowledger{}
+			msg := stockLowDelivery(t, ack)
+			msg.Headers = amqp091.Table{DryRunHeader: "true"}
+			h.processMessage(msg, nil)
+			if !ack.acked || ack.nacked || ack.rejected {
+				t.Fatalf("delivery settled as %+v, want acked", *ack)
+			}
+			for _, table := range []string{"orden-compra-read", "orden-compra-events", "orden-compra-dead-letters", outbox.TableName} {
+				if items := dynamoDB.Items(table); len(items) != 0 {
+					t.Fatalf("dry run stored %d items in %s", len(items), table)
+				}
+			}
+			if len(sender.published) != 0 {
+				t.Fatalf("dry run published %d messages", len(sender.published))
+			}
+		})
+	}
+}
+
+func TestMessageIsNackedWhenTheDeadLetterPublishFails(t *testing.T) {
+	sender := &recordingSender{err: errors.New("broker unavailable")}
+	h := newPublishingHandler(sender)
+	h.DynamoDB = memory.NewDynamoDB(memory.Tables)
+	h.Tenancy = tenant.Policy{DefaultTenant: "tenant-1"}
+
+	// The message is nacked rather than acked as if it reached the DLQ
+	ack := &acknowledger{}
+	msg := stockLowDelivery(t, ack)
+	msg.Body = []byte(`{"product_id":`)
+	h.processMessage(msg, nil)
+	if ack.acked || !ack.nacked {
+		t.Fatalf("delivery settled as %+v, want nacked", *ack)
+	}
+}
+
+func TestStockLowMessageCorrelatesTheOrderEvents(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		headers       amqp091.Table
+		correlationID string
+	}{
+		{"continues the chain of the message", amqp091.Table{"correlation-id": "correlation-1"}, "correlation-1"},
+		{"starts a chain at the message", nil, "stock-low-1"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dynamoDB := memory.NewDynamoDB(memory.Tables)
+			h := newPublishingHandler(&recordingSender{})
+			h.DynamoDB = dynamoDB
+			h.Tenancy = tenant.Policy{DefaultTenant: "tenant-1"}
+			h.Outbox = outbox.NewRelay(dynamoDB, h.publishOutboxMessage, outbox.Config{}, h.Logger)
+
+			msg := stockLowDelivery(t, &acknowledger{})
+			msg.Headers = tc.headers
+			h.processMessage(msg, nil)
+
+			events := dynamoDB.Items("orden-compra-events")
+			if len(events) == 0 {
+				t.Fatal("no events stored")
+			}
+			for _, event := range events {
+				if correlationID := aws.StringValue(event["correlation_id"].S); correlationID != tc.correlationID {
+					t.Fatalf("%s event has correlation ID %q, want %q", aws.StringValue(event["event_type"].S), correlationID, tc.correlationID)
+				}
+				// The message is the cause of the commands it triggers
+				if causationID := aws.StringValue(event["causation_id"].S); causationID != msg.MessageId {
+					t.Fatalf("%s event has causation ID %q, want %q", aws.StringValue(event["event_type"].S), causationID, msg.MessageId)
+				}
+			}
+		})
+	}
+}
+
+func TestStockLowMessagesAreScopedToTheirTenant(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		policy   tenant.Policy
+		headers  amqp091.Table
+		tenantID string
+		reason   string
+	}{
+		{"tenant header", tenant.Policy{Required: true}, amqp091.Table{tenant.MessageHeader: "tenant-2"}, "tenant-2", ""},
+		{"default tenant", tenant.Policy{DefaultTenant: "tenant-1"}, nil, "tenant-1", ""},
+		{"missing tenant", tenant.Policy{Required: true}, nil, "", "invalid_tenant"},
+		{"invalid tenant", tenant.Policy{DefaultTenant: "tenant-1"}, amqp091.Table{tenant.MessageHeader: "tenant 2"}, "", "invalid_tenant"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dynamoDB := memory.NewDynamoDB(memory.Tables)
+			h := newPublishingHandler(&recordingSender{})
+			h.DynamoDB = dynamoDB
+			h.Tenancy = tc.policy
+			h.Outbox = outbox.NewRelay(dynamoDB, h.publishOutboxMessage, outbox.Config{}, h.Logger)
+
+			msg := stockLowDelivery(t, &acknowledger{})
+			msg.Headers = tc.headers
+			h.processMessage(msg, nil)
+
+			orders := dynamoDB.Items("orden-compra-read")
+			if tc.reason != "" {
+				records := dynamoDB.Items("orden-compra-dead-letters")
+				if len(orders) != 0 || len(records) != 1 || aws.StringValue(records[0]["reason"].S) != tc.reason {
+					t.Fatalf("%d orders and dead letters %v, want one %s", len(orders), records, tc.reason)
+				}
+				return
+			}
+			if len(orders) != 1 || aws.StringValue(orders[0][tenant.Attribute].S) != tc.tenantID {
+				t.Fatalf("orders %v, want one owned by %s", orders, tc.tenantID)
+			}
+		})
+	}
+}
+
+func TestStartMessageSpanReadsTheDebugHeader(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		headers amqp091.Table
+		debug   bool
+	}{
+		{"boolean header", amqp091.Table{"debug": true}, true},
+		{"string header", amqp091.Table{"debug": "true"}, true},
+		{"switched off", amqp091.Table{"debug": false}, false},
+		{"no header", nil, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, span := startMessageSpan(context.Background(), "orden-compra.stock-bajo", amqp091.Delivery{Headers: tc.headers})
+			span.End()
+			if observability.IsDebug(ctx) != tc.debug {
+				t.Fatalf("debug %t, want %t", observability.IsDebug(ctx), tc.debug)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"context"
+	"log"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/sirupsen/logrus"
+
+	"orden-compra/internal/cqrs"
+)
+
+// HistoryHandler serves the history of purchase orders derived from their events
+type HistoryHandler struct {
+	DynamoDB    dynamodbiface.DynamoDBAPI
+	QueryLogger *logrus.Logger
+	Logger      *log.Logger
+}
+
+// NewHistoryHandler creates a new history handler
+func NewHistoryHandler(dynamoDB dynamodbiface.DynamoDBAPI, queryLogger *logrus.Logger, logger *log.Logger) *HistoryHandler {
+	return &HistoryHandler{
+		DynamoDB:    dynamoDB,
+		QueryLogger: queryLogger,
+		Logger:      logger,
+	}
+}
+
+// StatusHistory returns the status changes of a purchase order, oldest first
+func (h *HistoryHandler) StatusHistory(ctx context.Context, purchaseOrderID string) (map[string]interface{}, error) {
+	result, err := cqrs.NewGetPurchaseOrderStatusHistoryQuery(purchaseOrderID, h.DynamoDB, h.QueryLogger).Execute(ctx)
+	if err != nil {
+		h.Logger.Printf("Failed to get status history - purchase_order_id: %s: %v", purchaseOrderID, err)
+		return nil, err
+	}
+	return result, nil
+}
+
+// Rehydrate returns a purchase order as rebuilt from its latest snapshot and
+// the events stored after it
+func (h *HistoryHandler) Rehydrate(ctx context.Context, purchaseOrderID string) (map[string]interface{}, error) {
+	return cqrs.NewRehydratePurchaseOrderQuery(purchaseOrderID, h.DynamoDB, h.QueryLogger).Execute(ctx)
+}
@@ -0,0 +1,282 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/rabbitmq/amqp091-go"
+
+	"medisupply/correlation"
+	"medisupply/errortracking"
+	"medisupply/messaging"
+	"medisupply/queue"
+	"orden-compra/internal/audit"
+	"orden-compra/internal/codec"
+	"orden-compra/internal/cqrs"
+	"orden-compra/internal/intake"
+	"orden-compra/internal/models"
+	"orden-compra/internal/slo"
+	"orden-compra/internal/tenant"
+	"orden-compra/internal/webhooks"
+)
+
+// InventoryReceivedConsumer completes purchase orders from the InventarioRecibido
+// events Proveedor produces once a reception passes inspection. It consumes on
+// its own channel and publishes, retries and dead-letters through the stock low
+// handler, so failed events land in the same dead letter queue.
+type InventoryReceivedConsumer struct {
+	Channel      *amqp091.Channel
+	Handler      *RabbitMQHandler
+	QueueName    string
+	ExchangeName string
+	RoutingKey   string
+	Tenancy      tenant.Policy
+	InvoiceMatch models.InvoiceMatchPolicy
+	DynamoDB     dynamodbiface.DynamoDBAPI
+	Stats        cqrs.StatsProjection
+	Webhooks     *webhooks.Dispatcher
+	Audit        *audit.Recorder
+	Consumer     *intake.Consumer
+	Logger       *log.Logger
+	Running      bool
+}
+
+// NewInventoryReceivedConsumer declares the queue bound to the InventarioRecibido
+// events. The invoices of an order invoiced before delivery are matched again
+// with invoiceMatch once it is received.
+func NewInventoryReceivedConsumer(connection *amqp091.Connection, queueName, exchangeName, routingKey string, queueConfig queue.Config, handler *RabbitMQHandler, tenancy tenant.Policy, invoiceMatch models.InvoiceMatchPolicy, dynamoDB dynamodbiface.DynamoDBAPI, webhookDispatcher *webhooks.Dispatcher, auditRecorder *audit.Recorder, logger *log.Logger) (*InventoryReceivedConsumer, error) {
+	channel, err := bindConsumedQueue(connection, queueName, exchangeName, routingKey, queueConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return &InventoryReceivedConsumer{
+		Channel:      channel,
+		Handler:      handler,
+		QueueName:    queueName,
+		ExchangeName: exchangeName,
+		RoutingKey:   routingKey,
+		Tenancy:      tenancy,
+		InvoiceMatch: invoiceMatch,
+		DynamoDB:     dynamoDB,
+		Webhooks:     webhookDispatcher,
+		Audit:        auditRecorder,
+		Logger:       logger,
+		Running:      false,
+	}, nil
+}
+
+// bindConsumedQueue opens a channel and declares a queue bound to the events
+// of routingKey on the topic exchange exchangeName
+func bindConsumedQueue(connection *amqp091.Connection, queueName, exchangeName, routingKey string, queueConfig queue.Config) (*amqp091.Channel, error) {
+	channel, err := connection.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open channel: %w", err)
+	}
+
+	err = channel.ExchangeDeclare(
+		exchangeName, // name
+		"topic",      // type
+		true,         // durable
+		false,        // auto-deleted
+		false,        // internal
+		false,        // no-wait
+		nil,          // arguments
+	)
+	if err != nil {
+		channel.Close()
+		return nil, fmt.Errorf("failed to declare exchange: %w", err)
+	}
+
+	if err := queueConfig.Validate(); err != nil {
+		channel.Close()
+		return nil, fmt.Errorf("invalid queue %s: %w", queueName, err)
+	}
+	_, err = channel.QueueDeclare(
+		queueName,               // name
+		true,                    // durable
+		false,                   // delete when unused
+		false,                   // exclusive
+		false,                   // no-wait
+		queueConfig.Arguments(), // arguments
+	)
+	if err != nil {
+		channel.Close()
+		return nil, fmt.Errorf("failed to declare queue: %w", err)
+	}
+
+	err = channel.QueueBind(
+		queueName,    // queue name
+		routingKey,   // routing key
+		exchangeName, // exchange
+		false,        // no-wait
+		nil,          // arguments
+	)
+	if err != nil {
+		channel.Close()
+		return nil, fmt.Errorf("failed to bind queue: %w", err)
+	}
+
+	return channel, nil
+}
+
+// StartConsuming starts consuming InventarioRecibido events
+func (c *InventoryReceivedConsumer) StartConsuming(consumers *intake.Registry, config intake.Config) error {
+	c.Running = true
+	c.Logger.Printf("Starting inventory received consumer - queue: %s, exchange: %s, routing_key: %s, prefetch: %d, max_in_flight: %d", c.QueueName, c.ExchangeName, c.RoutingKey, config.Prefetch, config.MaxInFlight)
+
+	consumer, err := consumers.Register(InventoryReceivedConsumerName, c.Channel, c.QueueName, config, c.processMessage, inventoryReceivedKey, c.Logger)
+	if err != nil {
+		return err
+	}
+	c.Consumer = consumer
+	return consumer.Start()
+}
+
+// StopConsuming stops consuming once the events being processed finish; the
+// connection is closed by the stock low handler
+func (c *InventoryReceivedConsumer) StopConsuming() {
+	c.Running = false
+	c.Consumer.Stop()
+	if c.Channel != nil {
+		c.Channel.Close()
+	}
+	c.Logger.Println("Inventory received consumer stopped")
+}
+
+// inventoryReceivedKey keys InventarioRecibido events by purchase order, so
+// the receipts of an order are applied in order, and hands the decoded
+// event, or the decoding error, on to processMessage
+func inventoryReceivedKey(msg amqp091.Delivery) (string, interface{}) {
+	contentType := msg.ContentType
+	if contentType == "" {
+		contentType = extractHeader(msg.Headers, "content-type")
+	}
+	event, err := codec.DecodeInventoryReceivedEvent(contentType, msg.Body)
+	if err != nil {
+		return "", err
+	}
+	return event.PurchaseOrderID, event
+}
+
+// processMessage completes the purchase order of a single InventarioRecibido
+// event, decoding it unless inventoryReceivedKey did
+func (c *InventoryReceivedConsumer) processMessage(msg amqp091.Delivery, decoded interface{}) {
+	startTime := time.Now()
+	ctx := messageContext(msg, c.Logger)
+	ctx, span := startMessageSpan(ctx, c.QueueName, msg)
+	defer span.End()
+	ctx = errortracking.WithTags(ctx, map[string]string{"queue": c.QueueName, "message_id": msg.MessageId})
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			c.Handler.rejectPanicked(ctx, c.QueueName, msg, recovered)
+		}
+	}()
+	messaging.RecordAge(ctx, c.QueueName, msg.Timestamp)
+
+	contentType := msg.ContentType
+	if contentType == "" {
+		contentType = extractHeader(msg.Headers, "content-type")
+	}
+
+	var event *models.InventoryReceivedEvent
+	var err error
+	switch decoded := decoded.(type) {
+	case *models.InventoryReceivedEvent:
+		event = decoded
+	case error:
+		err = decoded
+	default:
+		event, err = codec.DecodeInventoryReceivedEvent(contentType, msg.Body)
+	}
+	if err != nil {
+		c.Logger.Printf("Failed to parse inventory received event: %v", err)
+		c.Handler.deadLetter(ctx, c.QueueName, msg, "malformed_payload", models.ValidationErrors{
+			{Field: "body", Message: err.Error()},
+		})
+		return
+	}
+
+	// Scope the message to its tenant; the header takes precedence over the payload
+	tenantID := extractHeader(msg.Headers, tenant.MessageHeader)
+	if tenantID == "" {
+		tenantID = event.TenantID
+	}
+	tenantID, err = c.Tenancy.Resolve(tenantID)
+	if err != nil {
+		c.Logger.Printf("Rejected inventory received event without a valid tenant - message_id: %s, error: %v", msg.MessageId, err)
+		c.Handler.deadLetter(ctx, c.QueueName, msg, "invalid_tenant", models.ValidationErrors{
+			{Field: "tenant_id", Message: err.Error()},
+		})
+		return
+	}
+	event.TenantID = tenantID
+	ctx = tenant.NewContext(ctx, tenantID)
+	ctx = errortracking.WithTags(ctx, map[string]string{"tenant_id": tenantID, "event_id": event.ID, "purchase_order_id": event.PurchaseOrderID})
+
+	if err := event.Validate(); err != nil {
+		c.Logger.Printf("Invalid inventory received event - message_id: %s, error: %v", msg.MessageId, err)
+		var validationErrors models.ValidationErrors
+		if !errors.As(err, &validationErrors) {
+			validationErrors = models.ValidationErrors{{Field: "body", Message: err.Error()}}
+		}
+		c.Handler.deadLetter(ctx, c.QueueName, msg, "validation_failed", validationErrors)
+		return
+	}
+
+	before := c.Audit.PurchaseOrder(ctx, event.PurchaseOrderID)
+	command := cqrs.NewReceiveInventoryCommand(
+		event,
+		c.DynamoDB,
+		c.Logger,
+		correlation.CorrelationID(ctx),
+		correlation.CausationID(ctx),
+	)
+	command.Stats = c.Stats
+	command.InvoiceMatch = c.InvoiceMatch
+	result, err := command.Execute(ctx)
+	switch {
+	case errors.Is(err, cqrs.ErrPurchaseOrderNotFound):
+		c.Logger.Printf("Inventory received for unknown purchase order - purchase_order_id: %s, event_id: %s", event.PurchaseOrderID, event.ID)
+		c.Handler.deadLetter(ctx, c.QueueName, msg, "unknown_purchase_order", models.ValidationErrors{
+			{Field: "purchase_order_id", Message: err.Error()},
+		})
+		return
+	case errors.Is(err, cqrs.ErrReceiptNotAllowed):
+		c.Logger.Printf("Inventory received for purchase order that cannot be completed - purchase_order_id: %s, error: %v", event.PurchaseOrderID, err)
+		c.Audit.Record(ctx, models.AuditPurchaseOrderCompleted, models.AuditResourcePurchaseOrder, event.PurchaseOrderID, before, before, err)
+		c.Handler.deadLetter(ctx, c.QueueName, msg, "receipt_not_allowed", models.ValidationErrors{
+			{Field: "purchase_order_id", Message: err.Error()},
+		})
+		return
+	case err != nil:
+		c.Logger.Printf("Failed to process inventory received event: %v", err)
+		c.Handler.retry(ctx, c.QueueName, msg, err)
+		return
+	}
+
+	if purchaseOrder, ok := result["purchase_order"].(*models.PurchaseOrder); ok {
+		if purchaseOrder.Status == models.StatusCompleted {
+			c.Audit.Record(ctx, models.AuditPurchaseOrderCompleted, models.AuditResourcePurchaseOrder, purchaseOrder.ID, before, purchaseOrder, nil)
+			c.Webhooks.Dispatch(ctx, models.WebhookPurchaseOrderCompleted, purchaseOrder)
+		} else {
+			c.Audit.Record(ctx, models.AuditPurchaseOrderReceived, models.AuditResourcePurchaseOrder, purchaseOrder.ID, before, purchaseOrder, nil)
+		}
+		c.Handler.SLO.Record(ctx, purchaseOrder.ID, purchaseOrder.UrgencyLevel, map[string]time.Time{
+			slo.StageInventoryReceived: event.ReceivedAt.UTC(),
+		})
+	}
+	if completedEvent, ok := result["completed_event"].(*models.PurchaseOrderCompletedEvent); ok {
+		if err := c.Handler.PublishCompletionEvent(ctx, completedEvent); err != nil {
+			c.Logger.Printf("Failed to produce completion event: %v", err)
+		}
+	}
+	c.Handler.announceInvoiceMismatch(ctx, result)
+
+	msg.Ack(false)
+
+	c.Logger.Printf("Inventory received event processed - event_id: %s, tenant_id: %s, purchase_order_id: %s, processing_time: %v, duplicate: %v", event.ID, tenantID, event.PurchaseOrderID, time.Since(startTime), result["duplicate"] == true)
+}
@@ -0,0 +1,290 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/rabbitmq/amqp091-go"
+
+	"medisupply/correlation"
+	"medisupply/errortracking"
+	"medisupply/messaging"
+	"medisupply/queue"
+	"orden-compra/internal/audit"
+	"orden-compra/internal/codec"
+	"orden-compra/internal/cqrs"
+	"orden-compra/internal/intake"
+	"orden-compra/internal/models"
+	"orden-compra/internal/tenant"
+)
+
+// InvoiceHandler handles the supplier invoices of purchase orders and their
+// three-way match against the order and its receipt
+type InvoiceHandler struct {
+	DynamoDB  dynamodbiface.DynamoDBAPI
+	Stats     cqrs.StatsProjection
+	Publisher *RabbitMQHandler
+	Policy    models.InvoiceMatchPolicy
+	Audit     *audit.Recorder
+	Logger    *log.Logger
+}
+
+// NewInvoiceHandler creates a new invoice handler
+func NewInvoiceHandler(dynamoDB dynamodbiface.DynamoDBAPI, publisher *RabbitMQHandler, policy models.InvoiceMatchPolicy, auditRecorder *audit.Recorder, logger *log.Logger) *InvoiceHandler {
+	return &InvoiceHandler{
+		DynamoDB:  dynamoDB,
+		Publisher: publisher,
+		Policy:    policy,
+		Audit:     auditRecorder,
+		Logger:    logger,
+	}
+}
+
+// AttachInvoice attaches a supplier invoice entered through the API to a
+// purchase order and announces a failed match to finance
+func (h *InvoiceHandler) AttachInvoice(ctx context.Context, purchaseOrderID string, invoice *models.SupplierInvoice) (map[string]interface{}, error) {
+	// Only FacturaRecibida events carry an event ID
+	if invoice != nil {
+		invoice.EventID = ""
+	}
+	return h.attach(ctx, purchaseOrderID, invoice)
+}
+
+// GetInvoiceMatch returns the invoices of a purchase order and their match
+// against the order and its receipt, computed with the current policy
+func (h *InvoiceHandler) GetInvoiceMatch(ctx context.Context, purchaseOrderID string) (map[string]interface{}, error) {
+	purchaseOrder, err := cqrs.FindPurchaseOrder(ctx, h.DynamoDB, purchaseOrderID, false)
+	if err != nil {
+		return nil, err
+	}
+
+	invoices := purchaseOrder.Invoices
+	if invoices == nil {
+		invoices = []models.SupplierInvoice{}
+	}
+
+	return map[string]interface{}{
+		"success":           true,
+		"purchase_order_id": purchaseOrder.ID,
+		"supplier_id":       purchaseOrder.SupplierID,
+		"status":            purchaseOrder.Status,
+		"pricing":           purchaseOrder.Pricing,
+		"receipt":           purchaseOrder.Receipt,
+		"invoices":          invoices,
+		"invoice_match":     models.MatchInvoices(purchaseOrder, h.Policy, time.Now()),
+	}, nil
+}
+
+// attach runs the attach invoice command and announces a failed match
+func (h *InvoiceHandler) attach(ctx context.Context, purchaseOrderID string, invoice *models.SupplierInvoice) (map[string]interface{}, error) {
+	before := h.Audit.PurchaseOrder(ctx, purchaseOrderID)
+
+	command := cqrs.NewAttachInvoiceCommand(
+		purchaseOrderID,
+		invoice,
+		h.Policy,
+		h.DynamoDB,
+		h.Logger,
+		correlation.CorrelationID(ctx),
+		correlation.CausationID(ctx),
+	)
+	command.Stats = h.Stats
+
+	result, err := command.Execute(ctx)
+	if err != nil {
+		h.Audit.Record(ctx, models.AuditPurchaseOrderInvoiced, models.AuditResourcePurchaseOrder, purchaseOrderID, before, before, err)
+		return nil, err
+	}
+	if result["duplicate"] == true {
+		return result, nil
+	}
+	h.Audit.Record(ctx, models.AuditPurchaseOrderInvoiced, models.AuditResourcePurchaseOrder, purchaseOrderID, before, result["purchase_order"], nil)
+
+	h.Publisher.announceInvoiceMismatch(ctx, result)
+	delete(result, "purchase_order")
+
+	return result, nil
+}
+
+// InvoiceReceivedConsumer attaches the supplier invoices of the FacturaRecibida
+// events the accounts payable system produces. Like the inventory received
+// consumer, it retries and dead-letters through the stock low handler.
+type InvoiceReceivedConsumer struct {
+	Channel      *amqp091.Channel
+	Handler      *RabbitMQHandler
+	Invoices     *InvoiceHandler
+	QueueName    string
+	ExchangeName string
+	RoutingKey   string
+	Tenancy      tenant.Policy
+	Consumer     *intake.Consumer
+	Logger       *log.Logger
+	Running      bool
+}
+
+// NewInvoiceReceivedConsumer declares the queue bound to the FacturaRecibida events
+func NewInvoiceReceivedConsumer(connection *amqp091.Connection, queueName, exchangeName, routingKey string, queueConfig queue.Config, handler *RabbitMQHandler, invoices *InvoiceHandler, tenancy tenant.Policy, logger *log.Logger) (*InvoiceReceivedConsumer, error) {
+	channel, err := bindConsumedQueue(connection, queueName, exchangeName, routingKey, queueConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return &InvoiceReceivedConsumer{
+		Channel:      channel,
+		Handler:      handler,
+		Invoices:     invoices,
+		QueueName:    queueName,
+		ExchangeName: exchangeName,
+		RoutingKey:   routingKey,
+		Tenancy:      tenancy,
+		Logger:       logger,
+		Running:      false,
+	}, nil
+}
+
+// StartConsuming starts consuming FacturaRecibida events
+func (c *InvoiceReceivedConsumer) StartConsuming(consumers *intake.Registry, config intake.Config) error {
+	c.Running = true
+	c.Logger.Printf("Starting invoice received consumer - queue: %s, exchange: %s, routing_key: %s, prefetch: %d, max_in_flight: %d", c.QueueName, c.ExchangeName, c.RoutingKey, config.Prefetch, config.MaxInFlight)
+
+	consumer, err := consumers.Register(InvoiceReceivedConsumerName, c.Channel, c.QueueName, config, c.processMessage, invoiceReceivedKey, c.Logger)
+	if err != nil {
+		return err
+	}
+	c.Consumer = consumer
+	return consumer.Start()
+}
+
+// StopConsuming stops consuming once the events being processed finish; the
+// connection is closed by the stock low handler
+func (c *InvoiceReceivedConsumer) StopConsuming() {
+	c.Running = false
+	c.Consumer.Stop()
+	if c.Channel != nil {
+		c.Channel.Close()
+	}
+	c.Logger.Println("Invoice received consumer stopped")
+}
+
+// invoiceReceivedKey keys FacturaRecibida events by purchase order, so the
+// invoices of an order are matched in order, and hands the decoded event,
+// or the decoding error, on to processMessage
+func invoiceReceivedKey(msg amqp091.Delivery) (string, interface{}) {
+	contentType := msg.ContentType
+	if contentType == "" {
+		contentType = extractHeader(msg.Headers, "content-type")
+	}
+	event, err := codec.DecodeInvoiceReceivedEvent(contentType, msg.Body)
+	if err != nil {
+		return "", err
+	}
+	return event.PurchaseOrderID, event
+}
+
+// processMessage attaches the invoice of a single FacturaRecibida event,
+// decoding it unless invoiceReceivedKey did
+func (c *InvoiceReceivedConsumer) processMessage(msg amqp091.Delivery, decoded interface{}) {
+	startTime := time.Now()
+	ctx := messageContext(msg, c.Logger)
+	ctx, span := startMessageSpan(ctx, c.QueueName, msg)
+	defer span.End()
+	ctx = errortracking.WithTags(ctx, map[string]string{"queue": c.QueueName, "message_id": msg.MessageId})
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			c.Handler.rejectPanicked(ctx, c.QueueName, msg, recovered)
+		}
+	}()
+	messaging.RecordAge(ctx, c.QueueName, msg.Timestamp)
+
+	contentType := msg.ContentType
+	if contentType == "" {
+		contentType = extractHeader(msg.Headers, "content-type")
+	}
+
+	var event *models.InvoiceReceivedEvent
+	var err error
+	switch decoded := decoded.(type) {
+	case *models.InvoiceReceivedEvent:
+		event = decoded
+	case error:
+		err = decoded
+	default:
+		event, err = codec.DecodeInvoiceReceivedEvent(contentType, msg.Body)
+	}
+	if err != nil {
+		c.Logger.Printf("Failed to parse invoice received event: %v", err)
+		c.Handler.deadLetter(ctx, c.QueueName, msg, "malformed_payload", models.ValidationErrors{
+			{Field: "body", Message: err.Error()},
+		})
+		return
+	}
+
+	// Scope the message to its tenant; the header takes precedence over the payload
+	tenantID := extractHeader(msg.Headers, tenant.MessageHeader)
+	if tenantID == "" {
+		tenantID = event.TenantID
+	}
+	tenantID, err = c.Tenancy.Resolve(tenantID)
+	if err != nil {
+		c.Logger.Printf("Rejected invoice received event without a valid tenant - message_id: %s, error: %v", msg.MessageId, err)
+		c.Handler.deadLetter(ctx, c.QueueName, msg, "invalid_tenant", models.ValidationErrors{
+			{Field: "tenant_id", Message: err.Error()},
+		})
+		return
+	}
+	event.TenantID = tenantID
+	ctx = tenant.NewContext(ctx, tenantID)
+	ctx = errortracking.WithTags(ctx, map[string]string{"tenant_id": tenantID, "event_id": event.ID, "purchase_order_id": event.PurchaseOrderID})
+
+	if err := event.Validate(); err != nil {
+		c.Logger.Printf("Invalid invoice received event - message_id: %s, error: %v", msg.MessageId, err)
+		var validationErrors models.ValidationErrors
+		if !errors.As(err, &validationErrors) {
+			validationErrors = models.ValidationErrors{{Field: "body", Message: err.Error()}}
+		}
+		c.Handler.deadLetter(ctx, c.QueueName, msg, "validation_failed", validationErrors)
+		return
+	}
+
+	result, err := c.Invoices.attach(ctx, event.PurchaseOrderID, event.Invoice())
+	var validationErrors models.ValidationErrors
+	switch {
+	case errors.Is(err, cqrs.ErrPurchaseOrderNotFound):
+		c.Logger.Printf("Invoice received for unknown purchase order - purchase_order_id: %s, event_id: %s", event.PurchaseOrderID, event.ID)
+		c.Handler.deadLetter(ctx, c.QueueName, msg, "unknown_purchase_order", models.ValidationErrors{
+			{Field: "purchase_order_id", Message: err.Error()},
+		})
+		return
+	case errors.Is(err, cqrs.ErrInvoiceNotAllowed):
+		c.Logger.Printf("Invoice received for purchase order that cannot be invoiced - purchase_order_id: %s, error: %v", event.PurchaseOrderID, err)
+		c.Handler.deadLetter(ctx, c.QueueName, msg, "invoice_not_allowed", models.ValidationErrors{
+			{Field: "purchase_order_id", Message: err.Error()},
+		})
+		return
+	case errors.Is(err, cqrs.ErrDuplicateInvoice):
+		c.Logger.Printf("Duplicate invoice received - purchase_order_id: %s, invoice_number: %s", event.PurchaseOrderID, event.InvoiceNumber)
+		c.Handler.deadLetter(ctx, c.QueueName, msg, "duplicate_invoice", models.ValidationErrors{
+			{Field: "invoice_number", Message: err.Error()},
+		})
+		return
+	case errors.As(err, &validationErrors):
+		c.Logger.Printf("Invoice rejected - purchase_order_id: %s, error: %v", event.PurchaseOrderID, err)
+		c.Handler.deadLetter(ctx, c.QueueName, msg, "validation_failed", validationErrors)
+		return
+	case err != nil:
+		c.Logger.Printf("Failed to process invoice received event: %v", err)
+		c.Handler.retry(ctx, c.QueueName, msg, err)
+		return
+	}
+
+	msg.Ack(false)
+
+	status := ""
+	if match, ok := result["invoice_match"].(*models.InvoiceMatch); ok && match != nil {
+		status = match.Status
+	}
+	c.Logger.Printf("Invoice received event processed - event_id: %s, tenant_id: %s, purchase_order_id: %s, match_status: %s, processing_time: %v, duplicate: %v", event.ID, tenantID, event.PurchaseOrderID, status, time.Since(startTime), result["duplicate"] == true)
+}
@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/rabbitmq/amqp091-go"
+
+	"orden-compra/internal/audit"
+	"orden-compra/internal/cqrs"
+	"orden-compra/internal/memory"
+	"orden-compra/internal/models"
+	"orden-compra/internal/tenant"
+)
+
+// newInvoiceHandler returns an invoice handler on an order of 10 units priced
+// at 2 USD, publishing through sender
+func newInvoiceHandler(t *testing.T, sender *recordingSender) (*InvoiceHandler, *audit.Store, string) {
+	t.Helper()
+	publisher := newRetryingHandler(sender)
+	dynamoDB := publisher.DynamoDB.(*memory.DynamoDB)
+	order := models.NewPurchaseOrder("product-1", "Gloves", "supplier-1", "Acme", "warehouse-1", "high", 10, time.Now())
+	order.Pricing = &models.OrderPricing{Currency: "USD", UnitPrice: 2, TotalAmount: 20}
+	if _, err := cqrs.NewCreatePurchaseOrderCommand(order, dynamoDB, publisher.Logger, nil, nil).Execute(context.Background()); err != nil {
+		t.Fatalf("create purchase order: %v", err)
+	}
+
+	auditStore := audit.NewStore(dynamoDB)
+	recorder := audit.NewRecorder(auditStore, dynamoDB, publisher.Logger)
+	return NewInvoiceHandler(dynamoDB, publisher, models.DefaultInvoiceMatchPolicy(), recorder, publisher.Logger), auditStore, order.ID
+}
+
+// invoiceReceivedDelivery returns a FacturaRecibida delivery settled through ack
+func invoiceReceivedDelivery(t *testing.T, ack *acknowledger, event *models.InvoiceReceivedEvent) amqp091.Delivery {
+	t.Helper()
+	body, err := json.Marshal(event)
+	if err != nil {
+		t.Fatalf("marshal invoice received event: %v", err)
+	}
+	return amqp091.Delivery{
+		Acknowledger: ack,
+		ContentType:  "application/json",
+		MessageId:    event.ID,
+		Body:         body,
+	}
+}
+
+func TestAttachInvoiceAnnouncesAMismatch(t *testing.T) {
+	sender := &recordingSender{}
+	h, auditStore, id := newInvoiceHandler(t, sender)
+
+	// Only FacturaRecibida events carry an event ID
+	invoice := &models.SupplierInvoice{
+		InvoiceNumber: "INV-1",
+		EventID:       "event-1",
+		IssuedAt:      time.Now(),
+		Lines:         []models.InvoiceLine{{Quantity: 10, UnitPrice: 3}},
+	}
+	result, err := h.AttachInvoice(context.Background(), id, invoice)
+	if err != nil {
+		t.Fatalf("attach invoice: %v", err)
+	}
+	if attached := result["invoice"].(*models.SupplierInvoice); attached.EventID != "" {
+		t.Fatalf("attached invoice kept event ID %q", attached.EventID)
+	}
+	if _, ok := result["purchase_order"]; ok {
+		t.Fatal("result carries the whole purchase order")
+	}
+
+	if len(sender.published) != 1 || sender.published[0].routingKey != InvoiceMismatchRoutingKey {
+		t.Fatalf("published %+v, want the mismatch event", sender.published)
+	}
+	if entries, err := auditStore.Query(context.Background(), audit.Filter{ResourceID: id, Action: models.AuditPurchaseOrderInvoiced}); err != nil || len(entries) != 1 {
+		t.Fatalf("audited %d invoiced entries, error %v", len(entries), err)
+	}
+
+	matched, err := h.GetInvoiceMatch(context.Background(), id)
+	if err != nil {
+		t.Fatalf("get invoice match: %v", err)
+	}
+	if invoices := matched["invoices"].([]models.SupplierInvoice); len(invoices) != 1 || invoices[0].InvoiceNumber != "INV-1" {
+		t.Fatalf("invoices %+v", invoices)
+	}
+	if match := matched["invoice_match"].(*models.InvoiceMatch); match.Status != models.InvoiceMatchMismatch || match.InvoicedAmount != 30 || match.ExpectedAmount != 20 {
+		t.Fatalf("match %+v", match)
+	}
+	if _, err := h.GetInvoiceMatch(context.Background(), "po-9"); !errors.Is(err, cqrs.ErrPurchaseOrderNotFound) {
+		t.Fatalf("unknown order returned %v", err)
+	}
+}
+
+func TestInvoiceReceivedMessages(t *testing.T) {
+	sender := &recordingSender{}
+	h, _, id := newInvoiceHandler(t, sender)
+	c := &InvoiceReceivedConsumer{
+		Handler:   h.Publisher,
+		Invoices:  h,
+		QueueName: "orden-compra.factura-recibida",
+		Tenancy:   tenant.Policy{DefaultTenant: "tenant-1"},
+		Logger:    h.Logger,
+	}
+	event := func(eventID, purchaseOrderID, invoiceNumber string) *models.InvoiceReceivedEvent {
+		return &models.InvoiceReceivedEvent{
+			ID:              eventID,
+			EventType:       models.InvoiceReceivedEventType,
+			PurchaseOrderID: purchaseOrderID,
+			InvoiceNumber:   invoiceNumber,
+			Currency:        "USD",
+			IssuedAt:        time.Now(),
+			Lines:           []models.InvoiceLine{{Quantity: 10, UnitPrice: 2}},
+		}
+	}
+
+	for _, tc := range []struct {
+		name   string
+		event  *models.InvoiceReceivedEvent
+		body   []byte
+		reason string
+	}{
+		{"attached", event("event-1", id, "INV-1"), nil, ""},
+		{"redelivered", event("event-1", id, "INV-1"), nil, ""},
+		{"malformed", nil, []byte(`{"id":`), "malformed_payload"},
+		{"invalid", event("event-2", id, ""), nil, "validation_failed"},
+		{"unknown order", event("event-3", "po-9", "INV-3"), nil, "unknown_purchase_order"},
+		{"duplicate invoice", event("event-4", id, "INV-1"), nil, "duplicate_invoice"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ack := &acknowledger{}
+			msg := amqp091.Delivery{Acknowledger: ack, ContentType: "application/json", MessageId: "malformed-1", Body: tc.body}
+			if tc.event != nil {
+				msg = invoiceReceivedDelivery(t, ack, tc.event)
+			}
+			before := len(h.DynamoDB.(*memory.DynamoDB).Items("orden-compra-dead-letters"))
+
+			_, decoded := invoiceReceivedKey(msg)
+			c.processMessage(msg, decoded)
+
+			records := h.DynamoDB.(*memory.DynamoDB).Items("orden-compra-dead-letters")
+			if tc.reason == "" {
+				if !ack.acked || len(records) != before {
+					t.Fatalf("delivery settled as %+v with %d new dead letters, want acked", *ack, len(records)-before)
+				}
+				return
+			}
+			reason := ""
+			for _, record := range records {
+				if aws.StringValue(record["message_id"].S) == msg.MessageId {
+					reason = aws.StringValue(record["reason"].S)
+				}
+			}
+			if len(records) != before+1 || reason != tc.reason {
+				t.Fatalf("dead-lettered %s as %q, want %s", msg.MessageId, reason, tc.reason)
+			}
+		})
+	}
+
+	// The redelivered event attached its invoice only once
+	matched, err := h.GetInvoiceMatch(context.Background(), id)
+	if err != nil {
+		t.Fatalf("get invoice match: %v", err)
+	}
+	invoices := matched["invoices"].([]models.SupplierInvoice)
+	if len(invoices) != 1 || invoices[0].EventID != "event-1" {
+		t.Fatalf("invoices %+v, want the one of event-1", invoices)
+	}
+	if match := matched["invoice_match"].(*models.InvoiceMatch); match.Status != models.InvoiceMatchAwaitingReceipt {
+		t.Fatalf("match %+v", match)
+	}
+}
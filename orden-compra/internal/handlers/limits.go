@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"orden-compra/internal/audit"
+	"orden-compra/internal/limiter"
+	"orden-compra/internal/models"
+)
+
+// LimitsHandler exposes the dependency concurrency limiters for runtime tuning
+type LimitsHandler struct {
+	Registry *limiter.Registry
+	Audit    *audit.Recorder
+	Logger   *log.Logger
+}
+
+// NewLimitsHandler creates a new limits handler
+func NewLimitsHandler(registry *limiter.Registry, auditRecorder *audit.Recorder, logger *log.Logger) *LimitsHandler {
+	return &LimitsHandler{
+		Registry: registry,
+		Audit:    auditRecorder,
+		Logger:   logger,
+	}
+}
+
+// GetLimits returns the current state of every dependency limiter
+func (h *LimitsHandler) GetLimits() map[string]interface{} {
+	return map[string]interface{}{
+		"success":  true,
+		"limiters": h.Registry.Stats(),
+	}
+}
+
+// UpdateLimit reconfigures the limiter for a dependency
+func (h *LimitsHandler) UpdateLimit(ctx context.Context, name string, config limiter.Config) (map[string]interface{}, error) {
+	l, ok := h.Registry.Get(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown dependency: %s", name)
+	}
+
+	before := l.Stats()
+	if err := l.Configure(config); err != nil {
+		return nil, fmt.Errorf("invalid limiter config: %w", err)
+	}
+	h.Audit.Record(ctx, models.AuditDependencyLimitUpdated, models.AuditResourceDependencyLimit, name, before, l.Stats(), nil)
+
+	h.Logger.Printf("Dependency limiter updated - dependency: %s, max_concurrency: %d, max_queue: %d, queue_timeout: %v", name, config.MaxConcurrency, config.MaxQueue, config.QueueTimeout)
+
+	return map[string]interface{}{
+		"success": true,
+		"limiter": l.Stats(),
+	}, nil
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"context"
+	"log"
+
+	"orden-compra/internal/audit"
+	"orden-compra/internal/logging"
+	"orden-compra/internal/models"
+)
+
+// LogLevelHandler exposes the service log level for runtime adjustment
+type LogLevelHandler struct {
+	Leveler *logging.Leveler
+	Audit   *audit.Recorder
+	Logger  *log.Logger
+}
+
+// NewLogLevelHandler creates a new log level handler
+func NewLogLevelHandler(leveler *logging.Leveler, auditRecorder *audit.Recorder, logger *log.Logger) *LogLevelHandler {
+	return &LogLevelHandler{
+		Leveler: leveler,
+		Audit:   auditRecorder,
+		Logger:  logger,
+	}
+}
+
+// GetLogLevel returns the current log level
+func (h *LogLevelHandler) GetLogLevel() map[string]interface{} {
+	return map[string]interface{}{
+		"success": true,
+		"level":   h.Leveler.Level().String(),
+	}
+}
+
+// SetLogLevel changes the log level until the next restart
+func (h *LogLevelHandler) SetLogLevel(ctx context.Context, name string) (map[string]interface{}, error) {
+	level, err := logging.ParseLevel(name)
+	if err != nil {
+		return nil, err
+	}
+
+	before := h.GetLogLevel()
+	h.Leveler.SetLevel(level)
+	h.Audit.Record(ctx, models.AuditLogLevelUpdated, models.AuditResourceLogLevel, "orden-compra", before, h.GetLogLevel(), nil)
+
+	// Logged as a warning so the change is visible at every level
+	h.Logger.Printf("WARNING: log level changed - from: %s, to: %s", before["level"], level)
+
+	return h.GetLogLevel(), nil
+}
@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"testing"
+
+	"orden-compra/internal/logging"
+)
+
+func TestSetLogLevel(t *testing.T) {
+	leveler := logging.NewLeveler(logging.LevelInfo)
+	h := NewLogLevelHandler(leveler, nil, log.New(io.Discard, "", 0))
+
+	result, err := h.SetLogLevel(context.Background(), "debug")
+	if err != nil || result["level"] != "debug" || leveler.Level() != logging.LevelDebug {
+		t.Fatalf("set level returned %v, error %v, leveler at %s", result, err, leveler.Level())
+	}
+
+	// An unknown level leaves the current one in place
+	if _, err := h.SetLogLevel(context.Background(), "verbose"); !errors.Is(err, logging.ErrInvalidLevel) {
+		t.Fatalf("unknown level returned %v", err)
+	}
+	if level := h.GetLogLevel()["level"]; level != "debug" {
+		t.Fatalf("level %v after an invalid change", level)
+	}
+}
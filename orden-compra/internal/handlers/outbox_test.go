@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rabbitmq/amqp091-go"
+
+	"medisupply/clock"
+	"orden-compra/internal/memory"
+	"orden-compra/internal/models"
+	"orden-compra/internal/outbox"
+	"orden-compra/internal/tenant"
+)
+
+// acknowledger records how a delivery was settled
+type acknowledger struct {
+	acked, nacked, rejected bool
+}
+
+func (a *acknowledger) Ack(tag uint64, multiple bool) error {
+	a.acked = true
+	return nil
+}
+
+func (a *acknowledger) Nack(tag uint64, multiple, requeue bool) error {
+	a.nacked = true
+	return nil
+}
+
+func (a *acknowledger) Reject(tag uint64, requeue bool) error {
+	a.rejected = true
+	return nil
+}
+
+// stockLowDelivery returns a StockBajo delivery settled through ack
+func stockLowDelivery(t *testing.T, ack *acknowledger) amqp091.Delivery {
+	t.Helper()
+	body, err := json.Marshal(models.StockLowEvent{
+		ID:           "stock-low-1",
+		Timestamp:    time.Now().UTC(),
+		EventType:    models.StockLowEventType,
+		ProductID:    "product-1",
+		ProductName:  "Gloves",
+		CurrentStock: 5,
+		MinimumStock: 20,
+		Location:     "warehouse-1",
+		UrgencyLevel: "high",
+		Metadata:     map[string]interface{}{"supplier_id": "supplier-1", "supplier_name": "Acme"},
+	})
+	if err != nil {
+		t.Fatalf("marshal stock low event: %v", err)
+	}
+	return amqp091.Delivery{
+		Acknowledger: ack,
+		ContentType:  "application/json",
+		MessageId:    "stock-low-1",
+		Body:         body,
+	}
+}
+
+func TestFailedPublishStaysInTheOutbox(t *testing.T) {
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	sender := &recordingSender{err: errors.New("broker unavailable")}
+	h := newPublishingHandler(sender)
+	h.DynamoDB = dynamoDB
+	h.Tenancy = tenant.Policy{DefaultTenant: "tenant-1"}
+	h.Outbox = outbox.NewRelay(dynamoDB, h.publishOutboxMessage, outbox.Config{Interval: time.Minute, Grace: time.Minute}, h.Logger)
+	fake := clock.NewFake(time.Now())
+	h.Outbox.Clock = fake
+
+	ack := &acknowledger{}
+	h.processMessage(stockLowDelivery(t, ack), nil)
+
+	// The order and its events are stored together, so the alert is settled
+	// while the events wait in the outbox rather than being dropped
+	if !ack.acked || ack.nacked || ack.rejected {
+		t.Fatalf("delivery settled as %+v, want acked", *ack)
+	}
+	if orders := dynamoDB.Items("orden-compra-read"); len(orders) != 1 {
+		t.Fatalf("stored %d orders, want 1", len(orders))
+	}
+	if messages := dynamoDB.Items(outbox.TableName); len(messages) != 2 {
+		t.Fatalf("outbox holds %d messages, want the reception and order placed events", len(messages))
+	}
+
+	// Once the broker is back the relay publishes them and clears the outbox
+	sender.mu.Lock()
+	sender.err = nil
+	sender.mu.Unlock()
+	fake.Advance(2 * time.Minute)
+	if _, err := h.Outbox.Sweep(context.Background()); err != nil {
+		t.Fatalf("sweep: %v", err)
+	}
+
+	routingKeys := map[string]bool{}
+	for _, message := range sender.published {
+		routingKeys[message.routingKey] = true
+		if message.msg.Headers[tenant.MessageHeader] != "tenant-1" {
+			t.Errorf("%s published for tenant %v, want tenant-1", message.routingKey, message.msg.Headers[tenant.MessageHeader])
+		}
+	}
+	if len(sender.published) != 2 || !routingKeys[ReceptionRoutingKey] || !routingKeys[OrderPlacedRoutingKey] {
+		t.Fatalf("relay published %v, want the reception and order placed events", routingKeys)
+	}
+	if messages := dynamoDB.Items(outbox.TableName); len(messages) != 0 {
+		t.Fatalf("outbox still holds %d messages", len(messages))
+	}
+}
+
+func TestPublishedEventsLeaveTheOutbox(t *testing.T) {
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	sender := &recordingSender{}
+	h := newPublishingHandler(sender)
+	h.DynamoDB = dynamoDB
+	h.Tenancy = tenant.Policy{DefaultTenant: "tenant-1"}
+	h.Outbox = outbox.NewRelay(dynamoDB, h.publishOutboxMessage, outbox.Config{}, h.Logger)
+
+	ack := &acknowledger{}
+	h.processMessage(stockLowDelivery(t, ack), nil)
+
+	if !ack.acked {
+		t.Fatalf("delivery settled as %+v, want acked", *ack)
+	}
+	if len(sender.published) != 2 {
+		t.Fatalf("published %d events, want 2", len(sender.published))
+	}
+	if messages := dynamoDB.Items(outbox.TableName); len(messages) != 0 {
+		t.Fatalf("outbox still holds %d messages", len(messages))
+	}
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+
+	"orden-compra/internal/audit"
+	"orden-compra/internal/cqrs"
+	"orden-compra/internal/models"
+	"orden-compra/internal/notify"
+	"orden-compra/internal/tenant"
+	"orden-compra/internal/webhooks"
+)
+
+// OverdueDetector periodically looks for purchase orders past their expected
+// date and notifies about each one once
+type OverdueDetector struct {
+	DynamoDB dynamodbiface.DynamoDBAPI
+	Stats    cqrs.StatsProjection
+	Notifier *notify.Notifier
+	Webhooks *webhooks.Dispatcher
+	Audit    *audit.Recorder
+	Interval time.Duration
+	Logger   *log.Logger
+}
+
+// NewOverdueDetector creates a new overdue detector
+func NewOverdueDetector(dynamoDB dynamodbiface.DynamoDBAPI, notifier *notify.Notifier, webhookDispatcher *webhooks.Dispatcher, auditRecorder *audit.Recorder, interval time.Duration, logger *log.Logger) *OverdueDetector {
+	return &OverdueDetector{
+		DynamoDB: dynamoDB,
+		Notifier: notifier,
+		Webhooks: webhookDispatcher,
+		Audit:    auditRecorder,
+		Interval: interval,
+		Logger:   logger,
+	}
+}
+
+// Start runs a detection every interval until the context is cancelled
+func (d *OverdueDetector) Start(ctx context.Context) {
+	d.Logger.Printf("Starting overdue detector - interval: %v", d.Interval)
+
+	ticker := time.NewTicker(d.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			d.Logger.Println("Overdue detector stopped")
+			return
+		case <-ticker.C:
+			if _, err := d.RunOnce(ctx); err != nil {
+				d.Logger.Printf("Overdue detection failed: %v", err)
+			}
+		}
+	}
+}
+
+// RunOnce detects newly overdue orders and notifies about them
+func (d *OverdueDetector) RunOnce(ctx context.Context) (map[string]interface{}, error) {
+	ctx = audit.WithOrigin(ctx, audit.SystemOrigin("overdue-detector"))
+	command := cqrs.NewDetectOverduePurchaseOrdersCommand(d.DynamoDB, d.Logger, nil, nil)
+	command.Stats = d.Stats
+
+	result, err := command.Execute(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	overdueOrders, _ := result["purchase_orders"].([]*models.PurchaseOrder)
+	for _, po := range overdueOrders {
+		// The detector works across tenants; each order is reported to its own
+		orderCtx := tenant.NewContext(ctx, po.TenantID)
+		d.Audit.Record(orderCtx, models.AuditPurchaseOrderOverdue, models.AuditResourcePurchaseOrder, po.ID, nil, po, nil)
+		d.Notifier.Notify(notify.NewNotification(notify.EventPurchaseOrderOverdue, po.ID, purchaseOrderNotificationData(po)))
+		d.Webhooks.Dispatch(orderCtx, models.WebhookPurchaseOrderOverdue, po)
+	}
+
+	return result, nil
+}
+
+// purchaseOrderNotificationData exposes the purchase order fields used by notification templates
+func purchaseOrderNotificationData(po *models.PurchaseOrder) map[string]interface{} {
+	data := map[string]interface{}{
+		"tenant_id":     po.TenantID,
+		"product_id":    po.ProductID,
+		"product_name":  po.ProductName,
+		"quantity":      po.Quantity,
+		"supplier_id":   po.SupplierID,
+		"supplier_name": po.SupplierName,
+		"location":      po.Location,
+		"urgency_level": po.UrgencyLevel,
+		"status":        po.Status,
+	}
+	if po.ExpectedDate != nil {
+		data["expected_date"] = po.ExpectedDate.Format("2006-01-02")
+	}
+	return data
+}
@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rabbitmq/amqp091-go"
+
+	"medisupply/correlation"
+	"orden-compra/internal/codec"
+	"orden-compra/internal/models"
+	"orden-compra/internal/tenant"
+)
+
+// published is a message handed to recordingSender
+type published struct {
+	exchange   string
+	routingKey string
+	msg        amqp091.Publishing
+}
+
+// recordingSender records publishes instead of sending them, failing with err when set
+type recordingSender struct {
+	mu        sync.Mutex
+	err       error
+	published []published
+}
+
+func (s *recordingSender) Publish(ctx context.Context, exchange, routingKey string, msg amqp091.Publishing) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.published = append(s.published, published{exchange, routingKey, msg})
+	return nil
+}
+
+func newPublishingHandler(sender *recordingSender) *RabbitMQHandler {
+	return &RabbitMQHandler{
+		Publisher:         sender,
+		OutputContentType: codec.ContentTypeJSON,
+		Priorities:        models.DefaultMessagePriorityPolicy(),
+		Logger:            log.New(io.Discard, "", 0),
+	}
+}
+
+var publishedAt = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func publishedMetadata() map[string]interface{} {
+	return map[string]interface{}{correlation.CorrelationIDKey: "correlation-1"}
+}
+
+func TestPublishEvents(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		publish    func(h *RabbitMQHandler) error
+		exchange   string
+		routingKey string
+		eventType  string
+	}{
+		{
+			name: "reception",
+			publish: func(h *RabbitMQHandler) error {
+				event := models.NewRecepcionProveedorEvent("po-1", "product-1", "Gloves", "supplier-1", "Acme", "warehouse-1", models.StatusSent, 10, publishedAt)
+				event.ID = "event-1"
+				event.TenantID = "tenant-1"
+				event.Metadata = publishedMetadata()
+				event.Metadata["top_up"] = true
+				return h.produceReceptionEvent(context.Background(), event)
+			},
+			exchange:   ReceptionExchange,
+			routingKey: ReceptionRoutingKey,
+			eventType:  "RecepcionProveedor",
+		},
+		{
+			name: "cancellation",
+			publish: func(h *RabbitMQHandler) error {
+				return h.PublishCancellationEvent(context.Background(), &models.PurchaseOrderCancelledEvent{ID: "event-1", TenantID: "tenant-1", Timestamp: publishedAt, Metadata: publishedMetadata()})
+			},
+			exchange:   ReceptionExchange,
+			routingKey: CancellationRoutingKey,
+			eventType:  string(models.PurchaseOrderCancelledEventType),
+		},
+		{
+			name: "order placed",
+			publish: func(h *RabbitMQHandler) error {
+				return h.PublishOrderPlacedEvent(context.Background(), &models.OrderPlacedForProductEvent{ID: "event-1", TenantID: "tenant-1", Timestamp: publishedAt, Metadata: publishedMetadata()})
+			},
+			exchange:   OutputExchange,
+			routingKey: OrderPlacedRoutingKey,
+			eventType:  string(models.OrderPlacedForProductEventType),
+		},
+		{
+			name: "completion",
+			publish: func(h *RabbitMQHandler) error {
+				return h.PublishCompletionEvent(context.Background(), &models.PurchaseOrderCompletedEvent{ID: "event-1", TenantID: "tenant-1", Timestamp: publishedAt, Metadata: publishedMetadata()})
+			},
+			exchange:   OutputExchange,
+			routingKey: CompletionRoutingKey,
+			eventType:  string(models.PurchaseOrderCompletedEventType),
+		},
+		{
+			name: "shipment notice",
+			publish: func(h *RabbitMQHandler) error {
+				return h.PublishShipmentNoticeEvent(context.Background(), &models.AdvanceShipmentNoticeEvent{ID: "event-1", TenantID: "tenant-1", Timestamp: publishedAt, Metadata: publishedMetadata()})
+			},
+			exchange:   ReceptionExchange,
+			routingKey: ShipmentNoticeRoutingKey,
+			eventType:  string(models.AdvanceShipmentNoticeEventType),
+		},
+		{
+			name: "invoice mismatch",
+			publish: func(h *RabbitMQHandler) error {
+				return h.PublishInvoiceMismatchEvent(context.Background(), &models.InvoiceMismatchDetectedEvent{ID: "event-1", TenantID: "tenant-1", Timestamp: publishedAt, Metadata: publishedMetadata()})
+			},
+			exchange:   OutputExchange,
+			routingKey: InvoiceMismatchRoutingKey,
+			eventType:  string(models.InvoiceMismatchDetectedEventType),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			sender := &recordingSender{}
+			if err := tc.publish(newPublishingHandler(sender)); err != nil {
+				t.Fatalf("publish: %v", err)
+			}
+			if len(sender.published) != 1 {
+				t.Fatalf("published %d messages, want 1", len(sender.published))
+			}
+
+			got := sender.published[0]
+			if got.exchange != tc.exchange || got.routingKey != tc.routingKey {
+				t.Fatalf("published to %s/%s, want %s/%s", got.exchange, got.routingKey, tc.exchange, tc.routingKey)
+			}
+			if got.msg.MessageId != "event-1" || !got.msg.Timestamp.Equal(publishedAt) {
+				t.Fatalf("message id %q at %v, want event-1 at %v", got.msg.MessageId, got.msg.Timestamp, publishedAt)
+			}
+			if got.msg.ContentType != codec.ContentTypeJSON || got.msg.DeliveryMode != amqp091.Persistent {
+				t.Fatalf("content type %q, delivery mode %d", got.msg.ContentType, got.msg.DeliveryMode)
+			}
+			for header, want := range map[string]string{
+				"event-type":         tc.eventType,
+				"content-type":       codec.ContentTypeJSON,
+				"correlation-id":     "correlation-1",
+				tenant.MessageHeader: "tenant-1",
+			} {
+				if value := got.msg.Headers[header]; value != want {
+					t.Errorf("header %s = %v, want %s", header, value, want)
+				}
+			}
+		})
+	}
+}
+
+func TestPublishReturnsTheSendError(t *testing.T) {
+	refused := errors.New("refused")
+	h := newPublishingHandler(&recordingSender{err: refused})
+
+	err := h.PublishCompletionEvent(context.Background(), &models.PurchaseOrderCompletedEvent{ID: "event-1", Timestamp: publishedAt})
+	if !errors.Is(err, refused) {
+		t.Fatalf("publish returned %v, want the send error", err)
+	}
+}
+
+func TestReceptionPriorityFollowsTheOrdersUrgency(t *testing.T) {
+	for urgencyLevel, priority := range map[string]uint8{"critical": 9, "low": 1, "": 3} {
+		sender := &recordingSender{}
+		event := models.NewRecepcionProveedorEvent("po-1", "product-1", "Gloves", "supplier-1", "Acme", "warehouse-1", models.StatusSent, 10, publishedAt)
+		event.Metadata = publishedMetadata()
+		event.Metadata["urgency_level"] = urgencyLevel
+		event.Metadata["top_up"] = true
+		if err := newPublishingHandler(sender).produceReceptionEvent(context.Background(), event); err != nil {
+			t.Fatalf("publish: %v", err)
+		}
+		if got := sender.published[0].msg.Priority; got != priority {
+			t.Errorf("%q urgency published at priority %d, want %d", urgencyLevel, got, priority)
+		}
+	}
+}
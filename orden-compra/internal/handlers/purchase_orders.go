@@ -0,0 +1,254 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+
+	"medisupply/correlation"
+	"orden-compra/internal/audit"
+	"orden-compra/internal/conditional"
+	"orden-compra/internal/cqrs"
+	"orden-compra/internal/models"
+	"orden-compra/internal/notify"
+	"orden-compra/internal/webhooks"
+)
+
+// PurchaseOrderHandler handles purchase order command requests
+type PurchaseOrderHandler struct {
+	DynamoDB     dynamodbiface.DynamoDBAPI
+	ScanSegments int
+	Stats        cqrs.StatsProjection
+	Publisher    *RabbitMQHandler
+	Notifier     *notify.Notifier
+	Webhooks     *webhooks.Dispatcher
+	Audit        *audit.Recorder
+	Logger       *log.Logger
+}
+
+// NewPurchaseOrderHandler creates a new purchase order handler
+func NewPurchaseOrderHandler(dynamoDB dynamodbiface.DynamoDBAPI, publisher *RabbitMQHandler, notifier *notify.Notifier, webhookDispatcher *webhooks.Dispatcher, auditRecorder *audit.Recorder, logger *log.Logger) *PurchaseOrderHandler {
+	return &PurchaseOrderHandler{
+		DynamoDB:  dynamoDB,
+		Publisher: publisher,
+		Notifier:  notifier,
+		Webhooks:  webhookDispatcher,
+		Audit:     auditRecorder,
+		Logger:    logger,
+	}
+}
+
+// GetPurchaseOrder returns a purchase order as stored in the read model.
+// The read is consistent, so a client polling with its ETag sees its own writes.
+func (h *PurchaseOrderHandler) GetPurchaseOrder(ctx context.Context, purchaseOrderID string) (*models.PurchaseOrder, error) {
+	return cqrs.FindPurchaseOrder(ctx, h.DynamoDB, purchaseOrderID, true)
+}
+
+// Precondition checks the If-Match header of a command on a purchase order
+// against the order's current ETag. It returns a context that holds the
+// command to the matched version, so a write that lands in between still
+// fails the command with cqrs.ErrPreconditionFailed.
+func (h *PurchaseOrderHandler) Precondition(ctx context.Context, purchaseOrderID, ifMatch string) (context.Context, error) {
+	purchaseOrder, err := cqrs.FindPurchaseOrder(ctx, h.DynamoDB, purchaseOrderID, true)
+	if err != nil {
+		return nil, err
+	}
+	if !conditional.Matches(ifMatch, purchaseOrder.ETag(), false) {
+		return nil, fmt.Errorf("%w: %s has ETag %s", cqrs.ErrPreconditionFailed, purchaseOrderID, purchaseOrder.ETag())
+	}
+	if strings.TrimSpace(ifMatch) == "*" {
+		return ctx, nil
+	}
+	return cqrs.WithExpectedVersion(ctx, purchaseOrder.Version), nil
+}
+
+// CancelPurchaseOrder cancels a purchase order and notifies Proveedor
+func (h *PurchaseOrderHandler) CancelPurchaseOrder(ctx context.Context, purchaseOrderID, reason string) (map[string]interface{}, error) {
+	before := h.Audit.PurchaseOrder(ctx, purchaseOrderID)
+
+	command := cqrs.NewCancelPurchaseOrderCommand(
+		purchaseOrderID,
+		reason,
+		h.DynamoDB,
+		h.Logger,
+		correlation.CorrelationID(ctx),
+		correlation.CausationID(ctx),
+	)
+	command.Stats = h.Stats
+
+	result, err := command.Execute(ctx)
+	h.Audit.Record(ctx, models.AuditPurchaseOrderCancelled, models.AuditResourcePurchaseOrder, purchaseOrderID, before, h.Audit.PurchaseOrder(ctx, purchaseOrderID), err)
+	if err != nil {
+		return nil, err
+	}
+
+	cancellationEvent := result["cancellation_event"].(*models.PurchaseOrderCancelledEvent)
+	if err := h.Publisher.PublishCancellationEvent(ctx, cancellationEvent); err != nil {
+		h.Logger.Printf("Failed to publish cancellation event: %v", err)
+		return nil, fmt.Errorf("purchase order cancelled but notification failed: %w", err)
+	}
+
+	h.Notifier.Notify(notify.NewNotification(notify.EventPurchaseOrderCancelled, purchaseOrderID, map[string]interface{}{
+		"tenant_id":       cancellationEvent.TenantID,
+		"product_id":      cancellationEvent.ProductID,
+		"supplier_id":     cancellationEvent.SupplierID,
+		"previous_status": cancellationEvent.PreviousStatus,
+		"reason":          cancellationEvent.Reason,
+	}))
+	h.Webhooks.Dispatch(ctx, models.WebhookPurchaseOrderCancelled, cancellationEvent)
+
+	return result, nil
+}
+
+// AttachShipmentNotice attaches a supplier's advance shipment notice to a
+// purchase order and forwards it to Proveedor so the reception is expected
+func (h *PurchaseOrderHandler) AttachShipmentNotice(ctx context.Context, purchaseOrderID string, notice *models.AdvanceShipmentNotice) (map[string]interface{}, error) {
+	before := h.Audit.PurchaseOrder(ctx, purchaseOrderID)
+
+	command := cqrs.NewAttachAdvanceShipmentNoticeCommand(
+		purchaseOrderID,
+		notice,
+		h.DynamoDB,
+		h.Logger,
+		correlation.CorrelationID(ctx),
+		correlation.CausationID(ctx),
+	)
+	command.Stats = h.Stats
+
+	result, err := command.Execute(ctx)
+	h.Audit.Record(ctx, models.AuditPurchaseOrderShipped, models.AuditResourcePurchaseOrder, purchaseOrderID, before, h.Audit.PurchaseOrder(ctx, purchaseOrderID), err)
+	if err != nil {
+		return nil, err
+	}
+
+	asnEvent := result["asn_event"].(*models.AdvanceShipmentNoticeEvent)
+	if err := h.Publisher.PublishShipmentNoticeEvent(ctx, asnEvent); err != nil {
+		h.Logger.Printf("Failed to publish shipment notice event: %v", err)
+		return nil, fmt.Errorf("shipment notice attached but notification failed: %w", err)
+	}
+
+	h.Webhooks.Dispatch(ctx, models.WebhookPurchaseOrderShipped, asnEvent)
+
+	return result, nil
+}
+
+// AcknowledgePurchaseOrder records a supplier's acknowledgement of a purchase
+// order. A rejection cancels the order and notifies Proveedor.
+func (h *PurchaseOrderHandler) AcknowledgePurchaseOrder(ctx context.Context, purchaseOrderID string, acknowledgement *models.OrderAcknowledgement) (map[string]interface{}, error) {
+	before := h.Audit.PurchaseOrder(ctx, purchaseOrderID)
+
+	command := cqrs.NewAcknowledgePurchaseOrderCommand(
+		purchaseOrderID,
+		acknowledgement,
+		h.DynamoDB,
+		h.Logger,
+		correlation.CorrelationID(ctx),
+		correlation.CausationID(ctx),
+	)
+	command.Stats = h.Stats
+
+	result, err := command.Execute(ctx)
+	h.Audit.Record(ctx, models.AuditPurchaseOrderAcknowledged, models.AuditResourcePurchaseOrder, purchaseOrderID, before, h.Audit.PurchaseOrder(ctx, purchaseOrderID), err)
+	if err != nil {
+		return nil, err
+	}
+
+	h.Webhooks.Dispatch(ctx, models.WebhookPurchaseOrderAcknowledged, map[string]interface{}{
+		"purchase_order_id": purchaseOrderID,
+		"status":            result["status"],
+		"acknowledgement":   result["acknowledgement"],
+	})
+
+	if acknowledgement.Status != models.AcknowledgementRejected {
+		return result, nil
+	}
+
+	reason := "rejected by supplier"
+	if acknowledgement.Note != "" {
+		reason += ": " + acknowledgement.Note
+	}
+	cancelled, err := h.CancelPurchaseOrder(ctx, purchaseOrderID, reason)
+	if err != nil {
+		return nil, fmt.Errorf("acknowledgement recorded but cancellation failed: %w", err)
+	}
+	result["status"] = cancelled["status"]
+	result["cancellation_event"] = cancelled["cancellation_event"]
+
+	return result, nil
+}
+
+// DispatchPurchaseOrder sends a released purchase order to its supplier again
+func (h *PurchaseOrderHandler) DispatchPurchaseOrder(ctx context.Context, purchaseOrderID string) (map[string]interface{}, error) {
+	if err := h.Publisher.Dispatcher.Redispatch(ctx, purchaseOrderID); err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"success":           true,
+		"purchase_order_id": purchaseOrderID,
+		"status":            models.DispatchPending,
+		"channels":          h.Publisher.Dispatcher.Channels(),
+	}, nil
+}
+
+// ApprovePurchaseOrder approves a pending purchase order and releases its
+// RecepcionProveedor event
+func (h *PurchaseOrderHandler) ApprovePurchaseOrder(ctx context.Context, purchaseOrderID, approver, comment string) (map[string]interface{}, error) {
+	before := h.Audit.PurchaseOrder(ctx, purchaseOrderID)
+
+	command := cqrs.NewApprovePurchaseOrderCommand(
+		purchaseOrderID,
+		approver,
+		comment,
+		h.DynamoDB,
+		h.Logger,
+		correlation.CorrelationID(ctx),
+		correlation.CausationID(ctx),
+	)
+	command.Stats = h.Stats
+
+	result, err := command.Execute(ctx)
+	h.Audit.Record(ctx, models.AuditPurchaseOrderApproved, models.AuditResourcePurchaseOrder, purchaseOrderID, before, h.Audit.PurchaseOrder(ctx, purchaseOrderID), err)
+	if err != nil {
+		return nil, err
+	}
+
+	receptionEvent := result["reception_event"].(*models.RecepcionProveedorEvent)
+	if err := h.Publisher.produceReceptionEvent(ctx, receptionEvent); err != nil {
+		h.Logger.Printf("Failed to produce reception event: %v", err)
+		return nil, fmt.Errorf("purchase order approved but notification failed: %w", err)
+	}
+
+	h.Webhooks.Dispatch(ctx, models.WebhookPurchaseOrderApproved, result["purchase_order"])
+
+	return result, nil
+}
+
+// RejectPurchaseOrder rejects a pending purchase order
+func (h *PurchaseOrderHandler) RejectPurchaseOrder(ctx context.Context, purchaseOrderID, approver, comment string) (map[string]interface{}, error) {
+	before := h.Audit.PurchaseOrder(ctx, purchaseOrderID)
+
+	command := cqrs.NewRejectPurchaseOrderCommand(
+		purchaseOrderID,
+		approver,
+		comment,
+		h.DynamoDB,
+		h.Logger,
+		correlation.CorrelationID(ctx),
+		correlation.CausationID(ctx),
+	)
+	command.Stats = h.Stats
+
+	result, err := command.Execute(ctx)
+	h.Audit.Record(ctx, models.AuditPurchaseOrderRejected, models.AuditResourcePurchaseOrder, purchaseOrderID, before, h.Audit.PurchaseOrder(ctx, purchaseOrderID), err)
+	if err != nil {
+		return nil, err
+	}
+
+	h.Webhooks.Dispatch(ctx, models.WebhookPurchaseOrderRejected, result["purchase_order"])
+
+	return result, nil
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"orden-compra/internal/audit"
+	"orden-compra/internal/cqrs"
+	"orden-compra/internal/memory"
+	"orden-compra/internal/models"
+)
+
+func TestAttachShipmentNoticeIsForwardedToProveedor(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	sender := &recordingSender{}
+	h := NewPurchaseOrderHandler(dynamoDB, newPublishingHandler(sender), nil, nil, audit.NewRecorder(audit.NewStore(dynamoDB), dynamoDB, logger), logger)
+
+	purchaseOrder := models.NewPurchaseOrder("product-1", "Gloves", "supplier-1", "Acme", "warehouse-1", "HIGH", 10, time.Now())
+	purchaseOrder.Status = models.StatusSent
+	if _, err := cqrs.NewCreatePurchaseOrderCommand(purchaseOrder, dynamoDB, logger, nil, nil).Execute(context.Background()); err != nil {
+		t.Fatalf("create purchase order: %v", err)
+	}
+
+	notice := &models.AdvanceShipmentNotice{ExpectedDeliveryDate: time.Now().Add(48 * time.Hour), Carrier: "DHL", TrackingNumber: "TRACK-1"}
+	if _, err := h.AttachShipmentNotice(context.Background(), purchaseOrder.ID, notice); err != nil {
+		t.Fatalf("attach shipment notice: %v", err)
+	}
+
+	if len(sender.published) != 1 || sender.published[0].routingKey != ShipmentNoticeRoutingKey {
+		t.Fatalf("published %d messages, want the shipment notice", len(sender.published))
+	}
+	var event models.AdvanceShipmentNoticeEvent
+	if err := json.Unmarshal(sender.published[0].msg.Body, &event); err != nil || event.PurchaseOrderID != purchaseOrder.ID || event.TrackingNumber != "TRACK-1" {
+		t.Fatalf("shipment notice %s, error %v", sender.published[0].msg.Body, err)
+	}
+
+	// A rejected notice is not forwarded
+	if _, err := h.AttachShipmentNotice(context.Background(), "missing", notice); err == nil {
+		t.Fatal("attached a notice to an unknown order")
+	}
+	if len(sender.published) != 1 {
+		t.Fatalf("published %d messages after a rejected notice", len(sender.published))
+	}
+}
+
+func TestPreconditionChecksTheETag(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	h := NewPurchaseOrderHandler(dynamoDB, newPublishingHandler(&recordingSender{}), nil, nil, audit.NewRecorder(audit.NewStore(dynamoDB), dynamoDB, logger), logger)
+
+	purchaseOrder := models.NewPurchaseOrder("product-1", "Gloves", "supplier-1", "Acme", "warehouse-1", "HIGH", 10, time.Now())
+	if _, err := cqrs.NewCreatePurchaseOrderCommand(purchaseOrder, dynamoDB, logger, nil, nil).Execute(context.Background()); err != nil {
+		t.Fatalf("create purchase order: %v", err)
+	}
+	stored, err := h.GetPurchaseOrder(context.Background(), purchaseOrder.ID)
+	if err != nil {
+		t.Fatalf("get purchase order: %v", err)
+	}
+
+	if _, err := h.Precondition(context.Background(), purchaseOrder.ID, `"0-stale"`); !errors.Is(err, cqrs.ErrPreconditionFailed) {
+		t.Fatalf("stale If-Match returned %v, want ErrPreconditionFailed", err)
+	}
+	if _, err := h.Precondition(context.Background(), "po-missing", "*"); !errors.Is(err, cqrs.ErrPurchaseOrderNotFound) {
+		t.Fatalf("If-Match on a missing order returned %v", err)
+	}
+	if _, err := h.Precondition(context.Background(), purchaseOrder.ID, "*"); err != nil {
+		t.Fatalf("If-Match * returned %v", err)
+	}
+
+	// A write that lands after the check still fails the command
+	ctx, err := h.Precondition(context.Background(), purchaseOrder.ID, stored.ETag())
+	if err != nil {
+		t.Fatalf("matching If-Match returned %v", err)
+	}
+	if _, err := cqrs.NewUpdatePurchaseOrderStatusCommand(purchaseOrder.ID, models.StatusSent, dynamoDB, logger, nil, nil).Execute(context.Background()); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if _, err := h.CancelPurchaseOrder(ctx, purchaseOrder.ID, "changed plans"); !errors.Is(err, cqrs.ErrPreconditionFailed) {
+		t.Fatalf("cancel after a concurrent write returned %v, want ErrPreconditionFailed", err)
+	}
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"orden-compra/internal/audit"
+	"orden-compra/internal/cqrs"
+	"orden-compra/internal/models"
+	"orden-compra/internal/tenant"
+)
+
+// ReconcilerConfig represents the read model reconciler settings
+type ReconcilerConfig struct {
+	Interval time.Duration
+	// Window is how far back each run replays events
+	Window time.Duration
+	// Repair restores drifted orders from their events; otherwise drift is only reported
+	Repair bool
+}
+
+// ReadModelReconciler periodically compares the read model with the events
+// stored within the window, reporting drift in the log and as metrics and
+// repairing it when configured to
+type ReadModelReconciler struct {
+	DynamoDB dynamodbiface.DynamoDBAPI
+	Stats    cqrs.StatsProjection
+	Audit    *audit.Recorder
+	Config   ReconcilerConfig
+	Logger   *log.Logger
+
+	mu        sync.Mutex
+	lastDrift map[string]int
+	drift     metric.Int64Counter
+}
+
+// NewReadModelReconciler creates a new read model reconciler
+func NewReadModelReconciler(dynamoDB dynamodbiface.DynamoDBAPI, auditRecorder *audit.Recorder, config ReconcilerConfig, logger *log.Logger) *ReadModelReconciler {
+	r := &ReadModelReconciler{
+		DynamoDB: dynamoDB,
+		Audit:    auditRecorder,
+		Config:   config,
+		Logger:   logger,
+	}
+
+	meter := otel.Meter("orden-compra/reconciler")
+	r.drift, _ = meter.Int64Counter(
+		"read_model_drift_total",
+		metric.WithDescription("Read model drift found by kind and whether it was repaired"),
+	)
+	lastDrift, err := meter.Int64ObservableGauge(
+		"read_model_drift",
+		metric.WithDescription("Read model drift found by the last reconciliation, by kind"),
+	)
+	if err == nil {
+		_, _ = meter.RegisterCallback(func(ctx context.Context, o metric.Observer) error {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			for kind, count := range r.lastDrift {
+				o.ObserveInt64(lastDrift, int64(count), metric.WithAttributes(attribute.String("kind", kind)))
+			}
+			return nil
+		}, lastDrift)
+	}
+
+	return r
+}
+
+// Start reconciles every interval until the context is cancelled
+func (r *ReadModelReconciler) Start(ctx context.Context) {
+	r.Logger.Printf("Starting read model reconciler - interval: %v, window: %v, repair: %v", r.Config.Interval, r.Config.Window, r.Config.Repair)
+
+	ticker := time.NewTicker(r.Config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			r.Logger.Println("Read model reconciler stopped")
+			return
+		case <-ticker.C:
+			if _, err := r.RunOnce(ctx); err != nil {
+				r.Logger.Printf("Read model reconciliation failed: %v", err)
+			}
+		}
+	}
+}
+
+// RunOnce reconciles the orders with events in the window and records the drift found
+func (r *ReadModelReconciler) RunOnce(ctx context.Context) (map[string]interface{}, error) {
+	ctx = audit.WithOrigin(ctx, audit.SystemOrigin("read-model-reconciler"))
+	since := time.Now().UTC().Add(-r.Config.Window)
+	command := cqrs.NewReconcileReadModelCommand(since, r.Config.Repair, r.DynamoDB, r.Logger)
+	command.Stats = r.Stats
+
+	result, err := command.Execute(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	counts, _ := result["drift_counts"].(map[string]int)
+	r.mu.Lock()
+	r.lastDrift = counts
+	r.mu.Unlock()
+
+	drifts, _ := result["drift"].([]*models.ReadModelDrift)
+	for _, drift := range drifts {
+		if r.drift != nil {
+			r.drift.Add(ctx, 1, metric.WithAttributes(
+				attribute.String("kind", drift.Kind),
+				attribute.Bool("repaired", drift.Repaired),
+			))
+		}
+		if drift.Repaired {
+			// The reconciler works across tenants; each repair is audited under its own
+			orderCtx := tenant.NewContext(ctx, drift.TenantID)
+			r.Audit.Record(orderCtx, models.AuditPurchaseOrderReconciled, models.AuditResourcePurchaseOrder, drift.PurchaseOrderID, drift, r.Audit.PurchaseOrder(orderCtx, drift.PurchaseOrderID), nil)
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+
+	"orden-compra/internal/audit"
+	"orden-compra/internal/cqrs"
+	"orden-compra/internal/memory"
+	"orden-compra/internal/models"
+)
+
+func TestReconcilerAuditsTheOrdersItRepairs(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	store := audit.NewStore(dynamoDB)
+	reconciler := NewReadModelReconciler(dynamoDB, audit.NewRecorder(store, dynamoDB, logger), ReconcilerConfig{Window: time.Hour, Repair: true}, logger)
+
+	purchaseOrder := models.NewPurchaseOrder("product-1", "Gloves", "supplier-1", "Acme", "warehouse-1", "HIGH", 10, time.Now())
+	purchaseOrder.TenantID = "tenant-1"
+	if _, err := cqrs.NewCreatePurchaseOrderCommand(purchaseOrder, dynamoDB, logger, nil, nil).Execute(context.Background()); err != nil {
+		t.Fatalf("create purchase order: %v", err)
+	}
+	if _, err := dynamoDB.DeleteItemWithContext(context.Background(), &dynamodb.DeleteItemInput{
+		TableName: aws.String("orden-compra-read"),
+		Key:       map[string]*dynamodb.AttributeValue{"id": {S: aws.String(purchaseOrder.ID)}},
+	}); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	result, err := reconciler.RunOnce(context.Background())
+	if err != nil || result["repaired"] != 1 {
+		t.Fatalf("result %v, error %v, want one repair", result, err)
+	}
+	if reconciler.lastDrift[models.DriftMissing] != 1 {
+		t.Fatalf("last drift %v, want one missing order", reconciler.lastDrift)
+	}
+
+	entries, err := store.Query(context.Background(), audit.Filter{ResourceID: purchaseOrder.ID, Action: models.AuditPurchaseOrderReconciled})
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("audit entries %v, error %v, want the repair", entries, err)
+	}
+	if entry := entries[0]; entry.TenantID != "tenant-1" || entry.After["status"] != models.StatusPending || entry.Before["kind"] != models.DriftMissing {
+		t.Fatalf("audit entry %+v", entry)
+	}
+}
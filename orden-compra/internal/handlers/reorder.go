@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"orden-compra/internal/audit"
+	"orden-compra/internal/models"
+	"orden-compra/internal/tenant"
+)
+
+// ReorderHandler schedules re-orders by publishing StockBajo events that are
+// processed once their delay has passed
+type ReorderHandler struct {
+	Publisher *RabbitMQHandler
+	Audit     *audit.Recorder
+	Logger    *log.Logger
+}
+
+// NewReorderHandler creates a new re-order handler
+func NewReorderHandler(publisher *RabbitMQHandler, auditRecorder *audit.Recorder, logger *log.Logger) *ReorderHandler {
+	return &ReorderHandler{
+		Publisher: publisher,
+		Audit:     auditRecorder,
+		Logger:    logger,
+	}
+}
+
+// ScheduleReorder validates a StockBajo event for the caller's tenant and
+// publishes it to be processed after wait
+func (h *ReorderHandler) ScheduleReorder(ctx context.Context, event *models.StockLowEvent, wait time.Duration) (map[string]interface{}, error) {
+	event.TenantID, _ = tenant.FromContext(ctx)
+	if err := event.Validate(time.Now()); err != nil {
+		return nil, err
+	}
+
+	err := h.Publisher.ScheduleStockLowEvent(ctx, event, wait)
+	h.Audit.Record(ctx, models.AuditReorderScheduled, models.AuditResourceStockLowEvent, event.ID, nil, event, err)
+	if err != nil {
+		h.Logger.Printf("Failed to schedule re-order - product_id: %s: %v", event.ProductID, err)
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"success":    true,
+		"event":      event,
+		"process_at": time.Now().UTC().Add(wait),
+	}, nil
+}
+
+// DryRunHTTPHeader has POST /reorders compute the order at once instead of
+// scheduling the event, and return it without storing or publishing it
+const DryRunHTTPHeader = "X-Dry-Run"
+
+// SimulateReorder computes the purchase order a StockBajo event for the
+// caller's tenant would create now, without storing or publishing anything
+func (h *ReorderHandler) SimulateReorder(ctx context.Context, event *models.StockLowEvent) (map[string]interface{}, error) {
+	event.TenantID, _ = tenant.FromContext(ctx)
+	if err := event.Validate(time.Now()); err != nil {
+		return nil, err
+	}
+
+	result, err := h.Publisher.processStockLowEvent(ctx, event, true)
+	if err != nil {
+		h.Logger.Printf("Failed to simulate re-order - product_id: %s: %v", event.ProductID, err)
+		return nil, err
+	}
+	result["event"] = event
+	return result, nil
+}
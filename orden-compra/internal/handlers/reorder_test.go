@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/rabbitmq/amqp091-go"
+
+	"orden-compra/internal/delay"
+	"orden-compra/internal/memory"
+	"orden-compra/internal/models"
+	"orden-compra/internal/tenant"
+)
+
+// newRetryingHandler returns a handler retrying failed messages at once
+// through a delayed publisher on sender
+func newRetryingHandler(sender *recordingSender) *RabbitMQHandler {
+	h := newPublishingHandler(sender)
+	h.DynamoDB = memory.NewDynamoDB(memory.Tables)
+	h.QueueName = "orden-compra.stock-bajo"
+	h.DeadLetterExchange = "orden-compra.dlx"
+	h.Delayed = &delay.Publisher{Confirms: sender, Config: delay.Config{Mode: delay.ModeTTL, MaxDelay: time.Hour}, Logger: h.Logger}
+	h.Retry = delay.RetryPolicy{MaxAttempts: 2}
+	return h
+}
+
+func TestFailedMessageIsRetriedThenDeadLettered(t *testing.T) {
+	sender := &recordingSender{}
+	h := newRetryingHandler(sender)
+	cause := errors.New("table unavailable")
+
+	ack := &acknowledger{}
+	msg := stockLowDelivery(t, ack)
+	msg.Exchange = "inventario"
+	h.retry(context.Background(), h.QueueName, msg, cause)
+	if !ack.acked || ack.nacked {
+		t.Fatalf("delivery settled as %+v, want acked once the retry is scheduled", *ack)
+	}
+	retried := sender.published[0]
+	if retried.exchange != "" || retried.routingKey != h.QueueName || retried.msg.MessageId != msg.MessageId || string(retried.msg.Body) != string(msg.Body) {
+		t.Fatalf("retry published %+v", retried)
+	}
+	if retried.msg.Headers[delay.RetryAttemptHeader] != int32(1) || retried.msg.AppId != "inventario" {
+		t.Fatalf("retry headers %v from %q", retried.msg.Headers, retried.msg.AppId)
+	}
+
+	// The last attempt's failure dead-letters the message
+	for attempt := int32(1); attempt <= 2; attempt++ {
+		msg.Headers = amqp091.Table{delay.RetryAttemptHeader: attempt}
+		h.retry(context.Background(), h.QueueName, msg, cause)
+	}
+	records := h.DynamoDB.(*memory.DynamoDB).Items("orden-compra-dead-letters")
+	if len(records) != 1 || aws.StringValue(records[0]["reason"].S) != "retries_exhausted" {
+		t.Fatalf("dead letters %v, want one retries_exhausted", records)
+	}
+	if len(sender.published) != 3 || sender.published[2].exchange != h.DeadLetterExchange {
+		t.Fatalf("published %d messages, want two retries and the dead letter", len(sender.published))
+	}
+}
+
+func TestFailedMessageIsRequeuedWithoutRetries(t *testing.T) {
+	for name, sender := range map[string]*recordingSender{
+		"retries disabled":   {},
+		"retry not accepted": {err: errors.New("broker unavailable")},
+	} {
+		t.Run(name, func(t *testing.T) {
+			h := newRetryingHandler(sender)
+			if sender.err == nil {
+				h.Retry = delay.RetryPolicy{}
+			}
+			ack := &acknowledger{}
+			h.retry(context.Background(), h.QueueName, stockLowDelivery(t, ack), errors.New("table unavailable"))
+			if ack.acked || !ack.nacked || len(sender.published) != 0 {
+				t.Fatalf("delivery settled as %+v with %d published, want requeued", *ack, len(sender.published))
+			}
+		})
+	}
+}
+
+func TestScheduleReorder(t *testing.T) {
+	sender := &recordingSender{}
+	h := NewReorderHandler(newRetryingHandler(sender), nil, log.New(io.Discard, "", 0))
+	event := &models.StockLowEvent{
+		ID:           "stock-low-1",
+		Timestamp:    time.Now().UTC(),
+		EventType:    models.StockLowEventType,
+		ProductID:    "product-1",
+		ProductName:  "Gloves",
+		CurrentStock: 5,
+		MinimumStock: 20,
+		Location:     "warehouse-1",
+		UrgencyLevel: "critical",
+	}
+
+	result, err := h.ScheduleReorder(tenant.NewContext(context.Background(), "tenant-1"), event, 0)
+	if err != nil || result["success"] != true {
+		t.Fatalf("ScheduleReorder = %v, %v", result, err)
+	}
+	scheduled := sender.published[0]
+	var decoded models.StockLowEvent
+	if err := json.Unmarshal(scheduled.msg.Body, &decoded); err != nil || decoded.TenantID != "tenant-1" {
+		t.Fatalf("scheduled %s, error %v, want the event of tenant-1", scheduled.msg.Body, err)
+	}
+	if scheduled.routingKey != "orden-compra.stock-bajo" || scheduled.msg.Priority != 9 || scheduled.msg.Headers[tenant.MessageHeader] != "tenant-1" {
+		t.Fatalf("scheduled %+v", scheduled)
+	}
+
+	// An invalid event is not scheduled
+	event.CurrentStock = -1
+	if _, err := h.ScheduleReorder(context.Background(), event, 0); err == nil || len(sender.published) != 1 {
+		t.Fatalf("invalid event returned %v with %d published", err, len(sender.published))
+	}
+}
+
+func TestSimulateReorder(t *testing.T) {
+	sender := &recordingSender{}
+	publisher := newRetryingHandler(sender)
+	h := NewReorderHandler(publisher, nil, log.New(io.Discard, "", 0))
+	event := &models.StockLowEvent{
+		ID:           "stock-low-1",
+		Timestamp:    time.Now().UTC(),
+		EventType:    models.StockLowEventType,
+		ProductID:    "product-1",
+		ProductName:  "Gloves",
+		CurrentStock: 5,
+		MinimumStock: 20,
+		Location:     "warehouse-1",
+		UrgencyLevel: "high",
+	}
+
+	result, err := h.SimulateReorder(tenant.NewContext(context.Background(), "tenant-1"), event)
+	if err != nil || result["dry_run"] != true || result["event"] != event {
+		t.Fatalf("SimulateReorder = %v, %v", result, err)
+	}
+	if order, ok := result["purchase_order"].(*models.PurchaseOrder); !ok || order.TenantID != "tenant-1" || order.ProductID != "product-1" {
+		t.Fatalf("simulated order %+v, want one for product-1 of tenant-1", result["purchase_order"])
+	}
+	if orders := publisher.DynamoDB.(*memory.DynamoDB).Items("orden-compra-read"); len(orders) != 0 || len(sender.published) != 0 {
+		t.Fatalf("simulation stored %d orders and published %d messages", len(orders), len(sender.published))
+	}
+
+	// An invalid event is not simulated
+	event.CurrentStock = -1
+	if _, err := h.SimulateReorder(context.Background(), event); err == nil {
+		t.Fatal("invalid event was simulated")
+	}
+}
@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"context"
+	"log"
+
+	"orden-compra/internal/saga"
+)
+
+// SagaHandler serves the state of the replenishment sagas
+type SagaHandler struct {
+	Store  *saga.Store
+	Logger *log.Logger
+}
+
+// NewSagaHandler creates a new saga handler
+func NewSagaHandler(store *saga.Store, logger *log.Logger) *SagaHandler {
+	return &SagaHandler{
+		Store:  store,
+		Logger: logger,
+	}
+}
+
+// GetSaga returns the steps, status and deadline of a correlation chain
+func (h *SagaHandler) GetSaga(ctx context.Context, correlationID string) (map[string]interface{}, error) {
+	result, err := h.Store.Get(ctx, correlationID)
+	if err != nil {
+		h.Logger.Printf("Failed to get saga - correlation_id: %s: %v", correlationID, err)
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"success": true,
+		"saga":    result,
+	}, nil
+}
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"context"
+	"log"
+
+	"orden-compra/internal/search"
+	"orden-compra/internal/tenant"
+)
+
+// SearchHandler serves free-text lookups of purchase orders
+type SearchHandler struct {
+	Client *search.Client
+	Logger *log.Logger
+}
+
+// NewSearchHandler creates a new search handler; client is nil when search is disabled
+func NewSearchHandler(client *search.Client, logger *log.Logger) *SearchHandler {
+	return &SearchHandler{
+		Client: client,
+		Logger: logger,
+	}
+}
+
+// SearchPurchaseOrders returns the orders best matching text, limited to the
+// caller's tenant
+func (h *SearchHandler) SearchPurchaseOrders(ctx context.Context, text string, limit int) (map[string]interface{}, error) {
+	tenantID, _ := tenant.FromContext(ctx)
+	documents, total, err := h.Client.Search(ctx, tenantID, text, limit)
+	if err != nil {
+		h.Logger.Printf("Purchase order search failed - query: %q: %v", text, err)
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"success":         true,
+		"purchase_orders": documents,
+		"count":           len(documents),
+		"total":           total,
+	}, nil
+}
@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+
+	"orden-compra/internal/cqrs"
+	"orden-compra/internal/tenant"
+)
+
+// SnapshotterConfig represents the aggregate snapshotter settings
+type SnapshotterConfig struct {
+	Interval time.Duration
+	// Window is how far back each run looks for orders with new events
+	Window time.Duration
+	// Threshold is the number of events after the latest snapshot that makes
+	// an order due for a new one
+	Threshold int
+}
+
+// AggregateSnapshotter periodically snapshots the purchase orders that
+// gathered Threshold events since their latest snapshot, so rehydrating them
+// replays only the events after it
+type AggregateSnapshotter struct {
+	DynamoDB dynamodbiface.DynamoDBAPI
+	Config   SnapshotterConfig
+	Logger   *log.Logger
+}
+
+// NewAggregateSnapshotter creates a new aggregate snapshotter
+func NewAggregateSnapshotter(dynamoDB dynamodbiface.DynamoDBAPI, config SnapshotterConfig, logger *log.Logger) *AggregateSnapshotter {
+	return &AggregateSnapshotter{
+		DynamoDB: dynamoDB,
+		Config:   config,
+		Logger:   logger,
+	}
+}
+
+// Start takes snapshots every interval until the context is cancelled
+func (s *AggregateSnapshotter) Start(ctx context.Context) {
+	s.Logger.Printf("Starting aggregate snapshotter - interval: %v, window: %v, threshold: %d", s.Config.Interval, s.Config.Window, s.Config.Threshold)
+
+	ticker := time.NewTicker(s.Config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			s.Logger.Println("Aggregate snapshotter stopped")
+			return
+		case <-ticker.C:
+			if _, err := s.RunOnce(ctx); err != nil {
+				s.Logger.Printf("Aggregate snapshot run failed: %v", err)
+			}
+		}
+	}
+}
+
+// RunOnce rehydrates every order with events in the window and snapshots
+// those that replayed at least Threshold events
+func (s *AggregateSnapshotter) RunOnce(ctx context.Context) (map[string]interface{}, error) {
+	orders, err := s.changedOrders(ctx, time.Now().UTC().Add(-s.Config.Window))
+	if err != nil {
+		return nil, err
+	}
+
+	taken := 0
+	failed := 0
+	for id, tenantID := range orders {
+		// The snapshotter works across tenants; each order is read and written under its own
+		orderCtx := tenant.NewContext(ctx, tenantID)
+		rehydration, err := cqrs.RehydratePurchaseOrder(orderCtx, s.DynamoDB, id)
+		if err != nil {
+			s.Logger.Printf("Failed to rehydrate purchase order %s, not snapshotted: %v", id, err)
+			failed++
+			continue
+		}
+		if rehydration.EventsReplayed < s.Config.Threshold {
+			continue
+		}
+
+		snapshot := rehydration.NewSnapshot(time.Now().UTC())
+		saved, err := cqrs.SaveAggregateSnapshot(orderCtx, s.DynamoDB, snapshot)
+		if err != nil {
+			s.Logger.Printf("Failed to snapshot purchase order %s: %v", id, err)
+			failed++
+			continue
+		}
+		if saved {
+			taken++
+		}
+	}
+
+	s.Logger.Printf("Aggregate snapshots taken - purchase_orders: %d, snapshots: %d, failed: %d", len(orders), taken, failed)
+
+	result := map[string]interface{}{
+		"success":         failed == 0,
+		"purchase_orders": len(orders),
+		"snapshots":       taken,
+		"failed":          failed,
+	}
+	if failed > 0 {
+		return result, fmt.Errorf("failed to snapshot %d of %d purchase orders", failed, len(orders))
+	}
+	return result, nil
+}
+
+// changedOrders returns the IDs of the orders with events since a point in
+// time, with the tenant of each
+func (s *AggregateSnapshotter) changedOrders(ctx context.Context, since time.Time) (map[string]string, error) {
+	scanInput := &dynamodb.ScanInput{
+		TableName:            aws.String("orden-compra-events"),
+		FilterExpression:     aws.String("#timestamp > :since AND attribute_exists(event_data.purchase_order) AND attribute_not_exists(restored_at)"),
+		ProjectionExpression: aws.String("aggregate_id, tenant_id"),
+		ExpressionAttributeNames: map[string]*string{
+			"#timestamp": aws.String("timestamp"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":since": {S: aws.String(since.Format(time.RFC3339Nano))},
+		},
+	}
+
+	orders := make(map[string]string)
+	for {
+		result, err := s.DynamoDB.ScanWithContext(ctx, scanInput)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan events: %w", err)
+		}
+
+		for _, item := range result.Items {
+			id := item["aggregate_id"]
+			if id == nil || id.S == nil {
+				continue
+			}
+			orders[*id.S] = ""
+			if owner := item[tenant.Attribute]; owner != nil && owner.S != nil {
+				orders[*id.S] = *owner.S
+			}
+		}
+
+		if len(result.LastEvaluatedKey) == 0 {
+			break
+		}
+		scanInput.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+	return orders, nil
+}
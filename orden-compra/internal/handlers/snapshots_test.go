@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"orden-compra/internal/cqrs"
+	"orden-compra/internal/memory"
+	"orden-compra/internal/models"
+)
+
+func TestSnapshotterSnapshotsOrdersPastTheThreshold(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	snapshotter := NewAggregateSnapshotter(dynamoDB, SnapshotterConfig{Window: time.Hour, Threshold: 2}, logger)
+
+	var ids []string
+	for _, updates := range [][]string{{models.StatusSent}, nil} {
+		purchaseOrder := models.NewPurchaseOrder("product-1", "Gloves", "supplier-1", "Acme", "warehouse-1", "HIGH", 10, time.Now())
+		if _, err := cqrs.NewCreatePurchaseOrderCommand(purchaseOrder, dynamoDB, logger, nil, nil).Execute(context.Background()); err != nil {
+			t.Fatalf("create purchase order: %v", err)
+		}
+		for _, status := range updates {
+			if _, err := cqrs.NewUpdatePurchaseOrderStatusCommand(purchaseOrder.ID, status, dynamoDB, logger, nil, nil).Execute(context.Background()); err != nil {
+				t.Fatalf("update purchase order: %v", err)
+			}
+		}
+		ids = append(ids, purchaseOrder.ID)
+	}
+
+	result, err := snapshotter.RunOnce(context.Background())
+	if err != nil || result["purchase_orders"] != 2 || result["snapshots"] != 1 {
+		t.Fatalf("run returned %v, error %v", result, err)
+	}
+	snapshot, err := cqrs.FindAggregateSnapshot(context.Background(), dynamoDB, ids[0])
+	if err != nil || snapshot == nil || snapshot.EventsApplied != 2 || snapshot.PurchaseOrder.Status != models.StatusSent {
+		t.Fatalf("snapshot %+v, error %v", snapshot, err)
+	}
+	if snapshot, err := cqrs.FindAggregateSnapshot(context.Background(), dynamoDB, ids[1]); err != nil || snapshot != nil {
+		t.Fatalf("order below the threshold snapshotted as %+v, error %v", snapshot, err)
+	}
+
+	// The snapshotted order has no new events to snapshot
+	if result, err := snapshotter.RunOnce(context.Background()); err != nil || result["snapshots"] != 0 {
+		t.Fatalf("second run returned %v, error %v", result, err)
+	}
+}
@@ -0,0 +1,309 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/sirupsen/logrus"
+
+	"orden-compra/internal/audit"
+	"orden-compra/internal/auth"
+	"orden-compra/internal/cache"
+	"orden-compra/internal/cqrs"
+	"orden-compra/internal/models"
+	"orden-compra/internal/notify"
+	"orden-compra/internal/tenant"
+)
+
+// StandingOrderHandler manages standing orders and lists the purchase orders
+// released from them
+type StandingOrderHandler struct {
+	DynamoDB     dynamodbiface.DynamoDBAPI
+	ScanSegments int
+	Audit        *audit.Recorder
+	QueryLogger  *logrus.Logger
+	Logger       *log.Logger
+}
+
+// NewStandingOrderHandler creates a new standing order handler
+func NewStandingOrderHandler(dynamoDB dynamodbiface.DynamoDBAPI, auditRecorder *audit.Recorder, queryLogger *logrus.Logger, logger *log.Logger) *StandingOrderHandler {
+	return &StandingOrderHandler{
+		DynamoDB:    dynamoDB,
+		Audit:       auditRecorder,
+		QueryLogger: queryLogger,
+		Logger:      logger,
+	}
+}
+
+// CreateStandingOrder validates and stores a standing order for the caller's
+// tenant. The supplier name is taken from the supplier catalog when it is
+// not given.
+func (h *StandingOrderHandler) CreateStandingOrder(ctx context.Context, standingOrder *models.StandingOrder) (map[string]interface{}, error) {
+	standingOrder.TenantID, _ = tenant.FromContext(ctx)
+	if principal, ok := auth.FromContext(ctx); ok {
+		standingOrder.CreatedBy = principal.Subject
+	}
+	if err := standingOrder.Validate(); err != nil {
+		return nil, err
+	}
+
+	if standingOrder.SupplierName == "" {
+		supplier, err := cqrs.FindSupplier(cache.AllowStale(ctx), h.DynamoDB, standingOrder.SupplierID, false)
+		if err != nil {
+			h.Logger.Printf("Failed to get supplier %s for standing order: %v", standingOrder.SupplierID, err)
+		} else if supplier != nil {
+			standingOrder.SupplierName = supplier.Name
+		}
+	}
+
+	err := cqrs.SaveStandingOrder(ctx, h.DynamoDB, standingOrder)
+	h.Audit.Record(ctx, models.AuditStandingOrderCreated, models.AuditResourceStandingOrder, standingOrder.ID, nil, standingOrder, err)
+	if err != nil {
+		return nil, err
+	}
+
+	h.Logger.Printf("Standing order created - standing_order_id: %s, product_id: %s, supplier_id: %s, quantity: %d, interval_days: %d", standingOrder.ID, standingOrder.ProductID, standingOrder.SupplierID, standingOrder.Quantity, standingOrder.IntervalDays)
+
+	return map[string]interface{}{
+		"success":        true,
+		"standing_order": standingOrder,
+	}, nil
+}
+
+// GetStandingOrder returns a standing order
+func (h *StandingOrderHandler) GetStandingOrder(ctx context.Context, id string) (map[string]interface{}, error) {
+	standingOrder, err := cqrs.FindStandingOrder(ctx, h.DynamoDB, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"success":        true,
+		"standing_order": standingOrder,
+	}, nil
+}
+
+// ListStandingOrders returns the standing orders matching the filter
+func (h *StandingOrderHandler) ListStandingOrders(ctx context.Context, filter cqrs.StandingOrderFilter) (map[string]interface{}, error) {
+	standingOrders, err := cqrs.ListStandingOrders(ctx, h.DynamoDB, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"success":         true,
+		"standing_orders": standingOrders,
+		"count":           len(standingOrders),
+	}, nil
+}
+
+// ListReleases returns the purchase orders released from a standing order
+func (h *StandingOrderHandler) ListReleases(ctx context.Context, id string) (map[string]interface{}, error) {
+	if _, err := cqrs.FindStandingOrder(ctx, h.DynamoDB, id); err != nil {
+		return nil, err
+	}
+
+	purchaseOrders := make([]*models.PurchaseOrder, 0)
+	err := cqrs.NewListPurchaseOrdersQuery(h.DynamoDB, h.QueryLogger).
+		WithBlanketOrderID(id).
+		WithLimit(exportPageSize).
+		WithScanSegments(h.ScanSegments).
+		Each(ctx, func(purchaseOrder *models.PurchaseOrder) error {
+			purchaseOrders = append(purchaseOrders, purchaseOrder)
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"success":          true,
+		"blanket_order_id": id,
+		"purchase_orders":  purchaseOrders,
+		"count":            len(purchaseOrders),
+	}, nil
+}
+
+// PauseStandingOrder stops an active standing order's releases until it is resumed
+func (h *StandingOrderHandler) PauseStandingOrder(ctx context.Context, id string) (map[string]interface{}, error) {
+	return h.update(ctx, id, func(standingOrder *models.StandingOrder) error {
+		if standingOrder.Status != models.StandingOrderActive {
+			return fmt.Errorf("%w: standing order %s is %s", models.ErrInvalidStatusTransition, id, standingOrder.Status)
+		}
+		standingOrder.Status = models.StandingOrderPaused
+		standingOrder.UpdatedAt = time.Now().UTC()
+		return nil
+	})
+}
+
+// ResumeStandingOrder restarts a paused standing order's releases. Releases
+// that fell due while it was paused are skipped.
+func (h *StandingOrderHandler) ResumeStandingOrder(ctx context.Context, id string) (map[string]interface{}, error) {
+	return h.update(ctx, id, func(standingOrder *models.StandingOrder) error {
+		if standingOrder.Status != models.StandingOrderPaused {
+			return fmt.Errorf("%w: standing order %s is %s", models.ErrInvalidStatusTransition, id, standingOrder.Status)
+		}
+		standingOrder.Resume(time.Now().UTC())
+		return nil
+	})
+}
+
+// CancelStandingOrder ends a standing order; orders already released are
+// not affected
+func (h *StandingOrderHandler) CancelStandingOrder(ctx context.Context, id string) (map[string]interface{}, error) {
+	return h.update(ctx, id, func(standingOrder *models.StandingOrder) error {
+		if standingOrder.Status != models.StandingOrderActive && standingOrder.Status != models.StandingOrderPaused {
+			return fmt.Errorf("%w: standing order %s is %s", models.ErrInvalidStatusTransition, id, standingOrder.Status)
+		}
+		standingOrder.Status = models.StandingOrderCancelled
+		standingOrder.NextReleaseAt = nil
+		standingOrder.UpdatedAt = time.Now().UTC()
+		return nil
+	})
+}
+
+// update applies a change to a copy of the stored standing order and writes
+// it back, auditing the change
+func (h *StandingOrderHandler) update(ctx context.Context, id string, change func(*models.StandingOrder) error) (map[string]interface{}, error) {
+	before, err := cqrs.FindStandingOrder(ctx, h.DynamoDB, id)
+	if err != nil {
+		return nil, err
+	}
+
+	standingOrder := *before
+	if err := change(&standingOrder); err != nil {
+		return nil, err
+	}
+
+	err = cqrs.SaveStandingOrder(ctx, h.DynamoDB, &standingOrder)
+	h.Audit.Record(ctx, models.AuditStandingOrderUpdated, models.AuditResourceStandingOrder, id, before, &standingOrder, err)
+	if err != nil {
+		return nil, err
+	}
+
+	h.Logger.Printf("Standing order updated - standing_order_id: %s, status: %s -> %s", id, before.Status, standingOrder.Status)
+
+	return map[string]interface{}{
+		"success":        true,
+		"standing_order": &standingOrder,
+	}, nil
+}
+
+// StandingOrderScheduler periodically releases the standing orders that are
+// due and sends their purchase orders to Proveedor
+type StandingOrderScheduler struct {
+	DynamoDB  dynamodbiface.DynamoDBAPI
+	Stats     cqrs.StatsProjection
+	Publisher *RabbitMQHandler
+	Audit     *audit.Recorder
+	Interval  time.Duration
+	Logger    *log.Logger
+}
+
+// NewStandingOrderScheduler creates a new standing order scheduler
+func NewStandingOrderScheduler(dynamoDB dynamodbiface.DynamoDBAPI, publisher *RabbitMQHandler, auditRecorder *audit.Recorder, interval time.Duration, logger *log.Logger) *StandingOrderScheduler {
+	return &StandingOrderScheduler{
+		DynamoDB:  dynamoDB,
+		Publisher: publisher,
+		Audit:     auditRecorder,
+		Interval:  interval,
+		Logger:    logger,
+	}
+}
+
+// Start releases due standing orders every interval until the context is cancelled
+func (s *StandingOrderScheduler) Start(ctx context.Context) {
+	s.Logger.Printf("Starting standing order scheduler - interval: %v", s.Interval)
+
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			s.Logger.Println("Standing order scheduler stopped")
+			return
+		case <-ticker.C:
+			if _, err := s.RunOnce(ctx); err != nil {
+				s.Logger.Printf("Standing order run failed: %v", err)
+			}
+		}
+	}
+}
+
+// RunOnce releases every standing order due right now. A standing order that
+// fails to release stays due and is retried on the next run.
+func (s *StandingOrderScheduler) RunOnce(ctx context.Context) (map[string]interface{}, error) {
+	ctx = audit.WithOrigin(ctx, audit.SystemOrigin("standing-order-scheduler"))
+	now := time.Now().UTC()
+
+	due, err := cqrs.ListDueStandingOrders(ctx, s.DynamoDB, now)
+	if err != nil {
+		return nil, err
+	}
+
+	released := 0
+	failed := 0
+	for _, standingOrder := range due {
+		if err := s.release(tenant.NewContext(ctx, standingOrder.TenantID), standingOrder); err != nil {
+			s.Logger.Printf("Failed to release standing order %s: %v", standingOrder.ID, err)
+			failed++
+			continue
+		}
+		released++
+	}
+
+	result := map[string]interface{}{
+		"success":  failed == 0,
+		"due":      len(due),
+		"released": released,
+		"failed":   failed,
+	}
+	if failed > 0 {
+		return result, fmt.Errorf("failed to release %d of %d due standing orders", failed, len(due))
+	}
+	return result, nil
+}
+
+// release creates the purchase order of one standing order's next release
+// and publishes its reception event
+func (s *StandingOrderScheduler) release(ctx context.Context, standingOrder *models.StandingOrder) error {
+	before := *standingOrder
+	command := cqrs.NewReleaseStandingOrderCommand(
+		standingOrder,
+		s.Publisher.OrderPolicy,
+		s.DynamoDB,
+		s.Logger,
+		nil,
+		nil,
+	)
+	command.Stats = s.Stats
+
+	result, err := command.Execute(ctx)
+	if err != nil {
+		return err
+	}
+	if released, _ := result["released"].(bool); !released {
+		return nil
+	}
+	s.Audit.Record(ctx, models.AuditStandingOrderReleased, models.AuditResourceStandingOrder, standingOrder.ID, &before, standingOrder, nil)
+
+	// A release stored by an earlier run that failed later carries no new order
+	purchaseOrder, ok := result["purchase_order"].(*models.PurchaseOrder)
+	if !ok {
+		return nil
+	}
+	s.Audit.Record(ctx, models.AuditPurchaseOrderCreated, models.AuditResourcePurchaseOrder, purchaseOrder.ID, nil, purchaseOrder, nil)
+	s.Publisher.Notifier.Notify(notify.NewNotification(notify.EventPurchaseOrderCreated, purchaseOrder.ID, purchaseOrderNotificationData(purchaseOrder)))
+	s.Publisher.Webhooks.Dispatch(ctx, models.WebhookPurchaseOrderCreated, purchaseOrder)
+
+	receptionEvent := result["reception_event"].(*models.RecepcionProveedorEvent)
+	if err := s.Publisher.produceReceptionEvent(ctx, receptionEvent); err != nil {
+		// The order is stored and the release recorded, so it is not released again
+		s.Logger.Printf("Failed to produce reception event for standing order %s release %d: %v", standingOrder.ID, standingOrder.Releases, err)
+	}
+	return nil
+}
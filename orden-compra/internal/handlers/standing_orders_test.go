@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"orden-compra/internal/audit"
+	"orden-compra/internal/cqrs"
+	"orden-compra/internal/memory"
+	"orden-compra/internal/models"
+	"orden-compra/internal/tenant"
+)
+
+func TestStandingOrderSchedulerReleasesDueOrders(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	sender := &recordingSender{}
+	handler := NewStandingOrderHandler(dynamoDB, nil, &logrus.Logger{Out: io.Discard, Formatter: new(logrus.TextFormatter), Level: logrus.PanicLevel}, logger)
+	scheduler := NewStandingOrderScheduler(dynamoDB, newPublishingHandler(sender), nil, time.Minute, logger)
+
+	// The supplier name comes from the catalog
+	if _, err := cqrs.NewUpsertSupplierCommand(&models.Supplier{ID: "supplier-1", Name: "Acme"}, dynamoDB, logger).Execute(context.Background()); err != nil {
+		t.Fatalf("store supplier: %v", err)
+	}
+	standingOrder := models.NewStandingOrder("product-1", "Gloves", "supplier-1", "", "warehouse-1", "medium", 100, 7, time.Now().Add(-time.Minute), time.Now())
+	ctx := tenant.NewContext(context.Background(), "tenant-1")
+	if _, err := handler.CreateStandingOrder(ctx, standingOrder); err != nil {
+		t.Fatalf("create standing order: %v", err)
+	}
+	if standingOrder.SupplierName != "Acme" || standingOrder.TenantID != "tenant-1" {
+		t.Fatalf("created standing order %+v", standingOrder)
+	}
+	notYet := models.NewStandingOrder("product-2", "Masks", "supplier-1", "Acme", "warehouse-1", "medium", 100, 7, time.Now().Add(time.Hour), time.Now())
+	if _, err := handler.CreateStandingOrder(ctx, notYet); err != nil {
+		t.Fatalf("create standing order: %v", err)
+	}
+
+	result, err := scheduler.RunOnce(context.Background())
+	if err != nil || result["due"] != 1 || result["released"] != 1 {
+		t.Fatalf("run returned %v, error %v", result, err)
+	}
+	if len(sender.published) != 1 || sender.published[0].routingKey != ReceptionRoutingKey {
+		t.Fatalf("published %d messages, want the reception event", len(sender.published))
+	}
+
+	releases, err := handler.ListReleases(ctx, standingOrder.ID)
+	if err != nil || releases["count"] != 1 {
+		t.Fatalf("releases %v, error %v", releases, err)
+	}
+	if released := releases["purchase_orders"].([]*models.PurchaseOrder)[0]; released.TenantID != "tenant-1" || released.SupplierName != "Acme" {
+		t.Fatalf("released order %+v", released)
+	}
+
+	// The released standing order is not due again until its next interval
+	if result, err := scheduler.RunOnce(context.Background()); err != nil || result["due"] != 0 {
+		t.Fatalf("second run returned %v, error %v", result, err)
+	}
+}
+
+func TestStandingOrderLifecycle(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	store := audit.NewStore(dynamoDB)
+	handler := NewStandingOrderHandler(dynamoDB, audit.NewRecorder(store, dynamoDB, logger), nil, logger)
+	ctx := context.Background()
+
+	standingOrder := models.NewStandingOrder("product-1", "Gloves", "supplier-1", "Acme", "warehouse-1", "medium", 100, 7, time.Now(), time.Now())
+	if _, err := handler.CreateStandingOrder(ctx, standingOrder); err != nil {
+		t.Fatalf("create standing order: %v", err)
+	}
+	if _, err := handler.CreateStandingOrder(ctx, &models.StandingOrder{}); err == nil {
+		t.Fatal("created an invalid standing order")
+	}
+
+	for _, step := range []struct {
+		name   string
+		apply  func(context.Context, string) (map[string]interface{}, error)
+		status string
+	}{
+		{"pause", handler.PauseStandingOrder, models.StandingOrderPaused},
+		{"resume", handler.ResumeStandingOrder, models.StandingOrderActive},
+		{"cancel", handler.CancelStandingOrder, models.StandingOrderCancelled},
+	} {
+		result, err := step.apply(ctx, standingOrder.ID)
+		if err != nil || result["standing_order"].(*models.StandingOrder).Status != step.status {
+			t.Fatalf("%s returned %v, error %v", step.name, result, err)
+		}
+	}
+
+	// A cancelled standing order stays cancelled
+	for _, apply := range []func(context.Context, string) (map[string]interface{}, error){handler.PauseStandingOrder, handler.ResumeStandingOrder, handler.CancelStandingOrder} {
+		if _, err := apply(ctx, standingOrder.ID); !errors.Is(err, models.ErrInvalidStatusTransition) {
+			t.Fatalf("change of a cancelled standing order returned %v", err)
+		}
+	}
+	stored, err := cqrs.FindStandingOrder(ctx, dynamoDB, standingOrder.ID)
+	if err != nil || stored.NextReleaseAt != nil || stored.Version != 4 {
+		t.Fatalf("cancelled standing order %+v, error %v", stored, err)
+	}
+
+	entries, err := store.Query(ctx, audit.Filter{ResourceID: standingOrder.ID})
+	if err != nil || len(entries) != 4 {
+		t.Fatalf("audited %d changes, error %v, want the creation and 3 updates", len(entries), err)
+	}
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/sirupsen/logrus"
+
+	"orden-compra/internal/cqrs"
+	"orden-compra/internal/models"
+	"orden-compra/internal/slo"
+)
+
+// StatsHandler serves the purchase order metrics behind dashboards
+type StatsHandler struct {
+	DynamoDB     dynamodbiface.DynamoDBAPI
+	ScanSegments int
+	SLO          *slo.Recorder
+	QueryLogger  *logrus.Logger
+	Logger       *log.Logger
+}
+
+// NewStatsHandler creates a new stats handler
+func NewStatsHandler(dynamoDB dynamodbiface.DynamoDBAPI, sloRecorder *slo.Recorder, queryLogger *logrus.Logger, logger *log.Logger) *StatsHandler {
+	return &StatsHandler{
+		DynamoDB:    dynamoDB,
+		SLO:         sloRecorder,
+		QueryLogger: queryLogger,
+		Logger:      logger,
+	}
+}
+
+// TimeSeries counts orders created, completed and reported overdue per
+// period of the range
+func (h *StatsHandler) TimeSeries(ctx context.Context, startDate, endDate time.Time, interval string) (map[string]interface{}, error) {
+	result, err := cqrs.NewGetPurchaseOrderTimeSeriesQuery(startDate, endDate, interval, h.DynamoDB, h.QueryLogger).WithScanSegments(h.ScanSegments).Execute(ctx)
+	if err != nil {
+		h.Logger.Printf("Failed to get purchase order time series: %v", err)
+		return nil, err
+	}
+	return result, nil
+}
+
+// SLOSummary summarizes how long the orders whose StockBajo event arrived
+// within the range took through each pipeline stage, and whether they were
+// released within the objective of their urgency
+func (h *StatsHandler) SLOSummary(ctx context.Context, startDate, endDate time.Time) (map[string]interface{}, error) {
+	result, err := h.SLO.Summary(ctx, startDate, endDate)
+	if err != nil {
+		h.Logger.Printf("Failed to summarize SLOs: %v", err)
+		return nil, err
+	}
+	return result, nil
+}
+
+// RecomputeStats rebuilds the statistics projection from the read model. It
+// is only needed after projection updates failed, as logged by the commands.
+func (h *PurchaseOrderHandler) RecomputeStats(ctx context.Context) (map[string]interface{}, error) {
+	command := cqrs.NewRecomputeStatsCommand(h.DynamoDB, h.Logger)
+	command.Stats = h.Stats
+	command.ScanSegments = h.ScanSegments
+	result, err := command.Execute(ctx)
+	h.Audit.Record(ctx, models.AuditStatisticsRecomputed, models.AuditResourceStatistics, "purchase_orders", nil, result, err)
+	if err != nil {
+		h.Logger.Printf("Failed to recompute statistics: %v", err)
+		return nil, err
+	}
+	return result, nil
+}
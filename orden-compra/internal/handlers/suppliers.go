@@ -0,0 +1,549 @@
+package handlers
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/google/uuid"
+
+	"orden-compra/internal/audit"
+	"orden-compra/internal/cache"
+	"orden-compra/internal/cqrs"
+	"orden-compra/internal/models"
+	"orden-compra/internal/notify"
+	"orden-compra/internal/tenant"
+)
+
+// maxSupplierImportRows bounds the suppliers of a single import
+const maxSupplierImportRows = 1000
+
+// SupplierImportColumns are the columns of a supplier import CSV. The
+// products column lists the supplier's products separated by semicolons,
+// each as product_id, optionally followed by |supplier_sku and
+// |lead_time_days.
+var SupplierImportColumns = []string{
+	"id", "name", "email", "phone", "address", "is_active",
+	"lead_time_days", "critical_lead_time_days", "products",
+}
+
+var (
+	// ErrInvalidImport is returned when an import file cannot be read at all
+	ErrInvalidImport = errors.New("invalid supplier import")
+	// ErrContactNotFound is returned when a supplier has no contact with an ID
+	ErrContactNotFound = errors.New("supplier contact not found")
+	// ErrInvalidEscalationRule is returned for rules of unknown events
+	ErrInvalidEscalationRule = errors.New("invalid escalation rule")
+)
+
+// SupplierImportRow is one supplier of an import. Suppliers are active
+// unless IsActive is false.
+type SupplierImportRow struct {
+	ID                   string                   `json:"id"`
+	Name                 string                   `json:"name"`
+	Email                string                   `json:"email"`
+	Phone                string                   `json:"phone"`
+	Address              string                   `json:"address"`
+	IsActive             *bool                    `json:"is_active,omitempty"`
+	LeadTimeDays         int                      `json:"lead_time_days"`
+	CriticalLeadTimeDays int                      `json:"critical_lead_time_days,omitempty"`
+	Products             []models.SupplierProduct `json:"products,omitempty"`
+	Metadata             map[string]interface{}   `json:"metadata,omitempty"`
+
+	// errs holds the problems found while parsing a CSV row
+	errs models.ValidationErrors
+}
+
+// Supplier returns the catalog entry of the row
+func (r *SupplierImportRow) Supplier() *models.Supplier {
+	return &models.Supplier{
+		ID:                   strings.TrimSpace(r.ID),
+		Name:                 strings.TrimSpace(r.Name),
+		Email:                strings.TrimSpace(r.Email),
+		Phone:                strings.TrimSpace(r.Phone),
+		Address:              strings.TrimSpace(r.Address),
+		IsActive:             r.IsActive == nil || *r.IsActive,
+		LeadTimeDays:         r.LeadTimeDays,
+		CriticalLeadTimeDays: r.CriticalLeadTimeDays,
+		Products:             r.Products,
+		Metadata:             r.Metadata,
+	}
+}
+
+// SupplierImportResult is the outcome of one row of a supplier import. Row
+// counts from 1, not counting a CSV header.
+type SupplierImportResult struct {
+	Row        int                     `json:"row"`
+	SupplierID string                  `json:"supplier_id,omitempty"`
+	Success    bool                    `json:"success"`
+	Created    bool                    `json:"created,omitempty"`
+	Products   int                     `json:"products,omitempty"`
+	Error      string                  `json:"error,omitempty"`
+	Errors     models.ValidationErrors `json:"errors,omitempty"`
+}
+
+// SupplierHandler maintains the supplier catalog
+type SupplierHandler struct {
+	DynamoDB     dynamodbiface.DynamoDBAPI
+	ScanSegments int
+	Stats        cqrs.StatsProjection
+	Audit        *audit.Recorder
+	Logger       *log.Logger
+}
+
+// NewSupplierHandler creates a new supplier handler
+func NewSupplierHandler(dynamoDB dynamodbiface.DynamoDBAPI, auditRecorder *audit.Recorder, logger *log.Logger) *SupplierHandler {
+	return &SupplierHandler{
+		DynamoDB: dynamoDB,
+		Audit:    auditRecorder,
+		Logger:   logger,
+	}
+}
+
+// ParseSupplierCSV reads the rows of a supplier import CSV. The header names
+// the columns, in any order; id and name are required, unknown columns are
+// rejected. Cells that cannot be parsed are reported on their row rather
+// than failing the import.
+func ParseSupplierCSV(r io.Reader) ([]*SupplierImportRow, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("%w: missing header", ErrInvalidImport)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+	}
+
+	known := make(map[string]bool, len(SupplierImportColumns))
+	for _, column := range SupplierImportColumns {
+		known[column] = true
+	}
+	columns := make(map[string]int, len(header))
+	for i, column := range header {
+		column = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(column, "\ufeff")))
+		if !known[column] {
+			return nil, fmt.Errorf("%w: unknown column %q", ErrInvalidImport, column)
+		}
+		columns[column] = i
+	}
+	for _, column := range []string{"id", "name"} {
+		if _, ok := columns[column]; !ok {
+			return nil, fmt.Errorf("%w: missing column %q", ErrInvalidImport, column)
+		}
+	}
+
+	var rows []*SupplierImportRow
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+		}
+
+		cell := func(column string) string {
+			if i, ok := columns[column]; ok {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		row := &SupplierImportRow{
+			ID:      cell("id"),
+			Name:    cell("name"),
+			Email:   cell("email"),
+			Phone:   cell("phone"),
+			Address: cell("address"),
+		}
+		if value := cell("is_active"); value != "" {
+			active, err := strconv.ParseBool(value)
+			if err != nil {
+				row.invalid("is_active", fmt.Sprintf("must be true or false, got %q", value))
+			}
+			row.IsActive = &active
+		}
+		row.LeadTimeDays = row.parseDays("lead_time_days", cell("lead_time_days"))
+		row.CriticalLeadTimeDays = row.parseDays("critical_lead_time_days", cell("critical_lead_time_days"))
+		row.Products = row.parseProducts(cell("products"))
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// invalid records a problem found while parsing the row
+func (r *SupplierImportRow) invalid(field, message string) {
+	r.errs = append(r.errs, models.ValidationError{Field: field, Message: message})
+}
+
+// parseDays parses a lead time cell, which may be empty
+func (r *SupplierImportRow) parseDays(field, value string) int {
+	if value == "" {
+		return 0
+	}
+	days, err := strconv.Atoi(value)
+	if err != nil {
+		r.invalid(field, fmt.Sprintf("must be a whole number of days, got %q", value))
+	}
+	return days
+}
+
+// parseProducts parses the products cell
+func (r *SupplierImportRow) parseProducts(value string) []models.SupplierProduct {
+	var products []models.SupplierProduct
+	for i, entry := range strings.Split(value, ";") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		parts := strings.Split(entry, "|")
+		if len(parts) > 3 {
+			r.invalid(fmt.Sprintf("products[%d]", i), fmt.Sprintf("must be product_id|supplier_sku|lead_time_days, got %q", entry))
+			continue
+		}
+		product := models.SupplierProduct{ProductID: strings.TrimSpace(parts[0])}
+		if len(parts) > 1 {
+			product.SupplierSKU = strings.TrimSpace(parts[1])
+		}
+		if len(parts) > 2 {
+			product.LeadTimeDays = r.parseDays(fmt.Sprintf("products[%d].lead_time_days", i), strings.TrimSpace(parts[2]))
+		}
+		products = append(products, product)
+	}
+	return products
+}
+
+// ImportSuppliers validates each row and upserts it into the caller's
+// tenant's supplier catalog, reporting the outcome of each row. An invalid
+// or failed row does not stop the others. A supplier listed on more than one
+// row is imported from the first and rejected on the others. Orders awaiting
+// delivery from the imported suppliers then take their names, so renaming a
+// supplier shows on its open orders; importing it again retries orders that
+// failed to refresh.
+func (h *SupplierHandler) ImportSuppliers(ctx context.Context, rows []*SupplierImportRow) (map[string]interface{}, error) {
+	switch {
+	case len(rows) == 0:
+		return nil, models.ValidationErrors{{Field: "suppliers", Message: "is required"}}
+	case len(rows) > maxSupplierImportRows:
+		return nil, models.ValidationErrors{{Field: "suppliers", Message: fmt.Sprintf("must not list more than %d suppliers, got %d", maxSupplierImportRows, len(rows))}}
+	}
+
+	tenantID, _ := tenant.FromContext(ctx)
+	results := make([]SupplierImportResult, 0, len(rows))
+	seen := make(map[string]int, len(rows))
+	names := make(map[string]string, len(rows))
+	created, updated, failed := 0, 0, 0
+	for i, row := range rows {
+		result := h.importRow(ctx, i+1, row, tenantID, seen, names)
+		switch {
+		case !result.Success:
+			failed++
+		case result.Created:
+			created++
+		default:
+			updated++
+		}
+		results = append(results, result)
+	}
+
+	h.Logger.Printf("Suppliers imported - rows: %d, created: %d, updated: %d, failed: %d", len(rows), created, updated, failed)
+
+	// The catalog is stored either way; orders left with an old name are
+	// reported and refreshed by the next import
+	refresh := map[string]interface{}{"refreshed": 0, "failed": 0}
+	if len(names) > 0 {
+		command := cqrs.NewRefreshSupplierNamesCommand(names, h.DynamoDB, h.Logger)
+		command.Stats = h.Stats
+		command.ScanSegments = h.ScanSegments
+		output, err := command.Execute(ctx)
+		if err != nil {
+			h.Logger.Printf("Failed to refresh the supplier names of orders: %v", err)
+			refresh["error"] = err.Error()
+		} else {
+			refresh["refreshed"], refresh["failed"] = output["refreshed"], output["failed"]
+		}
+	}
+
+	return map[string]interface{}{
+		"success":        failed == 0,
+		"rows":           len(rows),
+		"created":        created,
+		"updated":        updated,
+		"failed":         failed,
+		"results":        results,
+		"orders_refresh": refresh,
+	}, nil
+}
+
+// importRow validates and upserts one row, adding the name of an imported
+// supplier to names
+func (h *SupplierHandler) importRow(ctx context.Context, number int, row *SupplierImportRow, tenantID string, seen map[string]int, names map[string]string) SupplierImportResult {
+	supplier := row.Supplier()
+	supplier.TenantID = tenantID
+	result := SupplierImportResult{Row: number, SupplierID: supplier.ID}
+
+	errs := append(models.ValidationErrors{}, row.errs...)
+	var validationErrors models.ValidationErrors
+	if errors.As(supplier.Validate(), &validationErrors) {
+		errs = append(errs, validationErrors...)
+	}
+	if first, ok := seen[supplier.ID]; ok && supplier.ID != "" {
+		errs = append(errs, models.ValidationError{Field: "id", Message: fmt.Sprintf("supplier %s is already imported from row %d", supplier.ID, first)})
+	} else {
+		seen[supplier.ID] = number
+	}
+	if len(errs) > 0 {
+		result.Error = errs.Error()
+		result.Errors = errs
+		return result
+	}
+
+	output, err := cqrs.NewUpsertSupplierCommand(supplier, h.DynamoDB, h.Logger).Execute(ctx)
+	var before, after interface{}
+	if err == nil {
+		before, _ = output["previous"].(*models.Supplier)
+		after = output["supplier"]
+	}
+	h.Audit.Record(ctx, models.AuditSupplierImported, models.AuditResourceSupplier, supplier.ID, before, after, err)
+	if err != nil {
+		h.Logger.Printf("Failed to import supplier - row: %d, supplier_id: %s: %v", number, supplier.ID, err)
+		result.Error = err.Error()
+		return result
+	}
+
+	result.Success = true
+	result.Created, _ = output["created"].(bool)
+	names[supplier.ID] = supplier.Name
+	result.Products = len(supplier.Products)
+	return result
+}
+
+// GetSupplier returns a supplier of the catalog
+func (h *SupplierHandler) GetSupplier(ctx context.Context, supplierID string) (map[string]interface{}, error) {
+	supplier, err := h.supplier(ctx, supplierID)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"success":  true,
+		"supplier": supplier,
+	}, nil
+}
+
+// ListContacts returns the contacts of a supplier
+func (h *SupplierHandler) ListContacts(ctx context.Context, supplierID string) (map[string]interface{}, error) {
+	supplier, err := h.supplier(ctx, supplierID)
+	if err != nil {
+		return nil, err
+	}
+
+	contacts := supplier.Contacts
+	if contacts == nil {
+		contacts = []models.SupplierContact{}
+	}
+	return map[string]interface{}{
+		"success":     true,
+		"supplier_id": supplier.ID,
+		"contacts":    contacts,
+		"count":       len(contacts),
+	}, nil
+}
+
+// AddContact adds a contact to a supplier under a new ID
+func (h *SupplierHandler) AddContact(ctx context.Context, supplierID string, contact models.SupplierContact) (map[string]interface{}, error) {
+	contact.ID = uuid.New().String()
+	supplier, err := h.update(ctx, supplierID, func(supplier *models.Supplier) error {
+		supplier.Contacts = append(supplier.Contacts, contact)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	h.Logger.Printf("Supplier contact added - supplier_id: %s, contact_id: %s, role: %s", supplier.ID, contact.ID, contact.Role)
+
+	return map[string]interface{}{
+		"success":     true,
+		"supplier_id": supplier.ID,
+		"contact":     supplier.Contact(contact.ID),
+	}, nil
+}
+
+// UpdateContact replaces a contact of a supplier
+func (h *SupplierHandler) UpdateContact(ctx context.Context, supplierID, contactID string, contact models.SupplierContact) (map[string]interface{}, error) {
+	contact.ID = contactID
+	supplier, err := h.update(ctx, supplierID, func(supplier *models.Supplier) error {
+		existing := supplier.Contact(contactID)
+		if existing == nil {
+			return fmt.Errorf("%w: %s", ErrContactNotFound, contactID)
+		}
+		*existing = contact
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	h.Logger.Printf("Supplier contact updated - supplier_id: %s, contact_id: %s, role: %s", supplier.ID, contactID, contact.Role)
+
+	return map[string]interface{}{
+		"success":     true,
+		"supplier_id": supplier.ID,
+		"contact":     supplier.Contact(contactID),
+	}, nil
+}
+
+// DeleteContact removes a contact from a supplier
+func (h *SupplierHandler) DeleteContact(ctx context.Context, supplierID, contactID string) (map[string]interface{}, error) {
+	supplier, err := h.update(ctx, supplierID, func(supplier *models.Supplier) error {
+		contacts := make([]models.SupplierContact, 0, len(supplier.Contacts))
+		for _, contact := range supplier.Contacts {
+			if contact.ID != contactID {
+				contacts = append(contacts, contact)
+			}
+		}
+		if len(contacts) == len(supplier.Contacts) {
+			return fmt.Errorf("%w: %s", ErrContactNotFound, contactID)
+		}
+		supplier.Contacts = contacts
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	h.Logger.Printf("Supplier contact deleted - supplier_id: %s, contact_id: %s", supplier.ID, contactID)
+
+	return map[string]interface{}{
+		"success":     true,
+		"supplier_id": supplier.ID,
+		"contact_id":  contactID,
+	}, nil
+}
+
+// GetEscalationRules returns the escalation rules of a supplier together
+// with the defaults applied when none of them match
+func (h *SupplierHandler) GetEscalationRules(ctx context.Context, supplierID string) (map[string]interface{}, error) {
+	supplier, err := h.supplier(ctx, supplierID)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := supplier.EscalationRules
+	if rules == nil {
+		rules = []models.EscalationRule{}
+	}
+	return map[string]interface{}{
+		"success":          true,
+		"supplier_id":      supplier.ID,
+		"escalation_rules": rules,
+		"defaults":         notify.DefaultEscalationRules,
+	}, nil
+}
+
+// SetEscalationRules replaces the escalation rules of a supplier. Rules
+// are tried in order; an empty list leaves only the defaults.
+func (h *SupplierHandler) SetEscalationRules(ctx context.Context, supplierID string, rules []models.EscalationRule) (map[string]interface{}, error) {
+	events := make(map[string]bool, len(notify.AllEvents))
+	for _, event := range notify.AllEvents {
+		events[event] = true
+	}
+	for _, rule := range rules {
+		if rule.Event != "" && !events[rule.Event] {
+			return nil, fmt.Errorf("%w: unknown event %q", ErrInvalidEscalationRule, rule.Event)
+		}
+	}
+
+	if rules == nil {
+		rules = []models.EscalationRule{}
+	}
+	supplier, err := h.update(ctx, supplierID, func(supplier *models.Supplier) error {
+		supplier.EscalationRules = rules
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	h.Logger.Printf("Supplier escalation rules updated - supplier_id: %s, rules: %d", supplier.ID, len(rules))
+
+	return map[string]interface{}{
+		"success":          true,
+		"supplier_id":      supplier.ID,
+		"escalation_rules": supplier.EscalationRules,
+	}, nil
+}
+
+// supplier reads a supplier, failing when it is not in the catalog
+func (h *SupplierHandler) supplier(ctx context.Context, supplierID string) (*models.Supplier, error) {
+	supplier, err := cqrs.FindSupplier(ctx, h.DynamoDB, supplierID, false)
+	if err != nil {
+		return nil, err
+	}
+	if supplier == nil {
+		return nil, fmt.Errorf("%w: %s", cqrs.ErrSupplierNotFound, supplierID)
+	}
+	return supplier, nil
+}
+
+// update applies change to a copy of the stored supplier and stores it,
+// auditing the change. It fails with cqrs.ErrSupplierConflict when the
+// supplier is modified concurrently.
+func (h *SupplierHandler) update(ctx context.Context, supplierID string, change func(*models.Supplier) error) (*models.Supplier, error) {
+	before, err := cqrs.FindSupplier(ctx, h.DynamoDB, supplierID, true)
+	if err != nil {
+		return nil, err
+	}
+	if before == nil {
+		return nil, fmt.Errorf("%w: %s", cqrs.ErrSupplierNotFound, supplierID)
+	}
+
+	supplier := *before
+	supplier.Contacts = append([]models.SupplierContact{}, before.Contacts...)
+	supplier.EscalationRules = append([]models.EscalationRule{}, before.EscalationRules...)
+	if err := change(&supplier); err != nil {
+		return nil, err
+	}
+
+	result, err := cqrs.NewUpsertSupplierCommand(&supplier, h.DynamoDB, h.Logger).Execute(ctx)
+	var after *models.Supplier
+	if err == nil {
+		after = result["supplier"].(*models.Supplier)
+	}
+	h.Audit.Record(ctx, models.AuditSupplierUpdated, models.AuditResourceSupplier, supplierID, before, after, err)
+	if err != nil {
+		return nil, err
+	}
+	return after, nil
+}
+
+// NotificationSuppliers finds the supplier of a notification in the catalog
+// of the notification's tenant
+type NotificationSuppliers struct {
+	DynamoDB dynamodbiface.DynamoDBAPI
+}
+
+// NewNotificationSuppliers creates a new NotificationSuppliers
+func NewNotificationSuppliers(dynamoDB dynamodbiface.DynamoDBAPI) *NotificationSuppliers {
+	return &NotificationSuppliers{DynamoDB: dynamoDB}
+}
+
+// NotificationSupplier returns the supplier named by the notification's
+// supplier_id, or nil if it names none or the supplier is not listed
+func (s *NotificationSuppliers) NotificationSupplier(ctx context.Context, notification notify.Notification) (*models.Supplier, error) {
+	supplierID, _ := notification.Data["supplier_id"].(string)
+	if supplierID == "" {
+		return nil, nil
+	}
+	if tenantID, _ := notification.Data["tenant_id"].(string); tenantID != "" {
+		ctx = tenant.NewContext(ctx, tenantID)
+	}
+	return cqrs.FindSupplier(cache.AllowStale(ctx), s.DynamoDB, supplierID, false)
+}
@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"orden-compra/internal/audit"
+	"orden-compra/internal/cqrs"
+	"orden-compra/internal/memory"
+	"orden-compra/internal/models"
+)
+
+func TestParseSupplierCSV(t *testing.T) {
+	rows, err := ParseSupplierCSV(strings.NewReader("\ufeffName, ID, is_active, lead_time_days, products\n" +
+		"Acme, supplier-1, false, 5, product-1|ACME-1|3; product-2\n" +
+		"Globex, supplier-2, maybe, soon, product-3|a|b|c\n"))
+	if err != nil || len(rows) != 2 {
+		t.Fatalf("parsed %d rows, error %v", len(rows), err)
+	}
+
+	acme := rows[0].Supplier()
+	if acme.ID != "supplier-1" || acme.Name != "Acme" || acme.IsActive || acme.LeadTimeDays != 5 || len(rows[0].errs) != 0 {
+		t.Fatalf("first row %+v, errors %v", acme, rows[0].errs)
+	}
+	if len(acme.Products) != 2 || acme.Products[0] != (models.SupplierProduct{ProductID: "product-1", SupplierSKU: "ACME-1", LeadTimeDays: 3}) || acme.Products[1].ProductID != "product-2" {
+		t.Fatalf("products %+v", acme.Products)
+	}
+
+	// Cells that do not parse are reported on their row
+	var fields []string
+	for _, e := range rows[1].errs {
+		fields = append(fields, e.Field)
+	}
+	if strings.Join(fields, ",") != "is_active,lead_time_days,products[0]" {
+		t.Fatalf("second row errors %v", rows[1].errs)
+	}
+
+	for _, input := range []string{"", "id,name,colour\n", "id,email\n", "id,name\n\"open"} {
+		if _, err := ParseSupplierCSV(strings.NewReader(input)); !errors.Is(err, ErrInvalidImport) {
+			t.Errorf("parsing %q returned %v, want ErrInvalidImport", input, err)
+		}
+	}
+}
+
+func TestImportSuppliersReportsEachRow(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	store := audit.NewStore(dynamoDB)
+	h := NewSupplierHandler(dynamoDB, audit.NewRecorder(store, dynamoDB, logger), logger)
+
+	// The existing entry keeps its creation time and the metadata the row does not set
+	existing := &models.Supplier{ID: "supplier-1", Name: "Old name", Metadata: map[string]interface{}{"dispatch_channel": "email"}}
+	if _, err := cqrs.NewUpsertSupplierCommand(existing, dynamoDB, logger).Execute(context.Background()); err != nil {
+		t.Fatalf("store supplier: %v", err)
+	}
+	createdAt := mustFindSupplier(t, h, "supplier-1").CreatedAt
+	order := models.NewPurchaseOrder("product-1", "Gloves", "supplier-1", "Old name", "warehouse-1", "high", 10, time.Now())
+	if _, err := cqrs.NewCreatePurchaseOrderCommand(order, dynamoDB, logger, nil, nil).Execute(context.Background()); err != nil {
+		t.Fatalf("create purchase order: %v", err)
+	}
+	time.Sleep(time.Millisecond)
+
+	inactive := false
+	result, err := h.ImportSuppliers(context.Background(), []*SupplierImportRow{
+		{ID: "supplier-1", Name: "Acme", LeadTimeDays: 5, Products: []models.SupplierProduct{{ProductID: "product-1"}}},
+		{ID: "supplier-2", Name: "Globex", IsActive: &inactive},
+		{ID: "supplier-3", Email: "not an address"},
+		{ID: "supplier-1", Name: "Acme again"},
+	})
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if result["success"] != false || result["created"] != 1 || result["updated"] != 1 || result["failed"] != 2 {
+		t.Fatalf("result %v", result)
+	}
+	results := result["results"].([]SupplierImportResult)
+	if !results[0].Success || results[0].Created || results[0].Products != 1 || !results[1].Success || !results[1].Created {
+		t.Fatalf("imported rows %+v", results[:2])
+	}
+	if results[2].Success || len(results[2].Errors) != 2 || results[3].Success || results[3].Errors[0].Field != "id" {
+		t.Fatalf("rejected rows %+v", results[2:])
+	}
+
+	acme := mustFindSupplier(t, h, "supplier-1")
+	if acme.Name != "Acme" || !acme.IsActive || !acme.CreatedAt.Equal(createdAt) || !acme.UpdatedAt.After(createdAt) || acme.Metadata["dispatch_channel"] != "email" {
+		t.Fatalf("updated supplier %+v", acme)
+	}
+	if globex := mustFindSupplier(t, h, "supplier-2"); globex.IsActive {
+		t.Fatal("supplier imported as inactive is active")
+	}
+	if entries, err := store.Query(context.Background(), audit.Filter{Action: models.AuditSupplierImported}); err != nil || len(entries) != 2 {
+		t.Fatalf("audited %d imports, error %v, want 2", len(entries), err)
+	}
+
+	// The open order of the renamed supplier takes its new name
+	if refresh := result["orders_refresh"].(map[string]interface{}); refresh["refreshed"] != 1 || refresh["failed"] != 0 {
+		t.Fatalf("orders refresh %v, want the open order refreshed", refresh)
+	}
+	stored, err := cqrs.FindPurchaseOrder(context.Background(), dynamoDB, order.ID, true)
+	if err != nil || stored.SupplierName != "Acme" {
+		t.Fatalf("open order %+v, error %v, want it named Acme", stored, err)
+	}
+
+	if _, err := h.ImportSuppliers(context.Background(), nil); err == nil {
+		t.Fatal("imported no suppliers")
+	}
+}
+
+// mustFindSupplier reads a supplier straight from the catalog
+func mustFindSupplier(t *testing.T, h *SupplierHandler, supplierID string) *models.Supplier {
+	t.Helper()
+	supplier, err := cqrs.FindSupplier(context.Background(), h.DynamoDB, supplierID, true)
+	if err != nil || supplier == nil {
+		t.Fatalf("supplier %s: %v, error %v", supplierID, supplier, err)
+	}
+	return supplier
+}
+
+func TestSupplierContactsAndEscalationRules(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	h := NewSupplierHandler(dynamoDB, nil, logger)
+	ctx := context.Background()
+	if _, err := cqrs.NewUpsertSupplierCommand(&models.Supplier{ID: "supplier-1", Name: "Acme"}, dynamoDB, logger).Execute(ctx); err != nil {
+		t.Fatalf("store supplier: %v", err)
+	}
+
+	result, err := h.AddContact(ctx, "supplier-1", models.SupplierContact{Role: models.ContactRoleQuality, Email: "quality@acme.test"})
+	if err != nil {
+		t.Fatalf("add contact: %v", err)
+	}
+	contact := result["contact"].(*models.SupplierContact)
+	if contact.ID == "" || contact.Email != "quality@acme.test" {
+		t.Fatalf("added contact %+v", contact)
+	}
+	if _, err := h.UpdateContact(ctx, "supplier-1", contact.ID, models.SupplierContact{Role: models.ContactRoleEmergency, Phone: "+57 300 000 0000"}); err != nil {
+		t.Fatalf("update contact: %v", err)
+	}
+	if _, err := h.AddContact(ctx, "supplier-1", models.SupplierContact{Role: "sales", Email: "sales@acme.test"}); err == nil {
+		t.Fatal("added a contact with an unknown role")
+	}
+
+	// Importing the supplier again keeps the contacts it does not list
+	if _, err := h.ImportSuppliers(ctx, []*SupplierImportRow{{ID: "supplier-1", Name: "Acme Corp"}}); err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	result, err = h.ListContacts(ctx, "supplier-1")
+	if err != nil || result["count"] != 1 {
+		t.Fatalf("contacts %v, error %v", result, err)
+	}
+	if listed := result["contacts"].([]models.SupplierContact)[0]; listed.ID != contact.ID || listed.Role != models.ContactRoleEmergency || listed.Email != "" {
+		t.Fatalf("listed contact %+v", listed)
+	}
+
+	rules := []models.EscalationRule{{UrgencyLevels: []string{"critical"}, Roles: []string{models.ContactRoleEmergency}}}
+	if _, err := h.SetEscalationRules(ctx, "supplier-1", rules); err != nil {
+		t.Fatalf("set escalation rules: %v", err)
+	}
+	if _, err := h.SetEscalationRules(ctx, "supplier-1", []models.EscalationRule{{Event: "order_lost", Roles: []string{models.ContactRoleEmergency}}}); !errors.Is(err, ErrInvalidEscalationRule) {
+		t.Fatalf("rule of an unknown event returned %v", err)
+	}
+	result, err = h.GetEscalationRules(ctx, "supplier-1")
+	if err != nil || len(result["escalation_rules"].([]models.EscalationRule)) != 1 || result["defaults"] == nil {
+		t.Fatalf("escalation rules %v, error %v", result, err)
+	}
+
+	if _, err := h.DeleteContact(ctx, "supplier-1", contact.ID); err != nil {
+		t.Fatalf("delete contact: %v", err)
+	}
+	if _, err := h.DeleteContact(ctx, "supplier-1", contact.ID); !errors.Is(err, ErrContactNotFound) {
+		t.Fatalf("deleting a contact twice returned %v", err)
+	}
+	if _, err := h.UpdateContact(ctx, "supplier-1", "missing", models.SupplierContact{}); !errors.Is(err, ErrContactNotFound) {
+		t.Fatalf("update of a missing contact returned %v", err)
+	}
+	if _, err := h.ListContacts(ctx, "supplier-2"); !errors.Is(err, cqrs.ErrSupplierNotFound) {
+		t.Fatalf("contacts of a missing supplier returned %v", err)
+	}
+}
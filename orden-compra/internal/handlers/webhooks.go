@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"orden-compra/internal/audit"
+	"orden-compra/internal/models"
+	"orden-compra/internal/tenant"
+	"orden-compra/internal/webhooks"
+)
+
+// WebhookHandler manages outbound webhook subscriptions
+type WebhookHandler struct {
+	Store      *webhooks.Store
+	Dispatcher *webhooks.Dispatcher
+	Audit      *audit.Recorder
+	Logger     *log.Logger
+}
+
+// NewWebhookHandler creates a new webhook handler
+func NewWebhookHandler(store *webhooks.Store, dispatcher *webhooks.Dispatcher, auditRecorder *audit.Recorder, logger *log.Logger) *WebhookHandler {
+	return &WebhookHandler{
+		Store:      store,
+		Dispatcher: dispatcher,
+		Audit:      auditRecorder,
+		Logger:     logger,
+	}
+}
+
+// CreateSubscription registers an endpoint for the given event types. A secret
+// is generated when none is given; it is only returned in this response.
+func (h *WebhookHandler) CreateSubscription(ctx context.Context, endpoint, secret string, eventTypes []string) (map[string]interface{}, error) {
+	parsed, err := url.Parse(endpoint)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, fmt.Errorf("%w: url must be an absolute http(s) URL", webhooks.ErrInvalidSubscription)
+	}
+	if len(eventTypes) == 0 {
+		return nil, fmt.Errorf("%w: at least one event type is required", webhooks.ErrInvalidSubscription)
+	}
+	for _, eventType := range eventTypes {
+		if !models.IsValidWebhookEventType(eventType) {
+			return nil, fmt.Errorf("%w: unknown event type %q", webhooks.ErrInvalidSubscription, eventType)
+		}
+	}
+
+	if secret == "" {
+		secret, err = webhooks.GenerateSecret()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	subscription := models.NewWebhookSubscription(endpoint, secret, eventTypes, time.Now())
+	subscription.TenantID, _ = tenant.FromContext(ctx)
+	if err := h.Store.SaveSubscription(ctx, subscription); err != nil {
+		return nil, err
+	}
+	h.Dispatcher.Invalidate()
+
+	audited := *subscription
+	audited.Secret = ""
+	h.Audit.Record(ctx, models.AuditWebhookSubscriptionCreated, models.AuditResourceWebhookSubscription, subscription.ID, nil, &audited, nil)
+
+	h.Logger.Printf("Webhook subscription created - subscription_id: %s, url: %s, event_types: %v", subscription.ID, subscription.URL, subscription.EventTypes)
+
+	return map[string]interface{}{
+		"success":      true,
+		"subscription": subscription,
+	}, nil
+}
+
+// ListSubscriptions returns every subscription without its secret
+func (h *WebhookHandler) ListSubscriptions(ctx context.Context) (map[string]interface{}, error) {
+	subscriptions, err := h.Store.ListSubscriptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, subscription := range subscriptions {
+		subscription.Secret = ""
+	}
+
+	return map[string]interface{}{
+		"success":       true,
+		"subscriptions": subscriptions,
+		"count":         len(subscriptions),
+	}, nil
+}
+
+// GetSubscription returns a subscription without its secret
+func (h *WebhookHandler) GetSubscription(ctx context.Context, id string) (map[string]interface{}, error) {
+	subscription, err := h.Store.GetSubscription(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	subscription.Secret = ""
+
+	return map[string]interface{}{
+		"success":      true,
+		"subscription": subscription,
+	}, nil
+}
+
+// DeleteSubscription stops deliveries to a subscription
+func (h *WebhookHandler) DeleteSubscription(ctx context.Context, id string) (map[string]interface{}, error) {
+	before, err := h.Store.GetSubscription(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	before.Secret = ""
+
+	if err := h.Store.DeleteSubscription(ctx, id); err != nil {
+		return nil, err
+	}
+	h.Dispatcher.Invalidate()
+	h.Audit.Record(ctx, models.AuditWebhookSubscriptionDeleted, models.AuditResourceWebhookSubscription, id, before, nil, nil)
+
+	h.Logger.Printf("Webhook subscription deleted - subscription_id: %s", id)
+
+	return map[string]interface{}{
+		"success":         true,
+		"subscription_id": id,
+	}, nil
+}
+
+// ListDeliveries returns the delivery history of a subscription
+func (h *WebhookHandler) ListDeliveries(ctx context.Context, id string) (map[string]interface{}, error) {
+	if _, err := h.Store.GetSubscription(ctx, id); err != nil {
+		return nil, err
+	}
+
+	deliveries, err := h.Store.ListDeliveries(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"success":    true,
+		"deliveries": deliveries,
+		"count":      len(deliveries),
+	}, nil
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"testing"
+
+	"orden-compra/internal/memory"
+	"orden-compra/internal/models"
+	"orden-compra/internal/tenant"
+	"orden-compra/internal/webhooks"
+)
+
+func TestCreateSubscriptionValidatesTheRequest(t *testing.T) {
+	h := NewWebhookHandler(webhooks.NewStore(memory.NewDynamoDB(memory.Tables)), nil, nil, log.New(io.Discard, "", 0))
+
+	for _, tc := range []struct {
+		name       string
+		url        string
+		eventTypes []string
+	}{
+		{"relative url", "/hooks", []string{models.WebhookPurchaseOrderCreated}},
+		{"unsupported scheme", "ftp://erp.example.com/hooks", []string{models.WebhookPurchaseOrderCreated}},
+		{"no event types", "https://erp.example.com/hooks", nil},
+		{"unknown event type", "https://erp.example.com/hooks", []string{"purchase_order.deleted"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := h.CreateSubscription(context.Background(), tc.url, "", tc.eventTypes); !errors.Is(err, webhooks.ErrInvalidSubscription) {
+				t.Fatalf("CreateSubscription returned %v, want ErrInvalidSubscription", err)
+			}
+		})
+	}
+}
+
+func TestSubscriptionSecretIsOnlyReturnedOnCreation(t *testing.T) {
+	store := webhooks.NewStore(memory.NewDynamoDB(memory.Tables))
+	h := NewWebhookHandler(store, nil, nil, log.New(io.Discard, "", 0))
+	ctx := tenant.NewContext(context.Background(), "tenant-1")
+
+	result, err := h.CreateSubscription(ctx, "https://erp.example.com/hooks", "", []string{models.WebhookAllEvents})
+	if err != nil {
+		t.Fatalf("create subscription: %v", err)
+	}
+	created := result["subscription"].(*models.WebhookSubscription)
+	if len(created.Secret) != 64 || created.TenantID != "tenant-1" {
+		t.Fatalf("created %+v, want a generated secret owned by tenant-1", created)
+	}
+
+	result, err = h.GetSubscription(ctx, created.ID)
+	if err != nil || result["subscription"].(*models.WebhookSubscription).Secret != "" {
+		t.Fatalf("get returned %v, error %v, want no secret", result, err)
+	}
+	result, err = h.ListSubscriptions(ctx)
+	if err != nil || result["count"] != 1 || result["subscriptions"].([]*models.WebhookSubscription)[0].Secret != "" {
+		t.Fatalf("list returned %v, error %v, want no secret", result, err)
+	}
+
+	// The stored subscription keeps its secret for signing
+	if stored, err := store.GetSubscription(ctx, created.ID); err != nil || stored.Secret != created.Secret {
+		t.Fatalf("stored %+v, error %v", stored, err)
+	}
+
+	if _, err := h.DeleteSubscription(ctx, created.ID); err != nil {
+		t.Fatalf("delete subscription: %v", err)
+	}
+	if _, err := h.ListDeliveries(ctx, created.ID); !errors.Is(err, webhooks.ErrSubscriptionNotFound) {
+		t.Fatalf("deliveries of a deleted subscription returned %v", err)
+	}
+}
@@ -0,0 +1,127 @@
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HTTP headers of idempotent requests
+const (
+	Header = "Idempotency-Key"
+	// ReplayedHeader marks a response served from an earlier request
+	ReplayedHeader = "Idempotent-Replayed"
+)
+
+// maxKeyLength bounds client-supplied keys; a UUID fits comfortably
+const maxKeyLength = 255
+
+// responseRecorder keeps a copy of the response body as it is written
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.Write([]byte(s))
+}
+
+// Middleware makes a route idempotent for requests sent with an
+// Idempotency-Key header; requests without one run as usual. Keys are
+// scoped to the tenant by the store's table. A retry gets the stored status
+// and body of the first request. Responses that a retry may get past, 409,
+// 429 and server errors, are not stored, so the retry runs again.
+func Middleware(store *Store, logger *log.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(Header)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if !validKey(key) {
+			c.AbortWithStatusJSON(400, gin.H{"success": false, "error": "Idempotency-Key must be at most 255 printable ASCII characters"})
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatusJSON(400, gin.H{"success": false, "error": "failed to read request body"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		ctx := c.Request.Context()
+		record, err := store.Begin(ctx, key, fingerprint(c.Request.Method, c.Request.URL.Path, body), time.Now().UTC())
+		switch {
+		case errors.Is(err, ErrKeyInProgress):
+			c.AbortWithStatusJSON(409, gin.H{"success": false, "error": err.Error()})
+			return
+		case errors.Is(err, ErrKeyReused):
+			c.AbortWithStatusJSON(422, gin.H{"success": false, "error": err.Error()})
+			return
+		case err != nil:
+			logger.Printf("Idempotency key %s not claimed: %v", key, err)
+			c.AbortWithStatusJSON(500, gin.H{"success": false, "error": err.Error()})
+			return
+		}
+
+		if record.State == StateCompleted {
+			c.Header(ReplayedHeader, "true")
+			c.Data(record.StatusCode, record.ContentType, record.Body)
+			c.Abort()
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		// The outcome is stored even when the client has gone away, since
+		// that is when it retries
+		ctx = context.WithoutCancel(ctx)
+		status := recorder.Status()
+		if status == 409 || status == 429 || status >= 500 {
+			if err := store.Release(ctx, record); err != nil {
+				logger.Printf("Idempotency key %s not released, retries wait for its lock to expire: %v", key, err)
+			}
+			return
+		}
+		if err := store.Complete(ctx, record, status, recorder.Header().Get("Content-Type"), recorder.body.Bytes(), time.Now().UTC()); err != nil {
+			logger.Printf("Idempotency key %s not completed, a retry may run the request again: %v", key, err)
+		}
+	}
+}
+
+// validKey reports whether a key is short printable ASCII
+func validKey(key string) bool {
+	if len(key) > maxKeyLength {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < 0x20 || key[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// fingerprint identifies a request by its method, path and body
+func fingerprint(method, path string, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(method + " " + path + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
@@ -0,0 +1,107 @@
+package idempotency
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"orden-compra/internal/memory"
+)
+
+// newRouter serves POST /orders through the middleware, answering with the
+// given statuses in turn and repeating the last one
+func newRouter(statuses ...int) (*gin.Engine, *int) {
+	gin.SetMode(gin.TestMode)
+	store := NewStore(memory.NewDynamoDB(memory.Tables), Config{TTL: time.Hour, LockTimeout: time.Minute})
+	runs := new(int)
+	router := gin.New()
+	router.POST("/orders", Middleware(store, log.New(io.Discard, "", 0)), func(c *gin.Context) {
+		status := statuses[len(statuses)-1]
+		if *runs < len(statuses) {
+			status = statuses[*runs]
+		}
+		*runs++
+		body, _ := io.ReadAll(c.Request.Body)
+		c.JSON(status, gin.H{"run": *runs, "body": string(body)})
+	})
+	return router, runs
+}
+
+func post(router *gin.Engine, key, body string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(body))
+	if key != "" {
+		request.Header.Set(Header, key)
+	}
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestMiddlewareReplaysTheFirstResponse(t *testing.T) {
+	router, runs := newRouter(http.StatusCreated)
+
+	first := post(router, "key-1", `{"quantity":1}`)
+	if first.Code != http.StatusCreated || first.Header().Get(ReplayedHeader) != "" {
+		t.Fatalf("first request answered %d, replayed %q", first.Code, first.Header().Get(ReplayedHeader))
+	}
+	// The handler still reads the body the middleware read
+	if !strings.Contains(first.Body.String(), `"body":"{\"quantity\":1}"`) {
+		t.Fatalf("handler read %s", first.Body)
+	}
+
+	retry := post(router, "key-1", `{"quantity":1}`)
+	if retry.Code != http.StatusCreated || retry.Header().Get(ReplayedHeader) != "true" || retry.Body.String() != first.Body.String() || !strings.HasPrefix(retry.Header().Get("Content-Type"), "application/json") {
+		t.Fatalf("retry answered %d %q with %s", retry.Code, retry.Header().Get(ReplayedHeader), retry.Body)
+	}
+	if *runs != 1 {
+		t.Fatalf("handler ran %d times, want 1", *runs)
+	}
+
+	// The key cannot be reused for another request
+	if reused := post(router, "key-1", `{"quantity":2}`); reused.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("reused key answered %d, want 422", reused.Code)
+	}
+
+	// Requests without a key run every time
+	post(router, "", `{"quantity":1}`)
+	post(router, "", `{"quantity":1}`)
+	if *runs != 3 {
+		t.Fatalf("handler ran %d times, want 3", *runs)
+	}
+}
+
+func TestMiddlewareRunsRetriesOfFailedRequests(t *testing.T) {
+	for _, status := range []int{http.StatusConflict, http.StatusTooManyRequests, http.StatusServiceUnavailable} {
+		t.Run(http.StatusText(status), func(t *testing.T) {
+			router, runs := newRouter(status, http.StatusCreated)
+
+			if first := post(router, "key-1", "{}"); first.Code != status {
+				t.Fatalf("first request answered %d, want %d", first.Code, status)
+			}
+			if retry := post(router, "key-1", "{}"); retry.Code != http.StatusCreated || retry.Header().Get(ReplayedHeader) != "" {
+				t.Fatalf("retry answered %d, replayed %q", retry.Code, retry.Header().Get(ReplayedHeader))
+			}
+			if *runs != 2 {
+				t.Fatalf("handler ran %d times, want 2", *runs)
+			}
+		})
+	}
+}
+
+func TestMiddlewareRejectsInvalidKeys(t *testing.T) {
+	router, runs := newRouter(http.StatusCreated)
+	for _, key := range []string{strings.Repeat("k", maxKeyLength+1), "key\x01", "clé"} {
+		if recorder := post(router, key, "{}"); recorder.Code != http.StatusBadRequest {
+			t.Errorf("key %q answered %d, want 400", key, recorder.Code)
+		}
+	}
+	if *runs != 0 {
+		t.Fatalf("handler ran %d times for invalid keys", *runs)
+	}
+}
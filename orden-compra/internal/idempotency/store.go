@@ -0,0 +1,208 @@
+// Package idempotency lets clients retry command requests safely: a request
+// sent with an Idempotency-Key header runs once, and retries with the same
+// key get the response of the first request instead of running it again.
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+
+	"orden-compra/internal/models"
+)
+
+// Record states
+const (
+	StatePending   = "pending"
+	StateCompleted = "completed"
+)
+
+var (
+	// ErrKeyInProgress is returned when a request with the same key is still running
+	ErrKeyInProgress = errors.New("a request with this idempotency key is in progress")
+	// ErrKeyReused is returned when the key was used for a different request
+	ErrKeyReused = errors.New("idempotency key was used for a different request")
+)
+
+// Config represents the idempotency settings
+type Config struct {
+	// TTL is how long a key is remembered after its first request
+	TTL time.Duration
+	// LockTimeout is how long a request holds its key before a retry may
+	// take it over, in case the replica running it stopped
+	LockTimeout time.Duration
+}
+
+// Record is a key and the response of the request it was first used with
+type Record struct {
+	Key string `dynamodbav:"id"`
+	// Fingerprint identifies the request, so a key sent with another request
+	// is refused rather than answered with an unrelated response
+	Fingerprint string `dynamodbav:"fingerprint"`
+	State       string `dynamodbav:"state"`
+	// LockedUntil is the epoch second a pending request gives up the key
+	LockedUntil int64     `dynamodbav:"locked_until"`
+	StatusCode  int       `dynamodbav:"status_code,omitempty"`
+	ContentType string    `dynamodbav:"content_type,omitempty"`
+	Body        []byte    `dynamodbav:"body,omitempty"`
+	CreatedAt   time.Time `dynamodbav:"created_at"`
+	CompletedAt time.Time `dynamodbav:"completed_at,omitempty"`
+	ExpiresAt   int64     `dynamodbav:"expires_at,omitempty"`
+}
+
+// Store keeps idempotency keys in DynamoDB. DynamoDB TTL removes them on
+// expires_at; until it does, expired keys are treated as unused.
+type Store struct {
+	DynamoDB  dynamodbiface.DynamoDBAPI
+	TableName string
+	Config    Config
+}
+
+// NewStore creates a store on the default idempotency table
+func NewStore(dynamoDB dynamodbiface.DynamoDBAPI, config Config) *Store {
+	return &Store{
+		DynamoDB:  dynamoDB,
+		TableName: "orden-compra-idempotency-keys",
+		Config:    config,
+	}
+}
+
+// Begin claims a key for a request. It returns the pending record when the
+// request should run, the completed record when it already ran,
+// ErrKeyInProgress while another request holds the key and ErrKeyReused
+// when the key belongs to a different request.
+func (s *Store) Begin(ctx context.Context, key, fingerprint string, now time.Time) (*Record, error) {
+	record := &Record{
+		Key:         key,
+		Fingerprint: fingerprint,
+		State:       StatePending,
+		LockedUntil: now.Add(s.Config.LockTimeout).Unix(),
+		CreatedAt:   now,
+		ExpiresAt:   models.ExpiresAt(now, s.Config.TTL),
+	}
+	item, err := dynamodbattribute.MarshalMap(record)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal idempotency key: %w", err)
+	}
+
+	// An expired key, or one held by a request that stopped, is taken over
+	_, err = s.DynamoDB.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName:                aws.String(s.TableName),
+		Item:                     item,
+		ConditionExpression:      aws.String("attribute_not_exists(id) OR #expires_at < :now OR (#state = :pending AND locked_until < :now)"),
+		ExpressionAttributeNames: map[string]*string{"#expires_at": aws.String(models.ExpiresAtAttribute), "#state": aws.String("state")},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":now":     {N: aws.String(strconv.FormatInt(now.Unix(), 10))},
+			":pending": {S: aws.String(StatePending)},
+		},
+	})
+	if err == nil {
+		return record, nil
+	}
+	var awsErr awserr.Error
+	if !errors.As(err, &awsErr) || awsErr.Code() != dynamodb.ErrCodeConditionalCheckFailedException {
+		return nil, fmt.Errorf("failed to put idempotency key: %w", err)
+	}
+
+	stored, err := s.get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case stored == nil:
+		// Deleted since the put, by a request that failed; this one may retry
+		return nil, ErrKeyInProgress
+	case stored.Fingerprint != fingerprint:
+		return nil, ErrKeyReused
+	case stored.State != StateCompleted:
+		return nil, ErrKeyInProgress
+	}
+	return stored, nil
+}
+
+// Complete stores the response of the request holding the key, on the
+// pending record returned by Begin
+func (s *Store) Complete(ctx context.Context, record *Record, statusCode int, contentType string, body []byte, now time.Time) error {
+	record.State = StateCompleted
+	record.StatusCode = statusCode
+	record.ContentType = contentType
+	record.Body = body
+	record.CompletedAt = now
+	item, err := dynamodbattribute.MarshalMap(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal idempotency key: %w", err)
+	}
+
+	_, err = s.DynamoDB.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName:                aws.String(s.TableName),
+		Item:                     item,
+		ConditionExpression:      aws.String("fingerprint = :fingerprint AND #state = :pending"),
+		ExpressionAttributeNames: map[string]*string{"#state": aws.String("state")},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":fingerprint": {S: aws.String(record.Fingerprint)},
+			":pending":     {S: aws.String(StatePending)},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to complete idempotency key: %w", err)
+	}
+	return nil
+}
+
+// Release gives up a key whose request failed, so a retry runs it again
+func (s *Store) Release(ctx context.Context, record *Record) error {
+	_, err := s.DynamoDB.DeleteItemWithContext(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(s.TableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {S: aws.String(record.Key)},
+		},
+		ConditionExpression:      aws.String("fingerprint = :fingerprint AND #state = :pending"),
+		ExpressionAttributeNames: map[string]*string{"#state": aws.String("state")},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":fingerprint": {S: aws.String(record.Fingerprint)},
+			":pending":     {S: aws.String(StatePending)},
+		},
+	})
+	if err != nil {
+		var awsErr awserr.Error
+		if errors.As(err, &awsErr) && awsErr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return nil
+		}
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+	return nil
+}
+
+// get reads a key, treating an expired one as absent
+func (s *Store) get(ctx context.Context, key string) (*Record, error) {
+	result, err := s.DynamoDB.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(s.TableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {S: aws.String(key)},
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get idempotency key: %w", err)
+	}
+	if result.Item == nil {
+		return nil, nil
+	}
+
+	var record Record
+	if err := dynamodbattribute.UnmarshalMap(result.Item, &record); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal idempotency key: %w", err)
+	}
+	if record.ExpiresAt > 0 && record.ExpiresAt < time.Now().Unix() {
+		return nil, nil
+	}
+	return &record, nil
+}
@@ -0,0 +1,77 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"orden-compra/internal/memory"
+)
+
+func TestStoreClaimsAKeyOnce(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore(memory.NewDynamoDB(memory.Tables), Config{TTL: 24 * time.Hour, LockTimeout: time.Minute})
+	now := time.Now().UTC()
+
+	record, err := store.Begin(ctx, "key-1", "request-1", now)
+	if err != nil || record.State != StatePending {
+		t.Fatalf("first claim returned %+v, error %v", record, err)
+	}
+	if _, err := store.Begin(ctx, "key-1", "request-1", now); !errors.Is(err, ErrKeyInProgress) {
+		t.Fatalf("claim while pending returned %v, want ErrKeyInProgress", err)
+	}
+	if _, err := store.Begin(ctx, "key-1", "request-2", now); !errors.Is(err, ErrKeyReused) {
+		t.Fatalf("claim for another request returned %v, want ErrKeyReused", err)
+	}
+
+	if err := store.Complete(ctx, record, 201, "application/json", []byte(`{"id":"po-1"}`), now); err != nil {
+		t.Fatalf("complete: %v", err)
+	}
+	replayed, err := store.Begin(ctx, "key-1", "request-1", now)
+	if err != nil || replayed.State != StateCompleted || replayed.StatusCode != 201 || replayed.ContentType != "application/json" || string(replayed.Body) != `{"id":"po-1"}` {
+		t.Fatalf("claim after completion returned %+v, error %v", replayed, err)
+	}
+	if _, err := store.Begin(ctx, "key-1", "request-2", now); !errors.Is(err, ErrKeyReused) {
+		t.Fatalf("claim of a completed key for another request returned %v, want ErrKeyReused", err)
+	}
+}
+
+func TestStoreReleasesAndTakesOverKeys(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore(memory.NewDynamoDB(memory.Tables), Config{TTL: 24 * time.Hour, LockTimeout: time.Minute})
+	now := time.Now().UTC()
+
+	// A released key runs again
+	record, err := store.Begin(ctx, "key-1", "request-1", now)
+	if err != nil {
+		t.Fatalf("claim: %v", err)
+	}
+	if err := store.Release(ctx, record); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	if _, err := store.Begin(ctx, "key-1", "request-1", now); err != nil {
+		t.Fatalf("claim after release returned %v", err)
+	}
+
+	// A request that stopped gives up the key when its lock times out
+	if record, err := store.Begin(ctx, "key-1", "request-1", now.Add(2*time.Minute)); err != nil || record.State != StatePending {
+		t.Fatalf("claim after the lock timed out returned %+v, error %v", record, err)
+	}
+
+	// A completed key is only taken over once it expires
+	record, err = store.Begin(ctx, "key-2", "request-1", now)
+	if err != nil {
+		t.Fatalf("claim: %v", err)
+	}
+	if err := store.Complete(ctx, record, 200, "application/json", nil, now); err != nil {
+		t.Fatalf("complete: %v", err)
+	}
+	// Releasing a completed key leaves it alone
+	if err := store.Release(ctx, record); err != nil {
+		t.Fatalf("release of a completed key: %v", err)
+	}
+	if record, err := store.Begin(ctx, "key-2", "request-2", now.Add(25*time.Hour)); err != nil || record.State != StatePending {
+		t.Fatalf("claim after expiry returned %+v, error %v", record, err)
+	}
+}
@@ -0,0 +1,667 @@
+// Package intake controls how fast the RabbitMQ consumers take messages in.
+// The prefetch count bounds the messages the broker hands a consumer ahead of
+// processing, the in-flight limit bounds how many of them are processed at
+// once, and a paused consumer takes nothing in at all, so operators can hold
+// intake back while a dependency such as DynamoDB is throttling instead of
+// letting retries pile up.
+//
+// A consumer whose delivery channel closes under it, because the broker
+// cancelled it or its channel failed, is reported down until the watchdog
+// registers it again, and a message whose handler panics is rejected
+// without stopping the others.
+//
+// Consumers given a key function, such as the product of a stock alert,
+// process the messages sharing a key one at a time in delivery order, so
+// processing messages in parallel never reorders the events of a single
+// aggregate. The key function decodes each body, and its handler is given
+// what it decoded rather than decoding the body again.
+package intake
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rabbitmq/amqp091-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"medisupply/clock"
+	"medisupply/queue"
+)
+
+var (
+	// ErrConsumerNotFound is returned for consumers that are not registered
+	ErrConsumerNotFound = errors.New("consumer not found")
+	// ErrInvalidConfig is returned for unusable intake settings
+	ErrInvalidConfig = errors.New("invalid intake config")
+)
+
+// Config represents the intake settings of a consumer
+type Config struct {
+	// Prefetch is the number of unacknowledged messages the broker delivers
+	// to the consumer
+	Prefetch int `json:"prefetch"`
+	// MaxInFlight is the number of messages processed at once; 1 processes
+	// them one by one in delivery order. Above 1, messages with the same key
+	// are still processed one by one in delivery order.
+	MaxInFlight int `json:"max_in_flight"`
+}
+
+// Validate checks that the intake settings are usable
+func (c Config) Validate() error {
+	if c.Prefetch < 1 {
+		return fmt.Errorf("prefetch must be at least 1")
+	}
+	if c.MaxInFlight < 1 {
+		return fmt.Errorf("max_in_flight must be at least 1")
+	}
+	if c.MaxInFlight > c.Prefetch {
+		// The broker never has more than the prefetch count outstanding
+		return fmt.Errorf("max_in_flight must not exceed prefetch")
+	}
+	return nil
+}
+
+// Stats represents a point-in-time view of a consumer
+type Stats struct {
+	Name        string `json:"name"`
+	Queue       string `json:"queue"`
+	Tag         string `json:"tag,omitempty"`
+	Prefetch    int    `json:"prefetch"`
+	MaxInFlight int    `json:"max_in_flight"`
+	InFlight    int    `json:"in_flight"`
+	// Waiting counts the messages taken in that wait for an earlier one
+	// with the same key
+	Waiting      int        `json:"waiting"`
+	Processed    int64      `json:"processed"`
+	Paused       bool       `json:"paused"`
+	PausedReason string     `json:"paused_reason,omitempty"`
+	PausedAt     *time.Time `json:"paused_at,omitempty"`
+	// Alive reports whether the consumer takes messages in or was paused
+	// on purpose; a consumer that lost its delivery channel is not alive
+	Alive    bool       `json:"alive"`
+	Failure  string     `json:"failure,omitempty"`
+	FailedAt *time.Time `json:"failed_at,omitempty"`
+	Restarts int        `json:"restarts"`
+	Panics   int64      `json:"panics"`
+}
+
+// Handler processes a delivery, acknowledging or rejecting it. decoded is
+// what the consumer's key function decoded from the delivery, nil without
+// one.
+type Handler func(msg amqp091.Delivery, decoded interface{})
+
+// KeyFunc decodes a delivery and returns the key of the aggregate it
+// changes, with what it decoded for the handler, so a body is decoded
+// once; deliveries without a key are processed in any order
+type KeyFunc func(msg amqp091.Delivery) (key string, decoded interface{})
+
+// Channel is the AMQP channel consumers are registered on, such as an
+// *amqp091.Channel
+type Channel interface {
+	Qos(prefetchCount, prefetchSize int, global bool) error
+	Consume(queue, consumer string, autoAck, exclusive, noLocal, noWait bool, args amqp091.Table) (<-chan amqp091.Delivery, error)
+	Cancel(consumer string, noWait bool) error
+	IsClosed() bool
+}
+
+// delivery is a message taken in with what the key function decoded
+type delivery struct {
+	msg     amqp091.Delivery
+	decoded interface{}
+}
+
+// Consumer delivers the messages of a queue to a handler
+type Consumer struct {
+	name     string
+	identity string
+	channel  Channel
+	queue    string
+	handle   Handler
+	key      KeyFunc
+	logger   *log.Logger
+	clock    clock.Clock
+
+	mu           sync.Mutex
+	idle         *sync.Cond
+	config       Config
+	tag          string
+	generation   int
+	started      bool
+	stopped      bool
+	paused       bool
+	pausedReason string
+	pausedAt     time.Time
+	inFlight     int
+	processed    int64
+	// lanes holds the messages waiting behind the one being processed for
+	// each key; a key is in it while a message of it is processed
+	lanes    map[string][]delivery
+	waiting  int
+	failure  string
+	failedAt time.Time
+	restarts int
+	panics   int64
+}
+
+// Start sets the channel's prefetch count and registers the consumer,
+// unless it was paused before starting
+func (c *Consumer) Start() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.started = true
+	if c.paused {
+		c.logger.Printf("Consumer %s starts paused: %s", c.name, c.pausedReason)
+		return nil
+	}
+	return c.consumeLocked()
+}
+
+// Pause stops taking messages in. Messages being processed finish; those
+// already delivered but not started are requeued, and the rest wait in the
+// queue until the consumer resumes.
+func (c *Consumer) Pause(reason string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.paused {
+		return nil
+	}
+	if err := c.cancelLocked(); err != nil {
+		return err
+	}
+	c.paused = true
+	c.pausedReason = reason
+	c.pausedAt = c.clock.Now()
+	c.idle.Broadcast()
+	c.logger.Printf("Consumer %s paused - queue: %s, reason: %s", c.name, c.queue, reason)
+	return nil
+}
+
+// Resume takes messages in again after a pause
+func (c *Consumer) Resume() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.paused {
+		return nil
+	}
+	if c.started && !c.stopped {
+		if err := c.consumeLocked(); err != nil {
+			return err
+		}
+	}
+	c.logger.Printf("Consumer %s resumed after %v - queue: %s", c.name, c.clock.Now().Sub(c.pausedAt).Round(time.Second), c.queue)
+	c.paused = false
+	c.pausedReason = ""
+	c.pausedAt = time.Time{}
+	return nil
+}
+
+// Configure changes the intake settings. The broker applies a prefetch
+// count to consumers registered after it is set, so a running consumer is
+// registered again for a new one.
+func (c *Consumer) Configure(config Config) error {
+	if err := config.Validate(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	previous := c.config
+	c.config = config
+	c.idle.Broadcast()
+	if config.Prefetch == previous.Prefetch || c.tag == "" {
+		return nil
+	}
+	if err := c.cancelLocked(); err != nil {
+		return err
+	}
+	return c.consumeLocked()
+}
+
+// Stop stops taking messages in and waits for the messages being processed
+func (c *Consumer) Stop() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.cancelLocked(); err != nil {
+		c.logger.Printf("Consumer %s not cancelled: %v", c.name, err)
+	}
+	c.stopped = true
+	c.idle.Broadcast()
+	for c.inFlight > 0 || c.waiting > 0 {
+		c.idle.Wait()
+	}
+}
+
+// Stats returns the current consumer state
+func (c *Consumer) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := Stats{
+		Name:         c.name,
+		Queue:        c.queue,
+		Tag:          c.tag,
+		Prefetch:     c.config.Prefetch,
+		MaxInFlight:  c.config.MaxInFlight,
+		InFlight:     c.inFlight,
+		Waiting:      c.waiting,
+		Processed:    c.processed,
+		Paused:       c.paused,
+		PausedReason: c.pausedReason,
+		Alive:        c.aliveLocked(),
+		Failure:      c.failure,
+		Restarts:     c.restarts,
+		Panics:       c.panics,
+	}
+	if c.paused {
+		pausedAt := c.pausedAt
+		stats.PausedAt = &pausedAt
+	}
+	if c.failure != "" {
+		failedAt := c.failedAt
+		stats.FailedAt = &failedAt
+	}
+	return stats
+}
+
+// aliveLocked reports whether the consumer is registered on an open
+// channel, or is not meant to be: before starting, while paused and once
+// stopped
+func (c *Consumer) aliveLocked() bool {
+	if !c.started || c.paused || c.stopped {
+		return true
+	}
+	return c.failure == "" && c.tag != "" && !c.channel.IsClosed()
+}
+
+// check marks the consumer down when its channel closed, and registers it
+// again when it is down but its channel is still open, as when the broker
+// cancelled it
+func (c *Consumer) check() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.aliveLocked() {
+		return
+	}
+	if c.failure == "" {
+		c.failLocked("channel closed")
+	}
+	if c.channel.IsClosed() {
+		return
+	}
+	if err := c.consumeLocked(); err != nil {
+		c.logger.Printf("Consumer %s not restarted: %v", c.name, err)
+		return
+	}
+	c.restarts++
+	c.logger.Printf("Consumer %s restarted after %v - queue: %s, failure: %s", c.name, c.clock.Now().Sub(c.failedAt).Round(time.Second), c.queue, c.failure)
+	c.failure = ""
+	c.failedAt = time.Time{}
+}
+
+// failLocked marks the consumer down
+func (c *Consumer) failLocked(reason string) {
+	c.tag = ""
+	c.failure = reason
+	c.failedAt = c.clock.Now()
+	c.logger.Printf("Consumer %s down - queue: %s, reason: %s", c.name, c.queue, reason)
+}
+
+// consumeLocked sets the prefetch count and registers a consumer on the
+// queue. Each registration gets its own tag so it can be cancelled alone.
+func (c *Consumer) consumeLocked() error {
+	if err := c.channel.Qos(c.config.Prefetch, 0, false); err != nil {
+		return fmt.Errorf("failed to set QoS: %w", err)
+	}
+
+	c.generation++
+	tag := queue.ConsumerTag(c.identity, fmt.Sprintf("%s-%d", c.name, c.generation))
+	deliveries, err := c.channel.Consume(
+		c.queue, // queue
+		tag,     // consumer
+		false,   // auto-ack
+		false,   // exclusive
+		false,   // no-local
+		false,   // no-wait
+		nil,     // args
+	)
+	if err != nil {
+		return fmt.Errorf("failed to register consumer: %w", err)
+	}
+	c.tag = tag
+	go c.run(tag, deliveries)
+	return nil
+}
+
+// cancelLocked cancels the registered consumer; the broker stops
+// delivering and the delivery channel closes once drained
+func (c *Consumer) cancelLocked() error {
+	if c.tag == "" {
+		return nil
+	}
+	if err := c.channel.Cancel(c.tag, false); err != nil {
+		return fmt.Errorf("failed to cancel consumer: %w", err)
+	}
+	c.tag = ""
+	return nil
+}
+
+// run hands deliveries to the handler as in-flight slots free up. The
+// deliveries close once the registration is cancelled; when that was not
+// asked for, the consumer is down.
+func (c *Consumer) run(tag string, deliveries <-chan amqp091.Delivery) {
+	defer func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.tag == tag {
+			c.failLocked("delivery channel closed")
+		}
+	}()
+
+	for msg := range deliveries {
+		var key string
+		d := delivery{msg: msg}
+		if c.key != nil {
+			key, d.decoded = c.key(msg)
+		}
+		if c.wait(key, d) {
+			continue
+		}
+		if !c.acquire() {
+			// Delivered before the pause took effect; another consumer or
+			// the resumed one takes it
+			msg.Nack(false, true)
+			continue
+		}
+		if key == "" {
+			go c.process(d)
+			continue
+		}
+		// Only this loop opens lanes, so none opened for the key meanwhile
+		c.mu.Lock()
+		c.lanes[key] = nil
+		c.mu.Unlock()
+		go c.work(key, d)
+	}
+}
+
+// wait queues a message behind the one of the same key being processed,
+// reporting whether it did
+func (c *Consumer) wait(key string, d delivery) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	waiting, ok := c.lanes[key]
+	if key == "" || !ok {
+		return false
+	}
+	c.lanes[key] = append(waiting, d)
+	c.waiting++
+	return true
+}
+
+// work processes a message and then those of its key that arrived
+// meanwhile, one at a time in delivery order, each in an in-flight slot
+func (c *Consumer) work(key string, d delivery) {
+	for {
+		c.process(d)
+
+		c.mu.Lock()
+		waiting := c.lanes[key]
+		if len(waiting) == 0 {
+			delete(c.lanes, key)
+			c.mu.Unlock()
+			return
+		}
+		d, c.lanes[key] = waiting[0], waiting[1:]
+		c.mu.Unlock()
+
+		acquired := c.acquire()
+		c.mu.Lock()
+		if acquired {
+			c.waiting--
+			c.mu.Unlock()
+			continue
+		}
+		// Paused or stopped; the key's messages go back to the queue in
+		// order for another consumer or the resumed one
+		requeued := append([]delivery{d}, c.lanes[key]...)
+		delete(c.lanes, key)
+		c.waiting -= len(requeued)
+		c.idle.Broadcast()
+		c.mu.Unlock()
+		for _, d := range requeued {
+			d.msg.Nack(false, true)
+		}
+		return
+	}
+}
+
+// process hands a message to the handler and frees its slot
+func (c *Consumer) process(d delivery) {
+	defer c.release()
+	defer c.recoverPanic(d.msg)
+	c.handle(d.msg, d.decoded)
+}
+
+// recoverPanic keeps a handler panic from stopping the service. Handlers
+// dead-letter the messages they panic on themselves; a panic that still
+// reaches the consumer rejects the message without requeueing it, so it is
+// not redelivered to panic again.
+func (c *Consumer) recoverPanic(msg amqp091.Delivery) {
+	recovered := recover()
+	if recovered == nil {
+		return
+	}
+	c.mu.Lock()
+	c.panics++
+	c.mu.Unlock()
+	c.logger.Printf("Consumer %s recovered from panic - message_id: %s, panic: %v\n%s", c.name, msg.MessageId, recovered, debug.Stack())
+	if err := msg.Nack(false, false); err != nil {
+		c.logger.Printf("Consumer %s message not rejected - message_id: %s, error: %v", c.name, msg.MessageId, err)
+	}
+}
+
+// acquire waits for an in-flight slot; it fails once the consumer is
+// paused or stopped
+func (c *Consumer) acquire() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for !c.paused && !c.stopped && c.inFlight >= c.config.MaxInFlight {
+		c.idle.Wait()
+	}
+	if c.paused || c.stopped {
+		return false
+	}
+	c.inFlight++
+	return true
+}
+
+// release frees the slot of a processed message
+func (c *Consumer) release() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.inFlight--
+	c.processed++
+	c.idle.Broadcast()
+}
+
+// Registry holds the consumers of the service by name
+type Registry struct {
+	// Identity names the replica in the consumer tags
+	Identity string
+	// Clock dates pauses and failures of the consumers registered after
+	// it is set
+	Clock clock.Clock
+
+	mu        sync.RWMutex
+	consumers map[string]*Consumer
+}
+
+// NewRegistry creates a new consumer registry and registers its metrics
+func NewRegistry(identity string) *Registry {
+	r := &Registry{
+		Identity:  identity,
+		Clock:     clock.System,
+		consumers: make(map[string]*Consumer),
+	}
+	registerMetrics(r)
+	return r
+}
+
+// Register creates a consumer of a queue; it takes messages in once started
+func (r *Registry) Register(name string, channel Channel, queue string, config Config, handle Handler, key KeyFunc, logger *log.Logger) (*Consumer, error) {
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("%w for %s: %v", ErrInvalidConfig, name, err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.consumers[name]; exists {
+		return nil, fmt.Errorf("consumer %s already registered", name)
+	}
+
+	c := &Consumer{
+		name:     name,
+		identity: r.Identity,
+		channel:  channel,
+		queue:    queue,
+		handle:   handle,
+		key:      key,
+		logger:   logger,
+		clock:    r.Clock,
+		config:   config,
+	}
+	c.idle = sync.NewCond(&c.mu)
+	c.lanes = make(map[string][]delivery)
+	r.consumers[name] = c
+	return c, nil
+}
+
+// Watch checks the consumers every interval until the context is cancelled,
+// restarting those that went down while their channel is still open
+func (r *Registry) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, c := range r.list() {
+				c.check()
+			}
+		}
+	}
+}
+
+// Down returns the names of the consumers that are not alive
+func (r *Registry) Down() []string {
+	var down []string
+	for _, s := range r.Stats() {
+		if !s.Alive {
+			down = append(down, s.Name)
+		}
+	}
+	return down
+}
+
+// Get returns the consumer with the given name
+func (r *Registry) Get(name string) (*Consumer, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	c, ok := r.consumers[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrConsumerNotFound, name)
+	}
+	return c, nil
+}
+
+// Stats returns the state of every registered consumer ordered by name
+func (r *Registry) Stats() []Stats {
+	consumers := r.list()
+	stats := make([]Stats, 0, len(consumers))
+	for _, c := range consumers {
+		stats = append(stats, c.Stats())
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Name < stats[j].Name })
+	return stats
+}
+
+// list returns the registered consumers
+func (r *Registry) list() []*Consumer {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	consumers := make([]*Consumer, 0, len(r.consumers))
+	for _, c := range r.consumers {
+		consumers = append(consumers, c)
+	}
+	return consumers
+}
+
+// registerMetrics reports the consumers' in-flight messages, pause state and
+// whether they are alive; failures leave metrics disabled
+func registerMetrics(registry *Registry) {
+	meter := otel.Meter("orden-compra/intake")
+
+	inFlight, err := meter.Int64ObservableGauge(
+		"rabbitmq_consumer_in_flight",
+		metric.WithDescription("Messages being processed per consumer"),
+	)
+	if err != nil {
+		return
+	}
+	paused, err := meter.Int64ObservableGauge(
+		"rabbitmq_consumer_paused",
+		metric.WithDescription("Whether a consumer is paused: 1 paused, 0 consuming"),
+	)
+	if err != nil {
+		return
+	}
+	alive, err := meter.Int64ObservableGauge(
+		"rabbitmq_consumer_alive",
+		metric.WithDescription("Whether a consumer takes messages in or is paused on purpose: 1 alive, 0 down"),
+	)
+	if err != nil {
+		return
+	}
+	_, _ = meter.RegisterCallback(func(ctx context.Context, o metric.Observer) error {
+		for _, s := range registry.Stats() {
+			attributes := metric.WithAttributes(attribute.String("consumer", s.Name))
+			o.ObserveInt64(inFlight, int64(s.InFlight), attributes)
+			var value int64
+			if s.Paused {
+				value = 1
+			}
+			o.ObserveInt64(paused, value, attributes)
+			value = 0
+			if s.Alive {
+				value = 1
+			}
+			o.ObserveInt64(alive, value, attributes)
+		}
+		return nil
+	}, inFlight, paused, alive)
+}
@@ -0,0 +1,569 @@
+package intake
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rabbitmq/amqp091-go"
+
+	"medisupply/clock"
+)
+
+// fakeChannel registers consumers on in-memory delivery channels and
+// records how deliveries are settled
+type fakeChannel struct {
+	mu        sync.Mutex
+	prefetch  int
+	tags      []string
+	consumers map[string]chan amqp091.Delivery
+	closed    bool
+	settled   []string
+	nextTag   uint64
+}
+
+func newFakeChannel() *fakeChannel {
+	return &fakeChannel{consumers: make(map[string]chan amqp091.Delivery)}
+}
+
+func (f *fakeChannel) Qos(prefetchCount, prefetchSize int, global bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.prefetch = prefetchCount
+	return nil
+}
+
+func (f *fakeChannel) Consume(queue, consumer string, autoAck, exclusive, noLocal, noWait bool, args amqp091.Table) (<-chan amqp091.Delivery, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	deliveries := make(chan amqp091.Delivery, 100)
+	f.tags = append(f.tags, consumer)
+	f.consumers[consumer] = deliveries
+	return deliveries, nil
+}
+
+func (f *fakeChannel) Cancel(consumer string, noWait bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if deliveries, ok := f.consumers[consumer]; ok {
+		close(deliveries)
+		delete(f.consumers, consumer)
+	}
+	return nil
+}
+
+func (f *fakeChannel) IsClosed() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.closed
+}
+
+// deliver hands bodies to the consumer registered last
+func (f *fakeChannel) deliver(t *testing.T, bodies ...string) {
+	t.Helper()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	deliveries, ok := f.consumers[f.tags[len(f.tags)-1]]
+	if !ok {
+		t.Fatal("no consumer registered")
+	}
+	for _, body := range bodies {
+		f.nextTag++
+		deliveries <- amqp091.Delivery{
+			Acknowledger: f,
+			DeliveryTag:  f.nextTag,
+			MessageId:    body,
+			Body:         []byte(body),
+		}
+	}
+}
+
+// brokerCancel closes the deliveries of the consumer registered last, as
+// the broker does when it cancels a consumer
+func (f *fakeChannel) brokerCancel() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	tag := f.tags[len(f.tags)-1]
+	close(f.consumers[tag])
+	delete(f.consumers, tag)
+}
+
+func (f *fakeChannel) registrations() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.tags)
+}
+
+// Ack, Nack and Reject record settlements by delivery tag
+func (f *fakeChannel) Ack(tag uint64, multiple bool) error {
+	return f.settle(fmt.Sprintf("ack %d", tag))
+}
+
+func (f *fakeChannel) Nack(tag uint64, multiple, requeue bool) error {
+	if requeue {
+		return f.settle(fmt.Sprintf("requeue %d", tag))
+	}
+	return f.settle(fmt.Sprintf("reject %d", tag))
+}
+
+func (f *fakeChannel) Reject(tag uint64, requeue bool) error {
+	return f.Nack(tag, false, requeue)
+}
+
+func (f *fakeChannel) settle(settlement string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.settled = append(f.settled, settlement)
+	return nil
+}
+
+func (f *fakeChannel) settlements() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.settled...)
+}
+
+// bodyKey keys bodies such as "a-1" by the part before the dash, and hands
+// on the body as what it decoded
+func bodyKey(msg amqp091.Delivery) (string, interface{}) {
+	key, _, _ := strings.Cut(string(msg.Body), "-")
+	return key, string(msg.Body)
+}
+
+var discard = log.New(io.Discard, "", 0)
+
+func newConsumer(t *testing.T, channel *fakeChannel, config Config, handle Handler, key KeyFunc) *Consumer {
+	t.Helper()
+	c, err := NewRegistry("test").Register("stock-low", channel, "stock-low", config, handle, key, discard)
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	if err := c.Start(); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	t.Cleanup(c.Stop)
+	return c
+}
+
+// eventually waits for condition to hold
+func eventually(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestKeyedMessagesAreDecodedOnceAndProcessedInDeliveryOrder(t *testing.T) {
+	channel := newFakeChannel()
+	var keyCalls atomic.Int32
+	var mu sync.Mutex
+	processed := map[string][]string{}
+	c := newConsumer(t, channel, Config{Prefetch: 50, MaxInFlight: 4}, func(msg amqp091.Delivery, decoded interface{}) {
+		if decoded != string(msg.Body) {
+			t.Errorf("handler given %v for %s", decoded, msg.Body)
+		}
+		key, _ := bodyKey(msg)
+		mu.Lock()
+		processed[key] = append(processed[key], string(msg.Body))
+		mu.Unlock()
+		msg.Ack(false)
+	}, func(msg amqp091.Delivery) (string, interface{}) {
+		keyCalls.Add(1)
+		return bodyKey(msg)
+	})
+
+	var bodies []string
+	for n := 0; n < 20; n++ {
+		for _, key := range []string{"a", "b", "c"} {
+			bodies = append(bodies, fmt.Sprintf("%s-%02d", key, n))
+		}
+	}
+	channel.deliver(t, bodies...)
+	eventually(t, "every message processed", func() bool { return c.Stats().Processed == 60 })
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, key := range []string{"a", "b", "c"} {
+		for n, body := range processed[key] {
+			if want := fmt.Sprintf("%s-%02d", key, n); body != want {
+				t.Fatalf("key %s processed %v, want delivery order", key, processed[key])
+			}
+		}
+	}
+	if calls := keyCalls.Load(); calls != 60 {
+		t.Fatalf("key function called %d times for 60 messages", calls)
+	}
+}
+
+func TestPauseRequeuesTheWaitingMessagesOfAKeyInOrder(t *testing.T) {
+	channel := newFakeChannel()
+	release := make(chan struct{})
+	var mu sync.Mutex
+	var processed []string
+	c := newConsumer(t, channel, Config{Prefetch: 10, MaxInFlight: 2}, func(msg amqp091.Delivery, decoded interface{}) {
+		if decoded == "a-1" {
+			<-release
+		}
+		mu.Lock()
+		processed = append(processed, decoded.(string))
+		mu.Unlock()
+		msg.Ack(false)
+	}, bodyKey)
+
+	channel.deliver(t, "a-1", "a-2", "a-3")
+	eventually(t, "a-2 and a-3 waiting behind a-1", func() bool { return c.Stats().Waiting == 2 })
+
+	// Pausing lets a-1 finish and puts a-2 and a-3 back in the queue in order
+	if err := c.Pause("DynamoDB throttling"); err != nil {
+		t.Fatalf("pause: %v", err)
+	}
+	close(release)
+	eventually(t, "the key's messages settled", func() bool { return len(channel.settlements()) == 3 })
+	if settled := strings.Join(channel.settlements(), ", "); settled != "ack 1, requeue 2, requeue 3" {
+		t.Fatalf("settled %s", settled)
+	}
+	if stats := c.Stats(); !stats.Paused || stats.Waiting != 0 || stats.InFlight != 0 || stats.Tag != "" {
+		t.Fatalf("paused consumer stats %+v", stats)
+	}
+
+	// The resumed consumer takes the redelivered messages in order
+	if err := c.Resume(); err != nil {
+		t.Fatalf("resume: %v", err)
+	}
+	if registrations := channel.registrations(); registrations != 2 {
+		t.Fatalf("consumer registered %d times, want again on resume", registrations)
+	}
+	channel.deliver(t, "a-2", "a-3")
+	eventually(t, "the redelivered messages processed", func() bool { return c.Stats().Processed == 3 })
+
+	mu.Lock()
+	defer mu.Unlock()
+	if got := strings.Join(processed, ", "); got != "a-1, a-2, a-3" {
+		t.Fatalf("processed %s", got)
+	}
+}
+
+func TestWatchdogRegistersAConsumerCancelledByTheBrokerAgain(t *testing.T) {
+	channel := newFakeChannel()
+	registry := NewRegistry("test")
+	var processed atomic.Int32
+	c, err := registry.Register("stock-low", channel, "stock-low", Config{Prefetch: 10, MaxInFlight: 2}, func(msg amqp091.Delivery, decoded interface{}) {
+		processed.Add(1)
+		msg.Ack(false)
+	}, bodyKey, discard)
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	if err := c.Start(); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	defer c.Stop()
+
+	channel.deliver(t, "a-1")
+	eventually(t, "a-1 processed", func() bool { return processed.Load() == 1 })
+
+	// The broker cancels the consumer, as when its queue is deleted and
+	// declared again
+	channel.brokerCancel()
+	eventually(t, "the consumer reported down", func() bool { return !c.Stats().Alive })
+	if down := registry.Down(); len(down) != 1 || down[0] != "stock-low" {
+		t.Fatalf("down consumers %v", down)
+	}
+	if stats := c.Stats(); stats.Failure != "delivery channel closed" || stats.FailedAt == nil {
+		t.Fatalf("down consumer stats %+v", stats)
+	}
+
+	// The channel is still open, so the watchdog registers it again and it
+	// takes the redelivered messages in
+	c.check()
+	if stats := c.Stats(); !stats.Alive || stats.Restarts != 1 || stats.Failure != "" || channel.registrations() != 2 {
+		t.Fatalf("restarted consumer stats %+v after %d registrations", stats, channel.registrations())
+	}
+	// Each registration is tagged after the replica, with a new generation
+	channel.mu.Lock()
+	tags := strings.Join(channel.tags, ", ")
+	channel.mu.Unlock()
+	if tags != "test/stock-low-1, test/stock-low-2" || c.Stats().Tag != "test/stock-low-2" {
+		t.Fatalf("registered as %s, reporting tag %q", tags, c.Stats().Tag)
+	}
+	channel.deliver(t, "a-2", "b-1")
+	eventually(t, "the redelivered messages processed", func() bool { return processed.Load() == 3 })
+	if down := registry.Down(); len(down) != 0 {
+		t.Fatalf("down consumers %v after the restart", down)
+	}
+
+	// A closed channel cannot be consumed from; the consumer stays down
+	// for the connection to be replaced
+	channel.brokerCancel()
+	channel.mu.Lock()
+	channel.closed = true
+	channel.mu.Unlock()
+	eventually(t, "the consumer reported down", func() bool { return !c.Stats().Alive })
+	c.check()
+	if stats := c.Stats(); stats.Alive || stats.Restarts != 1 || channel.registrations() != 2 {
+		t.Fatalf("consumer on a closed channel %+v after %d registrations", stats, channel.registrations())
+	}
+}
+
+func TestDifferentKeysAreProcessedInParallelUpToMaxInFlight(t *testing.T) {
+	channel := newFakeChannel()
+	release := make(chan struct{})
+	var active, most atomic.Int32
+	c := newConsumer(t, channel, Config{Prefetch: 10, MaxInFlight: 3}, func(msg amqp091.Delivery, decoded interface{}) {
+		now := active.Add(1)
+		for {
+			seen := most.Load()
+			if now <= seen || most.CompareAndSwap(seen, now) {
+				break
+			}
+		}
+		<-release
+		active.Add(-1)
+		msg.Ack(false)
+	}, bodyKey)
+
+	channel.deliver(t, "a-1", "b-1", "c-1", "d-1", "e-1")
+	eventually(t, "three messages in flight", func() bool { return active.Load() == 3 })
+
+	// The other two keys wait for a slot, not for a key
+	time.Sleep(20 * time.Millisecond)
+	if stats := c.Stats(); stats.InFlight != 3 || stats.Waiting != 0 || most.Load() != 3 {
+		t.Fatalf("stats %+v with %d handlers running at most", stats, most.Load())
+	}
+	close(release)
+	eventually(t, "every message processed", func() bool { return c.Stats().Processed == 5 })
+	if most.Load() != 3 {
+		t.Fatalf("%d handlers ran at once, want max_in_flight 3", most.Load())
+	}
+}
+
+func TestSameKeyMessagesWaitForTheOneBeingProcessed(t *testing.T) {
+	channel := newFakeChannel()
+	release := make(chan struct{})
+	var mu sync.Mutex
+	var started []string
+	c := newConsumer(t, channel, Config{Prefetch: 10, MaxInFlight: 3}, func(msg amqp091.Delivery, decoded interface{}) {
+		mu.Lock()
+		started = append(started, decoded.(string))
+		mu.Unlock()
+		if decoded == "a-1" {
+			<-release
+		}
+		msg.Ack(false)
+	}, bodyKey)
+
+	channel.deliver(t, "a-1", "a-2", "b-1", "a-3")
+	eventually(t, "b-1 processed alongside a-1", func() bool { return c.Stats().Processed == 1 })
+
+	// Free slots do not start a-2 or a-3 while a-1 is processed
+	if stats := c.Stats(); stats.InFlight != 1 || stats.Waiting != 2 {
+		t.Fatalf("stats %+v, want a-1 in flight and two messages waiting", stats)
+	}
+	close(release)
+	eventually(t, "every message processed", func() bool { return c.Stats().Processed == 4 })
+
+	mu.Lock()
+	defer mu.Unlock()
+	var a []string
+	for _, body := range started {
+		if strings.HasPrefix(body, "a-") {
+			a = append(a, body)
+		}
+	}
+	if got := strings.Join(a, ", "); got != "a-1, a-2, a-3" {
+		t.Fatalf("key a started %s", got)
+	}
+}
+
+func TestConfigure(t *testing.T) {
+	channel := newFakeChannel()
+	c := newConsumer(t, channel, Config{Prefetch: 10, MaxInFlight: 2}, func(msg amqp091.Delivery, decoded interface{}) {
+		msg.Ack(false)
+	}, nil)
+
+	// The in-flight limit applies at once; the broker applies a prefetch
+	// count to new registrations only
+	if err := c.Configure(Config{Prefetch: 10, MaxInFlight: 5}); err != nil {
+		t.Fatalf("configure: %v", err)
+	}
+	if channel.registrations() != 1 {
+		t.Fatalf("registered %d times for a new max_in_flight", channel.registrations())
+	}
+	if err := c.Configure(Config{Prefetch: 20, MaxInFlight: 5}); err != nil {
+		t.Fatalf("configure: %v", err)
+	}
+	channel.mu.Lock()
+	prefetch := channel.prefetch
+	channel.mu.Unlock()
+	if channel.registrations() != 2 || prefetch != 20 {
+		t.Fatalf("registered %d times with prefetch %d, want again with 20", channel.registrations(), prefetch)
+	}
+	if stats := c.Stats(); stats.Prefetch != 20 || stats.MaxInFlight != 5 {
+		t.Fatalf("stats %+v", stats)
+	}
+
+	for _, config := range []Config{{Prefetch: 0, MaxInFlight: 1}, {Prefetch: 5, MaxInFlight: 0}, {Prefetch: 5, MaxInFlight: 6}} {
+		if err := c.Configure(config); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("Configure(%+v) returned %v, want ErrInvalidConfig", config, err)
+		}
+	}
+
+	// Messages still flow through the new registration
+	channel.deliver(t, "a-1", "b-1")
+	eventually(t, "messages processed", func() bool { return c.Stats().Processed == 2 })
+}
+
+// newWatchedConsumer registers a consumer on a registry running on a fake
+// clock
+func newWatchedConsumer(t *testing.T, channel *fakeChannel, fake *clock.Fake, config Config, handle Handler) (*Registry, *Consumer) {
+	t.Helper()
+	registry := NewRegistry("test")
+	registry.Clock = fake
+	c, err := registry.Register("stock-low", channel, "stock-low", config, handle, bodyKey, discard)
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	if err := c.Start(); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	t.Cleanup(c.Stop)
+	return registry, c
+}
+
+func TestWatchdogRestartRacesALateAck(t *testing.T) {
+	channel := newFakeChannel()
+	fake := clock.NewFake(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+	release := make(chan struct{})
+	var active, most atomic.Int32
+	registry, c := newWatchedConsumer(t, channel, fake, Config{Prefetch: 10, MaxInFlight: 1}, func(msg amqp091.Delivery, decoded interface{}) {
+		if now := active.Add(1); now > most.Load() {
+			most.Store(now)
+		}
+		if decoded == "a-1" {
+			<-release
+		}
+		active.Add(-1)
+		msg.Ack(false)
+	})
+
+	channel.deliver(t, "a-1")
+	eventually(t, "a-1 in flight", func() bool { return c.Stats().InFlight == 1 })
+
+	// The broker cancels the consumer while a-1 is still being processed
+	channel.brokerCancel()
+	eventually(t, "the consumer reported down", func() bool { return !c.Stats().Alive })
+	if stats := c.Stats(); stats.FailedAt == nil || !stats.FailedAt.Equal(fake.Now()) {
+		t.Fatalf("failed at %v, want %v", stats.FailedAt, fake.Now())
+	}
+
+	// The watchdog registers the consumer again before a-1 is acknowledged;
+	// the new registration's messages wait for a-1's slot and, for key a,
+	// for a-1 itself
+	fake.Advance(15 * time.Second)
+	c.check()
+	if stats := c.Stats(); !stats.Alive || stats.Restarts != 1 || stats.InFlight != 1 {
+		t.Fatalf("restarted consumer stats %+v", stats)
+	}
+	channel.deliver(t, "a-2", "b-1")
+	eventually(t, "a-2 waiting behind a-1", func() bool { return c.Stats().Waiting == 1 })
+	if settled := channel.settlements(); len(settled) != 0 {
+		t.Fatalf("settled %v before a-1 was acknowledged", settled)
+	}
+
+	// The late ack of a-1 frees the slot; a second watchdog pass finds
+	// nothing to do
+	close(release)
+	c.check()
+	eventually(t, "every message settled", func() bool { return len(channel.settlements()) == 3 })
+	if settled := channel.settlements(); settled[0] != "ack 1" {
+		t.Fatalf("settled %v, want a-1 first", settled)
+	}
+	if most.Load() != 1 {
+		t.Fatalf("%d handlers ran at once across the restart, want max_in_flight 1", most.Load())
+	}
+	if stats := c.Stats(); stats.Restarts != 1 || stats.InFlight != 0 || stats.Processed != 3 || len(registry.Down()) != 0 {
+		t.Fatalf("stats %+v", stats)
+	}
+}
+
+func TestPauseWithABlockedHandler(t *testing.T) {
+	channel := newFakeChannel()
+	pausedAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	fake := clock.NewFake(pausedAt)
+	release := make(chan struct{})
+	_, c := newWatchedConsumer(t, channel, fake, Config{Prefetch: 10, MaxInFlight: 1}, func(msg amqp091.Delivery, decoded interface{}) {
+		if decoded == "a-1" {
+			<-release
+		}
+		msg.Ack(false)
+	})
+
+	channel.deliver(t, "a-1", "b-1")
+	eventually(t, "a-1 in flight", func() bool { return c.Stats().InFlight == 1 })
+
+	if err := c.Pause("DynamoDB throttling"); err != nil {
+		t.Fatalf("pause: %v", err)
+	}
+	// b-1 was delivered before the pause and waited for a slot, so it goes
+	// back to the queue; a-1 is still being processed
+	eventually(t, "b-1 requeued", func() bool { return len(channel.settlements()) == 1 })
+	if settled := channel.settlements(); settled[0] != "requeue 2" {
+		t.Fatalf("settled %v, want b-1 requeued", settled)
+	}
+	stats := c.Stats()
+	if !stats.Paused || !stats.Alive || stats.InFlight != 1 || stats.PausedReason != "DynamoDB throttling" || !stats.PausedAt.Equal(pausedAt) {
+		t.Fatalf("paused consumer stats %+v", stats)
+	}
+
+	// The watchdog leaves a paused consumer alone
+	fake.Advance(time.Minute)
+	c.check()
+	if channel.registrations() != 1 {
+		t.Fatalf("watchdog registered a paused consumer")
+	}
+
+	// The ack of a-1 lands while paused
+	close(release)
+	eventually(t, "a-1 acknowledged", func() bool { return len(channel.settlements()) == 2 })
+	if stats := c.Stats(); stats.InFlight != 0 || stats.Processed != 1 {
+		t.Fatalf("stats %+v after the late ack", stats)
+	}
+
+	if err := c.Resume(); err != nil {
+		t.Fatalf("resume: %v", err)
+	}
+	channel.deliver(t, "b-1")
+	eventually(t, "b-1 processed after resuming", func() bool { return c.Stats().Processed == 2 })
+	if stats := c.Stats(); stats.Paused || stats.PausedAt != nil || channel.registrations() != 2 {
+		t.Fatalf("resumed consumer stats %+v", stats)
+	}
+}
+
+func TestHandlerPanicRejectsTheMessageAndTheConsumerGoesOn(t *testing.T) {
+	channel := newFakeChannel()
+	c := newConsumer(t, channel, Config{Prefetch: 10, MaxInFlight: 1}, func(msg amqp091.Delivery, decoded interface{}) {
+		if decoded == "a-1" {
+			panic("nil map")
+		}
+		msg.Ack(false)
+	}, bodyKey)
+
+	channel.deliver(t, "a-1", "a-2")
+	eventually(t, "both messages settled", func() bool { return len(channel.settlements()) == 2 })
+	if settled := strings.Join(channel.settlements(), ", "); settled != "reject 1, ack 2" {
+		t.Fatalf("settled %s", settled)
+	}
+	if stats := c.Stats(); stats.Panics != 1 || stats.Processed != 2 || !stats.Alive {
+		t.Fatalf("stats %+v", stats)
+	}
+}
@@ -0,0 +1,215 @@
+package leader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+
+	"medisupply/clock"
+)
+
+// LeaseTable is the DynamoDB table holding leader leases
+const LeaseTable = "orden-compra-leases"
+
+// ErrLeaseHeld is returned when another instance holds an unexpired lease
+var ErrLeaseHeld = errors.New("lease held by another instance")
+
+// Config configures a leader election
+type Config struct {
+	// LeaseDuration is how long a lease stays valid without renewal
+	LeaseDuration time.Duration
+	// RenewInterval is how often the leader renews and followers retry; it must
+	// be well below LeaseDuration so a healthy leader never loses its lease
+	RenewInterval time.Duration
+}
+
+// Validate checks that the configuration is usable
+func (c Config) Validate() error {
+	if c.LeaseDuration <= 0 {
+		return fmt.Errorf("lease duration must be positive")
+	}
+	if c.RenewInterval <= 0 || c.RenewInterval >= c.LeaseDuration {
+		return fmt.Errorf("renew interval must be positive and shorter than the lease duration")
+	}
+	return nil
+}
+
+// Elector elects a single instance to run a background job using a DynamoDB
+// lease lock. When the leader stops renewing, another instance takes over
+// once the lease expires.
+type Elector struct {
+	Name     string
+	Identity string
+	Config   Config
+	DynamoDB dynamodbiface.DynamoDBAPI
+	Clock    clock.Clock
+	Logger   *log.Logger
+
+	mu       sync.RWMutex
+	isLeader bool
+}
+
+// NewElector creates a new elector for the named lease
+func NewElector(name, identity string, config Config, dynamoDB dynamodbiface.DynamoDBAPI, logger *log.Logger) (*Elector, error) {
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid leader election config for %s: %w", name, err)
+	}
+
+	return &Elector{
+		Name:     name,
+		Identity: identity,
+		Config:   config,
+		DynamoDB: dynamoDB,
+		Clock:    clock.System,
+		Logger:   logger,
+	}, nil
+}
+
+// IsLeader reports whether this instance currently holds the lease
+func (e *Elector) IsLeader() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.isLeader
+}
+
+// Run campaigns for the lease until the context is cancelled. While this
+// instance is leader, job runs with a context that is cancelled as soon as the
+// lease is lost.
+func (e *Elector) Run(ctx context.Context, job func(ctx context.Context)) {
+	e.Logger.Printf("Starting leader election - lease: %s, identity: %s", e.Name, e.Identity)
+
+	ticker := time.NewTicker(e.Config.RenewInterval)
+	defer ticker.Stop()
+
+	var cancelJob context.CancelFunc
+	var jobDone chan struct{}
+
+	stopJob := func() {
+		if cancelJob != nil {
+			cancelJob()
+			<-jobDone
+			cancelJob = nil
+		}
+		e.setLeader(false)
+	}
+	defer func() {
+		stopJob()
+		e.release()
+	}()
+
+	for {
+		err := e.acquire(ctx)
+		switch {
+		case err == nil:
+			if cancelJob == nil {
+				e.Logger.Printf("Acquired leadership - lease: %s, identity: %s", e.Name, e.Identity)
+				e.setLeader(true)
+
+				cancelJob, jobDone = startJob(ctx, job)
+			}
+		case errors.Is(err, ErrLeaseHeld):
+			if cancelJob != nil {
+				e.Logger.Printf("Lost leadership - lease: %s, identity: %s", e.Name, e.Identity)
+				stopJob()
+			}
+		default:
+			// Without a successful renewal we cannot prove we still hold the lease
+			e.Logger.Printf("Failed to renew lease %s: %v", e.Name, err)
+			if cancelJob != nil {
+				stopJob()
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// startJob runs job in the background with its own cancellable context
+func startJob(ctx context.Context, job func(ctx context.Context)) (context.CancelFunc, chan struct{}) {
+	jobCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		job(jobCtx)
+	}()
+	return cancel, done
+}
+
+// acquire takes or renews the lease if it is free, expired or already ours
+func (e *Elector) acquire(ctx context.Context) error {
+	now := e.Clock.Now().UTC()
+	expiresAt := now.Add(e.Config.LeaseDuration)
+
+	_, err := e.DynamoDB.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(LeaseTable),
+		Item: map[string]*dynamodb.AttributeValue{
+			"name":       {S: aws.String(e.Name)},
+			"owner":      {S: aws.String(e.Identity)},
+			"expires_at": {N: aws.String(strconv.FormatInt(expiresAt.UnixMilli(), 10))},
+			"renewed_at": {S: aws.String(now.Format(time.RFC3339))},
+		},
+		ConditionExpression: aws.String("attribute_not_exists(#name) OR #owner = :owner OR expires_at < :now"),
+		ExpressionAttributeNames: map[string]*string{
+			"#name":  aws.String("name"),
+			"#owner": aws.String("owner"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":owner": {S: aws.String(e.Identity)},
+			":now":   {N: aws.String(strconv.FormatInt(now.UnixMilli(), 10))},
+		},
+	})
+
+	if err != nil {
+		var awsErr awserr.Error
+		if errors.As(err, &awsErr) && awsErr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return ErrLeaseHeld
+		}
+		return fmt.Errorf("failed to put lease: %w", err)
+	}
+
+	return nil
+}
+
+// release gives up the lease so another instance can take over immediately
+func (e *Elector) release() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := e.DynamoDB.DeleteItemWithContext(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(LeaseTable),
+		Key: map[string]*dynamodb.AttributeValue{
+			"name": {S: aws.String(e.Name)},
+		},
+		ConditionExpression:       aws.String("#owner = :owner"),
+		ExpressionAttributeNames:  map[string]*string{"#owner": aws.String("owner")},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":owner": {S: aws.String(e.Identity)}},
+	})
+
+	if err != nil {
+		var awsErr awserr.Error
+		if errors.As(err, &awsErr) && awsErr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return
+		}
+		e.Logger.Printf("Failed to release lease %s: %v", e.Name, err)
+	}
+}
+
+// setLeader records the current leadership state
+func (e *Elector) setLeader(isLeader bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.isLeader = isLeader
+}
@@ -0,0 +1,244 @@
+package leader
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+
+	"medisupply/clock"
+	"orden-compra/internal/memory"
+)
+
+var config = Config{LeaseDuration: 30 * time.Second, RenewInterval: 10 * time.Millisecond}
+
+func newElector(t *testing.T, identity string, dynamoDB dynamodbiface.DynamoDBAPI, fake *clock.Fake) *Elector {
+	t.Helper()
+	e, err := NewElector("consolidation-scheduler", identity, config, dynamoDB, log.New(io.Discard, "", 0))
+	if err != nil {
+		t.Fatalf("new elector: %v", err)
+	}
+	e.Clock = fake
+	return e
+}
+
+// owner returns the holder of the lease, or "" when nobody holds it
+func owner(t *testing.T, dynamoDB *memory.DynamoDB) string {
+	t.Helper()
+	for _, lease := range dynamoDB.Items(LeaseTable) {
+		return aws.StringValue(lease["owner"].S)
+	}
+	return ""
+}
+
+// eventually polls cond until it holds or a few seconds pass
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestOneOfTwoContendersHoldsTheLease(t *testing.T) {
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	fake := clock.NewFake(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+	a := newElector(t, "instance-a", dynamoDB, fake)
+	b := newElector(t, "instance-b", dynamoDB, fake)
+	ctx := context.Background()
+
+	if err := a.acquire(ctx); err != nil {
+		t.Fatalf("a acquire: %v", err)
+	}
+	if err := b.acquire(ctx); !errors.Is(err, ErrLeaseHeld) {
+		t.Fatalf("b acquire returned %v, want ErrLeaseHeld", err)
+	}
+
+	// The holder renews its own lease well before it expires
+	fake.Advance(config.LeaseDuration - time.Second)
+	if err := a.acquire(ctx); err != nil {
+		t.Fatalf("a renew: %v", err)
+	}
+	fake.Advance(config.LeaseDuration - time.Second)
+	if err := b.acquire(ctx); !errors.Is(err, ErrLeaseHeld) {
+		t.Fatalf("b acquire of a renewed lease returned %v, want ErrLeaseHeld", err)
+	}
+	if got := owner(t, dynamoDB); got != "instance-a" {
+		t.Fatalf("lease held by %q, want instance-a", got)
+	}
+
+	// Releasing frees the lease at once, and only for its holder
+	b.release()
+	if got := owner(t, dynamoDB); got != "instance-a" {
+		t.Fatalf("lease held by %q after b released, want instance-a", got)
+	}
+	a.release()
+	if err := b.acquire(ctx); err != nil {
+		t.Fatalf("b acquire of a released lease: %v", err)
+	}
+}
+
+func TestAnExpiredLeaseIsTakenOver(t *testing.T) {
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	fake := clock.NewFake(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+	a := newElector(t, "instance-a", dynamoDB, fake)
+	b := newElector(t, "instance-b", dynamoDB, fake)
+	ctx := context.Background()
+
+	a.acquire(ctx)
+	fake.Advance(config.LeaseDuration)
+	if err := b.acquire(ctx); !errors.Is(err, ErrLeaseHeld) {
+		t.Fatalf("b acquire at the expiry returned %v, want ErrLeaseHeld", err)
+	}
+	fake.Advance(time.Millisecond)
+	if err := b.acquire(ctx); err != nil {
+		t.Fatalf("b acquire of an expired lease: %v", err)
+	}
+
+	// The old holder finds the lease gone when it next renews
+	if err := a.acquire(ctx); !errors.Is(err, ErrLeaseHeld) {
+		t.Fatalf("a renew after the takeover returned %v, want ErrLeaseHeld", err)
+	}
+	if got := owner(t, dynamoDB); got != "instance-b" {
+		t.Fatalf("lease held by %q, want instance-b", got)
+	}
+}
+
+func TestRunHandsTheJobOverWhenTheLeaderStops(t *testing.T) {
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	fake := clock.NewFake(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+
+	var running atomic.Int32
+	job := func(ctx context.Context) {
+		if running.Add(1) > 1 {
+			t.Error("two instances ran the job at once")
+		}
+		<-ctx.Done()
+		running.Add(-1)
+	}
+
+	electors := []*Elector{newElector(t, "instance-a", dynamoDB, fake), newElector(t, "instance-b", dynamoDB, fake)}
+	cancels := make([]context.CancelFunc, len(electors))
+	done := make([]chan struct{}, len(electors))
+	for i, e := range electors {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancels[i], done[i] = cancel, make(chan struct{})
+		go func(e *Elector, done chan struct{}) {
+			defer close(done)
+			e.Run(ctx, job)
+		}(e, done[i])
+	}
+	defer func() {
+		for i := range electors {
+			cancels[i]()
+			<-done[i]
+		}
+	}()
+
+	eventually(t, "a leader", func() bool { return running.Load() == 1 })
+	leader, follower := 0, 1
+	if electors[1].IsLeader() {
+		leader, follower = 1, 0
+	}
+	// Let the follower retry a few times while the leader renews
+	time.Sleep(5 * config.RenewInterval)
+	if !electors[leader].IsLeader() || electors[follower].IsLeader() || owner(t, dynamoDB) != electors[leader].Identity {
+		t.Fatalf("leadership moved while the leader was renewing")
+	}
+
+	// Stopping the leader cancels its job and releases the lease, so the
+	// follower takes over without waiting for the lease to expire
+	cancels[leader]()
+	<-done[leader]
+	if electors[leader].IsLeader() {
+		t.Fatal("a stopped elector still reports leadership")
+	}
+	eventually(t, "the follower to take over", func() bool {
+		return electors[follower].IsLeader() && running.Load() == 1
+	})
+	if got := owner(t, dynamoDB); got != electors[follower].Identity {
+		t.Fatalf("lease held by %q, want %s", got, electors[follower].Identity)
+	}
+}
+
+// failingDynamoDB fails every lease write while failing is set
+type failingDynamoDB struct {
+	*memory.DynamoDB
+	failing atomic.Bool
+}
+
+func (d *failingDynamoDB) PutItemWithContext(ctx aws.Context, input *dynamodb.PutItemInput, opts ...request.Option) (*dynamodb.PutItemOutput, error) {
+	if d.failing.Load() {
+		return nil, errors.New("service unavailable")
+	}
+	return d.DynamoDB.PutItemWithContext(ctx, input, opts...)
+}
+
+func TestRunStopsTheJobWhenTheLeaseCannotBeRenewed(t *testing.T) {
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	unreliable := &failingDynamoDB{DynamoDB: dynamoDB}
+	fake := clock.NewFake(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+	a := newElector(t, "instance-a", unreliable, fake)
+
+	var stopped atomic.Bool
+	started := make(chan struct{}, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		a.Run(ctx, func(ctx context.Context) {
+			started <- struct{}{}
+			<-ctx.Done()
+			stopped.Store(true)
+		})
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+	<-started
+
+	// Without a renewal the leader cannot prove it holds the lease
+	unreliable.failing.Store(true)
+	eventually(t, "the job to stop", func() bool { return stopped.Load() && !a.IsLeader() })
+
+	// Once the lease expires another instance takes it, and the old leader
+	// stays a follower after its writes succeed again
+	fake.Advance(config.LeaseDuration + time.Millisecond)
+	b := newElector(t, "instance-b", dynamoDB, fake)
+	if err := b.acquire(context.Background()); err != nil {
+		t.Fatalf("b acquire of the expired lease: %v", err)
+	}
+	unreliable.failing.Store(false)
+	time.Sleep(5 * config.RenewInterval)
+	if a.IsLeader() || owner(t, dynamoDB) != "instance-b" {
+		t.Fatalf("instance-a took the lease back from instance-b")
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	for _, tc := range []struct {
+		config Config
+		valid  bool
+	}{
+		{Config{LeaseDuration: 30 * time.Second, RenewInterval: 10 * time.Second}, true},
+		{Config{LeaseDuration: 0, RenewInterval: 10 * time.Second}, false},
+		{Config{LeaseDuration: 30 * time.Second, RenewInterval: 0}, false},
+		{Config{LeaseDuration: 30 * time.Second, RenewInterval: 30 * time.Second}, false},
+	} {
+		if err := tc.config.Validate(); (err == nil) != tc.valid {
+			t.Errorf("Validate(%+v) returned %v", tc.config, err)
+		}
+	}
+}
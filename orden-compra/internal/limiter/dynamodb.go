@@ -0,0 +1,113 @@
+package limiter
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// LimitedDynamoDB wraps a DynamoDB client so every data-plane call holds a
+// slot of the shared DynamoDB limiter for its full duration, retries included
+type LimitedDynamoDB struct {
+	dynamodbiface.DynamoDBAPI
+	Limiter *Limiter
+}
+
+// NewLimitedDynamoDB creates a new LimitedDynamoDB
+func NewLimitedDynamoDB(client dynamodbiface.DynamoDBAPI, limiter *Limiter) *LimitedDynamoDB {
+	return &LimitedDynamoDB{
+		DynamoDBAPI: client,
+		Limiter:     limiter,
+	}
+}
+
+// GetItemWithContext gets an item while holding a limiter slot
+func (d *LimitedDynamoDB) GetItemWithContext(ctx aws.Context, input *dynamodb.GetItemInput, opts ...request.Option) (*dynamodb.GetItemOutput, error) {
+	if err := d.Limiter.Acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer d.Limiter.Release()
+
+	return d.DynamoDBAPI.GetItemWithContext(ctx, input, opts...)
+}
+
+// PutItemWithContext puts an item while holding a limiter slot
+func (d *LimitedDynamoDB) PutItemWithContext(ctx aws.Context, input *dynamodb.PutItemInput, opts ...request.Option) (*dynamodb.PutItemOutput, error) {
+	if err := d.Limiter.Acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer d.Limiter.Release()
+
+	return d.DynamoDBAPI.PutItemWithContext(ctx, input, opts...)
+}
+
+// UpdateItemWithContext updates an item while holding a limiter slot
+func (d *LimitedDynamoDB) UpdateItemWithContext(ctx aws.Context, input *dynamodb.UpdateItemInput, opts ...request.Option) (*dynamodb.UpdateItemOutput, error) {
+	if err := d.Limiter.Acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer d.Limiter.Release()
+
+	return d.DynamoDBAPI.UpdateItemWithContext(ctx, input, opts...)
+}
+
+// DeleteItemWithContext deletes an item while holding a limiter slot
+func (d *LimitedDynamoDB) DeleteItemWithContext(ctx aws.Context, input *dynamodb.DeleteItemInput, opts ...request.Option) (*dynamodb.DeleteItemOutput, error) {
+	if err := d.Limiter.Acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer d.Limiter.Release()
+
+	return d.DynamoDBAPI.DeleteItemWithContext(ctx, input, opts...)
+}
+
+// QueryWithContext runs a query while holding a limiter slot
+func (d *LimitedDynamoDB) QueryWithContext(ctx aws.Context, input *dynamodb.QueryInput, opts ...request.Option) (*dynamodb.QueryOutput, error) {
+	if err := d.Limiter.Acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer d.Limiter.Release()
+
+	return d.DynamoDBAPI.QueryWithContext(ctx, input, opts...)
+}
+
+// ScanWithContext runs a scan while holding a limiter slot
+func (d *LimitedDynamoDB) ScanWithContext(ctx aws.Context, input *dynamodb.ScanInput, opts ...request.Option) (*dynamodb.ScanOutput, error) {
+	if err := d.Limiter.Acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer d.Limiter.Release()
+
+	return d.DynamoDBAPI.ScanWithContext(ctx, input, opts...)
+}
+
+// BatchWriteItemWithContext writes a batch while holding a limiter slot
+func (d *LimitedDynamoDB) BatchWriteItemWithContext(ctx aws.Context, input *dynamodb.BatchWriteItemInput, opts ...request.Option) (*dynamodb.BatchWriteItemOutput, error) {
+	if err := d.Limiter.Acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer d.Limiter.Release()
+
+	return d.DynamoDBAPI.BatchWriteItemWithContext(ctx, input, opts...)
+}
+
+// BatchGetItemWithContext reads a batch while holding a limiter slot
+func (d *LimitedDynamoDB) BatchGetItemWithContext(ctx aws.Context, input *dynamodb.BatchGetItemInput, opts ...request.Option) (*dynamodb.BatchGetItemOutput, error) {
+	if err := d.Limiter.Acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer d.Limiter.Release()
+
+	return d.DynamoDBAPI.BatchGetItemWithContext(ctx, input, opts...)
+}
+
+// TransactWriteItemsWithContext runs a transaction while holding a limiter slot
+func (d *LimitedDynamoDB) TransactWriteItemsWithContext(ctx aws.Context, input *dynamodb.TransactWriteItemsInput, opts ...request.Option) (*dynamodb.TransactWriteItemsOutput, error) {
+	if err := d.Limiter.Acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer d.Limiter.Release()
+
+	return d.DynamoDBAPI.TransactWriteItemsWithContext(ctx, input, opts...)
+}
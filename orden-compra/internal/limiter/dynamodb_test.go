@@ -0,0 +1,104 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// blockingDynamoDB holds every GetItem call until release is closed or the
+// caller gives up
+type blockingDynamoDB struct {
+	dynamodbiface.DynamoDBAPI
+	started chan struct{}
+	release chan struct{}
+}
+
+func (d *blockingDynamoDB) GetItemWithContext(ctx aws.Context, input *dynamodb.GetItemInput, opts ...request.Option) (*dynamodb.GetItemOutput, error) {
+	d.started <- struct{}{}
+	select {
+	case <-d.release:
+		return &dynamodb.GetItemOutput{}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func TestLimitedDynamoDBHoldsASlotPerCall(t *testing.T) {
+	client := &blockingDynamoDB{started: make(chan struct{}, 10), release: make(chan struct{})}
+	l := newLimiter(t, Config{MaxConcurrency: 2, MaxQueue: 1})
+	db := NewLimitedDynamoDB(client, l)
+	input := &dynamodb.GetItemInput{TableName: aws.String("orden-compra-read")}
+
+	errs := make(chan error, 3)
+	for i := 0; i < 3; i++ {
+		go func() {
+			_, err := db.GetItemWithContext(context.Background(), input)
+			errs <- err
+		}()
+	}
+	<-client.started
+	<-client.started
+	eventually(t, "the third call to queue", func() bool { return l.Stats().Queued == 1 })
+
+	// The queue is full, so a fourth call is shed without reaching DynamoDB
+	if _, err := db.GetItemWithContext(context.Background(), input); !errors.Is(err, ErrSaturated) {
+		t.Fatalf("GetItem returned %v, want ErrSaturated", err)
+	}
+
+	close(client.release)
+	for i := 0; i < 3; i++ {
+		if err := <-errs; err != nil {
+			t.Fatalf("GetItem: %v", err)
+		}
+	}
+	if stats := l.Stats(); stats.InFlight != 0 || stats.Queued != 0 {
+		t.Fatalf("stats %+v after every call returned", stats)
+	}
+}
+
+func TestLimitedDynamoDBReleasesTheSlotOfACancelledCall(t *testing.T) {
+	client := &blockingDynamoDB{started: make(chan struct{}, 10), release: make(chan struct{})}
+	l := newLimiter(t, Config{MaxConcurrency: 1, MaxQueue: 1})
+	db := NewLimitedDynamoDB(client, l)
+	input := &dynamodb.GetItemInput{TableName: aws.String("orden-compra-read")}
+
+	// A call cancelled while DynamoDB is working hands its slot back
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() {
+		_, err := db.GetItemWithContext(ctx, input)
+		errs <- err
+	}()
+	<-client.started
+	cancel()
+	if err := <-errs; !errors.Is(err, context.Canceled) {
+		t.Fatalf("GetItem returned %v, want context.Canceled", err)
+	}
+	if stats := l.Stats(); stats.InFlight != 0 {
+		t.Fatalf("%d slots in flight after the call was cancelled, want 0", stats.InFlight)
+	}
+
+	// A call cancelled while queued never reaches DynamoDB
+	close(client.release)
+	l.Acquire(context.Background())
+	ctx, cancel = context.WithCancel(context.Background())
+	go func() {
+		_, err := db.GetItemWithContext(ctx, input)
+		errs <- err
+	}()
+	eventually(t, "the call to queue", func() bool { return l.Stats().Queued == 1 })
+	cancel()
+	if err := <-errs; !errors.Is(err, context.Canceled) {
+		t.Fatalf("GetItem returned %v, want context.Canceled", err)
+	}
+	l.Release()
+	if stats := l.Stats(); stats.InFlight != 0 || stats.Queued != 0 || len(client.started) != 0 {
+		t.Fatalf("stats %+v with %d calls reaching DynamoDB", stats, len(client.started))
+	}
+}
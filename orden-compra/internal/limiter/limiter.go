@@ -0,0 +1,320 @@
+package limiter
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// ErrSaturated is returned when a limiter is at capacity and its wait queue is full
+var ErrSaturated = errors.New("dependency saturated")
+
+// ErrQueueTimeout is returned when a caller waited longer than the queue timeout
+var ErrQueueTimeout = errors.New("timed out waiting for dependency slot")
+
+// Config represents the settings of a single limiter
+type Config struct {
+	MaxConcurrency int           `json:"max_concurrency"`
+	MaxQueue       int           `json:"max_queue"`
+	QueueTimeout   time.Duration `json:"queue_timeout"`
+}
+
+// Validate checks that the limiter settings are usable
+func (c Config) Validate() error {
+	if c.MaxConcurrency < 1 {
+		return fmt.Errorf("max_concurrency must be at least 1")
+	}
+	if c.MaxQueue < 0 {
+		return fmt.Errorf("max_queue must not be negative")
+	}
+	if c.QueueTimeout < 0 {
+		return fmt.Errorf("queue_timeout must not be negative")
+	}
+	return nil
+}
+
+// Stats represents a point-in-time view of a limiter
+type Stats struct {
+	Name           string  `json:"name"`
+	MaxConcurrency int     `json:"max_concurrency"`
+	MaxQueue       int     `json:"max_queue"`
+	QueueTimeout   string  `json:"queue_timeout"`
+	InFlight       int     `json:"in_flight"`
+	Queued         int     `json:"queued"`
+	Rejected       int64   `json:"rejected"`
+	Saturation     float64 `json:"saturation"`
+}
+
+// Limiter bounds the number of concurrent calls made to a single dependency.
+// Callers beyond the limit wait in a FIFO queue; once the queue is full
+// further callers are shed with ErrSaturated.
+type Limiter struct {
+	name     string
+	mu       sync.Mutex
+	config   Config
+	inFlight int
+	waiters  list.List
+	rejected int64
+	metrics  *limiterMetrics
+}
+
+// Acquire reserves a slot, waiting in the queue if necessary
+func (l *Limiter) Acquire(ctx context.Context) error {
+	start := time.Now()
+
+	l.mu.Lock()
+	if l.inFlight < l.config.MaxConcurrency && l.waiters.Len() == 0 {
+		l.inFlight++
+		l.mu.Unlock()
+		l.metrics.recordWait(ctx, l.name, start)
+		return nil
+	}
+
+	if l.waiters.Len() >= l.config.MaxQueue {
+		l.rejected++
+		l.mu.Unlock()
+		l.metrics.recordRejected(ctx, l.name, "queue_full")
+		return fmt.Errorf("%s: %w", l.name, ErrSaturated)
+	}
+
+	ready := make(chan struct{})
+	element := l.waiters.PushBack(ready)
+	queueTimeout := l.config.QueueTimeout
+	l.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if queueTimeout > 0 {
+		timer := time.NewTimer(queueTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case <-ready:
+		l.metrics.recordWait(ctx, l.name, start)
+		return nil
+	case <-ctx.Done():
+		return l.abandon(ctx, element, ready, ctx.Err(), "cancelled")
+	case <-timeout:
+		return l.abandon(ctx, element, ready, ErrQueueTimeout, "queue_timeout")
+	}
+}
+
+// abandon removes a waiter from the queue, handing back the slot if it was
+// granted concurrently with the caller giving up
+func (l *Limiter) abandon(ctx context.Context, element *list.Element, ready chan struct{}, cause error, reason string) error {
+	l.mu.Lock()
+	select {
+	case <-ready:
+		l.mu.Unlock()
+		l.Release()
+	default:
+		l.waiters.Remove(element)
+		l.rejected++
+		l.mu.Unlock()
+	}
+
+	l.metrics.recordRejected(ctx, l.name, reason)
+	return fmt.Errorf("%s: %w", l.name, cause)
+}
+
+// Release frees a slot previously reserved with Acquire
+func (l *Limiter) Release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.inFlight > 0 {
+		l.inFlight--
+	}
+	l.grantLocked()
+}
+
+// Do runs fn while holding a slot
+func (l *Limiter) Do(ctx context.Context, fn func() error) error {
+	if err := l.Acquire(ctx); err != nil {
+		return err
+	}
+	defer l.Release()
+
+	return fn()
+}
+
+// Configure updates the limiter settings at runtime
+func (l *Limiter) Configure(config Config) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.config = config
+	l.grantLocked()
+	return nil
+}
+
+// Stats returns the current limiter state
+func (l *Limiter) Stats() Stats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return Stats{
+		Name:           l.name,
+		MaxConcurrency: l.config.MaxConcurrency,
+		MaxQueue:       l.config.MaxQueue,
+		QueueTimeout:   l.config.QueueTimeout.String(),
+		InFlight:       l.inFlight,
+		Queued:         l.waiters.Len(),
+		Rejected:       l.rejected,
+		Saturation:     float64(l.inFlight) / float64(l.config.MaxConcurrency),
+	}
+}
+
+// grantLocked hands free slots to queued waiters in arrival order
+func (l *Limiter) grantLocked() {
+	for l.inFlight < l.config.MaxConcurrency && l.waiters.Len() > 0 {
+		element := l.waiters.Front()
+		l.waiters.Remove(element)
+		l.inFlight++
+		close(element.Value.(chan struct{}))
+	}
+}
+
+// Registry holds one limiter per downstream dependency
+type Registry struct {
+	mu       sync.RWMutex
+	limiters map[string]*Limiter
+	metrics  *limiterMetrics
+}
+
+// NewRegistry creates a new limiter registry and registers its saturation metrics
+func NewRegistry() *Registry {
+	r := &Registry{
+		limiters: make(map[string]*Limiter),
+	}
+	r.metrics = newLimiterMetrics(r)
+	return r
+}
+
+// Register creates a limiter for the named dependency
+func (r *Registry) Register(name string, config Config) (*Limiter, error) {
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid limiter config for %s: %w", name, err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.limiters[name]; exists {
+		return nil, fmt.Errorf("limiter %s already registered", name)
+	}
+
+	l := &Limiter{
+		name:    name,
+		config:  config,
+		metrics: r.metrics,
+	}
+	r.limiters[name] = l
+	return l, nil
+}
+
+// Get returns the limiter for the named dependency
+func (r *Registry) Get(name string) (*Limiter, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	l, ok := r.limiters[name]
+	return l, ok
+}
+
+// Stats returns the state of every registered limiter ordered by name
+func (r *Registry) Stats() []Stats {
+	r.mu.RLock()
+	limiters := make([]*Limiter, 0, len(r.limiters))
+	for _, l := range r.limiters {
+		limiters = append(limiters, l)
+	}
+	r.mu.RUnlock()
+
+	stats := make([]Stats, 0, len(limiters))
+	for _, l := range limiters {
+		stats = append(stats, l.Stats())
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Name < stats[j].Name })
+	return stats
+}
+
+// limiterMetrics holds the OpenTelemetry instruments shared by all limiters
+type limiterMetrics struct {
+	waitTime metric.Float64Histogram
+	rejected metric.Int64Counter
+}
+
+// newLimiterMetrics creates the limiter instruments; failures leave metrics disabled
+func newLimiterMetrics(registry *Registry) *limiterMetrics {
+	meter := otel.Meter("orden-compra/limiter")
+	m := &limiterMetrics{}
+
+	m.waitTime, _ = meter.Float64Histogram(
+		"dependency_limiter_wait_seconds",
+		metric.WithDescription("Time spent waiting for a dependency slot"),
+		metric.WithUnit("s"),
+	)
+	m.rejected, _ = meter.Int64Counter(
+		"dependency_limiter_rejected_total",
+		metric.WithDescription("Calls shed or abandoned because a dependency was saturated"),
+	)
+
+	inFlight, err1 := meter.Int64ObservableGauge(
+		"dependency_limiter_in_flight",
+		metric.WithDescription("Calls currently in flight per dependency"),
+	)
+	queued, err2 := meter.Int64ObservableGauge(
+		"dependency_limiter_queued",
+		metric.WithDescription("Calls waiting for a slot per dependency"),
+	)
+	limit, err3 := meter.Int64ObservableGauge(
+		"dependency_limiter_max_concurrency",
+		metric.WithDescription("Configured concurrency limit per dependency"),
+	)
+	if err1 == nil && err2 == nil && err3 == nil {
+		_, _ = meter.RegisterCallback(func(ctx context.Context, o metric.Observer) error {
+			for _, s := range registry.Stats() {
+				attrs := metric.WithAttributes(attribute.String("dependency", s.Name))
+				o.ObserveInt64(inFlight, int64(s.InFlight), attrs)
+				o.ObserveInt64(queued, int64(s.Queued), attrs)
+				o.ObserveInt64(limit, int64(s.MaxConcurrency), attrs)
+			}
+			return nil
+		}, inFlight, queued, limit)
+	}
+
+	return m
+}
+
+// recordWait records how long a caller waited for its slot
+func (m *limiterMetrics) recordWait(ctx context.Context, name string, start time.Time) {
+	if m == nil || m.waitTime == nil {
+		return
+	}
+	m.waitTime.Record(ctx, time.Since(start).Seconds(), metric.WithAttributes(attribute.String("dependency", name)))
+}
+
+// recordRejected records a shed or abandoned call
+func (m *limiterMetrics) recordRejected(ctx context.Context, name, reason string) {
+	if m == nil || m.rejected == nil {
+		return
+	}
+	m.rejected.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("dependency", name),
+		attribute.String("reason", reason),
+	))
+}
@@ -0,0 +1,222 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newLimiter(t *testing.T, config Config) *Limiter {
+	t.Helper()
+	l, err := NewRegistry().Register("dynamodb", config)
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	return l
+}
+
+// eventually polls cond until it holds or a few seconds pass
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestDoNeverExceedsMaxConcurrency(t *testing.T) {
+	l := newLimiter(t, Config{MaxConcurrency: 3, MaxQueue: 100})
+
+	var running, peak atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := l.Do(context.Background(), func() error {
+				n := running.Add(1)
+				for {
+					p := peak.Load()
+					if n <= p || peak.CompareAndSwap(p, n) {
+						break
+					}
+				}
+				time.Sleep(time.Millisecond)
+				running.Add(-1)
+				return nil
+			})
+			if err != nil {
+				t.Errorf("Do: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if peak.Load() != 3 {
+		t.Fatalf("%d calls ran at once, want max_concurrency 3", peak.Load())
+	}
+	if stats := l.Stats(); stats.InFlight != 0 || stats.Queued != 0 || stats.Rejected != 0 {
+		t.Fatalf("stats %+v after every call returned", stats)
+	}
+}
+
+func TestWaitersAreGrantedInArrivalOrder(t *testing.T) {
+	l := newLimiter(t, Config{MaxConcurrency: 1, MaxQueue: 10})
+	if err := l.Acquire(context.Background()); err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+
+	var mu sync.Mutex
+	var order []int
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			l.Do(context.Background(), func() error {
+				mu.Lock()
+				order = append(order, i)
+				mu.Unlock()
+				return nil
+			})
+		}(i)
+		eventually(t, "the waiter to queue", func() bool { return l.Stats().Queued == i+1 })
+	}
+
+	l.Release()
+	wg.Wait()
+	for i, n := range order {
+		if n != i {
+			t.Fatalf("granted in order %v, want arrival order", order)
+		}
+	}
+}
+
+func TestAcquireShedsCallsOnceTheQueueIsFull(t *testing.T) {
+	l := newLimiter(t, Config{MaxConcurrency: 1, MaxQueue: 1})
+	l.Acquire(context.Background())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go l.Acquire(ctx)
+	eventually(t, "the waiter to queue", func() bool { return l.Stats().Queued == 1 })
+
+	if err := l.Acquire(context.Background()); !errors.Is(err, ErrSaturated) {
+		t.Fatalf("Acquire returned %v, want ErrSaturated", err)
+	}
+	if stats := l.Stats(); stats.InFlight != 1 || stats.Queued != 1 || stats.Rejected != 1 || stats.Saturation != 1 {
+		t.Fatalf("stats %+v", stats)
+	}
+}
+
+func TestAcquireGivesUpAfterTheQueueTimeout(t *testing.T) {
+	l := newLimiter(t, Config{MaxConcurrency: 1, MaxQueue: 1, QueueTimeout: 10 * time.Millisecond})
+	l.Acquire(context.Background())
+
+	if err := l.Acquire(context.Background()); !errors.Is(err, ErrQueueTimeout) {
+		t.Fatalf("Acquire returned %v, want ErrQueueTimeout", err)
+	}
+	if stats := l.Stats(); stats.InFlight != 1 || stats.Queued != 0 || stats.Rejected != 1 {
+		t.Fatalf("stats %+v after the timeout", stats)
+	}
+}
+
+func TestCancelledWaiterReleasesItsPlace(t *testing.T) {
+	l := newLimiter(t, Config{MaxConcurrency: 1, MaxQueue: 1})
+	l.Acquire(context.Background())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() { errs <- l.Acquire(ctx) }()
+	eventually(t, "the waiter to queue", func() bool { return l.Stats().Queued == 1 })
+
+	cancel()
+	if err := <-errs; !errors.Is(err, context.Canceled) {
+		t.Fatalf("Acquire returned %v, want context.Canceled", err)
+	}
+	if stats := l.Stats(); stats.InFlight != 1 || stats.Queued != 0 {
+		t.Fatalf("stats %+v after the waiter gave up", stats)
+	}
+
+	// The slot goes to the next caller, not the one that gave up
+	l.Release()
+	if err := l.Acquire(context.Background()); err != nil {
+		t.Fatalf("Acquire after release: %v", err)
+	}
+	l.Release()
+	if stats := l.Stats(); stats.InFlight != 0 {
+		t.Fatalf("%d slots in flight after every caller released, want 0", stats.InFlight)
+	}
+}
+
+func TestCancellationRacingAGrantHandsTheSlotBack(t *testing.T) {
+	l := newLimiter(t, Config{MaxConcurrency: 1, MaxQueue: 1})
+
+	for i := 0; i < 200; i++ {
+		l.Acquire(context.Background())
+		ctx, cancel := context.WithCancel(context.Background())
+		errs := make(chan error, 1)
+		go func() { errs <- l.Acquire(ctx) }()
+		eventually(t, "the waiter to queue", func() bool { return l.Stats().Queued == 1 })
+
+		// Cancel while the slot is being granted; either way no slot leaks
+		go cancel()
+		l.Release()
+		if err := <-errs; err == nil {
+			l.Release()
+		}
+		if stats := l.Stats(); stats.InFlight != 0 || stats.Queued != 0 {
+			t.Fatalf("iteration %d: stats %+v, want no slot held", i, stats)
+		}
+	}
+}
+
+func TestConfigureGrantsTheWaiters(t *testing.T) {
+	l := newLimiter(t, Config{MaxConcurrency: 1, MaxQueue: 5})
+	l.Acquire(context.Background())
+
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() { errs <- l.Acquire(context.Background()) }()
+	}
+	eventually(t, "the waiters to queue", func() bool { return l.Stats().Queued == 2 })
+
+	if err := l.Configure(Config{MaxConcurrency: 0}); err == nil {
+		t.Fatal("accepted max_concurrency 0")
+	}
+	if err := l.Configure(Config{MaxConcurrency: 3, MaxQueue: 5}); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != nil {
+			t.Fatalf("waiter: %v", err)
+		}
+	}
+	if stats := l.Stats(); stats.InFlight != 3 || stats.Queued != 0 || stats.MaxConcurrency != 3 {
+		t.Fatalf("stats %+v after raising the limit", stats)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	registry := NewRegistry()
+	if _, err := registry.Register("sqs", Config{MaxConcurrency: 1, MaxQueue: -1}); err == nil {
+		t.Fatal("registered a limiter with a negative queue")
+	}
+	registry.Register("sqs", Config{MaxConcurrency: 4})
+	registry.Register("dynamodb", Config{MaxConcurrency: 8})
+	if _, err := registry.Register("sqs", Config{MaxConcurrency: 1}); err == nil {
+		t.Fatal("registered sqs twice")
+	}
+	if l, ok := registry.Get("dynamodb"); !ok || l.Stats().MaxConcurrency != 8 {
+		t.Fatalf("Get returned %v, %v", l, ok)
+	}
+	if stats := registry.Stats(); len(stats) != 2 || stats[0].Name != "dynamodb" || stats[1].Name != "sqs" {
+		t.Fatalf("stats %+v", stats)
+	}
+}
@@ -0,0 +1,149 @@
+package logging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Level is the minimum severity of the log lines that are written
+type Level int32
+
+// Log levels, from most to least verbose
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+// ErrInvalidLevel is returned when a log level name is not recognised
+var ErrInvalidLevel = errors.New("invalid log level")
+
+// String returns the name of the level
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	default:
+		return fmt.Sprintf("level(%d)", int32(l))
+	}
+}
+
+// ParseLevel parses debug, info, warn (or warning) and error, ignoring case
+func ParseLevel(name string) (Level, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "debug":
+		return LevelDebug, nil
+	case "info":
+		return LevelInfo, nil
+	case "warn", "warning":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	default:
+		return 0, fmt.Errorf("%w: %q, expected debug, info, warn or error", ErrInvalidLevel, name)
+	}
+}
+
+// logrusLevel maps a level onto its logrus equivalent
+func (l Level) logrusLevel() logrus.Level {
+	switch l {
+	case LevelDebug:
+		return logrus.DebugLevel
+	case LevelWarn:
+		return logrus.WarnLevel
+	case LevelError:
+		return logrus.ErrorLevel
+	default:
+		return logrus.InfoLevel
+	}
+}
+
+// Leveler holds the process-wide log level and applies changes to every
+// logger it controls, so the level can be adjusted at runtime
+type Leveler struct {
+	level atomic.Int32
+
+	mu     sync.Mutex
+	logrus []*logrus.Logger
+}
+
+// NewLeveler creates a leveler starting at level
+func NewLeveler(level Level) *Leveler {
+	l := &Leveler{}
+	l.level.Store(int32(level))
+	return l
+}
+
+// Level returns the current level
+func (l *Leveler) Level() Level {
+	return Level(l.level.Load())
+}
+
+// SetLevel changes the level of every controlled logger
+func (l *Leveler) SetLevel(level Level) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.level.Store(int32(level))
+	for _, logger := range l.logrus {
+		logger.SetLevel(level.logrusLevel())
+	}
+}
+
+// Attach puts a logrus logger under the control of the leveler
+func (l *Leveler) Attach(logger *logrus.Logger) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	logger.SetLevel(l.Level().logrusLevel())
+	l.logrus = append(l.logrus, logger)
+}
+
+// Writer returns an io.Writer for a standard library logger that drops lines
+// below the current level. The standard logger has no levels, so each line is
+// classified by its markers: DEBUG is debug, WARN and WARNING are warn, ERROR,
+// FAILED and Failed are error, and anything else is info.
+func (l *Leveler) Writer(out io.Writer) io.Writer {
+	return &levelWriter{leveler: l, out: out}
+}
+
+type levelWriter struct {
+	leveler *Leveler
+	out     io.Writer
+}
+
+// Write relies on log.Logger writing each line with a single call
+func (w *levelWriter) Write(p []byte) (int, error) {
+	if lineLevel(p) < w.leveler.Level() {
+		return len(p), nil
+	}
+	return w.out.Write(p)
+}
+
+// lineLevel classifies a log line by its severity markers
+func lineLevel(line []byte) Level {
+	switch {
+	case bytes.Contains(line, []byte("ERROR")), bytes.Contains(line, []byte("FAILED")), bytes.Contains(line, []byte("Failed")):
+		return LevelError
+	case bytes.Contains(line, []byte("WARN")):
+		return LevelWarn
+	case bytes.Contains(line, []byte("DEBUG")):
+		return LevelDebug
+	default:
+		return LevelInfo
+	}
+}
@@ -0,0 +1,87 @@
+package logging
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestParseLevel(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		want Level
+	}{
+		{"debug", LevelDebug},
+		{"INFO", LevelInfo},
+		{" warn ", LevelWarn},
+		{"Warning", LevelWarn},
+		{"error", LevelError},
+	} {
+		if level, err := ParseLevel(tc.name); err != nil || level != tc.want {
+			t.Fatalf("ParseLevel(%q) = %s, %v, want %s", tc.name, level, err, tc.want)
+		}
+	}
+	for _, name := range []string{"", "trace", "fatal"} {
+		if _, err := ParseLevel(name); !errors.Is(err, ErrInvalidLevel) {
+			t.Fatalf("ParseLevel(%q) returned %v, want ErrInvalidLevel", name, err)
+		}
+	}
+	if got := Level(7).String(); got != "level(7)" {
+		t.Fatalf("unknown level named %q", got)
+	}
+}
+
+func TestLevelerFiltersStandardLoggers(t *testing.T) {
+	var out bytes.Buffer
+	leveler := NewLeveler(LevelInfo)
+	logger := log.New(leveler.Writer(&out), "", 0)
+
+	lines := []string{
+		"DEBUG: cache miss",
+		"Purchase order created",
+		"WARNING: retrying",
+		"Failed to publish event",
+		"AUDIT WRITE FAILED",
+		"ERROR: broker unavailable",
+	}
+	for _, tc := range []struct {
+		level   Level
+		written int
+	}{
+		{LevelDebug, 6},
+		{LevelInfo, 5},
+		{LevelWarn, 4},
+		{LevelError, 3},
+	} {
+		t.Run(tc.level.String(), func(t *testing.T) {
+			out.Reset()
+			leveler.SetLevel(tc.level)
+			for _, line := range lines {
+				logger.Print(line)
+			}
+			if written := bytes.Count(out.Bytes(), []byte("\n")); written != tc.written {
+				t.Fatalf("wrote %d lines, want %d:\n%s", written, tc.written, out.String())
+			}
+		})
+	}
+}
+
+func TestLevelerControlsLogrusLoggers(t *testing.T) {
+	leveler := NewLeveler(LevelWarn)
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	leveler.Attach(logger)
+	if logger.GetLevel() != logrus.WarnLevel {
+		t.Fatalf("attached logger at %s, want warning", logger.GetLevel())
+	}
+
+	leveler.SetLevel(LevelDebug)
+	if logger.GetLevel() != logrus.DebugLevel || leveler.Level() != LevelDebug {
+		t.Fatalf("logger at %s and leveler at %s after SetLevel(debug)", logger.GetLevel(), leveler.Level())
+	}
+}
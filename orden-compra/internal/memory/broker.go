@@ -0,0 +1,163 @@
+package memory
+
+import (
+	"context"
+	"sync"
+
+	"github.com/rabbitmq/amqp091-go"
+)
+
+// Published is a message a Broker accepted
+type Published struct {
+	Exchange   string
+	RoutingKey string
+	Message    amqp091.Publishing
+}
+
+// Outcome is how a consumer settled a delivery
+type Outcome string
+
+const (
+	// Acked deliveries were processed
+	Acked Outcome = "ack"
+	// Requeued deliveries were returned to their queue
+	Requeued Outcome = "requeue"
+	// Rejected deliveries were dropped or dead-lettered
+	Rejected Outcome = "reject"
+)
+
+// Broker stands in for RabbitMQ in tests. It implements messaging.Sender,
+// recording every message published instead of sending it, and acknowledges
+// the deliveries it hands to consumers, recording how each was settled.
+type Broker struct {
+	mu        sync.Mutex
+	published []Published
+	err       error
+	nextTag   uint64
+	outcomes  map[uint64]Outcome
+}
+
+// NewBroker creates a broker with nothing published
+func NewBroker() *Broker {
+	return &Broker{outcomes: make(map[uint64]Outcome)}
+}
+
+// Publish records a message, or returns the error set by Fail
+func (b *Broker) Publish(ctx context.Context, exchange, routingKey string, msg amqp091.Publishing) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.err != nil {
+		return b.err
+	}
+	body := append([]byte(nil), msg.Body...)
+	msg.Body = body
+	b.published = append(b.published, Published{Exchange: exchange, RoutingKey: routingKey, Message: msg})
+	return nil
+}
+
+// Published returns the messages published so far, oldest first
+func (b *Broker) Published() []Published {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return append([]Published(nil), b.published...)
+}
+
+// Fail makes every later publish return err, as when the broker nacks or is
+// unreachable; Fail(nil) restores publishing
+func (b *Broker) Fail(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.err = err
+}
+
+// Reset forgets the published messages, settled deliveries and any failure set by Fail
+func (b *Broker) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.published = nil
+	b.err = nil
+	b.outcomes = make(map[uint64]Outcome)
+}
+
+// Deliver returns a delivery of msg consumed from a queue, as a consumer
+// would receive it. Its Ack, Nack and Reject calls are recorded by delivery
+// tag and read back with Outcome.
+func (b *Broker) Deliver(queue string, msg amqp091.Publishing) amqp091.Delivery {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.nextTag++
+	return amqp091.Delivery{
+		Acknowledger:    b,
+		Headers:         msg.Headers,
+		ContentType:     msg.ContentType,
+		ContentEncoding: msg.ContentEncoding,
+		DeliveryMode:    msg.DeliveryMode,
+		Priority:        msg.Priority,
+		CorrelationId:   msg.CorrelationId,
+		ReplyTo:         msg.ReplyTo,
+		Expiration:      msg.Expiration,
+		MessageId:       msg.MessageId,
+		Timestamp:       msg.Timestamp,
+		Type:            msg.Type,
+		UserId:          msg.UserId,
+		AppId:           msg.AppId,
+		DeliveryTag:     b.nextTag,
+		RoutingKey:      queue,
+		Body:            append([]byte(nil), msg.Body...),
+	}
+}
+
+// Outcome returns how the delivery with the tag was settled, or "" when it
+// was not settled yet
+func (b *Broker) Outcome(tag uint64) Outcome {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.outcomes[tag]
+}
+
+// Ack records that a delivery was processed
+func (b *Broker) Ack(tag uint64, multiple bool) error {
+	b.settle(tag, multiple, Acked)
+	return nil
+}
+
+// Nack records that a delivery was requeued or rejected
+func (b *Broker) Nack(tag uint64, multiple, requeue bool) error {
+	if requeue {
+		b.settle(tag, multiple, Requeued)
+	} else {
+		b.settle(tag, multiple, Rejected)
+	}
+	return nil
+}
+
+// Reject records that a delivery was requeued or rejected
+func (b *Broker) Reject(tag uint64, requeue bool) error {
+	return b.Nack(tag, false, requeue)
+}
+
+// settle records the outcome of a delivery, and with multiple of every
+// earlier unsettled one
+func (b *Broker) settle(tag uint64, multiple bool, outcome Outcome) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.outcomes[tag] = outcome
+	if !multiple {
+		return
+	}
+	for earlier := uint64(1); earlier < tag; earlier++ {
+		if _, ok := b.outcomes[earlier]; !ok {
+			b.outcomes[earlier] = outcome
+		}
+	}
+}
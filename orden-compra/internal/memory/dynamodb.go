@@ -0,0 +1,829 @@
+// Package memory holds in-memory stand-ins for the services' external
+// dependencies: a DynamoDB client that keeps its tables in maps and a broker
+// that records what is published. With them and a clock.Fake the command and
+// query handlers run in unit tests without DynamoDB Local or RabbitMQ:
+//
+//	db := memory.NewDynamoDB(memory.Tables)
+//	command := cqrs.NewCreatePurchaseOrderCommand(order, db, logger, nil, nil)
+//	command.Clock = clock.NewFake(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))
+//	_, err := command.Execute(ctx)
+//	items := db.Items("orden-compra-events")
+package memory
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// Key is the primary key schema of a table; Range is empty for tables with a
+// hash key only
+type Key struct {
+	Hash  string
+	Range string
+}
+
+// Tables is the key schema of every orden-compra table, as created by
+// infrastructure/dynamodb-local/dynamodb.yaml
+var Tables = map[string]Key{
+	"orden-compra-events":                {Hash: "id", Range: "timestamp"},
+	"orden-compra-read":                  {Hash: "id"},
+	"orden-compra-dead-letters":          {Hash: "id"},
+	"orden-compra-consolidated":          {Hash: "id"},
+	"orden-compra-suppliers":             {Hash: "id"},
+	"orden-compra-reorder-policies":      {Hash: "product_id"},
+	"orden-compra-leases":                {Hash: "name"},
+	"orden-compra-webhook-subscriptions": {Hash: "id"},
+	"orden-compra-webhook-deliveries":    {Hash: "id"},
+	"orden-compra-access-policies":       {Hash: "subject"},
+	"orden-compra-audit-log":             {Hash: "id"},
+	"orden-compra-stats":                 {Hash: "id"},
+	"orden-compra-stats-contributions":   {Hash: "id"},
+	"orden-compra-sagas":                 {Hash: "id"},
+	"orden-compra-checkpoints":           {Hash: "id"},
+	"orden-compra-standing-orders":       {Hash: "id"},
+	"orden-compra-snapshots":             {Hash: "aggregate_id"},
+	"orden-compra-idempotency-keys":      {Hash: "id"},
+	"orden-compra-stage-timings":         {Hash: "id"},
+	"orden-compra-erp-deliveries":        {Hash: "id"},
+	"orden-compra-outbox":                {Hash: "id"},
+}
+
+// table holds the items of one table by their encoded key
+type table struct {
+	key   Key
+	items map[string]item
+}
+
+// DynamoDB is a DynamoDB client keeping its tables in memory. It supports
+// the item, batch, transaction, scan and query operations the services use,
+// with condition, filter, key condition, update and projection expressions.
+// Secondary indexes, streams and capacity reporting are not supported; the
+// operations it does not implement panic through the nil embedded client.
+type DynamoDB struct {
+	dynamodbiface.DynamoDBAPI
+
+	mu     sync.Mutex
+	tables map[string]*table
+}
+
+// NewDynamoDB creates an in-memory client with empty tables of the given schemas
+func NewDynamoDB(tables map[string]Key) *DynamoDB {
+	d := &DynamoDB{tables: make(map[string]*table, len(tables))}
+	for name, key := range tables {
+		d.tables[name] = &table{key: key, items: make(map[string]item)}
+	}
+	return d
+}
+
+// Items returns a copy of every item of a table, ordered by key
+func (d *DynamoDB) Items(tableName string) []map[string]*dynamodb.AttributeValue {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	t, ok := d.tables[tableName]
+	if !ok {
+		return nil
+	}
+	items := make([]map[string]*dynamodb.AttributeValue, 0, len(t.items))
+	for _, key := range t.sortedKeys() {
+		items = append(items, copyItem(t.items[key]))
+	}
+	return items
+}
+
+// GetItemWithContext returns an item by key
+func (d *DynamoDB) GetItemWithContext(ctx aws.Context, input *dynamodb.GetItemInput, opts ...request.Option) (*dynamodb.GetItemOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	t, key, err := d.lookup(input.TableName, input.Key)
+	if err != nil {
+		return nil, err
+	}
+	stored, ok := t.items[key]
+	if !ok {
+		return &dynamodb.GetItemOutput{}, nil
+	}
+	projected, err := projectItem(stored, input.ProjectionExpression, input.ExpressionAttributeNames)
+	if err != nil {
+		return nil, err
+	}
+	return &dynamodb.GetItemOutput{Item: projected}, nil
+}
+
+// PutItemWithContext creates or replaces an item if its condition holds
+func (d *DynamoDB) PutItemWithContext(ctx aws.Context, input *dynamodb.PutItemInput, opts ...request.Option) (*dynamodb.PutItemOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	t, key, err := d.lookup(input.TableName, input.Item)
+	if err != nil {
+		return nil, err
+	}
+	old := t.items[key]
+	if err := checkCondition(old, input.ConditionExpression, input.ExpressionAttributeNames, input.ExpressionAttributeValues); err != nil {
+		return nil, err
+	}
+	t.items[key] = copyItem(input.Item)
+
+	output := &dynamodb.PutItemOutput{}
+	if aws.StringValue(input.ReturnValues) == dynamodb.ReturnValueAllOld {
+		output.Attributes = copyItem(old)
+	}
+	return output, nil
+}
+
+// UpdateItemWithContext updates or creates an item if its condition holds
+func (d *DynamoDB) UpdateItemWithContext(ctx aws.Context, input *dynamodb.UpdateItemInput, opts ...request.Option) (*dynamodb.UpdateItemOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	t, key, err := d.lookup(input.TableName, input.Key)
+	if err != nil {
+		return nil, err
+	}
+	old := t.items[key]
+	updated, u, err := t.update(old, input.Key, input.UpdateExpression, input.ConditionExpression, input.ExpressionAttributeNames, input.ExpressionAttributeValues)
+	if err != nil {
+		return nil, err
+	}
+	t.items[key] = updated
+
+	output := &dynamodb.UpdateItemOutput{}
+	switch aws.StringValue(input.ReturnValues) {
+	case dynamodb.ReturnValueAllOld:
+		output.Attributes = copyItem(old)
+	case dynamodb.ReturnValueAllNew:
+		output.Attributes = copyItem(updated)
+	case dynamodb.ReturnValueUpdatedOld:
+		output.Attributes = touched(old, u)
+	case dynamodb.ReturnValueUpdatedNew:
+		output.Attributes = touched(updated, u)
+	}
+	return output, nil
+}
+
+// DeleteItemWithContext deletes an item if its condition holds
+func (d *DynamoDB) DeleteItemWithContext(ctx aws.Context, input *dynamodb.DeleteItemInput, opts ...request.Option) (*dynamodb.DeleteItemOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	t, key, err := d.lookup(input.TableName, input.Key)
+	if err != nil {
+		return nil, err
+	}
+	old := t.items[key]
+	if err := checkCondition(old, input.ConditionExpression, input.ExpressionAttributeNames, input.ExpressionAttributeValues); err != nil {
+		return nil, err
+	}
+	delete(t.items, key)
+
+	output := &dynamodb.DeleteItemOutput{}
+	if aws.StringValue(input.ReturnValues) == dynamodb.ReturnValueAllOld {
+		output.Attributes = copyItem(old)
+	}
+	return output, nil
+}
+
+// ScanWithContext reads a page of a table in key order. Limit bounds the
+// items evaluated before the filter, as DynamoDB does, and segments split
+// the keys by hash.
+func (d *DynamoDB) ScanWithContext(ctx aws.Context, input *dynamodb.ScanInput, opts ...request.Option) (*dynamodb.ScanOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if input.IndexName != nil {
+		return nil, validationError("secondary indexes are not supported")
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	t, err := d.table(input.TableName)
+	if err != nil {
+		return nil, err
+	}
+	filter, err := compileCondition(input.FilterExpression, input.ExpressionAttributeNames, input.ExpressionAttributeValues)
+	if err != nil {
+		return nil, err
+	}
+	projection, err := compileProjection(input.ProjectionExpression, input.ExpressionAttributeNames)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := t.sortedKeys()
+	if segments := aws.Int64Value(input.TotalSegments); segments > 0 {
+		segment := aws.Int64Value(input.Segment)
+		inSegment := keys[:0:0]
+		for _, key := range keys {
+			hash := fnv.New32a()
+			hash.Write([]byte(key))
+			if int64(hash.Sum32())%segments == segment {
+				inSegment = append(inSegment, key)
+			}
+		}
+		keys = inSegment
+	}
+
+	page, last, err := t.page(keys, input.ExclusiveStartKey, aws.Int64Value(input.Limit))
+	if err != nil {
+		return nil, err
+	}
+	matched, err := filterItems(page, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	output := &dynamodb.ScanOutput{
+		Count:            aws.Int64(int64(len(matched))),
+		ScannedCount:     aws.Int64(int64(len(page))),
+		LastEvaluatedKey: last,
+	}
+	if aws.StringValue(input.Select) != dynamodb.SelectCount {
+		output.Items = projectItems(matched, projection)
+	}
+	return output, nil
+}
+
+// QueryWithContext reads the items of a partition in range key order
+func (d *DynamoDB) QueryWithContext(ctx aws.Context, input *dynamodb.QueryInput, opts ...request.Option) (*dynamodb.QueryOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if input.IndexName != nil {
+		return nil, validationError("secondary indexes are not supported")
+	}
+	if input.KeyConditionExpression == nil {
+		return nil, validationError("KeyConditionExpression is required")
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	t, err := d.table(input.TableName)
+	if err != nil {
+		return nil, err
+	}
+	keyCondition, err := compileCondition(input.KeyConditionExpression, input.ExpressionAttributeNames, input.ExpressionAttributeValues)
+	if err != nil {
+		return nil, err
+	}
+	filter, err := compileCondition(input.FilterExpression, input.ExpressionAttributeNames, input.ExpressionAttributeValues)
+	if err != nil {
+		return nil, err
+	}
+	projection, err := compileProjection(input.ProjectionExpression, input.ExpressionAttributeNames)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	for _, key := range t.sortedKeys() {
+		ok, err := keyCondition(t.items[key])
+		if err != nil {
+			return nil, validationError(err.Error())
+		}
+		if ok {
+			keys = append(keys, key)
+		}
+	}
+	if input.ScanIndexForward != nil && !*input.ScanIndexForward {
+		for i, j := 0, len(keys)-1; i < j; i, j = i+1, j-1 {
+			keys[i], keys[j] = keys[j], keys[i]
+		}
+	}
+
+	page, last, err := t.page(keys, input.ExclusiveStartKey, aws.Int64Value(input.Limit))
+	if err != nil {
+		return nil, err
+	}
+	matched, err := filterItems(page, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	output := &dynamodb.QueryOutput{
+		Count:            aws.Int64(int64(len(matched))),
+		ScannedCount:     aws.Int64(int64(len(page))),
+		LastEvaluatedKey: last,
+	}
+	if aws.StringValue(input.Select) != dynamodb.SelectCount {
+		output.Items = projectItems(matched, projection)
+	}
+	return output, nil
+}
+
+// BatchGetItemWithContext returns the items of several keys; every key is processed
+func (d *DynamoDB) BatchGetItemWithContext(ctx aws.Context, input *dynamodb.BatchGetItemInput, opts ...request.Option) (*dynamodb.BatchGetItemOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	responses := make(map[string][]map[string]*dynamodb.AttributeValue, len(input.RequestItems))
+	for tableName, keys := range input.RequestItems {
+		projection, err := compileProjection(keys.ProjectionExpression, keys.ExpressionAttributeNames)
+		if err != nil {
+			return nil, err
+		}
+		for _, k := range keys.Keys {
+			t, key, err := d.lookup(aws.String(tableName), k)
+			if err != nil {
+				return nil, err
+			}
+			if stored, ok := t.items[key]; ok {
+				responses[tableName] = append(responses[tableName], projectItems([]item{stored}, projection)...)
+			}
+		}
+	}
+	return &dynamodb.BatchGetItemOutput{
+		Responses:       responses,
+		UnprocessedKeys: map[string]*dynamodb.KeysAndAttributes{},
+	}, nil
+}
+
+// BatchWriteItemWithContext puts and deletes several items; every request is processed
+func (d *DynamoDB) BatchWriteItemWithContext(ctx aws.Context, input *dynamodb.BatchWriteItemInput, opts ...request.Option) (*dynamodb.BatchWriteItemOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// Validate every request first so a bad one writes nothing
+	type write struct {
+		table *table
+		key   string
+		item  item
+	}
+	var writes []write
+	for tableName, requests := range input.RequestItems {
+		for _, r := range requests {
+			switch {
+			case r.PutRequest != nil:
+				t, key, err := d.lookup(aws.String(tableName), r.PutRequest.Item)
+				if err != nil {
+					return nil, err
+				}
+				writes = append(writes, write{table: t, key: key, item: r.PutRequest.Item})
+			case r.DeleteRequest != nil:
+				t, key, err := d.lookup(aws.String(tableName), r.DeleteRequest.Key)
+				if err != nil {
+					return nil, err
+				}
+				writes = append(writes, write{table: t, key: key})
+			default:
+				return nil, validationError("a write request needs a PutRequest or a DeleteRequest")
+			}
+		}
+	}
+
+	for _, w := range writes {
+		if w.item == nil {
+			delete(w.table.items, w.key)
+			continue
+		}
+		w.table.items[w.key] = copyItem(w.item)
+	}
+	return &dynamodb.BatchWriteItemOutput{
+		UnprocessedItems: map[string][]*dynamodb.WriteRequest{},
+	}, nil
+}
+
+// TransactWriteItemsWithContext applies several writes all or nothing. When a
+// condition fails it returns a TransactionCanceledException whose reasons
+// name the failed item.
+func (d *DynamoDB) TransactWriteItemsWithContext(ctx aws.Context, input *dynamodb.TransactWriteItemsInput, opts ...request.Option) (*dynamodb.TransactWriteItemsOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	type write struct {
+		table *table
+		key   string
+		item  item // nil deletes the item
+		check bool // a condition check writes nothing
+	}
+	writes := make([]write, 0, len(input.TransactItems))
+	reasons := make([]*dynamodb.CancellationReason, len(input.TransactItems))
+	cancelled := false
+	type target struct {
+		table *table
+		key   string
+	}
+	seen := make(map[target]bool, len(input.TransactItems))
+
+	for i, transactItem := range input.TransactItems {
+		reasons[i] = &dynamodb.CancellationReason{Code: aws.String("None")}
+
+		var (
+			t         *table
+			key       string
+			w         write
+			condition error
+			err       error
+		)
+		switch {
+		case transactItem.Put != nil:
+			put := transactItem.Put
+			if t, key, err = d.lookup(put.TableName, put.Item); err != nil {
+				return nil, err
+			}
+			condition = checkCondition(t.items[key], put.ConditionExpression, put.ExpressionAttributeNames, put.ExpressionAttributeValues)
+			w = write{table: t, key: key, item: put.Item}
+		case transactItem.Update != nil:
+			update := transactItem.Update
+			if t, key, err = d.lookup(update.TableName, update.Key); err != nil {
+				return nil, err
+			}
+			var updated item
+			updated, _, condition = t.update(t.items[key], update.Key, update.UpdateExpression, update.ConditionExpression, update.ExpressionAttributeNames, update.ExpressionAttributeValues)
+			w = write{table: t, key: key, item: updated}
+		case transactItem.Delete != nil:
+			del := transactItem.Delete
+			if t, key, err = d.lookup(del.TableName, del.Key); err != nil {
+				return nil, err
+			}
+			condition = checkCondition(t.items[key], del.ConditionExpression, del.ExpressionAttributeNames, del.ExpressionAttributeValues)
+			w = write{table: t, key: key}
+		case transactItem.ConditionCheck != nil:
+			check := transactItem.ConditionCheck
+			if t, key, err = d.lookup(check.TableName, check.Key); err != nil {
+				return nil, err
+			}
+			condition = checkCondition(t.items[key], check.ConditionExpression, check.ExpressionAttributeNames, check.ExpressionAttributeValues)
+			w = write{table: t, key: key, check: true}
+		default:
+			return nil, validationError("a transact item needs a Put, Update, Delete or ConditionCheck")
+		}
+
+		if seen[target{t, key}] {
+			return nil, validationError("transaction request cannot include multiple operations on one item")
+		}
+		seen[target{t, key}] = true
+
+		if condition != nil {
+			if !isConditionFailure(condition) {
+				return nil, condition
+			}
+			cancelled = true
+			reasons[i] = &dynamodb.CancellationReason{
+				Code:    aws.String("ConditionalCheckFailed"),
+				Message: aws.String("The conditional request failed"),
+			}
+			continue
+		}
+		writes = append(writes, w)
+	}
+
+	if cancelled {
+		codes := make([]string, len(reasons))
+		for i, reason := range reasons {
+			codes[i] = aws.StringValue(reason.Code)
+		}
+		return nil, &dynamodb.TransactionCanceledException{
+			Message_:            aws.String(fmt.Sprintf("Transaction cancelled, please refer cancellation reasons for specific reasons [%s]", strings.Join(codes, ", "))),
+			CancellationReasons: reasons,
+		}
+	}
+
+	for _, w := range writes {
+		switch {
+		case w.check:
+		case w.item == nil:
+			delete(w.table.items, w.key)
+		default:
+			w.table.items[w.key] = copyItem(w.item)
+		}
+	}
+	return &dynamodb.TransactWriteItemsOutput{}, nil
+}
+
+// DescribeTableWithContext describes a table's key schema and item count
+func (d *DynamoDB) DescribeTableWithContext(ctx aws.Context, input *dynamodb.DescribeTableInput, opts ...request.Option) (*dynamodb.DescribeTableOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	t, err := d.table(input.TableName)
+	if err != nil {
+		return nil, err
+	}
+	schema := []*dynamodb.KeySchemaElement{{AttributeName: aws.String(t.key.Hash), KeyType: aws.String(dynamodb.KeyTypeHash)}}
+	if t.key.Range != "" {
+		schema = append(schema, &dynamodb.KeySchemaElement{AttributeName: aws.String(t.key.Range), KeyType: aws.String(dynamodb.KeyTypeRange)})
+	}
+	return &dynamodb.DescribeTableOutput{
+		Table: &dynamodb.TableDescription{
+			TableName:   input.TableName,
+			TableStatus: aws.String(dynamodb.TableStatusActive),
+			KeySchema:   schema,
+			ItemCount:   aws.Int64(int64(len(t.items))),
+		},
+	}, nil
+}
+
+// CreateTableWithContext creates an empty table with the given key schema
+func (d *DynamoDB) CreateTableWithContext(ctx aws.Context, input *dynamodb.CreateTableInput, opts ...request.Option) (*dynamodb.CreateTableOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	name := aws.StringValue(input.TableName)
+	if _, ok := d.tables[name]; ok {
+		return nil, awserr.New(dynamodb.ErrCodeResourceInUseException, "Table already exists: "+name, nil)
+	}
+	var key Key
+	for _, element := range input.KeySchema {
+		if aws.StringValue(element.KeyType) == dynamodb.KeyTypeRange {
+			key.Range = aws.StringValue(element.AttributeName)
+		} else {
+			key.Hash = aws.StringValue(element.AttributeName)
+		}
+	}
+	if key.Hash == "" {
+		return nil, validationError("the key schema needs a hash key")
+	}
+	d.tables[name] = &table{key: key, items: make(map[string]item)}
+
+	return &dynamodb.CreateTableOutput{
+		TableDescription: &dynamodb.TableDescription{
+			TableName:   input.TableName,
+			TableStatus: aws.String(dynamodb.TableStatusActive),
+			KeySchema:   input.KeySchema,
+		},
+	}, nil
+}
+
+// table returns a table by name
+func (d *DynamoDB) table(name *string) (*table, error) {
+	t, ok := d.tables[aws.StringValue(name)]
+	if !ok {
+		return nil, &dynamodb.ResourceNotFoundException{
+			Message_: aws.String("Requested resource not found: Table: " + aws.StringValue(name) + " not found"),
+		}
+	}
+	return t, nil
+}
+
+// lookup returns a table and the encoded key of an item or item key
+func (d *DynamoDB) lookup(name *string, key map[string]*dynamodb.AttributeValue) (*table, string, error) {
+	t, err := d.table(name)
+	if err != nil {
+		return nil, "", err
+	}
+	encoded, err := t.encode(key)
+	if err != nil {
+		return nil, "", err
+	}
+	return t, encoded, nil
+}
+
+// encode returns the map key of an item from its primary key attributes.
+// The hash and range parts are separated by a zero byte, which keeps
+// encoded keys in the order of their hash then range key.
+func (t *table) encode(key map[string]*dynamodb.AttributeValue) (string, error) {
+	part := func(name string) (string, error) {
+		value, ok := key[name]
+		if !ok || value == nil {
+			return "", validationError("the provided key element " + name + " is missing")
+		}
+		switch {
+		case value.S != nil:
+			return *value.S, nil
+		case value.N != nil:
+			return number(value).Text('g', 38), nil
+		case value.B != nil:
+			return string(value.B), nil
+		default:
+			return "", validationError("the key element " + name + " must be a string, number or binary")
+		}
+	}
+
+	hash, err := part(t.key.Hash)
+	if err != nil {
+		return "", err
+	}
+	if t.key.Range == "" {
+		return hash, nil
+	}
+	rangeKey, err := part(t.key.Range)
+	if err != nil {
+		return "", err
+	}
+	return hash + "\x00" + rangeKey, nil
+}
+
+// sortedKeys returns the encoded keys of the table in order
+func (t *table) sortedKeys() []string {
+	keys := make([]string, 0, len(t.items))
+	for key := range t.items {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// keyOf returns the primary key attributes of an item
+func (t *table) keyOf(it item) map[string]*dynamodb.AttributeValue {
+	key := map[string]*dynamodb.AttributeValue{t.key.Hash: copyValue(it[t.key.Hash])}
+	if t.key.Range != "" {
+		key[t.key.Range] = copyValue(it[t.key.Range])
+	}
+	return key
+}
+
+// page returns the items of the keys after the exclusive start key, up to
+// limit when positive, and the key to continue from when more remain
+func (t *table) page(keys []string, exclusiveStartKey map[string]*dynamodb.AttributeValue, limit int64) ([]item, map[string]*dynamodb.AttributeValue, error) {
+	start := 0
+	if len(exclusiveStartKey) > 0 {
+		after, err := t.encode(exclusiveStartKey)
+		if err != nil {
+			return nil, nil, err
+		}
+		for start < len(keys) && keys[start] != after {
+			start++
+		}
+		start++
+	}
+	if start > len(keys) {
+		start = len(keys)
+	}
+	keys = keys[start:]
+
+	var last map[string]*dynamodb.AttributeValue
+	if limit > 0 && int64(len(keys)) > limit {
+		keys = keys[:limit]
+		last = t.keyOf(t.items[keys[len(keys)-1]])
+	}
+	items := make([]item, 0, len(keys))
+	for _, key := range keys {
+		items = append(items, t.items[key])
+	}
+	return items, last, nil
+}
+
+// update checks the condition on an item and returns a copy with the update
+// applied, creating the item from its key when it does not exist
+func (t *table) update(old item, key map[string]*dynamodb.AttributeValue, updateExpression, conditionExpression *string, names map[string]*string, values map[string]*dynamodb.AttributeValue) (item, *update, error) {
+	if err := checkCondition(old, conditionExpression, names, values); err != nil {
+		return nil, nil, err
+	}
+
+	base := old
+	if base == nil {
+		base = copyItem(key)
+	}
+	if updateExpression == nil {
+		return copyItem(base), &update{touched: map[string]bool{}}, nil
+	}
+	u, err := parseUpdate(*updateExpression, names, values)
+	if err != nil {
+		return nil, nil, validationError("Invalid UpdateExpression: " + err.Error())
+	}
+	if u.touched[t.key.Hash] || (t.key.Range != "" && u.touched[t.key.Range]) {
+		return nil, nil, validationError("cannot update attribute " + t.key.Hash + ": it is part of the key")
+	}
+	updated, err := u.apply(base)
+	if err != nil {
+		return nil, nil, validationError("Invalid UpdateExpression: " + err.Error())
+	}
+	return updated, u, nil
+}
+
+// touched returns the attributes of an item named by an update
+func touched(it item, u *update) map[string]*dynamodb.AttributeValue {
+	attributes := make(map[string]*dynamodb.AttributeValue)
+	for name := range u.touched {
+		if value, ok := it[name]; ok {
+			attributes[name] = copyValue(value)
+		}
+	}
+	return attributes
+}
+
+// compileCondition parses an optional condition expression
+func compileCondition(expression *string, names map[string]*string, values map[string]*dynamodb.AttributeValue) (condition, error) {
+	if expression == nil || strings.TrimSpace(*expression) == "" {
+		return nil, nil
+	}
+	c, err := parseCondition(*expression, names, values)
+	if err != nil {
+		return nil, validationError("Invalid expression: " + err.Error())
+	}
+	return c, nil
+}
+
+// checkCondition evaluates an optional condition expression on an item,
+// which is nil when it does not exist
+func checkCondition(it item, expression *string, names map[string]*string, values map[string]*dynamodb.AttributeValue) error {
+	c, err := compileCondition(expression, names, values)
+	if err != nil || c == nil {
+		return err
+	}
+	if it == nil {
+		it = item{}
+	}
+	ok, err := c(it)
+	if err != nil {
+		return validationError(err.Error())
+	}
+	if !ok {
+		return &dynamodb.ConditionalCheckFailedException{
+			Message_: aws.String("The conditional request failed"),
+		}
+	}
+	return nil
+}
+
+// isConditionFailure reports whether err is a failed condition
+func isConditionFailure(err error) bool {
+	_, ok := err.(*dynamodb.ConditionalCheckFailedException)
+	return ok
+}
+
+// filterItems returns the items matching an optional filter
+func filterItems(items []item, filter condition) ([]item, error) {
+	if filter == nil {
+		return items, nil
+	}
+	matched := make([]item, 0, len(items))
+	for _, it := range items {
+		ok, err := filter(it)
+		if err != nil {
+			return nil, validationError(err.Error())
+		}
+		if ok {
+			matched = append(matched, it)
+		}
+	}
+	return matched, nil
+}
+
+// compileProjection parses an optional projection expression
+func compileProjection(expression *string, names map[string]*string) ([]path, error) {
+	if expression == nil || strings.TrimSpace(*expression) == "" {
+		return nil, nil
+	}
+	paths, err := parseProjection(*expression, names)
+	if err != nil {
+		return nil, validationError("Invalid ProjectionExpression: " + err.Error())
+	}
+	return paths, nil
+}
+
+// projectItem copies an item, keeping only the projected attributes
+func projectItem(it item, expression *string, names map[string]*string) (item, error) {
+	projection, err := compileProjection(expression, names)
+	if err != nil {
+		return nil, err
+	}
+	return projectItems([]item{it}, projection)[0], nil
+}
+
+// projectItems copies items, keeping only the projected attributes when
+// there is a projection
+func projectItems(items []item, projection []path) []map[string]*dynamodb.AttributeValue {
+	projected := make([]map[string]*dynamodb.AttributeValue, 0, len(items))
+	for _, it := range items {
+		if projection == nil {
+			projected = append(projected, copyItem(it))
+			continue
+		}
+		projected = append(projected, project(it, projection))
+	}
+	return projected
+}
+
+// validationError is the error DynamoDB returns for malformed requests
+func validationError(message string) error {
+	return awserr.New("ValidationException", message, nil)
+}
@@ -0,0 +1,188 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+func str(value string) *dynamodb.AttributeValue {
+	return &dynamodb.AttributeValue{S: aws.String(value)}
+}
+
+func num(value string) *dynamodb.AttributeValue {
+	return &dynamodb.AttributeValue{N: aws.String(value)}
+}
+
+// putVersioned puts po-1 at version under the optimistic locking condition
+// the commands use: the item must not exist, or still be at read
+func putVersioned(d *DynamoDB, status string, read, version int) error {
+	input := &dynamodb.PutItemInput{
+		TableName: aws.String("orden-compra-read"),
+		Item: map[string]*dynamodb.AttributeValue{
+			"id":      str("po-1"),
+			"status":  str(status),
+			"version": num(strconv.Itoa(version)),
+		},
+		ExpressionAttributeNames: map[string]*string{"#version": aws.String("version")},
+	}
+	if read == 0 {
+		input.ConditionExpression = aws.String("attribute_not_exists(#version)")
+	} else {
+		input.ConditionExpression = aws.String("#version = :version")
+		input.ExpressionAttributeValues = map[string]*dynamodb.AttributeValue{":version": num(strconv.Itoa(read))}
+	}
+	_, err := d.PutItemWithContext(context.Background(), input)
+	return err
+}
+
+func TestVersionConditionRejectsStaleWrites(t *testing.T) {
+	d := NewDynamoDB(Tables)
+
+	if err := putVersioned(d, "pending", 0, 1); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	var failed *dynamodb.ConditionalCheckFailedException
+	if err := putVersioned(d, "approved", 0, 1); !errors.As(err, &failed) {
+		t.Fatalf("second create returned %v, want a ConditionalCheckFailedException", err)
+	}
+	if err := putVersioned(d, "approved", 1, 2); err != nil {
+		t.Fatalf("update from version 1: %v", err)
+	}
+	if err := putVersioned(d, "cancelled", 1, 2); !errors.As(err, &failed) {
+		t.Fatalf("stale update returned %v, want a ConditionalCheckFailedException", err)
+	}
+
+	items := d.Items("orden-compra-read")
+	if len(items) != 1 || aws.StringValue(items[0]["status"].S) != "approved" || aws.StringValue(items[0]["version"].N) != "2" {
+		t.Fatalf("items %v, want po-1 approved at version 2", items)
+	}
+}
+
+func TestUpdateItemAddsToCounters(t *testing.T) {
+	d := NewDynamoDB(Tables)
+	add := func(counter, delta string) *dynamodb.UpdateItemOutput {
+		t.Helper()
+		output, err := d.UpdateItemWithContext(context.Background(), &dynamodb.UpdateItemInput{
+			TableName:                 aws.String("orden-compra-stats"),
+			Key:                       map[string]*dynamodb.AttributeValue{"id": str("2026-03-02")},
+			UpdateExpression:          aws.String("ADD #counter :delta"),
+			ExpressionAttributeNames:  map[string]*string{"#counter": aws.String(counter)},
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":delta": num(delta)},
+			ReturnValues:              aws.String(dynamodb.ReturnValueUpdatedNew),
+		})
+		if err != nil {
+			t.Fatalf("ADD %s %s: %v", counter, delta, err)
+		}
+		return output
+	}
+
+	// ADD creates both the item and the counter
+	add("total", "1")
+	add("total", "1")
+	add("status_pending", "1")
+	if output := add("status_pending", "-1"); aws.StringValue(output.Attributes["status_pending"].N) != "0" {
+		t.Fatalf("UPDATED_NEW %v, want status_pending 0", output.Attributes)
+	}
+
+	items := d.Items("orden-compra-stats")
+	if len(items) != 1 {
+		t.Fatalf("items %v, want one bucket", items)
+	}
+	if total := aws.StringValue(items[0]["total"].N); total != "2" {
+		t.Fatalf("total %s, want 2", total)
+	}
+}
+
+// putEvents stores the events of two orders, two of them on 2026-03-02
+func putEvents(t *testing.T, d *DynamoDB) {
+	t.Helper()
+	events := []struct{ id, timestamp, eventType string }{
+		{"po-1", "2026-03-01T09:00:00Z", "PurchaseOrderCreated"},
+		{"po-1", "2026-03-02T09:00:00Z", "PurchaseOrderApproved"},
+		{"po-1", "2026-03-02T10:00:00Z", "PurchaseOrderSent"},
+		{"po-2", "2026-03-02T11:00:00Z", "PurchaseOrderCreated"},
+	}
+	for _, event := range events {
+		_, err := d.PutItemWithContext(context.Background(), &dynamodb.PutItemInput{
+			TableName: aws.String("orden-compra-events"),
+			Item: map[string]*dynamodb.AttributeValue{
+				"id":         str(event.id),
+				"timestamp":  str(event.timestamp),
+				"event_type": str(event.eventType),
+			},
+		})
+		if err != nil {
+			t.Fatalf("put %s event: %v", event.id, err)
+		}
+	}
+}
+
+// eventTypes returns the event_type of every item
+func eventTypes(items []map[string]*dynamodb.AttributeValue) []string {
+	types := make([]string, 0, len(items))
+	for _, item := range items {
+		types = append(types, aws.StringValue(item["event_type"].S))
+	}
+	return types
+}
+
+func TestQueryKeyConditionBeginsWith(t *testing.T) {
+	d := NewDynamoDB(Tables)
+	putEvents(t, d)
+
+	output, err := d.QueryWithContext(context.Background(), &dynamodb.QueryInput{
+		TableName:                 aws.String("orden-compra-events"),
+		KeyConditionExpression:    aws.String("id = :id AND begins_with(#timestamp, :day)"),
+		ExpressionAttributeNames:  map[string]*string{"#timestamp": aws.String("timestamp")},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":id": str("po-1"), ":day": str("2026-03-02")},
+		ScanIndexForward:          aws.Bool(false),
+	})
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	got := eventTypes(output.Items)
+	if len(got) != 2 || got[0] != "PurchaseOrderSent" || got[1] != "PurchaseOrderApproved" {
+		t.Fatalf("events %v, want po-1's events of 2026-03-02 newest first", got)
+	}
+}
+
+func TestFilterExpressionsApplyAfterTheLimit(t *testing.T) {
+	d := NewDynamoDB(Tables)
+	putEvents(t, d)
+
+	scan, err := d.ScanWithContext(context.Background(), &dynamodb.ScanInput{
+		TableName:                 aws.String("orden-compra-events"),
+		FilterExpression:          aws.String("event_type = :type AND begins_with(#timestamp, :day)"),
+		ExpressionAttributeNames:  map[string]*string{"#timestamp": aws.String("timestamp")},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":type": str("PurchaseOrderCreated"), ":day": str("2026-03-02")},
+	})
+	if err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	if aws.Int64Value(scan.Count) != 1 || aws.Int64Value(scan.ScannedCount) != 4 || aws.StringValue(scan.Items[0]["id"].S) != "po-2" {
+		t.Fatalf("scan %v, want po-2's creation out of 4 scanned", scan)
+	}
+
+	// The limit bounds the items read, not the items returned
+	query, err := d.QueryWithContext(context.Background(), &dynamodb.QueryInput{
+		TableName:                 aws.String("orden-compra-events"),
+		KeyConditionExpression:    aws.String("id = :id"),
+		FilterExpression:          aws.String("event_type <> :type"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":id": str("po-1"), ":type": str("PurchaseOrderCreated")},
+		Limit:                     aws.Int64(2),
+	})
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if got := eventTypes(query.Items); len(got) != 1 || got[0] != "PurchaseOrderApproved" {
+		t.Fatalf("first page %v, want only PurchaseOrderApproved", got)
+	}
+	if query.LastEvaluatedKey == nil || aws.StringValue(query.LastEvaluatedKey["timestamp"].S) != "2026-03-02T09:00:00Z" {
+		t.Fatalf("LastEvaluatedKey %v, want the second event read", query.LastEvaluatedKey)
+	}
+}
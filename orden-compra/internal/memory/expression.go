@@ -0,0 +1,1144 @@
+package memory
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// item is a stored DynamoDB item
+type item = map[string]*dynamodb.AttributeValue
+
+// pathElement is one step of a document path: a map key, or a list index
+// when name is empty
+type pathElement struct {
+	name  string
+	index int
+}
+
+// path addresses an attribute, possibly nested in maps and lists
+type path []pathElement
+
+// String renders the path for error messages
+func (p path) String() string {
+	var b strings.Builder
+	for i, element := range p {
+		switch {
+		case element.name == "":
+			fmt.Fprintf(&b, "[%d]", element.index)
+		case i > 0:
+			b.WriteString("." + element.name)
+		default:
+			b.WriteString(element.name)
+		}
+	}
+	return b.String()
+}
+
+// token is a lexical token of an expression
+type token struct {
+	kind  byte // 'i' identifier or keyword, '#' name placeholder, ':' value placeholder, 'n' number, 'p' punctuation
+	text  string
+	start int
+}
+
+// tokenize splits an expression into tokens
+func tokenize(expression string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(expression); {
+		c := rune(expression[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '#' || c == ':' || unicode.IsLetter(c) || c == '_':
+			start := i
+			i++
+			for i < len(expression) && (unicode.IsLetter(rune(expression[i])) || unicode.IsDigit(rune(expression[i])) || expression[i] == '_') {
+				i++
+			}
+			kind := byte('i')
+			if c == '#' || c == ':' {
+				kind = byte(c)
+				if i == start+1 {
+					return nil, fmt.Errorf("empty placeholder at %d", start)
+				}
+			}
+			tokens = append(tokens, token{kind: kind, text: expression[start:i], start: start})
+		case unicode.IsDigit(c):
+			start := i
+			for i < len(expression) && unicode.IsDigit(rune(expression[i])) {
+				i++
+			}
+			tokens = append(tokens, token{kind: 'n', text: expression[start:i], start: start})
+		case strings.HasPrefix(expression[i:], "<>"), strings.HasPrefix(expression[i:], "<="), strings.HasPrefix(expression[i:], ">="):
+			tokens = append(tokens, token{kind: 'p', text: expression[i : i+2], start: i})
+			i += 2
+		case strings.ContainsRune("()[],.=<>+-", c):
+			tokens = append(tokens, token{kind: 'p', text: string(c), start: i})
+			i++
+		default:
+			return nil, fmt.Errorf("unexpected %q at %d", c, i)
+		}
+	}
+	return tokens, nil
+}
+
+// parser reads an expression, resolving its placeholders
+type parser struct {
+	tokens []token
+	pos    int
+	names  map[string]*string
+	values map[string]*dynamodb.AttributeValue
+}
+
+// newParser tokenizes an expression for parsing
+func newParser(expression string, names map[string]*string, values map[string]*dynamodb.AttributeValue) (*parser, error) {
+	tokens, err := tokenize(expression)
+	if err != nil {
+		return nil, err
+	}
+	return &parser{tokens: tokens, names: names, values: values}, nil
+}
+
+// peek returns the next token without consuming it
+func (p *parser) peek() (token, bool) {
+	if p.pos >= len(p.tokens) {
+		return token{}, false
+	}
+	return p.tokens[p.pos], true
+}
+
+// keyword reports whether the next token is the keyword, case-insensitively
+func (p *parser) keyword(keyword string) bool {
+	t, ok := p.peek()
+	return ok && t.kind == 'i' && strings.EqualFold(t.text, keyword)
+}
+
+// punctuation reports whether the next token is the punctuation
+func (p *parser) punctuation(text string) bool {
+	t, ok := p.peek()
+	return ok && t.kind == 'p' && t.text == text
+}
+
+// expect consumes the punctuation or fails
+func (p *parser) expect(text string) error {
+	if !p.punctuation(text) {
+		return p.errorf("expected %q", text)
+	}
+	p.pos++
+	return nil
+}
+
+// done reports whether every token was consumed
+func (p *parser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+// errorf reports a syntax error at the next token
+func (p *parser) errorf(format string, args ...interface{}) error {
+	if t, ok := p.peek(); ok {
+		return fmt.Errorf(format+" at %q (%d)", append(args, t.text, t.start)...)
+	}
+	return fmt.Errorf(format+" at end of expression", args...)
+}
+
+// parsePath reads a document path, resolving name placeholders
+func (p *parser) parsePath() (path, error) {
+	var result path
+	for {
+		t, ok := p.peek()
+		if !ok {
+			return nil, p.errorf("expected attribute name")
+		}
+		switch t.kind {
+		case 'i':
+			result = append(result, pathElement{name: t.text})
+		case '#':
+			name, ok := p.names[t.text]
+			if !ok || name == nil {
+				return nil, fmt.Errorf("expression attribute name %s is not defined", t.text)
+			}
+			result = append(result, pathElement{name: *name})
+		default:
+			return nil, p.errorf("expected attribute name")
+		}
+		p.pos++
+
+		for p.punctuation("[") {
+			p.pos++
+			t, ok := p.peek()
+			if !ok || t.kind != 'n' {
+				return nil, p.errorf("expected list index")
+			}
+			index, _ := strconv.Atoi(t.text)
+			p.pos++
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			result = append(result, pathElement{index: index})
+		}
+		if !p.punctuation(".") {
+			return result, nil
+		}
+		p.pos++
+	}
+}
+
+// value resolves a value placeholder
+func (p *parser) value(placeholder string) (*dynamodb.AttributeValue, error) {
+	value, ok := p.values[placeholder]
+	if !ok || value == nil {
+		return nil, fmt.Errorf("expression attribute value %s is not defined", placeholder)
+	}
+	return value, nil
+}
+
+// condition is a parsed condition, filter or key condition expression
+type condition func(item) (bool, error)
+
+// operand is a parsed operand of a comparison or function
+type operand func(item) *dynamodb.AttributeValue
+
+// parseCondition parses a whole condition expression
+func parseCondition(expression string, names map[string]*string, values map[string]*dynamodb.AttributeValue) (condition, error) {
+	p, err := newParser(expression, names, values)
+	if err != nil {
+		return nil, err
+	}
+	c, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if !p.done() {
+		return nil, p.errorf("unexpected token")
+	}
+	return c, nil
+}
+
+// parseOr parses conditions joined by OR
+func (p *parser) parseOr() (condition, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("OR") {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(it item) (bool, error) {
+			if ok, err := l(it); ok || err != nil {
+				return ok, err
+			}
+			return right(it)
+		}
+	}
+	return left, nil
+}
+
+// parseAnd parses conditions joined by AND
+func (p *parser) parseAnd() (condition, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("AND") {
+		p.pos++
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(it item) (bool, error) {
+			if ok, err := l(it); !ok || err != nil {
+				return ok, err
+			}
+			return right(it)
+		}
+	}
+	return left, nil
+}
+
+// parseNot parses a possibly negated condition
+func (p *parser) parseNot() (condition, error) {
+	if !p.keyword("NOT") {
+		return p.parsePrimary()
+	}
+	p.pos++
+	c, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	return func(it item) (bool, error) {
+		ok, err := c(it)
+		return !ok, err
+	}, nil
+}
+
+// parsePrimary parses a parenthesized condition, a function or a comparison
+func (p *parser) parsePrimary() (condition, error) {
+	if p.punctuation("(") {
+		p.pos++
+		c, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return c, nil
+	}
+
+	t, ok := p.peek()
+	if ok && t.kind == 'i' && p.pos+1 < len(p.tokens) && p.tokens[p.pos+1].text == "(" && !strings.EqualFold(t.text, "size") {
+		return p.parseFunction()
+	}
+
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case p.keyword("BETWEEN"):
+		p.pos++
+		low, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		if !p.keyword("AND") {
+			return nil, p.errorf("expected AND")
+		}
+		p.pos++
+		high, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		return func(it item) (bool, error) {
+			value := left(it)
+			lowCmp, lowOK := compare(value, low(it))
+			highCmp, highOK := compare(value, high(it))
+			return lowOK && highOK && lowCmp >= 0 && highCmp <= 0, nil
+		}, nil
+
+	case p.keyword("IN"):
+		p.pos++
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		var candidates []operand
+		for {
+			candidate, err := p.parseOperand()
+			if err != nil {
+				return nil, err
+			}
+			candidates = append(candidates, candidate)
+			if !p.punctuation(",") {
+				break
+			}
+			p.pos++
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return func(it item) (bool, error) {
+			value := left(it)
+			for _, candidate := range candidates {
+				if equal(value, candidate(it)) {
+					return true, nil
+				}
+			}
+			return false, nil
+		}, nil
+	}
+
+	t, ok = p.peek()
+	if !ok || t.kind != 'p' {
+		return nil, p.errorf("expected comparator")
+	}
+	comparator := t.text
+	switch comparator {
+	case "=", "<>", "<", "<=", ">", ">=":
+	default:
+		return nil, p.errorf("expected comparator")
+	}
+	p.pos++
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	return func(it item) (bool, error) {
+		l, r := left(it), right(it)
+		switch comparator {
+		case "=":
+			return equal(l, r), nil
+		case "<>":
+			// A missing attribute is unequal to every value
+			return l == nil || r == nil || !equal(l, r), nil
+		}
+		cmp, ok := compare(l, r)
+		if !ok {
+			return false, nil
+		}
+		switch comparator {
+		case "<":
+			return cmp < 0, nil
+		case "<=":
+			return cmp <= 0, nil
+		case ">":
+			return cmp > 0, nil
+		default:
+			return cmp >= 0, nil
+		}
+	}, nil
+}
+
+// parseFunction parses a function used as a condition
+func (p *parser) parseFunction() (condition, error) {
+	name := strings.ToLower(p.tokens[p.pos].text)
+	p.pos += 2 // name and (
+
+	target, err := p.parsePath()
+	if err != nil {
+		return nil, err
+	}
+
+	var argument operand
+	switch name {
+	case "attribute_exists", "attribute_not_exists":
+	case "attribute_type", "begins_with", "contains":
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+		if argument, err = p.parseOperand(); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported function %s", name)
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+
+	return func(it item) (bool, error) {
+		value := resolve(it, target)
+		switch name {
+		case "attribute_exists":
+			return value != nil, nil
+		case "attribute_not_exists":
+			return value == nil, nil
+		case "attribute_type":
+			want := argument(it)
+			return value != nil && want != nil && want.S != nil && typeOf(value) == *want.S, nil
+		case "begins_with":
+			prefix := argument(it)
+			switch {
+			case value == nil || prefix == nil:
+				return false, nil
+			case value.S != nil && prefix.S != nil:
+				return strings.HasPrefix(*value.S, *prefix.S), nil
+			case value.B != nil && prefix.B != nil:
+				return bytes.HasPrefix(value.B, prefix.B), nil
+			}
+			return false, nil
+		default:
+			return contains(value, argument(it)), nil
+		}
+	}, nil
+}
+
+// parseOperand parses a path, a value placeholder or size(path)
+func (p *parser) parseOperand() (operand, error) {
+	t, ok := p.peek()
+	if !ok {
+		return nil, p.errorf("expected operand")
+	}
+
+	if t.kind == ':' {
+		value, err := p.value(t.text)
+		if err != nil {
+			return nil, err
+		}
+		p.pos++
+		return func(item) *dynamodb.AttributeValue { return value }, nil
+	}
+
+	if t.kind == 'i' && strings.EqualFold(t.text, "size") && p.pos+1 < len(p.tokens) && p.tokens[p.pos+1].text == "(" {
+		p.pos += 2
+		target, err := p.parsePath()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return func(it item) *dynamodb.AttributeValue {
+			size, ok := sizeOf(resolve(it, target))
+			if !ok {
+				return nil
+			}
+			return &dynamodb.AttributeValue{N: aws.String(strconv.Itoa(size))}
+		}, nil
+	}
+
+	target, err := p.parsePath()
+	if err != nil {
+		return nil, err
+	}
+	return func(it item) *dynamodb.AttributeValue { return resolve(it, target) }, nil
+}
+
+// update is a parsed update expression
+type update struct {
+	set     []setAction
+	remove  []path
+	add     []pathValue
+	delete  []pathValue
+	touched map[string]bool
+}
+
+// setAction assigns the value computed from the item to a path
+type setAction struct {
+	target path
+	value  func(item) (*dynamodb.AttributeValue, error)
+}
+
+// pathValue pairs a path with the value added to or deleted from it
+type pathValue struct {
+	target path
+	value  *dynamodb.AttributeValue
+}
+
+// parseUpdate parses an update expression
+func parseUpdate(expression string, names map[string]*string, values map[string]*dynamodb.AttributeValue) (*update, error) {
+	p, err := newParser(expression, names, values)
+	if err != nil {
+		return nil, err
+	}
+
+	u := &update{touched: make(map[string]bool)}
+	seen := make(map[string]bool)
+	for !p.done() {
+		t, _ := p.peek()
+		clause := strings.ToUpper(t.text)
+		if t.kind != 'i' || (clause != "SET" && clause != "REMOVE" && clause != "ADD" && clause != "DELETE") {
+			return nil, p.errorf("expected SET, REMOVE, ADD or DELETE")
+		}
+		if seen[clause] {
+			return nil, fmt.Errorf("the %s clause appears more than once", clause)
+		}
+		seen[clause] = true
+		p.pos++
+
+		for {
+			target, err := p.parsePath()
+			if err != nil {
+				return nil, err
+			}
+			u.touched[target[0].name] = true
+
+			switch clause {
+			case "SET":
+				if err := p.expect("="); err != nil {
+					return nil, err
+				}
+				value, err := p.parseSetValue()
+				if err != nil {
+					return nil, err
+				}
+				u.set = append(u.set, setAction{target: target, value: value})
+			case "REMOVE":
+				u.remove = append(u.remove, target)
+			default:
+				t, ok := p.peek()
+				if !ok || t.kind != ':' {
+					return nil, p.errorf("expected value placeholder")
+				}
+				value, err := p.value(t.text)
+				if err != nil {
+					return nil, err
+				}
+				p.pos++
+				if clause == "ADD" {
+					u.add = append(u.add, pathValue{target: target, value: value})
+				} else {
+					u.delete = append(u.delete, pathValue{target: target, value: value})
+				}
+			}
+
+			if !p.punctuation(",") {
+				break
+			}
+			p.pos++
+		}
+	}
+	return u, nil
+}
+
+// parseSetValue parses the value of a SET action: an operand, or two joined by + or -
+func (p *parser) parseSetValue() (func(item) (*dynamodb.AttributeValue, error), error) {
+	left, err := p.parseSetOperand()
+	if err != nil {
+		return nil, err
+	}
+	if !p.punctuation("+") && !p.punctuation("-") {
+		return left, nil
+	}
+	operator := p.tokens[p.pos].text
+	p.pos++
+	right, err := p.parseSetOperand()
+	if err != nil {
+		return nil, err
+	}
+	return func(it item) (*dynamodb.AttributeValue, error) {
+		l, err := left(it)
+		if err != nil {
+			return nil, err
+		}
+		r, err := right(it)
+		if err != nil {
+			return nil, err
+		}
+		if l == nil || r == nil || l.N == nil || r.N == nil {
+			return nil, fmt.Errorf("an operand in the update expression has an incorrect data type")
+		}
+		if operator == "-" {
+			return addNumbers(l, negate(r)), nil
+		}
+		return addNumbers(l, r), nil
+	}, nil
+}
+
+// parseSetOperand parses an operand of a SET action, including if_not_exists and list_append
+func (p *parser) parseSetOperand() (func(item) (*dynamodb.AttributeValue, error), error) {
+	t, ok := p.peek()
+	if ok && t.kind == 'i' && p.pos+1 < len(p.tokens) && p.tokens[p.pos+1].text == "(" {
+		name := strings.ToLower(t.text)
+		p.pos += 2
+		switch name {
+		case "if_not_exists":
+			target, err := p.parsePath()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+			fallback, err := p.parseSetOperand()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			return func(it item) (*dynamodb.AttributeValue, error) {
+				if value := resolve(it, target); value != nil {
+					return value, nil
+				}
+				return fallback(it)
+			}, nil
+		case "list_append":
+			first, err := p.parseSetOperand()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+			second, err := p.parseSetOperand()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			return func(it item) (*dynamodb.AttributeValue, error) {
+				a, err := first(it)
+				if err != nil {
+					return nil, err
+				}
+				b, err := second(it)
+				if err != nil {
+					return nil, err
+				}
+				if a == nil || b == nil || a.L == nil || b.L == nil {
+					return nil, fmt.Errorf("list_append takes two lists")
+				}
+				joined := make([]*dynamodb.AttributeValue, 0, len(a.L)+len(b.L))
+				joined = append(joined, a.L...)
+				joined = append(joined, b.L...)
+				return &dynamodb.AttributeValue{L: joined}, nil
+			}, nil
+		default:
+			return nil, fmt.Errorf("unsupported function %s", name)
+		}
+	}
+
+	o, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	return func(it item) (*dynamodb.AttributeValue, error) { return o(it), nil }, nil
+}
+
+// apply runs the update on a copy of it and returns the updated item
+func (u *update) apply(it item) (item, error) {
+	updated := copyItem(it)
+
+	// Every value is computed from the item as it was before the update
+	for _, action := range u.set {
+		value, err := action.value(it)
+		if err != nil {
+			return nil, err
+		}
+		if value == nil {
+			return nil, fmt.Errorf("the update expression refers to %s, which does not exist", action.target)
+		}
+		if err := assign(updated, action.target, copyValue(value)); err != nil {
+			return nil, err
+		}
+	}
+	for _, target := range u.remove {
+		remove(updated, target)
+	}
+	for _, action := range u.add {
+		current := resolve(updated, action.target)
+		var value *dynamodb.AttributeValue
+		switch {
+		case action.value.N != nil && (current == nil || current.N != nil):
+			if current == nil {
+				current = &dynamodb.AttributeValue{N: aws.String("0")}
+			}
+			value = addNumbers(current, action.value)
+		case action.value.SS != nil || action.value.NS != nil || action.value.BS != nil:
+			value = union(current, action.value)
+			if value == nil {
+				return nil, fmt.Errorf("ADD on %s mixes set types", action.target)
+			}
+		default:
+			return nil, fmt.Errorf("ADD on %s needs a number or a set", action.target)
+		}
+		if err := assign(updated, action.target, value); err != nil {
+			return nil, err
+		}
+	}
+	for _, action := range u.delete {
+		current := resolve(updated, action.target)
+		if current == nil {
+			continue
+		}
+		value := difference(current, action.value)
+		if value == nil {
+			remove(updated, action.target)
+			continue
+		}
+		if err := assign(updated, action.target, value); err != nil {
+			return nil, err
+		}
+	}
+	return updated, nil
+}
+
+// parseProjection parses a projection expression into the paths it keeps
+func parseProjection(expression string, names map[string]*string) ([]path, error) {
+	p, err := newParser(expression, names, nil)
+	if err != nil {
+		return nil, err
+	}
+	var paths []path
+	for {
+		target, err := p.parsePath()
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, target)
+		if p.done() {
+			return paths, nil
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// project returns the attributes of the item at the given paths. List
+// elements are kept in order, without the elements between them.
+func project(it item, paths []path) item {
+	projected := make(item)
+	for _, target := range paths {
+		value := resolve(it, target)
+		if value == nil {
+			continue
+		}
+		container := &dynamodb.AttributeValue{M: projected}
+		for i, element := range target {
+			last := i == len(target)-1
+			if element.name == "" {
+				if last {
+					container.L = append(container.L, copyValue(value))
+					break
+				}
+				next := &dynamodb.AttributeValue{}
+				container.L = append(container.L, next)
+				container = next
+				continue
+			}
+			if container.M == nil {
+				container.M = make(item)
+			}
+			if last {
+				container.M[element.name] = copyValue(value)
+				break
+			}
+			next, ok := container.M[element.name]
+			if !ok {
+				next = &dynamodb.AttributeValue{}
+				container.M[element.name] = next
+			}
+			container = next
+		}
+	}
+	return projected
+}
+
+// resolve returns the value at a path, or nil when it does not exist
+func resolve(it item, target path) *dynamodb.AttributeValue {
+	value := &dynamodb.AttributeValue{M: it}
+	for _, element := range target {
+		switch {
+		case value == nil:
+			return nil
+		case element.name != "":
+			if value.M == nil {
+				return nil
+			}
+			value = value.M[element.name]
+		default:
+			if value.L == nil || element.index >= len(value.L) {
+				return nil
+			}
+			value = value.L[element.index]
+		}
+	}
+	return value
+}
+
+// assign sets the value at a path; the maps and lists holding it must exist
+func assign(it item, target path, value *dynamodb.AttributeValue) error {
+	parent := resolve(it, target[:len(target)-1])
+	last := target[len(target)-1]
+	switch {
+	case parent == nil:
+		return fmt.Errorf("the document path %s is invalid for update", target)
+	case last.name != "":
+		if parent.M == nil {
+			return fmt.Errorf("the document path %s is invalid for update", target)
+		}
+		parent.M[last.name] = value
+	case parent.L == nil:
+		return fmt.Errorf("the document path %s is invalid for update", target)
+	case last.index >= len(parent.L):
+		parent.L = append(parent.L, value)
+	default:
+		parent.L[last.index] = value
+	}
+	return nil
+}
+
+// remove deletes the value at a path if it exists
+func remove(it item, target path) {
+	parent := resolve(it, target[:len(target)-1])
+	last := target[len(target)-1]
+	switch {
+	case parent == nil:
+	case last.name != "":
+		delete(parent.M, last.name)
+	case last.index < len(parent.L):
+		parent.L = append(parent.L[:last.index], parent.L[last.index+1:]...)
+	}
+}
+
+// typeOf returns the DynamoDB type descriptor of a value
+func typeOf(value *dynamodb.AttributeValue) string {
+	switch {
+	case value.S != nil:
+		return "S"
+	case value.N != nil:
+		return "N"
+	case value.B != nil:
+		return "B"
+	case value.BOOL != nil:
+		return "BOOL"
+	case value.NULL != nil:
+		return "NULL"
+	case value.SS != nil:
+		return "SS"
+	case value.NS != nil:
+		return "NS"
+	case value.BS != nil:
+		return "BS"
+	case value.L != nil:
+		return "L"
+	default:
+		return "M"
+	}
+}
+
+// number parses a numeric value
+func number(value *dynamodb.AttributeValue) *big.Float {
+	n, _, err := big.ParseFloat(aws.StringValue(value.N), 10, 128, big.ToNearestEven)
+	if err != nil {
+		return new(big.Float)
+	}
+	return n
+}
+
+// addNumbers adds two numeric values
+func addNumbers(a, b *dynamodb.AttributeValue) *dynamodb.AttributeValue {
+	sum := new(big.Float).SetPrec(128).Add(number(a), number(b))
+	return &dynamodb.AttributeValue{N: aws.String(sum.Text('g', 38))}
+}
+
+// negate returns the negation of a numeric value
+func negate(value *dynamodb.AttributeValue) *dynamodb.AttributeValue {
+	return &dynamodb.AttributeValue{N: aws.String(new(big.Float).Neg(number(value)).Text('g', 38))}
+}
+
+// compare orders two scalar values of the same type
+func compare(a, b *dynamodb.AttributeValue) (int, bool) {
+	switch {
+	case a == nil || b == nil:
+		return 0, false
+	case a.S != nil && b.S != nil:
+		return strings.Compare(*a.S, *b.S), true
+	case a.N != nil && b.N != nil:
+		return number(a).Cmp(number(b)), true
+	case a.B != nil && b.B != nil:
+		return bytes.Compare(a.B, b.B), true
+	default:
+		return 0, false
+	}
+}
+
+// equal reports whether two values are equal; numbers compare by value
+func equal(a, b *dynamodb.AttributeValue) bool {
+	if a == nil || b == nil {
+		return false
+	}
+	if cmp, ok := compare(a, b); ok {
+		return cmp == 0
+	}
+	return typeOf(a) == typeOf(b) && reflect.DeepEqual(normalize(a), normalize(b))
+}
+
+// normalize canonicalizes the numbers of a value so DeepEqual compares them by value
+func normalize(value *dynamodb.AttributeValue) *dynamodb.AttributeValue {
+	normalized := copyValue(value)
+	if normalized.N != nil {
+		normalized.N = aws.String(number(normalized).Text('g', 38))
+	}
+	for i, n := range normalized.NS {
+		normalized.NS[i] = aws.String(number(&dynamodb.AttributeValue{N: n}).Text('g', 38))
+	}
+	for i, element := range normalized.L {
+		normalized.L[i] = normalize(element)
+	}
+	for key, element := range normalized.M {
+		normalized.M[key] = normalize(element)
+	}
+	return normalized
+}
+
+// contains implements the contains function on strings, sets and lists
+func contains(value, operand *dynamodb.AttributeValue) bool {
+	if value == nil || operand == nil {
+		return false
+	}
+	switch {
+	case value.S != nil && operand.S != nil:
+		return strings.Contains(*value.S, *operand.S)
+	case value.B != nil && operand.B != nil:
+		return bytes.Contains(value.B, operand.B)
+	case value.SS != nil && operand.S != nil:
+		for _, s := range value.SS {
+			if aws.StringValue(s) == *operand.S {
+				return true
+			}
+		}
+	case value.NS != nil && operand.N != nil:
+		for _, n := range value.NS {
+			if equal(&dynamodb.AttributeValue{N: n}, operand) {
+				return true
+			}
+		}
+	case value.BS != nil && operand.B != nil:
+		for _, b := range value.BS {
+			if bytes.Equal(b, operand.B) {
+				return true
+			}
+		}
+	case value.L != nil:
+		for _, element := range value.L {
+			if equal(element, operand) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sizeOf implements the size function
+func sizeOf(value *dynamodb.AttributeValue) (int, bool) {
+	switch {
+	case value == nil:
+		return 0, false
+	case value.S != nil:
+		return len(*value.S), true
+	case value.B != nil:
+		return len(value.B), true
+	case value.SS != nil:
+		return len(value.SS), true
+	case value.NS != nil:
+		return len(value.NS), true
+	case value.BS != nil:
+		return len(value.BS), true
+	case value.L != nil:
+		return len(value.L), true
+	case value.M != nil:
+		return len(value.M), true
+	default:
+		return 0, false
+	}
+}
+
+// union adds the elements of a set to another of the same type; current may be nil
+func union(current, added *dynamodb.AttributeValue) *dynamodb.AttributeValue {
+	if current == nil {
+		return copyValue(added)
+	}
+	result := copyValue(current)
+	switch {
+	case current.SS != nil && added.SS != nil:
+		for _, s := range added.SS {
+			if !contains(result, &dynamodb.AttributeValue{S: s}) {
+				result.SS = append(result.SS, aws.String(*s))
+			}
+		}
+	case current.NS != nil && added.NS != nil:
+		for _, n := range added.NS {
+			if !contains(result, &dynamodb.AttributeValue{N: n}) {
+				result.NS = append(result.NS, aws.String(*n))
+			}
+		}
+	case current.BS != nil && added.BS != nil:
+		for _, b := range added.BS {
+			if !contains(result, &dynamodb.AttributeValue{B: b}) {
+				result.BS = append(result.BS, append([]byte(nil), b...))
+			}
+		}
+	default:
+		return nil
+	}
+	return result
+}
+
+// difference removes the elements of a set from another; an emptied set is nil
+func difference(current, removed *dynamodb.AttributeValue) *dynamodb.AttributeValue {
+	result := &dynamodb.AttributeValue{}
+	switch {
+	case current.SS != nil:
+		for _, s := range current.SS {
+			if !contains(removed, &dynamodb.AttributeValue{S: s}) {
+				result.SS = append(result.SS, s)
+			}
+		}
+		if len(result.SS) == 0 {
+			return nil
+		}
+	case current.NS != nil:
+		for _, n := range current.NS {
+			if !contains(removed, &dynamodb.AttributeValue{N: n}) {
+				result.NS = append(result.NS, n)
+			}
+		}
+		if len(result.NS) == 0 {
+			return nil
+		}
+	case current.BS != nil:
+		for _, b := range current.BS {
+			if !contains(removed, &dynamodb.AttributeValue{B: b}) {
+				result.BS = append(result.BS, b)
+			}
+		}
+		if len(result.BS) == 0 {
+			return nil
+		}
+	default:
+		return current
+	}
+	return result
+}
+
+// copyItem deep-copies an item so callers cannot change stored data
+func copyItem(it item) item {
+	if it == nil {
+		return nil
+	}
+	copied := make(item, len(it))
+	for name, value := range it {
+		copied[name] = copyValue(value)
+	}
+	return copied
+}
+
+// copyValue deep-copies an attribute value
+func copyValue(value *dynamodb.AttributeValue) *dynamodb.AttributeValue {
+	if value == nil {
+		return nil
+	}
+	copied := &dynamodb.AttributeValue{}
+	if value.S != nil {
+		copied.S = aws.String(*value.S)
+	}
+	if value.N != nil {
+		copied.N = aws.String(*value.N)
+	}
+	if value.B != nil {
+		copied.B = append([]byte(nil), value.B...)
+	}
+	if value.BOOL != nil {
+		copied.BOOL = aws.Bool(*value.BOOL)
+	}
+	if value.NULL != nil {
+		copied.NULL = aws.Bool(*value.NULL)
+	}
+	for _, s := range value.SS {
+		copied.SS = append(copied.SS, aws.String(*s))
+	}
+	for _, n := range value.NS {
+		copied.NS = append(copied.NS, aws.String(*n))
+	}
+	for _, b := range value.BS {
+		copied.BS = append(copied.BS, append([]byte(nil), b...))
+	}
+	if value.L != nil {
+		copied.L = make([]*dynamodb.AttributeValue, len(value.L))
+		for i, element := range value.L {
+			copied.L[i] = copyValue(element)
+		}
+	}
+	if value.M != nil {
+		copied.M = copyItem(value.M)
+	}
+	return copied
+}
@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// Roles granted to API callers
+const (
+	// RoleViewer can call the query endpoints
+	RoleViewer = "viewer"
+	// RoleBuyer can create and cancel purchase orders
+	RoleBuyer = "buyer"
+	// RoleApprover can approve and reject purchase orders
+	RoleApprover = "approver"
+	// RoleAdmin can manage the service, trigger projections and replays
+	RoleAdmin = "admin"
+)
+
+// Roles lists every known role
+var Roles = []string{RoleViewer, RoleBuyer, RoleApprover, RoleAdmin}
+
+// IsValidRole reports whether role is a known role
+func IsValidRole(role string) bool {
+	for _, known := range Roles {
+		if role == known {
+			return true
+		}
+	}
+	return false
+}
+
+// AccessPolicy overrides the roles of a subject, either a JWT subject or an
+// API key client name. When present it replaces the roles from the token.
+type AccessPolicy struct {
+	Subject   string    `json:"subject" dynamodbav:"subject"`
+	Roles     []string  `json:"roles" dynamodbav:"roles"`
+	UpdatedBy string    `json:"updated_by,omitempty" dynamodbav:"updated_by,omitempty"`
+	UpdatedAt time.Time `json:"updated_at" dynamodbav:"updated_at"`
+}
+
+// NewAccessPolicy creates an AccessPolicy
+func NewAccessPolicy(subject string, roles []string, updatedBy string, now time.Time) *AccessPolicy {
+	return &AccessPolicy{
+		Subject:   subject,
+		Roles:     roles,
+		UpdatedBy: updatedBy,
+		UpdatedAt: now.UTC(),
+	}
+}
@@ -0,0 +1,16 @@
+package models
+
+import "testing"
+
+func TestIsValidRole(t *testing.T) {
+	for _, role := range Roles {
+		if !IsValidRole(role) {
+			t.Fatalf("%s is not valid", role)
+		}
+	}
+	for _, role := range []string{"", "Admin", "superuser"} {
+		if IsValidRole(role) {
+			t.Fatalf("%q is valid", role)
+		}
+	}
+}
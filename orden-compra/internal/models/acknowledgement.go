@@ -0,0 +1,50 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// Supplier acknowledgement statuses of a purchase order
+const (
+	AcknowledgementAccepted = "accepted"
+	// AcknowledgementChanged means the supplier accepted with a different quantity or date
+	AcknowledgementChanged  = "accepted_with_changes"
+	AcknowledgementRejected = "rejected"
+)
+
+// PurchaseOrderAcknowledgedEventType is recorded when the supplier acknowledges an order
+const PurchaseOrderAcknowledgedEventType = "PurchaseOrderAcknowledged"
+
+// OrderAcknowledgement is a supplier's answer to a purchase order, such as an
+// EDI 855 purchase order acknowledgement
+type OrderAcknowledgement struct {
+	Status string `json:"status" dynamodbav:"status"`
+	// AcknowledgedQuantity is the quantity the supplier will ship, when given
+	AcknowledgedQuantity int `json:"acknowledged_quantity,omitempty" dynamodbav:"acknowledged_quantity,omitempty"`
+	// PromisedDate is the delivery date the supplier committed to, when given
+	PromisedDate *time.Time `json:"promised_date,omitempty" dynamodbav:"promised_date,omitempty"`
+	// Reference is the supplier's own order number
+	Reference  string    `json:"reference,omitempty" dynamodbav:"reference,omitempty"`
+	Note       string    `json:"note,omitempty" dynamodbav:"note,omitempty"`
+	ReceivedAt time.Time `json:"received_at" dynamodbav:"received_at"`
+}
+
+// Validate checks the acknowledgement for a known status and sane values
+func (a *OrderAcknowledgement) Validate() error {
+	var errs ValidationErrors
+
+	switch a.Status {
+	case AcknowledgementAccepted, AcknowledgementChanged, AcknowledgementRejected:
+	default:
+		errs.add("status", fmt.Sprintf("must be one of %s, %s or %s", AcknowledgementAccepted, AcknowledgementChanged, AcknowledgementRejected))
+	}
+	if a.AcknowledgedQuantity < 0 {
+		errs.add("acknowledged_quantity", fmt.Sprintf("must not be negative, got %d", a.AcknowledgedQuantity))
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package models
+
+// Approval metadata keys recorded on purchase orders
+const (
+	MetadataApprovalReason  = "approval_reason"
+	MetadataApprovedBy      = "approved_by"
+	MetadataApprovedAt      = "approved_at"
+	MetadataRejectedBy      = "rejected_by"
+	MetadataRejectedAt      = "rejected_at"
+	MetadataApprovalComment = "approval_comment"
+)
+
+// ApprovalPolicy decides which purchase orders need a manual approval
+type ApprovalPolicy struct {
+	// QuantityThreshold is the quantity above which an order needs approval; 0 disables the check
+	QuantityThreshold int
+	// RequireForCritical sends every critical-urgency order to approval
+	RequireForCritical bool
+}
+
+// RequiresApproval returns whether the order needs approval and why
+func (p ApprovalPolicy) RequiresApproval(po *PurchaseOrder) (bool, string) {
+	if p.RequireForCritical && po.UrgencyLevel == "critical" {
+		return true, "critical urgency"
+	}
+	if p.QuantityThreshold > 0 && po.Quantity > p.QuantityThreshold {
+		return true, "quantity above approval threshold"
+	}
+	return false, ""
+}
@@ -0,0 +1,28 @@
+package models
+
+import "testing"
+
+func TestRequiresApproval(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		policy   ApprovalPolicy
+		urgency  string
+		quantity int
+		want     bool
+		reason   string
+	}{
+		{"no policy", ApprovalPolicy{}, "critical", 1000, false, ""},
+		{"critical order", ApprovalPolicy{RequireForCritical: true}, "critical", 1, true, "critical urgency"},
+		{"high urgency is not critical", ApprovalPolicy{RequireForCritical: true}, "high", 1, false, ""},
+		{"quantity at the threshold", ApprovalPolicy{QuantityThreshold: 100}, "low", 100, false, ""},
+		{"quantity above the threshold", ApprovalPolicy{QuantityThreshold: 100}, "low", 101, true, "quantity above approval threshold"},
+		{"critical urgency is reported first", ApprovalPolicy{QuantityThreshold: 100, RequireForCritical: true}, "critical", 500, true, "critical urgency"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			po := &PurchaseOrder{UrgencyLevel: tc.urgency, Quantity: tc.quantity}
+			if got, reason := tc.policy.RequiresApproval(po); got != tc.want || reason != tc.reason {
+				t.Fatalf("RequiresApproval = %t, %q; want %t, %q", got, reason, tc.want, tc.reason)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AdvanceShipmentNoticeEventType is the type of the ASN event sent to Proveedor
+const AdvanceShipmentNoticeEventType EventType = "AdvanceShipmentNotice"
+
+// PurchaseOrderShipmentNotifiedEventType is recorded when a supplier's ASN is attached to an order
+const PurchaseOrderShipmentNotifiedEventType = "PurchaseOrderShipmentNotified"
+
+// ShipmentLot is a lot the supplier announced in an advance shipment notice
+type ShipmentLot struct {
+	BatchNumber string     `json:"batch_number" dynamodbav:"batch_number"`
+	Quantity    int        `json:"quantity" dynamodbav:"quantity"`
+	ExpiryDate  *time.Time `json:"expiry_date,omitempty" dynamodbav:"expiry_date,omitempty"`
+}
+
+// AdvanceShipmentNotice is a supplier's notice that a purchase order has shipped
+type AdvanceShipmentNotice struct {
+	ID                   string        `json:"id" dynamodbav:"id"`
+	SupplierID           string        `json:"supplier_id,omitempty" dynamodbav:"supplier_id,omitempty"`
+	ExpectedDeliveryDate time.Time     `json:"expected_delivery_date" dynamodbav:"expected_delivery_date"`
+	Carrier              string        `json:"carrier" dynamodbav:"carrier"`
+	TrackingNumber       string        `json:"tracking_number" dynamodbav:"tracking_number"`
+	Lots                 []ShipmentLot `json:"lots,omitempty" dynamodbav:"lots,omitempty"`
+	ReceivedAt           time.Time     `json:"received_at" dynamodbav:"received_at"`
+}
+
+// Validate checks the notice for required fields and sane values
+func (n *AdvanceShipmentNotice) Validate() error {
+	var errs ValidationErrors
+
+	if n.ExpectedDeliveryDate.IsZero() {
+		errs.add("expected_delivery_date", "is required")
+	}
+	if strings.TrimSpace(n.Carrier) == "" {
+		errs.add("carrier", "is required")
+	}
+	if strings.TrimSpace(n.TrackingNumber) == "" {
+		errs.add("tracking_number", "is required")
+	}
+	for i, lot := range n.Lots {
+		if strings.TrimSpace(lot.BatchNumber) == "" {
+			errs.add(fmt.Sprintf("lots[%d].batch_number", i), "is required")
+		}
+		if lot.Quantity <= 0 {
+			errs.add(fmt.Sprintf("lots[%d].quantity", i), fmt.Sprintf("must be positive, got %d", lot.Quantity))
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// ShippedQuantity returns the total quantity of the announced lots
+func (n *AdvanceShipmentNotice) ShippedQuantity() int {
+	total := 0
+	for _, lot := range n.Lots {
+		total += lot.Quantity
+	}
+	return total
+}
+
+// AdvanceShipmentNoticeEvent tells Proveedor what is coming for a purchase order
+type AdvanceShipmentNoticeEvent struct {
+	ID                   string                 `json:"id" dynamodbav:"id"`
+	TenantID             string                 `json:"tenant_id,omitempty" dynamodbav:"tenant_id,omitempty"`
+	Timestamp            time.Time              `json:"timestamp" dynamodbav:"timestamp"`
+	EventType            EventType              `json:"event_type" dynamodbav:"event_type"`
+	ASNID                string                 `json:"asn_id" dynamodbav:"asn_id"`
+	PurchaseOrderID      string                 `json:"purchase_order_id" dynamodbav:"purchase_order_id"`
+	ProductID            string                 `json:"product_id" dynamodbav:"product_id"`
+	SupplierID           string                 `json:"supplier_id" dynamodbav:"supplier_id"`
+	Quantity             int                    `json:"quantity" dynamodbav:"quantity"`
+	ExpectedDeliveryDate time.Time              `json:"expected_delivery_date" dynamodbav:"expected_delivery_date"`
+	Carrier              string                 `json:"carrier" dynamodbav:"carrier"`
+	TrackingNumber       string                 `json:"tracking_number" dynamodbav:"tracking_number"`
+	Lots                 []ShipmentLot          `json:"lots,omitempty" dynamodbav:"lots,omitempty"`
+	Metadata             map[string]interface{} `json:"metadata" dynamodbav:"metadata"`
+}
+
+// NewAdvanceShipmentNoticeEvent creates the ASN event for the notice attached to purchaseOrder
+func NewAdvanceShipmentNoticeEvent(purchaseOrder *PurchaseOrder, notice *AdvanceShipmentNotice, now time.Time) *AdvanceShipmentNoticeEvent {
+	return &AdvanceShipmentNoticeEvent{
+		ID:                   uuid.New().String(),
+		TenantID:             purchaseOrder.TenantID,
+		Timestamp:            now.UTC(),
+		EventType:            AdvanceShipmentNoticeEventType,
+		ASNID:                notice.ID,
+		PurchaseOrderID:      purchaseOrder.ID,
+		ProductID:            purchaseOrder.ProductID,
+		SupplierID:           purchaseOrder.SupplierID,
+		Quantity:             purchaseOrder.Quantity,
+		ExpectedDeliveryDate: notice.ExpectedDeliveryDate,
+		Carrier:              notice.Carrier,
+		TrackingNumber:       notice.TrackingNumber,
+		Lots:                 notice.Lots,
+		Metadata:             make(map[string]interface{}),
+	}
+}
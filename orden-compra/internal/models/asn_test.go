@@ -0,0 +1,66 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAdvanceShipmentNoticeValidate(t *testing.T) {
+	valid := func() AdvanceShipmentNotice {
+		return AdvanceShipmentNotice{
+			ExpectedDeliveryDate: time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC),
+			Carrier:              "DHL",
+			TrackingNumber:       "TRACK-1",
+			Lots:                 []ShipmentLot{{BatchNumber: "LOT-1", Quantity: 10}},
+		}
+	}
+
+	for _, tc := range []struct {
+		name   string
+		change func(*AdvanceShipmentNotice)
+		fields []string
+	}{
+		{"valid", func(*AdvanceShipmentNotice) {}, nil},
+		{"no lots", func(n *AdvanceShipmentNotice) { n.Lots = nil }, nil},
+		{"missing delivery date", func(n *AdvanceShipmentNotice) { n.ExpectedDeliveryDate = time.Time{} }, []string{"expected_delivery_date"}},
+		{"blank carrier and tracking number", func(n *AdvanceShipmentNotice) { n.Carrier, n.TrackingNumber = " ", "" }, []string{"carrier", "tracking_number"}},
+		{"invalid lot", func(n *AdvanceShipmentNotice) { n.Lots = append(n.Lots, ShipmentLot{Quantity: 0}) }, []string{"lots[1].batch_number", "lots[1].quantity"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			notice := valid()
+			tc.change(&notice)
+			err := notice.Validate()
+			if tc.fields == nil {
+				if err != nil {
+					t.Fatalf("Validate returned %v", err)
+				}
+				return
+			}
+
+			var errs ValidationErrors
+			if !errors.As(err, &errs) || len(errs) != len(tc.fields) {
+				t.Fatalf("Validate returned %v, want errors on %v", err, tc.fields)
+			}
+			for i, field := range tc.fields {
+				if errs[i].Field != field {
+					t.Fatalf("error %d is %+v, want one on %s", i, errs[i], field)
+				}
+			}
+		})
+	}
+}
+
+func TestNewAdvanceShipmentNoticeEvent(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	purchaseOrder := &PurchaseOrder{ID: "po-1", TenantID: "tenant-1", ProductID: "product-1", SupplierID: "supplier-1", Quantity: 30}
+	notice := &AdvanceShipmentNotice{ID: "asn-1", Carrier: "DHL", TrackingNumber: "TRACK-1", Lots: []ShipmentLot{{BatchNumber: "LOT-1", Quantity: 10}, {BatchNumber: "LOT-2", Quantity: 20}}}
+
+	event := NewAdvanceShipmentNoticeEvent(purchaseOrder, notice, now)
+	if event.EventType != AdvanceShipmentNoticeEventType || event.ASNID != "asn-1" || event.PurchaseOrderID != "po-1" || event.TenantID != "tenant-1" || event.Quantity != 30 || len(event.Lots) != 2 || event.Metadata == nil {
+		t.Fatalf("event %+v", event)
+	}
+	if shipped := notice.ShippedQuantity(); shipped != 30 {
+		t.Fatalf("shipped %d, want 30", shipped)
+	}
+}
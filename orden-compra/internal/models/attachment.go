@@ -0,0 +1,91 @@
+package models
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+)
+
+// Events recorded when buyers annotate a purchase order
+const (
+	PurchaseOrderCommentAddedEventType    = "PurchaseOrderCommentAdded"
+	PurchaseOrderAttachmentAddedEventType = "PurchaseOrderAttachmentAdded"
+)
+
+// Bounds of purchase order comments and attachments
+const (
+	MaxCommentLength     = 4000
+	MaxAttachmentSize    = 25 << 20
+	MaxOrderAttachments  = 50
+	maxAttachmentNameLen = 255
+)
+
+// OrderComment is a note a buyer added to a purchase order
+type OrderComment struct {
+	ID        string    `json:"id" dynamodbav:"id"`
+	Text      string    `json:"text" dynamodbav:"text"`
+	Author    string    `json:"author,omitempty" dynamodbav:"author,omitempty"`
+	CreatedAt time.Time `json:"created_at" dynamodbav:"created_at"`
+}
+
+// Validate checks the comment has text of a sane length
+func (c *OrderComment) Validate() error {
+	var errs ValidationErrors
+
+	if strings.TrimSpace(c.Text) == "" {
+		errs.add("text", "is required")
+	} else if len(c.Text) > MaxCommentLength {
+		errs.add("text", fmt.Sprintf("must be at most %d characters, got %d", MaxCommentLength, len(c.Text)))
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// OrderAttachment describes a file attached to a purchase order. The file
+// itself is uploaded to object storage under Key; the order only holds its
+// metadata.
+type OrderAttachment struct {
+	ID          string `json:"id" dynamodbav:"id"`
+	FileName    string `json:"file_name" dynamodbav:"file_name"`
+	ContentType string `json:"content_type" dynamodbav:"content_type"`
+	// Size is the size in bytes the uploader declared
+	Size        int64  `json:"size" dynamodbav:"size"`
+	Description string `json:"description,omitempty" dynamodbav:"description,omitempty"`
+	// Key is the object storage key the file is uploaded to
+	Key        string    `json:"key" dynamodbav:"key"`
+	UploadedBy string    `json:"uploaded_by,omitempty" dynamodbav:"uploaded_by,omitempty"`
+	CreatedAt  time.Time `json:"created_at" dynamodbav:"created_at"`
+}
+
+// Validate checks the attachment for a plain file name, a content type and a
+// size within MaxAttachmentSize
+func (a *OrderAttachment) Validate() error {
+	var errs ValidationErrors
+
+	switch name := strings.TrimSpace(a.FileName); {
+	case name == "":
+		errs.add("file_name", "is required")
+	case len(name) > maxAttachmentNameLen:
+		errs.add("file_name", fmt.Sprintf("must be at most %d characters", maxAttachmentNameLen))
+	case path.Base(name) != name || name == "." || name == "..":
+		errs.add("file_name", "must be a file name without a path")
+	}
+	if strings.TrimSpace(a.ContentType) == "" {
+		errs.add("content_type", "is required")
+	}
+	if a.Size <= 0 || a.Size > MaxAttachmentSize {
+		errs.add("size", fmt.Sprintf("must be between 1 and %d bytes, got %d", MaxAttachmentSize, a.Size))
+	}
+	if len(a.Description) > MaxCommentLength {
+		errs.add("description", fmt.Sprintf("must be at most %d characters", MaxCommentLength))
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
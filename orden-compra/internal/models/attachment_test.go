@@ -0,0 +1,62 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestOrderCommentValidate(t *testing.T) {
+	for _, tc := range []struct {
+		text  string
+		valid bool
+	}{
+		{"Supplier confirmed by phone", true},
+		{"  ", false},
+		{strings.Repeat("a", MaxCommentLength), true},
+		{strings.Repeat("a", MaxCommentLength+1), false},
+	} {
+		err := (&OrderComment{Text: tc.text}).Validate()
+		var validationErrors ValidationErrors
+		if tc.valid != (err == nil) || (err != nil && (!errors.As(err, &validationErrors) || validationErrors[0].Field != "text")) {
+			t.Errorf("comment of %d characters returned %v", len(tc.text), err)
+		}
+	}
+}
+
+func TestOrderAttachmentValidate(t *testing.T) {
+	valid := func() OrderAttachment {
+		return OrderAttachment{FileName: "invoice.pdf", ContentType: "application/pdf", Size: 1024}
+	}
+	for _, tc := range []struct {
+		name   string
+		modify func(a *OrderAttachment)
+		field  string
+	}{
+		{"valid", func(a *OrderAttachment) {}, ""},
+		{"no file name", func(a *OrderAttachment) { a.FileName = " " }, "file_name"},
+		{"path", func(a *OrderAttachment) { a.FileName = "../invoice.pdf" }, "file_name"},
+		{"parent directory", func(a *OrderAttachment) { a.FileName = ".." }, "file_name"},
+		{"long file name", func(a *OrderAttachment) { a.FileName = strings.Repeat("a", 256) }, "file_name"},
+		{"no content type", func(a *OrderAttachment) { a.ContentType = "" }, "content_type"},
+		{"empty file", func(a *OrderAttachment) { a.Size = 0 }, "size"},
+		{"too large", func(a *OrderAttachment) { a.Size = MaxAttachmentSize + 1 }, "size"},
+		{"long description", func(a *OrderAttachment) { a.Description = strings.Repeat("a", MaxCommentLength+1) }, "description"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			attachment := valid()
+			tc.modify(&attachment)
+			err := attachment.Validate()
+			if tc.field == "" {
+				if err != nil {
+					t.Fatalf("Validate() = %v", err)
+				}
+				return
+			}
+			var validationErrors ValidationErrors
+			if !errors.As(err, &validationErrors) || len(validationErrors) != 1 || validationErrors[0].Field != tc.field {
+				t.Fatalf("Validate() = %v, want an error on %s", err, tc.field)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Audited actions
+const (
+	AuditPurchaseOrderCreated       = "purchase_order.created"
+	AuditPurchaseOrderDuplicate     = "purchase_order.duplicate_attached"
+	AuditPurchaseOrderCancelled     = "purchase_order.cancelled"
+	AuditPurchaseOrderApproved      = "purchase_order.approved"
+	AuditPurchaseOrderRejected      = "purchase_order.rejected"
+	AuditPurchaseOrderOverdue       = "purchase_order.overdue"
+	AuditPurchaseOrderShipped       = "purchase_order.shipment_notified"
+	AuditPurchaseOrderAcknowledged  = "purchase_order.acknowledged"
+	AuditPurchaseOrderCompleted     = "purchase_order.completed"
+	AuditPurchaseOrderReceived      = "purchase_order.received"
+	AuditPurchaseOrderStatusUpdated = "purchase_order.status_updated"
+	AuditPurchaseOrdersConsolidated = "purchase_order.consolidated"
+	AuditPurchaseOrderReconciled    = "purchase_order.reconciled"
+	AuditPurchaseOrderCommented     = "purchase_order.commented"
+	AuditPurchaseOrderAttached      = "purchase_order.attachment_added"
+	AuditPurchaseOrderInvoiced      = "purchase_order.invoiced"
+	AuditWebhookSubscriptionCreated = "webhook_subscription.created"
+	AuditWebhookSubscriptionDeleted = "webhook_subscription.deleted"
+	AuditAccessPolicyUpdated        = "access_policy.updated"
+	AuditAccessPolicyDeleted        = "access_policy.deleted"
+	AuditDependencyLimitUpdated     = "dependency_limit.updated"
+	AuditLogLevelUpdated            = "log_level.updated"
+	AuditConsumerUpdated            = "consumer.updated"
+	AuditConsumerPaused             = "consumer.paused"
+	AuditConsumerResumed            = "consumer.resumed"
+	AuditStatisticsRecomputed       = "statistics.recomputed"
+	AuditEventsRestored             = "events.restored"
+	AuditReorderScheduled           = "reorder.scheduled"
+	AuditSupplierImported           = "supplier.imported"
+	AuditSupplierUpdated            = "supplier.updated"
+	AuditStandingOrderCreated       = "standing_order.created"
+	AuditStandingOrderUpdated       = "standing_order.updated"
+	AuditStandingOrderReleased      = "standing_order.released"
+	AuditReorderSuggestionApplied   = "reorder_policy.suggestion_applied"
+	AuditDeadLetterReplayed         = "dead_letter.replayed"
+	AuditDeadLetterDiscarded        = "dead_letter.discarded"
+	AuditPurchaseOrderERPExported   = "purchase_order.erp_exported"
+	AuditERPDeliveryReplayed        = "erp_delivery.replayed"
+)
+
+// Audited resource types
+const (
+	AuditResourcePurchaseOrder             = "purchase_order"
+	AuditResourceConsolidatedPurchaseOrder = "consolidated_purchase_order"
+	AuditResourceWebhookSubscription       = "webhook_subscription"
+	AuditResourceAccessPolicy              = "access_policy"
+	AuditResourceDependencyLimit           = "dependency_limit"
+	AuditResourceLogLevel                  = "log_level"
+	AuditResourceConsumer                  = "consumer"
+	AuditResourceStatistics                = "statistics"
+	AuditResourceEventArchive              = "event_archive"
+	AuditResourceStockLowEvent             = "stock_low_event"
+	AuditResourceSupplier                  = "supplier"
+	AuditResourceStandingOrder             = "standing_order"
+	AuditResourceReorderPolicy             = "reorder_policy"
+	AuditResourceDeadLetter                = "dead_letter"
+	AuditResourceERPDelivery               = "erp_delivery"
+)
+
+// Kinds of actor executing an operation
+const (
+	AuditActorUser      = "user"
+	AuditActorAPIClient = "api_client"
+	AuditActorMessage   = "message"
+	AuditActorSystem    = "system"
+	AuditActorAnonymous = "anonymous"
+)
+
+// Audit outcomes
+const (
+	AuditOutcomeSuccess = "success"
+	AuditOutcomeFailure = "failure"
+)
+
+// AuditEntry records who executed a state-changing operation and the state of
+// the resource before and after it. Entries are never updated or deleted.
+type AuditEntry struct {
+	ID            string                 `json:"id" dynamodbav:"id"`
+	TenantID      string                 `json:"tenant_id,omitempty" dynamodbav:"tenant_id,omitempty"`
+	Timestamp     time.Time              `json:"timestamp" dynamodbav:"timestamp"`
+	Action        string                 `json:"action" dynamodbav:"action"`
+	ResourceType  string                 `json:"resource_type" dynamodbav:"resource_type"`
+	ResourceID    string                 `json:"resource_id" dynamodbav:"resource_id"`
+	ActorID       string                 `json:"actor_id" dynamodbav:"actor_id"`
+	ActorType     string                 `json:"actor_type" dynamodbav:"actor_type"`
+	Source        string                 `json:"source" dynamodbav:"source"`
+	IPAddress     string                 `json:"ip_address,omitempty" dynamodbav:"ip_address,omitempty"`
+	MessageID     string                 `json:"message_id,omitempty" dynamodbav:"message_id,omitempty"`
+	RequestID     string                 `json:"request_id,omitempty" dynamodbav:"request_id,omitempty"`
+	CorrelationID string                 `json:"correlation_id,omitempty" dynamodbav:"correlation_id,omitempty"`
+	Outcome       string                 `json:"outcome" dynamodbav:"outcome"`
+	Error         string                 `json:"error,omitempty" dynamodbav:"error,omitempty"`
+	Before        map[string]interface{} `json:"before,omitempty" dynamodbav:"before,omitempty"`
+	After         map[string]interface{} `json:"after,omitempty" dynamodbav:"after,omitempty"`
+}
+
+// NewAuditEntry creates a successful AuditEntry for an action on a resource
+func NewAuditEntry(action, resourceType, resourceID string, now time.Time) *AuditEntry {
+	return &AuditEntry{
+		ID:           uuid.New().String(),
+		Timestamp:    now.UTC(),
+		Action:       action,
+		ResourceType: resourceType,
+		ResourceID:   resourceID,
+		Outcome:      AuditOutcomeSuccess,
+	}
+}
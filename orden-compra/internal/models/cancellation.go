@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PurchaseOrderCancelledEventType is the type of the cancellation event sent to Proveedor
+const PurchaseOrderCancelledEventType EventType = "PurchaseOrderCancelled"
+
+// ReceptionCancelledType is the command type Proveedor expects on cancellations
+const ReceptionCancelledType = "RecepcionProveedorCancelled"
+
+// PurchaseOrderCancelledEvent tells Proveedor to stop any reception for the order
+type PurchaseOrderCancelledEvent struct {
+	ID              string                 `json:"id" dynamodbav:"id"`
+	TenantID        string                 `json:"tenant_id,omitempty" dynamodbav:"tenant_id,omitempty"`
+	Timestamp       time.Time              `json:"timestamp" dynamodbav:"timestamp"`
+	EventType       EventType              `json:"event_type" dynamodbav:"event_type"`
+	Type            string                 `json:"type" dynamodbav:"type"`
+	PurchaseOrderID string                 `json:"purchase_order_id" dynamodbav:"purchase_order_id"`
+	ProductID       string                 `json:"product_id" dynamodbav:"product_id"`
+	SupplierID      string                 `json:"supplier_id" dynamodbav:"supplier_id"`
+	PreviousStatus  string                 `json:"previous_status" dynamodbav:"previous_status"`
+	Reason          string                 `json:"reason" dynamodbav:"reason"`
+	Metadata        map[string]interface{} `json:"metadata" dynamodbav:"metadata"`
+}
+
+// NewPurchaseOrderCancelledEvent creates a new PurchaseOrderCancelledEvent
+func NewPurchaseOrderCancelledEvent(purchaseOrder *PurchaseOrder, previousStatus, reason string, now time.Time) *PurchaseOrderCancelledEvent {
+	return &PurchaseOrderCancelledEvent{
+		ID:              uuid.New().String(),
+		TenantID:        purchaseOrder.TenantID,
+		Timestamp:       now.UTC(),
+		EventType:       PurchaseOrderCancelledEventType,
+		Type:            ReceptionCancelledType,
+		PurchaseOrderID: purchaseOrder.ID,
+		ProductID:       purchaseOrder.ProductID,
+		SupplierID:      purchaseOrder.SupplierID,
+		PreviousStatus:  previousStatus,
+		Reason:          reason,
+		Metadata:        make(map[string]interface{}),
+	}
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// ReceptionCreatedType is the command type Proveedor expects on new receptions
+const ReceptionCreatedType = "RecepcionProveedorCreated"
+
+// MarshalLegacyJSON emits the canonical field names together with the Spanish
+// aliases read by older Proveedor versions during the deprecation window
+func (r RecepcionProveedorEvent) MarshalLegacyJSON() ([]byte, error) {
+	type canonical RecepcionProveedorEvent
+	return json.Marshal(struct {
+		canonical
+		Type           string    `json:"type"`
+		ProductoID     string    `json:"producto_id"`
+		Cantidad       int       `json:"cantidad"`
+		ProveedorID    string    `json:"proveedor_id"`
+		Estado         string    `json:"estado"`
+		FechaRecepcion time.Time `json:"fecha_recepcion"`
+	}{
+		canonical:      canonical(r),
+		Type:           ReceptionCreatedType,
+		ProductoID:     r.ProductID,
+		Cantidad:       r.Quantity,
+		ProveedorID:    r.SupplierID,
+		Estado:         r.Status,
+		FechaRecepcion: r.Timestamp,
+	})
+}
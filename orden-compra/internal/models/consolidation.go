@@ -0,0 +1,87 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Consolidation metadata keys recorded on purchase orders
+const (
+	MetadataAwaitingConsolidation = "awaiting_consolidation"
+	MetadataConsolidatedOrderID   = "consolidated_order_id"
+)
+
+// ConsolidatedPurchaseOrderCreatedType is the event recorded when orders are consolidated
+const ConsolidatedPurchaseOrderCreatedType = "ConsolidatedPurchaseOrderCreated"
+
+// ConsolidatedOrderLine is one purchase order inside a consolidated order
+type ConsolidatedOrderLine struct {
+	PurchaseOrderID string `json:"purchase_order_id" dynamodbav:"purchase_order_id"`
+	ProductID       string `json:"product_id" dynamodbav:"product_id"`
+	ProductName     string `json:"product_name" dynamodbav:"product_name"`
+	Quantity        int    `json:"quantity" dynamodbav:"quantity"`
+	Location        string `json:"location" dynamodbav:"location"`
+	UrgencyLevel    string `json:"urgency_level" dynamodbav:"urgency_level"`
+}
+
+// ConsolidatedPurchaseOrder batches pending purchase orders for one supplier
+// into a single multi-line order
+type ConsolidatedPurchaseOrder struct {
+	ID           string                  `json:"id" dynamodbav:"id"`
+	TenantID     string                  `json:"tenant_id,omitempty" dynamodbav:"tenant_id,omitempty"`
+	SupplierID   string                  `json:"supplier_id" dynamodbav:"supplier_id"`
+	SupplierName string                  `json:"supplier_name" dynamodbav:"supplier_name"`
+	Lines        []ConsolidatedOrderLine `json:"lines" dynamodbav:"lines"`
+	Status       string                  `json:"status" dynamodbav:"status"`
+	WindowStart  time.Time               `json:"window_start" dynamodbav:"window_start"`
+	WindowEnd    time.Time               `json:"window_end" dynamodbav:"window_end"`
+	CreatedAt    time.Time               `json:"created_at" dynamodbav:"created_at"`
+	Metadata     map[string]interface{}  `json:"metadata" dynamodbav:"metadata"`
+}
+
+// NewConsolidatedPurchaseOrder creates a consolidated order from purchase orders of one supplier
+func NewConsolidatedPurchaseOrder(supplierID, supplierName string, windowStart, windowEnd time.Time, orders []*PurchaseOrder, now time.Time) *ConsolidatedPurchaseOrder {
+	consolidated := &ConsolidatedPurchaseOrder{
+		ID:           uuid.New().String(),
+		SupplierID:   supplierID,
+		SupplierName: supplierName,
+		Status:       StatusPending,
+		WindowStart:  windowStart,
+		WindowEnd:    windowEnd,
+		CreatedAt:    now.UTC(),
+		Metadata:     make(map[string]interface{}),
+	}
+
+	if len(orders) > 0 {
+		consolidated.TenantID = orders[0].TenantID
+	}
+
+	for _, po := range orders {
+		consolidated.Lines = append(consolidated.Lines, ConsolidatedOrderLine{
+			PurchaseOrderID: po.ID,
+			ProductID:       po.ProductID,
+			ProductName:     po.ProductName,
+			Quantity:        po.Quantity,
+			Location:        po.Location,
+			UrgencyLevel:    po.UrgencyLevel,
+		})
+	}
+
+	return consolidated
+}
+
+// TotalQuantity returns the sum of all line quantities
+func (c *ConsolidatedPurchaseOrder) TotalQuantity() int {
+	total := 0
+	for _, line := range c.Lines {
+		total += line.Quantity
+	}
+	return total
+}
+
+// IsAwaitingConsolidation checks if the order is held back for the next consolidation run
+func (po *PurchaseOrder) IsAwaitingConsolidation() bool {
+	awaiting, _ := po.Metadata[MetadataAwaitingConsolidation].(bool)
+	return awaiting
+}
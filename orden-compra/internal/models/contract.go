@@ -0,0 +1,121 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// MetadataMOQAdjustedFrom records the quantity an order was rounded up from
+// to meet the contract's minimum order quantity
+const MetadataMOQAdjustedFrom = "moq_adjusted_from"
+
+// SupplierContract holds the terms agreed with a supplier for one product
+type SupplierContract struct {
+	ContractID string `json:"contract_id,omitempty" dynamodbav:"contract_id,omitempty"`
+	// MinimumOrderQuantity is the smallest quantity the supplier accepts
+	MinimumOrderQuantity int    `json:"minimum_order_quantity,omitempty" dynamodbav:"minimum_order_quantity,omitempty"`
+	Currency             string `json:"currency,omitempty" dynamodbav:"currency,omitempty"`
+	// UnitPrice applies below the first price break
+	UnitPrice   float64      `json:"unit_price,omitempty" dynamodbav:"unit_price,omitempty"`
+	PriceBreaks []PriceBreak `json:"price_breaks,omitempty" dynamodbav:"price_breaks,omitempty"`
+	ValidFrom   *time.Time   `json:"valid_from,omitempty" dynamodbav:"valid_from,omitempty"`
+	ValidTo     *time.Time   `json:"valid_to,omitempty" dynamodbav:"valid_to,omitempty"`
+}
+
+// PriceBreak is the unit price of orders of at least MinQuantity
+type PriceBreak struct {
+	MinQuantity int     `json:"min_quantity" dynamodbav:"min_quantity"`
+	UnitPrice   float64 `json:"unit_price" dynamodbav:"unit_price"`
+}
+
+// OrderPricing is the contract price of a purchase order
+type OrderPricing struct {
+	ContractID  string  `json:"contract_id,omitempty" dynamodbav:"contract_id,omitempty"`
+	Currency    string  `json:"currency,omitempty" dynamodbav:"currency,omitempty"`
+	UnitPrice   float64 `json:"unit_price" dynamodbav:"unit_price"`
+	TotalAmount float64 `json:"total_amount" dynamodbav:"total_amount"`
+}
+
+// Expired reports whether the contract ended before at
+func (c *SupplierContract) Expired(at time.Time) bool {
+	return c.ValidTo != nil && at.After(*c.ValidTo)
+}
+
+// InForce reports whether the contract applies at at
+func (c *SupplierContract) InForce(at time.Time) bool {
+	return !c.Expired(at) && (c.ValidFrom == nil || !at.Before(*c.ValidFrom))
+}
+
+// OrderQuantity rounds a quantity up to the minimum order quantity
+func (c *SupplierContract) OrderQuantity(quantity int) int {
+	if quantity < c.MinimumOrderQuantity {
+		return c.MinimumOrderQuantity
+	}
+	return quantity
+}
+
+// Price returns the contract price of ordering quantity, from the highest
+// price break it reaches, or false when the contract has no price for it
+func (c *SupplierContract) Price(quantity int) (*OrderPricing, bool) {
+	unitPrice := c.UnitPrice
+	breaks := append([]PriceBreak(nil), c.PriceBreaks...)
+	sort.Slice(breaks, func(i, j int) bool {
+		return breaks[i].MinQuantity < breaks[j].MinQuantity
+	})
+	for _, priceBreak := range breaks {
+		if quantity >= priceBreak.MinQuantity {
+			unitPrice = priceBreak.UnitPrice
+		}
+	}
+	if unitPrice <= 0 {
+		return nil, false
+	}
+
+	pricing := &OrderPricing{
+		ContractID: c.ContractID,
+		Currency:   c.Currency,
+		UnitPrice:  unitPrice,
+	}
+	return pricing.ForQuantity(quantity), true
+}
+
+// ForQuantity returns the pricing of quantity at the same unit price, with
+// the total rounded to cents
+func (p *OrderPricing) ForQuantity(quantity int) *OrderPricing {
+	pricing := *p
+	pricing.TotalAmount = math.Round(p.UnitPrice*float64(quantity)*100) / 100
+	return &pricing
+}
+
+// validate checks the contract terms, reporting problems under field
+func (c *SupplierContract) validate(field string, errs *ValidationErrors) {
+	if c.MinimumOrderQuantity < 0 {
+		errs.add(field+".minimum_order_quantity", fmt.Sprintf("must not be negative, got %d", c.MinimumOrderQuantity))
+	}
+	if c.UnitPrice < 0 {
+		errs.add(field+".unit_price", fmt.Sprintf("must not be negative, got %g", c.UnitPrice))
+	}
+	if c.Currency != "" && len(c.Currency) != 3 {
+		errs.add(field+".currency", fmt.Sprintf("must be an ISO 4217 code, got %q", c.Currency))
+	}
+	if c.ValidFrom != nil && c.ValidTo != nil && c.ValidTo.Before(*c.ValidFrom) {
+		errs.add(field+".valid_to", "must not be before valid_from")
+	}
+
+	seen := make(map[int]bool, len(c.PriceBreaks))
+	for i, priceBreak := range c.PriceBreaks {
+		breakField := fmt.Sprintf("%s.price_breaks[%d]", field, i)
+		switch {
+		case priceBreak.MinQuantity < 1:
+			errs.add(breakField+".min_quantity", fmt.Sprintf("must be positive, got %d", priceBreak.MinQuantity))
+		case seen[priceBreak.MinQuantity]:
+			errs.add(breakField+".min_quantity", fmt.Sprintf("break at %d is listed more than once", priceBreak.MinQuantity))
+		}
+		seen[priceBreak.MinQuantity] = true
+		if priceBreak.UnitPrice <= 0 {
+			errs.add(breakField+".unit_price", fmt.Sprintf("must be positive, got %g", priceBreak.UnitPrice))
+		}
+	}
+}
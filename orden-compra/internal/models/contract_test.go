@@ -0,0 +1,101 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSupplierContractPrice(t *testing.T) {
+	contract := &SupplierContract{
+		ContractID:           "contract-1",
+		MinimumOrderQuantity: 50,
+		Currency:             "USD",
+		UnitPrice:            1.25,
+		PriceBreaks:          []PriceBreak{{MinQuantity: 500, UnitPrice: 0.8}, {MinQuantity: 100, UnitPrice: 1.1}},
+	}
+
+	for _, tc := range []struct {
+		quantity, ordered int
+		unitPrice, total  float64
+	}{
+		{10, 50, 1.25, 62.5},
+		{99, 99, 1.25, 123.75},
+		{100, 100, 1.1, 110},
+		{333, 333, 1.1, 366.3},
+		{500, 500, 0.8, 400},
+	} {
+		ordered := contract.OrderQuantity(tc.quantity)
+		pricing, ok := contract.Price(ordered)
+		if ordered != tc.ordered || !ok || pricing.UnitPrice != tc.unitPrice || pricing.TotalAmount != tc.total || pricing.ContractID != "contract-1" || pricing.Currency != "USD" {
+			t.Errorf("ordering %d: %d priced %+v, want %d at %g for %g", tc.quantity, ordered, pricing, tc.ordered, tc.unitPrice, tc.total)
+		}
+	}
+
+	// A contract with no price for the quantity leaves the order unpriced
+	if pricing, ok := (&SupplierContract{PriceBreaks: []PriceBreak{{MinQuantity: 100, UnitPrice: 1}}}).Price(10); ok {
+		t.Fatalf("priced %+v without a unit price", pricing)
+	}
+}
+
+func TestSupplierContractValidity(t *testing.T) {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC)
+	contract := &SupplierContract{ValidFrom: &from, ValidTo: &to}
+
+	for _, tc := range []struct {
+		at               time.Time
+		inForce, expired bool
+	}{
+		{from.Add(-time.Second), false, false},
+		{from, true, false},
+		{to, true, false},
+		{to.Add(time.Second), false, true},
+	} {
+		if contract.InForce(tc.at) != tc.inForce || contract.Expired(tc.at) != tc.expired {
+			t.Errorf("at %s in force %v and expired %v", tc.at, contract.InForce(tc.at), contract.Expired(tc.at))
+		}
+	}
+	if open := (&SupplierContract{}); !open.InForce(to) || open.Expired(to) {
+		t.Fatal("contract without dates is not in force")
+	}
+}
+
+func TestSupplierContractValidate(t *testing.T) {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(-time.Hour)
+	supplier := &Supplier{
+		ID:   "supplier-1",
+		Name: "Acme",
+		Products: []SupplierProduct{{ProductID: "product-1", Contract: &SupplierContract{
+			MinimumOrderQuantity: -1,
+			UnitPrice:            -1,
+			Currency:             "dollars",
+			ValidFrom:            &from,
+			ValidTo:              &to,
+			PriceBreaks:          []PriceBreak{{MinQuantity: 0, UnitPrice: 1}, {MinQuantity: 10, UnitPrice: 1}, {MinQuantity: 10, UnitPrice: 0}},
+		}}},
+	}
+
+	var errs ValidationErrors
+	if !errors.As(supplier.Validate(), &errs) {
+		t.Fatal("invalid contract passed validation")
+	}
+	want := []string{
+		"products[0].contract.minimum_order_quantity",
+		"products[0].contract.unit_price",
+		"products[0].contract.currency",
+		"products[0].contract.valid_to",
+		"products[0].contract.price_breaks[0].min_quantity",
+		"products[0].contract.price_breaks[2].min_quantity",
+		"products[0].contract.price_breaks[2].unit_price",
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("errors %v, want them on %v", errs, want)
+	}
+	for i, field := range want {
+		if errs[i].Field != field {
+			t.Fatalf("error %d is %+v, want one on %s", i, errs[i], field)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package models
+
+import "time"
+
+// Dispatch statuses of a purchase order sent to its supplier
+const (
+	DispatchPending = "pending"
+	DispatchSent    = "sent"
+	DispatchFailed  = "failed"
+	// DispatchSkipped marks channels the supplier cannot be reached through
+	DispatchSkipped = "skipped"
+)
+
+// Event sourcing events recorded when a dispatch finishes
+const (
+	PurchaseOrderDispatchedEventType     = "PurchaseOrderDispatched"
+	PurchaseOrderDispatchFailedEventType = "PurchaseOrderDispatchFailed"
+)
+
+// ChannelDispatch is the outcome of sending an order through one channel
+type ChannelDispatch struct {
+	Channel string `json:"channel" dynamodbav:"channel"`
+	Status  string `json:"status" dynamodbav:"status"`
+	// Reference identifies the sent document, such as the EDI interchange control number
+	Reference string     `json:"reference,omitempty" dynamodbav:"reference,omitempty"`
+	Attempts  int        `json:"attempts" dynamodbav:"attempts"`
+	LastError string     `json:"last_error,omitempty" dynamodbav:"last_error,omitempty"`
+	SentAt    *time.Time `json:"sent_at,omitempty" dynamodbav:"sent_at,omitempty"`
+}
+
+// DispatchStatus tracks the sending of a purchase order to its supplier
+type DispatchStatus struct {
+	Status    string            `json:"status" dynamodbav:"status"`
+	Channels  []ChannelDispatch `json:"channels" dynamodbav:"channels"`
+	UpdatedAt time.Time         `json:"updated_at" dynamodbav:"updated_at"`
+}
+
+// NewDispatchStatus creates a pending dispatch through the given channels
+func NewDispatchStatus(channels []string, now time.Time) *DispatchStatus {
+	status := &DispatchStatus{
+		Status:    DispatchPending,
+		Channels:  make([]ChannelDispatch, 0, len(channels)),
+		UpdatedAt: now.UTC(),
+	}
+	for _, channel := range channels {
+		status.Channels = append(status.Channels, ChannelDispatch{Channel: channel, Status: DispatchPending})
+	}
+	return status
+}
+
+// Summarize derives the overall status from the channels: failed when any
+// channel failed or every channel was skipped, pending while a channel is
+// still sending, and sent otherwise
+func (d *DispatchStatus) Summarize(now time.Time) {
+	d.UpdatedAt = now.UTC()
+	d.Status = DispatchFailed
+	for _, channel := range d.Channels {
+		switch channel.Status {
+		case DispatchFailed:
+			d.Status = DispatchFailed
+			return
+		case DispatchPending:
+			d.Status = DispatchPending
+		case DispatchSent:
+			if d.Status != DispatchPending {
+				d.Status = DispatchSent
+			}
+		}
+	}
+}
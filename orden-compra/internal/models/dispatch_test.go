@@ -0,0 +1,40 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDispatchStatusSummarize(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		channels []string
+		want     string
+	}{
+		{"all sent", []string{DispatchSent, DispatchSent}, DispatchSent},
+		{"sent and skipped", []string{DispatchSkipped, DispatchSent}, DispatchSent},
+		{"any failure", []string{DispatchSent, DispatchFailed}, DispatchFailed},
+		{"all skipped", []string{DispatchSkipped, DispatchSkipped}, DispatchFailed},
+		{"still sending", []string{DispatchSent, DispatchPending}, DispatchPending},
+		{"pending before sent", []string{DispatchPending, DispatchSent}, DispatchPending},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			status := NewDispatchStatus(make([]string, len(tc.channels)), time.Now())
+			for i, channelStatus := range tc.channels {
+				status.Channels[i].Status = channelStatus
+			}
+			now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.FixedZone("CET", 3600))
+			status.Summarize(now)
+			if status.Status != tc.want || !status.UpdatedAt.Equal(now) || status.UpdatedAt.Location() != time.UTC {
+				t.Fatalf("summarized as %s at %v, want %s", status.Status, status.UpdatedAt, tc.want)
+			}
+		})
+	}
+}
+
+func TestNewDispatchStatusIsPending(t *testing.T) {
+	status := NewDispatchStatus([]string{"email", "edi"}, time.Now())
+	if status.Status != DispatchPending || len(status.Channels) != 2 || status.Channels[1].Channel != "edi" || status.Channels[1].Status != DispatchPending {
+		t.Fatalf("status %+v", status)
+	}
+}
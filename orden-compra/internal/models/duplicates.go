@@ -0,0 +1,79 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// DuplicatePolicy decides what happens to a StockBajo event when the product
+// already has an open purchase order at the same location
+type DuplicatePolicy string
+
+// Supported duplicate-order policies
+const (
+	// DuplicatePolicyCreate always creates a new purchase order
+	DuplicatePolicyCreate DuplicatePolicy = "create"
+	// DuplicatePolicySkip drops the event and keeps the existing order unchanged
+	DuplicatePolicySkip DuplicatePolicy = "skip"
+	// DuplicatePolicyTopUp adds the calculated quantity to the existing order
+	DuplicatePolicyTopUp DuplicatePolicy = "top_up"
+	// DuplicatePolicyAttach records the event on the existing order without changing it
+	DuplicatePolicyAttach DuplicatePolicy = "attach"
+)
+
+// MetadataAttachedStockLowEvents lists the StockBajo events folded into an order
+const MetadataAttachedStockLowEvents = "attached_stock_low_event_ids"
+
+// ParseDuplicatePolicy validates a duplicate policy name
+func ParseDuplicatePolicy(policy string) (DuplicatePolicy, error) {
+	switch p := DuplicatePolicy(policy); p {
+	case DuplicatePolicyCreate, DuplicatePolicySkip, DuplicatePolicyTopUp, DuplicatePolicyAttach:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown duplicate order policy %q", policy)
+	}
+}
+
+// OpenStatuses returns the statuses in which an order has not been sent to the
+// supplier and can still absorb new demand
+func OpenStatuses() []string {
+	return []string{StatusPending, StatusPendingApproval, StatusApproved}
+}
+
+// IsOpen checks if the purchase order has not yet been sent to the supplier
+func (po *PurchaseOrder) IsOpen() bool {
+	for _, status := range OpenStatuses() {
+		if po.Status == status {
+			return true
+		}
+	}
+	return false
+}
+
+// AwaitingDelivery checks if the purchase order is still to be received: open
+// or sent to the supplier, and neither cancelled nor rejected
+func (po *PurchaseOrder) AwaitingDelivery() bool {
+	return po.IsOpen() || po.Status == StatusSent
+}
+
+// AttachStockLowEvent records a StockBajo event ID on the purchase order
+func (po *PurchaseOrder) AttachStockLowEvent(eventID string, now time.Time) {
+	var ids []interface{}
+	switch existing := po.Metadata[MetadataAttachedStockLowEvents].(type) {
+	case []interface{}:
+		ids = existing
+	case []string:
+		for _, id := range existing {
+			ids = append(ids, id)
+		}
+	}
+
+	po.Metadata[MetadataAttachedStockLowEvents] = append(ids, eventID)
+	po.UpdatedAt = now.UTC()
+}
+
+// TopUp adds quantity to the purchase order
+func (po *PurchaseOrder) TopUp(quantity int, now time.Time) {
+	po.Quantity += quantity
+	po.UpdatedAt = now.UTC()
+}
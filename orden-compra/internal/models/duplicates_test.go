@@ -0,0 +1,56 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDuplicatePolicy(t *testing.T) {
+	for _, name := range []string{"create", "skip", "top_up", "attach"} {
+		if policy, err := ParseDuplicatePolicy(name); err != nil || string(policy) != name {
+			t.Errorf("ParseDuplicatePolicy(%q) = %q, %v", name, policy, err)
+		}
+	}
+	if _, err := ParseDuplicatePolicy("merge"); err == nil {
+		t.Error("accepted an unknown policy")
+	}
+}
+
+func TestIsOpen(t *testing.T) {
+	for status, open := range map[string]bool{
+		StatusPending:         true,
+		StatusPendingApproval: true,
+		StatusApproved:        true,
+		StatusSent:            false,
+		StatusReceived:        false,
+		StatusCancelled:       false,
+		StatusRejected:        false,
+	} {
+		po := &PurchaseOrder{Status: status}
+		if po.IsOpen() != open {
+			t.Errorf("%s order open: %t, want %t", status, po.IsOpen(), open)
+		}
+		if po.AwaitingDelivery() != (open || status == StatusSent) {
+			t.Errorf("%s order awaiting delivery: %t", status, po.AwaitingDelivery())
+		}
+	}
+}
+
+func TestAttachStockLowEvent(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	// Read back from DynamoDB the IDs are a []interface{}; built in code a []string
+	po := &PurchaseOrder{Metadata: map[string]interface{}{MetadataAttachedStockLowEvents: []string{"stock-low-1"}}}
+	po.AttachStockLowEvent("stock-low-2", now)
+	po.AttachStockLowEvent("stock-low-3", now)
+
+	ids, _ := po.Metadata[MetadataAttachedStockLowEvents].([]interface{})
+	if len(ids) != 3 || ids[0] != "stock-low-1" || ids[2] != "stock-low-3" || !po.UpdatedAt.Equal(now) {
+		t.Fatalf("attached %v, updated at %s", po.Metadata[MetadataAttachedStockLowEvents], po.UpdatedAt)
+	}
+
+	po.TopUp(5, now.Add(time.Hour))
+	if po.Quantity != 5 || !po.UpdatedAt.Equal(now.Add(time.Hour)) {
+		t.Fatalf("topped up to %d at %s", po.Quantity, po.UpdatedAt)
+	}
+}
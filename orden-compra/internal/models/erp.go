@@ -0,0 +1,69 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// Documents exported to the ERP for a completed purchase order
+const (
+	// ERPDocumentPurchaseOrder is the order itself, for the ERP's purchasing module
+	ERPDocumentPurchaseOrder = "purchase_order"
+	// ERPDocumentGoodsReceipt is the goods movement posting the received
+	// inventory; it is only exported for orders with a receipt
+	ERPDocumentGoodsReceipt = "goods_receipt"
+)
+
+// ERPDocumentTypes lists the exported document types in export order
+var ERPDocumentTypes = []string{ERPDocumentPurchaseOrder, ERPDocumentGoodsReceipt}
+
+// Statuses of an ERP delivery
+const (
+	ERPDeliveryPending   = "pending"
+	ERPDeliveryDelivered = "delivered"
+	ERPDeliveryFailed    = "failed"
+)
+
+// ERPDelivery tracks the delivery of one document of a purchase order to the
+// ERP. Its ID is derived from the order and document type, so an order is
+// exported once however often its completion is seen; replays deliver the
+// same document again under the same ID.
+type ERPDelivery struct {
+	ID              string     `json:"id" dynamodbav:"id"`
+	TenantID        string     `json:"tenant_id,omitempty" dynamodbav:"tenant_id,omitempty"`
+	PurchaseOrderID string     `json:"purchase_order_id" dynamodbav:"purchase_order_id"`
+	DocumentType    string     `json:"document_type" dynamodbav:"document_type"`
+	DocumentNumber  string     `json:"document_number" dynamodbav:"document_number"`
+	Format          string     `json:"format" dynamodbav:"format"`
+	Transport       string     `json:"transport" dynamodbav:"transport"`
+	FileName        string     `json:"file_name" dynamodbav:"file_name"`
+	Status          string     `json:"status" dynamodbav:"status"`
+	Attempts        int        `json:"attempts" dynamodbav:"attempts"`
+	Replays         int        `json:"replays" dynamodbav:"replays"`
+	Reference       string     `json:"reference,omitempty" dynamodbav:"reference,omitempty"`
+	LastError       string     `json:"last_error,omitempty" dynamodbav:"last_error,omitempty"`
+	CreatedAt       time.Time  `json:"created_at" dynamodbav:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at" dynamodbav:"updated_at"`
+	DeliveredAt     *time.Time `json:"delivered_at,omitempty" dynamodbav:"delivered_at,omitempty"`
+}
+
+// ERPDeliveryID returns the ID of the delivery of a document of a purchase order
+func ERPDeliveryID(purchaseOrderID, documentType string) string {
+	return purchaseOrderID + "-" + documentType
+}
+
+// NewERPDelivery creates a new pending ERPDelivery. The document number is
+// a 16 digit number taken from the clock, as IDoc numbers are.
+func NewERPDelivery(purchaseOrder *PurchaseOrder, documentType string, now time.Time) *ERPDelivery {
+	now = now.UTC()
+	return &ERPDelivery{
+		ID:              ERPDeliveryID(purchaseOrder.ID, documentType),
+		TenantID:        purchaseOrder.TenantID,
+		PurchaseOrderID: purchaseOrder.ID,
+		DocumentType:    documentType,
+		DocumentNumber:  fmt.Sprintf("%016d", now.UnixNano()%1e16),
+		Status:          ERPDeliveryPending,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+}
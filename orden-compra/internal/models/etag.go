@@ -0,0 +1,10 @@
+package models
+
+import "strconv"
+
+// ETag returns the entity tag of the order's current state. Every write
+// through the read model moves the version; updated_at tells apart the
+// versions of orders stored before they were versioned.
+func (p *PurchaseOrder) ETag() string {
+	return `"` + strconv.Itoa(p.Version) + "-" + strconv.FormatInt(p.UpdatedAt.UnixNano(), 36) + `"`
+}
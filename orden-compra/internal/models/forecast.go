@@ -0,0 +1,219 @@
+package models
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// ReorderSourceForecast marks reorder policy overrides written from a
+// reorder suggestion, and the orders whose quantity came from one
+const ReorderSourceForecast = "forecast"
+
+// Lead time sources of a reorder suggestion
+const (
+	LeadTimeSourceObserved = "observed"
+	LeadTimeSourcePromised = "promised"
+	LeadTimeSourceDefault  = "default"
+)
+
+// ForecastPolicy configures how reorder suggestions are computed from the
+// event history
+type ForecastPolicy struct {
+	// Window is how much event history is analyzed
+	Window time.Duration
+	// CoverDays is the demand each suggested order should cover
+	CoverDays int
+	// SafetyFactor is the number of standard deviations of lead time demand
+	// kept as safety stock; 1.65 covers about 95% of lead times
+	SafetyFactor float64
+	// MinimumEvents is the fewest StockBajo events a suggestion is made from
+	MinimumEvents int
+	// DefaultLeadTimeDays is used when no order of the product has a lead time
+	DefaultLeadTimeDays int
+}
+
+// DefaultForecastPolicy returns a 90-day history, 30 days of cover and a 95% service level
+func DefaultForecastPolicy() ForecastPolicy {
+	return ForecastPolicy{
+		Window:              90 * 24 * time.Hour,
+		CoverDays:           30,
+		SafetyFactor:        1.65,
+		MinimumEvents:       3,
+		DefaultLeadTimeDays: DefaultLeadTimePolicy().DefaultDays,
+	}
+}
+
+// ReorderSuggestion is the reorder point and order quantity suggested for a
+// product from its event history. The figures are only set when the history
+// has enough StockBajo events.
+type ReorderSuggestion struct {
+	TenantID    string `json:"tenant_id,omitempty"`
+	ProductID   string `json:"product_id"`
+	ProductName string `json:"product_name"`
+	// Sufficient reports whether the history was long enough for a suggestion
+	Sufficient bool `json:"sufficient"`
+	// StockLowEvents is the number of StockBajo events in the window
+	StockLowEvents   int     `json:"stock_low_events"`
+	MeanIntervalDays float64 `json:"mean_interval_days,omitempty"`
+	// OrderedQuantity is the quantity ordered or topped up in the window
+	OrderedQuantity      int       `json:"ordered_quantity"`
+	AverageOrderQuantity float64   `json:"average_order_quantity,omitempty"`
+	DailyDemand          float64   `json:"daily_demand,omitempty"`
+	DailyDemandStdDev    float64   `json:"daily_demand_std_dev,omitempty"`
+	LeadTimeDays         float64   `json:"lead_time_days,omitempty"`
+	LeadTimeSource       string    `json:"lead_time_source,omitempty"`
+	LeadTimeSamples      int       `json:"lead_time_samples"`
+	SafetyStock          int       `json:"safety_stock,omitempty"`
+	ReorderPoint         int       `json:"reorder_point,omitempty"`
+	SuggestedQuantity    int       `json:"suggested_quantity,omitempty"`
+	WindowStart          time.Time `json:"window_start"`
+	GeneratedAt          time.Time `json:"generated_at"`
+}
+
+// DemandHistory accumulates the event history of one product
+type DemandHistory struct {
+	TenantID    string
+	ProductID   string
+	ProductName string
+
+	stockLowEvents []time.Time
+	orders         map[time.Time]int
+	leadTimes      []float64
+	promised       []float64
+}
+
+// NewDemandHistory creates an empty history for a product
+func NewDemandHistory(tenantID, productID string) *DemandHistory {
+	return &DemandHistory{
+		TenantID:  tenantID,
+		ProductID: productID,
+		orders:    make(map[time.Time]int),
+	}
+}
+
+// AddStockLowEvent records a StockBajo event at a point in time and the
+// quantity it added to an order, 0 when it was suppressed or attached
+func (h *DemandHistory) AddStockLowEvent(at time.Time, quantity int) {
+	h.stockLowEvents = append(h.stockLowEvents, at)
+	if quantity > 0 {
+		h.orders[at] += quantity
+	}
+}
+
+// AddLeadTime records the lead time of an order of the product: the days
+// from creation to receipt when it was received, or else the lead time
+// promised to it, if any
+func (h *DemandHistory) AddLeadTime(po *PurchaseOrder) {
+	if po.ActualDate != nil {
+		if days := po.ActualDate.Sub(po.CreatedAt).Hours() / 24; days >= 0 {
+			h.leadTimes = append(h.leadTimes, days)
+			return
+		}
+	}
+	switch days := po.Metadata[MetadataSLALeadTimeDays].(type) {
+	case float64:
+		h.promised = append(h.promised, days)
+	case int:
+		h.promised = append(h.promised, float64(days))
+	}
+}
+
+// Suggest computes the product's reorder suggestion over the window that
+// ends at now. Daily demand is the quantity ordered per day since the first
+// StockBajo event in the window; safety stock is SafetyFactor standard
+// deviations of daily demand over the lead time.
+func (h *DemandHistory) Suggest(policy ForecastPolicy, now time.Time) *ReorderSuggestion {
+	windowStart := now.Add(-policy.Window)
+	suggestion := &ReorderSuggestion{
+		TenantID:       h.TenantID,
+		ProductID:      h.ProductID,
+		ProductName:    h.ProductName,
+		StockLowEvents: len(h.stockLowEvents),
+		WindowStart:    windowStart,
+		GeneratedAt:    now,
+	}
+
+	orders := 0
+	for _, quantity := range h.orders {
+		suggestion.OrderedQuantity += quantity
+		orders++
+	}
+	if orders > 0 {
+		suggestion.AverageOrderQuantity = round2(float64(suggestion.OrderedQuantity) / float64(orders))
+	}
+
+	sort.Slice(h.stockLowEvents, func(i, j int) bool { return h.stockLowEvents[i].Before(h.stockLowEvents[j]) })
+	if n := len(h.stockLowEvents); n > 1 {
+		suggestion.MeanIntervalDays = round2(h.stockLowEvents[n-1].Sub(h.stockLowEvents[0]).Hours() / 24 / float64(n-1))
+	}
+
+	suggestion.LeadTimeSamples = len(h.leadTimes)
+	switch {
+	case len(h.leadTimes) > 0:
+		suggestion.LeadTimeDays = round2(mean(h.leadTimes))
+		suggestion.LeadTimeSource = LeadTimeSourceObserved
+	case len(h.promised) > 0:
+		suggestion.LeadTimeDays = round2(mean(h.promised))
+		suggestion.LeadTimeSource = LeadTimeSourcePromised
+	default:
+		suggestion.LeadTimeDays = float64(policy.DefaultLeadTimeDays)
+		suggestion.LeadTimeSource = LeadTimeSourceDefault
+	}
+
+	if len(h.stockLowEvents) < policy.MinimumEvents || len(h.stockLowEvents) == 0 || suggestion.OrderedQuantity == 0 {
+		return suggestion
+	}
+	suggestion.Sufficient = true
+
+	// Demand is spread over whole days from the first event, so a product
+	// that only recently started running low is not diluted by the window
+	days := int(math.Ceil(now.Sub(h.stockLowEvents[0]).Hours() / 24))
+	if days < 1 {
+		days = 1
+	}
+	daily := make([]float64, days)
+	for at, quantity := range h.orders {
+		day := int(at.Sub(h.stockLowEvents[0]).Hours() / 24)
+		if day >= days {
+			day = days - 1
+		}
+		daily[day] += float64(quantity)
+	}
+	demand := mean(daily)
+	deviation := stdDev(daily, demand)
+	suggestion.DailyDemand = round2(demand)
+	suggestion.DailyDemandStdDev = round2(deviation)
+
+	safetyStock := policy.SafetyFactor * deviation * math.Sqrt(suggestion.LeadTimeDays)
+	suggestion.SafetyStock = int(math.Ceil(safetyStock))
+	suggestion.ReorderPoint = int(math.Ceil(demand*suggestion.LeadTimeDays + safetyStock))
+	suggestion.SuggestedQuantity = int(math.Ceil(demand * float64(policy.CoverDays)))
+	if suggestion.SuggestedQuantity < 1 {
+		suggestion.SuggestedQuantity = 1
+	}
+	return suggestion
+}
+
+// mean returns the arithmetic mean of values
+func mean(values []float64) float64 {
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+// stdDev returns the population standard deviation of values around mean
+func stdDev(values []float64, mean float64) float64 {
+	sum := 0.0
+	for _, v := range values {
+		sum += (v - mean) * (v - mean)
+	}
+	return math.Sqrt(sum / float64(len(values)))
+}
+
+// round2 rounds to two decimals for display
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
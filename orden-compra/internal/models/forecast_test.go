@@ -0,0 +1,74 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDemandHistorySuggest(t *testing.T) {
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	now := start.AddDate(0, 0, 10)
+	policy := DefaultForecastPolicy()
+
+	history := NewDemandHistory("tenant-1", "product-1")
+	for _, day := range []int{0, 5, 10} {
+		history.AddStockLowEvent(start.AddDate(0, 0, day), 100)
+	}
+	// A suppressed event counts towards the frequency but not the demand
+	history.AddStockLowEvent(start.AddDate(0, 0, 5).Add(time.Hour), 0)
+	received := start.AddDate(0, 0, 4)
+	history.AddLeadTime(&PurchaseOrder{CreatedAt: start, ActualDate: &received})
+	history.AddLeadTime(&PurchaseOrder{CreatedAt: start, Metadata: map[string]interface{}{MetadataSLALeadTimeDays: 9}})
+
+	// 300 ordered over 10 days, 100 on three of them
+	suggestion := history.Suggest(policy, now)
+	want := ReorderSuggestion{
+		TenantID:             "tenant-1",
+		ProductID:            "product-1",
+		Sufficient:           true,
+		StockLowEvents:       4,
+		MeanIntervalDays:     3.33,
+		OrderedQuantity:      300,
+		AverageOrderQuantity: 100,
+		DailyDemand:          30,
+		DailyDemandStdDev:    45.83,
+		LeadTimeDays:         4,
+		LeadTimeSource:       LeadTimeSourceObserved,
+		LeadTimeSamples:      1,
+		SafetyStock:          152,
+		ReorderPoint:         272,
+		SuggestedQuantity:    900,
+		WindowStart:          now.Add(-policy.Window),
+		GeneratedAt:          now,
+	}
+	if *suggestion != want {
+		t.Fatalf("suggestion %+v, want %+v", *suggestion, want)
+	}
+}
+
+func TestDemandHistoryLeadTimeSources(t *testing.T) {
+	now := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	policy := DefaultForecastPolicy()
+
+	history := NewDemandHistory("", "product-1")
+	if suggestion := history.Suggest(policy, now); suggestion.LeadTimeSource != LeadTimeSourceDefault || suggestion.LeadTimeDays != float64(policy.DefaultLeadTimeDays) {
+		t.Fatalf("lead time %g from %s, want the default", suggestion.LeadTimeDays, suggestion.LeadTimeSource)
+	}
+	history.AddLeadTime(&PurchaseOrder{Metadata: map[string]interface{}{MetadataSLALeadTimeDays: float64(5)}})
+	history.AddLeadTime(&PurchaseOrder{Metadata: map[string]interface{}{MetadataSLALeadTimeDays: 8}})
+	if suggestion := history.Suggest(policy, now); suggestion.LeadTimeSource != LeadTimeSourcePromised || suggestion.LeadTimeDays != 6.5 {
+		t.Fatalf("lead time %g from %s, want 6.5 promised", suggestion.LeadTimeDays, suggestion.LeadTimeSource)
+	}
+}
+
+func TestDemandHistoryNeedsEnoughEvents(t *testing.T) {
+	now := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	history := NewDemandHistory("", "product-1")
+	history.AddStockLowEvent(now.AddDate(0, 0, -2), 100)
+	history.AddStockLowEvent(now.AddDate(0, 0, -1), 100)
+
+	suggestion := history.Suggest(DefaultForecastPolicy(), now)
+	if suggestion.Sufficient || suggestion.SuggestedQuantity != 0 || suggestion.ReorderPoint != 0 || suggestion.OrderedQuantity != 200 {
+		t.Fatalf("suggestion from 2 events %+v", suggestion)
+	}
+}
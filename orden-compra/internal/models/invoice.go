@@ -0,0 +1,333 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// InvoiceReceivedEventType is the type of the FacturaRecibida event the
+// accounts payable system produces for each supplier invoice
+const InvoiceReceivedEventType EventType = "FacturaRecibida"
+
+// InvoiceMismatchDetectedEventType is recorded and published when an order's
+// invoices do not match what was ordered and received
+const InvoiceMismatchDetectedEventType EventType = "InvoiceMismatchDetected"
+
+// PurchaseOrderInvoicedEventType is recorded when a supplier invoice is attached to an order
+const PurchaseOrderInvoicedEventType = "PurchaseOrderInvoiced"
+
+// MaxOrderInvoices bounds the invoices attached to a purchase order
+const MaxOrderInvoices = 50
+
+// Statuses of the three-way match of a purchase order
+const (
+	// InvoiceMatchPending is an order that has not been invoiced
+	InvoiceMatchPending = "pending"
+	// InvoiceMatchAwaitingReceipt is an invoiced order that matches what was
+	// ordered but has not been received
+	InvoiceMatchAwaitingReceipt = "awaiting_receipt"
+	InvoiceMatchMatched         = "matched"
+	InvoiceMatchMismatch        = "mismatch"
+)
+
+// Kinds of discrepancy between an order's invoices, the order and its receipt
+const (
+	DiscrepancyOverOrdered  = "quantity_over_ordered"
+	DiscrepancyOverReceived = "quantity_over_received"
+	DiscrepancyUnitPrice    = "unit_price"
+	DiscrepancyCurrency     = "currency"
+	DiscrepancyProduct      = "product"
+)
+
+// InvoiceMatchPolicy configures how far an invoice may deviate from its
+// order before the match fails
+type InvoiceMatchPolicy struct {
+	// PriceTolerance is the fraction an invoiced unit price may differ from
+	// the ordered unit price, e.g. 0.02 for 2%
+	PriceTolerance float64
+	// QuantityTolerance is the number of units that may be invoiced beyond
+	// what was ordered or received
+	QuantityTolerance int
+}
+
+// DefaultInvoiceMatchPolicy returns a 1% price tolerance and no quantity tolerance
+func DefaultInvoiceMatchPolicy() InvoiceMatchPolicy {
+	return InvoiceMatchPolicy{PriceTolerance: 0.01}
+}
+
+// InvoiceLine is a line of a supplier invoice. Lines without a product are
+// taken to bill the order's product.
+type InvoiceLine struct {
+	ProductID   string  `json:"product_id,omitempty" dynamodbav:"product_id,omitempty"`
+	Description string  `json:"description,omitempty" dynamodbav:"description,omitempty"`
+	Quantity    int     `json:"quantity" dynamodbav:"quantity"`
+	UnitPrice   float64 `json:"unit_price" dynamodbav:"unit_price"`
+	Amount      float64 `json:"amount" dynamodbav:"amount"`
+}
+
+// SupplierInvoice is an invoice a supplier issued for a purchase order
+type SupplierInvoice struct {
+	ID            string        `json:"id" dynamodbav:"id"`
+	InvoiceNumber string        `json:"invoice_number" dynamodbav:"invoice_number"`
+	SupplierID    string        `json:"supplier_id,omitempty" dynamodbav:"supplier_id,omitempty"`
+	Currency      string        `json:"currency,omitempty" dynamodbav:"currency,omitempty"`
+	IssuedAt      time.Time     `json:"issued_at" dynamodbav:"issued_at"`
+	Lines         []InvoiceLine `json:"lines" dynamodbav:"lines"`
+	TotalAmount   float64       `json:"total_amount" dynamodbav:"total_amount"`
+	// EventID is the FacturaRecibida event the invoice arrived in, if any
+	EventID    string    `json:"event_id,omitempty" dynamodbav:"event_id,omitempty"`
+	ReceivedAt time.Time `json:"received_at" dynamodbav:"received_at"`
+}
+
+// Validate checks the invoice for required fields and sane values
+func (i *SupplierInvoice) Validate() error {
+	var errs ValidationErrors
+
+	if strings.TrimSpace(i.InvoiceNumber) == "" {
+		errs.add("invoice_number", "is required")
+	}
+	if i.IssuedAt.IsZero() {
+		errs.add("issued_at", "is required")
+	}
+	if len(i.Lines) == 0 {
+		errs.add("lines", "at least one line is required")
+	}
+	for n, line := range i.Lines {
+		if line.Quantity <= 0 {
+			errs.add(fmt.Sprintf("lines[%d].quantity", n), fmt.Sprintf("must be positive, got %d", line.Quantity))
+		}
+		if line.UnitPrice < 0 {
+			errs.add(fmt.Sprintf("lines[%d].unit_price", n), fmt.Sprintf("must not be negative, got %.2f", line.UnitPrice))
+		}
+		if line.Amount != 0 && !sameAmount(line.Amount, float64(line.Quantity)*line.UnitPrice) {
+			errs.add(fmt.Sprintf("lines[%d].amount", n), fmt.Sprintf("must be quantity times unit price, got %.2f", line.Amount))
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Total fills in the amount of lines that left it out and returns the
+// invoice total
+func (i *SupplierInvoice) Total() float64 {
+	total := 0.0
+	for n := range i.Lines {
+		line := &i.Lines[n]
+		if line.Amount == 0 {
+			line.Amount = round2(float64(line.Quantity) * line.UnitPrice)
+		}
+		total += line.Amount
+	}
+	return round2(total)
+}
+
+// InvoiceReceivedEvent is the FacturaRecibida event of a supplier invoice
+// for a purchase order
+type InvoiceReceivedEvent struct {
+	ID              string                 `json:"id" dynamodbav:"id"`
+	TenantID        string                 `json:"tenant_id,omitempty" dynamodbav:"tenant_id,omitempty"`
+	Timestamp       time.Time              `json:"timestamp" dynamodbav:"timestamp"`
+	EventType       EventType              `json:"event_type" dynamodbav:"event_type"`
+	PurchaseOrderID string                 `json:"purchase_order_id" dynamodbav:"purchase_order_id"`
+	InvoiceNumber   string                 `json:"invoice_number" dynamodbav:"invoice_number"`
+	SupplierID      string                 `json:"supplier_id" dynamodbav:"supplier_id"`
+	Currency        string                 `json:"currency" dynamodbav:"currency"`
+	IssuedAt        time.Time              `json:"issued_at" dynamodbav:"issued_at"`
+	Lines           []InvoiceLine          `json:"lines" dynamodbav:"lines"`
+	Metadata        map[string]interface{} `json:"metadata" dynamodbav:"metadata"`
+}
+
+// Validate checks the event for the fields needed to attach its invoice
+func (e *InvoiceReceivedEvent) Validate() error {
+	var errs ValidationErrors
+
+	if strings.TrimSpace(e.ID) == "" {
+		errs.add("id", "is required")
+	}
+	if e.EventType != "" && e.EventType != InvoiceReceivedEventType {
+		errs.add("event_type", fmt.Sprintf("must be %s, got %s", InvoiceReceivedEventType, e.EventType))
+	}
+	if strings.TrimSpace(e.PurchaseOrderID) == "" {
+		errs.add("purchase_order_id", "is required")
+	}
+	if err := e.Invoice().Validate(); err != nil {
+		errs = append(errs, err.(ValidationErrors)...)
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Invoice returns the invoice the event carries
+func (e *InvoiceReceivedEvent) Invoice() *SupplierInvoice {
+	return &SupplierInvoice{
+		InvoiceNumber: e.InvoiceNumber,
+		SupplierID:    e.SupplierID,
+		Currency:      e.Currency,
+		IssuedAt:      e.IssuedAt,
+		Lines:         append([]InvoiceLine(nil), e.Lines...),
+		EventID:       e.ID,
+	}
+}
+
+// InvoiceDiscrepancy is one way an order's invoices differ from the order
+// or its receipt
+type InvoiceDiscrepancy struct {
+	Kind          string  `json:"kind" dynamodbav:"kind"`
+	InvoiceNumber string  `json:"invoice_number,omitempty" dynamodbav:"invoice_number,omitempty"`
+	Expected      float64 `json:"expected" dynamodbav:"expected"`
+	Actual        float64 `json:"actual" dynamodbav:"actual"`
+	Message       string  `json:"message" dynamodbav:"message"`
+}
+
+// InvoiceMatch is the three-way match of a purchase order: the quantity and
+// price invoiced against the quantity and price ordered and the quantity
+// received. ReceivedQuantity is nil until the order is received.
+type InvoiceMatch struct {
+	Status           string               `json:"status" dynamodbav:"status"`
+	OrderedQuantity  int                  `json:"ordered_quantity" dynamodbav:"ordered_quantity"`
+	ReceivedQuantity *int                 `json:"received_quantity,omitempty" dynamodbav:"received_quantity,omitempty"`
+	InvoicedQuantity int                  `json:"invoiced_quantity" dynamodbav:"invoiced_quantity"`
+	ExpectedAmount   float64              `json:"expected_amount" dynamodbav:"expected_amount"`
+	InvoicedAmount   float64              `json:"invoiced_amount" dynamodbav:"invoiced_amount"`
+	Invoices         int                  `json:"invoices" dynamodbav:"invoices"`
+	Discrepancies    []InvoiceDiscrepancy `json:"discrepancies" dynamodbav:"discrepancies"`
+	MatchedAt        time.Time            `json:"matched_at" dynamodbav:"matched_at"`
+}
+
+// MatchInvoices matches every invoice of a purchase order against the order
+// and its receipt. Quantities are summed over the invoices, so an order may
+// be invoiced in parts; prices are checked line by line against the ordered
+// unit price when the order was priced. The expected amount is the received
+// quantity, or the ordered quantity until the order is received, at the
+// ordered unit price.
+func MatchInvoices(purchaseOrder *PurchaseOrder, policy InvoiceMatchPolicy, now time.Time) *InvoiceMatch {
+	match := &InvoiceMatch{
+		Status:          InvoiceMatchPending,
+		OrderedQuantity: purchaseOrder.Quantity,
+		Invoices:        len(purchaseOrder.Invoices),
+		Discrepancies:   []InvoiceDiscrepancy{},
+		MatchedAt:       now.UTC(),
+	}
+
+	billable := purchaseOrder.Quantity
+	if purchaseOrder.Receipt != nil {
+		received := purchaseOrder.Receipt.ReceivedQuantity
+		match.ReceivedQuantity = &received
+		billable = received
+	}
+	if purchaseOrder.Pricing != nil {
+		match.ExpectedAmount = round2(float64(billable) * purchaseOrder.Pricing.UnitPrice)
+	}
+
+	if len(purchaseOrder.Invoices) == 0 {
+		return match
+	}
+
+	for _, invoice := range purchaseOrder.Invoices {
+		match.InvoicedAmount += invoice.TotalAmount
+		match.matchInvoice(purchaseOrder, &invoice, policy)
+	}
+	match.InvoicedAmount = round2(match.InvoicedAmount)
+
+	if match.InvoicedQuantity > purchaseOrder.Quantity+policy.QuantityTolerance {
+		match.flag(DiscrepancyOverOrdered, "", float64(purchaseOrder.Quantity), float64(match.InvoicedQuantity),
+			fmt.Sprintf("invoiced %d units of %d ordered", match.InvoicedQuantity, purchaseOrder.Quantity))
+	}
+	if match.ReceivedQuantity != nil && match.InvoicedQuantity > *match.ReceivedQuantity+policy.QuantityTolerance {
+		match.flag(DiscrepancyOverReceived, "", float64(*match.ReceivedQuantity), float64(match.InvoicedQuantity),
+			fmt.Sprintf("invoiced %d units of %d received", match.InvoicedQuantity, *match.ReceivedQuantity))
+	}
+
+	switch {
+	case len(match.Discrepancies) > 0:
+		match.Status = InvoiceMatchMismatch
+	case match.ReceivedQuantity == nil:
+		match.Status = InvoiceMatchAwaitingReceipt
+	default:
+		match.Status = InvoiceMatchMatched
+	}
+	return match
+}
+
+// matchInvoice adds the quantity an invoice bills for the order's product
+// and flags its lines that bill another product or another price
+func (m *InvoiceMatch) matchInvoice(purchaseOrder *PurchaseOrder, invoice *SupplierInvoice, policy InvoiceMatchPolicy) {
+	pricing := purchaseOrder.Pricing
+	if pricing != nil && pricing.Currency != "" && invoice.Currency != "" && !strings.EqualFold(pricing.Currency, invoice.Currency) {
+		m.flag(DiscrepancyCurrency, invoice.InvoiceNumber, 0, 0,
+			fmt.Sprintf("invoice %s is in %s, the order is priced in %s", invoice.InvoiceNumber, invoice.Currency, pricing.Currency))
+	}
+
+	for n, line := range invoice.Lines {
+		if line.ProductID != "" && line.ProductID != purchaseOrder.ProductID {
+			m.flag(DiscrepancyProduct, invoice.InvoiceNumber, 0, float64(line.Quantity),
+				fmt.Sprintf("invoice %s line %d bills product %s, the order is for %s", invoice.InvoiceNumber, n+1, line.ProductID, purchaseOrder.ProductID))
+			continue
+		}
+		m.InvoicedQuantity += line.Quantity
+
+		if pricing == nil {
+			continue
+		}
+		if math.Abs(line.UnitPrice-pricing.UnitPrice) > pricing.UnitPrice*policy.PriceTolerance+0.005 {
+			m.flag(DiscrepancyUnitPrice, invoice.InvoiceNumber, pricing.UnitPrice, line.UnitPrice,
+				fmt.Sprintf("invoice %s line %d bills %.2f per unit, the order is priced at %.2f", invoice.InvoiceNumber, n+1, line.UnitPrice, pricing.UnitPrice))
+		}
+	}
+}
+
+// flag records a discrepancy
+func (m *InvoiceMatch) flag(kind, invoiceNumber string, expected, actual float64, message string) {
+	m.Discrepancies = append(m.Discrepancies, InvoiceDiscrepancy{
+		Kind:          kind,
+		InvoiceNumber: invoiceNumber,
+		Expected:      expected,
+		Actual:        actual,
+		Message:       message,
+	})
+}
+
+// InvoiceMismatchDetectedEvent tells finance that a purchase order's invoices
+// do not match what was ordered and received, so payment is held
+type InvoiceMismatchDetectedEvent struct {
+	ID              string                 `json:"id" dynamodbav:"id"`
+	TenantID        string                 `json:"tenant_id,omitempty" dynamodbav:"tenant_id,omitempty"`
+	Timestamp       time.Time              `json:"timestamp" dynamodbav:"timestamp"`
+	EventType       EventType              `json:"event_type" dynamodbav:"event_type"`
+	PurchaseOrderID string                 `json:"purchase_order_id" dynamodbav:"purchase_order_id"`
+	SupplierID      string                 `json:"supplier_id" dynamodbav:"supplier_id"`
+	ProductID       string                 `json:"product_id" dynamodbav:"product_id"`
+	Match           *InvoiceMatch          `json:"match" dynamodbav:"match"`
+	Metadata        map[string]interface{} `json:"metadata" dynamodbav:"metadata"`
+}
+
+// NewInvoiceMismatchDetectedEvent creates the mismatch event of an order
+// whose invoice match failed
+func NewInvoiceMismatchDetectedEvent(purchaseOrder *PurchaseOrder, now time.Time) *InvoiceMismatchDetectedEvent {
+	return &InvoiceMismatchDetectedEvent{
+		ID:              uuid.New().String(),
+		TenantID:        purchaseOrder.TenantID,
+		Timestamp:       now.UTC(),
+		EventType:       InvoiceMismatchDetectedEventType,
+		PurchaseOrderID: purchaseOrder.ID,
+		SupplierID:      purchaseOrder.SupplierID,
+		ProductID:       purchaseOrder.ProductID,
+		Match:           purchaseOrder.InvoiceMatch,
+		Metadata:        make(map[string]interface{}),
+	}
+}
+
+// sameAmount reports whether two amounts are equal to the cent
+func sameAmount(a, b float64) bool {
+	return math.Abs(a-b) < 0.005
+}
@@ -0,0 +1,167 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+var invoicedAt = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func TestSupplierInvoiceValidate(t *testing.T) {
+	invoice := &SupplierInvoice{
+		Lines: []InvoiceLine{
+			{Quantity: 0, UnitPrice: -1},
+			{Quantity: 2, UnitPrice: 5, Amount: 11},
+		},
+	}
+
+	var errs ValidationErrors
+	if !errors.As(invoice.Validate(), &errs) {
+		t.Fatal("invalid invoice passed validation")
+	}
+	want := []string{"invoice_number", "issued_at", "lines[0].quantity", "lines[0].unit_price", "lines[1].amount"}
+	if len(errs) != len(want) {
+		t.Fatalf("errors %v, want them on %v", errs, want)
+	}
+	for i, field := range want {
+		if errs[i].Field != field {
+			t.Fatalf("error %d is %+v, want one on %s", i, errs[i], field)
+		}
+	}
+
+	// An amount within a cent of quantity times unit price is accepted
+	valid := &SupplierInvoice{InvoiceNumber: "INV-1", IssuedAt: invoicedAt, Lines: []InvoiceLine{{Quantity: 3, UnitPrice: 3.333, Amount: 10}}}
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("valid invoice: %v", err)
+	}
+}
+
+func TestSupplierInvoiceTotal(t *testing.T) {
+	invoice := &SupplierInvoice{Lines: []InvoiceLine{
+		{Quantity: 3, UnitPrice: 1.5},
+		{Quantity: 1, UnitPrice: 2, Amount: 2},
+	}}
+	if total := invoice.Total(); total != 6.5 {
+		t.Fatalf("total %.2f, want 6.50", total)
+	}
+	if invoice.Lines[0].Amount != 4.5 {
+		t.Fatalf("line amount %.2f was not filled in", invoice.Lines[0].Amount)
+	}
+}
+
+func TestInvoiceReceivedEvent(t *testing.T) {
+	event := &InvoiceReceivedEvent{
+		ID:              "event-1",
+		EventType:       InvoiceReceivedEventType,
+		PurchaseOrderID: "po-1",
+		InvoiceNumber:   "INV-1",
+		SupplierID:      "supplier-1",
+		Currency:        "USD",
+		IssuedAt:        invoicedAt,
+		Lines:           []InvoiceLine{{Quantity: 10, UnitPrice: 2}},
+	}
+	if err := event.Validate(); err != nil {
+		t.Fatalf("valid event: %v", err)
+	}
+
+	invoice := event.Invoice()
+	if invoice.EventID != "event-1" || invoice.InvoiceNumber != "INV-1" || invoice.SupplierID != "supplier-1" || len(invoice.Lines) != 1 {
+		t.Fatalf("invoice %+v", invoice)
+	}
+	// The invoice does not share its lines with the event
+	invoice.Lines[0].Quantity = 1
+	if event.Lines[0].Quantity != 10 {
+		t.Fatal("invoice lines alias the event's")
+	}
+
+	invalid := &InvoiceReceivedEvent{EventType: "StockLow"}
+	var errs ValidationErrors
+	if !errors.As(invalid.Validate(), &errs) {
+		t.Fatal("invalid event passed validation")
+	}
+	want := []string{"id", "event_type", "purchase_order_id", "invoice_number", "issued_at", "lines"}
+	if len(errs) != len(want) {
+		t.Fatalf("errors %v, want them on %v", errs, want)
+	}
+	for i, field := range want {
+		if errs[i].Field != field {
+			t.Fatalf("error %d is %+v, want one on %s", i, errs[i], field)
+		}
+	}
+}
+
+func TestMatchInvoices(t *testing.T) {
+	received := func(quantity int) *InventoryReceipt {
+		return &InventoryReceipt{OrderedQuantity: 10, ReceivedQuantity: quantity}
+	}
+	invoice := func(number, currency string, lines ...InvoiceLine) SupplierInvoice {
+		invoice := SupplierInvoice{InvoiceNumber: number, Currency: currency, IssuedAt: invoicedAt, Lines: lines}
+		invoice.TotalAmount = invoice.Total()
+		return invoice
+	}
+	line := func(quantity int, unitPrice float64) InvoiceLine {
+		return InvoiceLine{Quantity: quantity, UnitPrice: unitPrice}
+	}
+	pricing := &OrderPricing{Currency: "USD", UnitPrice: 2, TotalAmount: 20}
+
+	for _, tc := range []struct {
+		name          string
+		pricing       *OrderPricing
+		receipt       *InventoryReceipt
+		invoices      []SupplierInvoice
+		policy        InvoiceMatchPolicy
+		status        string
+		discrepancies []string
+		expected      float64
+		invoiced      float64
+	}{
+		{"not invoiced", pricing, nil, nil, DefaultInvoiceMatchPolicy(), InvoiceMatchPending, nil, 20, 0},
+		{"invoiced before delivery", pricing, nil, []SupplierInvoice{invoice("INV-1", "USD", line(10, 2))}, DefaultInvoiceMatchPolicy(), InvoiceMatchAwaitingReceipt, nil, 20, 20},
+		{"invoiced in parts", pricing, received(10), []SupplierInvoice{invoice("INV-1", "USD", line(4, 2)), invoice("INV-2", "usd", line(6, 2.02))}, DefaultInvoiceMatchPolicy(), InvoiceMatchMatched, nil, 20, 20.12},
+		{"unpriced order", nil, received(10), []SupplierInvoice{invoice("INV-1", "EUR", line(10, 7))}, DefaultInvoiceMatchPolicy(), InvoiceMatchMatched, nil, 0, 70},
+		{"over ordered", pricing, nil, []SupplierInvoice{invoice("INV-1", "USD", line(11, 2))}, DefaultInvoiceMatchPolicy(), InvoiceMatchMismatch, []string{DiscrepancyOverOrdered}, 20, 22},
+		{"over ordered within tolerance", pricing, nil, []SupplierInvoice{invoice("INV-1", "USD", line(11, 2))}, InvoiceMatchPolicy{PriceTolerance: 0.01, QuantityTolerance: 1}, InvoiceMatchAwaitingReceipt, nil, 20, 22},
+		{"over received", pricing, received(8), []SupplierInvoice{invoice("INV-1", "USD", line(10, 2))}, DefaultInvoiceMatchPolicy(), InvoiceMatchMismatch, []string{DiscrepancyOverReceived}, 16, 20},
+		{"unit price", pricing, received(10), []SupplierInvoice{invoice("INV-1", "USD", line(10, 2.05))}, DefaultInvoiceMatchPolicy(), InvoiceMatchMismatch, []string{DiscrepancyUnitPrice}, 20, 20.5},
+		{"unit price within tolerance", pricing, received(10), []SupplierInvoice{invoice("INV-1", "USD", line(10, 2.05))}, InvoiceMatchPolicy{PriceTolerance: 0.05}, InvoiceMatchMatched, nil, 20, 20.5},
+		{"currency", pricing, received(10), []SupplierInvoice{invoice("INV-1", "EUR", line(10, 2))}, DefaultInvoiceMatchPolicy(), InvoiceMatchMismatch, []string{DiscrepancyCurrency}, 20, 20},
+		{"another product", pricing, received(10), []SupplierInvoice{invoice("INV-1", "USD", line(10, 2), InvoiceLine{ProductID: "product-2", Quantity: 1, UnitPrice: 2})}, DefaultInvoiceMatchPolicy(), InvoiceMatchMismatch, []string{DiscrepancyProduct}, 20, 22},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			purchaseOrder := &PurchaseOrder{ID: "po-1", ProductID: "product-1", Quantity: 10, Pricing: tc.pricing, Receipt: tc.receipt, Invoices: tc.invoices}
+			match := MatchInvoices(purchaseOrder, tc.policy, invoicedAt)
+
+			if match.Status != tc.status {
+				t.Fatalf("status %s, want %s: %+v", match.Status, tc.status, match.Discrepancies)
+			}
+			if len(match.Discrepancies) != len(tc.discrepancies) {
+				t.Fatalf("discrepancies %+v, want %v", match.Discrepancies, tc.discrepancies)
+			}
+			for i, kind := range tc.discrepancies {
+				if match.Discrepancies[i].Kind != kind {
+					t.Fatalf("discrepancy %d is %+v, want %s", i, match.Discrepancies[i], kind)
+				}
+			}
+			if match.ExpectedAmount != tc.expected || match.InvoicedAmount != tc.invoiced {
+				t.Fatalf("expected %.2f and invoiced %.2f, want %.2f and %.2f", match.ExpectedAmount, match.InvoicedAmount, tc.expected, tc.invoiced)
+			}
+			if match.Invoices != len(tc.invoices) || match.OrderedQuantity != 10 || !match.MatchedAt.Equal(invoicedAt) {
+				t.Fatalf("match %+v", match)
+			}
+			if (match.ReceivedQuantity == nil) != (tc.receipt == nil) {
+				t.Fatalf("received quantity %v with receipt %v", match.ReceivedQuantity, tc.receipt)
+			}
+		})
+	}
+}
+
+func TestNewInvoiceMismatchDetectedEvent(t *testing.T) {
+	purchaseOrder := &PurchaseOrder{ID: "po-1", TenantID: "tenant-1", ProductID: "product-1", SupplierID: "supplier-1", Quantity: 10}
+	purchaseOrder.InvoiceMatch = &InvoiceMatch{Status: InvoiceMatchMismatch}
+
+	event := NewInvoiceMismatchDetectedEvent(purchaseOrder, invoicedAt)
+	if event.ID == "" || event.EventType != InvoiceMismatchDetectedEventType || event.TenantID != "tenant-1" || event.PurchaseOrderID != "po-1" || event.SupplierID != "supplier-1" || event.Match != purchaseOrder.InvoiceMatch || event.Metadata == nil {
+		t.Fatalf("event %+v", event)
+	}
+}
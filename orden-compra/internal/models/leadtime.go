@@ -0,0 +1,95 @@
+package models
+
+import (
+	"math"
+	"time"
+)
+
+// SLA metadata keys recorded on purchase orders
+const (
+	MetadataSLALeadTimeDays = "sla_lead_time_days"
+	MetadataSLASource       = "sla_source"
+	// MetadataOverdueDetectedAt marks orders already reported as overdue
+	MetadataOverdueDetectedAt = "overdue_detected_at"
+)
+
+// PurchaseOrderOverdueEventType is recorded when an order misses its expected date
+const PurchaseOrderOverdueEventType = "PurchaseOrderOverdue"
+
+// SLA sources
+const (
+	SLASourceSupplierCatalog = "supplier_catalog"
+	SLASourceDefault         = "default"
+)
+
+// LeadTimePolicy turns supplier lead times into expected delivery dates
+type LeadTimePolicy struct {
+	// DefaultDays is used when the supplier is not in the catalog or has no lead time
+	DefaultDays int
+	// CriticalFactor shortens the lead time of critical orders when the supplier
+	// has no explicit expedited lead time
+	CriticalFactor float64
+	// MinimumDays is the shortest lead time ever promised
+	MinimumDays int
+}
+
+// DefaultLeadTimePolicy returns the historical 7-day lead time
+func DefaultLeadTimePolicy() LeadTimePolicy {
+	return LeadTimePolicy{
+		DefaultDays:    7,
+		CriticalFactor: 0.5,
+		MinimumDays:    1,
+	}
+}
+
+// LeadTimeDays returns the lead time for an order and where it came from.
+// The supplier may be nil when it is not in the catalog. A lead time on the
+// supplier's mapping of the product takes precedence over the supplier's.
+func (p LeadTimePolicy) LeadTimeDays(supplier *Supplier, productID, urgencyLevel string) (int, string) {
+	days := p.DefaultDays
+	source := SLASourceDefault
+	if supplier != nil && supplier.LeadTimeDays > 0 {
+		days = supplier.LeadTimeDays
+		source = SLASourceSupplierCatalog
+	}
+	if supplier != nil {
+		if product := supplier.Product(productID); product != nil && product.LeadTimeDays > 0 {
+			days = product.LeadTimeDays
+			source = SLASourceSupplierCatalog
+		}
+	}
+
+	if urgencyLevel == "critical" {
+		if supplier != nil && supplier.CriticalLeadTimeDays > 0 {
+			days = supplier.CriticalLeadTimeDays
+			source = SLASourceSupplierCatalog
+		} else if p.CriticalFactor > 0 {
+			days = int(math.Ceil(float64(days) * p.CriticalFactor))
+		}
+	}
+
+	if days < p.MinimumDays {
+		days = p.MinimumDays
+	}
+	return days, source
+}
+
+// ApplySLA sets the expected date from the lead time and records the SLA on the order
+func (po *PurchaseOrder) ApplySLA(from time.Time, leadTimeDays int, source string) {
+	expectedDate := from.AddDate(0, 0, leadTimeDays)
+	po.ExpectedDate = &expectedDate
+	po.Metadata[MetadataSLALeadTimeDays] = leadTimeDays
+	po.Metadata[MetadataSLASource] = source
+}
+
+// SLALeadTimeDays returns the lead time recorded on the order, if any
+func (po *PurchaseOrder) SLALeadTimeDays() (int, bool) {
+	switch v := po.Metadata[MetadataSLALeadTimeDays].(type) {
+	case int:
+		return v, true
+	case float64:
+		return int(v), true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLeadTimeDays(t *testing.T) {
+	policy := DefaultLeadTimePolicy()
+	supplier := &Supplier{
+		LeadTimeDays: 10,
+		Products:     []SupplierProduct{{ProductID: "product-2", LeadTimeDays: 4}},
+	}
+	expedited := &Supplier{LeadTimeDays: 10, CriticalLeadTimeDays: 2}
+
+	for _, tc := range []struct {
+		name      string
+		policy    LeadTimePolicy
+		supplier  *Supplier
+		productID string
+		urgency   string
+		days      int
+		source    string
+	}{
+		{"not in the catalog", policy, nil, "product-1", "high", 7, SLASourceDefault},
+		{"supplier without a lead time", policy, &Supplier{}, "product-1", "high", 7, SLASourceDefault},
+		{"supplier lead time", policy, supplier, "product-1", "high", 10, SLASourceSupplierCatalog},
+		{"product lead time", policy, supplier, "product-2", "high", 4, SLASourceSupplierCatalog},
+		{"critical shortened by the factor", policy, supplier, "product-1", "critical", 5, SLASourceSupplierCatalog},
+		{"critical factor rounds up", policy, nil, "product-1", "critical", 4, SLASourceDefault},
+		{"expedited lead time", policy, expedited, "product-1", "critical", 2, SLASourceSupplierCatalog},
+		{"never below the minimum", LeadTimePolicy{DefaultDays: 1, CriticalFactor: 0.1, MinimumDays: 1}, nil, "product-1", "critical", 1, SLASourceDefault},
+		{"no critical factor", LeadTimePolicy{DefaultDays: 6}, nil, "product-1", "critical", 6, SLASourceDefault},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			days, source := tc.policy.LeadTimeDays(tc.supplier, tc.productID, tc.urgency)
+			if days != tc.days || source != tc.source {
+				t.Fatalf("LeadTimeDays = %d from %s, want %d from %s", days, source, tc.days, tc.source)
+			}
+		})
+	}
+}
+
+func TestApplySLA(t *testing.T) {
+	from := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	po := &PurchaseOrder{Metadata: map[string]interface{}{}}
+	if _, ok := po.SLALeadTimeDays(); ok {
+		t.Fatal("an order without an SLA has a lead time")
+	}
+
+	po.ApplySLA(from, 5, SLASourceSupplierCatalog)
+	if po.ExpectedDate == nil || !po.ExpectedDate.Equal(from.AddDate(0, 0, 5)) || po.Metadata[MetadataSLASource] != SLASourceSupplierCatalog {
+		t.Fatalf("expected date %v, metadata %v", po.ExpectedDate, po.Metadata)
+	}
+	if days, ok := po.SLALeadTimeDays(); !ok || days != 5 {
+		t.Fatalf("lead time %d, %t", days, ok)
+	}
+
+	// Read back from DynamoDB the lead time is a float64
+	po.Metadata[MetadataSLALeadTimeDays] = 5.0
+	if days, ok := po.SLALeadTimeDays(); !ok || days != 5 {
+		t.Fatalf("lead time read back %d, %t", days, ok)
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+
+	"medisupply/events"
 )
 
 // EventType represents the type of event
@@ -18,6 +20,7 @@ const (
 // StockLowEvent represents a stock low event from MovimientoInventario
 type StockLowEvent struct {
 	ID           string                 `json:"id" dynamodbav:"id"`
+	TenantID     string                 `json:"tenant_id,omitempty" dynamodbav:"tenant_id,omitempty"`
 	Timestamp    time.Time              `json:"timestamp" dynamodbav:"timestamp"`
 	EventType    EventType             `json:"event_type" dynamodbav:"event_type"`
 	ProductID    string                `json:"product_id" dynamodbav:"product_id"`
@@ -32,6 +35,7 @@ type StockLowEvent struct {
 // PurchaseOrder represents a purchase order
 type PurchaseOrder struct {
 	ID              string                 `json:"id" dynamodbav:"id"`
+	TenantID        string                 `json:"tenant_id,omitempty" dynamodbav:"tenant_id,omitempty"`
 	ProductID       string                 `json:"product_id" dynamodbav:"product_id"`
 	ProductName     string                 `json:"product_name" dynamodbav:"product_name"`
 	Quantity        int                    `json:"quantity" dynamodbav:"quantity"`
@@ -42,27 +46,45 @@ type PurchaseOrder struct {
 	UrgencyLevel    string                 `json:"urgency_level" dynamodbav:"urgency_level"`
 	CreatedAt       time.Time              `json:"created_at" dynamodbav:"created_at"`
 	UpdatedAt       time.Time              `json:"updated_at" dynamodbav:"updated_at"`
+	Version         int                    `json:"version" dynamodbav:"version"`
 	ExpectedDate    *time.Time             `json:"expected_date,omitempty" dynamodbav:"expected_date,omitempty"`
 	ActualDate      *time.Time             `json:"actual_date,omitempty" dynamodbav:"actual_date,omitempty"`
+	ASN             *AdvanceShipmentNotice `json:"asn,omitempty" dynamodbav:"asn,omitempty"`
+	Dispatch        *DispatchStatus        `json:"dispatch,omitempty" dynamodbav:"dispatch,omitempty"`
+	Acknowledgement *OrderAcknowledgement  `json:"acknowledgement,omitempty" dynamodbav:"acknowledgement,omitempty"`
+	Receipt         *InventoryReceipt      `json:"receipt,omitempty" dynamodbav:"receipt,omitempty"`
+	Pricing         *OrderPricing          `json:"pricing,omitempty" dynamodbav:"pricing,omitempty"`
+	BlanketOrderID  string                 `json:"blanket_order_id,omitempty" dynamodbav:"blanket_order_id,omitempty"`
+	Comments        []OrderComment         `json:"comments,omitempty" dynamodbav:"comments,omitempty"`
+	Attachments     []OrderAttachment      `json:"attachments,omitempty" dynamodbav:"attachments,omitempty"`
+	Invoices        []SupplierInvoice      `json:"invoices,omitempty" dynamodbav:"invoices,omitempty"`
+	InvoiceMatch    *InvoiceMatch          `json:"invoice_match,omitempty" dynamodbav:"invoice_match,omitempty"`
 	Metadata        map[string]interface{} `json:"metadata" dynamodbav:"metadata"`
 }
 
 // Supplier represents a supplier
 type Supplier struct {
-	ID          string                 `json:"id" dynamodbav:"id"`
-	Name        string                 `json:"name" dynamodbav:"name"`
-	Email       string                 `json:"email" dynamodbav:"email"`
-	Phone       string                 `json:"phone" dynamodbav:"phone"`
-	Address     string                 `json:"address" dynamodbav:"address"`
-	IsActive    bool                   `json:"is_active" dynamodbav:"is_active"`
-	CreatedAt   time.Time              `json:"created_at" dynamodbav:"created_at"`
-	UpdatedAt   time.Time              `json:"updated_at" dynamodbav:"updated_at"`
-	Metadata    map[string]interface{} `json:"metadata" dynamodbav:"metadata"`
+	ID                   string                 `json:"id" dynamodbav:"id"`
+	TenantID             string                 `json:"tenant_id,omitempty" dynamodbav:"tenant_id,omitempty"`
+	Name                 string                 `json:"name" dynamodbav:"name"`
+	Email                string                 `json:"email" dynamodbav:"email"`
+	Phone                string                 `json:"phone" dynamodbav:"phone"`
+	Address              string                 `json:"address" dynamodbav:"address"`
+	IsActive             bool                   `json:"is_active" dynamodbav:"is_active"`
+	LeadTimeDays         int                    `json:"lead_time_days" dynamodbav:"lead_time_days"`
+	CriticalLeadTimeDays int                    `json:"critical_lead_time_days,omitempty" dynamodbav:"critical_lead_time_days,omitempty"`
+	Products             []SupplierProduct      `json:"products,omitempty" dynamodbav:"products,omitempty"`
+	Contacts             []SupplierContact      `json:"contacts,omitempty" dynamodbav:"contacts,omitempty"`
+	EscalationRules      []EscalationRule       `json:"escalation_rules,omitempty" dynamodbav:"escalation_rules,omitempty"`
+	CreatedAt            time.Time              `json:"created_at" dynamodbav:"created_at"`
+	UpdatedAt            time.Time              `json:"updated_at" dynamodbav:"updated_at"`
+	Metadata             map[string]interface{} `json:"metadata" dynamodbav:"metadata"`
 }
 
 // RecepcionProveedorEvent represents a supplier reception event
 type RecepcionProveedorEvent struct {
 	ID              string                 `json:"id" dynamodbav:"id"`
+	TenantID        string                 `json:"tenant_id,omitempty" dynamodbav:"tenant_id,omitempty"`
 	Timestamp       time.Time              `json:"timestamp" dynamodbav:"timestamp"`
 	EventType       EventType             `json:"event_type" dynamodbav:"event_type"`
 	PurchaseOrderID string                 `json:"purchase_order_id" dynamodbav:"purchase_order_id"`
@@ -76,23 +98,15 @@ type RecepcionProveedorEvent struct {
 	Metadata        map[string]interface{} `json:"metadata" dynamodbav:"metadata"`
 }
 
-// EventSourcingEvent represents an event sourcing event
-type EventSourcingEvent struct {
-	ID            string                 `json:"id" dynamodbav:"id"`
-	AggregateID   string                 `json:"aggregate_id" dynamodbav:"aggregate_id"`
-	EventType     string                 `json:"event_type" dynamodbav:"event_type"`
-	EventData     map[string]interface{} `json:"event_data" dynamodbav:"event_data"`
-	Timestamp     time.Time              `json:"timestamp" dynamodbav:"timestamp"`
-	Version       int                    `json:"version" dynamodbav:"version"`
-	CorrelationID *string                `json:"correlation_id,omitempty" dynamodbav:"correlation_id,omitempty"`
-	CausationID   *string                `json:"causation_id,omitempty" dynamodbav:"causation_id,omitempty"`
-}
+// EventSourcingEvent represents an event sourcing event, in the envelope
+// shared with proveedor
+type EventSourcingEvent = events.Event
 
 // NewStockLowEvent creates a new StockLowEvent
-func NewStockLowEvent(productID, productName, location, urgencyLevel string, currentStock, minimumStock int) *StockLowEvent {
+func NewStockLowEvent(productID, productName, location, urgencyLevel string, currentStock, minimumStock int, now time.Time) *StockLowEvent {
 	return &StockLowEvent{
 		ID:           uuid.New().String(),
-		Timestamp:    time.Now().UTC(),
+		Timestamp:    now.UTC(),
 		EventType:    StockLowEventType,
 		ProductID:    productID,
 		ProductName:  productName,
@@ -105,8 +119,8 @@ func NewStockLowEvent(productID, productName, location, urgencyLevel string, cur
 }
 
 // NewPurchaseOrder creates a new PurchaseOrder
-func NewPurchaseOrder(productID, productName, supplierID, supplierName, location, urgencyLevel string, quantity int) *PurchaseOrder {
-	now := time.Now().UTC()
+func NewPurchaseOrder(productID, productName, supplierID, supplierName, location, urgencyLevel string, quantity int, now time.Time) *PurchaseOrder {
+	now = now.UTC()
 	expectedDate := now.AddDate(0, 0, 7) // Default 7 days from now
 	
 	return &PurchaseOrder{
@@ -117,7 +131,7 @@ func NewPurchaseOrder(productID, productName, supplierID, supplierName, location
 		SupplierID:   supplierID,
 		SupplierName: supplierName,
 		Location:     location,
-		Status:       "pending",
+		Status:       StatusPending,
 		UrgencyLevel: urgencyLevel,
 		CreatedAt:    now,
 		UpdatedAt:    now,
@@ -127,10 +141,10 @@ func NewPurchaseOrder(productID, productName, supplierID, supplierName, location
 }
 
 // NewRecepcionProveedorEvent creates a new RecepcionProveedorEvent
-func NewRecepcionProveedorEvent(purchaseOrderID, productID, productName, supplierID, supplierName, location, status string, quantity int) *RecepcionProveedorEvent {
+func NewRecepcionProveedorEvent(purchaseOrderID, productID, productName, supplierID, supplierName, location, status string, quantity int, now time.Time) *RecepcionProveedorEvent {
 	return &RecepcionProveedorEvent{
 		ID:              uuid.New().String(),
-		Timestamp:       time.Now().UTC(),
+		Timestamp:       now.UTC(),
 		EventType:       PurchaseOrderEventType,
 		PurchaseOrderID: purchaseOrderID,
 		ProductID:       productID,
@@ -144,37 +158,16 @@ func NewRecepcionProveedorEvent(purchaseOrderID, productID, productName, supplie
 	}
 }
 
-// NewEventSourcingEvent creates a new EventSourcingEvent
-func NewEventSourcingEvent(aggregateID, eventType string, eventData map[string]interface{}, correlationID, causationID *string) *EventSourcingEvent {
-	return &EventSourcingEvent{
-		ID:            uuid.New().String(),
-		AggregateID:   aggregateID,
-		EventType:     eventType,
-		EventData:     eventData,
-		Timestamp:     time.Now().UTC(),
-		Version:       1,
-		CorrelationID: correlationID,
-		CausationID:   causationID,
-	}
+// NewEventSourcingEvent creates a new EventSourcingEvent that happened at now
+func NewEventSourcingEvent(aggregateID, eventType string, eventData map[string]interface{}, correlationID, causationID *string, now time.Time) *EventSourcingEvent {
+	event := events.New(aggregateID, eventType, EventDataRedactor.Map(eventData), correlationID, causationID, now)
+	event.SchemaVersion = EventSchemas.CurrentVersion(eventType)
+	return event
 }
 
-// CalculateQuantity calculates the quantity to order based on urgency level
+// CalculateQuantity calculates the quantity to order using the default reorder strategy
 func (s *StockLowEvent) CalculateQuantity() int {
-	baseQuantity := s.MinimumStock * 2 // Order 2x minimum stock
-	
-	urgencyMultipliers := map[string]float64{
-		"low":      1.0,
-		"medium":   1.5,
-		"high":     2.0,
-		"critical": 3.0,
-	}
-	
-	multiplier := urgencyMultipliers[s.UrgencyLevel]
-	if multiplier == 0 {
-		multiplier = 1.0
-	}
-	
-	return int(float64(baseQuantity) * multiplier)
+	return DefaultReorderStrategy().CalculateQuantity(s)
 }
 
 // GetSupplierID returns the supplier ID for the product
@@ -191,26 +184,37 @@ func (s *StockLowEvent) GetSupplierName() string {
 	return "Default Supplier"
 }
 
-// UpdateStatus updates the purchase order status
-func (po *PurchaseOrder) UpdateStatus(status string) {
+// UpdateStatus moves the purchase order to a new status, rejecting illegal transitions
+func (po *PurchaseOrder) UpdateStatus(status string, now time.Time) error {
+	if err := ValidateTransition(po.Status, status); err != nil {
+		return err
+	}
+
 	po.Status = status
-	po.UpdatedAt = time.Now().UTC()
+	po.UpdatedAt = now.UTC()
 	
-	if status == "received" {
-		now := time.Now().UTC()
-		po.ActualDate = &now
+	if status == StatusReceived {
+		receivedAt := now.UTC()
+		po.ActualDate = &receivedAt
 	}
+
+	return nil
 }
 
 // IsCompleted checks if the purchase order is completed
 func (po *PurchaseOrder) IsCompleted() bool {
-	return po.Status == "received" || po.Status == "completed"
+	return po.Status == StatusReceived || po.Status == StatusCompleted
 }
 
-// IsOverdue checks if the purchase order is overdue
-func (po *PurchaseOrder) IsOverdue() bool {
+// IsOverdue checks if the purchase order is past the expected date promised by its SLA.
+// Orders still awaiting approval or already closed are never overdue.
+func (po *PurchaseOrder) IsOverdue(now time.Time) bool {
 	if po.ExpectedDate == nil {
 		return false
 	}
-	return time.Now().UTC().After(*po.ExpectedDate) && !po.IsCompleted()
+	switch po.Status {
+	case StatusPendingApproval, StatusRejected, StatusCancelled:
+		return false
+	}
+	return now.UTC().After(*po.ExpectedDate) && !po.IsCompleted()
 }
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OrderPlacedForProductEventType is the type of the event telling
+// MovimientoInventario a product is on order
+const OrderPlacedForProductEventType EventType = "OrderPlacedForProduct"
+
+// OnOrderStatuses returns the statuses in which an order's quantity is on its
+// way: placed and neither received nor closed
+func OnOrderStatuses() []string {
+	return []string{StatusPending, StatusPendingApproval, StatusApproved, StatusSent}
+}
+
+// IsOnOrder checks if the purchase order's quantity counts as on order
+func (po *PurchaseOrder) IsOnOrder() bool {
+	for _, status := range OnOrderStatuses() {
+		if po.Status == status {
+			return true
+		}
+	}
+	return false
+}
+
+// OrderPlacedForProductEvent acknowledges a StockBajo event by telling
+// MovimientoInventario how much of the product is on order at the location,
+// so it shows the quantity and stops raising the same shortage. Quantity is
+// that of the order the event placed or topped up; QuantityOnOrder adds the
+// other orders on order for the product and location.
+type OrderPlacedForProductEvent struct {
+	ID              string                 `json:"id" dynamodbav:"id"`
+	TenantID        string                 `json:"tenant_id,omitempty" dynamodbav:"tenant_id,omitempty"`
+	Timestamp       time.Time              `json:"timestamp" dynamodbav:"timestamp"`
+	EventType       EventType              `json:"event_type" dynamodbav:"event_type"`
+	ProductID       string                 `json:"product_id" dynamodbav:"product_id"`
+	Location        string                 `json:"location" dynamodbav:"location"`
+	PurchaseOrderID string                 `json:"purchase_order_id" dynamodbav:"purchase_order_id"`
+	StockLowEventID string                 `json:"stock_low_event_id" dynamodbav:"stock_low_event_id"`
+	Status          string                 `json:"status" dynamodbav:"status"`
+	Quantity        int                    `json:"quantity" dynamodbav:"quantity"`
+	QuantityOnOrder int                    `json:"quantity_on_order" dynamodbav:"quantity_on_order"`
+	ExpectedDate    *time.Time             `json:"expected_date,omitempty" dynamodbav:"expected_date,omitempty"`
+	Metadata        map[string]interface{} `json:"metadata" dynamodbav:"metadata"`
+}
+
+// NewOrderPlacedForProductEvent creates a new OrderPlacedForProductEvent for
+// an order placed or topped up from a StockBajo event
+func NewOrderPlacedForProductEvent(purchaseOrder *PurchaseOrder, stockLowEventID string, quantityOnOrder int, now time.Time) *OrderPlacedForProductEvent {
+	return &OrderPlacedForProductEvent{
+		ID:              uuid.New().String(),
+		TenantID:        purchaseOrder.TenantID,
+		Timestamp:       now.UTC(),
+		EventType:       OrderPlacedForProductEventType,
+		ProductID:       purchaseOrder.ProductID,
+		Location:        purchaseOrder.Location,
+		PurchaseOrderID: purchaseOrder.ID,
+		StockLowEventID: stockLowEventID,
+		Status:          purchaseOrder.Status,
+		Quantity:        purchaseOrder.Quantity,
+		QuantityOnOrder: quantityOnOrder,
+		ExpectedDate:    purchaseOrder.ExpectedDate,
+		Metadata:        make(map[string]interface{}),
+	}
+}
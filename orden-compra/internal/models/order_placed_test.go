@@ -0,0 +1,20 @@
+package models
+
+import "testing"
+
+func TestIsOnOrder(t *testing.T) {
+	for status, onOrder := range map[string]bool{
+		StatusPending:         true,
+		StatusPendingApproval: true,
+		StatusApproved:        true,
+		StatusSent:            true,
+		StatusReceived:        false,
+		StatusCompleted:       false,
+		StatusCancelled:       false,
+		StatusRejected:        false,
+	} {
+		if got := (&PurchaseOrder{Status: status}).IsOnOrder(); got != onOrder {
+			t.Errorf("%s order on order = %v, want %v", status, got, onOrder)
+		}
+	}
+}
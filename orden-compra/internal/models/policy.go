@@ -0,0 +1,18 @@
+package models
+
+// PurchaseOrderPolicy groups the rules applied when turning StockBajo events into orders
+type PurchaseOrderPolicy struct {
+	Approval   ApprovalPolicy
+	Duplicates DuplicatePolicy
+	// Consolidate holds routine orders back so they can be batched per supplier
+	Consolidate bool
+	// Reorder calculates order quantities when no product override applies
+	Reorder ReorderStrategy
+	// ProductOverrides enables per-product strategies from the reorder policy table
+	ProductOverrides bool
+	// LeadTimes computes expected delivery dates from supplier lead times
+	LeadTimes LeadTimePolicy
+	// DryRun computes the supplier, quantity and price of each order without
+	// storing or publishing anything, to tune strategies against real events
+	DryRun bool
+}
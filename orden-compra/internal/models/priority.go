@@ -0,0 +1,58 @@
+package models
+
+import "fmt"
+
+// MaxMessagePriority is the highest x-max-priority RabbitMQ supports
+const MaxMessagePriority = 255
+
+// MessagePriorityPolicy maps urgency levels onto AMQP message priorities so
+// critical work is delivered ahead of routine work
+type MessagePriorityPolicy struct {
+	// MaxPriority is declared as x-max-priority on the consumed queue; 0
+	// declares a queue without priorities and publishes without them
+	MaxPriority int
+	// Levels maps each urgency level to its priority; unknown levels get
+	// the medium priority
+	Levels map[string]int
+}
+
+// DefaultMessagePriorityPolicy spreads the urgency levels over priorities 1 to 9
+func DefaultMessagePriorityPolicy() MessagePriorityPolicy {
+	return MessagePriorityPolicy{
+		MaxPriority: 10,
+		Levels: map[string]int{
+			"critical": 9,
+			"high":     6,
+			"medium":   3,
+			"low":      1,
+		},
+	}
+}
+
+// Validate checks the priorities fit the queue's x-max-priority
+func (p MessagePriorityPolicy) Validate() error {
+	if p.MaxPriority < 0 || p.MaxPriority > MaxMessagePriority {
+		return fmt.Errorf("max priority must be between 0 and %d", MaxMessagePriority)
+	}
+	for level, priority := range p.Levels {
+		if !ValidUrgencyLevels[level] {
+			return fmt.Errorf("unknown urgency level %q", level)
+		}
+		if priority < 0 || priority > p.MaxPriority {
+			return fmt.Errorf("priority of %s urgency must be between 0 and %d", level, p.MaxPriority)
+		}
+	}
+	return nil
+}
+
+// Priority returns the message priority of an urgency level
+func (p MessagePriorityPolicy) Priority(urgencyLevel string) uint8 {
+	if p.MaxPriority == 0 {
+		return 0
+	}
+	priority, ok := p.Levels[urgencyLevel]
+	if !ok {
+		priority = p.Levels["medium"]
+	}
+	return uint8(min(priority, p.MaxPriority))
+}
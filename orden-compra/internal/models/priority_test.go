@@ -0,0 +1,38 @@
+package models
+
+import "testing"
+
+func TestMessagePriorityPolicyValidate(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		policy MessagePriorityPolicy
+		valid  bool
+	}{
+		{"default", DefaultMessagePriorityPolicy(), true},
+		{"without priorities", MessagePriorityPolicy{}, true},
+		{"max above RabbitMQ's", MessagePriorityPolicy{MaxPriority: MaxMessagePriority + 1}, false},
+		{"unknown level", MessagePriorityPolicy{MaxPriority: 10, Levels: map[string]int{"urgent": 9}}, false},
+		{"priority above the max", MessagePriorityPolicy{MaxPriority: 5, Levels: map[string]int{"critical": 9}}, false},
+		{"negative priority", MessagePriorityPolicy{MaxPriority: 5, Levels: map[string]int{"low": -1}}, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.policy.Validate(); (err == nil) != tc.valid {
+				t.Fatalf("Validate() = %v, want valid %v", err, tc.valid)
+			}
+		})
+	}
+}
+
+func TestMessagePriority(t *testing.T) {
+	policy := DefaultMessagePriorityPolicy()
+	for level, want := range map[string]uint8{"critical": 9, "high": 6, "medium": 3, "low": 1, "": 3, "unknown": 3} {
+		if got := policy.Priority(level); got != want {
+			t.Errorf("priority of %q urgency %d, want %d", level, got, want)
+		}
+	}
+
+	// Without priorities every message is published at 0
+	if got := (MessagePriorityPolicy{Levels: policy.Levels}).Priority("critical"); got != 0 {
+		t.Fatalf("priority %d without a max priority, want 0", got)
+	}
+}
@@ -0,0 +1,200 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PurchaseOrderCompletedEventType is recorded and published when the
+// inventory of a purchase order is received
+const PurchaseOrderCompletedEventType EventType = "PurchaseOrderCompleted"
+
+// PurchaseOrderPartiallyReceivedEventType is recorded when a delivery
+// leaves part of the ordered quantity outstanding
+const PurchaseOrderPartiallyReceivedEventType EventType = "PurchaseOrderPartiallyReceived"
+
+// Outcomes of reconciling the received quantity with the ordered quantity
+const (
+	ReconciliationMatched = "matched"
+	ReconciliationShort   = "short"
+	ReconciliationOver    = "over"
+)
+
+// QualityCheckPassed is the quality check of receipts that passed inspection
+const QualityCheckPassed = "passed"
+
+// InventoryReceivedEvent is the InventarioRecibido event Proveedor produces
+// once a reception passes inspection
+type InventoryReceivedEvent struct {
+	ID              string                 `json:"id" dynamodbav:"id"`
+	TenantID        string                 `json:"tenant_id,omitempty" dynamodbav:"tenant_id,omitempty"`
+	Timestamp       time.Time              `json:"timestamp" dynamodbav:"timestamp"`
+	EventType       EventType              `json:"event_type" dynamodbav:"event_type"`
+	PurchaseOrderID string                 `json:"purchase_order_id" dynamodbav:"purchase_order_id"`
+	ProductID       string                 `json:"product_id" dynamodbav:"product_id"`
+	ProductName     string                 `json:"product_name" dynamodbav:"product_name"`
+	Quantity        int                    `json:"quantity" dynamodbav:"quantity"`
+	SupplierID      string                 `json:"supplier_id" dynamodbav:"supplier_id"`
+	SupplierName    string                 `json:"supplier_name" dynamodbav:"supplier_name"`
+	Location        string                 `json:"location" dynamodbav:"location"`
+	Status          string                 `json:"status" dynamodbav:"status"`
+	ReceivedAt      time.Time              `json:"received_at" dynamodbav:"received_at"`
+	QualityCheck    string                 `json:"quality_check" dynamodbav:"quality_check"`
+	Temperature     *float64               `json:"temperature,omitempty" dynamodbav:"temperature,omitempty"`
+	BatchNumber     string                 `json:"batch_number" dynamodbav:"batch_number"`
+	ExpiryDate      *time.Time             `json:"expiry_date,omitempty" dynamodbav:"expiry_date,omitempty"`
+	Metadata        map[string]interface{} `json:"metadata" dynamodbav:"metadata"`
+}
+
+// Validate checks the event for the fields needed to complete its order
+func (e *InventoryReceivedEvent) Validate() error {
+	var errs ValidationErrors
+
+	if strings.TrimSpace(e.ID) == "" {
+		errs.add("id", "is required")
+	}
+	if e.EventType != "" && e.EventType != SupplierEventType {
+		errs.add("event_type", fmt.Sprintf("must be %s, got %s", SupplierEventType, e.EventType))
+	}
+	if strings.TrimSpace(e.PurchaseOrderID) == "" {
+		errs.add("purchase_order_id", "is required")
+	}
+	if e.Quantity < 0 {
+		errs.add("quantity", fmt.Sprintf("must not be negative, got %d", e.Quantity))
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// InventoryReceipt records the inventory received for a purchase order and
+// how it compares with what was ordered. ReceivedQuantity sums every
+// delivery counted, DeliveredQuantity is the last one's, and Variance is the
+// received minus the ordered quantity.
+type InventoryReceipt struct {
+	EventID           string     `json:"event_id" dynamodbav:"event_id"`
+	OrderedQuantity   int        `json:"ordered_quantity" dynamodbav:"ordered_quantity"`
+	ReceivedQuantity  int        `json:"received_quantity" dynamodbav:"received_quantity"`
+	DeliveredQuantity int        `json:"delivered_quantity,omitempty" dynamodbav:"delivered_quantity,omitempty"`
+	Variance          int        `json:"variance" dynamodbav:"variance"`
+	Reconciliation    string     `json:"reconciliation" dynamodbav:"reconciliation"`
+	QualityCheck      string     `json:"quality_check,omitempty" dynamodbav:"quality_check,omitempty"`
+	BatchNumber       string     `json:"batch_number,omitempty" dynamodbav:"batch_number,omitempty"`
+	ExpiryDate        *time.Time `json:"expiry_date,omitempty" dynamodbav:"expiry_date,omitempty"`
+	ReceivedAt        time.Time  `json:"received_at" dynamodbav:"received_at"`
+	// EventIDs lists the InventarioRecibido events of the deliveries counted
+	EventIDs []string `json:"event_ids,omitempty" dynamodbav:"event_ids,omitempty"`
+}
+
+// NewInventoryReceipt adds the delivery of an InventarioRecibido event to
+// the previous receipt of the order, nil for its first delivery, and
+// reconciles the quantity received so far with the ordered quantity
+func NewInventoryReceipt(event *InventoryReceivedEvent, orderedQuantity int, previous *InventoryReceipt, now time.Time) *InventoryReceipt {
+	receipt := &InventoryReceipt{
+		EventID:           event.ID,
+		OrderedQuantity:   orderedQuantity,
+		ReceivedQuantity:  event.Quantity,
+		DeliveredQuantity: event.Quantity,
+		Reconciliation:    ReconciliationMatched,
+		QualityCheck:      event.QualityCheck,
+		BatchNumber:       event.BatchNumber,
+		ExpiryDate:        event.ExpiryDate,
+		ReceivedAt:        event.ReceivedAt.UTC(),
+		EventIDs:          []string{event.ID},
+	}
+	if receipt.ReceivedAt.IsZero() {
+		receipt.ReceivedAt = now.UTC()
+	}
+	if previous != nil {
+		receipt.ReceivedQuantity += previous.ReceivedQuantity
+		receipt.EventIDs = append(previous.countedEvents(), event.ID)
+		// A failed inspection of any delivery stays on the receipt
+		if previous.FailedQualityCheck() && !receipt.FailedQualityCheck() {
+			receipt.QualityCheck = previous.QualityCheck
+		}
+	}
+	receipt.Variance = receipt.ReceivedQuantity - orderedQuantity
+
+	switch {
+	case receipt.Variance < 0:
+		receipt.Reconciliation = ReconciliationShort
+	case receipt.Variance > 0:
+		receipt.Reconciliation = ReconciliationOver
+	}
+	return receipt
+}
+
+// Counts reports whether the receipt already counts the delivery of an
+// InventarioRecibido event
+func (r *InventoryReceipt) Counts(eventID string) bool {
+	for _, counted := range r.countedEvents() {
+		if counted == eventID {
+			return true
+		}
+	}
+	return false
+}
+
+// Complete reports whether at least the ordered quantity was received
+func (r *InventoryReceipt) Complete() bool {
+	return r.ReceivedQuantity >= r.OrderedQuantity
+}
+
+// countedEvents returns the events the receipt counts. Receipts recorded
+// before deliveries were summed count their own event only.
+func (r *InventoryReceipt) countedEvents() []string {
+	if len(r.EventIDs) == 0 {
+		return []string{r.EventID}
+	}
+	return append([]string(nil), r.EventIDs...)
+}
+
+// FailedQualityCheck reports whether the receipt records a quality check
+// other than passed. Receipts that do not record one count as passed.
+func (r *InventoryReceipt) FailedQualityCheck() bool {
+	return r.QualityCheck != "" && r.QualityCheck != QualityCheckPassed
+}
+
+// PurchaseOrderCompletedEvent announces that a purchase order's inventory
+// was received and the order is closed
+type PurchaseOrderCompletedEvent struct {
+	ID              string                 `json:"id" dynamodbav:"id"`
+	TenantID        string                 `json:"tenant_id,omitempty" dynamodbav:"tenant_id,omitempty"`
+	Timestamp       time.Time              `json:"timestamp" dynamodbav:"timestamp"`
+	EventType       EventType              `json:"event_type" dynamodbav:"event_type"`
+	PurchaseOrderID string                 `json:"purchase_order_id" dynamodbav:"purchase_order_id"`
+	ProductID       string                 `json:"product_id" dynamodbav:"product_id"`
+	SupplierID      string                 `json:"supplier_id" dynamodbav:"supplier_id"`
+	Location        string                 `json:"location" dynamodbav:"location"`
+	PreviousStatus  string                 `json:"previous_status" dynamodbav:"previous_status"`
+	ActualDate      time.Time              `json:"actual_date" dynamodbav:"actual_date"`
+	Receipt         *InventoryReceipt      `json:"receipt" dynamodbav:"receipt"`
+	Metadata        map[string]interface{} `json:"metadata" dynamodbav:"metadata"`
+}
+
+// NewPurchaseOrderCompletedEvent creates a new PurchaseOrderCompletedEvent
+// for an order whose receipt is recorded
+func NewPurchaseOrderCompletedEvent(purchaseOrder *PurchaseOrder, previousStatus string, now time.Time) *PurchaseOrderCompletedEvent {
+	event := &PurchaseOrderCompletedEvent{
+		ID:              uuid.New().String(),
+		TenantID:        purchaseOrder.TenantID,
+		Timestamp:       now.UTC(),
+		EventType:       PurchaseOrderCompletedEventType,
+		PurchaseOrderID: purchaseOrder.ID,
+		ProductID:       purchaseOrder.ProductID,
+		SupplierID:      purchaseOrder.SupplierID,
+		Location:        purchaseOrder.Location,
+		PreviousStatus:  previousStatus,
+		Receipt:         purchaseOrder.Receipt,
+		Metadata:        make(map[string]interface{}),
+	}
+	if purchaseOrder.ActualDate != nil {
+		event.ActualDate = *purchaseOrder.ActualDate
+	}
+	return event
+}
@@ -0,0 +1,33 @@
+package models
+
+// Kinds of drift between the read model and the event store
+const (
+	// DriftMissing is an order with events but no read model item
+	DriftMissing = "missing"
+	// DriftStaleStatus is a read model item behind its events with another status
+	DriftStaleStatus = "stale_status"
+	// DriftStale is a read model item behind its events with the same status
+	DriftStale = "stale"
+)
+
+// DriftKinds returns the kinds of read model drift
+func DriftKinds() []string {
+	return []string{DriftMissing, DriftStaleStatus, DriftStale}
+}
+
+// ReadModelDrift is a purchase order whose read model item differs from the
+// state its latest event recorded. Versions are 0 for orders stored before
+// they were versioned.
+type ReadModelDrift struct {
+	PurchaseOrderID string `json:"purchase_order_id"`
+	TenantID        string `json:"tenant_id,omitempty"`
+	Kind            string `json:"kind"`
+	EventID         string `json:"event_id"`
+	EventType       string `json:"event_type"`
+	EventStatus     string `json:"event_status"`
+	EventVersion    int    `json:"event_version"`
+	ReadStatus      string `json:"read_status,omitempty"`
+	ReadVersion     int    `json:"read_version,omitempty"`
+	Repaired        bool   `json:"repaired"`
+	RepairError     string `json:"repair_error,omitempty"`
+}
@@ -0,0 +1,8 @@
+package models
+
+import "orden-compra/internal/redact"
+
+// EventDataRedactor masks personal data in the event_data of event sourcing
+// events before it is persisted. The full values stay in the supplier catalog
+// and read model; a nil redactor keeps event data verbatim.
+var EventDataRedactor *redact.Redactor
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"orden-compra/internal/redact"
+)
+
+func TestEventDataIsRedactedBeforeItIsPersisted(t *testing.T) {
+	data := map[string]interface{}{"supplier_id": "supplier-1", "contact_email": "ana@acme.com"}
+
+	if event := NewEventSourcingEvent("po-1", "SupplierUpdated", data, nil, nil, time.Now()); event.EventData["contact_email"] != "ana@acme.com" {
+		t.Fatalf("event data %v redacted without a redactor", event.EventData)
+	}
+
+	EventDataRedactor = redact.New(redact.DefaultFields)
+	t.Cleanup(func() { EventDataRedactor = nil })
+
+	event := NewEventSourcingEvent("po-1", "SupplierUpdated", data, nil, nil, time.Now())
+	if event.EventData["contact_email"] != redact.Mask || event.EventData["supplier_id"] != "supplier-1" {
+		t.Fatalf("event data %v", event.EventData)
+	}
+	if data["contact_email"] != "ana@acme.com" {
+		t.Fatal("redaction changed the caller's data")
+	}
+}
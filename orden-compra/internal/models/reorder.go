@@ -0,0 +1,220 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Reorder strategy names
+const (
+	ReorderFixedMultiplier = "fixed_multiplier"
+	ReorderUpToMax         = "up_to_max"
+	ReorderEOQ             = "eoq"
+	ReorderFixedQuantity   = "fixed_quantity"
+)
+
+// Reorder metadata keys recorded on purchase orders
+const (
+	MetadataReorderStrategy = "reorder_strategy"
+	MetadataReorderSource   = "reorder_policy_source"
+)
+
+// Reorder policy sources
+const (
+	ReorderSourceDefault         = "default"
+	ReorderSourceProductOverride = "product_override"
+)
+
+// ReorderStrategy calculates how much to order for a StockBajo event
+type ReorderStrategy interface {
+	Name() string
+	CalculateQuantity(event *StockLowEvent) int
+}
+
+// FixedMultiplierStrategy orders a multiple of the minimum stock scaled by urgency
+type FixedMultiplierStrategy struct {
+	BaseFactor         float64
+	UrgencyMultipliers map[string]float64
+}
+
+// DefaultReorderStrategy returns the historical 2x minimum stock policy
+func DefaultReorderStrategy() *FixedMultiplierStrategy {
+	return &FixedMultiplierStrategy{
+		BaseFactor: 2,
+		UrgencyMultipliers: map[string]float64{
+			"low":      1.0,
+			"medium":   1.5,
+			"high":     2.0,
+			"critical": 3.0,
+		},
+	}
+}
+
+// Name returns the strategy name
+func (s *FixedMultiplierStrategy) Name() string {
+	return ReorderFixedMultiplier
+}
+
+// CalculateQuantity returns BaseFactor x minimum stock x urgency multiplier
+func (s *FixedMultiplierStrategy) CalculateQuantity(event *StockLowEvent) int {
+	multiplier := s.UrgencyMultipliers[event.UrgencyLevel]
+	if multiplier == 0 {
+		multiplier = 1.0
+	}
+
+	return int(float64(event.MinimumStock) * s.BaseFactor * multiplier)
+}
+
+// UpToMaxStrategy orders enough to refill the location up to its maximum stock.
+// The maximum comes from the event's maximum_stock metadata, or MaxStockFactor
+// times the minimum stock when the producer does not send it.
+type UpToMaxStrategy struct {
+	MaxStockFactor float64
+}
+
+// Name returns the strategy name
+func (s *UpToMaxStrategy) Name() string {
+	return ReorderUpToMax
+}
+
+// CalculateQuantity returns maximum stock minus current stock
+func (s *UpToMaxStrategy) CalculateQuantity(event *StockLowEvent) int {
+	maxStock, ok := event.metadataNumber("maximum_stock")
+	if !ok {
+		maxStock = float64(event.MinimumStock) * s.MaxStockFactor
+	}
+
+	quantity := int(maxStock) - event.CurrentStock
+	if quantity < 1 {
+		return 1
+	}
+	return quantity
+}
+
+// EOQStrategy orders the economic order quantity sqrt(2DS/H), where D is the
+// annual demand sent in the event's annual_demand metadata. Events without a
+// demand figure fall back to another strategy.
+type EOQStrategy struct {
+	OrderingCost float64
+	HoldingCost  float64
+	Fallback     ReorderStrategy
+}
+
+// Name returns the strategy name
+func (s *EOQStrategy) Name() string {
+	return ReorderEOQ
+}
+
+// CalculateQuantity returns the economic order quantity, never less than the
+// amount needed to get back to the minimum stock
+func (s *EOQStrategy) CalculateQuantity(event *StockLowEvent) int {
+	demand, ok := event.metadataNumber("annual_demand")
+	if !ok || demand <= 0 || s.OrderingCost <= 0 || s.HoldingCost <= 0 {
+		return s.Fallback.CalculateQuantity(event)
+	}
+
+	quantity := int(math.Ceil(math.Sqrt(2 * demand * s.OrderingCost / s.HoldingCost)))
+	if shortfall := event.MinimumStock - event.CurrentStock; quantity < shortfall {
+		return shortfall
+	}
+	return quantity
+}
+
+// FixedQuantityStrategy always orders the same quantity
+type FixedQuantityStrategy struct {
+	Quantity int
+}
+
+// Name returns the strategy name
+func (s *FixedQuantityStrategy) Name() string {
+	return ReorderFixedQuantity
+}
+
+// CalculateQuantity returns the configured quantity
+func (s *FixedQuantityStrategy) CalculateQuantity(event *StockLowEvent) int {
+	return s.Quantity
+}
+
+// ReorderParams configures the reorder strategies
+type ReorderParams struct {
+	BaseFactor     float64
+	MaxStockFactor float64
+	OrderingCost   float64
+	HoldingCost    float64
+	FixedQuantity  int
+}
+
+// NewReorderStrategy builds a reorder strategy by name
+func NewReorderStrategy(name string, params ReorderParams) (ReorderStrategy, error) {
+	fixed := DefaultReorderStrategy()
+	if params.BaseFactor > 0 {
+		fixed.BaseFactor = params.BaseFactor
+	}
+
+	switch name {
+	case "", ReorderFixedMultiplier:
+		return fixed, nil
+	case ReorderUpToMax:
+		if params.MaxStockFactor <= 0 {
+			return nil, fmt.Errorf("up_to_max strategy requires a positive max stock factor")
+		}
+		return &UpToMaxStrategy{MaxStockFactor: params.MaxStockFactor}, nil
+	case ReorderEOQ:
+		if params.OrderingCost <= 0 || params.HoldingCost <= 0 {
+			return nil, fmt.Errorf("eoq strategy requires positive ordering and holding costs")
+		}
+		return &EOQStrategy{OrderingCost: params.OrderingCost, HoldingCost: params.HoldingCost, Fallback: fixed}, nil
+	case ReorderFixedQuantity:
+		if params.FixedQuantity <= 0 {
+			return nil, fmt.Errorf("fixed_quantity strategy requires a positive quantity")
+		}
+		return &FixedQuantityStrategy{Quantity: params.FixedQuantity}, nil
+	default:
+		return nil, fmt.Errorf("unknown reorder strategy %q", name)
+	}
+}
+
+// ReorderPolicyOverride is a per-product entry of the reorder policy table
+type ReorderPolicyOverride struct {
+	ProductID      string  `json:"product_id" dynamodbav:"product_id"`
+	TenantID       string  `json:"tenant_id,omitempty" dynamodbav:"tenant_id,omitempty"`
+	Strategy       string  `json:"strategy" dynamodbav:"strategy"`
+	BaseFactor     float64 `json:"base_factor,omitempty" dynamodbav:"base_factor,omitempty"`
+	MaxStockFactor float64 `json:"max_stock_factor,omitempty" dynamodbav:"max_stock_factor,omitempty"`
+	OrderingCost   float64 `json:"ordering_cost,omitempty" dynamodbav:"ordering_cost,omitempty"`
+	HoldingCost    float64 `json:"holding_cost,omitempty" dynamodbav:"holding_cost,omitempty"`
+	FixedQuantity  int     `json:"fixed_quantity,omitempty" dynamodbav:"fixed_quantity,omitempty"`
+	// Source is ReorderSourceForecast for overrides written from a reorder
+	// suggestion, which later suggestions may replace; overrides set by
+	// hand have none
+	Source string `json:"source,omitempty" dynamodbav:"source,omitempty"`
+}
+
+// BuildStrategy builds the reorder strategy described by the override
+func (o *ReorderPolicyOverride) BuildStrategy() (ReorderStrategy, error) {
+	return NewReorderStrategy(o.Strategy, ReorderParams{
+		BaseFactor:     o.BaseFactor,
+		MaxStockFactor: o.MaxStockFactor,
+		OrderingCost:   o.OrderingCost,
+		HoldingCost:    o.HoldingCost,
+		FixedQuantity:  o.FixedQuantity,
+	})
+}
+
+// metadataNumber reads a numeric metadata value sent as a number or a string
+func (s *StockLowEvent) metadataNumber(key string) (float64, bool) {
+	switch v := s.Metadata[key].(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,72 @@
+package models
+
+import "testing"
+
+func TestReorderStrategies(t *testing.T) {
+	event := func(urgency string, current, minimum int, metadata map[string]interface{}) *StockLowEvent {
+		return &StockLowEvent{UrgencyLevel: urgency, CurrentStock: current, MinimumStock: minimum, Metadata: metadata}
+	}
+	fixed := DefaultReorderStrategy()
+
+	for _, tc := range []struct {
+		name     string
+		strategy ReorderStrategy
+		event    *StockLowEvent
+		want     int
+	}{
+		{"fixed multiplier low", fixed, event("low", 5, 20, nil), 40},
+		{"fixed multiplier critical", fixed, event("critical", 5, 20, nil), 120},
+		{"fixed multiplier unknown urgency", fixed, event("urgent", 5, 20, nil), 40},
+		{"up to max from metadata", &UpToMaxStrategy{MaxStockFactor: 3}, event("low", 5, 20, map[string]interface{}{"maximum_stock": 100.0}), 95},
+		{"up to max as a string", &UpToMaxStrategy{MaxStockFactor: 3}, event("low", 5, 20, map[string]interface{}{"maximum_stock": "50"}), 45},
+		{"up to max from the factor", &UpToMaxStrategy{MaxStockFactor: 3}, event("low", 5, 20, nil), 55},
+		{"up to max already full", &UpToMaxStrategy{MaxStockFactor: 3}, event("low", 80, 20, nil), 1},
+		{"eoq", &EOQStrategy{OrderingCost: 50, HoldingCost: 2, Fallback: fixed}, event("low", 5, 20, map[string]interface{}{"annual_demand": 1000}), 224},
+		{"eoq below the shortfall", &EOQStrategy{OrderingCost: 1, HoldingCost: 200, Fallback: fixed}, event("low", 5, 20, map[string]interface{}{"annual_demand": 100}), 15},
+		{"eoq without demand falls back", &EOQStrategy{OrderingCost: 50, HoldingCost: 2, Fallback: fixed}, event("high", 5, 20, nil), 80},
+		{"eoq with unreadable demand falls back", &EOQStrategy{OrderingCost: 50, HoldingCost: 2, Fallback: fixed}, event("low", 5, 20, map[string]interface{}{"annual_demand": "lots"}), 40},
+		{"fixed quantity", &FixedQuantityStrategy{Quantity: 250}, event("critical", 5, 20, nil), 250},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.strategy.CalculateQuantity(tc.event); got != tc.want {
+				t.Fatalf("%s ordered %d, want %d", tc.strategy.Name(), got, tc.want)
+			}
+		})
+	}
+}
+
+func TestNewReorderStrategy(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		params ReorderParams
+		want   string
+		fails  bool
+	}{
+		{"", ReorderParams{}, ReorderFixedMultiplier, false},
+		{ReorderFixedMultiplier, ReorderParams{BaseFactor: 3}, ReorderFixedMultiplier, false},
+		{ReorderUpToMax, ReorderParams{MaxStockFactor: 4}, ReorderUpToMax, false},
+		{ReorderUpToMax, ReorderParams{}, "", true},
+		{ReorderEOQ, ReorderParams{OrderingCost: 50, HoldingCost: 2}, ReorderEOQ, false},
+		{ReorderEOQ, ReorderParams{OrderingCost: 50}, "", true},
+		{ReorderFixedQuantity, ReorderParams{FixedQuantity: 10}, ReorderFixedQuantity, false},
+		{ReorderFixedQuantity, ReorderParams{}, "", true},
+		{"random", ReorderParams{}, "", true},
+	} {
+		strategy, err := NewReorderStrategy(tc.name, tc.params)
+		if tc.fails {
+			if err == nil {
+				t.Errorf("NewReorderStrategy(%q, %+v) succeeded", tc.name, tc.params)
+			}
+			continue
+		}
+		if err != nil || strategy.Name() != tc.want {
+			t.Errorf("NewReorderStrategy(%q, %+v) = %v, %v; want %s", tc.name, tc.params, strategy, err, tc.want)
+		}
+	}
+
+	// The base factor also applies to the EOQ fallback
+	strategy, _ := NewReorderStrategy(ReorderEOQ, ReorderParams{BaseFactor: 3, OrderingCost: 50, HoldingCost: 2})
+	if got := strategy.CalculateQuantity(&StockLowEvent{UrgencyLevel: "low", MinimumStock: 10}); got != 30 {
+		t.Fatalf("EOQ fallback ordered %d, want 30", got)
+	}
+}
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// ExpiresAtAttribute is the DynamoDB TTL attribute of operational tables.
+// DynamoDB deletes items some time after this epoch second has passed.
+const ExpiresAtAttribute = "expires_at"
+
+// Retention of transient records before DynamoDB TTL removes them. Zero
+// keeps records until they are deleted by hand.
+var (
+	DeadLetterRetention      time.Duration
+	WebhookDeliveryRetention time.Duration
+)
+
+// ExpiresAt returns the TTL value of a record kept for retention from t, or
+// 0, which omits the attribute, when retention is not positive
+func ExpiresAt(t time.Time, retention time.Duration) int64 {
+	if retention <= 0 {
+		return 0
+	}
+	return t.Add(retention).Unix()
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExpiresAt(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		name      string
+		retention time.Duration
+		want      int64
+	}{
+		{"positive retention", 7 * 24 * time.Hour, now.AddDate(0, 0, 7).Unix()},
+		{"zero keeps the record", 0, 0},
+		{"negative keeps the record", -time.Hour, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := ExpiresAt(now, tc.retention); got != tc.want {
+				t.Fatalf("ExpiresAt = %d, want %d", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDeadLetterRecordExpiresAfterTheRetention(t *testing.T) {
+	retention := DeadLetterRetention
+	DeadLetterRetention = 30 * 24 * time.Hour
+	t.Cleanup(func() { DeadLetterRetention = retention })
+
+	receivedAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	record := NewDeadLetterRecord("message-1", "orden-compra.stock-bajo", "stock.bajo", "application/json", "malformed_payload", nil, []byte(`{`), nil, "", receivedAt)
+	if want := receivedAt.AddDate(0, 0, 30).Unix(); record.ExpiresAt != want {
+		t.Fatalf("expires at %d, want %d", record.ExpiresAt, want)
+	}
+}
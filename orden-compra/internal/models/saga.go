@@ -0,0 +1,83 @@
+package models
+
+import "time"
+
+// Steps of the replenishment saga, in the order they normally happen
+const (
+	SagaStepStockLowReceived     = "stock_low_received"
+	SagaStepOrderCreated         = "order_created"
+	SagaStepOrderDispatched      = "order_dispatched"
+	SagaStepSupplierAcknowledged = "supplier_acknowledged"
+	SagaStepReceptionConfirmed   = "reception_confirmed"
+	SagaStepInventoryReceived    = "inventory_received"
+)
+
+// Saga statuses
+const (
+	// SagaRunning waits for the next step, possibly until a deadline
+	SagaRunning = "running"
+	// SagaStalled missed the reception deadline; it resumes when the order is received
+	SagaStalled     = "stalled"
+	SagaCompleted   = "completed"
+	SagaCancelled   = "cancelled"
+	SagaCompensated = "compensated"
+)
+
+// SagaStep records when a step of the saga happened and the event that showed it
+type SagaStep struct {
+	Name      string    `json:"name" dynamodbav:"name"`
+	EventID   string    `json:"event_id" dynamodbav:"event_id"`
+	EventType string    `json:"event_type" dynamodbav:"event_type"`
+	At        time.Time `json:"at" dynamodbav:"at"`
+}
+
+// SagaCompensation records the compensating action taken when a deadline passed
+type SagaCompensation struct {
+	Action string    `json:"action" dynamodbav:"action"`
+	Reason string    `json:"reason" dynamodbav:"reason"`
+	At     time.Time `json:"at" dynamodbav:"at"`
+	// Error is the last failure to compensate; compensation is retried until it succeeds
+	Error string `json:"error,omitempty" dynamodbav:"error,omitempty"`
+}
+
+// Saga tracks the replenishment flow of one correlation chain, from the
+// StockBajo event through the purchase order to the inventory reception
+type Saga struct {
+	CorrelationID   string            `json:"correlation_id" dynamodbav:"id"`
+	TenantID        string            `json:"tenant_id,omitempty" dynamodbav:"tenant_id,omitempty"`
+	PurchaseOrderID string            `json:"purchase_order_id" dynamodbav:"purchase_order_id"`
+	Status          string            `json:"status" dynamodbav:"status"`
+	Steps           []SagaStep        `json:"steps" dynamodbav:"steps"`
+	Deadline        *time.Time        `json:"deadline,omitempty" dynamodbav:"deadline,omitempty"`
+	Compensation    *SagaCompensation `json:"compensation,omitempty" dynamodbav:"compensation,omitempty"`
+	StartedAt       time.Time         `json:"started_at" dynamodbav:"started_at"`
+	UpdatedAt       time.Time         `json:"updated_at" dynamodbav:"updated_at"`
+}
+
+// Step returns the recorded step with the given name
+func (s *Saga) Step(name string) (SagaStep, bool) {
+	for _, step := range s.Steps {
+		if step.Name == name {
+			return step, true
+		}
+	}
+	return SagaStep{}, false
+}
+
+// RecordStep records a step the first time it is seen and reports whether it was new
+func (s *Saga) RecordStep(step SagaStep) bool {
+	if _, ok := s.Step(step.Name); ok {
+		return false
+	}
+	s.Steps = append(s.Steps, step)
+	return true
+}
+
+// Finished reports whether the saga reached a final status
+func (s *Saga) Finished() bool {
+	switch s.Status {
+	case SagaCompleted, SagaCancelled, SagaCompensated:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSagaRecordsEachStepOnce(t *testing.T) {
+	saga := &Saga{Status: SagaRunning}
+	first := SagaStep{Name: SagaStepOrderCreated, EventID: "event-1", At: time.Now()}
+	if !saga.RecordStep(first) {
+		t.Fatal("first step not recorded")
+	}
+	if saga.RecordStep(SagaStep{Name: SagaStepOrderCreated, EventID: "event-2"}) {
+		t.Fatal("step recorded twice")
+	}
+	if step, ok := saga.Step(SagaStepOrderCreated); !ok || step.EventID != "event-1" {
+		t.Fatalf("step %+v, want the first one", step)
+	}
+	if _, ok := saga.Step(SagaStepOrderDispatched); ok {
+		t.Fatal("found a step that was not recorded")
+	}
+}
+
+func TestSagaFinished(t *testing.T) {
+	for status, finished := range map[string]bool{
+		SagaRunning:     false,
+		SagaStalled:     false,
+		SagaCompleted:   true,
+		SagaCancelled:   true,
+		SagaCompensated: true,
+	} {
+		if got := (&Saga{Status: status}).Finished(); got != finished {
+			t.Errorf("%s saga finished = %v, want %v", status, got, finished)
+		}
+	}
+}
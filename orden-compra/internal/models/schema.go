@@ -0,0 +1,107 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrUnknownSchemaVersion is returned when an event was written with a
+// schema version newer than this build knows, e.g. by a newer replica
+// during a rolling deployment
+var ErrUnknownSchemaVersion = errors.New("unknown event schema version")
+
+// Upcaster migrates the event_data of an event from one schema version to
+// the next. It may modify and return the map it is given.
+type Upcaster func(data map[string]interface{}) (map[string]interface{}, error)
+
+// SchemaRegistry holds the upcasters of every event type, so events stored
+// under an old schema are read as if written by the current code. Version 1
+// is the schema of events stored before schema versions were recorded.
+type SchemaRegistry struct {
+	// upcasters[eventType][i] migrates version i+1 to i+2
+	upcasters map[string][]Upcaster
+}
+
+// NewSchemaRegistry creates a registry in which every event type is at version 1
+func NewSchemaRegistry() *SchemaRegistry {
+	return &SchemaRegistry{
+		upcasters: make(map[string][]Upcaster),
+	}
+}
+
+// Register adds the upcaster that migrates an event type from version from
+// to from+1. Upcasters must be registered in version order; a gap would
+// leave events unreadable, so it panics.
+func (r *SchemaRegistry) Register(eventType string, from int, upcaster Upcaster) {
+	if current := r.CurrentVersion(eventType); from != current {
+		panic(fmt.Sprintf("upcaster of %s from version %d registered at version %d", eventType, from, current))
+	}
+	r.upcasters[eventType] = append(r.upcasters[eventType], upcaster)
+}
+
+// CurrentVersion returns the schema version new events of the type are written with
+func (r *SchemaRegistry) CurrentVersion(eventType string) int {
+	return len(r.upcasters[eventType]) + 1
+}
+
+// Upcast migrates the event's data to the current schema version of its
+// type. Events without a schema version are version 1.
+func (r *SchemaRegistry) Upcast(event *EventSourcingEvent) error {
+	version := event.SchemaVersion
+	if version == 0 {
+		version = 1
+	}
+	current := r.CurrentVersion(event.EventType)
+	if version > current {
+		return fmt.Errorf("%w: event %s is %s version %d, latest known is %d", ErrUnknownSchemaVersion, event.ID, event.EventType, version, current)
+	}
+
+	data := event.EventData
+	if data == nil {
+		data = make(map[string]interface{})
+	}
+	for ; version < current; version++ {
+		upcasted, err := r.upcasters[event.EventType][version-1](data)
+		if err != nil {
+			return fmt.Errorf("failed to upcast event %s from %s version %d: %w", event.ID, event.EventType, version, err)
+		}
+		data = upcasted
+	}
+
+	event.EventData = data
+	event.SchemaVersion = current
+	return nil
+}
+
+// EventSchemas is the registry of the event schemas this service has written
+var EventSchemas = historicalEventSchemas()
+
+// historicalEventSchemas registers every past change to an event payload.
+// An upcaster is never changed once released: events of its version may
+// still be stored, so a new change gets a new version instead.
+func historicalEventSchemas() *SchemaRegistry {
+	r := NewSchemaRegistry()
+
+	// PurchaseOrderStatusUpdated v1 recorded status_change.old_status as
+	// "unknown" until the previous status was tracked; v2 omits old_status
+	// when it is not known
+	r.Register("PurchaseOrderStatusUpdated", 1, func(data map[string]interface{}) (map[string]interface{}, error) {
+		if change, ok := data["status_change"].(map[string]interface{}); ok && change["old_status"] == "unknown" {
+			delete(change, "old_status")
+		}
+		return data, nil
+	})
+
+	// PurchaseOrderApproved and PurchaseOrderRejected v1 had no
+	// previous_status; orders are only decided while pending approval
+	for _, eventType := range []string{"PurchaseOrderApproved", "PurchaseOrderRejected"} {
+		r.Register(eventType, 1, func(data map[string]interface{}) (map[string]interface{}, error) {
+			if _, ok := data["previous_status"]; !ok {
+				data["previous_status"] = StatusPendingApproval
+			}
+			return data, nil
+		})
+	}
+
+	return r
+}
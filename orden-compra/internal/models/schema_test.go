@@ -0,0 +1,180 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// schemaCases feed one stored payload of every schema version Upcast
+// accepts, the current one included, through Upcast; want is the
+// event_data the current code would write
+var schemaCases = []struct {
+	name      string
+	eventType string
+	version   int
+	payload   string
+	want      map[string]interface{}
+}{
+	{
+		name:      "status updated v1 with unknown previous status",
+		eventType: "PurchaseOrderStatusUpdated",
+		version:   1,
+		payload:   `{"purchase_order": {"id": "po-1"}, "status_change": {"old_status": "unknown", "new_status": "sent"}}`,
+		want: map[string]interface{}{
+			"purchase_order": map[string]interface{}{"id": "po-1"},
+			"status_change":  map[string]interface{}{"new_status": "sent"},
+		},
+	},
+	{
+		name:      "status updated v1 with known previous status",
+		eventType: "PurchaseOrderStatusUpdated",
+		version:   1,
+		payload:   `{"purchase_order": {"id": "po-1"}, "status_change": {"old_status": "approved", "new_status": "sent"}}`,
+		want: map[string]interface{}{
+			"purchase_order": map[string]interface{}{"id": "po-1"},
+			"status_change":  map[string]interface{}{"old_status": "approved", "new_status": "sent"},
+		},
+	},
+	{
+		name:      "approved v1",
+		eventType: "PurchaseOrderApproved",
+		version:   1,
+		payload:   `{"purchase_order": {"id": "po-1"}, "decided_by": "alice", "comment": "ok"}`,
+		want: map[string]interface{}{
+			"purchase_order":  map[string]interface{}{"id": "po-1"},
+			"previous_status": StatusPendingApproval,
+			"decided_by":      "alice",
+			"comment":         "ok",
+		},
+	},
+	{
+		name:      "rejected v1",
+		eventType: "PurchaseOrderRejected",
+		version:   1,
+		payload:   `{"purchase_order": {"id": "po-1"}, "decided_by": "bob", "comment": "over budget"}`,
+		want: map[string]interface{}{
+			"purchase_order":  map[string]interface{}{"id": "po-1"},
+			"previous_status": StatusPendingApproval,
+			"decided_by":      "bob",
+			"comment":         "over budget",
+		},
+	},
+	{
+		name:      "status updated v2 is left as stored",
+		eventType: "PurchaseOrderStatusUpdated",
+		version:   2,
+		payload:   `{"purchase_order": {"id": "po-1"}, "status_change": {"new_status": "sent"}}`,
+		want: map[string]interface{}{
+			"purchase_order": map[string]interface{}{"id": "po-1"},
+			"status_change":  map[string]interface{}{"new_status": "sent"},
+		},
+	},
+	{
+		name:      "approved v2 is left as stored",
+		eventType: "PurchaseOrderApproved",
+		version:   2,
+		payload:   `{"purchase_order": {"id": "po-1"}, "previous_status": "draft", "decided_by": "alice"}`,
+		want: map[string]interface{}{
+			"purchase_order":  map[string]interface{}{"id": "po-1"},
+			"previous_status": "draft",
+			"decided_by":      "alice",
+		},
+	},
+	{
+		name:      "rejected v2 is left as stored",
+		eventType: "PurchaseOrderRejected",
+		version:   2,
+		payload:   `{"purchase_order": {"id": "po-1"}, "previous_status": "draft", "decided_by": "bob"}`,
+		want: map[string]interface{}{
+			"purchase_order":  map[string]interface{}{"id": "po-1"},
+			"previous_status": "draft",
+			"decided_by":      "bob",
+		},
+	},
+}
+
+// currentSchemaVersions pins the version events of each type are written
+// with. A new upcaster bumps it, and needs a case in schemaCases.
+var currentSchemaVersions = map[string]int{
+	"PurchaseOrderStatusUpdated": 2,
+	"PurchaseOrderApproved":      2,
+	"PurchaseOrderRejected":      2,
+}
+
+func TestUpcastHistoricalSchemas(t *testing.T) {
+	for _, tt := range schemaCases {
+		t.Run(tt.name, func(t *testing.T) {
+			var data map[string]interface{}
+			if err := json.Unmarshal([]byte(tt.payload), &data); err != nil {
+				t.Fatalf("payload: %v", err)
+			}
+			event := &EventSourcingEvent{ID: "event-1", EventType: tt.eventType, SchemaVersion: tt.version, EventData: data}
+
+			if err := EventSchemas.Upcast(event); err != nil {
+				t.Fatalf("Upcast: %v", err)
+			}
+			if want := EventSchemas.CurrentVersion(tt.eventType); event.SchemaVersion != want {
+				t.Fatalf("schema version %d after upcasting, want %d", event.SchemaVersion, want)
+			}
+			if !reflect.DeepEqual(event.EventData, tt.want) {
+				t.Fatalf("event data %v, want %v", event.EventData, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnversionedEventsAreVersionOne(t *testing.T) {
+	event := &EventSourcingEvent{
+		ID:        "event-1",
+		EventType: "PurchaseOrderApproved",
+		EventData: map[string]interface{}{"decided_by": "alice"},
+	}
+	if err := EventSchemas.Upcast(event); err != nil {
+		t.Fatalf("Upcast: %v", err)
+	}
+	if event.EventData["previous_status"] != StatusPendingApproval {
+		t.Fatalf("unversioned event not upcast from version 1: %v", event.EventData)
+	}
+}
+
+func TestUpcastRefusesNewerVersions(t *testing.T) {
+	event := &EventSourcingEvent{ID: "event-1", EventType: "PurchaseOrderApproved", SchemaVersion: 3}
+	if err := EventSchemas.Upcast(event); !errors.Is(err, ErrUnknownSchemaVersion) {
+		t.Fatalf("Upcast error %v, want ErrUnknownSchemaVersion", err)
+	}
+}
+
+func TestEverySchemaVersionHasAnUpcastCase(t *testing.T) {
+	for eventType := range EventSchemas.upcasters {
+		if _, ok := currentSchemaVersions[eventType]; !ok {
+			t.Errorf("%s has upcasters but no pinned current version", eventType)
+		}
+	}
+	for eventType, want := range currentSchemaVersions {
+		if got := EventSchemas.CurrentVersion(eventType); got != want {
+			t.Errorf("%s is at version %d, pinned at %d; pin the new version and add its upcast case", eventType, got, want)
+		}
+		for version := 1; version <= want; version++ {
+			covered := false
+			for _, tt := range schemaCases {
+				covered = covered || tt.eventType == eventType && tt.version == version
+			}
+			if !covered {
+				t.Errorf("%s version %d has no upcast case", eventType, version)
+			}
+		}
+	}
+}
+
+func TestRegisterRefusesVersionGaps(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("Register accepted an upcaster from version 2 with none from version 1")
+		}
+	}()
+	NewSchemaRegistry().Register("PurchaseOrderApproved", 2, func(data map[string]interface{}) (map[string]interface{}, error) {
+		return data, nil
+	})
+}
@@ -0,0 +1,52 @@
+package models
+
+import "time"
+
+// AggregateSnapshot is the state of a purchase order as of one of its
+// events. Rehydration starts from it and only replays the events stored
+// after it.
+type AggregateSnapshot struct {
+	AggregateID string `json:"aggregate_id" dynamodbav:"aggregate_id"`
+	TenantID    string `json:"tenant_id,omitempty" dynamodbav:"tenant_id,omitempty"`
+	// EventID and EventTimestamp identify the last event folded into the snapshot
+	EventID        string    `json:"event_id" dynamodbav:"event_id"`
+	EventTimestamp time.Time `json:"event_timestamp" dynamodbav:"event_timestamp"`
+	// Version is the order version as of the snapshot
+	Version int `json:"version" dynamodbav:"version"`
+	// EventsApplied counts the events folded into this and earlier snapshots
+	EventsApplied int            `json:"events_applied" dynamodbav:"events_applied"`
+	PurchaseOrder *PurchaseOrder `json:"purchase_order" dynamodbav:"purchase_order"`
+	TakenAt       time.Time      `json:"taken_at" dynamodbav:"taken_at"`
+}
+
+// Rehydration is a purchase order rebuilt from its latest snapshot and the
+// events stored after it
+type Rehydration struct {
+	PurchaseOrder *PurchaseOrder `json:"purchase_order"`
+	// Snapshot is the snapshot rehydration started from, nil when it
+	// replayed every event
+	Snapshot *AggregateSnapshot `json:"snapshot,omitempty"`
+	// EventsReplayed is the number of events read after the snapshot
+	EventsReplayed int `json:"events_replayed"`
+	// LastEventID and LastEventTimestamp identify the event the state is taken from
+	LastEventID        string    `json:"last_event_id"`
+	LastEventTimestamp time.Time `json:"last_event_timestamp"`
+}
+
+// NewSnapshot returns the snapshot of the rehydrated state
+func (r *Rehydration) NewSnapshot(now time.Time) *AggregateSnapshot {
+	applied := r.EventsReplayed
+	if r.Snapshot != nil {
+		applied += r.Snapshot.EventsApplied
+	}
+	return &AggregateSnapshot{
+		AggregateID:    r.PurchaseOrder.ID,
+		TenantID:       r.PurchaseOrder.TenantID,
+		EventID:        r.LastEventID,
+		EventTimestamp: r.LastEventTimestamp,
+		Version:        r.PurchaseOrder.Version,
+		EventsApplied:  applied,
+		PurchaseOrder:  r.PurchaseOrder,
+		TakenAt:        now,
+	}
+}
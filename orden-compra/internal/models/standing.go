@@ -0,0 +1,178 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Standing order statuses
+const (
+	StandingOrderActive    = "active"
+	StandingOrderPaused    = "paused"
+	StandingOrderCompleted = "completed"
+	StandingOrderCancelled = "cancelled"
+)
+
+// StandingOrder is a blanket purchase order: a quantity of a product ordered
+// from one supplier every IntervalDays from StartAt until EndAt, or until
+// TotalQuantity has been released. Each release is a purchase order linked
+// to the standing order by its blanket order ID, the standing order's ID.
+type StandingOrder struct {
+	ID           string `json:"id" dynamodbav:"id"`
+	TenantID     string `json:"tenant_id,omitempty" dynamodbav:"tenant_id,omitempty"`
+	ProductID    string `json:"product_id" dynamodbav:"product_id"`
+	ProductName  string `json:"product_name" dynamodbav:"product_name"`
+	SupplierID   string `json:"supplier_id" dynamodbav:"supplier_id"`
+	SupplierName string `json:"supplier_name" dynamodbav:"supplier_name"`
+	Location     string `json:"location" dynamodbav:"location"`
+	UrgencyLevel string `json:"urgency_level" dynamodbav:"urgency_level"`
+	// Quantity is released every interval
+	Quantity     int        `json:"quantity" dynamodbav:"quantity"`
+	IntervalDays int        `json:"interval_days" dynamodbav:"interval_days"`
+	StartAt      time.Time  `json:"start_at" dynamodbav:"start_at"`
+	EndAt        *time.Time `json:"end_at,omitempty" dynamodbav:"end_at,omitempty"`
+	// TotalQuantity caps the quantity released over the standing order's
+	// life; 0 means no cap
+	TotalQuantity    int    `json:"total_quantity,omitempty" dynamodbav:"total_quantity,omitempty"`
+	ReleasedQuantity int    `json:"released_quantity" dynamodbav:"released_quantity"`
+	Releases         int    `json:"releases" dynamodbav:"releases"`
+	Status           string `json:"status" dynamodbav:"status"`
+	// NextReleaseAt is nil once the standing order is completed or cancelled
+	NextReleaseAt       *time.Time             `json:"next_release_at,omitempty" dynamodbav:"next_release_at,omitempty"`
+	LastReleaseAt       *time.Time             `json:"last_release_at,omitempty" dynamodbav:"last_release_at,omitempty"`
+	LastPurchaseOrderID string                 `json:"last_purchase_order_id,omitempty" dynamodbav:"last_purchase_order_id,omitempty"`
+	CreatedBy           string                 `json:"created_by,omitempty" dynamodbav:"created_by,omitempty"`
+	CreatedAt           time.Time              `json:"created_at" dynamodbav:"created_at"`
+	UpdatedAt           time.Time              `json:"updated_at" dynamodbav:"updated_at"`
+	Version             int                    `json:"version" dynamodbav:"version"`
+	Metadata            map[string]interface{} `json:"metadata" dynamodbav:"metadata"`
+}
+
+// NewStandingOrder creates an active standing order whose first release is
+// at its start
+func NewStandingOrder(productID, productName, supplierID, supplierName, location, urgencyLevel string, quantity, intervalDays int, startAt, now time.Time) *StandingOrder {
+	now = now.UTC()
+	startAt = startAt.UTC()
+	return &StandingOrder{
+		ID:            uuid.New().String(),
+		ProductID:     productID,
+		ProductName:   productName,
+		SupplierID:    supplierID,
+		SupplierName:  supplierName,
+		Location:      location,
+		UrgencyLevel:  urgencyLevel,
+		Quantity:      quantity,
+		IntervalDays:  intervalDays,
+		StartAt:       startAt,
+		Status:        StandingOrderActive,
+		NextReleaseAt: &startAt,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+		Metadata:      make(map[string]interface{}),
+	}
+}
+
+// Validate checks the standing order for required fields and a usable schedule
+func (s *StandingOrder) Validate() error {
+	var errs ValidationErrors
+
+	if strings.TrimSpace(s.ProductID) == "" {
+		errs.add("product_id", "is required")
+	}
+	if strings.TrimSpace(s.SupplierID) == "" {
+		errs.add("supplier_id", "is required")
+	}
+	if strings.TrimSpace(s.Location) == "" {
+		errs.add("location", "is required")
+	}
+	if !ValidUrgencyLevels[s.UrgencyLevel] {
+		errs.add("urgency_level", fmt.Sprintf("unknown urgency level %q", s.UrgencyLevel))
+	}
+	if s.Quantity < 1 {
+		errs.add("quantity", fmt.Sprintf("must be positive, got %d", s.Quantity))
+	}
+	if s.IntervalDays < 1 {
+		errs.add("interval_days", fmt.Sprintf("must be positive, got %d", s.IntervalDays))
+	}
+	if s.StartAt.IsZero() {
+		errs.add("start_at", "is required")
+	}
+	if s.EndAt != nil && !s.EndAt.After(s.StartAt) {
+		errs.add("end_at", "must be after start_at")
+	}
+	if s.TotalQuantity < 0 {
+		errs.add("total_quantity", fmt.Sprintf("must not be negative, got %d", s.TotalQuantity))
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Due reports whether a release is due at now
+func (s *StandingOrder) Due(now time.Time) bool {
+	return s.Status == StandingOrderActive && s.NextReleaseAt != nil && !now.Before(*s.NextReleaseAt)
+}
+
+// ReleaseQuantity returns the quantity of the next release, which is less
+// than Quantity when the total cap is nearly reached
+func (s *StandingOrder) ReleaseQuantity() int {
+	if s.TotalQuantity > 0 && s.TotalQuantity-s.ReleasedQuantity < s.Quantity {
+		return s.TotalQuantity - s.ReleasedQuantity
+	}
+	return s.Quantity
+}
+
+// ReleasePurchaseOrderID returns the ID of the purchase order of a release.
+// It is derived from the standing order and release number, so a release
+// that is retried creates the same order rather than a second one.
+func (s *StandingOrder) ReleasePurchaseOrderID(release int) string {
+	return uuid.NewSHA1(uuid.NameSpaceURL, []byte(fmt.Sprintf("standing-order:%s:%d", s.ID, release))).String()
+}
+
+// RecordRelease records a release made at now and schedules the next one.
+// Releases missed while the scheduler was down are not made up; the next
+// release is the first scheduled one after now. The standing order completes
+// once its end or total quantity is reached.
+func (s *StandingOrder) RecordRelease(now time.Time, purchaseOrderID string, quantity int) {
+	s.Releases++
+	s.ReleasedQuantity += quantity
+	s.LastPurchaseOrderID = purchaseOrderID
+	releasedAt := now.UTC()
+	s.LastReleaseAt = &releasedAt
+	s.UpdatedAt = releasedAt
+
+	next := *s.NextReleaseAt
+	for !next.After(now) {
+		next = next.AddDate(0, 0, s.IntervalDays)
+	}
+	s.NextReleaseAt = &next
+
+	if (s.EndAt != nil && next.After(*s.EndAt)) || (s.TotalQuantity > 0 && s.ReleasedQuantity >= s.TotalQuantity) {
+		s.Status = StandingOrderCompleted
+		s.NextReleaseAt = nil
+	}
+}
+
+// Resume reactivates a paused standing order, scheduling its next release
+// at the first scheduled time from now
+func (s *StandingOrder) Resume(now time.Time) {
+	s.Status = StandingOrderActive
+	s.UpdatedAt = now.UTC()
+	if s.NextReleaseAt == nil {
+		return
+	}
+	next := *s.NextReleaseAt
+	for next.Before(now) {
+		next = next.AddDate(0, 0, s.IntervalDays)
+	}
+	s.NextReleaseAt = &next
+	if s.EndAt != nil && next.After(*s.EndAt) {
+		s.Status = StandingOrderCompleted
+		s.NextReleaseAt = nil
+	}
+}
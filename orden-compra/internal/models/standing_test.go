@@ -0,0 +1,127 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func newTestStandingOrder(start time.Time) *StandingOrder {
+	return NewStandingOrder("product-1", "Gloves", "supplier-1", "Acme", "warehouse-1", "medium", 100, 7, start, start)
+}
+
+func TestStandingOrderValidate(t *testing.T) {
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	before := start.Add(-time.Hour)
+
+	for _, tc := range []struct {
+		name   string
+		change func(*StandingOrder)
+		fields []string
+	}{
+		{"valid", func(*StandingOrder) {}, nil},
+		{"missing product, supplier and location", func(s *StandingOrder) { s.ProductID, s.SupplierID, s.Location = "", " ", "" }, []string{"product_id", "supplier_id", "location"}},
+		{"unknown urgency", func(s *StandingOrder) { s.UrgencyLevel = "urgent" }, []string{"urgency_level"}},
+		{"no quantity or interval", func(s *StandingOrder) { s.Quantity, s.IntervalDays = 0, 0 }, []string{"quantity", "interval_days"}},
+		{"ends before it starts", func(s *StandingOrder) { s.EndAt = &before }, []string{"end_at"}},
+		{"missing start and negative total", func(s *StandingOrder) { s.StartAt, s.TotalQuantity = time.Time{}, -1 }, []string{"start_at", "total_quantity"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			standingOrder := newTestStandingOrder(start)
+			tc.change(standingOrder)
+			err := standingOrder.Validate()
+			if tc.fields == nil {
+				if err != nil {
+					t.Fatalf("Validate returned %v", err)
+				}
+				return
+			}
+
+			var errs ValidationErrors
+			if !errors.As(err, &errs) || len(errs) != len(tc.fields) {
+				t.Fatalf("Validate returned %v, want errors on %v", err, tc.fields)
+			}
+			for i, field := range tc.fields {
+				if errs[i].Field != field {
+					t.Fatalf("error %d is %+v, want one on %s", i, errs[i], field)
+				}
+			}
+		})
+	}
+}
+
+func TestStandingOrderReleases(t *testing.T) {
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	standingOrder := newTestStandingOrder(start)
+	standingOrder.TotalQuantity = 250
+
+	if standingOrder.Due(start.Add(-time.Second)) || !standingOrder.Due(start) {
+		t.Fatal("standing order is not due from its start")
+	}
+	standingOrder.RecordRelease(start, "po-1", standingOrder.ReleaseQuantity())
+	if standingOrder.Releases != 1 || standingOrder.ReleasedQuantity != 100 || standingOrder.LastPurchaseOrderID != "po-1" || !standingOrder.NextReleaseAt.Equal(start.AddDate(0, 0, 7)) {
+		t.Fatalf("after the first release %+v", standingOrder)
+	}
+
+	// Releases missed while the scheduler was down are not made up
+	late := start.AddDate(0, 0, 15)
+	standingOrder.RecordRelease(late, "po-2", standingOrder.ReleaseQuantity())
+	if !standingOrder.NextReleaseAt.Equal(start.AddDate(0, 0, 21)) {
+		t.Fatalf("next release at %s, want %s", standingOrder.NextReleaseAt, start.AddDate(0, 0, 21))
+	}
+
+	// The last release is cut to the total and completes the standing order
+	if quantity := standingOrder.ReleaseQuantity(); quantity != 50 {
+		t.Fatalf("last release of %d, want 50", quantity)
+	}
+	standingOrder.RecordRelease(start.AddDate(0, 0, 21), "po-3", 50)
+	if standingOrder.Status != StandingOrderCompleted || standingOrder.NextReleaseAt != nil || standingOrder.Due(start.AddDate(0, 0, 28)) {
+		t.Fatalf("after the total %+v", standingOrder)
+	}
+}
+
+func TestStandingOrderCompletesAtItsEnd(t *testing.T) {
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 0, 10)
+	standingOrder := newTestStandingOrder(start)
+	standingOrder.EndAt = &end
+
+	standingOrder.RecordRelease(start, "po-1", 100)
+	if standingOrder.Status != StandingOrderActive {
+		t.Fatalf("status %s with a release before the end", standingOrder.Status)
+	}
+	standingOrder.RecordRelease(start.AddDate(0, 0, 7), "po-2", 100)
+	if standingOrder.Status != StandingOrderCompleted || standingOrder.NextReleaseAt != nil {
+		t.Fatalf("status %s, next release %v past the end", standingOrder.Status, standingOrder.NextReleaseAt)
+	}
+}
+
+func TestStandingOrderResume(t *testing.T) {
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	standingOrder := newTestStandingOrder(start)
+	standingOrder.Status = StandingOrderPaused
+
+	// Releases due while paused are skipped
+	standingOrder.Resume(start.AddDate(0, 0, 10))
+	if standingOrder.Status != StandingOrderActive || !standingOrder.NextReleaseAt.Equal(start.AddDate(0, 0, 14)) {
+		t.Fatalf("resumed as %s with the next release at %v", standingOrder.Status, standingOrder.NextReleaseAt)
+	}
+
+	end := start.AddDate(0, 0, 20)
+	standingOrder.EndAt = &end
+	standingOrder.Status = StandingOrderPaused
+	standingOrder.Resume(start.AddDate(0, 0, 22))
+	if standingOrder.Status != StandingOrderCompleted || standingOrder.NextReleaseAt != nil {
+		t.Fatalf("resumed past the end as %s", standingOrder.Status)
+	}
+}
+
+func TestReleasePurchaseOrderIDIsStable(t *testing.T) {
+	standingOrder := newTestStandingOrder(time.Now())
+	if standingOrder.ReleasePurchaseOrderID(1) != standingOrder.ReleasePurchaseOrderID(1) {
+		t.Fatal("release 1 has two purchase order IDs")
+	}
+	if standingOrder.ReleasePurchaseOrderID(1) == standingOrder.ReleasePurchaseOrderID(2) {
+		t.Fatal("releases 1 and 2 share a purchase order ID")
+	}
+}
@@ -0,0 +1,111 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// Counters of the purchase order statistics projection. Breakdown counters
+// are named by prefix and value, e.g. status:pending.
+const (
+	StatsTotal          = "total"
+	StatsOverdue        = "overdue"
+	StatsStatusPrefix   = "status:"
+	StatsUrgencyPrefix  = "urgency:"
+	StatsSupplierPrefix = "supplier:"
+	// StatsOpenPrefix counts the orders awaiting delivery by urgency
+	StatsOpenPrefix = "open:"
+	// StatsReceipts counts the orders received, StatsReceiptLatencySeconds
+	// sums the time from their creation to their receipt and
+	// StatsQualityFailures counts those whose receipt failed quality checks
+	StatsReceipts              = "receipts"
+	StatsReceiptLatencySeconds = "receipt_latency_seconds"
+	StatsQualityFailures       = "quality_failures"
+)
+
+// StatsDayLayout formats the creation day projection counters are kept per
+const StatsDayLayout = "2006-01-02"
+
+// StatsCounters returns the projection counters the order adds one to. An
+// order counts as overdue once the overdue check reported it and while it
+// is still overdue at now, so the count follows the check's interval.
+func (po *PurchaseOrder) StatsCounters(now time.Time) map[string]int {
+	counters := make(map[string]int, 5)
+	counters[StatsTotal] = 1
+	counters[StatsStatusPrefix+po.Status] = 1
+	counters[StatsUrgencyPrefix+po.UrgencyLevel] = 1
+	counters[StatsSupplierPrefix+po.SupplierID] = 1
+	if po.Metadata[MetadataOverdueDetectedAt] != nil && po.IsOverdue(now) {
+		counters[StatsOverdue] = 1
+	}
+	if po.AwaitingDelivery() {
+		counters[StatsOpenPrefix+po.UrgencyLevel] = 1
+	}
+	if po.Receipt != nil {
+		counters[StatsReceipts] = 1
+		if latency := po.Receipt.ReceivedAt.Sub(po.CreatedAt); latency > 0 {
+			counters[StatsReceiptLatencySeconds] = int(latency / time.Second)
+		}
+		if po.Receipt.FailedQualityCheck() {
+			counters[StatsQualityFailures] = 1
+		}
+	}
+	return counters
+}
+
+// SummarizeStats turns summed projection counters into the statistics
+// returned by the stats query
+func SummarizeStats(counters map[string]int) map[string]interface{} {
+	byStatus := make(map[string]int)
+	byUrgency := make(map[string]int)
+	bySupplier := make(map[string]int)
+	openByUrgency := make(map[string]int)
+
+	for counter, count := range counters {
+		if count == 0 {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(counter, StatsStatusPrefix):
+			byStatus[strings.TrimPrefix(counter, StatsStatusPrefix)] = count
+		case strings.HasPrefix(counter, StatsUrgencyPrefix):
+			byUrgency[strings.TrimPrefix(counter, StatsUrgencyPrefix)] = count
+		case strings.HasPrefix(counter, StatsSupplierPrefix):
+			bySupplier[strings.TrimPrefix(counter, StatsSupplierPrefix)] = count
+		case strings.HasPrefix(counter, StatsOpenPrefix):
+			openByUrgency[strings.TrimPrefix(counter, StatsOpenPrefix)] = count
+		}
+	}
+
+	// Averages and rates are over the received orders, and zero without any
+	receipts := counters[StatsReceipts]
+	averageReceiptLatency, qualityFailureRate := 0.0, 0.0
+	if receipts > 0 {
+		averageReceiptLatency = float64(counters[StatsReceiptLatencySeconds]) / float64(receipts)
+		qualityFailureRate = float64(counters[StatsQualityFailures]) / float64(receipts)
+	}
+
+	return map[string]interface{}{
+		"total_orders":     counters[StatsTotal],
+		"pending_orders":   byStatus[StatusPending],
+		"completed_orders": byStatus[StatusReceived] + byStatus[StatusCompleted],
+		"overdue_orders":   counters[StatsOverdue],
+		"by_status":        byStatus,
+		"by_urgency":       byUrgency,
+		"by_supplier":      bySupplier,
+		"open_by_urgency":  openByUrgency,
+		"received_orders":  receipts,
+		"quality_failures": counters[StatsQualityFailures],
+		// Seconds from creation to receipt
+		"average_receipt_latency": averageReceiptLatency,
+		"quality_failure_rate":    qualityFailureRate,
+	}
+}
+
+// StatsPeriod is one bucket of the purchase order time series
+type StatsPeriod struct {
+	Start     string `json:"start"`
+	Created   int    `json:"created"`
+	Completed int    `json:"completed"`
+	Overdue   int    `json:"overdue"`
+}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBusinessStatsCounters(t *testing.T) {
+	created := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		name    string
+		status  string
+		receipt *InventoryReceipt
+		want    map[string]int
+	}{
+		{"open", StatusApproved, nil, map[string]int{StatsOpenPrefix + "high": 1}},
+		{"sent", StatusSent, nil, map[string]int{StatsOpenPrefix + "high": 1}},
+		{"cancelled", StatusCancelled, nil, map[string]int{}},
+		{
+			"received",
+			StatusCompleted,
+			&InventoryReceipt{QualityCheck: QualityCheckPassed, ReceivedAt: created.Add(36 * time.Hour)},
+			map[string]int{StatsReceipts: 1, StatsReceiptLatencySeconds: 36 * 3600},
+		},
+		{
+			"failed quality check",
+			StatusCompleted,
+			&InventoryReceipt{QualityCheck: "failed", ReceivedAt: created.Add(time.Hour)},
+			map[string]int{StatsReceipts: 1, StatsReceiptLatencySeconds: 3600, StatsQualityFailures: 1},
+		},
+		{
+			"received before it was created",
+			StatusCompleted,
+			&InventoryReceipt{ReceivedAt: created.Add(-time.Hour)},
+			map[string]int{StatsReceipts: 1},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			po := &PurchaseOrder{Status: tc.status, UrgencyLevel: "high", SupplierID: "supplier-1", CreatedAt: created, Receipt: tc.receipt}
+			counters := po.StatsCounters(created)
+			for _, counter := range []string{StatsOpenPrefix + "high", StatsReceipts, StatsReceiptLatencySeconds, StatsQualityFailures} {
+				if counters[counter] != tc.want[counter] {
+					t.Fatalf("counters %v, want %v", counters, tc.want)
+				}
+			}
+		})
+	}
+}
+
+func TestFailedQualityCheck(t *testing.T) {
+	for check, failed := range map[string]bool{"": false, QualityCheckPassed: false, "failed": true, "quarantined": true} {
+		if got := (&InventoryReceipt{QualityCheck: check}).FailedQualityCheck(); got != failed {
+			t.Errorf("quality check %q failed: %t, want %t", check, got, failed)
+		}
+	}
+}
+
+func TestSummarizeBusinessStats(t *testing.T) {
+	summary := SummarizeStats(map[string]int{
+		StatsOpenPrefix + "high":   3,
+		StatsOpenPrefix + "low":    0,
+		StatsReceipts:              4,
+		StatsReceiptLatencySeconds: 4 * 7200,
+		StatsQualityFailures:       1,
+	})
+	open, _ := summary["open_by_urgency"].(map[string]int)
+	if len(open) != 1 || open["high"] != 3 {
+		t.Fatalf("open by urgency %v, want only high", summary["open_by_urgency"])
+	}
+	if summary["received_orders"] != 4 || summary["quality_failures"] != 1 || summary["average_receipt_latency"] != 7200.0 || summary["quality_failure_rate"] != 0.25 {
+		t.Fatalf("summary %v", summary)
+	}
+
+	// Without receipts the average and rate are zero rather than undefined
+	if summary := SummarizeStats(map[string]int{StatsTotal: 2}); summary["average_receipt_latency"] != 0.0 || summary["quality_failure_rate"] != 0.0 {
+		t.Fatalf("summary without receipts %v", summary)
+	}
+}
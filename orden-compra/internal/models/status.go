@@ -0,0 +1,74 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Purchase order statuses
+const (
+	StatusPending         = "pending"
+	StatusPendingApproval = "pending_approval"
+	StatusApproved        = "approved"
+	StatusRejected        = "rejected"
+	StatusSent            = "sent"
+	StatusReceived        = "received"
+	StatusCompleted       = "completed"
+	StatusCancelled       = "cancelled"
+)
+
+// ErrInvalidStatusTransition is returned when a status change is not allowed
+var ErrInvalidStatusTransition = errors.New("invalid status transition")
+
+// statusTransitions lists the statuses reachable from each status
+var statusTransitions = map[string][]string{
+	StatusPending:         {StatusApproved, StatusSent, StatusCancelled},
+	StatusPendingApproval: {StatusApproved, StatusRejected, StatusCancelled},
+	StatusApproved:        {StatusSent, StatusCancelled},
+	StatusRejected:        {},
+	StatusSent:            {StatusReceived, StatusCancelled},
+	StatusReceived:        {StatusCompleted},
+	StatusCompleted:       {},
+	StatusCancelled:       {},
+}
+
+// StatusTransitionError describes a rejected status change
+type StatusTransitionError struct {
+	From    string
+	To      string
+	Allowed []string
+}
+
+// Error implements the error interface
+func (e *StatusTransitionError) Error() string {
+	return fmt.Sprintf("cannot change status from %q to %q (allowed: %v)", e.From, e.To, e.Allowed)
+}
+
+// Unwrap allows errors.Is checks against ErrInvalidStatusTransition
+func (e *StatusTransitionError) Unwrap() error {
+	return ErrInvalidStatusTransition
+}
+
+// IsValidStatus checks if the status is a known purchase order status
+func IsValidStatus(status string) bool {
+	_, ok := statusTransitions[status]
+	return ok
+}
+
+// AllowedTransitions returns the statuses reachable from the given status
+func AllowedTransitions(from string) []string {
+	return statusTransitions[from]
+}
+
+// ValidateTransition checks that a purchase order may move from one status to another
+func ValidateTransition(from, to string) error {
+	if !IsValidStatus(to) {
+		return &StatusTransitionError{From: from, To: to, Allowed: AllowedTransitions(from)}
+	}
+	for _, allowed := range statusTransitions[from] {
+		if allowed == to {
+			return nil
+		}
+	}
+	return &StatusTransitionError{From: from, To: to, Allowed: AllowedTransitions(from)}
+}
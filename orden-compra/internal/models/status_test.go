@@ -0,0 +1,92 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestValidateTransition(t *testing.T) {
+	statuses := []string{StatusPending, StatusPendingApproval, StatusApproved, StatusRejected, StatusSent, StatusReceived, StatusCompleted, StatusCancelled}
+	allowed := map[string]bool{
+		StatusPending + ">" + StatusApproved:          true,
+		StatusPending + ">" + StatusSent:              true,
+		StatusPending + ">" + StatusCancelled:         true,
+		StatusPendingApproval + ">" + StatusApproved:  true,
+		StatusPendingApproval + ">" + StatusRejected:  true,
+		StatusPendingApproval + ">" + StatusCancelled: true,
+		StatusApproved + ">" + StatusSent:             true,
+		StatusApproved + ">" + StatusCancelled:        true,
+		StatusSent + ">" + StatusReceived:             true,
+		StatusSent + ">" + StatusCancelled:            true,
+		StatusReceived + ">" + StatusCompleted:        true,
+	}
+
+	// Every pair of statuses, the same status included
+	for _, from := range statuses {
+		for _, to := range statuses {
+			err := ValidateTransition(from, to)
+			if allowed[from+">"+to] {
+				if err != nil {
+					t.Errorf("%s -> %s refused: %v", from, to, err)
+				}
+				continue
+			}
+			var transition *StatusTransitionError
+			if !errors.As(err, &transition) || !errors.Is(err, ErrInvalidStatusTransition) {
+				t.Errorf("%s -> %s returned %v, want a StatusTransitionError", from, to, err)
+				continue
+			}
+			if transition.From != from || transition.To != to || len(transition.Allowed) != len(AllowedTransitions(from)) {
+				t.Errorf("%s -> %s error %+v", from, to, transition)
+			}
+		}
+	}
+
+	if err := ValidateTransition(StatusPending, "shipped"); !errors.Is(err, ErrInvalidStatusTransition) {
+		t.Fatalf("unknown target status returned %v", err)
+	}
+	if err := ValidateTransition("", StatusSent); !errors.Is(err, ErrInvalidStatusTransition) {
+		t.Fatalf("unknown current status returned %v", err)
+	}
+	if IsValidStatus("shipped") || !IsValidStatus(StatusCancelled) {
+		t.Fatal("IsValidStatus does not follow the known statuses")
+	}
+}
+
+func TestStatusTransitionErrorMessage(t *testing.T) {
+	err := ValidateTransition(StatusReceived, StatusSent)
+	if want := `cannot change status from "received" to "sent" (allowed: [completed])`; err == nil || err.Error() != want {
+		t.Fatalf("error %v, want %s", err, want)
+	}
+}
+
+func TestUpdateStatus(t *testing.T) {
+	createdAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	po := NewPurchaseOrder("product-1", "Gloves", "supplier-1", "Acme", "warehouse-1", "high", 10, createdAt)
+
+	sentAt := createdAt.Add(time.Hour).In(time.FixedZone("COT", -5*3600))
+	if err := po.UpdateStatus(StatusSent, sentAt); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if po.Status != StatusSent || !po.UpdatedAt.Equal(sentAt) || po.UpdatedAt.Location() != time.UTC || po.ActualDate != nil {
+		t.Fatalf("order %+v after sending", po)
+	}
+
+	// A refused transition leaves the order as it was
+	if err := po.UpdateStatus(StatusPending, sentAt.Add(time.Hour)); err == nil {
+		t.Fatal("sent -> pending accepted")
+	}
+	if po.Status != StatusSent || !po.UpdatedAt.Equal(sentAt) {
+		t.Fatalf("order %+v after a refused transition", po)
+	}
+
+	// Receiving records the actual delivery date
+	receivedAt := sentAt.Add(48 * time.Hour)
+	if err := po.UpdateStatus(StatusReceived, receivedAt); err != nil {
+		t.Fatalf("receive: %v", err)
+	}
+	if po.ActualDate == nil || !po.ActualDate.Equal(receivedAt) {
+		t.Fatalf("actual date %v, want %s", po.ActualDate, receivedAt)
+	}
+}
@@ -0,0 +1,204 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PurchaseOrderSupplierUpdatedEventType is recorded when an order awaiting
+// delivery takes the new name of its renamed supplier
+const PurchaseOrderSupplierUpdatedEventType = "PurchaseOrderSupplierUpdated"
+
+// SupplierProduct maps a product to the supplier that stocks it
+type SupplierProduct struct {
+	ProductID   string `json:"product_id" dynamodbav:"product_id"`
+	SupplierSKU string `json:"supplier_sku,omitempty" dynamodbav:"supplier_sku,omitempty"`
+	// LeadTimeDays overrides the supplier's lead time for this product
+	LeadTimeDays int `json:"lead_time_days,omitempty" dynamodbav:"lead_time_days,omitempty"`
+	// Contract holds the agreed terms, if any
+	Contract *SupplierContract `json:"contract,omitempty" dynamodbav:"contract,omitempty"`
+}
+
+// Roles of supplier contacts
+const (
+	ContactRolePurchasing = "purchasing"
+	ContactRoleQuality    = "quality"
+	ContactRoleEmergency  = "emergency"
+)
+
+// ValidContactRoles lists the roles a supplier contact may have
+var ValidContactRoles = map[string]bool{
+	ContactRolePurchasing: true,
+	ContactRoleQuality:    true,
+	ContactRoleEmergency:  true,
+}
+
+// SupplierContact is a person or mailbox at a supplier
+type SupplierContact struct {
+	ID    string `json:"id" dynamodbav:"id"`
+	Role  string `json:"role" dynamodbav:"role"`
+	Name  string `json:"name,omitempty" dynamodbav:"name,omitempty"`
+	Email string `json:"email,omitempty" dynamodbav:"email,omitempty"`
+	Phone string `json:"phone,omitempty" dynamodbav:"phone,omitempty"`
+}
+
+// EscalationRule picks the contact roles notified of an event. An empty
+// event matches every event and empty urgency levels match every urgency.
+type EscalationRule struct {
+	Event         string   `json:"event,omitempty" dynamodbav:"event,omitempty"`
+	UrgencyLevels []string `json:"urgency_levels,omitempty" dynamodbav:"urgency_levels,omitempty"`
+	Roles         []string `json:"roles" dynamodbav:"roles"`
+}
+
+// Matches reports whether the rule applies to an event of the given urgency
+func (r EscalationRule) Matches(event, urgencyLevel string) bool {
+	if r.Event != "" && r.Event != event {
+		return false
+	}
+	if len(r.UrgencyLevels) == 0 {
+		return true
+	}
+	for _, level := range r.UrgencyLevels {
+		if level == urgencyLevel {
+			return true
+		}
+	}
+	return false
+}
+
+// Contact returns the supplier's contact with the given ID, or nil if it has none
+func (s *Supplier) Contact(contactID string) *SupplierContact {
+	for i := range s.Contacts {
+		if s.Contacts[i].ID == contactID {
+			return &s.Contacts[i]
+		}
+	}
+	return nil
+}
+
+// EscalationContacts returns the contacts to notify of an event: those with
+// a role of the first of the supplier's rules that matches, or of the first
+// matching default rule when none of the supplier's do. When no contact has
+// those roles the supplier's own email address is used, if it has one.
+func (s *Supplier) EscalationContacts(event, urgencyLevel string, defaults []EscalationRule) []SupplierContact {
+	var roles []string
+	for _, rules := range [][]EscalationRule{s.EscalationRules, defaults} {
+		for _, rule := range rules {
+			if rule.Matches(event, urgencyLevel) {
+				roles = rule.Roles
+				break
+			}
+		}
+		if roles != nil {
+			break
+		}
+	}
+
+	wanted := make(map[string]bool, len(roles))
+	for _, role := range roles {
+		wanted[role] = true
+	}
+	var contacts []SupplierContact
+	for _, contact := range s.Contacts {
+		if wanted[contact.Role] && contact.Email != "" {
+			contacts = append(contacts, contact)
+		}
+	}
+
+	if len(contacts) == 0 && s.Email != "" {
+		contacts = append(contacts, SupplierContact{Role: ContactRolePurchasing, Name: s.Name, Email: s.Email})
+	}
+	return contacts
+}
+
+// Product returns the supplier's mapping of a product, or nil if it has none
+func (s *Supplier) Product(productID string) *SupplierProduct {
+	for i := range s.Products {
+		if s.Products[i].ProductID == productID {
+			return &s.Products[i]
+		}
+	}
+	return nil
+}
+
+// Validate checks a supplier catalog entry for required fields, sane lead
+// times and well-formed contacts and escalation rules
+func (s *Supplier) Validate() error {
+	var errs ValidationErrors
+
+	if strings.TrimSpace(s.ID) == "" {
+		errs.add("id", "is required")
+	}
+	if strings.TrimSpace(s.Name) == "" {
+		errs.add("name", "is required")
+	}
+	if s.Email != "" && !strings.Contains(s.Email, "@") {
+		errs.add("email", fmt.Sprintf("invalid email address %q", s.Email))
+	}
+	if s.LeadTimeDays < 0 {
+		errs.add("lead_time_days", fmt.Sprintf("must not be negative, got %d", s.LeadTimeDays))
+	}
+	if s.CriticalLeadTimeDays < 0 {
+		errs.add("critical_lead_time_days", fmt.Sprintf("must not be negative, got %d", s.CriticalLeadTimeDays))
+	}
+
+	seen := make(map[string]bool, len(s.Products))
+	for i, product := range s.Products {
+		field := fmt.Sprintf("products[%d]", i)
+		switch {
+		case strings.TrimSpace(product.ProductID) == "":
+			errs.add(field+".product_id", "is required")
+		case seen[product.ProductID]:
+			errs.add(field+".product_id", fmt.Sprintf("product %s is listed more than once", product.ProductID))
+		}
+		seen[product.ProductID] = true
+		if product.LeadTimeDays < 0 {
+			errs.add(field+".lead_time_days", fmt.Sprintf("must not be negative, got %d", product.LeadTimeDays))
+		}
+		if product.Contract != nil {
+			product.Contract.validate(field+".contract", &errs)
+		}
+	}
+
+	contactIDs := make(map[string]bool, len(s.Contacts))
+	for i, contact := range s.Contacts {
+		field := fmt.Sprintf("contacts[%d]", i)
+		switch {
+		case strings.TrimSpace(contact.ID) == "":
+			errs.add(field+".id", "is required")
+		case contactIDs[contact.ID]:
+			errs.add(field+".id", fmt.Sprintf("contact %s is listed more than once", contact.ID))
+		}
+		contactIDs[contact.ID] = true
+		if !ValidContactRoles[contact.Role] {
+			errs.add(field+".role", fmt.Sprintf("unknown contact role %q", contact.Role))
+		}
+		if contact.Email == "" && contact.Phone == "" {
+			errs.add(field, "needs an email address or a phone number")
+		} else if contact.Email != "" && !strings.Contains(contact.Email, "@") {
+			errs.add(field+".email", fmt.Sprintf("invalid email address %q", contact.Email))
+		}
+	}
+
+	for i, rule := range s.EscalationRules {
+		field := fmt.Sprintf("escalation_rules[%d]", i)
+		for _, level := range rule.UrgencyLevels {
+			if !ValidUrgencyLevels[level] {
+				errs.add(field+".urgency_levels", fmt.Sprintf("unknown urgency level %q", level))
+			}
+		}
+		if len(rule.Roles) == 0 {
+			errs.add(field+".roles", "is required")
+		}
+		for _, role := range rule.Roles {
+			if !ValidContactRoles[role] {
+				errs.add(field+".roles", fmt.Sprintf("unknown contact role %q", role))
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
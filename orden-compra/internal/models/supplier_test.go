@@ -0,0 +1,111 @@
+package models
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSupplierValidate(t *testing.T) {
+	valid := func() Supplier {
+		return Supplier{
+			ID:           "supplier-1",
+			Name:         "Acme",
+			Email:        "orders@acme.example.com",
+			LeadTimeDays: 5,
+			Products:     []SupplierProduct{{ProductID: "product-1", SupplierSKU: "ACME-1", LeadTimeDays: 3}},
+		}
+	}
+
+	for _, tc := range []struct {
+		name   string
+		change func(*Supplier)
+		fields []string
+	}{
+		{"valid", func(*Supplier) {}, nil},
+		{"no email or products", func(s *Supplier) { s.Email, s.Products = "", nil }, nil},
+		{"missing id and name", func(s *Supplier) { s.ID, s.Name = " ", "" }, []string{"id", "name"}},
+		{"invalid email", func(s *Supplier) { s.Email = "acme" }, []string{"email"}},
+		{"negative lead times", func(s *Supplier) { s.LeadTimeDays, s.CriticalLeadTimeDays = -1, -1 }, []string{"lead_time_days", "critical_lead_time_days"}},
+		{"product without an id", func(s *Supplier) { s.Products = append(s.Products, SupplierProduct{}) }, []string{"products[1].product_id"}},
+		{"product listed twice", func(s *Supplier) { s.Products = append(s.Products, SupplierProduct{ProductID: "product-1"}) }, []string{"products[1].product_id"}},
+		{"negative product lead time", func(s *Supplier) { s.Products[0].LeadTimeDays = -2 }, []string{"products[0].lead_time_days"}},
+		{"contact without an id or a way to reach it", func(s *Supplier) { s.Contacts = []SupplierContact{{Role: ContactRoleQuality}} }, []string{"contacts[0].id", "contacts[0]"}},
+		{"contact listed twice", func(s *Supplier) {
+			s.Contacts = []SupplierContact{{ID: "c-1", Role: ContactRoleQuality, Phone: "1"}, {ID: "c-1", Role: "sales", Email: "sales"}}
+		}, []string{"contacts[1].id", "contacts[1].role", "contacts[1].email"}},
+		{"escalation rule", func(s *Supplier) { s.EscalationRules = []EscalationRule{{UrgencyLevels: []string{"urgent"}}} }, []string{"escalation_rules[0].urgency_levels", "escalation_rules[0].roles"}},
+		{"escalation rule with an unknown role", func(s *Supplier) { s.EscalationRules = []EscalationRule{{Roles: []string{"sales"}}} }, []string{"escalation_rules[0].roles"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			supplier := valid()
+			tc.change(&supplier)
+			err := supplier.Validate()
+			if tc.fields == nil {
+				if err != nil {
+					t.Fatalf("Validate returned %v", err)
+				}
+				return
+			}
+
+			var errs ValidationErrors
+			if !errors.As(err, &errs) || len(errs) != len(tc.fields) {
+				t.Fatalf("Validate returned %v, want errors on %v", err, tc.fields)
+			}
+			for i, field := range tc.fields {
+				if errs[i].Field != field {
+					t.Fatalf("error %d is %+v, want one on %s", i, errs[i], field)
+				}
+			}
+		})
+	}
+}
+
+func TestSupplierProduct(t *testing.T) {
+	supplier := &Supplier{Products: []SupplierProduct{{ProductID: "product-1"}, {ProductID: "product-2", SupplierSKU: "SKU-2"}}}
+	if product := supplier.Product("product-2"); product == nil || product.SupplierSKU != "SKU-2" {
+		t.Fatalf("product-2 mapped as %+v", product)
+	}
+	if product := supplier.Product("product-3"); product != nil {
+		t.Fatalf("product-3 mapped as %+v", product)
+	}
+}
+
+func TestEscalationContacts(t *testing.T) {
+	defaults := []EscalationRule{
+		{Event: "quality_check_failed", Roles: []string{ContactRoleQuality}},
+		{UrgencyLevels: []string{"critical"}, Roles: []string{ContactRoleEmergency, ContactRolePurchasing}},
+		{Roles: []string{ContactRolePurchasing}},
+	}
+	contacts := []SupplierContact{
+		{ID: "purchasing", Role: ContactRolePurchasing, Email: "buying@acme.test"},
+		{ID: "emergency", Role: ContactRoleEmergency, Email: "oncall@acme.test"},
+		{ID: "quality", Role: ContactRoleQuality, Phone: "+57 300 000 0000"},
+	}
+
+	for _, tc := range []struct {
+		name     string
+		supplier Supplier
+		event    string
+		urgency  string
+		want     []string
+	}{
+		{"default rule", Supplier{Contacts: contacts}, "purchase_order_overdue", "low", []string{"purchasing"}},
+		{"default rule for the urgency", Supplier{Contacts: contacts}, "purchase_order_overdue", "critical", []string{"purchasing", "emergency"}},
+		{"supplier rule first", Supplier{Contacts: contacts, EscalationRules: []EscalationRule{{Event: "purchase_order_overdue", Roles: []string{ContactRoleEmergency}}}}, "purchase_order_overdue", "low", []string{"emergency"}},
+		{"supplier rule that does not match", Supplier{Contacts: contacts, EscalationRules: []EscalationRule{{UrgencyLevels: []string{"critical"}, Roles: []string{ContactRoleEmergency}}}}, "purchase_order_overdue", "high", []string{"purchasing"}},
+		{"contacts without an email", Supplier{Contacts: contacts}, "quality_check_failed", "low", nil},
+		{"supplier email", Supplier{Name: "Acme", Email: "orders@acme.test", Contacts: contacts}, "quality_check_failed", "low", []string{""}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := tc.supplier.EscalationContacts(tc.event, tc.urgency, defaults)
+			if len(got) != len(tc.want) {
+				t.Fatalf("contacts %+v, want %v", got, tc.want)
+			}
+			for i, id := range tc.want {
+				if got[i].ID != id || got[i].Email == "" {
+					t.Fatalf("contacts %+v, want %v", got, tc.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MaxEventClockSkew is how far in the future an event timestamp may be before it is rejected
+const MaxEventClockSkew = 5 * time.Minute
+
+// ValidUrgencyLevels lists the urgency levels accepted on incoming events
+var ValidUrgencyLevels = map[string]bool{
+	"low":      true,
+	"medium":   true,
+	"high":     true,
+	"critical": true,
+}
+
+// ValidationError describes a single invalid field on an incoming event
+type ValidationError struct {
+	Field   string `json:"field" dynamodbav:"field"`
+	Message string `json:"message" dynamodbav:"message"`
+}
+
+// ValidationErrors is the set of problems found while validating an event
+type ValidationErrors []ValidationError
+
+// Error implements the error interface
+func (v ValidationErrors) Error() string {
+	messages := make([]string, 0, len(v))
+	for _, e := range v {
+		messages = append(messages, fmt.Sprintf("%s: %s", e.Field, e.Message))
+	}
+	return "validation failed: " + strings.Join(messages, "; ")
+}
+
+// add appends a validation error
+func (v *ValidationErrors) add(field, message string) {
+	*v = append(*v, ValidationError{Field: field, Message: message})
+}
+
+// Validate checks the stock low event for required fields and sane values
+func (s *StockLowEvent) Validate(now time.Time) error {
+	var errs ValidationErrors
+
+	if strings.TrimSpace(s.ID) == "" {
+		errs.add("id", "is required")
+	}
+	if s.EventType != "" && s.EventType != StockLowEventType {
+		errs.add("event_type", fmt.Sprintf("must be %s, got %s", StockLowEventType, s.EventType))
+	}
+	if strings.TrimSpace(s.ProductID) == "" {
+		errs.add("product_id", "is required")
+	}
+	if strings.TrimSpace(s.Location) == "" {
+		errs.add("location", "is required")
+	}
+	if s.CurrentStock < 0 {
+		errs.add("current_stock", "must not be negative")
+	}
+	if s.MinimumStock < 0 {
+		errs.add("minimum_stock", "must not be negative")
+	}
+	if !ValidUrgencyLevels[s.UrgencyLevel] {
+		errs.add("urgency_level", fmt.Sprintf("unknown urgency level %q", s.UrgencyLevel))
+	}
+	if s.Timestamp.IsZero() {
+		errs.add("timestamp", "is required")
+	} else if s.Timestamp.After(now.UTC().Add(MaxEventClockSkew)) {
+		errs.add("timestamp", "is in the future")
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Statuses of a dead letter record
+const (
+	DeadLetterFailed   = "failed"
+	DeadLetterReplayed = "replayed"
+)
+
+// DeadLetterRecord represents an incoming message that was rejected to the DLQ
+type DeadLetterRecord struct {
+	ID            string                 `json:"id" dynamodbav:"id"`
+	TenantID      string                 `json:"tenant_id,omitempty" dynamodbav:"tenant_id,omitempty"`
+	MessageID     string                 `json:"message_id" dynamodbav:"message_id"`
+	Queue         string                 `json:"queue,omitempty" dynamodbav:"queue,omitempty"`
+	RoutingKey    string                 `json:"routing_key" dynamodbav:"routing_key"`
+	ContentType   string                 `json:"content_type,omitempty" dynamodbav:"content_type,omitempty"`
+	Reason        string                 `json:"reason" dynamodbav:"reason"`
+	Errors        ValidationErrors       `json:"errors" dynamodbav:"errors"`
+	Payload       string                 `json:"payload" dynamodbav:"payload"`
+	Headers       map[string]interface{} `json:"headers" dynamodbav:"headers"`
+	CorrelationID string                 `json:"correlation_id,omitempty" dynamodbav:"correlation_id,omitempty"`
+	Status        string                 `json:"status" dynamodbav:"status"`
+	ReceivedAt    time.Time              `json:"received_at" dynamodbav:"received_at"`
+	ReplayedAt    *time.Time             `json:"replayed_at,omitempty" dynamodbav:"replayed_at,omitempty"`
+	ExpiresAt     int64                  `json:"expires_at,omitempty" dynamodbav:"expires_at,omitempty"`
+}
+
+// NewDeadLetterRecord creates a new DeadLetterRecord of a message consumed from queue
+func NewDeadLetterRecord(messageID, queue, routingKey, contentType, reason string, errs ValidationErrors, payload []byte, headers map[string]interface{}, correlationID string, now time.Time) *DeadLetterRecord {
+	now = now.UTC()
+	return &DeadLetterRecord{
+		ID:            uuid.New().String(),
+		MessageID:     messageID,
+		Queue:         queue,
+		RoutingKey:    routingKey,
+		ContentType:   contentType,
+		Reason:        reason,
+		Errors:        errs,
+		Payload:       string(payload),
+		Headers:       headers,
+		CorrelationID: correlationID,
+		Status:        DeadLetterFailed,
+		ReceivedAt:    now,
+		ExpiresAt:     ExpiresAt(now, DeadLetterRetention),
+	}
+}
+
+// Normalize fills in the status of records written before dead letters
+// could be replayed
+func (r *DeadLetterRecord) Normalize() {
+	if r.Status == "" {
+		r.Status = DeadLetterFailed
+	}
+}
@@ -0,0 +1,91 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestStockLowEventValidate(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	valid := func() StockLowEvent {
+		return StockLowEvent{
+			ID:           "stock-low-1",
+			Timestamp:    now,
+			EventType:    StockLowEventType,
+			ProductID:    "product-1",
+			CurrentStock: 5,
+			MinimumStock: 20,
+			Location:     "warehouse-1",
+			UrgencyLevel: "high",
+		}
+	}
+
+	for _, tc := range []struct {
+		name   string
+		change func(*StockLowEvent)
+		fields []string
+	}{
+		{"valid", func(*StockLowEvent) {}, nil},
+		{"event type left out", func(e *StockLowEvent) { e.EventType = "" }, nil},
+		{"clock skew within bounds", func(e *StockLowEvent) { e.Timestamp = now.Add(MaxEventClockSkew) }, nil},
+		{"missing id", func(e *StockLowEvent) { e.ID = " " }, []string{"id"}},
+		{"other event type", func(e *StockLowEvent) { e.EventType = "StockAlto" }, []string{"event_type"}},
+		{"missing product and location", func(e *StockLowEvent) { e.ProductID, e.Location = "", "" }, []string{"product_id", "location"}},
+		{"negative stock", func(e *StockLowEvent) { e.CurrentStock, e.MinimumStock = -1, -1 }, []string{"current_stock", "minimum_stock"}},
+		{"unknown urgency", func(e *StockLowEvent) { e.UrgencyLevel = "urgent" }, []string{"urgency_level"}},
+		{"missing timestamp", func(e *StockLowEvent) { e.Timestamp = time.Time{} }, []string{"timestamp"}},
+		{"future timestamp", func(e *StockLowEvent) { e.Timestamp = now.Add(MaxEventClockSkew + time.Second) }, []string{"timestamp"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			event := valid()
+			tc.change(&event)
+			err := event.Validate(now)
+			if tc.fields == nil {
+				if err != nil {
+					t.Fatalf("Validate returned %v", err)
+				}
+				return
+			}
+
+			var errs ValidationErrors
+			if !errors.As(err, &errs) || len(errs) != len(tc.fields) {
+				t.Fatalf("Validate returned %v, want errors on %v", err, tc.fields)
+			}
+			for i, field := range tc.fields {
+				if errs[i].Field != field || errs[i].Message == "" {
+					t.Fatalf("error %d is %+v, want one on %s", i, errs[i], field)
+				}
+			}
+		})
+	}
+}
+
+func TestValidationErrorsMessage(t *testing.T) {
+	errs := ValidationErrors{{Field: "id", Message: "is required"}, {Field: "location", Message: "is required"}}
+	if got := errs.Error(); got != "validation failed: id: is required; location: is required" {
+		t.Fatalf("Error() = %q", got)
+	}
+}
+
+func TestNewDeadLetterRecord(t *testing.T) {
+	receivedAt := time.Date(2024, 3, 1, 9, 0, 0, 0, time.FixedZone("COT", -5*3600))
+	record := NewDeadLetterRecord("message-1", "orden-compra.stock-bajo", "stock.bajo", "application/json", "validation_failed", ValidationErrors{{Field: "id", Message: "is required"}}, []byte(`{}`), map[string]interface{}{"correlation-id": "c-1"}, "c-1", receivedAt)
+
+	if record.ID == "" || record.Status != DeadLetterFailed || record.Payload != "{}" || record.CorrelationID != "c-1" {
+		t.Fatalf("record %+v", record)
+	}
+	if record.ReceivedAt.Location() != time.UTC || !record.ReceivedAt.Equal(receivedAt) {
+		t.Fatalf("received at %v, want %v in UTC", record.ReceivedAt, receivedAt)
+	}
+	if record.ExpiresAt != ExpiresAt(receivedAt, DeadLetterRetention) {
+		t.Fatalf("expires at %d", record.ExpiresAt)
+	}
+
+	// Records stored before replay support read as failed
+	old := DeadLetterRecord{}
+	old.Normalize()
+	if old.Status != DeadLetterFailed {
+		t.Fatalf("normalized status %q", old.Status)
+	}
+}
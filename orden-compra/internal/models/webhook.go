@@ -0,0 +1,126 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Webhook event types external systems can subscribe to
+const (
+	WebhookPurchaseOrderCreated      = "purchase_order.created"
+	WebhookPurchaseOrderApproved     = "purchase_order.approved"
+	WebhookPurchaseOrderRejected     = "purchase_order.rejected"
+	WebhookPurchaseOrderCancelled    = "purchase_order.cancelled"
+	WebhookPurchaseOrderOverdue      = "purchase_order.overdue"
+	WebhookPurchaseOrderShipped      = "purchase_order.shipment_notified"
+	WebhookPurchaseOrderAcknowledged = "purchase_order.acknowledged"
+	WebhookPurchaseOrderCompleted    = "purchase_order.completed"
+	WebhookInvoiceMismatch           = "purchase_order.invoice_mismatch"
+	WebhookReceptionRequested        = "reception.requested"
+	// WebhookAllEvents subscribes to every event type
+	WebhookAllEvents = "*"
+)
+
+// WebhookEventTypes lists the event types that can be subscribed to
+var WebhookEventTypes = []string{
+	WebhookPurchaseOrderCreated,
+	WebhookPurchaseOrderApproved,
+	WebhookPurchaseOrderRejected,
+	WebhookPurchaseOrderCancelled,
+	WebhookPurchaseOrderOverdue,
+	WebhookPurchaseOrderShipped,
+	WebhookPurchaseOrderAcknowledged,
+	WebhookPurchaseOrderCompleted,
+	WebhookInvoiceMismatch,
+	WebhookReceptionRequested,
+}
+
+// Webhook delivery statuses
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+// WebhookSubscription is an external endpoint registered for event types
+type WebhookSubscription struct {
+	ID         string    `json:"id" dynamodbav:"id"`
+	TenantID   string    `json:"tenant_id,omitempty" dynamodbav:"tenant_id,omitempty"`
+	URL        string    `json:"url" dynamodbav:"url"`
+	Secret     string    `json:"secret,omitempty" dynamodbav:"secret"`
+	EventTypes []string  `json:"event_types" dynamodbav:"event_types"`
+	Active     bool      `json:"active" dynamodbav:"active"`
+	CreatedAt  time.Time `json:"created_at" dynamodbav:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at" dynamodbav:"updated_at"`
+}
+
+// NewWebhookSubscription creates a new active WebhookSubscription
+func NewWebhookSubscription(url, secret string, eventTypes []string, now time.Time) *WebhookSubscription {
+	now = now.UTC()
+	return &WebhookSubscription{
+		ID:         uuid.New().String(),
+		URL:        url,
+		Secret:     secret,
+		EventTypes: eventTypes,
+		Active:     true,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+}
+
+// Matches checks if the subscription wants the event type
+func (s *WebhookSubscription) Matches(eventType string) bool {
+	if !s.Active {
+		return false
+	}
+	for _, t := range s.EventTypes {
+		if t == eventType || t == WebhookAllEvents {
+			return true
+		}
+	}
+	return false
+}
+
+// IsValidWebhookEventType checks if the event type can be subscribed to
+func IsValidWebhookEventType(eventType string) bool {
+	if eventType == WebhookAllEvents {
+		return true
+	}
+	for _, t := range WebhookEventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery tracks the delivery of one event to one subscription
+type WebhookDelivery struct {
+	ID             string    `json:"id" dynamodbav:"id"`
+	TenantID       string    `json:"tenant_id,omitempty" dynamodbav:"tenant_id,omitempty"`
+	SubscriptionID string    `json:"subscription_id" dynamodbav:"subscription_id"`
+	EventID        string    `json:"event_id" dynamodbav:"event_id"`
+	EventType      string    `json:"event_type" dynamodbav:"event_type"`
+	Status         string    `json:"status" dynamodbav:"status"`
+	Attempts       int       `json:"attempts" dynamodbav:"attempts"`
+	ResponseStatus int       `json:"response_status,omitempty" dynamodbav:"response_status,omitempty"`
+	LastError      string    `json:"last_error,omitempty" dynamodbav:"last_error,omitempty"`
+	CreatedAt      time.Time `json:"created_at" dynamodbav:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at" dynamodbav:"updated_at"`
+	ExpiresAt      int64     `json:"expires_at,omitempty" dynamodbav:"expires_at,omitempty"`
+}
+
+// NewWebhookDelivery creates a new pending WebhookDelivery
+func NewWebhookDelivery(subscriptionID, eventID, eventType string, now time.Time) *WebhookDelivery {
+	now = now.UTC()
+	return &WebhookDelivery{
+		ID:             uuid.New().String(),
+		SubscriptionID: subscriptionID,
+		EventID:        eventID,
+		EventType:      eventType,
+		Status:         DeliveryPending,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+}
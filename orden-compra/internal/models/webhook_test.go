@@ -0,0 +1,40 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWebhookSubscriptionMatches(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		eventTypes []string
+		active     bool
+		eventType  string
+		want       bool
+	}{
+		{"subscribed type", []string{WebhookPurchaseOrderCreated, WebhookPurchaseOrderApproved}, true, WebhookPurchaseOrderApproved, true},
+		{"other type", []string{WebhookPurchaseOrderCreated}, true, WebhookPurchaseOrderApproved, false},
+		{"every event", []string{WebhookAllEvents}, true, WebhookReceptionRequested, true},
+		{"inactive", []string{WebhookAllEvents}, false, WebhookPurchaseOrderCreated, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			subscription := NewWebhookSubscription("https://erp.example.com/hooks", "secret", tc.eventTypes, time.Now())
+			subscription.Active = tc.active
+			if got := subscription.Matches(tc.eventType); got != tc.want {
+				t.Fatalf("Matches(%s) = %v, want %v", tc.eventType, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestIsValidWebhookEventType(t *testing.T) {
+	for _, eventType := range append([]string{WebhookAllEvents}, WebhookEventTypes...) {
+		if !IsValidWebhookEventType(eventType) {
+			t.Fatalf("%s is not valid", eventType)
+		}
+	}
+	if IsValidWebhookEventType("purchase_order.deleted") || IsValidWebhookEventType("") {
+		t.Fatal("unknown event type is valid")
+	}
+}
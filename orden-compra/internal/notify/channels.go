@@ -0,0 +1,159 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/smtp"
+	"strings"
+)
+
+// SMTPChannel sends notifications as plain-text email
+type SMTPChannel struct {
+	Addr string
+	From string
+	To   []string
+	Auth smtp.Auth
+}
+
+// NewSMTPChannel creates an SMTP channel. Authentication is skipped when the username is empty.
+func NewSMTPChannel(addr, from string, to []string, username, password string) *SMTPChannel {
+	var auth smtp.Auth
+	if username != "" {
+		host := addr
+		if i := strings.LastIndex(addr, ":"); i >= 0 {
+			host = addr[:i]
+		}
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return &SMTPChannel{
+		Addr: addr,
+		From: from,
+		To:   to,
+		Auth: auth,
+	}
+}
+
+// Name returns the channel name
+func (c *SMTPChannel) Name() string {
+	return "email"
+}
+
+// Send sends the message to every recipient
+func (c *SMTPChannel) Send(ctx context.Context, message Message) error {
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", c.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(c.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", message.Subject)
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	msg.WriteString(message.Body)
+
+	// net/smtp has no context support; run it aside so a hung server cannot outlive the deadline
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(c.Addr, c.Auth, c.From, c.To, msg.Bytes())
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("failed to send email: %w", err)
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// SlackChannel posts notifications to a Slack incoming webhook
+type SlackChannel struct {
+	WebhookURL string
+	Client     *http.Client
+}
+
+// NewSlackChannel creates a Slack channel
+func NewSlackChannel(webhookURL string) *SlackChannel {
+	return &SlackChannel{
+		WebhookURL: webhookURL,
+		Client:     &http.Client{},
+	}
+}
+
+// Name returns the channel name
+func (c *SlackChannel) Name() string {
+	return "slack"
+}
+
+// Send posts the message body as Slack text
+func (c *SlackChannel) Send(ctx context.Context, message Message) error {
+	return postJSON(ctx, c.Client, c.WebhookURL, nil, map[string]string{"text": message.Body})
+}
+
+// WebhookChannel posts notifications as JSON to a generic HTTP endpoint
+type WebhookChannel struct {
+	URL     string
+	Headers map[string]string
+	Client  *http.Client
+}
+
+// NewWebhookChannel creates a generic webhook channel
+func NewWebhookChannel(url string, headers map[string]string) *WebhookChannel {
+	return &WebhookChannel{
+		URL:     url,
+		Headers: headers,
+		Client:  &http.Client{},
+	}
+}
+
+// Name returns the channel name
+func (c *WebhookChannel) Name() string {
+	return "webhook"
+}
+
+// Send posts the rendered message together with the raw notification
+func (c *WebhookChannel) Send(ctx context.Context, message Message) error {
+	payload := map[string]interface{}{
+		"event":             message.Notification.Event,
+		"purchase_order_id": message.Notification.PurchaseOrderID,
+		"timestamp":         message.Notification.Timestamp,
+		"subject":           message.Subject,
+		"body":              message.Body,
+		"data":              message.Notification.Data,
+	}
+	return postJSON(ctx, c.Client, c.URL, c.Headers, payload)
+}
+
+// postJSON posts a JSON payload and treats any non-2xx response as a failure
+func postJSON(ctx context.Context, client *http.Client, url string, headers map[string]string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d from %s", resp.StatusCode, url)
+	}
+
+	return nil
+}
@@ -0,0 +1,172 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// mail is a message received by the fake SMTP server
+type mail struct {
+	From string
+	To   []string
+	Data string
+}
+
+// startSMTP serves just enough SMTP for net/smtp.SendMail and returns its
+// address and the messages it receives
+func startSMTP(t *testing.T) (string, <-chan mail) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	received := make(chan mail, 10)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveSMTP(conn, received)
+		}
+	}()
+	return listener.Addr().String(), received
+}
+
+// serveSMTP answers one SMTP session
+func serveSMTP(conn net.Conn, received chan<- mail) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+	reply("220 localhost ready")
+	var current mail
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		command := strings.ToUpper(line)
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(command, "MAIL FROM:"):
+			current = mail{From: strings.Trim(line[len("MAIL FROM:"):], "<>")}
+			reply("250 OK")
+		case strings.HasPrefix(command, "RCPT TO:"):
+			current.To = append(current.To, strings.Trim(line[len("RCPT TO:"):], "<>"))
+			reply("250 OK")
+		case command == "DATA":
+			reply("354 end with .")
+			var data strings.Builder
+			for {
+				line, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data.WriteString(line)
+			}
+			current.Data = data.String()
+			received <- current
+			reply("250 OK")
+		case command == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func TestSMTPChannelSendsToEveryRecipient(t *testing.T) {
+	addr, received := startSMTP(t)
+	channel := NewSMTPChannel(addr, "orders@medisupply.test", []string{"buyer@medisupply.test", "ops@medisupply.test"}, "", "")
+
+	message := Message{Subject: "Purchase order po-1 created", Body: "A purchase order was created.\r\n"}
+	if err := channel.Send(context.Background(), message); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	select {
+	case got := <-received:
+		if got.From != "orders@medisupply.test" || len(got.To) != 2 || got.To[1] != "ops@medisupply.test" {
+			t.Fatalf("envelope from %s to %v", got.From, got.To)
+		}
+		if !strings.Contains(got.Data, "Subject: Purchase order po-1 created\r\n") || !strings.Contains(got.Data, "To: buyer@medisupply.test, ops@medisupply.test\r\n") || !strings.HasSuffix(got.Data, "\r\n\r\nA purchase order was created.\r\n") {
+			t.Fatalf("message %q", got.Data)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no email received")
+	}
+}
+
+func TestSMTPChannelGivesUpAtTheDeadline(t *testing.T) {
+	// A server that accepts the connection and never answers
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer listener.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	channel := NewSMTPChannel(listener.Addr().String(), "orders@medisupply.test", []string{"buyer@medisupply.test"}, "", "")
+	if err := channel.Send(ctx, Message{}); err != context.DeadlineExceeded {
+		t.Fatalf("send returned %v, want the deadline", err)
+	}
+}
+
+func TestSlackAndWebhookChannelsPostJSON(t *testing.T) {
+	var requests []*http.Request
+	var bodies []map[string]interface{}
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		requests = append(requests, r)
+		bodies = append(bodies, body)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	notification := NewNotification(EventPurchaseOrderOverdue, "po-1", map[string]interface{}{"supplier_id": "supplier-1"})
+	message := Message{Subject: "Purchase order po-1 is overdue", Body: "late", Notification: notification}
+
+	if err := NewSlackChannel(server.URL).Send(context.Background(), message); err != nil {
+		t.Fatalf("slack: %v", err)
+	}
+	if len(bodies) != 1 || bodies[0]["text"] != "late" || len(bodies[0]) != 1 {
+		t.Fatalf("slack posted %v, want only the text", bodies)
+	}
+
+	webhook := NewWebhookChannel(server.URL, map[string]string{"Authorization": "Bearer token"})
+	if err := webhook.Send(context.Background(), message); err != nil {
+		t.Fatalf("webhook: %v", err)
+	}
+	body := bodies[1]
+	if requests[1].Header.Get("Authorization") != "Bearer token" || requests[1].Header.Get("Content-Type") != "application/json" {
+		t.Fatalf("webhook headers %v", requests[1].Header)
+	}
+	if body["event"] != EventPurchaseOrderOverdue || body["purchase_order_id"] != "po-1" || body["subject"] != message.Subject || body["body"] != "late" || body["data"].(map[string]interface{})["supplier_id"] != "supplier-1" {
+		t.Fatalf("webhook posted %v", body)
+	}
+
+	// Any status outside 2xx is a failed delivery
+	status = http.StatusServiceUnavailable
+	if err := webhook.Send(context.Background(), message); err == nil || !strings.Contains(err.Error(), "503") {
+		t.Fatalf("webhook returned %v on a 503", err)
+	}
+}
@@ -0,0 +1,242 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// Order lifecycle events that trigger notifications
+const (
+	EventPurchaseOrderCreated   = "PurchaseOrderCreated"
+	EventPurchaseOrderOverdue   = "PurchaseOrderOverdue"
+	EventPurchaseOrderCancelled = "PurchaseOrderCancelled"
+	EventQualityCheckFailed     = "QualityCheckFailed"
+)
+
+// AllEvents lists every event a channel can subscribe to
+var AllEvents = []string{
+	EventPurchaseOrderCreated,
+	EventPurchaseOrderOverdue,
+	EventPurchaseOrderCancelled,
+	EventQualityCheckFailed,
+}
+
+// Notification describes something that happened to a purchase order
+type Notification struct {
+	Event           string                 `json:"event"`
+	PurchaseOrderID string                 `json:"purchase_order_id"`
+	Timestamp       time.Time              `json:"timestamp"`
+	Data            map[string]interface{} `json:"data"`
+}
+
+// NewNotification creates a new Notification
+func NewNotification(event, purchaseOrderID string, data map[string]interface{}) Notification {
+	if data == nil {
+		data = make(map[string]interface{})
+	}
+	return Notification{
+		Event:           event,
+		PurchaseOrderID: purchaseOrderID,
+		Timestamp:       time.Now().UTC(),
+		Data:            data,
+	}
+}
+
+// Message is a notification rendered for one channel
+type Message struct {
+	Subject      string
+	Body         string
+	Notification Notification
+}
+
+// Channel delivers rendered messages to one destination
+type Channel interface {
+	Name() string
+	Send(ctx context.Context, message Message) error
+}
+
+// RetryPolicy controls how failed deliveries are retried
+type RetryPolicy struct {
+	MaxAttempts int
+	Backoff     time.Duration
+}
+
+// route binds a channel to its templates and subscribed events
+type route struct {
+	channel   Channel
+	templates *Templates
+	events    map[string]bool
+}
+
+// Notifier fans lifecycle notifications out to the subscribed channels
+type Notifier struct {
+	Retry   RetryPolicy
+	Timeout time.Duration
+	Logger  *log.Logger
+
+	routes []route
+	wg     sync.WaitGroup
+}
+
+// NewNotifier creates a notifier without channels
+func NewNotifier(retry RetryPolicy, logger *log.Logger) *Notifier {
+	if retry.MaxAttempts < 1 {
+		retry.MaxAttempts = 1
+	}
+	return &Notifier{
+		Retry:   retry,
+		Timeout: 30 * time.Second,
+		Logger:  logger,
+	}
+}
+
+// AddChannel subscribes a channel to the given events, rendering messages with
+// its templates. An empty event list subscribes to every event.
+func (n *Notifier) AddChannel(channel Channel, templates *Templates, events []string) {
+	subscribed := make(map[string]bool)
+	if len(events) == 0 {
+		events = AllEvents
+	}
+	for _, event := range events {
+		subscribed[event] = true
+	}
+
+	n.routes = append(n.routes, route{
+		channel:   channel,
+		templates: templates,
+		events:    subscribed,
+	})
+}
+
+// Channels returns the names of the configured channels
+func (n *Notifier) Channels() []string {
+	if n == nil {
+		return nil
+	}
+	names := make([]string, 0, len(n.routes))
+	for _, r := range n.routes {
+		names = append(names, r.channel.Name())
+	}
+	return names
+}
+
+// Notify delivers the notification to every subscribed channel in the
+// background, so callers never wait on slow destinations. A nil notifier is a
+// no-op.
+func (n *Notifier) Notify(notification Notification) {
+	if n == nil {
+		return
+	}
+
+	for _, r := range n.routes {
+		if !r.events[notification.Event] {
+			continue
+		}
+
+		message, err := r.templates.Render(notification)
+		if err != nil {
+			n.Logger.Printf("Failed to render %s notification for channel %s: %v", notification.Event, r.channel.Name(), err)
+			continue
+		}
+
+		n.wg.Add(1)
+		go func(channel Channel) {
+			defer n.wg.Done()
+			n.deliver(channel, message)
+		}(r.channel)
+	}
+}
+
+// Wait blocks until in-flight deliveries finish
+func (n *Notifier) Wait() {
+	if n == nil {
+		return
+	}
+	n.wg.Wait()
+}
+
+// deliver sends a message, retrying with linear backoff
+func (n *Notifier) deliver(channel Channel, message Message) {
+	var err error
+	for attempt := 1; attempt <= n.Retry.MaxAttempts; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), n.Timeout)
+		err = channel.Send(ctx, message)
+		cancel()
+		if err == nil {
+			return
+		}
+
+		n.Logger.Printf("Notification delivery failed - channel: %s, event: %s, purchase_order_id: %s, attempt: %d/%d, error: %v",
+			channel.Name(), message.Notification.Event, message.Notification.PurchaseOrderID, attempt, n.Retry.MaxAttempts, err)
+
+		if attempt < n.Retry.MaxAttempts {
+			time.Sleep(n.Retry.Backoff * time.Duration(attempt))
+		}
+	}
+
+	n.Logger.Printf("Giving up on notification - channel: %s, event: %s, purchase_order_id: %s", channel.Name(), message.Notification.Event, message.Notification.PurchaseOrderID)
+}
+
+// Templates renders the subject and body of each event for one channel
+type Templates struct {
+	subjects map[string]*template.Template
+	bodies   map[string]*template.Template
+}
+
+// NewTemplates parses subject and body templates keyed by event
+func NewTemplates(subjects, bodies map[string]string) (*Templates, error) {
+	t := &Templates{
+		subjects: make(map[string]*template.Template),
+		bodies:   make(map[string]*template.Template),
+	}
+
+	for event, text := range subjects {
+		parsed, err := template.New(event + ".subject").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid subject template for %s: %w", event, err)
+		}
+		t.subjects[event] = parsed
+	}
+	for event, text := range bodies {
+		parsed, err := template.New(event + ".body").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid body template for %s: %w", event, err)
+		}
+		t.bodies[event] = parsed
+	}
+
+	return t, nil
+}
+
+// Render renders the notification into a message
+func (t *Templates) Render(notification Notification) (Message, error) {
+	message := Message{Notification: notification}
+
+	subject, ok := t.subjects[notification.Event]
+	if !ok {
+		return message, fmt.Errorf("no subject template for %s", notification.Event)
+	}
+	body, ok := t.bodies[notification.Event]
+	if !ok {
+		return message, fmt.Errorf("no body template for %s", notification.Event)
+	}
+
+	var buf bytes.Buffer
+	if err := subject.Execute(&buf, notification); err != nil {
+		return message, fmt.Errorf("failed to render subject: %w", err)
+	}
+	message.Subject = buf.String()
+
+	buf.Reset()
+	if err := body.Execute(&buf, notification); err != nil {
+		return message, fmt.Errorf("failed to render body: %w", err)
+	}
+	message.Body = buf.String()
+
+	return message, nil
+}
@@ -0,0 +1,119 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingChannel records the messages sent to it, failing the first
+// failures sends
+type recordingChannel struct {
+	name     string
+	failures int
+
+	mu       sync.Mutex
+	attempts int
+	sent     []Message
+}
+
+func (c *recordingChannel) Name() string { return c.name }
+
+func (c *recordingChannel) Send(ctx context.Context, message Message) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.attempts++
+	if c.attempts <= c.failures {
+		return errors.New("destination unavailable")
+	}
+	c.sent = append(c.sent, message)
+	return nil
+}
+
+func newTestTemplates(t *testing.T) *Templates {
+	t.Helper()
+	templates, err := LoadTemplates("slack", "")
+	if err != nil {
+		t.Fatalf("load templates: %v", err)
+	}
+	return templates
+}
+
+func TestNotifyRoutesEventsToTheSubscribedChannels(t *testing.T) {
+	notifier := NewNotifier(RetryPolicy{}, log.New(io.Discard, "", 0))
+	everything := &recordingChannel{name: "everything"}
+	overdue := &recordingChannel{name: "overdue"}
+	notifier.AddChannel(everything, newTestTemplates(t), nil)
+	notifier.AddChannel(overdue, newTestTemplates(t), []string{EventPurchaseOrderOverdue})
+
+	notifier.Notify(NewNotification(EventPurchaseOrderCreated, "po-1", map[string]interface{}{"quantity": 10, "product_name": "Gloves"}))
+	notifier.Notify(NewNotification(EventPurchaseOrderOverdue, "po-2", nil))
+	notifier.Notify(NewNotification("SomethingElse", "po-3", nil))
+	notifier.Wait()
+
+	if len(everything.sent) != 2 || len(overdue.sent) != 1 || overdue.sent[0].Notification.PurchaseOrderID != "po-2" {
+		t.Fatalf("everything got %d messages and overdue %d, want 2 and the po-2 one", len(everything.sent), len(overdue.sent))
+	}
+	if names := notifier.Channels(); len(names) != 2 || names[0] != "everything" || names[1] != "overdue" {
+		t.Fatalf("channels %v", names)
+	}
+}
+
+func TestNotifyRetriesAFailedDelivery(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		failures int
+		attempts int
+		sent     int
+	}{
+		{"succeeds on a retry", 2, 3, 1},
+		{"gives up after the attempts", 5, 3, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			notifier := NewNotifier(RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond}, log.New(io.Discard, "", 0))
+			channel := &recordingChannel{name: "flaky", failures: tc.failures}
+			notifier.AddChannel(channel, newTestTemplates(t), nil)
+
+			notifier.Notify(NewNotification(EventPurchaseOrderCancelled, "po-1", nil))
+			notifier.Wait()
+			if channel.attempts != tc.attempts || len(channel.sent) != tc.sent {
+				t.Fatalf("%d attempts delivered %d messages, want %d and %d", channel.attempts, len(channel.sent), tc.attempts, tc.sent)
+			}
+		})
+	}
+}
+
+func TestNilNotifierIsANoOp(t *testing.T) {
+	var notifier *Notifier
+	notifier.Notify(NewNotification(EventPurchaseOrderCreated, "po-1", nil))
+	notifier.Wait()
+	if notifier.Channels() != nil {
+		t.Fatal("a nil notifier has channels")
+	}
+}
+
+func TestRender(t *testing.T) {
+	templates, err := NewTemplates(
+		map[string]string{EventPurchaseOrderCreated: "Order {{.PurchaseOrderID}}"},
+		map[string]string{EventPurchaseOrderCreated: "{{.Data.quantity}} x {{.Data.product_name}}"},
+	)
+	if err != nil {
+		t.Fatalf("new templates: %v", err)
+	}
+
+	notification := NewNotification(EventPurchaseOrderCreated, "po-1", map[string]interface{}{"quantity": 10, "product_name": "Gloves"})
+	message, err := templates.Render(notification)
+	if err != nil || message.Subject != "Order po-1" || message.Body != "10 x Gloves" || message.Notification.PurchaseOrderID != "po-1" {
+		t.Fatalf("rendered %+v, %v", message, err)
+	}
+	if _, err := templates.Render(NewNotification(EventPurchaseOrderOverdue, "po-1", nil)); err == nil {
+		t.Fatal("rendered an event without templates")
+	}
+	if _, err := NewTemplates(map[string]string{EventPurchaseOrderCreated: "{{.PurchaseOrderID"}, nil); err == nil {
+		t.Fatal("parsed a broken template")
+	}
+}
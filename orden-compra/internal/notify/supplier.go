@@ -0,0 +1,77 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"orden-compra/internal/models"
+)
+
+// DefaultEscalationRules pick the supplier contacts notified when the
+// supplier has no rule of its own for an event: quality for failed
+// inspections, emergencies and purchasing for critical orders, purchasing
+// otherwise.
+var DefaultEscalationRules = []models.EscalationRule{
+	{Event: EventQualityCheckFailed, Roles: []string{models.ContactRoleQuality}},
+	{UrgencyLevels: []string{"critical"}, Roles: []string{models.ContactRoleEmergency, models.ContactRolePurchasing}},
+	{Roles: []string{models.ContactRolePurchasing}},
+}
+
+// SupplierLookup returns the supplier of a notification, or nil if it is not
+// in the catalog
+type SupplierLookup interface {
+	NotificationSupplier(ctx context.Context, notification Notification) (*models.Supplier, error)
+}
+
+// SupplierChannel emails each notification to the supplier contacts its
+// event and urgency escalate to
+type SupplierChannel struct {
+	SMTP     *SMTPChannel
+	Lookup   SupplierLookup
+	Defaults []models.EscalationRule
+	Logger   *log.Logger
+}
+
+// NewSupplierChannel creates a supplier channel sending through smtp
+func NewSupplierChannel(smtp *SMTPChannel, lookup SupplierLookup, logger *log.Logger) *SupplierChannel {
+	return &SupplierChannel{
+		SMTP:     smtp,
+		Lookup:   lookup,
+		Defaults: DefaultEscalationRules,
+		Logger:   logger,
+	}
+}
+
+// Name returns the channel name
+func (c *SupplierChannel) Name() string {
+	return "supplier"
+}
+
+// Send emails the message to the escalation contacts of the notification's
+// supplier. Notifications of suppliers that are not in the catalog or have
+// no email contact are dropped.
+func (c *SupplierChannel) Send(ctx context.Context, message Message) error {
+	supplier, err := c.Lookup.NotificationSupplier(ctx, message.Notification)
+	if err != nil {
+		return fmt.Errorf("failed to get supplier: %w", err)
+	}
+	if supplier == nil {
+		c.Logger.Printf("Supplier notification skipped, supplier not in catalog - event: %s, purchase_order_id: %s", message.Notification.Event, message.Notification.PurchaseOrderID)
+		return nil
+	}
+
+	urgencyLevel, _ := message.Notification.Data["urgency_level"].(string)
+	contacts := supplier.EscalationContacts(message.Notification.Event, urgencyLevel, c.Defaults)
+	if len(contacts) == 0 {
+		c.Logger.Printf("Supplier notification skipped, no email contact - event: %s, supplier_id: %s", message.Notification.Event, supplier.ID)
+		return nil
+	}
+
+	smtp := *c.SMTP
+	smtp.To = make([]string, 0, len(contacts))
+	for _, contact := range contacts {
+		smtp.To = append(smtp.To, contact.Email)
+	}
+	return smtp.Send(ctx, message)
+}
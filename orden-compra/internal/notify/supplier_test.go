@@ -0,0 +1,78 @@
+package notify
+
+import (
+	"context"
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"orden-compra/internal/models"
+)
+
+// suppliers is a SupplierLookup over a fixed catalog
+type suppliers map[string]*models.Supplier
+
+func (s suppliers) NotificationSupplier(ctx context.Context, notification Notification) (*models.Supplier, error) {
+	supplierID, _ := notification.Data["supplier_id"].(string)
+	return s[supplierID], nil
+}
+
+func TestSupplierChannelEmailsTheEscalationContacts(t *testing.T) {
+	addr, received := startSMTP(t)
+	catalog := suppliers{
+		"supplier-1": {
+			ID: "supplier-1",
+			Contacts: []models.SupplierContact{
+				{ID: "c-1", Role: models.ContactRolePurchasing, Email: "buying@acme.test"},
+				{ID: "c-2", Role: models.ContactRoleEmergency, Email: "oncall@acme.test"},
+				{ID: "c-3", Role: models.ContactRoleQuality, Phone: "+57 300 000 0000"},
+			},
+		},
+	}
+	channel := NewSupplierChannel(NewSMTPChannel(addr, "orders@medisupply.test", []string{"buyer@medisupply.test"}, "", ""), catalog, log.New(io.Discard, "", 0))
+
+	for _, tc := range []struct {
+		name    string
+		event   string
+		data    map[string]interface{}
+		wantTo  []string
+		skipped bool
+	}{
+		{"critical order", EventPurchaseOrderOverdue, map[string]interface{}{"supplier_id": "supplier-1", "urgency_level": "critical"}, []string{"buying@acme.test", "oncall@acme.test"}, false},
+		{"routine order", EventPurchaseOrderOverdue, map[string]interface{}{"supplier_id": "supplier-1", "urgency_level": "low"}, []string{"buying@acme.test"}, false},
+		{"no email contact", EventQualityCheckFailed, map[string]interface{}{"supplier_id": "supplier-1"}, nil, true},
+		{"supplier not in the catalog", EventPurchaseOrderOverdue, map[string]interface{}{"supplier_id": "supplier-2"}, nil, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			message := Message{Subject: "Purchase order po-1", Body: "body\r\n", Notification: NewNotification(tc.event, "po-1", tc.data)}
+			if err := channel.Send(context.Background(), message); err != nil {
+				t.Fatalf("send: %v", err)
+			}
+
+			select {
+			case got := <-received:
+				if tc.skipped {
+					t.Fatalf("emailed %v", got.To)
+				}
+				if len(got.To) != len(tc.wantTo) {
+					t.Fatalf("emailed %v, want %v", got.To, tc.wantTo)
+				}
+				for i := range tc.wantTo {
+					if got.To[i] != tc.wantTo[i] {
+						t.Fatalf("emailed %v, want %v", got.To, tc.wantTo)
+					}
+				}
+			case <-time.After(100 * time.Millisecond):
+				if !tc.skipped {
+					t.Fatal("no email received")
+				}
+			}
+		})
+	}
+
+	// The configured recipients are left alone
+	if len(channel.SMTP.To) != 1 || channel.SMTP.To[0] != "buyer@medisupply.test" {
+		t.Fatalf("SMTP recipients changed to %v", channel.SMTP.To)
+	}
+}
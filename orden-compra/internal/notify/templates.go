@@ -0,0 +1,105 @@
+package notify
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// defaultSubjects are shared by every channel
+var defaultSubjects = map[string]string{
+	EventPurchaseOrderCreated:   `Purchase order {{.PurchaseOrderID}} created`,
+	EventPurchaseOrderOverdue:   `Purchase order {{.PurchaseOrderID}} is overdue`,
+	EventPurchaseOrderCancelled: `Purchase order {{.PurchaseOrderID}} cancelled`,
+	EventQualityCheckFailed:     `Quality check failed for purchase order {{.PurchaseOrderID}}`,
+}
+
+// defaultBodies holds the built-in body templates of each channel type
+var defaultBodies = map[string]map[string]string{
+	"email": {
+		EventPurchaseOrderCreated: `A purchase order was created.
+
+Purchase order: {{.PurchaseOrderID}}
+Product: {{.Data.product_name}} ({{.Data.product_id}})
+Quantity: {{.Data.quantity}}
+Supplier: {{.Data.supplier_name}} ({{.Data.supplier_id}})
+Location: {{.Data.location}}
+Urgency: {{.Data.urgency_level}}
+Status: {{.Data.status}}
+`,
+		EventPurchaseOrderOverdue: `A purchase order is past its expected delivery date.
+
+Purchase order: {{.PurchaseOrderID}}
+Product: {{.Data.product_name}} ({{.Data.product_id}})
+Supplier: {{.Data.supplier_name}} ({{.Data.supplier_id}})
+Expected date: {{.Data.expected_date}}
+Status: {{.Data.status}}
+`,
+		EventPurchaseOrderCancelled: `A purchase order was cancelled.
+
+Purchase order: {{.PurchaseOrderID}}
+Previous status: {{.Data.previous_status}}
+Reason: {{.Data.reason}}
+`,
+		EventQualityCheckFailed: `A received shipment failed quality inspection.
+
+Purchase order: {{.PurchaseOrderID}}
+Product: {{.Data.product_id}}
+Reason: {{.Data.reason}}
+`,
+	},
+	"slack": {
+		EventPurchaseOrderCreated:   `:package: Purchase order *{{.PurchaseOrderID}}* created: {{.Data.quantity}} x {{.Data.product_name}} from {{.Data.supplier_name}} ({{.Data.urgency_level}}, {{.Data.status}})`,
+		EventPurchaseOrderOverdue:   `:warning: Purchase order *{{.PurchaseOrderID}}* for {{.Data.product_name}} from {{.Data.supplier_name}} was expected on {{.Data.expected_date}}`,
+		EventPurchaseOrderCancelled: `:x: Purchase order *{{.PurchaseOrderID}}* cancelled (was {{.Data.previous_status}}): {{.Data.reason}}`,
+		EventQualityCheckFailed:     `:rotating_light: Quality check failed for purchase order *{{.PurchaseOrderID}}*: {{.Data.reason}}`,
+	},
+}
+
+// LoadTemplates returns the built-in templates for a channel type, replaced by
+// any <dir>/<channelType>/<Event>.subject.tmpl or <Event>.body.tmpl files.
+// Channel types without their own bodies, such as generic webhooks, reuse the
+// email bodies.
+func LoadTemplates(channelType, dir string) (*Templates, error) {
+	bodies, ok := defaultBodies[channelType]
+	if !ok {
+		bodies = defaultBodies["email"]
+	}
+
+	subjects := make(map[string]string, len(defaultSubjects))
+	for event, text := range defaultSubjects {
+		subjects[event] = text
+	}
+	overriddenBodies := make(map[string]string, len(bodies))
+	for event, text := range bodies {
+		overriddenBodies[event] = text
+	}
+
+	if dir != "" {
+		for _, event := range AllEvents {
+			if err := readOverride(filepath.Join(dir, channelType, event+".subject.tmpl"), event, subjects); err != nil {
+				return nil, err
+			}
+			if err := readOverride(filepath.Join(dir, channelType, event+".body.tmpl"), event, overriddenBodies); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return NewTemplates(subjects, overriddenBodies)
+}
+
+// readOverride replaces a template with the file's content when the file exists
+func readOverride(path, event string, templates map[string]string) error {
+	content, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read template %s: %w", path, err)
+	}
+	templates[event] = string(content)
+	return nil
+}
@@ -0,0 +1,77 @@
+package notify
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadTemplates(t *testing.T) {
+	notification := NewNotification(EventPurchaseOrderCreated, "po-1", map[string]interface{}{
+		"product_name":  "Gloves",
+		"quantity":      10,
+		"supplier_name": "Acme",
+		"urgency_level": "high",
+		"status":        "pending",
+	})
+
+	// Every event renders on every channel type
+	for _, channelType := range []string{"email", "slack", "webhook"} {
+		templates, err := LoadTemplates(channelType, "")
+		if err != nil {
+			t.Fatalf("load %s templates: %v", channelType, err)
+		}
+		for _, event := range AllEvents {
+			if _, err := templates.Render(NewNotification(event, "po-1", nil)); err != nil {
+				t.Errorf("%s %s: %v", channelType, event, err)
+			}
+		}
+	}
+
+	slack, _ := LoadTemplates("slack", "")
+	message, _ := slack.Render(notification)
+	if message.Subject != "Purchase order po-1 created" || message.Body != ":package: Purchase order *po-1* created: 10 x Gloves from Acme (high, pending)" {
+		t.Fatalf("slack message %+v", message)
+	}
+
+	// Generic webhooks reuse the email bodies
+	webhook, _ := LoadTemplates("webhook", "")
+	message, _ = webhook.Render(notification)
+	if !strings.HasPrefix(message.Body, "A purchase order was created.") {
+		t.Fatalf("webhook body %q", message.Body)
+	}
+}
+
+func TestLoadTemplatesReadsOverrides(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "slack"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "slack", EventPurchaseOrderOverdue+".body.tmpl"), []byte("late: {{.PurchaseOrderID}}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	templates, err := LoadTemplates("slack", dir)
+	if err != nil {
+		t.Fatalf("load templates: %v", err)
+	}
+	message, _ := templates.Render(NewNotification(EventPurchaseOrderOverdue, "po-1", nil))
+	if message.Body != "late: po-1" || message.Subject != "Purchase order po-1 is overdue" {
+		t.Fatalf("overridden message %+v", message)
+	}
+
+	// Other channel types keep the built-in templates
+	email, _ := LoadTemplates("email", dir)
+	if message, _ := email.Render(NewNotification(EventPurchaseOrderOverdue, "po-1", nil)); message.Body == "late: po-1" {
+		t.Fatal("a slack override applied to email")
+	}
+
+	// A broken override fails loading rather than every send
+	if err := os.WriteFile(filepath.Join(dir, "slack", EventPurchaseOrderCreated+".subject.tmpl"), []byte("{{.PurchaseOrderID"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadTemplates("slack", dir); err == nil {
+		t.Fatal("loaded a broken override")
+	}
+}
@@ -0,0 +1,370 @@
+package openapi
+
+import (
+	_ "embed"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SwaggerUI is a page rendering /openapi.json with Swagger UI
+//
+//go:embed swagger.html
+var SwaggerUI []byte
+
+// SwaggerUIContentSecurityPolicy lets the Swagger UI page load its assets
+// from unpkg, run its inline bootstrap and fetch /openapi.json
+const SwaggerUIContentSecurityPolicy = "default-src 'none'; script-src 'unsafe-inline' https://unpkg.com; style-src 'unsafe-inline' https://unpkg.com; img-src 'self' data: https://unpkg.com; connect-src 'self'; frame-ancestors 'none'"
+
+// Operation documents one route
+type Operation struct {
+	Summary     string
+	Description string
+	Tags        []string
+	// Request is a Go value whose type describes the JSON request body
+	Request interface{}
+	// Query lists the supported query parameters with their descriptions
+	Query     map[string]string
+	Responses map[int]Response
+	// Public marks operations reachable without credentials
+	Public bool
+}
+
+// Response documents one response of an operation
+type Response struct {
+	Description string
+	// Body is a Go value whose type describes the JSON response body
+	Body interface{}
+}
+
+// Fields describes a JSON object by example: each value's type becomes the
+// schema of its property. Handlers returning map[string]interface{} are
+// documented this way.
+type Fields map[string]interface{}
+
+// Registry holds the documented operations and renders the OpenAPI document
+type Registry struct {
+	Title       string
+	Version     string
+	Description string
+	// SecuritySchemes lists the accepted credentials; any one of them
+	// authorizes a non-public operation
+	SecuritySchemes map[string]interface{}
+
+	mu         sync.Mutex
+	operations map[string]Operation
+	models     map[string]interface{}
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry(title, version, description string) *Registry {
+	return &Registry{
+		Title:       title,
+		Version:     version,
+		Description: description,
+		operations:  make(map[string]Operation),
+		models:      make(map[string]interface{}),
+	}
+}
+
+// Describe documents the route registered with the given method and Gin path
+func (r *Registry) Describe(method, path string, operation Operation) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.operations[method+" "+path] = operation
+}
+
+// Model adds a component schema even when no operation references it, such as
+// payloads consumed over RabbitMQ, GraphQL or gRPC
+func (r *Registry) Model(name string, v interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.models[name] = v
+}
+
+// Document renders an OpenAPI 3 document for the registered Gin routes. Routes
+// without a description are still listed so the document never misses an endpoint.
+func (r *Registry) Document(routes gin.RoutesInfo) map[string]interface{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	g := &generator{schemas: make(map[string]interface{}), secured: len(r.SecuritySchemes) > 0}
+	for name, model := range r.models {
+		schema := g.schemaOf(model)
+		if ref, isRef := schema["$ref"].(string); !isRef || ref != "#/components/schemas/"+name {
+			g.schemas[name] = schema
+		}
+	}
+
+	paths := make(map[string]interface{})
+
+	sorted := append(gin.RoutesInfo(nil), routes...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Path != sorted[j].Path {
+			return sorted[i].Path < sorted[j].Path
+		}
+		return sorted[i].Method < sorted[j].Method
+	})
+
+	for _, route := range sorted {
+		path, params := convertPath(route.Path)
+		item, ok := paths[path].(map[string]interface{})
+		if !ok {
+			item = make(map[string]interface{})
+			paths[path] = item
+		}
+
+		operation, documented := r.operations[route.Method+" "+route.Path]
+		item[strings.ToLower(route.Method)] = g.operation(route, operation, documented, params)
+	}
+
+	components := map[string]interface{}{"schemas": g.schemas}
+	document := map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       r.Title,
+			"version":     r.Version,
+			"description": r.Description,
+		},
+		"paths":      paths,
+		"components": components,
+	}
+
+	if len(r.SecuritySchemes) > 0 {
+		components["securitySchemes"] = r.SecuritySchemes
+		names := make([]string, 0, len(r.SecuritySchemes))
+		for name := range r.SecuritySchemes {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		security := make([]interface{}, 0, len(names))
+		for _, name := range names {
+			security = append(security, map[string]interface{}{name: []string{}})
+		}
+		document["security"] = security
+	}
+
+	return document
+}
+
+// convertPath turns a Gin path into an OpenAPI path and its parameter names
+func convertPath(path string) (string, []string) {
+	segments := strings.Split(path, "/")
+	var params []string
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			name := segment[1:]
+			params = append(params, name)
+			segments[i] = "{" + name + "}"
+		}
+	}
+	return strings.Join(segments, "/"), params
+}
+
+// generator builds operations and collects the named component schemas
+type generator struct {
+	schemas map[string]interface{}
+	secured bool
+}
+
+// operation renders one OpenAPI operation object
+func (g *generator) operation(route gin.RouteInfo, operation Operation, documented bool, params []string) map[string]interface{} {
+	op := map[string]interface{}{
+		"operationId": operationID(route.Method, route.Path),
+	}
+	if operation.Summary != "" {
+		op["summary"] = operation.Summary
+	}
+	if operation.Description != "" {
+		op["description"] = operation.Description
+	}
+	if len(operation.Tags) > 0 {
+		op["tags"] = operation.Tags
+	}
+	if operation.Public {
+		op["security"] = []interface{}{}
+	}
+
+	var parameters []interface{}
+	for _, name := range params {
+		parameters = append(parameters, map[string]interface{}{
+			"name":     name,
+			"in":       "path",
+			"required": true,
+			"schema":   map[string]interface{}{"type": "string"},
+		})
+	}
+	queryNames := make([]string, 0, len(operation.Query))
+	for name := range operation.Query {
+		queryNames = append(queryNames, name)
+	}
+	sort.Strings(queryNames)
+	for _, name := range queryNames {
+		parameters = append(parameters, map[string]interface{}{
+			"name":        name,
+			"in":          "query",
+			"description": operation.Query[name],
+			"schema":      map[string]interface{}{"type": "string"},
+		})
+	}
+	if len(parameters) > 0 {
+		op["parameters"] = parameters
+	}
+
+	if operation.Request != nil {
+		op["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{"schema": g.schemaOf(operation.Request)},
+			},
+		}
+	}
+
+	responses := make(map[string]interface{})
+	for code, response := range operation.Responses {
+		description := response.Description
+		if description == "" {
+			description = http.StatusText(code)
+		}
+		rendered := map[string]interface{}{"description": description}
+		if response.Body != nil {
+			rendered["content"] = map[string]interface{}{
+				"application/json": map[string]interface{}{"schema": g.schemaOf(response.Body)},
+			}
+		}
+		responses[strconv.Itoa(code)] = rendered
+	}
+	if !documented || len(responses) == 0 {
+		responses["default"] = map[string]interface{}{"description": "Undocumented response"}
+	}
+	if g.secured && !operation.Public {
+		responses["401"] = map[string]interface{}{"description": "Missing or invalid credentials"}
+	}
+	op["responses"] = responses
+
+	return op
+}
+
+// operationID derives a stable operation ID such as post_purchase_orders_id_cancel
+func operationID(method, path string) string {
+	replacer := strings.NewReplacer("/", "_", ":", "", "*", "", "-", "_", "{", "", "}", "")
+	id := strings.Trim(replacer.Replace(path), "_")
+	if id == "" {
+		id = "root"
+	}
+	return strings.ToLower(method) + "_" + id
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schemaOf returns the schema of an example value
+func (g *generator) schemaOf(v interface{}) map[string]interface{} {
+	if fields, ok := v.(Fields); ok {
+		names := make([]string, 0, len(fields))
+		for name := range fields {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		properties := make(map[string]interface{})
+		for _, name := range names {
+			properties[name] = g.schemaOf(fields[name])
+		}
+		return map[string]interface{}{"type": "object", "properties": properties}
+	}
+	if v == nil {
+		return map[string]interface{}{}
+	}
+	return g.schemaFor(reflect.TypeOf(v))
+}
+
+// schemaFor returns the schema of a Go type; named structs become components
+func (g *generator) schemaFor(t reflect.Type) map[string]interface{} {
+	switch t.Kind() {
+	case reflect.Ptr:
+		schema := g.schemaFor(t.Elem())
+		if _, isRef := schema["$ref"]; isRef {
+			return map[string]interface{}{"allOf": []interface{}{schema}, "nullable": true}
+		}
+		schema["nullable"] = true
+		return schema
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": g.schemaFor(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": g.schemaFor(t.Elem())}
+	case reflect.Interface:
+		return map[string]interface{}{}
+	case reflect.Struct:
+		if t == timeType {
+			return map[string]interface{}{"type": "string", "format": "date-time"}
+		}
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		if _, ok := g.schemas[t.Name()]; !ok {
+			// Reserve the name first so recursive types terminate
+			g.schemas[t.Name()] = map[string]interface{}{}
+			g.schemas[t.Name()] = g.structSchema(t)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + t.Name()}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+// structSchema describes the JSON encoding of a struct from its json tags
+func (g *generator) structSchema(t reflect.Type) map[string]interface{} {
+	properties := make(map[string]interface{})
+	var required []string
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name := field.Name
+		omitEmpty := false
+		if tag := field.Tag.Get("json"); tag != "" {
+			parts := strings.Split(tag, ",")
+			if parts[0] == "-" {
+				continue
+			}
+			if parts[0] != "" {
+				name = parts[0]
+			}
+			for _, option := range parts[1:] {
+				if option == "omitempty" {
+					omitEmpty = true
+				}
+			}
+		}
+
+		properties[name] = g.schemaFor(field.Type)
+		if !omitEmpty && field.Type.Kind() != reflect.Ptr {
+			required = append(required, name)
+		}
+	}
+
+	schema := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
@@ -0,0 +1,132 @@
+package openapi
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type item struct {
+	ID       string    `json:"id"`
+	Note     string    `json:"note,omitempty"`
+	Parent   *item     `json:"parent"`
+	Tags     []string  `json:"tags"`
+	Created  time.Time `json:"created_at"`
+	Internal string    `json:"-"`
+	hidden   string
+}
+
+type createItemRequest struct {
+	Quantity int     `json:"quantity"`
+	Price    float64 `json:"price"`
+}
+
+func TestConvertPath(t *testing.T) {
+	for _, tc := range []struct {
+		path   string
+		want   string
+		params []string
+	}{
+		{"/health", "/health", nil},
+		{"/api/v1/items/:id", "/api/v1/items/{id}", []string{"id"}},
+		{"/api/v1/items/:id/notes/:note", "/api/v1/items/{id}/notes/{note}", []string{"id", "note"}},
+		{"/files/*path", "/files/{path}", []string{"path"}},
+	} {
+		if path, params := convertPath(tc.path); path != tc.want || !reflect.DeepEqual(params, tc.params) {
+			t.Fatalf("convertPath(%s) = %s %v, want %s %v", tc.path, path, params, tc.want, tc.params)
+		}
+	}
+}
+
+func TestOperationID(t *testing.T) {
+	if id := operationID("POST", "/api/v1/purchase-orders/:id/cancel"); id != "post_api_v1_purchase_orders_id_cancel" {
+		t.Fatalf("operation ID %s", id)
+	}
+	if id := operationID("GET", "/"); id != "get_root" {
+		t.Fatalf("operation ID %s", id)
+	}
+}
+
+func TestDocument(t *testing.T) {
+	registry := NewRegistry("Items", "1.0.0", "Item API")
+	registry.Describe("POST", "/items/:id", Operation{
+		Summary:   "Create an item",
+		Tags:      []string{"items"},
+		Request:   createItemRequest{},
+		Query:     map[string]string{"dry_run": "Validate only"},
+		Responses: map[int]Response{http.StatusCreated: {Body: Fields{"success": true, "item": item{}}}},
+	})
+	registry.Describe("GET", "/health", Operation{Public: true, Responses: map[int]Response{http.StatusOK: {Description: "Healthy"}}})
+	registry.Model("Event", createItemRequest{})
+	registry.SecuritySchemes = map[string]interface{}{"bearerAuth": map[string]interface{}{"type": "http", "scheme": "bearer"}}
+
+	document := registry.Document(gin.RoutesInfo{
+		{Method: "POST", Path: "/items/:id"},
+		{Method: "GET", Path: "/health"},
+		{Method: "DELETE", Path: "/items/:id"},
+	})
+	paths := document["paths"].(map[string]interface{})
+
+	create := paths["/items/{id}"].(map[string]interface{})["post"].(map[string]interface{})
+	if create["summary"] != "Create an item" || create["operationId"] != "post_items_id" {
+		t.Fatalf("create operation %v", create)
+	}
+	if parameters := create["parameters"].([]interface{}); len(parameters) != 2 || parameters[0].(map[string]interface{})["in"] != "path" || parameters[1].(map[string]interface{})["name"] != "dry_run" {
+		t.Fatalf("parameters %v", parameters)
+	}
+	responses := create["responses"].(map[string]interface{})
+	if responses["201"].(map[string]interface{})["description"] != "Created" || responses["401"] == nil || responses["default"] != nil {
+		t.Fatalf("create responses %v", responses)
+	}
+
+	// Public operations need no credentials
+	health := paths["/health"].(map[string]interface{})["get"].(map[string]interface{})
+	if security, ok := health["security"].([]interface{}); !ok || len(security) != 0 || health["responses"].(map[string]interface{})["401"] != nil {
+		t.Fatalf("health operation %v", health)
+	}
+
+	// Routes without a description are still listed
+	remove := paths["/items/{id}"].(map[string]interface{})["delete"].(map[string]interface{})
+	if remove["responses"].(map[string]interface{})["default"] == nil {
+		t.Fatalf("undocumented operation %v", remove)
+	}
+
+	components := document["components"].(map[string]interface{})
+	if components["securitySchemes"] == nil || len(document["security"].([]interface{})) != 1 {
+		t.Fatalf("security %v %v", components["securitySchemes"], document["security"])
+	}
+	schemas := components["schemas"].(map[string]interface{})
+	if schemas["createItemRequest"] == nil || schemas["Event"] == nil {
+		t.Fatalf("schemas %v", schemas)
+	}
+}
+
+func TestStructSchema(t *testing.T) {
+	g := &generator{schemas: make(map[string]interface{})}
+	if ref := g.schemaOf(item{}); ref["$ref"] != "#/components/schemas/item" {
+		t.Fatalf("schema %v, want a reference", ref)
+	}
+
+	schema := g.schemas["item"].(map[string]interface{})
+	properties := schema["properties"].(map[string]interface{})
+	if len(properties) != 5 || properties["Internal"] != nil || properties["hidden"] != nil {
+		t.Fatalf("properties %v", properties)
+	}
+	if created := properties["created_at"].(map[string]interface{}); created["format"] != "date-time" {
+		t.Fatalf("created_at %v", created)
+	}
+	if tags := properties["tags"].(map[string]interface{}); tags["type"] != "array" || tags["items"].(map[string]interface{})["type"] != "string" {
+		t.Fatalf("tags %v", tags)
+	}
+	// A recursive pointer refers back to the component
+	if parent := properties["parent"].(map[string]interface{}); parent["nullable"] != true || !reflect.DeepEqual(parent["allOf"], []interface{}{map[string]interface{}{"$ref": "#/components/schemas/item"}}) {
+		t.Fatalf("parent %v", parent)
+	}
+	// Omitted-when-empty and pointer fields are optional
+	if required := schema["required"].([]string); !reflect.DeepEqual(required, []string{"id", "tags", "created_at"}) {
+		t.Fatalf("required %v", required)
+	}
+}
@@ -0,0 +1,202 @@
+// Package outbox keeps the events a command has to publish next to the
+// purchase order it wrote. The command adds its messages to the order's
+// DynamoDB transaction, and a Relay publishes them and deletes each one the
+// broker confirmed, so an event is sent at least once even when publishing
+// fails or the service stops right after saving the order.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+
+	"medisupply/clock"
+)
+
+// TableName is the table messages wait in until they are published
+const TableName = "orden-compra-outbox"
+
+// Message is an event waiting to be published
+type Message struct {
+	// ID is the event's ID, which consumers use to recognise a message
+	// published twice
+	ID       string `dynamodbav:"id"`
+	Type     string `dynamodbav:"type"`
+	TenantID string `dynamodbav:"tenant_id,omitempty"`
+	// Payload is the event encoded as JSON
+	Payload   []byte    `dynamodbav:"payload"`
+	CreatedAt time.Time `dynamodbav:"created_at"`
+}
+
+// NewMessage creates the message publishing event
+func NewMessage(eventType, id, tenantID string, event interface{}, now time.Time) (*Message, error) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %s event: %w", eventType, err)
+	}
+	return &Message{
+		ID:        id,
+		Type:      eventType,
+		TenantID:  tenantID,
+		Payload:   payload,
+		CreatedAt: now.UTC(),
+	}, nil
+}
+
+// Decode decodes the message's event into event
+func (m *Message) Decode(event interface{}) error {
+	if err := json.Unmarshal(m.Payload, event); err != nil {
+		return fmt.Errorf("failed to decode %s event %s: %w", m.Type, m.ID, err)
+	}
+	return nil
+}
+
+// Put returns the transaction item storing the message; it fails the
+// transaction if a message with the same ID is already waiting
+func (m *Message) Put() (*dynamodb.TransactWriteItem, error) {
+	item, err := dynamodbattribute.MarshalMap(m)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal outbox message: %w", err)
+	}
+	return &dynamodb.TransactWriteItem{Put: &dynamodb.Put{
+		TableName:           aws.String(TableName),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(id)"),
+	}}, nil
+}
+
+// PublishFunc publishes a message's event, returning once the broker has it
+type PublishFunc func(ctx context.Context, message *Message) error
+
+// Config represents the relay settings
+type Config struct {
+	// Interval is how often the relay sweeps the messages left behind
+	Interval time.Duration
+	// Grace is how old a message must be before a sweep publishes it, so
+	// the sweep leaves alone the messages their command is still delivering
+	Grace time.Duration
+}
+
+// Relay publishes outbox messages and deletes the ones published
+type Relay struct {
+	DynamoDB dynamodbiface.DynamoDBAPI
+	Publish  PublishFunc
+	Config   Config
+	Clock    clock.Clock
+	Logger   *log.Logger
+}
+
+// NewRelay creates a new Relay
+func NewRelay(dynamoDB dynamodbiface.DynamoDBAPI, publish PublishFunc, config Config, logger *log.Logger) *Relay {
+	return &Relay{
+		DynamoDB: dynamoDB,
+		Publish:  publish,
+		Config:   config,
+		Clock:    clock.System,
+		Logger:   logger,
+	}
+}
+
+// Deliver publishes messages in order and deletes each one published. A
+// message that fails to publish stays in the outbox for the next sweep.
+func (r *Relay) Deliver(ctx context.Context, messages []*Message) error {
+	var errs []error
+	for _, message := range messages {
+		if err := r.Publish(ctx, message); err != nil {
+			errs = append(errs, fmt.Errorf("failed to publish %s event %s: %w", message.Type, message.ID, err))
+			continue
+		}
+		if err := r.delete(ctx, message); err != nil {
+			// Published all the same; the next sweep publishes it again
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// delete removes a published message from the outbox
+func (r *Relay) delete(ctx context.Context, message *Message) error {
+	_, err := r.DynamoDB.DeleteItemWithContext(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(TableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {S: aws.String(message.ID)},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete outbox message %s: %w", message.ID, err)
+	}
+	return nil
+}
+
+// Pending returns the messages stored before the given time, oldest first
+func (r *Relay) Pending(ctx context.Context, before time.Time) ([]*Message, error) {
+	var messages []*Message
+	input := &dynamodb.ScanInput{TableName: aws.String(TableName)}
+	for {
+		result, err := r.DynamoDB.ScanWithContext(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan outbox: %w", err)
+		}
+		for _, item := range result.Items {
+			var message Message
+			if err := dynamodbattribute.UnmarshalMap(item, &message); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal outbox message: %w", err)
+			}
+			if message.CreatedAt.Before(before) {
+				messages = append(messages, &message)
+			}
+		}
+		if len(result.LastEvaluatedKey) == 0 {
+			break
+		}
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+
+	sort.SliceStable(messages, func(i, j int) bool {
+		return messages[i].CreatedAt.Before(messages[j].CreatedAt)
+	})
+	return messages, nil
+}
+
+// Sweep publishes the messages older than the grace period
+func (r *Relay) Sweep(ctx context.Context) (int, error) {
+	messages, err := r.Pending(ctx, r.Clock.Now().Add(-r.Config.Grace))
+	if err != nil {
+		return 0, err
+	}
+	if len(messages) == 0 {
+		return 0, nil
+	}
+
+	r.Logger.Printf("Publishing outbox messages left behind - count: %d", len(messages))
+	return len(messages), r.Deliver(ctx, messages)
+}
+
+// Start sweeps the outbox every interval until ctx is done
+func (r *Relay) Start(ctx context.Context) {
+	r.Logger.Printf("Starting outbox relay - interval: %v, grace: %v", r.Config.Interval, r.Config.Grace)
+
+	ticker := time.NewTicker(r.Config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			r.Logger.Println("Outbox relay stopped")
+			return
+		case <-ticker.C:
+			if _, err := r.Sweep(ctx); err != nil {
+				r.Logger.Printf("Outbox sweep failed: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+
+	"medisupply/clock"
+	"orden-compra/internal/memory"
+)
+
+var storedAt = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// store writes messages to the outbox as a command's transaction would
+func store(t *testing.T, dynamoDB *memory.DynamoDB, messages ...*Message) {
+	t.Helper()
+	var items []*dynamodb.TransactWriteItem
+	for _, message := range messages {
+		item, err := message.Put()
+		if err != nil {
+			t.Fatalf("put: %v", err)
+		}
+		items = append(items, item)
+	}
+	if _, err := dynamoDB.TransactWriteItemsWithContext(context.Background(), &dynamodb.TransactWriteItemsInput{TransactItems: items}); err != nil {
+		t.Fatalf("store messages: %v", err)
+	}
+}
+
+func newMessage(t *testing.T, id string, at time.Time) *Message {
+	t.Helper()
+	message, err := NewMessage("Test", id, "tenant-1", map[string]string{"id": id}, at)
+	if err != nil {
+		t.Fatalf("new message: %v", err)
+	}
+	return message
+}
+
+// recorder publishes into a list, failing the IDs in fail
+type recorder struct {
+	fail      map[string]bool
+	published []string
+}
+
+func (r *recorder) publish(ctx context.Context, message *Message) error {
+	if r.fail[message.ID] {
+		return errors.New("broker unavailable")
+	}
+	r.published = append(r.published, message.ID)
+	return nil
+}
+
+func newTestRelay(dynamoDB *memory.DynamoDB, publisher *recorder, fake *clock.Fake) *Relay {
+	relay := NewRelay(dynamoDB, publisher.publish, Config{Interval: time.Minute, Grace: time.Minute}, log.New(io.Discard, "", 0))
+	relay.Clock = fake
+	return relay
+}
+
+func TestDeliverKeepsTheMessagesThatFailed(t *testing.T) {
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	first, second := newMessage(t, "event-1", storedAt), newMessage(t, "event-2", storedAt)
+	store(t, dynamoDB, first, second)
+
+	publisher := &recorder{fail: map[string]bool{"event-2": true}}
+	relay := newTestRelay(dynamoDB, publisher, clock.NewFake(storedAt))
+	if err := relay.Deliver(context.Background(), []*Message{first, second}); err == nil {
+		t.Fatal("deliver succeeded with a failed publish")
+	}
+
+	items := dynamoDB.Items(TableName)
+	if len(items) != 1 || aws.StringValue(items[0]["id"].S) != "event-2" {
+		t.Fatalf("outbox holds %v, want only the failed event-2", items)
+	}
+}
+
+func TestSweepPublishesTheMessagesLeftBehind(t *testing.T) {
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	fake := clock.NewFake(storedAt)
+	store(t, dynamoDB, newMessage(t, "event-2", storedAt.Add(time.Second)))
+	store(t, dynamoDB, newMessage(t, "event-1", storedAt))
+
+	publisher := &recorder{}
+	relay := newTestRelay(dynamoDB, publisher, fake)
+
+	// Within the grace period the messages are left to their command
+	if sent, err := relay.Sweep(context.Background()); err != nil || sent != 0 {
+		t.Fatalf("sweep in grace period sent %d messages, error %v", sent, err)
+	}
+
+	fake.Advance(2 * time.Minute)
+	if sent, err := relay.Sweep(context.Background()); err != nil || sent != 2 {
+		t.Fatalf("sweep sent %d messages, error %v; want 2", sent, err)
+	}
+	if len(publisher.published) != 2 || publisher.published[0] != "event-1" || publisher.published[1] != "event-2" {
+		t.Fatalf("published %v, want oldest first", publisher.published)
+	}
+	if items := dynamoDB.Items(TableName); len(items) != 0 {
+		t.Fatalf("outbox still holds %d messages", len(items))
+	}
+}
+
+func TestMessageDecodesItsEvent(t *testing.T) {
+	message := newMessage(t, "event-1", storedAt)
+
+	var event map[string]string
+	if err := message.Decode(&event); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if event["id"] != "event-1" {
+		t.Fatalf("decoded %v", event)
+	}
+}
@@ -0,0 +1,69 @@
+package pdf
+
+// code128Patterns holds the bar and space widths, in modules, of each Code 128
+// symbol value; 104 is Start B and 106 the stop pattern
+var code128Patterns = [...]string{
+	"212222", "222122", "222221", "121223", "121322", "131222", "122213", "122312", "132212", "221213",
+	"221312", "231212", "112232", "122132", "122231", "113222", "123122", "123221", "223211", "221132",
+	"221231", "213212", "223112", "312131", "311222", "321122", "321221", "312212", "322112", "322211",
+	"212123", "212321", "232121", "111323", "131123", "131321", "112313", "132113", "132311", "211313",
+	"231113", "231311", "112133", "112331", "132131", "113123", "113321", "133121", "313121", "211331",
+	"231131", "213113", "213311", "213131", "311123", "311321", "331121", "312113", "312311", "332111",
+	"314111", "221411", "431111", "111224", "111422", "121124", "121421", "141122", "141221", "112214",
+	"112412", "122114", "122411", "142112", "142211", "241211", "221114", "413111", "241112", "134111",
+	"111242", "121142", "121241", "114212", "124112", "124211", "411212", "421112", "421211", "212141",
+	"214121", "412121", "111143", "111341", "131141", "114113", "114311", "411113", "411311", "113141",
+	"114131", "311141", "411131", "211412", "211214", "211232", "2331112",
+}
+
+const (
+	code128StartB = 104
+	code128Stop   = 106
+	// code128QuietZone is the blank margin required on each side, in modules
+	code128QuietZone = 10
+)
+
+// code128 encodes data in Code 128 set B and returns the alternating bar and
+// space widths in modules, starting with a bar. Characters outside printable
+// ASCII are encoded as '?'.
+func code128(data string) []int {
+	values := []int{code128StartB}
+	checksum := code128StartB
+	for i, r := range data {
+		if r < 32 || r > 126 {
+			r = '?'
+		}
+		value := int(r) - 32
+		values = append(values, value)
+		checksum += (i + 1) * value
+	}
+	values = append(values, checksum%103, code128Stop)
+
+	var widths []int
+	for _, value := range values {
+		for _, width := range code128Patterns[value] {
+			widths = append(widths, int(width-'0'))
+		}
+	}
+	return widths
+}
+
+// Barcode draws data as a Code 128 barcode with its top left corner at x, y,
+// scaled to the given width including the quiet zones
+func (d *Document) Barcode(x, y, width, height float64, data string) {
+	widths := code128(data)
+
+	modules := 2 * code128QuietZone
+	for _, w := range widths {
+		modules += w
+	}
+	module := width / float64(modules)
+
+	position := x + code128QuietZone*module
+	for i, w := range widths {
+		if i%2 == 0 {
+			d.Rect(position, y, float64(w)*module, height, 0)
+		}
+		position += float64(w) * module
+	}
+}
@@ -0,0 +1,126 @@
+// Package pdf writes simple printable PDF documents: text in the standard
+// Helvetica fonts, lines and filled rectangles on A4 pages. It needs no font
+// files since every PDF viewer ships the standard fonts.
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// A4 page size in points
+const (
+	PageWidth  = 595.0
+	PageHeight = 842.0
+)
+
+// Font selects one of the standard fonts
+type Font int
+
+// Standard fonts
+const (
+	Regular Font = iota
+	Bold
+)
+
+// Document is a PDF document under construction
+type Document struct {
+	Title string
+	pages []*bytes.Buffer
+}
+
+// NewDocument creates a document with one empty page
+func NewDocument(title string) *Document {
+	d := &Document{Title: title}
+	d.AddPage()
+	return d
+}
+
+// AddPage starts a new page; drawing goes to the last page
+func (d *Document) AddPage() {
+	d.pages = append(d.pages, &bytes.Buffer{})
+}
+
+// page returns the page being drawn on
+func (d *Document) page() *bytes.Buffer {
+	return d.pages[len(d.pages)-1]
+}
+
+// Text draws text with its baseline starting at x, y. Coordinates are in
+// points from the top left corner of the page.
+func (d *Document) Text(x, y float64, font Font, size float64, text string) {
+	fmt.Fprintf(d.page(), "BT /F%d %.1f Tf %.2f %.2f Td (%s) Tj ET\n", font+1, size, x, PageHeight-y, escape(text))
+}
+
+// Line draws a line of the given width
+func (d *Document) Line(x1, y1, x2, y2, width float64) {
+	fmt.Fprintf(d.page(), "%.2f w %.2f %.2f m %.2f %.2f l S\n", width, x1, PageHeight-y1, x2, PageHeight-y2)
+}
+
+// Rect fills a rectangle with the given gray level, 0 being black and 1 white
+func (d *Document) Rect(x, y, width, height, gray float64) {
+	fmt.Fprintf(d.page(), "%.3f g %.3f %.2f %.3f %.2f re f 0 g\n", gray, x, PageHeight-y-height, width, height)
+}
+
+// Bytes returns the encoded document
+func (d *Document) Bytes() []byte {
+	var out bytes.Buffer
+	var offsets []int
+
+	object := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	// Objects 1-4 are the catalog, page tree, fonts and info; pages follow
+	// as pairs of page and content stream objects
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 6+2*i)
+	}
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	object(fmt.Sprintf("<< /Title (%s) /Producer (orden-compra) >>", escape(d.Title)))
+
+	for i, content := range d.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			PageWidth, PageHeight, 7+2*i))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R /Info 5 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	return out.Bytes()
+}
+
+// escape encodes text as a PDF string in WinAnsiEncoding. Latin-1 covers
+// Spanish and most Western names; other characters print as '?'.
+func escape(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n' || r == '\r' || r == '\t':
+			b.WriteByte(' ')
+		case r >= 0x20 && r < 0x7f:
+			b.WriteRune(r)
+		case r >= 0xa0 && r <= 0xff:
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,144 @@
+package pdf
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"orden-compra/internal/models"
+)
+
+// checkStructure checks that the cross-reference table of a document points
+// at its objects and that every stream has its stated length
+func checkStructure(t *testing.T, data []byte) {
+	t.Helper()
+	if !bytes.HasPrefix(data, []byte("%PDF-1.4\n")) || !bytes.HasSuffix(data, []byte("%%EOF\n")) {
+		t.Fatalf("not a PDF: %q...", data[:20])
+	}
+
+	startxref := regexp.MustCompile(`startxref\n(\d+)\n`).FindSubmatch(data)
+	if startxref == nil {
+		t.Fatal("no startxref")
+	}
+	xref, _ := strconv.Atoi(string(startxref[1]))
+	if !bytes.HasPrefix(data[xref:], []byte("xref\n")) {
+		t.Fatalf("startxref %d does not point at the xref table", xref)
+	}
+	for i, offset := range regexp.MustCompile(`(\d{10}) 00000 n `).FindAllSubmatch(data[xref:], -1) {
+		at, _ := strconv.Atoi(string(offset[1]))
+		if want := strconv.Itoa(i+1) + " 0 obj\n"; !bytes.HasPrefix(data[at:], []byte(want)) {
+			t.Fatalf("xref entry %d points at %q, want %q", i+1, data[at:at+10], want)
+		}
+	}
+
+	for _, stream := range regexp.MustCompile(`(?s)/Length (\d+) >>\nstream\n(.*?)endstream`).FindAllSubmatch(data, -1) {
+		if length, _ := strconv.Atoi(string(stream[1])); length != len(stream[2]) {
+			t.Fatalf("stream of %d bytes says /Length %d", len(stream[2]), length)
+		}
+	}
+}
+
+func TestDocumentBytes(t *testing.T) {
+	d := NewDocument("Orden (borrador)")
+	d.Text(50, 70, Bold, 20, "Página 1")
+	d.Line(50, 110, 545, 110, 1)
+	d.AddPage()
+	d.Rect(50, 100, 100, 20, 0.9)
+	data := d.Bytes()
+
+	checkStructure(t, data)
+	if !bytes.Contains(data, []byte("/Count 2")) || bytes.Count(data, []byte("/Type /Page ")) != 2 {
+		t.Fatal("document does not have two pages")
+	}
+	if !bytes.Contains(data, []byte(`/Title (Orden \(borrador\))`)) {
+		t.Fatal("title is not escaped")
+	}
+	// Coordinates are from the top left corner, PDF's are from the bottom left
+	if !bytes.Contains(data, []byte("BT /F2 20.0 Tf 50.00 772.00 Td (P\\341gina 1) Tj ET")) {
+		t.Fatalf("text not drawn at the flipped coordinates:\n%s", data)
+	}
+}
+
+func TestEscape(t *testing.T) {
+	for text, want := range map[string]string{
+		"plain":          "plain",
+		`a (b) c\d`:      `a \(b\) c\\d`,
+		"line\nbreak\t!": "line break !",
+		"Año número":     `A\361o n\372mero`,
+		"東京 €":           "?? ?",
+	} {
+		if got := escape(text); got != want {
+			t.Errorf("escape(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
+
+func TestCode128(t *testing.T) {
+	widths := code128("PJJ123C")
+
+	// Start B, seven characters and the checksum take 11 modules each, and
+	// the stop pattern 13
+	modules := 0
+	for _, w := range widths {
+		modules += w
+	}
+	if modules != 9*11+13 || len(widths) != 9*6+7 {
+		t.Fatalf("%d widths of %d modules", len(widths), modules)
+	}
+	symbol := func(i int) string {
+		var s strings.Builder
+		for _, w := range widths[6*i : 6*i+6] {
+			s.WriteString(strconv.Itoa(w))
+		}
+		return s.String()
+	}
+	// (104 + 48*1 + 42*2 + 42*3 + 17*4 + 18*5 + 19*6 + 35*7) mod 103 = 55
+	if symbol(0) != code128Patterns[code128StartB] || symbol(1) != code128Patterns['P'-32] || symbol(8) != code128Patterns[55] {
+		t.Fatalf("symbols %s %s ... %s", symbol(0), symbol(1), symbol(8))
+	}
+
+	// Characters outside set B print as '?'
+	if got, want := code128("ñ"), code128("?"); len(got) != len(want) {
+		t.Fatalf("code128(ñ) has %d widths, want those of '?'", len(got))
+	}
+}
+
+func TestPurchaseOrder(t *testing.T) {
+	expected := time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+	order := &models.PurchaseOrder{
+		ID:           "po-123",
+		ProductID:    "product-1",
+		ProductName:  "Guantes de nitrilo talla M, caja de 100 unidades, sin polvo, azul",
+		SupplierID:   "supplier-1",
+		SupplierName: "Name on the order",
+		Quantity:     250,
+		Status:       "created",
+		Location:     "bodega-norte",
+		ExpectedDate: &expected,
+		CreatedAt:    time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+	}
+
+	for name, tc := range map[string]struct {
+		supplier *models.Supplier
+		want     []string
+	}{
+		"catalog supplier": {
+			&models.Supplier{Name: "Distribuidora Médica", Address: "Calle 1 #2-3", Email: "ventas@example.com"},
+			[]string{`(Distribuidora M\351dica)`, "(Calle 1 #2-3)", "(ventas@example.com)"},
+		},
+		"supplier not in the catalog": {nil, []string{"(Name on the order)"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			data := PurchaseOrder(order, tc.supplier)
+			checkStructure(t, data)
+			for _, want := range append(tc.want, "(po-123)", "(2024-03-10)", "(bodega-norte)", "(250)", "(Guantes de nitrilo talla M, caja de 100 un...)") {
+				if !bytes.Contains(data, []byte(want)) {
+					t.Errorf("document does not show %s", want)
+				}
+			}
+		})
+	}
+}
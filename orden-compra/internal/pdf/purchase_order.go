@@ -0,0 +1,111 @@
+package pdf
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"orden-compra/internal/models"
+)
+
+// BuyerName is printed as the issuer of purchase orders
+var BuyerName = "MediSupply"
+
+// PurchaseOrder renders a printable purchase order for suppliers that need a
+// paper or PDF copy. supplier is nil when the supplier is not in the catalog.
+func PurchaseOrder(purchaseOrder *models.PurchaseOrder, supplier *models.Supplier) []byte {
+	d := NewDocument("Purchase order " + purchaseOrder.ID)
+	const left, right = 50.0, PageWidth - 50
+
+	// Header with the order number as a barcode for receiving
+	d.Text(left, 70, Bold, 20, "PURCHASE ORDER")
+	d.Text(left, 90, Regular, 11, BuyerName)
+	d.Barcode(right-260, 45, 260, 40, purchaseOrder.ID)
+	d.Text(right-230, 98, Regular, 8, purchaseOrder.ID)
+	d.Line(left, 110, right, 110, 1)
+
+	y := 135.0
+	field := func(x float64, label, value string) {
+		d.Text(x, y, Bold, 9, label)
+		d.Text(x+95, y, Regular, 9, fit(value, 40))
+	}
+	for _, row := range [][4]string{
+		{"Order number", purchaseOrder.ID, "Status", purchaseOrder.Status},
+		{"Order date", formatDate(&purchaseOrder.CreatedAt), "Urgency", purchaseOrder.UrgencyLevel},
+		{"Delivery date", formatDate(purchaseOrder.ExpectedDate), "Deliver to", purchaseOrder.Location},
+	} {
+		field(left, row[0], row[1])
+		field(left+300, row[2], row[3])
+		y += 16
+	}
+
+	// Supplier details, from the catalog when the supplier is listed
+	y += 14
+	d.Text(left, y, Bold, 11, "Supplier")
+	y += 18
+	supplierName := purchaseOrder.SupplierName
+	if supplier != nil && supplier.Name != "" {
+		supplierName = supplier.Name
+	}
+	supplierFields := [][2]string{{"Name", supplierName}, {"Supplier ID", purchaseOrder.SupplierID}}
+	if supplier != nil {
+		supplierFields = append(supplierFields,
+			[2]string{"Address", supplier.Address},
+			[2]string{"Email", supplier.Email},
+			[2]string{"Phone", supplier.Phone},
+		)
+	}
+	for _, f := range supplierFields {
+		if f[1] == "" {
+			continue
+		}
+		field(left, f[0], f[1])
+		y += 16
+	}
+
+	// Line items; orders carry a single product
+	y += 20
+	columns := []float64{left, left + 30, left + 150, right - 110, right - 40}
+	d.Rect(left, y-12, right-left, 18, 0.9)
+	for i, heading := range []string{"#", "Product ID", "Description", "Quantity", "Unit"} {
+		d.Text(columns[i]+4, y, Bold, 9, heading)
+	}
+	y += 20
+	for i, cell := range []string{"1", fit(purchaseOrder.ProductID, 20), fit(purchaseOrder.ProductName, 45), strconv.Itoa(purchaseOrder.Quantity), "EA"} {
+		d.Text(columns[i]+4, y, Regular, 9, cell)
+	}
+	y += 8
+	d.Line(left, y, right, y, 0.5)
+
+	// Totals
+	y += 20
+	d.Text(right-200, y, Bold, 9, "Total lines")
+	d.Text(right-80, y, Regular, 9, "1")
+	y += 16
+	d.Text(right-200, y, Bold, 9, "Total units")
+	d.Text(right-80, y, Regular, 9, strconv.Itoa(purchaseOrder.Quantity))
+
+	// Footer
+	d.Line(left, PageHeight-80, right, PageHeight-80, 0.5)
+	d.Text(left, PageHeight-64, Regular, 8, "Please quote the purchase order number on the advance shipment notice and all delivery documents.")
+	d.Text(left, PageHeight-52, Regular, 8, fmt.Sprintf("Generated %s", time.Now().UTC().Format("2006-01-02 15:04 MST")))
+
+	return d.Bytes()
+}
+
+// formatDate formats an optional date for printing
+func formatDate(date *time.Time) string {
+	if date == nil || date.IsZero() {
+		return "-"
+	}
+	return date.UTC().Format("2006-01-02")
+}
+
+// fit truncates text to at most n characters so it stays inside its column
+func fit(text string, n int) string {
+	runes := []rune(text)
+	if len(runes) <= n {
+		return text
+	}
+	return string(runes[:n-3]) + "..."
+}
@@ -0,0 +1,175 @@
+package redact
+
+import (
+	"encoding/json"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// Mask replaces redacted values
+const Mask = "[REDACTED]"
+
+// DefaultFields are the field names masked when no list is configured:
+// supplier contact details and credentials
+var DefaultFields = []string{
+	"email",
+	"phone",
+	"contact_name",
+	"contact_email",
+	"contact_phone",
+	"address",
+	"password",
+	"secret",
+	"token",
+	"api_key",
+	"authorization",
+}
+
+// emailPattern matches email addresses in free text
+var emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+
+// Redactor masks personal data and secrets. Field names are matched without
+// regard to case, and email addresses are masked wherever they appear. A nil
+// Redactor masks nothing.
+type Redactor struct {
+	fields map[string]struct{}
+	// fieldPattern matches "field": "value", field: value and field=value;
+	// unquoted values run to the next separator so phone numbers with spaces
+	// are masked whole
+	fieldPattern *regexp.Regexp
+}
+
+// New creates a redactor masking the given field names
+func New(fields []string) *Redactor {
+	r := &Redactor{fields: make(map[string]struct{}, len(fields))}
+
+	var names []string
+	for _, field := range fields {
+		field = strings.ToLower(strings.TrimSpace(field))
+		if field == "" {
+			continue
+		}
+		r.fields[field] = struct{}{}
+		names = append(names, regexp.QuoteMeta(field))
+	}
+	if len(names) > 0 {
+		r.fieldPattern = regexp.MustCompile(`(?i)("?\b(?:` + strings.Join(names, "|") + `)"?\s*[:=]\s*)("[^"]*"|[^,;&}\]\r\n]*[^\s,;&}\]])`)
+	}
+
+	return r
+}
+
+// IsRedacted reports whether values of the field are masked
+func (r *Redactor) IsRedacted(field string) bool {
+	if r == nil {
+		return false
+	}
+	_, ok := r.fields[strings.ToLower(field)]
+	return ok
+}
+
+// String masks the configured fields and email addresses in free text such
+// as log lines and message bodies
+func (r *Redactor) String(s string) string {
+	if r == nil {
+		return s
+	}
+
+	if r.fieldPattern != nil {
+		s = r.fieldPattern.ReplaceAllStringFunc(s, func(match string) string {
+			parts := r.fieldPattern.FindStringSubmatch(match)
+			if strings.HasPrefix(parts[2], `"`) {
+				return parts[1] + `"` + Mask + `"`
+			}
+			return parts[1] + Mask
+		})
+	}
+	return emailPattern.ReplaceAllString(s, Mask)
+}
+
+// Map returns data with the configured fields masked at any depth. Values
+// that are not plain maps, slices or scalars are compared in their JSON form;
+// data is returned unchanged when nothing needs masking, so its types are
+// kept.
+func (r *Redactor) Map(data map[string]interface{}) map[string]interface{} {
+	if r == nil || data == nil {
+		return data
+	}
+
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return data
+	}
+	var generic map[string]interface{}
+	if err := json.Unmarshal(raw, &generic); err != nil {
+		return data
+	}
+
+	if !r.walk(generic) {
+		return data
+	}
+	return generic
+}
+
+// walk masks v in place, reporting whether anything was masked
+func (r *Redactor) walk(v interface{}) bool {
+	masked := false
+
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for key, field := range value {
+			if r.IsRedacted(key) && field != nil {
+				value[key] = Mask
+				masked = true
+				continue
+			}
+			if s, ok := field.(string); ok {
+				if redacted := emailPattern.ReplaceAllString(s, Mask); redacted != s {
+					value[key] = redacted
+					masked = true
+				}
+				continue
+			}
+			if r.walk(field) {
+				masked = true
+			}
+		}
+	case []interface{}:
+		for i, item := range value {
+			if s, ok := item.(string); ok {
+				if redacted := emailPattern.ReplaceAllString(s, Mask); redacted != s {
+					value[i] = redacted
+					masked = true
+				}
+				continue
+			}
+			if r.walk(item) {
+				masked = true
+			}
+		}
+	}
+
+	return masked
+}
+
+// Writer returns an io.Writer that masks each write before passing it to out.
+// It relies on loggers writing each line with a single call.
+func (r *Redactor) Writer(out io.Writer) io.Writer {
+	if r == nil {
+		return out
+	}
+	return &writer{redactor: r, out: out}
+}
+
+type writer struct {
+	redactor *Redactor
+	out      io.Writer
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(w.out, w.redactor.String(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
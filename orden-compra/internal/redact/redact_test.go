@@ -0,0 +1,87 @@
+package redact
+
+import (
+	"bytes"
+	"log"
+	"reflect"
+	"testing"
+)
+
+func TestString(t *testing.T) {
+	r := New(DefaultFields)
+
+	for _, tc := range []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"JSON field", `{"contact_email":"ana@acme.com","quantity":10}`, `{"contact_email":"[REDACTED]","quantity":10}`},
+		{"key value pair", "supplier phone=+34 600 123 456, status=sent", "supplier phone=[REDACTED], status=sent"},
+		{"colon separated", "token: abc123; retry", "token: [REDACTED]; retry"},
+		{"field names ignore case", `{"Password":"hunter2"}`, `{"Password":"[REDACTED]"}`},
+		{"email in free text", "notified ana@acme.com about po-1", "notified [REDACTED] about po-1"},
+		{"other fields are kept", "status=sent quantity=10", "status=sent quantity=10"},
+		{"field name inside a word", "emailed=true", "emailed=true"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := r.String(tc.in); got != tc.want {
+				t.Fatalf("String(%q) = %q, want %q", tc.in, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMap(t *testing.T) {
+	r := New([]string{"contact_phone", " Secret "})
+
+	type supplier struct {
+		Name         string `json:"name"`
+		ContactPhone string `json:"contact_phone"`
+	}
+	data := map[string]interface{}{
+		"supplier": supplier{Name: "Acme", ContactPhone: "600123456"},
+		"notes":    []interface{}{"call ana@acme.com", map[string]interface{}{"secret": "s3cr3t"}},
+		"quantity": 10,
+	}
+	got := r.Map(data)
+
+	want := map[string]interface{}{
+		"supplier": map[string]interface{}{"name": "Acme", "contact_phone": Mask},
+		"notes":    []interface{}{"call " + Mask, map[string]interface{}{"secret": Mask}},
+		"quantity": float64(10),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Map = %#v, want %#v", got, want)
+	}
+	if data["supplier"].(supplier).ContactPhone != "600123456" {
+		t.Fatal("Map changed its input")
+	}
+
+	// Data with nothing to mask keeps its types
+	clean := map[string]interface{}{"supplier_id": "supplier-1", "quantity": 10}
+	if got := r.Map(clean); got["quantity"] != 10 {
+		t.Fatalf("clean data converted to %#v", got)
+	}
+}
+
+func TestWriterMasksLogLines(t *testing.T) {
+	var out bytes.Buffer
+	logger := log.New(New(DefaultFields).Writer(&out), "", 0)
+	logger.Printf("Supplier updated - contact_email: %s, supplier_id: %s", "ana@acme.com", "supplier-1")
+
+	if got := out.String(); got != "Supplier updated - contact_email: [REDACTED], supplier_id: supplier-1\n" {
+		t.Fatalf("logged %q", got)
+	}
+}
+
+func TestNilRedactorMasksNothing(t *testing.T) {
+	var r *Redactor
+	data := map[string]interface{}{"email": "ana@acme.com"}
+	if r.String("ana@acme.com") != "ana@acme.com" || r.IsRedacted("email") || !reflect.DeepEqual(r.Map(data), data) {
+		t.Fatal("nil redactor masked a value")
+	}
+	var out bytes.Buffer
+	if r.Writer(&out) != &out {
+		t.Fatal("nil redactor wrapped the writer")
+	}
+}
@@ -0,0 +1,373 @@
+package saga
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+
+	"medisupply/correlation"
+	"orden-compra/internal/audit"
+	"orden-compra/internal/models"
+	"orden-compra/internal/tenant"
+)
+
+// syncOverlap is how far before the checkpoint each run looks again, so
+// events stored late by other replicas are not skipped. Steps are recorded
+// once, so applying an event twice is harmless.
+const syncOverlap = time.Minute
+
+// compensationCancel is the compensating action for a missing acknowledgement
+const compensationCancel = "cancel_purchase_order"
+
+// Config represents the deadlines of the saga steps
+type Config struct {
+	// AckTimeout is how long the supplier has to acknowledge a dispatched
+	// order before it is cancelled; zero disables the compensation
+	AckTimeout time.Duration
+	// ReceptionGrace is how long after the expected date a reception may
+	// arrive before the saga is marked stalled; zero disables the deadline
+	ReceptionGrace time.Duration
+	Interval       time.Duration
+}
+
+// Compensator cancels purchase orders whose saga missed a deadline and
+// notifies the other services of the cancellation
+type Compensator interface {
+	CancelPurchaseOrder(ctx context.Context, purchaseOrderID, reason string) (map[string]interface{}, error)
+}
+
+// sagaEvent holds the event attributes the coordinator reads. Every order
+// event snapshots the order, whose metadata keeps the correlation ID of the
+// chain that created it; events of later requests may carry their own.
+type sagaEvent struct {
+	ID            string    `dynamodbav:"id"`
+	AggregateID   string    `dynamodbav:"aggregate_id"`
+	EventType     string    `dynamodbav:"event_type"`
+	Timestamp     time.Time `dynamodbav:"timestamp"`
+	CorrelationID *string   `dynamodbav:"correlation_id"`
+	EventData     struct {
+		PurchaseOrder *models.PurchaseOrder `dynamodbav:"purchase_order"`
+	} `dynamodbav:"event_data"`
+}
+
+// chainID returns the correlation ID of the chain the event belongs to
+func (e *sagaEvent) chainID() string {
+	if id := correlation.FromMetadata(e.EventData.PurchaseOrder.Metadata, correlation.CorrelationIDKey); id != nil {
+		return *id
+	}
+	return aws.StringValue(e.CorrelationID)
+}
+
+// Coordinator advances the sagas from the event store and enforces their
+// deadlines. It runs on one replica at a time.
+type Coordinator struct {
+	Store       *Store
+	DynamoDB    dynamodbiface.DynamoDBAPI
+	Compensator Compensator
+	Config      Config
+	Logger      *log.Logger
+}
+
+// NewCoordinator creates a new saga coordinator
+func NewCoordinator(store *Store, dynamoDB dynamodbiface.DynamoDBAPI, compensator Compensator, config Config, logger *log.Logger) *Coordinator {
+	return &Coordinator{
+		Store:       store,
+		DynamoDB:    dynamoDB,
+		Compensator: compensator,
+		Config:      config,
+		Logger:      logger,
+	}
+}
+
+// Start runs the coordinator every interval until the context is cancelled
+func (c *Coordinator) Start(ctx context.Context) {
+	c.Logger.Printf("Starting saga coordinator - interval: %v, ack timeout: %v, reception grace: %v", c.Config.Interval, c.Config.AckTimeout, c.Config.ReceptionGrace)
+
+	ticker := time.NewTicker(c.Config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			c.Logger.Println("Saga coordinator stopped")
+			return
+		case <-ticker.C:
+			if err := c.RunOnce(ctx); err != nil {
+				c.Logger.Printf("Saga coordination failed: %v", err)
+			}
+		}
+	}
+}
+
+// RunOnce applies the events stored since the checkpoint, then handles the
+// sagas whose deadline has passed
+func (c *Coordinator) RunOnce(ctx context.Context) error {
+	ctx = audit.WithOrigin(ctx, audit.SystemOrigin("saga-coordinator"))
+
+	if err := c.project(ctx); err != nil {
+		return err
+	}
+	return c.enforceDeadlines(ctx, time.Now().UTC())
+}
+
+// project records the steps shown by new events and moves the checkpoint
+func (c *Coordinator) project(ctx context.Context) error {
+	checkpoint, err := c.Store.Checkpoint(ctx)
+	if err != nil {
+		return err
+	}
+
+	scanInput := &dynamodb.ScanInput{
+		TableName:                aws.String("orden-compra-events"),
+		ExpressionAttributeNames: map[string]*string{"#timestamp": aws.String("timestamp")},
+		ProjectionExpression:     aws.String("id, aggregate_id, event_type, #timestamp, correlation_id, event_data.purchase_order"),
+	}
+	if !checkpoint.IsZero() {
+		scanInput.FilterExpression = aws.String("#timestamp > :since")
+		scanInput.ExpressionAttributeValues = map[string]*dynamodb.AttributeValue{
+			":since": {S: aws.String(checkpoint.Add(-syncOverlap).UTC().Format(time.RFC3339Nano))},
+		}
+	}
+
+	var events []*sagaEvent
+	latest := checkpoint
+	for {
+		result, err := c.DynamoDB.ScanWithContext(ctx, scanInput)
+		if err != nil {
+			return fmt.Errorf("failed to scan events: %w", err)
+		}
+
+		for _, item := range result.Items {
+			var event sagaEvent
+			if err := dynamodbattribute.UnmarshalMap(item, &event); err != nil {
+				c.Logger.Printf("Failed to unmarshal event: %v", err)
+				continue
+			}
+			if event.Timestamp.After(latest) {
+				latest = event.Timestamp
+			}
+			if event.EventData.PurchaseOrder != nil {
+				events = append(events, &event)
+			}
+		}
+
+		if len(result.LastEvaluatedKey) == 0 {
+			break
+		}
+		scanInput.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+
+	// Steps are recorded in the order they happened
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Timestamp.Before(events[j].Timestamp)
+	})
+
+	sagas := make(map[string]*models.Saga)
+	changed := make(map[string]bool)
+	for _, event := range events {
+		id := event.chainID()
+		if id == "" {
+			continue
+		}
+
+		saga, ok := sagas[id]
+		if !ok {
+			saga, err = c.Store.Get(ctx, id)
+			if err != nil && !errors.Is(err, ErrSagaNotFound) {
+				return err
+			}
+			sagas[id] = saga
+		}
+
+		saga, updated := c.apply(saga, event)
+		if saga != nil {
+			sagas[id] = saga
+		}
+		if updated {
+			changed[id] = true
+		}
+	}
+
+	for id := range changed {
+		if err := c.Store.Save(ctx, sagas[id]); err != nil {
+			// The checkpoint stays put so the next run retries
+			return err
+		}
+	}
+
+	if latest.After(checkpoint) {
+		if err := c.Store.SaveCheckpoint(ctx, latest); err != nil {
+			return err
+		}
+	}
+
+	if len(changed) > 0 {
+		c.Logger.Printf("Sagas updated - sagas: %d, checkpoint: %s", len(changed), latest.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// apply records the steps an event shows on its saga, starting the saga on
+// the order's creation, and reports whether the saga changed
+func (c *Coordinator) apply(saga *models.Saga, event *sagaEvent) (*models.Saga, bool) {
+	purchaseOrder := event.EventData.PurchaseOrder
+
+	if saga == nil {
+		// Only a new order starts a saga; events of orders created before
+		// sagas were tracked are ignored
+		if event.EventType != "PurchaseOrderCreated" {
+			return nil, false
+		}
+		saga = &models.Saga{
+			CorrelationID:   event.chainID(),
+			TenantID:        purchaseOrder.TenantID,
+			PurchaseOrderID: event.AggregateID,
+			Status:          models.SagaRunning,
+			Steps:           make([]models.SagaStep, 0, 6),
+			StartedAt:       event.Timestamp,
+		}
+		if stockLowEventID, ok := purchaseOrder.Metadata["stock_low_event_id"].(string); ok && stockLowEventID != "" {
+			saga.RecordStep(models.SagaStep{Name: models.SagaStepStockLowReceived, EventID: stockLowEventID, EventType: string(models.StockLowEventType), At: purchaseOrder.CreatedAt})
+		}
+	}
+
+	// A chain ID reused for another order, such as a consolidated order or a
+	// client resending its correlation ID, does not belong to this saga
+	if event.AggregateID != saga.PurchaseOrderID {
+		return saga, false
+	}
+
+	step := func(name string) models.SagaStep {
+		return models.SagaStep{Name: name, EventID: event.ID, EventType: event.EventType, At: event.Timestamp}
+	}
+
+	changed := false
+	if event.EventType == "PurchaseOrderCreated" {
+		changed = saga.RecordStep(step(models.SagaStepOrderCreated)) || changed
+	}
+	if event.EventType == models.PurchaseOrderDispatchedEventType || purchaseOrder.Status == models.StatusSent {
+		changed = saga.RecordStep(step(models.SagaStepOrderDispatched)) || changed
+	}
+	if event.EventType == models.PurchaseOrderAcknowledgedEventType && purchaseOrder.Status != models.StatusCancelled {
+		changed = saga.RecordStep(step(models.SagaStepSupplierAcknowledged)) || changed
+	}
+	if purchaseOrder.Status == models.StatusReceived || purchaseOrder.Status == models.StatusCompleted {
+		changed = saga.RecordStep(step(models.SagaStepReceptionConfirmed)) || changed
+	}
+	if purchaseOrder.Status == models.StatusCompleted {
+		changed = saga.RecordStep(step(models.SagaStepInventoryReceived)) || changed
+	}
+
+	status := saga.Status
+	switch {
+	case saga.Status == models.SagaCompensated:
+	case purchaseOrder.Status == models.StatusCompleted:
+		status = models.SagaCompleted
+	case purchaseOrder.Status == models.StatusCancelled || purchaseOrder.Status == models.StatusRejected:
+		status = models.SagaCancelled
+	case saga.Status == models.SagaStalled && purchaseOrder.Status == models.StatusReceived:
+		status = models.SagaRunning
+	}
+	if status != saga.Status {
+		saga.Status = status
+		changed = true
+	}
+
+	deadline := c.deadline(saga, purchaseOrder)
+	if !equalTimes(deadline, saga.Deadline) {
+		saga.Deadline = deadline
+		changed = true
+	}
+
+	if changed {
+		saga.UpdatedAt = time.Now().UTC()
+	}
+	return saga, changed
+}
+
+// deadline returns when the step the saga waits for times out, or nil when
+// it does not wait for a step with a deadline
+func (c *Coordinator) deadline(saga *models.Saga, purchaseOrder *models.PurchaseOrder) *time.Time {
+	if saga.Status != models.SagaRunning {
+		return nil
+	}
+
+	dispatched, isDispatched := saga.Step(models.SagaStepOrderDispatched)
+	_, isAcknowledged := saga.Step(models.SagaStepSupplierAcknowledged)
+	_, isReceived := saga.Step(models.SagaStepReceptionConfirmed)
+
+	switch {
+	case isReceived:
+		return nil
+	case isDispatched && !isAcknowledged && c.Config.AckTimeout > 0:
+		deadline := dispatched.At.Add(c.Config.AckTimeout)
+		return &deadline
+	case isAcknowledged && purchaseOrder.ExpectedDate != nil && c.Config.ReceptionGrace > 0:
+		deadline := purchaseOrder.ExpectedDate.Add(c.Config.ReceptionGrace)
+		return &deadline
+	}
+	return nil
+}
+
+// enforceDeadlines compensates or flags the sagas whose deadline passed
+func (c *Coordinator) enforceDeadlines(ctx context.Context, now time.Time) error {
+	due, err := c.Store.ListDue(ctx, now)
+	if err != nil {
+		return err
+	}
+
+	for _, saga := range due {
+		if _, ok := saga.Step(models.SagaStepSupplierAcknowledged); ok {
+			c.Logger.Printf("Saga stalled waiting for reception - correlation_id: %s, purchase_order_id: %s, deadline: %s", saga.CorrelationID, saga.PurchaseOrderID, saga.Deadline.Format(time.RFC3339))
+			saga.Status = models.SagaStalled
+			saga.Deadline = nil
+		} else {
+			c.compensate(ctx, saga, now)
+		}
+
+		saga.UpdatedAt = now
+		if err := c.Store.Save(ctx, saga); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compensate cancels the order of a saga whose supplier never acknowledged
+// it. Failures are recorded on the saga and retried on the next run.
+func (c *Coordinator) compensate(ctx context.Context, saga *models.Saga, now time.Time) {
+	reason := fmt.Sprintf("no supplier acknowledgement within %v", c.Config.AckTimeout)
+	c.Logger.Printf("Compensating saga - correlation_id: %s, purchase_order_id: %s, reason: %s", saga.CorrelationID, saga.PurchaseOrderID, reason)
+
+	// The cancellation joins the saga's chain and belongs to its tenant
+	compensationCtx := correlation.NewContext(ctx, correlation.IDs{RequestID: "saga-" + saga.CorrelationID, CorrelationID: saga.CorrelationID})
+	if saga.TenantID != "" {
+		compensationCtx = tenant.NewContext(compensationCtx, saga.TenantID)
+	}
+
+	saga.Compensation = &models.SagaCompensation{Action: compensationCancel, Reason: reason, At: now}
+	if _, err := c.Compensator.CancelPurchaseOrder(compensationCtx, saga.PurchaseOrderID, reason); err != nil {
+		c.Logger.Printf("Failed to compensate saga %s: %v", saga.CorrelationID, err)
+		saga.Compensation.Error = err.Error()
+		return
+	}
+
+	saga.Status = models.SagaCompensated
+	saga.Deadline = nil
+}
+
+// equalTimes reports whether two optional times are the same instant
+func equalTimes(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
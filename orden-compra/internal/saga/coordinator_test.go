@@ -0,0 +1,234 @@
+package saga
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+
+	"medisupply/correlation"
+	"orden-compra/internal/audit"
+	"orden-compra/internal/memory"
+	"orden-compra/internal/models"
+	"orden-compra/internal/tenant"
+)
+
+// cancellation is one compensation the coordinator asked for
+type cancellation struct {
+	purchaseOrderID string
+	tenantID        string
+	correlationID   string
+	actorID         string
+}
+
+// recordingCompensator records cancellations and fails them with err
+type recordingCompensator struct {
+	err       error
+	cancelled []cancellation
+}
+
+func (c *recordingCompensator) CancelPurchaseOrder(ctx context.Context, purchaseOrderID, reason string) (map[string]interface{}, error) {
+	tenantID, _ := tenant.FromContext(ctx)
+	ids, _ := correlation.FromContext(ctx)
+	c.cancelled = append(c.cancelled, cancellation{purchaseOrderID, tenantID, ids.CorrelationID, audit.OriginFrom(ctx).ActorID})
+	if c.err != nil {
+		return nil, c.err
+	}
+	return map[string]interface{}{"success": true}, nil
+}
+
+// newOrder returns an order placed for a stock low alert in the chain-1 chain
+func newOrder(createdAt time.Time) models.PurchaseOrder {
+	purchaseOrder := models.NewPurchaseOrder("product-1", "Gloves", "supplier-1", "Acme", "warehouse-1", "HIGH", 10, createdAt)
+	purchaseOrder.TenantID = "tenant-1"
+	purchaseOrder.Metadata = map[string]interface{}{
+		correlation.CorrelationIDKey: "chain-1",
+		"stock_low_event_id":         "stock-low-1",
+	}
+	return *purchaseOrder
+}
+
+// putOrderEvent stores an event snapshotting the order in a status
+func putOrderEvent(t *testing.T, dynamoDB *memory.DynamoDB, purchaseOrder models.PurchaseOrder, eventType, status string, at time.Time) {
+	t.Helper()
+	purchaseOrder.Status = status
+	event := models.NewEventSourcingEvent(purchaseOrder.ID, eventType, map[string]interface{}{"purchase_order": &purchaseOrder}, nil, nil, at)
+	item, err := dynamodbattribute.MarshalMap(event)
+	if err != nil {
+		t.Fatalf("marshal event: %v", err)
+	}
+	if _, err := dynamoDB.PutItemWithContext(context.Background(), &dynamodb.PutItemInput{TableName: aws.String("orden-compra-events"), Item: item}); err != nil {
+		t.Fatalf("put event: %v", err)
+	}
+}
+
+func newTestCoordinator(compensator Compensator, config Config) (*Coordinator, *memory.DynamoDB) {
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	return NewCoordinator(NewStore(dynamoDB), dynamoDB, compensator, config, log.New(io.Discard, "", 0)), dynamoDB
+}
+
+func TestSagaFollowsTheReplenishmentFlow(t *testing.T) {
+	ctx := context.Background()
+	coordinator, dynamoDB := newTestCoordinator(&recordingCompensator{}, Config{AckTimeout: time.Hour, ReceptionGrace: time.Hour})
+	start := time.Now().UTC().Add(-time.Hour)
+	purchaseOrder := newOrder(start)
+
+	putOrderEvent(t, dynamoDB, purchaseOrder, "PurchaseOrderCreated", models.StatusPending, start)
+	putOrderEvent(t, dynamoDB, purchaseOrder, models.PurchaseOrderDispatchedEventType, models.StatusSent, start.Add(time.Minute))
+	if err := coordinator.RunOnce(ctx); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	saga, err := coordinator.Store.Get(ctx, "chain-1")
+	if err != nil {
+		t.Fatalf("get saga: %v", err)
+	}
+	// The dispatched order waits for the acknowledgement
+	if saga.Status != models.SagaRunning || saga.PurchaseOrderID != purchaseOrder.ID || saga.TenantID != "tenant-1" || len(saga.Steps) != 3 {
+		t.Fatalf("saga %+v", saga)
+	}
+	if saga.Deadline == nil || !saga.Deadline.Equal(start.Add(time.Minute+time.Hour)) {
+		t.Fatalf("deadline %v, want an hour after the dispatch", saga.Deadline)
+	}
+
+	putOrderEvent(t, dynamoDB, purchaseOrder, models.PurchaseOrderAcknowledgedEventType, models.StatusSent, start.Add(2*time.Minute))
+	putOrderEvent(t, dynamoDB, purchaseOrder, "PurchaseOrderStatusUpdated", models.StatusReceived, start.Add(3*time.Minute))
+	putOrderEvent(t, dynamoDB, purchaseOrder, "PurchaseOrderStatusUpdated", models.StatusCompleted, start.Add(4*time.Minute))
+	if err := coordinator.RunOnce(ctx); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	saga, _ = coordinator.Store.Get(ctx, "chain-1")
+	if saga.Status != models.SagaCompleted || saga.Deadline != nil || !saga.Finished() {
+		t.Fatalf("saga %+v, want completed", saga)
+	}
+	want := []string{
+		models.SagaStepStockLowReceived,
+		models.SagaStepOrderCreated,
+		models.SagaStepOrderDispatched,
+		models.SagaStepSupplierAcknowledged,
+		models.SagaStepReceptionConfirmed,
+		models.SagaStepInventoryReceived,
+	}
+	if len(saga.Steps) != len(want) {
+		t.Fatalf("steps %+v, want %v", saga.Steps, want)
+	}
+	for i, step := range saga.Steps {
+		if step.Name != want[i] {
+			t.Fatalf("step %d is %s, want %s", i, step.Name, want[i])
+		}
+	}
+
+	if checkpoint, err := coordinator.Store.Checkpoint(ctx); err != nil || !checkpoint.Equal(start.Add(4*time.Minute)) {
+		t.Fatalf("checkpoint %v, error %v", checkpoint, err)
+	}
+}
+
+func TestSagaIsCompensatedWithoutAnAcknowledgement(t *testing.T) {
+	ctx := context.Background()
+	compensator := &recordingCompensator{err: errors.New("broker unavailable")}
+	coordinator, dynamoDB := newTestCoordinator(compensator, Config{AckTimeout: time.Hour})
+	start := time.Now().UTC().Add(-3 * time.Hour)
+	purchaseOrder := newOrder(start)
+	putOrderEvent(t, dynamoDB, purchaseOrder, "PurchaseOrderCreated", models.StatusPending, start)
+	putOrderEvent(t, dynamoDB, purchaseOrder, models.PurchaseOrderDispatchedEventType, models.StatusSent, start.Add(time.Minute))
+
+	// A failed cancellation is recorded and retried on the next run
+	if err := coordinator.RunOnce(ctx); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	saga, _ := coordinator.Store.Get(ctx, "chain-1")
+	if saga.Status != models.SagaRunning || saga.Compensation == nil || saga.Compensation.Error != "broker unavailable" {
+		t.Fatalf("saga %+v, want a failed compensation", saga)
+	}
+
+	compensator.err = nil
+	if err := coordinator.RunOnce(ctx); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	saga, _ = coordinator.Store.Get(ctx, "chain-1")
+	if saga.Status != models.SagaCompensated || saga.Deadline != nil || saga.Compensation.Action != compensationCancel || saga.Compensation.Error != "" {
+		t.Fatalf("saga %+v, want compensated", saga)
+	}
+	want := cancellation{purchaseOrder.ID, "tenant-1", "chain-1", "saga-coordinator"}
+	if len(compensator.cancelled) != 2 || compensator.cancelled[1] != want {
+		t.Fatalf("cancellations %+v, want two of %+v", compensator.cancelled, want)
+	}
+
+	// The cancellation event that follows does not reopen the saga
+	putOrderEvent(t, dynamoDB, purchaseOrder, "PurchaseOrderCancelled", models.StatusCancelled, time.Now().UTC())
+	if err := coordinator.RunOnce(ctx); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	if saga, _ = coordinator.Store.Get(ctx, "chain-1"); saga.Status != models.SagaCompensated {
+		t.Fatalf("saga status %s after the cancellation, want compensated", saga.Status)
+	}
+}
+
+func TestSagaStallsPastTheReceptionDeadline(t *testing.T) {
+	ctx := context.Background()
+	compensator := &recordingCompensator{}
+	coordinator, dynamoDB := newTestCoordinator(compensator, Config{AckTimeout: time.Hour, ReceptionGrace: time.Hour})
+	start := time.Now().UTC().Add(-48 * time.Hour)
+	purchaseOrder := newOrder(start)
+	expected := start.Add(24 * time.Hour)
+	purchaseOrder.ExpectedDate = &expected
+	putOrderEvent(t, dynamoDB, purchaseOrder, "PurchaseOrderCreated", models.StatusPending, start)
+	putOrderEvent(t, dynamoDB, purchaseOrder, models.PurchaseOrderDispatchedEventType, models.StatusSent, start.Add(time.Minute))
+	putOrderEvent(t, dynamoDB, purchaseOrder, models.PurchaseOrderAcknowledgedEventType, models.StatusSent, start.Add(2*time.Minute))
+
+	if err := coordinator.RunOnce(ctx); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	saga, _ := coordinator.Store.Get(ctx, "chain-1")
+	if saga.Status != models.SagaStalled || saga.Deadline != nil || len(compensator.cancelled) != 0 {
+		t.Fatalf("saga %+v, cancellations %v, want stalled without compensation", saga, compensator.cancelled)
+	}
+
+	// The late reception resumes the saga
+	putOrderEvent(t, dynamoDB, purchaseOrder, "PurchaseOrderStatusUpdated", models.StatusReceived, time.Now().UTC())
+	if err := coordinator.RunOnce(ctx); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	if saga, _ = coordinator.Store.Get(ctx, "chain-1"); saga.Status != models.SagaRunning || saga.Deadline != nil {
+		t.Fatalf("saga %+v, want running without a deadline", saga)
+	}
+}
+
+func TestSagaStartsOnlyWithTheOrder(t *testing.T) {
+	ctx := context.Background()
+	coordinator, dynamoDB := newTestCoordinator(&recordingCompensator{}, Config{})
+	start := time.Now().UTC().Add(-time.Hour)
+
+	// An order created before sagas were tracked has no saga
+	untracked := newOrder(start)
+	putOrderEvent(t, dynamoDB, untracked, models.PurchaseOrderDispatchedEventType, models.StatusSent, start)
+	if err := coordinator.RunOnce(ctx); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	if _, err := coordinator.Store.Get(ctx, "chain-1"); !errors.Is(err, ErrSagaNotFound) {
+		t.Fatalf("get saga returned %v", err)
+	}
+
+	// Another order reusing the chain ID is not part of its saga
+	purchaseOrder := newOrder(start)
+	putOrderEvent(t, dynamoDB, purchaseOrder, "PurchaseOrderCreated", models.StatusPending, start.Add(time.Minute))
+	other := newOrder(start)
+	putOrderEvent(t, dynamoDB, other, "PurchaseOrderCreated", models.StatusPending, start.Add(2*time.Minute))
+	if err := coordinator.RunOnce(ctx); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	saga, err := coordinator.Store.Get(ctx, "chain-1")
+	if err != nil || saga.PurchaseOrderID != purchaseOrder.ID || len(saga.Steps) != 2 {
+		t.Fatalf("saga %+v, error %v", saga, err)
+	}
+
+	// The checkpoint item is not a saga
+	if _, err := coordinator.Store.Get(ctx, checkpointID); !errors.Is(err, ErrSagaNotFound) {
+		t.Fatalf("get checkpoint as a saga returned %v", err)
+	}
+}
@@ -0,0 +1,158 @@
+// Package saga coordinates the replenishment flow across services: it follows
+// each correlation chain from the StockBajo event to the inventory reception
+// through the events orden-compra stores, enforces step deadlines and
+// compensates flows that stall.
+package saga
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+
+	"orden-compra/internal/models"
+)
+
+// ErrSagaNotFound is returned when no saga exists for a correlation ID
+var ErrSagaNotFound = errors.New("saga not found")
+
+// checkpointID is the item holding the coordinator's position in the event
+// store. Deleting it makes the coordinator replay every event.
+const checkpointID = "_checkpoint#events"
+
+// Store persists sagas in DynamoDB, one item per correlation ID
+type Store struct {
+	DynamoDB  dynamodbiface.DynamoDBAPI
+	TableName string
+}
+
+// NewStore creates a store on the default saga table
+func NewStore(dynamoDB dynamodbiface.DynamoDBAPI) *Store {
+	return &Store{
+		DynamoDB:  dynamoDB,
+		TableName: "orden-compra-sagas",
+	}
+}
+
+// Get returns the saga of a correlation chain
+func (s *Store) Get(ctx context.Context, correlationID string) (*models.Saga, error) {
+	if correlationID == checkpointID {
+		return nil, ErrSagaNotFound
+	}
+
+	result, err := s.DynamoDB.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(s.TableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {S: aws.String(correlationID)},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get saga: %w", err)
+	}
+	if result.Item == nil {
+		return nil, ErrSagaNotFound
+	}
+
+	var saga models.Saga
+	if err := dynamodbattribute.UnmarshalMap(result.Item, &saga); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal saga: %w", err)
+	}
+	return &saga, nil
+}
+
+// Save creates or replaces a saga
+func (s *Store) Save(ctx context.Context, saga *models.Saga) error {
+	item, err := dynamodbattribute.MarshalMap(saga)
+	if err != nil {
+		return fmt.Errorf("failed to marshal saga: %w", err)
+	}
+
+	_, err = s.DynamoDB.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(s.TableName),
+		Item:      item,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put saga: %w", err)
+	}
+	return nil
+}
+
+// ListDue returns the unfinished sagas whose deadline is at or before now
+func (s *Store) ListDue(ctx context.Context, now time.Time) ([]*models.Saga, error) {
+	scanInput := &dynamodb.ScanInput{
+		TableName:                aws.String(s.TableName),
+		FilterExpression:         aws.String("#status = :running AND deadline <= :now"),
+		ExpressionAttributeNames: map[string]*string{"#status": aws.String("status")},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":running": {S: aws.String(models.SagaRunning)},
+			":now":     {S: aws.String(now.UTC().Format(time.RFC3339Nano))},
+		},
+	}
+
+	sagas := make([]*models.Saga, 0)
+	for {
+		result, err := s.DynamoDB.ScanWithContext(ctx, scanInput)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan sagas: %w", err)
+		}
+
+		for _, item := range result.Items {
+			var saga models.Saga
+			if err := dynamodbattribute.UnmarshalMap(item, &saga); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal saga: %w", err)
+			}
+			sagas = append(sagas, &saga)
+		}
+
+		if len(result.LastEvaluatedKey) == 0 {
+			break
+		}
+		scanInput.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+	return sagas, nil
+}
+
+// Checkpoint returns the timestamp of the last event applied to the sagas, or
+// the zero time before the first run
+func (s *Store) Checkpoint(ctx context.Context) (time.Time, error) {
+	result, err := s.DynamoDB.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(s.TableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {S: aws.String(checkpointID)},
+		},
+	})
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get saga checkpoint: %w", err)
+	}
+	if result.Item == nil {
+		return time.Time{}, nil
+	}
+
+	var checkpoint struct {
+		Timestamp time.Time `dynamodbav:"timestamp"`
+	}
+	if err := dynamodbattribute.UnmarshalMap(result.Item, &checkpoint); err != nil {
+		return time.Time{}, fmt.Errorf("failed to unmarshal saga checkpoint: %w", err)
+	}
+	return checkpoint.Timestamp, nil
+}
+
+// SaveCheckpoint records the timestamp of the last event applied to the sagas
+func (s *Store) SaveCheckpoint(ctx context.Context, timestamp time.Time) error {
+	_, err := s.DynamoDB.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(s.TableName),
+		Item: map[string]*dynamodb.AttributeValue{
+			"id":        {S: aws.String(checkpointID)},
+			"timestamp": {S: aws.String(timestamp.UTC().Format(time.RFC3339Nano))},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save saga checkpoint: %w", err)
+	}
+	return nil
+}
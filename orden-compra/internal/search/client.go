@@ -0,0 +1,286 @@
+// Package search indexes purchase orders in OpenSearch or Elasticsearch for
+// free-text lookups. The index is a projection of the event store kept in
+// sync by Projector; only the REST API common to both engines is used.
+package search
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrNotConfigured is returned when no search cluster is configured
+var ErrNotConfigured = errors.New("search is not configured")
+
+// ErrInvalidQuery is returned for empty search queries
+var ErrInvalidQuery = errors.New("invalid search query")
+
+// Config represents the connection to the search cluster
+type Config struct {
+	URL      string
+	Index    string
+	Username string
+	Password string
+	Timeout  time.Duration
+}
+
+// Client talks to the purchase order index. A nil client means search is
+// disabled; its methods return ErrNotConfigured.
+type Client struct {
+	Config Config
+	HTTP   *http.Client
+}
+
+// NewClient creates a client, or returns nil when no URL is configured
+func NewClient(config Config) *Client {
+	if config.URL == "" {
+		return nil
+	}
+	config.URL = strings.TrimRight(config.URL, "/")
+	return &Client{
+		Config: config,
+		HTTP:   &http.Client{Timeout: config.Timeout},
+	}
+}
+
+// indexMapping keeps identifiers exact and analyses names for free text
+const indexMapping = `{
+  "mappings": {
+    "properties": {
+      "id":            {"type": "keyword"},
+      "tenant_id":     {"type": "keyword"},
+      "product_id":    {"type": "keyword"},
+      "product_name":  {"type": "text"},
+      "supplier_id":   {"type": "keyword"},
+      "supplier_name": {"type": "text"},
+      "batch_numbers": {"type": "keyword"},
+      "status":        {"type": "keyword"},
+      "urgency_level": {"type": "keyword"},
+      "location":      {"type": "keyword"},
+      "created_at":    {"type": "date"},
+      "updated_at":    {"type": "date"}
+    }
+  }
+}`
+
+// EnsureIndex creates the purchase order index unless it exists
+func (c *Client) EnsureIndex(ctx context.Context) error {
+	if c == nil {
+		return ErrNotConfigured
+	}
+
+	status, _, err := c.do(ctx, http.MethodHead, c.Config.Index, nil)
+	if err != nil {
+		return err
+	}
+	if status == http.StatusOK {
+		return nil
+	}
+
+	status, body, err := c.do(ctx, http.MethodPut, c.Config.Index, []byte(indexMapping))
+	if err != nil {
+		return err
+	}
+	// Another replica may have created it in the meantime
+	if status >= 300 && !bytes.Contains(body, []byte("resource_already_exists_exception")) {
+		return fmt.Errorf("failed to create index %s: status %d: %s", c.Config.Index, status, body)
+	}
+	return nil
+}
+
+// Index stores or replaces the document of a purchase order
+func (c *Client) Index(ctx context.Context, document *Document) error {
+	if c == nil {
+		return ErrNotConfigured
+	}
+
+	payload, err := json.Marshal(document)
+	if err != nil {
+		return fmt.Errorf("failed to marshal search document: %w", err)
+	}
+	status, body, err := c.do(ctx, http.MethodPut, c.Config.Index+"/_doc/"+url.PathEscape(document.ID), payload)
+	if err != nil {
+		return err
+	}
+	if status >= 300 {
+		return fmt.Errorf("failed to index purchase order %s: status %d: %s", document.ID, status, body)
+	}
+	return nil
+}
+
+// Delete removes the document of a purchase order; missing documents are ignored
+func (c *Client) Delete(ctx context.Context, id string) error {
+	if c == nil {
+		return ErrNotConfigured
+	}
+
+	status, body, err := c.do(ctx, http.MethodDelete, c.Config.Index+"/_doc/"+url.PathEscape(id), nil)
+	if err != nil {
+		return err
+	}
+	if status >= 300 && status != http.StatusNotFound {
+		return fmt.Errorf("failed to delete purchase order %s from index: status %d: %s", id, status, body)
+	}
+	return nil
+}
+
+// checkpointPath is the document holding the projector's position in the
+// event store. Deleting it makes the projector replay every event.
+func (c *Client) checkpointPath() string {
+	return c.Config.Index + "-sync/_doc/events"
+}
+
+// Checkpoint returns the timestamp of the last projected event, or the zero
+// time before the first sync
+func (c *Client) Checkpoint(ctx context.Context) (time.Time, error) {
+	if c == nil {
+		return time.Time{}, ErrNotConfigured
+	}
+
+	status, body, err := c.do(ctx, http.MethodGet, c.checkpointPath(), nil)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if status == http.StatusNotFound {
+		return time.Time{}, nil
+	}
+	if status >= 300 {
+		return time.Time{}, fmt.Errorf("failed to get search checkpoint: status %d: %s", status, body)
+	}
+
+	var result struct {
+		Source struct {
+			Timestamp time.Time `json:"timestamp"`
+		} `json:"_source"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return time.Time{}, fmt.Errorf("failed to decode search checkpoint: %w", err)
+	}
+	return result.Source.Timestamp, nil
+}
+
+// SaveCheckpoint records the timestamp of the last projected event
+func (c *Client) SaveCheckpoint(ctx context.Context, timestamp time.Time) error {
+	if c == nil {
+		return ErrNotConfigured
+	}
+
+	payload, err := json.Marshal(map[string]time.Time{"timestamp": timestamp})
+	if err != nil {
+		return fmt.Errorf("failed to marshal search checkpoint: %w", err)
+	}
+	status, body, err := c.do(ctx, http.MethodPut, c.checkpointPath(), payload)
+	if err != nil {
+		return err
+	}
+	if status >= 300 {
+		return fmt.Errorf("failed to save search checkpoint: status %d: %s", status, body)
+	}
+	return nil
+}
+
+// Search returns the best matches of text in order IDs, product and supplier
+// names and batch numbers, with the total number of matches. tenantID
+// restricts the results to one tenant when set.
+func (c *Client) Search(ctx context.Context, tenantID, text string, limit int) ([]*Document, int, error) {
+	if c == nil {
+		return nil, 0, ErrNotConfigured
+	}
+	if strings.TrimSpace(text) == "" {
+		return nil, 0, fmt.Errorf("%w: q is required", ErrInvalidQuery)
+	}
+
+	query := map[string]interface{}{
+		"multi_match": map[string]interface{}{
+			"query":     text,
+			"fields":    []string{"id^4", "batch_numbers^3", "product_name^2", "supplier_name"},
+			"fuzziness": "AUTO",
+			"lenient":   true,
+		},
+	}
+	if tenantID != "" {
+		query = map[string]interface{}{
+			"bool": map[string]interface{}{
+				"must":   query,
+				"filter": map[string]interface{}{"term": map[string]string{"tenant_id": tenantID}},
+			},
+		}
+	}
+	payload, err := json.Marshal(map[string]interface{}{
+		"size":             limit,
+		"query":            query,
+		"track_total_hits": true,
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to marshal search query: %w", err)
+	}
+
+	status, body, err := c.do(ctx, http.MethodPost, c.Config.Index+"/_search", payload)
+	if err != nil {
+		return nil, 0, err
+	}
+	if status >= 300 {
+		return nil, 0, fmt.Errorf("search failed: status %d: %s", status, body)
+	}
+
+	var result struct {
+		Hits struct {
+			Total struct {
+				Value int `json:"value"`
+			} `json:"total"`
+			Hits []struct {
+				Source *Document `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, 0, fmt.Errorf("failed to decode search response: %w", err)
+	}
+
+	documents := make([]*Document, 0, len(result.Hits.Hits))
+	for _, hit := range result.Hits.Hits {
+		if hit.Source != nil {
+			documents = append(documents, hit.Source)
+		}
+	}
+	return documents, result.Hits.Total.Value, nil
+}
+
+// do sends a request to the cluster and returns the status and body
+func (c *Client) do(ctx context.Context, method, path string, payload []byte) (int, []byte, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.Config.URL+"/"+path, body)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.Config.Username != "" {
+		req.SetBasicAuth(c.Config.Username, c.Config.Password)
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return 0, nil, fmt.Errorf("search request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Capped so a misbehaving cluster cannot exhaust memory
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 10<<20))
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to read search response: %w", err)
+	}
+	return resp.StatusCode, respBody, nil
+}
@@ -0,0 +1,160 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// cluster is a fake search cluster keeping documents by path
+type cluster struct {
+	mu        sync.Mutex
+	indices   map[string]bool
+	documents map[string][]byte
+	searches  []map[string]interface{}
+	auth      string
+}
+
+// startCluster serves the subset of the OpenSearch REST API the client uses
+func startCluster(t *testing.T) (*Client, *cluster) {
+	t.Helper()
+	c := &cluster{indices: map[string]bool{}, documents: map[string][]byte{}}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.auth = r.Header.Get("Authorization")
+		path := strings.TrimPrefix(r.URL.Path, "/")
+		body, _ := io.ReadAll(r.Body)
+
+		switch {
+		case strings.HasSuffix(path, "/_search"):
+			var query map[string]interface{}
+			json.Unmarshal(body, &query)
+			c.searches = append(c.searches, query)
+			var hits []map[string]json.RawMessage
+			for documentPath, document := range c.documents {
+				if strings.HasPrefix(documentPath, strings.TrimSuffix(path, "_search")+"_doc/") {
+					hits = append(hits, map[string]json.RawMessage{"_source": document})
+				}
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"hits": map[string]interface{}{"total": map[string]int{"value": len(hits)}, "hits": hits}})
+		case strings.Contains(path, "/_doc/"):
+			switch r.Method {
+			case http.MethodPut:
+				c.documents[path] = body
+				w.WriteHeader(http.StatusCreated)
+			case http.MethodDelete, http.MethodGet:
+				document, ok := c.documents[path]
+				if !ok {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				if r.Method == http.MethodDelete {
+					delete(c.documents, path)
+					return
+				}
+				json.NewEncoder(w).Encode(map[string]json.RawMessage{"_source": document})
+			}
+		default:
+			if r.Method == http.MethodPut {
+				c.indices[path] = true
+			} else if !c.indices[path] {
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+	return NewClient(Config{URL: server.URL + "/", Index: "purchase-orders", Username: "search", Password: "secret", Timeout: time.Second}), c
+}
+
+func TestClientIndexesAndSearches(t *testing.T) {
+	ctx := context.Background()
+	client, cluster := startCluster(t)
+
+	if err := client.EnsureIndex(ctx); err != nil {
+		t.Fatalf("EnsureIndex: %v", err)
+	}
+	if err := client.EnsureIndex(ctx); err != nil || !cluster.indices["purchase-orders"] {
+		t.Fatalf("EnsureIndex on an existing index: %v, indices %v", err, cluster.indices)
+	}
+	if !strings.HasPrefix(cluster.auth, "Basic ") {
+		t.Fatalf("authorization %q, want basic auth", cluster.auth)
+	}
+
+	for _, id := range []string{"po-1", "po-2"} {
+		if err := client.Index(ctx, &Document{ID: id, TenantID: "tenant-1", ProductName: "Gloves"}); err != nil {
+			t.Fatalf("Index: %v", err)
+		}
+	}
+	if err := client.Delete(ctx, "po-2"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := client.Delete(ctx, "po-2"); err != nil {
+		t.Fatalf("deleting a missing document: %v", err)
+	}
+
+	documents, total, err := client.Search(ctx, "tenant-1", "glove", 10)
+	if err != nil || total != 1 || len(documents) != 1 || documents[0].ID != "po-1" {
+		t.Fatalf("found %v of %d, error %v", documents, total, err)
+	}
+	// The tenant filters the matches
+	query := cluster.searches[0]["query"].(map[string]interface{})
+	filter := query["bool"].(map[string]interface{})["filter"].(map[string]interface{})
+	if filter["term"].(map[string]interface{})["tenant_id"] != "tenant-1" || cluster.searches[0]["size"] != float64(10) {
+		t.Fatalf("search query %v", cluster.searches[0])
+	}
+
+	if _, _, err := client.Search(ctx, "", "glove", 10); err != nil {
+		t.Fatalf("Search without a tenant: %v", err)
+	}
+	if _, ok := cluster.searches[1]["query"].(map[string]interface{})["multi_match"]; !ok {
+		t.Fatalf("search without a tenant %v, want a plain multi_match", cluster.searches[1])
+	}
+	if _, _, err := client.Search(ctx, "", "  ", 10); !errors.Is(err, ErrInvalidQuery) {
+		t.Fatalf("empty search returned %v", err)
+	}
+}
+
+func TestClientCheckpoint(t *testing.T) {
+	ctx := context.Background()
+	client, _ := startCluster(t)
+
+	if checkpoint, err := client.Checkpoint(ctx); err != nil || !checkpoint.IsZero() {
+		t.Fatalf("checkpoint before the first sync %v, error %v", checkpoint, err)
+	}
+	at := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	if err := client.SaveCheckpoint(ctx, at); err != nil {
+		t.Fatalf("SaveCheckpoint: %v", err)
+	}
+	if checkpoint, err := client.Checkpoint(ctx); err != nil || !checkpoint.Equal(at) {
+		t.Fatalf("checkpoint %v, error %v, want %v", checkpoint, err, at)
+	}
+}
+
+func TestNilClientIsNotConfigured(t *testing.T) {
+	ctx := context.Background()
+	client := NewClient(Config{})
+	if client != nil {
+		t.Fatalf("client %+v without a URL", client)
+	}
+	for name, err := range map[string]error{
+		"EnsureIndex":    client.EnsureIndex(ctx),
+		"Index":          client.Index(ctx, &Document{ID: "po-1"}),
+		"Delete":         client.Delete(ctx, "po-1"),
+		"SaveCheckpoint": client.SaveCheckpoint(ctx, time.Now()),
+	} {
+		if !errors.Is(err, ErrNotConfigured) {
+			t.Fatalf("%s returned %v", name, err)
+		}
+	}
+	if _, _, err := client.Search(ctx, "", "glove", 10); !errors.Is(err, ErrNotConfigured) {
+		t.Fatalf("Search returned %v", err)
+	}
+}
@@ -0,0 +1,47 @@
+package search
+
+import (
+	"time"
+
+	"orden-compra/internal/models"
+)
+
+// Document is the searchable summary of a purchase order
+type Document struct {
+	ID           string    `json:"id"`
+	TenantID     string    `json:"tenant_id,omitempty"`
+	ProductID    string    `json:"product_id"`
+	ProductName  string    `json:"product_name"`
+	SupplierID   string    `json:"supplier_id"`
+	SupplierName string    `json:"supplier_name"`
+	BatchNumbers []string  `json:"batch_numbers,omitempty"`
+	Status       string    `json:"status"`
+	UrgencyLevel string    `json:"urgency_level"`
+	Location     string    `json:"location"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// NewDocument builds the search document of a purchase order. Batch numbers
+// come from the lots of its advance shipment notice.
+func NewDocument(purchaseOrder *models.PurchaseOrder) *Document {
+	document := &Document{
+		ID:           purchaseOrder.ID,
+		TenantID:     purchaseOrder.TenantID,
+		ProductID:    purchaseOrder.ProductID,
+		ProductName:  purchaseOrder.ProductName,
+		SupplierID:   purchaseOrder.SupplierID,
+		SupplierName: purchaseOrder.SupplierName,
+		Status:       purchaseOrder.Status,
+		UrgencyLevel: purchaseOrder.UrgencyLevel,
+		Location:     purchaseOrder.Location,
+		CreatedAt:    purchaseOrder.CreatedAt,
+		UpdatedAt:    purchaseOrder.UpdatedAt,
+	}
+	if purchaseOrder.ASN != nil {
+		for _, lot := range purchaseOrder.ASN.Lots {
+			document.BatchNumbers = append(document.BatchNumbers, lot.BatchNumber)
+		}
+	}
+	return document
+}
@@ -0,0 +1,183 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+
+	"orden-compra/internal/models"
+)
+
+// syncOverlap is how far before the checkpoint each sync looks again, so
+// events stored late by other replicas are not skipped. Reindexing an order
+// twice is harmless.
+const syncOverlap = time.Minute
+
+// projectedEvent holds the event attributes the projector reads
+type projectedEvent struct {
+	AggregateID string    `dynamodbav:"aggregate_id"`
+	Timestamp   time.Time `dynamodbav:"timestamp"`
+}
+
+// Projector keeps the search index in sync with the event store: every
+// order with new events is reindexed from its current read model version,
+// so the index converges whatever order the events are seen in.
+type Projector struct {
+	DynamoDB dynamodbiface.DynamoDBAPI
+	Client   *Client
+	Interval time.Duration
+	Logger   *log.Logger
+}
+
+// NewProjector creates a new search projector
+func NewProjector(dynamoDB dynamodbiface.DynamoDBAPI, client *Client, interval time.Duration, logger *log.Logger) *Projector {
+	return &Projector{
+		DynamoDB: dynamoDB,
+		Client:   client,
+		Interval: interval,
+		Logger:   logger,
+	}
+}
+
+// Start creates the index and syncs it every interval until the context is cancelled
+func (p *Projector) Start(ctx context.Context) {
+	p.Logger.Printf("Starting search projector - index: %s, interval: %v", p.Client.Config.Index, p.Interval)
+
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+
+	ready := false
+	for {
+		if !ready {
+			if err := p.Client.EnsureIndex(ctx); err != nil {
+				p.Logger.Printf("Failed to create search index: %v", err)
+			} else {
+				ready = true
+			}
+		}
+		if ready {
+			if _, err := p.RunOnce(ctx); err != nil {
+				p.Logger.Printf("Search sync failed: %v", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			p.Logger.Println("Search projector stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce reindexes the orders with events since the checkpoint and moves
+// the checkpoint forward. It returns the number of orders reindexed.
+func (p *Projector) RunOnce(ctx context.Context) (int, error) {
+	checkpoint, err := p.Client.Checkpoint(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	scanInput := &dynamodb.ScanInput{
+		TableName:                aws.String("orden-compra-events"),
+		ProjectionExpression:     aws.String("aggregate_id, #timestamp"),
+		ExpressionAttributeNames: map[string]*string{"#timestamp": aws.String("timestamp")},
+	}
+	if !checkpoint.IsZero() {
+		scanInput.FilterExpression = aws.String("#timestamp > :since")
+		scanInput.ExpressionAttributeValues = map[string]*dynamodb.AttributeValue{
+			":since": {S: aws.String(checkpoint.Add(-syncOverlap).UTC().Format(time.RFC3339Nano))},
+		}
+	}
+
+	changed := make(map[string]bool)
+	latest := checkpoint
+	for {
+		result, err := p.DynamoDB.ScanWithContext(ctx, scanInput)
+		if err != nil {
+			return 0, fmt.Errorf("failed to scan events: %w", err)
+		}
+
+		for _, item := range result.Items {
+			var event projectedEvent
+			if err := dynamodbattribute.UnmarshalMap(item, &event); err != nil {
+				p.Logger.Printf("Failed to unmarshal event: %v", err)
+				continue
+			}
+			changed[event.AggregateID] = true
+			if event.Timestamp.After(latest) {
+				latest = event.Timestamp
+			}
+		}
+
+		if len(result.LastEvaluatedKey) == 0 {
+			break
+		}
+		scanInput.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+
+	for id := range changed {
+		if err := p.reindex(ctx, id); err != nil {
+			// The checkpoint stays put so the next run retries
+			return 0, err
+		}
+	}
+
+	if latest.After(checkpoint) {
+		if err := p.Client.SaveCheckpoint(ctx, latest); err != nil {
+			return 0, err
+		}
+	}
+
+	if len(changed) > 0 {
+		p.Logger.Printf("Search index synced - orders: %d, checkpoint: %s", len(changed), latest.Format(time.RFC3339))
+	}
+	return len(changed), nil
+}
+
+// reindex indexes the current version of an order, removing orders that are
+// no longer in the read model. Events of other aggregates, such as
+// consolidated orders, find no order and are dropped the same way.
+func (p *Projector) reindex(ctx context.Context, id string) error {
+	result, err := p.DynamoDB.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String("orden-compra-read"),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {S: aws.String(id)},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get purchase order %s: %w", id, err)
+	}
+	if result.Item == nil {
+		return p.Client.Delete(ctx, id)
+	}
+
+	var purchaseOrder models.PurchaseOrder
+	if err := dynamodbattribute.UnmarshalMap(result.Item, &purchaseOrder); err != nil {
+		p.Logger.Printf("Failed to unmarshal purchase order %s, not indexed: %v", id, err)
+		return nil
+	}
+	return p.Client.Index(ctx, NewDocument(&purchaseOrder))
+}
+
+// Project reindexes the orders of events delivered by the event stream
+// consumer, in place of the scheduled sync
+func (p *Projector) Project(ctx context.Context, events []*models.EventSourcingEvent) error {
+	reindexed := make(map[string]bool, len(events))
+	for _, event := range events {
+		if reindexed[event.AggregateID] {
+			continue
+		}
+		if err := p.reindex(ctx, event.AggregateID); err != nil {
+			return err
+		}
+		reindexed[event.AggregateID] = true
+	}
+	return nil
+}
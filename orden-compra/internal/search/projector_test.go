@@ -0,0 +1,83 @@
+package search
+
+import (
+	"context"
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+
+	"orden-compra/internal/memory"
+	"orden-compra/internal/models"
+)
+
+func putItem(t *testing.T, dynamoDB *memory.DynamoDB, tableName string, v interface{}) {
+	t.Helper()
+	item, err := dynamodbattribute.MarshalMap(v)
+	if err != nil {
+		t.Fatalf("marshal %T: %v", v, err)
+	}
+	if _, err := dynamoDB.PutItemWithContext(context.Background(), &dynamodb.PutItemInput{TableName: aws.String(tableName), Item: item}); err != nil {
+		t.Fatalf("put %T: %v", v, err)
+	}
+}
+
+func TestProjectorReindexesChangedOrders(t *testing.T) {
+	ctx := context.Background()
+	client, cluster := startCluster(t)
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	projector := NewProjector(dynamoDB, client, time.Minute, log.New(io.Discard, "", 0))
+	at := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	purchaseOrder := models.NewPurchaseOrder("product-1", "Gloves", "supplier-1", "Acme", "warehouse-1", "HIGH", 10, at)
+	purchaseOrder.ASN = &models.AdvanceShipmentNotice{Lots: []models.ShipmentLot{{BatchNumber: "LOT-1"}, {BatchNumber: "LOT-2"}}}
+	putItem(t, dynamoDB, "orden-compra-read", purchaseOrder)
+	putItem(t, dynamoDB, "orden-compra-events", models.NewEventSourcingEvent(purchaseOrder.ID, "PurchaseOrderCreated", nil, nil, nil, at))
+	// An order no longer in the read model is removed from the index
+	cluster.documents["purchase-orders/_doc/po-gone"] = []byte(`{"id":"po-gone"}`)
+	putItem(t, dynamoDB, "orden-compra-events", models.NewEventSourcingEvent("po-gone", "PurchaseOrderCancelled", nil, nil, nil, at.Add(time.Minute)))
+
+	reindexed, err := projector.RunOnce(ctx)
+	if err != nil || reindexed != 2 {
+		t.Fatalf("reindexed %d orders, error %v", reindexed, err)
+	}
+	documents, _, err := client.Search(ctx, "", "gloves", 10)
+	if err != nil || len(documents) != 1 || documents[0].ID != purchaseOrder.ID {
+		t.Fatalf("indexed %v, error %v", documents, err)
+	}
+	if batches := documents[0].BatchNumbers; len(batches) != 2 || batches[1] != "LOT-2" {
+		t.Fatalf("batch numbers %v", batches)
+	}
+	if checkpoint, err := client.Checkpoint(ctx); err != nil || !checkpoint.Equal(at.Add(time.Minute)) {
+		t.Fatalf("checkpoint %v, error %v", checkpoint, err)
+	}
+
+	// Only events after the checkpoint, less the overlap, are read again
+	putItem(t, dynamoDB, "orden-compra-events", models.NewEventSourcingEvent("po-old", "PurchaseOrderCreated", nil, nil, nil, at.Add(-time.Hour)))
+	if reindexed, err := projector.RunOnce(ctx); err != nil || reindexed != 1 {
+		t.Fatalf("reindexed %d orders, error %v, want the one within the overlap", reindexed, err)
+	}
+}
+
+func TestProjectReindexesEachOrderOnce(t *testing.T) {
+	client, cluster := startCluster(t)
+	dynamoDB := memory.NewDynamoDB(memory.Tables)
+	projector := NewProjector(dynamoDB, client, time.Minute, log.New(io.Discard, "", 0))
+
+	purchaseOrder := models.NewPurchaseOrder("product-1", "Gloves", "supplier-1", "Acme", "warehouse-1", "HIGH", 10, time.Now())
+	putItem(t, dynamoDB, "orden-compra-read", purchaseOrder)
+	events := []*models.EventSourcingEvent{
+		models.NewEventSourcingEvent(purchaseOrder.ID, "PurchaseOrderCreated", nil, nil, nil, time.Now()),
+		models.NewEventSourcingEvent(purchaseOrder.ID, "PurchaseOrderStatusUpdated", nil, nil, nil, time.Now()),
+	}
+	if err := projector.Project(context.Background(), events); err != nil {
+		t.Fatalf("Project: %v", err)
+	}
+	if _, ok := cluster.documents["purchase-orders/_doc/"+purchaseOrder.ID]; !ok || len(cluster.documents) != 1 {
+		t.Fatalf("indexed %v", cluster.documents)
+	}
+}
@@ -0,0 +1,106 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/secretsmanager/secretsmanageriface"
+)
+
+// Provider names
+const (
+	ProviderSecretsManager = "aws-secrets-manager"
+	ProviderVault          = "vault"
+)
+
+// SecretsManagerProvider reads secrets from AWS Secrets Manager, by name or
+// ARN, in their current version
+type SecretsManagerProvider struct {
+	Client secretsmanageriface.SecretsManagerAPI
+}
+
+// Fetch returns the secret's string value
+func (p *SecretsManagerProvider) Fetch(ctx context.Context, name string) (string, error) {
+	result, err := p.Client.GetSecretValueWithContext(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(name),
+	})
+	if err != nil {
+		return "", err
+	}
+	if result.SecretString != nil {
+		return *result.SecretString, nil
+	}
+	return string(result.SecretBinary), nil
+}
+
+// VaultConfig represents the connection to Vault
+type VaultConfig struct {
+	Address string
+	Token   string
+	// Mount is the path of the KV version 2 secrets engine
+	Mount     string
+	Namespace string
+	Timeout   time.Duration
+}
+
+// VaultProvider reads secrets from a Vault KV version 2 engine. A secret's
+// data is returned as a JSON object of its keys.
+type VaultProvider struct {
+	Config VaultConfig
+	HTTP   *http.Client
+}
+
+// NewVaultProvider creates a provider on a Vault server
+func NewVaultProvider(config VaultConfig) *VaultProvider {
+	config.Address = strings.TrimRight(config.Address, "/")
+	if config.Mount == "" {
+		config.Mount = "secret"
+	}
+	return &VaultProvider{
+		Config: config,
+		HTTP:   &http.Client{Timeout: config.Timeout},
+	}
+}
+
+// Fetch returns the latest version of the secret's data
+func (p *VaultProvider) Fetch(ctx context.Context, name string) (string, error) {
+	url := p.Config.Address + "/v1/" + strings.Trim(p.Config.Mount, "/") + "/data/" + strings.TrimLeft(name, "/")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", p.Config.Token)
+	if p.Config.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", p.Config.Namespace)
+	}
+
+	resp, err := p.HTTP.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("vault returned %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var payload struct {
+		Data struct {
+			Data json.RawMessage `json:"data"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return "", fmt.Errorf("failed to decode vault response: %w", err)
+	}
+	if len(payload.Data.Data) == 0 || string(payload.Data.Data) == "null" {
+		return "", fmt.Errorf("vault secret %s has no data", name)
+	}
+	return string(payload.Data.Data), nil
+}
@@ -0,0 +1,77 @@
+package secrets
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/secretsmanager/secretsmanageriface"
+)
+
+type fakeSecretsManager struct {
+	secretsmanageriface.SecretsManagerAPI
+	output *secretsmanager.GetSecretValueOutput
+	input  *secretsmanager.GetSecretValueInput
+}
+
+func (f *fakeSecretsManager) GetSecretValueWithContext(ctx aws.Context, input *secretsmanager.GetSecretValueInput, opts ...request.Option) (*secretsmanager.GetSecretValueOutput, error) {
+	f.input = input
+	return f.output, nil
+}
+
+func TestSecretsManagerProvider(t *testing.T) {
+	client := &fakeSecretsManager{output: &secretsmanager.GetSecretValueOutput{SecretString: aws.String(`{"password":"s3cret"}`)}}
+	provider := &SecretsManagerProvider{Client: client}
+
+	if secret, err := provider.Fetch(context.Background(), "orden-compra/rabbitmq"); err != nil || secret != `{"password":"s3cret"}` || aws.StringValue(client.input.SecretId) != "orden-compra/rabbitmq" {
+		t.Fatalf("fetched %q, error %v", secret, err)
+	}
+
+	client.output = &secretsmanager.GetSecretValueOutput{SecretBinary: []byte("binary")}
+	if secret, err := provider.Fetch(context.Background(), "orden-compra/key"); err != nil || secret != "binary" {
+		t.Fatalf("fetched %q, error %v", secret, err)
+	}
+}
+
+func TestVaultProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "token-1" || r.Header.Get("X-Vault-Namespace") != "medisupply" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/kv/data/orden-compra/rabbitmq":
+			w.Write([]byte(`{"data":{"data":{"password":"s3cret"},"metadata":{"version":3}}}`))
+		case "/v1/kv/data/orden-compra/deleted":
+			w.Write([]byte(`{"data":{"data":null,"metadata":{"version":4}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors":[]}`))
+		}
+	}))
+	defer server.Close()
+
+	provider := NewVaultProvider(VaultConfig{Address: server.URL + "/", Token: "token-1", Mount: "/kv/", Namespace: "medisupply"})
+	if secret, err := provider.Fetch(context.Background(), "/orden-compra/rabbitmq"); err != nil || secret != `{"password":"s3cret"}` {
+		t.Fatalf("fetched %q, error %v", secret, err)
+	}
+	for _, name := range []string{"orden-compra/deleted", "orden-compra/missing"} {
+		if _, err := provider.Fetch(context.Background(), name); err == nil {
+			t.Fatalf("fetched %s", name)
+		}
+	}
+
+	provider.Config.Token = "expired"
+	if _, err := provider.Fetch(context.Background(), "orden-compra/rabbitmq"); err == nil || err.Error() != `vault returned 403: {"errors":["permission denied"]}` {
+		t.Fatalf("fetch with an expired token returned %v", err)
+	}
+
+	if mount := NewVaultProvider(VaultConfig{}).Config.Mount; mount != "secret" {
+		t.Fatalf("default mount %q, want secret", mount)
+	}
+}
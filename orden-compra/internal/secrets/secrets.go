@@ -0,0 +1,202 @@
+// Package secrets reads credentials and keys from a secret store, AWS Secrets
+// Manager or HashiCorp Vault, instead of plain environment variables. A
+// setting refers to a secret as secret:<name>#<key>; the reference is
+// resolved at startup and read again periodically, so rotated secrets reach
+// the settings that can change at runtime.
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ReferencePrefix marks a setting whose value is read from the secret store
+const ReferencePrefix = "secret:"
+
+var (
+	// ErrNotConfigured is returned when a reference is used without a provider
+	ErrNotConfigured = errors.New("no secrets provider is configured")
+	// ErrInvalidReference is returned for malformed references
+	ErrInvalidReference = errors.New("invalid secret reference")
+	// ErrKeyNotFound is returned when a secret has no value under the key
+	ErrKeyNotFound = errors.New("secret key not found")
+)
+
+// Provider reads the current value of a secret by name. Secrets holding
+// several values, such as a username and a password, are JSON objects.
+type Provider interface {
+	Fetch(ctx context.Context, name string) (string, error)
+}
+
+// IsReference reports whether a setting refers to a secret
+func IsReference(value string) bool {
+	return strings.HasPrefix(value, ReferencePrefix)
+}
+
+// parseReference splits secret:<name>#<key> into the secret name and key.
+// Without a key the reference is to the whole secret value.
+func parseReference(reference string) (string, string, error) {
+	name, key, _ := strings.Cut(strings.TrimPrefix(reference, ReferencePrefix), "#")
+	if name == "" {
+		return "", "", fmt.Errorf("%w: %q has no secret name", ErrInvalidReference, reference)
+	}
+	return name, key, nil
+}
+
+// watch is a setting kept up to date with its secret
+type watch struct {
+	reference string
+	value     string
+	apply     func(value string)
+}
+
+// Manager resolves references against a provider. Each secret is fetched
+// once and cached; Start refreshes the cache, so the provider is not called
+// per request. A nil manager resolves plain values only.
+type Manager struct {
+	Provider Provider
+	Interval time.Duration
+	Logger   *log.Logger
+
+	mu      sync.Mutex
+	values  map[string]string
+	watches []*watch
+}
+
+// NewManager creates a manager refreshing its secrets every interval
+func NewManager(provider Provider, interval time.Duration, logger *log.Logger) *Manager {
+	return &Manager{
+		Provider: provider,
+		Interval: interval,
+		Logger:   logger,
+		values:   make(map[string]string),
+	}
+}
+
+// Resolve returns a setting's value, reading it from the secret store when
+// the setting is a reference
+func (m *Manager) Resolve(ctx context.Context, value string) (string, error) {
+	if !IsReference(value) {
+		return value, nil
+	}
+	if m == nil {
+		return "", fmt.Errorf("%w: %s", ErrNotConfigured, value)
+	}
+	name, key, err := parseReference(value)
+	if err != nil {
+		return "", err
+	}
+
+	m.mu.Lock()
+	secret, ok := m.values[name]
+	m.mu.Unlock()
+	if !ok {
+		secret, err = m.Provider.Fetch(ctx, name)
+		if err != nil {
+			return "", fmt.Errorf("failed to read secret %s: %w", name, err)
+		}
+		m.mu.Lock()
+		m.values[name] = secret
+		m.mu.Unlock()
+	}
+	return extract(name, key, secret)
+}
+
+// Watch calls apply with the value of a reference whenever a refresh finds
+// it changed. The current value is expected to be in use already.
+func (m *Manager) Watch(ctx context.Context, reference string, apply func(value string)) error {
+	if m == nil || !IsReference(reference) {
+		return nil
+	}
+	value, err := m.Resolve(ctx, reference)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.watches = append(m.watches, &watch{reference: reference, value: value, apply: apply})
+	m.mu.Unlock()
+	return nil
+}
+
+// Start refreshes the secrets every interval until the context is done
+func (m *Manager) Start(ctx context.Context) {
+	if m == nil || m.Interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(m.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.RunOnce(ctx)
+		}
+	}
+}
+
+// RunOnce reads every cached secret again and applies the watched settings
+// that changed. A secret that cannot be read keeps its cached value.
+func (m *Manager) RunOnce(ctx context.Context) {
+	m.mu.Lock()
+	names := make([]string, 0, len(m.values))
+	for name := range m.values {
+		names = append(names, name)
+	}
+	m.mu.Unlock()
+
+	for _, name := range names {
+		secret, err := m.Provider.Fetch(ctx, name)
+		if err != nil {
+			m.Logger.Printf("Secret %s not refreshed, keeping the cached value: %v", name, err)
+			continue
+		}
+		m.mu.Lock()
+		m.values[name] = secret
+		m.mu.Unlock()
+	}
+
+	m.mu.Lock()
+	watches := append([]*watch(nil), m.watches...)
+	m.mu.Unlock()
+	for _, w := range watches {
+		value, err := m.Resolve(ctx, w.reference)
+		if err != nil {
+			m.Logger.Printf("Secret %s not applied: %v", w.reference, err)
+			continue
+		}
+		if value == w.value {
+			continue
+		}
+		w.apply(value)
+		w.value = value
+		m.Logger.Printf("Secret %s rotated", w.reference)
+	}
+}
+
+// extract picks a key out of a JSON object secret, or returns the whole
+// secret when no key is given
+func extract(name, key, secret string) (string, error) {
+	if key == "" {
+		return secret, nil
+	}
+	var values map[string]interface{}
+	if err := json.Unmarshal([]byte(secret), &values); err != nil {
+		return "", fmt.Errorf("%w: secret %s is not a JSON object", ErrInvalidReference, name)
+	}
+	value, ok := values[key]
+	if !ok {
+		return "", fmt.Errorf("%w: %s#%s", ErrKeyNotFound, name, key)
+	}
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	return fmt.Sprint(value), nil
+}
@@ -0,0 +1,127 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"sync"
+	"testing"
+)
+
+// fakeProvider serves secrets from a map and counts the fetches
+type fakeProvider struct {
+	mu      sync.Mutex
+	secrets map[string]string
+	err     error
+	fetches int
+}
+
+func (p *fakeProvider) Fetch(ctx context.Context, name string) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.fetches++
+	if p.err != nil {
+		return "", p.err
+	}
+	secret, ok := p.secrets[name]
+	if !ok {
+		return "", errors.New("secret not found")
+	}
+	return secret, nil
+}
+
+func (p *fakeProvider) set(name, secret string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.secrets[name] = secret
+}
+
+func TestResolve(t *testing.T) {
+	provider := &fakeProvider{secrets: map[string]string{
+		"rabbitmq":    `{"username":"orden-compra","password":"s3cret","port":5671}`,
+		"signing-key": "plain-value",
+	}}
+	manager := NewManager(provider, 0, log.New(io.Discard, "", 0))
+
+	for _, tc := range []struct {
+		value string
+		want  string
+		err   error
+	}{
+		{"not-a-reference", "not-a-reference", nil},
+		{"secret:rabbitmq#password", "s3cret", nil},
+		{"secret:rabbitmq#port", "5671", nil},
+		{"secret:signing-key", "plain-value", nil},
+		{"secret:rabbitmq#vhost", "", ErrKeyNotFound},
+		{"secret:signing-key#key", "", ErrInvalidReference},
+		{"secret:#password", "", ErrInvalidReference},
+	} {
+		t.Run(tc.value, func(t *testing.T) {
+			got, err := manager.Resolve(context.Background(), tc.value)
+			if !errors.Is(err, tc.err) || got != tc.want {
+				t.Fatalf("Resolve() = %q, %v, want %q, %v", got, err, tc.want, tc.err)
+			}
+		})
+	}
+
+	// Each secret is fetched once, however many keys are read from it
+	if provider.fetches != 2 {
+		t.Fatalf("fetched %d times, want once per secret", provider.fetches)
+	}
+	if _, err := manager.Resolve(context.Background(), "secret:missing"); err == nil {
+		t.Fatal("resolved a missing secret")
+	}
+
+	var unconfigured *Manager
+	if got, err := unconfigured.Resolve(context.Background(), "plain"); err != nil || got != "plain" {
+		t.Fatalf("nil manager resolved %q, %v", got, err)
+	}
+	if _, err := unconfigured.Resolve(context.Background(), "secret:rabbitmq#password"); !errors.Is(err, ErrNotConfigured) {
+		t.Fatalf("nil manager resolved a reference with %v", err)
+	}
+}
+
+func TestRunOnceAppliesRotatedSecrets(t *testing.T) {
+	ctx := context.Background()
+	provider := &fakeProvider{secrets: map[string]string{"api-keys": `{"erp":"key-1","portal":"key-2"}`}}
+	manager := NewManager(provider, 0, log.New(io.Discard, "", 0))
+
+	var applied []string
+	if err := manager.Watch(ctx, "secret:api-keys#erp", func(value string) { applied = append(applied, value) }); err != nil {
+		t.Fatalf("watch: %v", err)
+	}
+	if err := manager.Watch(ctx, "plain", func(string) { t.Fatal("a plain setting was applied") }); err != nil {
+		t.Fatalf("watch of a plain setting: %v", err)
+	}
+
+	// Only a changed value is applied
+	manager.RunOnce(ctx)
+	provider.set("api-keys", `{"erp":"key-1","portal":"key-3"}`)
+	manager.RunOnce(ctx)
+	if len(applied) != 0 {
+		t.Fatalf("applied %v while the watched key did not change", applied)
+	}
+	provider.set("api-keys", `{"erp":"key-4","portal":"key-3"}`)
+	manager.RunOnce(ctx)
+	if len(applied) != 1 || applied[0] != "key-4" {
+		t.Fatalf("applied %v, want the rotated key", applied)
+	}
+	if got, _ := manager.Resolve(ctx, "secret:api-keys#portal"); got != "key-3" {
+		t.Fatalf("resolved %q after a refresh, want key-3", got)
+	}
+
+	// A secret that cannot be read keeps its cached value
+	provider.err = errors.New("throttled")
+	manager.RunOnce(ctx)
+	if got, err := manager.Resolve(ctx, "secret:api-keys#erp"); err != nil || got != "key-4" {
+		t.Fatalf("resolved %q, %v after a failed refresh", got, err)
+	}
+	if len(applied) != 1 {
+		t.Fatalf("applied %v after a failed refresh", applied)
+	}
+
+	if err := manager.Watch(ctx, "secret:unknown", func(string) {}); err == nil {
+		t.Fatal("watched a secret that cannot be read")
+	}
+}
@@ -0,0 +1,222 @@
+// Package slo tracks how long purchase orders take through each stage of the
+// replenishment pipeline, from the StockBajo event reaching orden-compra to
+// Proveedor reporting the inventory received, so the release objectives
+// agreed per urgency level can be measured and proven.
+package slo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"orden-compra/internal/models"
+	"orden-compra/internal/tenant"
+)
+
+// Stages of the pipeline, in the order an order goes through them
+const (
+	// StageEventReceived is when the StockBajo event was taken off the queue
+	StageEventReceived = "event_received"
+	// StageOrderPersisted is when the purchase order was stored
+	StageOrderPersisted = "order_persisted"
+	// StageReceptionPublished is when the RecepcionProveedor event was
+	// published, releasing the order to Proveedor; orders awaiting approval
+	// reach it once approved
+	StageReceptionPublished = "reception_published"
+	// StageInventoryReceived is when Proveedor received the inventory
+	StageInventoryReceived = "inventory_received"
+)
+
+// Stages lists the stages in pipeline order
+var Stages = []string{StageEventReceived, StageOrderPersisted, StageReceptionPublished, StageInventoryReceived}
+
+// ErrInvalidConfig is returned for objectives or targets that cannot be met
+var ErrInvalidConfig = errors.New("invalid SLO configuration")
+
+// Config represents the SLO settings
+type Config struct {
+	// Objectives bounds the release time, from the event being received to
+	// the reception being published, by urgency level
+	Objectives map[string]time.Duration
+	// Target is the share of orders that must be released within their
+	// objective, such as 0.99
+	Target float64
+	// Retention is how long stage timings are kept before DynamoDB TTL
+	// removes them; zero keeps them
+	Retention time.Duration
+}
+
+// DefaultObjectives returns the release objectives agreed with the hospitals
+func DefaultObjectives() map[string]time.Duration {
+	return map[string]time.Duration{
+		"critical": 15 * time.Minute,
+		"high":     time.Hour,
+		"medium":   4 * time.Hour,
+		"low":      24 * time.Hour,
+	}
+}
+
+// Validate checks the objectives are positive and the target a share
+func (c Config) Validate() error {
+	for urgency, objective := range c.Objectives {
+		if !models.ValidUrgencyLevels[urgency] {
+			return fmt.Errorf("%w: unknown urgency level %q", ErrInvalidConfig, urgency)
+		}
+		if objective <= 0 {
+			return fmt.Errorf("%w: objective of %s must be positive", ErrInvalidConfig, urgency)
+		}
+	}
+	if c.Target <= 0 || c.Target > 1 {
+		return fmt.Errorf("%w: target must be above 0 and at most 1", ErrInvalidConfig)
+	}
+	return nil
+}
+
+// Timing holds when a purchase order reached each stage. Stages are only
+// ever set once, so redelivered messages keep the first time.
+type Timing struct {
+	PurchaseOrderID      string     `json:"purchase_order_id" dynamodbav:"id"`
+	TenantID             string     `json:"tenant_id,omitempty" dynamodbav:"tenant_id,omitempty"`
+	UrgencyLevel         string     `json:"urgency_level" dynamodbav:"urgency_level"`
+	EventReceivedAt      *time.Time `json:"event_received_at,omitempty" dynamodbav:"event_received_at,omitempty"`
+	OrderPersistedAt     *time.Time `json:"order_persisted_at,omitempty" dynamodbav:"order_persisted_at,omitempty"`
+	ReceptionPublishedAt *time.Time `json:"reception_published_at,omitempty" dynamodbav:"reception_published_at,omitempty"`
+	InventoryReceivedAt  *time.Time `json:"inventory_received_at,omitempty" dynamodbav:"inventory_received_at,omitempty"`
+	ExpiresAt            int64      `json:"-" dynamodbav:"expires_at,omitempty"`
+}
+
+// At returns when the order reached a stage, or nil when it has not yet
+func (t *Timing) At(stage string) *time.Time {
+	switch stage {
+	case StageEventReceived:
+		return t.EventReceivedAt
+	case StageOrderPersisted:
+		return t.OrderPersistedAt
+	case StageReceptionPublished:
+		return t.ReceptionPublishedAt
+	case StageInventoryReceived:
+		return t.InventoryReceivedAt
+	}
+	return nil
+}
+
+// Recorder stores stage timings in DynamoDB and records the time spent
+// between consecutive stages in the purchase_order_stage_duration_seconds
+// histogram. Failing to record is logged and never fails the pipeline.
+type Recorder struct {
+	DynamoDB  dynamodbiface.DynamoDBAPI
+	TableName string
+	Config    Config
+	Logger    *log.Logger
+
+	duration metric.Float64Histogram
+}
+
+// NewRecorder creates a recorder on the default stage timings table
+func NewRecorder(dynamoDB dynamodbiface.DynamoDBAPI, config Config, logger *log.Logger) *Recorder {
+	duration, _ := otel.Meter("orden-compra/slo").Float64Histogram(
+		"purchase_order_stage_duration_seconds",
+		metric.WithDescription("Time purchase orders took to reach a pipeline stage from the previous one, by stage reached and urgency"),
+		metric.WithUnit("s"),
+		// From sub-second hand-offs to deliveries taking weeks
+		metric.WithExplicitBucketBoundaries(0.05, 0.25, 1, 5, 30, 60, 300, 900, 1800, 3600, 4*3600, 24*3600, 3*24*3600, 7*24*3600, 14*24*3600, 30*24*3600),
+	)
+	return &Recorder{
+		DynamoDB:  dynamoDB,
+		TableName: "orden-compra-stage-timings",
+		Config:    config,
+		Logger:    logger,
+		duration:  duration,
+	}
+}
+
+// Record stores the times of the stages a purchase order reached, keeping
+// any stage already stored, and observes the duration of each stage reached
+// for the first time since the previous one
+func (r *Recorder) Record(ctx context.Context, purchaseOrderID, urgency string, stages map[string]time.Time) {
+	if r == nil || purchaseOrderID == "" || len(stages) == 0 {
+		return
+	}
+	tenantID, _ := tenant.FromContext(ctx)
+	now := time.Now().UTC()
+
+	update := "SET urgency_level = if_not_exists(urgency_level, :urgency)"
+	names := map[string]*string{}
+	values := map[string]*dynamodb.AttributeValue{
+		":urgency": {S: aws.String(urgency)},
+	}
+	if tenantID != "" {
+		update += ", tenant_id = if_not_exists(tenant_id, :tenant)"
+		values[":tenant"] = &dynamodb.AttributeValue{S: aws.String(tenantID)}
+	}
+	if expiresAt := models.ExpiresAt(now, r.Config.Retention); expiresAt > 0 {
+		update += ", #expires_at = if_not_exists(#expires_at, :expires_at)"
+		names["#expires_at"] = aws.String(models.ExpiresAtAttribute)
+		values[":expires_at"] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(expiresAt, 10))}
+	}
+	for i, stage := range Stages {
+		at, ok := stages[stage]
+		if !ok {
+			continue
+		}
+		update += fmt.Sprintf(", #s%d = if_not_exists(#s%d, :s%d)", i, i, i)
+		names[fmt.Sprintf("#s%d", i)] = aws.String(stage + "_at")
+		values[fmt.Sprintf(":s%d", i)] = &dynamodb.AttributeValue{S: aws.String(at.UTC().Format(time.RFC3339Nano))}
+	}
+
+	result, err := r.DynamoDB.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(r.TableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {S: aws.String(purchaseOrderID)},
+		},
+		UpdateExpression:          aws.String(update),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+		ReturnValues:              aws.String(dynamodb.ReturnValueAllNew),
+	})
+	if err != nil {
+		r.Logger.Printf("Stage timing not recorded - purchase_order_id: %s, error: %v", purchaseOrderID, err)
+		return
+	}
+
+	var timing Timing
+	if err := dynamodbattribute.UnmarshalMap(result.Attributes, &timing); err != nil {
+		r.Logger.Printf("Stage timing not recorded - purchase_order_id: %s, error: %v", purchaseOrderID, err)
+		return
+	}
+	if r.duration == nil {
+		return
+	}
+	for i := 1; i < len(Stages); i++ {
+		at, ok := stages[Stages[i]]
+		reached, previous := timing.At(Stages[i]), timing.At(Stages[i-1])
+		// Stages stored by an earlier delivery were observed then
+		if !ok || reached == nil || previous == nil || !reached.Equal(at.UTC()) {
+			continue
+		}
+		r.duration.Record(ctx, stageDuration(*previous, *reached).Seconds(), metric.WithAttributes(
+			attribute.String("stage", Stages[i]),
+			attribute.String("urgency", timing.UrgencyLevel),
+		))
+	}
+}
+
+// stageDuration returns the time from one stage to the next; clocks of other
+// services may run behind ours, which must not give negative durations
+func stageDuration(from, to time.Time) time.Duration {
+	if to.Before(from) {
+		return 0
+	}
+	return to.Sub(from)
+}
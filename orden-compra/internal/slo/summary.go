@@ -0,0 +1,170 @@
+package slo
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+
+	"orden-compra/internal/tenant"
+)
+
+// StageSummary describes the durations of orders reaching one stage from
+// the previous one, in seconds
+type StageSummary struct {
+	Count int     `json:"count"`
+	P50   float64 `json:"p50_seconds"`
+	P95   float64 `json:"p95_seconds"`
+	P99   float64 `json:"p99_seconds"`
+	Max   float64 `json:"max_seconds"`
+}
+
+// ReleaseSummary compares the release time of orders with their objective
+type ReleaseSummary struct {
+	ObjectiveSeconds float64 `json:"objective_seconds"`
+	// Released counts the orders whose reception was published; those still
+	// awaiting approval or publication are Unreleased and not judged yet
+	Released        int          `json:"released"`
+	Unreleased      int          `json:"unreleased"`
+	WithinObjective int          `json:"within_objective"`
+	Compliance      float64      `json:"compliance"`
+	Met             bool         `json:"met"`
+	Durations       StageSummary `json:"durations"`
+}
+
+// UrgencySummary is the summary of the orders of one urgency level
+type UrgencySummary struct {
+	Orders  int                     `json:"orders"`
+	Stages  map[string]StageSummary `json:"stages"`
+	Release *ReleaseSummary         `json:"release,omitempty"`
+}
+
+// Summary reads the timings of the orders whose StockBajo event was received
+// within the range, for the tenant of ctx or across tenants, and summarizes
+// the time each stage took by urgency level along with the share of orders
+// released within their objective
+func (r *Recorder) Summary(ctx context.Context, from, to time.Time) (map[string]interface{}, error) {
+	scanInput := &dynamodb.ScanInput{
+		TableName:                aws.String(r.TableName),
+		FilterExpression:         aws.String("#received BETWEEN :from AND :to"),
+		ExpressionAttributeNames: map[string]*string{"#received": aws.String(StageEventReceived + "_at")},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":from": {S: aws.String(from.UTC().Format(time.RFC3339Nano))},
+			":to":   {S: aws.String(to.UTC().Format(time.RFC3339Nano))},
+		},
+	}
+	if tenantID, ok := tenant.FromContext(ctx); ok {
+		scanInput.FilterExpression = aws.String(aws.StringValue(scanInput.FilterExpression) + " AND tenant_id = :tenant")
+		scanInput.ExpressionAttributeValues[":tenant"] = &dynamodb.AttributeValue{S: aws.String(tenantID)}
+	}
+
+	durations := make(map[string]map[string][]time.Duration)
+	releases := make(map[string][]time.Duration)
+	orders := make(map[string]int)
+	unreleased := make(map[string]int)
+	for {
+		result, err := r.DynamoDB.ScanWithContext(ctx, scanInput)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan stage timings: %w", err)
+		}
+
+		for _, item := range result.Items {
+			var timing Timing
+			if err := dynamodbattribute.UnmarshalMap(item, &timing); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal stage timing: %w", err)
+			}
+			urgency := timing.UrgencyLevel
+			orders[urgency]++
+			if durations[urgency] == nil {
+				durations[urgency] = make(map[string][]time.Duration)
+			}
+			for i := 1; i < len(Stages); i++ {
+				if reached, previous := timing.At(Stages[i]), timing.At(Stages[i-1]); reached != nil && previous != nil {
+					durations[urgency][Stages[i]] = append(durations[urgency][Stages[i]], stageDuration(*previous, *reached))
+				}
+			}
+			if timing.ReceptionPublishedAt == nil {
+				unreleased[urgency]++
+				continue
+			}
+			releases[urgency] = append(releases[urgency], stageDuration(*timing.EventReceivedAt, *timing.ReceptionPublishedAt))
+		}
+
+		if len(result.LastEvaluatedKey) == 0 {
+			break
+		}
+		scanInput.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+
+	summaries := make(map[string]*UrgencySummary, len(orders))
+	allMet := true
+	for urgency, count := range orders {
+		summary := &UrgencySummary{Orders: count, Stages: make(map[string]StageSummary)}
+		for stage, values := range durations[urgency] {
+			summary.Stages[stage] = summarize(values)
+		}
+		if objective, ok := r.Config.Objectives[urgency]; ok {
+			release := &ReleaseSummary{
+				ObjectiveSeconds: objective.Seconds(),
+				Released:         len(releases[urgency]),
+				Unreleased:       unreleased[urgency],
+				Durations:        summarize(releases[urgency]),
+				Compliance:       1,
+			}
+			for _, duration := range releases[urgency] {
+				if duration <= objective {
+					release.WithinObjective++
+				}
+			}
+			if release.Released > 0 {
+				release.Compliance = float64(release.WithinObjective) / float64(release.Released)
+			}
+			release.Met = release.Compliance >= r.Config.Target
+			allMet = allMet && release.Met
+			summary.Release = release
+		}
+		summaries[urgency] = summary
+	}
+
+	objectives := make(map[string]float64, len(r.Config.Objectives))
+	for urgency, objective := range r.Config.Objectives {
+		objectives[urgency] = objective.Seconds()
+	}
+	return map[string]interface{}{
+		"success":            true,
+		"from":               from.UTC().Format(time.RFC3339),
+		"to":                 to.UTC().Format(time.RFC3339),
+		"target":             r.Config.Target,
+		"objectives_seconds": objectives,
+		"met":                allMet,
+		"urgency_levels":     summaries,
+	}, nil
+}
+
+// summarize computes the nearest-rank percentiles of durations
+func summarize(durations []time.Duration) StageSummary {
+	if len(durations) == 0 {
+		return StageSummary{}
+	}
+	sorted := append([]time.Duration(nil), durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	percentile := func(p float64) float64 {
+		rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+		if rank < 0 {
+			rank = 0
+		}
+		return sorted[rank].Seconds()
+	}
+	return StageSummary{
+		Count: len(sorted),
+		P50:   percentile(0.50),
+		P95:   percentile(0.95),
+		P99:   percentile(0.99),
+		Max:   sorted[len(sorted)-1].Seconds(),
+	}
+}
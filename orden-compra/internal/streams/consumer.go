@@ -0,0 +1,397 @@
+// Package streams consumes the DynamoDB stream of the event store and hands
+// every stored event to the projections, so the search index, statistics
+// and EventBridge mirror follow the store without the command path or
+// scheduled table scans updating them.
+package streams
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/aws/aws-sdk-go/service/dynamodbstreams"
+	"github.com/aws/aws-sdk-go/service/dynamodbstreams/dynamodbstreamsiface"
+
+	"orden-compra/internal/models"
+)
+
+const (
+	eventsTable      = "orden-compra-events"
+	checkpointsTable = "orden-compra-checkpoints"
+	// checkpointPrefix prefixes the checkpoint ID of every shard
+	checkpointPrefix = "dynamodb-stream#"
+)
+
+// Projection is fed the events of each batch of stream records, oldest
+// first within a shard. Records are delivered at least once: a batch is
+// delivered again when any projection fails it.
+type Projection interface {
+	Project(ctx context.Context, events []*models.EventSourcingEvent) error
+}
+
+// ProjectionFunc adapts a function to a Projection
+type ProjectionFunc func(ctx context.Context, events []*models.EventSourcingEvent) error
+
+// Project calls f
+func (f ProjectionFunc) Project(ctx context.Context, events []*models.EventSourcingEvent) error {
+	return f(ctx, events)
+}
+
+// Config represents the stream consumer settings
+type Config struct {
+	// StreamARN is the event store's stream; empty, the table's latest
+	// stream is used
+	StreamARN    string
+	PollInterval time.Duration
+	// BatchSize is the most records read from a shard at once, at most 1000
+	BatchSize int64
+}
+
+// checkpoint is how far a shard has been consumed
+type checkpoint struct {
+	ID             string    `dynamodbav:"id"`
+	SequenceNumber string    `dynamodbav:"sequence_number"`
+	Closed         bool      `dynamodbav:"closed"`
+	UpdatedAt      time.Time `dynamodbav:"updated_at"`
+}
+
+// Consumer reads the event store's stream shard by shard and feeds the
+// projections, checkpointing each shard after every batch
+type Consumer struct {
+	DynamoDB    dynamodbiface.DynamoDBAPI
+	Streams     dynamodbstreamsiface.DynamoDBStreamsAPI
+	Projections map[string]Projection
+	Config      Config
+	Logger      *log.Logger
+
+	streamARN string
+	// iterators are the shard iterators to continue from on the next run.
+	// An open shard may return empty pages before its next records, so the
+	// iterator is followed across runs rather than started again each time.
+	iterators map[string]*string
+}
+
+// NewConsumer creates a new stream consumer feeding the named projections
+func NewConsumer(dynamoDB dynamodbiface.DynamoDBAPI, streams dynamodbstreamsiface.DynamoDBStreamsAPI, projections map[string]Projection, config Config, logger *log.Logger) *Consumer {
+	return &Consumer{
+		DynamoDB:    dynamoDB,
+		Streams:     streams,
+		Projections: projections,
+		Config:      config,
+		Logger:      logger,
+		streamARN:   config.StreamARN,
+		iterators:   make(map[string]*string),
+	}
+}
+
+// Start consumes the stream every poll interval until the context is cancelled
+func (c *Consumer) Start(ctx context.Context) {
+	names := make([]string, 0, len(c.Projections))
+	for name := range c.Projections {
+		names = append(names, name)
+	}
+	c.Logger.Printf("Starting event stream consumer - projections: %s, poll_interval: %v", strings.Join(names, ", "), c.Config.PollInterval)
+
+	// Another replica may have moved the checkpoints while this one was not
+	// the leader
+	c.iterators = make(map[string]*string)
+
+	ticker := time.NewTicker(c.Config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := c.RunOnce(ctx); err != nil {
+			c.Logger.Printf("Event stream consumption failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			c.Logger.Println("Event stream consumer stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce reads every shard up to its latest record and returns the number
+// of events projected. A child shard is read once its parent is closed, so
+// the events of an item are projected in the order they were stored.
+func (c *Consumer) RunOnce(ctx context.Context) (int, error) {
+	arn, err := c.arn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	shards, err := c.shards(ctx, arn)
+	if err != nil {
+		return 0, err
+	}
+
+	listed := make(map[string]bool, len(shards))
+	for _, shard := range shards {
+		listed[aws.StringValue(shard.ShardId)] = true
+	}
+	checkpoints, err := c.checkpoints(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	projected := 0
+	for _, shard := range shards {
+		shardID := aws.StringValue(shard.ShardId)
+		if parent := aws.StringValue(shard.ParentShardId); parent != "" && listed[parent] {
+			if cp, ok := checkpoints[parent]; !ok || !cp.Closed {
+				continue
+			}
+		}
+		cp := checkpoints[shardID]
+		if cp == nil {
+			cp = &checkpoint{ID: checkpointPrefix + shardID}
+		}
+		if cp.Closed {
+			continue
+		}
+
+		n, err := c.consume(ctx, arn, shardID, cp)
+		projected += n
+		if err != nil {
+			return projected, fmt.Errorf("shard %s: %w", shardID, err)
+		}
+		checkpoints[shardID] = cp
+	}
+
+	// Shards past the stream's retention are no longer listed
+	for shardID, cp := range checkpoints {
+		if listed[shardID] {
+			continue
+		}
+		delete(c.iterators, shardID)
+		if _, err := c.DynamoDB.DeleteItemWithContext(ctx, &dynamodb.DeleteItemInput{
+			TableName: aws.String(checkpointsTable),
+			Key: map[string]*dynamodb.AttributeValue{
+				"id": {S: aws.String(cp.ID)},
+			},
+		}); err != nil {
+			c.Logger.Printf("Failed to delete checkpoint of expired shard %s: %v", shardID, err)
+		}
+	}
+
+	if projected > 0 {
+		c.Logger.Printf("Event stream consumed - events: %d, shards: %d", projected, len(shards))
+	}
+	return projected, nil
+}
+
+// consume reads a shard from where the last run stopped, or from its
+// checkpoint, until it has no more records, projecting and checkpointing
+// every batch
+func (c *Consumer) consume(ctx context.Context, arn, shardID string, cp *checkpoint) (int, error) {
+	iterator, ok := c.iterators[shardID]
+	delete(c.iterators, shardID)
+	if ok {
+		return c.read(ctx, shardID, iterator, cp)
+	}
+
+	iterator, err := c.iterator(ctx, arn, shardID, cp.SequenceNumber)
+	if err != nil {
+		var awsErr awserr.Error
+		if !errors.As(err, &awsErr) || awsErr.Code() != dynamodbstreams.ErrCodeTrimmedDataAccessException {
+			return 0, err
+		}
+		// The checkpoint is older than the stream's retention; the records in
+		// between are lost to the projections until they are rebuilt
+		c.Logger.Printf("WARN: Event stream shard %s trimmed past its checkpoint %s, resuming from the oldest record; recompute the statistics", shardID, cp.SequenceNumber)
+		if iterator, err = c.iterator(ctx, arn, shardID, ""); err != nil {
+			return 0, err
+		}
+	}
+	return c.read(ctx, shardID, iterator, cp)
+}
+
+// read follows a shard iterator until the shard has no more records,
+// keeping the iterator for the next run. On failure the iterator is
+// dropped, so the next run starts again after the checkpoint.
+func (c *Consumer) read(ctx context.Context, shardID string, iterator *string, cp *checkpoint) (int, error) {
+	projected := 0
+	for iterator != nil {
+		result, err := c.Streams.GetRecordsWithContext(ctx, &dynamodbstreams.GetRecordsInput{
+			ShardIterator: iterator,
+			Limit:         aws.Int64(c.Config.BatchSize),
+		})
+		if err != nil {
+			var awsErr awserr.Error
+			if errors.As(err, &awsErr) && awsErr.Code() == dynamodbstreams.ErrCodeExpiredIteratorException {
+				// The next run starts a new iterator from the checkpoint
+				return projected, nil
+			}
+			return projected, fmt.Errorf("failed to get records: %w", err)
+		}
+
+		if len(result.Records) > 0 {
+			events := c.events(result.Records)
+			for name, projection := range c.Projections {
+				if err := projection.Project(ctx, events); err != nil {
+					return projected, fmt.Errorf("projection %s failed: %w", name, err)
+				}
+			}
+			projected += len(events)
+			cp.SequenceNumber = aws.StringValue(result.Records[len(result.Records)-1].Dynamodb.SequenceNumber)
+		}
+
+		// A nil iterator means the shard is closed and fully read
+		cp.Closed = result.NextShardIterator == nil
+		if len(result.Records) > 0 || cp.Closed {
+			if err := c.saveCheckpoint(ctx, cp); err != nil {
+				return projected, err
+			}
+		}
+		iterator = result.NextShardIterator
+		if len(result.Records) == 0 {
+			break
+		}
+	}
+	if iterator != nil {
+		c.iterators[shardID] = iterator
+	}
+	return projected, nil
+}
+
+// events decodes the events stored by the records. Only inserts store
+// events; events restored from the archive were projected when first stored.
+func (c *Consumer) events(records []*dynamodbstreams.Record) []*models.EventSourcingEvent {
+	events := make([]*models.EventSourcingEvent, 0, len(records))
+	for _, record := range records {
+		if aws.StringValue(record.EventName) != dynamodbstreams.OperationTypeInsert || record.Dynamodb == nil {
+			continue
+		}
+		image := record.Dynamodb.NewImage
+		if _, ok := image["restored_at"]; ok {
+			continue
+		}
+
+		var event models.EventSourcingEvent
+		if err := dynamodbattribute.UnmarshalMap(image, &event); err != nil {
+			c.Logger.Printf("Failed to unmarshal stream record %s, not projected: %v", aws.StringValue(record.EventID), err)
+			continue
+		}
+		if err := models.EventSchemas.Upcast(&event); err != nil {
+			c.Logger.Printf("Failed to upcast stream record %s, not projected: %v", aws.StringValue(record.EventID), err)
+			continue
+		}
+		events = append(events, &event)
+	}
+	return events
+}
+
+// arn returns the stream of the event store, looking it up on first use
+func (c *Consumer) arn(ctx context.Context) (string, error) {
+	if c.streamARN != "" {
+		return c.streamARN, nil
+	}
+
+	result, err := c.DynamoDB.DescribeTableWithContext(ctx, &dynamodb.DescribeTableInput{
+		TableName: aws.String(eventsTable),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to describe %s: %w", eventsTable, err)
+	}
+	if result.Table == nil || aws.StringValue(result.Table.LatestStreamArn) == "" {
+		return "", fmt.Errorf("%s has no stream enabled", eventsTable)
+	}
+	c.streamARN = aws.StringValue(result.Table.LatestStreamArn)
+	return c.streamARN, nil
+}
+
+// shards lists every shard of the stream, parents before their children
+func (c *Consumer) shards(ctx context.Context, arn string) ([]*dynamodbstreams.Shard, error) {
+	input := &dynamodbstreams.DescribeStreamInput{StreamArn: aws.String(arn)}
+	var shards []*dynamodbstreams.Shard
+	for {
+		result, err := c.Streams.DescribeStreamWithContext(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe stream: %w", err)
+		}
+		shards = append(shards, result.StreamDescription.Shards...)
+
+		if result.StreamDescription.LastEvaluatedShardId == nil {
+			break
+		}
+		input.ExclusiveStartShardId = result.StreamDescription.LastEvaluatedShardId
+	}
+	return shards, nil
+}
+
+// iterator returns a shard iterator after the sequence number, or at the
+// oldest record when there is none
+func (c *Consumer) iterator(ctx context.Context, arn, shardID, sequenceNumber string) (*string, error) {
+	input := &dynamodbstreams.GetShardIteratorInput{
+		StreamArn:         aws.String(arn),
+		ShardId:           aws.String(shardID),
+		ShardIteratorType: aws.String(dynamodbstreams.ShardIteratorTypeTrimHorizon),
+	}
+	if sequenceNumber != "" {
+		input.ShardIteratorType = aws.String(dynamodbstreams.ShardIteratorTypeAfterSequenceNumber)
+		input.SequenceNumber = aws.String(sequenceNumber)
+	}
+
+	result, err := c.Streams.GetShardIteratorWithContext(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get shard iterator: %w", err)
+	}
+	return result.ShardIterator, nil
+}
+
+// checkpoints returns the checkpoints of every shard by shard ID
+func (c *Consumer) checkpoints(ctx context.Context) (map[string]*checkpoint, error) {
+	scanInput := &dynamodb.ScanInput{
+		TableName:                 aws.String(checkpointsTable),
+		FilterExpression:          aws.String("begins_with(id, :prefix)"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":prefix": {S: aws.String(checkpointPrefix)}},
+		ConsistentRead:            aws.Bool(true),
+	}
+
+	checkpoints := make(map[string]*checkpoint)
+	for {
+		result, err := c.DynamoDB.ScanWithContext(ctx, scanInput)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan stream checkpoints: %w", err)
+		}
+
+		for _, item := range result.Items {
+			var cp checkpoint
+			if err := dynamodbattribute.UnmarshalMap(item, &cp); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal stream checkpoint: %w", err)
+			}
+			checkpoints[strings.TrimPrefix(cp.ID, checkpointPrefix)] = &cp
+		}
+
+		if len(result.LastEvaluatedKey) == 0 {
+			break
+		}
+		scanInput.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+	return checkpoints, nil
+}
+
+// saveCheckpoint records how far a shard has been consumed
+func (c *Consumer) saveCheckpoint(ctx context.Context, cp *checkpoint) error {
+	cp.UpdatedAt = time.Now().UTC()
+	item, err := dynamodbattribute.MarshalMap(cp)
+	if err != nil {
+		return fmt.Errorf("failed to marshal stream checkpoint: %w", err)
+	}
+	if _, err := c.DynamoDB.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(checkpointsTable),
+		Item:      item,
+	}); err != nil {
+		return fmt.Errorf("failed to save stream checkpoint: %w", err)
+	}
+	return nil
+}
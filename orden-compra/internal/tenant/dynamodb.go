@@ -0,0 +1,342 @@
+package tenant
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// Expression placeholders used for the tenant condition, chosen so they do
+// not collide with the caller's own placeholders
+const (
+	attributeName  = "#tenant_scope"
+	attributeValue = ":tenant_scope"
+)
+
+var (
+	// ErrCrossTenantWrite is returned when an item names a tenant other than the context's
+	ErrCrossTenantWrite = errors.New("item belongs to another tenant")
+	// ErrUnsupportedOperation is returned for batch operations on tenant tables
+	ErrUnsupportedOperation = errors.New("operation is not tenant-isolated")
+)
+
+// IsolatedDynamoDB wraps a DynamoDB client so every call made in a tenant
+// context only sees and writes that tenant's items: writes are stamped with
+// tenant_id, reads of other tenants' items come back empty, scans and queries
+// are filtered and deletes are conditional on the owner. Calls without a
+// tenant in the context work across tenants; items they read that predate
+// multi-tenancy are attributed to the default tenant.
+type IsolatedDynamoDB struct {
+	dynamodbiface.DynamoDBAPI
+	Policy Policy
+	// Scoped lists the tables whose items belong to one tenant
+	Scoped map[string]bool
+	// Partitioned maps catalog tables keyed by business IDs to their hash
+	// key, which is prefixed with the tenant so tenants may reuse IDs
+	Partitioned map[string]string
+}
+
+// NewIsolatedDynamoDB creates a new IsolatedDynamoDB
+func NewIsolatedDynamoDB(client dynamodbiface.DynamoDBAPI, policy Policy, scoped []string, partitioned map[string]string) *IsolatedDynamoDB {
+	d := &IsolatedDynamoDB{
+		DynamoDBAPI: client,
+		Policy:      policy,
+		Scoped:      make(map[string]bool, len(scoped)),
+		Partitioned: partitioned,
+	}
+	for _, table := range scoped {
+		d.Scoped[table] = true
+	}
+	return d
+}
+
+// isolated reports whether the table holds tenant-owned items
+func (d *IsolatedDynamoDB) isolated(table *string) bool {
+	name := aws.StringValue(table)
+	_, partitioned := d.Partitioned[name]
+	return d.Scoped[name] || partitioned
+}
+
+// scope returns the tenant of ctx when the table is isolated
+func (d *IsolatedDynamoDB) scope(ctx aws.Context, table *string) (string, bool) {
+	tenantID, ok := FromContext(ctx)
+	if !ok || !d.isolated(table) {
+		return "", false
+	}
+	return tenantID, true
+}
+
+// GetItemWithContext gets an item, hiding it when another tenant owns it
+func (d *IsolatedDynamoDB) GetItemWithContext(ctx aws.Context, input *dynamodb.GetItemInput, opts ...request.Option) (*dynamodb.GetItemOutput, error) {
+	tenantID, ok := d.scope(ctx, input.TableName)
+	if !ok {
+		output, err := d.DynamoDBAPI.GetItemWithContext(ctx, input, opts...)
+		if err == nil && output.Item != nil && d.isolated(input.TableName) {
+			d.backfill(output.Item)
+		}
+		return output, err
+	}
+
+	scoped := *input
+	scoped.Key = d.partitionItem(tenantID, input.TableName, input.Key)
+	output, err := d.DynamoDBAPI.GetItemWithContext(ctx, &scoped, opts...)
+	if err != nil || output.Item == nil {
+		return output, err
+	}
+
+	if !d.owns(tenantID, output.Item) {
+		return &dynamodb.GetItemOutput{}, nil
+	}
+	d.backfill(output.Item)
+	output.Item = d.unpartitionItem(tenantID, input.TableName, output.Item)
+	return output, nil
+}
+
+// PutItemWithContext stamps the item with the tenant before writing it
+func (d *IsolatedDynamoDB) PutItemWithContext(ctx aws.Context, input *dynamodb.PutItemInput, opts ...request.Option) (*dynamodb.PutItemOutput, error) {
+	tenantID, ok := d.scope(ctx, input.TableName)
+	if !ok {
+		return d.DynamoDBAPI.PutItemWithContext(ctx, input, opts...)
+	}
+
+	if owner, ok := input.Item[Attribute]; ok && aws.StringValue(owner.S) != "" && aws.StringValue(owner.S) != tenantID {
+		return nil, fmt.Errorf("%w: %s", ErrCrossTenantWrite, aws.StringValue(owner.S))
+	}
+
+	scoped := *input
+	scoped.Item = d.partitionItem(tenantID, input.TableName, input.Item)
+	scoped.Item[Attribute] = &dynamodb.AttributeValue{S: aws.String(tenantID)}
+	return d.DynamoDBAPI.PutItemWithContext(ctx, &scoped, opts...)
+}
+
+// UpdateItemWithContext updates an item only when the tenant owns it
+func (d *IsolatedDynamoDB) UpdateItemWithContext(ctx aws.Context, input *dynamodb.UpdateItemInput, opts ...request.Option) (*dynamodb.UpdateItemOutput, error) {
+	tenantID, ok := d.scope(ctx, input.TableName)
+	if !ok {
+		return d.DynamoDBAPI.UpdateItemWithContext(ctx, input, opts...)
+	}
+
+	scoped := *input
+	scoped.Key = d.partitionItem(tenantID, input.TableName, input.Key)
+	scoped.ConditionExpression, scoped.ExpressionAttributeNames, scoped.ExpressionAttributeValues = d.condition(tenantID, input.ConditionExpression, input.ExpressionAttributeNames, input.ExpressionAttributeValues)
+	return d.DynamoDBAPI.UpdateItemWithContext(ctx, &scoped, opts...)
+}
+
+// DeleteItemWithContext deletes an item only when the tenant owns it
+func (d *IsolatedDynamoDB) DeleteItemWithContext(ctx aws.Context, input *dynamodb.DeleteItemInput, opts ...request.Option) (*dynamodb.DeleteItemOutput, error) {
+	tenantID, ok := d.scope(ctx, input.TableName)
+	if !ok {
+		return d.DynamoDBAPI.DeleteItemWithContext(ctx, input, opts...)
+	}
+
+	scoped := *input
+	scoped.Key = d.partitionItem(tenantID, input.TableName, input.Key)
+	scoped.ConditionExpression, scoped.ExpressionAttributeNames, scoped.ExpressionAttributeValues = d.condition(tenantID, input.ConditionExpression, input.ExpressionAttributeNames, input.ExpressionAttributeValues)
+	return d.DynamoDBAPI.DeleteItemWithContext(ctx, &scoped, opts...)
+}
+
+// QueryWithContext runs a query filtered to the tenant's items
+func (d *IsolatedDynamoDB) QueryWithContext(ctx aws.Context, input *dynamodb.QueryInput, opts ...request.Option) (*dynamodb.QueryOutput, error) {
+	tenantID, ok := d.scope(ctx, input.TableName)
+	if !ok {
+		output, err := d.DynamoDBAPI.QueryWithContext(ctx, input, opts...)
+		if err == nil && d.isolated(input.TableName) {
+			for _, item := range output.Items {
+				d.backfill(item)
+			}
+		}
+		return output, err
+	}
+
+	scoped := *input
+	scoped.FilterExpression, scoped.ExpressionAttributeNames, scoped.ExpressionAttributeValues = d.condition(tenantID, input.FilterExpression, input.ExpressionAttributeNames, input.ExpressionAttributeValues)
+	output, err := d.DynamoDBAPI.QueryWithContext(ctx, &scoped, opts...)
+	if err != nil {
+		return output, err
+	}
+	for i, item := range output.Items {
+		d.backfill(item)
+		output.Items[i] = d.unpartitionItem(tenantID, input.TableName, item)
+	}
+	return output, nil
+}
+
+// ScanWithContext runs a scan filtered to the tenant's items
+func (d *IsolatedDynamoDB) ScanWithContext(ctx aws.Context, input *dynamodb.ScanInput, opts ...request.Option) (*dynamodb.ScanOutput, error) {
+	tenantID, ok := d.scope(ctx, input.TableName)
+	if !ok {
+		output, err := d.DynamoDBAPI.ScanWithContext(ctx, input, opts...)
+		if err == nil && d.isolated(input.TableName) {
+			for _, item := range output.Items {
+				d.backfill(item)
+			}
+		}
+		return output, err
+	}
+
+	scoped := *input
+	scoped.FilterExpression, scoped.ExpressionAttributeNames, scoped.ExpressionAttributeValues = d.condition(tenantID, input.FilterExpression, input.ExpressionAttributeNames, input.ExpressionAttributeValues)
+	output, err := d.DynamoDBAPI.ScanWithContext(ctx, &scoped, opts...)
+	if err != nil {
+		return output, err
+	}
+	for i, item := range output.Items {
+		d.backfill(item)
+		output.Items[i] = d.unpartitionItem(tenantID, input.TableName, item)
+	}
+	return output, nil
+}
+
+// BatchWriteItemWithContext refuses to write tenant tables in a tenant context
+func (d *IsolatedDynamoDB) BatchWriteItemWithContext(ctx aws.Context, input *dynamodb.BatchWriteItemInput, opts ...request.Option) (*dynamodb.BatchWriteItemOutput, error) {
+	for table := range input.RequestItems {
+		if _, ok := d.scope(ctx, aws.String(table)); ok {
+			return nil, fmt.Errorf("%w: batch write to %s", ErrUnsupportedOperation, table)
+		}
+	}
+	return d.DynamoDBAPI.BatchWriteItemWithContext(ctx, input, opts...)
+}
+
+// BatchGetItemWithContext refuses to read tenant tables in a tenant context
+func (d *IsolatedDynamoDB) BatchGetItemWithContext(ctx aws.Context, input *dynamodb.BatchGetItemInput, opts ...request.Option) (*dynamodb.BatchGetItemOutput, error) {
+	for table := range input.RequestItems {
+		if _, ok := d.scope(ctx, aws.String(table)); ok {
+			return nil, fmt.Errorf("%w: batch get from %s", ErrUnsupportedOperation, table)
+		}
+	}
+	return d.DynamoDBAPI.BatchGetItemWithContext(ctx, input, opts...)
+}
+
+// TransactWriteItemsWithContext scopes every item of a transaction like the
+// single-item writes: puts are stamped with the tenant, and updates, deletes
+// and condition checks only apply to the tenant's items
+func (d *IsolatedDynamoDB) TransactWriteItemsWithContext(ctx aws.Context, input *dynamodb.TransactWriteItemsInput, opts ...request.Option) (*dynamodb.TransactWriteItemsOutput, error) {
+	scoped := *input
+	scoped.TransactItems = make([]*dynamodb.TransactWriteItem, len(input.TransactItems))
+	for i, item := range input.TransactItems {
+		tenantID, ok := d.scope(ctx, transactTable(item))
+		if !ok {
+			scoped.TransactItems[i] = item
+			continue
+		}
+
+		scopedItem := &dynamodb.TransactWriteItem{}
+		switch {
+		case item.Put != nil:
+			if owner, ok := item.Put.Item[Attribute]; ok && aws.StringValue(owner.S) != "" && aws.StringValue(owner.S) != tenantID {
+				return nil, fmt.Errorf("%w: %s", ErrCrossTenantWrite, aws.StringValue(owner.S))
+			}
+			put := *item.Put
+			put.Item = d.partitionItem(tenantID, put.TableName, put.Item)
+			put.Item[Attribute] = &dynamodb.AttributeValue{S: aws.String(tenantID)}
+			scopedItem.Put = &put
+		case item.Update != nil:
+			update := *item.Update
+			update.Key = d.partitionItem(tenantID, update.TableName, update.Key)
+			update.ConditionExpression, update.ExpressionAttributeNames, update.ExpressionAttributeValues = d.condition(tenantID, update.ConditionExpression, update.ExpressionAttributeNames, update.ExpressionAttributeValues)
+			scopedItem.Update = &update
+		case item.Delete != nil:
+			del := *item.Delete
+			del.Key = d.partitionItem(tenantID, del.TableName, del.Key)
+			del.ConditionExpression, del.ExpressionAttributeNames, del.ExpressionAttributeValues = d.condition(tenantID, del.ConditionExpression, del.ExpressionAttributeNames, del.ExpressionAttributeValues)
+			scopedItem.Delete = &del
+		case item.ConditionCheck != nil:
+			check := *item.ConditionCheck
+			check.Key = d.partitionItem(tenantID, check.TableName, check.Key)
+			check.ConditionExpression, check.ExpressionAttributeNames, check.ExpressionAttributeValues = d.condition(tenantID, check.ConditionExpression, check.ExpressionAttributeNames, check.ExpressionAttributeValues)
+			scopedItem.ConditionCheck = &check
+		}
+		scoped.TransactItems[i] = scopedItem
+	}
+	return d.DynamoDBAPI.TransactWriteItemsWithContext(ctx, &scoped, opts...)
+}
+
+// transactTable returns the table a transaction item writes
+func transactTable(item *dynamodb.TransactWriteItem) *string {
+	switch {
+	case item.Put != nil:
+		return item.Put.TableName
+	case item.Update != nil:
+		return item.Update.TableName
+	case item.Delete != nil:
+		return item.Delete.TableName
+	case item.ConditionCheck != nil:
+		return item.ConditionCheck.TableName
+	default:
+		return nil
+	}
+}
+
+// owns reports whether a stored item belongs to the tenant
+func (d *IsolatedDynamoDB) owns(tenantID string, item map[string]*dynamodb.AttributeValue) bool {
+	var owner string
+	if value, ok := item[Attribute]; ok {
+		owner = aws.StringValue(value.S)
+	}
+	return d.Policy.owner(owner) == tenantID
+}
+
+// backfill attributes an item without tenant_id to the default tenant
+func (d *IsolatedDynamoDB) backfill(item map[string]*dynamodb.AttributeValue) {
+	if _, ok := item[Attribute]; !ok && d.Policy.DefaultTenant != "" {
+		item[Attribute] = &dynamodb.AttributeValue{S: aws.String(d.Policy.DefaultTenant)}
+	}
+}
+
+// condition adds the tenant check to a condition or filter expression.
+// Items without tenant_id predate multi-tenancy and belong to the default tenant.
+func (d *IsolatedDynamoDB) condition(tenantID string, expression *string, names map[string]*string, values map[string]*dynamodb.AttributeValue) (*string, map[string]*string, map[string]*dynamodb.AttributeValue) {
+	check := attributeName + " = " + attributeValue
+	if tenantID == d.Policy.DefaultTenant {
+		check = "(" + check + " OR attribute_not_exists(" + attributeName + "))"
+	}
+	if existing := aws.StringValue(expression); existing != "" {
+		check = "(" + existing + ") AND " + check
+	}
+
+	scopedNames := make(map[string]*string, len(names)+1)
+	for key, value := range names {
+		scopedNames[key] = value
+	}
+	scopedNames[attributeName] = aws.String(Attribute)
+
+	scopedValues := make(map[string]*dynamodb.AttributeValue, len(values)+1)
+	for key, value := range values {
+		scopedValues[key] = value
+	}
+	scopedValues[attributeValue] = &dynamodb.AttributeValue{S: aws.String(tenantID)}
+
+	return aws.String(check), scopedNames, scopedValues
+}
+
+// partitionItem copies a key or item, prefixing the hash key of partitioned tables
+func (d *IsolatedDynamoDB) partitionItem(tenantID string, table *string, item map[string]*dynamodb.AttributeValue) map[string]*dynamodb.AttributeValue {
+	scoped := make(map[string]*dynamodb.AttributeValue, len(item)+1)
+	for key, value := range item {
+		scoped[key] = value
+	}
+
+	if hashKey, ok := d.Partitioned[aws.StringValue(table)]; ok {
+		if value, ok := scoped[hashKey]; ok && value.S != nil {
+			scoped[hashKey] = &dynamodb.AttributeValue{S: aws.String(d.Policy.PartitionKey(tenantID, *value.S))}
+		}
+	}
+	return scoped
+}
+
+// unpartitionItem strips the tenant prefix from the hash key of partitioned tables
+func (d *IsolatedDynamoDB) unpartitionItem(tenantID string, table *string, item map[string]*dynamodb.AttributeValue) map[string]*dynamodb.AttributeValue {
+	hashKey, ok := d.Partitioned[aws.StringValue(table)]
+	if !ok {
+		return item
+	}
+	if value, ok := item[hashKey]; ok && value.S != nil {
+		item[hashKey] = &dynamodb.AttributeValue{S: aws.String(strings.TrimPrefix(*value.S, d.Policy.PartitionKey(tenantID, "")))}
+	}
+	return item
+}
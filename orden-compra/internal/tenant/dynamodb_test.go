@@ -0,0 +1,218 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+
+	"orden-compra/internal/memory"
+)
+
+func newTestIsolatedDynamoDB() (*IsolatedDynamoDB, *memory.DynamoDB) {
+	client := memory.NewDynamoDB(memory.Tables)
+	return NewIsolatedDynamoDB(client, Policy{DefaultTenant: "default"}, []string{"orden-compra-read"}, map[string]string{"orden-compra-suppliers": "id"}), client
+}
+
+func TestTransactWriteItemsScopesEveryItem(t *testing.T) {
+	d, client := newTestIsolatedDynamoDB()
+	ctx := NewContext(context.Background(), "tenant-a")
+
+	_, err := d.TransactWriteItemsWithContext(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: []*dynamodb.TransactWriteItem{
+			{Put: &dynamodb.Put{
+				TableName: aws.String("orden-compra-read"),
+				Item:      map[string]*dynamodb.AttributeValue{"id": {S: aws.String("po-1")}},
+			}},
+			{Put: &dynamodb.Put{
+				TableName: aws.String("orden-compra-suppliers"),
+				Item:      map[string]*dynamodb.AttributeValue{"id": {S: aws.String("supplier-1")}},
+			}},
+			{Update: &dynamodb.Update{
+				TableName:                 aws.String("orden-compra-stats"),
+				Key:                       map[string]*dynamodb.AttributeValue{"id": {S: aws.String("tenant-a#2026-03-02")}},
+				UpdateExpression:          aws.String("ADD #total :one"),
+				ExpressionAttributeNames:  map[string]*string{"#total": aws.String("total")},
+				ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":one": {N: aws.String("1")}},
+			}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("TransactWriteItems in a tenant context: %v", err)
+	}
+
+	orders := client.Items("orden-compra-read")
+	if len(orders) != 1 || aws.StringValue(orders[0][Attribute].S) != "tenant-a" {
+		t.Fatalf("orders %v, want po-1 stamped with tenant-a", orders)
+	}
+	suppliers := client.Items("orden-compra-suppliers")
+	if len(suppliers) != 1 || aws.StringValue(suppliers[0]["id"].S) != d.Policy.PartitionKey("tenant-a", "supplier-1") {
+		t.Fatalf("suppliers %v, want supplier-1 partitioned under tenant-a", suppliers)
+	}
+	stats := client.Items("orden-compra-stats")
+	if len(stats) != 1 || stats[0][Attribute] != nil {
+		t.Fatalf("stats %v, want one unscoped bucket", stats)
+	}
+}
+
+func TestTransactWriteItemsOnlyChangesTheTenantsItems(t *testing.T) {
+	d, client := newTestIsolatedDynamoDB()
+	if _, err := d.PutItemWithContext(NewContext(context.Background(), "tenant-a"), &dynamodb.PutItemInput{
+		TableName: aws.String("orden-compra-read"),
+		Item:      map[string]*dynamodb.AttributeValue{"id": {S: aws.String("po-1")}, "status": {S: aws.String("pending")}},
+	}); err != nil {
+		t.Fatalf("PutItem: %v", err)
+	}
+
+	_, err := d.TransactWriteItemsWithContext(NewContext(context.Background(), "tenant-b"), &dynamodb.TransactWriteItemsInput{
+		TransactItems: []*dynamodb.TransactWriteItem{
+			{Update: &dynamodb.Update{
+				TableName:                 aws.String("orden-compra-read"),
+				Key:                       map[string]*dynamodb.AttributeValue{"id": {S: aws.String("po-1")}},
+				UpdateExpression:          aws.String("SET #status = :status"),
+				ExpressionAttributeNames:  map[string]*string{"#status": aws.String("status")},
+				ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":status": {S: aws.String("cancelled")}},
+			}},
+		},
+	})
+	var cancelled *dynamodb.TransactionCanceledException
+	if !errors.As(err, &cancelled) {
+		t.Fatalf("update of another tenant's order returned %v, want a cancelled transaction", err)
+	}
+	if status := aws.StringValue(client.Items("orden-compra-read")[0]["status"].S); status != "pending" {
+		t.Fatalf("status %s after another tenant's update, want pending", status)
+	}
+}
+
+func TestTransactWriteItemsRefusesCrossTenantPuts(t *testing.T) {
+	d, client := newTestIsolatedDynamoDB()
+
+	_, err := d.TransactWriteItemsWithContext(NewContext(context.Background(), "tenant-a"), &dynamodb.TransactWriteItemsInput{
+		TransactItems: []*dynamodb.TransactWriteItem{
+			{Put: &dynamodb.Put{
+				TableName: aws.String("orden-compra-read"),
+				Item: map[string]*dynamodb.AttributeValue{
+					"id":      {S: aws.String("po-1")},
+					Attribute: {S: aws.String("tenant-b")},
+				},
+			}},
+		},
+	})
+	if !errors.Is(err, ErrCrossTenantWrite) {
+		t.Fatalf("put of another tenant's order returned %v, want ErrCrossTenantWrite", err)
+	}
+	if items := client.Items("orden-compra-read"); len(items) != 0 {
+		t.Fatalf("stored %v after a refused transaction", items)
+	}
+}
+
+// putOrder writes an order in ctx through the isolated client
+func putOrder(t *testing.T, d *IsolatedDynamoDB, ctx context.Context, id string) {
+	t.Helper()
+	if _, err := d.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String("orden-compra-read"),
+		Item:      map[string]*dynamodb.AttributeValue{"id": {S: aws.String(id)}},
+	}); err != nil {
+		t.Fatalf("put %s: %v", id, err)
+	}
+}
+
+func TestTenantsOnlySeeTheirItems(t *testing.T) {
+	d, client := newTestIsolatedDynamoDB()
+	tenantA := NewContext(context.Background(), "tenant-a")
+	tenantB := NewContext(context.Background(), "tenant-b")
+	putOrder(t, d, tenantA, "po-a")
+	putOrder(t, d, tenantB, "po-b")
+	// An order written before multi-tenancy belongs to the default tenant
+	client.PutItemWithContext(context.Background(), &dynamodb.PutItemInput{
+		TableName: aws.String("orden-compra-read"),
+		Item:      map[string]*dynamodb.AttributeValue{"id": {S: aws.String("po-legacy")}},
+	})
+
+	get := func(ctx context.Context, id string) map[string]*dynamodb.AttributeValue {
+		output, err := d.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+			TableName: aws.String("orden-compra-read"),
+			Key:       map[string]*dynamodb.AttributeValue{"id": {S: aws.String(id)}},
+		})
+		if err != nil {
+			t.Fatalf("get %s: %v", id, err)
+		}
+		return output.Item
+	}
+	if get(tenantA, "po-a") == nil || get(tenantA, "po-b") != nil || get(tenantA, "po-legacy") != nil {
+		t.Fatal("tenant-a reads another tenant's orders")
+	}
+	if item := get(NewContext(context.Background(), "default"), "po-legacy"); item == nil || aws.StringValue(item[Attribute].S) != "default" {
+		t.Fatalf("default tenant reads legacy order as %v", item)
+	}
+
+	scan := func(ctx context.Context) []string {
+		output, err := d.ScanWithContext(ctx, &dynamodb.ScanInput{TableName: aws.String("orden-compra-read")})
+		if err != nil {
+			t.Fatalf("scan: %v", err)
+		}
+		var ids []string
+		for _, item := range output.Items {
+			ids = append(ids, aws.StringValue(item["id"].S))
+		}
+		return ids
+	}
+	if ids := scan(tenantB); len(ids) != 1 || ids[0] != "po-b" {
+		t.Fatalf("tenant-b scanned %v", ids)
+	}
+	if ids := scan(NewContext(context.Background(), "default")); len(ids) != 1 || ids[0] != "po-legacy" {
+		t.Fatalf("default tenant scanned %v", ids)
+	}
+	// Service calls without a tenant work across tenants
+	if ids := scan(context.Background()); len(ids) != 3 {
+		t.Fatalf("service scanned %v", ids)
+	}
+
+	// Deleting another tenant's order fails its condition
+	if _, err := d.DeleteItemWithContext(tenantB, &dynamodb.DeleteItemInput{
+		TableName: aws.String("orden-compra-read"),
+		Key:       map[string]*dynamodb.AttributeValue{"id": {S: aws.String("po-a")}},
+	}); err == nil {
+		t.Fatal("tenant-b deleted tenant-a's order")
+	}
+	if get(tenantA, "po-a") == nil {
+		t.Fatal("tenant-a's order is gone")
+	}
+}
+
+func TestPartitionedTablesLetTenantsReuseIDs(t *testing.T) {
+	d, client := newTestIsolatedDynamoDB()
+	for _, tenantID := range []string{"default", "tenant-a"} {
+		if _, err := d.PutItemWithContext(NewContext(context.Background(), tenantID), &dynamodb.PutItemInput{
+			TableName: aws.String("orden-compra-suppliers"),
+			Item:      map[string]*dynamodb.AttributeValue{"id": {S: aws.String("supplier-1")}, "name": {S: aws.String(tenantID)}},
+		}); err != nil {
+			t.Fatalf("put supplier for %s: %v", tenantID, err)
+		}
+	}
+	if suppliers := client.Items("orden-compra-suppliers"); len(suppliers) != 2 {
+		t.Fatalf("stored %d suppliers, want one per tenant", len(suppliers))
+	}
+
+	output, err := d.GetItemWithContext(NewContext(context.Background(), "tenant-a"), &dynamodb.GetItemInput{
+		TableName: aws.String("orden-compra-suppliers"),
+		Key:       map[string]*dynamodb.AttributeValue{"id": {S: aws.String("supplier-1")}},
+	})
+	if err != nil || aws.StringValue(output.Item["name"].S) != "tenant-a" || aws.StringValue(output.Item["id"].S) != "supplier-1" {
+		t.Fatalf("tenant-a supplier %v, error %v", output.Item, err)
+	}
+}
+
+func TestBatchWritesAreRefusedInATenantContext(t *testing.T) {
+	d, _ := newTestIsolatedDynamoDB()
+	_, err := d.BatchWriteItemWithContext(NewContext(context.Background(), "tenant-a"), &dynamodb.BatchWriteItemInput{
+		RequestItems: map[string][]*dynamodb.WriteRequest{
+			"orden-compra-read": {{PutRequest: &dynamodb.PutRequest{Item: map[string]*dynamodb.AttributeValue{"id": {S: aws.String("po-1")}}}}},
+		},
+	})
+	if !errors.Is(err, ErrUnsupportedOperation) {
+		t.Fatalf("batch write returned %v, want ErrUnsupportedOperation", err)
+	}
+}
@@ -0,0 +1,49 @@
+package tenant
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+
+	"orden-compra/internal/auth"
+)
+
+// ContextKey is the Gin context key holding the tenant of the request
+const ContextKey = "tenant_id"
+
+// Middleware scopes every request to the caller's tenant, rejecting requests
+// without one except for the given public route paths. It runs after
+// authentication.
+func Middleware(policy Policy, publicPaths []string) gin.HandlerFunc {
+	public := make(map[string]bool, len(publicPaths))
+	for _, path := range publicPaths {
+		public[path] = true
+	}
+
+	return func(c *gin.Context) {
+		if public[c.FullPath()] {
+			c.Next()
+			return
+		}
+
+		var credentialTenant string
+		principal, authenticated := auth.FromContext(c.Request.Context())
+		if authenticated {
+			credentialTenant = principal.Tenant
+		}
+
+		tenantID, err := policy.ForCaller(credentialTenant, c.GetHeader(Header), authenticated)
+		if err != nil {
+			status := 403
+			if errors.Is(err, ErrInvalidTenant) {
+				status = 400
+			}
+			c.AbortWithStatusJSON(status, gin.H{"success": false, "error": err.Error()})
+			return
+		}
+
+		c.Set(ContextKey, tenantID)
+		c.Request = c.Request.WithContext(NewContext(c.Request.Context(), tenantID))
+		c.Next()
+	}
+}
@@ -0,0 +1,60 @@
+package tenant
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"orden-compra/internal/auth"
+)
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	for _, tc := range []struct {
+		name      string
+		policy    Policy
+		principal *auth.Principal
+		header    string
+		path      string
+		status    int
+		tenantID  string
+	}{
+		{"header without authentication", Policy{DefaultTenant: "default"}, nil, "tenant-1", "/orders", http.StatusOK, "tenant-1"},
+		{"default tenant", Policy{DefaultTenant: "default"}, nil, "", "/orders", http.StatusOK, "default"},
+		{"credential tenant", Policy{Required: true}, &auth.Principal{Subject: "client-1", Tenant: "tenant-1"}, "", "/orders", http.StatusOK, "tenant-1"},
+		{"another tenant in the header", Policy{Required: true}, &auth.Principal{Subject: "client-1", Tenant: "tenant-1"}, "tenant-2", "/orders", http.StatusForbidden, ""},
+		{"missing tenant", Policy{Required: true}, nil, "", "/orders", http.StatusForbidden, ""},
+		{"invalid tenant", Policy{DefaultTenant: "default"}, nil, "tenant 1", "/orders", http.StatusBadRequest, ""},
+		{"public path", Policy{Required: true}, nil, "", "/health", http.StatusOK, ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var tenantID string
+			handler := func(c *gin.Context) {
+				tenantID, _ = FromContext(c.Request.Context())
+				c.Status(http.StatusOK)
+			}
+			router := gin.New()
+			router.Use(func(c *gin.Context) {
+				if tc.principal != nil {
+					c.Request = c.Request.WithContext(auth.NewContext(c.Request.Context(), tc.principal))
+				}
+			}, Middleware(tc.policy, []string{"/health"}))
+			router.GET("/orders", handler)
+			router.GET("/health", handler)
+
+			request := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			if tc.header != "" {
+				request.Header.Set(Header, tc.header)
+			}
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, request)
+
+			if recorder.Code != tc.status || tenantID != tc.tenantID {
+				t.Fatalf("status %d with tenant %q, want %d with %q: %s", recorder.Code, tenantID, tc.status, tc.tenantID, recorder.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+// Tenant identifiers on the wire and in storage
+const (
+	// Header carries the tenant of HTTP requests when authentication is disabled
+	Header = "X-Tenant-ID"
+	// MessageHeader carries the tenant of AMQP messages
+	MessageHeader = "tenant-id"
+	// Attribute holds the owning tenant of stored items
+	Attribute = "tenant_id"
+)
+
+var (
+	// ErrMissingTenant is returned when a caller or message has no tenant and one is required
+	ErrMissingTenant = errors.New("tenant is required")
+	// ErrInvalidTenant is returned for malformed tenant IDs
+	ErrInvalidTenant = errors.New("invalid tenant")
+	// ErrTenantMismatch is returned when a caller names a tenant other than its own
+	ErrTenantMismatch = errors.New("tenant does not match the caller")
+)
+
+// idPattern restricts tenant IDs to characters that are safe in keys and headers
+var idPattern = regexp.MustCompile(`^[A-Za-z0-9_.\-]{1,64}$`)
+
+type tenantKey struct{}
+
+// NewContext returns a copy of ctx scoped to the tenant
+func NewContext(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenantID)
+}
+
+// FromContext returns the tenant ctx is scoped to. Contexts without one
+// belong to the service itself, such as scheduled jobs working across tenants.
+func FromContext(ctx context.Context) (string, bool) {
+	tenantID, ok := ctx.Value(tenantKey{}).(string)
+	return tenantID, ok && tenantID != ""
+}
+
+// Policy decides the tenant of callers and owns the records written before
+// multi-tenancy
+type Policy struct {
+	// Required rejects callers and messages without a tenant; otherwise they
+	// act as DefaultTenant
+	Required bool
+	// DefaultTenant owns records without a tenant_id attribute
+	DefaultTenant string
+}
+
+// Resolve validates a tenant ID, falling back to the default tenant when
+// tenants are optional
+func (p Policy) Resolve(tenantID string) (string, error) {
+	if tenantID == "" {
+		if p.Required || p.DefaultTenant == "" {
+			return "", ErrMissingTenant
+		}
+		return p.DefaultTenant, nil
+	}
+
+	if !idPattern.MatchString(tenantID) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidTenant, tenantID)
+	}
+	return tenantID, nil
+}
+
+// ForCaller resolves the tenant of an API caller. Authenticated callers act
+// for the tenant of their credentials and may only repeat it in the request;
+// the requested tenant is trusted as is only when authentication is disabled.
+func (p Policy) ForCaller(credentialTenant, requested string, authenticated bool) (string, error) {
+	if !authenticated {
+		return p.Resolve(requested)
+	}
+
+	tenantID, err := p.Resolve(credentialTenant)
+	if err != nil {
+		return "", err
+	}
+	if requested != "" && requested != tenantID {
+		return "", fmt.Errorf("%w: %s", ErrTenantMismatch, requested)
+	}
+	return tenantID, nil
+}
+
+// PartitionKey scopes a business key such as a supplier ID to a tenant.
+// Keys of the default tenant stay unprefixed so existing records keep working.
+func (p Policy) PartitionKey(tenantID, key string) string {
+	if tenantID == p.DefaultTenant {
+		return key
+	}
+	return tenantID + "#" + key
+}
+
+// owner returns the tenant owning a stored item's tenant_id value
+func (p Policy) owner(tenantID string) string {
+	if tenantID == "" {
+		return p.DefaultTenant
+	}
+	return tenantID
+}
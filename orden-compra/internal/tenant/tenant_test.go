@@ -0,0 +1,79 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		policy   Policy
+		tenantID string
+		want     string
+		err      error
+	}{
+		{"given tenant", Policy{DefaultTenant: "default"}, "tenant-1", "tenant-1", nil},
+		{"default tenant", Policy{DefaultTenant: "default"}, "", "default", nil},
+		{"required tenant", Policy{Required: true, DefaultTenant: "default"}, "", "", ErrMissingTenant},
+		{"no default", Policy{}, "", "", ErrMissingTenant},
+		{"unsafe characters", Policy{DefaultTenant: "default"}, "tenant#1", "", ErrInvalidTenant},
+		{"too long", Policy{DefaultTenant: "default"}, strings.Repeat("t", 65), "", ErrInvalidTenant},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := tc.policy.Resolve(tc.tenantID)
+			if got != tc.want || !errors.Is(err, tc.err) {
+				t.Fatalf("Resolve(%q) = %q, %v, want %q, %v", tc.tenantID, got, err, tc.want, tc.err)
+			}
+		})
+	}
+}
+
+func TestForCaller(t *testing.T) {
+	policy := Policy{DefaultTenant: "default"}
+	for _, tc := range []struct {
+		name             string
+		credentialTenant string
+		requested        string
+		authenticated    bool
+		want             string
+		err              error
+	}{
+		{"credential tenant", "tenant-1", "", true, "tenant-1", nil},
+		{"repeated tenant", "tenant-1", "tenant-1", true, "tenant-1", nil},
+		{"another tenant", "tenant-1", "tenant-2", true, "", ErrTenantMismatch},
+		{"credentials without a tenant", "", "", true, "default", nil},
+		{"requested without authentication", "", "tenant-2", false, "tenant-2", nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := policy.ForCaller(tc.credentialTenant, tc.requested, tc.authenticated)
+			if got != tc.want || !errors.Is(err, tc.err) {
+				t.Fatalf("ForCaller = %q, %v, want %q, %v", got, err, tc.want, tc.err)
+			}
+		})
+	}
+}
+
+func TestPartitionKey(t *testing.T) {
+	policy := Policy{DefaultTenant: "default"}
+	if key := policy.PartitionKey("default", "supplier-1"); key != "supplier-1" {
+		t.Fatalf("default tenant key %q", key)
+	}
+	if key := policy.PartitionKey("tenant-1", "supplier-1"); key != "tenant-1#supplier-1" {
+		t.Fatalf("tenant key %q", key)
+	}
+}
+
+func TestContext(t *testing.T) {
+	if _, ok := FromContext(context.Background()); ok {
+		t.Fatal("tenant found in an empty context")
+	}
+	if _, ok := FromContext(NewContext(context.Background(), "")); ok {
+		t.Fatal("empty tenant found")
+	}
+	if tenantID, ok := FromContext(NewContext(context.Background(), "tenant-1")); !ok || tenantID != "tenant-1" {
+		t.Fatalf("tenant %q", tenantID)
+	}
+}
@@ -0,0 +1,262 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"orden-compra/internal/models"
+	"orden-compra/internal/tenant"
+)
+
+// Headers sent with every delivery
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// RetryPolicy controls how failed deliveries are retried. The backoff doubles
+// after every attempt.
+type RetryPolicy struct {
+	MaxAttempts int
+	Backoff     time.Duration
+}
+
+// Payload is the JSON body delivered to subscribers
+type Payload struct {
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	Timestamp time.Time   `json:"timestamp"`
+	Data      interface{} `json:"data"`
+}
+
+// Dispatcher delivers signed event payloads to the matching subscriptions
+type Dispatcher struct {
+	Store    *Store
+	Client   *http.Client
+	Retry    RetryPolicy
+	CacheTTL time.Duration
+	Logger   *log.Logger
+
+	mu sync.Mutex
+	// subscriptions caches the subscriptions of each tenant
+	subscriptions map[string]cachedSubscriptions
+	wg            sync.WaitGroup
+}
+
+// cachedSubscriptions are the subscriptions of one tenant and when they were loaded
+type cachedSubscriptions struct {
+	subscriptions []*models.WebhookSubscription
+	loadedAt      time.Time
+}
+
+// NewDispatcher creates a new dispatcher
+func NewDispatcher(store *Store, retry RetryPolicy, timeout time.Duration, logger *log.Logger) *Dispatcher {
+	if retry.MaxAttempts < 1 {
+		retry.MaxAttempts = 1
+	}
+	return &Dispatcher{
+		Store:    store,
+		Client:   &http.Client{Timeout: timeout},
+		Retry:    retry,
+		CacheTTL: 30 * time.Second,
+		Logger:   logger,
+	}
+}
+
+// GenerateSecret returns a random signing secret for subscriptions registered without one
+func GenerateSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate secret: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// Sign returns the signature of a payload: hex HMAC-SHA256 of "<timestamp>.<body>"
+// keyed with the subscription secret
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Invalidate drops the cached subscriptions so changes apply to the next event
+func (d *Dispatcher) Invalidate() {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	d.subscriptions = nil
+	d.mu.Unlock()
+}
+
+// Dispatch delivers an event to every matching subscription of the tenant in
+// ctx in the background, so callers never wait on subscriber endpoints. A nil
+// dispatcher is a no-op.
+func (d *Dispatcher) Dispatch(ctx context.Context, eventType string, data interface{}) {
+	if d == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+	subscriptions, err := d.matching(ctx, eventType)
+	cancel()
+	if err != nil {
+		d.Logger.Printf("Failed to load webhook subscriptions for %s: %v", eventType, err)
+		return
+	}
+	if len(subscriptions) == 0 {
+		return
+	}
+
+	payload := Payload{
+		ID:        uuid.New().String(),
+		Type:      eventType,
+		Timestamp: time.Now().UTC(),
+		Data:      data,
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		d.Logger.Printf("Failed to marshal webhook payload for %s: %v", eventType, err)
+		return
+	}
+
+	for _, subscription := range subscriptions {
+		delivery := models.NewWebhookDelivery(subscription.ID, payload.ID, eventType, time.Now())
+		delivery.TenantID = subscription.TenantID
+
+		d.wg.Add(1)
+		go func(subscription *models.WebhookSubscription) {
+			defer d.wg.Done()
+			d.deliver(subscription, delivery, body)
+		}(subscription)
+	}
+}
+
+// Wait blocks until in-flight deliveries finish
+func (d *Dispatcher) Wait() {
+	if d == nil {
+		return
+	}
+	d.wg.Wait()
+}
+
+// matching returns the active subscriptions of the tenant in ctx for an event
+// type, refreshing the cache when stale
+func (d *Dispatcher) matching(ctx context.Context, eventType string) ([]*models.WebhookSubscription, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	tenantID, _ := tenant.FromContext(ctx)
+	cached, ok := d.subscriptions[tenantID]
+	if !ok || time.Since(cached.loadedAt) > d.CacheTTL {
+		subscriptions, err := d.Store.ListSubscriptions(ctx)
+		if err != nil {
+			return nil, err
+		}
+		cached = cachedSubscriptions{subscriptions: subscriptions, loadedAt: time.Now()}
+		if d.subscriptions == nil {
+			d.subscriptions = make(map[string]cachedSubscriptions)
+		}
+		d.subscriptions[tenantID] = cached
+	}
+
+	var matched []*models.WebhookSubscription
+	for _, subscription := range cached.subscriptions {
+		if subscription.Matches(eventType) {
+			matched = append(matched, subscription)
+		}
+	}
+	return matched, nil
+}
+
+// deliver posts the payload, retrying with exponential backoff and recording
+// the outcome of every attempt
+func (d *Dispatcher) deliver(subscription *models.WebhookSubscription, delivery *models.WebhookDelivery, body []byte) {
+	d.record(delivery)
+
+	backoff := d.Retry.Backoff
+	for attempt := 1; attempt <= d.Retry.MaxAttempts; attempt++ {
+		statusCode, err := d.post(subscription, delivery, body)
+
+		delivery.Attempts = attempt
+		delivery.ResponseStatus = statusCode
+		delivery.UpdatedAt = time.Now().UTC()
+		if err == nil {
+			delivery.Status = models.DeliverySucceeded
+			delivery.LastError = ""
+			d.record(delivery)
+			return
+		}
+
+		delivery.LastError = err.Error()
+		d.Logger.Printf("Webhook delivery failed - subscription_id: %s, delivery_id: %s, event: %s, attempt: %d/%d, error: %v",
+			subscription.ID, delivery.ID, delivery.EventType, attempt, d.Retry.MaxAttempts, err)
+
+		if attempt < d.Retry.MaxAttempts {
+			d.record(delivery)
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+
+	delivery.Status = models.DeliveryFailed
+	d.record(delivery)
+	d.Logger.Printf("Giving up on webhook delivery - subscription_id: %s, delivery_id: %s, event: %s", subscription.ID, delivery.ID, delivery.EventType)
+}
+
+// post sends one signed delivery attempt and treats any non-2xx response as a failure
+func (d *Dispatcher) post(subscription *models.WebhookSubscription, delivery *models.WebhookDelivery, body []byte) (int, error) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequest(http.MethodPost, subscription.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, delivery.EventType)
+	req.Header.Set(HeaderDelivery, delivery.ID)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, Sign(subscription.Secret, timestamp, body))
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to post: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// record stores the delivery status; tracking failures never block delivery
+func (d *Dispatcher) record(delivery *models.WebhookDelivery) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := d.Store.SaveDelivery(ctx, delivery); err != nil {
+		d.Logger.Printf("Failed to record webhook delivery %s: %v", delivery.ID, err)
+	}
+}
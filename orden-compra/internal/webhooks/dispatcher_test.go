@@ -0,0 +1,199 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"orden-compra/internal/memory"
+	"orden-compra/internal/models"
+)
+
+// received is one request a test subscriber endpoint got
+type received struct {
+	header http.Header
+	body   []byte
+}
+
+// subscriber starts an endpoint that answers with the given statuses in turn,
+// repeating the last one, and records every request
+func subscriber(t *testing.T, statuses ...int) (*httptest.Server, func() []received) {
+	t.Helper()
+	var mu sync.Mutex
+	var requests []received
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		requests = append(requests, received{header: r.Header.Clone(), body: body})
+		status := statuses[len(statuses)-1]
+		if len(requests) <= len(statuses) {
+			status = statuses[len(requests)-1]
+		}
+		mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []received {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]received(nil), requests...)
+	}
+}
+
+func newDispatcher(t *testing.T, retry RetryPolicy, subscriptions ...*models.WebhookSubscription) (*Dispatcher, *Store) {
+	t.Helper()
+	store := NewStore(memory.NewDynamoDB(memory.Tables))
+	for _, subscription := range subscriptions {
+		if err := store.SaveSubscription(context.Background(), subscription); err != nil {
+			t.Fatalf("save subscription: %v", err)
+		}
+	}
+	return NewDispatcher(store, retry, time.Second, log.New(io.Discard, "", 0)), store
+}
+
+func TestSign(t *testing.T) {
+	// HMAC-SHA256 of "1700000000.{}" keyed with "secret"
+	if got := Sign("secret", "1700000000", []byte("{}")); got != "sha256=b8569b78799ff9e3cbff0fc2d63a33a2b57f3282abd07c37ae5e8e7d79a5f163" {
+		t.Fatalf("signature %q", got)
+	}
+	if Sign("secret", "1", []byte("{}")) == Sign("secret", "2", []byte("{}")) {
+		t.Fatal("signature does not cover the timestamp")
+	}
+	if Sign("secret", "1", []byte("{}")) == Sign("other", "1", []byte("{}")) {
+		t.Fatal("signature does not depend on the secret")
+	}
+}
+
+func TestDispatchDeliversSignedPayloads(t *testing.T) {
+	server, requests := subscriber(t, http.StatusOK)
+	subscription := models.NewWebhookSubscription(server.URL, "secret-1", []string{models.WebhookPurchaseOrderCreated}, time.Now())
+	dispatcher, store := newDispatcher(t, RetryPolicy{MaxAttempts: 1}, subscription)
+
+	dispatcher.Dispatch(context.Background(), models.WebhookPurchaseOrderCreated, map[string]string{"purchase_order_id": "po-1"})
+	dispatcher.Wait()
+
+	got := requests()
+	if len(got) != 1 {
+		t.Fatalf("subscriber got %d requests, want 1", len(got))
+	}
+	header := got[0].header
+	if header.Get(HeaderEvent) != models.WebhookPurchaseOrderCreated || header.Get("Content-Type") != "application/json" {
+		t.Fatalf("headers %v", header)
+	}
+	if signature := Sign("secret-1", header.Get(HeaderTimestamp), got[0].body); header.Get(HeaderSignature) != signature {
+		t.Fatalf("signature %q, want %q", header.Get(HeaderSignature), signature)
+	}
+
+	var payload struct {
+		ID   string            `json:"id"`
+		Type string            `json:"type"`
+		Data map[string]string `json:"data"`
+	}
+	if err := json.Unmarshal(got[0].body, &payload); err != nil || payload.Type != models.WebhookPurchaseOrderCreated || payload.Data["purchase_order_id"] != "po-1" {
+		t.Fatalf("payload %s, error %v", got[0].body, err)
+	}
+
+	deliveries, err := store.ListDeliveries(context.Background(), subscription.ID)
+	if err != nil || len(deliveries) != 1 {
+		t.Fatalf("deliveries %v, error %v", deliveries, err)
+	}
+	delivery := deliveries[0]
+	if delivery.ID != header.Get(HeaderDelivery) || delivery.EventID != payload.ID || delivery.Status != models.DeliverySucceeded || delivery.Attempts != 1 || delivery.ResponseStatus != http.StatusOK {
+		t.Fatalf("delivery %+v", delivery)
+	}
+}
+
+func TestDispatchOnlyReachesMatchingSubscriptions(t *testing.T) {
+	server, requests := subscriber(t, http.StatusNoContent)
+	created := models.NewWebhookSubscription(server.URL+"/created", "s", []string{models.WebhookPurchaseOrderCreated}, time.Now())
+	all := models.NewWebhookSubscription(server.URL+"/all", "s", []string{models.WebhookAllEvents}, time.Now())
+	inactive := models.NewWebhookSubscription(server.URL+"/inactive", "s", []string{models.WebhookAllEvents}, time.Now())
+	inactive.Active = false
+	dispatcher, _ := newDispatcher(t, RetryPolicy{MaxAttempts: 1}, created, all, inactive)
+
+	dispatcher.Dispatch(context.Background(), models.WebhookPurchaseOrderApproved, nil)
+	dispatcher.Wait()
+
+	if got := requests(); len(got) != 1 || got[0].header.Get(HeaderEvent) != models.WebhookPurchaseOrderApproved {
+		t.Fatalf("subscribers got %d requests, want only the one for every event", len(got))
+	}
+}
+
+func TestDispatchRetriesWithBackoff(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		statuses []int
+		status   string
+		attempts int
+		response int
+	}{
+		{"succeeds after a failure", []int{http.StatusServiceUnavailable, http.StatusOK}, models.DeliverySucceeded, 2, http.StatusOK},
+		{"gives up after the last attempt", []int{http.StatusInternalServerError}, models.DeliveryFailed, 3, http.StatusInternalServerError},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			server, requests := subscriber(t, tc.statuses...)
+			subscription := models.NewWebhookSubscription(server.URL, "s", []string{models.WebhookAllEvents}, time.Now())
+			dispatcher, store := newDispatcher(t, RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond}, subscription)
+
+			dispatcher.Dispatch(context.Background(), models.WebhookPurchaseOrderOverdue, nil)
+			dispatcher.Wait()
+
+			got := requests()
+			if len(got) != tc.attempts {
+				t.Fatalf("subscriber got %d requests, want %d", len(got), tc.attempts)
+			}
+			// Every attempt is the same delivery
+			for _, request := range got {
+				if request.header.Get(HeaderDelivery) != got[0].header.Get(HeaderDelivery) {
+					t.Fatalf("attempts carried different delivery IDs")
+				}
+			}
+
+			deliveries, err := store.ListDeliveries(context.Background(), subscription.ID)
+			if err != nil || len(deliveries) != 1 {
+				t.Fatalf("deliveries %v, error %v", deliveries, err)
+			}
+			if delivery := deliveries[0]; delivery.Status != tc.status || delivery.Attempts != tc.attempts || delivery.ResponseStatus != tc.response {
+				t.Fatalf("delivery %+v, want %s after %d attempts", delivery, tc.status, tc.attempts)
+			}
+		})
+	}
+}
+
+func TestDispatcherCachesSubscriptionsUntilInvalidated(t *testing.T) {
+	server, requests := subscriber(t, http.StatusOK)
+	dispatcher, store := newDispatcher(t, RetryPolicy{MaxAttempts: 1})
+
+	dispatcher.Dispatch(context.Background(), models.WebhookPurchaseOrderCreated, nil)
+	subscription := models.NewWebhookSubscription(server.URL, "s", []string{models.WebhookAllEvents}, time.Now())
+	if err := store.SaveSubscription(context.Background(), subscription); err != nil {
+		t.Fatalf("save subscription: %v", err)
+	}
+
+	// The cached empty list still applies until it is invalidated
+	dispatcher.Dispatch(context.Background(), models.WebhookPurchaseOrderCreated, nil)
+	dispatcher.Wait()
+	if got := requests(); len(got) != 0 {
+		t.Fatalf("subscriber got %d requests from a stale cache", len(got))
+	}
+
+	dispatcher.Invalidate()
+	dispatcher.Dispatch(context.Background(), models.WebhookPurchaseOrderCreated, nil)
+	dispatcher.Wait()
+	if got := requests(); len(got) != 1 {
+		t.Fatalf("subscriber got %d requests after invalidating, want 1", len(got))
+	}
+}
+
+func TestNilDispatcherIsANoOp(t *testing.T) {
+	var dispatcher *Dispatcher
+	dispatcher.Dispatch(context.Background(), models.WebhookPurchaseOrderCreated, nil)
+	dispatcher.Invalidate()
+	dispatcher.Wait()
+}
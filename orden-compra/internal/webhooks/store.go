@@ -0,0 +1,185 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+
+	"orden-compra/internal/models"
+)
+
+// ErrSubscriptionNotFound is returned when a webhook subscription does not exist
+var ErrSubscriptionNotFound = errors.New("webhook subscription not found")
+
+// ErrInvalidSubscription is returned when a webhook subscription is malformed
+var ErrInvalidSubscription = errors.New("invalid webhook subscription")
+
+// Store persists webhook subscriptions and their deliveries in DynamoDB
+type Store struct {
+	DynamoDB           dynamodbiface.DynamoDBAPI
+	SubscriptionsTable string
+	DeliveriesTable    string
+}
+
+// NewStore creates a store on the default webhook tables
+func NewStore(dynamoDB dynamodbiface.DynamoDBAPI) *Store {
+	return &Store{
+		DynamoDB:           dynamoDB,
+		SubscriptionsTable: "orden-compra-webhook-subscriptions",
+		DeliveriesTable:    "orden-compra-webhook-deliveries",
+	}
+}
+
+// SaveSubscription creates or replaces a subscription
+func (s *Store) SaveSubscription(ctx context.Context, subscription *models.WebhookSubscription) error {
+	item, err := dynamodbattribute.MarshalMap(subscription)
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook subscription: %w", err)
+	}
+
+	_, err = s.DynamoDB.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(s.SubscriptionsTable),
+		Item:      item,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put webhook subscription: %w", err)
+	}
+
+	return nil
+}
+
+// GetSubscription returns a subscription by ID
+func (s *Store) GetSubscription(ctx context.Context, id string) (*models.WebhookSubscription, error) {
+	result, err := s.DynamoDB.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(s.SubscriptionsTable),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {S: aws.String(id)},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook subscription: %w", err)
+	}
+	if result.Item == nil {
+		return nil, ErrSubscriptionNotFound
+	}
+
+	var subscription models.WebhookSubscription
+	if err := dynamodbattribute.UnmarshalMap(result.Item, &subscription); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal webhook subscription: %w", err)
+	}
+
+	return &subscription, nil
+}
+
+// ListSubscriptions returns every subscription
+func (s *Store) ListSubscriptions(ctx context.Context) ([]*models.WebhookSubscription, error) {
+	scanInput := &dynamodb.ScanInput{
+		TableName: aws.String(s.SubscriptionsTable),
+	}
+
+	subscriptions := make([]*models.WebhookSubscription, 0)
+	for {
+		result, err := s.DynamoDB.ScanWithContext(ctx, scanInput)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook subscriptions: %w", err)
+		}
+
+		for _, item := range result.Items {
+			var subscription models.WebhookSubscription
+			if err := dynamodbattribute.UnmarshalMap(item, &subscription); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal webhook subscription: %w", err)
+			}
+			subscriptions = append(subscriptions, &subscription)
+		}
+
+		if len(result.LastEvaluatedKey) == 0 {
+			break
+		}
+		scanInput.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+
+	return subscriptions, nil
+}
+
+// DeleteSubscription removes a subscription. Its delivery history is kept.
+func (s *Store) DeleteSubscription(ctx context.Context, id string) error {
+	_, err := s.DynamoDB.DeleteItemWithContext(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(s.SubscriptionsTable),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {S: aws.String(id)},
+		},
+		ConditionExpression: aws.String("attribute_exists(id)"),
+	})
+	if err != nil {
+		var awsErr awserr.Error
+		if errors.As(err, &awsErr) && awsErr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return ErrSubscriptionNotFound
+		}
+		return fmt.Errorf("failed to delete webhook subscription: %w", err)
+	}
+
+	return nil
+}
+
+// SaveDelivery creates or replaces a delivery record. Finished deliveries
+// expire after the delivery retention; pending ones are kept until they finish.
+func (s *Store) SaveDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	if delivery.Status != models.DeliveryPending {
+		delivery.ExpiresAt = models.ExpiresAt(delivery.UpdatedAt, models.WebhookDeliveryRetention)
+	}
+
+	item, err := dynamodbattribute.MarshalMap(delivery)
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook delivery: %w", err)
+	}
+
+	_, err = s.DynamoDB.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(s.DeliveriesTable),
+		Item:      item,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put webhook delivery: %w", err)
+	}
+
+	return nil
+}
+
+// ListDeliveries returns the deliveries made to a subscription
+func (s *Store) ListDeliveries(ctx context.Context, subscriptionID string) ([]*models.WebhookDelivery, error) {
+	scanInput := &dynamodb.ScanInput{
+		TableName:        aws.String(s.DeliveriesTable),
+		FilterExpression: aws.String("subscription_id = :subscription_id"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":subscription_id": {S: aws.String(subscriptionID)},
+		},
+	}
+
+	deliveries := make([]*models.WebhookDelivery, 0)
+	for {
+		result, err := s.DynamoDB.ScanWithContext(ctx, scanInput)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook deliveries: %w", err)
+		}
+
+		for _, item := range result.Items {
+			var delivery models.WebhookDelivery
+			if err := dynamodbattribute.UnmarshalMap(item, &delivery); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal webhook delivery: %w", err)
+			}
+			deliveries = append(deliveries, &delivery)
+		}
+
+		if len(result.LastEvaluatedKey) == 0 {
+			break
+		}
+		scanInput.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+
+	return deliveries, nil
+}
@@ -0,0 +1,77 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"orden-compra/internal/memory"
+	"orden-compra/internal/models"
+)
+
+func TestStoreSubscriptions(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore(memory.NewDynamoDB(memory.Tables))
+
+	subscription := models.NewWebhookSubscription("https://erp.example.com/hooks", "secret-1", []string{models.WebhookPurchaseOrderCreated}, time.Now())
+	subscription.TenantID = "tenant-1"
+	if err := store.SaveSubscription(ctx, subscription); err != nil {
+		t.Fatalf("save subscription: %v", err)
+	}
+
+	got, err := store.GetSubscription(ctx, subscription.ID)
+	if err != nil || got.URL != subscription.URL || got.Secret != "secret-1" || got.TenantID != "tenant-1" || len(got.EventTypes) != 1 || !got.Active {
+		t.Fatalf("subscription %+v, error %v", got, err)
+	}
+	if subscriptions, err := store.ListSubscriptions(ctx); err != nil || len(subscriptions) != 1 {
+		t.Fatalf("listed %d subscriptions, error %v", len(subscriptions), err)
+	}
+
+	if err := store.DeleteSubscription(ctx, subscription.ID); err != nil {
+		t.Fatalf("delete subscription: %v", err)
+	}
+	if _, err := store.GetSubscription(ctx, subscription.ID); !errors.Is(err, ErrSubscriptionNotFound) {
+		t.Fatalf("get after delete returned %v", err)
+	}
+	if err := store.DeleteSubscription(ctx, subscription.ID); !errors.Is(err, ErrSubscriptionNotFound) {
+		t.Fatalf("deleting twice returned %v", err)
+	}
+}
+
+func TestStoreDeliveries(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore(memory.NewDynamoDB(memory.Tables))
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	retention := models.WebhookDeliveryRetention
+	models.WebhookDeliveryRetention = 24 * time.Hour
+	t.Cleanup(func() { models.WebhookDeliveryRetention = retention })
+
+	pending := models.NewWebhookDelivery("subscription-1", "event-1", models.WebhookPurchaseOrderCreated, now)
+	finished := models.NewWebhookDelivery("subscription-1", "event-2", models.WebhookPurchaseOrderApproved, now)
+	finished.Status = models.DeliveryFailed
+	other := models.NewWebhookDelivery("subscription-2", "event-1", models.WebhookPurchaseOrderCreated, now)
+	for _, delivery := range []*models.WebhookDelivery{pending, finished, other} {
+		if err := store.SaveDelivery(ctx, delivery); err != nil {
+			t.Fatalf("save delivery: %v", err)
+		}
+	}
+
+	// Only finished deliveries expire
+	if pending.ExpiresAt != 0 {
+		t.Fatalf("pending delivery expires at %d", pending.ExpiresAt)
+	}
+	if want := now.Add(24 * time.Hour).Unix(); finished.ExpiresAt != want {
+		t.Fatalf("finished delivery expires at %d, want %d", finished.ExpiresAt, want)
+	}
+
+	deliveries, err := store.ListDeliveries(ctx, "subscription-1")
+	if err != nil || len(deliveries) != 2 {
+		t.Fatalf("listed %d deliveries, error %v", len(deliveries), err)
+	}
+	for _, delivery := range deliveries {
+		if delivery.SubscriptionID != "subscription-1" {
+			t.Fatalf("listed a delivery of %s", delivery.SubscriptionID)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+// Internal query API of the OrdenCompra service.
+//
+// Other MediSupply services use it for low-latency purchase order lookups
+// instead of the public REST endpoints. It is served on the gRPC port of
+// orden-compra (GRPC_PORT, 9090 by default) next to the Gin HTTP server.
+//
+// The Go messages and gRPC stubs in pkg/medisupply/api/ordencomprav1 are
+// generated from this file by scripts/generate-proto.sh. Never reuse or
+// renumber a field.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: medisupply/ordencompra/v1/orden_compra.proto
+
+package ordencomprav1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	PurchaseOrderQueryService_GetPurchaseOrder_FullMethodName   = "/medisupply.ordencompra.v1.PurchaseOrderQueryService/GetPurchaseOrder"
+	PurchaseOrderQueryService_ListPurchaseOrders_FullMethodName = "/medisupply.ordencompra.v1.PurchaseOrderQueryService/ListPurchaseOrders"
+	PurchaseOrderQueryService_GetOrderEvents_FullMethodName     = "/medisupply.ordencompra.v1.PurchaseOrderQueryService/GetOrderEvents"
+)
+
+// PurchaseOrderQueryServiceClient is the client API for PurchaseOrderQueryService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type PurchaseOrderQueryServiceClient interface {
+	// GetPurchaseOrder returns one purchase order; NOT_FOUND if it does not exist.
+	GetPurchaseOrder(ctx context.Context, in *GetPurchaseOrderRequest, opts ...grpc.CallOption) (*GetPurchaseOrderResponse, error)
+	// ListPurchaseOrders lists purchase orders matching every filter that is set.
+	ListPurchaseOrders(ctx context.Context, in *ListPurchaseOrdersRequest, opts ...grpc.CallOption) (*ListPurchaseOrdersResponse, error)
+	// GetOrderEvents returns the event-sourcing history of a purchase order.
+	GetOrderEvents(ctx context.Context, in *GetOrderEventsRequest, opts ...grpc.CallOption) (*GetOrderEventsResponse, error)
+}
+
+type purchaseOrderQueryServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewPurchaseOrderQueryServiceClient(cc grpc.ClientConnInterface) PurchaseOrderQueryServiceClient {
+	return &purchaseOrderQueryServiceClient{cc}
+}
+
+func (c *purchaseOrderQueryServiceClient) GetPurchaseOrder(ctx context.Context, in *GetPurchaseOrderRequest, opts ...grpc.CallOption) (*GetPurchaseOrderResponse, error) {
+	out := new(GetPurchaseOrderResponse)
+	err := c.cc.Invoke(ctx, PurchaseOrderQueryService_GetPurchaseOrder_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *purchaseOrderQueryServiceClient) ListPurchaseOrders(ctx context.Context, in *ListPurchaseOrdersRequest, opts ...grpc.CallOption) (*ListPurchaseOrdersResponse, error) {
+	out := new(ListPurchaseOrdersResponse)
+	err := c.cc.Invoke(ctx, PurchaseOrderQueryService_ListPurchaseOrders_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *purchaseOrderQueryServiceClient) GetOrderEvents(ctx context.Context, in *GetOrderEventsRequest, opts ...grpc.CallOption) (*GetOrderEventsResponse, error) {
+	out := new(GetOrderEventsResponse)
+	err := c.cc.Invoke(ctx, PurchaseOrderQueryService_GetOrderEvents_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// PurchaseOrderQueryServiceServer is the server API for PurchaseOrderQueryService service.
+// All implementations must embed UnimplementedPurchaseOrderQueryServiceServer
+// for forward compatibility
+type PurchaseOrderQueryServiceServer interface {
+	// GetPurchaseOrder returns one purchase order; NOT_FOUND if it does not exist.
+	GetPurchaseOrder(context.Context, *GetPurchaseOrderRequest) (*GetPurchaseOrderResponse, error)
+	// ListPurchaseOrders lists purchase orders matching every filter that is set.
+	ListPurchaseOrders(context.Context, *ListPurchaseOrdersRequest) (*ListPurchaseOrdersResponse, error)
+	// GetOrderEvents returns the event-sourcing history of a purchase order.
+	GetOrderEvents(context.Context, *GetOrderEventsRequest) (*GetOrderEventsResponse, error)
+	mustEmbedUnimplementedPurchaseOrderQueryServiceServer()
+}
+
+// UnimplementedPurchaseOrderQueryServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedPurchaseOrderQueryServiceServer struct {
+}
+
+func (UnimplementedPurchaseOrderQueryServiceServer) GetPurchaseOrder(context.Context, *GetPurchaseOrderRequest) (*GetPurchaseOrderResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetPurchaseOrder not implemented")
+}
+func (UnimplementedPurchaseOrderQueryServiceServer) ListPurchaseOrders(context.Context, *ListPurchaseOrdersRequest) (*ListPurchaseOrdersResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListPurchaseOrders not implemented")
+}
+func (UnimplementedPurchaseOrderQueryServiceServer) GetOrderEvents(context.Context, *GetOrderEventsRequest) (*GetOrderEventsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetOrderEvents not implemented")
+}
+func (UnimplementedPurchaseOrderQueryServiceServer) mustEmbedUnimplementedPurchaseOrderQueryServiceServer() {
+}
+
+// UnsafePurchaseOrderQueryServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to PurchaseOrderQueryServiceServer will
+// result in compilation errors.
+type UnsafePurchaseOrderQueryServiceServer interface {
+	mustEmbedUnimplementedPurchaseOrderQueryServiceServer()
+}
+
+func RegisterPurchaseOrderQueryServiceServer(s grpc.ServiceRegistrar, srv PurchaseOrderQueryServiceServer) {
+	s.RegisterService(&PurchaseOrderQueryService_ServiceDesc, srv)
+}
+
+func _PurchaseOrderQueryService_GetPurchaseOrder_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetPurchaseOrderRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PurchaseOrderQueryServiceServer).GetPurchaseOrder(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PurchaseOrderQueryService_GetPurchaseOrder_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PurchaseOrderQueryServiceServer).GetPurchaseOrder(ctx, req.(*GetPurchaseOrderRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PurchaseOrderQueryService_ListPurchaseOrders_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListPurchaseOrdersRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PurchaseOrderQueryServiceServer).ListPurchaseOrders(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PurchaseOrderQueryService_ListPurchaseOrders_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PurchaseOrderQueryServiceServer).ListPurchaseOrders(ctx, req.(*ListPurchaseOrdersRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PurchaseOrderQueryService_GetOrderEvents_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetOrderEventsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PurchaseOrderQueryServiceServer).GetOrderEvents(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PurchaseOrderQueryService_GetOrderEvents_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PurchaseOrderQueryServiceServer).GetOrderEvents(ctx, req.(*GetOrderEventsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// PurchaseOrderQueryService_ServiceDesc is the grpc.ServiceDesc for PurchaseOrderQueryService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var PurchaseOrderQueryService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "medisupply.ordencompra.v1.PurchaseOrderQueryService",
+	HandlerType: (*PurchaseOrderQueryServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetPurchaseOrder",
+			Handler:    _PurchaseOrderQueryService_GetPurchaseOrder_Handler,
+		},
+		{
+			MethodName: "ListPurchaseOrders",
+			Handler:    _PurchaseOrderQueryService_ListPurchaseOrders_Handler,
+		},
+		{
+			MethodName: "GetOrderEvents",
+			Handler:    _PurchaseOrderQueryService_GetOrderEvents_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "medisupply/ordencompra/v1/orden_compra.proto",
+}
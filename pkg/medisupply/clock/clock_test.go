@@ -0,0 +1,30 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFake(t *testing.T) {
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	fake := NewFake(start)
+	if !fake.Now().Equal(start) || !fake.Now().Equal(start) {
+		t.Fatalf("fake clock moved on its own to %s", fake.Now())
+	}
+
+	if now := fake.Advance(90 * time.Minute); !now.Equal(start.Add(90*time.Minute)) || !fake.Now().Equal(now) {
+		t.Fatalf("advanced to %s, now %s", now, fake.Now())
+	}
+	fake.Set(start.Add(-time.Hour))
+	if !fake.Now().Equal(start.Add(-time.Hour)) {
+		t.Fatalf("set to %s", fake.Now())
+	}
+}
+
+func TestSystem(t *testing.T) {
+	before := time.Now()
+	now := System.Now()
+	if now.Before(before) || now.After(time.Now()) {
+		t.Fatalf("system clock told %s", now)
+	}
+}
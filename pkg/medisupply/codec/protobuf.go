@@ -0,0 +1,72 @@
+// Package codec holds the helpers the services convert their events to and
+// from the medisupply.events.v1 messages with. The messages are generated
+// into eventsv1 from proto/medisupply/events/v1/events.proto; the gRPC
+// query messages in api/ordencomprav1 use the same timestamp and metadata
+// conventions.
+package codec
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Timestamp converts t to a google.protobuf.Timestamp, leaving the zero time
+// unset as proto3 leaves out zero values
+func Timestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+// OptionalTimestamp converts an optional time to a google.protobuf.Timestamp
+func OptionalTimestamp(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return Timestamp(*t)
+}
+
+// Time converts a google.protobuf.Timestamp to a UTC time, the zero time when unset
+func Time(ts *timestamppb.Timestamp) time.Time {
+	if ts == nil {
+		return time.Time{}
+	}
+	return ts.AsTime()
+}
+
+// OptionalTime converts a google.protobuf.Timestamp to a UTC time, nil when unset
+func OptionalTime(ts *timestamppb.Timestamp) *time.Time {
+	if ts == nil {
+		return nil
+	}
+	t := ts.AsTime()
+	return &t
+}
+
+// EncodeMetadata encodes a metadata map as the JSON the metadata fields carry
+func EncodeMetadata(m map[string]interface{}) ([]byte, error) {
+	if len(m) == 0 {
+		return nil, nil
+	}
+	raw, err := json.Marshal(m)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode metadata: %w", err)
+	}
+	return raw, nil
+}
+
+// DecodeMetadata decodes a JSON-encoded metadata field, an empty map when unset
+func DecodeMetadata(raw []byte) (map[string]interface{}, error) {
+	m := make(map[string]interface{})
+	if len(raw) == 0 {
+		return m, nil
+	}
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return nil, fmt.Errorf("failed to decode metadata: %w", err)
+	}
+	return m, nil
+}
@@ -0,0 +1,50 @@
+package codec
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTimestamps(t *testing.T) {
+	at := time.Date(2024, 3, 1, 9, 30, 0, 500, time.FixedZone("COT", -5*3600))
+	if got := Time(Timestamp(at)); !got.Equal(at) || got.Location() != time.UTC {
+		t.Fatalf("round trip of %s gave %s", at, got)
+	}
+	if got := OptionalTime(OptionalTimestamp(&at)); got == nil || !got.Equal(at) {
+		t.Fatalf("optional round trip of %s gave %v", at, got)
+	}
+
+	// Unset timestamps stand for the zero time and nil
+	if Timestamp(time.Time{}) != nil || OptionalTimestamp(nil) != nil {
+		t.Fatal("zero times were encoded")
+	}
+	if !Time(nil).IsZero() || OptionalTime(nil) != nil {
+		t.Fatal("unset timestamps did not decode as unset")
+	}
+}
+
+func TestMetadata(t *testing.T) {
+	metadata := map[string]interface{}{"correlation_id": "correlation-1", "attempt": float64(2)}
+	raw, err := EncodeMetadata(metadata)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	decoded, err := DecodeMetadata(raw)
+	if err != nil || !reflect.DeepEqual(decoded, metadata) {
+		t.Fatalf("decoded %v, error %v", decoded, err)
+	}
+
+	if raw, err := EncodeMetadata(nil); raw != nil || err != nil {
+		t.Fatalf("empty metadata encoded as %q, error %v", raw, err)
+	}
+	if decoded, err := DecodeMetadata(nil); err != nil || decoded == nil || len(decoded) != 0 {
+		t.Fatalf("unset metadata decoded as %v, error %v", decoded, err)
+	}
+	if _, err := EncodeMetadata(map[string]interface{}{"callback": func() {}}); err == nil {
+		t.Fatal("encoded metadata that is not JSON")
+	}
+	if _, err := DecodeMetadata([]byte("[1]")); err == nil {
+		t.Fatal("decoded metadata that is not an object")
+	}
+}
@@ -0,0 +1,122 @@
+package correlation
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// HTTP headers carrying the request and correlation IDs
+const (
+	RequestIDHeader     = "X-Request-ID"
+	CorrelationIDHeader = "X-Correlation-ID"
+)
+
+// Metadata keys events record their correlation and causation IDs under
+const (
+	CorrelationIDKey = "correlation_id"
+	CausationIDKey   = "causation_id"
+)
+
+// maxIDLength bounds client-supplied IDs so they cannot bloat logs and events
+const maxIDLength = 128
+
+// IDs identify a unit of work. The request ID is unique per HTTP request or
+// message; the correlation ID is shared by everything caused by the same
+// original request, across services and queues.
+type IDs struct {
+	RequestID     string
+	CorrelationID string
+}
+
+type idsKey struct{}
+
+// NewContext returns a copy of ctx carrying the IDs
+func NewContext(ctx context.Context, ids IDs) context.Context {
+	return context.WithValue(ctx, idsKey{}, ids)
+}
+
+// FromContext returns the IDs carried by ctx, if any
+func FromContext(ctx context.Context) (IDs, bool) {
+	ids, ok := ctx.Value(idsKey{}).(IDs)
+	return ids, ok
+}
+
+// CorrelationID returns the correlation ID in ctx in the form commands expect,
+// or nil when there is none
+func CorrelationID(ctx context.Context) *string {
+	if ids, ok := FromContext(ctx); ok && ids.CorrelationID != "" {
+		return &ids.CorrelationID
+	}
+	return nil
+}
+
+// CausationID returns the request ID in ctx as the cause of the commands it
+// executes, or nil when there is none
+func CausationID(ctx context.Context) *string {
+	if ids, ok := FromContext(ctx); ok && ids.RequestID != "" {
+		return &ids.RequestID
+	}
+	return nil
+}
+
+// FromMetadata returns the ID recorded under key in an event's metadata, or
+// nil when there is none. Metadata built in process holds *string IDs and
+// decoded metadata holds strings; both are accepted.
+func FromMetadata(metadata map[string]interface{}, key string) *string {
+	switch id := metadata[key].(type) {
+	case *string:
+		if id != nil && *id != "" {
+			return id
+		}
+	case string:
+		if id != "" {
+			return &id
+		}
+	}
+	return nil
+}
+
+// Sanitize returns id when it is a usable client-supplied ID, otherwise a new one
+func Sanitize(id string) string {
+	if id == "" || len(id) > maxIDLength {
+		return uuid.New().String()
+	}
+	for _, r := range id {
+		if r < 0x21 || r > 0x7e {
+			return uuid.New().String()
+		}
+	}
+	return id
+}
+
+// Middleware accepts or creates X-Request-ID and X-Correlation-ID, echoes them
+// in the response, stores them in the request context and logs each request
+// with them. A request without a correlation ID starts a new chain rooted at
+// its request ID.
+func Middleware(logger *log.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		ids := IDs{RequestID: Sanitize(c.GetHeader(RequestIDHeader))}
+		if header := c.GetHeader(CorrelationIDHeader); header != "" {
+			ids.CorrelationID = Sanitize(header)
+		} else {
+			ids.CorrelationID = ids.RequestID
+		}
+
+		c.Header(RequestIDHeader, ids.RequestID)
+		c.Header(CorrelationIDHeader, ids.CorrelationID)
+		c.Set("request_id", ids.RequestID)
+		c.Set("correlation_id", ids.CorrelationID)
+		c.Request = c.Request.WithContext(NewContext(c.Request.Context(), ids))
+
+		c.Next()
+
+		logger.Printf("HTTP request - method: %s, path: %s, status: %d, duration: %v, client_ip: %s, request_id: %s, correlation_id: %s",
+			c.Request.Method, c.Request.URL.Path, c.Writer.Status(), time.Since(start), c.ClientIP(), ids.RequestID, ids.CorrelationID)
+	}
+}
@@ -0,0 +1,117 @@
+package correlation
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	for _, tc := range []struct {
+		name          string
+		requestID     string
+		correlationID string
+		// wantRequestID and wantCorrelationID are empty when a new ID is expected
+		wantRequestID     string
+		wantCorrelationID string
+	}{
+		{"client IDs are kept", "request-1", "correlation-1", "request-1", "correlation-1"},
+		{"a new chain is rooted at the request", "request-1", "", "request-1", "request-1"},
+		{"missing IDs are created", "", "", "", ""},
+		{"unusable IDs are replaced", "request 1", strings.Repeat("c", maxIDLength+1), "", ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			var ids IDs
+			router := gin.New()
+			router.Use(Middleware(log.New(&logs, "", 0)))
+			router.GET("/", func(c *gin.Context) { ids, _ = FromContext(c.Request.Context()) })
+
+			request := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.requestID != "" {
+				request.Header.Set(RequestIDHeader, tc.requestID)
+			}
+			if tc.correlationID != "" {
+				request.Header.Set(CorrelationIDHeader, tc.correlationID)
+			}
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, request)
+
+			if ids.RequestID == "" || ids.CorrelationID == "" {
+				t.Fatalf("context IDs %+v", ids)
+			}
+			if tc.wantRequestID != "" && ids.RequestID != tc.wantRequestID {
+				t.Fatalf("request ID %q, want %q", ids.RequestID, tc.wantRequestID)
+			}
+			if tc.wantCorrelationID != "" && ids.CorrelationID != tc.wantCorrelationID {
+				t.Fatalf("correlation ID %q, want %q", ids.CorrelationID, tc.wantCorrelationID)
+			}
+			if ids.RequestID == tc.requestID && tc.wantRequestID == "" {
+				t.Fatalf("unusable request ID %q was kept", tc.requestID)
+			}
+			if recorder.Header().Get(RequestIDHeader) != ids.RequestID || recorder.Header().Get(CorrelationIDHeader) != ids.CorrelationID {
+				t.Fatalf("response headers %v, want %+v", recorder.Header(), ids)
+			}
+			if !strings.Contains(logs.String(), "correlation_id: "+ids.CorrelationID) {
+				t.Fatalf("request log %q", logs.String())
+			}
+		})
+	}
+}
+
+func TestSanitize(t *testing.T) {
+	if id := Sanitize("order-123_ABC"); id != "order-123_ABC" {
+		t.Fatalf("usable ID replaced by %q", id)
+	}
+	for _, id := range []string{"", "with space", "tab\there", "línea", strings.Repeat("a", maxIDLength+1)} {
+		if sanitized := Sanitize(id); sanitized == id || len(sanitized) != 36 {
+			t.Fatalf("Sanitize(%q) = %q, want a new ID", id, sanitized)
+		}
+	}
+}
+
+func TestContextIDs(t *testing.T) {
+	if CorrelationID(context.Background()) != nil || CausationID(context.Background()) != nil {
+		t.Fatal("IDs returned for a context without them")
+	}
+
+	ctx := NewContext(context.Background(), IDs{RequestID: "request-1", CorrelationID: "correlation-1"})
+	if id := CorrelationID(ctx); id == nil || *id != "correlation-1" {
+		t.Fatalf("correlation ID %v", id)
+	}
+	if id := CausationID(ctx); id == nil || *id != "request-1" {
+		t.Fatalf("causation ID %v", id)
+	}
+}
+
+func TestFromMetadata(t *testing.T) {
+	id := "correlation-1"
+	empty := ""
+	for _, tc := range []struct {
+		name  string
+		value interface{}
+		want  string
+	}{
+		{"pointer", &id, "correlation-1"},
+		{"decoded string", "correlation-1", "correlation-1"},
+		{"empty pointer", &empty, ""},
+		{"nil pointer", (*string)(nil), ""},
+		{"empty string", "", ""},
+		{"other type", 42, ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := FromMetadata(map[string]interface{}{CorrelationIDKey: tc.value}, CorrelationIDKey)
+			if (got == nil) != (tc.want == "") || (got != nil && *got != tc.want) {
+				t.Fatalf("FromMetadata returned %v, want %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+// Package env loads service settings from environment variables. Unset
+// variables take their default, and malformed ones are logged and take it
+// as well, so a typo in one setting does not keep a service from starting.
+package env
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// String gets an environment variable with a default value
+func String(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// Int gets an integer environment variable with a default value
+func Int(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil {
+			return parsed
+		}
+		log.Printf("Invalid integer for %s: %q, using default %d", key, value, defaultValue)
+	}
+	return defaultValue
+}
+
+// Float gets a numeric environment variable with a default value
+func Float(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+			return parsed
+		}
+		log.Printf("Invalid number for %s: %q, using default %v", key, value, defaultValue)
+	}
+	return defaultValue
+}
+
+// Duration gets a duration environment variable with a default value
+func Duration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := time.ParseDuration(value); err == nil {
+			return parsed
+		}
+		log.Printf("Invalid duration for %s: %q, using default %v", key, value, defaultValue)
+	}
+	return defaultValue
+}
+
+// List gets a comma-separated environment variable as a list
+func List(key string) []string {
+	return SplitList(os.Getenv(key))
+}
+
+// SplitList splits a comma-separated setting, dropping empty entries
+func SplitList(list string) []string {
+	var values []string
+	for _, value := range strings.Split(list, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// Secret gets a secret from the file named by the key's _FILE variable,
+// such as a mounted Kubernetes secret, or else from the variable. A file
+// that cannot be read is fatal, as the service would otherwise start
+// without its credentials.
+func Secret(key string) string {
+	if path := os.Getenv(key + "_FILE"); path != "" {
+		value, err := os.ReadFile(path)
+		if err != nil {
+			log.Fatalf("Failed to read %s_FILE: %v", key, err)
+		}
+		return strings.TrimSpace(string(value))
+	}
+	return os.Getenv(key)
+}
@@ -0,0 +1,68 @@
+package env
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSettingsTakeTheirDefault(t *testing.T) {
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	t.Setenv("ORDERS_NAME", "")
+	t.Setenv("ORDERS_LIMIT", "")
+	if String("ORDERS_NAME", "orders") != "orders" || Int("ORDERS_LIMIT", 10) != 10 || Float("ORDERS_RATIO", 0.5) != 0.5 || Duration("ORDERS_TIMEOUT", time.Second) != time.Second {
+		t.Fatal("unset variables did not take their default")
+	}
+
+	t.Setenv("ORDERS_NAME", "purchase-orders")
+	t.Setenv("ORDERS_LIMIT", "25")
+	t.Setenv("ORDERS_RATIO", "0.75")
+	t.Setenv("ORDERS_TIMEOUT", "1m30s")
+	if String("ORDERS_NAME", "orders") != "purchase-orders" || Int("ORDERS_LIMIT", 10) != 25 || Float("ORDERS_RATIO", 0.5) != 0.75 || Duration("ORDERS_TIMEOUT", time.Second) != 90*time.Second {
+		t.Fatal("set variables were not read")
+	}
+
+	// A malformed setting takes its default rather than stopping the service
+	t.Setenv("ORDERS_LIMIT", "ten")
+	t.Setenv("ORDERS_RATIO", "half")
+	t.Setenv("ORDERS_TIMEOUT", "90")
+	if Int("ORDERS_LIMIT", 10) != 10 || Float("ORDERS_RATIO", 0.5) != 0.5 || Duration("ORDERS_TIMEOUT", time.Second) != time.Second {
+		t.Fatal("malformed variables did not take their default")
+	}
+}
+
+func TestList(t *testing.T) {
+	t.Setenv("ORDERS_ORIGINS", " https://a.example.com, ,https://b.example.com,")
+	if got, want := List("ORDERS_ORIGINS"), []string{"https://a.example.com", "https://b.example.com"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("List() = %q, want %q", got, want)
+	}
+	if got := SplitList(""); got != nil {
+		t.Fatalf("SplitList(\"\") = %q, want nil", got)
+	}
+}
+
+func TestSecret(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "password")
+	if err := os.WriteFile(path, []byte("from-file\n"), 0o600); err != nil {
+		t.Fatalf("write secret: %v", err)
+	}
+
+	t.Setenv("RABBITMQ_PASSWORD", "from-variable")
+	if got := Secret("RABBITMQ_PASSWORD"); got != "from-variable" {
+		t.Fatalf("Secret() = %q, want the variable", got)
+	}
+	// The file takes precedence, without its trailing newline
+	t.Setenv("RABBITMQ_PASSWORD_FILE", path)
+	if got := Secret("RABBITMQ_PASSWORD"); got != "from-file" {
+		t.Fatalf("Secret() = %q, want the file", got)
+	}
+	if got := Secret("RABBITMQ_USERNAME"); got != "" {
+		t.Fatalf("Secret() of an unset key = %q", got)
+	}
+}
@@ -0,0 +1,274 @@
+// Package errortracking reports errors and panics to Sentry, or to any
+// service accepting its envelope API such as GlitchTip, so production
+// failures are grouped and alerted on rather than only written to container
+// logs. Reporting is optional: without a DSN the reporter is nil and every
+// method does nothing.
+package errortracking
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"go.opentelemetry.io/otel/trace"
+
+	"medisupply/correlation"
+	"medisupply/env"
+)
+
+// Levels of reported events
+const (
+	LevelFatal   = "fatal"
+	LevelError   = "error"
+	LevelWarning = "warning"
+)
+
+// module is this package's import path, whose frames are left out of the
+// stack traces reported
+const module = "medisupply/errortracking"
+
+// ErrInvalidDSN is returned for DSNs that do not name a key and a project
+var ErrInvalidDSN = errors.New("invalid error tracking DSN")
+
+// Config represents where and how errors are reported
+type Config struct {
+	// DSN is the project's client key URL, https://key@host/project-id;
+	// reporting is disabled when empty
+	DSN         string
+	Environment string
+	Release     string
+	// SampleRate is the share of events sent; 0 sends them all, as with
+	// Sentry's SDKs
+	SampleRate float64
+	// Timeout bounds each request to the tracking service
+	Timeout time.Duration
+	// Scrub masks personal data and secrets in messages and error texts
+	// before they leave the service; optional
+	Scrub func(string) string
+}
+
+// ConfigFromEnv reads the settings from the variables Sentry's own SDKs use:
+// SENTRY_DSN, SENTRY_ENVIRONMENT (ENVIRONMENT by default), SENTRY_RELEASE
+// and SENTRY_SAMPLE_RATE
+func ConfigFromEnv() Config {
+	return Config{
+		DSN:         env.String("SENTRY_DSN", ""),
+		Environment: env.String("SENTRY_ENVIRONMENT", env.String("ENVIRONMENT", "")),
+		Release:     env.String("SENTRY_RELEASE", ""),
+		SampleRate:  env.Float("SENTRY_SAMPLE_RATE", 1),
+		Timeout:     env.Duration("SENTRY_TIMEOUT", 5*time.Second),
+	}
+}
+
+// Reporter sends events to the tracking service in the background through
+// a Sentry client of its own, so services never share a global hub. A nil
+// Reporter is valid and reports nothing.
+type Reporter struct {
+	config  Config
+	service string
+	client  *sentry.Client
+	logger  *log.Logger
+
+	closeOnce sync.Once
+}
+
+// New creates a reporter for the service and starts sending its events. It
+// returns nil, and no error, when no DSN is configured.
+func New(serviceName string, config Config, logger *log.Logger) (*Reporter, error) {
+	if config.DSN == "" {
+		return nil, nil
+	}
+	dsn, err := sentry.NewDsn(config.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDSN, err)
+	}
+	if config.SampleRate < 0 || config.SampleRate > 1 {
+		return nil, fmt.Errorf("error tracking sample rate must be between 0 and 1, got %v", config.SampleRate)
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 5 * time.Second
+	}
+
+	r := &Reporter{config: config, service: serviceName, logger: logger}
+	r.client, err = sentry.NewClient(sentry.ClientOptions{
+		Dsn:         config.DSN,
+		Environment: config.Environment,
+		Release:     config.Release,
+		SampleRate:  config.SampleRate,
+		HTTPClient:  &http.Client{Timeout: config.Timeout},
+		BeforeSend:  r.beforeSend,
+		// The default integrations read the modules and environment of the
+		// process; events carry only what the reporter sets
+		Integrations: func([]sentry.Integration) []sentry.Integration { return nil },
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create error tracking client: %w", err)
+	}
+	logger.Printf("Error tracking enabled - host: %s, environment: %s, sample_rate: %v", dsn.GetHost(), config.Environment, config.SampleRate)
+	return r, nil
+}
+
+type tagsKey struct{}
+
+// WithTags returns a copy of ctx whose reported events carry the tags, on
+// top of those ctx already carries, such as the IDs of the order or event
+// being processed
+func WithTags(ctx context.Context, tags map[string]string) context.Context {
+	merged := make(map[string]string)
+	if existing, ok := ctx.Value(tagsKey{}).(map[string]string); ok {
+		for key, value := range existing {
+			merged[key] = value
+		}
+	}
+	for key, value := range tags {
+		if value != "" {
+			merged[key] = value
+		}
+	}
+	return context.WithValue(ctx, tagsKey{}, merged)
+}
+
+// CaptureError reports an error, tagged with the correlation and trace IDs
+// of ctx, the tags ctx carries and tags
+func (r *Reporter) CaptureError(ctx context.Context, err error, tags map[string]string) {
+	if r == nil || err == nil {
+		return
+	}
+	r.client.CaptureException(err, &sentry.EventHint{Context: ctx, OriginalException: err}, r.scope(ctx, sentry.LevelError, tags, nil))
+}
+
+// CaptureMessage reports an event without an error, grouped by the
+// fingerprint when one is given rather than by message
+func (r *Reporter) CaptureMessage(ctx context.Context, level, message string, tags map[string]string, fingerprint ...string) {
+	if r == nil {
+		return
+	}
+	r.client.CaptureMessage(message, &sentry.EventHint{Context: ctx}, r.scope(ctx, sentry.Level(level), tags, fingerprint))
+}
+
+// CapturePanic reports a recovered panic with the stack that raised it
+func (r *Reporter) CapturePanic(ctx context.Context, recovered interface{}, tags map[string]string) {
+	if r == nil || recovered == nil {
+		return
+	}
+	value := fmt.Sprint(recovered)
+	if err, ok := recovered.(error); ok {
+		value = err.Error()
+	}
+	event := sentry.NewEvent()
+	event.Level = sentry.LevelFatal
+	event.Exception = []sentry.Exception{{
+		Type:       "panic",
+		Value:      value,
+		Stacktrace: sentry.NewStacktrace(),
+		Mechanism:  &sentry.Mechanism{Type: "panic", Handled: sentry.Pointer(false)},
+	}}
+	r.client.CaptureEvent(event, &sentry.EventHint{Context: ctx, RecoveredException: recovered}, r.scope(ctx, sentry.LevelFatal, tags, nil))
+}
+
+// Recover reports a panic unwinding the calling goroutine and panics again,
+// waiting for the report to be sent first since the process is likely to
+// exit. Use it deferred: defer reporter.Recover(ctx, tags).
+func (r *Reporter) Recover(ctx context.Context, tags map[string]string) {
+	recovered := recover()
+	if recovered == nil {
+		return
+	}
+	r.CapturePanic(ctx, recovered, tags)
+	r.Flush(2 * time.Second)
+	panic(recovered)
+}
+
+// Flush waits up to timeout for the queued events to be sent, reporting
+// whether they all were
+func (r *Reporter) Flush(timeout time.Duration) bool {
+	if r == nil {
+		return true
+	}
+	return r.client.Flush(timeout)
+}
+
+// Close sends the queued events, waiting up to timeout, and stops reporting
+func (r *Reporter) Close(timeout time.Duration) {
+	if r == nil {
+		return
+	}
+	r.closeOnce.Do(func() {
+		if !r.client.Flush(timeout) {
+			r.logger.Println("Error tracking closed before every event was sent")
+		}
+		r.client.Close()
+	})
+}
+
+// scope returns the scope of an event: its level and fingerprint, tagged
+// with the service, ctx's IDs and tags, and linked to ctx's trace
+func (r *Reporter) scope(ctx context.Context, level sentry.Level, tags map[string]string, fingerprint []string) *sentry.Scope {
+	merged := map[string]string{"service": r.service}
+	if ids, ok := correlation.FromContext(ctx); ok {
+		merged["correlation_id"] = ids.CorrelationID
+		merged["request_id"] = ids.RequestID
+	}
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+		merged["trace_id"] = spanContext.TraceID().String()
+	}
+	if existing, ok := ctx.Value(tagsKey{}).(map[string]string); ok {
+		for key, value := range existing {
+			merged[key] = value
+		}
+	}
+	for key, value := range tags {
+		if value != "" {
+			merged[key] = value
+		}
+	}
+	for key, value := range merged {
+		if value == "" {
+			delete(merged, key)
+		}
+	}
+
+	scope := sentry.NewScope()
+	scope.SetLevel(level)
+	scope.SetTags(merged)
+	if len(fingerprint) > 0 {
+		scope.SetFingerprint(fingerprint)
+	}
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+		propagation := sentry.NewPropagationContext()
+		propagation.TraceID = sentry.TraceID(spanContext.TraceID())
+		propagation.SpanID = sentry.SpanID(spanContext.SpanID())
+		scope.SetPropagationContext(propagation)
+	}
+	return scope
+}
+
+// beforeSend scrubs an event's texts and drops the reporter's own frames
+// from its stack traces
+func (r *Reporter) beforeSend(event *sentry.Event, hint *sentry.EventHint) *sentry.Event {
+	event.Logger = r.service
+	scrub := r.config.Scrub
+	if scrub != nil {
+		event.Message = scrub(event.Message)
+	}
+	for i := range event.Exception {
+		if scrub != nil {
+			event.Exception[i].Value = scrub(event.Exception[i].Value)
+		}
+		if stacktrace := event.Exception[i].Stacktrace; stacktrace != nil {
+			frames := stacktrace.Frames[:0]
+			for _, frame := range stacktrace.Frames {
+				if frame.Module != module {
+					frames = append(frames, frame)
+				}
+			}
+			stacktrace.Frames = frames
+		}
+	}
+	return event
+}
@@ -0,0 +1,234 @@
+package errortracking
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/gin-gonic/gin"
+
+	"medisupply/correlation"
+)
+
+// received is an envelope the tracking service accepted
+type received struct {
+	auth  string
+	event sentry.Event
+}
+
+// newTrackingService starts a server accepting envelopes on a project's
+// endpoint, and a reporter sending to it
+func newTrackingService(t *testing.T, scrub func(string) string) (*Reporter, <-chan received) {
+	t.Helper()
+	events := make(chan received, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/42/envelope/" {
+			t.Errorf("envelope posted to %s", r.URL.Path)
+		}
+		body, _ := io.ReadAll(r.Body)
+		// An envelope is a header line, then an item header and payload per item
+		scanner := bufio.NewScanner(bytes.NewReader(body))
+		scanner.Buffer(nil, 1<<20)
+		scanner.Scan()
+		for scanner.Scan() {
+			var item struct {
+				Type string `json:"type"`
+			}
+			if err := json.Unmarshal(scanner.Bytes(), &item); err != nil {
+				t.Errorf("item header: %v", err)
+				break
+			}
+			scanner.Scan()
+			if item.Type != "event" {
+				continue
+			}
+			var event sentry.Event
+			if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+				t.Errorf("event payload: %v", err)
+				break
+			}
+			events <- received{auth: r.Header.Get("X-Sentry-Auth"), event: event}
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+
+	dsn := strings.Replace(server.URL, "http://", "http://public-key@", 1) + "/42"
+	reporter, err := New("orden-compra", Config{DSN: dsn, Environment: "test", Release: "1.2.3", Scrub: scrub}, log.New(io.Discard, "", 0))
+	if err != nil {
+		t.Fatalf("new reporter: %v", err)
+	}
+	t.Cleanup(func() { reporter.Close(time.Second) })
+	return reporter, events
+}
+
+func receive(t *testing.T, reporter *Reporter, events <-chan received) received {
+	t.Helper()
+	if !reporter.Flush(5 * time.Second) {
+		t.Fatal("events not sent")
+	}
+	select {
+	case got := <-events:
+		return got
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event received")
+	}
+	return received{}
+}
+
+func TestCaptureErrorSendsTheEventEnvelope(t *testing.T) {
+	reporter, events := newTrackingService(t, func(s string) string {
+		return strings.ReplaceAll(s, "buyer@example.com", "[email]")
+	})
+
+	ctx := correlation.NewContext(context.Background(), correlation.IDs{RequestID: "request-1", CorrelationID: "correlation-1"})
+	ctx = WithTags(ctx, map[string]string{"tenant_id": "tenant-1", "queue": ""})
+	cause := errors.New("supplier buyer@example.com rejected the order")
+	reporter.CaptureError(ctx, fmt.Errorf("failed to place order: %w", cause), map[string]string{"purchase_order_id": "po-1"})
+
+	got := receive(t, reporter, events)
+	if !strings.Contains(got.auth, "sentry_key=public-key") {
+		t.Errorf("auth header %q does not carry the key", got.auth)
+	}
+	event := got.event
+	if event.Level != sentry.LevelError || event.Environment != "test" || event.Release != "1.2.3" {
+		t.Errorf("level %q, environment %q, release %q", event.Level, event.Environment, event.Release)
+	}
+	for key, want := range map[string]string{
+		"service":           "orden-compra",
+		"correlation_id":    "correlation-1",
+		"request_id":        "request-1",
+		"tenant_id":         "tenant-1",
+		"purchase_order_id": "po-1",
+	} {
+		if event.Tags[key] != want {
+			t.Errorf("tag %s = %q, want %q", key, event.Tags[key], want)
+		}
+	}
+	if _, ok := event.Tags["queue"]; ok {
+		t.Error("empty tag sent")
+	}
+
+	// The chain is sent innermost error first, every text scrubbed
+	if len(event.Exception) != 2 {
+		t.Fatalf("sent %d exceptions, want the error and its cause", len(event.Exception))
+	}
+	if event.Exception[0].Type != "*errors.errorString" || event.Exception[0].Value != "supplier [email] rejected the order" {
+		t.Errorf("cause sent as %s: %q", event.Exception[0].Type, event.Exception[0].Value)
+	}
+	if value := event.Exception[1].Value; value != "failed to place order: supplier [email] rejected the order" {
+		t.Errorf("error sent as %q", value)
+	}
+	for _, exception := range event.Exception {
+		if exception.Stacktrace == nil {
+			continue
+		}
+		for _, frame := range exception.Stacktrace.Frames {
+			if frame.Function == "(*Reporter).CaptureError" {
+				t.Errorf("stack trace includes the reporter's own frames")
+			}
+		}
+	}
+}
+
+func TestCaptureMessageUsesTheFingerprint(t *testing.T) {
+	reporter, events := newTrackingService(t, nil)
+
+	reporter.CaptureMessage(context.Background(), LevelWarning, "Message rejected to dead letter queue", nil, "dead-letter", "invalid")
+
+	event := receive(t, reporter, events).event
+	if event.Level != sentry.LevelWarning || event.Message != "Message rejected to dead letter queue" {
+		t.Errorf("sent %s message %q", event.Level, event.Message)
+	}
+	if strings.Join(event.Fingerprint, ",") != "dead-letter,invalid" {
+		t.Errorf("fingerprint %v", event.Fingerprint)
+	}
+}
+
+func TestMiddlewareReportsPanicsAndLeavesTheAnswerToRecovery(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	reporter, events := newTrackingService(t, nil)
+
+	router := gin.New()
+	router.Use(gin.CustomRecoveryWithWriter(io.Discard, func(c *gin.Context, recovered interface{}) {
+		c.AbortWithStatus(http.StatusInternalServerError)
+	}), reporter.Middleware())
+	router.GET("/orders/:id", func(c *gin.Context) {
+		panic("nil supplier")
+	})
+
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/orders/po-1", nil))
+	if response.Code != http.StatusInternalServerError {
+		t.Fatalf("status %d, want the recovery middleware's 500", response.Code)
+	}
+
+	event := receive(t, reporter, events).event
+	if event.Level != sentry.LevelFatal {
+		t.Errorf("level %q, want fatal", event.Level)
+	}
+	if event.Tags["http.route"] != "/orders/:id" || event.Tags["http.param.id"] != "po-1" || event.Tags["http.method"] != http.MethodGet {
+		t.Errorf("tags %v", event.Tags)
+	}
+	if len(event.Exception) != 1 {
+		t.Fatalf("sent %d exceptions, want the panic", len(event.Exception))
+	}
+	exception := event.Exception[0]
+	if exception.Type != "panic" || exception.Value != "nil supplier" {
+		t.Errorf("panic sent as %s: %q", exception.Type, exception.Value)
+	}
+	if exception.Mechanism == nil || exception.Mechanism.Handled == nil || *exception.Mechanism.Handled {
+		t.Errorf("panic not marked unhandled: %+v", exception.Mechanism)
+	}
+}
+
+func TestMiddlewareReportsServerErrors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	reporter, events := newTrackingService(t, nil)
+
+	router := gin.New()
+	router.Use(reporter.Middleware())
+	router.POST("/orders/:id/cancel", func(c *gin.Context) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "order store unavailable"})
+	})
+
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, httptest.NewRequest(http.MethodPost, "/orders/po-1/cancel", nil))
+
+	event := receive(t, reporter, events).event
+	if event.Message != "POST /orders/:id/cancel: order store unavailable" {
+		t.Errorf("message %q", event.Message)
+	}
+	if strings.Join(event.Fingerprint, " ") != "http POST /orders/:id/cancel" {
+		t.Errorf("fingerprint %v, want the route's", event.Fingerprint)
+	}
+}
+
+func TestReportingIsOptional(t *testing.T) {
+	reporter, err := New("orden-compra", Config{}, log.New(io.Discard, "", 0))
+	if err != nil || reporter != nil {
+		t.Fatalf("new without a DSN returned %v, %v", reporter, err)
+	}
+	// A nil reporter accepts every call
+	reporter.CaptureError(context.Background(), errors.New("ignored"), nil)
+	reporter.CaptureMessage(context.Background(), LevelError, "ignored", nil)
+	if !reporter.Flush(time.Second) {
+		t.Fatal("nil reporter did not flush")
+	}
+	reporter.Close(time.Second)
+
+	if _, err := New("orden-compra", Config{DSN: "https://sentry.example.com"}, log.New(io.Discard, "", 0)); !errors.Is(err, ErrInvalidDSN) {
+		t.Fatalf("DSN without key or project returned %v, want ErrInvalidDSN", err)
+	}
+}
@@ -0,0 +1,92 @@
+package errortracking
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxErrorBody bounds the response body read for the error of a failed request
+const maxErrorBody = 4096
+
+// errorRecorder keeps the start of the body of internal server errors
+type errorRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *errorRecorder) Write(data []byte) (int, error) {
+	w.record(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *errorRecorder) WriteString(s string) (int, error) {
+	w.record([]byte(s))
+	return w.ResponseWriter.Write([]byte(s))
+}
+
+func (w *errorRecorder) record(data []byte) {
+	if w.Status() != http.StatusInternalServerError {
+		return
+	}
+	if room := maxErrorBody - w.body.Len(); room > 0 {
+		if len(data) > room {
+			data = data[:room]
+		}
+		w.body.Write(data)
+	}
+}
+
+// Middleware reports panics and internal server errors of HTTP requests,
+// tagged with the method, route and path parameters. Panics are raised
+// again for the recovery middleware, which must come before it, to answer.
+// Other server errors, such as unavailable dependencies, are left to
+// metrics and logs.
+func (r *Reporter) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if r == nil {
+			c.Next()
+			return
+		}
+
+		recorder := &errorRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				r.CapturePanic(c.Request.Context(), recovered, requestTags(c))
+				panic(recovered)
+			}
+		}()
+
+		c.Next()
+
+		if recorder.Status() != http.StatusInternalServerError {
+			return
+		}
+		message := "internal server error"
+		var body struct {
+			Error string `json:"error"`
+		}
+		if err := json.Unmarshal(recorder.body.Bytes(), &body); err == nil && body.Error != "" {
+			message = body.Error
+		}
+		// Group by route, as messages differ in the IDs they name
+		r.CaptureMessage(c.Request.Context(), LevelError, fmt.Sprintf("%s %s: %s", c.Request.Method, c.FullPath(), message), requestTags(c), "http", c.Request.Method, c.FullPath())
+	}
+}
+
+// requestTags tags a request's events with its method, route and path
+// parameters
+func requestTags(c *gin.Context) map[string]string {
+	tags := map[string]string{
+		"http.method": c.Request.Method,
+		"http.route":  c.FullPath(),
+	}
+	for _, param := range c.Params {
+		tags["http.param."+param.Key] = param.Value
+	}
+	return tags
+}
@@ -0,0 +1,38 @@
+// Package events defines the event sourcing envelope the services record
+// their domain events in, so the event logs of every service read the same
+// and a correlation chain can be followed from one to the next.
+package events
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Event represents an event sourcing event
+type Event struct {
+	ID            string                 `json:"id" dynamodbav:"id"`
+	TenantID      string                 `json:"tenant_id,omitempty" dynamodbav:"tenant_id,omitempty"`
+	AggregateID   string                 `json:"aggregate_id" dynamodbav:"aggregate_id"`
+	EventType     string                 `json:"event_type" dynamodbav:"event_type"`
+	EventData     map[string]interface{} `json:"event_data" dynamodbav:"event_data"`
+	Timestamp     time.Time              `json:"timestamp" dynamodbav:"timestamp"`
+	Version       int                    `json:"version" dynamodbav:"version"`
+	SchemaVersion int                    `json:"schema_version,omitempty" dynamodbav:"schema_version,omitempty"`
+	CorrelationID *string                `json:"correlation_id,omitempty" dynamodbav:"correlation_id,omitempty"`
+	CausationID   *string                `json:"causation_id,omitempty" dynamodbav:"causation_id,omitempty"`
+}
+
+// New creates a new Event that happened at now
+func New(aggregateID, eventType string, eventData map[string]interface{}, correlationID, causationID *string, now time.Time) *Event {
+	return &Event{
+		ID:            uuid.New().String(),
+		AggregateID:   aggregateID,
+		EventType:     eventType,
+		EventData:     eventData,
+		Timestamp:     now.UTC(),
+		Version:       1,
+		CorrelationID: correlationID,
+		CausationID:   causationID,
+	}
+}
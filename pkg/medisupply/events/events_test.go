@@ -0,0 +1,37 @@
+package events
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestNew(t *testing.T) {
+	correlationID, causationID := "correlation-1", "request-1"
+	now := time.Date(2024, 3, 1, 9, 0, 0, 0, time.FixedZone("COT", -5*3600))
+	event := New("po-1", "PurchaseOrderCreated", map[string]interface{}{"quantity": 10}, &correlationID, &causationID, now)
+
+	if event.ID == "" || event.AggregateID != "po-1" || event.EventType != "PurchaseOrderCreated" || event.Version != 1 || *event.CorrelationID != "correlation-1" || *event.CausationID != "request-1" {
+		t.Fatalf("event %+v", event)
+	}
+	// Events are recorded in UTC
+	if !event.Timestamp.Equal(now) || event.Timestamp.Location() != time.UTC {
+		t.Fatalf("timestamp %s, want %s in UTC", event.Timestamp, now)
+	}
+	if other := New("po-1", "PurchaseOrderCreated", nil, nil, nil, now); other.ID == event.ID {
+		t.Fatal("two events share an ID")
+	}
+}
+
+func TestEventJSONLeavesOutUnsetFields(t *testing.T) {
+	event := New("po-1", "PurchaseOrderCreated", nil, nil, nil, time.Date(2024, 3, 1, 14, 0, 0, 0, time.UTC))
+	event.ID = "event-1"
+	data, err := json.Marshal(event)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	want := `{"id":"event-1","aggregate_id":"po-1","event_type":"PurchaseOrderCreated","event_data":null,"timestamp":"2024-03-01T14:00:00Z","version":1}`
+	if string(data) != want {
+		t.Fatalf("event JSON %s, want %s", data, want)
+	}
+}
@@ -0,0 +1,105 @@
+// Package export writes tabular exports as CSV or XLSX spreadsheets. Rows are
+// streamed to the output as they are written, so exports of any size use
+// constant memory.
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+)
+
+// Format is an export file format
+type Format string
+
+// Supported export formats
+const (
+	CSV  Format = "csv"
+	XLSX Format = "xlsx"
+)
+
+// ParseFormat parses an export format, defaulting to CSV
+func ParseFormat(value string) (Format, error) {
+	switch Format(value) {
+	case "", CSV:
+		return CSV, nil
+	case XLSX:
+		return XLSX, nil
+	default:
+		return "", fmt.Errorf("unsupported export format %q, use %s or %s", value, CSV, XLSX)
+	}
+}
+
+// ContentType returns the MIME type of the format
+func (f Format) ContentType() string {
+	if f == XLSX {
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// Filename returns the download file name for an export
+func (f Format) Filename(name string) string {
+	return name + "." + string(f)
+}
+
+// Writer writes the rows of an export
+type Writer interface {
+	Write(row []string) error
+	// Close flushes buffered rows and finishes the file
+	Close() error
+}
+
+// NewWriter creates a writer of the format on w. sheet names the XLSX worksheet.
+func NewWriter(w io.Writer, format Format, sheet string) (Writer, error) {
+	switch format {
+	case CSV:
+		return &csvWriter{csv: csv.NewWriter(w)}, nil
+	case XLSX:
+		return newXLSXWriter(w, sheet)
+	default:
+		return nil, fmt.Errorf("unsupported export format %q", format)
+	}
+}
+
+// csvWriter writes RFC 4180 CSV
+type csvWriter struct {
+	csv  *csv.Writer
+	rows int
+}
+
+// Write writes a row, flushing every few hundred rows to keep the stream moving
+func (w *csvWriter) Write(row []string) error {
+	cells := make([]string, len(row))
+	for i, value := range row {
+		cells[i] = neutralizeFormula(value)
+	}
+	if err := w.csv.Write(cells); err != nil {
+		return err
+	}
+	w.rows++
+	if w.rows%500 == 0 {
+		w.csv.Flush()
+		return w.csv.Error()
+	}
+	return nil
+}
+
+// Close flushes the remaining rows
+func (w *csvWriter) Close() error {
+	w.csv.Flush()
+	return w.csv.Error()
+}
+
+// neutralizeFormula prefixes values a spreadsheet would evaluate as formulas
+// with a quote, so free text such as product names cannot inject formulas
+func neutralizeFormula(value string) string {
+	if value == "" || number.MatchString(value) {
+		return value
+	}
+	switch value[0] {
+	case '=', '+', '-', '@', '\t', '\r':
+		return "'" + value
+	}
+	return value
+}
@@ -0,0 +1,112 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestParseFormat(t *testing.T) {
+	for _, tc := range []struct {
+		value string
+		want  Format
+		ok    bool
+	}{
+		{"", CSV, true},
+		{"csv", CSV, true},
+		{"xlsx", XLSX, true},
+		{"pdf", "", false},
+	} {
+		format, err := ParseFormat(tc.value)
+		if format != tc.want || (err == nil) != tc.ok {
+			t.Fatalf("ParseFormat(%q) = %q, %v", tc.value, format, err)
+		}
+	}
+	if XLSX.Filename("purchase-orders") != "purchase-orders.xlsx" || !strings.HasPrefix(CSV.ContentType(), "text/csv") {
+		t.Fatalf("xlsx file %s, csv content type %s", XLSX.Filename("purchase-orders"), CSV.ContentType())
+	}
+}
+
+func TestCSVNeutralizesFormulas(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, CSV, "")
+	if err != nil {
+		t.Fatalf("NewWriter: %v", err)
+	}
+	if err := w.Write([]string{"=SUM(A1:A2)", "-12.5", "@user", "+1 555", "Gloves, nitrile"}); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("read back: %v", err)
+	}
+	want := []string{"'=SUM(A1:A2)", "-12.5", "'@user", "'+1 555", "Gloves, nitrile"}
+	for i, cell := range rows[0] {
+		if cell != want[i] {
+			t.Fatalf("cell %d is %q, want %q", i, cell, want[i])
+		}
+	}
+}
+
+func TestXLSXWritesAWorkbook(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, XLSX, "Orders & more")
+	if err != nil {
+		t.Fatalf("NewWriter: %v", err)
+	}
+	for _, row := range [][]string{{"id", "quantity"}, {"po-<1>", "10"}} {
+		if err := w.Write(row); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("open workbook: %v", err)
+	}
+	parts := map[string]string{}
+	for _, f := range archive.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatalf("open %s: %v", f.Name, err)
+		}
+		content, _ := io.ReadAll(r)
+		r.Close()
+		parts[f.Name] = string(content)
+	}
+
+	if !strings.Contains(parts["xl/workbook.xml"], `name="Orders &amp; more"`) {
+		t.Fatalf("workbook %s", parts["xl/workbook.xml"])
+	}
+	sheet := parts["xl/worksheets/sheet1.xml"]
+	for _, want := range []string{
+		`<row r="1"><c r="A1" t="inlineStr"><is><t xml:space="preserve">id</t></is></c>`,
+		`<c r="A2" t="inlineStr"><is><t xml:space="preserve">po-&lt;1&gt;</t></is></c>`,
+		`<c r="B2"><v>10</v></c>`,
+	} {
+		if !strings.Contains(sheet, want) {
+			t.Fatalf("worksheet lacks %s:\n%s", want, sheet)
+		}
+	}
+	if !strings.HasSuffix(sheet, "</sheetData></worksheet>") {
+		t.Fatalf("worksheet not finished: %s", sheet)
+	}
+}
+
+func TestColumnName(t *testing.T) {
+	for i, want := range map[int]string{0: "A", 25: "Z", 26: "AA", 51: "AZ", 52: "BA", 701: "ZZ", 702: "AAA"} {
+		if got := columnName(i); got != want {
+			t.Fatalf("columnName(%d) = %s, want %s", i, got, want)
+		}
+	}
+}
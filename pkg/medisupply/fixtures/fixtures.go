@@ -0,0 +1,252 @@
+// Package fixtures holds golden examples of the events the services send
+// each other, one per wire version still in circulation. Producers check
+// that they encode the versions they send exactly and consumers that they
+// decode every version to the same event, so a change on one side that the
+// other cannot read fails the contract tests before it is deployed. The
+// examples are JSON; Protobuf derives the protobuf form of each version.
+package fixtures
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Event types exchanged between the services
+const (
+	// StockBajo is sent by inventario and consumed by orden-compra
+	StockBajo = "StockBajo"
+	// RecepcionProveedor is sent by orden-compra and consumed by proveedor
+	RecepcionProveedor = "RecepcionProveedor"
+	// InventarioRecibido is sent by proveedor and consumed by orden-compra
+	InventarioRecibido = "InventarioRecibido"
+)
+
+// Versions lists the wire versions of each event type that consumers must
+// still accept, oldest first. The last one is the current form.
+//
+// StockBajo:          v1 untenanted, v2 with tenant_id
+// RecepcionProveedor: v1 Spanish field names, v2 both names (the deprecation
+// window, EMIT_LEGACY_EVENT_FIELDS=true), v3 English names only
+// InventarioRecibido: v1 without cold chain fields, v2 with temperature and expiry_date
+var Versions = map[string][]int{
+	StockBajo:          {1, 2},
+	RecepcionProveedor: {1, 2, 3},
+	InventarioRecibido: {1, 2},
+}
+
+//go:embed golden/*.json
+var golden embed.FS
+
+// Fixture is a golden example of one version of an event
+type Fixture struct {
+	EventType string
+	Version   int
+	Body      []byte
+}
+
+// Name identifies the fixture in reports, e.g. StockBajo v2
+func (f Fixture) Name() string {
+	return fmt.Sprintf("%s v%d", f.EventType, f.Version)
+}
+
+// Load returns the golden example of a version of an event type
+func Load(eventType string, version int) (Fixture, error) {
+	body, err := golden.ReadFile(fmt.Sprintf("golden/%s.v%d.json", eventType, version))
+	if err != nil {
+		return Fixture{}, fmt.Errorf("no fixture for %s v%d: %w", eventType, version, err)
+	}
+	return Fixture{EventType: eventType, Version: version, Body: body}, nil
+}
+
+// All returns the golden examples of every supported version of an event
+// type, oldest first
+func All(eventType string) ([]Fixture, error) {
+	versions, ok := Versions[eventType]
+	if !ok {
+		return nil, fmt.Errorf("unknown event type %s", eventType)
+	}
+	all := make([]Fixture, 0, len(versions))
+	for _, version := range versions {
+		fixture, err := Load(eventType, version)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, fixture)
+	}
+	return all, nil
+}
+
+// Latest returns the golden example of the current version of an event type
+func Latest(eventType string) (Fixture, error) {
+	versions, ok := Versions[eventType]
+	if !ok {
+		return Fixture{}, fmt.Errorf("unknown event type %s", eventType)
+	}
+	return Load(eventType, versions[len(versions)-1])
+}
+
+// Match compares an encoded event with its golden example field by field.
+// With exact, got must carry the same fields as want; otherwise extra fields
+// in got, such as zero values of fields want predates, are allowed.
+func Match(want, got []byte, exact bool) error {
+	var wantFields, gotFields map[string]interface{}
+	if err := json.Unmarshal(want, &wantFields); err != nil {
+		return fmt.Errorf("invalid fixture: %w", err)
+	}
+	if err := json.Unmarshal(got, &gotFields); err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+
+	var differences []string
+	for _, name := range sortedKeys(wantFields) {
+		value, ok := gotFields[name]
+		switch {
+		case !ok:
+			differences = append(differences, fmt.Sprintf("%s is missing", name))
+		case !reflect.DeepEqual(wantFields[name], value):
+			differences = append(differences, fmt.Sprintf("%s is %s, want %s", name, encode(value), encode(wantFields[name])))
+		}
+	}
+	if exact {
+		for _, name := range sortedKeys(gotFields) {
+			if _, ok := wantFields[name]; !ok {
+				differences = append(differences, fmt.Sprintf("%s is unexpected", name))
+			}
+		}
+	}
+
+	if len(differences) > 0 {
+		return errors.New(strings.Join(differences, "; "))
+	}
+	return nil
+}
+
+// sortedKeys returns the keys of a JSON object in order, for stable reports
+func sortedKeys(fields map[string]interface{}) []string {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// encode renders a JSON value for a report
+func encode(value interface{}) string {
+	body, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(body)
+}
+
+// protobufAliases are the Spanish names older versions carry the
+// medisupply.events.v1 fields under, by proto field name
+var protobufAliases = map[string]string{
+	"product_id":     "producto_id",
+	"quantity":       "cantidad",
+	"supplier_id":    "proveedor_id",
+	"status":         "estado",
+	"reception_date": "fecha_recepcion",
+}
+
+// ProtobufFixture is a golden example as its producer would have sent it in
+// protobuf, with the fields its schema carries
+type ProtobufFixture struct {
+	Fixture
+	// Message is the medisupply.events.v1 message holding the fixture's fields
+	Message proto.Message
+	// Body is Message encoded
+	Body []byte
+	// Fields is the JSON of the fixture's fields Message carries, for Match;
+	// fields only JSON carries, such as tenant_id, are left out
+	Fields []byte
+}
+
+// Protobuf fills message, a medisupply.events.v1 message, with the fields of
+// the fixture, read under their proto names or, in older versions, under
+// their Spanish aliases
+func (f Fixture) Protobuf(message proto.Message) (ProtobufFixture, error) {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(f.Body, &fields); err != nil {
+		return ProtobufFixture{}, fmt.Errorf("invalid fixture %s: %w", f.Name(), err)
+	}
+
+	carried := make(map[string]interface{})
+	m := message.ProtoReflect()
+	descriptors := m.Descriptor().Fields()
+	for i := 0; i < descriptors.Len(); i++ {
+		field := descriptors.Get(i)
+		name := string(field.Name())
+		value, ok := fields[name]
+		if !ok {
+			name = protobufAliases[name]
+			if value, ok = fields[name]; !ok {
+				continue
+			}
+		}
+		protoValue, err := protobufValue(field, value)
+		if err != nil {
+			return ProtobufFixture{}, fmt.Errorf("%s: field %s: %w", f.Name(), name, err)
+		}
+		m.Set(field, protoValue)
+		carried[name] = value
+	}
+
+	body, err := proto.Marshal(message)
+	if err != nil {
+		return ProtobufFixture{}, fmt.Errorf("%s: %w", f.Name(), err)
+	}
+	carriedJSON, err := json.Marshal(carried)
+	if err != nil {
+		return ProtobufFixture{}, fmt.Errorf("%s: %w", f.Name(), err)
+	}
+	return ProtobufFixture{Fixture: f, Message: message, Body: body, Fields: carriedJSON}, nil
+}
+
+// protobufValue converts a JSON value to the value of a proto field. Metadata
+// objects are carried JSON-encoded in bytes fields.
+func protobufValue(field protoreflect.FieldDescriptor, value interface{}) (protoreflect.Value, error) {
+	switch field.Kind() {
+	case protoreflect.StringKind:
+		if s, ok := value.(string); ok {
+			return protoreflect.ValueOfString(s), nil
+		}
+	case protoreflect.Int64Kind:
+		if n, ok := value.(float64); ok {
+			return protoreflect.ValueOfInt64(int64(n)), nil
+		}
+	case protoreflect.DoubleKind:
+		if n, ok := value.(float64); ok {
+			return protoreflect.ValueOfFloat64(n), nil
+		}
+	case protoreflect.BytesKind:
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		return protoreflect.ValueOfBytes(raw), nil
+	case protoreflect.MessageKind:
+		if field.Message().FullName() != "google.protobuf.Timestamp" {
+			break
+		}
+		if s, ok := value.(string); ok {
+			t, err := time.Parse(time.RFC3339Nano, s)
+			if err != nil {
+				return protoreflect.Value{}, err
+			}
+			return protoreflect.ValueOfMessage(timestamppb.New(t).ProtoReflect()), nil
+		}
+	}
+	return protoreflect.Value{}, fmt.Errorf("cannot hold %s in a %s field", encode(value), field.Kind())
+}
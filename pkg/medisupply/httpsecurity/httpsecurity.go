@@ -0,0 +1,122 @@
+// Package httpsecurity adds the browser-facing headers of the HTTP API:
+// CORS, so dashboards served from other origins can call it, and the
+// standard security headers on every response.
+package httpsecurity
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CORSConfig represents the cross-origin settings. Without allowed origins
+// no CORS headers are sent and browsers keep to same-origin requests.
+type CORSConfig struct {
+	// AllowedOrigins lists the origins allowed to call the API, such as
+	// https://dashboard.example.com; "*" allows any origin
+	AllowedOrigins []string
+	AllowedMethods []string
+	AllowedHeaders []string
+	// ExposedHeaders lists the response headers scripts may read
+	ExposedHeaders   []string
+	AllowCredentials bool
+	// MaxAge is how long browsers may cache a preflight response
+	MaxAge time.Duration
+}
+
+// CORS answers preflight requests and marks the responses to allowed
+// origins. It runs before authentication, since browsers send preflight
+// requests without credentials. A preflight from an origin that is not
+// allowed gets 403.
+func CORS(config CORSConfig) gin.HandlerFunc {
+	anyOrigin := false
+	origins := make(map[string]bool, len(config.AllowedOrigins))
+	for _, origin := range config.AllowedOrigins {
+		if origin == "*" {
+			anyOrigin = true
+		}
+		origins[strings.TrimSuffix(origin, "/")] = true
+	}
+	methods := strings.Join(config.AllowedMethods, ", ")
+	headers := strings.Join(config.AllowedHeaders, ", ")
+	exposed := strings.Join(config.ExposedHeaders, ", ")
+	maxAge := strconv.Itoa(int(config.MaxAge.Seconds()))
+
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin == "" || len(origins) == 0 {
+			c.Next()
+			return
+		}
+		preflight := c.Request.Method == "OPTIONS" && c.GetHeader("Access-Control-Request-Method") != ""
+
+		c.Writer.Header().Add("Vary", "Origin")
+		if !anyOrigin && !origins[origin] {
+			if preflight {
+				c.AbortWithStatus(403)
+				return
+			}
+			c.Next()
+			return
+		}
+
+		// A credentialed response must name the origin rather than "*"
+		if anyOrigin && !config.AllowCredentials {
+			c.Header("Access-Control-Allow-Origin", "*")
+		} else {
+			c.Header("Access-Control-Allow-Origin", origin)
+		}
+		if config.AllowCredentials {
+			c.Header("Access-Control-Allow-Credentials", "true")
+		}
+
+		if preflight {
+			c.Header("Access-Control-Allow-Methods", methods)
+			c.Header("Access-Control-Allow-Headers", headers)
+			if config.MaxAge > 0 {
+				c.Header("Access-Control-Max-Age", maxAge)
+			}
+			c.AbortWithStatus(204)
+			return
+		}
+		if exposed != "" {
+			c.Header("Access-Control-Expose-Headers", exposed)
+		}
+		c.Next()
+	}
+}
+
+// HeadersConfig represents the security header settings
+type HeadersConfig struct {
+	// ContentSecurityPolicy applies to every response; a route serving a
+	// page may set its own
+	ContentSecurityPolicy string
+	// HSTSMaxAge enables Strict-Transport-Security; only set it when the
+	// API is only reached over HTTPS
+	HSTSMaxAge time.Duration
+}
+
+// Headers sets the standard security headers on every response: no MIME
+// sniffing, no framing, no referrer and the content security policy
+func Headers(config HeadersConfig) gin.HandlerFunc {
+	hsts := ""
+	if config.HSTSMaxAge > 0 {
+		hsts = "max-age=" + strconv.Itoa(int(config.HSTSMaxAge.Seconds())) + "; includeSubDomains"
+	}
+
+	return func(c *gin.Context) {
+		header := c.Writer.Header()
+		header.Set("X-Content-Type-Options", "nosniff")
+		header.Set("X-Frame-Options", "DENY")
+		header.Set("Referrer-Policy", "no-referrer")
+		if config.ContentSecurityPolicy != "" {
+			header.Set("Content-Security-Policy", config.ContentSecurityPolicy)
+		}
+		if hsts != "" {
+			header.Set("Strict-Transport-Security", hsts)
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,121 @@
+package httpsecurity
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func corsRouter(config CORSConfig) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(CORS(config))
+	router.GET("/orders", func(c *gin.Context) { c.String(200, "orders") })
+	return router
+}
+
+func request(router *gin.Engine, method, origin, requestMethod string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/orders", nil)
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+	if requestMethod != "" {
+		req.Header.Set("Access-Control-Request-Method", requestMethod)
+	}
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func TestCORS(t *testing.T) {
+	router := corsRouter(CORSConfig{
+		AllowedOrigins: []string{"https://dashboard.example.com/"},
+		AllowedMethods: []string{"GET", "POST"},
+		AllowedHeaders: []string{"Authorization", "Content-Type"},
+		ExposedHeaders: []string{"ETag"},
+		MaxAge:         10 * time.Minute,
+	})
+
+	preflight := request(router, http.MethodOptions, "https://dashboard.example.com", "POST")
+	header := preflight.Header()
+	if preflight.Code != 204 || header.Get("Access-Control-Allow-Origin") != "https://dashboard.example.com" || header.Get("Access-Control-Allow-Methods") != "GET, POST" || header.Get("Access-Control-Allow-Headers") != "Authorization, Content-Type" || header.Get("Access-Control-Max-Age") != "600" || header.Get("Vary") != "Origin" {
+		t.Fatalf("preflight answered %d with %v", preflight.Code, header)
+	}
+
+	simple := request(router, http.MethodGet, "https://dashboard.example.com", "")
+	if simple.Code != 200 || simple.Header().Get("Access-Control-Allow-Origin") != "https://dashboard.example.com" || simple.Header().Get("Access-Control-Expose-Headers") != "ETag" || simple.Header().Get("Access-Control-Allow-Credentials") != "" {
+		t.Fatalf("request answered %d with %v", simple.Code, simple.Header())
+	}
+
+	// Other origins get no CORS headers, and their preflights are refused
+	if other := request(router, http.MethodOptions, "https://evil.example.com", "POST"); other.Code != 403 {
+		t.Fatalf("preflight from another origin answered %d", other.Code)
+	}
+	if other := request(router, http.MethodGet, "https://evil.example.com", ""); other.Code != 200 || other.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatalf("request from another origin answered %d with %v", other.Code, other.Header())
+	}
+	if sameOrigin := request(router, http.MethodGet, "", ""); sameOrigin.Code != 200 || sameOrigin.Header().Get("Vary") != "" {
+		t.Fatalf("same-origin request answered %d with %v", sameOrigin.Code, sameOrigin.Header())
+	}
+}
+
+func TestCORSAnyOrigin(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		credentials bool
+		origin      string
+	}{
+		{"without credentials", false, "*"},
+		{"with credentials", true, "https://dashboard.example.com"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			router := corsRouter(CORSConfig{AllowedOrigins: []string{"*"}, AllowCredentials: tc.credentials})
+			recorder := request(router, http.MethodGet, "https://dashboard.example.com", "")
+			if got := recorder.Header().Get("Access-Control-Allow-Origin"); got != tc.origin {
+				t.Fatalf("Access-Control-Allow-Origin %q, want %q", got, tc.origin)
+			}
+			if credentials := recorder.Header().Get("Access-Control-Allow-Credentials") == "true"; credentials != tc.credentials {
+				t.Fatalf("Access-Control-Allow-Credentials %q", recorder.Header().Get("Access-Control-Allow-Credentials"))
+			}
+		})
+	}
+}
+
+func TestCORSWithoutOriginsSendsNoHeaders(t *testing.T) {
+	router := corsRouter(CORSConfig{})
+	recorder := request(router, http.MethodGet, "https://dashboard.example.com", "")
+	if recorder.Code != 200 || recorder.Header().Get("Access-Control-Allow-Origin") != "" || recorder.Header().Get("Vary") != "" {
+		t.Fatalf("answered %d with %v", recorder.Code, recorder.Header())
+	}
+}
+
+func TestHeaders(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		config HeadersConfig
+		csp    string
+		hsts   string
+	}{
+		{"defaults", HeadersConfig{}, "", ""},
+		{"policy and HSTS", HeadersConfig{ContentSecurityPolicy: "default-src 'none'", HSTSMaxAge: 24 * time.Hour}, "default-src 'none'", "max-age=86400; includeSubDomains"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.Use(Headers(tc.config))
+			router.GET("/orders", func(c *gin.Context) { c.String(200, "orders") })
+			recorder := request(router, http.MethodGet, "", "")
+
+			header := recorder.Header()
+			if header.Get("X-Content-Type-Options") != "nosniff" || header.Get("X-Frame-Options") != "DENY" || header.Get("Referrer-Policy") != "no-referrer" {
+				t.Fatalf("headers %v", header)
+			}
+			if header.Get("Content-Security-Policy") != tc.csp || header.Get("Strict-Transport-Security") != tc.hsts {
+				t.Fatalf("Content-Security-Policy %q and Strict-Transport-Security %q", header.Get("Content-Security-Policy"), header.Get("Strict-Transport-Security"))
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package httpsecurity
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Client certificate modes
+const (
+	ClientAuthNone    = "none"
+	ClientAuthRequest = "request"
+	// ClientAuthVerifyIfGiven verifies a client certificate when one is sent
+	ClientAuthVerifyIfGiven = "verify-if-given"
+	// ClientAuthRequire refuses connections without a verified client
+	// certificate, for mutual TLS
+	ClientAuthRequire = "require"
+)
+
+// ErrInvalidTLSConfig is returned for TLS settings that cannot be served
+var ErrInvalidTLSConfig = errors.New("invalid TLS configuration")
+
+// TLSConfig represents the TLS listener settings. Without a certificate the
+// server listens in plain HTTP, as behind a proxy that terminates TLS.
+type TLSConfig struct {
+	CertFile string
+	KeyFile  string
+	// MinVersion is "1.2" or "1.3"
+	MinVersion string
+	// ClientAuth is one of the client certificate modes
+	ClientAuth string
+	// ClientCAFile holds the PEM certificates client certificates are
+	// verified against
+	ClientCAFile string
+}
+
+// Enabled reports whether the server listens with TLS
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != ""
+}
+
+// ServerConfig builds the listener's TLS configuration. The certificate is
+// read again when its files change, so a rotated certificate is served
+// without a restart.
+func (c TLSConfig) ServerConfig() (*tls.Config, error) {
+	if c.KeyFile == "" {
+		return nil, fmt.Errorf("%w: a key file is required with the certificate", ErrInvalidTLSConfig)
+	}
+	minVersion, err := parseTLSVersion(c.MinVersion)
+	if err != nil {
+		return nil, err
+	}
+	reloader := &certificateReloader{certFile: c.CertFile, keyFile: c.KeyFile}
+	if err := reloader.load(); err != nil {
+		return nil, err
+	}
+	config := &tls.Config{
+		MinVersion:     minVersion,
+		GetCertificate: reloader.getCertificate,
+	}
+
+	switch strings.ToLower(c.ClientAuth) {
+	case "", ClientAuthNone:
+		config.ClientAuth = tls.NoClientCert
+	case ClientAuthRequest:
+		config.ClientAuth = tls.RequestClientCert
+	case ClientAuthVerifyIfGiven:
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+	case ClientAuthRequire:
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	default:
+		return nil, fmt.Errorf("%w: unknown client auth mode %q", ErrInvalidTLSConfig, c.ClientAuth)
+	}
+	if config.ClientAuth == tls.VerifyClientCertIfGiven || config.ClientAuth == tls.RequireAndVerifyClientCert {
+		if c.ClientCAFile == "" {
+			return nil, fmt.Errorf("%w: client auth mode %q needs a client CA file", ErrInvalidTLSConfig, c.ClientAuth)
+		}
+		pem, err := os.ReadFile(c.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%w: no certificates in client CA file %s", ErrInvalidTLSConfig, c.ClientCAFile)
+		}
+		config.ClientCAs = pool
+	}
+	return config, nil
+}
+
+// ListenAndServe serves the server with TLS when it is configured and in
+// plain HTTP otherwise
+func ListenAndServe(server *http.Server, config TLSConfig) error {
+	if !config.Enabled() {
+		return server.ListenAndServe()
+	}
+	tlsConfig, err := config.ServerConfig()
+	if err != nil {
+		return err
+	}
+	server.TLSConfig = tlsConfig
+	return server.ListenAndServeTLS("", "")
+}
+
+// parseTLSVersion maps a configured minimum version onto its constant,
+// defaulting to TLS 1.2
+func parseTLSVersion(version string) (uint16, error) {
+	switch version {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("%w: unsupported minimum version %q", ErrInvalidTLSConfig, version)
+	}
+}
+
+// certificateReloaderInterval bounds how often the certificate files are
+// checked for changes
+const certificateReloaderInterval = 30 * time.Second
+
+// certificateReloader serves a certificate pair and reads it again once the
+// files change
+type certificateReloader struct {
+	certFile string
+	keyFile  string
+
+	mu          sync.Mutex
+	certificate *tls.Certificate
+	modTime     time.Time
+	checkedAt   time.Time
+}
+
+func (r *certificateReloader) load() error {
+	info, err := os.Stat(r.certFile)
+	if err != nil {
+		return fmt.Errorf("failed to read certificate file: %w", err)
+	}
+	certificate, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load certificate: %w", err)
+	}
+	r.certificate = &certificate
+	r.modTime = info.ModTime()
+	r.checkedAt = time.Now()
+	return nil
+}
+
+func (r *certificateReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if time.Since(r.checkedAt) < certificateReloaderInterval {
+		return r.certificate, nil
+	}
+	r.checkedAt = time.Now()
+	if info, err := os.Stat(r.certFile); err == nil && !info.ModTime().Equal(r.modTime) {
+		// A pair caught halfway through rotation fails to load; the loaded
+		// certificate is served until the next check
+		r.load()
+	}
+	return r.certificate, nil
+}
@@ -0,0 +1,198 @@
+package httpsecurity
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeCertificate writes a self-signed certificate for localhost and its
+// key to dir, returning the file paths and the certificate
+func writeCertificate(t *testing.T, dir, name string) (certFile, keyFile string, certificate *x509.Certificate) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshal key: %v", err)
+	}
+	certFile = filepath.Join(dir, name+".crt")
+	keyFile = filepath.Join(dir, name+".key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("write certificate: %v", err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatalf("write key: %v", err)
+	}
+	certificate, err = x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("parse certificate: %v", err)
+	}
+	return certFile, keyFile, certificate
+}
+
+func TestServerConfig(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, _ := writeCertificate(t, dir, "server")
+	caFile, _, _ := writeCertificate(t, dir, "client-ca")
+
+	for _, tc := range []struct {
+		name       string
+		config     TLSConfig
+		invalid    bool
+		minVersion uint16
+		clientAuth tls.ClientAuthType
+	}{
+		{"defaults", TLSConfig{CertFile: certFile, KeyFile: keyFile}, false, tls.VersionTLS12, tls.NoClientCert},
+		{"TLS 1.3", TLSConfig{CertFile: certFile, KeyFile: keyFile, MinVersion: "1.3"}, false, tls.VersionTLS13, tls.NoClientCert},
+		{"request", TLSConfig{CertFile: certFile, KeyFile: keyFile, ClientAuth: ClientAuthRequest}, false, tls.VersionTLS12, tls.RequestClientCert},
+		{"verify if given", TLSConfig{CertFile: certFile, KeyFile: keyFile, ClientAuth: ClientAuthVerifyIfGiven, ClientCAFile: caFile}, false, tls.VersionTLS12, tls.VerifyClientCertIfGiven},
+		{"require", TLSConfig{CertFile: certFile, KeyFile: keyFile, ClientAuth: "REQUIRE", ClientCAFile: caFile}, false, tls.VersionTLS12, tls.RequireAndVerifyClientCert},
+		{"no key", TLSConfig{CertFile: certFile}, true, 0, 0},
+		{"TLS 1.1", TLSConfig{CertFile: certFile, KeyFile: keyFile, MinVersion: "1.1"}, true, 0, 0},
+		{"unknown client auth", TLSConfig{CertFile: certFile, KeyFile: keyFile, ClientAuth: "optional"}, true, 0, 0},
+		{"require without a CA", TLSConfig{CertFile: certFile, KeyFile: keyFile, ClientAuth: ClientAuthRequire}, true, 0, 0},
+		{"CA without certificates", TLSConfig{CertFile: certFile, KeyFile: keyFile, ClientAuth: ClientAuthRequire, ClientCAFile: keyFile}, true, 0, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			config, err := tc.config.ServerConfig()
+			if tc.invalid {
+				if !errors.Is(err, ErrInvalidTLSConfig) {
+					t.Fatalf("returned %v, want ErrInvalidTLSConfig", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("server config: %v", err)
+			}
+			if config.MinVersion != tc.minVersion || config.ClientAuth != tc.clientAuth || (tc.config.ClientCAFile != "") != (config.ClientCAs != nil) {
+				t.Fatalf("config has min version %x, client auth %v and client CAs %v", config.MinVersion, config.ClientAuth, config.ClientCAs)
+			}
+		})
+	}
+
+	if (TLSConfig{}).Enabled() || !(TLSConfig{CertFile: certFile}).Enabled() {
+		t.Fatal("TLS is enabled by the certificate file")
+	}
+	if _, err := (TLSConfig{CertFile: filepath.Join(dir, "missing.crt"), KeyFile: keyFile}).ServerConfig(); err == nil {
+		t.Fatal("loaded a missing certificate")
+	}
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, serverCertificate := writeCertificate(t, dir, "server")
+	clientCertFile, clientKeyFile, _ := writeCertificate(t, dir, "client")
+	config, err := TLSConfig{CertFile: certFile, KeyFile: keyFile, ClientAuth: ClientAuthRequire, ClientCAFile: clientCertFile}.ServerConfig()
+	if err != nil {
+		t.Fatalf("server config: %v", err)
+	}
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	// StartTLS would add its own certificate, so the listener is wrapped
+	server.Listener = tls.NewListener(server.Listener, config)
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	server.Start()
+	defer server.Close()
+	url := "https://" + server.Listener.Addr().String()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(serverCertificate)
+	client := func(certificates ...tls.Certificate) *http.Client {
+		return &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: certificates}}}
+	}
+
+	// A client without a certificate is refused
+	if response, err := client().Get(url); err == nil {
+		response.Body.Close()
+		t.Fatal("served a client without a certificate")
+	}
+
+	clientCertificate, err := tls.LoadX509KeyPair(clientCertFile, clientKeyFile)
+	if err != nil {
+		t.Fatalf("load client certificate: %v", err)
+	}
+	response, err := client(clientCertificate).Get(url)
+	if err != nil {
+		t.Fatalf("request with a client certificate: %v", err)
+	}
+	defer response.Body.Close()
+	if body, _ := io.ReadAll(response.Body); response.StatusCode != 200 || string(body) != "client" {
+		t.Fatalf("answered %d to %q, want the client", response.StatusCode, body)
+	}
+}
+
+func TestCertificateReloaderServesTheRotatedCertificate(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, first := writeCertificate(t, dir, "server")
+	reloader := &certificateReloader{certFile: certFile, keyFile: keyFile}
+	if err := reloader.load(); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	rotatedCert, rotatedKey, second := writeCertificate(t, t.TempDir(), "server")
+	for from, to := range map[string]string{rotatedCert: certFile, rotatedKey: keyFile} {
+		if err := os.Rename(from, to); err != nil {
+			t.Fatalf("rotate: %v", err)
+		}
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(certFile, later, later); err != nil {
+		t.Fatalf("touch certificate: %v", err)
+	}
+
+	// The files are only checked once per interval
+	certificate, _ := reloader.getCertificate(nil)
+	if !first.Equal(mustParse(t, certificate)) {
+		t.Fatal("the certificate was read again within the interval")
+	}
+	reloader.checkedAt = time.Now().Add(-certificateReloaderInterval)
+	certificate, _ = reloader.getCertificate(nil)
+	if !second.Equal(mustParse(t, certificate)) {
+		t.Fatal("the rotated certificate is not served")
+	}
+}
+
+func mustParse(t *testing.T, certificate *tls.Certificate) *x509.Certificate {
+	t.Helper()
+	parsed, err := x509.ParseCertificate(certificate.Certificate[0])
+	if err != nil {
+		t.Fatalf("parse certificate: %v", err)
+	}
+	return parsed
+}
@@ -0,0 +1,43 @@
+package messaging
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+var (
+	ageOnce      sync.Once
+	ageHistogram metric.Float64Histogram
+)
+
+// RecordAge records how long a message waited between being published and
+// being handled, by the queue or subject it was consumed from. A growing age
+// shows consumers falling behind before the backlog itself is alarming.
+// Messages without a publish time are not recorded.
+func RecordAge(ctx context.Context, source string, published time.Time) {
+	if published.IsZero() {
+		return
+	}
+	ageOnce.Do(func() {
+		ageHistogram, _ = otel.Meter("medisupply/messaging").Float64Histogram(
+			"message_age_seconds",
+			metric.WithDescription("Time messages waited between being published and being handled"),
+			metric.WithUnit("s"),
+		)
+	})
+	if ageHistogram == nil {
+		return
+	}
+
+	age := time.Since(published).Seconds()
+	if age < 0 {
+		// The publisher's clock is ahead of ours
+		age = 0
+	}
+	ageHistogram.Record(ctx, age, metric.WithAttributes(attribute.String("queue", source)))
+}
@@ -0,0 +1,50 @@
+package messaging
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestRecordAge(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	otel.SetMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+
+	RecordAge(context.Background(), "orden-compra.stock-bajo", time.Now().Add(-2*time.Second))
+	// The publisher's clock may be ahead, which counts as no wait
+	RecordAge(context.Background(), "orden-compra.stock-bajo", time.Now().Add(time.Minute))
+	// Messages without a publish time are not recorded
+	RecordAge(context.Background(), "orden-compra.stock-bajo", time.Time{})
+
+	var data metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &data); err != nil {
+		t.Fatalf("collect: %v", err)
+	}
+	for _, scope := range data.ScopeMetrics {
+		for _, m := range scope.Metrics {
+			if m.Name != "message_age_seconds" {
+				continue
+			}
+			points := m.Data.(metricdata.Histogram[float64]).DataPoints
+			if len(points) != 1 {
+				t.Fatalf("recorded %d series, want 1", len(points))
+			}
+			point := points[0]
+			if queue, _ := point.Attributes.Value("queue"); queue.AsString() != "orden-compra.stock-bajo" {
+				t.Fatalf("recorded under queue %q", queue.AsString())
+			}
+			if point.Count != 2 || point.Sum < 2 || point.Sum > 3 {
+				t.Fatalf("recorded %d ages summing to %v, want 2 summing to about 2s", point.Count, point.Sum)
+			}
+			if min, ok := point.Min.Value(); !ok || min != 0 {
+				t.Fatalf("smallest age %v, want 0", min)
+			}
+			return
+		}
+	}
+	t.Fatal("message_age_seconds was not recorded")
+}
@@ -0,0 +1,167 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+const (
+	// apiTimeout bounds connecting and requests to the JetStream API
+	apiTimeout = 5 * time.Second
+	// pullExpiry is how long a pull request waits for messages before it is renewed
+	pullExpiry = 30 * time.Second
+)
+
+// JetStreamConfig represents the NATS JetStream consumer settings
+type JetStreamConfig struct {
+	URL string
+	// DurablePrefix names the durable consumers, which keep their position
+	// across restarts
+	DurablePrefix string
+	// AckWait is how long a message may go unacknowledged before it is redelivered
+	AckWait time.Duration
+	// MaxDeliver is the number of deliveries of a message before it is dropped
+	MaxDeliver int
+	// MaxAge is how long streams keep messages
+	MaxAge time.Duration
+	// Batch is the number of messages pulled at once
+	Batch int
+}
+
+// Stream is a JetStream stream holding one event type
+type Stream struct {
+	Name     string
+	Subjects []string
+}
+
+// JetStream consumes streams through durable pull consumers
+type JetStream struct {
+	Conn   *nats.Conn
+	Config JetStreamConfig
+
+	js jetstream.JetStream
+}
+
+// NewJetStream connects to the NATS server of config
+func NewJetStream(ctx context.Context, config JetStreamConfig) (*JetStream, error) {
+	if config.AckWait <= 0 || config.MaxDeliver < 1 || config.Batch < 1 {
+		return nil, fmt.Errorf("invalid jetstream config: ack wait, max deliver and batch must be positive")
+	}
+
+	conn, err := Dial(config.URL, config.DurablePrefix, apiTimeout, log.Default())
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to nats: %w", err)
+	}
+	js, err := jetstream.New(conn)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to open jetstream: %w", err)
+	}
+	return &JetStream{Conn: conn, Config: config, js: js}, nil
+}
+
+// Consume creates stream and its durable consumer, or updates them when
+// they exist with other settings, and delivers the stream's messages until
+// ctx is done. Pulls resume on their own after the connection reconnects.
+func (js *JetStream) Consume(ctx context.Context, stream Stream) (<-chan Message, error) {
+	apiCtx, cancel := context.WithTimeout(ctx, apiTimeout)
+	defer cancel()
+
+	_, err := js.js.CreateOrUpdateStream(apiCtx, jetstream.StreamConfig{
+		Name:      stream.Name,
+		Subjects:  stream.Subjects,
+		Retention: jetstream.LimitsPolicy,
+		Storage:   jetstream.FileStorage,
+		Discard:   jetstream.DiscardOld,
+		MaxAge:    js.Config.MaxAge,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stream %s: %w", stream.Name, err)
+	}
+
+	name := js.Config.DurablePrefix + "-" + strings.ToLower(strings.ReplaceAll(stream.Name, "_", "-"))
+	consumer, err := js.js.CreateOrUpdateConsumer(apiCtx, stream.Name, jetstream.ConsumerConfig{
+		Durable:       name,
+		DeliverPolicy: jetstream.DeliverAllPolicy,
+		AckPolicy:     jetstream.AckExplicitPolicy,
+		AckWait:       js.Config.AckWait,
+		MaxDeliver:    js.Config.MaxDeliver,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create consumer %s on stream %s: %w", name, stream.Name, err)
+	}
+
+	pulls, err := consumer.Messages(jetstream.PullMaxMessages(js.Config.Batch), jetstream.PullExpiry(pullExpiry))
+	if err != nil {
+		return nil, fmt.Errorf("failed to pull from stream %s: %w", stream.Name, err)
+	}
+
+	messages := make(chan Message)
+	go func() {
+		<-ctx.Done()
+		pulls.Stop()
+	}()
+	go func() {
+		defer close(messages)
+		for {
+			msg, err := pulls.Next()
+			if errors.Is(err, jetstream.ErrMsgIteratorClosed) {
+				return
+			}
+			if err != nil {
+				// The consumer was deleted or the connection closed
+				log.Printf("Pull from stream %s ended: %v", stream.Name, err)
+				return
+			}
+			select {
+			case messages <- js.message(msg):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return messages, nil
+}
+
+// message converts a JetStream delivery, acknowledged on its reply subject
+func (js *JetStream) message(msg jetstream.Msg) Message {
+	headers := make(map[string]string, len(msg.Headers()))
+	for key, values := range msg.Headers() {
+		if len(values) > 0 {
+			headers[key] = values[0]
+		}
+	}
+
+	var timestamp time.Time
+	if metadata, err := msg.Metadata(); err == nil {
+		timestamp = metadata.Timestamp
+	}
+
+	return Message{
+		Topic:       msg.Subject(),
+		MessageID:   headers[nats.MsgIdHdr],
+		ContentType: headers["Content-Type"],
+		Body:        msg.Data(),
+		Headers:     headers,
+		Timestamp:   timestamp,
+		settle: func(ack bool) error {
+			if ack {
+				return msg.Ack()
+			}
+			return msg.Nak()
+		},
+	}
+}
+
+// Close closes the connection to NATS
+func (js *JetStream) Close() error {
+	js.Conn.Close()
+	return nil
+}
@@ -0,0 +1,181 @@
+package messaging
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/rabbitmq/amqp091-go"
+)
+
+// startNATS runs a JetStream-enabled NATS server in the test process,
+// storing streams in dir so a restarted server keeps them
+func startNATS(t *testing.T, port int, dir string) *server.Server {
+	t.Helper()
+	natsServer, err := server.NewServer(&server.Options{
+		Host:      "127.0.0.1",
+		Port:      port,
+		JetStream: true,
+		StoreDir:  dir,
+		NoLog:     true,
+		NoSigs:    true,
+	})
+	if err != nil {
+		t.Fatalf("new nats server: %v", err)
+	}
+	go natsServer.Start()
+	if !natsServer.ReadyForConnections(5 * time.Second) {
+		t.Fatal("nats server not ready")
+	}
+	return natsServer
+}
+
+func newTestJetStream(t *testing.T, url string) *JetStream {
+	t.Helper()
+	js, err := NewJetStream(context.Background(), JetStreamConfig{
+		URL:           url,
+		DurablePrefix: "test",
+		AckWait:       time.Second,
+		MaxDeliver:    3,
+		MaxAge:        time.Hour,
+		Batch:         10,
+	})
+	if err != nil {
+		t.Fatalf("new jetstream: %v", err)
+	}
+	t.Cleanup(func() { js.Close() })
+	return js
+}
+
+// publish stores a message in JetStream with the headers publishers set
+func publish(t *testing.T, js *JetStream, subject, id, body string) {
+	t.Helper()
+	msg := nats.NewMsg(subject)
+	msg.Header.Set(nats.MsgIdHdr, id)
+	msg.Header.Set("Content-Type", "application/json")
+	msg.Data = []byte(body)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := js.js.PublishMsg(ctx, msg); err != nil {
+		t.Fatalf("publish %s: %v", id, err)
+	}
+}
+
+func next(t *testing.T, messages <-chan Message) Message {
+	t.Helper()
+	select {
+	case message, ok := <-messages:
+		if !ok {
+			t.Fatal("consumer stopped")
+		}
+		return message
+	case <-time.After(10 * time.Second):
+		t.Fatal("no message delivered")
+	}
+	return Message{}
+}
+
+func TestJetStreamDeliversAndRedeliversNakedMessages(t *testing.T) {
+	natsServer := startNATS(t, -1, t.TempDir())
+	defer natsServer.Shutdown()
+	js := newTestJetStream(t, natsServer.ClientURL())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	messages, err := js.Consume(ctx, Stream{Name: "RECEPCION_PROVEEDOR", Subjects: []string{"recepcion.proveedor"}})
+	if err != nil {
+		t.Fatalf("consume: %v", err)
+	}
+
+	publish(t, js, "recepcion.proveedor", "event-1", `{"id":"event-1"}`)
+	message := next(t, messages)
+	if message.Topic != "recepcion.proveedor" || message.MessageID != "event-1" || message.ContentType != "application/json" || string(message.Body) != `{"id":"event-1"}` {
+		t.Fatalf("delivered %+v", message)
+	}
+	if message.Timestamp.IsZero() || time.Since(message.Timestamp) > time.Minute {
+		t.Fatalf("stored at %v", message.Timestamp)
+	}
+
+	// A nak redelivers the message, an ack settles it
+	if err := message.Nak(); err != nil {
+		t.Fatalf("nak: %v", err)
+	}
+	if redelivered := next(t, messages); redelivered.MessageID != "event-1" {
+		t.Fatalf("redelivered %s, want event-1", redelivered.MessageID)
+	} else if err := redelivered.Ack(); err != nil {
+		t.Fatalf("ack: %v", err)
+	}
+
+	// The durable consumer holds no message once the ack is recorded
+	consumer, err := js.js.Consumer(context.Background(), "RECEPCION_PROVEEDOR", "test-recepcion-proveedor")
+	if err != nil {
+		t.Fatalf("consumer: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		info, err := consumer.Info(context.Background())
+		if err != nil {
+			t.Fatalf("consumer info: %v", err)
+		}
+		if info.NumAckPending == 0 && info.NumPending == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("consumer still holds %d unacked and %d pending messages", info.NumAckPending, info.NumPending)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	// Cancelling the context stops the consumer
+	cancel()
+	select {
+	case _, ok := <-messages:
+		if ok {
+			t.Fatal("message delivered after cancel")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("consumer did not stop")
+	}
+}
+
+func TestJetStreamResumesAfterTheServerRestarts(t *testing.T) {
+	dir := t.TempDir()
+	natsServer := startNATS(t, -1, dir)
+	js := newTestJetStream(t, natsServer.ClientURL())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	messages, err := js.Consume(ctx, Stream{Name: "TEMPERATURE_READINGS", Subjects: []string{"cold-chain.temperature.>"}})
+	if err != nil {
+		t.Fatalf("consume: %v", err)
+	}
+	publish(t, js, "cold-chain.temperature.po-1", "reading-1", `{}`)
+	next(t, messages).Ack()
+
+	reconnected := make(chan struct{}, 1)
+	js.Conn.SetReconnectHandler(func(*nats.Conn) { reconnected <- struct{}{} })
+
+	port := natsServer.Addr().(*net.TCPAddr).Port
+	natsServer.Shutdown()
+	natsServer.WaitForShutdown()
+	restarted := startNATS(t, port, dir)
+	defer restarted.Shutdown()
+
+	select {
+	case <-reconnected:
+	case <-time.After(15 * time.Second):
+		t.Fatal("connection did not reconnect")
+	}
+
+	// The publisher's side of the connection and the consumer both resume
+	sender := NATSSender{Conn: js.Conn}
+	if err := sender.Publish(context.Background(), "", "cold-chain.temperature.po-2", amqp091.Publishing{Body: []byte(`{"id":"reading-2"}`)}); err != nil {
+		t.Fatalf("publish after reconnect: %v", err)
+	}
+	if message := next(t, messages); message.Topic != "cold-chain.temperature.po-2" || string(message.Body) != `{"id":"reading-2"}` {
+		t.Fatalf("delivered %s %s after reconnect", message.Topic, message.Body)
+	}
+}
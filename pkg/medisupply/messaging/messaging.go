@@ -0,0 +1,111 @@
+// Package messaging decouples event handlers from the broker delivering the
+// events, so a service consumes from RabbitMQ or, in edge deployments, from
+// NATS JetStream, and takes sensor readings from an MQTT broker as well.
+// Events are published to RabbitMQ with publisher confirms.
+package messaging
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rabbitmq/amqp091-go"
+)
+
+// Transports events can be consumed from
+const (
+	TransportRabbitMQ = "rabbitmq"
+	TransportNATS     = "nats"
+)
+
+// Message is an event delivered by a transport
+type Message struct {
+	// Topic is the RabbitMQ routing key, NATS subject or MQTT topic of the message
+	Topic       string
+	MessageID   string
+	ContentType string
+	Body        []byte
+	// Headers holds the message headers, such as traceparent; MQTT
+	// messages have none
+	Headers map[string]string
+	// Timestamp is when the message was published, or stored by JetStream;
+	// zero when the publisher did not set it and for MQTT messages
+	Timestamp time.Time
+
+	// settle acknowledges the message to the transport; nil when the
+	// transport acknowledges on delivery
+	settle func(ack bool) error
+}
+
+// Ack tells the transport the message was handled
+func (m Message) Ack() error {
+	if m.settle == nil {
+		return nil
+	}
+	return m.settle(true)
+}
+
+// Nak asks the transport to redeliver the message later. RabbitMQ
+// deliveries are acknowledged on delivery and are not redelivered, and MQTT
+// has no redelivery on request.
+func (m Message) Nak() error {
+	if m.settle == nil {
+		return nil
+	}
+	return m.settle(false)
+}
+
+// FromDeliveries adapts auto-acknowledged RabbitMQ deliveries to messages
+func FromDeliveries(deliveries <-chan amqp091.Delivery) <-chan Message {
+	messages := make(chan Message)
+	go func() {
+		defer close(messages)
+		for delivery := range deliveries {
+			messages <- Message{
+				Topic:       delivery.RoutingKey,
+				MessageID:   delivery.MessageId,
+				ContentType: delivery.ContentType,
+				Body:        delivery.Body,
+				Headers:     Headers(delivery.Headers),
+				Timestamp:   delivery.Timestamp,
+			}
+		}
+	}()
+	return messages
+}
+
+// Merge delivers the messages of several subscriptions on one channel,
+// which is closed once all of them are
+func Merge(subscriptions ...<-chan Message) <-chan Message {
+	merged := make(chan Message)
+	var wg sync.WaitGroup
+	for _, subscription := range subscriptions {
+		wg.Add(1)
+		go func(subscription <-chan Message) {
+			defer wg.Done()
+			for message := range subscription {
+				merged <- message
+			}
+		}(subscription)
+	}
+	go func() {
+		wg.Wait()
+		close(merged)
+	}()
+	return merged
+}
+
+// Headers keeps the string and boolean headers of a RabbitMQ delivery, the
+// ones trace context and debug flags travel in
+func Headers(table amqp091.Table) map[string]string {
+	headers := make(map[string]string, len(table))
+	for key, value := range table {
+		switch value := value.(type) {
+		case string:
+			headers[key] = value
+		case bool:
+			headers[key] = strconv.FormatBool(value)
+		}
+	}
+	return headers
+}
@@ -0,0 +1,62 @@
+package messaging
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/rabbitmq/amqp091-go"
+)
+
+func TestHeaders(t *testing.T) {
+	headers := Headers(amqp091.Table{
+		"traceparent": "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01",
+		"debug":       true,
+		"x-retries":   int32(2),
+	})
+	want := map[string]string{
+		"traceparent": "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01",
+		"debug":       "true",
+	}
+	if !reflect.DeepEqual(headers, want) {
+		t.Fatalf("headers %v, want %v", headers, want)
+	}
+}
+
+func TestFromDeliveries(t *testing.T) {
+	deliveries := make(chan amqp091.Delivery, 1)
+	deliveries <- amqp091.Delivery{RoutingKey: "stock.bajo", MessageId: "message-1", ContentType: "application/json", Body: []byte("{}"), Headers: amqp091.Table{"debug": true}}
+	close(deliveries)
+
+	var messages []Message
+	for message := range FromDeliveries(deliveries) {
+		messages = append(messages, message)
+	}
+	if len(messages) != 1 {
+		t.Fatalf("got %d messages, want 1", len(messages))
+	}
+	message := messages[0]
+	if message.Topic != "stock.bajo" || message.MessageID != "message-1" || message.ContentType != "application/json" || string(message.Body) != "{}" || message.Headers["debug"] != "true" {
+		t.Fatalf("message %+v", message)
+	}
+	// RabbitMQ deliveries are acknowledged on delivery
+	if message.Ack() != nil || message.Nak() != nil {
+		t.Fatal("settling an auto-acknowledged message failed")
+	}
+}
+
+func TestMerge(t *testing.T) {
+	first, second := make(chan Message, 2), make(chan Message, 1)
+	first <- Message{MessageID: "1"}
+	first <- Message{MessageID: "2"}
+	second <- Message{MessageID: "3"}
+	close(first)
+	close(second)
+
+	seen := make(map[string]bool)
+	for message := range Merge(first, second) {
+		seen[message.MessageID] = true
+	}
+	if len(seen) != 3 {
+		t.Fatalf("merged %v, want the 3 messages", seen)
+	}
+}
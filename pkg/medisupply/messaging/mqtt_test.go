@@ -0,0 +1,140 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	broker "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+)
+
+// startMQTT runs an MQTT broker in the test process and returns its URL
+func startMQTT(t *testing.T) (*broker.Server, string) {
+	t.Helper()
+	server := broker.New(&broker.Options{
+		InlineClient: true,
+		Logger:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	if err := server.AddHook(new(auth.AllowHook), nil); err != nil {
+		t.Fatalf("add hook: %v", err)
+	}
+	listener := listeners.NewTCP(listeners.Config{ID: "tcp", Address: "127.0.0.1:0"})
+	if err := server.AddListener(listener); err != nil {
+		t.Fatalf("add listener: %v", err)
+	}
+	if err := server.Serve(); err != nil {
+		t.Fatalf("serve: %v", err)
+	}
+	t.Cleanup(func() { server.Close() })
+	return server, "tcp://" + listener.Address()
+}
+
+// awaitSubscription waits until the client holds filter on the broker
+func awaitSubscription(t *testing.T, server *broker.Server, clientID, filter string) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		if client, ok := server.Clients.Get(clientID); ok && !client.Closed() {
+			if _, ok := client.State.Subscriptions.Get(filter); ok {
+				return
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("%s did not subscribe to %s", clientID, filter)
+}
+
+func TestMQTTSubscriberDeliversAndReconnects(t *testing.T) {
+	server, url := startMQTT(t)
+	const filter = "cold-chain/shipments/+/telemetry"
+	subscriber, err := NewMQTTSubscriber(MQTTConfig{
+		URL:       url,
+		ClientID:  "proveedor-test",
+		Topics:    []string{filter},
+		QoS:       1,
+		KeepAlive: 5 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("new subscriber: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	messages := subscriber.Subscribe(ctx)
+	awaitSubscription(t, server, "proveedor-test", filter)
+	if subscriber.IsClosed() {
+		t.Fatal("subscriber reports a closed connection once subscribed")
+	}
+
+	if err := server.Publish("cold-chain/shipments/asn-1/telemetry", []byte(`{"temperature":4.2}`), false, 1); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	message := next(t, messages)
+	if message.Topic != "cold-chain/shipments/asn-1/telemetry" || string(message.Body) != `{"temperature":4.2}` {
+		t.Fatalf("delivered %s %s", message.Topic, message.Body)
+	}
+	if err := message.Ack(); err != nil {
+		t.Fatalf("ack: %v", err)
+	}
+
+	// The PUBACK settles the reading on the broker
+	client, _ := server.Clients.Get("proveedor-test")
+	deadline := time.Now().Add(5 * time.Second)
+	for client.State.Inflight.Len() > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("broker still holds the acknowledged reading")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	// The broker drops the connection; the session keeps the reading
+	// published meanwhile, which arrives once the subscriber reconnects
+	client.Stop(errors.New("dropped by test"))
+	if err := server.Publish("cold-chain/shipments/asn-2/telemetry", []byte(`{"temperature":9.1}`), false, 1); err != nil {
+		t.Fatalf("publish while disconnected: %v", err)
+	}
+	message = next(t, messages)
+	if message.Topic != "cold-chain/shipments/asn-2/telemetry" || string(message.Body) != `{"temperature":9.1}` {
+		t.Fatalf("delivered %s %s after reconnect", message.Topic, message.Body)
+	}
+	message.Ack()
+
+	// Cancelling the context disconnects and closes the channel
+	cancel()
+	select {
+	case _, ok := <-messages:
+		if ok {
+			t.Fatal("message delivered after cancel")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("subscriber did not stop")
+	}
+	if !subscriber.IsClosed() {
+		t.Fatal("subscriber reports an open connection after cancel")
+	}
+}
+
+func TestMQTTConfigValidate(t *testing.T) {
+	valid := MQTTConfig{URL: "tcp://mosquitto:1883", ClientID: "proveedor", Topics: []string{"a/+"}, QoS: 1, KeepAlive: 30 * time.Second}
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	for name, mutate := range map[string]func(*MQTTConfig){
+		"no url":           func(c *MQTTConfig) { c.URL = "" },
+		"no topics":        func(c *MQTTConfig) { c.Topics = nil },
+		"empty topic":      func(c *MQTTConfig) { c.Topics = []string{""} },
+		"qos 2":            func(c *MQTTConfig) { c.QoS = 2 },
+		"short keep alive": func(c *MQTTConfig) { c.KeepAlive = time.Millisecond },
+	} {
+		config := valid
+		mutate(&config)
+		if err := config.Validate(); err == nil {
+			t.Errorf("%s: Validate accepted %+v", name, config)
+		}
+	}
+}
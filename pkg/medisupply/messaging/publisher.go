@@ -0,0 +1,200 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/rabbitmq/amqp091-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+var (
+	// ErrNacked is returned when the broker refused a message
+	ErrNacked = errors.New("message nacked by broker")
+	// ErrConfirmTimeout is returned when the broker did not confirm a message in time
+	ErrConfirmTimeout = errors.New("publish confirmation timed out")
+)
+
+// Publish results recorded by the metrics
+const (
+	resultAcked   = "acked"
+	resultNacked  = "nacked"
+	resultTimeout = "timeout"
+	resultError   = "error"
+)
+
+// CircuitBreaker fails calls at once while the broker is failing
+type CircuitBreaker interface {
+	Allow(ctx context.Context) error
+	Record(ctx context.Context, success bool)
+}
+
+// PublisherConfig represents the publisher confirm settings
+type PublisherConfig struct {
+	// Timeout is how long a publish waits for the broker's confirmation
+	Timeout time.Duration
+	// MaxAttempts is the number of publishes of a message before giving up
+	MaxAttempts int
+	// RetryDelay is the wait before the second attempt; it doubles after each
+	RetryDelay time.Duration
+	// Breaker, when set, fails publishes at once while the broker is
+	// failing; it is shared by the publishers on the connection
+	Breaker CircuitBreaker
+}
+
+// Validate checks the publisher confirm settings
+func (c PublisherConfig) Validate() error {
+	if c.Timeout <= 0 {
+		return fmt.Errorf("confirm timeout must be positive")
+	}
+	if c.MaxAttempts < 1 {
+		return fmt.Errorf("max publish attempts must be at least 1")
+	}
+	if c.RetryDelay < 0 {
+		return fmt.Errorf("publish retry delay must not be negative")
+	}
+	return nil
+}
+
+// Sender publishes messages. Publisher is the one the services run with;
+// handlers depend on Sender so tests can record what they publish instead.
+type Sender interface {
+	Publish(ctx context.Context, exchange, routingKey string, msg amqp091.Publishing) error
+}
+
+// Publisher publishes RabbitMQ messages on a channel in confirm mode, so a
+// publish only succeeds once the broker has taken responsibility for the
+// message. Nacked and unconfirmed publishes are retried, and publishes that
+// never get acked fail instead of being lost silently.
+type Publisher struct {
+	Channel *amqp091.Channel
+	Config  PublisherConfig
+	Logger  *log.Logger
+
+	attempts metric.Int64Counter
+	failures metric.Int64Counter
+}
+
+// NewPublisher puts channel in confirm mode and returns a publisher on it.
+// Every publish on the channel is confirmed from then on, so all of them
+// must go through the publisher.
+func NewPublisher(channel *amqp091.Channel, config PublisherConfig, logger *log.Logger) (*Publisher, error) {
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid publisher confirm config: %w", err)
+	}
+	if err := channel.Confirm(false); err != nil {
+		return nil, fmt.Errorf("failed to enable publisher confirms: %w", err)
+	}
+
+	p := &Publisher{
+		Channel: channel,
+		Config:  config,
+		Logger:  logger,
+	}
+	meter := otel.Meter("medisupply/messaging")
+	p.attempts, _ = meter.Int64Counter(
+		"rabbitmq_publish_attempts_total",
+		metric.WithDescription("RabbitMQ publish attempts by exchange and confirmation result"),
+	)
+	p.failures, _ = meter.Int64Counter(
+		"rabbitmq_publish_failures_total",
+		metric.WithDescription("RabbitMQ publishes abandoned after every attempt failed"),
+	)
+	return p, nil
+}
+
+// Publish publishes msg and waits until the broker acks it, retrying nacked,
+// unconfirmed and failed publishes. A retry after a timeout may deliver the
+// message twice, which consumers absorb by deduplicating on its message ID.
+func (p *Publisher) Publish(ctx context.Context, exchange, routingKey string, msg amqp091.Publishing) error {
+	wait := p.Config.RetryDelay
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = p.allow(ctx); err != nil {
+			break
+		}
+		var result string
+		result, err = p.publish(ctx, exchange, routingKey, msg)
+		p.record(ctx, err == nil)
+		p.attempts.Add(ctx, 1, metric.WithAttributes(
+			attribute.String("exchange", exchange),
+			attribute.String("result", result),
+		))
+		if err == nil {
+			return nil
+		}
+		if attempt >= p.Config.MaxAttempts || ctx.Err() != nil {
+			break
+		}
+
+		p.Logger.Printf("Publish not confirmed, retrying - message_id: %s, exchange: %s, attempt: %d/%d, error: %v", msg.MessageId, exchange, attempt, p.Config.MaxAttempts, err)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+		}
+		wait *= 2
+	}
+
+	p.failures.Add(ctx, 1, metric.WithAttributes(attribute.String("exchange", exchange)))
+	return err
+}
+
+// allow asks the breaker, when there is one, whether to attempt a publish
+func (p *Publisher) allow(ctx context.Context) error {
+	if p.Config.Breaker == nil {
+		return nil
+	}
+	return p.Config.Breaker.Allow(ctx)
+}
+
+// record reports the outcome of a publish to the breaker, when there is one
+func (p *Publisher) record(ctx context.Context, success bool) {
+	if p.Config.Breaker != nil {
+		p.Config.Breaker.Record(ctx, success)
+	}
+}
+
+// publish makes one publish attempt and returns its result
+func (p *Publisher) publish(ctx context.Context, exchange, routingKey string, msg amqp091.Publishing) (string, error) {
+	confirmation, err := p.Channel.PublishWithDeferredConfirmWithContext(ctx, exchange, routingKey, false, false, msg)
+	if err != nil {
+		return resultError, fmt.Errorf("failed to send message: %w", err)
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, p.Config.Timeout)
+	defer cancel()
+
+	acked, err := confirmation.WaitContext(waitCtx)
+	switch {
+	case err != nil && ctx.Err() == nil:
+		return resultTimeout, fmt.Errorf("%w after %v", ErrConfirmTimeout, p.Config.Timeout)
+	case err != nil:
+		return resultError, fmt.Errorf("failed to await publish confirmation: %w", err)
+	case !acked:
+		return resultNacked, ErrNacked
+	}
+	return resultAcked, nil
+}
+
+// NATSSender publishes messages on a NATS connection, to the subject named
+// by their routing key; the exchange is ignored. NATS core publishes carry
+// the body only, so consumers take the event type and IDs from it, and
+// JetStream keeps the messages of subjects one of its streams captures.
+// Publishes made while the connection reconnects are held until it is back.
+type NATSSender struct {
+	Conn *nats.Conn
+}
+
+// Publish publishes the body of msg to the subject routingKey
+func (s NATSSender) Publish(ctx context.Context, exchange, routingKey string, msg amqp091.Publishing) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.Conn.Publish(routingKey, msg.Body)
+}
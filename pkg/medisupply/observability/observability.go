@@ -0,0 +1,188 @@
+package observability
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/jaeger"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/prometheus"
+	"go.opentelemetry.io/otel/propagation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+)
+
+// Trace exporters, as named by OTEL_TRACES_EXPORTER
+const (
+	ExporterOTLP = "otlp"
+	// ExporterJaeger uses the deprecated Jaeger collector protocol, for
+	// collectors without OTLP
+	ExporterJaeger = "jaeger"
+	ExporterNone   = "none"
+)
+
+// OTLP protocols, as named by OTEL_EXPORTER_OTLP_PROTOCOL
+const (
+	ProtocolGRPC         = "grpc"
+	ProtocolHTTPProtobuf = "http/protobuf"
+)
+
+// TracingConfig represents where traces are exported and which are sampled.
+// The exporters and the SDK read the rest of the standard variables
+// themselves: the endpoint, headers, timeout and certificate of
+// OTEL_EXPORTER_OTLP_* and their OTEL_EXPORTER_OTLP_TRACES_* overrides,
+// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES.
+type TracingConfig struct {
+	// Exporter is otlp, the default, jaeger or none
+	Exporter string
+	// Protocol is the OTLP protocol, http/protobuf by default or grpc
+	Protocol string
+	// JaegerEndpoint is the collector URL of the Jaeger exporter
+	JaegerEndpoint string
+	// Sampler is one of the sampler names, parentbased_always_on by default
+	Sampler string
+	// SamplerArg is the ratio or the traces per second of the sampler
+	SamplerArg string
+}
+
+// TracingConfigFromEnv reads the tracing settings from the standard
+// OpenTelemetry variables; JAEGER_ENDPOINT is still read for the Jaeger
+// exporter
+func TracingConfigFromEnv() TracingConfig {
+	protocol := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_PROTOCOL")
+	if protocol == "" {
+		protocol = os.Getenv("OTEL_EXPORTER_OTLP_PROTOCOL")
+	}
+	jaegerEndpoint := os.Getenv("OTEL_EXPORTER_JAEGER_ENDPOINT")
+	if jaegerEndpoint == "" {
+		jaegerEndpoint = os.Getenv("JAEGER_ENDPOINT")
+	}
+	return TracingConfig{
+		Exporter:       strings.ToLower(strings.TrimSpace(os.Getenv("OTEL_TRACES_EXPORTER"))),
+		Protocol:       strings.ToLower(strings.TrimSpace(protocol)),
+		JaegerEndpoint: jaegerEndpoint,
+		Sampler:        strings.ToLower(strings.TrimSpace(os.Getenv("OTEL_TRACES_SAMPLER"))),
+		SamplerArg:     strings.TrimSpace(os.Getenv("OTEL_TRACES_SAMPLER_ARG")),
+	}
+}
+
+// InitTracing initializes OpenTelemetry tracing
+func InitTracing(serviceName string, config TracingConfig) (*sdktrace.TracerProvider, error) {
+	exp, err := newTraceExporter(context.Background(), config)
+	if err != nil {
+		return nil, err
+	}
+
+	sampler, err := NewSampler(config.Sampler, config.SamplerArg)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := newResource(serviceName)
+	if err != nil {
+		return nil, err
+	}
+
+	// Without an exporter spans are still created, so trace IDs keep
+	// propagating to the services downstream
+	options := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sampler),
+	}
+	if exp != nil {
+		options = append(options, sdktrace.WithBatcher(exp))
+	}
+	tp := sdktrace.NewTracerProvider(options...)
+
+	// Set global tracer provider; W3C trace context carries traces and their
+	// sampling decisions across services
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	return tp, nil
+}
+
+// newTraceExporter creates the configured span exporter; it is nil when
+// traces are not exported
+func newTraceExporter(ctx context.Context, config TracingConfig) (sdktrace.SpanExporter, error) {
+	switch config.Exporter {
+	case "", ExporterOTLP:
+		switch config.Protocol {
+		case "", ProtocolHTTPProtobuf:
+			return otlptracehttp.New(ctx)
+		case ProtocolGRPC:
+			return otlptracegrpc.New(ctx)
+		default:
+			return nil, fmt.Errorf("unsupported OTLP protocol %q", config.Protocol)
+		}
+	case ExporterJaeger:
+		if config.JaegerEndpoint == "" {
+			return jaeger.New(jaeger.WithCollectorEndpoint())
+		}
+		return jaeger.New(jaeger.WithCollectorEndpoint(jaeger.WithEndpoint(config.JaegerEndpoint)))
+	case ExporterNone:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unsupported trace exporter %q", config.Exporter)
+	}
+}
+
+// newResource describes the service; OTEL_SERVICE_NAME and
+// OTEL_RESOURCE_ATTRIBUTES override the defaults
+func newResource(serviceName string) (*resource.Resource, error) {
+	return resource.New(context.Background(),
+		resource.WithAttributes(
+			semconv.ServiceNameKey.String(serviceName),
+			semconv.ServiceVersionKey.String("1.0.0"),
+		),
+		resource.WithFromEnv(),
+	)
+}
+
+// InitMetrics initializes OpenTelemetry metrics
+func InitMetrics(serviceName string) (*sdkmetric.MeterProvider, error) {
+	// Create Prometheus exporter
+	exp, err := prometheus.New()
+	if err != nil {
+		return nil, err
+	}
+
+	// Create resource
+	res, err := newResource(serviceName)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create meter provider
+	mp := sdkmetric.NewMeterProvider(
+		sdkmetric.WithReader(exp),
+		sdkmetric.WithResource(res),
+	)
+
+	// Set global meter provider
+	otel.SetMeterProvider(mp)
+
+	return mp, nil
+}
+
+// Shutdown gracefully shuts down the observability components
+func Shutdown(tp *sdktrace.TracerProvider, mp *sdkmetric.MeterProvider) {
+	if tp != nil {
+		if err := tp.Shutdown(context.Background()); err != nil {
+			log.Printf("Error shutting down tracer provider: %v", err)
+		}
+	}
+
+	if mp != nil {
+		if err := mp.Shutdown(context.Background()); err != nil {
+			log.Printf("Error shutting down meter provider: %v", err)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package observability
+
+import (
+	"context"
+	"testing"
+
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+)
+
+func TestTracingConfigFromEnv(t *testing.T) {
+	t.Setenv("OTEL_TRACES_EXPORTER", " OTLP ")
+	t.Setenv("OTEL_EXPORTER_OTLP_PROTOCOL", "http/protobuf")
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_PROTOCOL", "GRPC")
+	t.Setenv("OTEL_EXPORTER_JAEGER_ENDPOINT", "")
+	t.Setenv("JAEGER_ENDPOINT", "http://jaeger:14268/api/traces")
+	t.Setenv("OTEL_TRACES_SAMPLER", "ParentBased_TraceIDRatio")
+	t.Setenv("OTEL_TRACES_SAMPLER_ARG", " 0.25 ")
+
+	want := TracingConfig{
+		Exporter:       ExporterOTLP,
+		Protocol:       ProtocolGRPC,
+		JaegerEndpoint: "http://jaeger:14268/api/traces",
+		Sampler:        "parentbased_traceidratio",
+		SamplerArg:     "0.25",
+	}
+	if got := TracingConfigFromEnv(); got != want {
+		t.Fatalf("config %+v, want %+v", got, want)
+	}
+
+	// The signal-specific variables take precedence over the general ones
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_PROTOCOL", "")
+	t.Setenv("OTEL_EXPORTER_JAEGER_ENDPOINT", "http://collector:14268/api/traces")
+	if got := TracingConfigFromEnv(); got.Protocol != ProtocolHTTPProtobuf || got.JaegerEndpoint != "http://collector:14268/api/traces" {
+		t.Fatalf("config %+v", got)
+	}
+}
+
+func TestNewTraceExporter(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		config   TracingConfig
+		exporter bool
+		valid    bool
+	}{
+		{"default", TracingConfig{}, true, true},
+		{"OTLP over gRPC", TracingConfig{Exporter: ExporterOTLP, Protocol: ProtocolGRPC}, true, true},
+		{"Jaeger", TracingConfig{Exporter: ExporterJaeger, JaegerEndpoint: "http://jaeger:14268/api/traces"}, true, true},
+		{"none", TracingConfig{Exporter: ExporterNone}, false, true},
+		{"unknown protocol", TracingConfig{Protocol: "http/json"}, false, false},
+		{"unknown exporter", TracingConfig{Exporter: "zipkin"}, false, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			exporter, err := newTraceExporter(context.Background(), tc.config)
+			if (err == nil) != tc.valid || (exporter != nil) != tc.exporter {
+				t.Fatalf("exporter %v, error %v", exporter, err)
+			}
+			if exporter != nil {
+				exporter.Shutdown(context.Background())
+			}
+		})
+	}
+}
+
+func TestNewResourceHonorsTheEnvironment(t *testing.T) {
+	t.Setenv("OTEL_SERVICE_NAME", "")
+	t.Setenv("OTEL_RESOURCE_ATTRIBUTES", "deployment.environment=staging")
+	res, err := newResource("orden-compra")
+	if err != nil {
+		t.Fatalf("resource: %v", err)
+	}
+	attributes := res.Set()
+	if name, _ := attributes.Value(semconv.ServiceNameKey); name.AsString() != "orden-compra" {
+		t.Fatalf("service name %q, want orden-compra", name.AsString())
+	}
+	if environment, _ := attributes.Value(semconv.DeploymentEnvironmentKey); environment.AsString() != "staging" {
+		t.Fatalf("deployment.environment %q, want staging", environment.AsString())
+	}
+
+	t.Setenv("OTEL_SERVICE_NAME", "orden-compra-canary")
+	res, err = newResource("orden-compra")
+	if err != nil {
+		t.Fatalf("resource: %v", err)
+	}
+	if name, _ := res.Set().Value(semconv.ServiceNameKey); name.AsString() != "orden-compra-canary" {
+		t.Fatalf("service name %q, want the OTEL_SERVICE_NAME override", name.AsString())
+	}
+}
+
+func TestInitTracingWithoutAnExporter(t *testing.T) {
+	tp, err := InitTracing("orden-compra", TracingConfig{Exporter: ExporterNone})
+	if err != nil {
+		t.Fatalf("init tracing: %v", err)
+	}
+	defer Shutdown(tp, nil)
+
+	// Spans are still created, so trace IDs propagate downstream
+	_, span := tp.Tracer("test").Start(context.Background(), "operation")
+	defer span.End()
+	if !span.SpanContext().IsValid() || !span.SpanContext().IsSampled() {
+		t.Fatalf("span context %+v, want a sampled trace", span.SpanContext())
+	}
+}
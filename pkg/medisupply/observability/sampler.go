@@ -0,0 +1,206 @@
+package observability
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Samplers, as named by OTEL_TRACES_SAMPLER. The parent-based samplers
+// follow the sampling decision of a propagated parent span and apply theirs
+// to new traces only.
+const (
+	SamplerAlwaysOn                = "always_on"
+	SamplerAlwaysOff               = "always_off"
+	SamplerTraceIDRatio            = "traceidratio"
+	SamplerParentBasedAlwaysOn     = "parentbased_always_on"
+	SamplerParentBasedAlwaysOff    = "parentbased_always_off"
+	SamplerParentBasedTraceIDRatio = "parentbased_traceidratio"
+	// SamplerRateLimited samples up to OTEL_TRACES_SAMPLER_ARG traces per
+	// second; it is not a standard sampler
+	SamplerRateLimited            = "ratelimited"
+	SamplerParentBasedRateLimited = "parentbased_ratelimited"
+)
+
+// defaultRateLimit is the traces per second of the rate-limited samplers
+// without an argument
+const defaultRateLimit = 10
+
+// DebugHeader is the message header that has a message traced whatever the
+// sampler decides
+const DebugHeader = "debug"
+
+type debugKey struct{}
+
+// WithDebug marks the spans started from ctx to be sampled
+func WithDebug(ctx context.Context) context.Context {
+	return context.WithValue(ctx, debugKey{}, true)
+}
+
+// IsDebug reports whether spans started from ctx are always sampled
+func IsDebug(ctx context.Context) bool {
+	debug, _ := ctx.Value(debugKey{}).(bool)
+	return debug
+}
+
+// NewSampler creates the sampler of an OTEL_TRACES_SAMPLER name and
+// argument. Spans started from a WithDebug context are sampled by all of
+// them.
+func NewSampler(name, arg string) (sdktrace.Sampler, error) {
+	var sampler sdktrace.Sampler
+	switch name {
+	case "", SamplerParentBasedAlwaysOn:
+		sampler = sdktrace.ParentBased(sdktrace.AlwaysSample())
+	case SamplerAlwaysOn:
+		sampler = sdktrace.AlwaysSample()
+	case SamplerAlwaysOff:
+		sampler = sdktrace.NeverSample()
+	case SamplerParentBasedAlwaysOff:
+		sampler = sdktrace.ParentBased(sdktrace.NeverSample())
+	case SamplerTraceIDRatio, SamplerParentBasedTraceIDRatio:
+		ratio, err := parseSamplerArg(arg, 1)
+		if err != nil {
+			return nil, err
+		}
+		if ratio > 1 {
+			return nil, fmt.Errorf("sampling ratio %v is above 1", ratio)
+		}
+		sampler = sdktrace.TraceIDRatioBased(ratio)
+		if name == SamplerParentBasedTraceIDRatio {
+			sampler = sdktrace.ParentBased(sampler)
+		}
+	case SamplerRateLimited, SamplerParentBasedRateLimited:
+		rate, err := parseSamplerArg(arg, defaultRateLimit)
+		if err != nil {
+			return nil, err
+		}
+		sampler = newRateLimitedSampler(rate)
+		if name == SamplerParentBasedRateLimited {
+			sampler = sdktrace.ParentBased(sampler)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported trace sampler %q", name)
+	}
+	return debugSampler{base: sampler}, nil
+}
+
+// parseSamplerArg parses a non-negative sampler argument
+func parseSamplerArg(arg string, defaultValue float64) (float64, error) {
+	if arg == "" {
+		return defaultValue, nil
+	}
+	value, err := strconv.ParseFloat(arg, 64)
+	if err != nil || value < 0 || math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, fmt.Errorf("invalid sampler argument %q", arg)
+	}
+	return value, nil
+}
+
+// debugSampler samples spans started from a WithDebug context and leaves
+// the others to its base sampler
+type debugSampler struct {
+	base sdktrace.Sampler
+}
+
+func (s debugSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	if IsDebug(p.ParentContext) {
+		return sdktrace.SamplingResult{
+			Decision:   sdktrace.RecordAndSample,
+			Tracestate: trace.SpanContextFromContext(p.ParentContext).TraceState(),
+		}
+	}
+	return s.base.ShouldSample(p)
+}
+
+func (s debugSampler) Description() string {
+	return "Debug{" + s.base.Description() + "}"
+}
+
+// rateLimitedSampler samples up to rate traces per second, allowing bursts
+// of one second's worth
+type rateLimitedSampler struct {
+	rate float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimitedSampler(rate float64) *rateLimitedSampler {
+	return &rateLimitedSampler{
+		rate:   rate,
+		tokens: math.Max(rate, 1),
+		last:   time.Now(),
+	}
+}
+
+func (s *rateLimitedSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	decision := sdktrace.Drop
+	if s.allow() {
+		decision = sdktrace.RecordAndSample
+	}
+	return sdktrace.SamplingResult{
+		Decision:   decision,
+		Tracestate: trace.SpanContextFromContext(p.ParentContext).TraceState(),
+	}
+}
+
+func (s *rateLimitedSampler) Description() string {
+	return fmt.Sprintf("RateLimited{%g}", s.rate)
+}
+
+// allow takes a token, refilled at the sampling rate
+func (s *rateLimitedSampler) allow() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.tokens = math.Min(s.tokens+now.Sub(s.last).Seconds()*s.rate, math.Max(s.rate, 1))
+	s.last = now
+	if s.tokens < 1 {
+		return false
+	}
+	s.tokens--
+	return true
+}
+
+// StartConsumerSpan starts the span of processing a consumed message. It
+// continues the trace of a traceparent header, and a message with a debug
+// header set to true is sampled whatever the sampler decides.
+func StartConsumerSpan(ctx context.Context, name string, headers map[string]string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	// Header names are matched regardless of case, as brokers and
+	// publishers differ in how they write them
+	carrier := make(propagation.MapCarrier, len(headers))
+	for key, value := range headers {
+		carrier[strings.ToLower(key)] = value
+	}
+	ctx = otel.GetTextMapPropagator().Extract(ctx, carrier)
+	if isTrue(carrier[DebugHeader]) {
+		ctx = WithDebug(ctx)
+		attributes = append(attributes, attribute.Bool("debug", true))
+	}
+
+	return otel.Tracer("medisupply/observability").Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(attributes...),
+	)
+}
+
+// isTrue reports whether a header value switches a flag on
+func isTrue(value string) bool {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "true", "1", "yes", "on":
+		return true
+	}
+	return false
+}
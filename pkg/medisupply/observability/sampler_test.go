@@ -0,0 +1,135 @@
+package observability
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// sampled reports whether a sampler samples a new root span started from ctx
+func sampled(sampler sdktrace.Sampler, ctx context.Context) bool {
+	return sampler.ShouldSample(sdktrace.SamplingParameters{
+		ParentContext: ctx,
+		TraceID:       trace.TraceID{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		Name:          "operation",
+	}).Decision == sdktrace.RecordAndSample
+}
+
+// withParent returns ctx under a remote parent span with the given sampling flag
+func withParent(ctx context.Context, sampled bool) context.Context {
+	config := trace.SpanContextConfig{TraceID: trace.TraceID{1}, SpanID: trace.SpanID{1}, Remote: true}
+	if sampled {
+		config.TraceFlags = trace.FlagsSampled
+	}
+	return trace.ContextWithRemoteSpanContext(ctx, trace.NewSpanContext(config))
+}
+
+func TestNewSampler(t *testing.T) {
+	ctx := context.Background()
+	for _, tc := range []struct {
+		name         string
+		arg          string
+		root         bool
+		sampledChild bool
+		droppedChild bool
+	}{
+		{"", "", true, true, false},
+		{SamplerParentBasedAlwaysOn, "", true, true, false},
+		{SamplerAlwaysOn, "", true, true, true},
+		{SamplerAlwaysOff, "", false, false, false},
+		{SamplerParentBasedAlwaysOff, "", false, true, false},
+		// The test trace ID is the highest, so only a ratio of 1 samples it
+		{SamplerTraceIDRatio, "0.5", false, false, false},
+		{SamplerTraceIDRatio, "", true, true, true},
+		{SamplerParentBasedTraceIDRatio, "0", false, true, false},
+		{SamplerRateLimited, "5", true, true, true},
+		{SamplerParentBasedRateLimited, "", true, true, false},
+	} {
+		t.Run(tc.name+"/"+tc.arg, func(t *testing.T) {
+			sampler, err := NewSampler(tc.name, tc.arg)
+			if err != nil {
+				t.Fatalf("new sampler: %v", err)
+			}
+			if got := sampled(sampler, ctx); got != tc.root {
+				t.Errorf("root span sampled %t, want %t", got, tc.root)
+			}
+			if got := sampled(sampler, withParent(ctx, true)); got != tc.sampledChild {
+				t.Errorf("child of a sampled parent sampled %t, want %t", got, tc.sampledChild)
+			}
+			if got := sampled(sampler, withParent(ctx, false)); got != tc.droppedChild {
+				t.Errorf("child of a dropped parent sampled %t, want %t", got, tc.droppedChild)
+			}
+			// Debug spans are sampled whatever the sampler decides
+			if !sampled(sampler, WithDebug(withParent(ctx, false))) {
+				t.Error("debug span dropped")
+			}
+		})
+	}
+
+	for _, tc := range []struct{ name, arg string }{
+		{"probabilistic", ""},
+		{SamplerTraceIDRatio, "1.5"},
+		{SamplerTraceIDRatio, "half"},
+		{SamplerRateLimited, "-1"},
+		{SamplerRateLimited, "NaN"},
+	} {
+		if _, err := NewSampler(tc.name, tc.arg); err == nil {
+			t.Errorf("created sampler %q with argument %q", tc.name, tc.arg)
+		}
+	}
+}
+
+func TestRateLimitedSamplerAllowsBurstsOfItsRate(t *testing.T) {
+	sampler := newRateLimitedSampler(3)
+	var count int
+	for i := 0; i < 10; i++ {
+		if sampled(sampler, context.Background()) {
+			count++
+		}
+	}
+	if count != 3 {
+		t.Fatalf("sampled %d of a burst, want 3", count)
+	}
+
+	// A rate below one still samples the first trace
+	if !sampled(newRateLimitedSampler(0.1), context.Background()) {
+		t.Fatal("a slow sampler dropped its first trace")
+	}
+}
+
+func TestStartConsumerSpan(t *testing.T) {
+	sampler, err := NewSampler(SamplerAlwaysOff, "")
+	if err != nil {
+		t.Fatalf("new sampler: %v", err)
+	}
+	provider := otel.GetTracerProvider()
+	propagator := otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSampler(sampler)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(provider)
+		otel.SetTextMapPropagator(propagator)
+	})
+
+	traceparent := "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-00"
+	_, span := StartConsumerSpan(context.Background(), "orders process", map[string]string{"Traceparent": traceparent})
+	span.End()
+	if span.SpanContext().TraceID().String() != "0af7651916cd43dd8448eb211c80319c" || span.SpanContext().IsSampled() {
+		t.Fatalf("span context %+v, want the publisher's unsampled trace", span.SpanContext())
+	}
+
+	for _, debug := range []string{"true", "1", " Yes "} {
+		ctx, span := StartConsumerSpan(context.Background(), "orders process", map[string]string{"traceparent": traceparent, "Debug": debug})
+		span.End()
+		if !span.SpanContext().IsSampled() || !IsDebug(ctx) {
+			t.Fatalf("message with debug %q was not sampled", debug)
+		}
+	}
+	if ctx, _ := StartConsumerSpan(context.Background(), "orders process", map[string]string{DebugHeader: "false"}); IsDebug(ctx) {
+		t.Fatal("debug false marked the message for sampling")
+	}
+}
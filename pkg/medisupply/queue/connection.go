@@ -0,0 +1,105 @@
+package queue
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/rabbitmq/amqp091-go"
+)
+
+// ConnectionConfig represents the broker connection settings
+type ConnectionConfig struct {
+	// URL is an amqp:// or amqps:// URL; it need not carry credentials
+	URL string
+	// Username and Password replace the credentials of the URL when set
+	Username string
+	Password string
+	// ExternalAuth authenticates with the client certificate instead of a
+	// password, for brokers with the rabbitmq_auth_mechanism_ssl plugin
+	ExternalAuth bool
+	// CAFile verifies the broker's certificate on amqps://; the system
+	// roots are used without it
+	CAFile string
+	// CertFile and KeyFile are the client certificate for brokers that
+	// verify their peers
+	CertFile string
+	KeyFile  string
+	// ServerName overrides the host name the broker's certificate is
+	// verified against
+	ServerName string
+	// ConnectionName tags the connection in the broker's management UI
+	ConnectionName string
+}
+
+// Dial connects to the broker
+func Dial(config ConnectionConfig) (*amqp091.Connection, error) {
+	uri, err := amqp091.ParseURI(config.URL)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+	}
+
+	properties := amqp091.NewConnectionProperties()
+	if config.ConnectionName != "" {
+		properties.SetClientConnectionName(config.ConnectionName)
+	}
+	amqpConfig := amqp091.Config{
+		Heartbeat:  10 * time.Second,
+		Locale:     "en_US",
+		Properties: properties,
+	}
+
+	switch {
+	case config.ExternalAuth:
+		amqpConfig.SASL = []amqp091.Authentication{&amqp091.ExternalAuth{}}
+	case config.Username != "":
+		amqpConfig.SASL = []amqp091.Authentication{&amqp091.PlainAuth{Username: config.Username, Password: config.Password}}
+	}
+
+	if uri.Scheme == "amqps" {
+		amqpConfig.TLSClientConfig, err = config.tlsConfig()
+		if err != nil {
+			return nil, err
+		}
+	} else if config.CAFile != "" || config.CertFile != "" || config.ExternalAuth {
+		return nil, fmt.Errorf("%w: TLS settings need an amqps:// URL", ErrInvalidConfig)
+	}
+
+	conn, err := amqp091.DialConfig(config.URL, amqpConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", uri.Host, err)
+	}
+	return conn, nil
+}
+
+// tlsConfig builds the client TLS configuration of an amqps:// connection
+func (c ConnectionConfig) tlsConfig() (*tls.Config, error) {
+	config := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: c.ServerName,
+	}
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read broker CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%w: no certificates in broker CA file %s", ErrInvalidConfig, c.CAFile)
+		}
+		config.RootCAs = pool
+	}
+	if c.CertFile != "" || c.KeyFile != "" {
+		certificate, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{certificate}
+	}
+	if c.ExternalAuth && len(config.Certificates) == 0 {
+		return nil, fmt.Errorf("%w: external auth needs a client certificate", ErrInvalidConfig)
+	}
+	return config, nil
+}
@@ -0,0 +1,94 @@
+package queue
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeCertificate writes a self-signed certificate and its key to dir
+func writeCertificate(t *testing.T, dir string) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "orden-compra"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshal key: %v", err)
+	}
+	certFile = filepath.Join(dir, "client.crt")
+	keyFile = filepath.Join(dir, "client.key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("write certificate: %v", err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatalf("write key: %v", err)
+	}
+	return certFile, keyFile
+}
+
+func TestDialRejectsInvalidSettings(t *testing.T) {
+	certFile, keyFile := writeCertificate(t, t.TempDir())
+	for _, tc := range []struct {
+		name   string
+		config ConnectionConfig
+	}{
+		{"malformed URL", ConnectionConfig{URL: "http://rabbitmq:5672/"}},
+		{"CA over plain amqp", ConnectionConfig{URL: "amqp://rabbitmq:5672/", CAFile: certFile}},
+		{"client certificate over plain amqp", ConnectionConfig{URL: "amqp://rabbitmq:5672/", CertFile: certFile, KeyFile: keyFile}},
+		{"external auth over plain amqp", ConnectionConfig{URL: "amqp://rabbitmq:5672/", ExternalAuth: true}},
+		{"external auth without a certificate", ConnectionConfig{URL: "amqps://rabbitmq:5671/", ExternalAuth: true}},
+		{"CA without certificates", ConnectionConfig{URL: "amqps://rabbitmq:5671/", CAFile: keyFile}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			// Settings are checked before anything is dialed
+			if _, err := Dial(tc.config); !errors.Is(err, ErrInvalidConfig) {
+				t.Fatalf("Dial() = %v, want ErrInvalidConfig", err)
+			}
+		})
+	}
+}
+
+func TestConnectionTLSConfig(t *testing.T) {
+	certFile, keyFile := writeCertificate(t, t.TempDir())
+
+	config, err := ConnectionConfig{CAFile: certFile, CertFile: certFile, KeyFile: keyFile, ServerName: "rabbitmq.internal", ExternalAuth: true}.tlsConfig()
+	if err != nil {
+		t.Fatalf("tls config: %v", err)
+	}
+	if config.MinVersion != tls.VersionTLS12 || config.ServerName != "rabbitmq.internal" || config.RootCAs == nil || len(config.Certificates) != 1 {
+		t.Fatalf("tls config has min version %x, server name %q, roots %v and %d certificates", config.MinVersion, config.ServerName, config.RootCAs, len(config.Certificates))
+	}
+
+	// Without a CA the system roots verify the broker
+	if config, err := (ConnectionConfig{}).tlsConfig(); err != nil || config.RootCAs != nil || len(config.Certificates) != 0 {
+		t.Fatalf("default tls config %+v, error %v", config, err)
+	}
+	if _, err := (ConnectionConfig{CAFile: filepath.Join(t.TempDir(), "missing.crt")}).tlsConfig(); err == nil {
+		t.Fatal("read a missing CA file")
+	}
+	if _, err := (ConnectionConfig{CertFile: certFile}).tlsConfig(); err == nil {
+		t.Fatal("loaded a client certificate without its key")
+	}
+}
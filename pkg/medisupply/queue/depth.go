@@ -0,0 +1,158 @@
+package queue
+
+import (
+	"context"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rabbitmq/amqp091-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Depth represents the backlog of a queue when it was last polled
+type Depth struct {
+	Queue string `json:"queue"`
+	// Messages is the number of messages ready for delivery; messages
+	// delivered but not yet acknowledged are not counted
+	Messages  int       `json:"messages"`
+	Consumers int       `json:"consumers"`
+	PolledAt  time.Time `json:"polled_at"`
+}
+
+// DepthCollector polls the depth of queues and reports it as gauges. It
+// declares the queues passively, which needs no access to the management
+// API and never creates a queue that does not exist.
+type DepthCollector struct {
+	conn     *amqp091.Connection
+	queues   []string
+	interval time.Duration
+	logger   *log.Logger
+
+	mu     sync.RWMutex
+	depths map[string]Depth
+}
+
+// NewDepthCollector creates a collector of the given queues' depth and
+// registers its metrics
+func NewDepthCollector(conn *amqp091.Connection, queues []string, interval time.Duration, logger *log.Logger) *DepthCollector {
+	c := &DepthCollector{
+		conn:     conn,
+		queues:   queues,
+		interval: interval,
+		logger:   logger,
+		depths:   make(map[string]Depth),
+	}
+	registerDepthMetrics(c)
+	return c
+}
+
+// Run polls the queues every interval until ctx is done
+func (c *DepthCollector) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		c.Poll()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll reads the depth of every queue. A queue that cannot be read stops
+// being reported until it can again, rather than reporting a stale depth.
+func (c *DepthCollector) Poll() {
+	var channel *amqp091.Channel
+	defer func() {
+		if channel != nil {
+			channel.Close()
+		}
+	}()
+
+	for _, name := range c.queues {
+		if channel == nil || channel.IsClosed() {
+			var err error
+			if channel, err = c.conn.Channel(); err != nil {
+				c.logger.Printf("Queue depth not polled: %v", err)
+				c.forget(c.queues...)
+				return
+			}
+		}
+
+		// A failed passive declare closes the channel; the next queue
+		// opens another
+		q, err := channel.QueueDeclarePassive(name, true, false, false, false, nil)
+		if err != nil {
+			c.logger.Printf("Queue depth not polled - queue: %s, error: %v", name, err)
+			c.forget(name)
+			continue
+		}
+
+		c.mu.Lock()
+		c.depths[name] = Depth{
+			Queue:     name,
+			Messages:  q.Messages,
+			Consumers: q.Consumers,
+			PolledAt:  time.Now().UTC(),
+		}
+		c.mu.Unlock()
+	}
+}
+
+// Depths returns the last polled depth of every queue ordered by name
+func (c *DepthCollector) Depths() []Depth {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	depths := make([]Depth, 0, len(c.depths))
+	for _, depth := range c.depths {
+		depths = append(depths, depth)
+	}
+	sort.Slice(depths, func(i, j int) bool { return depths[i].Queue < depths[j].Queue })
+	return depths
+}
+
+// forget drops the depth of queues that could not be polled
+func (c *DepthCollector) forget(queues ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, name := range queues {
+		delete(c.depths, name)
+	}
+}
+
+// registerDepthMetrics reports the polled backlog and consumers per queue;
+// failures leave metrics disabled
+func registerDepthMetrics(collector *DepthCollector) {
+	meter := otel.Meter("medisupply/queue")
+
+	messages, err := meter.Int64ObservableGauge(
+		"rabbitmq_queue_messages",
+		metric.WithDescription("Messages ready for delivery per queue"),
+	)
+	if err != nil {
+		return
+	}
+	consumers, err := meter.Int64ObservableGauge(
+		"rabbitmq_queue_consumers",
+		metric.WithDescription("Consumers per queue"),
+	)
+	if err != nil {
+		return
+	}
+	_, _ = meter.RegisterCallback(func(ctx context.Context, o metric.Observer) error {
+		for _, d := range collector.Depths() {
+			attributes := metric.WithAttributes(attribute.String("queue", d.Queue))
+			o.ObserveInt64(messages, int64(d.Messages), attributes)
+			o.ObserveInt64(consumers, int64(d.Consumers), attributes)
+		}
+		return nil
+	}, messages, consumers)
+}
@@ -0,0 +1,70 @@
+package queue
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestDepthCollectorReportsThePolledQueues(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	otel.SetMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+
+	collector := NewDepthCollector(nil, []string{"stock-bajo-queue", "stock-bajo-queue.dlq"}, time.Minute, nil)
+	polledAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	collector.depths["stock-bajo-queue.dlq"] = Depth{Queue: "stock-bajo-queue.dlq", Messages: 3, PolledAt: polledAt}
+	collector.depths["stock-bajo-queue"] = Depth{Queue: "stock-bajo-queue", Messages: 120, Consumers: 2, PolledAt: polledAt}
+
+	want := []Depth{
+		{Queue: "stock-bajo-queue", Messages: 120, Consumers: 2, PolledAt: polledAt},
+		{Queue: "stock-bajo-queue.dlq", Messages: 3, PolledAt: polledAt},
+	}
+	if depths := collector.Depths(); !reflect.DeepEqual(depths, want) {
+		t.Fatalf("depths %+v, want %+v", depths, want)
+	}
+	if got := gauges(t, reader); !reflect.DeepEqual(got, map[string]int64{
+		"rabbitmq_queue_messages/stock-bajo-queue":      120,
+		"rabbitmq_queue_consumers/stock-bajo-queue":     2,
+		"rabbitmq_queue_messages/stock-bajo-queue.dlq":  3,
+		"rabbitmq_queue_consumers/stock-bajo-queue.dlq": 0,
+	}) {
+		t.Fatalf("gauges %v", got)
+	}
+
+	// A queue that cannot be polled stops being reported
+	collector.forget("stock-bajo-queue.dlq")
+	if depths := collector.Depths(); len(depths) != 1 || depths[0].Queue != "stock-bajo-queue" {
+		t.Fatalf("depths %+v after forgetting the DLQ", depths)
+	}
+	if got := gauges(t, reader); len(got) != 2 {
+		t.Fatalf("gauges %v after forgetting the DLQ", got)
+	}
+}
+
+// gauges collects the queue gauges by name and queue
+func gauges(t *testing.T, reader *sdkmetric.ManualReader) map[string]int64 {
+	t.Helper()
+	var data metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &data); err != nil {
+		t.Fatalf("collect: %v", err)
+	}
+	values := make(map[string]int64)
+	for _, scope := range data.ScopeMetrics {
+		for _, m := range scope.Metrics {
+			gauge, ok := m.Data.(metricdata.Gauge[int64])
+			if !ok {
+				continue
+			}
+			for _, point := range gauge.DataPoints {
+				queue, _ := point.Attributes.Value("queue")
+				values[m.Name+"/"+queue.AsString()] = point.Value
+			}
+		}
+	}
+	return values
+}
@@ -0,0 +1,123 @@
+// Package queue builds the declaration arguments of RabbitMQ queues, so
+// their type and limits are configured per queue instead of hardcoded
+package queue
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rabbitmq/amqp091-go"
+)
+
+// ErrInvalidConfig is returned for queue settings the queue type does not support
+var ErrInvalidConfig = errors.New("invalid queue configuration")
+
+// Type is a RabbitMQ queue type
+type Type string
+
+const (
+	Classic Type = "classic"
+	Quorum  Type = "quorum"
+	Stream  Type = "stream"
+)
+
+// Overflow behaviours once a queue reaches its max length
+const (
+	OverflowDropHead         = "drop-head"
+	OverflowRejectPublish    = "reject-publish"
+	OverflowRejectPublishDLX = "reject-publish-dlx"
+)
+
+// Config represents the declaration settings of a queue. Zero values keep
+// the broker defaults, so a zero Config declares a classic queue without
+// arguments. RabbitMQ refuses to redeclare an existing queue with different
+// settings; the queue must be deleted, after draining it, to change them.
+type Config struct {
+	Type       Type
+	MessageTTL time.Duration
+	MaxLength  int
+	Overflow   string
+	// MaxPriority declares a priority queue, which only classic queues support
+	MaxPriority int
+	// SingleActiveConsumer delivers to one consumer at a time, whichever
+	// registered first; the broker moves to the next as soon as it goes
+	// away, so replicas stand by without processing messages twice or out
+	// of order
+	SingleActiveConsumer bool
+}
+
+// Validate checks the settings are supported by the queue type
+func (c Config) Validate() error {
+	switch c.Type {
+	case "", Classic, Quorum, Stream:
+	default:
+		return fmt.Errorf("%w: unknown queue type %q, expected %s, %s or %s", ErrInvalidConfig, c.Type, Classic, Quorum, Stream)
+	}
+
+	switch c.Overflow {
+	case "", OverflowDropHead, OverflowRejectPublish, OverflowRejectPublishDLX:
+	default:
+		return fmt.Errorf("%w: unknown overflow %q", ErrInvalidConfig, c.Overflow)
+	}
+
+	if c.MessageTTL < 0 || c.MaxLength < 0 {
+		return fmt.Errorf("%w: message TTL and max length must not be negative", ErrInvalidConfig)
+	}
+	if c.MessageTTL%time.Millisecond != 0 {
+		return fmt.Errorf("%w: message TTL must be whole milliseconds", ErrInvalidConfig)
+	}
+	if c.MaxPriority > 0 && c.Type != "" && c.Type != Classic {
+		return fmt.Errorf("%w: %s queues do not support priorities", ErrInvalidConfig, c.Type)
+	}
+	if c.Type == Stream && (c.MessageTTL > 0 || c.MaxLength > 0 || c.Overflow != "") {
+		return fmt.Errorf("%w: stream queues do not support message TTL, max length or overflow", ErrInvalidConfig)
+	}
+	if c.Type == Stream && c.SingleActiveConsumer {
+		return fmt.Errorf("%w: stream queues do not support single active consumer over AMQP", ErrInvalidConfig)
+	}
+	if c.Type == Quorum && c.Overflow == OverflowRejectPublishDLX {
+		return fmt.Errorf("%w: quorum queues do not support overflow %s", ErrInvalidConfig, OverflowRejectPublishDLX)
+	}
+	return nil
+}
+
+// Arguments returns the x-arguments declaring the queue, or nil when every
+// setting is the broker default
+func (c Config) Arguments() amqp091.Table {
+	arguments := amqp091.Table{}
+	if c.Type != "" && c.Type != Classic {
+		arguments["x-queue-type"] = string(c.Type)
+	}
+	if c.MessageTTL > 0 {
+		arguments["x-message-ttl"] = c.MessageTTL.Milliseconds()
+	}
+	if c.MaxLength > 0 {
+		arguments["x-max-length"] = int64(c.MaxLength)
+	}
+	if c.Overflow != "" {
+		arguments["x-overflow"] = c.Overflow
+	}
+	if c.MaxPriority > 0 {
+		arguments["x-max-priority"] = int32(c.MaxPriority)
+	}
+	if c.SingleActiveConsumer {
+		arguments["x-single-active-consumer"] = true
+	}
+
+	if len(arguments) == 0 {
+		return nil
+	}
+	return arguments
+}
+
+// ConsumerTag names a consumer after the replica running it, such as
+// orden-compra@orden-compra-7d4f9/stock-low, so the broker's management UI
+// shows which replica consumes a queue, and with a single active consumer,
+// which one is active. Without an identity the name is the tag.
+func ConsumerTag(identity, name string) string {
+	if identity == "" {
+		return name
+	}
+	return identity + "/" + name
+}
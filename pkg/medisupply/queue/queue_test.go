@@ -0,0 +1,78 @@
+package queue
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rabbitmq/amqp091-go"
+)
+
+func TestConfigValidate(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		config Config
+		valid  bool
+	}{
+		{"broker defaults", Config{}, true},
+		{"classic with limits", Config{Type: Classic, MessageTTL: time.Hour, MaxLength: 1000, Overflow: OverflowRejectPublishDLX, MaxPriority: 10}, true},
+		{"quorum with limits", Config{Type: Quorum, MessageTTL: time.Minute, MaxLength: 10, Overflow: OverflowRejectPublish}, true},
+		{"stream", Config{Type: Stream}, true},
+		{"unknown type", Config{Type: "lazy"}, false},
+		{"unknown overflow", Config{Overflow: "block"}, false},
+		{"negative TTL", Config{MessageTTL: -time.Second}, false},
+		{"negative max length", Config{MaxLength: -1}, false},
+		{"TTL below a millisecond", Config{MessageTTL: 1500 * time.Microsecond}, false},
+		{"quorum priorities", Config{Type: Quorum, MaxPriority: 10}, false},
+		{"stream TTL", Config{Type: Stream, MessageTTL: time.Hour}, false},
+		{"stream max length", Config{Type: Stream, MaxLength: 10}, false},
+		{"quorum dead-lettering overflow", Config{Type: Quorum, Overflow: OverflowRejectPublishDLX}, false},
+		{"quorum single active consumer", Config{Type: Quorum, SingleActiveConsumer: true}, true},
+		{"stream single active consumer", Config{Type: Stream, SingleActiveConsumer: true}, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.config.Validate()
+			if tc.valid && err != nil {
+				t.Fatalf("Validate() = %v, want valid", err)
+			}
+			if !tc.valid && !errors.Is(err, ErrInvalidConfig) {
+				t.Fatalf("Validate() = %v, want ErrInvalidConfig", err)
+			}
+		})
+	}
+}
+
+func TestConfigArguments(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		config Config
+		want   amqp091.Table
+	}{
+		{"broker defaults", Config{}, nil},
+		{"classic is the default type", Config{Type: Classic}, nil},
+		{"quorum", Config{Type: Quorum, MessageTTL: 90 * time.Second, MaxLength: 500, Overflow: OverflowRejectPublish}, amqp091.Table{
+			"x-queue-type":  "quorum",
+			"x-message-ttl": int64(90000),
+			"x-max-length":  int64(500),
+			"x-overflow":    "reject-publish",
+		}},
+		{"priorities", Config{MaxPriority: 10}, amqp091.Table{"x-max-priority": int32(10)}},
+		{"single active consumer", Config{SingleActiveConsumer: true}, amqp091.Table{"x-single-active-consumer": true}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.config.Arguments(); !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("Arguments() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestConsumerTag(t *testing.T) {
+	if tag := ConsumerTag("orden-compra@replica-1", "stock-low-1"); tag != "orden-compra@replica-1/stock-low-1" {
+		t.Fatalf("ConsumerTag() = %q", tag)
+	}
+	if tag := ConsumerTag("", "stock-low-1"); tag != "stock-low-1" {
+		t.Fatalf("ConsumerTag() without an identity = %q", tag)
+	}
+}
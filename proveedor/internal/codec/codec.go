@@ -0,0 +1,161 @@
+package codec
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+
+	wire "medisupply/codec"
+	"medisupply/codec/eventsv1"
+	"proveedor/internal/models"
+)
+
+// Supported message content types
+const (
+	ContentTypeJSON     = "application/json"
+	ContentTypeProtobuf = "application/x-protobuf"
+)
+
+// protobufAliases lists other content types commonly used for protobuf payloads
+var protobufAliases = map[string]bool{
+	ContentTypeProtobuf:                 true,
+	"application/protobuf":              true,
+	"application/vnd.google.protobuf":   true,
+	"application/octet-stream+protobuf": true,
+}
+
+// Normalize maps a content type header onto one of the supported content types.
+// An empty content type is treated as JSON for backwards compatibility.
+func Normalize(contentType string) (string, error) {
+	if strings.TrimSpace(contentType) == "" {
+		return ContentTypeJSON, nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", fmt.Errorf("invalid content type %q: %w", contentType, err)
+	}
+
+	switch {
+	case mediaType == ContentTypeJSON:
+		return ContentTypeJSON, nil
+	case protobufAliases[mediaType]:
+		return ContentTypeProtobuf, nil
+	default:
+		return "", fmt.Errorf("unsupported content type %q", contentType)
+	}
+}
+
+// DecodeRecepcionProveedorEvent decodes a RecepcionProveedor payload in the given content type
+func DecodeRecepcionProveedorEvent(contentType string, body []byte) (*models.RecepcionProveedorEvent, error) {
+	normalized, err := Normalize(contentType)
+	if err != nil {
+		return nil, err
+	}
+
+	var event models.RecepcionProveedorEvent
+	if normalized == ContentTypeProtobuf {
+		if err := unmarshalRecepcionProveedorEvent(body, &event); err != nil {
+			return nil, fmt.Errorf("failed to decode protobuf RecepcionProveedor: %w", err)
+		}
+		return &event, nil
+	}
+
+	if err := json.Unmarshal(body, &event); err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+// EncodeInventoryReceivedEvent encodes an InventarioRecibido event in the given content type
+func EncodeInventoryReceivedEvent(contentType string, event *models.InventoryReceivedEvent) ([]byte, string, error) {
+	normalized, err := Normalize(contentType)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if normalized == ContentTypeProtobuf {
+		body, err := marshalInventoryReceivedEvent(event)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to encode protobuf InventarioRecibido: %w", err)
+		}
+		return body, ContentTypeProtobuf, nil
+	}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		return nil, "", err
+	}
+	return body, ContentTypeJSON, nil
+}
+
+// unmarshalRecepcionProveedorEvent decodes the medisupply.events.v1.RecepcionProveedor message.
+// The canonical fields are mirrored into their Spanish aliases so handlers see
+// the same struct regardless of wire format.
+func unmarshalRecepcionProveedorEvent(b []byte, event *models.RecepcionProveedorEvent) error {
+	var message eventsv1.RecepcionProveedor
+	if err := proto.Unmarshal(b, &message); err != nil {
+		return err
+	}
+	metadata, err := wire.DecodeMetadata(message.Metadata)
+	if err != nil {
+		return err
+	}
+
+	*event = models.RecepcionProveedorEvent{
+		ID:               message.Id,
+		Timestamp:        wire.Time(message.Timestamp),
+		Type:             message.Type,
+		EventType:        models.EventType(message.EventType),
+		PurchaseOrderID:  message.PurchaseOrderId,
+		ProductID:        message.ProductId,
+		ProductoID:       message.ProductId,
+		ProductName:      message.ProductName,
+		Quantity:         int(message.Quantity),
+		Cantidad:         int(message.Quantity),
+		SupplierID:       message.SupplierId,
+		ProveedorID:      message.SupplierId,
+		SupplierName:     message.SupplierName,
+		Location:         message.Location,
+		Status:           message.Status,
+		Estado:           message.Status,
+		FechaRecepcion:   wire.Time(message.ReceptionDate),
+		InspectorID:      message.InspectorId,
+		Notes:            message.Notes,
+		BatchNumber:      message.BatchNumber,
+		ExpiryDate:       wire.OptionalTime(message.ExpiryDate),
+		ReceivedQuantity: int(message.ReceivedQuantity),
+		Metadata:         metadata,
+	}
+	return nil
+}
+
+// marshalInventoryReceivedEvent encodes the medisupply.events.v1.InventarioRecibido message
+func marshalInventoryReceivedEvent(event *models.InventoryReceivedEvent) ([]byte, error) {
+	metadata, err := wire.EncodeMetadata(event.Metadata)
+	if err != nil {
+		return nil, err
+	}
+	return proto.Marshal(&eventsv1.InventarioRecibido{
+		Id:              event.ID,
+		Timestamp:       wire.Timestamp(event.Timestamp),
+		EventType:       string(event.EventType),
+		PurchaseOrderId: event.PurchaseOrderID,
+		ProductId:       event.ProductID,
+		ProductName:     event.ProductName,
+		Quantity:        int64(event.Quantity),
+		SupplierId:      event.SupplierID,
+		SupplierName:    event.SupplierName,
+		Location:        event.Location,
+		Status:          event.Status,
+		ReceivedAt:      wire.Timestamp(event.ReceivedAt),
+		QualityCheck:    event.QualityCheck,
+		Temperature:     event.Temperature,
+		BatchNumber:     event.BatchNumber,
+		ExpiryDate:      wire.OptionalTimestamp(event.ExpiryDate),
+		Metadata:        metadata,
+	})
+}
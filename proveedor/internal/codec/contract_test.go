@@ -0,0 +1,109 @@
+package codec
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+
+	"medisupply/codec/eventsv1"
+	"medisupply/fixtures"
+	"proveedor/internal/models"
+)
+
+// TestRecepcionProveedorContract decodes every RecepcionProveedor version,
+// as JSON and as protobuf, which must normalize to the same reception
+// without losing any fixture field
+func TestRecepcionProveedorContract(t *testing.T) {
+	all, err := fixtures.All(fixtures.RecepcionProveedor)
+	if err != nil {
+		t.Fatalf("fixtures: %v", err)
+	}
+
+	latest := all[len(all)-1]
+	event, err := DecodeRecepcionProveedorEvent(ContentTypeJSON, latest.Body)
+	if err != nil {
+		t.Fatalf("%s: failed to decode: %v", latest.Name(), err)
+	}
+	want, err := event.Normalize()
+	if err != nil {
+		t.Fatalf("%s: failed to normalize: %v", latest.Name(), err)
+	}
+
+	check := func(t *testing.T, contentType string, body, fields []byte) {
+		event, err := DecodeRecepcionProveedorEvent(contentType, body)
+		if err != nil {
+			t.Fatalf("failed to decode: %v", err)
+		}
+		encoded, err := json.Marshal(event)
+		if err != nil {
+			t.Fatalf("failed to encode: %v", err)
+		}
+		if err := fixtures.Match(fields, encoded, false); err != nil {
+			t.Fatalf("%v", err)
+		}
+
+		reception, err := event.Normalize()
+		if err != nil {
+			t.Fatalf("failed to normalize: %v", err)
+		}
+		if !reflect.DeepEqual(reception, want) {
+			t.Fatalf("normalizes to %+v, the latest version to %+v", *reception, *want)
+		}
+	}
+
+	for _, fixture := range all {
+		t.Run(fixture.Name()+"/json", func(t *testing.T) {
+			check(t, ContentTypeJSON, fixture.Body, fixture.Body)
+		})
+		t.Run(fixture.Name()+"/protobuf", func(t *testing.T) {
+			protobuf, err := fixture.Protobuf(&eventsv1.RecepcionProveedor{})
+			if err != nil {
+				t.Fatalf("fixtures: %v", err)
+			}
+			check(t, ContentTypeProtobuf, protobuf.Body, protobuf.Fields)
+		})
+	}
+}
+
+// TestInventarioRecibidoContract encodes InventarioRecibido as sent, which
+// must match the latest version exactly, as JSON and as protobuf
+func TestInventarioRecibidoContract(t *testing.T) {
+	fixture, err := fixtures.Latest(fixtures.InventarioRecibido)
+	if err != nil {
+		t.Fatalf("fixtures: %v", err)
+	}
+
+	var event models.InventoryReceivedEvent
+	if err := json.Unmarshal(fixture.Body, &event); err != nil {
+		t.Fatalf("%s: failed to decode: %v", fixture.Name(), err)
+	}
+
+	t.Run(fixture.Name()+"/json", func(t *testing.T) {
+		body, _, err := EncodeInventoryReceivedEvent(ContentTypeJSON, &event)
+		if err != nil {
+			t.Fatalf("failed to encode: %v", err)
+		}
+		if err := fixtures.Match(fixture.Body, body, true); err != nil {
+			t.Fatalf("%v", err)
+		}
+	})
+	t.Run(fixture.Name()+"/protobuf", func(t *testing.T) {
+		want, err := fixture.Protobuf(&eventsv1.InventarioRecibido{})
+		if err != nil {
+			t.Fatalf("fixtures: %v", err)
+		}
+		body, _, err := EncodeInventoryReceivedEvent(ContentTypeProtobuf, &event)
+		if err != nil {
+			t.Fatalf("failed to encode: %v", err)
+		}
+		var got eventsv1.InventarioRecibido
+		if err := proto.Unmarshal(body, &got); err != nil {
+			t.Fatalf("failed to decode the encoded event: %v", err)
+		}
+		if !proto.Equal(&got, want.Message) {
+			t.Fatalf("encoded %v, want %v", &got, want.Message)
+		}
+	})
+}
@@ -0,0 +1,136 @@
+package codec
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"medisupply/codec/eventsv1"
+	"proveedor/internal/models"
+)
+
+var wireTime = time.Date(2024, 3, 1, 12, 0, 0, 500, time.UTC)
+
+func TestDecodeRecepcionProveedorEventFromGeneratedMessage(t *testing.T) {
+	expiry := wireTime.AddDate(1, 0, 0)
+	body, err := proto.Marshal(&eventsv1.RecepcionProveedor{
+		Id:               "event-1",
+		Timestamp:        timestamppb.New(wireTime),
+		EventType:        "RecepcionProveedor",
+		PurchaseOrderId:  "po-1",
+		ProductId:        "product-1",
+		ProductName:      "Gloves",
+		Quantity:         10,
+		SupplierId:       "supplier-1",
+		SupplierName:     "Acme",
+		Location:         "warehouse-1",
+		Status:           "sent",
+		Metadata:         []byte(`{"correlation_id":"correlation-1"}`),
+		Type:             models.ReceptionCreatedType,
+		ReceptionDate:    timestamppb.New(wireTime),
+		InspectorId:      "inspector-1",
+		Notes:            "seals intact",
+		BatchNumber:      "LOT-1",
+		ExpiryDate:       timestamppb.New(expiry),
+		ReceivedQuantity: 8,
+	})
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+
+	event, err := DecodeRecepcionProveedorEvent(ContentTypeProtobuf, body)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	// The canonical fields are mirrored into their Spanish aliases
+	want := &models.RecepcionProveedorEvent{
+		ID:               "event-1",
+		Timestamp:        wireTime,
+		Type:             models.ReceptionCreatedType,
+		EventType:        "RecepcionProveedor",
+		PurchaseOrderID:  "po-1",
+		ProductID:        "product-1",
+		ProductoID:       "product-1",
+		ProductName:      "Gloves",
+		Quantity:         10,
+		Cantidad:         10,
+		SupplierID:       "supplier-1",
+		ProveedorID:      "supplier-1",
+		SupplierName:     "Acme",
+		Location:         "warehouse-1",
+		Status:           "sent",
+		Estado:           "sent",
+		FechaRecepcion:   wireTime,
+		InspectorID:      "inspector-1",
+		Notes:            "seals intact",
+		BatchNumber:      "LOT-1",
+		ExpiryDate:       &expiry,
+		ReceivedQuantity: 8,
+		Metadata:         map[string]interface{}{"correlation_id": "correlation-1"},
+	}
+	if !reflect.DeepEqual(event, want) {
+		t.Fatalf("decoded %+v, want %+v", event, want)
+	}
+}
+
+func TestEncodeInventoryReceivedEventDecodesAsGeneratedMessage(t *testing.T) {
+	temperature := 4.5
+	expiry := wireTime.AddDate(1, 0, 0)
+	event := &models.InventoryReceivedEvent{
+		ID:              "event-1",
+		Timestamp:       wireTime,
+		EventType:       models.InventoryReceivedEventType,
+		PurchaseOrderID: "po-1",
+		ProductID:       "product-1",
+		ProductName:     "Gloves",
+		Quantity:        10,
+		SupplierID:      "supplier-1",
+		SupplierName:    "Acme",
+		Location:        "warehouse-1",
+		Status:          "received",
+		ReceivedAt:      wireTime,
+		QualityCheck:    "passed",
+		Temperature:     &temperature,
+		BatchNumber:     "LOT-1",
+		ExpiryDate:      &expiry,
+		Metadata:        map[string]interface{}{"correlation_id": "correlation-1"},
+	}
+
+	body, contentType, err := EncodeInventoryReceivedEvent(ContentTypeProtobuf, event)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	if contentType != ContentTypeProtobuf {
+		t.Fatalf("content type %q", contentType)
+	}
+
+	var message eventsv1.InventarioRecibido
+	if err := proto.Unmarshal(body, &message); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	want := &eventsv1.InventarioRecibido{
+		Id:              "event-1",
+		Timestamp:       timestamppb.New(wireTime),
+		EventType:       string(models.InventoryReceivedEventType),
+		PurchaseOrderId: "po-1",
+		ProductId:       "product-1",
+		ProductName:     "Gloves",
+		Quantity:        10,
+		SupplierId:      "supplier-1",
+		SupplierName:    "Acme",
+		Location:        "warehouse-1",
+		Status:          "received",
+		ReceivedAt:      timestamppb.New(wireTime),
+		QualityCheck:    "passed",
+		Temperature:     &temperature,
+		BatchNumber:     "LOT-1",
+		ExpiryDate:      timestamppb.New(expiry),
+		Metadata:        []byte(`{"correlation_id":"correlation-1"}`),
+	}
+	if !proto.Equal(&message, want) {
+		t.Fatalf("decoded %v, want %v", &message, want)
+	}
+}
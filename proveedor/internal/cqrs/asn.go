@@ -0,0 +1,239 @@
+package cqrs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"medisupply/clock"
+	"proveedor/internal/models"
+)
+
+var (
+	// ErrAdvanceShipmentNoticeNotFound is returned when a purchase order has no shipment notice
+	ErrAdvanceShipmentNoticeNotFound = errors.New("advance shipment notice not found")
+	// ErrInvalidAdvanceShipmentNotice is returned for notices without an order or delivery date
+	ErrInvalidAdvanceShipmentNotice = errors.New("invalid advance shipment notice")
+)
+
+// AdvanceShipmentNoticeRepository stores the latest shipment notice of each purchase order
+type AdvanceShipmentNoticeRepository interface {
+	Save(ctx context.Context, notice *models.AdvanceShipmentNotice) error
+	GetByPurchaseOrderID(ctx context.Context, purchaseOrderID string) (*models.AdvanceShipmentNotice, error)
+	GetByShipmentID(ctx context.Context, shipmentID string) (*models.AdvanceShipmentNotice, error)
+	ListExpected(ctx context.Context, expectedBefore *time.Time) ([]*models.AdvanceShipmentNotice, error)
+}
+
+// InMemoryAdvanceShipmentNoticeRepository keeps shipment notices in memory
+type InMemoryAdvanceShipmentNoticeRepository struct {
+	mu      sync.RWMutex
+	notices map[string]*models.AdvanceShipmentNotice
+}
+
+// NewInMemoryAdvanceShipmentNoticeRepository creates a new in-memory repository
+func NewInMemoryAdvanceShipmentNoticeRepository() *InMemoryAdvanceShipmentNoticeRepository {
+	return &InMemoryAdvanceShipmentNoticeRepository{
+		notices: make(map[string]*models.AdvanceShipmentNotice),
+	}
+}
+
+// Save stores a notice, replacing any earlier notice of its purchase order
+func (r *InMemoryAdvanceShipmentNoticeRepository) Save(ctx context.Context, notice *models.AdvanceShipmentNotice) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored := *notice
+	r.notices[notice.PurchaseOrderID] = &stored
+	return nil
+}
+
+// GetByPurchaseOrderID returns a copy of the shipment notice of a purchase order
+func (r *InMemoryAdvanceShipmentNoticeRepository) GetByPurchaseOrderID(ctx context.Context, purchaseOrderID string) (*models.AdvanceShipmentNotice, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	notice, ok := r.notices[purchaseOrderID]
+	if !ok {
+		return nil, ErrAdvanceShipmentNoticeNotFound
+	}
+
+	found := *notice
+	return &found, nil
+}
+
+// GetByShipmentID returns a copy of the shipment notice whose ASN ID or
+// tracking number is shipmentID. Sensors travelling with a shipment report
+// either, depending on who fitted them.
+func (r *InMemoryAdvanceShipmentNoticeRepository) GetByShipmentID(ctx context.Context, shipmentID string) (*models.AdvanceShipmentNotice, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, notice := range r.notices {
+		if notice.ID == shipmentID || (notice.TrackingNumber != "" && notice.TrackingNumber == shipmentID) {
+			found := *notice
+			return &found, nil
+		}
+	}
+	return nil, ErrAdvanceShipmentNoticeNotFound
+}
+
+// ListExpected returns the notices of shipments not received yet, optionally
+// only those due before expectedBefore, soonest delivery first
+func (r *InMemoryAdvanceShipmentNoticeRepository) ListExpected(ctx context.Context, expectedBefore *time.Time) ([]*models.AdvanceShipmentNotice, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	notices := make([]*models.AdvanceShipmentNotice, 0, len(r.notices))
+	for _, notice := range r.notices {
+		if !notice.Expected() {
+			continue
+		}
+		if expectedBefore != nil && !notice.ExpectedDeliveryDate.Before(*expectedBefore) {
+			continue
+		}
+		found := *notice
+		notices = append(notices, &found)
+	}
+
+	sort.Slice(notices, func(i, j int) bool {
+		if notices[i].ExpectedDeliveryDate.Equal(notices[j].ExpectedDeliveryDate) {
+			return notices[i].PurchaseOrderID < notices[j].PurchaseOrderID
+		}
+		return notices[i].ExpectedDeliveryDate.Before(notices[j].ExpectedDeliveryDate)
+	})
+	return notices, nil
+}
+
+// RecordAdvanceShipmentNoticeHandler stores the shipment notices forwarded by OrdenCompra
+type RecordAdvanceShipmentNoticeHandler struct {
+	repository AdvanceShipmentNoticeRepository
+	Clock      clock.Clock
+}
+
+// NewRecordAdvanceShipmentNoticeHandler creates a new handler
+func NewRecordAdvanceShipmentNoticeHandler(repository AdvanceShipmentNoticeRepository) *RecordAdvanceShipmentNoticeHandler {
+	return &RecordAdvanceShipmentNoticeHandler{repository: repository, Clock: clock.System}
+}
+
+// Handle records the notice of an ASN event
+func (h *RecordAdvanceShipmentNoticeHandler) Handle(ctx context.Context, event *models.AdvanceShipmentNoticeEvent) (*models.AdvanceShipmentNotice, error) {
+	if event.PurchaseOrderID == "" {
+		return nil, fmt.Errorf("%w: purchase_order_id is required", ErrInvalidAdvanceShipmentNotice)
+	}
+	if event.ExpectedDeliveryDate.IsZero() {
+		return nil, fmt.Errorf("%w: expected_delivery_date is required", ErrInvalidAdvanceShipmentNotice)
+	}
+
+	notice := &models.AdvanceShipmentNotice{
+		ID:                   event.ASNID,
+		PurchaseOrderID:      event.PurchaseOrderID,
+		ProductID:            event.ProductID,
+		SupplierID:           event.SupplierID,
+		Quantity:             event.Quantity,
+		ExpectedDeliveryDate: event.ExpectedDeliveryDate,
+		Carrier:              event.Carrier,
+		TrackingNumber:       event.TrackingNumber,
+		Lots:                 event.Lots,
+		ReceivedAt:           h.Clock.Now().UTC(),
+	}
+	if notice.ID == "" {
+		notice.ID = event.ID
+	}
+
+	if err := h.repository.Save(ctx, notice); err != nil {
+		return nil, err
+	}
+
+	return notice, nil
+}
+
+// ApplyAdvanceShipmentNoticeHandler pre-populates new receptions from the
+// shipment notice of their purchase order
+type ApplyAdvanceShipmentNoticeHandler struct {
+	notices     AdvanceShipmentNoticeRepository
+	recepciones RecepcionProveedorRepository
+	Clock       clock.Clock
+}
+
+// NewApplyAdvanceShipmentNoticeHandler creates a new handler
+func NewApplyAdvanceShipmentNoticeHandler(notices AdvanceShipmentNoticeRepository, recepciones RecepcionProveedorRepository) *ApplyAdvanceShipmentNoticeHandler {
+	return &ApplyAdvanceShipmentNoticeHandler{notices: notices, recepciones: recepciones, Clock: clock.System}
+}
+
+// Handle copies the carrier and tracking number of the expected shipment onto
+// the reception and marks the shipment received. It returns nil when the
+// purchase order has no shipment notice waiting.
+func (h *ApplyAdvanceShipmentNoticeHandler) Handle(ctx context.Context, recepcion *models.RecepcionProveedor) (*models.AdvanceShipmentNotice, error) {
+	if recepcion.PurchaseOrderID == "" {
+		return nil, nil
+	}
+
+	notice, err := h.notices.GetByPurchaseOrderID(ctx, recepcion.PurchaseOrderID)
+	if errors.Is(err, ErrAdvanceShipmentNoticeNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !notice.Expected() {
+		return nil, nil
+	}
+
+	recepcion.ASNID = notice.ID
+	recepcion.Carrier = notice.Carrier
+	recepcion.TrackingNumber = notice.TrackingNumber
+	recepcion.UpdatedAt = h.Clock.Now()
+	if err := h.recepciones.Update(ctx, recepcion); err != nil {
+		return nil, err
+	}
+
+	notice.RecepcionID = recepcion.ID
+	if err := h.notices.Save(ctx, notice); err != nil {
+		return nil, err
+	}
+
+	return notice, nil
+}
+
+// GetAdvanceShipmentNoticeQuery represents a query for the shipment notice of a purchase order
+type GetAdvanceShipmentNoticeQuery struct {
+	PurchaseOrderID string `json:"purchase_order_id"`
+}
+
+// GetAdvanceShipmentNoticeHandler handles the get shipment notice query
+type GetAdvanceShipmentNoticeHandler struct {
+	repository AdvanceShipmentNoticeRepository
+}
+
+// NewGetAdvanceShipmentNoticeHandler creates a new handler
+func NewGetAdvanceShipmentNoticeHandler(repository AdvanceShipmentNoticeRepository) *GetAdvanceShipmentNoticeHandler {
+	return &GetAdvanceShipmentNoticeHandler{repository: repository}
+}
+
+// Handle processes the get shipment notice query
+func (h *GetAdvanceShipmentNoticeHandler) Handle(ctx context.Context, query GetAdvanceShipmentNoticeQuery) (*models.AdvanceShipmentNotice, error) {
+	return h.repository.GetByPurchaseOrderID(ctx, query.PurchaseOrderID)
+}
+
+// ListExpectedShipmentsQuery represents a query for the shipments not received yet
+type ListExpectedShipmentsQuery struct {
+	ExpectedBefore *time.Time `json:"expected_before,omitempty"`
+}
+
+// ListExpectedShipmentsHandler handles the list expected shipments query
+type ListExpectedShipmentsHandler struct {
+	repository AdvanceShipmentNoticeRepository
+}
+
+// NewListExpectedShipmentsHandler creates a new handler
+func NewListExpectedShipmentsHandler(repository AdvanceShipmentNoticeRepository) *ListExpectedShipmentsHandler {
+	return &ListExpectedShipmentsHandler{repository: repository}
+}
+
+// Handle processes the list expected shipments query
+func (h *ListExpectedShipmentsHandler) Handle(ctx context.Context, query ListExpectedShipmentsQuery) ([]*models.AdvanceShipmentNotice, error) {
+	return h.repository.ListExpected(ctx, query.ExpectedBefore)
+}
@@ -0,0 +1,107 @@
+package cqrs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"medisupply/clock"
+	"proveedor/internal/models"
+)
+
+func TestRecordAdvanceShipmentNotice(t *testing.T) {
+	ctx := context.Background()
+	repository := NewInMemoryAdvanceShipmentNoticeRepository()
+	h := NewRecordAdvanceShipmentNoticeHandler(repository)
+	h.Clock = clock.NewFake(cancelledAt)
+	expected := cancelledAt.Add(24 * time.Hour)
+
+	notice, err := h.Handle(ctx, &models.AdvanceShipmentNoticeEvent{ID: "event-1", ASNID: "asn-1", PurchaseOrderID: "po-1", ExpectedDeliveryDate: expected, Carrier: "DHL"})
+	if err != nil || notice.ID != "asn-1" || !notice.ReceivedAt.Equal(cancelledAt) || !notice.Expected() {
+		t.Fatalf("notice %+v, error %v", notice, err)
+	}
+
+	// A notice without an ASN ID is known by its event, and replaces the earlier one
+	if _, err := h.Handle(ctx, &models.AdvanceShipmentNoticeEvent{ID: "event-2", PurchaseOrderID: "po-1", ExpectedDeliveryDate: expected, Carrier: "UPS"}); err != nil {
+		t.Fatalf("record second notice: %v", err)
+	}
+	if notice, err := repository.GetByPurchaseOrderID(ctx, "po-1"); err != nil || notice.ID != "event-2" || notice.Carrier != "UPS" {
+		t.Fatalf("notice %+v, error %v", notice, err)
+	}
+
+	for _, event := range []*models.AdvanceShipmentNoticeEvent{
+		{ID: "event-3", ExpectedDeliveryDate: expected},
+		{ID: "event-4", PurchaseOrderID: "po-2"},
+	} {
+		if _, err := h.Handle(ctx, event); !errors.Is(err, ErrInvalidAdvanceShipmentNotice) {
+			t.Fatalf("recording %+v returned %v, want ErrInvalidAdvanceShipmentNotice", event, err)
+		}
+	}
+}
+
+func TestAdvanceShipmentNoticeLookups(t *testing.T) {
+	ctx := context.Background()
+	repository := NewInMemoryAdvanceShipmentNoticeRepository()
+	for _, notice := range []*models.AdvanceShipmentNotice{
+		{ID: "asn-1", PurchaseOrderID: "po-1", TrackingNumber: "TRACK-1", ExpectedDeliveryDate: cancelledAt.Add(48 * time.Hour)},
+		{ID: "asn-2", PurchaseOrderID: "po-2", ExpectedDeliveryDate: cancelledAt.Add(24 * time.Hour)},
+		{ID: "asn-3", PurchaseOrderID: "po-3", ExpectedDeliveryDate: cancelledAt, RecepcionID: "recepcion-3"},
+	} {
+		if err := repository.Save(ctx, notice); err != nil {
+			t.Fatalf("save notice: %v", err)
+		}
+	}
+
+	for _, shipmentID := range []string{"asn-1", "TRACK-1"} {
+		if notice, err := repository.GetByShipmentID(ctx, shipmentID); err != nil || notice.PurchaseOrderID != "po-1" {
+			t.Fatalf("shipment %s is %+v, error %v", shipmentID, notice, err)
+		}
+	}
+	if _, err := repository.GetByShipmentID(ctx, "missing"); !errors.Is(err, ErrAdvanceShipmentNoticeNotFound) {
+		t.Fatalf("unknown shipment returned %v", err)
+	}
+
+	// Received shipments are no longer expected; the soonest comes first
+	expected, err := NewListExpectedShipmentsHandler(repository).Handle(ctx, ListExpectedShipmentsQuery{})
+	if err != nil || len(expected) != 2 || expected[0].ID != "asn-2" || expected[1].ID != "asn-1" {
+		t.Fatalf("expected shipments %v, error %v", expected, err)
+	}
+	before := cancelledAt.Add(36 * time.Hour)
+	if expected, err := NewListExpectedShipmentsHandler(repository).Handle(ctx, ListExpectedShipmentsQuery{ExpectedBefore: &before}); err != nil || len(expected) != 1 || expected[0].ID != "asn-2" {
+		t.Fatalf("shipments expected before %s: %v, error %v", before, expected, err)
+	}
+}
+
+func TestApplyAdvanceShipmentNoticeOnlyOnce(t *testing.T) {
+	ctx := context.Background()
+	notices := NewInMemoryAdvanceShipmentNoticeRepository()
+	recepciones := NewInMemoryRecepcionProveedorRepository()
+	h := NewApplyAdvanceShipmentNoticeHandler(notices, recepciones)
+	h.Clock = clock.NewFake(cancelledAt)
+
+	saveRecepcion(t, recepciones, "recepcion-1", models.EstadoPendingQuality)
+	recepcion, _ := recepciones.GetByID(ctx, "recepcion-1")
+	if notice, err := h.Handle(ctx, recepcion); notice != nil || err != nil {
+		t.Fatalf("applied %+v (%v) without a notice", notice, err)
+	}
+
+	if err := notices.Save(ctx, &models.AdvanceShipmentNotice{ID: "asn-1", PurchaseOrderID: "po-1", Carrier: "DHL", TrackingNumber: "TRACK-1"}); err != nil {
+		t.Fatalf("save notice: %v", err)
+	}
+	notice, err := h.Handle(ctx, recepcion)
+	if err != nil || notice == nil || notice.RecepcionID != "recepcion-1" {
+		t.Fatalf("applied %+v, error %v", notice, err)
+	}
+	stored, _ := recepciones.GetByID(ctx, "recepcion-1")
+	if stored.ASNID != "asn-1" || stored.Carrier != "DHL" || stored.TrackingNumber != "TRACK-1" || !stored.UpdatedAt.Equal(cancelledAt) {
+		t.Fatalf("recepcion %+v", stored)
+	}
+
+	// A second delivery of the order finds the shipment already received
+	saveRecepcion(t, recepciones, "recepcion-2", models.EstadoPendingQuality)
+	second, _ := recepciones.GetByID(ctx, "recepcion-2")
+	if notice, err := h.Handle(ctx, second); notice != nil || err != nil {
+		t.Fatalf("applied %+v (%v) to a second delivery", notice, err)
+	}
+}
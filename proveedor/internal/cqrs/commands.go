@@ -2,50 +2,92 @@ package cqrs
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"time"
 
+	"medisupply/clock"
 	"proveedor/internal/models"
 
 	"github.com/google/uuid"
 )
 
+// ErrOrderedQuantityMismatch is returned for a delivery stating an ordered
+// quantity other than the one its purchase order's first reception recorded
+var ErrOrderedQuantityMismatch = errors.New("ordered quantity differs from the purchase order's")
+
 // CreateRecepcionProveedorCommand represents a command to create a new recepcion proveedor
 type CreateRecepcionProveedorCommand struct {
-	ProveedorID    string    `json:"proveedor_id"`
-	ProductoID     string    `json:"producto_id"`
-	Cantidad       int       `json:"cantidad"`
-	FechaRecepcion time.Time `json:"fecha_recepcion"`
-	Estado         string    `json:"estado"`
+	PurchaseOrderID string `json:"purchase_order_id"`
+	ProveedorID     string `json:"proveedor_id"`
+	ProductoID      string `json:"producto_id"`
+	// Cantidad is the quantity ordered
+	Cantidad int `json:"cantidad"`
+	// CantidadRecibida is the quantity delivered; zero means the delivery
+	// brings everything still outstanding
+	CantidadRecibida int       `json:"cantidad_recibida"`
+	FechaRecepcion   time.Time `json:"fecha_recepcion"`
+	Estado           string    `json:"estado"`
 }
 
 // CreateRecepcionProveedorHandler handles the creation of recepcion proveedor
 type CreateRecepcionProveedorHandler struct {
-	// Add repository interface here when implementing
+	repository RecepcionProveedorRepository
+	Clock      clock.Clock
 }
 
 // NewCreateRecepcionProveedorHandler creates a new handler
-func NewCreateRecepcionProveedorHandler() *CreateRecepcionProveedorHandler {
-	return &CreateRecepcionProveedorHandler{}
+func NewCreateRecepcionProveedorHandler(repository RecepcionProveedorRepository) *CreateRecepcionProveedorHandler {
+	return &CreateRecepcionProveedorHandler{repository: repository, Clock: clock.System}
 }
 
-// Handle processes the create recepcion proveedor command
+// Handle processes the create recepcion proveedor command. New receptions
+// wait for quality inspection whatever their incoming estado. Each reception
+// is one delivery, counted against the quantity ordered by the purchase
+// order's first reception; later deliveries stating a different ordered
+// quantity are rejected.
 func (h *CreateRecepcionProveedorHandler) Handle(ctx context.Context, cmd CreateRecepcionProveedorCommand) (*models.RecepcionProveedor, error) {
+	ordered, received := cmd.Cantidad, 0
+	if cmd.PurchaseOrderID != "" {
+		previous, err := h.repository.ListByPurchaseOrderID(ctx, cmd.PurchaseOrderID)
+		if err != nil {
+			return nil, err
+		}
+		if len(previous) > 0 {
+			ordered = previous[0].CantidadOrdenada
+			if cmd.Cantidad > 0 && cmd.Cantidad != ordered {
+				return nil, fmt.Errorf("%w: purchase order %s ordered %d, delivery states %d", ErrOrderedQuantityMismatch, cmd.PurchaseOrderID, ordered, cmd.Cantidad)
+			}
+		}
+		for _, recepcion := range previous {
+			received += recepcion.Cantidad
+		}
+	}
+
+	delivered := cmd.CantidadRecibida
+	if delivered <= 0 {
+		delivered = max(ordered-received, 0)
+	}
+	outstanding := ordered - received - delivered
+
 	recepcion := &models.RecepcionProveedor{
-		ID:             uuid.New().String(),
-		ProveedorID:    cmd.ProveedorID,
-		ProductoID:     cmd.ProductoID,
-		Cantidad:       cmd.Cantidad,
-		FechaRecepcion: cmd.FechaRecepcion,
-		Estado:         cmd.Estado,
-		CreatedAt:      time.Now(),
-		UpdatedAt:      time.Now(),
+		ID:                uuid.New().String(),
+		PurchaseOrderID:   cmd.PurchaseOrderID,
+		ProveedorID:       cmd.ProveedorID,
+		ProductoID:        cmd.ProductoID,
+		CantidadOrdenada:  ordered,
+		Cantidad:          delivered,
+		CantidadPendiente: max(outstanding, 0),
+		CantidadExcedente: max(-outstanding, 0),
+		FechaRecepcion:    cmd.FechaRecepcion,
+		Estado:            models.EstadoPendingQuality,
+		CreatedAt:         h.Clock.Now(),
+		UpdatedAt:         h.Clock.Now(),
 	}
 
-	// TODO: Save to repository
-	// err := h.repository.Save(ctx, recepcion)
-	// if err != nil {
-	//     return nil, err
-	// }
+	if err := h.repository.Save(ctx, recepcion); err != nil {
+		return nil, err
+	}
 
 	return recepcion, nil
 }
@@ -58,26 +100,106 @@ type UpdateRecepcionProveedorCommand struct {
 
 // UpdateRecepcionProveedorHandler handles the update of recepcion proveedor
 type UpdateRecepcionProveedorHandler struct {
-	// Add repository interface here when implementing
+	repository RecepcionProveedorRepository
+	Clock      clock.Clock
 }
 
 // NewUpdateRecepcionProveedorHandler creates a new handler
-func NewUpdateRecepcionProveedorHandler() *UpdateRecepcionProveedorHandler {
-	return &UpdateRecepcionProveedorHandler{}
+func NewUpdateRecepcionProveedorHandler(repository RecepcionProveedorRepository) *UpdateRecepcionProveedorHandler {
+	return &UpdateRecepcionProveedorHandler{repository: repository, Clock: clock.System}
 }
 
 // Handle processes the update recepcion proveedor command
 func (h *UpdateRecepcionProveedorHandler) Handle(ctx context.Context, cmd UpdateRecepcionProveedorCommand) error {
-	// TODO: Update in repository
-	// recepcion, err := h.repository.GetByID(ctx, cmd.ID)
-	// if err != nil {
-	//     return err
-	// }
-	//
-	// recepcion.Estado = cmd.Estado
-	// recepcion.UpdatedAt = time.Now()
-	//
-	// return h.repository.Update(ctx, recepcion)
-
-	return nil
+	recepcion, err := h.repository.GetByID(ctx, cmd.ID)
+	if err != nil {
+		return err
+	}
+
+	recepcion.Estado = cmd.Estado
+	recepcion.UpdatedAt = h.Clock.Now()
+
+	return h.repository.Update(ctx, recepcion)
+}
+
+// CancelRecepcionProveedorCommand represents a command to stop the reception of a cancelled purchase order
+type CancelRecepcionProveedorCommand struct {
+	PurchaseOrderID string `json:"purchase_order_id"`
+	Reason          string `json:"reason"`
+}
+
+// CancelRecepcionProveedorHandler handles purchase orders cancelled by OrdenCompra
+type CancelRecepcionProveedorHandler struct {
+	repository RecepcionProveedorRepository
+	Clock      clock.Clock
+}
+
+// NewCancelRecepcionProveedorHandler creates a new handler
+func NewCancelRecepcionProveedorHandler(repository RecepcionProveedorRepository) *CancelRecepcionProveedorHandler {
+	return &CancelRecepcionProveedorHandler{repository: repository, Clock: clock.System}
+}
+
+// Handle records the cancellation so later receptions for the order are
+// stopped, and cancels the order's receptions awaiting or in quarantine,
+// returning them. Receptions already received or rejected keep their state
+// and are reported as an error wrapping ErrRecepcionProveedorConflict, after
+// the others are cancelled.
+func (h *CancelRecepcionProveedorHandler) Handle(ctx context.Context, cmd CancelRecepcionProveedorCommand) ([]*models.RecepcionProveedor, error) {
+	if cmd.PurchaseOrderID == "" {
+		return nil, fmt.Errorf("purchase_order_id is required")
+	}
+
+	err := h.repository.SaveCancellation(ctx, &models.PurchaseOrderCancellation{
+		PurchaseOrderID: cmd.PurchaseOrderID,
+		Reason:          cmd.Reason,
+		CancelledAt:     h.Clock.Now(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	recepciones, err := h.repository.ListByPurchaseOrderID(ctx, cmd.PurchaseOrderID)
+	if err != nil {
+		return nil, err
+	}
+
+	var cancelled []*models.RecepcionProveedor
+	var conflicts []error
+	for _, recepcion := range recepciones {
+		if recepcion.Estado == models.EstadoCancelled {
+			continue
+		}
+		if models.IsFinalEstado(recepcion.Estado) {
+			conflicts = append(conflicts, fmt.Errorf("%w: recepcion %s is %s", ErrRecepcionProveedorConflict, recepcion.ID, recepcion.Estado))
+			continue
+		}
+
+		recepcion.Estado = models.EstadoCancelled
+		recepcion.CancellationReason = cmd.Reason
+		recepcion.UpdatedAt = h.Clock.Now()
+		// An inspection recorded meanwhile wins; the reception is then final
+		err := h.repository.UpdateIfEstado(ctx, recepcion, models.EstadoPendingQuality, models.EstadoQuarantined)
+		if errors.Is(err, ErrRecepcionProveedorConflict) {
+			conflicts = append(conflicts, err)
+			continue
+		}
+		if err != nil {
+			return cancelled, err
+		}
+		cancelled = append(cancelled, recepcion)
+	}
+
+	return cancelled, errors.Join(conflicts...)
+}
+
+// IsCancelled reports whether the purchase order was cancelled and why
+func (h *CancelRecepcionProveedorHandler) IsCancelled(ctx context.Context, purchaseOrderID string) (string, bool, error) {
+	cancellation, err := h.repository.GetCancellation(ctx, purchaseOrderID)
+	if errors.Is(err, ErrCancellationNotFound) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return cancellation.Reason, true, nil
 }
@@ -0,0 +1,106 @@
+package cqrs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"medisupply/clock"
+	"proveedor/internal/models"
+)
+
+var cancelledAt = time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+
+func saveRecepcion(t *testing.T, repository RecepcionProveedorRepository, id, estado string) {
+	t.Helper()
+	err := repository.Save(context.Background(), &models.RecepcionProveedor{ID: id, PurchaseOrderID: "po-1", Estado: estado, CreatedAt: cancelledAt.Add(-time.Hour)})
+	if err != nil {
+		t.Fatalf("save recepcion: %v", err)
+	}
+}
+
+func newCancelHandler(repository RecepcionProveedorRepository) *CancelRecepcionProveedorHandler {
+	h := NewCancelRecepcionProveedorHandler(repository)
+	h.Clock = clock.NewFake(cancelledAt)
+	return h
+}
+
+func TestCancelMovesOpenReceptionsToCancelled(t *testing.T) {
+	for _, tc := range []struct {
+		estado       string
+		wantEstado   string
+		wantConflict bool
+	}{
+		{models.EstadoPendingQuality, models.EstadoCancelled, false},
+		{models.EstadoQuarantined, models.EstadoCancelled, false},
+		{models.EstadoReceived, models.EstadoReceived, true},
+		{models.EstadoRejected, models.EstadoRejected, true},
+		{models.EstadoCancelled, models.EstadoCancelled, false},
+	} {
+		t.Run(tc.estado, func(t *testing.T) {
+			repository := NewInMemoryRecepcionProveedorRepository()
+			saveRecepcion(t, repository, "recepcion-1", tc.estado)
+
+			cancelled, err := newCancelHandler(repository).Handle(context.Background(), CancelRecepcionProveedorCommand{PurchaseOrderID: "po-1", Reason: "recall"})
+			if conflict := errors.Is(err, ErrRecepcionProveedorConflict); conflict != tc.wantConflict || (err != nil && !conflict) {
+				t.Fatalf("cancel returned %v, want conflict %v", err, tc.wantConflict)
+			}
+
+			recepcion, err := repository.GetByID(context.Background(), "recepcion-1")
+			if err != nil {
+				t.Fatalf("get recepcion: %v", err)
+			}
+			if recepcion.Estado != tc.wantEstado {
+				t.Fatalf("recepcion is %s, want %s", recepcion.Estado, tc.wantEstado)
+			}
+			if changed := tc.estado != tc.wantEstado; changed != (len(cancelled) == 1) {
+				t.Fatalf("returned %d cancelled receptions", len(cancelled))
+			}
+			if len(cancelled) == 1 && (recepcion.CancellationReason != "recall" || !recepcion.UpdatedAt.Equal(cancelledAt)) {
+				t.Fatalf("cancelled recepcion records reason %q at %v", recepcion.CancellationReason, recepcion.UpdatedAt)
+			}
+		})
+	}
+}
+
+func TestCancelIsRecordedBeforeAnyReception(t *testing.T) {
+	repository := NewInMemoryRecepcionProveedorRepository()
+	h := newCancelHandler(repository)
+
+	if _, cancelled, err := h.IsCancelled(context.Background(), "po-1"); err != nil || cancelled {
+		t.Fatalf("purchase order cancelled %v before the cancellation (%v)", cancelled, err)
+	}
+	if _, err := h.Handle(context.Background(), CancelRecepcionProveedorCommand{PurchaseOrderID: "po-1", Reason: "recall"}); err != nil {
+		t.Fatalf("cancel: %v", err)
+	}
+	// A redelivered cancellation keeps the first reason
+	if _, err := h.Handle(context.Background(), CancelRecepcionProveedorCommand{PurchaseOrderID: "po-1", Reason: "duplicate"}); err != nil {
+		t.Fatalf("cancel again: %v", err)
+	}
+
+	reason, cancelled, err := h.IsCancelled(context.Background(), "po-1")
+	if err != nil || !cancelled || reason != "recall" {
+		t.Fatalf("IsCancelled = %q, %v, %v; want recall", reason, cancelled, err)
+	}
+}
+
+func TestUpdateIfEstadoRefusesAChangedReception(t *testing.T) {
+	repository := NewInMemoryRecepcionProveedorRepository()
+	saveRecepcion(t, repository, "recepcion-1", models.EstadoPendingQuality)
+
+	// An inspection read the reception before it was cancelled
+	inspected, _ := repository.GetByID(context.Background(), "recepcion-1")
+	if _, err := newCancelHandler(repository).Handle(context.Background(), CancelRecepcionProveedorCommand{PurchaseOrderID: "po-1"}); err != nil {
+		t.Fatalf("cancel: %v", err)
+	}
+
+	inspected.Estado = models.EstadoReceived
+	err := repository.UpdateIfEstado(context.Background(), inspected, models.EstadoPendingQuality, models.EstadoQuarantined)
+	if !errors.Is(err, ErrRecepcionProveedorConflict) {
+		t.Fatalf("update returned %v, want ErrRecepcionProveedorConflict", err)
+	}
+	if stored, _ := repository.GetByID(context.Background(), "recepcion-1"); stored.Estado != models.EstadoCancelled {
+		t.Fatalf("recepcion is %s, want it left cancelled", stored.Estado)
+	}
+}
@@ -0,0 +1,223 @@
+package cqrs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"medisupply/clock"
+	"proveedor/internal/models"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrDamageReportNotFound is returned when a damage report does not exist
+	ErrDamageReportNotFound = errors.New("damage report not found")
+	// ErrInvalidDamageReport is returned for damage reports without a reporter or with invalid photos
+	ErrInvalidDamageReport = errors.New("invalid damage report")
+)
+
+// DamageReportRepository stores damage reports
+type DamageReportRepository interface {
+	Save(ctx context.Context, report *models.DamageReport) error
+	GetByID(ctx context.Context, id string) (*models.DamageReport, error)
+	List(ctx context.Context, recepcionID, purchaseOrderID string) ([]*models.DamageReport, error)
+}
+
+// InMemoryDamageReportRepository keeps damage reports in memory
+type InMemoryDamageReportRepository struct {
+	mu      sync.RWMutex
+	reports map[string]*models.DamageReport
+}
+
+// NewInMemoryDamageReportRepository creates a new in-memory repository
+func NewInMemoryDamageReportRepository() *InMemoryDamageReportRepository {
+	return &InMemoryDamageReportRepository{
+		reports: make(map[string]*models.DamageReport),
+	}
+}
+
+// Save stores a new damage report
+func (r *InMemoryDamageReportRepository) Save(ctx context.Context, report *models.DamageReport) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored := *report
+	stored.Photos = append([]models.DamagePhoto(nil), report.Photos...)
+	r.reports[report.ID] = &stored
+	return nil
+}
+
+// GetByID returns a copy of the damage report with the given ID
+func (r *InMemoryDamageReportRepository) GetByID(ctx context.Context, id string) (*models.DamageReport, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	report, ok := r.reports[id]
+	if !ok {
+		return nil, ErrDamageReportNotFound
+	}
+
+	found := *report
+	found.Photos = append([]models.DamagePhoto(nil), report.Photos...)
+	return &found, nil
+}
+
+// List returns the damage reports matching the optional filters, newest first
+func (r *InMemoryDamageReportRepository) List(ctx context.Context, recepcionID, purchaseOrderID string) ([]*models.DamageReport, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	reports := make([]*models.DamageReport, 0, len(r.reports))
+	for _, report := range r.reports {
+		if recepcionID != "" && report.RecepcionID != recepcionID {
+			continue
+		}
+		if purchaseOrderID != "" && report.PurchaseOrderID != purchaseOrderID {
+			continue
+		}
+		found := *report
+		found.Photos = append([]models.DamagePhoto(nil), report.Photos...)
+		reports = append(reports, &found)
+	}
+
+	sort.Slice(reports, func(i, j int) bool {
+		return reports[i].CreatedAt.After(reports[j].CreatedAt)
+	})
+	return reports, nil
+}
+
+// ReportDamageCommand represents a command to report damaged goods on a
+// reception. The caller gives each photo its ID and storage key.
+type ReportDamageCommand struct {
+	RecepcionID string               `json:"recepcion_id"`
+	Cantidad    int                  `json:"cantidad"`
+	Notas       string               `json:"notas"`
+	ReportedBy  string               `json:"reported_by"`
+	Photos      []models.DamagePhoto `json:"photos"`
+}
+
+// ReportDamageHandler handles damage reports
+type ReportDamageHandler struct {
+	repository   DamageReportRepository
+	devoluciones *CreateDevolucionProveedorHandler
+	Clock        clock.Clock
+}
+
+// NewReportDamageHandler creates a new handler that opens returns through devoluciones
+func NewReportDamageHandler(repository DamageReportRepository, devoluciones *CreateDevolucionProveedorHandler) *ReportDamageHandler {
+	return &ReportDamageHandler{repository: repository, devoluciones: devoluciones, Clock: clock.System}
+}
+
+// Handle opens a return of the damaged units to the supplier and stores the
+// report with it. The return's rules apply: the reception must have been
+// inspected and the units returned across its returns cannot exceed the
+// units it delivered. It returns the report and the return it opened.
+func (h *ReportDamageHandler) Handle(ctx context.Context, cmd ReportDamageCommand) (*models.DamageReport, *models.DevolucionProveedor, error) {
+	if strings.TrimSpace(cmd.ReportedBy) == "" {
+		return nil, nil, fmt.Errorf("%w: reported_by is required", ErrInvalidDamageReport)
+	}
+	if err := validateDamagePhotos(cmd.Photos); err != nil {
+		return nil, nil, err
+	}
+
+	report := &models.DamageReport{
+		ID:         uuid.New().String(),
+		Cantidad:   cmd.Cantidad,
+		Notas:      cmd.Notas,
+		ReportedBy: cmd.ReportedBy,
+		Photos:     append([]models.DamagePhoto{}, cmd.Photos...),
+		CreatedAt:  h.Clock.Now(),
+	}
+
+	devolucion, err := h.devoluciones.Handle(ctx, CreateDevolucionProveedorCommand{
+		RecepcionID:    cmd.RecepcionID,
+		Cantidad:       cmd.Cantidad,
+		Motivo:         models.MotivoDamaged,
+		Notas:          cmd.Notas,
+		DamageReportID: report.ID,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	report.RecepcionID = devolucion.RecepcionID
+	report.PurchaseOrderID = devolucion.PurchaseOrderID
+	report.ProveedorID = devolucion.ProveedorID
+	report.ProductoID = devolucion.ProductoID
+	report.DevolucionID = devolucion.ID
+
+	if err := h.repository.Save(ctx, report); err != nil {
+		return nil, nil, err
+	}
+
+	return report, devolucion, nil
+}
+
+// validateDamagePhotos checks the photos are images of plain file names
+// within the size and count limits
+func validateDamagePhotos(photos []models.DamagePhoto) error {
+	if len(photos) > models.MaxDamagePhotos {
+		return fmt.Errorf("%w: at most %d photos, got %d", ErrInvalidDamageReport, models.MaxDamagePhotos, len(photos))
+	}
+	for i, photo := range photos {
+		name := strings.TrimSpace(photo.FileName)
+		switch {
+		case name == "" || path.Base(name) != name || name == "." || name == "..":
+			return fmt.Errorf("%w: photos[%d].file_name must be a file name without a path", ErrInvalidDamageReport, i)
+		case !strings.HasPrefix(photo.ContentType, "image/"):
+			return fmt.Errorf("%w: photos[%d].content_type must be an image type", ErrInvalidDamageReport, i)
+		case photo.Size <= 0 || photo.Size > models.MaxDamagePhotoSize:
+			return fmt.Errorf("%w: photos[%d].size must be between 1 and %d bytes", ErrInvalidDamageReport, i, models.MaxDamagePhotoSize)
+		case photo.ID == "" || photo.Key == "":
+			return fmt.Errorf("photos[%d] has no ID or storage key", i)
+		}
+	}
+	return nil
+}
+
+// GetDamageReportByIDQuery represents a query to get a damage report by ID
+type GetDamageReportByIDQuery struct {
+	ID string `json:"id"`
+}
+
+// GetDamageReportByIDHandler handles the get damage report by ID query
+type GetDamageReportByIDHandler struct {
+	repository DamageReportRepository
+}
+
+// NewGetDamageReportByIDHandler creates a new handler
+func NewGetDamageReportByIDHandler(repository DamageReportRepository) *GetDamageReportByIDHandler {
+	return &GetDamageReportByIDHandler{repository: repository}
+}
+
+// Handle processes the get damage report by ID query
+func (h *GetDamageReportByIDHandler) Handle(ctx context.Context, query GetDamageReportByIDQuery) (*models.DamageReport, error) {
+	return h.repository.GetByID(ctx, query.ID)
+}
+
+// ListDamageReportsQuery represents a query to list damage reports
+type ListDamageReportsQuery struct {
+	RecepcionID     string `json:"recepcion_id,omitempty"`
+	PurchaseOrderID string `json:"purchase_order_id,omitempty"`
+}
+
+// ListDamageReportsHandler handles the list damage reports query
+type ListDamageReportsHandler struct {
+	repository DamageReportRepository
+}
+
+// NewListDamageReportsHandler creates a new handler
+func NewListDamageReportsHandler(repository DamageReportRepository) *ListDamageReportsHandler {
+	return &ListDamageReportsHandler{repository: repository}
+}
+
+// Handle processes the list damage reports query
+func (h *ListDamageReportsHandler) Handle(ctx context.Context, query ListDamageReportsQuery) ([]*models.DamageReport, error) {
+	return h.repository.List(ctx, query.RecepcionID, query.PurchaseOrderID)
+}
@@ -0,0 +1,272 @@
+package cqrs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"medisupply/clock"
+	"proveedor/internal/models"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrDevolucionProveedorNotFound is returned when a return does not exist
+	ErrDevolucionProveedorNotFound = errors.New("devolucion proveedor not found")
+	// ErrInvalidDevolucion is returned for returns with an unknown reason or quantity out of range
+	ErrInvalidDevolucion = errors.New("invalid devolucion proveedor")
+	// ErrInvalidDevolucionTransition is returned when a return cannot move to the requested state
+	ErrInvalidDevolucionTransition = errors.New("invalid devolucion proveedor state transition")
+)
+
+// DevolucionProveedorRepository stores returns to suppliers
+type DevolucionProveedorRepository interface {
+	Save(ctx context.Context, devolucion *models.DevolucionProveedor) error
+	GetByID(ctx context.Context, id string) (*models.DevolucionProveedor, error)
+	Update(ctx context.Context, devolucion *models.DevolucionProveedor) error
+	List(ctx context.Context, recepcionID, purchaseOrderID, estado string) ([]*models.DevolucionProveedor, error)
+}
+
+// InMemoryDevolucionProveedorRepository keeps returns in memory
+type InMemoryDevolucionProveedorRepository struct {
+	mu           sync.RWMutex
+	devoluciones map[string]*models.DevolucionProveedor
+}
+
+// NewInMemoryDevolucionProveedorRepository creates a new in-memory repository
+func NewInMemoryDevolucionProveedorRepository() *InMemoryDevolucionProveedorRepository {
+	return &InMemoryDevolucionProveedorRepository{
+		devoluciones: make(map[string]*models.DevolucionProveedor),
+	}
+}
+
+// Save stores a new return
+func (r *InMemoryDevolucionProveedorRepository) Save(ctx context.Context, devolucion *models.DevolucionProveedor) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored := *devolucion
+	r.devoluciones[devolucion.ID] = &stored
+	return nil
+}
+
+// GetByID returns a copy of the return with the given ID
+func (r *InMemoryDevolucionProveedorRepository) GetByID(ctx context.Context, id string) (*models.DevolucionProveedor, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	devolucion, ok := r.devoluciones[id]
+	if !ok {
+		return nil, ErrDevolucionProveedorNotFound
+	}
+
+	found := *devolucion
+	return &found, nil
+}
+
+// Update replaces an existing return
+func (r *InMemoryDevolucionProveedorRepository) Update(ctx context.Context, devolucion *models.DevolucionProveedor) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.devoluciones[devolucion.ID]; !ok {
+		return ErrDevolucionProveedorNotFound
+	}
+
+	stored := *devolucion
+	r.devoluciones[devolucion.ID] = &stored
+	return nil
+}
+
+// List returns the returns matching the optional filters, newest first
+func (r *InMemoryDevolucionProveedorRepository) List(ctx context.Context, recepcionID, purchaseOrderID, estado string) ([]*models.DevolucionProveedor, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	devoluciones := make([]*models.DevolucionProveedor, 0, len(r.devoluciones))
+	for _, devolucion := range r.devoluciones {
+		if recepcionID != "" && devolucion.RecepcionID != recepcionID {
+			continue
+		}
+		if purchaseOrderID != "" && devolucion.PurchaseOrderID != purchaseOrderID {
+			continue
+		}
+		if estado != "" && devolucion.Estado != estado {
+			continue
+		}
+		found := *devolucion
+		devoluciones = append(devoluciones, &found)
+	}
+
+	sort.Slice(devoluciones, func(i, j int) bool {
+		return devoluciones[i].CreatedAt.After(devoluciones[j].CreatedAt)
+	})
+	return devoluciones, nil
+}
+
+// CreateDevolucionProveedorCommand represents a command to return received goods to their supplier
+type CreateDevolucionProveedorCommand struct {
+	RecepcionID string `json:"recepcion_id"`
+	Cantidad    int    `json:"cantidad"`
+	Motivo      string `json:"motivo"`
+	Notas       string `json:"notas"`
+	// DamageReportID links a return opened by a damage report; it is not
+	// accepted from API clients
+	DamageReportID string `json:"-"`
+}
+
+// CreateDevolucionProveedorHandler handles the creation of returns
+type CreateDevolucionProveedorHandler struct {
+	repository  DevolucionProveedorRepository
+	recepciones RecepcionProveedorRepository
+	Clock       clock.Clock
+
+	// mu serialises creation so concurrent returns cannot exceed the received quantity
+	mu sync.Mutex
+}
+
+// NewCreateDevolucionProveedorHandler creates a new handler
+func NewCreateDevolucionProveedorHandler(repository DevolucionProveedorRepository, recepciones RecepcionProveedorRepository) *CreateDevolucionProveedorHandler {
+	return &CreateDevolucionProveedorHandler{repository: repository, recepciones: recepciones, Clock: clock.System}
+}
+
+// Handle creates a return for goods of an inspected reception. The quantity
+// returned across the reception's open and completed returns cannot exceed
+// the quantity it delivered.
+func (h *CreateDevolucionProveedorHandler) Handle(ctx context.Context, cmd CreateDevolucionProveedorCommand) (*models.DevolucionProveedor, error) {
+	if !models.IsValidMotivo(cmd.Motivo) {
+		return nil, fmt.Errorf("%w: motivo must be %s or %s", ErrInvalidDevolucion, models.MotivoDamaged, models.MotivoExpired)
+	}
+	if cmd.Cantidad <= 0 {
+		return nil, fmt.Errorf("%w: cantidad must be positive", ErrInvalidDevolucion)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	recepcion, err := h.recepciones.GetByID(ctx, cmd.RecepcionID)
+	if err != nil {
+		return nil, err
+	}
+	if recepcion.Estado == models.EstadoPendingQuality {
+		return nil, fmt.Errorf("%w: recepcion %s has not been inspected", ErrInvalidDevolucion, recepcion.ID)
+	}
+
+	previous, err := h.repository.List(ctx, recepcion.ID, "", "")
+	if err != nil {
+		return nil, err
+	}
+	returnable := recepcion.Cantidad
+	for _, devolucion := range previous {
+		if devolucion.Estado != models.DevolucionCancelled {
+			returnable -= devolucion.Cantidad
+		}
+	}
+	if cmd.Cantidad > returnable {
+		return nil, fmt.Errorf("%w: only %d units of recepcion %s can be returned", ErrInvalidDevolucion, max(returnable, 0), recepcion.ID)
+	}
+
+	devolucion := &models.DevolucionProveedor{
+		ID:              uuid.New().String(),
+		RecepcionID:     recepcion.ID,
+		PurchaseOrderID: recepcion.PurchaseOrderID,
+		ProveedorID:     recepcion.ProveedorID,
+		ProductoID:      recepcion.ProductoID,
+		Cantidad:        cmd.Cantidad,
+		Motivo:          cmd.Motivo,
+		Notas:           cmd.Notas,
+		DamageReportID:  cmd.DamageReportID,
+		Estado:          models.DevolucionRequested,
+		CreatedAt:       h.Clock.Now(),
+		UpdatedAt:       h.Clock.Now(),
+	}
+
+	if err := h.repository.Save(ctx, devolucion); err != nil {
+		return nil, err
+	}
+
+	return devolucion, nil
+}
+
+// UpdateDevolucionProveedorEstadoCommand represents a command to move a return to a new state
+type UpdateDevolucionProveedorEstadoCommand struct {
+	ID     string `json:"id"`
+	Estado string `json:"estado"`
+}
+
+// UpdateDevolucionProveedorEstadoHandler handles return state changes
+type UpdateDevolucionProveedorEstadoHandler struct {
+	repository DevolucionProveedorRepository
+	Clock      clock.Clock
+}
+
+// NewUpdateDevolucionProveedorEstadoHandler creates a new handler
+func NewUpdateDevolucionProveedorEstadoHandler(repository DevolucionProveedorRepository) *UpdateDevolucionProveedorEstadoHandler {
+	return &UpdateDevolucionProveedorEstadoHandler{repository: repository, Clock: clock.System}
+}
+
+// Handle moves the return to the new state when the transition is allowed
+func (h *UpdateDevolucionProveedorEstadoHandler) Handle(ctx context.Context, cmd UpdateDevolucionProveedorEstadoCommand) (*models.DevolucionProveedor, error) {
+	devolucion, err := h.repository.GetByID(ctx, cmd.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !models.CanTransitionDevolucion(devolucion.Estado, cmd.Estado) {
+		return nil, fmt.Errorf("%w: %s to %s", ErrInvalidDevolucionTransition, devolucion.Estado, cmd.Estado)
+	}
+
+	devolucion.Estado = cmd.Estado
+	devolucion.UpdatedAt = h.Clock.Now()
+
+	if err := h.repository.Update(ctx, devolucion); err != nil {
+		return nil, err
+	}
+
+	return devolucion, nil
+}
+
+// GetDevolucionProveedorByIDQuery represents a query to get a return by ID
+type GetDevolucionProveedorByIDQuery struct {
+	ID string `json:"id"`
+}
+
+// GetDevolucionProveedorByIDHandler handles the get return by ID query
+type GetDevolucionProveedorByIDHandler struct {
+	repository DevolucionProveedorRepository
+}
+
+// NewGetDevolucionProveedorByIDHandler creates a new handler
+func NewGetDevolucionProveedorByIDHandler(repository DevolucionProveedorRepository) *GetDevolucionProveedorByIDHandler {
+	return &GetDevolucionProveedorByIDHandler{repository: repository}
+}
+
+// Handle processes the get return by ID query
+func (h *GetDevolucionProveedorByIDHandler) Handle(ctx context.Context, query GetDevolucionProveedorByIDQuery) (*models.DevolucionProveedor, error) {
+	return h.repository.GetByID(ctx, query.ID)
+}
+
+// ListDevolucionProveedorQuery represents a query to list returns
+type ListDevolucionProveedorQuery struct {
+	RecepcionID     string `json:"recepcion_id,omitempty"`
+	PurchaseOrderID string `json:"purchase_order_id,omitempty"`
+	Estado          string `json:"estado,omitempty"`
+}
+
+// ListDevolucionProveedorHandler handles the list returns query
+type ListDevolucionProveedorHandler struct {
+	repository DevolucionProveedorRepository
+}
+
+// NewListDevolucionProveedorHandler creates a new handler
+func NewListDevolucionProveedorHandler(repository DevolucionProveedorRepository) *ListDevolucionProveedorHandler {
+	return &ListDevolucionProveedorHandler{repository: repository}
+}
+
+// Handle processes the list returns query
+func (h *ListDevolucionProveedorHandler) Handle(ctx context.Context, query ListDevolucionProveedorQuery) ([]*models.DevolucionProveedor, error) {
+	return h.repository.List(ctx, query.RecepcionID, query.PurchaseOrderID, query.Estado)
+}
@@ -0,0 +1,91 @@
+package cqrs
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"proveedor/internal/models"
+)
+
+// EventRepository stores the events proveedor consumed and produced for each
+// purchase order, so the flow of an order can be traced across services
+type EventRepository interface {
+	Append(ctx context.Context, event *models.EventSourcingEvent) error
+	ListByAggregateID(ctx context.Context, aggregateID string) ([]*models.EventSourcingEvent, error)
+}
+
+// InMemoryEventRepository keeps events in memory, grouped by aggregate
+type InMemoryEventRepository struct {
+	mu     sync.RWMutex
+	events map[string][]*models.EventSourcingEvent
+}
+
+// NewInMemoryEventRepository creates a new in-memory repository
+func NewInMemoryEventRepository() *InMemoryEventRepository {
+	return &InMemoryEventRepository{
+		events: make(map[string][]*models.EventSourcingEvent),
+	}
+}
+
+// Append stores an event, numbering it after the aggregate's previous events
+func (r *InMemoryEventRepository) Append(ctx context.Context, event *models.EventSourcingEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored := *event
+	stored.Version = len(r.events[event.AggregateID]) + 1
+	r.events[event.AggregateID] = append(r.events[event.AggregateID], &stored)
+	event.Version = stored.Version
+	return nil
+}
+
+// ListByAggregateID returns copies of the events of an aggregate, oldest first
+func (r *InMemoryEventRepository) ListByAggregateID(ctx context.Context, aggregateID string) ([]*models.EventSourcingEvent, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	events := make([]*models.EventSourcingEvent, 0, len(r.events[aggregateID]))
+	for _, event := range r.events[aggregateID] {
+		found := *event
+		events = append(events, &found)
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Timestamp.Before(events[j].Timestamp)
+	})
+	return events, nil
+}
+
+// ListPurchaseOrderEventsQuery represents a query for the events of a
+// purchase order, optionally limited to one correlation chain
+type ListPurchaseOrderEventsQuery struct {
+	PurchaseOrderID string `json:"purchase_order_id"`
+	CorrelationID   string `json:"correlation_id,omitempty"`
+}
+
+// ListPurchaseOrderEventsHandler handles the list purchase order events query
+type ListPurchaseOrderEventsHandler struct {
+	repository EventRepository
+}
+
+// NewListPurchaseOrderEventsHandler creates a new handler
+func NewListPurchaseOrderEventsHandler(repository EventRepository) *ListPurchaseOrderEventsHandler {
+	return &ListPurchaseOrderEventsHandler{repository: repository}
+}
+
+// Handle returns the events of the purchase order, oldest first
+func (h *ListPurchaseOrderEventsHandler) Handle(ctx context.Context, query ListPurchaseOrderEventsQuery) ([]*models.EventSourcingEvent, error) {
+	events, err := h.repository.ListByAggregateID(ctx, query.PurchaseOrderID)
+	if err != nil || query.CorrelationID == "" {
+		return events, err
+	}
+
+	matches := make([]*models.EventSourcingEvent, 0, len(events))
+	for _, event := range events {
+		if event.CorrelationID != nil && *event.CorrelationID == query.CorrelationID {
+			matches = append(matches, event)
+		}
+	}
+	return matches, nil
+}
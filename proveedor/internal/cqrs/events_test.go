@@ -0,0 +1,57 @@
+package cqrs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"proveedor/internal/models"
+)
+
+func TestEventRepositoryNumbersEventsPerOrder(t *testing.T) {
+	ctx := context.Background()
+	repository := NewInMemoryEventRepository()
+	chain := "chain-1"
+
+	first := models.NewEventSourcingEvent("po-1", "RecepcionCreada", nil, &chain, nil, cancelledAt)
+	second := models.NewEventSourcingEvent("po-1", "InventarioRecibido", nil, nil, nil, cancelledAt.Add(-time.Minute))
+	other := models.NewEventSourcingEvent("po-2", "RecepcionCreada", nil, nil, nil, cancelledAt)
+	for _, event := range []*models.EventSourcingEvent{first, second, other} {
+		if err := repository.Append(ctx, event); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+	if first.Version != 1 || second.Version != 2 || other.Version != 1 {
+		t.Fatalf("versions %d, %d, %d, want 1, 2, 1", first.Version, second.Version, other.Version)
+	}
+
+	// Events are listed oldest first, as copies
+	events, err := repository.ListByAggregateID(ctx, "po-1")
+	if err != nil || len(events) != 2 || events[0].ID != second.ID || events[1].ID != first.ID {
+		t.Fatalf("listed %v, error %v", events, err)
+	}
+	events[0].EventType = "Changed"
+	if events, _ := repository.ListByAggregateID(ctx, "po-1"); events[0].EventType != "InventarioRecibido" {
+		t.Fatalf("listing returned the stored event, changed to %s", events[0].EventType)
+	}
+}
+
+func TestListPurchaseOrderEventsByCorrelation(t *testing.T) {
+	ctx := context.Background()
+	repository := NewInMemoryEventRepository()
+	chain, other := "chain-1", "chain-2"
+	for _, correlationID := range []*string{&chain, &other, nil} {
+		if err := repository.Append(ctx, models.NewEventSourcingEvent("po-1", "RecepcionCreada", nil, correlationID, nil, cancelledAt)); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+	h := NewListPurchaseOrderEventsHandler(repository)
+
+	if events, err := h.Handle(ctx, ListPurchaseOrderEventsQuery{PurchaseOrderID: "po-1"}); err != nil || len(events) != 3 {
+		t.Fatalf("listed %d events, error %v, want all 3", len(events), err)
+	}
+	events, err := h.Handle(ctx, ListPurchaseOrderEventsQuery{PurchaseOrderID: "po-1", CorrelationID: chain})
+	if err != nil || len(events) != 1 || *events[0].CorrelationID != chain {
+		t.Fatalf("listed %v, error %v, want the one in %s", events, err, chain)
+	}
+}
@@ -0,0 +1,72 @@
+package cqrs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"medisupply/clock"
+	"proveedor/internal/models"
+)
+
+var (
+	// ErrInvalidInspection is returned for inspections with an unknown result or no inspector
+	ErrInvalidInspection = errors.New("invalid quality inspection")
+	// ErrInspectionNotAllowed is returned when the reception is not awaiting inspection
+	ErrInspectionNotAllowed = errors.New("reception is not awaiting quality inspection")
+)
+
+// RecordQualityInspectionCommand represents a command to record an inspector's verdict on a reception
+type RecordQualityInspectionCommand struct {
+	RecepcionID string `json:"recepcion_id"`
+	Result      string `json:"result"`
+	Notes       string `json:"notes"`
+	InspectorID string `json:"inspector_id"`
+}
+
+// RecordQualityInspectionHandler handles quality inspections of receptions
+type RecordQualityInspectionHandler struct {
+	repository RecepcionProveedorRepository
+	Clock      clock.Clock
+}
+
+// NewRecordQualityInspectionHandler creates a new handler
+func NewRecordQualityInspectionHandler(repository RecepcionProveedorRepository) *RecordQualityInspectionHandler {
+	return &RecordQualityInspectionHandler{repository: repository, Clock: clock.System}
+}
+
+// Handle records the inspection and moves the reception to the state of its
+// result. Only receptions pending quality or in quarantine can be inspected.
+func (h *RecordQualityInspectionHandler) Handle(ctx context.Context, cmd RecordQualityInspectionCommand) (*models.RecepcionProveedor, error) {
+	result, ok := models.ParseQualityResult(cmd.Result)
+	if !ok {
+		return nil, fmt.Errorf("%w: result must be pass, fail or quarantine", ErrInvalidInspection)
+	}
+	if cmd.InspectorID == "" {
+		return nil, fmt.Errorf("%w: inspector_id is required", ErrInvalidInspection)
+	}
+
+	recepcion, err := h.repository.GetByID(ctx, cmd.RecepcionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if recepcion.Estado != models.EstadoPendingQuality && recepcion.Estado != models.EstadoQuarantined {
+		return nil, fmt.Errorf("%w: recepcion %s is %s", ErrInspectionNotAllowed, recepcion.ID, recepcion.Estado)
+	}
+
+	recepcion.QualityInspection = models.NewQualityInspection(result, cmd.Notes, cmd.InspectorID, h.Clock.Now())
+	recepcion.Estado = result.Estado()
+	recepcion.UpdatedAt = h.Clock.Now()
+
+	// The reception may have been cancelled or inspected since it was read
+	err = h.repository.UpdateIfEstado(ctx, recepcion, models.EstadoPendingQuality, models.EstadoQuarantined)
+	if errors.Is(err, ErrRecepcionProveedorConflict) {
+		return nil, fmt.Errorf("%w: %v", ErrInspectionNotAllowed, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return recepcion, nil
+}
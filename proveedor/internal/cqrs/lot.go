@@ -0,0 +1,184 @@
+package cqrs
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"medisupply/clock"
+	"proveedor/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// ErrLotNotFound is returned when a lot does not exist
+var ErrLotNotFound = errors.New("lot not found")
+
+// LotRepository stores the lots received with receptions
+type LotRepository interface {
+	Save(ctx context.Context, lot *models.Lot) error
+	Update(ctx context.Context, lot *models.Lot) error
+	List(ctx context.Context, productID string, expiringBefore *time.Time) ([]*models.Lot, error)
+	ListByRecepcionID(ctx context.Context, recepcionID string) ([]*models.Lot, error)
+}
+
+// InMemoryLotRepository keeps lots in memory
+type InMemoryLotRepository struct {
+	mu   sync.RWMutex
+	lots map[string]*models.Lot
+}
+
+// NewInMemoryLotRepository creates a new in-memory repository
+func NewInMemoryLotRepository() *InMemoryLotRepository {
+	return &InMemoryLotRepository{
+		lots: make(map[string]*models.Lot),
+	}
+}
+
+// Save stores a new lot
+func (r *InMemoryLotRepository) Save(ctx context.Context, lot *models.Lot) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored := *lot
+	r.lots[lot.ID] = &stored
+	return nil
+}
+
+// Update replaces an existing lot
+func (r *InMemoryLotRepository) Update(ctx context.Context, lot *models.Lot) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.lots[lot.ID]; !ok {
+		return ErrLotNotFound
+	}
+
+	stored := *lot
+	r.lots[lot.ID] = &stored
+	return nil
+}
+
+// List returns the lots matching the optional product and expiry filters,
+// soonest expiry first; lots without an expiry date come last
+func (r *InMemoryLotRepository) List(ctx context.Context, productID string, expiringBefore *time.Time) ([]*models.Lot, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	lots := make([]*models.Lot, 0, len(r.lots))
+	for _, lot := range r.lots {
+		if productID != "" && lot.ProductID != productID {
+			continue
+		}
+		if expiringBefore != nil && !lot.ExpiresBefore(*expiringBefore) {
+			continue
+		}
+		found := *lot
+		lots = append(lots, &found)
+	}
+
+	sort.Slice(lots, func(i, j int) bool {
+		a, b := lots[i].ExpiryDate, lots[j].ExpiryDate
+		switch {
+		case a == nil && b == nil:
+			return lots[i].CreatedAt.Before(lots[j].CreatedAt)
+		case a == nil || b == nil:
+			return b == nil
+		default:
+			return a.Before(*b)
+		}
+	})
+	return lots, nil
+}
+
+// ListByRecepcionID returns the lots received with a reception, oldest first
+func (r *InMemoryLotRepository) ListByRecepcionID(ctx context.Context, recepcionID string) ([]*models.Lot, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	lots := []*models.Lot{}
+	for _, lot := range r.lots {
+		if lot.RecepcionID != recepcionID {
+			continue
+		}
+		found := *lot
+		lots = append(lots, &found)
+	}
+
+	sort.Slice(lots, func(i, j int) bool {
+		if lots[i].CreatedAt.Equal(lots[j].CreatedAt) {
+			return lots[i].ID < lots[j].ID
+		}
+		return lots[i].CreatedAt.Before(lots[j].CreatedAt)
+	})
+	return lots, nil
+}
+
+// CreateLotCommand represents a command to record the lot delivered with a reception
+type CreateLotCommand struct {
+	RecepcionID     string     `json:"recepcion_id"`
+	PurchaseOrderID string     `json:"purchase_order_id"`
+	ProductID       string     `json:"product_id"`
+	SupplierID      string     `json:"supplier_id"`
+	Quantity        int        `json:"quantity"`
+	BatchNumber     string     `json:"batch_number"`
+	ExpiryDate      *time.Time `json:"expiry_date"`
+}
+
+// CreateLotHandler handles the creation of lots
+type CreateLotHandler struct {
+	repository LotRepository
+	Clock      clock.Clock
+}
+
+// NewCreateLotHandler creates a new handler
+func NewCreateLotHandler(repository LotRepository) *CreateLotHandler {
+	return &CreateLotHandler{repository: repository, Clock: clock.System}
+}
+
+// Handle records the lot, generating a batch number when the supplier sent none
+func (h *CreateLotHandler) Handle(ctx context.Context, cmd CreateLotCommand) (*models.Lot, error) {
+	lot := &models.Lot{
+		ID:              uuid.New().String(),
+		BatchNumber:     cmd.BatchNumber,
+		ProductID:       cmd.ProductID,
+		SupplierID:      cmd.SupplierID,
+		PurchaseOrderID: cmd.PurchaseOrderID,
+		RecepcionID:     cmd.RecepcionID,
+		Quantity:        cmd.Quantity,
+		ExpiryDate:      cmd.ExpiryDate,
+		CreatedAt:       h.Clock.Now(),
+	}
+	if lot.BatchNumber == "" {
+		lot.BatchNumber = models.GenerateBatchNumber()
+	}
+
+	if err := h.repository.Save(ctx, lot); err != nil {
+		return nil, err
+	}
+
+	return lot, nil
+}
+
+// ListLotsQuery represents a query to list lots by product and expiry
+type ListLotsQuery struct {
+	ProductID      string     `json:"product_id,omitempty"`
+	ExpiringBefore *time.Time `json:"expiring_before,omitempty"`
+}
+
+// ListLotsHandler handles the list lots query
+type ListLotsHandler struct {
+	repository LotRepository
+}
+
+// NewListLotsHandler creates a new handler
+func NewListLotsHandler(repository LotRepository) *ListLotsHandler {
+	return &ListLotsHandler{repository: repository}
+}
+
+// Handle processes the list lots query
+func (h *ListLotsHandler) Handle(ctx context.Context, query ListLotsQuery) ([]*models.Lot, error) {
+	return h.repository.List(ctx, query.ProductID, query.ExpiringBefore)
+}
@@ -13,23 +13,17 @@ type GetRecepcionProveedorByIDQuery struct {
 
 // GetRecepcionProveedorByIDHandler handles the get recepcion proveedor by ID query
 type GetRecepcionProveedorByIDHandler struct {
-	// Add repository interface here when implementing
+	repository RecepcionProveedorRepository
 }
 
 // NewGetRecepcionProveedorByIDHandler creates a new handler
-func NewGetRecepcionProveedorByIDHandler() *GetRecepcionProveedorByIDHandler {
-	return &GetRecepcionProveedorByIDHandler{}
+func NewGetRecepcionProveedorByIDHandler(repository RecepcionProveedorRepository) *GetRecepcionProveedorByIDHandler {
+	return &GetRecepcionProveedorByIDHandler{repository: repository}
 }
 
 // Handle processes the get recepcion proveedor by ID query
 func (h *GetRecepcionProveedorByIDHandler) Handle(ctx context.Context, query GetRecepcionProveedorByIDQuery) (*models.RecepcionProveedor, error) {
-	// TODO: Get from repository
-	// return h.repository.GetByID(ctx, query.ID)
-
-	// Placeholder response
-	return &models.RecepcionProveedor{
-		ID: query.ID,
-	}, nil
+	return h.repository.GetByID(ctx, query.ID)
 }
 
 // ListRecepcionProveedorQuery represents a query to list recepcion proveedor
@@ -42,19 +36,65 @@ type ListRecepcionProveedorQuery struct {
 
 // ListRecepcionProveedorHandler handles the list recepcion proveedor query
 type ListRecepcionProveedorHandler struct {
-	// Add repository interface here when implementing
+	repository RecepcionProveedorRepository
 }
 
 // NewListRecepcionProveedorHandler creates a new handler
-func NewListRecepcionProveedorHandler() *ListRecepcionProveedorHandler {
-	return &ListRecepcionProveedorHandler{}
+func NewListRecepcionProveedorHandler(repository RecepcionProveedorRepository) *ListRecepcionProveedorHandler {
+	return &ListRecepcionProveedorHandler{repository: repository}
 }
 
 // Handle processes the list recepcion proveedor query
 func (h *ListRecepcionProveedorHandler) Handle(ctx context.Context, query ListRecepcionProveedorQuery) ([]*models.RecepcionProveedor, error) {
-	// TODO: List from repository
-	// return h.repository.List(ctx, query.ProveedorID, query.Estado, query.Limit, query.Offset)
+	return h.repository.List(ctx, query.ProveedorID, query.Estado, query.Limit, query.Offset)
+}
+
+// GetPurchaseOrderReceiptQuery represents a query for the deliveries received for a purchase order
+type GetPurchaseOrderReceiptQuery struct {
+	PurchaseOrderID string `json:"purchase_order_id"`
+}
+
+// GetPurchaseOrderReceiptHandler handles the get purchase order receipt query
+type GetPurchaseOrderReceiptHandler struct {
+	repository RecepcionProveedorRepository
+}
+
+// NewGetPurchaseOrderReceiptHandler creates a new handler
+func NewGetPurchaseOrderReceiptHandler(repository RecepcionProveedorRepository) *GetPurchaseOrderReceiptHandler {
+	return &GetPurchaseOrderReceiptHandler{repository: repository}
+}
+
+// Handle totals the deliveries of the purchase order. The order stays open
+// while quantity remains outstanding.
+func (h *GetPurchaseOrderReceiptHandler) Handle(ctx context.Context, query GetPurchaseOrderReceiptQuery) (*models.PurchaseOrderReceipt, error) {
+	recepciones, err := h.repository.ListByPurchaseOrderID(ctx, query.PurchaseOrderID)
+	if err != nil {
+		return nil, err
+	}
+	if len(recepciones) == 0 {
+		return nil, ErrRecepcionProveedorNotFound
+	}
+
+	receipt := &models.PurchaseOrderReceipt{
+		PurchaseOrderID: query.PurchaseOrderID,
+		OrderedQuantity: recepciones[0].CantidadOrdenada,
+		Recepciones:     recepciones,
+	}
+	for _, recepcion := range recepciones {
+		receipt.ReceivedQuantity += recepcion.Cantidad
+	}
+
+	outstanding := receipt.OrderedQuantity - receipt.ReceivedQuantity
+	receipt.RemainingQuantity = max(outstanding, 0)
+	receipt.ExcessQuantity = max(-outstanding, 0)
+	switch {
+	case outstanding > 0:
+		receipt.Status = models.ReceiptOpen
+	case outstanding < 0:
+		receipt.Status = models.ReceiptOverDelivered
+	default:
+		receipt.Status = models.ReceiptComplete
+	}
 
-	// Placeholder response
-	return []*models.RecepcionProveedor{}, nil
+	return receipt, nil
 }
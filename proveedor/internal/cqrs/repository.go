@@ -0,0 +1,219 @@
+package cqrs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+
+	"proveedor/internal/models"
+)
+
+var (
+	// ErrRecepcionProveedorNotFound is returned when a recepcion proveedor does not exist
+	ErrRecepcionProveedorNotFound = errors.New("recepcion proveedor not found")
+	// ErrRecepcionProveedorConflict is returned when a recepcion proveedor is
+	// no longer in the state a change expected
+	ErrRecepcionProveedorConflict = errors.New("recepcion proveedor changed state")
+	// ErrCancellationNotFound is returned when a purchase order was not cancelled
+	ErrCancellationNotFound = errors.New("purchase order cancellation not found")
+)
+
+// RecepcionProveedorRepository stores recepcion proveedor records
+type RecepcionProveedorRepository interface {
+	Save(ctx context.Context, recepcion *models.RecepcionProveedor) error
+	GetByID(ctx context.Context, id string) (*models.RecepcionProveedor, error)
+	GetByPurchaseOrderID(ctx context.Context, purchaseOrderID string) (*models.RecepcionProveedor, error)
+	ListByPurchaseOrderID(ctx context.Context, purchaseOrderID string) ([]*models.RecepcionProveedor, error)
+	Update(ctx context.Context, recepcion *models.RecepcionProveedor) error
+	// UpdateIfEstado replaces the recepcion proveedor only while its stored
+	// estado is one of estados, returning ErrRecepcionProveedorConflict
+	// otherwise, so concurrent state changes cannot overwrite each other
+	UpdateIfEstado(ctx context.Context, recepcion *models.RecepcionProveedor, estados ...string) error
+	List(ctx context.Context, proveedorID, estado string, limit, offset int) ([]*models.RecepcionProveedor, error)
+	// SaveCancellation records a purchase order cancellation, keeping the
+	// first one recorded
+	SaveCancellation(ctx context.Context, cancellation *models.PurchaseOrderCancellation) error
+	GetCancellation(ctx context.Context, purchaseOrderID string) (*models.PurchaseOrderCancellation, error)
+}
+
+// InMemoryRecepcionProveedorRepository keeps recepcion proveedor records in memory
+type InMemoryRecepcionProveedorRepository struct {
+	mu            sync.RWMutex
+	recepciones   map[string]*models.RecepcionProveedor
+	cancellations map[string]*models.PurchaseOrderCancellation
+}
+
+// NewInMemoryRecepcionProveedorRepository creates a new in-memory repository
+func NewInMemoryRecepcionProveedorRepository() *InMemoryRecepcionProveedorRepository {
+	return &InMemoryRecepcionProveedorRepository{
+		recepciones:   make(map[string]*models.RecepcionProveedor),
+		cancellations: make(map[string]*models.PurchaseOrderCancellation),
+	}
+}
+
+// Save stores a new recepcion proveedor
+func (r *InMemoryRecepcionProveedorRepository) Save(ctx context.Context, recepcion *models.RecepcionProveedor) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored := *recepcion
+	r.recepciones[recepcion.ID] = &stored
+	return nil
+}
+
+// GetByID returns a copy of the recepcion proveedor with the given ID
+func (r *InMemoryRecepcionProveedorRepository) GetByID(ctx context.Context, id string) (*models.RecepcionProveedor, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	recepcion, ok := r.recepciones[id]
+	if !ok {
+		return nil, ErrRecepcionProveedorNotFound
+	}
+
+	found := *recepcion
+	return &found, nil
+}
+
+// GetByPurchaseOrderID returns a copy of the most recent recepcion proveedor of a purchase order
+func (r *InMemoryRecepcionProveedorRepository) GetByPurchaseOrderID(ctx context.Context, purchaseOrderID string) (*models.RecepcionProveedor, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var latest *models.RecepcionProveedor
+	for _, recepcion := range r.recepciones {
+		if recepcion.PurchaseOrderID != purchaseOrderID {
+			continue
+		}
+		if latest == nil || recepcion.CreatedAt.After(latest.CreatedAt) {
+			latest = recepcion
+		}
+	}
+	if purchaseOrderID == "" || latest == nil {
+		return nil, ErrRecepcionProveedorNotFound
+	}
+
+	found := *latest
+	return &found, nil
+}
+
+// ListByPurchaseOrderID returns the recepciones of a purchase order, oldest first
+func (r *InMemoryRecepcionProveedorRepository) ListByPurchaseOrderID(ctx context.Context, purchaseOrderID string) ([]*models.RecepcionProveedor, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	recepciones := []*models.RecepcionProveedor{}
+	for _, recepcion := range r.recepciones {
+		if purchaseOrderID == "" || recepcion.PurchaseOrderID != purchaseOrderID {
+			continue
+		}
+		found := *recepcion
+		recepciones = append(recepciones, &found)
+	}
+
+	sort.Slice(recepciones, func(i, j int) bool {
+		if recepciones[i].CreatedAt.Equal(recepciones[j].CreatedAt) {
+			return recepciones[i].ID < recepciones[j].ID
+		}
+		return recepciones[i].CreatedAt.Before(recepciones[j].CreatedAt)
+	})
+	return recepciones, nil
+}
+
+// Update replaces an existing recepcion proveedor
+func (r *InMemoryRecepcionProveedorRepository) Update(ctx context.Context, recepcion *models.RecepcionProveedor) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.recepciones[recepcion.ID]; !ok {
+		return ErrRecepcionProveedorNotFound
+	}
+
+	stored := *recepcion
+	r.recepciones[recepcion.ID] = &stored
+	return nil
+}
+
+// UpdateIfEstado replaces an existing recepcion proveedor if its stored
+// estado is one of estados
+func (r *InMemoryRecepcionProveedorRepository) UpdateIfEstado(ctx context.Context, recepcion *models.RecepcionProveedor, estados ...string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, ok := r.recepciones[recepcion.ID]
+	if !ok {
+		return ErrRecepcionProveedorNotFound
+	}
+	if !slices.Contains(estados, existing.Estado) {
+		return fmt.Errorf("%w: recepcion %s is %s", ErrRecepcionProveedorConflict, recepcion.ID, existing.Estado)
+	}
+
+	stored := *recepcion
+	r.recepciones[recepcion.ID] = &stored
+	return nil
+}
+
+// SaveCancellation stores a purchase order cancellation unless one is stored
+func (r *InMemoryRecepcionProveedorRepository) SaveCancellation(ctx context.Context, cancellation *models.PurchaseOrderCancellation) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.cancellations[cancellation.PurchaseOrderID]; ok {
+		return nil
+	}
+	stored := *cancellation
+	r.cancellations[cancellation.PurchaseOrderID] = &stored
+	return nil
+}
+
+// GetCancellation returns a copy of the cancellation of a purchase order
+func (r *InMemoryRecepcionProveedorRepository) GetCancellation(ctx context.Context, purchaseOrderID string) (*models.PurchaseOrderCancellation, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	cancellation, ok := r.cancellations[purchaseOrderID]
+	if !ok {
+		return nil, ErrCancellationNotFound
+	}
+
+	found := *cancellation
+	return &found, nil
+}
+
+// List returns the recepciones matching the optional proveedor and estado
+// filters, newest first. A limit of zero returns every match after offset.
+func (r *InMemoryRecepcionProveedorRepository) List(ctx context.Context, proveedorID, estado string, limit, offset int) ([]*models.RecepcionProveedor, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	matches := make([]*models.RecepcionProveedor, 0, len(r.recepciones))
+	for _, recepcion := range r.recepciones {
+		if proveedorID != "" && recepcion.ProveedorID != proveedorID {
+			continue
+		}
+		if estado != "" && recepcion.Estado != estado {
+			continue
+		}
+		found := *recepcion
+		matches = append(matches, &found)
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].CreatedAt.Equal(matches[j].CreatedAt) {
+			return matches[i].ID < matches[j].ID
+		}
+		return matches[i].CreatedAt.After(matches[j].CreatedAt)
+	})
+
+	if offset >= len(matches) {
+		return []*models.RecepcionProveedor{}, nil
+	}
+	matches = matches[offset:]
+	if limit > 0 && limit < len(matches) {
+		matches = matches[:limit]
+	}
+	return matches, nil
+}
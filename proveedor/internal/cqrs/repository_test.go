@@ -0,0 +1,105 @@
+package cqrs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"medisupply/clock"
+	"proveedor/internal/models"
+)
+
+func TestRepositoryReturnsCopies(t *testing.T) {
+	ctx := context.Background()
+	repository := NewInMemoryRecepcionProveedorRepository()
+	recepcion := &models.RecepcionProveedor{ID: "recepcion-1", Estado: models.EstadoPendingQuality}
+	if err := repository.Save(ctx, recepcion); err != nil {
+		t.Fatalf("save recepcion: %v", err)
+	}
+
+	// Changes to the saved or returned value do not reach the store
+	recepcion.Estado = models.EstadoRejected
+	found, err := repository.GetByID(ctx, "recepcion-1")
+	if err != nil || found.Estado != models.EstadoPendingQuality {
+		t.Fatalf("recepcion %+v, error %v", found, err)
+	}
+	found.Estado = models.EstadoReceived
+	if found, _ := repository.GetByID(ctx, "recepcion-1"); found.Estado != models.EstadoPendingQuality {
+		t.Fatalf("recepcion is %s after changing a copy", found.Estado)
+	}
+
+	if _, err := repository.GetByID(ctx, "missing"); !errors.Is(err, ErrRecepcionProveedorNotFound) {
+		t.Fatalf("get missing returned %v", err)
+	}
+	if err := repository.Update(ctx, &models.RecepcionProveedor{ID: "missing"}); !errors.Is(err, ErrRecepcionProveedorNotFound) {
+		t.Fatalf("update missing returned %v", err)
+	}
+}
+
+func TestRepositoryListFiltersAndPages(t *testing.T) {
+	ctx := context.Background()
+	repository := NewInMemoryRecepcionProveedorRepository()
+	for i, recepcion := range []*models.RecepcionProveedor{
+		{ID: "a", ProveedorID: "proveedor-1", Estado: models.EstadoPendingQuality},
+		{ID: "b", ProveedorID: "proveedor-1", Estado: models.EstadoReceived},
+		{ID: "c", ProveedorID: "proveedor-2", Estado: models.EstadoPendingQuality},
+		{ID: "d", ProveedorID: "proveedor-1", Estado: models.EstadoPendingQuality},
+	} {
+		recepcion.CreatedAt = cancelledAt.Add(time.Duration(i) * time.Hour)
+		if err := repository.Save(ctx, recepcion); err != nil {
+			t.Fatalf("save recepcion: %v", err)
+		}
+	}
+
+	for _, tc := range []struct {
+		name          string
+		proveedorID   string
+		estado        string
+		limit, offset int
+		want          []string
+	}{
+		{"everything newest first", "", "", 0, 0, []string{"d", "c", "b", "a"}},
+		{"by proveedor", "proveedor-1", "", 0, 0, []string{"d", "b", "a"}},
+		{"by estado", "", models.EstadoPendingQuality, 0, 0, []string{"d", "c", "a"}},
+		{"by both", "proveedor-1", models.EstadoPendingQuality, 0, 0, []string{"d", "a"}},
+		{"limited", "", "", 2, 0, []string{"d", "c"}},
+		{"offset", "", "", 2, 3, []string{"a"}},
+		{"past the end", "", "", 0, 4, []string{}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			recepciones, err := repository.List(ctx, tc.proveedorID, tc.estado, tc.limit, tc.offset)
+			if err != nil {
+				t.Fatalf("list: %v", err)
+			}
+			if len(recepciones) != len(tc.want) {
+				t.Fatalf("listed %d recepciones, want %v", len(recepciones), tc.want)
+			}
+			for i, recepcion := range recepciones {
+				if recepcion.ID != tc.want[i] {
+					t.Fatalf("recepcion %d is %s, want %v", i, recepcion.ID, tc.want)
+				}
+			}
+		})
+	}
+}
+
+func TestUpdateChangesTheStoredEstado(t *testing.T) {
+	ctx := context.Background()
+	repository := NewInMemoryRecepcionProveedorRepository()
+	saveRecepcion(t, repository, "recepcion-1", models.EstadoPendingQuality)
+
+	h := NewUpdateRecepcionProveedorHandler(repository)
+	h.Clock = clock.NewFake(cancelledAt)
+	if err := h.Handle(ctx, UpdateRecepcionProveedorCommand{ID: "recepcion-1", Estado: models.EstadoReceived}); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	recepcion, err := repository.GetByID(ctx, "recepcion-1")
+	if err != nil || recepcion.Estado != models.EstadoReceived || !recepcion.UpdatedAt.Equal(cancelledAt) {
+		t.Fatalf("recepcion %+v, error %v", recepcion, err)
+	}
+
+	if err := h.Handle(ctx, UpdateRecepcionProveedorCommand{ID: "missing"}); !errors.Is(err, ErrRecepcionProveedorNotFound) {
+		t.Fatalf("update missing returned %v", err)
+	}
+}
@@ -0,0 +1,419 @@
+package cqrs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"medisupply/clock"
+	"proveedor/internal/gs1"
+	"proveedor/internal/models"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrProductGTINNotFound is returned when a GTIN is not registered to a product
+	ErrProductGTINNotFound = errors.New("GTIN not registered to a product")
+	// ErrInvalidProductGTIN is returned for GTIN registrations without a valid GTIN or product
+	ErrInvalidProductGTIN = errors.New("invalid product GTIN")
+	// ErrInvalidReceptionScan is returned for scans without a scanner, GTIN or valid quantity
+	ErrInvalidReceptionScan = errors.New("invalid reception scan")
+	// ErrReceptionScanMismatch is returned when scanned goods do not match the
+	// purchase order line received
+	ErrReceptionScanMismatch = errors.New("scan does not match the purchase order line")
+	// ErrDuplicateSerialNumber is returned when a serialised item is scanned twice
+	ErrDuplicateSerialNumber = errors.New("serial number already scanned")
+)
+
+// ProductGTINRepository stores the GTINs of products
+type ProductGTINRepository interface {
+	Save(ctx context.Context, productGTIN *models.ProductGTIN) error
+	GetByGTIN(ctx context.Context, gtin string) (*models.ProductGTIN, error)
+}
+
+// InMemoryProductGTINRepository keeps product GTINs in memory
+type InMemoryProductGTINRepository struct {
+	mu    sync.RWMutex
+	gtins map[string]*models.ProductGTIN
+}
+
+// NewInMemoryProductGTINRepository creates a new in-memory repository
+func NewInMemoryProductGTINRepository() *InMemoryProductGTINRepository {
+	return &InMemoryProductGTINRepository{
+		gtins: make(map[string]*models.ProductGTIN),
+	}
+}
+
+// Save stores a product GTIN, replacing any earlier product of the GTIN
+func (r *InMemoryProductGTINRepository) Save(ctx context.Context, productGTIN *models.ProductGTIN) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored := *productGTIN
+	r.gtins[productGTIN.GTIN] = &stored
+	return nil
+}
+
+// GetByGTIN returns a copy of the product GTIN of a 14-digit GTIN
+func (r *InMemoryProductGTINRepository) GetByGTIN(ctx context.Context, gtin string) (*models.ProductGTIN, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	productGTIN, ok := r.gtins[gtin]
+	if !ok {
+		return nil, ErrProductGTINNotFound
+	}
+
+	found := *productGTIN
+	return &found, nil
+}
+
+// ReceptionScanRepository stores the barcodes scanned on receptions
+type ReceptionScanRepository interface {
+	Save(ctx context.Context, scan *models.ReceptionScan) error
+	ListByRecepcionID(ctx context.Context, recepcionID string) ([]*models.ReceptionScan, error)
+	HasSerialNumber(ctx context.Context, gtin, serialNumber string) (bool, error)
+}
+
+// InMemoryReceptionScanRepository keeps reception scans in memory
+type InMemoryReceptionScanRepository struct {
+	mu    sync.RWMutex
+	scans map[string]*models.ReceptionScan
+}
+
+// NewInMemoryReceptionScanRepository creates a new in-memory repository
+func NewInMemoryReceptionScanRepository() *InMemoryReceptionScanRepository {
+	return &InMemoryReceptionScanRepository{
+		scans: make(map[string]*models.ReceptionScan),
+	}
+}
+
+// Save stores a new scan
+func (r *InMemoryReceptionScanRepository) Save(ctx context.Context, scan *models.ReceptionScan) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored := *scan
+	r.scans[scan.ID] = &stored
+	return nil
+}
+
+// ListByRecepcionID returns the scans of a reception, oldest first
+func (r *InMemoryReceptionScanRepository) ListByRecepcionID(ctx context.Context, recepcionID string) ([]*models.ReceptionScan, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	scans := []*models.ReceptionScan{}
+	for _, scan := range r.scans {
+		if scan.RecepcionID != recepcionID {
+			continue
+		}
+		found := *scan
+		scans = append(scans, &found)
+	}
+
+	sort.Slice(scans, func(i, j int) bool {
+		if scans[i].CreatedAt.Equal(scans[j].CreatedAt) {
+			return scans[i].ID < scans[j].ID
+		}
+		return scans[i].CreatedAt.Before(scans[j].CreatedAt)
+	})
+	return scans, nil
+}
+
+// HasSerialNumber reports whether the item with a GTIN and serial number was
+// scanned on any reception
+func (r *InMemoryReceptionScanRepository) HasSerialNumber(ctx context.Context, gtin, serialNumber string) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, scan := range r.scans {
+		if scan.GTIN == gtin && scan.SerialNumber == serialNumber {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// RegisterProductGTINCommand represents a command to register the GTIN of a product
+type RegisterProductGTINCommand struct {
+	GTIN      string `json:"gtin"`
+	ProductID string `json:"product_id"`
+}
+
+// RegisterProductGTINHandler handles GTIN registrations
+type RegisterProductGTINHandler struct {
+	repository ProductGTINRepository
+	Clock      clock.Clock
+}
+
+// NewRegisterProductGTINHandler creates a new handler
+func NewRegisterProductGTINHandler(repository ProductGTINRepository) *RegisterProductGTINHandler {
+	return &RegisterProductGTINHandler{repository: repository, Clock: clock.System}
+}
+
+// Handle registers a GTIN-8, GTIN-12, GTIN-13 or GTIN-14 to a product, stored
+// as the 14 digits scanners read. A product may have several GTINs, one per
+// packaging level.
+func (h *RegisterProductGTINHandler) Handle(ctx context.Context, cmd RegisterProductGTINCommand) (*models.ProductGTIN, error) {
+	gtin, err := gs1.NormalizeGTIN(cmd.GTIN)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidProductGTIN, err)
+	}
+	if strings.TrimSpace(cmd.ProductID) == "" {
+		return nil, fmt.Errorf("%w: product_id is required", ErrInvalidProductGTIN)
+	}
+
+	productGTIN := &models.ProductGTIN{
+		GTIN:      gtin,
+		ProductID: cmd.ProductID,
+		UpdatedAt: h.Clock.Now(),
+	}
+	if err := h.repository.Save(ctx, productGTIN); err != nil {
+		return nil, err
+	}
+
+	return productGTIN, nil
+}
+
+// GetProductGTINQuery represents a query for the product of a GTIN
+type GetProductGTINQuery struct {
+	GTIN string `json:"gtin"`
+}
+
+// GetProductGTINHandler handles the get product GTIN query
+type GetProductGTINHandler struct {
+	repository ProductGTINRepository
+}
+
+// NewGetProductGTINHandler creates a new handler
+func NewGetProductGTINHandler(repository ProductGTINRepository) *GetProductGTINHandler {
+	return &GetProductGTINHandler{repository: repository}
+}
+
+// Handle processes the get product GTIN query for a GTIN of any length
+func (h *GetProductGTINHandler) Handle(ctx context.Context, query GetProductGTINQuery) (*models.ProductGTIN, error) {
+	gtin, err := gs1.NormalizeGTIN(query.GTIN)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidProductGTIN, err)
+	}
+	return h.repository.GetByGTIN(ctx, gtin)
+}
+
+// RecordReceptionScanCommand represents a command to record a barcode scanned
+// on a reception. Cantidad is the number of units scanned; zero means the
+// count of AI (30), or one unit.
+type RecordReceptionScanCommand struct {
+	RecepcionID string `json:"recepcion_id"`
+	Barcode     string `json:"barcode"`
+	Cantidad    int    `json:"cantidad"`
+	ScannedBy   string `json:"scanned_by"`
+}
+
+// RecordReceptionScanHandler handles reception scans
+type RecordReceptionScanHandler struct {
+	// mu serialises scans, so concurrent scanners cannot together exceed the
+	// delivered quantity or record a serial number twice
+	mu          sync.Mutex
+	scans       ReceptionScanRepository
+	gtins       ProductGTINRepository
+	recepciones RecepcionProveedorRepository
+	lots        LotRepository
+	Clock       clock.Clock
+}
+
+// NewRecordReceptionScanHandler creates a new handler
+func NewRecordReceptionScanHandler(scans ReceptionScanRepository, gtins ProductGTINRepository, recepciones RecepcionProveedorRepository, lots LotRepository) *RecordReceptionScanHandler {
+	return &RecordReceptionScanHandler{
+		scans:       scans,
+		gtins:       gtins,
+		recepciones: recepciones,
+		lots:        lots,
+		Clock:       clock.System,
+	}
+}
+
+// Handle parses the barcode and validates it against the reception's
+// purchase order line: the GTIN must be registered to the product ordered,
+// the units scanned across the reception cannot exceed the units it
+// delivered, and a serial number is only scanned once. A scanned batch and
+// expiry date are recorded on the reception's lot. It returns the scan and
+// the lot, or nil when the barcode has no batch.
+func (h *RecordReceptionScanHandler) Handle(ctx context.Context, cmd RecordReceptionScanCommand) (*models.ReceptionScan, *models.Lot, error) {
+	if strings.TrimSpace(cmd.ScannedBy) == "" {
+		return nil, nil, fmt.Errorf("%w: scanned_by is required", ErrInvalidReceptionScan)
+	}
+	barcode, err := gs1.Parse(cmd.Barcode)
+	if err != nil {
+		return nil, nil, err
+	}
+	if barcode.GTIN == "" {
+		return nil, nil, fmt.Errorf("%w: barcode has no GTIN (01)", ErrInvalidReceptionScan)
+	}
+
+	quantity := cmd.Cantidad
+	if quantity == 0 {
+		quantity = max(barcode.Count, 1)
+	}
+	if quantity < 0 {
+		return nil, nil, fmt.Errorf("%w: cantidad must be positive", ErrInvalidReceptionScan)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	recepcion, err := h.recepciones.GetByID(ctx, cmd.RecepcionID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	product, err := h.gtins.GetByGTIN(ctx, barcode.GTIN)
+	if err != nil {
+		return nil, nil, fmt.Errorf("GTIN %s: %w", barcode.GTIN, err)
+	}
+	if product.ProductID != recepcion.ProductoID {
+		return nil, nil, fmt.Errorf("%w: GTIN %s is product %s, the purchase order line is product %s",
+			ErrReceptionScanMismatch, barcode.GTIN, product.ProductID, recepcion.ProductoID)
+	}
+
+	scans, err := h.scans.ListByRecepcionID(ctx, recepcion.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	scanned := 0
+	for _, scan := range scans {
+		scanned += scan.Cantidad
+	}
+	if scanned+quantity > recepcion.Cantidad {
+		return nil, nil, fmt.Errorf("%w: %d units scanned after %d exceed the %d delivered",
+			ErrReceptionScanMismatch, quantity, scanned, recepcion.Cantidad)
+	}
+
+	if barcode.SerialNumber != "" {
+		duplicate, err := h.scans.HasSerialNumber(ctx, barcode.GTIN, barcode.SerialNumber)
+		if err != nil {
+			return nil, nil, err
+		}
+		if duplicate {
+			return nil, nil, fmt.Errorf("%w: GTIN %s serial %s", ErrDuplicateSerialNumber, barcode.GTIN, barcode.SerialNumber)
+		}
+	}
+
+	lot, err := h.applyToLot(ctx, recepcion, barcode)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	scan := &models.ReceptionScan{
+		ID:              uuid.New().String(),
+		RecepcionID:     recepcion.ID,
+		PurchaseOrderID: recepcion.PurchaseOrderID,
+		ProductoID:      recepcion.ProductoID,
+		Barcode:         barcode.String(),
+		GTIN:            barcode.GTIN,
+		BatchNumber:     barcode.BatchNumber,
+		ExpiryDate:      barcode.ExpiryDate,
+		SerialNumber:    barcode.SerialNumber,
+		Cantidad:        quantity,
+		ScannedBy:       cmd.ScannedBy,
+		CreatedAt:       h.Clock.Now(),
+	}
+	if lot != nil {
+		scan.LotID = lot.ID
+	}
+	if err := h.scans.Save(ctx, scan); err != nil {
+		return nil, nil, err
+	}
+
+	return scan, lot, nil
+}
+
+// applyToLot records the scanned batch on the reception's lots. A known
+// batch takes the scanned expiry date when it has none; a delivery the
+// supplier sent without a batch number takes the scanned batch. Any other
+// batch was not delivered with the reception.
+func (h *RecordReceptionScanHandler) applyToLot(ctx context.Context, recepcion *models.RecepcionProveedor, barcode *gs1.Barcode) (*models.Lot, error) {
+	if barcode.BatchNumber == "" {
+		return nil, nil
+	}
+
+	lots, err := h.lots.ListByRecepcionID(ctx, recepcion.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var lot *models.Lot
+	for _, candidate := range lots {
+		if candidate.BatchNumber == barcode.BatchNumber {
+			lot = candidate
+			break
+		}
+	}
+
+	switch {
+	case lot != nil:
+		if barcode.ExpiryDate == nil {
+			return lot, nil
+		}
+		if lot.ExpiryDate != nil {
+			if !sameDay(*lot.ExpiryDate, *barcode.ExpiryDate) {
+				return nil, fmt.Errorf("%w: batch %s expires on %s, not %s as scanned", ErrReceptionScanMismatch,
+					lot.BatchNumber, lot.ExpiryDate.Format("2006-01-02"), barcode.ExpiryDate.Format("2006-01-02"))
+			}
+			return lot, nil
+		}
+	case len(lots) == 1 && models.IsGeneratedBatchNumber(lots[0].BatchNumber):
+		lot = lots[0]
+		lot.BatchNumber = barcode.BatchNumber
+	default:
+		return nil, fmt.Errorf("%w: batch %s was not delivered with the reception", ErrReceptionScanMismatch, barcode.BatchNumber)
+	}
+
+	if barcode.ExpiryDate != nil {
+		lot.ExpiryDate = barcode.ExpiryDate
+	}
+	if err := h.lots.Update(ctx, lot); err != nil {
+		return nil, err
+	}
+	return lot, nil
+}
+
+// sameDay reports whether two dates fall on the same UTC day
+func sameDay(a, b time.Time) bool {
+	return a.UTC().Format("2006-01-02") == b.UTC().Format("2006-01-02")
+}
+
+// ListReceptionScansQuery represents a query to list the scans of a reception
+type ListReceptionScansQuery struct {
+	RecepcionID string `json:"recepcion_id"`
+}
+
+// ListReceptionScansHandler handles the list reception scans query
+type ListReceptionScansHandler struct {
+	scans       ReceptionScanRepository
+	recepciones RecepcionProveedorRepository
+}
+
+// NewListReceptionScansHandler creates a new handler
+func NewListReceptionScansHandler(scans ReceptionScanRepository, recepciones RecepcionProveedorRepository) *ListReceptionScansHandler {
+	return &ListReceptionScansHandler{scans: scans, recepciones: recepciones}
+}
+
+// Handle returns the reception and its scans, oldest first
+func (h *ListReceptionScansHandler) Handle(ctx context.Context, query ListReceptionScansQuery) (*models.RecepcionProveedor, []*models.ReceptionScan, error) {
+	recepcion, err := h.recepciones.GetByID(ctx, query.RecepcionID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	scans, err := h.scans.ListByRecepcionID(ctx, recepcion.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return recepcion, scans, nil
+}
@@ -0,0 +1,238 @@
+package cqrs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"medisupply/clock"
+	"proveedor/internal/models"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrInvalidTemperatureReading is returned for readings without a shipment or sensor
+	ErrInvalidTemperatureReading = errors.New("invalid temperature reading")
+	// ErrUnknownShipment is returned for readings of a shipment no shipment notice announced
+	ErrUnknownShipment = errors.New("unknown shipment")
+)
+
+// TemperatureReadingRepository stores the temperature readings of shipments
+type TemperatureReadingRepository interface {
+	Save(ctx context.Context, reading *models.TemperatureReading) error
+	ListByPurchaseOrderID(ctx context.Context, purchaseOrderID string) ([]*models.TemperatureReading, error)
+}
+
+// InMemoryTemperatureReadingRepository keeps temperature readings in memory
+type InMemoryTemperatureReadingRepository struct {
+	mu       sync.RWMutex
+	readings map[string][]*models.TemperatureReading
+}
+
+// NewInMemoryTemperatureReadingRepository creates a new in-memory repository
+func NewInMemoryTemperatureReadingRepository() *InMemoryTemperatureReadingRepository {
+	return &InMemoryTemperatureReadingRepository{
+		readings: make(map[string][]*models.TemperatureReading),
+	}
+}
+
+// Save stores a reading under its purchase order
+func (r *InMemoryTemperatureReadingRepository) Save(ctx context.Context, reading *models.TemperatureReading) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored := *reading
+	r.readings[reading.PurchaseOrderID] = append(r.readings[reading.PurchaseOrderID], &stored)
+	return nil
+}
+
+// ListByPurchaseOrderID returns the readings of a shipment, oldest first
+func (r *InMemoryTemperatureReadingRepository) ListByPurchaseOrderID(ctx context.Context, purchaseOrderID string) ([]*models.TemperatureReading, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	readings := make([]*models.TemperatureReading, 0, len(r.readings[purchaseOrderID]))
+	for _, reading := range r.readings[purchaseOrderID] {
+		found := *reading
+		readings = append(readings, &found)
+	}
+
+	sort.SliceStable(readings, func(i, j int) bool {
+		return readings[i].RecordedAt.Before(readings[j].RecordedAt)
+	})
+	return readings, nil
+}
+
+// RecordTemperatureReadingCommand represents a sensor reading taken during a
+// shipment. Sensors that do not know the purchase order report the shipment
+// ID instead: the ASN ID or tracking number of its shipment notice.
+type RecordTemperatureReadingCommand struct {
+	PurchaseOrderID string    `json:"purchase_order_id,omitempty"`
+	ShipmentID      string    `json:"shipment_id,omitempty"`
+	SensorID        string    `json:"sensor_id"`
+	Temperature     float64   `json:"temperature"`
+	Humidity        *float64  `json:"humidity,omitempty"`
+	RecordedAt      time.Time `json:"recorded_at"`
+}
+
+// RecordTemperatureReadingHandler stores readings and flags receptions whose
+// shipment left the allowed temperature range
+type RecordTemperatureReadingHandler struct {
+	readings    TemperatureReadingRepository
+	recepciones RecepcionProveedorRepository
+	notices     AdvanceShipmentNoticeRepository
+	allowed     models.TemperatureRange
+	Clock       clock.Clock
+
+	mu sync.Mutex
+	// breached holds the shipments whose latest reading was out of range, so
+	// a breach is reported once per excursion rather than once per reading
+	breached map[string]bool
+}
+
+// NewRecordTemperatureReadingHandler creates a new handler
+func NewRecordTemperatureReadingHandler(readings TemperatureReadingRepository, recepciones RecepcionProveedorRepository, notices AdvanceShipmentNoticeRepository, allowed models.TemperatureRange) *RecordTemperatureReadingHandler {
+	return &RecordTemperatureReadingHandler{
+		readings:    readings,
+		recepciones: recepciones,
+		notices:     notices,
+		allowed:     allowed,
+		Clock:       clock.System,
+		breached:    make(map[string]bool),
+	}
+}
+
+// Range returns the allowed temperature range
+func (h *RecordTemperatureReadingHandler) Range() models.TemperatureRange {
+	return h.allowed
+}
+
+// Handle stores the reading. When it starts an excursion out of range the
+// shipment's reception, if already created, is flagged and a breach event is
+// returned; otherwise the event is nil.
+func (h *RecordTemperatureReadingHandler) Handle(ctx context.Context, cmd RecordTemperatureReadingCommand) (*models.TemperatureReading, *models.TemperatureBreachEvent, error) {
+	if (cmd.PurchaseOrderID == "" && cmd.ShipmentID == "") || cmd.SensorID == "" {
+		return nil, nil, fmt.Errorf("%w: purchase_order_id or shipment_id, and sensor_id are required", ErrInvalidTemperatureReading)
+	}
+
+	purchaseOrderID := cmd.PurchaseOrderID
+	if purchaseOrderID == "" {
+		notice, err := h.notices.GetByShipmentID(ctx, cmd.ShipmentID)
+		if errors.Is(err, ErrAdvanceShipmentNoticeNotFound) {
+			return nil, nil, fmt.Errorf("%w: %s", ErrUnknownShipment, cmd.ShipmentID)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		purchaseOrderID = notice.PurchaseOrderID
+	}
+
+	reading := &models.TemperatureReading{
+		ID:              uuid.New().String(),
+		PurchaseOrderID: purchaseOrderID,
+		ShipmentID:      cmd.ShipmentID,
+		SensorID:        cmd.SensorID,
+		Temperature:     cmd.Temperature,
+		Humidity:        cmd.Humidity,
+		RecordedAt:      cmd.RecordedAt,
+		InRange:         h.allowed.Contains(cmd.Temperature),
+	}
+	if reading.RecordedAt.IsZero() {
+		reading.RecordedAt = h.Clock.Now().UTC()
+	}
+
+	if err := h.readings.Save(ctx, reading); err != nil {
+		return nil, nil, err
+	}
+
+	h.mu.Lock()
+	startsExcursion := !reading.InRange && !h.breached[reading.PurchaseOrderID]
+	if reading.InRange {
+		delete(h.breached, reading.PurchaseOrderID)
+	} else {
+		h.breached[reading.PurchaseOrderID] = true
+	}
+	h.mu.Unlock()
+
+	if !startsExcursion {
+		return reading, nil, nil
+	}
+
+	var recepcionID string
+	recepcion, err := h.recepciones.GetByPurchaseOrderID(ctx, reading.PurchaseOrderID)
+	switch {
+	case err == nil:
+		recepcionID = recepcion.ID
+		if err := h.flag(ctx, recepcion); err != nil {
+			return nil, nil, err
+		}
+	case !errors.Is(err, ErrRecepcionProveedorNotFound):
+		return nil, nil, err
+	}
+
+	return reading, models.NewTemperatureBreachEvent(reading, h.allowed, recepcionID, h.Clock.Now()), nil
+}
+
+// FlagIfBreached flags a newly created reception whose shipment already
+// reported readings out of range while in transit
+func (h *RecordTemperatureReadingHandler) FlagIfBreached(ctx context.Context, recepcion *models.RecepcionProveedor) error {
+	readings, err := h.readings.ListByPurchaseOrderID(ctx, recepcion.PurchaseOrderID)
+	if err != nil {
+		return err
+	}
+
+	for _, reading := range readings {
+		if !reading.InRange {
+			return h.flag(ctx, recepcion)
+		}
+	}
+	return nil
+}
+
+// flag marks the reception as having had a temperature breach
+func (h *RecordTemperatureReadingHandler) flag(ctx context.Context, recepcion *models.RecepcionProveedor) error {
+	if recepcion.TemperatureBreach {
+		return nil
+	}
+
+	recepcion.TemperatureBreach = true
+	recepcion.UpdatedAt = h.Clock.Now()
+	return h.recepciones.Update(ctx, recepcion)
+}
+
+// GetTemperatureReadingsQuery represents a query for the readings of a reception's shipment
+type GetTemperatureReadingsQuery struct {
+	RecepcionID string `json:"recepcion_id"`
+}
+
+// GetTemperatureReadingsHandler handles the get temperature readings query
+type GetTemperatureReadingsHandler struct {
+	readings    TemperatureReadingRepository
+	recepciones RecepcionProveedorRepository
+}
+
+// NewGetTemperatureReadingsHandler creates a new handler
+func NewGetTemperatureReadingsHandler(readings TemperatureReadingRepository, recepciones RecepcionProveedorRepository) *GetTemperatureReadingsHandler {
+	return &GetTemperatureReadingsHandler{readings: readings, recepciones: recepciones}
+}
+
+// Handle returns the reception and the readings of its shipment, oldest first
+func (h *GetTemperatureReadingsHandler) Handle(ctx context.Context, query GetTemperatureReadingsQuery) (*models.RecepcionProveedor, []*models.TemperatureReading, error) {
+	recepcion, err := h.recepciones.GetByID(ctx, query.RecepcionID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if recepcion.PurchaseOrderID == "" {
+		return recepcion, []*models.TemperatureReading{}, nil
+	}
+
+	readings, err := h.readings.ListByPurchaseOrderID(ctx, recepcion.PurchaseOrderID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return recepcion, readings, nil
+}
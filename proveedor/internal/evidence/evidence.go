@@ -0,0 +1,107 @@
+// Package evidence hands out presigned S3 URLs for the photos warehouse
+// staff take of damaged goods, so the photos go straight between the client
+// and the bucket and never through the service.
+package evidence
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+
+	"proveedor/internal/models"
+)
+
+// ErrNotConfigured is returned when no evidence bucket is configured
+var ErrNotConfigured = errors.New("damage evidence uploads are not configured")
+
+// Config represents the settings of the evidence store
+type Config struct {
+	Bucket string
+	Prefix string
+	// URLTTL is how long a presigned upload or download URL stays valid
+	URLTTL time.Duration
+}
+
+// Store presigns uploads and downloads of damage photos. A nil store means
+// photo evidence is disabled; its methods return ErrNotConfigured.
+type Store struct {
+	S3     s3iface.S3API
+	Config Config
+}
+
+// NewStore creates a new evidence store
+func NewStore(s3Client s3iface.S3API, config Config) *Store {
+	config.Prefix = strings.Trim(config.Prefix, "/")
+	return &Store{
+		S3:     s3Client,
+		Config: config,
+	}
+}
+
+// PresignedURL is a URL a client uses to upload or download one photo
+type PresignedURL struct {
+	PhotoID   string    `json:"photo_id"`
+	URL       string    `json:"url"`
+	Method    string    `json:"method"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Key returns the object key of a photo of a reception, e.g.
+// proveedor/damage/<recepcion>/<photo>/<file name>
+func (s *Store) Key(recepcionID string, photo *models.DamagePhoto) string {
+	key := fmt.Sprintf("%s/%s/%s", recepcionID, photo.ID, photo.FileName)
+	if s.Config.Prefix != "" {
+		key = s.Config.Prefix + "/" + key
+	}
+	return key
+}
+
+// PresignUpload returns a URL the client PUTs the photo to. The URL is
+// signed for the declared content type and length, so the upload must send
+// the same Content-Type and Content-Length.
+func (s *Store) PresignUpload(photo *models.DamagePhoto) (*PresignedURL, error) {
+	if s == nil {
+		return nil, ErrNotConfigured
+	}
+
+	req, _ := s.S3.PutObjectRequest(&s3.PutObjectInput{
+		Bucket:        aws.String(s.Config.Bucket),
+		Key:           aws.String(photo.Key),
+		ContentType:   aws.String(photo.ContentType),
+		ContentLength: aws.Int64(photo.Size),
+	})
+	return s.presign(req.Presign, "PUT", photo.ID)
+}
+
+// PresignDownload returns a URL the client GETs the photo from
+func (s *Store) PresignDownload(photo *models.DamagePhoto) (*PresignedURL, error) {
+	if s == nil {
+		return nil, ErrNotConfigured
+	}
+
+	req, _ := s.S3.GetObjectRequest(&s3.GetObjectInput{
+		Bucket: aws.String(s.Config.Bucket),
+		Key:    aws.String(photo.Key),
+	})
+	return s.presign(req.Presign, "GET", photo.ID)
+}
+
+// presign signs a request for the configured URL TTL
+func (s *Store) presign(sign func(time.Duration) (string, error), method, photoID string) (*PresignedURL, error) {
+	expiresAt := time.Now().UTC().Add(s.Config.URLTTL)
+	url, err := sign(s.Config.URLTTL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to presign %s: %w", method, err)
+	}
+	return &PresignedURL{
+		PhotoID:   photoID,
+		URL:       url,
+		Method:    method,
+		ExpiresAt: expiresAt,
+	}, nil
+}
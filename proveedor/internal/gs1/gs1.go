@@ -0,0 +1,354 @@
+// Package gs1 parses the GS1 element strings warehouse scanners read from
+// GS1-128, GS1 DataMatrix and GS1 QR barcodes on delivered goods: the GTIN
+// of the trade item, its batch, expiry date, serial number and count.
+package gs1
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidBarcode is returned for data that is not a valid GS1 element string
+var ErrInvalidBarcode = errors.New("invalid GS1 barcode")
+
+// Application identifiers read into Barcode fields
+const (
+	AIGTIN         = "01"
+	AIBatch        = "10"
+	AIBestBefore   = "15"
+	AIExpiry       = "17"
+	AISerialNumber = "21"
+	AICount        = "30"
+)
+
+// GroupSeparator is the ASCII GS character scanners transmit for FNC1,
+// terminating variable-length elements
+const GroupSeparator = '\x1d'
+
+// symbologyIdentifiers are the prefixes scanners add for GS1 symbols
+var symbologyIdentifiers = []string{"]C1", "]e0", "]d2", "]Q3"}
+
+// applicationIdentifier describes the data of an AI. Fixed-length data needs
+// no separator after it.
+type applicationIdentifier struct {
+	length  int
+	fixed   bool
+	numeric bool
+	date    bool
+}
+
+// applicationIdentifiers are the AIs found on healthcare trade items and
+// logistic units. The four-digit AIs of trade measures, whose last digit is
+// a decimal position, are recognised by measureIdentifier.
+var applicationIdentifiers = map[string]applicationIdentifier{
+	"00":   {length: 18, fixed: true, numeric: true},
+	"01":   {length: 14, fixed: true, numeric: true},
+	"02":   {length: 14, fixed: true, numeric: true},
+	"10":   {length: 20},
+	"11":   {length: 6, fixed: true, numeric: true, date: true},
+	"12":   {length: 6, fixed: true, numeric: true, date: true},
+	"13":   {length: 6, fixed: true, numeric: true, date: true},
+	"15":   {length: 6, fixed: true, numeric: true, date: true},
+	"16":   {length: 6, fixed: true, numeric: true, date: true},
+	"17":   {length: 6, fixed: true, numeric: true, date: true},
+	"20":   {length: 2, fixed: true, numeric: true},
+	"21":   {length: 20},
+	"22":   {length: 20},
+	"240":  {length: 30},
+	"241":  {length: 30},
+	"250":  {length: 30},
+	"30":   {length: 8, numeric: true},
+	"37":   {length: 8, numeric: true},
+	"400":  {length: 30},
+	"410":  {length: 13, fixed: true, numeric: true},
+	"414":  {length: 13, fixed: true, numeric: true},
+	"7003": {length: 10, fixed: true, numeric: true},
+	"710":  {length: 20},
+	"711":  {length: 20},
+	"712":  {length: 20},
+	"713":  {length: 20},
+	"714":  {length: 20},
+}
+
+// measureIdentifier reports whether prefix is the first three digits of a
+// four-digit AI of a trade measure, whose data is six digits
+func measureIdentifier(prefix string) bool {
+	n, err := strconv.Atoi(prefix)
+	return err == nil && len(prefix) == 3 && n >= 310 && n <= 369
+}
+
+// Barcode is the data of a scanned GS1 barcode. Elements holds every element
+// string by AI, including those without a field of their own.
+type Barcode struct {
+	GTIN         string            `json:"gtin,omitempty"`
+	BatchNumber  string            `json:"batch_number,omitempty"`
+	ExpiryDate   *time.Time        `json:"expiry_date,omitempty"`
+	SerialNumber string            `json:"serial_number,omitempty"`
+	Count        int               `json:"count,omitempty"`
+	Elements     map[string]string `json:"elements"`
+}
+
+// Parse parses the data of a GS1 barcode, either as transmitted by a scanner,
+// with an optional symbology identifier and GS characters for FNC1, or in
+// the human readable form with each AI in parentheses. The expiry date is
+// AI 17, or the best before date of AI 15 when there is none.
+func Parse(data string) (*Barcode, error) {
+	data = strings.TrimSpace(data)
+	for _, identifier := range symbologyIdentifiers {
+		if strings.HasPrefix(data, identifier) {
+			data = data[len(identifier):]
+			break
+		}
+	}
+	if data == "" {
+		return nil, fmt.Errorf("%w: no data", ErrInvalidBarcode)
+	}
+
+	var elements map[string]string
+	var err error
+	if strings.HasPrefix(data, "(") {
+		elements, err = parseHumanReadable(data)
+	} else {
+		elements, err = parseElementString(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	barcode := &Barcode{
+		GTIN:         elements[AIGTIN],
+		BatchNumber:  elements[AIBatch],
+		SerialNumber: elements[AISerialNumber],
+		Elements:     elements,
+	}
+	if barcode.GTIN != "" && !validCheckDigit(barcode.GTIN) {
+		return nil, fmt.Errorf("%w: GTIN %s has an invalid check digit", ErrInvalidBarcode, barcode.GTIN)
+	}
+	for _, ai := range []string{AIExpiry, AIBestBefore} {
+		if value, ok := elements[ai]; ok {
+			// Checked when the element was added
+			expiry, _ := parseDate(value)
+			barcode.ExpiryDate = &expiry
+			break
+		}
+	}
+	if value, ok := elements[AICount]; ok {
+		barcode.Count, _ = strconv.Atoi(value)
+	}
+
+	return barcode, nil
+}
+
+// parseElementString parses elements concatenated as encoded in the symbol,
+// with variable-length data terminated by GS unless it is last
+func parseElementString(data string) (map[string]string, error) {
+	elements := make(map[string]string)
+	for len(data) > 0 {
+		if data[0] == GroupSeparator {
+			data = data[1:]
+			continue
+		}
+
+		ai, definition, err := lookup(data)
+		if err != nil {
+			return nil, err
+		}
+		data = data[len(ai):]
+
+		var value string
+		if definition.fixed {
+			if len(data) < definition.length {
+				return nil, fmt.Errorf("%w: AI (%s) needs %d characters", ErrInvalidBarcode, ai, definition.length)
+			}
+			value, data = data[:definition.length], data[definition.length:]
+		} else {
+			end := strings.IndexByte(data, GroupSeparator)
+			if end < 0 {
+				end = len(data)
+			}
+			value, data = data[:end], data[end:]
+		}
+
+		if err := addElement(elements, ai, value, definition); err != nil {
+			return nil, err
+		}
+	}
+	return elements, nil
+}
+
+// parseHumanReadable parses elements written as (AI)data(AI)data
+func parseHumanReadable(data string) (map[string]string, error) {
+	elements := make(map[string]string)
+	for len(data) > 0 {
+		if data[0] != '(' {
+			return nil, fmt.Errorf("%w: expected an AI in parentheses at %q", ErrInvalidBarcode, data)
+		}
+		end := strings.IndexByte(data, ')')
+		if end < 0 {
+			return nil, fmt.Errorf("%w: unterminated AI at %q", ErrInvalidBarcode, data)
+		}
+		ai := data[1:end]
+		data = data[end+1:]
+
+		definition, ok := definitionOf(ai)
+		if !ok {
+			return nil, fmt.Errorf("%w: unsupported AI (%s)", ErrInvalidBarcode, ai)
+		}
+
+		next := strings.IndexByte(data, '(')
+		if next < 0 {
+			next = len(data)
+		}
+		value := data[:next]
+		data = data[next:]
+		if definition.fixed && len(value) != definition.length {
+			return nil, fmt.Errorf("%w: AI (%s) needs %d characters", ErrInvalidBarcode, ai, definition.length)
+		}
+
+		if err := addElement(elements, ai, value, definition); err != nil {
+			return nil, err
+		}
+	}
+	return elements, nil
+}
+
+// lookup returns the AI data starts with
+func lookup(data string) (string, applicationIdentifier, error) {
+	for _, length := range []int{2, 3, 4} {
+		if len(data) < length {
+			break
+		}
+		if definition, ok := definitionOf(data[:length]); ok {
+			return data[:length], definition, nil
+		}
+	}
+
+	prefix := data
+	if len(prefix) > 4 {
+		prefix = prefix[:4]
+	}
+	return "", applicationIdentifier{}, fmt.Errorf("%w: unsupported AI at %q", ErrInvalidBarcode, prefix)
+}
+
+// definitionOf returns the definition of an AI, including the four-digit
+// measure AIs
+func definitionOf(ai string) (applicationIdentifier, bool) {
+	if definition, ok := applicationIdentifiers[ai]; ok {
+		return definition, true
+	}
+	if len(ai) == 4 && measureIdentifier(ai[:3]) && ai[3] >= '0' && ai[3] <= '9' {
+		return applicationIdentifier{length: 6, fixed: true, numeric: true}, true
+	}
+	return applicationIdentifier{}, false
+}
+
+// addElement checks an element's data and adds it, rejecting repeated AIs
+func addElement(elements map[string]string, ai, value string, definition applicationIdentifier) error {
+	if value == "" {
+		return fmt.Errorf("%w: AI (%s) has no data", ErrInvalidBarcode, ai)
+	}
+	if len(value) > definition.length {
+		return fmt.Errorf("%w: AI (%s) has more than %d characters", ErrInvalidBarcode, ai, definition.length)
+	}
+	for _, r := range value {
+		if definition.numeric && (r < '0' || r > '9') {
+			return fmt.Errorf("%w: AI (%s) must be numeric", ErrInvalidBarcode, ai)
+		}
+		if r <= ' ' || r > '~' {
+			return fmt.Errorf("%w: AI (%s) has an invalid character", ErrInvalidBarcode, ai)
+		}
+	}
+	if definition.date {
+		if _, err := parseDate(value); err != nil {
+			return fmt.Errorf("%w: AI (%s): %v", ErrInvalidBarcode, ai, err)
+		}
+	}
+	if previous, ok := elements[ai]; ok && previous != value {
+		return fmt.Errorf("%w: AI (%s) appears twice", ErrInvalidBarcode, ai)
+	}
+	elements[ai] = value
+	return nil
+}
+
+// parseDate parses a YYMMDD date. The century is the one putting the year
+// within 49 years before or 50 years after the current year, and day 00
+// means the last day of the month.
+func parseDate(value string) (time.Time, error) {
+	yy, _ := strconv.Atoi(value[0:2])
+	month, _ := strconv.Atoi(value[2:4])
+	day, _ := strconv.Atoi(value[4:6])
+	if month < 1 || month > 12 {
+		return time.Time{}, fmt.Errorf("invalid month in %s", value)
+	}
+
+	current := time.Now().UTC().Year()
+	year := current/100*100 + yy
+	switch diff := yy - current%100; {
+	case diff >= 51:
+		year -= 100
+	case diff <= -50:
+		year += 100
+	}
+
+	if day == 0 {
+		return time.Date(year, time.Month(month)+1, 0, 0, 0, 0, 0, time.UTC), nil
+	}
+	date := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+	if date.Day() != day {
+		return time.Time{}, fmt.Errorf("invalid day in %s", value)
+	}
+	return date, nil
+}
+
+// NormalizeGTIN returns a GTIN-8, GTIN-12, GTIN-13 or GTIN-14 as the 14
+// digits encoded in AI (01)
+func NormalizeGTIN(gtin string) (string, error) {
+	gtin = strings.TrimSpace(gtin)
+	switch len(gtin) {
+	case 8, 12, 13, 14:
+	default:
+		return "", fmt.Errorf("GTIN must have 8, 12, 13 or 14 digits")
+	}
+	for _, r := range gtin {
+		if r < '0' || r > '9' {
+			return "", fmt.Errorf("GTIN must be numeric")
+		}
+	}
+	if !validCheckDigit(gtin) {
+		return "", fmt.Errorf("GTIN %s has an invalid check digit", gtin)
+	}
+	return strings.Repeat("0", 14-len(gtin)) + gtin, nil
+}
+
+// validCheckDigit checks the GS1 mod 10 check digit of a numeric key: digits
+// are weighted 3 and 1 alternately from the right, excluding the check digit
+func validCheckDigit(key string) bool {
+	sum := 0
+	for i := len(key) - 2; i >= 0; i-- {
+		digit := int(key[i] - '0')
+		if (len(key)-2-i)%2 == 0 {
+			digit *= 3
+		}
+		sum += digit
+	}
+	return (10-sum%10)%10 == int(key[len(key)-1]-'0')
+}
+
+// String returns the barcode in human readable form, AIs in ascending order
+func (b *Barcode) String() string {
+	ais := make([]string, 0, len(b.Elements))
+	for ai := range b.Elements {
+		ais = append(ais, ai)
+	}
+	sort.Strings(ais)
+
+	var s strings.Builder
+	for _, ai := range ais {
+		s.WriteString("(" + ai + ")" + b.Elements[ai])
+	}
+	return s.String()
+}
@@ -0,0 +1,177 @@
+package gs1
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+const gs = string(GroupSeparator)
+
+func TestParse(t *testing.T) {
+	expiry := time.Date(2030, time.December, 25, 0, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		name     string
+		data     string
+		gtin     string
+		batch    string
+		serial   string
+		expiry   *time.Time
+		count    int
+		elements int
+	}{
+		{"fixed length elements", "01095060001343521730122510ABC123", "09506000134352", "ABC123", "", &expiry, 0, 3},
+		{"symbology identifier", "]C101095060001343521730122510ABC123", "09506000134352", "ABC123", "", &expiry, 0, 3},
+		{"variable length batch ended by FNC1", "0109506000134352" + "10ABC123" + gs + "21SN-77" + gs + "30" + "12", "09506000134352", "ABC123", "SN-77", nil, 12, 4},
+		{"FNC1 after the last element", "]d20109506000134352" + "21SN-77" + gs, "09506000134352", "", "SN-77", nil, 0, 2},
+		{"FNC1 after a fixed length element", "0109506000134352" + gs + "10B1", "09506000134352", "B1", "", nil, 0, 2},
+		{"human readable", "(01)09506000134352(17)301225(10)ABC123(21)SN-77", "09506000134352", "ABC123", "SN-77", &expiry, 0, 4},
+		{"best before when there is no expiry", "(01)09506000134352(15)301225", "09506000134352", "", "", &expiry, 0, 2},
+		{"expiry preferred to best before", "(15)291231(17)301225", "", "", "", &expiry, 0, 2},
+		{"day 00 is the last day of the month", "(17)300200", "", "", "", datePtr(2030, time.February, 28), 0, 1},
+		{"trade measure", "(01)09506000134352(3103)001250", "09506000134352", "", "", nil, 0, 2},
+		{"four digit AI", "70031234567890", "", "", "", nil, 0, 1},
+		{"surrounding whitespace", "  (10)LOT-9\n", "", "LOT-9", "", nil, 0, 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			barcode, err := Parse(tc.data)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tc.data, err)
+			}
+			if barcode.GTIN != tc.gtin || barcode.BatchNumber != tc.batch || barcode.SerialNumber != tc.serial || barcode.Count != tc.count {
+				t.Fatalf("Parse(%q) = %+v", tc.data, barcode)
+			}
+			if (barcode.ExpiryDate == nil) != (tc.expiry == nil) || (tc.expiry != nil && !barcode.ExpiryDate.Equal(*tc.expiry)) {
+				t.Fatalf("Parse(%q) expiry %v, want %v", tc.data, barcode.ExpiryDate, tc.expiry)
+			}
+			if len(barcode.Elements) != tc.elements {
+				t.Fatalf("Parse(%q) elements %v, want %d", tc.data, barcode.Elements, tc.elements)
+			}
+		})
+	}
+}
+
+func datePtr(year int, month time.Month, day int) *time.Time {
+	date := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	return &date
+}
+
+func TestParseRejectsMalformedData(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		data string
+		want string
+	}{
+		{"empty", "", "no data"},
+		{"symbology identifier only", "]C1", "no data"},
+		{"wrong check digit", "0109506000134353", "invalid check digit"},
+		{"truncated GTIN", "01095060001343", "needs 14 characters"},
+		{"truncated human readable GTIN", "(01)0950600013435", "needs 14 characters"},
+		{"unsupported AI", "9912345", "unsupported AI"},
+		{"unsupported human readable AI", "(99)12345", "unsupported AI"},
+		{"AI too short to read", "0", "unsupported AI"},
+		{"batch longer than 20", "10" + strings.Repeat("A", 21), "more than 20 characters"},
+		{"empty batch", "10" + gs + "21SN", "has no data"},
+		{"letters in a numeric AI", "30A1", "must be numeric"},
+		{"control character in the data", "10AB\x01C", "invalid character"},
+		{"invalid month", "17301325", "invalid month"},
+		{"invalid day", "17300231", "invalid day"},
+		{"same AI twice with different data", "10A" + gs + "10B", "appears twice"},
+		{"text before the AI", "(01)09506000134352x(10)A", "needs 14 characters"},
+		{"unterminated AI", "(01", "unterminated AI"},
+		{"empty AI", "()12", "unsupported AI ()"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			barcode, err := Parse(tc.data)
+			if !errors.Is(err, ErrInvalidBarcode) || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("Parse(%q) = %+v, %v; want an error containing %q", tc.data, barcode, err, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseTruncatedDataDoesNotPanic(t *testing.T) {
+	for _, data := range []string{
+		"]C10109506000134352" + "17301225" + "10ABC123" + gs + "21SN-77" + gs + "3012" + gs + "3103001250",
+		"(01)09506000134352(17)301225(10)ABC123(21)SN-77(3103)001250",
+	} {
+		for i := 0; i <= len(data); i++ {
+			Parse(data[:i])
+			Parse(data[i:])
+		}
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	for _, seed := range []string{
+		"01095060001343521730122510ABC123",
+		"]C10109506000134352" + "10ABC123" + gs + "21SN-77",
+		"(01)09506000134352(17)301225(10)ABC123",
+		"(3103)001250",
+		"(17)300200",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, data string) {
+		barcode, err := Parse(data)
+		if err != nil && !errors.Is(err, ErrInvalidBarcode) {
+			t.Fatalf("Parse(%q) returned %v, not ErrInvalidBarcode", data, err)
+		}
+		if err == nil && barcode.GTIN != "" && !validCheckDigit(barcode.GTIN) {
+			t.Fatalf("Parse(%q) accepted GTIN %s", data, barcode.GTIN)
+		}
+	})
+}
+
+func TestCheckDigit(t *testing.T) {
+	for _, tc := range []struct {
+		key   string
+		valid bool
+	}{
+		{"09506000134352", true},
+		{"09506000134353", false},
+		{"9506000134352", true},
+		{"036000291452", true},
+		{"036000291453", false},
+		{"96385074", true},
+		{"96385075", false},
+		{"00000000000000", true},
+		{"106141411234567897", true},
+	} {
+		if got := validCheckDigit(tc.key); got != tc.valid {
+			t.Errorf("validCheckDigit(%s) = %v, want %v", tc.key, got, tc.valid)
+		}
+	}
+}
+
+func TestNormalizeGTIN(t *testing.T) {
+	for _, tc := range []struct {
+		gtin string
+		want string
+	}{
+		{"96385074", "00000096385074"},
+		{"036000291452", "00036000291452"},
+		{"9506000134352", "09506000134352"},
+		{" 09506000134352 ", "09506000134352"},
+		{"9506000134353", ""},
+		{"950600013435", ""},
+		{"95060001343A2", ""},
+		{"", ""},
+	} {
+		got, err := NormalizeGTIN(tc.gtin)
+		if got != tc.want || (err == nil) != (tc.want != "") {
+			t.Errorf("NormalizeGTIN(%q) = %q, %v; want %q", tc.gtin, got, err, tc.want)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	barcode, err := Parse("]C1" + "10ABC123" + gs + "0109506000134352" + "17301225")
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if got := barcode.String(); got != "(01)09506000134352(10)ABC123(17)301225" {
+		t.Fatalf("String() = %s", got)
+	}
+}
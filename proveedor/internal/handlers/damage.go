@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+
+	"proveedor/internal/cqrs"
+	"proveedor/internal/evidence"
+	"proveedor/internal/models"
+)
+
+// DamageReportHandler manages reports of damaged goods on receptions
+type DamageReportHandler struct {
+	reportHandler *cqrs.ReportDamageHandler
+	getHandler    *cqrs.GetDamageReportByIDHandler
+	listHandler   *cqrs.ListDamageReportsHandler
+	devoluciones  *DevolucionProveedorHandler
+	recorder      *EventHandler
+	store         *evidence.Store
+}
+
+// NewDamageReportHandler creates a new damage report handler. Returns are
+// opened and published through devoluciones, and DamageReported events are
+// published and logged under their purchase order through recorder. store is nil when
+// photo evidence is disabled.
+func NewDamageReportHandler(repository cqrs.DamageReportRepository, devoluciones *DevolucionProveedorHandler, recorder *EventHandler, store *evidence.Store) *DamageReportHandler {
+	return &DamageReportHandler{
+		reportHandler: cqrs.NewReportDamageHandler(repository, devoluciones.createHandler),
+		getHandler:    cqrs.NewGetDamageReportByIDHandler(repository),
+		listHandler:   cqrs.NewListDamageReportsHandler(repository),
+		devoluciones:  devoluciones,
+		recorder:      recorder,
+		store:         store,
+	}
+}
+
+// ReportDamage reports damaged goods on a reception, which opens a return of
+// the damaged units, and returns a presigned URL to upload each photo to
+func (h *DamageReportHandler) ReportDamage(ctx context.Context, cmd cqrs.ReportDamageCommand) (map[string]interface{}, error) {
+	if len(cmd.Photos) > 0 && h.store == nil {
+		return nil, evidence.ErrNotConfigured
+	}
+
+	// Photo IDs and keys are the service's, whatever the request says
+	for i := range cmd.Photos {
+		photo := &cmd.Photos[i]
+		photo.ID = uuid.New().String()
+		photo.Key = h.store.Key(cmd.RecepcionID, photo)
+	}
+
+	report, devolucion, err := h.reportHandler.Handle(ctx, cmd)
+	if err != nil {
+		log.Printf("Error reporting damage for recepcion proveedor %s: %v", cmd.RecepcionID, err)
+		return nil, err
+	}
+
+	log.Printf("Reported damage %s for recepcion %s: cantidad=%d photos=%d reported_by=%s devolucion_id=%s",
+		report.ID, report.RecepcionID, report.Cantidad, len(report.Photos), report.ReportedBy, report.DevolucionID)
+
+	if err := h.produceDamageReportedEvent(ctx, report); err != nil {
+		return nil, err
+	}
+	if err := h.devoluciones.produceDevolucionProveedorEvent(ctx, devolucion); err != nil {
+		return nil, err
+	}
+
+	uploads := make([]*evidence.PresignedURL, 0, len(report.Photos))
+	for i := range report.Photos {
+		upload, err := h.store.PresignUpload(&report.Photos[i])
+		if err != nil {
+			log.Printf("Error presigning photo upload for damage report %s: %v", report.ID, err)
+			return nil, err
+		}
+		uploads = append(uploads, upload)
+	}
+
+	return map[string]interface{}{
+		"success":       true,
+		"damage_report": report,
+		"devolucion":    devolucion,
+		"uploads":       uploads,
+	}, nil
+}
+
+// GetDamageReport returns one damage report, with a presigned URL to
+// download each photo when photo evidence is configured
+func (h *DamageReportHandler) GetDamageReport(ctx context.Context, id string) (map[string]interface{}, error) {
+	report, err := h.getHandler.Handle(ctx, cqrs.GetDamageReportByIDQuery{ID: id})
+	if err != nil {
+		return nil, err
+	}
+
+	result := map[string]interface{}{
+		"success":       true,
+		"damage_report": report,
+	}
+	if h.store != nil {
+		downloads := make([]*evidence.PresignedURL, 0, len(report.Photos))
+		for i := range report.Photos {
+			download, err := h.store.PresignDownload(&report.Photos[i])
+			if err != nil {
+				log.Printf("Error presigning photo download for damage report %s: %v", report.ID, err)
+				return nil, err
+			}
+			downloads = append(downloads, download)
+		}
+		result["downloads"] = downloads
+	}
+	return result, nil
+}
+
+// ListDamageReports lists damage reports by reception and purchase order
+func (h *DamageReportHandler) ListDamageReports(ctx context.Context, query cqrs.ListDamageReportsQuery) (map[string]interface{}, error) {
+	reports, err := h.listHandler.Handle(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"success":        true,
+		"damage_reports": reports,
+		"count":          len(reports),
+	}, nil
+}
+
+// produceDamageReportedEvent produces a damage reported event for inventory and the returns workflow
+func (h *DamageReportHandler) produceDamageReportedEvent(ctx context.Context, report *models.DamageReport) error {
+	event := models.NewDamageReportedEvent(report, time.Now())
+	if err := h.recorder.produce(ctx, event.PurchaseOrderID, DamageReportedRoutingKey, string(event.EventType), event.ID, event.Timestamp, event); err != nil {
+		return err
+	}
+
+	h.recorder.recordEvent(ctx, event.PurchaseOrderID, string(event.EventType), map[string]interface{}{
+		"event_id":         event.ID,
+		"damage_report_id": event.DamageReportID,
+		"recepcion_id":     event.RecepcionID,
+		"cantidad":         event.Cantidad,
+		"devolucion_id":    event.DevolucionID,
+		"reported_by":      event.ReportedBy,
+		"photos":           len(event.PhotoKeys),
+	}, nil, nil)
+	return nil
+}
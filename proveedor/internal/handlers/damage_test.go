@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"proveedor/internal/cqrs"
+	"proveedor/internal/models"
+)
+
+func TestReportDamagePublishesDamageReportedAndTheReturn(t *testing.T) {
+	ctx := context.Background()
+	h := newTestEventHandler()
+	recepcion := h.receive(t, "po-1", 10, 10)
+	if _, err := h.RecordQualityInspection(ctx, cqrs.RecordQualityInspectionCommand{RecepcionID: recepcion.ID, Result: "pass", InspectorID: "inspector-1"}); err != nil {
+		t.Fatalf("RecordQualityInspection: %v", err)
+	}
+	devoluciones := NewDevolucionProveedorHandler(cqrs.NewInMemoryDevolucionProveedorRepository(), h.recepciones, h.producer)
+	damage := NewDamageReportHandler(cqrs.NewInMemoryDamageReportRepository(), devoluciones, h.EventHandler, nil)
+
+	_, err := damage.ReportDamage(ctx, cqrs.ReportDamageCommand{RecepcionID: recepcion.ID, Cantidad: 2, ReportedBy: "warehouse-1"})
+	if err != nil {
+		t.Fatalf("ReportDamage: %v", err)
+	}
+
+	var event models.DamageReportedEvent
+	if err := json.Unmarshal(h.sender.find(t, DamageReportedRoutingKey).Body, &event); err != nil {
+		t.Fatalf("unmarshal DamageReported: %v", err)
+	}
+	if event.RecepcionID != recepcion.ID || event.PurchaseOrderID != "po-1" || event.Cantidad != 2 || event.DevolucionID == "" {
+		t.Fatalf("DamageReported %+v, want 2 units of %s of po-1 with their return", event, recepcion.ID)
+	}
+
+	var devolucion models.DevolucionProveedorEvent
+	if err := json.Unmarshal(h.sender.find(t, DevolucionProveedorRoutingKey).Body, &devolucion); err != nil {
+		t.Fatalf("unmarshal DevolucionProveedor: %v", err)
+	}
+	if devolucion.DevolucionID != event.DevolucionID {
+		t.Fatalf("DevolucionProveedor for %s, want the damage report's return %s", devolucion.DevolucionID, event.DevolucionID)
+	}
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"proveedor/internal/cqrs"
+	"proveedor/internal/models"
+)
+
+// DevolucionProveedorHandler manages returns of received goods to suppliers
+type DevolucionProveedorHandler struct {
+	createHandler *cqrs.CreateDevolucionProveedorHandler
+	updateHandler *cqrs.UpdateDevolucionProveedorEstadoHandler
+	getHandler    *cqrs.GetDevolucionProveedorByIDHandler
+	listHandler   *cqrs.ListDevolucionProveedorHandler
+	producer      *Producer
+}
+
+// NewDevolucionProveedorHandler creates a new return handler publishing
+// returns through producer
+func NewDevolucionProveedorHandler(repository cqrs.DevolucionProveedorRepository, recepciones cqrs.RecepcionProveedorRepository, producer *Producer) *DevolucionProveedorHandler {
+	return &DevolucionProveedorHandler{
+		createHandler: cqrs.NewCreateDevolucionProveedorHandler(repository, recepciones),
+		updateHandler: cqrs.NewUpdateDevolucionProveedorEstadoHandler(repository),
+		getHandler:    cqrs.NewGetDevolucionProveedorByIDHandler(repository),
+		listHandler:   cqrs.NewListDevolucionProveedorHandler(repository),
+		producer:      producer,
+	}
+}
+
+// CreateDevolucion creates a return and publishes it
+func (h *DevolucionProveedorHandler) CreateDevolucion(ctx context.Context, cmd cqrs.CreateDevolucionProveedorCommand) (map[string]interface{}, error) {
+	devolucion, err := h.createHandler.Handle(ctx, cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Created devolucion proveedor %s for recepcion %s: cantidad=%d motivo=%s", devolucion.ID, devolucion.RecepcionID, devolucion.Cantidad, devolucion.Motivo)
+
+	if err := h.produceDevolucionProveedorEvent(ctx, devolucion); err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"success":    true,
+		"devolucion": devolucion,
+	}, nil
+}
+
+// UpdateEstado moves a return to a new state and publishes the change
+func (h *DevolucionProveedorHandler) UpdateEstado(ctx context.Context, cmd cqrs.UpdateDevolucionProveedorEstadoCommand) (map[string]interface{}, error) {
+	devolucion, err := h.updateHandler.Handle(ctx, cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Devolucion proveedor %s is now %s", devolucion.ID, devolucion.Estado)
+
+	if err := h.produceDevolucionProveedorEvent(ctx, devolucion); err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"success":    true,
+		"devolucion": devolucion,
+	}, nil
+}
+
+// GetDevolucion returns one return
+func (h *DevolucionProveedorHandler) GetDevolucion(ctx context.Context, id string) (map[string]interface{}, error) {
+	devolucion, err := h.getHandler.Handle(ctx, cqrs.GetDevolucionProveedorByIDQuery{ID: id})
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"success":    true,
+		"devolucion": devolucion,
+	}, nil
+}
+
+// ListDevoluciones lists returns by reception, purchase order and estado
+func (h *DevolucionProveedorHandler) ListDevoluciones(ctx context.Context, query cqrs.ListDevolucionProveedorQuery) (map[string]interface{}, error) {
+	devoluciones, err := h.listHandler.Handle(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"success":      true,
+		"devoluciones": devoluciones,
+		"count":        len(devoluciones),
+	}, nil
+}
+
+// produceDevolucionProveedorEvent produces a devolucion proveedor event for inventory and finance
+func (h *DevolucionProveedorHandler) produceDevolucionProveedorEvent(ctx context.Context, devolucion *models.DevolucionProveedor) error {
+	event := models.NewDevolucionProveedorEvent(devolucion, time.Now())
+	return h.producer.produce(ctx, DevolucionProveedorRoutingKey, string(event.EventType), event.ID, event.Timestamp, nil, event)
+}
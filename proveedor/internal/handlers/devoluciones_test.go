@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"proveedor/internal/cqrs"
+	"proveedor/internal/models"
+)
+
+func TestCreateDevolucionPublishesIt(t *testing.T) {
+	ctx := context.Background()
+	h := newTestEventHandler()
+	recepcion := h.receive(t, "po-1", 10, 10)
+	if _, err := h.RecordQualityInspection(ctx, cqrs.RecordQualityInspectionCommand{RecepcionID: recepcion.ID, Result: "pass", InspectorID: "inspector-1"}); err != nil {
+		t.Fatalf("RecordQualityInspection: %v", err)
+	}
+	devoluciones := NewDevolucionProveedorHandler(cqrs.NewInMemoryDevolucionProveedorRepository(), h.recepciones, h.producer)
+
+	cmd := cqrs.CreateDevolucionProveedorCommand{RecepcionID: recepcion.ID, Cantidad: 3, Motivo: models.MotivoDamaged}
+	if _, err := devoluciones.CreateDevolucion(ctx, cmd); err != nil {
+		t.Fatalf("CreateDevolucion: %v", err)
+	}
+
+	var event models.DevolucionProveedorEvent
+	if err := json.Unmarshal(h.sender.find(t, DevolucionProveedorRoutingKey).Body, &event); err != nil {
+		t.Fatalf("unmarshal DevolucionProveedor: %v", err)
+	}
+	if event.RecepcionID != recepcion.ID || event.Cantidad != 3 || event.Estado != models.DevolucionRequested {
+		t.Fatalf("DevolucionProveedor of %d units of %s %s, want 3 units of %s %s",
+			event.Cantidad, event.RecepcionID, event.Estado, recepcion.ID, models.DevolucionRequested)
+	}
+
+	h.sender.err = errors.New("broker unavailable")
+	if _, err := devoluciones.CreateDevolucion(ctx, cmd); !errors.Is(err, h.sender.err) {
+		t.Fatalf("CreateDevolucion error %v, want the publish error", err)
+	}
+}
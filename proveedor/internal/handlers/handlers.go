@@ -3,47 +3,93 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"log"
+	"strings"
 	"time"
 
+	"medisupply/correlation"
+	"medisupply/messaging"
+	"proveedor/internal/codec"
 	"proveedor/internal/cqrs"
 	"proveedor/internal/models"
-
-	"github.com/rabbitmq/amqp091-go"
 )
 
 // EventHandler handles incoming events
 type EventHandler struct {
-	createHandler *cqrs.CreateRecepcionProveedorHandler
-	updateHandler *cqrs.UpdateRecepcionProveedorHandler
+	createHandler      *cqrs.CreateRecepcionProveedorHandler
+	updateHandler      *cqrs.UpdateRecepcionProveedorHandler
+	cancelHandler      *cqrs.CancelRecepcionProveedorHandler
+	inspectionHandler  *cqrs.RecordQualityInspectionHandler
+	temperatureHandler *cqrs.RecordTemperatureReadingHandler
+	lotHandler         *cqrs.CreateLotHandler
+	asnHandler         *cqrs.RecordAdvanceShipmentNoticeHandler
+	applyASNHandler    *cqrs.ApplyAdvanceShipmentNoticeHandler
+	readings           cqrs.TemperatureReadingRepository
+	events             cqrs.EventRepository
+	producer           *Producer
 }
 
-// NewEventHandler creates a new event handler
-func NewEventHandler() *EventHandler {
+// NewEventHandler creates a new event handler storing receptions in repository,
+// shipment temperature readings in readings, received lots in lots and
+// advance shipment notices in notices. Readings outside temperatureRange are
+// reported as breaches. Events produced are published through producer, and
+// events consumed and produced per purchase order are logged in events for
+// flow tracing.
+func NewEventHandler(repository cqrs.RecepcionProveedorRepository, readings cqrs.TemperatureReadingRepository, lots cqrs.LotRepository, notices cqrs.AdvanceShipmentNoticeRepository, events cqrs.EventRepository, temperatureRange models.TemperatureRange, producer *Producer) *EventHandler {
 	return &EventHandler{
-		createHandler: cqrs.NewCreateRecepcionProveedorHandler(),
-		updateHandler: cqrs.NewUpdateRecepcionProveedorHandler(),
+		createHandler:      cqrs.NewCreateRecepcionProveedorHandler(repository),
+		updateHandler:      cqrs.NewUpdateRecepcionProveedorHandler(repository),
+		cancelHandler:      cqrs.NewCancelRecepcionProveedorHandler(repository),
+		inspectionHandler:  cqrs.NewRecordQualityInspectionHandler(repository),
+		temperatureHandler: cqrs.NewRecordTemperatureReadingHandler(readings, repository, notices, temperatureRange),
+		lotHandler:         cqrs.NewCreateLotHandler(lots),
+		asnHandler:         cqrs.NewRecordAdvanceShipmentNoticeHandler(notices),
+		applyASNHandler:    cqrs.NewApplyAdvanceShipmentNoticeHandler(notices, repository),
+		readings:           readings,
+		events:             events,
+		producer:           producer,
 	}
 }
 
 // HandleRecepcionProveedorEvent handles recepcion proveedor events
-func (h *EventHandler) HandleRecepcionProveedorEvent(ctx context.Context, delivery amqp091.Delivery) error {
-	log.Printf("Received recepcion proveedor event: %s", delivery.Body)
+func (h *EventHandler) HandleRecepcionProveedorEvent(ctx context.Context, delivery messaging.Message) error {
+	// The body may carry supplier contact details, so only its envelope is logged
+	log.Printf("Received recepcion proveedor event: message_id=%s content_type=%s size=%d", delivery.MessageID, delivery.ContentType, len(delivery.Body))
 
-	var event models.RecepcionProveedorEvent
-	if err := json.Unmarshal(delivery.Body, &event); err != nil {
+	wireEvent, err := codec.DecodeRecepcionProveedorEvent(delivery.ContentType, delivery.Body)
+	if err != nil {
 		log.Printf("Error unmarshaling event: %v", err)
 		return err
 	}
 
+	// Map either field naming onto the canonical event
+	event, err := wireEvent.Normalize()
+	if err != nil {
+		log.Printf("Error normalizing event %s: %v", wireEvent.ID, err)
+		return err
+	}
+
 	switch event.Type {
-	case "RecepcionProveedorCreated":
+	case models.ReceptionCreatedType:
+		reason, cancelled, err := h.cancelHandler.IsCancelled(ctx, event.PurchaseOrderID)
+		if err != nil {
+			log.Printf("Error checking cancellation of purchase order %s: %v", event.PurchaseOrderID, err)
+			return err
+		}
+		if cancelled {
+			log.Printf("Skipping reception for cancelled purchase order %s: %s", event.PurchaseOrderID, reason)
+			return nil
+		}
+
 		cmd := cqrs.CreateRecepcionProveedorCommand{
-			ProveedorID:    event.ProveedorID,
-			ProductoID:     event.ProductoID,
-			Cantidad:       event.Cantidad,
-			FechaRecepcion: event.FechaRecepcion,
-			Estado:         event.Estado,
+			PurchaseOrderID:  event.PurchaseOrderID,
+			ProveedorID:      event.SupplierID,
+			ProductoID:       event.ProductID,
+			Cantidad:         event.Quantity,
+			CantidadRecibida: event.ReceivedQuantity,
+			FechaRecepcion:   event.ReceptionDate,
+			Estado:           event.Status,
 		}
 
 		recepcion, err := h.createHandler.Handle(ctx, cmd)
@@ -52,15 +98,51 @@ func (h *EventHandler) HandleRecepcionProveedorEvent(ctx context.Context, delive
 			return err
 		}
 
-		log.Printf("Created recepcion proveedor: %s", recepcion.ID)
+		h.recordConsumedEvent(ctx, event, map[string]interface{}{
+			"recepcion_id": recepcion.ID,
+			"cantidad":     recepcion.Cantidad,
+			"estado":       recepcion.Estado,
+		})
+
+		// The supplier's shipment notice fills in what the delivery left out
+		notice, err := h.applyASNHandler.Handle(ctx, recepcion)
+		if err != nil {
+			log.Printf("Error applying shipment notice to recepcion proveedor %s: %v", recepcion.ID, err)
+			return err
+		}
+
+		for _, cmd := range lotCommands(recepcion, event, notice) {
+			lot, err := h.lotHandler.Handle(ctx, cmd)
+			if err != nil {
+				log.Printf("Error creating lot for recepcion proveedor %s: %v", recepcion.ID, err)
+				return err
+			}
 
-		// Produce InventarioRecibido event
-		return h.produceInventarioRecibidoEvent(ctx, recepcion)
+			log.Printf("Recorded lot %s (batch %s) for recepcion proveedor %s", lot.ID, lot.BatchNumber, recepcion.ID)
+		}
 
-	case "RecepcionProveedorUpdated":
+		// Breaches reported while the shipment was in transit flag the reception
+		if err := h.temperatureHandler.FlagIfBreached(ctx, recepcion); err != nil {
+			log.Printf("Error checking temperature readings of recepcion proveedor %s: %v", recepcion.ID, err)
+			return err
+		}
+
+		// InventarioRecibido is produced once the reception passes inspection
+		log.Printf("Created recepcion proveedor: %s (awaiting quality inspection, delivered %d of %d, %d outstanding)",
+			recepcion.ID, recepcion.Cantidad, recepcion.CantidadOrdenada, recepcion.CantidadPendiente)
+
+		// Short and over deliveries are reported now; the delivered quantity
+		// still goes through inspection to InventarioRecibido like any other
+		if recepcion.QuantityDiscrepancy() != "" {
+			if err := h.produceQuantityDiscrepancyEvent(ctx, models.NewQuantityDiscrepancyEvent(recepcion, time.Now())); err != nil {
+				return err
+			}
+		}
+
+	case models.ReceptionUpdatedType:
 		cmd := cqrs.UpdateRecepcionProveedorCommand{
 			ID:     event.ID,
-			Estado: event.Estado,
+			Estado: event.Status,
 		}
 
 		if err := h.updateHandler.Handle(ctx, cmd); err != nil {
@@ -68,8 +150,50 @@ func (h *EventHandler) HandleRecepcionProveedorEvent(ctx context.Context, delive
 			return err
 		}
 
+		h.recordConsumedEvent(ctx, event, map[string]interface{}{
+			"recepcion_id": event.ID,
+			"estado":       event.Status,
+		})
 		log.Printf("Updated recepcion proveedor: %s", event.ID)
 
+	case models.ReceptionCancelledType:
+		cmd := cqrs.CancelRecepcionProveedorCommand{
+			PurchaseOrderID: event.PurchaseOrderID,
+			Reason:          event.Reason,
+		}
+
+		cancelled, err := h.cancelHandler.Handle(ctx, cmd)
+		// Receptions already received or rejected stay as they are; retrying
+		// cannot change that, so the cancellation is not redelivered
+		if errors.Is(err, cqrs.ErrRecepcionProveedorConflict) {
+			log.Printf("WARN: Receptions of cancelled purchase order %s kept their state: %v", event.PurchaseOrderID, err)
+		} else if err != nil {
+			log.Printf("Error cancelling recepcion proveedor: %v", err)
+			return err
+		}
+
+		cancelledIDs := make([]string, 0, len(cancelled))
+		for _, recepcion := range cancelled {
+			cancelledIDs = append(cancelledIDs, recepcion.ID)
+		}
+		h.recordConsumedEvent(ctx, event, map[string]interface{}{
+			"reason":                event.Reason,
+			"cancelled_recepciones": cancelledIDs,
+		})
+		log.Printf("Stopped reception for cancelled purchase order: %s (reason: %s, cancelled receptions: %d)", event.PurchaseOrderID, event.Reason, len(cancelled))
+
+	case models.QualityInspectionType:
+		cmd := cqrs.RecordQualityInspectionCommand{
+			RecepcionID: event.ID,
+			Result:      event.Status,
+			Notes:       event.Notes,
+			InspectorID: event.InspectorID,
+		}
+
+		if _, err := h.RecordQualityInspection(ctx, cmd); err != nil {
+			return err
+		}
+
 	default:
 		log.Printf("Unknown event type: %s", event.Type)
 	}
@@ -77,21 +201,296 @@ func (h *EventHandler) HandleRecepcionProveedorEvent(ctx context.Context, delive
 	return nil
 }
 
+// HandleAdvanceShipmentNotice handles an ASN forwarded by OrdenCompra so the
+// warehouse knows what is coming before the reception arrives
+func (h *EventHandler) HandleAdvanceShipmentNotice(ctx context.Context, delivery messaging.Message) error {
+	var event models.AdvanceShipmentNoticeEvent
+	if err := json.Unmarshal(delivery.Body, &event); err != nil {
+		log.Printf("Error unmarshaling advance shipment notice: %v", err)
+		return err
+	}
+
+	reason, cancelled, err := h.cancelHandler.IsCancelled(ctx, event.PurchaseOrderID)
+	if err != nil {
+		log.Printf("Error checking cancellation of purchase order %s: %v", event.PurchaseOrderID, err)
+		return err
+	}
+	if cancelled {
+		log.Printf("Skipping shipment notice for cancelled purchase order %s: %s", event.PurchaseOrderID, reason)
+		return nil
+	}
+
+	notice, err := h.asnHandler.Handle(ctx, &event)
+	if err != nil {
+		log.Printf("Error recording advance shipment notice: %v", err)
+		return err
+	}
+
+	h.recordEvent(ctx, notice.PurchaseOrderID, string(models.AdvanceShipmentNoticeEventType), map[string]interface{}{
+		"event_id":               event.ID,
+		"asn_id":                 notice.ID,
+		"expected_delivery_date": notice.ExpectedDeliveryDate,
+		"tracking_number":        notice.TrackingNumber,
+	}, correlation.FromMetadata(event.Metadata, correlation.CorrelationIDKey), correlation.FromMetadata(event.Metadata, correlation.CausationIDKey))
+
+	log.Printf("Expecting shipment for purchase order %s: carrier=%s tracking_number=%s expected_delivery_date=%s lots=%d",
+		notice.PurchaseOrderID, notice.Carrier, notice.TrackingNumber, notice.ExpectedDeliveryDate.Format(time.RFC3339), len(notice.Lots))
+	return nil
+}
+
+// HandleTemperatureReading handles a cold-chain sensor reading. Readings are
+// JSON, published on AMQP, by MQTT sensors through the RabbitMQ MQTT plugin,
+// or on the MQTT broker proveedor subscribes to. Readings naming neither
+// their purchase order nor their shipment take the shipment ID from a topic
+// such as cold-chain/shipments/<shipment_id>/telemetry.
+func (h *EventHandler) HandleTemperatureReading(ctx context.Context, delivery messaging.Message) error {
+	var cmd cqrs.RecordTemperatureReadingCommand
+	if err := json.Unmarshal(delivery.Body, &cmd); err != nil {
+		log.Printf("Error unmarshaling temperature reading: %v", err)
+		return err
+	}
+	if cmd.PurchaseOrderID == "" && cmd.ShipmentID == "" {
+		cmd.ShipmentID = shipmentIDFromTopic(delivery.Topic)
+	}
+
+	reading, breach, err := h.temperatureHandler.Handle(ctx, cmd)
+	if err != nil {
+		log.Printf("Error recording temperature reading: %v", err)
+		return err
+	}
+
+	if breach == nil {
+		return nil
+	}
+
+	allowed := h.temperatureHandler.Range()
+	log.Printf("WARN: Temperature breach for purchase order %s: sensor_id=%s temperature=%.2f allowed=[%.2f, %.2f]",
+		reading.PurchaseOrderID, reading.SensorID, reading.Temperature, allowed.Min, allowed.Max)
+	return h.produceTemperatureBreachEvent(ctx, breach)
+}
+
+// shipmentIDFromTopic returns the topic level following "shipments", with
+// MQTT topic levels separated by slashes or, once mapped to RabbitMQ routing
+// keys, by dots
+func shipmentIDFromTopic(topic string) string {
+	levels := strings.FieldsFunc(topic, func(r rune) bool { return r == '/' || r == '.' })
+	for i := 0; i < len(levels)-1; i++ {
+		if levels[i] == "shipments" {
+			return levels[i+1]
+		}
+	}
+	return ""
+}
+
+// RecordQualityInspection records an inspection received through the API or
+// as an event. Passed receptions produce InventarioRecibido unless their
+// purchase order was cancelled meanwhile; failed ones produce QualityCheckFailed.
+func (h *EventHandler) RecordQualityInspection(ctx context.Context, cmd cqrs.RecordQualityInspectionCommand) (*models.RecepcionProveedor, error) {
+	recepcion, err := h.inspectionHandler.Handle(ctx, cmd)
+	if err != nil {
+		log.Printf("Error recording quality inspection for recepcion proveedor %s: %v", cmd.RecepcionID, err)
+		return nil, err
+	}
+
+	inspection := recepcion.QualityInspection
+	h.recordEvent(ctx, recepcion.PurchaseOrderID, models.QualityInspectionType, map[string]interface{}{
+		"recepcion_id": recepcion.ID,
+		"result":       inspection.Result,
+		"inspector_id": inspection.InspectorID,
+	}, nil, nil)
+	log.Printf("Recorded quality inspection for recepcion proveedor %s: result=%s inspector_id=%s", recepcion.ID, inspection.Result, inspection.InspectorID)
+
+	switch inspection.Result {
+	case models.QualityPassed:
+		reason, cancelled, err := h.cancelHandler.IsCancelled(ctx, recepcion.PurchaseOrderID)
+		if err != nil {
+			return nil, err
+		}
+		if cancelled {
+			log.Printf("Skipping InventarioRecibido for cancelled purchase order %s: %s", recepcion.PurchaseOrderID, reason)
+			return recepcion, nil
+		}
+		if err := h.produceInventarioRecibidoEvent(ctx, recepcion); err != nil {
+			return nil, err
+		}
+	case models.QualityFailed:
+		if err := h.produceQualityCheckFailedEvent(ctx, recepcion); err != nil {
+			return nil, err
+		}
+	}
+
+	return recepcion, nil
+}
+
 // produceInventarioRecibidoEvent produces an inventario recibido event
 func (h *EventHandler) produceInventarioRecibidoEvent(ctx context.Context, recepcion *models.RecepcionProveedor) error {
-	// TODO: Implement RabbitMQ producer
-	// This would connect to RabbitMQ and publish the InventarioRecibido event
-
 	event := models.InventarioRecibidoEvent{
-		ID:             recepcion.ID,
-		ProveedorID:    recepcion.ProveedorID,
-		ProductoID:     recepcion.ProductoID,
-		Cantidad:       recepcion.Cantidad,
-		FechaRecepcion: recepcion.FechaRecepcion,
-		Estado:         recepcion.Estado,
-		Timestamp:      time.Now(),
+		ID:                recepcion.ID,
+		PurchaseOrderID:   recepcion.PurchaseOrderID,
+		ProveedorID:       recepcion.ProveedorID,
+		ProductoID:        recepcion.ProductoID,
+		Cantidad:          recepcion.Cantidad,
+		FechaRecepcion:    recepcion.FechaRecepcion,
+		Estado:            recepcion.Estado,
+		QualityCheck:      string(models.QualityPassed),
+		TemperatureBreach: recepcion.TemperatureBreach,
+		Timestamp:         time.Now(),
+	}
+
+	// Report the latest sensor reading of the shipment
+	if recepcion.PurchaseOrderID != "" {
+		readings, err := h.readings.ListByPurchaseOrderID(ctx, recepcion.PurchaseOrderID)
+		if err != nil {
+			return err
+		}
+		if len(readings) > 0 {
+			temperature := readings[len(readings)-1].Temperature
+			event.Temperature = &temperature
+		}
+	}
+
+	if err := h.produce(ctx, recepcion.PurchaseOrderID, InventarioRecibidoRoutingKey, string(models.InventoryReceivedEventType), event.ID, event.Timestamp, event); err != nil {
+		return err
+	}
+
+	h.recordEvent(ctx, recepcion.PurchaseOrderID, string(models.InventoryReceivedEventType), map[string]interface{}{
+		"event_id":           event.ID,
+		"recepcion_id":       recepcion.ID,
+		"cantidad":           event.Cantidad,
+		"temperature_breach": event.TemperatureBreach,
+	}, nil, nil)
+	return nil
+}
+
+// produceQualityCheckFailedEvent produces a quality check failed event
+func (h *EventHandler) produceQualityCheckFailedEvent(ctx context.Context, recepcion *models.RecepcionProveedor) error {
+	event := models.NewQualityCheckFailedEvent(recepcion, time.Now())
+	if err := h.produce(ctx, event.PurchaseOrderID, QualityCheckFailedRoutingKey, string(event.EventType), event.ID, event.Timestamp, event); err != nil {
+		return err
+	}
+
+	h.recordEvent(ctx, event.PurchaseOrderID, string(event.EventType), map[string]interface{}{
+		"event_id":     event.ID,
+		"recepcion_id": event.RecepcionID,
+		"reason":       event.Reason,
+	}, nil, nil)
+	return nil
+}
+
+// produceTemperatureBreachEvent produces a temperature breach event
+func (h *EventHandler) produceTemperatureBreachEvent(ctx context.Context, event *models.TemperatureBreachEvent) error {
+	if err := h.produce(ctx, event.PurchaseOrderID, TemperatureBreachRoutingKey, string(event.EventType), event.ID, event.Timestamp, event); err != nil {
+		return err
+	}
+
+	h.recordEvent(ctx, event.PurchaseOrderID, string(event.EventType), map[string]interface{}{
+		"event_id":     event.ID,
+		"recepcion_id": event.RecepcionID,
+		"temperature":  event.Temperature,
+	}, nil, nil)
+	return nil
+}
+
+// produceQuantityDiscrepancyEvent produces a quantity discrepancy event
+func (h *EventHandler) produceQuantityDiscrepancyEvent(ctx context.Context, event *models.QuantityDiscrepancyEvent) error {
+	if err := h.produce(ctx, event.PurchaseOrderID, QuantityDiscrepancyRoutingKey, string(event.EventType), event.ID, event.Timestamp, event); err != nil {
+		return err
 	}
 
-	log.Printf("Would produce InventarioRecibido event: %+v", event)
+	h.recordEvent(ctx, event.PurchaseOrderID, string(event.EventType), map[string]interface{}{
+		"event_id":           event.ID,
+		"recepcion_id":       event.RecepcionID,
+		"kind":               event.Kind,
+		"remaining_quantity": event.RemainingQuantity,
+		"excess_quantity":    event.ExcessQuantity,
+	}, nil, nil)
+	log.Printf("WARN: Quantity discrepancy on purchase order %s: kind=%s delivered=%d ordered=%d remaining=%d excess=%d",
+		event.PurchaseOrderID, event.Kind, event.DeliveredQuantity, event.OrderedQuantity, event.RemainingQuantity, event.ExcessQuantity)
 	return nil
 }
+
+// produce publishes an event of a purchase order with the correlation of
+// the order's latest consumed event
+func (h *EventHandler) produce(ctx context.Context, purchaseOrderID, routingKey, eventType, messageID string, timestamp time.Time, event interface{}) error {
+	var correlationID *string
+	if purchaseOrderID != "" {
+		correlationID = h.correlationID(ctx, purchaseOrderID)
+	}
+	return h.producer.produce(ctx, routingKey, eventType, messageID, timestamp, correlationID, event)
+}
+
+// recordConsumedEvent logs a reception event from OrdenCompra under its
+// purchase order, keeping the correlation it arrived with
+func (h *EventHandler) recordConsumedEvent(ctx context.Context, event *models.ReceptionEvent, data map[string]interface{}) {
+	data["event_id"] = event.ID
+	h.recordEvent(ctx, event.PurchaseOrderID, event.Type, data, correlation.FromMetadata(event.Metadata, correlation.CorrelationIDKey), correlation.FromMetadata(event.Metadata, correlation.CausationIDKey))
+}
+
+// recordEvent appends an event to the log of a purchase order. Events produced
+// by proveedor inherit the correlation of the order's latest consumed event.
+// The log only serves tracing, so failures are logged rather than returned.
+func (h *EventHandler) recordEvent(ctx context.Context, purchaseOrderID, eventType string, data map[string]interface{}, correlationID, causationID *string) {
+	if purchaseOrderID == "" {
+		return
+	}
+
+	if correlationID == nil {
+		correlationID = h.correlationID(ctx, purchaseOrderID)
+	}
+
+	event := models.NewEventSourcingEvent(purchaseOrderID, eventType, data, correlationID, causationID, time.Now())
+	if err := h.events.Append(ctx, event); err != nil {
+		log.Printf("Error logging %s event for purchase order %s: %v", eventType, purchaseOrderID, err)
+	}
+}
+
+// correlationID returns the correlation ID of the latest logged event of a
+// purchase order that carried one, or nil
+func (h *EventHandler) correlationID(ctx context.Context, purchaseOrderID string) *string {
+	events, err := h.events.ListByAggregateID(ctx, purchaseOrderID)
+	if err != nil {
+		return nil
+	}
+	for i := len(events) - 1; i >= 0; i-- {
+		if events[i].CorrelationID != nil {
+			return events[i].CorrelationID
+		}
+	}
+	return nil
+}
+
+// lotCommands returns the lots received with a reception. Deliveries without
+// a batch number take the lots announced in the shipment notice, and a known
+// batch takes its announced expiry date when the delivery has none.
+func lotCommands(recepcion *models.RecepcionProveedor, event *models.ReceptionEvent, notice *models.AdvanceShipmentNotice) []cqrs.CreateLotCommand {
+	base := cqrs.CreateLotCommand{
+		RecepcionID:     recepcion.ID,
+		PurchaseOrderID: event.PurchaseOrderID,
+		ProductID:       event.ProductID,
+		SupplierID:      event.SupplierID,
+		Quantity:        recepcion.Cantidad,
+		BatchNumber:     event.BatchNumber,
+		ExpiryDate:      event.ExpiryDate,
+	}
+
+	if notice == nil {
+		return []cqrs.CreateLotCommand{base}
+	}
+
+	if base.BatchNumber == "" && len(notice.Lots) > 0 {
+		commands := make([]cqrs.CreateLotCommand, 0, len(notice.Lots))
+		for _, lot := range notice.Lots {
+			cmd := base
+			cmd.BatchNumber = lot.BatchNumber
+			cmd.Quantity = lot.Quantity
+			cmd.ExpiryDate = lot.ExpiryDate
+			commands = append(commands, cmd)
+		}
+		return commands
+	}
+
+	if base.ExpiryDate == nil {
+		base.ExpiryDate = notice.LotExpiryDate(base.BatchNumber)
+	}
+	return []cqrs.CreateLotCommand{base}
+}
@@ -0,0 +1,461 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rabbitmq/amqp091-go"
+
+	"medisupply/messaging"
+	"proveedor/internal/cqrs"
+	"proveedor/internal/models"
+)
+
+// recordingSender records the messages published instead of sending them,
+// or fails every publish with err
+type recordingSender struct {
+	mu        sync.Mutex
+	published []publishedMessage
+	err       error
+}
+
+type publishedMessage struct {
+	exchange   string
+	routingKey string
+	msg        amqp091.Publishing
+}
+
+func (s *recordingSender) Publish(ctx context.Context, exchange, routingKey string, msg amqp091.Publishing) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.published = append(s.published, publishedMessage{exchange: exchange, routingKey: routingKey, msg: msg})
+	return nil
+}
+
+// routingKeys returns the routing keys of the messages published, in order
+func (s *recordingSender) routingKeys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.routingKeysLocked()
+}
+
+// find returns the last message published under routingKey
+func (s *recordingSender) find(t *testing.T, routingKey string) amqp091.Publishing {
+	t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := len(s.published) - 1; i >= 0; i-- {
+		if s.published[i].routingKey == routingKey {
+			if s.published[i].exchange != DefaultEventsExchange {
+				t.Fatalf("%s published to exchange %q, want %q", routingKey, s.published[i].exchange, DefaultEventsExchange)
+			}
+			return s.published[i].msg
+		}
+	}
+	t.Fatalf("nothing published under %s; published %v", routingKey, s.routingKeysLocked())
+	return amqp091.Publishing{}
+}
+
+func (s *recordingSender) routingKeysLocked() []string {
+	keys := make([]string, 0, len(s.published))
+	for _, published := range s.published {
+		keys = append(keys, published.routingKey)
+	}
+	return keys
+}
+
+// testEventHandler is an event handler on in-memory stores publishing to sender
+type testEventHandler struct {
+	*EventHandler
+	recepciones cqrs.RecepcionProveedorRepository
+	lots        cqrs.LotRepository
+	notices     cqrs.AdvanceShipmentNoticeRepository
+	events      cqrs.EventRepository
+	sender      *recordingSender
+}
+
+func newTestEventHandler() *testEventHandler {
+	sender := &recordingSender{}
+	recepciones := cqrs.NewInMemoryRecepcionProveedorRepository()
+	lots := cqrs.NewInMemoryLotRepository()
+	notices := cqrs.NewInMemoryAdvanceShipmentNoticeRepository()
+	events := cqrs.NewInMemoryEventRepository()
+	h := NewEventHandler(
+		recepciones,
+		cqrs.NewInMemoryTemperatureReadingRepository(),
+		lots,
+		notices,
+		events,
+		models.TemperatureRange{Min: 2, Max: 8},
+		NewProducer(sender, DefaultEventsExchange),
+	)
+	return &testEventHandler{EventHandler: h, recepciones: recepciones, lots: lots, notices: notices, events: events, sender: sender}
+}
+
+// deliver handles a reception event as delivered by OrdenCompra
+func (h *testEventHandler) deliver(t *testing.T, event *models.ReceptionEvent) error {
+	t.Helper()
+	body, err := json.Marshal(event.Wire())
+	if err != nil {
+		t.Fatalf("marshal event: %v", err)
+	}
+	return h.HandleRecepcionProveedorEvent(context.Background(), messaging.Message{
+		Body:        body,
+		ContentType: "application/json",
+		MessageID:   event.ID,
+	})
+}
+
+// receive delivers delivered of the units ordered by purchaseOrderID and
+// returns the reception created
+func (h *testEventHandler) receive(t *testing.T, purchaseOrderID string, ordered, delivered int) *models.RecepcionProveedor {
+	t.Helper()
+	err := h.deliver(t, &models.ReceptionEvent{
+		ID:               fmt.Sprintf("event-%s-%d", purchaseOrderID, delivered),
+		Type:             models.ReceptionCreatedType,
+		Timestamp:        time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC),
+		PurchaseOrderID:  purchaseOrderID,
+		ProductID:        "product-1",
+		SupplierID:       "supplier-1",
+		Quantity:         ordered,
+		ReceivedQuantity: delivered,
+		BatchNumber:      "LOT-1",
+	})
+	if err != nil {
+		t.Fatalf("deliver reception: %v", err)
+	}
+
+	recepciones, err := h.recepciones.ListByPurchaseOrderID(context.Background(), purchaseOrderID)
+	if err != nil || len(recepciones) == 0 {
+		t.Fatalf("reception of %s not stored: %v", purchaseOrderID, err)
+	}
+	return recepciones[len(recepciones)-1]
+}
+
+func TestFailedInspectionPublishesQualityCheckFailed(t *testing.T) {
+	h := newTestEventHandler()
+	recepcion := h.receive(t, "po-1", 10, 10)
+
+	_, err := h.RecordQualityInspection(context.Background(), cqrs.RecordQualityInspectionCommand{
+		RecepcionID: recepcion.ID,
+		Result:      "fail",
+		Notes:       "broken seals",
+		InspectorID: "inspector-1",
+	})
+	if err != nil {
+		t.Fatalf("RecordQualityInspection: %v", err)
+	}
+
+	msg := h.sender.find(t, QualityCheckFailedRoutingKey)
+	var event models.QualityCheckFailedEvent
+	if err := json.Unmarshal(msg.Body, &event); err != nil {
+		t.Fatalf("unmarshal QualityCheckFailed: %v", err)
+	}
+	if event.RecepcionID != recepcion.ID || event.PurchaseOrderID != "po-1" {
+		t.Fatalf("QualityCheckFailed for recepcion %s of %s, want %s of po-1", event.RecepcionID, event.PurchaseOrderID, recepcion.ID)
+	}
+	if msg.MessageId != event.ID {
+		t.Fatalf("message ID %q, want the event ID %q", msg.MessageId, event.ID)
+	}
+	if msg.Headers["event-type"] != string(models.QualityCheckFailedEventType) {
+		t.Fatalf("event-type header %v, want %s", msg.Headers["event-type"], models.QualityCheckFailedEventType)
+	}
+	for _, key := range h.sender.routingKeys() {
+		if key == InventarioRecibidoRoutingKey {
+			t.Fatalf("InventarioRecibido published for a reception failing inspection")
+		}
+	}
+}
+
+func TestFailedPublishFailsInspection(t *testing.T) {
+	h := newTestEventHandler()
+	recepcion := h.receive(t, "po-1", 10, 10)
+
+	h.sender.err = errors.New("broker unavailable")
+	_, err := h.RecordQualityInspection(context.Background(), cqrs.RecordQualityInspectionCommand{
+		RecepcionID: recepcion.ID,
+		Result:      "fail",
+		InspectorID: "inspector-1",
+	})
+	if err == nil || !errors.Is(err, h.sender.err) {
+		t.Fatalf("RecordQualityInspection error %v, want the publish error", err)
+	}
+}
+
+func TestTemperatureBreachIsPublished(t *testing.T) {
+	h := newTestEventHandler()
+	body, _ := json.Marshal(cqrs.RecordTemperatureReadingCommand{
+		PurchaseOrderID: "po-1",
+		SensorID:        "logger-1",
+		Temperature:     9.4,
+		RecordedAt:      time.Date(2026, 3, 2, 10, 15, 0, 0, time.UTC),
+	})
+
+	if err := h.HandleTemperatureReading(context.Background(), messaging.Message{Body: body}); err != nil {
+		t.Fatalf("HandleTemperatureReading: %v", err)
+	}
+
+	var event models.TemperatureBreachEvent
+	if err := json.Unmarshal(h.sender.find(t, TemperatureBreachRoutingKey).Body, &event); err != nil {
+		t.Fatalf("unmarshal TemperatureBreach: %v", err)
+	}
+	if event.PurchaseOrderID != "po-1" || event.Temperature != 9.4 {
+		t.Fatalf("TemperatureBreach of %s at %.2f, want po-1 at 9.40", event.PurchaseOrderID, event.Temperature)
+	}
+}
+
+func TestQuantityDiscrepancies(t *testing.T) {
+	tests := []struct {
+		name      string
+		delivered int
+		kind      string
+		remaining int
+		excess    int
+	}{
+		{name: "partial", delivered: 4, kind: models.DiscrepancyShort, remaining: 6},
+		{name: "exact", delivered: 10},
+		{name: "over", delivered: 12, kind: models.DiscrepancyOver, excess: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestEventHandler()
+			recepcion := h.receive(t, "po-1", 10, tt.delivered)
+
+			if tt.kind == "" {
+				for _, key := range h.sender.routingKeys() {
+					if key == QuantityDiscrepancyRoutingKey {
+						t.Fatalf("QuantityDiscrepancy published for an exact delivery")
+					}
+				}
+			} else {
+				var event models.QuantityDiscrepancyEvent
+				if err := json.Unmarshal(h.sender.find(t, QuantityDiscrepancyRoutingKey).Body, &event); err != nil {
+					t.Fatalf("unmarshal QuantityDiscrepancy: %v", err)
+				}
+				if event.Kind != tt.kind || event.OrderedQuantity != 10 || event.DeliveredQuantity != tt.delivered ||
+					event.RemainingQuantity != tt.remaining || event.ExcessQuantity != tt.excess {
+					t.Fatalf("QuantityDiscrepancy %+v, want %s of %d ordered 10 remaining %d excess %d",
+						event, tt.kind, tt.delivered, tt.remaining, tt.excess)
+				}
+			}
+
+			// Whatever the discrepancy, the delivery goes on to inspection
+			// and is reported as received once it passes
+			_, err := h.RecordQualityInspection(context.Background(), cqrs.RecordQualityInspectionCommand{
+				RecepcionID: recepcion.ID,
+				Result:      "pass",
+				InspectorID: "inspector-1",
+			})
+			if err != nil {
+				t.Fatalf("RecordQualityInspection: %v", err)
+			}
+
+			var received models.InventarioRecibidoEvent
+			if err := json.Unmarshal(h.sender.find(t, InventarioRecibidoRoutingKey).Body, &received); err != nil {
+				t.Fatalf("unmarshal InventarioRecibido: %v", err)
+			}
+			if received.Cantidad != tt.delivered || received.PurchaseOrderID != "po-1" {
+				t.Fatalf("InventarioRecibido of %d for %s, want %d for po-1", received.Cantidad, received.PurchaseOrderID, tt.delivered)
+			}
+		})
+	}
+}
+
+func TestDeliveryCompletingShortOrderHasNoDiscrepancy(t *testing.T) {
+	h := newTestEventHandler()
+	h.receive(t, "po-1", 10, 4)
+	second := h.receive(t, "po-1", 10, 6)
+
+	if second.CantidadPendiente != 0 || second.CantidadExcedente != 0 {
+		t.Fatalf("second delivery left %d pending and %d in excess, want none", second.CantidadPendiente, second.CantidadExcedente)
+	}
+	if keys := h.sender.routingKeys(); len(keys) != 1 || keys[0] != QuantityDiscrepancyRoutingKey {
+		t.Fatalf("published %v, want only the first delivery's QuantityDiscrepancy", keys)
+	}
+}
+
+func TestDeliveryStatingAnotherOrderedQuantityIsRejected(t *testing.T) {
+	h := newTestEventHandler()
+	h.receive(t, "po-1", 10, 4)
+
+	err := h.deliver(t, &models.ReceptionEvent{
+		ID:               "event-po-1-second",
+		Type:             models.ReceptionCreatedType,
+		PurchaseOrderID:  "po-1",
+		ProductID:        "product-1",
+		SupplierID:       "supplier-1",
+		Quantity:         12,
+		ReceivedQuantity: 6,
+	})
+	if !errors.Is(err, cqrs.ErrOrderedQuantityMismatch) {
+		t.Fatalf("deliver error %v, want ErrOrderedQuantityMismatch", err)
+	}
+
+	recepciones, err := h.recepciones.ListByPurchaseOrderID(context.Background(), "po-1")
+	if err != nil || len(recepciones) != 1 {
+		t.Fatalf("stored %d receptions (%v), want only the first", len(recepciones), err)
+	}
+}
+
+func TestCancellationStopsTheOpenReceptions(t *testing.T) {
+	h := newTestEventHandler()
+	passed := h.receive(t, "po-1", 10, 4)
+	open := h.receive(t, "po-1", 10, 6)
+	if _, err := h.RecordQualityInspection(context.Background(), cqrs.RecordQualityInspectionCommand{RecepcionID: passed.ID, Result: "pass", InspectorID: "inspector-1"}); err != nil {
+		t.Fatalf("RecordQualityInspection: %v", err)
+	}
+
+	// The reception already received cannot be cancelled, which retrying
+	// would not change, so the event is not failed
+	err := h.deliver(t, &models.ReceptionEvent{ID: "event-po-1-cancelled", Type: models.ReceptionCancelledType, PurchaseOrderID: "po-1", Reason: "supplier recall"})
+	if err != nil {
+		t.Fatalf("deliver cancellation: %v", err)
+	}
+
+	for id, want := range map[string]string{passed.ID: models.EstadoReceived, open.ID: models.EstadoCancelled} {
+		recepcion, err := h.recepciones.GetByID(context.Background(), id)
+		if err != nil {
+			t.Fatalf("get recepcion: %v", err)
+		}
+		if recepcion.Estado != want {
+			t.Errorf("recepcion %s is %s, want %s", id, recepcion.Estado, want)
+		}
+	}
+
+	_, err = h.RecordQualityInspection(context.Background(), cqrs.RecordQualityInspectionCommand{RecepcionID: open.ID, Result: "pass", InspectorID: "inspector-1"})
+	if !errors.Is(err, cqrs.ErrInspectionNotAllowed) {
+		t.Fatalf("inspection of a cancelled reception returned %v, want ErrInspectionNotAllowed", err)
+	}
+
+	// Deliveries arriving later are not received
+	if err := h.deliver(t, &models.ReceptionEvent{ID: "event-po-1-late", Type: models.ReceptionCreatedType, PurchaseOrderID: "po-1", ProductID: "product-1", Quantity: 10}); err != nil {
+		t.Fatalf("deliver late reception: %v", err)
+	}
+	recepciones, err := h.recepciones.ListByPurchaseOrderID(context.Background(), "po-1")
+	if err != nil || len(recepciones) != 2 {
+		t.Fatalf("stored %d receptions (%v), want the two delivered before the cancellation", len(recepciones), err)
+	}
+}
+
+// announce handles an ASN for purchaseOrderID as forwarded by OrdenCompra
+func (h *testEventHandler) announce(t *testing.T, purchaseOrderID string, lots ...models.ShipmentLot) error {
+	t.Helper()
+	body, err := json.Marshal(models.AdvanceShipmentNoticeEvent{
+		ID:                   "asn-event-" + purchaseOrderID,
+		EventType:            models.AdvanceShipmentNoticeEventType,
+		ASNID:                "asn-" + purchaseOrderID,
+		PurchaseOrderID:      purchaseOrderID,
+		ProductID:            "product-1",
+		SupplierID:           "supplier-1",
+		Quantity:             10,
+		ExpectedDeliveryDate: time.Date(2026, 3, 3, 0, 0, 0, 0, time.UTC),
+		Carrier:              "DHL",
+		TrackingNumber:       "TRACK-1",
+		Lots:                 lots,
+	})
+	if err != nil {
+		t.Fatalf("marshal notice: %v", err)
+	}
+	return h.HandleAdvanceShipmentNotice(context.Background(), messaging.Message{Body: body, ContentType: "application/json"})
+}
+
+func TestShipmentNoticePrepopulatesTheReception(t *testing.T) {
+	h := newTestEventHandler()
+	expiry := time.Date(2027, 1, 31, 0, 0, 0, 0, time.UTC)
+	if err := h.announce(t, "po-1", models.ShipmentLot{BatchNumber: "LOT-A", Quantity: 6, ExpiryDate: &expiry}, models.ShipmentLot{BatchNumber: "LOT-B", Quantity: 4}); err != nil {
+		t.Fatalf("HandleAdvanceShipmentNotice: %v", err)
+	}
+
+	// The delivery names no batch, so it takes the announced lots
+	err := h.deliver(t, &models.ReceptionEvent{ID: "event-po-1", Type: models.ReceptionCreatedType, PurchaseOrderID: "po-1", ProductID: "product-1", SupplierID: "supplier-1", Quantity: 10})
+	if err != nil {
+		t.Fatalf("deliver reception: %v", err)
+	}
+	recepcion, err := h.recepciones.GetByPurchaseOrderID(context.Background(), "po-1")
+	if err != nil || recepcion.ASNID != "asn-po-1" || recepcion.Carrier != "DHL" || recepcion.TrackingNumber != "TRACK-1" {
+		t.Fatalf("recepcion %+v, error %v", recepcion, err)
+	}
+
+	lots, err := h.lots.ListByRecepcionID(context.Background(), recepcion.ID)
+	if err != nil || len(lots) != 2 {
+		t.Fatalf("recorded %d lots (%v), want the two announced", len(lots), err)
+	}
+	for _, lot := range lots {
+		if lot.BatchNumber == "LOT-A" && (lot.Quantity != 6 || lot.ExpiryDate == nil || !lot.ExpiryDate.Equal(expiry)) {
+			t.Fatalf("lot %+v, want 6 units expiring %s", lot, expiry)
+		}
+	}
+
+	// The shipment is no longer expected once received
+	notice, err := h.notices.GetByPurchaseOrderID(context.Background(), "po-1")
+	if err != nil || notice.RecepcionID != recepcion.ID || notice.Expected() {
+		t.Fatalf("notice %+v, error %v", notice, err)
+	}
+}
+
+func TestShipmentNoticeOfACancelledOrderIsSkipped(t *testing.T) {
+	h := newTestEventHandler()
+	if err := h.deliver(t, &models.ReceptionEvent{ID: "event-po-1-cancelled", Type: models.ReceptionCancelledType, PurchaseOrderID: "po-1", Reason: "supplier recall"}); err != nil {
+		t.Fatalf("deliver cancellation: %v", err)
+	}
+
+	if err := h.announce(t, "po-1"); err != nil {
+		t.Fatalf("HandleAdvanceShipmentNotice: %v", err)
+	}
+	if _, err := h.notices.GetByPurchaseOrderID(context.Background(), "po-1"); !errors.Is(err, cqrs.ErrAdvanceShipmentNoticeNotFound) {
+		t.Fatalf("notice of a cancelled order returned %v, want it not recorded", err)
+	}
+}
+
+func TestEventLogKeepsTheCorrelationOfTheOrder(t *testing.T) {
+	ctx := context.Background()
+	h := newTestEventHandler()
+	err := h.deliver(t, &models.ReceptionEvent{
+		ID:               "event-1",
+		Type:             models.ReceptionCreatedType,
+		Timestamp:        time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC),
+		PurchaseOrderID:  "po-1",
+		ProductID:        "product-1",
+		SupplierID:       "supplier-1",
+		Quantity:         10,
+		ReceivedQuantity: 10,
+		BatchNumber:      "LOT-1",
+		Metadata:         map[string]interface{}{"correlation_id": "chain-1", "causation_id": "message-1"},
+	})
+	if err != nil {
+		t.Fatalf("deliver reception: %v", err)
+	}
+	recepciones, _ := h.recepciones.ListByPurchaseOrderID(ctx, "po-1")
+	if _, err := h.RecordQualityInspection(ctx, cqrs.RecordQualityInspectionCommand{RecepcionID: recepciones[0].ID, Result: "pass", InspectorID: "inspector-1"}); err != nil {
+		t.Fatalf("RecordQualityInspection: %v", err)
+	}
+
+	events, err := h.events.ListByAggregateID(ctx, "po-1")
+	if err != nil || len(events) < 3 {
+		t.Fatalf("logged %v, error %v", events, err)
+	}
+	consumed := events[0]
+	if consumed.EventType != models.ReceptionCreatedType || consumed.EventData["event_id"] != "event-1" || *consumed.CausationID != "message-1" {
+		t.Fatalf("first logged event %+v, want the consumed reception", consumed)
+	}
+	// Events proveedor produced join the chain of the consumed event
+	types := make(map[string]bool)
+	for _, event := range events {
+		types[event.EventType] = true
+		if event.CorrelationID == nil || *event.CorrelationID != "chain-1" {
+			t.Fatalf("%s logged in chain %v, want chain-1", event.EventType, event.CorrelationID)
+		}
+	}
+	if !types[models.QualityInspectionType] || !types[string(models.InventoryReceivedEventType)] {
+		t.Fatalf("logged %v, want the inspection and InventarioRecibido", types)
+	}
+}
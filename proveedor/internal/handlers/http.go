@@ -0,0 +1,285 @@
+package handlers
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"medisupply/export"
+	"proveedor/internal/cqrs"
+	"proveedor/internal/models"
+)
+
+// BrokerConnection is the connection events are consumed over
+type BrokerConnection interface {
+	IsClosed() bool
+}
+
+// HealthCheckHandler reports whether the service can consume events
+type HealthCheckHandler struct {
+	transport  string
+	connection BrokerConnection
+}
+
+// NewHealthCheckHandler creates a new health check handler for the
+// connection to the transport events are consumed from
+func NewHealthCheckHandler(transport string, connection BrokerConnection) *HealthCheckHandler {
+	return &HealthCheckHandler{transport: transport, connection: connection}
+}
+
+// CheckHealth checks the broker connection
+func (h *HealthCheckHandler) CheckHealth(ctx context.Context) map[string]interface{} {
+	checks := map[string]string{}
+	health := map[string]interface{}{
+		"status":    "healthy",
+		"timestamp": time.Now().Unix(),
+		"checks":    checks,
+	}
+
+	if h.connection == nil || h.connection.IsClosed() {
+		health["status"] = "unhealthy"
+		checks[h.transport] = "error"
+	} else {
+		checks[h.transport] = "ok"
+	}
+
+	return health
+}
+
+// RecepcionProveedorHandler answers recepcion proveedor queries
+type RecepcionProveedorHandler struct {
+	getHandler          *cqrs.GetRecepcionProveedorByIDHandler
+	listHandler         *cqrs.ListRecepcionProveedorHandler
+	temperaturesHandler *cqrs.GetTemperatureReadingsHandler
+	receiptHandler      *cqrs.GetPurchaseOrderReceiptHandler
+}
+
+// NewRecepcionProveedorHandler creates a new recepcion proveedor query handler
+func NewRecepcionProveedorHandler(repository cqrs.RecepcionProveedorRepository, readings cqrs.TemperatureReadingRepository) *RecepcionProveedorHandler {
+	return &RecepcionProveedorHandler{
+		getHandler:          cqrs.NewGetRecepcionProveedorByIDHandler(repository),
+		listHandler:         cqrs.NewListRecepcionProveedorHandler(repository),
+		temperaturesHandler: cqrs.NewGetTemperatureReadingsHandler(readings, repository),
+		receiptHandler:      cqrs.NewGetPurchaseOrderReceiptHandler(repository),
+	}
+}
+
+// GetRecepcion returns one recepcion proveedor
+func (h *RecepcionProveedorHandler) GetRecepcion(ctx context.Context, id string) (map[string]interface{}, error) {
+	recepcion, err := h.getHandler.Handle(ctx, cqrs.GetRecepcionProveedorByIDQuery{ID: id})
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"success":   true,
+		"recepcion": recepcion,
+	}, nil
+}
+
+// ListRecepciones lists recepciones filtered by proveedor and estado
+func (h *RecepcionProveedorHandler) ListRecepciones(ctx context.Context, query cqrs.ListRecepcionProveedorQuery) (map[string]interface{}, error) {
+	recepciones, err := h.listHandler.Handle(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"success":     true,
+		"recepciones": recepciones,
+		"count":       len(recepciones),
+		"limit":       query.Limit,
+		"offset":      query.Offset,
+	}, nil
+}
+
+// exportPageSize is the number of recepciones read per page during exports
+const exportPageSize = 1000
+
+// recepcionExportColumns is the header row of recepcion exports
+var recepcionExportColumns = []string{
+	"id", "purchase_order_id", "proveedor_id", "producto_id", "cantidad_ordenada", "cantidad",
+	"cantidad_pendiente", "cantidad_excedente", "fecha_recepcion", "estado", "quality_inspection",
+	"temperature_breach", "asn_id", "carrier", "tracking_number", "created_at",
+}
+
+// ExportRecepciones writes a header and one row per recepcion matching the
+// query's filters, paging through the whole list; query.Limit and
+// query.Offset are ignored. It returns the number of recepciones written.
+func (h *RecepcionProveedorHandler) ExportRecepciones(ctx context.Context, query cqrs.ListRecepcionProveedorQuery, w export.Writer) (int, error) {
+	if err := w.Write(recepcionExportColumns); err != nil {
+		return 0, err
+	}
+
+	rows := 0
+	query.Limit = exportPageSize
+	for query.Offset = 0; ; query.Offset += exportPageSize {
+		recepciones, err := h.listHandler.Handle(ctx, query)
+		if err != nil {
+			return rows, err
+		}
+		for _, recepcion := range recepciones {
+			if err := w.Write(recepcionExportRow(recepcion)); err != nil {
+				return rows, err
+			}
+			rows++
+		}
+		if len(recepciones) < exportPageSize {
+			return rows, nil
+		}
+	}
+}
+
+// recepcionExportRow flattens a recepcion into the export columns
+func recepcionExportRow(recepcion *models.RecepcionProveedor) []string {
+	qualityInspection := ""
+	if recepcion.QualityInspection != nil {
+		qualityInspection = string(recepcion.QualityInspection.Result)
+	}
+
+	return []string{
+		recepcion.ID,
+		recepcion.PurchaseOrderID,
+		recepcion.ProveedorID,
+		recepcion.ProductoID,
+		strconv.Itoa(recepcion.CantidadOrdenada),
+		strconv.Itoa(recepcion.Cantidad),
+		strconv.Itoa(recepcion.CantidadPendiente),
+		strconv.Itoa(recepcion.CantidadExcedente),
+		recepcion.FechaRecepcion.UTC().Format(time.RFC3339),
+		recepcion.Estado,
+		qualityInspection,
+		strconv.FormatBool(recepcion.TemperatureBreach),
+		recepcion.ASNID,
+		recepcion.Carrier,
+		recepcion.TrackingNumber,
+		recepcion.CreatedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+// GetPurchaseOrderReceipt returns the ordered, received and outstanding
+// quantities of a purchase order across its deliveries
+func (h *RecepcionProveedorHandler) GetPurchaseOrderReceipt(ctx context.Context, purchaseOrderID string) (map[string]interface{}, error) {
+	receipt, err := h.receiptHandler.Handle(ctx, cqrs.GetPurchaseOrderReceiptQuery{PurchaseOrderID: purchaseOrderID})
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"success": true,
+		"receipt": receipt,
+	}, nil
+}
+
+// GetTemperatureReadings returns the temperature readings of a reception's shipment
+func (h *RecepcionProveedorHandler) GetTemperatureReadings(ctx context.Context, id string) (map[string]interface{}, error) {
+	recepcion, readings, err := h.temperaturesHandler.Handle(ctx, cqrs.GetTemperatureReadingsQuery{RecepcionID: id})
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"success":            true,
+		"recepcion_id":       recepcion.ID,
+		"purchase_order_id":  recepcion.PurchaseOrderID,
+		"temperature_breach": recepcion.TemperatureBreach,
+		"readings":           readings,
+		"count":              len(readings),
+	}, nil
+}
+
+// LotHandler answers lot queries
+type LotHandler struct {
+	listHandler *cqrs.ListLotsHandler
+}
+
+// NewLotHandler creates a new lot query handler
+func NewLotHandler(lots cqrs.LotRepository) *LotHandler {
+	return &LotHandler{
+		listHandler: cqrs.NewListLotsHandler(lots),
+	}
+}
+
+// ListLots lists lots filtered by product and expiry date
+func (h *LotHandler) ListLots(ctx context.Context, query cqrs.ListLotsQuery) (map[string]interface{}, error) {
+	lots, err := h.listHandler.Handle(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"success": true,
+		"lots":    lots,
+		"count":   len(lots),
+	}, nil
+}
+
+// ShipmentNoticeHandler answers advance shipment notice queries
+type ShipmentNoticeHandler struct {
+	getHandler  *cqrs.GetAdvanceShipmentNoticeHandler
+	listHandler *cqrs.ListExpectedShipmentsHandler
+}
+
+// NewShipmentNoticeHandler creates a new shipment notice query handler
+func NewShipmentNoticeHandler(notices cqrs.AdvanceShipmentNoticeRepository) *ShipmentNoticeHandler {
+	return &ShipmentNoticeHandler{
+		getHandler:  cqrs.NewGetAdvanceShipmentNoticeHandler(notices),
+		listHandler: cqrs.NewListExpectedShipmentsHandler(notices),
+	}
+}
+
+// GetShipmentNotice returns the latest shipment notice of a purchase order
+func (h *ShipmentNoticeHandler) GetShipmentNotice(ctx context.Context, purchaseOrderID string) (map[string]interface{}, error) {
+	notice, err := h.getHandler.Handle(ctx, cqrs.GetAdvanceShipmentNoticeQuery{PurchaseOrderID: purchaseOrderID})
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"success": true,
+		"asn":     notice,
+	}, nil
+}
+
+// ListExpectedShipments lists the announced shipments not received yet
+func (h *ShipmentNoticeHandler) ListExpectedShipments(ctx context.Context, query cqrs.ListExpectedShipmentsQuery) (map[string]interface{}, error) {
+	notices, err := h.listHandler.Handle(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"success":   true,
+		"shipments": notices,
+		"count":     len(notices),
+	}, nil
+}
+
+// EventLogHandler answers queries on the events proveedor consumed and
+// produced, for tracing flows across services
+type EventLogHandler struct {
+	listHandler *cqrs.ListPurchaseOrderEventsHandler
+}
+
+// NewEventLogHandler creates a new event log query handler
+func NewEventLogHandler(events cqrs.EventRepository) *EventLogHandler {
+	return &EventLogHandler{
+		listHandler: cqrs.NewListPurchaseOrderEventsHandler(events),
+	}
+}
+
+// ListPurchaseOrderEvents lists the events of a purchase order, oldest first,
+// optionally limited to one correlation chain
+func (h *EventLogHandler) ListPurchaseOrderEvents(ctx context.Context, query cqrs.ListPurchaseOrderEventsQuery) (map[string]interface{}, error) {
+	events, err := h.listHandler.Handle(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"success":           true,
+		"purchase_order_id": query.PurchaseOrderID,
+		"events":            events,
+		"count":             len(events),
+	}, nil
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"proveedor/internal/cqrs"
+	"proveedor/internal/models"
+)
+
+// brokerConnection is a broker connection that is open unless closed
+type brokerConnection struct {
+	closed bool
+}
+
+func (c *brokerConnection) IsClosed() bool {
+	return c.closed
+}
+
+func TestCheckHealthReportsTheBrokerConnection(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		connection BrokerConnection
+		status     string
+		check      string
+	}{
+		{"open", &brokerConnection{}, "healthy", "ok"},
+		{"closed", &brokerConnection{closed: true}, "unhealthy", "error"},
+		{"not connected", nil, "unhealthy", "error"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			health := NewHealthCheckHandler("rabbitmq", tc.connection).CheckHealth(context.Background())
+			if health["status"] != tc.status {
+				t.Fatalf("status %v, want %s", health["status"], tc.status)
+			}
+			if checks := health["checks"].(map[string]string); checks["rabbitmq"] != tc.check {
+				t.Fatalf("checks %v, want rabbitmq %s", checks, tc.check)
+			}
+		})
+	}
+}
+
+func TestRecepcionQueries(t *testing.T) {
+	ctx := context.Background()
+	repository := cqrs.NewInMemoryRecepcionProveedorRepository()
+	for _, recepcion := range []*models.RecepcionProveedor{
+		{ID: "recepcion-1", ProveedorID: "proveedor-1", Estado: models.EstadoPendingQuality},
+		{ID: "recepcion-2", ProveedorID: "proveedor-2", Estado: models.EstadoPendingQuality},
+	} {
+		if err := repository.Save(ctx, recepcion); err != nil {
+			t.Fatalf("save recepcion: %v", err)
+		}
+	}
+	h := NewRecepcionProveedorHandler(repository, cqrs.NewInMemoryTemperatureReadingRepository())
+
+	response, err := h.GetRecepcion(ctx, "recepcion-1")
+	if err != nil || response["success"] != true || response["recepcion"].(*models.RecepcionProveedor).ID != "recepcion-1" {
+		t.Fatalf("response %v, error %v", response, err)
+	}
+	if _, err := h.GetRecepcion(ctx, "missing"); !errors.Is(err, cqrs.ErrRecepcionProveedorNotFound) {
+		t.Fatalf("get missing returned %v", err)
+	}
+
+	response, err = h.ListRecepciones(ctx, cqrs.ListRecepcionProveedorQuery{ProveedorID: "proveedor-2", Limit: 10})
+	if err != nil || response["count"] != 1 || response["limit"] != 10 || response["offset"] != 0 {
+		t.Fatalf("response %v, error %v", response, err)
+	}
+	if recepciones := response["recepciones"].([]*models.RecepcionProveedor); recepciones[0].ID != "recepcion-2" {
+		t.Fatalf("listed %s, want recepcion-2", recepciones[0].ID)
+	}
+}
+
+// exportedRows records the rows of an export
+type exportedRows [][]string
+
+func (r *exportedRows) Write(row []string) error {
+	*r = append(*r, row)
+	return nil
+}
+
+func (r *exportedRows) Close() error {
+	return nil
+}
+
+func TestExportRecepcionesIgnoresThePage(t *testing.T) {
+	ctx := context.Background()
+	repository := cqrs.NewInMemoryRecepcionProveedorRepository()
+	for i := 0; i < 3; i++ {
+		recepcion := &models.RecepcionProveedor{ID: fmt.Sprintf("recepcion-%d", i), ProveedorID: "proveedor-1", Cantidad: 5, Estado: models.EstadoReceived}
+		if i == 2 {
+			recepcion.ProveedorID = "proveedor-2"
+			recepcion.QualityInspection = &models.QualityInspection{Result: "pass"}
+		}
+		if err := repository.Save(ctx, recepcion); err != nil {
+			t.Fatalf("save recepcion: %v", err)
+		}
+	}
+	h := NewRecepcionProveedorHandler(repository, cqrs.NewInMemoryTemperatureReadingRepository())
+
+	var rows exportedRows
+	written, err := h.ExportRecepciones(ctx, cqrs.ListRecepcionProveedorQuery{Limit: 1, Offset: 2}, &rows)
+	if err != nil || written != 3 || len(rows) != 4 {
+		t.Fatalf("exported %d recepciones in %d rows, error %v, want every one after a header", written, len(rows), err)
+	}
+	if len(rows[0]) != len(recepcionExportColumns) || rows[0][0] != "id" {
+		t.Fatalf("header %v", rows[0])
+	}
+
+	rows = nil
+	written, err = h.ExportRecepciones(ctx, cqrs.ListRecepcionProveedorQuery{ProveedorID: "proveedor-2"}, &rows)
+	if err != nil || written != 1 {
+		t.Fatalf("exported %d recepciones, error %v, want the one of proveedor-2", written, err)
+	}
+	if row := rows[1]; row[0] != "recepcion-2" || row[5] != "5" || row[10] != "pass" || row[11] != "false" {
+		t.Fatalf("row %v", row)
+	}
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"proveedor/internal/cqrs"
+	"proveedor/internal/models"
+)
+
+// LotExpiryMonitor periodically warns about lots approaching their expiry date
+type LotExpiryMonitor struct {
+	lots          cqrs.LotRepository
+	producer      *Producer
+	warningWindow time.Duration
+	interval      time.Duration
+}
+
+// NewLotExpiryMonitor creates a monitor checking every interval for lots
+// expiring within warningWindow and publishing the warnings through producer
+func NewLotExpiryMonitor(lots cqrs.LotRepository, producer *Producer, warningWindow, interval time.Duration) *LotExpiryMonitor {
+	return &LotExpiryMonitor{
+		lots:          lots,
+		producer:      producer,
+		warningWindow: warningWindow,
+		interval:      interval,
+	}
+}
+
+// Run checks for expiring lots until ctx is cancelled
+func (m *LotExpiryMonitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := m.Check(ctx); err != nil {
+			log.Printf("Error checking lot expiry: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check produces a LotExpiringSoon event for every lot expiring within the
+// warning window, once per lot. A lot whose event fails to publish is
+// warned about again on the next check.
+func (m *LotExpiryMonitor) Check(ctx context.Context) error {
+	now := time.Now()
+	horizon := now.Add(m.warningWindow)
+	lots, err := m.lots.List(ctx, "", &horizon)
+	if err != nil {
+		return err
+	}
+
+	for _, lot := range lots {
+		if lot.ExpiryNotifiedAt != nil {
+			continue
+		}
+
+		if err := m.produceLotExpiringSoonEvent(ctx, models.NewLotExpiringSoonEvent(lot, now)); err != nil {
+			return err
+		}
+
+		notifiedAt := time.Now()
+		lot.ExpiryNotifiedAt = &notifiedAt
+		if err := m.lots.Update(ctx, lot); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// produceLotExpiringSoonEvent produces a lot expiring soon event
+func (m *LotExpiryMonitor) produceLotExpiringSoonEvent(ctx context.Context, event *models.LotExpiringSoonEvent) error {
+	if err := m.producer.produce(ctx, LotExpiringSoonRoutingKey, string(event.EventType), event.ID, event.Timestamp, nil, event); err != nil {
+		return err
+	}
+
+	log.Printf("Lot %s (batch %s) of product %s expires on %s",
+		event.LotID, event.BatchNumber, event.ProductID, event.ExpiryDate.Format(time.RFC3339))
+	return nil
+}
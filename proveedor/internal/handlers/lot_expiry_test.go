@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"proveedor/internal/cqrs"
+	"proveedor/internal/models"
+)
+
+func TestLotExpiryMonitorPublishesOncePerLot(t *testing.T) {
+	ctx := context.Background()
+	lots := cqrs.NewInMemoryLotRepository()
+	sender := &recordingSender{}
+	monitor := NewLotExpiryMonitor(lots, NewProducer(sender, DefaultEventsExchange), 30*24*time.Hour, time.Hour)
+
+	expiry := time.Now().Add(7 * 24 * time.Hour)
+	if err := lots.Save(ctx, &models.Lot{ID: "lot-1", BatchNumber: "LOT-1", ProductID: "product-1", ExpiryDate: &expiry}); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	sender.err = errors.New("broker unavailable")
+	if err := monitor.Check(ctx); !errors.Is(err, sender.err) {
+		t.Fatalf("Check error %v, want the publish error", err)
+	}
+	stored, err := lots.List(ctx, "product-1", nil)
+	if err != nil || len(stored) != 1 {
+		t.Fatalf("List: %v, %d lots", err, len(stored))
+	}
+	if stored[0].ExpiryNotifiedAt != nil {
+		t.Fatalf("lot marked notified although its event was not published")
+	}
+
+	sender.err = nil
+	for i := 0; i < 2; i++ {
+		if err := monitor.Check(ctx); err != nil {
+			t.Fatalf("Check: %v", err)
+		}
+	}
+	if keys := sender.routingKeys(); len(keys) != 1 {
+		t.Fatalf("published %v, want one LotExpiringSoon", keys)
+	}
+
+	var event models.LotExpiringSoonEvent
+	if err := json.Unmarshal(sender.find(t, LotExpiringSoonRoutingKey).Body, &event); err != nil {
+		t.Fatalf("unmarshal LotExpiringSoon: %v", err)
+	}
+	if event.LotID != "lot-1" || event.BatchNumber != "LOT-1" {
+		t.Fatalf("LotExpiringSoon for lot %s batch %s, want lot-1 batch LOT-1", event.LotID, event.BatchNumber)
+	}
+}
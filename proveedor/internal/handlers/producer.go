@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/rabbitmq/amqp091-go"
+
+	"medisupply/messaging"
+)
+
+// DefaultEventsExchange is the topic exchange proveedor produces its events
+// to, the one OrdenCompra consumes InventarioRecibido from
+const DefaultEventsExchange = "inventario-recibido-exchange"
+
+// Routing keys of the events proveedor produces
+const (
+	InventarioRecibidoRoutingKey  = "inventario.recibido"
+	QualityCheckFailedRoutingKey  = "inventario.quality-check-failed"
+	TemperatureBreachRoutingKey   = "inventario.temperature-breach"
+	QuantityDiscrepancyRoutingKey = "inventario.quantity-discrepancy"
+	LotExpiringSoonRoutingKey     = "inventario.lot-expiring-soon"
+	DamageReportedRoutingKey      = "inventario.damage-reported"
+	DevolucionProveedorRoutingKey = "devolucion.proveedor"
+)
+
+// Producer publishes the events proveedor produces as JSON messages
+type Producer struct {
+	sender   messaging.Sender
+	exchange string
+}
+
+// NewProducer creates a producer publishing to exchange through sender
+func NewProducer(sender messaging.Sender, exchange string) *Producer {
+	return &Producer{sender: sender, exchange: exchange}
+}
+
+// produce publishes event under routingKey. correlationID, when set, ties
+// the event to the flow of its purchase order.
+func (p *Producer) produce(ctx context.Context, routingKey, eventType, messageID string, timestamp time.Time, correlationID *string, event interface{}) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s event: %w", eventType, err)
+	}
+
+	headers := amqp091.Table{
+		"event-type":   eventType,
+		"content-type": "application/json",
+	}
+	if correlationID != nil {
+		headers["correlation-id"] = *correlationID
+	}
+
+	err = p.sender.Publish(ctx, p.exchange, routingKey, amqp091.Publishing{
+		ContentType:  "application/json",
+		Body:         body,
+		Headers:      headers,
+		MessageId:    messageID,
+		Timestamp:    timestamp,
+		DeliveryMode: amqp091.Persistent,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to publish %s event: %w", eventType, err)
+	}
+
+	log.Printf("Produced %s event: id=%s routing_key=%s", eventType, messageID, routingKey)
+	return nil
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"context"
+	"log"
+
+	"proveedor/internal/cqrs"
+	"proveedor/internal/models"
+)
+
+// ReceptionScanHandler manages the GS1 barcodes warehouse scanners read on
+// receptions and the product GTINs they are checked against
+type ReceptionScanHandler struct {
+	scanHandler     *cqrs.RecordReceptionScanHandler
+	listHandler     *cqrs.ListReceptionScansHandler
+	registerHandler *cqrs.RegisterProductGTINHandler
+	gtinHandler     *cqrs.GetProductGTINHandler
+	recorder        *EventHandler
+}
+
+// NewReceptionScanHandler creates a new reception scan handler. Scans update
+// the reception's lots, and ReceptionScanned events are logged under their
+// purchase order through recorder.
+func NewReceptionScanHandler(scans cqrs.ReceptionScanRepository, gtins cqrs.ProductGTINRepository, recepciones cqrs.RecepcionProveedorRepository, lots cqrs.LotRepository, recorder *EventHandler) *ReceptionScanHandler {
+	return &ReceptionScanHandler{
+		scanHandler:     cqrs.NewRecordReceptionScanHandler(scans, gtins, recepciones, lots),
+		listHandler:     cqrs.NewListReceptionScansHandler(scans, recepciones),
+		registerHandler: cqrs.NewRegisterProductGTINHandler(gtins),
+		gtinHandler:     cqrs.NewGetProductGTINHandler(gtins),
+		recorder:        recorder,
+	}
+}
+
+// ScanReception records a barcode scanned on a reception, returning the
+// parsed scan and the lot it filled in
+func (h *ReceptionScanHandler) ScanReception(ctx context.Context, cmd cqrs.RecordReceptionScanCommand) (map[string]interface{}, error) {
+	scan, lot, err := h.scanHandler.Handle(ctx, cmd)
+	if err != nil {
+		log.Printf("Error recording scan for recepcion proveedor %s: %v", cmd.RecepcionID, err)
+		return nil, err
+	}
+
+	log.Printf("Recorded scan %s for recepcion %s: gtin=%s batch_number=%s serial_number=%s cantidad=%d scanned_by=%s",
+		scan.ID, scan.RecepcionID, scan.GTIN, scan.BatchNumber, scan.SerialNumber, scan.Cantidad, scan.ScannedBy)
+
+	h.recorder.recordEvent(ctx, scan.PurchaseOrderID, string(models.ReceptionScannedEventType), map[string]interface{}{
+		"scan_id":      scan.ID,
+		"recepcion_id": scan.RecepcionID,
+		"gtin":         scan.GTIN,
+		"batch_number": scan.BatchNumber,
+		"cantidad":     scan.Cantidad,
+		"scanned_by":   scan.ScannedBy,
+	}, nil, nil)
+
+	return map[string]interface{}{
+		"success": true,
+		"scan":    scan,
+		"lot":     lot,
+	}, nil
+}
+
+// ListScans lists the scans of a reception with the units still to scan
+func (h *ReceptionScanHandler) ListScans(ctx context.Context, recepcionID string) (map[string]interface{}, error) {
+	recepcion, scans, err := h.listHandler.Handle(ctx, cqrs.ListReceptionScansQuery{RecepcionID: recepcionID})
+	if err != nil {
+		return nil, err
+	}
+
+	scanned := 0
+	for _, scan := range scans {
+		scanned += scan.Cantidad
+	}
+
+	return map[string]interface{}{
+		"success":            true,
+		"recepcion_id":       recepcion.ID,
+		"purchase_order_id":  recepcion.PurchaseOrderID,
+		"cantidad":           recepcion.Cantidad,
+		"cantidad_escaneada": scanned,
+		"scans":              scans,
+		"count":              len(scans),
+	}, nil
+}
+
+// RegisterGTIN registers the GTIN of a product
+func (h *ReceptionScanHandler) RegisterGTIN(ctx context.Context, cmd cqrs.RegisterProductGTINCommand) (map[string]interface{}, error) {
+	productGTIN, err := h.registerHandler.Handle(ctx, cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Registered GTIN %s for product %s", productGTIN.GTIN, productGTIN.ProductID)
+	return map[string]interface{}{
+		"success":      true,
+		"product_gtin": productGTIN,
+	}, nil
+}
+
+// GetGTIN returns the product a GTIN is registered to
+func (h *ReceptionScanHandler) GetGTIN(ctx context.Context, gtin string) (map[string]interface{}, error) {
+	productGTIN, err := h.gtinHandler.Handle(ctx, cqrs.GetProductGTINQuery{GTIN: gtin})
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"success":      true,
+		"product_gtin": productGTIN,
+	}, nil
+}
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"time"
+)
+
+// AdvanceShipmentNoticeEventType is the type of the ASN events sent by OrdenCompra
+const AdvanceShipmentNoticeEventType EventType = "AdvanceShipmentNotice"
+
+// ShipmentLot is a lot the supplier announced in an advance shipment notice
+type ShipmentLot struct {
+	BatchNumber string     `json:"batch_number" dynamodbav:"batch_number"`
+	Quantity    int        `json:"quantity" dynamodbav:"quantity"`
+	ExpiryDate  *time.Time `json:"expiry_date,omitempty" dynamodbav:"expiry_date,omitempty"`
+}
+
+// AdvanceShipmentNotice tells the warehouse what a supplier shipped for a
+// purchase order before it arrives. RecepcionID is set once the delivery is
+// received.
+type AdvanceShipmentNotice struct {
+	ID                   string        `json:"id" dynamodbav:"id"`
+	PurchaseOrderID      string        `json:"purchase_order_id" dynamodbav:"purchase_order_id"`
+	ProductID            string        `json:"product_id" dynamodbav:"product_id"`
+	SupplierID           string        `json:"supplier_id" dynamodbav:"supplier_id"`
+	Quantity             int           `json:"quantity" dynamodbav:"quantity"`
+	ExpectedDeliveryDate time.Time     `json:"expected_delivery_date" dynamodbav:"expected_delivery_date"`
+	Carrier              string        `json:"carrier" dynamodbav:"carrier"`
+	TrackingNumber       string        `json:"tracking_number" dynamodbav:"tracking_number"`
+	Lots                 []ShipmentLot `json:"lots,omitempty" dynamodbav:"lots,omitempty"`
+	RecepcionID          string        `json:"recepcion_id,omitempty" dynamodbav:"recepcion_id,omitempty"`
+	ReceivedAt           time.Time     `json:"received_at" dynamodbav:"received_at"`
+}
+
+// Expected reports whether the shipment has not been received yet
+func (n *AdvanceShipmentNotice) Expected() bool {
+	return n.RecepcionID == ""
+}
+
+// LotExpiryDate returns the expiry date the supplier announced for a batch
+func (n *AdvanceShipmentNotice) LotExpiryDate(batchNumber string) *time.Time {
+	for _, lot := range n.Lots {
+		if lot.BatchNumber == batchNumber {
+			return lot.ExpiryDate
+		}
+	}
+	return nil
+}
+
+// AdvanceShipmentNoticeEvent is an ASN forwarded by OrdenCompra
+type AdvanceShipmentNoticeEvent struct {
+	ID                   string                 `json:"id"`
+	Timestamp            time.Time              `json:"timestamp"`
+	EventType            EventType              `json:"event_type"`
+	ASNID                string                 `json:"asn_id"`
+	PurchaseOrderID      string                 `json:"purchase_order_id"`
+	ProductID            string                 `json:"product_id"`
+	SupplierID           string                 `json:"supplier_id"`
+	Quantity             int                    `json:"quantity"`
+	ExpectedDeliveryDate time.Time              `json:"expected_delivery_date"`
+	Carrier              string                 `json:"carrier"`
+	TrackingNumber       string                 `json:"tracking_number"`
+	Lots                 []ShipmentLot          `json:"lots,omitempty"`
+	Metadata             map[string]interface{} `json:"metadata"`
+}
@@ -0,0 +1,161 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Reception command types understood by the proveedor handlers
+const (
+	ReceptionCreatedType   = "RecepcionProveedorCreated"
+	ReceptionUpdatedType   = "RecepcionProveedorUpdated"
+	ReceptionCancelledType = "RecepcionProveedorCancelled"
+	// QualityInspectionType records an inspection of the reception in id,
+	// with the result in status
+	QualityInspectionType = "QualityInspectionRecorded"
+)
+
+// EmitLegacyFieldNames controls whether egress events still carry the Spanish
+// field aliases. It stays on during the deprecation window so older consumers
+// keep working.
+var EmitLegacyFieldNames = true
+
+// ReceptionEvent is the canonical form of a RecepcionProveedor event. Every
+// field has exactly one name, so handlers never read a half-populated alias.
+type ReceptionEvent struct {
+	ID              string
+	Type            string
+	EventType       EventType
+	Timestamp       time.Time
+	PurchaseOrderID string
+	ProductID       string
+	ProductName     string
+	Quantity        int
+	SupplierID      string
+	SupplierName    string
+	Location        string
+	Status          string
+	ReceptionDate   time.Time
+	Reason          string
+	InspectorID     string
+	Notes           string
+	BatchNumber     string
+	ExpiryDate      *time.Time
+	// ReceivedQuantity is the quantity actually delivered, when it differs
+	// from the ordered Quantity
+	ReceivedQuantity int
+	Metadata         map[string]interface{}
+}
+
+// NormalizationError reports fields whose English and Spanish names disagree
+type NormalizationError struct {
+	Conflicts []string
+}
+
+// Error implements the error interface
+func (e *NormalizationError) Error() string {
+	return "conflicting field aliases: " + strings.Join(e.Conflicts, "; ")
+}
+
+// Normalize maps either naming of a wire event into the canonical ReceptionEvent.
+// When both names are present they must agree.
+func (r *RecepcionProveedorEvent) Normalize() (*ReceptionEvent, error) {
+	var conflicts []string
+
+	pickString := func(english, spanish, englishName, spanishName string) string {
+		if english != "" && spanish != "" && english != spanish {
+			conflicts = append(conflicts, fmt.Sprintf("%s=%q vs %s=%q", englishName, english, spanishName, spanish))
+		}
+		if english != "" {
+			return english
+		}
+		return spanish
+	}
+
+	pickInt := func(english, spanish int, englishName, spanishName string) int {
+		if english != 0 && spanish != 0 && english != spanish {
+			conflicts = append(conflicts, fmt.Sprintf("%s=%d vs %s=%d", englishName, english, spanishName, spanish))
+		}
+		if english != 0 {
+			return english
+		}
+		return spanish
+	}
+
+	event := &ReceptionEvent{
+		ID:               r.ID,
+		Type:             r.Type,
+		EventType:        r.EventType,
+		Timestamp:        r.Timestamp,
+		PurchaseOrderID:  r.PurchaseOrderID,
+		ProductID:        pickString(r.ProductID, r.ProductoID, "product_id", "producto_id"),
+		ProductName:      r.ProductName,
+		Quantity:         pickInt(r.Quantity, r.Cantidad, "quantity", "cantidad"),
+		SupplierID:       pickString(r.SupplierID, r.ProveedorID, "supplier_id", "proveedor_id"),
+		SupplierName:     r.SupplierName,
+		Location:         r.Location,
+		Status:           pickString(r.Status, r.Estado, "status", "estado"),
+		ReceptionDate:    r.FechaRecepcion,
+		Reason:           r.Reason,
+		InspectorID:      r.InspectorID,
+		Notes:            r.Notes,
+		BatchNumber:      r.BatchNumber,
+		ExpiryDate:       r.ExpiryDate,
+		ReceivedQuantity: r.ReceivedQuantity,
+		Metadata:         r.Metadata,
+	}
+
+	if len(conflicts) > 0 {
+		return nil, &NormalizationError{Conflicts: conflicts}
+	}
+
+	// OrdenCompra only sets event_type; treat a new RecepcionProveedor as a create
+	if event.Type == "" && event.EventType == PurchaseOrderEventType {
+		event.Type = ReceptionCreatedType
+	}
+	if event.ReceptionDate.IsZero() {
+		event.ReceptionDate = event.Timestamp
+	}
+	if event.Metadata == nil {
+		event.Metadata = make(map[string]interface{})
+	}
+
+	return event, nil
+}
+
+// Wire converts the canonical event back into its wire form, populating the
+// Spanish aliases while EmitLegacyFieldNames is enabled
+func (e *ReceptionEvent) Wire() *RecepcionProveedorEvent {
+	wire := &RecepcionProveedorEvent{
+		ID:               e.ID,
+		Timestamp:        e.Timestamp,
+		Type:             e.Type,
+		EventType:        e.EventType,
+		PurchaseOrderID:  e.PurchaseOrderID,
+		ProductID:        e.ProductID,
+		ProductName:      e.ProductName,
+		Quantity:         e.Quantity,
+		SupplierID:       e.SupplierID,
+		SupplierName:     e.SupplierName,
+		Location:         e.Location,
+		Status:           e.Status,
+		FechaRecepcion:   e.ReceptionDate,
+		Reason:           e.Reason,
+		InspectorID:      e.InspectorID,
+		Notes:            e.Notes,
+		BatchNumber:      e.BatchNumber,
+		ExpiryDate:       e.ExpiryDate,
+		ReceivedQuantity: e.ReceivedQuantity,
+		Metadata:         e.Metadata,
+	}
+
+	if EmitLegacyFieldNames {
+		wire.ProductoID = e.ProductID
+		wire.Cantidad = e.Quantity
+		wire.ProveedorID = e.SupplierID
+		wire.Estado = e.Status
+	}
+
+	return wire
+}
@@ -0,0 +1,84 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DamageReportedEventType is produced when damaged goods are reported on a
+// reception; it opens a return of the damaged units to the supplier
+const DamageReportedEventType EventType = "DamageReported"
+
+// Bounds of the photos of a damage report
+const (
+	MaxDamagePhotos    = 10
+	MaxDamagePhotoSize = 10 << 20
+)
+
+// DamagePhoto is a photo taken as evidence of damage. The photo itself is
+// uploaded to object storage under Key; the report only holds its metadata.
+type DamagePhoto struct {
+	ID          string `json:"id" dynamodbav:"id"`
+	FileName    string `json:"file_name" dynamodbav:"file_name"`
+	ContentType string `json:"content_type" dynamodbav:"content_type"`
+	Size        int64  `json:"size" dynamodbav:"size"`
+	Key         string `json:"key" dynamodbav:"key"`
+}
+
+// DamageReport records goods of a reception found damaged, with the return
+// opened for them
+type DamageReport struct {
+	ID              string        `json:"id" dynamodbav:"id"`
+	RecepcionID     string        `json:"recepcion_id" dynamodbav:"recepcion_id"`
+	PurchaseOrderID string        `json:"purchase_order_id,omitempty" dynamodbav:"purchase_order_id,omitempty"`
+	ProveedorID     string        `json:"proveedor_id" dynamodbav:"proveedor_id"`
+	ProductoID      string        `json:"producto_id" dynamodbav:"producto_id"`
+	Cantidad        int           `json:"cantidad" dynamodbav:"cantidad"`
+	Notas           string        `json:"notas,omitempty" dynamodbav:"notas,omitempty"`
+	ReportedBy      string        `json:"reported_by" dynamodbav:"reported_by"`
+	Photos          []DamagePhoto `json:"photos" dynamodbav:"photos"`
+	DevolucionID    string        `json:"devolucion_id" dynamodbav:"devolucion_id"`
+	CreatedAt       time.Time     `json:"created_at" dynamodbav:"created_at"`
+}
+
+// DamageReportedEvent tells inventory and the returns workflow about damaged goods
+type DamageReportedEvent struct {
+	ID              string                 `json:"id" dynamodbav:"id"`
+	Timestamp       time.Time              `json:"timestamp" dynamodbav:"timestamp"`
+	EventType       EventType              `json:"event_type" dynamodbav:"event_type"`
+	DamageReportID  string                 `json:"damage_report_id" dynamodbav:"damage_report_id"`
+	RecepcionID     string                 `json:"recepcion_id" dynamodbav:"recepcion_id"`
+	PurchaseOrderID string                 `json:"purchase_order_id,omitempty" dynamodbav:"purchase_order_id,omitempty"`
+	ProveedorID     string                 `json:"proveedor_id" dynamodbav:"proveedor_id"`
+	ProductoID      string                 `json:"producto_id" dynamodbav:"producto_id"`
+	Cantidad        int                    `json:"cantidad" dynamodbav:"cantidad"`
+	DevolucionID    string                 `json:"devolucion_id" dynamodbav:"devolucion_id"`
+	ReportedBy      string                 `json:"reported_by" dynamodbav:"reported_by"`
+	PhotoKeys       []string               `json:"photo_keys" dynamodbav:"photo_keys"`
+	Metadata        map[string]interface{} `json:"metadata" dynamodbav:"metadata"`
+}
+
+// NewDamageReportedEvent creates a DamageReported event for a damage report
+func NewDamageReportedEvent(report *DamageReport, now time.Time) *DamageReportedEvent {
+	photoKeys := make([]string, 0, len(report.Photos))
+	for _, photo := range report.Photos {
+		photoKeys = append(photoKeys, photo.Key)
+	}
+
+	return &DamageReportedEvent{
+		ID:              uuid.New().String(),
+		Timestamp:       now.UTC(),
+		EventType:       DamageReportedEventType,
+		DamageReportID:  report.ID,
+		RecepcionID:     report.RecepcionID,
+		PurchaseOrderID: report.PurchaseOrderID,
+		ProveedorID:     report.ProveedorID,
+		ProductoID:      report.ProductoID,
+		Cantidad:        report.Cantidad,
+		DevolucionID:    report.DevolucionID,
+		ReportedBy:      report.ReportedBy,
+		PhotoKeys:       photoKeys,
+		Metadata:        make(map[string]interface{}),
+	}
+}
@@ -0,0 +1,100 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DevolucionProveedorEventType is produced whenever a return to a supplier is created or changes state
+const DevolucionProveedorEventType EventType = "DevolucionProveedor"
+
+// Reasons for returning received goods
+const (
+	MotivoDamaged = "damaged"
+	MotivoExpired = "expired"
+)
+
+// IsValidMotivo reports whether goods can be returned for the reason
+func IsValidMotivo(motivo string) bool {
+	return motivo == MotivoDamaged || motivo == MotivoExpired
+}
+
+// Return states. A return is requested, shipped back to the supplier and
+// completed once the supplier credits it; it can be cancelled until shipped.
+const (
+	DevolucionRequested = "requested"
+	DevolucionShipped   = "shipped"
+	DevolucionCompleted = "completed"
+	DevolucionCancelled = "cancelled"
+)
+
+// devolucionTransitions lists the states each return state may move to
+var devolucionTransitions = map[string][]string{
+	DevolucionRequested: {DevolucionShipped, DevolucionCancelled},
+	DevolucionShipped:   {DevolucionCompleted},
+}
+
+// CanTransitionDevolucion reports whether a return may move between the states
+func CanTransitionDevolucion(from, to string) bool {
+	for _, next := range devolucionTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// DevolucionProveedor is a return of received goods to their supplier, linked
+// to the reception and purchase order they arrived with and, for returns
+// opened by a damage report, to the report
+type DevolucionProveedor struct {
+	ID              string    `json:"id" dynamodbav:"id"`
+	RecepcionID     string    `json:"recepcion_id" dynamodbav:"recepcion_id"`
+	PurchaseOrderID string    `json:"purchase_order_id,omitempty" dynamodbav:"purchase_order_id,omitempty"`
+	ProveedorID     string    `json:"proveedor_id" dynamodbav:"proveedor_id"`
+	ProductoID      string    `json:"producto_id" dynamodbav:"producto_id"`
+	Cantidad        int       `json:"cantidad" dynamodbav:"cantidad"`
+	Motivo          string    `json:"motivo" dynamodbav:"motivo"`
+	Notas           string    `json:"notas,omitempty" dynamodbav:"notas,omitempty"`
+	DamageReportID  string    `json:"damage_report_id,omitempty" dynamodbav:"damage_report_id,omitempty"`
+	Estado          string    `json:"estado" dynamodbav:"estado"`
+	CreatedAt       time.Time `json:"created_at" dynamodbav:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at" dynamodbav:"updated_at"`
+}
+
+// DevolucionProveedorEvent tells inventory and finance about a return and its current state
+type DevolucionProveedorEvent struct {
+	ID              string                 `json:"id" dynamodbav:"id"`
+	Timestamp       time.Time              `json:"timestamp" dynamodbav:"timestamp"`
+	EventType       EventType              `json:"event_type" dynamodbav:"event_type"`
+	DevolucionID    string                 `json:"devolucion_id" dynamodbav:"devolucion_id"`
+	Estado          string                 `json:"estado" dynamodbav:"estado"`
+	RecepcionID     string                 `json:"recepcion_id" dynamodbav:"recepcion_id"`
+	PurchaseOrderID string                 `json:"purchase_order_id,omitempty" dynamodbav:"purchase_order_id,omitempty"`
+	ProveedorID     string                 `json:"proveedor_id" dynamodbav:"proveedor_id"`
+	ProductoID      string                 `json:"producto_id" dynamodbav:"producto_id"`
+	Cantidad        int                    `json:"cantidad" dynamodbav:"cantidad"`
+	Motivo          string                 `json:"motivo" dynamodbav:"motivo"`
+	DamageReportID  string                 `json:"damage_report_id,omitempty" dynamodbav:"damage_report_id,omitempty"`
+	Metadata        map[string]interface{} `json:"metadata" dynamodbav:"metadata"`
+}
+
+// NewDevolucionProveedorEvent creates an event for the current state of a return
+func NewDevolucionProveedorEvent(devolucion *DevolucionProveedor, now time.Time) *DevolucionProveedorEvent {
+	return &DevolucionProveedorEvent{
+		ID:              uuid.New().String(),
+		Timestamp:       now.UTC(),
+		EventType:       DevolucionProveedorEventType,
+		DevolucionID:    devolucion.ID,
+		Estado:          devolucion.Estado,
+		RecepcionID:     devolucion.RecepcionID,
+		PurchaseOrderID: devolucion.PurchaseOrderID,
+		ProveedorID:     devolucion.ProveedorID,
+		ProductoID:      devolucion.ProductoID,
+		Cantidad:        devolucion.Cantidad,
+		Motivo:          devolucion.Motivo,
+		DamageReportID:  devolucion.DamageReportID,
+		Metadata:        make(map[string]interface{}),
+	}
+}
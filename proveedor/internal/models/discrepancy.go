@@ -0,0 +1,84 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// QuantityDiscrepancyEventType is produced when a delivery leaves a purchase
+// order short or over-delivered
+const QuantityDiscrepancyEventType EventType = "QuantityDiscrepancy"
+
+// Kinds of quantity discrepancy
+const (
+	DiscrepancyShort = "short"
+	DiscrepancyOver  = "over"
+)
+
+// Receipt states of a purchase order across its deliveries
+const (
+	ReceiptOpen          = "open"
+	ReceiptComplete      = "complete"
+	ReceiptOverDelivered = "over_delivered"
+)
+
+// QuantityDiscrepancy returns the kind of discrepancy the purchase order had
+// after this delivery, or "" when it was received exactly
+func (r *RecepcionProveedor) QuantityDiscrepancy() string {
+	switch {
+	case r.CantidadExcedente > 0:
+		return DiscrepancyOver
+	case r.CantidadPendiente > 0:
+		return DiscrepancyShort
+	default:
+		return ""
+	}
+}
+
+// QuantityDiscrepancyEvent reports a short or over delivery against a purchase order
+type QuantityDiscrepancyEvent struct {
+	ID                string                 `json:"id" dynamodbav:"id"`
+	Timestamp         time.Time              `json:"timestamp" dynamodbav:"timestamp"`
+	EventType         EventType              `json:"event_type" dynamodbav:"event_type"`
+	Kind              string                 `json:"kind" dynamodbav:"kind"`
+	RecepcionID       string                 `json:"recepcion_id" dynamodbav:"recepcion_id"`
+	PurchaseOrderID   string                 `json:"purchase_order_id" dynamodbav:"purchase_order_id"`
+	ProductID         string                 `json:"product_id" dynamodbav:"product_id"`
+	SupplierID        string                 `json:"supplier_id" dynamodbav:"supplier_id"`
+	OrderedQuantity   int                    `json:"ordered_quantity" dynamodbav:"ordered_quantity"`
+	DeliveredQuantity int                    `json:"delivered_quantity" dynamodbav:"delivered_quantity"`
+	RemainingQuantity int                    `json:"remaining_quantity" dynamodbav:"remaining_quantity"`
+	ExcessQuantity    int                    `json:"excess_quantity" dynamodbav:"excess_quantity"`
+	Metadata          map[string]interface{} `json:"metadata" dynamodbav:"metadata"`
+}
+
+// NewQuantityDiscrepancyEvent creates a QuantityDiscrepancy event for a delivery with a discrepancy
+func NewQuantityDiscrepancyEvent(recepcion *RecepcionProveedor, now time.Time) *QuantityDiscrepancyEvent {
+	return &QuantityDiscrepancyEvent{
+		ID:                uuid.New().String(),
+		Timestamp:         now.UTC(),
+		EventType:         QuantityDiscrepancyEventType,
+		Kind:              recepcion.QuantityDiscrepancy(),
+		RecepcionID:       recepcion.ID,
+		PurchaseOrderID:   recepcion.PurchaseOrderID,
+		ProductID:         recepcion.ProductoID,
+		SupplierID:        recepcion.ProveedorID,
+		OrderedQuantity:   recepcion.CantidadOrdenada,
+		DeliveredQuantity: recepcion.Cantidad,
+		RemainingQuantity: recepcion.CantidadPendiente,
+		ExcessQuantity:    recepcion.CantidadExcedente,
+		Metadata:          make(map[string]interface{}),
+	}
+}
+
+// PurchaseOrderReceipt summarises the deliveries received for a purchase order
+type PurchaseOrderReceipt struct {
+	PurchaseOrderID   string                `json:"purchase_order_id"`
+	Status            string                `json:"status"`
+	OrderedQuantity   int                   `json:"ordered_quantity"`
+	ReceivedQuantity  int                   `json:"received_quantity"`
+	RemainingQuantity int                   `json:"remaining_quantity"`
+	ExcessQuantity    int                   `json:"excess_quantity"`
+	Recepciones       []*RecepcionProveedor `json:"recepciones"`
+}
@@ -0,0 +1,122 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// QualityCheckFailedEventType is produced when a reception fails inspection
+const QualityCheckFailedEventType EventType = "QualityCheckFailed"
+
+// Reception states of the quality inspection workflow. Receptions wait in
+// EstadoPendingQuality until an inspector records a result; quarantined
+// receptions may be inspected again. Receptions still open when their
+// purchase order is cancelled move to EstadoCancelled.
+const (
+	EstadoPendingQuality = "pending_quality"
+	EstadoReceived       = "received"
+	EstadoRejected       = "rejected"
+	EstadoQuarantined    = "quarantined"
+	EstadoCancelled      = "cancelled"
+)
+
+// IsFinalEstado reports whether a reception in the state can no longer change
+func IsFinalEstado(estado string) bool {
+	return estado == EstadoReceived || estado == EstadoRejected || estado == EstadoCancelled
+}
+
+// QualityResult is the outcome of a quality inspection
+type QualityResult string
+
+const (
+	QualityPassed      QualityResult = "passed"
+	QualityFailed      QualityResult = "failed"
+	QualityQuarantined QualityResult = "quarantined"
+)
+
+// ParseQualityResult parses pass, fail and quarantine in either tense, ignoring case
+func ParseQualityResult(value string) (QualityResult, bool) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "pass", "passed":
+		return QualityPassed, true
+	case "fail", "failed":
+		return QualityFailed, true
+	case "quarantine", "quarantined":
+		return QualityQuarantined, true
+	default:
+		return "", false
+	}
+}
+
+// Estado returns the reception state an inspection result leads to
+func (q QualityResult) Estado() string {
+	switch q {
+	case QualityPassed:
+		return EstadoReceived
+	case QualityFailed:
+		return EstadoRejected
+	default:
+		return EstadoQuarantined
+	}
+}
+
+// QualityInspection records an inspector's verdict on a reception
+type QualityInspection struct {
+	ID          string        `json:"id" dynamodbav:"id"`
+	Result      QualityResult `json:"result" dynamodbav:"result"`
+	Notes       string        `json:"notes,omitempty" dynamodbav:"notes,omitempty"`
+	InspectorID string        `json:"inspector_id" dynamodbav:"inspector_id"`
+	InspectedAt time.Time     `json:"inspected_at" dynamodbav:"inspected_at"`
+}
+
+// NewQualityInspection creates a new QualityInspection
+func NewQualityInspection(result QualityResult, notes, inspectorID string, now time.Time) *QualityInspection {
+	return &QualityInspection{
+		ID:          uuid.New().String(),
+		Result:      result,
+		Notes:       notes,
+		InspectorID: inspectorID,
+		InspectedAt: now.UTC(),
+	}
+}
+
+// QualityCheckFailedEvent reports a reception rejected by quality inspection
+type QualityCheckFailedEvent struct {
+	ID              string                 `json:"id" dynamodbav:"id"`
+	Timestamp       time.Time              `json:"timestamp" dynamodbav:"timestamp"`
+	EventType       EventType              `json:"event_type" dynamodbav:"event_type"`
+	RecepcionID     string                 `json:"recepcion_id" dynamodbav:"recepcion_id"`
+	PurchaseOrderID string                 `json:"purchase_order_id" dynamodbav:"purchase_order_id"`
+	ProductID       string                 `json:"product_id" dynamodbav:"product_id"`
+	SupplierID      string                 `json:"supplier_id" dynamodbav:"supplier_id"`
+	Quantity        int                    `json:"quantity" dynamodbav:"quantity"`
+	InspectorID     string                 `json:"inspector_id" dynamodbav:"inspector_id"`
+	Reason          string                 `json:"reason,omitempty" dynamodbav:"reason,omitempty"`
+	InspectedAt     time.Time              `json:"inspected_at" dynamodbav:"inspected_at"`
+	Metadata        map[string]interface{} `json:"metadata" dynamodbav:"metadata"`
+}
+
+// NewQualityCheckFailedEvent creates a QualityCheckFailed event for a rejected reception
+func NewQualityCheckFailedEvent(recepcion *RecepcionProveedor, now time.Time) *QualityCheckFailedEvent {
+	event := &QualityCheckFailedEvent{
+		ID:              uuid.New().String(),
+		Timestamp:       now.UTC(),
+		EventType:       QualityCheckFailedEventType,
+		RecepcionID:     recepcion.ID,
+		PurchaseOrderID: recepcion.PurchaseOrderID,
+		ProductID:       recepcion.ProductoID,
+		SupplierID:      recepcion.ProveedorID,
+		Quantity:        recepcion.Cantidad,
+		Metadata:        make(map[string]interface{}),
+	}
+
+	if inspection := recepcion.QualityInspection; inspection != nil {
+		event.InspectorID = inspection.InspectorID
+		event.Reason = inspection.Notes
+		event.InspectedAt = inspection.InspectedAt
+	}
+
+	return event
+}
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LotExpiringSoonEventType is produced when a lot approaches its expiry date
+const LotExpiringSoonEventType EventType = "LotExpiringSoon"
+
+// Lot is a supplier batch of a product received with a reception
+type Lot struct {
+	ID               string     `json:"id" dynamodbav:"id"`
+	BatchNumber      string     `json:"batch_number" dynamodbav:"batch_number"`
+	ProductID        string     `json:"product_id" dynamodbav:"product_id"`
+	SupplierID       string     `json:"supplier_id" dynamodbav:"supplier_id"`
+	PurchaseOrderID  string     `json:"purchase_order_id,omitempty" dynamodbav:"purchase_order_id,omitempty"`
+	RecepcionID      string     `json:"recepcion_id" dynamodbav:"recepcion_id"`
+	Quantity         int        `json:"quantity" dynamodbav:"quantity"`
+	ExpiryDate       *time.Time `json:"expiry_date,omitempty" dynamodbav:"expiry_date,omitempty"`
+	ExpiryNotifiedAt *time.Time `json:"expiry_notified_at,omitempty" dynamodbav:"expiry_notified_at,omitempty"`
+	CreatedAt        time.Time  `json:"created_at" dynamodbav:"created_at"`
+}
+
+// ExpiresBefore reports whether the lot has an expiry date before t
+func (l *Lot) ExpiresBefore(t time.Time) bool {
+	return l.ExpiryDate != nil && l.ExpiryDate.Before(t)
+}
+
+// LotExpiringSoonEvent warns that a lot expires within the warning window
+type LotExpiringSoonEvent struct {
+	ID          string                 `json:"id" dynamodbav:"id"`
+	Timestamp   time.Time              `json:"timestamp" dynamodbav:"timestamp"`
+	EventType   EventType              `json:"event_type" dynamodbav:"event_type"`
+	LotID       string                 `json:"lot_id" dynamodbav:"lot_id"`
+	BatchNumber string                 `json:"batch_number" dynamodbav:"batch_number"`
+	ProductID   string                 `json:"product_id" dynamodbav:"product_id"`
+	SupplierID  string                 `json:"supplier_id" dynamodbav:"supplier_id"`
+	Quantity    int                    `json:"quantity" dynamodbav:"quantity"`
+	ExpiryDate  time.Time              `json:"expiry_date" dynamodbav:"expiry_date"`
+	Metadata    map[string]interface{} `json:"metadata" dynamodbav:"metadata"`
+}
+
+// NewLotExpiringSoonEvent creates a LotExpiringSoon event for a lot with an expiry date
+func NewLotExpiringSoonEvent(lot *Lot, now time.Time) *LotExpiringSoonEvent {
+	return &LotExpiringSoonEvent{
+		ID:          uuid.New().String(),
+		Timestamp:   now.UTC(),
+		EventType:   LotExpiringSoonEventType,
+		LotID:       lot.ID,
+		BatchNumber: lot.BatchNumber,
+		ProductID:   lot.ProductID,
+		SupplierID:  lot.SupplierID,
+		Quantity:    lot.Quantity,
+		ExpiryDate:  *lot.ExpiryDate,
+		Metadata:    make(map[string]interface{}),
+	}
+}
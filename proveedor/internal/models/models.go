@@ -1,9 +1,12 @@
 package models
 
 import (
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
+
+	"medisupply/events"
 )
 
 // EventType represents the type of event
@@ -16,24 +19,30 @@ const (
 
 // RecepcionProveedorEvent represents a purchase order reception event from OrdenCompra
 type RecepcionProveedorEvent struct {
-	ID              string                 `json:"id" dynamodbav:"id"`
-	Timestamp       time.Time              `json:"timestamp" dynamodbav:"timestamp"`
-	Type            string                 `json:"type" dynamodbav:"type"`
-	EventType       EventType              `json:"event_type" dynamodbav:"event_type"`
-	PurchaseOrderID string                 `json:"purchase_order_id" dynamodbav:"purchase_order_id"`
-	ProductID       string                 `json:"product_id" dynamodbav:"product_id"`
-	ProductoID      string                 `json:"producto_id" dynamodbav:"producto_id"`
-	ProductName     string                 `json:"product_name" dynamodbav:"product_name"`
-	Quantity        int                    `json:"quantity" dynamodbav:"quantity"`
-	Cantidad        int                    `json:"cantidad" dynamodbav:"cantidad"`
-	SupplierID      string                 `json:"supplier_id" dynamodbav:"supplier_id"`
-	ProveedorID     string                 `json:"proveedor_id" dynamodbav:"proveedor_id"`
-	SupplierName    string                 `json:"supplier_name" dynamodbav:"supplier_name"`
-	Location        string                 `json:"location" dynamodbav:"location"`
-	Status          string                 `json:"status" dynamodbav:"status"`
-	Estado          string                 `json:"estado" dynamodbav:"estado"`
-	FechaRecepcion  time.Time              `json:"fecha_recepcion" dynamodbav:"fecha_recepcion"`
-	Metadata        map[string]interface{} `json:"metadata" dynamodbav:"metadata"`
+	ID               string                 `json:"id" dynamodbav:"id"`
+	Timestamp        time.Time              `json:"timestamp" dynamodbav:"timestamp"`
+	Type             string                 `json:"type" dynamodbav:"type"`
+	EventType        EventType              `json:"event_type" dynamodbav:"event_type"`
+	PurchaseOrderID  string                 `json:"purchase_order_id" dynamodbav:"purchase_order_id"`
+	ProductID        string                 `json:"product_id" dynamodbav:"product_id"`
+	ProductoID       string                 `json:"producto_id,omitempty" dynamodbav:"producto_id,omitempty"`
+	ProductName      string                 `json:"product_name" dynamodbav:"product_name"`
+	Quantity         int                    `json:"quantity" dynamodbav:"quantity"`
+	Cantidad         int                    `json:"cantidad,omitempty" dynamodbav:"cantidad,omitempty"`
+	SupplierID       string                 `json:"supplier_id" dynamodbav:"supplier_id"`
+	ProveedorID      string                 `json:"proveedor_id,omitempty" dynamodbav:"proveedor_id,omitempty"`
+	SupplierName     string                 `json:"supplier_name" dynamodbav:"supplier_name"`
+	Location         string                 `json:"location" dynamodbav:"location"`
+	Status           string                 `json:"status" dynamodbav:"status"`
+	Estado           string                 `json:"estado,omitempty" dynamodbav:"estado,omitempty"`
+	FechaRecepcion   time.Time              `json:"fecha_recepcion" dynamodbav:"fecha_recepcion"`
+	Reason           string                 `json:"reason,omitempty" dynamodbav:"reason,omitempty"`
+	InspectorID      string                 `json:"inspector_id,omitempty" dynamodbav:"inspector_id,omitempty"`
+	Notes            string                 `json:"notes,omitempty" dynamodbav:"notes,omitempty"`
+	BatchNumber      string                 `json:"batch_number,omitempty" dynamodbav:"batch_number,omitempty"`
+	ExpiryDate       *time.Time             `json:"expiry_date,omitempty" dynamodbav:"expiry_date,omitempty"`
+	ReceivedQuantity int                    `json:"received_quantity,omitempty" dynamodbav:"received_quantity,omitempty"`
+	Metadata         map[string]interface{} `json:"metadata" dynamodbav:"metadata"`
 }
 
 // InventoryReceivedEvent represents an inventory received event
@@ -70,23 +79,15 @@ type Supplier struct {
 	Metadata  map[string]interface{} `json:"metadata" dynamodbav:"metadata"`
 }
 
-// EventSourcingEvent represents an event sourcing event
-type EventSourcingEvent struct {
-	ID            string                 `json:"id" dynamodbav:"id"`
-	AggregateID   string                 `json:"aggregate_id" dynamodbav:"aggregate_id"`
-	EventType     string                 `json:"event_type" dynamodbav:"event_type"`
-	EventData     map[string]interface{} `json:"event_data" dynamodbav:"event_data"`
-	Timestamp     time.Time              `json:"timestamp" dynamodbav:"timestamp"`
-	Version       int                    `json:"version" dynamodbav:"version"`
-	CorrelationID *string                `json:"correlation_id,omitempty" dynamodbav:"correlation_id,omitempty"`
-	CausationID   *string                `json:"causation_id,omitempty" dynamodbav:"causation_id,omitempty"`
-}
+// EventSourcingEvent represents an event sourcing event, in the envelope
+// shared with orden-compra
+type EventSourcingEvent = events.Event
 
 // NewInventoryReceivedEvent creates a new InventoryReceivedEvent
-func NewInventoryReceivedEvent(purchaseOrderID, productID, productName, supplierID, supplierName, location, status string, quantity int) *InventoryReceivedEvent {
+func NewInventoryReceivedEvent(purchaseOrderID, productID, productName, supplierID, supplierName, location, status string, quantity int, now time.Time) *InventoryReceivedEvent {
 	return &InventoryReceivedEvent{
 		ID:              uuid.New().String(),
-		Timestamp:       time.Now().UTC(),
+		Timestamp:       now.UTC(),
 		EventType:       InventoryReceivedEventType,
 		PurchaseOrderID: purchaseOrderID,
 		ProductID:       productID,
@@ -96,29 +97,22 @@ func NewInventoryReceivedEvent(purchaseOrderID, productID, productName, supplier
 		SupplierName:    supplierName,
 		Location:        location,
 		Status:          status,
-		ReceivedAt:      time.Now().UTC(),
+		ReceivedAt:      now.UTC(),
 		QualityCheck:    "pending",
-		BatchNumber:     generateBatchNumber(),
+		BatchNumber:     GenerateBatchNumber(),
 		Metadata:        make(map[string]interface{}),
 	}
 }
 
-// NewEventSourcingEvent creates a new EventSourcingEvent
-func NewEventSourcingEvent(aggregateID, eventType string, eventData map[string]interface{}, correlationID, causationID *string) *EventSourcingEvent {
-	return &EventSourcingEvent{
-		ID:            uuid.New().String(),
-		AggregateID:   aggregateID,
-		EventType:     eventType,
-		EventData:     eventData,
-		Timestamp:     time.Now().UTC(),
-		Version:       1,
-		CorrelationID: correlationID,
-		CausationID:   causationID,
-	}
+// NewEventSourcingEvent creates a new EventSourcingEvent that happened at now
+func NewEventSourcingEvent(aggregateID, eventType string, eventData map[string]interface{}, correlationID, causationID *string, now time.Time) *EventSourcingEvent {
+	return events.New(aggregateID, eventType, eventData, correlationID, causationID, now)
 }
 
-// ProcessReception processes the reception event and creates inventory received event
-func (r *RecepcionProveedorEvent) ProcessReception() *InventoryReceivedEvent {
+// ProcessReception processes the reception event and creates inventory received
+// event received at now. temperature is the latest sensor reading of the
+// shipment, or nil when none was received.
+func (r *RecepcionProveedorEvent) ProcessReception(temperature *float64, now time.Time) *InventoryReceivedEvent {
 	// Simulate processing time
 	time.Sleep(100 * time.Millisecond)
 
@@ -132,6 +126,7 @@ func (r *RecepcionProveedorEvent) ProcessReception() *InventoryReceivedEvent {
 		r.Location,
 		"received",
 		r.Quantity,
+		now,
 	)
 
 	// Add correlation information
@@ -140,18 +135,21 @@ func (r *RecepcionProveedorEvent) ProcessReception() *InventoryReceivedEvent {
 	event.Metadata["purchase_order_id"] = r.PurchaseOrderID
 	event.Metadata["reception_event_id"] = r.ID
 
-	// Simulate quality check
-	event.QualityCheck = "passed"
+	// Quality stays pending until an inspector records a QualityInspection
 
-	// Simulate temperature check for temperature-controlled products
-	if r.Metadata["temperature_controlled"] == true {
-		temp := 2.5 // Simulate temperature reading
-		event.Temperature = &temp
+	// Report the sensor reading for temperature-controlled products
+	if r.IsTemperatureControlled() && temperature != nil {
+		event.SetTemperature(*temperature)
 	}
 
-	// Set expiry date (simulate 30 days from now)
-	expiryDate := time.Now().UTC().AddDate(0, 0, 30)
-	event.ExpiryDate = &expiryDate
+	// Keep the supplier's batch and expiry; a batch number is only generated
+	// when the supplier sent none
+	if r.BatchNumber != "" {
+		event.BatchNumber = r.BatchNumber
+	}
+	if r.ExpiryDate != nil {
+		event.SetExpiryDate(*r.ExpiryDate)
+	}
 
 	return event
 }
@@ -176,9 +174,19 @@ func (r *RecepcionProveedorEvent) GetUrgencyLevel() string {
 	return "medium"
 }
 
-// generateBatchNumber generates a batch number for the received inventory
-func generateBatchNumber() string {
-	return "BATCH-" + uuid.New().String()[:8]
+// generatedBatchPrefix starts the batch numbers generated by GenerateBatchNumber
+const generatedBatchPrefix = "BATCH-"
+
+// GenerateBatchNumber generates a batch number for received inventory whose
+// supplier did not send one
+func GenerateBatchNumber() string {
+	return generatedBatchPrefix + uuid.New().String()[:8]
+}
+
+// IsGeneratedBatchNumber reports whether a batch number was generated
+// rather than sent by the supplier
+func IsGeneratedBatchNumber(batchNumber string) bool {
+	return strings.HasPrefix(batchNumber, generatedBatchPrefix)
 }
 
 // UpdateStatus updates the inventory received event status
@@ -209,25 +217,52 @@ func (i *InventoryReceivedEvent) SetExpiryDate(expiryDate time.Time) {
 	i.ExpiryDate = &expiryDate
 }
 
-// RecepcionProveedor represents a recepcion proveedor entity
+// RecepcionProveedor represents a recepcion proveedor entity, one per
+// delivery. Cantidad is the quantity delivered; CantidadPendiente and
+// CantidadExcedente are what the purchase order still lacked, or received in
+// excess, once the delivery was counted.
 type RecepcionProveedor struct {
-	ID             string    `json:"id" dynamodbav:"id"`
-	ProveedorID    string    `json:"proveedor_id" dynamodbav:"proveedor_id"`
-	ProductoID     string    `json:"producto_id" dynamodbav:"producto_id"`
-	Cantidad       int       `json:"cantidad" dynamodbav:"cantidad"`
-	FechaRecepcion time.Time `json:"fecha_recepcion" dynamodbav:"fecha_recepcion"`
-	Estado         string    `json:"estado" dynamodbav:"estado"`
-	CreatedAt      time.Time `json:"created_at" dynamodbav:"created_at"`
-	UpdatedAt      time.Time `json:"updated_at" dynamodbav:"updated_at"`
+	ID                string             `json:"id" dynamodbav:"id"`
+	PurchaseOrderID   string             `json:"purchase_order_id,omitempty" dynamodbav:"purchase_order_id,omitempty"`
+	ProveedorID       string             `json:"proveedor_id" dynamodbav:"proveedor_id"`
+	ProductoID        string             `json:"producto_id" dynamodbav:"producto_id"`
+	CantidadOrdenada  int                `json:"cantidad_ordenada" dynamodbav:"cantidad_ordenada"`
+	Cantidad          int                `json:"cantidad" dynamodbav:"cantidad"`
+	CantidadPendiente int                `json:"cantidad_pendiente" dynamodbav:"cantidad_pendiente"`
+	CantidadExcedente int                `json:"cantidad_excedente" dynamodbav:"cantidad_excedente"`
+	FechaRecepcion    time.Time          `json:"fecha_recepcion" dynamodbav:"fecha_recepcion"`
+	Estado            string             `json:"estado" dynamodbav:"estado"`
+	QualityInspection *QualityInspection `json:"quality_inspection,omitempty" dynamodbav:"quality_inspection,omitempty"`
+	TemperatureBreach bool               `json:"temperature_breach" dynamodbav:"temperature_breach"`
+	ASNID             string             `json:"asn_id,omitempty" dynamodbav:"asn_id,omitempty"`
+	Carrier           string             `json:"carrier,omitempty" dynamodbav:"carrier,omitempty"`
+	TrackingNumber    string             `json:"tracking_number,omitempty" dynamodbav:"tracking_number,omitempty"`
+	// CancellationReason is why the purchase order was cancelled, for
+	// receptions in EstadoCancelled
+	CancellationReason string    `json:"cancellation_reason,omitempty" dynamodbav:"cancellation_reason,omitempty"`
+	CreatedAt          time.Time `json:"created_at" dynamodbav:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at" dynamodbav:"updated_at"`
+}
+
+// PurchaseOrderCancellation records that OrdenCompra cancelled a purchase
+// order, so deliveries arriving for it afterwards are not received
+type PurchaseOrderCancellation struct {
+	PurchaseOrderID string    `json:"purchase_order_id" dynamodbav:"purchase_order_id"`
+	Reason          string    `json:"reason,omitempty" dynamodbav:"reason,omitempty"`
+	CancelledAt     time.Time `json:"cancelled_at" dynamodbav:"cancelled_at"`
 }
 
 // InventarioRecibidoEvent represents an inventario recibido event
 type InventarioRecibidoEvent struct {
-	ID             string    `json:"id" dynamodbav:"id"`
-	ProveedorID    string    `json:"proveedor_id" dynamodbav:"proveedor_id"`
-	ProductoID     string    `json:"producto_id" dynamodbav:"producto_id"`
-	Cantidad       int       `json:"cantidad" dynamodbav:"cantidad"`
-	FechaRecepcion time.Time `json:"fecha_recepcion" dynamodbav:"fecha_recepcion"`
-	Estado         string    `json:"estado" dynamodbav:"estado"`
-	Timestamp      time.Time `json:"timestamp" dynamodbav:"timestamp"`
+	ID                string    `json:"id" dynamodbav:"id"`
+	PurchaseOrderID   string    `json:"purchase_order_id,omitempty" dynamodbav:"purchase_order_id,omitempty"`
+	ProveedorID       string    `json:"proveedor_id" dynamodbav:"proveedor_id"`
+	ProductoID        string    `json:"producto_id" dynamodbav:"producto_id"`
+	Cantidad          int       `json:"cantidad" dynamodbav:"cantidad"`
+	FechaRecepcion    time.Time `json:"fecha_recepcion" dynamodbav:"fecha_recepcion"`
+	Estado            string    `json:"estado" dynamodbav:"estado"`
+	QualityCheck      string    `json:"quality_check,omitempty" dynamodbav:"quality_check,omitempty"`
+	Temperature       *float64  `json:"temperature,omitempty" dynamodbav:"temperature,omitempty"`
+	TemperatureBreach bool      `json:"temperature_breach" dynamodbav:"temperature_breach"`
+	Timestamp         time.Time `json:"timestamp" dynamodbav:"timestamp"`
 }
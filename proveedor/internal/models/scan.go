@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+)
+
+// ReceptionScannedEventType is logged under the purchase order when a
+// barcode is scanned on one of its receptions
+const ReceptionScannedEventType EventType = "ReceptionScanned"
+
+// ProductGTIN maps the GTIN printed on a product's packaging to the product
+// ordered on purchase orders. GTIN holds the 14 digits of AI (01).
+type ProductGTIN struct {
+	GTIN      string    `json:"gtin" dynamodbav:"gtin"`
+	ProductID string    `json:"product_id" dynamodbav:"product_id"`
+	UpdatedAt time.Time `json:"updated_at" dynamodbav:"updated_at"`
+}
+
+// ReceptionScan is a GS1 barcode scanned on a reception by warehouse staff.
+// Barcode is the scanned data in human readable form; LotID is the lot of
+// the reception the scanned batch belongs to.
+type ReceptionScan struct {
+	ID              string     `json:"id" dynamodbav:"id"`
+	RecepcionID     string     `json:"recepcion_id" dynamodbav:"recepcion_id"`
+	PurchaseOrderID string     `json:"purchase_order_id,omitempty" dynamodbav:"purchase_order_id,omitempty"`
+	ProductoID      string     `json:"producto_id" dynamodbav:"producto_id"`
+	Barcode         string     `json:"barcode" dynamodbav:"barcode"`
+	GTIN            string     `json:"gtin" dynamodbav:"gtin"`
+	BatchNumber     string     `json:"batch_number,omitempty" dynamodbav:"batch_number,omitempty"`
+	ExpiryDate      *time.Time `json:"expiry_date,omitempty" dynamodbav:"expiry_date,omitempty"`
+	SerialNumber    string     `json:"serial_number,omitempty" dynamodbav:"serial_number,omitempty"`
+	Cantidad        int        `json:"cantidad" dynamodbav:"cantidad"`
+	LotID           string     `json:"lot_id,omitempty" dynamodbav:"lot_id,omitempty"`
+	ScannedBy       string     `json:"scanned_by" dynamodbav:"scanned_by"`
+	CreatedAt       time.Time  `json:"created_at" dynamodbav:"created_at"`
+}
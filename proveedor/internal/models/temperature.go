@@ -0,0 +1,68 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TemperatureBreachEventType is produced when a shipment leaves its temperature range
+const TemperatureBreachEventType EventType = "TemperatureBreach"
+
+// TemperatureReading is a sensor reading taken during a shipment. Shipments
+// are identified by the purchase order they deliver; ShipmentID is set on
+// readings sensors reported by shipment rather than by purchase order.
+// Humidity is the relative humidity in percent, for sensors measuring it.
+type TemperatureReading struct {
+	ID              string    `json:"id" dynamodbav:"id"`
+	PurchaseOrderID string    `json:"purchase_order_id" dynamodbav:"purchase_order_id"`
+	ShipmentID      string    `json:"shipment_id,omitempty" dynamodbav:"shipment_id,omitempty"`
+	SensorID        string    `json:"sensor_id" dynamodbav:"sensor_id"`
+	Temperature     float64   `json:"temperature" dynamodbav:"temperature"`
+	Humidity        *float64  `json:"humidity,omitempty" dynamodbav:"humidity,omitempty"`
+	RecordedAt      time.Time `json:"recorded_at" dynamodbav:"recorded_at"`
+	InRange         bool      `json:"in_range" dynamodbav:"in_range"`
+}
+
+// TemperatureRange is the inclusive range, in degrees Celsius, goods must be kept in
+type TemperatureRange struct {
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+}
+
+// Contains reports whether the temperature is within the range
+func (r TemperatureRange) Contains(temperature float64) bool {
+	return temperature >= r.Min && temperature <= r.Max
+}
+
+// TemperatureBreachEvent reports a reading outside the configured range
+type TemperatureBreachEvent struct {
+	ID              string                 `json:"id" dynamodbav:"id"`
+	Timestamp       time.Time              `json:"timestamp" dynamodbav:"timestamp"`
+	EventType       EventType              `json:"event_type" dynamodbav:"event_type"`
+	PurchaseOrderID string                 `json:"purchase_order_id" dynamodbav:"purchase_order_id"`
+	RecepcionID     string                 `json:"recepcion_id,omitempty" dynamodbav:"recepcion_id,omitempty"`
+	SensorID        string                 `json:"sensor_id" dynamodbav:"sensor_id"`
+	Temperature     float64                `json:"temperature" dynamodbav:"temperature"`
+	MinTemperature  float64                `json:"min_temperature" dynamodbav:"min_temperature"`
+	MaxTemperature  float64                `json:"max_temperature" dynamodbav:"max_temperature"`
+	RecordedAt      time.Time              `json:"recorded_at" dynamodbav:"recorded_at"`
+	Metadata        map[string]interface{} `json:"metadata" dynamodbav:"metadata"`
+}
+
+// NewTemperatureBreachEvent creates a TemperatureBreach event for an out-of-range reading
+func NewTemperatureBreachEvent(reading *TemperatureReading, allowed TemperatureRange, recepcionID string, now time.Time) *TemperatureBreachEvent {
+	return &TemperatureBreachEvent{
+		ID:              uuid.New().String(),
+		Timestamp:       now.UTC(),
+		EventType:       TemperatureBreachEventType,
+		PurchaseOrderID: reading.PurchaseOrderID,
+		RecepcionID:     recepcionID,
+		SensorID:        reading.SensorID,
+		Temperature:     reading.Temperature,
+		MinTemperature:  allowed.Min,
+		MaxTemperature:  allowed.Max,
+		RecordedAt:      reading.RecordedAt,
+		Metadata:        make(map[string]interface{}),
+	}
+}
@@ -1,91 +0,0 @@
-package observability
-
-import (
-	"context"
-	"log"
-
-	"go.opentelemetry.io/otel"
-	"go.opentelemetry.io/otel/exporters/jaeger"
-	"go.opentelemetry.io/otel/exporters/prometheus"
-	"go.opentelemetry.io/otel/sdk/metric"
-	"go.opentelemetry.io/otel/sdk/resource"
-	"go.opentelemetry.io/otel/sdk/trace"
-	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
-)
-
-// InitTracing initializes OpenTelemetry tracing
-func InitTracing(serviceName, jaegerEndpoint string) (*trace.TracerProvider, error) {
-	// Create Jaeger exporter
-	exp, err := jaeger.New(jaeger.WithCollectorEndpoint(jaeger.WithEndpoint(jaegerEndpoint)))
-	if err != nil {
-		return nil, err
-	}
-
-	// Create resource
-	res, err := resource.New(context.Background(),
-		resource.WithAttributes(
-			semconv.ServiceNameKey.String(serviceName),
-			semconv.ServiceVersionKey.String("1.0.0"),
-		),
-	)
-	if err != nil {
-		return nil, err
-	}
-
-	// Create tracer provider
-	tp := trace.NewTracerProvider(
-		trace.WithBatcher(exp),
-		trace.WithResource(res),
-	)
-
-	// Set global tracer provider
-	otel.SetTracerProvider(tp)
-
-	return tp, nil
-}
-
-// InitMetrics initializes OpenTelemetry metrics
-func InitMetrics(serviceName string) (*metric.MeterProvider, error) {
-	// Create Prometheus exporter
-	exp, err := prometheus.New()
-	if err != nil {
-		return nil, err
-	}
-
-	// Create resource
-	res, err := resource.New(context.Background(),
-		resource.WithAttributes(
-			semconv.ServiceNameKey.String(serviceName),
-			semconv.ServiceVersionKey.String("1.0.0"),
-		),
-	)
-	if err != nil {
-		return nil, err
-	}
-
-	// Create meter provider
-	mp := metric.NewMeterProvider(
-		metric.WithReader(exp),
-		metric.WithResource(res),
-	)
-
-	// Set global meter provider
-	otel.SetMeterProvider(mp)
-
-	return mp, nil
-}
-
-// Shutdown gracefully shuts down the observability components
-func Shutdown(tp *trace.TracerProvider, mp *metric.MeterProvider) {
-	if tp != nil {
-		if err := tp.Shutdown(context.Background()); err != nil {
-			log.Printf("Error shutting down tracer provider: %v", err)
-		}
-	}
-
-	if mp != nil {
-		if err := mp.Shutdown(context.Background()); err != nil {
-			log.Printf("Error shutting down meter provider: %v", err)
-		}
-	}
-}
@@ -0,0 +1,19 @@
+#!/bin/bash
+
+# Generates the Go code of the protobuf contracts in proto/ with protoc,
+# protoc-gen-go and protoc-gen-go-grpc. Install the plugins at the versions
+# pkg/medisupply/go.mod builds with:
+#   go install google.golang.org/protobuf/cmd/protoc-gen-go@v1.31.0
+#   go install google.golang.org/grpc/cmd/protoc-gen-go-grpc@v1.3.0
+
+set -e
+
+ROOT="$(cd "$(dirname "$0")/.." && pwd)"
+cd "$ROOT/proto"
+
+protoc --go_out="$ROOT/pkg/medisupply" --go_opt=module=medisupply \
+    medisupply/events/v1/events.proto
+
+protoc --go_out="$ROOT/pkg/medisupply" --go_opt=module=medisupply \
+    --go-grpc_out="$ROOT/pkg/medisupply" --go-grpc_opt=module=medisupply \
+    medisupply/ordencompra/v1/orden_compra.proto